@@ -0,0 +1,34 @@
+// Command blockconv rewrites the blocks of a store data directory in another
+// encoding.
+//
+// Stores read blocks in whatever encoding they were written with, so running
+// it is never required. It is useful for moving old gob blocks, including
+// block_*.gob files from before segment files existed, to protobuf in one pass
+// instead of waiting for them to be rewritten. The store must be stopped while
+// it runs.
+package main
+
+import (
+	"flag"
+	"log"
+
+	"imy/pkg/storage"
+)
+
+var (
+	dir   = flag.String("dir", "", "store data directory")
+	codec = flag.String("codec", string(storage.BlockCodecProtobuf), "target encoding: protobuf | gob")
+)
+
+func main() {
+	flag.Parse()
+	if *dir == "" {
+		log.Fatal("-dir is required")
+	}
+
+	converted, err := storage.ConvertBlockCodec(*dir, storage.BlockCodec(*codec))
+	if err != nil {
+		log.Fatalf("convert %s: %v", *dir, err)
+	}
+	log.Printf("rewrote %d blocks in %s as %s", converted, *dir, *codec)
+}
@@ -0,0 +1,120 @@
+// Command bot is a sample echo bot for the bot API.
+//
+// It exchanges its service-account credentials for a bot-scoped token, polls the
+// conversations it has been added to and echoes back every text message sent by
+// someone else. Add the bot's client id as a member of a conversation to try it.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"imy/pkg/imyclient"
+)
+
+var (
+	gateway      = flag.String("gateway", "http://127.0.0.1:8081", "gateway base url")
+	clientID     = flag.String("client-id", os.Getenv("BOT_CLIENT_ID"), "service account client id")
+	clientSecret = flag.String("client-secret", os.Getenv("BOT_CLIENT_SECRET"), "service account client secret")
+	interval     = flag.Duration("interval", 2*time.Second, "poll interval")
+)
+
+type bot struct {
+	client *imyclient.Client
+	// cursors holds the last seen message id per conversation; conversations
+	// seen for the first time start at their latest message so history is not echoed
+	cursors map[uint32]uint64
+}
+
+func main() {
+	flag.Parse()
+	if *clientID == "" || *clientSecret == "" {
+		log.Fatal("bot: -client-id and -client-secret are required")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := imyclient.New(imyclient.Config{BaseURL: *gateway})
+	if err != nil {
+		log.Fatalf("bot: %v", err)
+	}
+	// the client keeps the credentials and exchanges them again whenever the
+	// token expires, so a failed first attempt is retried by the next poll
+	if session, err := client.LoginService(ctx, *clientID, *clientSecret); err != nil {
+		log.Printf("bot: service token: %v", err)
+	} else {
+		log.Printf("bot: signed in as %s, token expires at %s", session.UUID, session.ExpiresAt.Format(time.RFC3339))
+	}
+
+	b := &bot{client: client, cursors: make(map[uint32]uint64)}
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		if err := b.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("bot: poll: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll checks every conversation once and echoes the new messages
+func (b *bot) poll(ctx context.Context) error {
+	convs, err := b.client.GetConversations(ctx, 100, 1)
+	if err != nil {
+		return err
+	}
+	for _, conv := range convs {
+		cursor, ok := b.cursors[conv.ConversationId]
+		if !ok {
+			b.cursors[conv.ConversationId] = conv.LastMessageId
+			log.Printf("bot: joined conversation %d", conv.ConversationId)
+			continue
+		}
+		if conv.LastMessageId <= cursor {
+			continue
+		}
+		if err := b.echo(ctx, conv.ConversationId, cursor); err != nil {
+			log.Printf("bot: conversation %d: %v", conv.ConversationId, err)
+		}
+	}
+	return nil
+}
+
+// echo replies to the messages after cursor and advances it
+func (b *bot) echo(ctx context.Context, conversationID uint32, cursor uint64) error {
+	msgs, err := b.client.GetMessages(ctx, &imyclient.GetMessagesRequest{ConversationId: conversationID, AfterId: cursor, Limit: 50})
+	if err != nil {
+		return err
+	}
+	self := b.client.UUID()
+	for _, m := range msgs {
+		if m.Id <= cursor {
+			continue
+		}
+		if m.SendUuid != self && m.MsgType == imyclient.MsgTypeText && m.IsSystem == 0 && m.IsRevoked == 0 {
+			_, err := b.client.SendMessage(ctx, &imyclient.SendMessageRequest{
+				ConversationId:   conversationID,
+				ClientMsgId:      fmt.Sprintf("echo-%d", m.Id), // retries are deduplicated
+				MsgType:          imyclient.MsgTypeText,
+				Content:          m.Content,
+				ReplyToMessageId: m.Id,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cursor = m.Id
+		b.cursors[conversationID] = cursor
+	}
+	return nil
+}
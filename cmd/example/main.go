@@ -39,7 +39,7 @@ func exampleRegisterStores(ctx context.Context, registry storage.StoreRegistry)
 		{
 			ID:      "store-1",
 			Address: "192.168.1.10:8080",
-			Status:  "healthy",
+			Status:  storage.StoreStatusActive,
 			Metadata: map[string]interface{}{
 				"region":   "us-west-1",
 				"capacity": 10737418240, // 10GB
@@ -49,7 +49,7 @@ func exampleRegisterStores(ctx context.Context, registry storage.StoreRegistry)
 		{
 			ID:      "store-2",
 			Address: "192.168.1.11:8080",
-			Status:  "healthy",
+			Status:  storage.StoreStatusActive,
 			Metadata: map[string]interface{}{
 				"region":   "us-west-1",
 				"capacity": 21474836480, // 20GB
@@ -77,9 +77,9 @@ func exampleCustomRouting(ctx context.Context) {
 
 	// 添加Store节点
 	stores := []*storage.StoreInfo{
-		{ID: "store-1", Address: "192.168.1.10:8080", Status: "healthy"},
-		{ID: "store-2", Address: "192.168.1.11:8080", Status: "healthy"},
-		{ID: "store-3", Address: "192.168.1.12:8080", Status: "healthy"},
+		{ID: "store-1", Address: "192.168.1.10:8080", Status: storage.StoreStatusActive},
+		{ID: "store-2", Address: "192.168.1.11:8080", Status: storage.StoreStatusActive},
+		{ID: "store-3", Address: "192.168.1.12:8080", Status: storage.StoreStatusActive},
 	}
 
 	for _, store := range stores {
@@ -111,9 +111,9 @@ func exampleLoadBalancing(ctx context.Context) {
 
 	// 添加Store节点
 	stores := []*storage.StoreInfo{
-		{ID: "store-1", Address: "192.168.1.10:8080", Status: "healthy"},
-		{ID: "store-2", Address: "192.168.1.11:8080", Status: "healthy"},
-		{ID: "store-3", Address: "192.168.1.12:8080", Status: "healthy"},
+		{ID: "store-1", Address: "192.168.1.10:8080", Status: storage.StoreStatusActive},
+		{ID: "store-2", Address: "192.168.1.11:8080", Status: storage.StoreStatusActive},
+		{ID: "store-3", Address: "192.168.1.12:8080", Status: storage.StoreStatusActive},
 	}
 
 	for _, store := range stores {
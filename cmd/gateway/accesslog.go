@@ -0,0 +1,476 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type AccessLogConfig struct {
+	Enabled bool            `json:",optional"`
+	Output  string          `json:",default=stdout"` // stdout | file | syslog | kafka
+	File    AccessLogFile   `json:",optional"`
+	Syslog  AccessLogSyslog `json:",optional"`
+	Kafka   AccessLogKafka  `json:",optional"`
+	Audit   AuditConfig     `json:",optional"`
+}
+
+type AccessLogFile struct {
+	Path string `json:",default=logs/gateway-access.log"`
+}
+
+type AccessLogSyslog struct {
+	Network string `json:",optional"` // empty connects to the local syslog daemon
+	Addr    string `json:",optional"`
+	Tag     string `json:",default=imy-gateway"`
+}
+
+// AccessLogKafka publishes through a Kafka REST Proxy (v2 JSON API)
+type AccessLogKafka struct {
+	RestProxy     string        `json:",optional"` // e.g. http://127.0.0.1:8082
+	Topic         string        `json:",optional"`
+	BatchSize     int           `json:",default=100"`
+	FlushInterval time.Duration `json:",default=1s"`
+}
+
+// AuditConfig records request and response bodies of sensitive routes
+type AuditConfig struct {
+	Enabled     bool     `json:",optional"`
+	Routes      []string `json:",optional"`      // regexes matched against the request path
+	MaxBodySize int      `json:",default=65536"` // bodies are truncated beyond this
+	Redact      []string `json:",optional"`      // JSON fields masked in bodies, see defaultRedact
+}
+
+var defaultRedact = []string{"password", "token", "accessToken", "refreshToken"}
+
+// accessEntry is one JSON line of the access log
+type accessEntry struct {
+	Time      string       `json:"ts"`
+	RequestID string       `json:"request_id"`
+	UUID      string       `json:"uuid,omitempty"`
+	ClientIP  string       `json:"client_ip"`
+	Method    string       `json:"method"`
+	Path      string       `json:"path"`
+	Status    int          `json:"status"`
+	LatencyMs float64      `json:"latency_ms"`
+	Bytes     int64        `json:"bytes"`
+	UserAgent string       `json:"user_agent,omitempty"`
+	Audit     *auditRecord `json:"audit,omitempty"`
+}
+
+type auditRecord struct {
+	Query        string          `json:"query,omitempty"`
+	RequestBody  json.RawMessage `json:"request_body,omitempty"`
+	ResponseBody json.RawMessage `json:"response_body,omitempty"`
+	Truncated    bool            `json:"truncated,omitempty"`
+}
+
+// logSink receives serialized lines from the logger goroutine
+type logSink interface {
+	Write(line []byte) error
+	Flush() error
+	Close() error
+}
+
+// accessLogger writes entries asynchronously; when the buffer is full entries
+// are dropped and counted rather than slowing requests down
+type accessLogger struct {
+	cfg     AccessLogConfig
+	sink    logSink
+	audits  []*regexp.Regexp
+	redact  map[string]bool
+	ch      chan []byte
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+type accessInfoKey struct{}
+
+// accessInfo lets the handler report facts only known after authentication
+type accessInfo struct {
+	uuid string
+}
+
+// setAccessUUID records the authenticated user of the current request
+func setAccessUUID(ctx context.Context, uuid string) {
+	if info, ok := ctx.Value(accessInfoKey{}).(*accessInfo); ok {
+		info.uuid = uuid
+	}
+}
+
+func newAccessLogger(cfg AccessLogConfig) (*accessLogger, error) {
+	// nested defaults are not applied when the whole section is omitted
+	if cfg.Syslog.Tag == "" {
+		cfg.Syslog.Tag = "imy-gateway"
+	}
+	if cfg.Kafka.BatchSize <= 0 {
+		cfg.Kafka.BatchSize = 100
+	}
+	if cfg.File.Path == "" {
+		cfg.File.Path = "logs/gateway-access.log"
+	}
+	sink, err := newLogSink(cfg)
+	if err != nil {
+		return nil, err
+	}
+	l := &accessLogger{
+		cfg:    cfg,
+		sink:   sink,
+		redact: make(map[string]bool),
+		ch:     make(chan []byte, 4096),
+		done:   make(chan struct{}),
+	}
+	if cfg.Audit.Enabled {
+		for _, p := range cfg.Audit.Routes {
+			re, err := regexp.Compile(p)
+			if err != nil {
+				sink.Close()
+				return nil, fmt.Errorf("audit route %q: %w", p, err)
+			}
+			l.audits = append(l.audits, re)
+		}
+		if l.cfg.Audit.MaxBodySize <= 0 {
+			l.cfg.Audit.MaxBodySize = 64 << 10
+		}
+	}
+	redact := cfg.Audit.Redact
+	if len(redact) == 0 {
+		redact = defaultRedact
+	}
+	for _, f := range redact {
+		l.redact[strings.ToLower(f)] = true
+	}
+	go l.loop()
+	return l, nil
+}
+
+func newLogSink(cfg AccessLogConfig) (logSink, error) {
+	switch cfg.Output {
+	case "", "stdout":
+		return &writerSink{w: bufio.NewWriter(os.Stdout)}, nil
+	case "file":
+		if err := os.MkdirAll(filepath.Dir(cfg.File.Path), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(cfg.File.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return &writerSink{w: bufio.NewWriter(f), c: f}, nil
+	case "syslog":
+		w, err := syslog.Dial(cfg.Syslog.Network, cfg.Syslog.Addr, syslog.LOG_INFO|syslog.LOG_LOCAL0, cfg.Syslog.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return &syslogSink{w: w}, nil
+	case "kafka":
+		if cfg.Kafka.RestProxy == "" || cfg.Kafka.Topic == "" {
+			return nil, fmt.Errorf("kafka access log requires RestProxy and Topic")
+		}
+		return &kafkaSink{cfg: cfg.Kafka, client: &http.Client{Timeout: 5 * time.Second}}, nil
+	}
+	return nil, fmt.Errorf("unknown access log output %q", cfg.Output)
+}
+
+func (l *accessLogger) loop() {
+	defer close(l.done)
+	interval := l.cfg.Kafka.FlushInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case line, ok := <-l.ch:
+			if !ok {
+				if err := l.sink.Flush(); err != nil {
+					logx.Errorf("gateway access log: flush failed: %v", err)
+				}
+				return
+			}
+			if err := l.sink.Write(line); err != nil {
+				logx.Errorf("gateway access log: write failed: %v", err)
+			}
+		case <-ticker.C:
+			if err := l.sink.Flush(); err != nil {
+				logx.Errorf("gateway access log: flush failed: %v", err)
+			}
+			if n := l.dropped.Swap(0); n > 0 {
+				logx.Errorf("gateway access log: dropped %d entries, buffer full", n)
+			}
+		}
+	}
+}
+
+// Close flushes pending entries and closes the sink
+func (l *accessLogger) Close() error {
+	close(l.ch)
+	<-l.done
+	return l.sink.Close()
+}
+
+func (l *accessLogger) audited(path string) bool {
+	for _, re := range l.audits {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware logs every request handled by next
+func (l *accessLogger) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &accessInfo{}
+		r = r.WithContext(context.WithValue(r.Context(), accessInfoKey{}, info))
+		aw := &accessWriter{ResponseWriter: w, code: http.StatusOK}
+
+		var audit *auditRecord
+		if l.audited(r.URL.Path) {
+			audit = &auditRecord{Query: r.URL.RawQuery}
+			if r.Body != nil && r.Body != http.NoBody {
+				body, err := io.ReadAll(io.LimitReader(r.Body, int64(l.cfg.Audit.MaxBodySize)+1))
+				if err == nil {
+					r.Body = struct {
+						io.Reader
+						io.Closer
+					}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+					if len(body) > l.cfg.Audit.MaxBodySize {
+						body = body[:l.cfg.Audit.MaxBodySize]
+						audit.Truncated = true
+					}
+					audit.RequestBody = l.redactBody(body)
+				}
+			}
+			aw.capture = &bytes.Buffer{}
+			aw.captureLimit = l.cfg.Audit.MaxBodySize
+		}
+
+		next.ServeHTTP(aw, r)
+
+		if audit != nil {
+			audit.ResponseBody = l.redactBody(aw.capture.Bytes())
+			audit.Truncated = audit.Truncated || aw.captureTruncated
+		}
+		entry := accessEntry{
+			Time:      start.UTC().Format(time.RFC3339Nano),
+			RequestID: r.Header.Get("X-Request-Id"),
+			UUID:      info.uuid,
+			ClientIP:  getClientIP(r),
+			Method:    r.Method,
+			Path:      r.URL.Path,
+			Status:    aw.code,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+			Bytes:     aw.bytes,
+			UserAgent: r.UserAgent(),
+			Audit:     audit,
+		}
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		select {
+		case l.ch <- line:
+		default:
+			l.dropped.Add(1)
+		}
+	})
+}
+
+// redactBody masks sensitive JSON fields; non-JSON bodies are kept as a JSON string
+func (l *accessLogger) redactBody(body []byte) json.RawMessage {
+	if len(body) == 0 {
+		return nil
+	}
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		s, _ := json.Marshal(string(body))
+		return s
+	}
+	out, err := json.Marshal(l.redactValue(v))
+	if err != nil {
+		return nil
+	}
+	return out
+}
+
+func (l *accessLogger) redactValue(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, val := range t {
+			if l.redact[strings.ToLower(k)] {
+				t[k] = "***"
+			} else {
+				t[k] = l.redactValue(val)
+			}
+		}
+	case []any:
+		for i, val := range t {
+			t[i] = l.redactValue(val)
+		}
+	}
+	return v
+}
+
+// accessWriter records status and size, and optionally a copy of the body
+type accessWriter struct {
+	http.ResponseWriter
+	code             int
+	bytes            int64
+	capture          *bytes.Buffer
+	captureLimit     int
+	captureTruncated bool
+}
+
+func (w *accessWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *accessWriter) Write(p []byte) (int, error) {
+	if w.capture != nil {
+		if room := w.captureLimit - w.capture.Len(); room >= len(p) {
+			w.capture.Write(p)
+		} else {
+			if room > 0 {
+				w.capture.Write(p[:room])
+			}
+			w.captureTruncated = true
+		}
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *accessWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *accessWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writerSink writes newline-delimited lines to a buffered writer
+type writerSink struct {
+	w *bufio.Writer
+	c io.Closer
+}
+
+func (s *writerSink) Write(line []byte) error {
+	if _, err := s.w.Write(line); err != nil {
+		return err
+	}
+	return s.w.WriteByte('\n')
+}
+
+func (s *writerSink) Flush() error {
+	return s.w.Flush()
+}
+
+func (s *writerSink) Close() error {
+	err := s.w.Flush()
+	if s.c != nil {
+		if cerr := s.c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+func (s *syslogSink) Write(line []byte) error {
+	return s.w.Info(string(line))
+}
+
+func (s *syslogSink) Flush() error { return nil }
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
+
+// kafkaSink batches lines and posts them to the REST Proxy
+type kafkaSink struct {
+	cfg    AccessLogKafka
+	client *http.Client
+	mu     sync.Mutex
+	batch  []json.RawMessage
+}
+
+func (s *kafkaSink) Write(line []byte) error {
+	s.mu.Lock()
+	s.batch = append(s.batch, json.RawMessage(append([]byte(nil), line...)))
+	full := s.cfg.BatchSize > 0 && len(s.batch) >= s.cfg.BatchSize
+	s.mu.Unlock()
+	if full {
+		return s.Flush()
+	}
+	return nil
+}
+
+func (s *kafkaSink) Flush() error {
+	s.mu.Lock()
+	batch := s.batch
+	s.batch = nil
+	s.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+
+	type record struct {
+		Value json.RawMessage `json:"value"`
+	}
+	payload := struct {
+		Records []record `json:"records"`
+	}{Records: make([]record, len(batch))}
+	for i, line := range batch {
+		payload.Records[i].Value = line
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(s.cfg.RestProxy, "/") + "/topics/" + s.cfg.Topic
+	resp, err := s.client.Post(url, "application/vnd.kafka.json.v2+json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("kafka rest proxy: %w (%d entries lost)", err, len(batch))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka rest proxy: %s %s (%d entries lost)", resp.Status, strings.TrimSpace(string(msg)), len(batch))
+	}
+	return nil
+}
+
+func (s *kafkaSink) Close() error {
+	return s.Flush()
+}
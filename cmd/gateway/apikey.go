@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/syncx"
+	"imy/pkg/jwt"
+)
+
+// APIKeyConfig lets integrations that cannot log in (bots, server to server)
+// authenticate with a static key sent in Header. Keys are configured here by
+// their sha256 hash, or stored upstream as service accounts when Upstream is
+// set: a key of the form <clientId>.<secret> is exchanged at ExchangePath and
+// the resulting identity is cached for CacheTTL. Keys are reloaded live, so
+// revoking or re-limiting a key needs no restart; Upstream, ExchangePath and
+// CacheTTL are read at startup
+type APIKeyConfig struct {
+	Enabled      bool          `json:",optional"`
+	Header       string        `json:",default=X-Api-Key"`
+	Keys         []APIKey      `json:",optional"`
+	Upstream     bool          `json:",optional"`
+	ExchangePath string        `json:",default=/api/auth/serviceToken"`
+	CacheTTL     time.Duration `json:",default=1m"`
+}
+
+// APIKey maps a key to the identity injected for it. Entries without a UUID
+// only revoke or limit a key stored upstream
+type APIKey struct {
+	Name     string
+	Hash     string  // hex sha256 of the key, e.g. printf %s "$KEY" | sha256sum
+	UUID     string  `json:",optional"`
+	Nickname string  `json:",optional"`
+	Scope    string  `json:",optional"` // bot limits the key to BotPaths
+	RPS      float64 `json:",optional"` // per-key limit on top of the per-uuid one
+	Burst    int     `json:",optional"`
+	Revoked  bool    `json:",optional"`
+}
+
+var (
+	errAPIKeyInvalid = errors.New("invalid api key")
+	errAPIKeyRevoked = errors.New("api key revoked")
+)
+
+type apiKeyEntry struct {
+	APIKey
+	limit   rateLimit
+	limited bool
+}
+
+// apiKeySet is the live part of the api key config
+type apiKeySet struct {
+	header string
+	keys   map[string]*apiKeyEntry // by hash
+	limits limiterBackend
+}
+
+// newAPIKeySet validates the configured keys; per-key limits share the
+// gateway limiter backend when rate limiting is enabled
+func newAPIKeySet(c APIKeyConfig, limiter *gatewayLimiter, prev *apiKeySet) (*apiKeySet, error) {
+	s := &apiKeySet{
+		header: c.Header,
+		keys:   make(map[string]*apiKeyEntry, len(c.Keys)),
+	}
+	for _, k := range c.Keys {
+		hash := strings.ToLower(strings.TrimSpace(k.Hash))
+		if b, err := hex.DecodeString(hash); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("api key %s: Hash must be a hex sha256", k.Name)
+		}
+		if _, ok := s.keys[hash]; ok {
+			return nil, fmt.Errorf("api key %s: duplicate hash", k.Name)
+		}
+		if k.UUID == "" && !k.Revoked && !c.Upstream {
+			return nil, fmt.Errorf("api key %s: UUID required", k.Name)
+		}
+		if k.Name == "" {
+			k.Name = hash[:12]
+		}
+		k.Hash = hash
+		e := &apiKeyEntry{APIKey: k}
+		if k.RPS > 0 {
+			e.limit, e.limited = newRateLimit(k.RPS, k.Burst), true
+		}
+		s.keys[hash] = e
+	}
+	switch {
+	case limiter != nil:
+		s.limits = limiter.backend
+	case prev != nil:
+		// keep the buckets across reloads
+		s.limits = prev.limits
+	}
+	if s.limits == nil {
+		s.limits = NewClientLimiter(0, 0)
+	}
+	return s, nil
+}
+
+// Take reads the key header and removes it so it is not forwarded upstream
+func (s *apiKeySet) Take(r *http.Request) string {
+	key := strings.TrimSpace(r.Header.Get(s.header))
+	r.Header.Del(s.header)
+	return key
+}
+
+// apiKeyIdentity is what an authenticated key injects; token is empty for
+// configured keys, which have no upstream session
+type apiKeyIdentity struct {
+	name   string
+	claims *jwt.CustomClaims
+	token  string
+}
+
+// Resolve looks key up in the configured keys, then upstream when exchange is
+// set, and applies the per-key limit. allowed is false when the key is over it
+func (s *apiKeySet) Resolve(ctx context.Context, key string, exchange *apiKeyExchange) (id *apiKeyIdentity, allowed bool, err error) {
+	sum := sha256.Sum256([]byte(key))
+	hash := hex.EncodeToString(sum[:])
+	entry := s.keys[hash]
+	switch {
+	case entry != nil && entry.Revoked:
+		return nil, false, errAPIKeyRevoked
+	case entry != nil && entry.UUID != "":
+		id = &apiKeyIdentity{
+			name: entry.Name,
+			claims: &jwt.CustomClaims{JwtPayLoad: jwt.JwtPayLoad{
+				UUID:     entry.UUID,
+				Nickname: entry.Nickname,
+				Scope:    entry.Scope,
+			}},
+		}
+	case exchange != nil:
+		if id, err = exchange.Resolve(ctx, hash, key); err != nil {
+			return nil, false, err
+		}
+		if entry != nil {
+			id.name = entry.Name
+		}
+	default:
+		return nil, false, errAPIKeyInvalid
+	}
+	if entry != nil && entry.limited && !s.limits.AllowLimit("apikey:"+entry.Name, entry.limit) {
+		return id, false, nil
+	}
+	return id, true, nil
+}
+
+// maxAPIKeyCache bounds the exchanged identities kept in memory
+const maxAPIKeyCache = 10000
+
+type cachedAPIKey struct {
+	id      *apiKeyIdentity
+	expires time.Time
+}
+
+// apiKeyExchange resolves keys stored upstream as service-account credentials
+type apiKeyExchange struct {
+	endpoint string
+	keys     *jwt.KeySet
+	ttl      time.Duration
+	client   *http.Client
+
+	mu     sync.Mutex
+	cache  map[string]cachedAPIKey // by key hash
+	flight syncx.SingleFlight
+}
+
+func newAPIKeyExchange(c APIKeyConfig, upstream *url.URL, transport http.RoundTripper, keys *jwt.KeySet) *apiKeyExchange {
+	endpoint := *upstream
+	endpoint.Path = singleJoiningSlash(upstream.Path, c.ExchangePath)
+	return &apiKeyExchange{
+		endpoint: endpoint.String(),
+		keys:     keys,
+		ttl:      c.CacheTTL,
+		client:   &http.Client{Transport: transport, Timeout: 5 * time.Second},
+		cache:    make(map[string]cachedAPIKey),
+		flight:   syncx.NewSingleFlight(),
+	}
+}
+
+// Resolve returns the cached identity for hash or exchanges key for a token.
+// Upstream revocations take effect once the cached identity expires
+func (x *apiKeyExchange) Resolve(ctx context.Context, hash, key string) (*apiKeyIdentity, error) {
+	now := time.Now()
+	x.mu.Lock()
+	cached, ok := x.cache[hash]
+	x.mu.Unlock()
+	if !ok || !now.Before(cached.expires) {
+		v, err := x.flight.Do(hash, func() (any, error) {
+			id, expires, err := x.exchange(ctx, key)
+			if err != nil {
+				return nil, err
+			}
+			entry := cachedAPIKey{id: id, expires: expires}
+			x.store(hash, entry)
+			return entry, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		cached = v.(cachedAPIKey)
+	}
+	// callers may rename the identity, the cached one stays shared
+	id := *cached.id
+	return &id, nil
+}
+
+func (x *apiKeyExchange) store(hash string, entry cachedAPIKey) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.cache) >= maxAPIKeyCache {
+		now := time.Now()
+		for k, e := range x.cache {
+			if !now.Before(e.expires) {
+				delete(x.cache, k)
+			}
+		}
+		if len(x.cache) >= maxAPIKeyCache {
+			x.cache = make(map[string]cachedAPIKey)
+		}
+	}
+	x.cache[hash] = entry
+}
+
+func (x *apiKeyExchange) exchange(ctx context.Context, key string) (*apiKeyIdentity, time.Time, error) {
+	clientID, secret, ok := strings.Cut(key, ".")
+	if !ok || clientID == "" || secret == "" {
+		return nil, time.Time{}, errAPIKeyInvalid
+	}
+	body, err := json.Marshal(map[string]string{"clientId": clientID, "clientSecret": secret})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	// the exchange outlives a cancelled caller, others may be waiting on it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), x.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, x.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := x.client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("api key exchange: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("api key exchange: upstream status %d", resp.StatusCode)
+	}
+	var out struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			AccessToken string `json:"accessToken"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, time.Time{}, fmt.Errorf("api key exchange: decode response: %w", err)
+	}
+	if out.Code != 0 || out.Data.AccessToken == "" {
+		return nil, time.Time{}, errAPIKeyInvalid
+	}
+	claims, err := x.keys.ParseToken(out.Data.AccessToken)
+	if err != nil || claims == nil {
+		if err == nil {
+			err = errAPIKeyInvalid
+		}
+		return nil, time.Time{}, fmt.Errorf("api key exchange: parse token: %w", err)
+	}
+	expires := time.Now().Add(x.ttl)
+	if claims.ExpiresAt != nil {
+		// stop using the token a little before it expires
+		if limit := claims.ExpiresAt.Add(-10 * time.Second); limit.Before(expires) {
+			expires = limit
+		}
+	}
+	return &apiKeyIdentity{name: clientID, claims: claims, token: out.Data.AccessToken}, expires, nil
+}
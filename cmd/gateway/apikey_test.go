@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"imy/pkg/jwt"
+)
+
+func apiKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAPIKeyTakesPrecedenceOverToken(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, fmt.Sprintf(`APIKeys:
+  Enabled: true
+  Keys:
+    - Name: reporter
+      Hash: %s
+      UUID: svc-reporter
+      Nickname: reporter
+    - Name: old
+      Hash: %s
+      Revoked: true
+    - Name: limited
+      Hash: %s
+      UUID: svc-limited
+      RPS: 1
+      Burst: 1
+`, apiKeyHash("key-live"), apiKeyHash("key-old"), apiKeyHash("key-limited")))
+	token := testToken(t, jwt.JwtPayLoad{UUID: "u-1", Nickname: "alice"})
+
+	// a valid key wins over the token sent alongside
+	w := serve(gw.handler, http.MethodGet, "/api/user/info", "", "X-Api-Key: key-live", bearer(token))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for a valid api key, got %d: %s", w.Code, w.Body.String())
+	}
+	got := upstream.last(t)
+	if uuid := got.Header.Get("uuid"); uuid != "svc-reporter" {
+		t.Errorf("Expected the api key identity, got uuid %q", uuid)
+	}
+	if nick := got.Header.Get("X-User-Nickname"); nick != "reporter" {
+		t.Errorf("Expected the api key nickname to be injected, got %q", nick)
+	}
+	if key := got.Header.Get("X-Api-Key"); key != "" {
+		t.Errorf("Expected the api key not to be forwarded, got %q", key)
+	}
+
+	// a bad key is not ignored in favour of a good token
+	before := upstream.count()
+	for _, key := range []string{"key-unknown", "key-old"} {
+		w = serve(gw.handler, http.MethodGet, "/api/user/info", "", "X-Api-Key: "+key, bearer(token))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for api key %s, got %d", key, w.Code)
+		}
+	}
+	if w.Body.String() != "Unauthorized: api key revoked\n" {
+		t.Errorf("Expected the revoked key to be reported, got %q", w.Body.String())
+	}
+
+	// per-key limit
+	if w = serve(gw.handler, http.MethodGet, "/api/user/info", "", "X-Api-Key: key-limited"); w.Code != http.StatusOK {
+		t.Fatalf("Expected the first limited request to pass, got %d", w.Code)
+	}
+	if w = serve(gw.handler, http.MethodGet, "/api/user/info", "", "X-Api-Key: key-limited"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the key limit to apply, got %d", w.Code)
+	}
+	if upstream.count() != before+1 {
+		t.Errorf("Expected only the allowed request to reach the upstream, got %d", upstream.count()-before)
+	}
+
+	// without a key the token is used
+	if w = serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token)); w.Code != http.StatusOK {
+		t.Fatalf("Expected 200 for the token alone, got %d", w.Code)
+	}
+	if uuid := upstream.last(t).Header.Get("uuid"); uuid != "u-1" {
+		t.Errorf("Expected the token identity, got uuid %q", uuid)
+	}
+
+	// keys are dropped on whitelisted routes too
+	serve(gw.handler, http.MethodPost, "/api/auth/login", "", "X-Api-Key: key-live")
+	if key := upstream.last(t).Header.Get("X-Api-Key"); key != "" {
+		t.Errorf("Expected the api key not to be forwarded on whitelisted routes, got %q", key)
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"imy/pkg/jwt"
+)
+
+// AuthzConfig adds per-route requirements on top of the WhiteList. The first
+// rule whose Path matches applies; Roles name groups of uuids that rules can
+// require, so e.g. the admin API needs no role support upstream
+type AuthzConfig struct {
+	Roles map[string][]string `json:",optional"` // role -> uuids
+	Rules []AuthzRule         `json:",optional"`
+}
+
+// AuthzRule lists what a request to Path needs. Auth is required to force
+// auth on a whitelisted path or none to skip it; rules with Claims or Roles
+// always require auth. Methods outside Methods get 405
+type AuthzRule struct {
+	Name    string
+	Path    string              // regex matched against the request path
+	Auth    string              `json:",optional"` // required | none, defaults to the WhiteList
+	Methods []string            `json:",optional"`
+	Claims  map[string][]string `json:",optional"` // claim -> accepted values, e.g. scope: [bot]
+	Roles   []string            `json:",optional"` // any one of them
+}
+
+const (
+	authRequired = "required"
+	authNone     = "none"
+)
+
+type authzRule struct {
+	AuthzRule
+	re      *regexp.Regexp
+	methods map[string]bool
+}
+
+type authzRules struct {
+	rules []*authzRule
+	roles map[string]map[string]bool // role -> uuid set
+}
+
+func newAuthzRules(c AuthzConfig) (*authzRules, error) {
+	a := &authzRules{roles: make(map[string]map[string]bool, len(c.Roles))}
+	for role, uuids := range c.Roles {
+		set := make(map[string]bool, len(uuids))
+		for _, u := range uuids {
+			set[u] = true
+		}
+		a.roles[role] = set
+	}
+	for _, rc := range c.Rules {
+		re, err := regexp.Compile(rc.Path)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: invalid path %q: %w", rc.Name, rc.Path, err)
+		}
+		rc.Auth = strings.ToLower(rc.Auth)
+		switch rc.Auth {
+		case "", authRequired:
+		case authNone:
+			if len(rc.Claims) > 0 || len(rc.Roles) > 0 {
+				return nil, fmt.Errorf("rule %s: Auth none cannot require claims or roles", rc.Name)
+			}
+		default:
+			return nil, fmt.Errorf("rule %s: unknown Auth %q", rc.Name, rc.Auth)
+		}
+		if len(rc.Claims) > 0 || len(rc.Roles) > 0 {
+			rc.Auth = authRequired
+		}
+		for _, role := range rc.Roles {
+			if _, ok := a.roles[role]; !ok {
+				return nil, fmt.Errorf("rule %s: unknown role %q", rc.Name, role)
+			}
+		}
+		for claim := range rc.Claims {
+			if !isIdentityClaim(claim) {
+				return nil, fmt.Errorf("rule %s: unsupported claim %q", rc.Name, claim)
+			}
+		}
+		rule := &authzRule{AuthzRule: rc, re: re, methods: make(map[string]bool)}
+		rule.Methods = make([]string, 0, len(rc.Methods))
+		for _, m := range rc.Methods {
+			m = strings.ToUpper(m)
+			rule.methods[m] = true
+			rule.Methods = append(rule.Methods, m)
+		}
+		a.rules = append(a.rules, rule)
+	}
+	return a, nil
+}
+
+// isIdentityClaim reports whether claimValue knows the claim
+func isIdentityClaim(claim string) bool {
+	switch strings.ToLower(claim) {
+	case "uuid", "nickname", "nick", "nick_name", "scope":
+		return true
+	}
+	return false
+}
+
+// Match returns the rule for path, nil when no rule matches
+func (a *authzRules) Match(path string) *authzRule {
+	for _, rule := range a.rules {
+		if rule.re.MatchString(path) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// AllowMethod writes a 405 and returns false when the rule restricts methods
+func (rule *authzRule) AllowMethod(w http.ResponseWriter, r *http.Request) bool {
+	if len(rule.methods) == 0 || rule.methods[r.Method] {
+		return true
+	}
+	w.Header().Set("Allow", strings.Join(rule.Methods, ", "))
+	writeRequestError(w, http.StatusMethodNotAllowed, "method not allowed", map[string]any{"rule": rule.Name})
+	return false
+}
+
+// Public reports whether requests skip auth, given the WhiteList verdict
+func (rule *authzRule) Public(whitelisted bool) bool {
+	switch rule.Auth {
+	case authRequired:
+		return false
+	case authNone:
+		return true
+	}
+	return whitelisted
+}
+
+// Authorize writes a 403 and returns false when claims miss a requirement
+func (a *authzRules) Authorize(w http.ResponseWriter, rule *authzRule, claims *jwt.CustomClaims) bool {
+	for claim, accepted := range rule.Claims {
+		value := claimValue(claim, claims, "")
+		if !containsString(accepted, value) {
+			writeRequestError(w, http.StatusForbidden, "forbidden: claim "+claim+" not accepted",
+				map[string]any{"rule": rule.Name})
+			return false
+		}
+	}
+	if len(rule.Roles) == 0 {
+		return true
+	}
+	for _, role := range rule.Roles {
+		if a.roles[role][claims.UUID] {
+			return true
+		}
+	}
+	writeRequestError(w, http.StatusForbidden, "forbidden: role required",
+		map[string]any{"rule": rule.Name, "roles": rule.Roles})
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"imy/pkg/jwt"
+)
+
+const authzYAML = `Authz:
+  Roles:
+    admin: [u-admin]
+  Rules:
+    - Name: admin
+      Path: ^/api/admin/.*
+      Roles: [admin]
+    - Name: ops
+      Path: ^/api/ops/.*
+      Claims:
+        nickname: [ops]
+    - Name: sessions
+      Path: ^/api/auth/sessions$
+      Auth: required
+      Methods: [POST]
+    - Name: public
+      Path: ^/api/public/.*
+      Auth: none
+`
+
+func TestAuthzRules(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, authzYAML)
+	admin := testToken(t, jwt.JwtPayLoad{UUID: "u-admin", Nickname: "ops"})
+	user := testToken(t, jwt.JwtPayLoad{UUID: "u-user", Nickname: "alice"})
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		header []string
+		code   int
+		msg    string
+	}{
+		{"role granted", http.MethodGet, "/api/admin/users", []string{bearer(admin)}, http.StatusOK, ""},
+		{"role missing", http.MethodGet, "/api/admin/users", []string{bearer(user)}, http.StatusForbidden, "forbidden: role required"},
+		{"role without token", http.MethodGet, "/api/admin/users", nil, http.StatusUnauthorized, "Unauthorized: token required"},
+		{"claim accepted", http.MethodGet, "/api/ops/stats", []string{bearer(admin)}, http.StatusOK, ""},
+		{"claim rejected", http.MethodGet, "/api/ops/stats", []string{bearer(user)}, http.StatusForbidden, "forbidden: claim nickname not accepted"},
+		{"auth required on whitelisted path", http.MethodPost, "/api/auth/sessions", nil, http.StatusUnauthorized, "Unauthorized: token required"},
+		{"auth required with token", http.MethodPost, "/api/auth/sessions", []string{bearer(user)}, http.StatusOK, ""},
+		{"method not allowed", http.MethodGet, "/api/auth/sessions", []string{bearer(user)}, http.StatusMethodNotAllowed, "method not allowed"},
+		{"auth none", http.MethodGet, "/api/public/info", nil, http.StatusOK, ""},
+		{"auth none ignores a bad token", http.MethodGet, "/api/public/info", []string{bearer("garbage")}, http.StatusOK, ""},
+		{"whitelist still applies", http.MethodPost, "/api/auth/login", nil, http.StatusOK, ""},
+		{"no rule needs a token", http.MethodGet, "/api/user/info", nil, http.StatusUnauthorized, "Unauthorized: token required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := upstream.count()
+			w := serve(gw.handler, tt.method, tt.path, "", tt.header...)
+			if w.Code != tt.code {
+				t.Fatalf("Expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.msg != "" && responseMsg(w) != tt.msg {
+				t.Errorf("Expected message %q, got %q", tt.msg, responseMsg(w))
+			}
+			reached := upstream.count() > before
+			if reached != (tt.code == http.StatusOK) {
+				t.Errorf("Expected the upstream to be reached only when allowed, reached=%v", reached)
+			}
+		})
+	}
+
+	w := serve(gw.handler, http.MethodGet, "/api/auth/sessions", "", bearer(user))
+	if allow := w.Header().Get("Allow"); allow != "POST" {
+		t.Errorf("Expected Allow: POST, got %q", allow)
+	}
+}
+
+func TestAuthzPublicRuleRequiresNoClaims(t *testing.T) {
+	_, err := newAuthzRules(AuthzConfig{Rules: []AuthzRule{{
+		Name:   "bad",
+		Path:   "^/api/x$",
+		Auth:   authNone,
+		Claims: map[string][]string{"scope": {"bot"}},
+	}}})
+	if err == nil {
+		t.Error("Expected a rule with Auth none and claims to be rejected")
+	}
+	_, err = newAuthzRules(AuthzConfig{Rules: []AuthzRule{{Name: "bad", Path: "^/api/x$", Roles: []string{"missing"}}}})
+	if err == nil {
+		t.Error("Expected a rule with an unknown role to be rejected")
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type HealthCheckConfig struct {
+	Enabled          bool          `json:",optional"`
+	Path             string        `json:",default=/api/version"`
+	Interval         time.Duration `json:",default=5s"`
+	Timeout          time.Duration `json:",default=2s"`
+	FailureThreshold int           `json:",default=3"` // consecutive failed probes before the upstream is marked down
+	SuccessThreshold int           `json:",default=2"` // consecutive good probes before it is marked up again
+}
+
+type CircuitBreakerConfig struct {
+	Enabled          bool          `json:",optional"`
+	FailureThreshold int           `json:",default=5"`   // consecutive upstream failures that open the circuit
+	OpenTimeout      time.Duration `json:",default=10s"` // fast-fail period before trial requests are let through
+	HalfOpenRequests int           `json:",default=1"`   // concurrent trial requests while half-open
+}
+
+type breakerState int
+
+const (
+	stateClosed breakerState = iota
+	stateOpen
+	stateHalfOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case stateOpen:
+		return "open"
+	case stateHalfOpen:
+		return "half-open"
+	}
+	return "closed"
+}
+
+// circuitBreaker fast-fails requests while the upstream keeps failing; after
+// OpenTimeout a few trial requests decide whether to close it again. The
+// health checker can also hold it open while the upstream is known to be down
+type circuitBreaker struct {
+	cfg CircuitBreakerConfig
+
+	mu        sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	inflight  int  // trial requests while half-open
+	unhealthy bool // set by the health checker, keeps the circuit open
+}
+
+func newCircuitBreaker(cfg CircuitBreakerConfig) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 5
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = 10 * time.Second
+	}
+	if cfg.HalfOpenRequests <= 0 {
+		cfg.HalfOpenRequests = 1
+	}
+	return &circuitBreaker{cfg: cfg}
+}
+
+// Allow reports whether a request may go upstream; every allowed request must
+// be followed by Done. When rejected it returns how long the caller should wait
+func (b *circuitBreaker) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case stateOpen:
+		wait := b.cfg.OpenTimeout - time.Since(b.openedAt)
+		if b.unhealthy || wait > 0 {
+			if wait <= 0 {
+				wait = b.cfg.OpenTimeout
+			}
+			return false, wait
+		}
+		b.state = stateHalfOpen
+		b.inflight = 0
+		fallthrough
+	case stateHalfOpen:
+		if b.inflight >= b.cfg.HalfOpenRequests {
+			return false, b.cfg.OpenTimeout
+		}
+		b.inflight++
+	}
+	return true, 0
+}
+
+// Done records the outcome of a request admitted by Allow
+func (b *circuitBreaker) Done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == stateHalfOpen {
+		if b.inflight > 0 {
+			b.inflight--
+		}
+		if success {
+			b.closeLocked()
+		} else {
+			b.openLocked("trial request failed")
+		}
+		return
+	}
+	if success {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == stateClosed && b.failures >= b.cfg.FailureThreshold {
+		b.openLocked(fmt.Sprintf("%d consecutive upstream failures", b.failures))
+	}
+}
+
+// Rejecting reports an open circuit without taking a trial slot, for
+// long-lived requests such as websocket sessions
+func (b *circuitBreaker) Rejecting() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != stateOpen {
+		return false, 0
+	}
+	wait := b.cfg.OpenTimeout - time.Since(b.openedAt)
+	if b.unhealthy && wait <= 0 {
+		wait = b.cfg.OpenTimeout
+	}
+	return wait > 0, wait
+}
+
+// SetHealthy is driven by the health checker
+func (b *circuitBreaker) SetHealthy(healthy bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if healthy == !b.unhealthy {
+		return
+	}
+	b.unhealthy = !healthy
+	if healthy {
+		b.closeLocked()
+	} else if b.state != stateOpen {
+		b.openLocked("health check failing")
+	}
+}
+
+func (b *circuitBreaker) openLocked(reason string) {
+	logx.Errorf("gateway breaker: open, %s", reason)
+	b.state = stateOpen
+	b.openedAt = time.Now()
+	b.inflight = 0
+}
+
+func (b *circuitBreaker) closeLocked() {
+	if b.state != stateClosed {
+		logx.Info("gateway breaker: closed, upstream recovered")
+	}
+	b.state = stateClosed
+	b.failures = 0
+	b.inflight = 0
+}
+
+type breakerStatus struct {
+	State      string `json:"state"`
+	Failures   int    `json:"failures"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+}
+
+func (b *circuitBreaker) Status() breakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := breakerStatus{State: b.state.String(), Failures: b.failures}
+	if b.state == stateOpen {
+		s.RetryAfter = retrySeconds(b.cfg.OpenTimeout - time.Since(b.openedAt))
+	}
+	return s
+}
+
+// upstreamFailed treats gateway-level upstream errors as failures; business
+// errors and 4xx answers mean the upstream is alive
+func upstreamFailed(code int) bool {
+	return code == http.StatusBadGateway || code == http.StatusServiceUnavailable || code == http.StatusGatewayTimeout
+}
+
+func retrySeconds(d time.Duration) int {
+	if d <= 0 {
+		return 1
+	}
+	return int(math.Ceil(d.Seconds()))
+}
+
+// writeUnavailable is the fast-fail answer while the circuit is open
+func writeUnavailable(w http.ResponseWriter, retryAfter time.Duration) {
+	secs := retrySeconds(retryAfter)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code": http.StatusServiceUnavailable,
+		"msg":  "upstream unavailable, retry later",
+		"data": map[string]int{"retryAfter": secs},
+	})
+}
+
+// healthChecker probes the upstream periodically and feeds the breaker
+type healthChecker struct {
+	cfg     HealthCheckConfig
+	url     string
+	client  *http.Client
+	breaker *circuitBreaker
+
+	mu        sync.Mutex
+	healthy   bool
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastError string
+}
+
+func newHealthChecker(cfg HealthCheckConfig, upstream *url.URL, transport http.RoundTripper, breaker *circuitBreaker) *healthChecker {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Second
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 3
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 2
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/api/version"
+	}
+	u := *upstream
+	u.Path = singleJoiningSlash(u.Path, cfg.Path)
+	return &healthChecker{
+		cfg:     cfg,
+		url:     u.String(),
+		client:  &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		breaker: breaker,
+		healthy: true,
+	}
+}
+
+// Start probes until ctx is done
+func (h *healthChecker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(h.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			h.probe(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (h *healthChecker) probe(ctx context.Context) {
+	err := h.check(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	h.mu.Lock()
+	h.lastCheck = time.Now()
+	changed := false
+	if err != nil {
+		h.lastError = err.Error()
+		h.successes = 0
+		h.failures++
+		if h.healthy && h.failures >= h.cfg.FailureThreshold {
+			h.healthy = false
+			changed = true
+			logx.Errorf("gateway health: upstream %s down: %v", h.url, err)
+		}
+	} else {
+		h.lastError = ""
+		h.failures = 0
+		h.successes++
+		if !h.healthy && h.successes >= h.cfg.SuccessThreshold {
+			h.healthy = true
+			changed = true
+			logx.Infof("gateway health: upstream %s up again", h.url)
+		}
+	}
+	healthy := h.healthy
+	h.mu.Unlock()
+
+	if changed && h.breaker != nil {
+		h.breaker.SetHealthy(healthy)
+	}
+}
+
+func (h *healthChecker) check(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+type healthStatus struct {
+	Healthy   bool      `json:"healthy"`
+	LastCheck time.Time `json:"lastCheck,omitempty"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+func (h *healthChecker) Status() healthStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return healthStatus{Healthy: h.healthy, LastCheck: h.lastCheck, LastError: h.lastError}
+}
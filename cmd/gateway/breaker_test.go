@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"imy/pkg/jwt"
+)
+
+const breakerYAML = `CircuitBreaker:
+  Enabled: true
+  FailureThreshold: 2
+  OpenTimeout: 1m
+`
+
+func TestCircuitBreakerFastFails(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, breakerYAML)
+	token := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+
+	// business errors and 4xx mean the upstream is alive
+	upstream.status.Store(http.StatusNotFound)
+	for i := 0; i < 3; i++ {
+		serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token))
+	}
+	upstream.status.Store(http.StatusBadGateway)
+	for i := 0; i < 2; i++ {
+		if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token)); w.Code != http.StatusBadGateway {
+			t.Fatalf("Expected the upstream failure %d to be relayed, got %d", i, w.Code)
+		}
+	}
+	if upstream.count() != 5 {
+		t.Fatalf("Expected 5 upstream requests before the circuit opens, got %d", upstream.count())
+	}
+
+	upstream.status.Store(http.StatusOK)
+	w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected 503 while the circuit is open, got %d", w.Code)
+	}
+	if retry := w.Header().Get("Retry-After"); retry == "" || retry == "0" {
+		t.Errorf("Expected a Retry-After header, got %q", retry)
+	}
+	if msg := responseMsg(w); msg != "upstream unavailable, retry later" {
+		t.Errorf("Expected the unavailable message, got %q", msg)
+	}
+	if upstream.count() != 5 {
+		t.Error("Expected the open circuit not to reach the upstream")
+	}
+	// unauthenticated requests are still rejected first
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", ""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 before the breaker, got %d", w.Code)
+	}
+
+	w = serve(gw.handler, http.MethodGet, "/healthz", "")
+	var health struct {
+		Status  string        `json:"status"`
+		Breaker breakerStatus `json:"breaker"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &health); err != nil {
+		t.Fatalf("Failed to decode health: %v", err)
+	}
+	if health.Status != "degraded" || health.Breaker.State != "open" {
+		t.Errorf("Expected the health check to report the open circuit, got %+v", health)
+	}
+}
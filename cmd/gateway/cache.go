@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type CacheConfig struct {
+	Enabled     bool                `json:",optional"`
+	MaxEntries  int                 `json:",default=10000"`
+	MaxBodySize int64               `json:",default=1048576"` // larger request or response bodies bypass the cache
+	TTL         time.Duration       `json:",default=5s"`      // used by routes without their own TTL
+	Routes      []CacheRoute        `json:",optional"`
+	Invalidate  []CacheInvalidation `json:",optional"`
+}
+
+// CacheRoute marks responses of matching requests as cacheable
+type CacheRoute struct {
+	Name    string
+	Path    string        // regex matched against the request path
+	Methods []string      `json:",optional"` // defaults to GET
+	TTL     time.Duration `json:",optional"`
+}
+
+// CacheInvalidation purges cached routes after a matching request succeeds
+type CacheInvalidation struct {
+	Path   string   // regex matched against the request path
+	Routes []string // names of the cache routes to purge
+}
+
+type cacheRoute struct {
+	CacheRoute
+	re      *regexp.Regexp
+	methods map[string]bool
+}
+
+type cacheInvalidation struct {
+	re     *regexp.Regexp
+	routes []string
+}
+
+type cacheEntry struct {
+	key     string
+	route   string
+	header  http.Header
+	body    []byte
+	expires time.Time
+}
+
+// responseCache is an in-memory LRU of upstream responses keyed by
+// method+path+query+body+uuid; only successful business responses are stored
+type responseCache struct {
+	cfg         CacheConfig
+	routes      []*cacheRoute
+	invalidates []*cacheInvalidation
+
+	mu      sync.Mutex
+	lru     *list.List // front is most recently used
+	items   map[string]*list.Element
+	byRoute map[string]map[string]*list.Element
+	gen     uint64 // bumped by every purge, fills that started earlier are dropped
+}
+
+func newResponseCache(cfg CacheConfig) (*responseCache, error) {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 10000
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 1 << 20
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Second
+	}
+	c := &responseCache{
+		cfg:     cfg,
+		lru:     list.New(),
+		items:   make(map[string]*list.Element),
+		byRoute: make(map[string]map[string]*list.Element),
+	}
+
+	names := make(map[string]bool)
+	for _, rt := range cfg.Routes {
+		if rt.Name == "" {
+			return nil, fmt.Errorf("cache route %q has no name", rt.Path)
+		}
+		re, err := regexp.Compile(rt.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cache route %s: %w", rt.Name, err)
+		}
+		methods := map[string]bool{}
+		for _, m := range rt.Methods {
+			methods[strings.ToUpper(m)] = true
+		}
+		if len(methods) == 0 {
+			methods[http.MethodGet] = true
+		}
+		if rt.TTL <= 0 {
+			rt.TTL = cfg.TTL
+		}
+		names[rt.Name] = true
+		c.routes = append(c.routes, &cacheRoute{CacheRoute: rt, re: re, methods: methods})
+	}
+	for _, inv := range cfg.Invalidate {
+		re, err := regexp.Compile(inv.Path)
+		if err != nil {
+			return nil, fmt.Errorf("cache invalidation %q: %w", inv.Path, err)
+		}
+		for _, name := range inv.Routes {
+			if !names[name] {
+				return nil, fmt.Errorf("cache invalidation %q purges unknown route %q", inv.Path, name)
+			}
+		}
+		c.invalidates = append(c.invalidates, &cacheInvalidation{re: re, routes: inv.Routes})
+	}
+	return c, nil
+}
+
+func (c *responseCache) match(r *http.Request) *cacheRoute {
+	for _, rt := range c.routes {
+		if rt.methods[r.Method] && rt.re.MatchString(r.URL.Path) {
+			return rt
+		}
+	}
+	return nil
+}
+
+// Serve answers from the cache when possible, otherwise forwards to next and
+// stores or purges entries depending on the route
+func (c *responseCache) Serve(w http.ResponseWriter, r *http.Request, uuid string, next http.Handler) {
+	rt := c.match(r)
+	if rt == nil {
+		next.ServeHTTP(w, r)
+		c.invalidate(r, w)
+		return
+	}
+
+	body, ok := c.readBody(r)
+	if !ok {
+		next.ServeHTTP(w, r)
+		return
+	}
+	key := cacheKey(r, body, uuid)
+	if e := c.get(key); e != nil {
+		for name, values := range e.header {
+			w.Header()[name] = values
+		}
+		w.Header().Set("X-Cache", "HIT")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(e.body)
+		return
+	}
+
+	gen := c.generation()
+	w.Header().Set("X-Cache", "MISS")
+	rec := &cacheRecorder{ResponseWriter: w, code: http.StatusOK, limit: c.cfg.MaxBodySize}
+	next.ServeHTTP(rec, r)
+	if rec.cacheable() {
+		c.put(gen, &cacheEntry{
+			key:     key,
+			route:   rt.Name,
+			header:  cacheableHeader(rec.Header()),
+			body:    rec.buf.Bytes(),
+			expires: time.Now().Add(rt.TTL),
+		})
+	}
+}
+
+// readBody buffers the request body for the cache key and restores it for the proxy
+func (c *responseCache) readBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+	orig := r.Body
+	body, err := io.ReadAll(io.LimitReader(orig, c.cfg.MaxBodySize+1))
+	if err != nil || int64(len(body)) > c.cfg.MaxBodySize {
+		// too large to key on (or unreadable), forward what was read plus the rest untouched
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), orig), orig}
+		return nil, false
+	}
+	orig.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, true
+}
+
+// invalidate purges routes affected by a mutating request that did not fail
+func (c *responseCache) invalidate(r *http.Request, w http.ResponseWriter) {
+	if sw, ok := w.(*statusWriter); ok && sw.code >= http.StatusBadRequest {
+		return
+	}
+	for _, inv := range c.invalidates {
+		if inv.re.MatchString(r.URL.Path) {
+			c.purge(inv.routes...)
+		}
+	}
+}
+
+func cacheKey(r *http.Request, body []byte, uuid string) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, uuid, r.Header.Get("Accept-Encoding")} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *responseCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+func (c *responseCache) get(key string) *cacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[key]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*cacheEntry)
+	if time.Now().After(e.expires) {
+		c.removeLocked(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return e
+}
+
+func (c *responseCache) put(gen uint64, e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		// a purge ran while the upstream was answering, the response may be stale
+		return
+	}
+	if el, ok := c.items[e.key]; ok {
+		c.removeLocked(el)
+	}
+	el := c.lru.PushFront(e)
+	c.items[e.key] = el
+	if c.byRoute[e.route] == nil {
+		c.byRoute[e.route] = make(map[string]*list.Element)
+	}
+	c.byRoute[e.route][e.key] = el
+	for c.lru.Len() > c.cfg.MaxEntries {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *responseCache) purge(routes ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	purged := 0
+	for _, name := range routes {
+		for _, el := range c.byRoute[name] {
+			c.removeLocked(el)
+			purged++
+		}
+	}
+	if purged > 0 {
+		logx.Infof("gateway cache: purged %d entries of %v", purged, routes)
+	}
+}
+
+func (c *responseCache) removeLocked(el *list.Element) {
+	e := el.Value.(*cacheEntry)
+	c.lru.Remove(el)
+	delete(c.items, e.key)
+	if m := c.byRoute[e.route]; m != nil {
+		delete(m, e.key)
+		if len(m) == 0 {
+			delete(c.byRoute, e.route)
+		}
+	}
+}
+
+// cacheRecorder passes the response through while keeping a copy of the body
+type cacheRecorder struct {
+	http.ResponseWriter
+	code     int
+	buf      bytes.Buffer
+	limit    int64
+	overflow bool
+}
+
+func (r *cacheRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *cacheRecorder) Write(p []byte) (int, error) {
+	if !r.overflow {
+		if int64(r.buf.Len()+len(p)) > r.limit {
+			r.overflow = true
+			r.buf.Reset()
+		} else {
+			r.buf.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *cacheRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// cacheable only keeps complete 200 JSON responses whose business code is ok
+func (r *cacheRecorder) cacheable() bool {
+	if r.code != http.StatusOK || r.overflow {
+		return false
+	}
+	h := r.Header()
+	if h.Get("Set-Cookie") != "" || strings.Contains(h.Get("Cache-Control"), "no-store") {
+		return false
+	}
+	if !strings.HasPrefix(h.Get("Content-Type"), "application/json") || h.Get("Content-Encoding") != "" {
+		return false
+	}
+	var biz struct {
+		Code *int `json:"code"`
+	}
+	if err := json.Unmarshal(r.buf.Bytes(), &biz); err != nil || biz.Code == nil {
+		return false
+	}
+	return *biz.Code == 0
+}
+
+// cacheableHeader copies the response headers worth replaying on a hit
+func cacheableHeader(h http.Header) http.Header {
+	out := make(http.Header)
+	for name, values := range h {
+		switch name {
+		case "Date", "Content-Length", "Connection", "Keep-Alive", "Transfer-Encoding", "X-Cache", "Set-Cookie":
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"imy/pkg/jwt"
+)
+
+const cacheYAML = `Cache:
+  Enabled: true
+  Routes:
+    - Name: profile
+      Path: ^/api/user/profile$
+  Invalidate:
+    - Path: ^/api/user/updateProfile$
+      Routes: [profile]
+`
+
+func TestResponseCache(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, cacheYAML)
+	alice := testToken(t, jwt.JwtPayLoad{UUID: "u-alice"})
+	bob := testToken(t, jwt.JwtPayLoad{UUID: "u-bob"})
+
+	get := func(token, want string) {
+		t.Helper()
+		w := serve(gw.handler, http.MethodGet, "/api/user/profile", "", bearer(token))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", w.Code)
+		}
+		if got := w.Header().Get("X-Cache"); got != want {
+			t.Errorf("Expected X-Cache %s, got %q", want, got)
+		}
+	}
+	get(alice, "MISS")
+	get(alice, "HIT")
+	// entries are kept per user
+	get(bob, "MISS")
+	if upstream.count() != 2 {
+		t.Errorf("Expected 2 upstream requests, got %d", upstream.count())
+	}
+
+	serve(gw.handler, http.MethodPost, "/api/user/updateProfile", "", bearer(alice))
+	get(alice, "MISS")
+	get(bob, "MISS")
+
+	// a failed mutation keeps the entries
+	upstream.status.Store(http.StatusInternalServerError)
+	serve(gw.handler, http.MethodPost, "/api/user/updateProfile", "", bearer(alice))
+	upstream.status.Store(http.StatusOK)
+	get(alice, "HIT")
+}
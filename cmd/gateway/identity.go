@@ -0,0 +1,97 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"imy/pkg/identity"
+)
+
+// IdentityHeadersConfig protects the headers the gateway injects. uuid, scope,
+// sid and the Inject targets are always removed from inbound requests, Strip and
+// StripPrefixes remove more; with a SigningSecret the injected headers are
+// signed (HMAC-SHA256) so the upstream can reject requests that bypassed the gateway
+type IdentityHeadersConfig struct {
+	Strip         []string `json:",optional"`
+	StripPrefixes []string `json:",optional"` // e.g. X-User-
+	SigningSecret string   `json:",optional"`
+}
+
+// identityHeaders strips spoofable headers and signs the injected ones
+type identityHeaders struct {
+	strip    map[string]bool // canonical header names
+	prefixes []string        // canonical prefixes
+	injected []string        // headers the gateway may inject, signed when present
+	secret   []byte
+	upstream *url.URL
+}
+
+func newIdentityHeaders(c IdentityHeadersConfig, inject map[string]string, upstream *url.URL) *identityHeaders {
+	h := &identityHeaders{
+		strip:    make(map[string]bool),
+		injected: []string{"uuid", "scope", "sid"},
+		upstream: upstream,
+	}
+	for claim, name := range inject {
+		// the token itself is not an identity claim and may be needed to authenticate
+		if name == "" || isTokenClaim(claim) {
+			continue
+		}
+		h.injected = append(h.injected, name)
+	}
+	for _, name := range append(append([]string{identity.SignatureHeader}, h.injected...), c.Strip...) {
+		h.strip[http.CanonicalHeaderKey(name)] = true
+	}
+	for _, prefix := range c.StripPrefixes {
+		h.prefixes = append(h.prefixes, http.CanonicalHeaderKey(prefix))
+	}
+	if c.SigningSecret != "" {
+		h.secret = []byte(c.SigningSecret)
+	}
+	return h
+}
+
+func isTokenClaim(claim string) bool {
+	switch strings.ToLower(claim) {
+	case "authorization", "auth", "token":
+		return true
+	}
+	return false
+}
+
+// Strip removes client supplied identity headers; call it before injecting
+func (h *identityHeaders) Strip(r *http.Request) {
+	for name := range r.Header {
+		canonical := http.CanonicalHeaderKey(name)
+		if h.strip[canonical] || h.hasStripPrefix(canonical) {
+			delete(r.Header, name)
+		}
+	}
+}
+
+func (h *identityHeaders) hasStripPrefix(name string) bool {
+	for _, prefix := range h.prefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign signs the injected headers over the method, path and query the upstream
+// will see; it does nothing without a secret or injected headers
+func (h *identityHeaders) Sign(r *http.Request) {
+	if h.secret == nil {
+		return
+	}
+	path := r.URL.Path
+	if h.upstream.Path != "" && h.upstream.Path != "/" {
+		path = singleJoiningSlash(h.upstream.Path, path)
+	}
+	query := joinQuery(h.upstream.RawQuery, r.URL.RawQuery)
+	if sig := identity.Sign(h.secret, r.Method, path, query, r.Header, h.injected, time.Now()); sig != "" {
+		r.Header.Set(identity.SignatureHeader, sig)
+	}
+}
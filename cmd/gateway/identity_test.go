@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"imy/pkg/identity"
+	"imy/pkg/jwt"
+)
+
+const identityYAML = `IdentityHeaders:
+  StripPrefixes:
+    - X-User-
+  Strip:
+    - X-Forwarded-User
+  SigningSecret: signing-secret
+`
+
+func TestIdentityHeadersStrippedAndSigned(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, identityYAML)
+	token := testToken(t, jwt.JwtPayLoad{UUID: "u-1", Nickname: "alice"})
+	spoofed := []string{
+		"uuid: u-admin",
+		"Scope: " + jwt.ScopeBot,
+		"sid: stolen",
+		"X-User-Nickname: admin",
+		"X-User-Role: admin",
+		"X-Forwarded-User: admin",
+		identity.SignatureHeader + ": t=1,n=00,h=uuid,s=forged",
+	}
+
+	w := serve(gw.handler, http.MethodGet, "/api/user/info?page=2", "", append(spoofed, bearer(token))...)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	got := upstream.last(t)
+	for name, want := range map[string]string{
+		"uuid":             "u-1",
+		"scope":            "",
+		"sid":              "",
+		"X-User-Nickname":  "alice",
+		"X-User-Role":      "",
+		"X-Forwarded-User": "",
+	} {
+		if values := got.Header.Values(name); len(values) > 1 || got.Header.Get(name) != want {
+			t.Errorf("Expected upstream header %s to be %q, got %q", name, want, values)
+		}
+	}
+	names, err := identity.Verify([]byte("signing-secret"), got, time.Minute, time.Now())
+	if err != nil {
+		t.Fatalf("Expected the gateway to re-sign the injected headers: %v", err)
+	}
+	if len(names) != 2 || names[0] != "uuid" || names[1] != "x-user-nickname" {
+		t.Errorf("Expected uuid and x-user-nickname to be signed, got %v", names)
+	}
+	if _, err := identity.Verify([]byte("other-secret"), got, time.Minute, time.Now()); err == nil {
+		t.Error("Expected the signature to depend on the secret")
+	}
+
+	// whitelisted routes get no identity at all
+	serve(gw.handler, http.MethodPost, "/api/auth/login", "", spoofed...)
+	got = upstream.last(t)
+	for _, name := range []string{"uuid", "scope", "sid", "X-User-Nickname", "X-User-Role", "X-Forwarded-User", identity.SignatureHeader} {
+		if value := got.Header.Get(name); value != "" {
+			t.Errorf("Expected %s to be stripped on whitelisted routes, got %q", name, value)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode/utf8"
+)
+
+// jsonSchema is the subset of JSON Schema the gateway checks request bodies
+// against: type, properties, required, additionalProperties (boolean), items,
+// enum, min/maxLength, pattern, minimum/maximum and min/maxItems. Other
+// keywords are ignored
+type jsonSchema struct {
+	Type                 schemaTypes            `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	Pattern              string                 `json:"pattern"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+
+	pattern *regexp.Regexp
+}
+
+// schemaTypes accepts both "type": "string" and "type": ["string", "null"]
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = schemaTypes{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return fmt.Errorf("type must be a string or a list of strings")
+	}
+	*t = many
+	return nil
+}
+
+var schemaTypeNames = map[string]bool{
+	"object": true, "array": true, "string": true, "integer": true,
+	"number": true, "boolean": true, "null": true,
+}
+
+// maxSchemaErrors caps the field errors reported for one body
+const maxSchemaErrors = 20
+
+// schemaError is one field that failed validation; Field is a path such as
+// mentionedUuids[2], empty for the body itself
+type schemaError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// loadJSONSchema reads and compiles a schema file
+func loadJSONSchema(path string) (*jsonSchema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var schema jsonSchema
+	if err := dec.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := schema.compile(""); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &schema, nil
+}
+
+func (s *jsonSchema) compile(at string) error {
+	for _, t := range s.Type {
+		if !schemaTypeNames[t] {
+			return fmt.Errorf("%s: unknown type %q", schemaPath(at), t)
+		}
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid pattern: %w", schemaPath(at), err)
+		}
+		s.pattern = re
+	}
+	for name, prop := range s.Properties {
+		if err := prop.compile(joinField(at, name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(at + "[]")
+	}
+	return nil
+}
+
+// Validate decodes body and checks it against the schema
+func (s *jsonSchema) Validate(body []byte) []schemaError {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return []schemaError{{Error: "body is not valid JSON"}}
+	}
+	if dec.More() {
+		return []schemaError{{Error: "body must hold a single JSON value"}}
+	}
+	var errs []schemaError
+	s.validate("", value, &errs)
+	return errs
+}
+
+func (s *jsonSchema) validate(at string, value any, errs *[]schemaError) {
+	fail := func(format string, args ...any) {
+		if len(*errs) < maxSchemaErrors {
+			*errs = append(*errs, schemaError{Field: at, Error: fmt.Sprintf(format, args...)})
+		}
+	}
+	if len(s.Type) > 0 && !s.matchesType(value) {
+		fail("must be %s", typeList(s.Type))
+		return
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		fail("must be one of %s", enumList(s.Enum))
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				*errs = appendLimited(*errs, schemaError{Field: joinField(at, name), Error: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if prop, ok := s.Properties[name]; ok {
+				prop.validate(joinField(at, name), v[name], errs)
+			} else if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				*errs = appendLimited(*errs, schemaError{Field: joinField(at, name), Error: "is not allowed"})
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(at+"["+strconv.Itoa(i)+"]", item, errs)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("must match %s", s.Pattern)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	}
+}
+
+func (s *jsonSchema) matchesType(value any) bool {
+	for _, t := range s.Type {
+		switch v := value.(type) {
+		case map[string]any:
+			if t == "object" {
+				return true
+			}
+		case []any:
+			if t == "array" {
+				return true
+			}
+		case string:
+			if t == "string" {
+				return true
+			}
+		case bool:
+			if t == "boolean" {
+				return true
+			}
+		case nil:
+			if t == "null" {
+				return true
+			}
+		case json.Number:
+			if t == "number" {
+				return true
+			}
+			if f, err := v.Float64(); t == "integer" && err == nil && f == math.Trunc(f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(enum []any, value any) bool {
+	for _, allowed := range enum {
+		a, aNum := allowed.(json.Number)
+		b, bNum := value.(json.Number)
+		if aNum && bNum {
+			fa, _ := a.Float64()
+			fb, _ := b.Float64()
+			if fa == fb {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(allowed, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendLimited(errs []schemaError, e schemaError) []schemaError {
+	if len(errs) >= maxSchemaErrors {
+		return errs
+	}
+	return append(errs, e)
+}
+
+func joinField(at, name string) string {
+	if at == "" {
+		return name
+	}
+	return at + "." + name
+}
+
+func schemaPath(at string) string {
+	if at == "" {
+		return "schema root"
+	}
+	return at
+}
+
+func typeList(types schemaTypes) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+	data, _ := json.Marshal(types)
+	return "one of " + string(data)
+}
+
+func enumList(enum []any) string {
+	data, _ := json.Marshal(enum)
+	return string(data)
+}
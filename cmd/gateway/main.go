@@ -1,20 +1,33 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
+	"os"
+	"os/signal"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/zeromicro/go-zero/core/conf"
 	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/trace"
 	"github.com/zeromicro/go-zero/rest"
-	"golang.org/x/time/rate"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	oteltrace "go.opentelemetry.io/otel/trace"
 	"imy/pkg/jwt"
 	"imy/pkg/utils"
 )
@@ -27,11 +40,38 @@ type GatewayConfig struct {
 	Inject     map[string]string `json:"Inject"` // claim -> header name, e.g. {"nickname":"X-User-Nickname"}
 	CORS       CORSConfig        `json:"CORS"`
 	RateLimit  RateLimitConfig   `json:"RateLimit"`
+	WebSocket  WebSocketConfig   `json:"WebSocket,optional"`
+	Cache      CacheConfig       `json:"Cache,optional"`
+	AccessLog  AccessLogConfig   `json:"AccessLog,optional"`
+	Validation ValidationConfig  `json:"Validation,optional"`
+	// strip-list for inbound headers and signing of the injected identity headers
+	IdentityHeaders IdentityHeadersConfig `json:"IdentityHeaders,optional"`
+	// X-Api-Key authentication for integrations that cannot log in
+	APIKeys APIKeyConfig `json:"APIKeys,optional"`
+	// logout at the gateway and rejection of revoked tokens
+	Revocation RevocationConfig `json:"Revocation,optional"`
+	// per-route auth requirements, checked after the token
+	Authz AuthzConfig `json:"Authz,optional"`
+	// BotPaths are the only paths tokens with the bot scope may reach (regexes);
+	// defaults to the bot API
+	BotPaths []string `json:"BotPaths,optional"`
+
+	HealthCheck    HealthCheckConfig    `json:"HealthCheck,optional"`
+	CircuitBreaker CircuitBreakerConfig `json:"CircuitBreaker,optional"`
+
+	// TLS termination uses the RestConf CertFile/KeyFile
+	TLS         ListenerTLSConfig `json:"TLS,optional"`
+	UpstreamTLS UpstreamTLSConfig `json:"UpstreamTLS,optional"`
 }
 
 type Auth struct {
 	AccessSecret string `json:"AccessSecret"`
 	AccessExpire int64  `json:"AccessExpire"`
+	// verification keys (kid selected, RS256/ES256/HS256); AccessSecret still
+	// verifies tokens without a kid
+	Keys jwt.KeySetConfig `json:"Keys,optional"`
+	// public RS256/ES256 keys are served here for other services
+	JWKSPath string `json:"JWKSPath,default=/.well-known/jwks.json"`
 }
 
 type CORSConfig struct {
@@ -44,70 +84,124 @@ type CORSConfig struct {
 	MaxAge            int      `json:"MaxAge"`
 }
 
-type RateLimitConfig struct {
-	Enabled bool    `json:"Enabled"`
-	RPS     float64 `json:"RPS"`
-	Burst   int     `json:"Burst"`
-	Key     string  `json:"Key"` // ip | uuid
-}
+// defaultBotPaths limits service-account tokens to the bot API
+var defaultBotPaths = []string{"^/api/bot/.*"}
 
-// simple keyed limiter store
-type ClientLimiter struct {
-	mu      sync.Mutex
-	clients map[string]*rate.Limiter
-	rps     rate.Limit
-	burst   int
-}
+var configFile = flag.String("f", "etc/gateway.yaml", "the config file")
+
+func main() {
+	flag.Parse()
 
-func NewClientLimiter(rps float64, burst int) *ClientLimiter {
-	if rps <= 0 {
-		rps = 10
+	var c GatewayConfig
+	conf.MustLoad(*configFile, &c)
+	// starts the trace agent when Telemetry is configured
+	c.MustSetUp()
+
+	// WhiteList, Authz, CORS, RateLimit and APIKeys are reloaded live on file changes or SIGHUP
+	reloader, err := newConfigReloader(*configFile, c)
+	if err != nil {
+		panic(err)
 	}
-	if burst <= 0 {
-		burst = int(rps * 2)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if err := reloader.Watch(watchCtx); err != nil {
+		logx.Errorf("gateway: config watch disabled: %v", err)
 	}
-	return &ClientLimiter{
-		clients: make(map[string]*rate.Limiter),
-		rps:     rate.Limit(rps),
-		burst:  burst,
+
+	serverTLS, err := listenerTLS(c.CertFile, c.KeyFile, c.TLS)
+	if err != nil {
+		panic(fmt.Errorf("invalid tls config: %w", err))
 	}
-}
+	gw, err := newGateway(c, reloader)
+	if err != nil {
+		panic(err)
+	}
+	defer gw.Close()
 
-func (c *ClientLimiter) get(key string) *rate.Limiter {
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	lim, ok := c.clients[key]
-	if !ok {
-		lim = rate.NewLimiter(c.rps, c.burst)
-		c.clients[key] = lim
+	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
+	srv := &http.Server{Addr: addr, Handler: gw.handler, TLSConfig: serverTLS}
+	errCh := make(chan error, 1)
+	go func() {
+		if serverTLS != nil {
+			// certificates are already loaded into TLSConfig
+			errCh <- srv.ListenAndServeTLS("", "")
+			return
+		}
+		errCh <- srv.ListenAndServe()
+	}()
+	logx.Infof("Starting gateway at %s (tls %t) -> upstream %s", addr, serverTLS != nil, c.Upstream)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errCh:
+		logx.Error(err)
+		return
+	case sig := <-stop:
+		logx.Infof("Received %s, draining gateway", sig)
 	}
-	return lim
-}
 
-func (c *ClientLimiter) Allow(key string) bool {
-	return c.get(key).Allow()
+	// hijacked websocket connections are not tracked by Shutdown, drain them alongside
+	ctx, cancel := context.WithTimeout(context.Background(), gw.ws.cfg.DrainTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		gw.ws.Drain(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		if err := srv.Shutdown(ctx); err != nil {
+			logx.Errorf("gateway shutdown: %v", err)
+		}
+	}()
+	wg.Wait()
+	logx.Info("Gateway stopped")
 }
 
-var configFile = flag.String("f", "etc/gateway.yaml", "the config file")
+// gateway is the request pipeline built from the config; main serves handler
+// and drains ws on shutdown
+type gateway struct {
+	handler http.Handler
+	ws      *wsProxy
+	closers []func()
+}
 
-func main() {
-	flag.Parse()
+// newGateway wires the proxy, the optional features and the request handler;
+// the live parts of the config are read from reloader on every request
+func newGateway(c GatewayConfig, reloader *configReloader) (_ *gateway, err error) {
+	g := &gateway{}
+	defer func() {
+		if err != nil {
+			g.Close()
+		}
+	}()
 
-	var c GatewayConfig
-	conf.MustLoad(*configFile, &c)
+	if len(c.BotPaths) == 0 {
+		c.BotPaths = defaultBotPaths
+	}
 
 	upstreamURL, err := url.Parse(c.Upstream)
 	if err != nil {
-		panic(fmt.Errorf("invalid upstream url: %w", err))
+		return nil, fmt.Errorf("invalid upstream url: %w", err)
 	}
-
-	// init limiter if enabled
-	var limiter *ClientLimiter
-	if c.RateLimit.Enabled {
-		limiter = NewClientLimiter(c.RateLimit.RPS, c.RateLimit.Burst)
+	jwtKeys, err := jwt.LoadKeySet(c.Auth.Keys, c.Auth.AccessSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth keys: %w", err)
 	}
+	clientTLS, err := upstreamTLS(c.UpstreamTLS)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream tls config: %w", err)
+	}
+	transport := upstreamTransport(clientTLS)
+
+	wsp := newWsProxy(c.WebSocket, upstreamURL)
+	wsp.dialer.TLSClientConfig = clientTLS
+	g.ws = wsp
 
 	proxy := httputil.NewSingleHostReverseProxy(upstreamURL)
+	proxy.Transport = transport
 	origDirector := proxy.Director
 	proxy.Director = func(r *http.Request) {
 		// keep path/query, just rewrite scheme/host and optional base path
@@ -119,17 +213,166 @@ func main() {
 		}
 		// present as upstream host
 		r.Host = upstreamURL.Host
+		// propagate the gateway span to upstream
+		otel.GetTextMapPropagator().Inject(r.Context(), propagation.HeaderCarrier(r.Header))
 	}
 
-	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+	// optional response cache for read-heavy routes
+	var cache *responseCache
+	if c.Cache.Enabled {
+		cache, err = newResponseCache(c.Cache)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cache config: %w", err)
+		}
+	}
+	idHeaders := newIdentityHeaders(c.IdentityHeaders, c.Inject, upstreamURL)
+	// api keys stored upstream are exchanged as service-account credentials
+	var keyExchange *apiKeyExchange
+	if c.APIKeys.Enabled && c.APIKeys.Upstream {
+		keyExchange = newAPIKeyExchange(c.APIKeys, upstreamURL, transport, jwtKeys)
+	}
+	var revocations *revocationList
+	if c.Revocation.Enabled {
+		revocations = newRevocationList(c.Revocation)
+		g.closers = append(g.closers, revocations.Close)
+	}
+	// in-band websocket token renewals get the checks of the upgrade
+	wsp.renew = func(user, token string) (time.Time, bool) {
+		claims, err := jwtKeys.ParseToken(token)
+		if err != nil || "uuid:"+claims.UUID != user {
+			return time.Time{}, false
+		}
+		if revocations != nil && revocations.Revoked(claims, token) {
+			return time.Time{}, false
+		}
+		var expires time.Time
+		if claims.ExpiresAt != nil {
+			expires = claims.ExpiresAt.Time
+		}
+		return expires, true
+	}
+	// body limits, content types and schemas checked before forwarding
+	var validator *requestValidator
+	if c.Validation.Enabled {
+		validator, err = newRequestValidator(c.Validation)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validation config: %w", err)
+		}
+	}
+	// circuit breaker and active health checks of the upstream
+	var breaker *circuitBreaker
+	if c.CircuitBreaker.Enabled {
+		breaker = newCircuitBreaker(c.CircuitBreaker)
+	}
+	var health *healthChecker
+	if c.HealthCheck.Enabled {
+		health = newHealthChecker(c.HealthCheck, upstreamURL, transport, breaker)
+		healthCtx, stopHealth := context.WithCancel(context.Background())
+		g.closers = append(g.closers, stopHealth)
+		health.Start(healthCtx)
+	}
+
+	forward := func(w http.ResponseWriter, r *http.Request, uuid string) {
+		if breaker != nil {
+			ok, retryAfter := breaker.Allow()
+			if !ok {
+				writeUnavailable(w, retryAfter)
+				return
+			}
+			defer func() {
+				code := http.StatusOK
+				if sw, ok := w.(*statusWriter); ok {
+					code = sw.code
+				}
+				breaker.Done(!upstreamFailed(code))
+			}()
+		}
+		if cache != nil {
+			cache.Serve(w, r, uuid, proxy)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}
+	// websocket sessions are long-lived, they only respect an open circuit
+	serveWs := func(w http.ResponseWriter, r *http.Request, user string, expires time.Time) {
+		if breaker != nil {
+			if open, retryAfter := breaker.Rejecting(); open {
+				writeUnavailable(w, retryAfter)
+				return
+			}
+		}
+		wsp.ServeWs(w, r, user, expires)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		status := map[string]any{"status": "ok"}
+		if health != nil {
+			hs := health.Status()
+			status["upstream"] = hs
+			if !hs.Healthy {
+				status["status"] = "degraded"
+			}
+		}
+		if breaker != nil {
+			bs := breaker.Status()
+			status["breaker"] = bs
+			if bs.State != stateClosed.String() {
+				status["status"] = "degraded"
+			}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
+		_ = json.NewEncoder(w).Encode(status)
 	})
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	// no auth: these are public keys, cacheable by the services verifying tokens
+	mux.HandleFunc(c.Auth.JWKSPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(jwtKeys.JWKS())
+	})
+
+	tracer := otel.Tracer(trace.TraceName)
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ensure request id exists for tracing
+		if r.Header.Get("X-Request-Id") == "" {
+			r.Header.Set("X-Request-Id", uuid.New().String())
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, "gateway "+r.URL.Path,
+			oteltrace.WithSpanKind(oteltrace.SpanKindServer),
+			oteltrace.WithAttributes(
+				attribute.String("http.method", r.Method),
+				attribute.String("http.target", r.URL.Path),
+				attribute.String("request.id", r.Header.Get("X-Request-Id")),
+			))
+		defer span.End()
+		r = r.WithContext(ctx)
+		// identity headers only ever come from the gateway, whitelisted routes included
+		idHeaders.Strip(r)
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		w = sw
+		defer func() {
+			span.SetAttributes(attribute.Int("http.status_code", sw.code))
+			if sw.code >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(sw.code))
+			}
+		}()
+
+		live := reloader.Load()
+		limiter := live.limiter
+		// the key never reaches the upstream, whitelisted routes included
+		var apiKey string
+		if live.apiKeys != nil {
+			apiKey = live.apiKeys.Take(r)
+		}
+
 		// CORS handling (includes preflight)
-		if c.CORS.Enabled {
-			writeCORSHeaders(w, r, &c.CORS)
+		if live.CORS.Enabled {
+			writeCORSHeaders(w, r, &live.CORS)
 			if r.Method == http.MethodOptions {
 				w.WriteHeader(http.StatusNoContent)
 				return
@@ -142,55 +385,139 @@ func main() {
 			if ip == "" {
 				ip = "unknown"
 			}
-			if !limiter.Allow("ip:" + ip) {
+			if !limiter.AllowIP(ip) {
 				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
 			}
 		}
 
+		// reject malformed bodies before they reach the upstream
+		if validator != nil && !isWebSocketUpgrade(r) && !validator.Check(w, r) {
+			return
+		}
+
 		path := r.URL.Path
 
+		// logout is answered by the gateway, it owns the revocation list
+		if revocations != nil && path == c.Revocation.LogoutPath {
+			revocations.ServeLogout(w, r, jwtKeys)
+			return
+		}
+
+		// per-route rules restrict methods here and claims once the caller is known
+		rule := live.authz.Match(path)
+		if rule != nil && !rule.AllowMethod(w, r) {
+			return
+		}
+
 		// whitelist: pass through without auth
-		isWhitelisted := utils.InListByRegex(c.WhiteList, path)
+		isWhitelisted := utils.InListByRegex(live.WhiteList, path)
+		if rule != nil {
+			isWhitelisted = rule.Public(isWhitelisted)
+		}
 		logx.Infof("Path %s whitelist check: %t", path, isWhitelisted)
+		isWs := isWebSocketUpgrade(r)
 		if isWhitelisted {
 			logx.Infof("Path %s matched whitelist, bypassing auth", path)
-			proxy.ServeHTTP(w, r)
+			if limiter != nil && !limiter.AllowRoute(path, "ip:"+getClientIP(r)) {
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			if isWs {
+				serveWs(w, r, "ip:"+getClientIP(r), time.Time{})
+				return
+			}
+			forward(w, r, "")
 			return
 		}
 
-		// extract token
-		logx.Infof("Path %s requires auth, extracting token", path)
-		token := extractToken(r)
-		logx.Infof("Extracted token: %s", token[:20]+"...")
-		if token == "" {
-			logx.Errorf("No token found for path %s", path)
-			http.Error(w, "Unauthorized: token required", http.StatusUnauthorized)
-			return
+		var (
+			claims *jwt.CustomClaims
+			token  string
+		)
+		if apiKey != "" {
+			// api keys map to a service identity injected like token claims
+			id, allowed, err := live.apiKeys.Resolve(r.Context(), apiKey, keyExchange)
+			switch {
+			case errors.Is(err, errAPIKeyInvalid), errors.Is(err, errAPIKeyRevoked):
+				logx.Errorf("gateway: api key rejected for path %s: %v", path, err)
+				http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+				return
+			case err != nil:
+				logx.Errorf("gateway: api key lookup failed: %v", err)
+				http.Error(w, "Bad Gateway: api key lookup failed", http.StatusBadGateway)
+				return
+			case !allowed:
+				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+				return
+			}
+			if isWs && id.token == "" {
+				// upstream websocket handlers need a token, configured keys have none
+				http.Error(w, "Forbidden: websocket not allowed for this api key", http.StatusForbidden)
+				return
+			}
+			claims, token = id.claims, id.token
+			span.SetAttributes(attribute.String("auth.api_key", id.name))
+			logx.Infof("API key %s authenticated, UUID: %s", id.name, claims.UUID)
+		} else {
+			// extract token
+			logx.Infof("Path %s requires auth, extracting token", path)
+			token = extractToken(r)
+			if token == "" && isWs {
+				// browsers cannot set headers on websocket handshakes
+				token = takeQueryToken(r)
+			}
+			if token == "" {
+				logx.Errorf("No token found for path %s", path)
+				http.Error(w, "Unauthorized: token required", http.StatusUnauthorized)
+				return
+			}
+			logx.Infof("Extracted token: %s", truncate(token, 20))
+
+			logx.Infof("Parsing token with secret: %s", c.Auth.AccessSecret)
+			var err error
+			claims, err = jwtKeys.ParseToken(token)
+			if err != nil || claims == nil {
+				logx.Errorf("gateway: parse token failed: %v", err)
+				http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
+				return
+			}
+			if revocations != nil && revocations.Revoked(claims, token) {
+				logx.Errorf("gateway: revoked token of %s used for path %s", claims.UUID, path)
+				http.Error(w, "Unauthorized: token revoked", http.StatusUnauthorized)
+				return
+			}
+			logx.Infof("Token parsed successfully, UUID: %s", claims.UUID)
 		}
 
-		logx.Infof("Parsing token with secret: %s", c.Auth.AccessSecret)
-		claims, err := jwt.ParseToken(token, c.Auth.AccessSecret)
-		if err != nil || claims == nil {
-			logx.Errorf("gateway: parse token failed: %v", err)
-			http.Error(w, "Unauthorized: invalid token", http.StatusUnauthorized)
+		// scoped tokens (bots) only reach the paths of their scope
+		if claims.Scope == jwt.ScopeBot && !utils.InListByRegex(c.BotPaths, path) {
+			logx.Errorf("gateway: bot token %s denied for path %s", claims.UUID, path)
+			http.Error(w, "Forbidden: path not allowed for bot tokens", http.StatusForbidden)
+			return
+		}
+		if rule != nil && !live.authz.Authorize(w, rule, claims) {
+			logx.Errorf("gateway: %s denied for path %s by rule %s", claims.UUID, path, rule.Name)
 			return
 		}
-		logx.Infof("Token parsed successfully, UUID: %s", claims.UUID)
 
 		// Optional: rate limiting by UUID after auth if configured
-		if limiter != nil && strings.ToLower(c.RateLimit.Key) == "uuid" && claims.UUID != "" {
-			if !limiter.Allow("uuid:" + claims.UUID) {
+		if limiter != nil {
+			if !limiter.AllowUser(claims.UUID) || !limiter.AllowRoute(path, "uuid:"+claims.UUID) {
 				http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
 				return
 			}
 		}
 
-		// inject required and configured headers
-		// Always override client-provided identity headers
-		r.Header.Del("uuid")
-		
+		// inject required and configured headers; client-provided ones were stripped above
 		r.Header.Set("uuid", claims.UUID)
+		if claims.Scope != "" {
+			r.Header.Set("scope", claims.Scope)
+		}
+		if claims.Session != "" {
+			// upstream rejects requests of revoked login sessions
+			r.Header.Set("sid", claims.Session)
+		}
 		logx.Infof("Set UUID header: %s", claims.UUID)
 
 		// Optional: mapping-based injections for extensibility
@@ -203,20 +530,71 @@ func main() {
 				r.Header.Set(headerName, val)
 			}
 		}
+		idHeaders.Sign(r)
+
+		span.SetAttributes(attribute.String("user.uuid", claims.UUID))
+		setAccessUUID(r.Context(), claims.UUID)
+		if isWs {
+			// upstream websocket handlers authenticate with the bearer header
+			r.Header.Set("Authorization", "Bearer "+token)
+			var expires time.Time
+			if claims.ExpiresAt != nil {
+				expires = claims.ExpiresAt.Time
+			}
+			serveWs(w, r, "uuid:"+claims.UUID, expires)
+			return
+		}
+		forward(w, r, claims.UUID)
+	})
 
-		// Ensure request id exists for tracing
-		if r.Header.Get("X-Request-Id") == "" {
-			r.Header.Set("X-Request-Id", uuid.New().String())
+	// structured access log, with request bodies of audited routes
+	if c.AccessLog.Enabled {
+		accessLog, err := newAccessLogger(c.AccessLog)
+		if err != nil {
+			return nil, fmt.Errorf("invalid access log config: %w", err)
 		}
+		g.closers = append(g.closers, func() {
+			if err := accessLog.Close(); err != nil {
+				logx.Errorf("gateway: close access log: %v", err)
+			}
+		})
+		handler = accessLog.Middleware(handler)
+	}
+	mux.Handle("/", handler)
+	g.handler = mux
+	return g, nil
+}
 
-		proxy.ServeHTTP(w, r)
-	})
+// Close stops the background work started by newGateway, last started first
+func (g *gateway) Close() {
+	for i := len(g.closers) - 1; i >= 0; i-- {
+		g.closers[i]()
+	}
+	g.closers = nil
+}
 
-	addr := fmt.Sprintf("%s:%d", c.Host, c.Port)
-	logx.Infof("Starting gateway at %s -> upstream %s", addr, c.Upstream)
-	if err := http.ListenAndServe(addr, nil); err != nil {
-		logx.Error(err)
+// statusWriter records the response status for the gateway span
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack lets websocket upgrades take over the connection
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
 	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
 }
 
 func extractToken(r *http.Request) string {
@@ -230,19 +608,52 @@ func extractToken(r *http.Request) string {
 	return r.Header.Get("token")
 }
 
+// takeQueryToken reads the token query parameter and removes it so it is not forwarded upstream
+func takeQueryToken(r *http.Request) string {
+	q := r.URL.Query()
+	token := q.Get("token")
+	if token != "" {
+		q.Del("token")
+		r.URL.RawQuery = q.Encode()
+	}
+	return token
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
 func claimValue(key string, claims *jwt.CustomClaims, token string) string {
 	switch strings.ToLower(key) {
 	case "uuid":
 		return claims.UUID
 	case "nickname", "nick", "nick_name":
 		return claims.Nickname
+	case "scope":
+		return claims.Scope
+	case "sid", "session":
+		return claims.Session
 	case "authorization", "auth", "token":
+		if token == "" {
+			return ""
+		}
 		return "Bearer " + token
 	default:
 		return ""
 	}
 }
 
+// joinQuery combines the upstream and request queries the way the reverse proxy director does
+func joinQuery(upstream, request string) string {
+	if upstream == "" || request == "" {
+		return upstream + request
+	}
+	return upstream + "&" + request
+}
+
 func singleJoiningSlash(a, b string) string {
 	aHas := strings.HasSuffix(a, "/")
 	bHas := strings.HasPrefix(b, "/")
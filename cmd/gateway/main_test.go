@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/conf"
+	"imy/pkg/jwt"
+)
+
+const testSecret = "gateway-test-secret"
+
+// testConfigYAML holds the keys every gateway config needs; tests append the
+// sections they exercise
+const testConfigYAML = `Name: gateway-test
+Host: 127.0.0.1
+Port: 0
+Upstream: %s
+Auth:
+  AccessSecret: ` + testSecret + `
+  AccessExpire: 3600
+  JWKSPath: /.well-known/jwks.json
+WhiteList:
+  - ^/api/auth/.*
+Inject:
+  nickname: X-User-Nickname
+CORS:
+  Enabled: false
+  AllowOrigins: []
+  AllowMethods: []
+  AllowHeaders: []
+  ExposeHeaders: []
+  AllowCredentials: false
+  MaxAge: 0
+RateLimit:
+  Enabled: false
+  RPS: 0
+  Burst: 0
+  Key: ip
+`
+
+// testUpstream records the requests that reach it and answers with a
+// successful business response, or echoes websocket messages
+type testUpstream struct {
+	*httptest.Server
+	status atomic.Int32
+
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newTestUpstream(t *testing.T) *testUpstream {
+	u := &testUpstream{}
+	u.status.Store(http.StatusOK)
+	u.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		recorded := r.Clone(context.Background())
+		recorded.Body = io.NopCloser(bytes.NewReader(body))
+		u.mu.Lock()
+		u.requests = append(u.requests, recorded)
+		u.mu.Unlock()
+
+		if websocket.IsWebSocketUpgrade(r) {
+			conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			for {
+				kind, data, err := conn.ReadMessage()
+				if err != nil || conn.WriteMessage(kind, data) != nil {
+					return
+				}
+			}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(int(u.status.Load()))
+		fmt.Fprintf(w, `{"code":0,"msg":"ok","data":{"path":%q}}`, r.URL.Path)
+	}))
+	t.Cleanup(u.Close)
+	return u
+}
+
+// count returns how many requests reached the upstream
+func (u *testUpstream) count() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.requests)
+}
+
+// last returns the latest request that reached the upstream
+func (u *testUpstream) last(t *testing.T) *http.Request {
+	t.Helper()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if len(u.requests) == 0 {
+		t.Fatal("Expected a request to reach the upstream")
+	}
+	return u.requests[len(u.requests)-1]
+}
+
+// writeTestConfig writes the base config plus extra to a yaml file
+func writeTestConfig(t *testing.T, path, upstream, extra string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(testConfigYAML, upstream)+extra), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+// loadTestConfig loads the base config plus extra the way main does, so the
+// defaults of the optional sections apply
+func loadTestConfig(t *testing.T, upstream, extra string) (GatewayConfig, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "gateway.yaml")
+	writeTestConfig(t, path, upstream, extra)
+	var c GatewayConfig
+	if err := conf.Load(path, &c); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	return c, path
+}
+
+// startTestGateway builds the gateway handler from c
+func startTestGateway(t *testing.T, c GatewayConfig, path string) (*gateway, *configReloader) {
+	t.Helper()
+	reloader, err := newConfigReloader(path, c)
+	if err != nil {
+		t.Fatalf("Failed to create reloader: %v", err)
+	}
+	gw, err := newGateway(c, reloader)
+	if err != nil {
+		t.Fatalf("Failed to create gateway: %v", err)
+	}
+	t.Cleanup(gw.Close)
+	return gw, reloader
+}
+
+func newTestGateway(t *testing.T, upstream *testUpstream, extra string) *gateway {
+	t.Helper()
+	c, path := loadTestConfig(t, upstream.URL, extra)
+	gw, _ := startTestGateway(t, c, path)
+	return gw
+}
+
+func testToken(t *testing.T, payload jwt.JwtPayLoad) string {
+	t.Helper()
+	token, err := jwt.GenAccessToken(payload, testSecret, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+	return token
+}
+
+// serve sends one request through the gateway handler; header values are
+// "Name: value" pairs
+func serve(h http.Handler, method, target, body string, header ...string) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	r := httptest.NewRequest(method, target, reader)
+	for _, kv := range header {
+		name, value, _ := strings.Cut(kv, ":")
+		r.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func bearer(token string) string {
+	return "Authorization: Bearer " + token
+}
+
+// responseMsg decodes the msg of a code/msg/data error response
+func responseMsg(w *httptest.ResponseRecorder) string {
+	var resp struct {
+		Msg string `json:"msg"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		return strings.TrimSpace(w.Body.String())
+	}
+	return resp.Msg
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/zeromicro/go-zero/core/logx"
+	"golang.org/x/time/rate"
+)
+
+type RateLimitConfig struct {
+	Enabled bool    `json:"Enabled"`
+	RPS     float64 `json:"RPS"`
+	Burst   int     `json:"Burst"`
+	Key     string  `json:"Key"` // ip | uuid
+
+	Backend   string           `json:"Backend,default=local"` // local | redis
+	Redis     RateLimitRedis   `json:"Redis,optional"`
+	IdleEvict time.Duration    `json:"IdleEvict,default=10m"` // local limiters unused for this long are dropped
+	Routes    []RouteRateLimit `json:"Routes,optional"`
+	Users     []UserRateLimit  `json:"Users,optional"`
+}
+
+type RateLimitRedis struct {
+	Addr     string
+	Password string `json:",optional"`
+	DB       int    `json:",optional"`
+	Prefix   string `json:",default=gateway:ratelimit:"`
+}
+
+// RouteRateLimit applies an extra limit to matching paths, per uuid (or ip before auth)
+type RouteRateLimit struct {
+	Name  string
+	Path  string // regex matched against the request path
+	RPS   float64
+	Burst int `json:",optional"`
+}
+
+// UserRateLimit overrides the per-uuid limit for one user
+type UserRateLimit struct {
+	UUID  string
+	RPS   float64
+	Burst int `json:",optional"`
+}
+
+type rateLimit struct {
+	rps   float64
+	burst int
+}
+
+func newRateLimit(rps float64, burst int) rateLimit {
+	if rps <= 0 {
+		rps = 10
+	}
+	if burst <= 0 {
+		burst = int(rps * 2)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	return rateLimit{rps: rps, burst: burst}
+}
+
+// limiterBackend stores the token buckets
+type limiterBackend interface {
+	AllowLimit(key string, limit rateLimit) bool
+}
+
+// simple keyed limiter store
+type ClientLimiter struct {
+	mu      sync.Mutex
+	clients map[string]*limiterEntry
+	rps     rate.Limit
+	burst   int
+}
+
+type limiterEntry struct {
+	lim      *rate.Limiter
+	lastSeen time.Time
+}
+
+func NewClientLimiter(rps float64, burst int) *ClientLimiter {
+	l := newRateLimit(rps, burst)
+	return &ClientLimiter{
+		clients: make(map[string]*limiterEntry),
+		rps:     rate.Limit(l.rps),
+		burst:   l.burst,
+	}
+}
+
+func (c *ClientLimiter) get(key string, limit rateLimit) *rate.Limiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.clients[key]
+	if !ok {
+		e = &limiterEntry{lim: rate.NewLimiter(rate.Limit(limit.rps), limit.burst)}
+		c.clients[key] = e
+	}
+	e.lastSeen = time.Now()
+	return e.lim
+}
+
+func (c *ClientLimiter) Allow(key string) bool {
+	return c.AllowLimit(key, rateLimit{rps: float64(c.rps), burst: c.burst})
+}
+
+func (c *ClientLimiter) AllowLimit(key string, limit rateLimit) bool {
+	return c.get(key, limit).Allow()
+}
+
+// EvictIdle drops limiters not used since the cutoff; an idle bucket is full
+// again anyway, so recreating it later does not change the outcome
+func (c *ClientLimiter) EvictIdle(idle time.Duration) int {
+	cutoff := time.Now().Add(-idle)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	evicted := 0
+	for key, e := range c.clients {
+		if e.lastSeen.Before(cutoff) {
+			delete(c.clients, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// StartEviction runs EvictIdle periodically until stop is closed
+func (c *ClientLimiter) StartEviction(idle time.Duration, stop <-chan struct{}) {
+	if idle <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(idle / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n := c.EvictIdle(idle); n > 0 {
+					logx.Infof("gateway ratelimit: evicted %d idle limiters", n)
+				}
+			}
+		}
+	}()
+}
+
+// tokenBucketScript refills the bucket from the redis clock so every replica
+// sees the same time; returns 1 when a token was taken
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate / 1000)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return allowed
+`)
+
+// redisLimiter shares token buckets across gateway replicas; when redis is
+// unreachable it falls back to the local limiter instead of rejecting traffic
+type redisLimiter struct {
+	client   *redis.Client
+	prefix   string
+	fallback *ClientLimiter
+}
+
+func (l *redisLimiter) AllowLimit(key string, limit rateLimit) bool {
+	allowed, err := tokenBucketScript.Run(l.client, []string{l.prefix + key}, limit.rps, limit.burst).Int()
+	if err != nil {
+		logx.Errorf("gateway ratelimit: redis unavailable, using local limiter: %v", err)
+		return l.fallback.AllowLimit(key, limit)
+	}
+	return allowed == 1
+}
+
+type routeLimiter struct {
+	name  string
+	re    *regexp.Regexp
+	limit rateLimit
+}
+
+// gatewayLimiter applies the global, per-route and per-user limits
+type gatewayLimiter struct {
+	backend limiterBackend
+	local   *ClientLimiter
+	global  rateLimit
+	byUUID  bool
+	routes  []*routeLimiter
+	users   map[string]rateLimit
+
+	stop     chan struct{} // stops idle eviction of the local limiter
+	stopOnce sync.Once
+}
+
+func newGatewayLimiter(c RateLimitConfig, byUUID bool) (*gatewayLimiter, error) {
+	local := NewClientLimiter(c.RPS, c.Burst)
+	g := &gatewayLimiter{
+		backend: local,
+		local:   local,
+		global:  newRateLimit(c.RPS, c.Burst),
+		byUUID:  byUUID,
+		users:   make(map[string]rateLimit),
+		stop:    make(chan struct{}),
+	}
+	if c.Backend == "redis" {
+		client := redis.NewClient(&redis.Options{
+			Addr:     c.Redis.Addr,
+			Password: c.Redis.Password,
+			DB:       c.Redis.DB,
+		})
+		if err := client.Ping().Err(); err != nil {
+			logx.Errorf("gateway ratelimit: redis %s not reachable yet: %v", c.Redis.Addr, err)
+		}
+		g.backend = &redisLimiter{client: client, prefix: c.Redis.Prefix, fallback: local}
+	}
+	for _, rt := range c.Routes {
+		re, err := regexp.Compile(rt.Path)
+		if err != nil {
+			return nil, err
+		}
+		name := rt.Name
+		if name == "" {
+			name = rt.Path
+		}
+		g.routes = append(g.routes, &routeLimiter{name: name, re: re, limit: newRateLimit(rt.RPS, rt.Burst)})
+	}
+	for _, u := range c.Users {
+		g.users[u.UUID] = newRateLimit(u.RPS, u.Burst)
+	}
+	return g, nil
+}
+
+// AllowIP is the pre-auth limit for every request
+func (g *gatewayLimiter) AllowIP(ip string) bool {
+	return g.backend.AllowLimit("ip:"+ip, g.global)
+}
+
+// AllowUser is the post-auth limit when limiting by uuid, with per-user overrides
+func (g *gatewayLimiter) AllowUser(uuid string) bool {
+	if !g.byUUID || uuid == "" {
+		return true
+	}
+	limit, ok := g.users[uuid]
+	if !ok {
+		limit = g.global
+	}
+	return g.backend.AllowLimit("uuid:"+uuid, limit)
+}
+
+// AllowRoute applies the first matching route limit to the caller identity
+func (g *gatewayLimiter) AllowRoute(path, identity string) bool {
+	for _, rt := range g.routes {
+		if rt.re.MatchString(path) {
+			return g.backend.AllowLimit("route:"+rt.name+":"+identity, rt.limit)
+		}
+	}
+	return true
+}
+
+// Close stops idle eviction and releases the redis connection
+func (g *gatewayLimiter) Close() {
+	g.stopOnce.Do(func() { close(g.stop) })
+	if rl, ok := g.backend.(*redisLimiter); ok {
+		if err := rl.client.Close(); err != nil {
+			logx.Errorf("gateway ratelimit: close redis: %v", err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"imy/pkg/jwt"
+)
+
+func TestRateLimitByIP(t *testing.T) {
+	upstream := newTestUpstream(t)
+	c, path := loadTestConfig(t, upstream.URL, "")
+	c.RateLimit = RateLimitConfig{
+		Enabled: true, RPS: 0.001, Burst: 2, Key: "ip",
+		Routes: []RouteRateLimit{{Name: "login", Path: "^/api/auth/login$", RPS: 0.001, Burst: 1}},
+	}
+	gw, _ := startTestGateway(t, c, path)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := serve(gw.handler, http.MethodPost, "/api/auth/register", "", "X-Forwarded-For: 198.51.100.1")
+		if w.Code != want {
+			t.Errorf("Request %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+	// other clients have their own bucket
+	if w := serve(gw.handler, http.MethodPost, "/api/auth/register", "", "X-Forwarded-For: 198.51.100.2"); w.Code != http.StatusOK {
+		t.Errorf("Expected another ip to pass, got %d", w.Code)
+	}
+	// route limits are stricter than the global one
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := serve(gw.handler, http.MethodPost, "/api/auth/login", "", "X-Forwarded-For: 198.51.100.3")
+		if w.Code != want {
+			t.Errorf("Login %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+}
+
+func TestRateLimitByUser(t *testing.T) {
+	upstream := newTestUpstream(t)
+	c, path := loadTestConfig(t, upstream.URL, "")
+	c.RateLimit = RateLimitConfig{
+		Enabled: true, RPS: 0.001, Burst: 1, Key: "uuid",
+		Users: []UserRateLimit{{UUID: "u-vip", RPS: 0.001, Burst: 3}},
+	}
+	gw, _ := startTestGateway(t, c, path)
+	user := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+	vip := testToken(t, jwt.JwtPayLoad{UUID: "u-vip"})
+
+	// every request comes from a new ip so only the per-user bucket is shared
+	ip := 0
+	request := func(token string) int {
+		ip++
+		return serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token), "X-Forwarded-For: 203.0.113."+strconv.Itoa(ip)).Code
+	}
+	if code := request(user); code != http.StatusOK {
+		t.Errorf("Expected the first request to pass, got %d", code)
+	}
+	if code := request(user); code != http.StatusTooManyRequests {
+		t.Errorf("Expected the per-user limit, got %d", code)
+	}
+	for i := 0; i < 3; i++ {
+		if code := request(vip); code != http.StatusOK {
+			t.Errorf("Expected the user override to allow request %d, got %d", i, code)
+		}
+	}
+}
+
+func TestRateLimitRedisUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	upstream := newTestUpstream(t)
+	c, path := loadTestConfig(t, upstream.URL, "")
+	c.RateLimit = RateLimitConfig{
+		Enabled: true, RPS: 0.001, Burst: 1, Key: "ip",
+		Backend: "redis", Redis: RateLimitRedis{Addr: addr, Prefix: "test:"},
+	}
+	gw, _ := startTestGateway(t, c, path)
+
+	// the local limiter takes over instead of rejecting or admitting everything
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		w := serve(gw.handler, http.MethodPost, "/api/auth/register", "", "X-Forwarded-For: 198.51.100.1")
+		if w.Code != want {
+			t.Errorf("Request %d: expected %d, got %d", i, want, w.Code)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// liveConfig is the part of the gateway config that can change without a
+// restart; requests load it once and use that snapshot throughout
+type liveConfig struct {
+	WhiteList []string
+	CORS      CORSConfig
+	RateLimit RateLimitConfig
+	APIKeys   APIKeyConfig
+	Authz     AuthzConfig
+	limiter   *gatewayLimiter
+	apiKeys   *apiKeySet // nil when api keys are disabled
+	authz     *authzRules
+}
+
+// newLiveConfig validates c and builds the limiter, reusing prev's limiter
+// (and its buckets) when the rate limit section did not change
+func newLiveConfig(c *GatewayConfig, prev *liveConfig) (*liveConfig, error) {
+	authz, err := newAuthzRules(c.Authz)
+	if err != nil {
+		return nil, fmt.Errorf("invalid authz config: %w", err)
+	}
+	live, err := newLiveLimits(c, prev)
+	if err != nil {
+		return nil, err
+	}
+	live.authz = authz
+	if c.APIKeys.Enabled {
+		var prevKeys *apiKeySet
+		if prev != nil {
+			prevKeys = prev.apiKeys
+		}
+		if live.apiKeys, err = newAPIKeySet(c.APIKeys, live.limiter, prevKeys); err != nil {
+			if live.limiter != nil && (prev == nil || live.limiter != prev.limiter) {
+				live.limiter.Close()
+			}
+			return nil, fmt.Errorf("invalid api key config: %w", err)
+		}
+	}
+	return live, nil
+}
+
+func newLiveLimits(c *GatewayConfig, prev *liveConfig) (*liveConfig, error) {
+	for _, p := range c.WhiteList {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid whitelist entry %q: %w", p, err)
+		}
+	}
+	live := &liveConfig{
+		WhiteList: c.WhiteList,
+		CORS:      c.CORS,
+		RateLimit: c.RateLimit,
+		APIKeys:   c.APIKeys,
+		Authz:     c.Authz,
+	}
+	if prev != nil && reflect.DeepEqual(prev.RateLimit, c.RateLimit) {
+		live.limiter = prev.limiter
+		return live, nil
+	}
+	if c.RateLimit.Enabled {
+		limiter, err := newGatewayLimiter(c.RateLimit, strings.ToLower(c.RateLimit.Key) == "uuid")
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit config: %w", err)
+		}
+		limiter.local.StartEviction(c.RateLimit.IdleEvict, limiter.stop)
+		live.limiter = limiter
+	}
+	return live, nil
+}
+
+// retireGrace keeps a replaced limiter usable by requests that loaded the old snapshot
+const retireGrace = 30 * time.Second
+
+// configReloader re-reads the gateway yaml on file changes or SIGHUP and
+// atomically swaps the live config; a bad file keeps the current config
+type configReloader struct {
+	path    string
+	current atomic.Pointer[liveConfig]
+	static  GatewayConfig // the config loaded at startup, for restart-only fields
+
+	mu sync.Mutex
+}
+
+func newConfigReloader(path string, c GatewayConfig) (*configReloader, error) {
+	live, err := newLiveConfig(&c, nil)
+	if err != nil {
+		return nil, err
+	}
+	r := &configReloader{path: path, static: c}
+	r.current.Store(live)
+	return r, nil
+}
+
+// Load returns the current snapshot
+func (r *configReloader) Load() *liveConfig {
+	return r.current.Load()
+}
+
+// Reload parses the file again and swaps in the new live config
+func (r *configReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var c GatewayConfig
+	if err := conf.Load(r.path, &c); err != nil {
+		return err
+	}
+	prev := r.current.Load()
+	live, err := newLiveConfig(&c, prev)
+	if err != nil {
+		return err
+	}
+	r.current.Store(live)
+	if prev.limiter != nil && prev.limiter != live.limiter {
+		old := prev.limiter
+		time.AfterFunc(retireGrace, old.Close)
+	}
+
+	if c.Upstream != r.static.Upstream || c.Host != r.static.Host || c.Port != r.static.Port ||
+		!reflect.DeepEqual(c.Auth, r.static.Auth) {
+		logx.Errorf("gateway reload: Upstream, Host, Port and Auth changes need a restart, ignored")
+	}
+	if c.APIKeys.Upstream != r.static.APIKeys.Upstream || c.APIKeys.ExchangePath != r.static.APIKeys.ExchangePath ||
+		c.APIKeys.CacheTTL != r.static.APIKeys.CacheTTL {
+		logx.Errorf("gateway reload: APIKeys Upstream, ExchangePath and CacheTTL changes need a restart, ignored")
+	}
+	logx.Infof("gateway reload: applied %s (whitelist %d entries, authz %d rules, cors %t, ratelimit %t, api keys %d)",
+		r.path, len(live.WhiteList), len(live.Authz.Rules), live.CORS.Enabled, live.RateLimit.Enabled, len(live.APIKeys.Keys))
+	return nil
+}
+
+// Watch reloads on SIGHUP and on writes to the config file until ctx is done.
+// The directory is watched so editors that replace the file and kubernetes
+// configmap symlink swaps are picked up too
+func (r *configReloader) Watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	abs, err := filepath.Abs(r.path)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	dir := filepath.Dir(abs)
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(hup)
+
+		// editors often emit several events per save, reload once they settle
+		debounce := time.NewTimer(time.Hour)
+		debounce.Stop()
+		reload := func(reason string) {
+			if err := r.Reload(); err != nil {
+				logx.Errorf("gateway reload (%s) failed, keeping current config: %v", reason, err)
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				reload("SIGHUP")
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if ev.Name == abs || strings.HasPrefix(filepath.Base(ev.Name), "..") {
+					debounce.Reset(200 * time.Millisecond)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logx.Errorf("gateway reload: watch %s: %v", dir, err)
+			case <-debounce.C:
+				reload("file change")
+			}
+		}
+	}()
+	return nil
+}
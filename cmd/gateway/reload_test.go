@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"imy/pkg/jwt"
+)
+
+// reloadedYAML whitelists /api/open and enables a generous rate limit on top of the base config
+func reloadedYAML(upstream string) string {
+	base := fmt.Sprintf(testConfigYAML, upstream)
+	base = strings.Replace(base, "  - ^/api/auth/.*\n", "  - ^/api/auth/.*\n  - ^/api/open/.*\n", 1)
+	return strings.Replace(base, "RateLimit:\n  Enabled: false\n  RPS: 0\n  Burst: 0\n",
+		"RateLimit:\n  Enabled: true\n  RPS: 100000\n  Burst: 100000\n", 1)
+}
+
+func TestReloadSwapsConfigWithoutDroppingRequests(t *testing.T) {
+	upstream := newTestUpstream(t)
+	c, path := loadTestConfig(t, upstream.URL, "")
+	gw, reloader := startTestGateway(t, c, path)
+	token := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+	original := fmt.Sprintf(testConfigYAML, upstream.URL)
+	reloaded := reloadedYAML(upstream.URL)
+
+	if w := serve(gw.handler, http.MethodGet, "/api/open/info", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected /api/open to need a token before the reload, got %d", w.Code)
+	}
+
+	var served, failed atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token)); w.Code != http.StatusOK {
+					failed.Add(1)
+				}
+				served.Add(1)
+			}
+		}()
+	}
+	for i := 1; i <= 20; i++ {
+		content := reloaded
+		if i%2 == 1 {
+			content = original
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		if err := reloader.Reload(); err != nil {
+			t.Fatalf("Failed to reload: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+	if served.Load() == 0 || failed.Load() != 0 {
+		t.Errorf("Expected every request to be served across reloads, %d of %d failed", failed.Load(), served.Load())
+	}
+
+	// the last reload applied the new whitelist and limiter
+	if w := serve(gw.handler, http.MethodGet, "/api/open/info", ""); w.Code != http.StatusOK {
+		t.Errorf("Expected /api/open to be whitelisted after the reload, got %d", w.Code)
+	}
+	if reloader.Load().limiter == nil {
+		t.Error("Expected the reloaded rate limit to be enabled")
+	}
+
+	// a broken file keeps the current config
+	live := reloader.Load()
+	broken := strings.Replace(reloaded, "  - ^/api/open/.*\n", "  - ^/api/(\n", 1)
+	if err := os.WriteFile(path, []byte(broken), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := reloader.Reload(); err == nil {
+		t.Error("Expected an invalid whitelist to be rejected")
+	}
+	if reloader.Load() != live {
+		t.Error("Expected the live config to be kept after a failed reload")
+	}
+
+	// restart-only fields are ignored
+	moved := strings.Replace(reloaded, "Upstream: "+upstream.URL, "Upstream: http://127.0.0.1:1", 1)
+	if err := os.WriteFile(path, []byte(moved), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Failed to reload: %v", err)
+	}
+	before := upstream.count()
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(token)); w.Code != http.StatusOK || upstream.count() != before+1 {
+		t.Errorf("Expected requests to keep going to the original upstream, got %d", w.Code)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/zeromicro/go-zero/core/logx"
+	"imy/pkg/jwt"
+)
+
+// RevocationConfig enables logout at the gateway: LogoutPath revokes the
+// caller's access token until it expires and revoked tokens get 401. The
+// redis backend shares the list across gateway replicas
+type RevocationConfig struct {
+	Enabled    bool            `json:",optional"`
+	LogoutPath string          `json:",default=/api/auth/logout"`
+	Backend    string          `json:",default=local"` // local | redis
+	Redis      RevocationRedis `json:",optional"`
+}
+
+type RevocationRedis struct {
+	Addr     string
+	Password string `json:",optional"`
+	DB       int    `json:",optional"`
+	Prefix   string `json:",default=gateway:revoked:"`
+}
+
+// revocationList remembers revoked tokens until they expire. Tokens are
+// keyed by jti, older tokens without one by their hash
+type revocationList struct {
+	mu        sync.Mutex
+	local     map[string]time.Time // key -> token expiry
+	lastSweep time.Time
+
+	client *redis.Client // nil for the local backend
+	prefix string
+}
+
+func newRevocationList(c RevocationConfig) *revocationList {
+	l := &revocationList{local: make(map[string]time.Time), lastSweep: time.Now()}
+	if c.Backend == "redis" {
+		l.client = redis.NewClient(&redis.Options{
+			Addr:     c.Redis.Addr,
+			Password: c.Redis.Password,
+			DB:       c.Redis.DB,
+		})
+		if err := l.client.Ping().Err(); err != nil {
+			logx.Errorf("gateway revocation: redis %s not reachable yet: %v", c.Redis.Addr, err)
+		}
+		l.prefix = c.Redis.Prefix
+		if l.prefix == "" {
+			l.prefix = "gateway:revoked:"
+		}
+	}
+	return l
+}
+
+func revocationKey(claims *jwt.CustomClaims, token string) string {
+	if claims.ID != "" {
+		return "jti:" + claims.ID
+	}
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Revoke adds the token to the list until it expires; it is kept locally as
+// well so this replica still rejects it while redis is down
+func (l *revocationList) Revoke(claims *jwt.CustomClaims, token string) error {
+	key := revocationKey(claims, token)
+	expires := time.Now().Add(24 * time.Hour)
+	if claims.ExpiresAt != nil {
+		expires = claims.ExpiresAt.Time
+	}
+	ttl := time.Until(expires)
+	if ttl <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	l.local[key] = expires
+	l.sweepLocked()
+	l.mu.Unlock()
+
+	if l.client != nil {
+		return l.client.Set(l.prefix+key, "1", ttl).Err()
+	}
+	return nil
+}
+
+// Revoked reports whether the token was revoked; when redis is unreachable
+// only the local entries are consulted
+func (l *revocationList) Revoked(claims *jwt.CustomClaims, token string) bool {
+	key := revocationKey(claims, token)
+	l.mu.Lock()
+	expires, ok := l.local[key]
+	l.mu.Unlock()
+	if ok && time.Now().Before(expires) {
+		return true
+	}
+	if l.client == nil {
+		return false
+	}
+	n, err := l.client.Exists(l.prefix + key).Result()
+	if err != nil {
+		logx.Errorf("gateway revocation: redis unavailable, using local list: %v", err)
+		return false
+	}
+	return n > 0
+}
+
+// sweepLocked drops expired entries at most once a minute
+func (l *revocationList) sweepLocked() {
+	now := time.Now()
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, expires := range l.local {
+		if !now.Before(expires) {
+			delete(l.local, key)
+		}
+	}
+}
+
+// Close releases the redis connection
+func (l *revocationList) Close() {
+	if l.client != nil {
+		if err := l.client.Close(); err != nil {
+			logx.Errorf("gateway revocation: close redis: %v", err)
+		}
+	}
+}
+
+// ServeLogout revokes the access token of the request
+func (l *revocationList) ServeLogout(w http.ResponseWriter, r *http.Request, keys *jwt.KeySet) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeRequestError(w, http.StatusMethodNotAllowed, "method not allowed", nil)
+		return
+	}
+	token := extractToken(r)
+	if token == "" {
+		writeRequestError(w, http.StatusUnauthorized, "token required", nil)
+		return
+	}
+	claims, err := keys.ParseToken(token)
+	if err != nil || claims == nil {
+		writeRequestError(w, http.StatusUnauthorized, "invalid token", nil)
+		return
+	}
+	if err := l.Revoke(claims, token); err != nil {
+		logx.Errorf("gateway revocation: revoke token of %s: %v", claims.UUID, err)
+		writeRequestError(w, http.StatusServiceUnavailable, "logout failed, retry later", nil)
+		return
+	}
+	logx.Infof("gateway: token of %s revoked by logout", claims.UUID)
+	setAccessUUID(r.Context(), claims.UUID)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(map[string]any{"code": 0, "msg": "ok"})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	gojwt "github.com/golang-jwt/jwt/v4"
+	"imy/pkg/jwt"
+)
+
+const revocationYAML = `Revocation:
+  Enabled: true
+`
+
+// tokenWithoutID signs a token the way older releases did, without a jti
+func tokenWithoutID(t *testing.T, uuid string, ttl time.Duration) string {
+	t.Helper()
+	claims := jwt.CustomClaims{
+		JwtPayLoad:       jwt.JwtPayLoad{UUID: uuid},
+		RegisteredClaims: gojwt.RegisteredClaims{ExpiresAt: gojwt.NewNumericDate(time.Now().Add(ttl))},
+	}
+	token, err := gojwt.NewWithClaims(gojwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestRevocationByTokenID(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, revocationYAML)
+	revoked := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+	other := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+
+	if w := serve(gw.handler, http.MethodGet, "/api/auth/logout", "", bearer(revoked)); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected logout to need POST, got %d", w.Code)
+	}
+	before := upstream.count()
+	if w := serve(gw.handler, http.MethodPost, "/api/auth/logout", "", bearer(revoked)); w.Code != http.StatusOK {
+		t.Fatalf("Expected logout to succeed, got %d: %s", w.Code, w.Body.String())
+	}
+	if upstream.count() != before {
+		t.Error("Expected logout to be answered by the gateway")
+	}
+
+	w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(revoked))
+	if w.Code != http.StatusUnauthorized || w.Body.String() != "Unauthorized: token revoked\n" {
+		t.Errorf("Expected the revoked token to get 401, got %d: %s", w.Code, w.Body.String())
+	}
+	// another session of the same user has its own jti
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(other)); w.Code != http.StatusOK {
+		t.Errorf("Expected a token with another jti to pass, got %d", w.Code)
+	}
+}
+
+func TestRevocationByTokenHash(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, revocationYAML)
+	revoked := tokenWithoutID(t, "u-1", time.Hour)
+	other := tokenWithoutID(t, "u-1", 2*time.Hour)
+
+	if w := serve(gw.handler, http.MethodPost, "/api/auth/logout", "", bearer(revoked)); w.Code != http.StatusOK {
+		t.Fatalf("Expected logout to succeed, got %d", w.Code)
+	}
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(revoked)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the revoked token to be keyed by its hash, got %d", w.Code)
+	}
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(other)); w.Code != http.StatusOK {
+		t.Errorf("Expected another token without a jti to pass, got %d", w.Code)
+	}
+}
+
+func TestRevocationRedisUnavailable(t *testing.T) {
+	// a port nothing listens on
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve a port: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, `Revocation:
+  Enabled: true
+  Backend: redis
+  Redis:
+    Addr: `+addr+`
+`)
+	revoked := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+	other := testToken(t, jwt.JwtPayLoad{UUID: "u-2"})
+
+	// the logout cannot be shared, but this replica remembers it
+	if w := serve(gw.handler, http.MethodPost, "/api/auth/logout", "", bearer(revoked)); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected logout to report the redis failure, got %d", w.Code)
+	}
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(revoked)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected the locally revoked token to be rejected, got %d", w.Code)
+	}
+	// lookups fail open instead of rejecting every token
+	if w := serve(gw.handler, http.MethodGet, "/api/user/info", "", bearer(other)); w.Code != http.StatusOK {
+		t.Errorf("Expected other tokens to pass while redis is down, got %d", w.Code)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ListenerTLSConfig completes the RestConf CertFile/KeyFile used for TLS
+// termination; a client CA turns on mutual TLS for callers
+type ListenerTLSConfig struct {
+	ClientCAFile   string `json:",optional"`
+	ClientOptional bool   `json:",optional"` // verify client certs when presented instead of requiring them
+}
+
+// UpstreamTLSConfig is used when dialing an https upstream
+type UpstreamTLSConfig struct {
+	CAFile             string `json:",optional"` // system roots when empty
+	CertFile           string `json:",optional"` // client certificate for mutual TLS
+	KeyFile            string `json:",optional"`
+	ServerName         string `json:",optional"`
+	InsecureSkipVerify bool   `json:",optional"`
+}
+
+func (c UpstreamTLSConfig) enabled() bool {
+	return c.CAFile != "" || c.CertFile != "" || c.ServerName != "" || c.InsecureSkipVerify
+}
+
+// listenerTLS returns nil when the gateway serves plain http
+func listenerTLS(certFile, keyFile string, c ListenerTLSConfig) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if c.ClientCAFile != "" {
+			return nil, fmt.Errorf("ClientCAFile requires CertFile and KeyFile")
+		}
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load gateway certificate: %w", err)
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.ClientCAFile != "" {
+		pool, err := loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+		if c.ClientOptional {
+			cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return cfg, nil
+}
+
+// upstreamTLS returns nil when the defaults of http.DefaultTransport are enough
+func upstreamTLS(c UpstreamTLSConfig) (*tls.Config, error) {
+	if !c.enabled() {
+		return nil, nil
+	}
+	cfg := &tls.Config{
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+	if c.CAFile != "" {
+		pool, err := loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load upstream client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// upstreamTransport is shared by the proxy and the health checker
+func upstreamTransport(cfg *tls.Config) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = cfg
+	return t
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read ca file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return pool, nil
+}
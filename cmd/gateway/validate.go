@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type ValidationConfig struct {
+	Enabled     bool  `json:",optional"`
+	MaxBodySize int64 `json:",default=1048576"` // bytes, 0 means unlimited
+	// media types accepted for requests with a body; defaults to application/json
+	ContentTypes []string          `json:",optional"`
+	Routes       []ValidationRoute `json:",optional"`
+}
+
+// ValidationRoute overrides the limits for matching requests and optionally
+// checks their JSON body against a schema file; the first matching route wins
+type ValidationRoute struct {
+	Name         string
+	Path         string   // regex matched against the request path
+	Methods      []string `json:",optional"` // all methods when empty
+	MaxBodySize  int64    `json:",optional"`
+	ContentTypes []string `json:",optional"`
+	Schema       string   `json:",optional"` // JSON schema file
+}
+
+type validationRoute struct {
+	ValidationRoute
+	re           *regexp.Regexp
+	methods      map[string]bool
+	contentTypes []string
+	schema       *jsonSchema
+}
+
+// requestValidator rejects requests whose body is too large, has an
+// unexpected content type or does not match the route schema, before they
+// are forwarded upstream
+type requestValidator struct {
+	cfg          ValidationConfig
+	contentTypes []string
+	routes       []*validationRoute
+}
+
+func newRequestValidator(cfg ValidationConfig) (*requestValidator, error) {
+	v := &requestValidator{cfg: cfg, contentTypes: normalizeMediaTypes(cfg.ContentTypes)}
+	if len(v.contentTypes) == 0 {
+		v.contentTypes = []string{"application/json"}
+	}
+	for _, rc := range cfg.Routes {
+		re, err := regexp.Compile(rc.Path)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: invalid path %q: %w", rc.Name, rc.Path, err)
+		}
+		route := &validationRoute{
+			ValidationRoute: rc,
+			re:              re,
+			methods:         make(map[string]bool),
+			contentTypes:    normalizeMediaTypes(rc.ContentTypes),
+		}
+		for _, m := range rc.Methods {
+			route.methods[strings.ToUpper(m)] = true
+		}
+		if rc.Schema != "" {
+			if route.schema, err = loadJSONSchema(rc.Schema); err != nil {
+				return nil, fmt.Errorf("route %s: %w", rc.Name, err)
+			}
+		}
+		v.routes = append(v.routes, route)
+	}
+	return v, nil
+}
+
+func normalizeMediaTypes(types []string) []string {
+	out := make([]string, 0, len(types))
+	for _, t := range types {
+		out = append(out, strings.ToLower(strings.TrimSpace(t)))
+	}
+	return out
+}
+
+func (v *requestValidator) match(r *http.Request) *validationRoute {
+	for _, route := range v.routes {
+		if len(route.methods) > 0 && !route.methods[r.Method] {
+			continue
+		}
+		if route.re.MatchString(r.URL.Path) {
+			return route
+		}
+	}
+	return nil
+}
+
+// Check writes a 4xx response and returns false when r is rejected. Bodies
+// that are checked against a schema or sent without a Content-Length are
+// buffered, and r.Body is replaced with the buffered copy
+func (v *requestValidator) Check(w http.ResponseWriter, r *http.Request) bool {
+	route := v.match(r)
+	limit := v.cfg.MaxBodySize
+	allowed := v.contentTypes
+	var schema *jsonSchema
+	if route != nil {
+		if route.MaxBodySize > 0 {
+			limit = route.MaxBodySize
+		}
+		if len(route.contentTypes) > 0 {
+			allowed = route.contentTypes
+		}
+		schema = route.schema
+	}
+
+	hasBody := r.Body != nil && r.Body != http.NoBody && r.ContentLength != 0
+	if !hasBody {
+		if schema != nil {
+			writeRequestError(w, http.StatusBadRequest, "request body required", nil)
+			return false
+		}
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !containsMediaType(allowed, mediaType) {
+		writeRequestError(w, http.StatusUnsupportedMediaType, "unsupported content type",
+			map[string]any{"allowed": allowed})
+		return false
+	}
+	if limit > 0 && r.ContentLength > limit {
+		writeRequestError(w, http.StatusRequestEntityTooLarge, "request body too large",
+			map[string]any{"maxBodySize": limit})
+		return false
+	}
+	// a known Content-Length within the limit is enforced by net/http itself
+	if schema == nil && r.ContentLength > 0 {
+		return true
+	}
+
+	reader := io.Reader(r.Body)
+	if limit > 0 {
+		reader = io.LimitReader(r.Body, limit+1)
+	}
+	body, err := io.ReadAll(reader)
+	r.Body.Close()
+	if err != nil {
+		writeRequestError(w, http.StatusBadRequest, "failed to read request body", nil)
+		return false
+	}
+	if limit > 0 && int64(len(body)) > limit {
+		writeRequestError(w, http.StatusRequestEntityTooLarge, "request body too large",
+			map[string]any{"maxBodySize": limit})
+		return false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(body)), nil }
+	r.ContentLength = int64(len(body))
+	r.TransferEncoding = nil
+
+	if schema != nil {
+		if errs := schema.Validate(body); len(errs) > 0 {
+			writeRequestError(w, http.StatusBadRequest, "invalid request body", map[string]any{"errors": errs})
+			return false
+		}
+	}
+	return true
+}
+
+func containsMediaType(allowed []string, mediaType string) bool {
+	for _, t := range allowed {
+		if t == "*/*" || t == mediaType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(mediaType, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// writeRequestError replies in the code/msg/data shape of the upstream API
+func writeRequestError(w http.ResponseWriter, status int, msg string, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"code": status,
+		"msg":  msg,
+		"data": data,
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const validationYAML = `Validation:
+  Enabled: true
+  MaxBodySize: 256
+  Routes:
+    - Name: login
+      Path: ^/api/auth/emailPasswordLogin$
+      Methods: [POST]
+      Schema: ../../etc/schemas/emailPasswordLogin.json
+`
+
+func TestRequestValidation(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, validationYAML)
+	const path = "/api/auth/emailPasswordLogin"
+	const jsonType = "Content-Type: application/json"
+
+	tests := []struct {
+		name   string
+		body   string
+		header []string
+		code   int
+		msg    string
+	}{
+		{"valid", `{"email":"a@b.c","password":"x"}`, []string{jsonType}, http.StatusOK, ""},
+		{"missing field", `{"email":"a@b.c"}`, []string{jsonType}, http.StatusBadRequest, "invalid request body"},
+		{"unknown field", `{"email":"a@b.c","password":"x","admin":true}`, []string{jsonType}, http.StatusBadRequest, "invalid request body"},
+		{"wrong type", `{"email":"a@b.c","password":1}`, []string{jsonType}, http.StatusBadRequest, "invalid request body"},
+		{"not json", `email=a@b.c`, []string{jsonType}, http.StatusBadRequest, "invalid request body"},
+		{"no body", "", nil, http.StatusBadRequest, "request body required"},
+		{"content type", `{"email":"a@b.c","password":"x"}`, []string{"Content-Type: text/plain"}, http.StatusUnsupportedMediaType, "unsupported content type"},
+		{"too large", `{"email":"a@b.c","password":"` + strings.Repeat("x", 300) + `"}`, []string{jsonType}, http.StatusRequestEntityTooLarge, "request body too large"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := upstream.count()
+			w := serve(gw.handler, http.MethodPost, path, tt.body, tt.header...)
+			if w.Code != tt.code {
+				t.Fatalf("Expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.msg != "" && responseMsg(w) != tt.msg {
+				t.Errorf("Expected message %q, got %q", tt.msg, responseMsg(w))
+			}
+			if reached := upstream.count() > before; reached != (tt.code == http.StatusOK) {
+				t.Errorf("Expected the upstream to be reached only for valid bodies, reached=%v", reached)
+			}
+		})
+	}
+
+	// the buffered body is forwarded unchanged
+	serve(gw.handler, http.MethodPost, path, `{"email":"a@b.c","password":"x"}`, jsonType)
+	var body map[string]string
+	if err := json.NewDecoder(upstream.last(t).Body).Decode(&body); err != nil || body["email"] != "a@b.c" {
+		t.Errorf("Expected the upstream to receive the body, got %v %v", body, err)
+	}
+
+	// schema errors name the field
+	w := serve(gw.handler, http.MethodPost, path, `{"email":"a@b.c"}`, jsonType)
+	var resp struct {
+		Data struct {
+			Errors []schemaError `json:"errors"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Data.Errors) != 1 || resp.Data.Errors[0].Field != "password" {
+		t.Errorf("Expected an error for the missing password, got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,394 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type WebSocketConfig struct {
+	IdleTimeout     time.Duration `json:",default=90s"`     // close a hop that sent no frame (pongs included) for this long
+	PingInterval    time.Duration `json:",default=30s"`     // gateway keepalive pings sent to both hops
+	MaxConnsPerUser int           `json:",default=5"`       // 0 disables the limit
+	MaxMessageSize  int64         `json:",default=1048576"` // largest message relayed in either direction
+	DrainTimeout    time.Duration `json:",default=15s"`     // how long shutdown waits for sessions to close
+}
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsDialWait   = 10 * time.Second
+	wsCloseGrace = 2 * time.Second // time the second hop gets to finish the closing handshake
+)
+
+// hop-by-hop and handshake headers the dialer sets itself
+var wsSkipHeaders = map[string]bool{
+	"Connection":               true,
+	"Upgrade":                  true,
+	"Host":                     true,
+	"Sec-Websocket-Key":        true,
+	"Sec-Websocket-Version":    true,
+	"Sec-Websocket-Extensions": true,
+	"Sec-Websocket-Protocol":   true,
+}
+
+// wsProxy relays WebSocket sessions frame by frame so the gateway can enforce
+// idle timeouts and token expiry and send proper close frames when draining
+type wsProxy struct {
+	cfg      WebSocketConfig
+	upstream *url.URL
+	dialer   *websocket.Dialer
+	upgrader websocket.Upgrader
+	// renew validates a token sent in an auth frame for user and returns its
+	// expiry; nil leaves sessions bound to the token of the upgrade
+	renew func(user, token string) (time.Time, bool)
+
+	mu       sync.Mutex
+	perUser  map[string]int
+	sessions map[*wsSession]struct{}
+	draining bool
+	wg       sync.WaitGroup
+}
+
+func newWsProxy(cfg WebSocketConfig, upstream *url.URL) *wsProxy {
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 90 * time.Second
+	}
+	if cfg.PingInterval <= 0 || cfg.PingInterval >= cfg.IdleTimeout {
+		cfg.PingInterval = cfg.IdleTimeout / 3
+	}
+	if cfg.MaxMessageSize <= 0 {
+		cfg.MaxMessageSize = 1 << 20
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = 15 * time.Second
+	}
+
+	target := *upstream
+	switch target.Scheme {
+	case "https":
+		target.Scheme = "wss"
+	default:
+		target.Scheme = "ws"
+	}
+	return &wsProxy{
+		cfg:      cfg,
+		upstream: &target,
+		dialer: &websocket.Dialer{
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: wsDialWait,
+		},
+		upgrader: websocket.Upgrader{
+			// origin checks are left to CORS config and the upstream
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		perUser:  make(map[string]int),
+		sessions: make(map[*wsSession]struct{}),
+	}
+}
+
+// acquire reserves a connection slot for the user
+func (p *wsProxy) acquire(user string) (int, string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.draining {
+		return http.StatusServiceUnavailable, "Service Unavailable: gateway is draining"
+	}
+	if p.cfg.MaxConnsPerUser > 0 && p.perUser[user] >= p.cfg.MaxConnsPerUser {
+		return http.StatusTooManyRequests, "Too Many Requests: websocket connection limit reached"
+	}
+	p.perUser[user]++
+	p.wg.Add(1)
+	return 0, ""
+}
+
+func (p *wsProxy) release(user string, s *wsSession) {
+	p.mu.Lock()
+	if p.perUser[user]--; p.perUser[user] <= 0 {
+		delete(p.perUser, user)
+	}
+	if s != nil {
+		delete(p.sessions, s)
+	}
+	p.mu.Unlock()
+	p.wg.Done()
+}
+
+// upstreamURL maps the request path and query onto the upstream, like the HTTP director
+func (p *wsProxy) upstreamURL(r *http.Request) string {
+	u := *p.upstream
+	if p.upstream.Path != "" && p.upstream.Path != "/" {
+		u.Path = singleJoiningSlash(p.upstream.Path, r.URL.Path)
+	} else {
+		u.Path = r.URL.Path
+	}
+	u.RawPath = ""
+	u.RawQuery = joinQuery(p.upstream.RawQuery, r.URL.RawQuery)
+	return u.String()
+}
+
+// ServeWs proxies one upgrade request. user keys the per-user connection limit and
+// expires, when set, is the token expiry after which the session is closed
+// unless the client renews its token in band.
+func (p *wsProxy) ServeWs(w http.ResponseWriter, r *http.Request, user string, expires time.Time) {
+	if code, msg := p.acquire(user); code != 0 {
+		http.Error(w, msg, code)
+		return
+	}
+
+	header := http.Header{}
+	for name, values := range r.Header {
+		if !wsSkipHeaders[http.CanonicalHeaderKey(name)] {
+			header[name] = values
+		}
+	}
+	if ip := getClientIP(r); ip != "" {
+		header.Set("X-Forwarded-For", ip)
+	}
+	dialer := *p.dialer
+	dialer.Subprotocols = websocket.Subprotocols(r)
+
+	ctx, cancel := context.WithTimeout(r.Context(), wsDialWait)
+	upstream, resp, err := dialer.DialContext(ctx, p.upstreamURL(r), header)
+	cancel()
+	if err != nil {
+		p.release(user, nil)
+		if resp != nil {
+			// relay the upstream refusal, e.g. 401 from its own auth check
+			defer resp.Body.Close()
+			w.WriteHeader(resp.StatusCode)
+			_, _ = io.Copy(w, io.LimitReader(resp.Body, 4096))
+			return
+		}
+		logx.Errorf("gateway: dial upstream websocket failed: %v", err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+
+	var respHeader http.Header
+	if sub := upstream.Subprotocol(); sub != "" {
+		respHeader = http.Header{"Sec-Websocket-Protocol": {sub}}
+	}
+	client, err := p.upgrader.Upgrade(w, r, respHeader)
+	if err != nil {
+		// the upgrader already replied to the client
+		upstream.Close()
+		p.release(user, nil)
+		return
+	}
+
+	s := &wsSession{proxy: p, client: client, upstream: upstream, user: user}
+	s.setExpires(expires)
+	p.mu.Lock()
+	p.sessions[s] = struct{}{}
+	p.mu.Unlock()
+	defer p.release(user, s)
+
+	s.run()
+}
+
+// Drain stops accepting upgrades, asks every session to close (upstream first)
+// and waits for them until ctx expires, then cuts the remaining connections
+func (p *wsProxy) Drain(ctx context.Context) {
+	p.mu.Lock()
+	p.draining = true
+	sessions := make([]*wsSession, 0, len(p.sessions))
+	for s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.mu.Unlock()
+
+	logx.Infof("gateway: draining %d websocket sessions", len(sessions))
+	for _, s := range sessions {
+		s.shutdown(websocket.CloseGoingAway, "gateway shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		p.mu.Lock()
+		for s := range p.sessions {
+			s.terminate()
+		}
+		p.mu.Unlock()
+		<-done
+	}
+}
+
+// wsSession is one client <-> upstream pair
+type wsSession struct {
+	proxy    *wsProxy
+	client   *websocket.Conn
+	upstream *websocket.Conn
+	user     string
+	expires  atomic.Int64 // unix nanos of the token expiry, 0 when unbounded
+	closing  atomic.Bool
+}
+
+func (s *wsSession) setExpires(t time.Time) {
+	if t.IsZero() {
+		s.expires.Store(0)
+		return
+	}
+	s.expires.Store(t.UnixNano())
+}
+
+// inspect picks up in-band token renewals (auth envelopes) from the client so
+// the session outlives the token of the upgrade; the frame is relayed either
+// way and the upstream answers it
+func (s *wsSession) inspect(data []byte) {
+	if s.proxy.renew == nil || !bytes.Contains(data, []byte(`"auth"`)) {
+		return
+	}
+	var env struct {
+		Type    string `json:"type"`
+		Payload struct {
+			Token string `json:"token"`
+		} `json:"payload"`
+	}
+	if json.Unmarshal(data, &env) != nil || env.Type != "auth" || env.Payload.Token == "" {
+		return
+	}
+	if expires, ok := s.proxy.renew(s.user, env.Payload.Token); ok {
+		s.setExpires(expires)
+	}
+}
+
+func (s *wsSession) run() {
+	idle := s.proxy.cfg.IdleTimeout
+	for _, c := range []*websocket.Conn{s.client, s.upstream} {
+		c := c
+		c.SetReadLimit(s.proxy.cfg.MaxMessageSize)
+		s.touch(c, idle)
+		c.SetPongHandler(func(string) error {
+			s.touch(c, idle)
+			return nil
+		})
+		// keepalive is per hop: answer pings here instead of relaying them
+		c.SetPingHandler(func(data string) error {
+			s.touch(c, idle)
+			err := c.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(wsWriteWait))
+			var ne net.Error
+			if errors.Is(err, websocket.ErrCloseSent) || (errors.As(err, &ne) && ne.Timeout()) {
+				return nil
+			}
+			return err
+		})
+	}
+
+	errc := make(chan error, 2)
+	go func() { errc <- s.pump(s.client, s.upstream) }()
+	go func() { errc <- s.pump(s.upstream, s.client) }()
+	stop := make(chan struct{})
+	go s.keepalive(stop)
+
+	<-errc
+	// one hop is gone; give the other a moment to complete the closing handshake
+	s.closing.Store(true)
+	deadline := time.Now().Add(wsCloseGrace)
+	s.client.SetReadDeadline(deadline)
+	s.upstream.SetReadDeadline(deadline)
+	<-errc
+	close(stop)
+	s.terminate()
+}
+
+// touch extends the idle deadline unless the session is already closing
+func (s *wsSession) touch(c *websocket.Conn, idle time.Duration) {
+	if !s.closing.Load() {
+		c.SetReadDeadline(time.Now().Add(idle))
+	}
+}
+
+// pump relays messages from src to dst and forwards the close frame that ended src
+func (s *wsSession) pump(src, dst *websocket.Conn) error {
+	for {
+		msgType, data, err := src.ReadMessage()
+		if err != nil {
+			code, text := websocket.CloseGoingAway, ""
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				code, text = ce.Code, ce.Text
+				if code == websocket.CloseNoStatusReceived || code == websocket.CloseAbnormalClosure {
+					code = websocket.CloseNormalClosure
+				}
+			} else {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() && !s.closing.Load() {
+					text = "idle timeout"
+				}
+			}
+			_ = dst.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(wsWriteWait))
+			return err
+		}
+		s.touch(src, s.proxy.cfg.IdleTimeout)
+		if src == s.client && msgType == websocket.TextMessage {
+			s.inspect(data)
+		}
+		dst.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		if err := dst.WriteMessage(msgType, data); err != nil {
+			_ = src.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""), time.Now().Add(wsWriteWait))
+			return err
+		}
+	}
+}
+
+// keepalive pings both hops and closes the session once the token expires
+func (s *wsSession) keepalive(stop <-chan struct{}) {
+	ticker := time.NewTicker(s.proxy.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if exp := s.expires.Load(); exp != 0 && now.UnixNano() > exp {
+				s.shutdown(websocket.ClosePolicyViolation, "token expired")
+				return
+			}
+			deadline := now.Add(wsWriteWait)
+			_ = s.upstream.WriteControl(websocket.PingMessage, nil, deadline)
+			_ = s.client.WriteControl(websocket.PingMessage, nil, deadline)
+		}
+	}
+}
+
+// shutdown sends close frames to the upstream first so it can persist state,
+// then to the client; the pumps exit when the peers answer or the grace period ends
+func (s *wsSession) shutdown(code int, text string) {
+	s.closing.Store(true)
+	msg := websocket.FormatCloseMessage(code, text)
+	deadline := time.Now().Add(wsWriteWait)
+	_ = s.upstream.WriteControl(websocket.CloseMessage, msg, deadline)
+	_ = s.client.WriteControl(websocket.CloseMessage, msg, deadline)
+	grace := time.Now().Add(wsCloseGrace)
+	s.client.SetReadDeadline(grace)
+	s.upstream.SetReadDeadline(grace)
+}
+
+// terminate closes both underlying connections immediately
+func (s *wsSession) terminate() {
+	s.client.Close()
+	s.upstream.Close()
+}
+
+// isWebSocketUpgrade reports whether r asks to switch to the websocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	return websocket.IsWebSocketUpgrade(r) && strings.EqualFold(r.Method, http.MethodGet)
+}
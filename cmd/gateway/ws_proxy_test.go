@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"imy/pkg/jwt"
+)
+
+func TestWebSocketProxy(t *testing.T) {
+	upstream := newTestUpstream(t)
+	gw := newTestGateway(t, upstream, `WebSocket:
+  MaxConnsPerUser: 1
+`)
+	server := httptest.NewServer(gw.handler)
+	defer server.Close()
+	token := testToken(t, jwt.JwtPayLoad{UUID: "u-1"})
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/api/ws"
+
+	// browsers pass the token in the query
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL+"?token="+token+"&device=web", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial the gateway: %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("ping")); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "ping" {
+		t.Fatalf("Expected the upstream echo, got %q %v", data, err)
+	}
+	got := upstream.last(t)
+	if got.URL.Query().Has("token") || got.URL.Query().Get("device") != "web" {
+		t.Errorf("Expected only the token to be removed from the query, got %q", got.URL.RawQuery)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer "+token {
+		t.Errorf("Expected the token to be sent as a bearer header, got %q", auth)
+	}
+	if uuid := got.Header.Get("uuid"); uuid != "u-1" {
+		t.Errorf("Expected the uuid to be injected, got %q", uuid)
+	}
+
+	// one session per user
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL+"?token="+token, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected the second session to be refused with 429, got %v", err)
+	}
+	// the upgrade needs a token like any other request
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected an upgrade without a token to get 401, got %v", err)
+	}
+}
@@ -0,0 +1,283 @@
+// Command loadgen drives a synthetic chat workload through the gateway.
+//
+// It registers and signs in -users synthetic accounts, creates -convs group
+// conversations with -members members each and opens a WebSocket stream per
+// user. It then sends -rate messages per second for -duration from random
+// members. At the end it reports send latency percentiles, the error rate and
+// the WS delivery lag, which is measured from the send timestamp embedded in
+// each message. Re-running with the same -prefix reuses the accounts.
+//
+// The gateway rate-limits the auth endpoints, so raise those limits in
+// etc/gateway.yaml before signing in many users.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"imy/pkg/imyclient"
+)
+
+var (
+	gateway  = flag.String("gateway", "http://127.0.0.1:8081", "gateway base url")
+	users    = flag.Int("users", 20, "number of synthetic users")
+	convs    = flag.Int("convs", 5, "number of group conversations")
+	members  = flag.Int("members", 5, "members per conversation, including the owner")
+	rate     = flag.Float64("rate", 20, "messages sent per second across all conversations")
+	duration = flag.Duration("duration", 30*time.Second, "how long to send messages")
+	workers  = flag.Int("workers", 32, "maximum concurrent requests")
+	prefix   = flag.String("prefix", "loadgen", "email prefix of the synthetic users")
+	password = flag.String("password", "loadgen-password", "password of the synthetic users")
+	drain    = flag.Duration("drain", 3*time.Second, "how long to wait for WS deliveries after sending stops")
+)
+
+// contentPrefix marks loadgen messages; the rest of the content is the send time in UnixNano
+const contentPrefix = "loadgen:"
+
+type user struct {
+	email  string
+	uuid   string
+	client *imyclient.Client
+}
+
+type conversation struct {
+	id      uint32
+	members []*user
+}
+
+// samples collects durations for percentile reporting
+type samples struct {
+	mu     sync.Mutex
+	values []time.Duration
+}
+
+func (s *samples) add(d time.Duration) {
+	s.mu.Lock()
+	s.values = append(s.values, d)
+	s.mu.Unlock()
+}
+
+// report prints the count and latency percentiles of the samples
+func (s *samples) report(name string) {
+	s.mu.Lock()
+	values := slices.Clone(s.values)
+	s.mu.Unlock()
+	if len(values) == 0 {
+		fmt.Printf("%-14s no samples\n", name)
+		return
+	}
+	slices.Sort(values)
+	pct := func(p float64) time.Duration {
+		return values[min(len(values)-1, int(p*float64(len(values))))]
+	}
+	fmt.Printf("%-14s n=%d p50=%s p90=%s p99=%s max=%s\n", name, len(values),
+		pct(0.50).Round(time.Microsecond), pct(0.90).Round(time.Microsecond),
+		pct(0.99).Round(time.Microsecond), values[len(values)-1].Round(time.Microsecond))
+}
+
+func main() {
+	flag.Parse()
+	if *users < 2 || *convs < 1 || *members < 2 || *members > *users || *rate <= 0 {
+		log.Fatal("loadgen: need -users >= -members >= 2, -convs >= 1 and -rate > 0")
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	start := time.Now()
+	all, err := setupUsers(ctx)
+	if err != nil {
+		log.Fatalf("loadgen: %v", err)
+	}
+	conversations, err := setupConversations(ctx, all)
+	if err != nil {
+		log.Fatalf("loadgen: %v", err)
+	}
+	log.Printf("loadgen: %d users and %d conversations ready in %s", len(all), len(conversations), time.Since(start).Round(time.Millisecond))
+
+	var (
+		sendLatency, deliveryLag samples
+		sent, failed, delivered  atomic.Int64
+		expected                 atomic.Int64
+	)
+
+	// one event stream per user; every member receives message_new for each message
+	streamCtx, stopStreams := context.WithCancel(ctx)
+	defer stopStreams()
+	var streams sync.WaitGroup
+	for _, u := range all {
+		streams.Add(1)
+		go func() {
+			defer streams.Done()
+			err := u.client.StreamEvents(streamCtx, 0, func(event *imyclient.Event) error {
+				if sentAt, ok := messageSentAt(event); ok {
+					delivered.Add(1)
+					deliveryLag.add(time.Since(sentAt))
+				}
+				return nil
+			})
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("loadgen: stream of %s: %v", u.email, err)
+			}
+		}()
+	}
+	// give the streams a moment to connect so early messages are not missed
+	time.Sleep(time.Second)
+
+	log.Printf("loadgen: sending %.1f msg/s for %s", *rate, *duration)
+	sendStart := time.Now()
+	sendCtx, stopSending := context.WithTimeout(ctx, *duration)
+	defer stopSending()
+	sem := make(chan struct{}, *workers)
+	var sends sync.WaitGroup
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / *rate))
+	defer ticker.Stop()
+	var seq atomic.Int64
+loop:
+	for {
+		select {
+		case <-sendCtx.Done():
+			break loop
+		case <-ticker.C:
+		}
+		select {
+		case sem <- struct{}{}:
+		default:
+			// every worker is busy: the target rate is not reachable, count it as a failure
+			failed.Add(1)
+			continue
+		}
+		conv := conversations[rand.IntN(len(conversations))]
+		sender := conv.members[rand.IntN(len(conv.members))]
+		sends.Add(1)
+		go func() {
+			defer func() { <-sem; sends.Done() }()
+			begin := time.Now()
+			_, err := sender.client.SendMessage(ctx, &imyclient.SendMessageRequest{
+				ConversationId: conv.id,
+				ClientMsgId:    fmt.Sprintf("%s-%d-%d", *prefix, begin.UnixNano(), seq.Add(1)),
+				MsgType:        imyclient.MsgTypeText,
+				Content:        contentPrefix + strconv.FormatInt(begin.UnixNano(), 10),
+			})
+			if err != nil {
+				failed.Add(1)
+				log.Printf("loadgen: send: %v", err)
+				return
+			}
+			sent.Add(1)
+			expected.Add(int64(len(conv.members)))
+			sendLatency.add(time.Since(begin))
+		}()
+	}
+	sends.Wait()
+	elapsed := time.Since(sendStart)
+	if ctx.Err() == nil {
+		time.Sleep(*drain)
+	}
+	stopStreams()
+	streams.Wait()
+
+	total := sent.Load() + failed.Load()
+	fmt.Printf("messages       sent=%d failed=%d error_rate=%.2f%%\n", sent.Load(), failed.Load(), percent(failed.Load(), total))
+	sendLatency.report("send latency")
+	fmt.Printf("ws deliveries  received=%d expected=%d (%.2f%%)\n", delivered.Load(), expected.Load(), percent(delivered.Load(), expected.Load()))
+	deliveryLag.report("delivery lag")
+	fmt.Printf("send phase     %s, %.1f msg/s achieved\n", elapsed.Round(time.Millisecond), float64(sent.Load())/elapsed.Seconds())
+}
+
+func percent(n, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// setupUsers registers the synthetic users if needed and signs them in
+func setupUsers(ctx context.Context) ([]*user, error) {
+	all := make([]*user, *users)
+	errs := make([]error, *users)
+	sem := make(chan struct{}, *workers)
+	var wg sync.WaitGroup
+	for i := range all {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			client, err := imyclient.New(imyclient.Config{BaseURL: *gateway})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			u := &user{email: fmt.Sprintf("%s-%d@loadgen.test", *prefix, i), client: client}
+			// registering an existing account fails; the login below decides
+			var apiErr *imyclient.APIError
+			if _, err := client.Register(ctx, u.email, *password, ""); err != nil && !errors.As(err, &apiErr) {
+				errs[i] = fmt.Errorf("register %s: %w", u.email, err)
+				return
+			}
+			session, err := client.Login(ctx, u.email, *password)
+			if err != nil {
+				errs[i] = fmt.Errorf("login %s: %w", u.email, err)
+				return
+			}
+			u.uuid = session.UUID
+			all[i] = u
+		}()
+	}
+	wg.Wait()
+	return all, errors.Join(errs...)
+}
+
+// setupConversations creates the group conversations, each owned by a random user
+func setupConversations(ctx context.Context, all []*user) ([]*conversation, error) {
+	result := make([]*conversation, 0, *convs)
+	for i := 0; i < *convs; i++ {
+		picked := make([]*user, 0, *members)
+		for _, idx := range rand.Perm(len(all))[:*members] {
+			picked = append(picked, all[idx])
+		}
+		uuids := make([]string, 0, len(picked)-1)
+		for _, u := range picked[1:] {
+			uuids = append(uuids, u.uuid)
+		}
+		conv, err := picked[0].client.CreateGroup(ctx, fmt.Sprintf("%s-%d", *prefix, i), uuids)
+		if err != nil {
+			return nil, fmt.Errorf("create conversation %d: %w", i, err)
+		}
+		result = append(result, &conversation{id: conv.ConversationId, members: picked})
+	}
+	return result, nil
+}
+
+// messageSentAt extracts the send time from a message_new event of a loadgen message
+func messageSentAt(event *imyclient.Event) (time.Time, bool) {
+	var payload struct {
+		Op   string            `json:"op"`
+		Data imyclient.Message `json:"data"`
+	}
+	if event.Type != "message" || json.Unmarshal(event.Payload, &payload) != nil || payload.Op != "message_new" {
+		return time.Time{}, false
+	}
+	nanos, ok := strings.CutPrefix(payload.Data.Content, contentPrefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, n), true
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/conf"
+	"github.com/zeromicro/go-zero/core/logx"
+	"imy/pkg/blob"
+	"imy/pkg/events"
+	"imy/pkg/moderation"
+)
+
+type StoreNodeConfig struct {
+	StoreID         string        `json:"StoreID"`
+	ListenOn        string        `json:",default=0.0.0.0:9100"`
+	Advertise       string        `json:",optional"`    // address other nodes dial, derived from ListenOn when empty
+	ShutdownTimeout time.Duration `json:",default=15s"` // drain deadline on SIGTERM, see storeNode.Stop
+	DeregisterDelay time.Duration `json:",optional"`    // keeps serving this long after leaving the registry
+
+	// published in the registry metadata together with the store capacity
+	Region string `json:",optional"`
+	Tier   string `json:",optional"`
+	// tenants this store is dedicated to; their timelines only go to their
+	// dedicated stores, other tenants only use stores without Tenants
+	Tenants      []string            `json:",optional"`
+	TenantQuotas []TenantQuotaConfig `json:",optional"`
+
+	Store       StoreConfig       `json:"Store"`
+	Tiering     TieringConfig     `json:"Tiering,optional"`
+	Registry    RegistryConfig    `json:"Registry,optional"`
+	GlobalIndex GlobalIndexConfig `json:"GlobalIndex,optional"`
+	Replication ReplicationConfig `json:"Replication,optional"`
+	TLS         TLSConfig         `json:"TLS,optional"`
+	Auth        AuthConfig        `json:"Auth,optional"`
+	Admin       AdminConfig       `json:"Admin,optional"`
+	Split       SplitConfig       `json:"Split,optional"`
+	Rebalance   RebalanceConfig   `json:"Rebalance,optional"`
+	Election    ElectionConfig    `json:"Election,optional"`
+	// per-store breakers and retry budgets on the RPC clients dialing other stores
+	CircuitBreaker CircuitBreakerConfig `json:"CircuitBreaker,optional"`
+	Retry          RetryConfig          `json:"Retry,optional"`
+	// interceptors run on messages submitted to this store; replicated
+	// messages were already checked by the store that accepted them
+	Moderation moderation.Config `json:"Moderation,optional"`
+	// message.created events of messages submitted to this store
+	Events events.Config `json:"Events,optional"`
+}
+
+type StoreConfig struct {
+	DataDir         string        `json:"DataDir"`
+	MaxCapacity     int64         `json:",default=10737418240"` // bytes
+	TimelineMaxSize int64         `json:",default=1000"`        // messages per block
+	SegmentMaxSize  int64         `json:",optional"`
+	DisableWAL      bool          `json:",optional"`
+	WALSyncPolicy   string        `json:",optional,options=always|interval|none"` // follows Durability when unset, interval when both are
+	WALSyncInterval time.Duration `json:",optional"`
+	WALMaxSize      int64         `json:",optional"`
+	// writes are rejected once blocks and WAL use this share of MaxCapacity
+	CapacityHighWatermark float64 `json:",optional"`
+	// retries carrying the same client message id within DedupTTL return the stored message
+	DedupTTL        time.Duration `json:",optional"`
+	DedupMaxEntries int           `json:",optional"` // per conversation
+	// bloom filters on senders and mentions let sender/mention lookups skip blocks
+	BlockBloomFilters bool `json:",optional"`
+	BloomBitsPerKey   int  `json:",optional"`
+	// encoding of newly written blocks; existing blocks are read in whatever
+	// encoding they were written with and cmd/blockconv rewrites them offline
+	BlockCodec string `json:",default=protobuf,options=protobuf|gob"`
+	// fsync policy for segments, timeline metadata and, unless WALSyncPolicy
+	// is set, the WAL; blocks are synced on every write when unset
+	Durability         string        `json:",optional,options=always|periodic|none"`
+	DurabilityInterval time.Duration `json:",optional"`
+	// writes partially filled blocks to segments on this interval and on
+	// shutdown, so recent messages survive a crash even without the WAL
+	BlockFlushInterval time.Duration `json:",optional"`
+	// re-verifies every segment record on this interval; segments opened from
+	// their index files are verified once in the background after startup
+	ScrubInterval time.Duration `json:",optional"`
+	// full blocks are read from segments on demand; this bounds the bytes of
+	// such blocks kept in memory between reads (64MB when unset)
+	BlockCacheSize int64 `json:",optional"`
+	// copies messages into members' user timelines on this many background
+	// workers instead of on the writer; sends block once a worker's queue is full
+	FanoutWorkers   int `json:",optional"`
+	FanoutQueueSize int `json:",optional"` // per worker, 1024 when unset
+	// conversations reaching this many members stop copying messages into
+	// user timelines; members read them from the conversation when syncing
+	PullFanoutThreshold int `json:",optional"`
+	// where timeline metadata, fan-out state and checkpoints are kept: one file
+	// each, or a single bbolt database, metadata.kv; switching to kv imports the files
+	MetadataBackend string `json:",default=files,options=files|kv"`
+}
+
+// TieringConfig moves full blocks whose newest message is older than After
+// to object storage (a local dir or S3-compatible), keeping a local stub;
+// reads fetch them back on demand
+type TieringConfig struct {
+	Enabled  bool          `json:",optional"`
+	Blob     blob.Config   `json:",optional"`
+	After    time.Duration `json:",default=168h"`
+	Interval time.Duration `json:",default=1h"`
+}
+
+type RegistryConfig struct {
+	Type       string        `json:",default=memory,options=memory|etcd|consul"`
+	Endpoints  []string      `json:",optional"` // etcd endpoints
+	Address    string        `json:",optional"` // consul http address
+	Username   string        `json:",optional"`
+	Password   string        `json:",optional"`
+	Token      string        `json:",optional"` // consul acl token
+	Datacenter string        `json:",optional"`
+	Prefix     string        `json:",optional"`
+	TTL        time.Duration `json:",optional"` // registrations expire this long after the node is gone
+}
+
+// GlobalIndexConfig selects the etcd index when endpoints are set, otherwise
+// the index only lives in this process
+type GlobalIndexConfig struct {
+	Endpoints   []string      `json:",optional"`
+	Username    string        `json:",optional"`
+	Password    string        `json:",optional"`
+	Prefix      string        `json:",optional"`
+	DialTimeout time.Duration `json:",optional"`
+}
+
+type ReplicationConfig struct {
+	Factor     int           `json:",default=1"`
+	Mode       string        `json:",default=async,options=sync|async"`
+	RPCTimeout time.Duration `json:",default=5s"`
+	// hash places timelines on a consistent hash ring, rendezvous uses
+	// weighted HRW hashing which moves fewer timelines on small clusters
+	Router string `json:",default=hash,options=hash|rendezvous"`
+}
+
+// CircuitBreakerConfig opens a store's breaker after FailureThreshold
+// consecutive transport failures; reads then go to its replicas until trial
+// requests succeed again
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `json:",default=5"`
+	OpenTimeout      time.Duration `json:",default=30s"` // fast-fail period before trial requests
+	HalfOpenProbes   int           `json:",default=1"`   // concurrent trial requests while half-open
+	SuccessThreshold int           `json:",default=1"`   // successful trials that close the breaker
+}
+
+// RetryConfig only retries connection failures and 429/502/503/504 answers,
+// with exponential backoff shortened by up to Jitter; each store's retries
+// are capped at BudgetRatio of its requests plus BudgetMinPerSecond
+type RetryConfig struct {
+	MaxRetries         int           `json:",default=3"`
+	InitialBackoff     time.Duration `json:",default=100ms"`
+	MaxBackoff         time.Duration `json:",default=2s"`
+	Multiplier         float64       `json:",default=2"`
+	Jitter             float64       `json:",default=0.5"`
+	RequestBudget      time.Duration `json:",optional"` // total time for a request including retries
+	BudgetRatio        float64       `json:",default=0.1"`
+	BudgetMinPerSecond float64       `json:",default=10"`
+}
+
+// TLSConfig covers both the RPC listener and the clients dialing other
+// stores; a CAFile turns on mutual TLS
+type TLSConfig struct {
+	CertFile   string `json:",optional"`
+	KeyFile    string `json:",optional"`
+	CAFile     string `json:",optional"`
+	ServerName string `json:",optional"`
+}
+
+func (c TLSConfig) enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// AuthConfig authenticates RPCs between stores: secret signs every request
+// with the cluster-wide Secret, mtls trusts the verified client certificate
+// (TLS.CAFile is required) and can be limited to AllowedIdentities
+type AuthConfig struct {
+	Mode              string        `json:",default=none,options=none|secret|mtls"`
+	Secret            string        `json:",optional"`
+	MaxClockSkew      time.Duration `json:",default=5m"`       // signed requests older than this are rejected
+	AllowedIdentities []string      `json:",optional"`         // certificate CN or DNS names
+	MaxBodySize       int64         `json:",default=67108864"` // bytes, larger RPC bodies are refused before they are read
+}
+
+type AdminConfig struct {
+	ListenOn string `json:",optional"` // admin API is disabled when empty
+	Token    string `json:",optional"`
+}
+
+// TenantQuotaConfig limits what one tenant may write to this store; timeline
+// keys of a tenant carry a "<tenant>::" prefix, the empty tenant owns the
+// unprefixed keys. Zero leaves a limit off
+type TenantQuotaConfig struct {
+	Tenant               string  `json:",optional"`
+	MaxBytes             int64   `json:",optional"`
+	MaxMessagesPerSecond float64 `json:",optional"`
+	Burst                int     `json:",optional"` // defaults to one second of messages
+}
+
+// SplitConfig moves writes of conversations whose message or byte rate stays
+// above the limits for a Window onto another store; earlier messages stay
+// where they are and reads stitch the pieces together
+type SplitConfig struct {
+	Enabled              bool          `json:",optional"`
+	CheckInterval        time.Duration `json:",default=1m"`
+	Window               time.Duration `json:",default=1m"`
+	MaxMessagesPerSecond float64       `json:",default=200"`
+	MaxBytesPerSecond    float64       `json:",default=1048576"`
+}
+
+// RebalanceConfig limits the migrations automatic rebalancing starts. Windows
+// are HH:MM-HH:MM in UTC and may cross midnight; when Windows is set
+// rebalancing only starts migrations inside them, never inside BlackoutWindows
+type RebalanceConfig struct {
+	MaxConcurrentMigrations   int           `json:",default=2"` // 0 means unlimited
+	MaxTransferBytesPerSecond int64         `json:",optional"`  // 0 means unlimited
+	TimelineCooldown          time.Duration `json:",default=1h"`
+	Windows                   []string      `json:",optional"`
+	BlackoutWindows           []string      `json:",optional"`
+	// load samples kept to judge whether rebalancing helps, served by the
+	// admin API under /admin/stats/history and /admin/stats/rebalances
+	StatsInterval    time.Duration `json:",default=1m"`
+	StatsHistorySize int           `json:",default=1440"`
+}
+
+// ElectionConfig elects one store as coordinator; only it runs automatic
+// rebalancing and drains stores. The election holds a lease in the etcd
+// registry, with other registries every store coordinates on its own
+type ElectionConfig struct {
+	Prefix string        `json:",optional"`
+	TTL    time.Duration `json:",default=10s"` // a dead coordinator is replaced after this long
+}
+
+var configFile = flag.String("f", "etc/store.yaml", "the config file")
+
+func main() {
+	flag.Parse()
+
+	var c StoreNodeConfig
+	conf.MustLoad(*configFile, &c)
+
+	node, err := newStoreNode(c)
+	logx.Must(err)
+	if err := node.Start(); err != nil {
+		node.Stop(context.Background())
+		logx.Must(err)
+	}
+
+	quit := make(chan os.Signal, 2)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	logx.Infof("store %s: received %s, shutting down", c.StoreID, sig)
+
+	// a second signal cuts the drain short; data is still flushed
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sig := <-quit
+		logx.Infof("store %s: received %s, skipping the remaining drain", c.StoreID, sig)
+		cancel()
+	}()
+	if err := node.Stop(ctx); err != nil {
+		logx.Errorf("store %s: shutdown: %v", c.StoreID, err)
+		os.Exit(1)
+	}
+	logx.Infof("store %s: stopped", c.StoreID)
+}
@@ -0,0 +1,571 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"imy/pkg/blob"
+	"imy/pkg/events"
+	"imy/pkg/moderation"
+	"imy/pkg/storage"
+)
+
+// storeNode wires a local Store into the cluster: RPC server, registry
+// membership, replication and the optional admin API
+type storeNode struct {
+	c        StoreNodeConfig
+	store    *storage.Store
+	registry storage.StoreRegistry
+	index    storage.GlobalIndexManager
+
+	distributed *storage.DistributedStorageManager
+	replication *storage.ReplicationManager
+	rpcServer   *storage.HTTPStoreRPCServer
+	routerSync  *storage.StoreRouterSync
+	discovery   *storage.StoreDiscoveryClient
+	admin       *storage.AdminServer
+	splitter    *storage.TimelineSplitter
+	shards      *storage.TimelineShardManager
+	migrations  *storage.TimelineMigrationManager
+	elector     storage.LeaderElector
+	events      *events.Bus
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newStoreNode(c StoreNodeConfig) (*storeNode, error) {
+	if c.StoreID == "" {
+		return nil, errors.New("StoreID is required")
+	}
+	if c.Store.DataDir == "" {
+		return nil, errors.New("Store.DataDir is required")
+	}
+	if c.Admin.ListenOn != "" && c.Admin.Token == "" {
+		return nil, errors.New("Admin.Token is required when the admin API is enabled")
+	}
+	if c.Auth.Mode == "secret" && c.Auth.Secret == "" {
+		return nil, errors.New("Auth.Secret is required for secret authentication")
+	}
+	if c.Auth.Mode == "mtls" && c.TLS.CAFile == "" {
+		return nil, errors.New("TLS.CAFile is required for mtls authentication")
+	}
+
+	coldStore, err := newColdStore(c.Tiering)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := storage.NewStore(&storage.StoreConfig{
+		StoreID:         c.StoreID,
+		MaxCapacity:     c.Store.MaxCapacity,
+		TimelineMaxSize: c.Store.TimelineMaxSize,
+		DataDir:         c.Store.DataDir,
+		SegmentMaxSize:  c.Store.SegmentMaxSize,
+		DisableWAL:      c.Store.DisableWAL,
+		WALSyncPolicy:   storage.WALSyncPolicy(c.Store.WALSyncPolicy),
+		WALSyncInterval: c.Store.WALSyncInterval,
+		WALMaxSize:      c.Store.WALMaxSize,
+
+		CapacityHighWatermark: c.Store.CapacityHighWatermark,
+		DedupTTL:              c.Store.DedupTTL,
+		DedupMaxEntries:       c.Store.DedupMaxEntries,
+
+		BlockBloomFilters: c.Store.BlockBloomFilters,
+		BloomBitsPerKey:   c.Store.BloomBitsPerKey,
+
+		BlockCodec: storage.BlockCodec(c.Store.BlockCodec),
+
+		Durability:         storage.DurabilityPolicy(c.Store.Durability),
+		DurabilityInterval: c.Store.DurabilityInterval,
+		BlockFlushInterval: c.Store.BlockFlushInterval,
+		ScrubInterval:      c.Store.ScrubInterval,
+		BlockCacheSize:     c.Store.BlockCacheSize,
+		FanoutWorkers:      c.Store.FanoutWorkers,
+		FanoutQueueSize:    c.Store.FanoutQueueSize,
+
+		PullFanoutThreshold: c.Store.PullFanoutThreshold,
+		MetadataBackend:     storage.MetadataBackendType(c.Store.MetadataBackend),
+
+		ColdStore:    coldStore,
+		TierAfter:    c.Tiering.After,
+		TierInterval: c.Tiering.Interval,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("open store: %w", err)
+	}
+
+	for _, tenant := range c.Tenants {
+		if err := storage.ValidateTenantID(tenant); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("Tenants: %w", err)
+		}
+	}
+	for _, quota := range c.TenantQuotas {
+		if err := store.SetTenantQuota(quota.Tenant, storage.TenantQuota{
+			MaxBytes:             quota.MaxBytes,
+			MaxMessagesPerSecond: quota.MaxMessagesPerSecond,
+			Burst:                quota.Burst,
+		}); err != nil {
+			store.Close()
+			return nil, fmt.Errorf("TenantQuotas %q: %w", quota.Tenant, err)
+		}
+	}
+
+	pipeline, err := moderation.New(c.Moderation)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("Moderation: %w", err)
+	}
+	store.SetModeration(pipeline)
+
+	if c.Events.Source == "" {
+		c.Events.Source = c.StoreID
+	}
+	bus, err := events.New(c.Events)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("Events: %w", err)
+	}
+	store.SetEventBus(bus)
+
+	n := &storeNode{c: c, store: store, events: bus}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	if err := n.setup(); err != nil {
+		n.Stop(context.Background())
+		return nil, err
+	}
+	return n, nil
+}
+
+func (n *storeNode) setup() error {
+	c := n.c
+
+	registry, err := newRegistry(c.Registry)
+	if err != nil {
+		return err
+	}
+	n.registry = registry
+
+	if len(c.GlobalIndex.Endpoints) > 0 {
+		index, err := storage.NewEtcdGlobalIndex(&storage.EtcdGlobalIndexConfig{
+			Endpoints:   c.GlobalIndex.Endpoints,
+			Username:    c.GlobalIndex.Username,
+			Password:    c.GlobalIndex.Password,
+			Prefix:      c.GlobalIndex.Prefix,
+			DialTimeout: c.GlobalIndex.DialTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("open global index: %w", err)
+		}
+		n.index = index
+	} else {
+		n.index = storage.NewInMemoryGlobalIndex()
+	}
+	n.reconcileIndex()
+
+	var serverTLS, clientTLS *tls.Config
+	if c.TLS.enabled() {
+		tlsConfig := &storage.TLSConfig{
+			CertFile:   c.TLS.CertFile,
+			KeyFile:    c.TLS.KeyFile,
+			CAFile:     c.TLS.CAFile,
+			ServerName: c.TLS.ServerName,
+		}
+		if serverTLS, err = tlsConfig.ServerTLSConfig(); err != nil {
+			return err
+		}
+		if clientTLS, err = tlsConfig.ClientTLSConfig(); err != nil {
+			return err
+		}
+	}
+
+	policy := storage.DefaultShardPolicy()
+	policy.ReplicationFactor = c.Replication.Factor
+	policy.ReplicationMode = storage.ReplicationMode(c.Replication.Mode)
+	policy.MaxConcurrentMigrations = c.Rebalance.MaxConcurrentMigrations
+	policy.MaxTransferBytesPerSecond = c.Rebalance.MaxTransferBytesPerSecond
+	policy.TimelineCooldown = c.Rebalance.TimelineCooldown
+	if policy.RebalanceWindows, err = parseWindows(c.Rebalance.Windows); err != nil {
+		return fmt.Errorf("Rebalance.Windows: %w", err)
+	}
+	if policy.BlackoutWindows, err = parseWindows(c.Rebalance.BlackoutWindows); err != nil {
+		return fmt.Errorf("Rebalance.BlackoutWindows: %w", err)
+	}
+
+	// the router is kept in step with the registry, including this store
+	router, err := newRouter(c.Replication, policy.LoadBalanceThreshold)
+	if err != nil {
+		return err
+	}
+	routerManager := storage.NewRouterManager()
+	routerManager.RegisterRouter(c.Replication.Router, router)
+	n.routerSync = storage.NewStoreRouterSync(registry, routerManager)
+
+	breakers := storage.NewCircuitBreakerGroup(storage.CircuitBreakerConfig{
+		FailureThreshold: c.CircuitBreaker.FailureThreshold,
+		OpenTimeout:      c.CircuitBreaker.OpenTimeout,
+		HalfOpenProbes:   c.CircuitBreaker.HalfOpenProbes,
+		SuccessThreshold: c.CircuitBreaker.SuccessThreshold,
+	})
+	pool := storage.NewStoreRPCClientPool(c.Replication.RPCTimeout)
+	pool.SetTLSConfig(clientTLS)
+	pool.SetCircuitBreakers(breakers)
+	pool.SetRetryPolicy(storage.RetryPolicy{
+		MaxRetries:                c.Retry.MaxRetries,
+		InitialBackoff:            c.Retry.InitialBackoff,
+		MaxBackoff:                c.Retry.MaxBackoff,
+		Multiplier:                c.Retry.Multiplier,
+		Jitter:                    c.Retry.Jitter,
+		RequestBudget:             c.Retry.RequestBudget,
+		BudgetRatio:               c.Retry.BudgetRatio,
+		BudgetMinRetriesPerSecond: c.Retry.BudgetMinPerSecond,
+	})
+	if c.Auth.Mode == "secret" {
+		pool.SetRequestSigner(storage.NewRPCSigner(c.StoreID, []byte(c.Auth.Secret)))
+	}
+
+	n.distributed = storage.NewDistributedStorageManager(n.store, n.index, routerManager, registry, pool, c.StoreID)
+	accessor := n.distributed.GetCrossStoreAccessor()
+	n.replication = storage.NewReplicationManager(n.store, router, registry, n.index, pool, policy)
+	accessor.SetReplicationManager(n.replication)
+
+	// One participant handler serves both this node's coordinator and
+	// transactions that peers coordinate over RPC
+	participant := storage.NewDefaultTransactionHandler(n.store, n.index, pool, c.StoreID)
+	n.distributed.RegisterTransactionHandler(c.StoreID, participant)
+
+	n.rpcServer = storage.NewHTTPStoreRPCServer(n.store)
+	n.rpcServer.SetTLSConfig(serverTLS)
+	n.rpcServer.SetTransactionHandler(participant)
+	n.rpcServer.SetMaxRequestBodySize(c.Auth.MaxBodySize)
+	switch c.Auth.Mode {
+	case "secret":
+		n.rpcServer.SetAuthenticator(storage.NewSharedSecretAuthenticator([]byte(c.Auth.Secret), c.Auth.MaxClockSkew))
+	case "mtls":
+		n.rpcServer.SetAuthenticator(storage.NewTLSIdentityAuthenticator(c.Auth.AllowedIdentities))
+	}
+
+	n.discovery = storage.NewStoreDiscoveryClient(registry, &storage.StoreInfo{
+		ID:       c.StoreID,
+		Address:  n.advertise(),
+		Metadata: n.metadata(),
+	})
+
+	// new timelines are placed where the shard manager recommends, and
+	// decommissioning a store migrates its timelines the same way
+	migrations := storage.NewTimelineMigrationManager(n.store, n.index, pool, accessor, n.distributed.GetLockManager(), c.StoreID)
+	shards := storage.NewTimelineShardManager(n.index, registry, routerManager, migrations)
+	if err := shards.UpdateShardPolicy(policy); err != nil {
+		return err
+	}
+	n.distributed.SetShardManager(shards)
+	n.distributed.SetMigrationManager(migrations)
+	shards.SetStatsHistorySize(c.Rebalance.StatsHistorySize)
+	n.shards = shards
+	n.migrations = migrations
+
+	// only the coordinator starts rebalancing migrations and decommissions
+	n.elector = newLeaderElector(c, registry)
+	shards.SetLeaderElector(n.elector)
+	n.distributed.SetLeaderElector(n.elector)
+
+	if c.Split.Enabled {
+		splits, ok := n.index.(storage.TimelineSplitIndex)
+		if !ok {
+			return errors.New("Split needs a global index that stores timeline splits")
+		}
+		detector := storage.NewHotTimelineDetector(storage.HotTimelineConfig{
+			Window:               c.Split.Window,
+			MaxMessagesPerSecond: c.Split.MaxMessagesPerSecond,
+			MaxBytesPerSecond:    c.Split.MaxBytesPerSecond,
+		})
+		accessor.SetHotTimelineDetector(detector)
+		n.splitter = storage.NewTimelineSplitter(accessor, splits, shards, detector)
+	}
+
+	if c.Admin.ListenOn != "" {
+		n.admin = storage.NewAdminServer(storage.AdminDependencies{
+			Registry:         registry,
+			GlobalIndex:      n.index,
+			ShardManager:     shards,
+			MigrationManager: migrations,
+			RouterManager:    routerManager,
+			Coordinator:      n.distributed.GetTransactionCoordinator(),
+			LockManager:      n.distributed.GetLockManager(),
+			CircuitBreakers:  breakers,
+			Store:            n.store,
+			LeaderElector:    n.elector,
+		}, c.Admin.Token)
+		n.admin.SetTLSConfig(serverTLS)
+	}
+	return nil
+}
+
+// Start serves RPC before registering so peers never see an address that
+// refuses connections
+func (n *storeNode) Start() error {
+	if err := n.replication.Start(n.ctx); err != nil {
+		return fmt.Errorf("start replication: %w", err)
+	}
+	if err := n.rpcServer.Start(n.c.ListenOn); err != nil {
+		return fmt.Errorf("start rpc server: %w", err)
+	}
+	logx.Infof("store %s: rpc listening on %s", n.c.StoreID, n.c.ListenOn)
+
+	if n.admin != nil {
+		if err := n.admin.Start(n.c.Admin.ListenOn); err != nil {
+			return fmt.Errorf("start admin server: %w", err)
+		}
+		logx.Infof("store %s: admin api listening on %s", n.c.StoreID, n.c.Admin.ListenOn)
+	}
+
+	if err := n.routerSync.Start(n.ctx); err != nil {
+		return err
+	}
+	if n.splitter != nil {
+		if err := n.splitter.Start(n.ctx, n.c.Split.CheckInterval); err != nil {
+			return err
+		}
+	}
+	if err := n.shards.StartStatsSampling(n.ctx, n.c.Rebalance.StatsInterval); err != nil {
+		return err
+	}
+	if err := n.discovery.Start(n.ctx); err != nil {
+		return err
+	}
+	if err := n.elector.Start(n.ctx); err != nil {
+		return fmt.Errorf("start coordinator election: %w", err)
+	}
+	// migrations the last shutdown interrupted continue from their checkpoints
+	if resumed := n.migrations.ResumeHandedOff(n.ctx); len(resumed) > 0 {
+		logx.Infof("store %s: resumed %d migrations handed off by the last shutdown", n.c.StoreID, len(resumed))
+	}
+	return nil
+}
+
+// Stop leaves the registry first so no new traffic is routed here, then
+// drains in-flight RPCs and migrations until the drain deadline, releases
+// locks and flushes open blocks, metadata and checkpoints before closing the
+// store; see storage.ShutdownManager for the order
+func (n *storeNode) Stop(ctx context.Context) error {
+	deps := storage.ShutdownDependencies{
+		Discovery:  n.discovery,
+		Migrations: n.migrations,
+		Store:      n.store,
+		Closers: []storage.ShutdownHook{
+			// undelivered events stay in the spool directory for the next start
+			{Name: "close event bus", Run: func(context.Context) error { return n.events.Close() }},
+			{Name: "close global index", Run: func(context.Context) error {
+				if closer, ok := n.index.(interface{ Close() error }); ok {
+					return closer.Close()
+				}
+				return nil
+			}},
+			{Name: "close registry", Run: func(context.Context) error {
+				switch closer := n.registry.(type) {
+				case interface{ Close() error }:
+					return closer.Close()
+				case interface{ Close() }:
+					closer.Close()
+				}
+				return nil
+			}},
+		},
+	}
+	if n.admin != nil {
+		deps.Servers = append(deps.Servers, storage.ShutdownHook{Name: "stop admin server", Run: n.admin.Stop})
+	}
+	if n.rpcServer != nil {
+		deps.Servers = append(deps.Servers, storage.ShutdownHook{Name: "stop rpc server", Run: n.rpcServer.Stop})
+	}
+	if n.elector != nil {
+		// after draining so migrations started as coordinator are handed off
+		// rather than cut by the end of the term
+		deps.Background = append(deps.Background, storage.ShutdownHook{Name: "resign coordinator", Run: func(context.Context) error {
+			return n.elector.Stop()
+		}})
+	}
+	deps.Background = append(deps.Background, storage.ShutdownHook{Name: "stop background tasks", Run: func(context.Context) error {
+		if n.routerSync != nil {
+			n.routerSync.Stop()
+		}
+		if n.splitter != nil {
+			// not running when Start failed early
+			n.splitter.Stop()
+		}
+		if n.shards != nil {
+			// not running when Start failed early
+			n.shards.StopStatsSampling()
+		}
+		if n.replication != nil {
+			// not running when Start failed early
+			n.replication.Stop()
+		}
+		if n.cancel != nil {
+			n.cancel()
+		}
+		return nil
+	}})
+	if n.distributed != nil {
+		deps.LockManager = n.distributed.GetLockManager()
+		deps.Background = append(deps.Background, storage.ShutdownHook{Name: "close transaction coordinator", Run: func(context.Context) error {
+			return n.distributed.Close()
+		}})
+	}
+
+	shutdown := storage.NewShutdownManager(n.c.StoreID, storage.ShutdownConfig{
+		DrainTimeout:    n.c.ShutdownTimeout,
+		DeregisterDelay: n.c.DeregisterDelay,
+	}, deps)
+	report, err := shutdown.Shutdown(ctx)
+	logx.Infof("store %s: shutdown took %s, flushed %d open blocks, released %d locks, handed off %d migrations",
+		n.c.StoreID, report.Duration, report.FlushedBlocks, report.ReleasedLocks, len(report.HandedOff))
+	return err
+}
+
+// reconcileIndex registers timelines found on disk that the global index
+// does not know about. Mismatches are only logged; startup goes on either way.
+func (n *storeNode) reconcileIndex() {
+	report, err := n.store.ReconcileGlobalIndex(n.ctx, n.index)
+	if err != nil {
+		logx.Errorf("store %s: reconcile global index: %v", n.c.StoreID, err)
+		return
+	}
+	if len(report.OrphanedBlocks) > 0 {
+		logx.Errorf("store %s: %d blocks belong to no timeline: %v", n.c.StoreID, len(report.OrphanedBlocks), report.OrphanedBlocks)
+	}
+	if len(report.IndexRegistered) > 0 || len(report.IndexStale) > 0 {
+		logx.Infof("store %s: registered %d timelines in the global index, %d registered timelines have no local blocks",
+			n.c.StoreID, len(report.IndexRegistered), len(report.IndexStale))
+	}
+}
+
+// metadata is published with the registration so schedulers can place
+// timelines by region, tier, capacity and dedicated tenants
+func (n *storeNode) metadata() map[string]interface{} {
+	metadata := map[string]interface{}{
+		storage.StoreMetadataCapacity: n.c.Store.MaxCapacity,
+		"replicationFactor":           n.c.Replication.Factor,
+		"tls":                         n.c.TLS.enabled(),
+	}
+	if n.c.Region != "" {
+		metadata[storage.StoreMetadataRegion] = n.c.Region
+	}
+	if n.c.Tier != "" {
+		metadata[storage.StoreMetadataTier] = n.c.Tier
+	}
+	if len(n.c.Tenants) > 0 {
+		metadata[storage.StoreMetadataTenants] = strings.Join(n.c.Tenants, ",")
+	}
+	return metadata
+}
+
+// advertise returns the RPC address registered for this store
+func (n *storeNode) advertise() string {
+	if n.c.Advertise != "" {
+		return n.c.Advertise
+	}
+	scheme := "http"
+	if n.c.TLS.enabled() {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(n.c.ListenOn)
+	if err != nil {
+		return scheme + "://" + n.c.ListenOn
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		if hostname, err := os.Hostname(); err == nil {
+			host = hostname
+		}
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
+
+func newRouter(c ReplicationConfig, loadThreshold float64) (storage.TimelineRouter, error) {
+	switch c.Router {
+	case "", storage.RouterNameConsistentHash:
+		return storage.NewConsistentHashRouter(c.Factor, 100, loadThreshold), nil
+	case storage.RouterNameRendezvous:
+		return storage.NewRendezvousRouter(c.Factor, loadThreshold), nil
+	default:
+		return nil, fmt.Errorf("unsupported router %q", c.Router)
+	}
+}
+
+// newColdStore returns nil when tiering is off
+func newColdStore(c TieringConfig) (blob.Store, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	store, err := blob.New(c.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("Tiering: %w", err)
+	}
+	return store, nil
+}
+
+func newRegistry(c RegistryConfig) (storage.StoreRegistry, error) {
+	switch c.Type {
+	case "", "memory":
+		logx.Info("store registry is in memory, other nodes will not see this store")
+		return storage.NewInMemoryRegistry(), nil
+	case "etcd":
+		registry, err := storage.NewEtcdRegistry(&storage.EtcdRegistryConfig{
+			Endpoints: c.Endpoints,
+			Username:  c.Username,
+			Password:  c.Password,
+			Prefix:    c.Prefix,
+			TTL:       c.TTL,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("open etcd registry: %w", err)
+		}
+		return registry, nil
+	case "consul":
+		return storage.NewConsulRegistry(&storage.ConsulRegistryConfig{
+			Address:    c.Address,
+			Token:      c.Token,
+			Datacenter: c.Datacenter,
+			Prefix:     c.Prefix,
+			TTL:        c.TTL,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported registry type %q", c.Type)
+	}
+}
+
+// newLeaderElector campaigns in the etcd registry; other registries have no
+// shared lease, so the store elects itself and coordinates on its own
+func newLeaderElector(c StoreNodeConfig, registry storage.StoreRegistry) storage.LeaderElector {
+	if etcd, ok := registry.(*storage.EtcdRegistry); ok {
+		return storage.NewEtcdLeaderElector(etcd.Client(), c.Election.Prefix, c.StoreID, c.Election.TTL)
+	}
+	if c.Registry.Type == "consul" {
+		logx.Info("coordinator election needs the etcd registry, this store rebalances and drains stores on its own")
+	}
+	return storage.NewInMemoryLeaderElector(storage.NewInMemoryElection(), c.StoreID, c.Election.TTL)
+}
+
+// parseWindows parses HH:MM-HH:MM windows; the times themselves are checked
+// when the shard policy is applied
+func parseWindows(specs []string) ([]storage.MaintenanceWindow, error) {
+	windows := make([]storage.MaintenanceWindow, 0, len(specs))
+	for _, spec := range specs {
+		start, end, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, fmt.Errorf("window %q is not HH:MM-HH:MM", spec)
+		}
+		windows = append(windows, storage.MaintenanceWindow{
+			Start: strings.TrimSpace(start),
+			End:   strings.TrimSpace(end),
+		})
+	}
+	return windows, nil
+}
@@ -7,6 +7,7 @@ import (
 	"imy/internal/dao"
 	"imy/internal/handler"
 	"imy/internal/svc"
+	"imy/pkg/identity"
 	"imy/pkg/utils"
 
 	"github.com/zeromicro/go-zero/core/conf"
@@ -26,13 +27,22 @@ func main() {
 	server := rest.MustNewServer(c.RestConf)
 	defer server.Stop()
 
+	// 只接受网关签名的身份请求头
+	if c.Identity.SigningSecret != "" {
+		protected := append([]string{"uuid", "scope", "sid"}, c.Identity.Headers...)
+		server.Use(identity.Middleware([]byte(c.Identity.SigningSecret), c.Identity.MaxSkew, protected))
+	}
+
 	ctx := svc.NewServiceContext(c)
+	// 拒绝已吊销会话的请求
+	server.Use(ctx.Sessions.Middleware)
 	handler.RegisterHandlers(server, ctx)
 
 	// swagger
 	handler.RegisterSwaggerHandlers(server, ctx)
 
 	// ws
+	handler.RegisterWsHandlers(server, ctx)
 	handler.RegisterWsHandlersV2(server, ctx)
 
 	ServerInit(ctx)
@@ -1,6 +1,15 @@
 package config
 
-import "github.com/zeromicro/go-zero/rest"
+import (
+	"time"
+
+	"imy/pkg/blob"
+	"imy/pkg/events"
+	"imy/pkg/jwt"
+	"imy/pkg/moderation"
+
+	"github.com/zeromicro/go-zero/rest"
+)
 
 type Config struct {
 	rest.RestConf
@@ -12,11 +21,33 @@ type Config struct {
 	WhiteList   []string
 	Redis       Redis
 	FileServers []FileServer
+	Attachment  Attachment        `json:",optional"`
+	WsJournal   WsJournal         `json:",optional"`
+	Moderation  moderation.Config `json:",optional"`
+	Events      events.Config     `json:",optional"`
+	// 机器人等服务账号，通过 /api/auth/serviceToken 用密钥换取令牌
+	ServiceAccounts []ServiceAccount `json:",optional"`
+	// 校验网关注入的身份请求头签名
+	Identity Identity `json:",optional"`
 }
 
 type Auth struct {
 	AccessSecret string `json:"AccessSecret"`
 	AccessExpire int64  `json:"AccessExpire"`
+	AccessTTL    int64  `json:"AccessTTL,default=900"`     // 邮箱登录及刷新签发的访问令牌有效期（秒）
+	RefreshTTL   int64  `json:"RefreshTTL,default=604800"` // 刷新令牌有效期（秒），同时作为登录会话的有效期
+	MaxSessions  int    `json:"MaxSessions,default=10"`    // 每个用户同时保留的登录会话数，超出时淘汰最久未活跃的
+	// 访问令牌的签名密钥集，支持RS256/ES256与轮换；未配置时用AccessSecret签名
+	// 刷新令牌与v2接口仍使用AccessSecret
+	Keys jwt.KeySetConfig `json:"Keys,optional"`
+}
+
+// ServiceAccount 服务账号，令牌带有 bot 范围，网关只允许其访问机器人接口
+type ServiceAccount struct {
+	ClientId   string // 同时作为账号的用户UUID，机器人以此身份加入会话
+	Name       string // 机器人昵称
+	SecretHash string // 密钥的bcrypt哈希，明文只由机器人保存
+	TokenTTL   int64  `json:",default=3600"` // 令牌有效期（秒），过期后用密钥重新换取
 }
 
 type Swagger struct {
@@ -40,3 +71,25 @@ type FileServer struct {
 	ApiPrefix string
 	Dir       string
 }
+
+// Attachment 聊天附件配置
+type Attachment struct {
+	MaxSize int64       `json:",default=20971520"` // 单个附件最大字节数
+	Blob    blob.Config `json:",optional"`
+}
+
+// Identity 网关身份请求头的签名校验，SigningSecret与网关IdentityHeaders.SigningSecret一致
+// 开启后带有uuid、scope、sid或Headers中请求头的请求必须有网关的有效签名，否则返回401
+type Identity struct {
+	SigningSecret string        `json:",optional"`   // 为空时不校验
+	MaxSkew       time.Duration `json:",default=1m"` // 签名时间戳允许的偏差
+	Headers       []string      `json:",optional"`   // 除uuid、scope与sid外网关注入的请求头，如X-User-Nickname
+}
+
+// WsJournal WebSocket事件日志配置，v2连接断线重连后从日志补发错过的事件
+type WsJournal struct {
+	Disable   bool          `json:",optional"`                 // 关闭后不记录事件，续传请求只返回空结果
+	Dir       string        `json:",default=./work/wsjournal"` // 日志数据目录
+	MaxReplay int           `json:",default=500"`              // 单次续传最多补发的事件数
+	Retention time.Duration `json:",default=72h"`              // 事件保留时长
+}
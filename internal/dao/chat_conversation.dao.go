@@ -47,14 +47,15 @@ func (c *chatConversation) GetList(ctx context.Context, id []uint32, withDeleted
 type ListChatConversationParams struct {
 	dbgen.Pager
 
-	Type          int8   // optional
-	PrivateKey    string // optional
-	CreateUuid    string // optional, likely
-	Name          string // optional, likely
-	MemberCount   uint32 // optional
-	LastMessageId uint64 // optional
-	Avatar        string // optional, likely
-	Extra         string // optional, likely
+	Type             int8   // optional
+	PrivateKey       string // optional
+	CreateUuid       string // optional, likely
+	Name             string // optional, likely
+	MemberCount      uint32 // optional
+	LastMessageId    uint64 // optional
+	Avatar           string // optional, likely
+	Extra            string // optional, likely
+	AnnouncementOnly bool   // optional
 
 	Deleted bool // optional
 }
@@ -72,6 +73,7 @@ func (c *chatConversation) List(ctx context.Context, params *ListChatConversatio
 		Scopes(dbgen.Cond(!reflect.ValueOf(params.LastMessageId).IsZero(), "last_message_id = ?", params.LastMessageId)).
 		Scopes(dbgen.Cond(!reflect.ValueOf(params.Avatar).IsZero(), "avatar like ?", "%"+params.Avatar+"%")).
 		Scopes(dbgen.Cond(!reflect.ValueOf(params.Extra).IsZero(), "extra like ?", "%"+params.Extra+"%")).
+		Scopes(dbgen.Cond(!reflect.ValueOf(params.AnnouncementOnly).IsZero(), "announcement_only = ?", params.AnnouncementOnly)).
 		Order("id desc")
 
 	total, err = dbgen.FindAndCountTransaction(tx, &list)
@@ -36,6 +36,7 @@ func newChatConversation(db *gorm.DB, opts ...gen.DOOption) chatConversation {
 	_chatConversation.LastMessageID = field.NewUint64(tableName, "last_message_id")
 	_chatConversation.Avatar = field.NewString(tableName, "avatar")
 	_chatConversation.Extra = field.NewString(tableName, "extra")
+	_chatConversation.AnnouncementOnly = field.NewBool(tableName, "announcement_only")
 	_chatConversation.CreatedAt = field.NewTime(tableName, "created_at")
 	_chatConversation.UpdatedAt = field.NewTime(tableName, "updated_at")
 	_chatConversation.DeletedAt = field.NewField(tableName, "deleted_at")
@@ -49,19 +50,20 @@ func newChatConversation(db *gorm.DB, opts ...gen.DOOption) chatConversation {
 type chatConversation struct {
 	chatConversationDo chatConversationDo
 
-	ALL           field.Asterisk
-	ID            field.Uint32 // 主键id
-	Type          field.Int8   // 会话类型，1表示单聊，2表示群聊
-	PrivateKey    field.String // 会话标识
-	CreateUUID    field.String // 创建方
-	Name          field.String // 会话名称,群聊使用,单聊可以为空
-	MemberCount   field.Uint32 // 成员数
-	LastMessageID field.Uint64 // 最后一条消息的id，用于展示
-	Avatar        field.String // 会话头像（群聊）
-	Extra         field.String // 扩展信息（置顶、公告等）
-	CreatedAt     field.Time   // 数据插入时间
-	UpdatedAt     field.Time   // 数据更新时间,最后一次登陆时间
-	DeletedAt     field.Field  // 删除标记
+	ALL              field.Asterisk
+	ID               field.Uint32 // 主键id
+	Type             field.Int8   // 会话类型，1表示单聊，2表示群聊
+	PrivateKey       field.String // 会话标识
+	CreateUUID       field.String // 创建方
+	Name             field.String // 会话名称,群聊使用,单聊可以为空
+	MemberCount      field.Uint32 // 成员数
+	LastMessageID    field.Uint64 // 最后一条消息的id，用于展示
+	Avatar           field.String // 会话头像（群聊）
+	Extra            field.String // 扩展信息（置顶、公告等）
+	AnnouncementOnly field.Bool   // 仅群主和管理员可发言
+	CreatedAt        field.Time   // 数据插入时间
+	UpdatedAt        field.Time   // 数据更新时间,最后一次登陆时间
+	DeletedAt        field.Field  // 删除标记
 
 	fieldMap map[string]field.Expr
 }
@@ -87,6 +89,7 @@ func (c *chatConversation) updateTableName(table string) *chatConversation {
 	c.LastMessageID = field.NewUint64(table, "last_message_id")
 	c.Avatar = field.NewString(table, "avatar")
 	c.Extra = field.NewString(table, "extra")
+	c.AnnouncementOnly = field.NewBool(table, "announcement_only")
 	c.CreatedAt = field.NewTime(table, "created_at")
 	c.UpdatedAt = field.NewTime(table, "updated_at")
 	c.DeletedAt = field.NewField(table, "deleted_at")
@@ -118,7 +121,7 @@ func (c *chatConversation) GetFieldByName(fieldName string) (field.OrderExpr, bo
 }
 
 func (c *chatConversation) fillFieldMap() {
-	c.fieldMap = make(map[string]field.Expr, 12)
+	c.fieldMap = make(map[string]field.Expr, 13)
 	c.fieldMap["id"] = c.ID
 	c.fieldMap["type"] = c.Type
 	c.fieldMap["private_key"] = c.PrivateKey
@@ -128,6 +131,7 @@ func (c *chatConversation) fillFieldMap() {
 	c.fieldMap["last_message_id"] = c.LastMessageID
 	c.fieldMap["avatar"] = c.Avatar
 	c.fieldMap["extra"] = c.Extra
+	c.fieldMap["announcement_only"] = c.AnnouncementOnly
 	c.fieldMap["created_at"] = c.CreatedAt
 	c.fieldMap["updated_at"] = c.UpdatedAt
 	c.fieldMap["deleted_at"] = c.DeletedAt
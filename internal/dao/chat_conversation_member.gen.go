@@ -55,7 +55,7 @@ type chatConversationMember struct {
 	ID                field.Uint32 // 主键id
 	ConversationID    field.Uint32 // 会话id
 	UserUUID          field.String // 成员uuid
-	Role              field.Int8   // 成员角色，1表示普通成员，2表示管理员，群聊使用
+	Role              field.Int8   // 成员角色，1表示普通成员，2表示管理员，3表示群主，群聊使用
 	LastReadMessageID field.Uint64 // 最后已读消息ID
 	LastReadAt        field.Time   // 最后已读时间
 	MuteUntil         field.Time   // 免打扰截止时间
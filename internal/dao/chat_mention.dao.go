@@ -0,0 +1,94 @@
+// Code generated by go-exp. DO NOT EDIT.
+
+package dao
+
+import (
+	"context"
+	"reflect"
+
+	"imy/internal/dao/model"
+
+	"imy/pkg/dbgen"
+
+	"gorm.io/gorm"
+)
+
+func (c *chatMention) DB() *gorm.DB {
+	return c.chatMentionDo.DO.UnderlyingDB()
+}
+
+func (c *chatMention) Get(ctx context.Context, id uint64, withDeleted ...bool) (result *model.ChatMention, err error) {
+	err = c.DB().WithContext(ctx).Table(model.TableNameChatMention).
+		Scopes(dbgen.WithDeletedList(withDeleted)).
+		Where("id = ?", id).
+		First(&result).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *chatMention) GetList(ctx context.Context, id []uint64, withDeleted ...bool) (list []*model.ChatMention, err error) {
+	err = c.DB().WithContext(ctx).Table(model.TableNameChatMention).
+		Scopes(dbgen.WithDeletedList(withDeleted)).
+		Where("id IN ?", id).
+		Find(&list).
+		Error
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// ListChatMentionParams represents the params to list models
+type ListChatMentionParams struct {
+	dbgen.Pager
+
+	ConversationId uint32 // optional
+	MessageId      uint64 // optional
+	MentionUuid    string // optional, likely
+	SendUuid       string // optional, likely
+
+	Deleted bool // optional
+}
+
+// List returns the specified models from database by params
+func (c *chatMention) List(ctx context.Context, params *ListChatMentionParams) (list []*model.ChatMention, total int64, err error) {
+	tx := c.DB().WithContext(ctx).Table(model.TableNameChatMention).
+		Scopes(dbgen.WithDeleted(params.Deleted)).
+		Scopes(dbgen.Paginate(params.Pager)).
+		Scopes(dbgen.Cond(!reflect.ValueOf(params.ConversationId).IsZero(), "conversation_id = ?", params.ConversationId)).
+		Scopes(dbgen.Cond(!reflect.ValueOf(params.MessageId).IsZero(), "message_id = ?", params.MessageId)).
+		Scopes(dbgen.Cond(!reflect.ValueOf(params.MentionUuid).IsZero(), "mention_uuid like ?", "%"+params.MentionUuid+"%")).
+		Scopes(dbgen.Cond(!reflect.ValueOf(params.SendUuid).IsZero(), "send_uuid like ?", "%"+params.SendUuid+"%")).
+		Order("id desc")
+
+	total, err = dbgen.FindAndCountTransaction(tx, &list)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+func (c *chatMention) Update(ctx context.Context, model *model.ChatMention, cols ...string) error {
+	return c.DB().WithContext(ctx).
+		Model(model).
+		Select(cols).
+		Updates(model).
+		Error
+}
+
+func (c *chatMention) DeleteByID(ctx context.Context, id uint64) error {
+	return c.DB().WithContext(ctx).Table(model.TableNameChatMention).
+		Delete(&model.ChatMention{}, id).Error
+}
+
+func (c *chatMention) Destroy(ctx context.Context, id uint64) error {
+	return c.DB().WithContext(ctx).Table(model.TableNameChatMention).
+		Unscoped().
+		Delete(&model.ChatMention{}, id).Error
+}
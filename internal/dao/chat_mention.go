@@ -0,0 +1,12 @@
+package dao
+
+import (
+	"context"
+
+	"imy/internal/dao/model"
+)
+
+func (c *chatMention) Example(ctx context.Context) (result *model.ChatMention, err error) {
+	// example code
+	return c.WithContext(ctx).First()
+}
@@ -0,0 +1,12 @@
+package dao
+
+import (
+	"context"
+
+	"imy/internal/dao/model"
+)
+
+func (c *chatPinnedMessage) Example(ctx context.Context) (result *model.ChatPinnedMessage, err error) {
+	// example code
+	return c.WithContext(ctx).First()
+}
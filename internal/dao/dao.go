@@ -20,8 +20,11 @@ var (
 	Auth                   *auth
 	ChatConversation       *chatConversation
 	ChatConversationMember *chatConversationMember
+	ChatMention            *chatMention
 	ChatMessage            *chatMessage
+	ChatPinnedMessage      *chatPinnedMessage
 	Friend                 *friend
+	FriendBlock            *friendBlock
 	FriendV2               *friendV2
 	FriendVerify           *friendVerify
 	User                   *user
@@ -33,8 +36,11 @@ func SetDefault(db *gorm.DB, opts ...gen.DOOption) {
 	Auth = &Q.Auth
 	ChatConversation = &Q.ChatConversation
 	ChatConversationMember = &Q.ChatConversationMember
+	ChatMention = &Q.ChatMention
 	ChatMessage = &Q.ChatMessage
+	ChatPinnedMessage = &Q.ChatPinnedMessage
 	Friend = &Q.Friend
+	FriendBlock = &Q.FriendBlock
 	FriendV2 = &Q.FriendV2
 	FriendVerify = &Q.FriendVerify
 	User = &Q.User
@@ -47,8 +53,11 @@ func Use(db *gorm.DB, opts ...gen.DOOption) *Query {
 		Auth:                   newAuth(db, opts...),
 		ChatConversation:       newChatConversation(db, opts...),
 		ChatConversationMember: newChatConversationMember(db, opts...),
+		ChatMention:            newChatMention(db, opts...),
 		ChatMessage:            newChatMessage(db, opts...),
+		ChatPinnedMessage:      newChatPinnedMessage(db, opts...),
 		Friend:                 newFriend(db, opts...),
+		FriendBlock:            newFriendBlock(db, opts...),
 		FriendV2:               newFriendV2(db, opts...),
 		FriendVerify:           newFriendVerify(db, opts...),
 		User:                   newUser(db, opts...),
@@ -62,8 +71,11 @@ type Query struct {
 	Auth                   auth
 	ChatConversation       chatConversation
 	ChatConversationMember chatConversationMember
+	ChatMention            chatMention
 	ChatMessage            chatMessage
+	ChatPinnedMessage      chatPinnedMessage
 	Friend                 friend
+	FriendBlock            friendBlock
 	FriendV2               friendV2
 	FriendVerify           friendVerify
 	User                   user
@@ -78,8 +90,11 @@ func (q *Query) clone(db *gorm.DB) *Query {
 		Auth:                   q.Auth.clone(db),
 		ChatConversation:       q.ChatConversation.clone(db),
 		ChatConversationMember: q.ChatConversationMember.clone(db),
+		ChatMention:            q.ChatMention.clone(db),
 		ChatMessage:            q.ChatMessage.clone(db),
+		ChatPinnedMessage:      q.ChatPinnedMessage.clone(db),
 		Friend:                 q.Friend.clone(db),
+		FriendBlock:            q.FriendBlock.clone(db),
 		FriendV2:               q.FriendV2.clone(db),
 		FriendVerify:           q.FriendVerify.clone(db),
 		User:                   q.User.clone(db),
@@ -101,8 +116,11 @@ func (q *Query) ReplaceDB(db *gorm.DB) *Query {
 		Auth:                   q.Auth.replaceDB(db),
 		ChatConversation:       q.ChatConversation.replaceDB(db),
 		ChatConversationMember: q.ChatConversationMember.replaceDB(db),
+		ChatMention:            q.ChatMention.replaceDB(db),
 		ChatMessage:            q.ChatMessage.replaceDB(db),
+		ChatPinnedMessage:      q.ChatPinnedMessage.replaceDB(db),
 		Friend:                 q.Friend.replaceDB(db),
+		FriendBlock:            q.FriendBlock.replaceDB(db),
 		FriendV2:               q.FriendV2.replaceDB(db),
 		FriendVerify:           q.FriendVerify.replaceDB(db),
 		User:                   q.User.replaceDB(db),
@@ -114,8 +132,11 @@ type queryCtx struct {
 	Auth                   *authDo
 	ChatConversation       *chatConversationDo
 	ChatConversationMember *chatConversationMemberDo
+	ChatMention            *chatMentionDo
 	ChatMessage            *chatMessageDo
+	ChatPinnedMessage      *chatPinnedMessageDo
 	Friend                 *friendDo
+	FriendBlock            *friendBlockDo
 	FriendV2               *friendV2Do
 	FriendVerify           *friendVerifyDo
 	User                   *userDo
@@ -127,8 +148,11 @@ func (q *Query) WithContext(ctx context.Context) *queryCtx {
 		Auth:                   q.Auth.WithContext(ctx),
 		ChatConversation:       q.ChatConversation.WithContext(ctx),
 		ChatConversationMember: q.ChatConversationMember.WithContext(ctx),
+		ChatMention:            q.ChatMention.WithContext(ctx),
 		ChatMessage:            q.ChatMessage.WithContext(ctx),
+		ChatPinnedMessage:      q.ChatPinnedMessage.WithContext(ctx),
 		Friend:                 q.Friend.WithContext(ctx),
+		FriendBlock:            q.FriendBlock.WithContext(ctx),
 		FriendV2:               q.FriendV2.WithContext(ctx),
 		FriendVerify:           q.FriendVerify.WithContext(ctx),
 		User:                   q.User.WithContext(ctx),
@@ -0,0 +1,12 @@
+package dao
+
+import (
+	"context"
+
+	"imy/internal/dao/model"
+)
+
+func (f *friendBlock) Example(ctx context.Context) (result *model.FriendBlock, err error) {
+	// example code
+	return f.WithContext(ctx).First()
+}
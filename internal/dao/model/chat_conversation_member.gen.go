@@ -17,7 +17,7 @@ type ChatConversationMember struct {
 	ID                uint32                `gorm:"column:id;type:int unsigned;primaryKey;autoIncrement:true;comment:主键id" json:"id"`                             // 主键id
 	ConversationID    uint32                `gorm:"column:conversation_id;type:int unsigned;not null;comment:会话id" json:"conversation_id"`                        // 会话id
 	UserUUID          string                `gorm:"column:user_uuid;type:varchar(64);not null;comment:成员uuid" json:"user_uuid"`                                   // 成员uuid
-	Role              int8                  `gorm:"column:role;type:tinyint;not null;default:1;comment:成员角色，1表示普通成员，2表示管理员，群聊使用" json:"role"`                     // 成员角色，1表示普通成员，2表示管理员，群聊使用
+	Role              int8                  `gorm:"column:role;type:tinyint;not null;default:1;comment:成员角色，1表示普通成员，2表示管理员，3表示群主，群聊使用" json:"role"`               // 成员角色，1表示普通成员，2表示管理员，3表示群主，群聊使用
 	LastReadMessageID uint64                `gorm:"column:last_read_message_id;type:bigint unsigned;not null;comment:最后已读消息ID" json:"last_read_message_id"`       // 最后已读消息ID
 	LastReadAt        time.Time             `gorm:"column:last_read_at;type:datetime;not null;default:CURRENT_TIMESTAMP;comment:最后已读时间" json:"last_read_at"`      // 最后已读时间
 	MuteUntil         time.Time             `gorm:"column:mute_until;type:datetime;not null;default:1970-01-01 00:00:00;comment:免打扰截止时间" json:"mute_until"`       // 免打扰截止时间
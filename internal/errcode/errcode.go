@@ -29,6 +29,8 @@ var (
 	ErrJsonMarshal           = utils.NewBaseError(1113, "序列化json失败")
 	ErrAuthTokenCreateFailed = utils.NewBaseError(1114, "token生成失败")
 	ErrPasswordGenerate      = utils.NewBaseError(1115, "密码哈希失败")
+	ErrAuthServiceAccount    = utils.NewBaseError(1116, "服务账号或密钥错误")
+	ErrAuthSessionNotFound   = utils.NewBaseError(1117, "会话不存在或已失效")
 
 	ErrTime         = utils.NewBaseError(1201, "时间解析错误")
 	ErrFileNotFund  = utils.NewBaseError(1202, "文件不存在")
@@ -44,4 +46,19 @@ var (
 	ErrVerifyDeal         = utils.NewBaseError(1403, "处理验证失败")
 	ErrVerifyNotFound     = utils.NewBaseError(1404, "该好友验证不存在")
 	ErrVerifyExist        = utils.NewBaseError(1405, "该条验证已经存在")
+	ErrUserBlocked        = utils.NewBaseError(1406, "无法与该用户建立联系")
+	ErrFriendNotExist     = utils.NewBaseError(1407, "对方还不是你的好友")
+
+	ErrMessageRejected  = utils.NewBaseError(1501, "消息未通过内容审核")
+	ErrMessageSpam      = utils.NewBaseError(1502, "发送过于频繁或内容重复")
+	ErrMessageProfanity = utils.NewBaseError(1503, "消息包含敏感词")
+	ErrMessageTooLarge  = utils.NewBaseError(1504, "消息内容过长")
+	ErrMessageType      = utils.NewBaseError(1505, "不支持的消息类型")
+	ErrMessageEmpty     = utils.NewBaseError(1506, "消息内容为空")
+
+	ErrConvPermission       = utils.NewBaseError(1601, "没有该会话的操作权限")
+	ErrConvAnnouncementOnly = utils.NewBaseError(1602, "当前仅群主和管理员可以发言")
+	ErrConvOwnerLeave       = utils.NewBaseError(1603, "群主需要先转让群主才能退出群聊")
+	ErrConvNotGroup         = utils.NewBaseError(1604, "只有群聊支持该操作")
+	ErrConvPinLimit         = utils.NewBaseError(1605, "置顶消息数量已达上限")
 )
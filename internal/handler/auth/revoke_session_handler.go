@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"net/http"
+
+	"imy/internal/logic/auth"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	xhttp "imy/pkg/httpx"
+)
+
+func RevokeSessionHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.RevokeSessionReq
+		if err := xhttp.Parse(r, &req); err != nil {
+			xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+			return
+		}
+		cw := &xhttp.CustomResponseWriter{
+			ResponseWriter: w,
+			Wrote:          false,
+		}
+		ctx := xhttp.HttpInterceptor(r.Context(), cw, r)
+
+		l := auth.NewRevokeSessionLogic(ctx, svcCtx)
+		resp, err := l.RevokeSession(&req)
+		if err != nil {
+			if !cw.Wrote {
+				// use cw to preserve any headers set in logic
+				xhttp.JsonBaseResponseCtx(r.Context(), cw, err)
+			}
+		} else {
+			if !cw.Wrote {
+				// use cw to preserve any headers set in logic
+				xhttp.JsonBaseResponseCtx(r.Context(), cw, resp)
+			}
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package bot
+
+import (
+	"net/http"
+
+	"imy/internal/logic/bot"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	xhttp "imy/pkg/httpx"
+)
+
+func SendMessageHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.SendMessageReq
+		if err := xhttp.Parse(r, &req); err != nil {
+			xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+			return
+		}
+		cw := &xhttp.CustomResponseWriter{
+			ResponseWriter: w,
+			Wrote:          false,
+		}
+		ctx := xhttp.HttpInterceptor(r.Context(), cw, r)
+
+		l := bot.NewSendMessageLogic(ctx, svcCtx)
+		resp, err := l.SendMessage(&req)
+		if err != nil {
+			if !cw.Wrote {
+				xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+			}
+		} else {
+			if !cw.Wrote {
+				xhttp.JsonBaseResponseCtx(r.Context(), w, resp)
+			}
+		}
+	}
+}
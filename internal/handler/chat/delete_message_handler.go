@@ -0,0 +1,38 @@
+package chat
+
+import (
+	"net/http"
+
+	"imy/internal/logic/chat"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	xhttp "imy/pkg/httpx"
+)
+
+func DeleteMessageHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.DeleteMessageReq
+		if err := xhttp.Parse(r, &req); err != nil {
+			xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+			return
+		}
+		cw := &xhttp.CustomResponseWriter{
+			ResponseWriter: w,
+			Wrote:          false,
+		}
+		ctx := xhttp.HttpInterceptor(r.Context(), cw, r)
+
+		l := chat.NewDeleteMessageLogic(ctx, svcCtx)
+		err := l.DeleteMessage(&req)
+		if err != nil {
+			if !cw.Wrote {
+				xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+			}
+		} else {
+			if !cw.Wrote {
+				xhttp.JsonBaseResponseCtx(r.Context(), w, nil)
+			}
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package chat
+
+import (
+	"net/http"
+
+	"imy/internal/logic/chat"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	xhttp "imy/pkg/httpx"
+)
+
+func ExportConversationHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req types.ExportConversationReq
+		if err := xhttp.Parse(r, &req); err != nil {
+			xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+			return
+		}
+		cw := &xhttp.CustomResponseWriter{
+			ResponseWriter: w,
+			Wrote:          false,
+		}
+		ctx := xhttp.HttpInterceptor(r.Context(), cw, r)
+
+		l := chat.NewExportConversationLogic(ctx, svcCtx)
+		// 成功时响应体由逻辑层流式写出，空会话导出为空文件
+		if err := l.ExportConversation(&req); err != nil && !cw.Wrote {
+			xhttp.JsonBaseResponseCtx(r.Context(), w, err)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package chat
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
@@ -8,15 +10,20 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/zeromicro/go-zero/core/logx"
+	"imy/internal/logic/chat"
 	"imy/internal/svc"
-	"imy/pkg/jwt"
+	ws "imy/pkg/websocket"
 )
 
-// ChatWsHandler handles WebSocket upgrade with auth and a minimal read/ping loop.
+// ChatWsHandler handles WebSocket upgrade with auth and a read/ping loop.
+// Clients opt into protocol v2 (typed envelopes, resume, receipts, typing,
+// presence and in-band token renewal) with the "imy.v2" subprotocol or the
+// v=2 query parameter. Connections are closed once their token expires.
 func ChatWsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 	upgrader := websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
+		Subprotocols:    []string{ws.ProtocolV2},
 		// TODO: Restrict origin per configuration. For now, allow all origins for development.
 		CheckOrigin: func(r *http.Request) bool { return true },
 	}
@@ -41,12 +48,17 @@ func ChatWsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
-		claims, err := jwt.ParseToken(tok, svcCtx.Config.Auth.AccessSecret)
-		if err != nil || claims == nil || claims.UUID == "" {
+		claims, err := svcCtx.JWTKeys.ParseToken(tok)
+		if err != nil || claims == nil || claims.UUID == "" || !wsSessionActive(svcCtx, claims) {
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 		uuid := claims.UUID
+		var expires time.Time
+		if claims.ExpiresAt != nil {
+			expires = claims.ExpiresAt.Time
+		}
+		expiry := newWsTokenExpiry(expires)
 
 		// 2) upgrade
 		conn, err := upgrader.Upgrade(w, r, nil)
@@ -56,9 +68,25 @@ func ChatWsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 		}
 		defer conn.Close()
 
+		version := 1
+		if conn.Subprotocol() == ws.ProtocolV2 || r.URL.Query().Get("v") == "2" {
+			version = 2
+		}
+
 		// register and ensure unregister on exit
-		svcCtx.Ws.Register(uuid, conn)
-		defer svcCtx.Ws.Unregister(uuid, conn)
+		if svcCtx.Ws.RegisterVersion(uuid, conn, version) {
+			go chat.NotifyPresence(context.Background(), svcCtx, uuid, true)
+		}
+		// revoking the login session closes its connections
+		svcCtx.Ws.SetSession(conn, claims.Session)
+		if claims.Session != "" {
+			svcCtx.Sessions.Touch(uuid, claims.Session, svc.RequestIP(r))
+		}
+		defer func() {
+			if svcCtx.Ws.Unregister(uuid, conn) {
+				go chat.NotifyPresence(context.Background(), svcCtx, uuid, false)
+			}
+		}()
 
 		// Read setup
 		conn.SetReadLimit(64 << 10) // 64KB per message
@@ -73,12 +101,24 @@ func ChatWsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			Op   string                 `json:"op"`
 			Data map[string]interface{} `json:"data"`
 		}
-		_ = svcCtx.Ws.WithConnWrite(conn, func(c *websocket.Conn) error {
-			return c.WriteJSON(readyPayload{Op: "ready", Data: map[string]interface{}{"serverTime": time.Now().Unix(), "uuid": uuid}})
-		})
+		ready := readyPayload{Op: "ready", Data: map[string]interface{}{"serverTime": time.Now().Unix(), "uuid": uuid}}
+		if version >= 2 {
+			ready.Data["version"] = version
+			ready.Data["lastSeq"] = svcCtx.Ws.Checkpoint(uuid)
+			env, _ := ws.NewEnvelope(ws.EnvelopeReady, 0, ready)
+			_ = svcCtx.Ws.WriteConn(conn, env)
+		} else {
+			_ = svcCtx.Ws.WriteConn(conn, ready)
+		}
 
 		// Ping loop
+		stop := make(chan struct{})
 		done := make(chan struct{})
+		watchDone := make(chan struct{})
+		go func() {
+			defer close(watchDone)
+			expiry.Watch(svcCtx, conn, version, stop)
+		}()
 		go func() {
 			ticker := time.NewTicker(30 * time.Second)
 			defer func() {
@@ -87,7 +127,7 @@ func ChatWsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			}()
 			for {
 				select {
-				case <-r.Context().Done():
+				case <-stop:
 					return
 				case <-ticker.C:
 					_ = svcCtx.Ws.WithConnWrite(conn, func(c *websocket.Conn) error {
@@ -98,14 +138,93 @@ func ChatWsHandler(svcCtx *svc.ServiceContext) http.HandlerFunc {
 			}
 		}()
 
-		// Read loop (placeholder)
+		// Read loop: v1 clients only keep the connection alive, v2 clients send envelopes
 		for {
-			if _, _, err := conn.ReadMessage(); err != nil {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
 				// Normal closure or error
 				break
 			}
+			if version >= 2 {
+				handleClientEnvelope(r.Context(), svcCtx, uuid, conn, expiry, data)
+			}
 		}
 
+		close(stop)
 		<-done
+		<-watchDone
+	}
+}
+
+// handleClientEnvelope dispatches one frame sent by a v2 client.
+func handleClientEnvelope(ctx context.Context, svcCtx *svc.ServiceContext, uuid string, conn *websocket.Conn, expiry *wsTokenExpiry, data []byte) {
+	var env ws.Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		writeWsError(svcCtx, conn, "bad_envelope", "invalid envelope: "+err.Error())
+		return
+	}
+	switch env.Type {
+	case ws.EnvelopeResume:
+		var p ws.ResumePayload
+		if len(env.Payload) > 0 {
+			if err := json.Unmarshal(env.Payload, &p); err != nil {
+				writeWsError(svcCtx, conn, "bad_payload", "invalid resume payload")
+				return
+			}
+		}
+		if err := svcCtx.Ws.Resume(uuid, conn, p.LastSeq); err != nil {
+			logx.Infof("ws resume for %s failed: %v", uuid, err)
+		}
+	case ws.EnvelopeReceipt:
+		var p ws.ReceiptPayload
+		if err := json.Unmarshal(env.Payload, &p); err != nil || p.Seq <= 0 {
+			writeWsError(svcCtx, conn, "bad_payload", "receipt requires a positive seq")
+			return
+		}
+		svcCtx.Ws.Ack(uuid, p.Seq)
+	case ws.EnvelopeTyping:
+		var p ws.TypingPayload
+		if len(env.Payload) > 0 {
+			if err := json.Unmarshal(env.Payload, &p); err != nil {
+				writeWsError(svcCtx, conn, "bad_payload", "invalid typing payload")
+				return
+			}
+		}
+		if err := chat.ForwardTyping(ctx, svcCtx, uuid, env.ConvID, p.Typing); err != nil {
+			writeWsError(svcCtx, conn, "typing_rejected", err.Error())
+		}
+	case ws.EnvelopeAuth:
+		var p ws.AuthPayload
+		if err := json.Unmarshal(env.Payload, &p); err != nil || p.Token == "" {
+			writeWsError(svcCtx, conn, "bad_payload", "auth requires a token")
+			return
+		}
+		claims, err := svcCtx.JWTKeys.ParseToken(p.Token)
+		if err != nil || claims.UUID != uuid || !wsSessionActive(svcCtx, claims) {
+			// the current token stays valid until it expires
+			writeWsError(svcCtx, conn, "auth_failed", "token rejected")
+			return
+		}
+		var expires time.Time
+		result := ws.AuthResult{UUID: uuid}
+		if claims.ExpiresAt != nil {
+			expires = claims.ExpiresAt.Time
+			result.ExpiresAt = expires.Unix()
+		}
+		expiry.Renew(expires)
+		svcCtx.Ws.SetSession(conn, claims.Session)
+		if reply, err := ws.NewEnvelope(ws.EnvelopeAuth, 0, result); err == nil {
+			_ = svcCtx.Ws.WriteConn(conn, reply)
+		}
+	default:
+		writeWsError(svcCtx, conn, "unsupported_type", "unsupported envelope type: "+string(env.Type))
+	}
+}
+
+func writeWsError(svcCtx *svc.ServiceContext, conn *websocket.Conn, code, message string) {
+	env, err := ws.NewEnvelope(ws.EnvelopeError, 0, ws.ErrorPayload{Code: code, Message: message})
+	if err != nil {
+		return
 	}
-}
\ No newline at end of file
+	_ = svcCtx.Ws.WriteConn(conn, env)
+}
@@ -0,0 +1,132 @@
+package chat
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zeromicro/go-zero/core/logx"
+	"imy/internal/svc"
+	"imy/pkg/jwt"
+	ws "imy/pkg/websocket"
+)
+
+// wsExpiryWarning is how long before the token expires the client is warned
+const wsExpiryWarning = time.Minute
+
+// wsTokenExpiry tracks the token of one connection. v2 clients renew it with
+// an auth envelope; the connection is warned before and closed at expiry
+type wsTokenExpiry struct {
+	mu      sync.Mutex
+	expires time.Time // zero for tokens without exp
+	warned  bool
+	renewed chan struct{}
+}
+
+func newWsTokenExpiry(expires time.Time) *wsTokenExpiry {
+	return &wsTokenExpiry{expires: expires, renewed: make(chan struct{}, 1)}
+}
+
+// Renew replaces the expiry after the client sent a new token
+func (e *wsTokenExpiry) Renew(expires time.Time) {
+	e.mu.Lock()
+	e.expires = expires
+	e.warned = false
+	e.mu.Unlock()
+	select {
+	case e.renewed <- struct{}{}:
+	default:
+	}
+}
+
+func (e *wsTokenExpiry) state() (time.Time, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.expires, e.warned
+}
+
+// warn records the warning unless a renewal raced it
+func (e *wsTokenExpiry) warn(expires time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.expires.Equal(expires) {
+		return false
+	}
+	e.warned = true
+	return true
+}
+
+// Watch warns the client wsExpiryWarning before the token expires and closes
+// the connection with 1008 once it has, until stop is closed
+func (e *wsTokenExpiry) Watch(svcCtx *svc.ServiceContext, conn *websocket.Conn, version int, stop <-chan struct{}) {
+	for {
+		expires, warned := e.state()
+		// tokens without exp never fire, a renewal may add one
+		at := time.Now().Add(24 * time.Hour)
+		if !expires.IsZero() {
+			at = expires
+			if !warned {
+				at = expires.Add(-wsExpiryWarning)
+			}
+		}
+		timer := time.NewTimer(time.Until(at))
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-e.renewed:
+			timer.Stop()
+			continue
+		case <-timer.C:
+		}
+		if expires.IsZero() {
+			continue
+		}
+
+		if !warned {
+			if e.warn(expires) {
+				writeExpiring(svcCtx, conn, version, expires)
+			}
+			continue
+		}
+		if current, _ := e.state(); !current.Equal(expires) {
+			continue
+		}
+		_ = svcCtx.Ws.WithConnWrite(conn, func(c *websocket.Conn) error {
+			msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "token expired")
+			return c.WriteControl(websocket.CloseMessage, msg, time.Now().Add(10*time.Second))
+		})
+		// the read loop ends when the client answers the close frame or the grace runs out
+		time.AfterFunc(2*time.Second, func() { _ = conn.Close() })
+		return
+	}
+}
+
+// wsSessionActive reports whether the login session of the token was not
+// revoked; tokens without a session and redis failures are let through
+func wsSessionActive(svcCtx *svc.ServiceContext, claims *jwt.CustomClaims) bool {
+	if claims.Session == "" {
+		return true
+	}
+	ok, err := svcCtx.Sessions.Exists(claims.UUID, claims.Session)
+	if err != nil {
+		logx.Errorf("ws session lookup failed: %v", err)
+		return true
+	}
+	return ok
+}
+
+func writeExpiring(svcCtx *svc.ServiceContext, conn *websocket.Conn, version int, expires time.Time) {
+	if version >= 2 {
+		env, err := ws.NewEnvelope(ws.EnvelopeExpiring, 0, ws.ExpiringPayload{ExpiresAt: expires.Unix()})
+		if err == nil {
+			_ = svcCtx.Ws.WriteConn(conn, env)
+		}
+		return
+	}
+	// v1 clients cannot renew in band, they reconnect with a fresh token
+	_ = svcCtx.Ws.WriteConn(conn, map[string]any{
+		"op":   "token_expiring",
+		"data": map[string]any{"expiresAt": expires.Unix()},
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net/http"
 
 	auth "imy/internal/handler/auth"
+	bot "imy/internal/handler/bot"
 	chat "imy/internal/handler/chat"
 	friend "imy/internal/handler/friend"
 	v2auth "imy/internal/handler/v2/auth"
@@ -51,10 +52,58 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/getEmailCode",
 				Handler: auth.GetEmailCodeHandler(serverCtx),
 			},
+			{
+				// 刷新访问令牌
+				Method:  http.MethodPost,
+				Path:    "/refreshToken",
+				Handler: auth.RefreshTokenHandler(serverCtx),
+			},
+			{
+				// 服务账号换取访问令牌
+				Method:  http.MethodPost,
+				Path:    "/serviceToken",
+				Handler: auth.ServiceTokenHandler(serverCtx),
+			},
+			{
+				// 查看当前用户的登录会话
+				Method:  http.MethodPost,
+				Path:    "/getSessions",
+				Handler: auth.GetSessionsHandler(serverCtx),
+			},
+			{
+				// 吊销登录会话，对应设备被强制下线
+				Method:  http.MethodPost,
+				Path:    "/revokeSession",
+				Handler: auth.RevokeSessionHandler(serverCtx),
+			},
 		},
 		rest.WithPrefix("/api/auth"),
 	)
 
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				// 获取机器人所在的会话列表
+				Method:  http.MethodPost,
+				Path:    "/getConversations",
+				Handler: bot.GetConversationsHandler(serverCtx),
+			},
+			{
+				// 拉取会话消息
+				Method:  http.MethodPost,
+				Path:    "/getMessages",
+				Handler: bot.GetMessagesHandler(serverCtx),
+			},
+			{
+				// 机器人发送消息
+				Method:  http.MethodPost,
+				Path:    "/sendMessage",
+				Handler: bot.SendMessageHandler(serverCtx),
+			},
+		},
+		rest.WithPrefix("/api/bot"),
+	)
+
 	server.AddRoutes(
 		[]rest.Route{
 			{
@@ -75,6 +124,24 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/createPrivate",
 				Handler: chat.CreatePrivateConversationHandler(serverCtx),
 			},
+			{
+				// 删除消息
+				Method:  http.MethodPost,
+				Path:    "/deleteMessage",
+				Handler: chat.DeleteMessageHandler(serverCtx),
+			},
+			{
+				// 编辑消息
+				Method:  http.MethodPost,
+				Path:    "/editMessage",
+				Handler: chat.EditMessageHandler(serverCtx),
+			},
+			{
+				// 导出会话完整历史（流式 NDJSON/CSV）
+				Method:  http.MethodGet,
+				Path:    "/exportConversation",
+				Handler: chat.ExportConversationHandler(serverCtx),
+			},
 			{
 				// 获取会话详情
 				Method:  http.MethodPost,
@@ -87,18 +154,42 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/getConversations",
 				Handler: chat.GetConversationsHandler(serverCtx),
 			},
+			{
+				// 获取提及我的消息
+				Method:  http.MethodPost,
+				Path:    "/getMentions",
+				Handler: chat.GetMentionsHandler(serverCtx),
+			},
 			{
 				// 拉取历史消息
 				Method:  http.MethodPost,
 				Path:    "/getMessages",
 				Handler: chat.GetMessagesHandler(serverCtx),
 			},
+			{
+				// 获取会话的置顶消息
+				Method:  http.MethodPost,
+				Path:    "/getPinnedMessages",
+				Handler: chat.GetPinnedMessagesHandler(serverCtx),
+			},
+			{
+				// 获取消息的回复话题
+				Method:  http.MethodPost,
+				Path:    "/getThread",
+				Handler: chat.GetThreadHandler(serverCtx),
+			},
 			{
 				// 获取未读计数
 				Method:  http.MethodPost,
 				Path:    "/getUnreadCounts",
 				Handler: chat.GetUnreadCountsHandler(serverCtx),
 			},
+			{
+				// 置顶消息
+				Method:  http.MethodPost,
+				Path:    "/pinMessage",
+				Handler: chat.PinMessageHandler(serverCtx),
+			},
 			{
 				// 上报已读进度
 				Method:  http.MethodPost,
@@ -123,6 +214,30 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/sendMessage",
 				Handler: chat.SendMessageHandler(serverCtx),
 			},
+			{
+				// 设置/取消管理员（群主）
+				Method:  http.MethodPost,
+				Path:    "/setMemberRole",
+				Handler: chat.SetMemberRoleHandler(serverCtx),
+			},
+			{
+				// 转让群主
+				Method:  http.MethodPost,
+				Path:    "/transferOwner",
+				Handler: chat.TransferOwnerHandler(serverCtx),
+			},
+			{
+				// 取消置顶消息
+				Method:  http.MethodPost,
+				Path:    "/unpinMessage",
+				Handler: chat.UnpinMessageHandler(serverCtx),
+			},
+			{
+				// 修改群资料（群主/管理员）
+				Method:  http.MethodPost,
+				Path:    "/updateInfo",
+				Handler: chat.UpdateConversationInfoHandler(serverCtx),
+			},
 			{
 				// 更新个人会话设置
 				Method:  http.MethodPost,
@@ -133,6 +248,25 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 		rest.WithPrefix("/api/chat"),
 	)
 
+	server.AddRoutes(
+		[]rest.Route{
+			{
+				// 下载附件
+				Method:  http.MethodGet,
+				Path:    "/downloadAttachment",
+				Handler: chat.DownloadAttachmentHandler(serverCtx),
+			},
+			{
+				// 上传附件（multipart，字段 file）
+				Method:  http.MethodPost,
+				Path:    "/uploadAttachment",
+				Handler: chat.UploadAttachmentHandler(serverCtx),
+			},
+		},
+		rest.WithPrefix("/api/chat"),
+		rest.WithMaxBytes(67108864),
+	)
+
 	server.AddRoutes(
 		[]rest.Route{
 			{
@@ -141,6 +275,18 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/addFriend",
 				Handler: friend.AddFriendHandler(serverCtx),
 			},
+			{
+				// 拉黑用户
+				Method:  http.MethodPost,
+				Path:    "/blockUser",
+				Handler: friend.BlockUserHandler(serverCtx),
+			},
+			{
+				// 获取黑名单
+				Method:  http.MethodPost,
+				Path:    "/getBlockList",
+				Handler: friend.GetBlockListHandler(serverCtx),
+			},
 			{
 				// 获取好友列表
 				Method:  http.MethodPost,
@@ -153,6 +299,12 @@ func RegisterHandlers(server *rest.Server, serverCtx *svc.ServiceContext) {
 				Path:    "/searchUser",
 				Handler: friend.SearchUserHandler(serverCtx),
 			},
+			{
+				// 解除拉黑
+				Method:  http.MethodPost,
+				Path:    "/unblockUser",
+				Handler: friend.UnblockUserHandler(serverCtx),
+			},
 			{
 				// 处理好友验证
 				Method:  http.MethodPost,
@@ -3,13 +3,12 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"time"
 
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
-	"imy/pkg/jwt"
+	"imy/pkg/httpx"
 	"imy/pkg/utils"
 
 	"github.com/zeromicro/go-zero/core/logx"
@@ -39,16 +38,32 @@ func (l *AuthCheckLogic) AuthCheck(req *types.AuthCheckReq) (resp *types.AuthChe
 	if req.Token == "" {
 		return nil, errcode.ErrAuthTokenNil
 	}
-	claims, err := jwt.ParseToken(req.Token, l.svcCtx.Config.Auth.AccessSecret)
+	claims, err := l.svcCtx.JWTKeys.ParseToken(req.Token)
 	if err != nil {
 		logx.Errorf("解析token失败：%v", err)
 		return nil, errcode.ErrAuthTokenFailed.WithError(err)
 	}
 
-	// TODO：设备识别
+	// 带会话ID的令牌只要会话未被吊销就有效，同一用户的多台设备各自持有会话
+	if claims.Session != "" {
+		ok, err := l.svcCtx.Sessions.Exists(claims.UUID, claims.Session)
+		if err != nil {
+			logx.Errorf("查询会话失败：%v", err)
+			return nil, errcode.ErrAuthSession.WithError(err)
+		}
+		if !ok {
+			return nil, errcode.ErrAuthTokenUseless
+		}
+		ip := ""
+		if r, ok := httpx.GetRequest(l.ctx); ok {
+			ip = svc.RequestIP(r)
+		}
+		l.svcCtx.Sessions.Touch(claims.UUID, claims.Session, ip)
+		return &types.AuthCheckResp{UUID: claims.UUID}, nil
+	}
 
-	// 看一下redis中是否存在token会话记录，如果没有就代表token无用，表示用户没有登陆
-	key := fmt.Sprintf("login_%s", claims.UUID)
+	// 服务账号与旧令牌：看一下redis中是否存在token会话记录，如果没有就代表token无用，表示用户没有登陆
+	key := sessionKey(claims.UUID)
 	loginStr, err := l.svcCtx.Redis.Get(key).Result()
 	if err != nil {
 		logx.Errorf("解析会话信息失败：%v", err)
@@ -75,7 +90,7 @@ func (l *AuthCheckLogic) AuthCheck(req *types.AuthCheckReq) (resp *types.AuthChe
 			logx.Errorf("序列化会话信息失败：%v", err)
 			return nil, errcode.ErrJsonMarshal.WithError(err)
 		}
-		err = l.svcCtx.Redis.Set(key, string(updateSession), 24*time.Hour).Err()
+		err = l.svcCtx.Redis.Set(key, string(updateSession), sessionTTL(l.svcCtx.Config.Auth)).Err()
 		if err != nil {
 			logx.Errorf("存储key于redis失败：%v", err)
 			return nil, errcode.ErrRedisSet.WithError(err)
@@ -2,17 +2,12 @@ package auth
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"fmt"
-	"time"
 
 	"imy/internal/dao"
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
-	"imy/pkg/httpx"
-	"imy/pkg/jwt"
 	"imy/pkg/utils"
 
 	"gorm.io/gorm"
@@ -56,41 +51,18 @@ func (l *EmailPasswordLoginLogic) EmailPasswordLogin(req *types.EmailPasswordLog
 		return nil, errcode.ErrAuthInvalidParam.WithError(err)
 	}
 
-	// 生成token，用uuid作为payload
-	token, err := jwt.GenToken(jwt.JwtPayLoad{
-		Nickname: u.NickName,
-		UUID:     u.UUID,
-	}, l.svcCtx.Config.Auth.AccessSecret, l.svcCtx.Config.Auth.AccessExpire)
+	// 每次登录创建新的会话并签发访问令牌和刷新令牌，其他设备的会话不受影响
+	tokens, err := startSession(l.ctx, l.svcCtx, u.UUID, u.Email, u.NickName, req.Device)
 	if err != nil {
-		logx.Errorf("生成token失败：%v", err)
-		return nil, errcode.ErrAuthTokenFailed.WithError(err)
-	}
-
-	// 存入redis会话，如果会话存在就更新成新的，不存在就直接插入
-	key := fmt.Sprintf("login_%s", u.UUID)
-	session := map[string]any{
-		"uuid":        u.UUID,
-		"email":       u.Email,
-		"nickname":    u.NickName,
-		"token":       token,
-		"last_active": time.Now().Format("2006-01-02 15:04:05"),
-	}
-	b, err := json.Marshal(session)
-	if err != nil {
-		logx.Errorf("序列化会话信息失败：%v", err)
-		return nil, errcode.ErrJsonMarshal.WithError(err)
-	}
-	if err := l.svcCtx.Redis.Set(key, string(b), 24*time.Hour).Err(); err != nil {
-		logx.Errorf("存储key于redis失败：%v", err)
-		return nil, errcode.ErrRedisSet.WithError(err)
-	}
-
-	// 在响应头中写入 token，便于客户端后续携带
-	if w, ok := httpx.GetResponse(l.ctx); ok {
-		w.Header().Set("token", token)
-		w.Header().Set("Authorization", "Bearer "+token)
+		return nil, err
 	}
 
 	// 装配响应
-	return &types.EmailPasswordLoginResp{UUID: u.UUID}, nil
+	return &types.EmailPasswordLoginResp{
+		UUID:         u.UUID,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		SessionId:    tokens.SessionID,
+	}, nil
 }
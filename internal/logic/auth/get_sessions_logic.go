@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"context"
+
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetSessionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 查看当前用户的登录会话
+func NewGetSessionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetSessionsLogic {
+	return &GetSessionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetSessions 列出未过期的登录会话，按最近活跃时间倒序，并标出发起请求的会话和各会话的WebSocket连接数
+func (l *GetSessionsLogic) GetSessions(req *types.GetSessionsReq) (resp *types.GetSessionsResp, err error) {
+	if req.UUID == "" {
+		return nil, errcode.ErrInvalidParam
+	}
+	sessions, err := l.svcCtx.Sessions.List(req.UUID)
+	if err != nil {
+		logx.Errorf("查询会话失败：%v", err)
+		return nil, errcode.ErrAuthSession.WithError(err)
+	}
+	conns := l.svcCtx.Ws.SessionConns(req.UUID)
+
+	resp = &types.GetSessionsResp{Sessions: make([]types.SessionInfo, 0, len(sessions))}
+	for _, sess := range sessions {
+		resp.Sessions = append(resp.Sessions, types.SessionInfo{
+			SessionId:     sess.ID,
+			Device:        sess.Device,
+			UserAgent:     sess.UserAgent,
+			LoginIp:       sess.IP,
+			LastIp:        sess.Activity.IP,
+			CreatedAt:     sess.CreatedAt,
+			LastActive:    sess.Activity.At,
+			ExpiresAt:     sess.ExpiresAt,
+			WsConnections: conns[sess.ID],
+			Current:       sess.ID == req.SessionId,
+		})
+	}
+	return resp, nil
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+	"imy/pkg/httpx"
+	"imy/pkg/jwt"
+	ws "imy/pkg/websocket"
+
+	jwtv4 "github.com/golang-jwt/jwt/v4"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RefreshTokenLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 刷新访问令牌
+func NewRefreshTokenLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RefreshTokenLogic {
+	return &RefreshTokenLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// RefreshToken 用刷新令牌换取新的访问令牌，同时轮换刷新令牌
+// 每个刷新令牌只能使用一次，已使用过的令牌再次出现视为泄露，直接吊销所属会话
+func (l *RefreshTokenLogic) RefreshToken(req *types.RefreshTokenReq) (resp *types.RefreshTokenResp, err error) {
+	if req.RefreshToken == "" {
+		return nil, errcode.ErrAuthTokenNil
+	}
+	claims, err := jwt.ParseRefreshToken(req.RefreshToken, l.svcCtx.Config.Auth.AccessSecret)
+	if err != nil {
+		if errors.Is(err, jwtv4.ErrTokenExpired) {
+			return nil, errcode.ErrAuthTokenExpire.WithError(err)
+		}
+		logx.Errorf("解析刷新token失败：%v", err)
+		return nil, errcode.ErrAuthTokenFailed.WithError(err)
+	}
+
+	// 标记该刷新令牌已使用，保留到令牌过期为止，并发刷新时只有一个请求能成功
+	usedKey := fmt.Sprintf("refresh_used_%s", claims.ID)
+	usedTTL := time.Until(claims.ExpiresAt.Time) + time.Minute
+	first, err := l.svcCtx.Redis.SetNX(usedKey, claims.UUID, usedTTL).Result()
+	if err != nil {
+		logx.Errorf("存储key于redis失败：%v", err)
+		return nil, errcode.ErrRedisSet.WithError(err)
+	}
+
+	var tokens *tokenPair
+	if claims.Session != "" {
+		tokens, err = l.refreshSession(claims, first)
+	} else {
+		tokens, err = l.refreshLegacy(claims, first)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.RefreshTokenResp{
+		UUID:         claims.UUID,
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		SessionId:    tokens.SessionID,
+	}, nil
+}
+
+// refreshSession 轮换会话的刷新令牌，会话被吊销后其刷新令牌不再有效
+func (l *RefreshTokenLogic) refreshSession(claims *jwt.CustomClaims, first bool) (*tokenPair, error) {
+	sess, err := l.svcCtx.Sessions.Get(claims.UUID, claims.Session)
+	if errors.Is(err, svc.ErrSessionNotFound) {
+		return nil, errcode.ErrAuthTokenUseless
+	}
+	if err != nil {
+		logx.Errorf("查询会话失败：%v", err)
+		return nil, errcode.ErrAuthSession.WithError(err)
+	}
+
+	if !first {
+		// 已使用过的刷新令牌被重放，令牌可能已经泄露，吊销该会话迫使这台设备重新登录
+		logx.Errorf("刷新token被重复使用，吊销会话：%s/%s", claims.UUID, sess.ID)
+		if _, _, err := revokeSession(l.svcCtx, claims.UUID, sess.ID, ws.LogoutRefreshReused); err != nil {
+			logx.Errorf("吊销会话失败：%v", err)
+		}
+		return nil, errcode.ErrAuthTokenUseless
+	}
+	if sess.RefreshID != claims.ID {
+		return nil, errcode.ErrAuthTokenUseless
+	}
+	if r, ok := httpx.GetRequest(l.ctx); ok {
+		l.svcCtx.Sessions.Touch(claims.UUID, sess.ID, svc.RequestIP(r))
+	}
+	return renewSession(l.ctx, l.svcCtx, sess)
+}
+
+// refreshLegacy 处理升级前签发、不带会话ID的刷新令牌，校验旧的单会话后迁移为新会话
+func (l *RefreshTokenLogic) refreshLegacy(claims *jwt.CustomClaims, first bool) (*tokenPair, error) {
+	key := sessionKey(claims.UUID)
+	loginStr, err := l.svcCtx.Redis.Get(key).Result()
+	if err != nil {
+		logx.Errorf("解析会话信息失败：%v", err)
+		return nil, errcode.ErrAuthTokenUseless.WithError(err)
+	}
+	var loginSession map[string]any
+	if err := json.Unmarshal([]byte(loginStr), &loginSession); err != nil {
+		logx.Errorf("登陆信息格式出错：%v", err)
+		return nil, errcode.ErrAuthSession.WithError(err)
+	}
+
+	if !first {
+		// 已使用过的刷新令牌被重放，令牌可能已经泄露，注销会话迫使用户重新登录
+		logx.Errorf("刷新token被重复使用，注销会话：%s", claims.UUID)
+		l.svcCtx.Redis.Del(key)
+		return nil, errcode.ErrAuthTokenUseless
+	}
+	if refreshID, _ := loginSession["refresh_id"].(string); refreshID != claims.ID {
+		// 会话已被新的登录覆盖，旧会话的刷新令牌不再有效
+		return nil, errcode.ErrAuthTokenUseless
+	}
+
+	l.svcCtx.Redis.Del(key)
+	email, _ := loginSession["email"].(string)
+	return startSession(l.ctx, l.svcCtx, claims.UUID, email, claims.Nickname, "")
+}
@@ -0,0 +1,45 @@
+package auth
+
+import (
+	"context"
+
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+	ws "imy/pkg/websocket"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type RevokeSessionLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 吊销登录会话，对应设备被强制下线
+func NewRevokeSessionLogic(ctx context.Context, svcCtx *svc.ServiceContext) *RevokeSessionLogic {
+	return &RevokeSessionLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// RevokeSession 吊销当前用户的一个会话，也可以是发起请求的会话本身
+// 会话的刷新令牌立即失效，WebSocket连接收到下线通知后被关闭
+func (l *RevokeSessionLogic) RevokeSession(req *types.RevokeSessionReq) (resp *types.RevokeSessionResp, err error) {
+	if req.UUID == "" || req.SessionId == "" {
+		return nil, errcode.ErrInvalidParam
+	}
+	existed, closed, err := revokeSession(l.svcCtx, req.UUID, req.SessionId, ws.LogoutRevoked)
+	if err != nil {
+		logx.Errorf("吊销会话失败：%v", err)
+		return nil, errcode.ErrAuthSession.WithError(err)
+	}
+	if !existed {
+		return nil, errcode.ErrAuthSessionNotFound
+	}
+	logx.Infof("会话已吊销：%s/%s，关闭连接%d个", req.UUID, req.SessionId, closed)
+	return &types.RevokeSessionResp{WsClosed: closed}, nil
+}
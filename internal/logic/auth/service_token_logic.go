@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"imy/internal/config"
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+	"imy/pkg/jwt"
+	"imy/pkg/utils"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// userSourceBot 服务账号对应用户的注册来源
+const userSourceBot int8 = 3
+
+type ServiceTokenLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 服务账号换取访问令牌
+func NewServiceTokenLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ServiceTokenLogic {
+	return &ServiceTokenLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// ServiceToken 校验服务账号密钥后签发带bot范围的访问令牌
+// 服务账号没有刷新令牌，令牌过期后重新用密钥换取；账号对应的用户不存在时自动创建
+func (l *ServiceTokenLogic) ServiceToken(req *types.ServiceTokenReq) (resp *types.ServiceTokenResp, err error) {
+	account, ok := findServiceAccount(l.svcCtx.Config.ServiceAccounts, req.ClientId)
+	if !ok || utils.PwdVerify(req.ClientSecret, account.SecretHash) != nil {
+		return nil, errcode.ErrAuthServiceAccount
+	}
+
+	user, err := l.ensureUser(account)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == 2 {
+		return nil, errcode.ErrAuthServiceAccount
+	}
+
+	ttl := time.Duration(account.TokenTTL) * time.Second
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	token, err := l.svcCtx.JWTKeys.GenServiceToken(jwt.JwtPayLoad{Nickname: user.NickName, UUID: user.UUID},
+		ttl, jwt.ScopeBot)
+	if err != nil {
+		logx.Errorf("生成token失败：%v", err)
+		return nil, errcode.ErrAuthTokenFailed.WithError(err)
+	}
+
+	// 与登录相同写入会话，网关鉴权时据此校验令牌是否为最新签发
+	session := map[string]any{
+		"uuid":        user.UUID,
+		"nickname":    user.NickName,
+		"token":       token,
+		"scope":       jwt.ScopeBot,
+		"last_active": time.Now().Format("2006-01-02 15:04:05"),
+	}
+	b, err := json.Marshal(session)
+	if err != nil {
+		logx.Errorf("序列化会话信息失败：%v", err)
+		return nil, errcode.ErrJsonMarshal.WithError(err)
+	}
+	if err := l.svcCtx.Redis.Set(sessionKey(user.UUID), string(b), ttl).Err(); err != nil {
+		logx.Errorf("存储key于redis失败：%v", err)
+		return nil, errcode.ErrRedisSet.WithError(err)
+	}
+
+	return &types.ServiceTokenResp{
+		UUID:        user.UUID,
+		AccessToken: token,
+		ExpiresIn:   int64(ttl / time.Second),
+		Scope:       jwt.ScopeBot,
+	}, nil
+}
+
+// ensureUser 查询服务账号对应的用户，不存在时创建
+func (l *ServiceTokenLogic) ensureUser(account config.ServiceAccount) (*model.User, error) {
+	user, err := dao.User.WithContext(l.ctx).Where(dao.User.UUID.Eq(account.ClientId)).Take()
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errcode.ErrDataQueryFail.WithError(err)
+	}
+	user = &model.User{
+		UUID:     account.ClientId,
+		NickName: account.Name,
+		Source:   userSourceBot,
+	}
+	if user.NickName == "" {
+		user.NickName = account.ClientId
+	}
+	if err := dao.User.WithContext(l.ctx).Create(user); err != nil {
+		return nil, errcode.ErrDataCreateFail.WithError(err)
+	}
+	logx.Infof("创建服务账号用户：%s", user.UUID)
+	return user, nil
+}
+
+// findServiceAccount 按ClientId查找配置的服务账号
+func findServiceAccount(accounts []config.ServiceAccount, clientID string) (config.ServiceAccount, bool) {
+	if clientID == "" {
+		return config.ServiceAccount{}, false
+	}
+	for _, account := range accounts {
+		if account.ClientId == clientID {
+			return account, true
+		}
+	}
+	return config.ServiceAccount{}, false
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"imy/internal/svc"
+	ws "imy/pkg/websocket"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// kickSession 通知会话的WebSocket连接强制下线并关闭连接，返回关闭的连接数
+// v1连接收到 {op:"force_logout"}，v2连接收到logout信封
+func kickSession(svcCtx *svc.ServiceContext, uid, sessionID, reason string) int {
+	payload := ws.LogoutPayload{SessionID: sessionID, Reason: reason}
+	env, err := ws.NewEnvelope(ws.EnvelopeLogout, 0, payload)
+	if err != nil {
+		logx.Errorf("构造下线通知失败：%v", err)
+		return 0
+	}
+	legacy := map[string]any{"op": "force_logout", "data": payload}
+	return svcCtx.Ws.CloseSession(uid, sessionID, legacy, env)
+}
+
+// revokeSession 删除会话并让其设备下线，返回会话是否存在与关闭的连接数
+// 会话删除后其刷新令牌失效，网关转发的请求也会因会话不存在被拒绝
+func revokeSession(svcCtx *svc.ServiceContext, uid, sessionID, reason string) (bool, int, error) {
+	existed, err := svcCtx.Sessions.Delete(uid, sessionID)
+	if err != nil {
+		return false, 0, err
+	}
+	return existed, kickSession(svcCtx, uid, sessionID, reason), nil
+}
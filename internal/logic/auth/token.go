@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"imy/internal/config"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/pkg/httpx"
+	"imy/pkg/jwt"
+	ws "imy/pkg/websocket"
+
+	"github.com/google/uuid"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// tokenPair 一次签发的访问令牌与刷新令牌
+type tokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // 访问令牌有效期（秒）
+	SessionID    string
+}
+
+// sessionKey 旧版单会话在redis中的键，服务账号与升级前签发的令牌仍使用
+func sessionKey(uuid string) string {
+	return fmt.Sprintf("login_%s", uuid)
+}
+
+// sessionTTL 登录会话有效期，与刷新令牌一致，会话过期后只能重新登录
+func sessionTTL(c config.Auth) time.Duration {
+	if c.RefreshTTL <= 0 {
+		return 24 * time.Hour
+	}
+	return time.Duration(c.RefreshTTL) * time.Second
+}
+
+// accessTTL 访问令牌有效期
+func accessTTL(c config.Auth) time.Duration {
+	if c.AccessTTL <= 0 {
+		return 15 * time.Minute
+	}
+	return time.Duration(c.AccessTTL) * time.Second
+}
+
+// maxDeviceLen 设备名称的最大长度
+const maxDeviceLen = 64
+
+// startSession 为一次登录创建会话并签发令牌，每次登录都是独立的会话，可以单独吊销
+// 超出会话数量上限时最久未活跃的会话被淘汰，其设备随即下线
+func startSession(ctx context.Context, svcCtx *svc.ServiceContext, uid, email, nickname, device string) (*tokenPair, error) {
+	sess := &svc.Session{
+		ID:        uuid.NewString(),
+		UUID:      uid,
+		Email:     email,
+		Nickname:  nickname,
+		Device:    strings.TrimSpace(device),
+		CreatedAt: time.Now().Unix(),
+	}
+	if r, ok := httpx.GetRequest(ctx); ok {
+		sess.IP = svc.RequestIP(r)
+		sess.UserAgent = r.UserAgent()
+	}
+	if runes := []rune(sess.Device); len(runes) > maxDeviceLen {
+		sess.Device = string(runes[:maxDeviceLen])
+	}
+
+	tokens, err := issueTokens(ctx, svcCtx, sess)
+	if err != nil {
+		return nil, err
+	}
+	evicted, err := svcCtx.Sessions.Create(sess, sessionTTL(svcCtx.Config.Auth))
+	if err != nil {
+		logx.Errorf("存储会话失败：%v", err)
+		return nil, errcode.ErrRedisSet.WithError(err)
+	}
+	for _, id := range evicted {
+		logx.Infof("会话数超出上限，淘汰会话：%s/%s", uid, id)
+		kickSession(svcCtx, uid, id, ws.LogoutEvicted)
+	}
+	writeTokenHeaders(ctx, tokens)
+	return tokens, nil
+}
+
+// renewSession 刷新时为会话签发新令牌，会话已被吊销时刷新失败
+func renewSession(ctx context.Context, svcCtx *svc.ServiceContext, sess *svc.Session) (*tokenPair, error) {
+	tokens, err := issueTokens(ctx, svcCtx, sess)
+	if err != nil {
+		return nil, err
+	}
+	if err := svcCtx.Sessions.Update(sess, sessionTTL(svcCtx.Config.Auth)); err != nil {
+		if errors.Is(err, svc.ErrSessionNotFound) {
+			return nil, errcode.ErrAuthTokenUseless
+		}
+		logx.Errorf("存储会话失败：%v", err)
+		return nil, errcode.ErrRedisSet.WithError(err)
+	}
+	writeTokenHeaders(ctx, tokens)
+	return tokens, nil
+}
+
+// writeTokenHeaders 在响应头中写入 token，便于客户端后续携带
+func writeTokenHeaders(ctx context.Context, tokens *tokenPair) {
+	if w, ok := httpx.GetResponse(ctx); ok {
+		w.Header().Set("token", tokens.AccessToken)
+		w.Header().Set("Authorization", "Bearer "+tokens.AccessToken)
+		w.Header().Set("Refresh-Token", tokens.RefreshToken)
+	}
+}
+
+// issueTokens 签发带会话ID的访问令牌和新的刷新令牌，并把新刷新令牌的ID记入会话
+// 会话中只保存当前有效的刷新令牌ID，旧的刷新令牌随之失效；会话由调用方保存
+func issueTokens(ctx context.Context, svcCtx *svc.ServiceContext, sess *svc.Session) (*tokenPair, error) {
+	c := svcCtx.Config.Auth
+	payload := jwt.JwtPayLoad{Nickname: sess.Nickname, UUID: sess.UUID, Session: sess.ID}
+
+	access, err := svcCtx.JWTKeys.GenAccessToken(payload, accessTTL(c))
+	if err != nil {
+		logx.Errorf("生成token失败：%v", err)
+		return nil, errcode.ErrAuthTokenFailed.WithError(err)
+	}
+	refreshID := uuid.NewString()
+	refresh, err := jwt.GenRefreshToken(payload, c.AccessSecret, sessionTTL(c), refreshID)
+	if err != nil {
+		logx.Errorf("生成刷新token失败：%v", err)
+		return nil, errcode.ErrAuthTokenFailed.WithError(err)
+	}
+	sess.RefreshID = refreshID
+	sess.ExpiresAt = time.Now().Add(sessionTTL(c)).Unix()
+
+	return &tokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int64(accessTTL(c) / time.Second),
+		SessionID:    sess.ID,
+	}, nil
+}
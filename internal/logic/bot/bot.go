@@ -0,0 +1,20 @@
+package bot
+
+import (
+	"imy/internal/errcode"
+	"imy/internal/svc"
+)
+
+// msgTypeSystem 系统消息，机器人不能发送
+const msgTypeSystem = 6
+
+// checkServiceAccount 机器人接口只对配置的服务账号开放
+// 网关已限制bot范围的令牌只能访问机器人接口，这里再拒绝普通用户调用
+func checkServiceAccount(svcCtx *svc.ServiceContext, uuid string) error {
+	for _, account := range svcCtx.Config.ServiceAccounts {
+		if account.ClientId == uuid {
+			return nil
+		}
+	}
+	return errcode.ErrAuthServiceAccount
+}
@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"context"
+
+	"imy/internal/logic/chat"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetConversationsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 获取机器人所在的会话
+func NewGetConversationsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetConversationsLogic {
+	return &GetConversationsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetConversations 机器人据此发现自己被加入的会话
+func (l *GetConversationsLogic) GetConversations(req *types.GetConversationsReq) (resp *types.GetConversationsResp, err error) {
+	if err := checkServiceAccount(l.svcCtx, req.UUID); err != nil {
+		return nil, err
+	}
+	return chat.NewGetConversationsLogic(l.ctx, l.svcCtx).GetConversations(req)
+}
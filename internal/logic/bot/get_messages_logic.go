@@ -0,0 +1,34 @@
+package bot
+
+import (
+	"context"
+
+	"imy/internal/logic/chat"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetMessagesLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 拉取机器人所在会话的消息
+func NewGetMessagesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetMessagesLogic {
+	return &GetMessagesLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetMessages 与用户拉取消息相同，只能读取机器人已加入的会话
+func (l *GetMessagesLogic) GetMessages(req *types.GetMessagesReq) (resp *types.GetMessagesResp, err error) {
+	if err := checkServiceAccount(l.svcCtx, req.UUID); err != nil {
+		return nil, err
+	}
+	return chat.NewGetMessagesLogic(l.ctx, l.svcCtx).GetMessages(req)
+}
@@ -0,0 +1,41 @@
+package bot
+
+import (
+	"context"
+
+	"imy/internal/errcode"
+	"imy/internal/logic/chat"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type SendMessageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 机器人向所在会话发送消息
+func NewSendMessageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SendMessageLogic {
+	return &SendMessageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// SendMessage 消息类型缺省为文本，成员校验、幂等和内容审核都复用用户发送消息的逻辑
+func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.SendMessageResp, err error) {
+	if err := checkServiceAccount(l.svcCtx, req.UUID); err != nil {
+		return nil, err
+	}
+	if req.MsgType == 0 {
+		req.MsgType = 1
+	}
+	if req.MsgType == msgTypeSystem {
+		return nil, errcode.ErrInvalidParam
+	}
+	return chat.NewSendMessageLogic(l.ctx, l.svcCtx).SendMessage(req)
+}
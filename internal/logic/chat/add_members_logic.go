@@ -2,16 +2,15 @@ package chat
 
 import (
 	"context"
-	"errors"
 
 	"imy/internal/dao"
 	"imy/internal/dao/model"
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
+	"imy/pkg/events"
 
 	"github.com/zeromicro/go-zero/core/logx"
-	"gorm.io/gorm"
 )
 
 type AddMembersLogic struct {
@@ -35,16 +34,9 @@ func (l *AddMembersLogic) AddMembers(req *types.AddMembersReq) error {
 		return errcode.ErrInvalidParam
 	}
 
-	// 校验操作者是会话成员
-	if _, e := dao.ChatConversationMember.WithContext(l.ctx).
-		Where(
-			dao.ChatConversationMember.ConversationID.Eq(req.ConversationId), 
-			dao.ChatConversationMember.UserUUID.Eq(req.UUID)).
-		Take(); e != nil {
-		if errors.Is(e, gorm.ErrRecordNotFound) {
-			return errcode.ErrAuthSession
-		}
-		return errcode.ErrDataQueryFail.WithError(e)
+	// 只有群主和管理员可以拉人进群
+	if _, _, e := loadGroupRole(l.ctx, req.ConversationId, req.UUID, memberRoleAdmin); e != nil {
+		return e
 	}
 
 	// 查询已存在成员集合
@@ -81,6 +73,12 @@ func (l *AddMembersLogic) AddMembers(req *types.AddMembersReq) error {
 		}
 		// 更新成员数（忽略错误）
 		_ = dao.ChatConversation.Update(l.ctx, &model.ChatConversation{ID: req.ConversationId, MemberCount: uint32(len(existMembers) + len(toCreate))}, "MemberCount")
+
+		added := make([]string, 0, len(toCreate))
+		for _, m := range toCreate {
+			added = append(added, m.UserUUID)
+		}
+		publishEvent(l.svcCtx, "", events.TypeMemberAdded, req.ConversationId, events.MemberData{Members: added, Operator: req.UUID})
 	}
 
 	// 广播 member_added 事件给群内所有成员
@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"imy/internal/errcode"
+	"imy/internal/types"
+	"imy/pkg/blob"
+)
+
+// msgTypeAttachment 附件消息，content 为 AttachmentInfo 的 JSON
+const msgTypeAttachment = 7
+
+// defaultAttachmentMaxSize 未配置时单个附件的最大字节数
+const defaultAttachmentMaxSize = 20 << 20
+
+// attachmentKey 生成附件对象键：conv/{会话ID}/{唯一ID}{扩展名}
+// 键中带有会话ID，下载时据此校验请求者是否为会话成员
+func attachmentKey(conversationID uint32, id string, filename string) string {
+	return fmt.Sprintf("conv/%d/%s%s", conversationID, id, attachmentExt(filename))
+}
+
+// attachmentExt 取文件扩展名，只保留短的字母数字扩展名
+func attachmentExt(filename string) string {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if len(ext) < 2 || len(ext) > 10 {
+		return ""
+	}
+	for _, c := range ext[1:] {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+// attachmentBelongsTo 校验对象键属于该会话
+func attachmentBelongsTo(key string, conversationID uint32) bool {
+	cleaned, err := blob.CleanKey(key)
+	if err != nil || cleaned != key {
+		return false
+	}
+	return strings.HasPrefix(key, fmt.Sprintf("conv/%d/", conversationID))
+}
+
+// attachmentURL 附件下载地址
+func attachmentURL(conversationID uint32, key, name string) string {
+	query := url.Values{}
+	query.Set("conversationId", fmt.Sprint(conversationID))
+	query.Set("key", key)
+	query.Set("name", name)
+	return "/api/chat/downloadAttachment?" + query.Encode()
+}
+
+// parseAttachmentContent 校验附件消息的内容，附件必须是上传到该会话的对象
+func parseAttachmentContent(content string, conversationID uint32) (*types.AttachmentInfo, error) {
+	var info types.AttachmentInfo
+	if err := json.Unmarshal([]byte(content), &info); err != nil {
+		return nil, errcode.ErrInvalidParam.WithError(err)
+	}
+	if info.Name == "" || info.Size <= 0 || !attachmentBelongsTo(info.Key, conversationID) {
+		return nil, errcode.ErrInvalidParam
+	}
+	return &info, nil
+}
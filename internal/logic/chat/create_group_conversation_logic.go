@@ -2,6 +2,7 @@ package chat
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"imy/internal/dao"
@@ -9,6 +10,7 @@ import (
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
+	"imy/pkg/events"
 	"imy/pkg/utils"
 
 	"github.com/zeromicro/go-zero/core/logx"
@@ -52,7 +54,7 @@ func (l *CreateGroupConversationLogic) CreateGroupConversation(req *types.Create
 
 	// 组装成员：创建者 + 传入成员（去重，忽略创建者重复）
 	set := map[string]struct{}{req.UUID: {}}
-	members := []*model.ChatConversationMember{{ConversationID: conv.ID, UserUUID: req.UUID, Role: 1}}
+	members := []*model.ChatConversationMember{{ConversationID: conv.ID, UserUUID: req.UUID, Role: memberRoleOwner}}
 	for _, u := range req.MemberUUIDs {
 		if u == "" {
 			continue
@@ -73,6 +75,18 @@ func (l *CreateGroupConversationLogic) CreateGroupConversation(req *types.Create
 	conv.MemberCount = uint32(len(members))
 	_ = dao.ChatConversation.Update(l.ctx, &model.ChatConversation{ID: conv.ID, MemberCount: conv.MemberCount}, "MemberCount")
 
+	// 发布会话创建事件
+	uuids := make([]string, 0, len(members))
+	for _, m := range members {
+		uuids = append(uuids, m.UserUUID)
+	}
+	publishEvent(l.svcCtx, "conversation/"+strconv.FormatUint(uint64(conv.ID), 10), events.TypeConversationCreated, conv.ID, events.ConversationData{
+		Type:    uint32(conv.Type),
+		Name:    conv.Name,
+		Creator: req.UUID,
+		Members: uuids,
+	})
+
 	// 装配返回
 	resp = &types.ConversationInfo{
 		ConversationId: conv.ID,
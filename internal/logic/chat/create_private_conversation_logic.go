@@ -4,13 +4,16 @@ import (
 	"context"
 	"errors"
 	"sort"
+	"strconv"
 	"strings"
 
 	"imy/internal/dao"
 	"imy/internal/dao/model"
 	"imy/internal/errcode"
+	"imy/internal/logic/friend"
 	"imy/internal/svc"
 	"imy/internal/types"
+	"imy/pkg/events"
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"gorm.io/gorm"
@@ -36,6 +39,10 @@ func (l *CreatePrivateConversationLogic) CreatePrivateConversation(req *types.Cr
 	if req.UUID == "" || req.PeerUUID == "" || req.UUID == req.PeerUUID {
 		return nil, errcode.ErrInvalidParam
 	}
+	// 只能与好友单聊，任意一方拉黑对方后不能再打开单聊
+	if err := friend.CheckPrivateChat(l.ctx, req.UUID, req.PeerUUID); err != nil {
+		return nil, err
+	}
 
 	// 规范化单聊 private_key：按字典序拼接，确保唯一且无序
 	pair := []string{req.UUID, req.PeerUUID}
@@ -74,6 +81,12 @@ func (l *CreatePrivateConversationLogic) CreatePrivateConversation(req *types.Cr
 		if me := dao.ChatConversationMember.WithContext(l.ctx).CreateInBatches(members, 2); me != nil {
 			return nil, errcode.ErrDataCreateFail.WithError(me)
 		}
+		// 只有新建的会话发布创建事件
+		publishEvent(l.svcCtx, "conversation/"+strconv.FormatUint(uint64(conv.ID), 10), events.TypeConversationCreated, conv.ID, events.ConversationData{
+			Type:    uint32(conv.Type),
+			Creator: req.UUID,
+			Members: []string{req.UUID, req.PeerUUID},
+		})
 	} else {
 		// 确保两个成员都在会话内（容错补齐）
 		for _, u := range []string{req.UUID, req.PeerUUID} {
@@ -0,0 +1,69 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DeleteMessageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 删除消息
+func NewDeleteMessageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DeleteMessageLogic {
+	return &DeleteMessageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *DeleteMessageLogic) DeleteMessage(req *types.DeleteMessageReq) error {
+	// 1) 参数校验
+	if req.UUID == "" || req.ConversationId == 0 || req.MessageId == 0 {
+		return errcode.ErrInvalidParam
+	}
+
+	// 2) 校验会话成员并读取消息
+	msg, mem, err := loadMessageForMutation(l.ctx, req.ConversationId, req.MessageId, req.UUID)
+	if err != nil {
+		return err
+	}
+
+	// 3) 发送者本人或管理员可以删除
+	if msg.SendUUID != req.UUID && mem.Role < memberRoleAdmin {
+		return errcode.ErrAuth
+	}
+
+	// 4) 软删除，拉取历史消息时不再返回
+	if e := dao.ChatMessage.DeleteByID(l.ctx, msg.ID); e != nil {
+		return errcode.ErrDataDeleteFail.WithError(e)
+	}
+
+	// 5) 广播消息删除事件给会话内所有成员
+	payload := struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32 `json:"conversationId"`
+			MessageId      uint64 `json:"messageId"`
+			OperatorUuid   string `json:"operatorUuid"`
+			DeletedAt      string `json:"deletedAt"`
+		} `json:"data"`
+	}{Op: "message_deleted"}
+	payload.Data.ConversationId = req.ConversationId
+	payload.Data.MessageId = req.MessageId
+	payload.Data.OperatorUuid = req.UUID
+	payload.Data.DeletedAt = time.Now().UTC().Format(time.RFC3339)
+	go broadcastToConversation(l.svcCtx, req.ConversationId, payload)
+
+	return nil
+}
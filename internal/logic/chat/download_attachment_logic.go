@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strconv"
+
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+	"imy/pkg/blob"
+
+	xhttp "imy/pkg/httpx"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type DownloadAttachmentLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 下载附件
+func NewDownloadAttachmentLogic(ctx context.Context, svcCtx *svc.ServiceContext) *DownloadAttachmentLogic {
+	return &DownloadAttachmentLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *DownloadAttachmentLogic) DownloadAttachment(req *types.DownloadAttachmentReq) error {
+	// 1) 参数校验，附件必须属于请求的会话
+	if req.UUID == "" || req.ConversationId == 0 || !attachmentBelongsTo(req.Key, req.ConversationId) {
+		return errcode.ErrInvalidParam
+	}
+
+	// 2) 校验是否会话成员
+	if _, err := checkConversationMember(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return err
+	}
+
+	// 3) 读取附件
+	rc, info, e := l.svcCtx.Blob.Get(l.ctx, req.Key)
+	if e != nil {
+		if errors.Is(e, blob.ErrNotFound) {
+			return errcode.ErrFileNotFund
+		}
+		return errcode.ErrFileOpenFail.WithError(e)
+	}
+	defer rc.Close()
+
+	w, ok := xhttp.GetResponse(l.ctx)
+	if !ok {
+		return errcode.ErrInternalErr
+	}
+
+	// 4) 流式写回响应
+	name := req.Name
+	if name == "" {
+		name = path.Base(req.Key)
+	}
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(name)))
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	if info.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+	}
+	if _, e := io.Copy(w, rc); e != nil {
+		l.Errorf("download attachment %s failed: %v", req.Key, e)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type EditMessageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 编辑消息
+func NewEditMessageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *EditMessageLogic {
+	return &EditMessageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *EditMessageLogic) EditMessage(req *types.EditMessageReq) error {
+	// 1) 参数校验
+	if req.UUID == "" || req.ConversationId == 0 || req.MessageId == 0 || req.Content == "" {
+		return errcode.ErrInvalidParam
+	}
+
+	// 2) 校验会话成员并读取消息
+	msg, _, err := loadMessageForMutation(l.ctx, req.ConversationId, req.MessageId, req.UUID)
+	if err != nil {
+		return err
+	}
+
+	// 3) 只有发送者本人可以编辑，系统消息和已撤回的消息不可编辑
+	if msg.SendUUID != req.UUID {
+		return errcode.ErrAuth
+	}
+	if msg.IsSystem || msg.IsRevoked {
+		return errcode.ErrInvalidParam
+	}
+
+	// 3.1) 编辑后的内容同样经过审核
+	review := newReviewMessage(req.ConversationId, req.UUID, uint32(msg.MsgType), req.Content, nil)
+	content, err := moderateContent(l.ctx, l.svcCtx, review)
+	if err != nil {
+		return err
+	}
+
+	// 4) 更新消息内容
+	msg.Content = content
+	msg.ContentExtra = req.ContentExtra
+	if e := dao.ChatMessage.Update(l.ctx, msg, "Content", "ContentExtra"); e != nil {
+		return errcode.ErrDataModifyFail.WithError(e)
+	}
+	reviewAfterWrite(l.svcCtx, review, msg)
+
+	// 5) 广播消息编辑事件给会话内所有成员
+	payload := struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32 `json:"conversationId"`
+			MessageId      uint64 `json:"messageId"`
+			OperatorUuid   string `json:"operatorUuid"`
+			Content        string `json:"content"`
+			ContentExtra   string `json:"contentExtra"`
+			EditedAt       string `json:"editedAt"`
+		} `json:"data"`
+	}{Op: "message_edited"}
+	payload.Data.ConversationId = req.ConversationId
+	payload.Data.MessageId = req.MessageId
+	payload.Data.OperatorUuid = req.UUID
+	payload.Data.Content = msg.Content
+	payload.Data.ContentExtra = msg.ContentExtra
+	payload.Data.EditedAt = time.Now().UTC().Format(time.RFC3339)
+	go broadcastToConversation(l.svcCtx, req.ConversationId, payload)
+
+	return nil
+}
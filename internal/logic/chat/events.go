@@ -0,0 +1,36 @@
+package chat
+
+import (
+	"strconv"
+
+	"imy/internal/dao/model"
+	"imy/internal/svc"
+	"imy/pkg/events"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// publishEvent 向外部系统发布事件（失败只记录日志，不影响业务流程）；id 为空时随机生成
+func publishEvent(svcCtx *svc.ServiceContext, id string, eventType events.Type, conversationID uint32, data any) {
+	if svcCtx.Events == nil {
+		return
+	}
+	event, err := events.NewEvent(id, eventType, strconv.FormatUint(uint64(conversationID), 10), data)
+	if err == nil {
+		err = svcCtx.Events.Publish(event)
+	}
+	if err != nil {
+		logx.Errorf("publish %s event of conversation %d failed: %v", eventType, conversationID, err)
+	}
+}
+
+// publishMessageCreated 发布新消息事件，事件ID与消息ID对应，便于消费方去重
+func publishMessageCreated(svcCtx *svc.ServiceContext, msg *model.ChatMessage, mentions []string) {
+	publishEvent(svcCtx, "message/"+strconv.FormatUint(msg.ID, 10), events.TypeMessageCreated, msg.ConversationID, events.MessageData{
+		MessageID: strconv.FormatUint(msg.ID, 10),
+		SenderID:  msg.SendUUID,
+		MsgType:   uint32(msg.MsgType),
+		Content:   msg.Content,
+		Mentions:  mentions,
+	})
+}
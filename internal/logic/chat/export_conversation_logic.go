@@ -0,0 +1,155 @@
+package chat
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	xhttp "imy/pkg/httpx"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// exportBatchSize 导出时每批读取的消息数，按消息ID游标翻页，避免一次加载整个会话
+const exportBatchSize = 500
+
+// exportCSVHeader CSV 导出的表头，与 MessageInfo 字段一一对应
+var exportCSVHeader = []string{
+	"id", "conversationId", "sendUuid", "msgType", "content", "contentExtra",
+	"replyToMessageId", "mentionedUuids", "isSystem", "isRevoked", "createdAt",
+}
+
+type ExportConversationLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 导出会话完整历史（流式 NDJSON/CSV）
+func NewExportConversationLogic(ctx context.Context, svcCtx *svc.ServiceContext) *ExportConversationLogic {
+	return &ExportConversationLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *ExportConversationLogic) ExportConversation(req *types.ExportConversationReq) error {
+	// 1) 参数校验
+	if req.UUID == "" || req.ConversationId == 0 {
+		return errcode.ErrInvalidParam
+	}
+	if req.Format != "ndjson" && req.Format != "csv" {
+		return errcode.ErrInvalidParam
+	}
+
+	// 2) 校验是否会话成员
+	if _, err := checkConversationMember(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return err
+	}
+
+	w, ok := xhttp.GetResponse(l.ctx)
+	if !ok {
+		return errcode.ErrInternalErr
+	}
+
+	// 3) 按消息ID游标分批读取并写出，首批读取成功后才写响应头，失败时仍可返回 JSON 错误
+	var (
+		afterID uint64
+		started bool
+		csvw    *csv.Writer
+		enc     *json.Encoder
+	)
+	rc := http.NewResponseController(w)
+	for {
+		list, e := l.nextBatch(req.ConversationId, afterID)
+		if e != nil {
+			if !started {
+				return errcode.ErrDataQueryFail.WithError(e)
+			}
+			// 响应已开始输出，只能中断流
+			l.Errorf("export conversation %d failed after id %d: %v", req.ConversationId, afterID, e)
+			return nil
+		}
+
+		if !started {
+			started = true
+			ext, contentType := "ndjson", "application/x-ndjson"
+			if req.Format == "csv" {
+				ext, contentType = "csv", "text/csv; charset=utf-8"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=conversation-%d.%s", req.ConversationId, ext))
+			if req.Format == "csv" {
+				csvw = csv.NewWriter(w)
+				if e := csvw.Write(exportCSVHeader); e != nil {
+					return nil
+				}
+			} else {
+				enc = json.NewEncoder(w)
+			}
+		}
+
+		for _, m := range list {
+			info := messageInfoFromModel(m)
+			if csvw != nil {
+				e = csvw.Write(exportCSVRow(info))
+			} else {
+				e = enc.Encode(info)
+			}
+			if e != nil {
+				// 客户端断开
+				return nil
+			}
+		}
+		if csvw != nil {
+			csvw.Flush()
+		}
+		_ = rc.Flush()
+
+		if len(list) < exportBatchSize {
+			break
+		}
+		afterID = list[len(list)-1].ID
+	}
+	return nil
+}
+
+// nextBatch 读取消息ID大于 afterID 的下一批消息
+func (l *ExportConversationLogic) nextBatch(conversationID uint32, afterID uint64) ([]*model.ChatMessage, error) {
+	return dao.ChatMessage.WithContext(l.ctx).
+		Where(
+			dao.ChatMessage.ConversationID.Eq(conversationID),
+			dao.ChatMessage.ID.Gt(afterID),
+		).
+		Order(dao.ChatMessage.ID.Asc()).
+		Limit(exportBatchSize).
+		Find()
+}
+
+// exportCSVRow 将消息转换为一行 CSV
+func exportCSVRow(m types.MessageInfo) []string {
+	return []string{
+		strconv.FormatUint(m.Id, 10),
+		strconv.FormatUint(uint64(m.ConversationId), 10),
+		m.SendUuid,
+		strconv.FormatUint(uint64(m.MsgType), 10),
+		m.Content,
+		m.ContentExtra,
+		strconv.FormatUint(m.ReplyToMessageId, 10),
+		strings.Join(m.MentionedUuids, ","),
+		strconv.FormatUint(uint64(m.IsSystem), 10),
+		strconv.FormatUint(uint64(m.IsRevoked), 10),
+		m.CreatedAt,
+	}
+}
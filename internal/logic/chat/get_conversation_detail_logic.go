@@ -55,14 +55,15 @@ func (l *GetConversationDetailLogic) GetConversationDetail(req *types.GetConvers
 	}
 
 	info := types.ConversationInfo{
-		ConversationId: conv.ID,
-		Type:           uint32(conv.Type),
-		PrivateKey:     conv.PrivateKey,
-		Name:           conv.Name,
-		MemberCount:    conv.MemberCount,
-		LastMessageId:  conv.LastMessageID,
-		Avatar:         conv.Avatar,
-		Extra:          conv.Extra,
+		ConversationId:   conv.ID,
+		Type:             uint32(conv.Type),
+		PrivateKey:       conv.PrivateKey,
+		Name:             conv.Name,
+		MemberCount:      conv.MemberCount,
+		LastMessageId:    conv.LastMessageID,
+		Avatar:           conv.Avatar,
+		Extra:            conv.Extra,
+		AnnouncementOnly: ternary(conv.AnnouncementOnly, uint32(1), uint32(0)),
 	}
 
 	// 获取成员列表
@@ -73,11 +74,20 @@ func (l *GetConversationDetailLogic) GetConversationDetail(req *types.GetConvers
 		return nil, errcode.ErrDataQueryFail.WithError(e)
 	}
 
+	// 没有群主的旧群由创建者担任群主
+	hasOwner := false
+	for _, m := range members {
+		hasOwner = hasOwner || m.Role == memberRoleOwner
+	}
 	list := make([]types.ConversationMember, 0, len(members))
 	for _, m := range members {
+		role := m.Role
+		if !hasOwner && conv.Type == conversationTypeGroup && m.UserUUID == conv.CreateUUID {
+			role = memberRoleOwner
+		}
 		list = append(list, types.ConversationMember{
 			UserUUID:  m.UserUUID,
-			Role:      uint32(m.Role),
+			Role:      uint32(role),
 			Alias:     m.Alias_,
 			MuteUntil: m.MuteUntil.UTC().Format(time.RFC3339),
 			IsPinned:  func(b bool) uint32 { if b { return 1 }; return 0 }(m.IsPinned),
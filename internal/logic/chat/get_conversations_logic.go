@@ -57,14 +57,15 @@ func (l *GetConversationsLogic) GetConversations(req *types.GetConversationsReq)
 	list := make([]types.ConversationInfo, 0, len(convs))
 	for _, c := range convs {
 		list = append(list, types.ConversationInfo{
-			ConversationId: c.ID,
-			Type:           uint32(c.Type),
-			PrivateKey:     c.PrivateKey,
-			Name:           c.Name,
-			MemberCount:    c.MemberCount,
-			LastMessageId:  c.LastMessageID,
-			Avatar:         c.Avatar,
-			Extra:          c.Extra,
+			ConversationId:   c.ID,
+			Type:             uint32(c.Type),
+			PrivateKey:       c.PrivateKey,
+			Name:             c.Name,
+			MemberCount:      c.MemberCount,
+			LastMessageId:    c.LastMessageID,
+			Avatar:           c.Avatar,
+			Extra:            c.Extra,
+			AnnouncementOnly: ternary(c.AnnouncementOnly, uint32(1), uint32(0)),
 		})
 	}
 
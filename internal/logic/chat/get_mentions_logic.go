@@ -0,0 +1,127 @@
+package chat
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetMentionsLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 获取提及我的消息
+func NewGetMentionsLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetMentionsLogic {
+	return &GetMentionsLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetMentions 按消息ID倒序返回提及当前用户的消息，并统计各会话已读进度之后的提及数
+// 只返回用户仍在的会话中的提及
+func (l *GetMentionsLogic) GetMentions(req *types.GetMentionsReq) (resp *types.GetMentionsResp, err error) {
+	if req.UUID == "" {
+		return nil, errcode.ErrInvalidParam
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 50 {
+		limit = 50
+	}
+
+	// 1) 用户所在会话的已读进度
+	mq := dao.ChatConversationMember.WithContext(l.ctx).Where(dao.ChatConversationMember.UserUUID.Eq(req.UUID))
+	if req.ConversationId > 0 {
+		mq = mq.Where(dao.ChatConversationMember.ConversationID.Eq(req.ConversationId))
+	}
+	members, e := mq.Find()
+	if e != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	if req.ConversationId > 0 && len(members) == 0 {
+		return nil, errcode.ErrAuthSession
+	}
+	resp = &types.GetMentionsResp{Mentions: []types.MentionInfo{}, UnreadCounts: []types.MentionUnreadItem{}}
+	if len(members) == 0 {
+		return resp, nil
+	}
+
+	// 2) 各会话的未读提及数
+	mention := dao.ChatMention
+	lastRead := make(map[uint32]uint64, len(members))
+	convIDs := make([]uint32, 0, len(members))
+	for _, m := range members {
+		lastRead[m.ConversationID] = m.LastReadMessageID
+		convIDs = append(convIDs, m.ConversationID)
+		cnt, e := mention.WithContext(l.ctx).
+			Where(
+				mention.MentionUUID.Eq(req.UUID),
+				mention.ConversationID.Eq(m.ConversationID),
+				mention.MessageID.Gt(m.LastReadMessageID),
+			).
+			Count()
+		if e != nil {
+			return nil, errcode.ErrDataQueryFail.WithError(e)
+		}
+		if cnt > 0 {
+			resp.UnreadCounts = append(resp.UnreadCounts, types.MentionUnreadItem{
+				ConversationId: m.ConversationID,
+				Unread:         uint32(cnt),
+			})
+		}
+	}
+
+	// 3) 分页拉取提及记录，多取一条判断是否还有更多
+	q := mention.WithContext(l.ctx).Where(mention.MentionUUID.Eq(req.UUID), mention.ConversationID.In(convIDs...))
+	if req.BeforeId > 0 {
+		q = q.Where(mention.MessageID.Lt(req.BeforeId))
+	}
+	rows, e := q.Order(mention.MessageID.Desc()).Limit(limit + 1).Find()
+	if e != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		resp.HasMore = true
+	}
+	if len(rows) == 0 {
+		return resp, nil
+	}
+
+	// 4) 加载消息并按提及记录的顺序返回
+	ids := make([]uint64, 0, len(rows))
+	for _, r := range rows {
+		ids = append(ids, r.MessageID)
+	}
+	msgs, e := dao.ChatMessage.WithContext(l.ctx).Where(dao.ChatMessage.ID.In(ids...)).Find()
+	if e != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	byID := make(map[uint64]*model.ChatMessage, len(msgs))
+	for _, m := range msgs {
+		byID[m.ID] = m
+	}
+	for _, r := range rows {
+		m, ok := byID[r.MessageID]
+		if !ok {
+			continue
+		}
+		resp.Mentions = append(resp.Mentions, types.MentionInfo{
+			Message: messageInfoFromModel(m),
+			IsRead:  ternary(m.ID <= lastRead[r.ConversationID], uint32(1), uint32(0)),
+		})
+	}
+	return resp, nil
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"imy/internal/dao"
+	"imy/internal/dao/model"
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
@@ -83,24 +84,32 @@ func (l *GetMessagesLogic) GetMessages(req *types.GetMessagesReq) (resp *types.G
 	// 5) 映射为响应
 	msgs := make([]types.MessageInfo, 0, len(list))
 	for _, m := range list {
-		var mentioned []string
-		if m.MentionedUuids != "" {
-			mentioned = strings.Split(m.MentionedUuids, ",")
-		}
-		msgs = append(msgs, types.MessageInfo{
-			Id:               m.ID,
-			ConversationId:   m.ConversationID,
-			SendUuid:         m.SendUUID,
-			MsgType:          uint32(m.MsgType),
-			Content:          m.Content,
-			ContentExtra:     m.ContentExtra,
-			ReplyToMessageId: m.ReplyToMessageID,
-			MentionedUuids:   mentioned,
-			IsSystem:         ternary(m.IsSystem, uint32(1), uint32(0)),
-			IsRevoked:        ternary(m.IsRevoked, uint32(1), uint32(0)),
-			CreatedAt:        m.CreatedAt.UTC().Format(time.RFC3339),
-		})
+		msgs = append(msgs, messageInfoFromModel(m))
+	}
+	if err := fillReplyCounts(l.ctx, req.ConversationId, msgs); err != nil {
+		return nil, err
 	}
 
 	return &types.GetMessagesResp{Messages: msgs}, nil
 }
+
+// messageInfoFromModel 将消息记录转换为接口返回的消息结构
+func messageInfoFromModel(m *model.ChatMessage) types.MessageInfo {
+	var mentioned []string
+	if m.MentionedUuids != "" {
+		mentioned = strings.Split(m.MentionedUuids, ",")
+	}
+	return types.MessageInfo{
+		Id:               m.ID,
+		ConversationId:   m.ConversationID,
+		SendUuid:         m.SendUUID,
+		MsgType:          uint32(m.MsgType),
+		Content:          m.Content,
+		ContentExtra:     m.ContentExtra,
+		ReplyToMessageId: m.ReplyToMessageID,
+		MentionedUuids:   mentioned,
+		IsSystem:         ternary(m.IsSystem, uint32(1), uint32(0)),
+		IsRevoked:        ternary(m.IsRevoked, uint32(1), uint32(0)),
+		CreatedAt:        m.CreatedAt.UTC().Format(time.RFC3339),
+	}
+}
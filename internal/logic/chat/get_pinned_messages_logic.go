@@ -0,0 +1,80 @@
+package chat
+
+import (
+	"context"
+	"time"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetPinnedMessagesLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 获取会话的置顶消息
+func NewGetPinnedMessagesLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetPinnedMessagesLogic {
+	return &GetPinnedMessagesLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetPinnedMessages 按置顶时间倒序返回置顶消息，已删除的消息不再返回
+func (l *GetPinnedMessagesLogic) GetPinnedMessages(req *types.GetPinnedMessagesReq) (resp *types.GetPinnedMessagesResp, err error) {
+	if req.UUID == "" || req.ConversationId == 0 {
+		return nil, errcode.ErrInvalidParam
+	}
+	if _, err := checkConversationMember(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return nil, err
+	}
+
+	pinned := dao.ChatPinnedMessage
+	pins, e := pinned.WithContext(l.ctx).
+		Where(pinned.ConversationID.Eq(req.ConversationId)).
+		Order(pinned.ID.Desc()).
+		Find()
+	if e != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	if len(pins) == 0 {
+		return &types.GetPinnedMessagesResp{Pins: []types.PinnedMessage{}}, nil
+	}
+
+	ids := make([]uint64, 0, len(pins))
+	for _, p := range pins {
+		ids = append(ids, p.MessageID)
+	}
+	msgs, e := dao.ChatMessage.WithContext(l.ctx).
+		Where(dao.ChatMessage.ConversationID.Eq(req.ConversationId), dao.ChatMessage.ID.In(ids...)).
+		Find()
+	if e != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	byID := make(map[uint64]*model.ChatMessage, len(msgs))
+	for _, m := range msgs {
+		byID[m.ID] = m
+	}
+
+	list := make([]types.PinnedMessage, 0, len(pins))
+	for _, p := range pins {
+		m, ok := byID[p.MessageID]
+		if !ok {
+			continue
+		}
+		list = append(list, types.PinnedMessage{
+			Message:  messageInfoFromModel(m),
+			PinUuid:  p.PinUUID,
+			PinnedAt: p.CreatedAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return &types.GetPinnedMessagesResp{Pins: list}, nil
+}
@@ -0,0 +1,114 @@
+package chat
+
+import (
+	"context"
+	"errors"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+type GetThreadLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 获取消息的回复话题
+func NewGetThreadLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetThreadLogic {
+	return &GetThreadLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// GetThread 返回根消息和直接回复它的消息，回复按ID升序，走 idx_conv_reply 索引而不扫描整个会话
+func (l *GetThreadLogic) GetThread(req *types.GetThreadReq) (resp *types.GetThreadResp, err error) {
+	if req.UUID == "" || req.ConversationId == 0 || req.RootId == 0 {
+		return nil, errcode.ErrInvalidParam
+	}
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+	if limit > 50 {
+		limit = 50
+	}
+	if _, err := checkConversationMember(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return nil, err
+	}
+
+	m := dao.ChatMessage
+	root, e := m.WithContext(l.ctx).
+		Where(m.ID.Eq(req.RootId), m.ConversationID.Eq(req.ConversationId)).
+		Take()
+	if e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrInvalidParam
+		}
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+
+	// 多取一条判断是否还有更多回复
+	list, e := m.WithContext(l.ctx).
+		Where(m.ConversationID.Eq(req.ConversationId), m.ReplyToMessageID.Eq(req.RootId), m.ID.Gt(req.AfterId)).
+		Order(m.ID.Asc()).
+		Limit(limit + 1).
+		Find()
+	if e != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	hasMore := len(list) > limit
+	if hasMore {
+		list = list[:limit]
+	}
+
+	msgs := make([]types.MessageInfo, 0, len(list)+1)
+	msgs = append(msgs, messageInfoFromModel(root))
+	for _, r := range list {
+		msgs = append(msgs, messageInfoFromModel(r))
+	}
+	if err := fillReplyCounts(l.ctx, req.ConversationId, msgs); err != nil {
+		return nil, err
+	}
+	return &types.GetThreadResp{Root: msgs[0], Replies: msgs[1:], HasMore: hasMore}, nil
+}
+
+// fillReplyCounts 按消息ID分组统计直接回复的数量并填入ReplyCount
+func fillReplyCounts(ctx context.Context, conversationID uint32, msgs []types.MessageInfo) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	ids := make([]uint64, 0, len(msgs))
+	for _, msg := range msgs {
+		ids = append(ids, msg.Id)
+	}
+
+	var rows []struct {
+		ReplyToMessageID uint64
+		Count            uint32
+	}
+	m := dao.ChatMessage
+	e := m.WithContext(ctx).
+		Select(m.ReplyToMessageID, m.ID.Count().As("count")).
+		Where(m.ConversationID.Eq(conversationID), m.ReplyToMessageID.In(ids...)).
+		Group(m.ReplyToMessageID).
+		Scan(&rows)
+	if e != nil {
+		return errcode.ErrDataQueryFail.WithError(e)
+	}
+	counts := make(map[uint64]uint32, len(rows))
+	for _, row := range rows {
+		counts[row.ReplyToMessageID] = row.Count
+	}
+	for i := range msgs {
+		msgs[i].ReplyCount = counts[msgs[i].Id]
+	}
+	return nil
+}
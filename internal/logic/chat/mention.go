@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/types"
+)
+
+// recordMentions 为消息中提及的会话成员写入提及记录，忽略发送者自己、重复和非成员的uuid，返回实际记录的成员
+func recordMentions(ctx context.Context, msg *model.ChatMessage, uuids []string) ([]string, error) {
+	candidates := make([]string, 0, len(uuids))
+	seen := make(map[string]bool, len(uuids))
+	for _, uuid := range uuids {
+		if uuid == "" || uuid == msg.SendUUID || seen[uuid] {
+			continue
+		}
+		seen[uuid] = true
+		candidates = append(candidates, uuid)
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	members, err := dao.ChatConversationMember.WithContext(ctx).
+		Where(dao.ChatConversationMember.ConversationID.Eq(msg.ConversationID), dao.ChatConversationMember.UserUUID.In(candidates...)).
+		Find()
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	mentioned := make([]string, 0, len(members))
+	rows := make([]*model.ChatMention, 0, len(members))
+	for _, mem := range members {
+		mentioned = append(mentioned, mem.UserUUID)
+		rows = append(rows, &model.ChatMention{
+			ConversationID: msg.ConversationID,
+			MessageID:      msg.ID,
+			MentionUUID:    mem.UserUUID,
+			SendUUID:       msg.SendUUID,
+		})
+	}
+	if err := dao.ChatMention.WithContext(ctx).Create(rows...); err != nil {
+		return nil, err
+	}
+	return mentioned, nil
+}
+
+// mentionPayload 推送给被提及成员的 WS 事件，客户端据此高亮提及并累加提及未读数
+func mentionPayload(info types.MessageInfo) any {
+	return struct {
+		Op   string            `json:"op"`
+		Data types.MessageInfo `json:"data"`
+	}{
+		Op:   "mention_new",
+		Data: info,
+	}
+}
@@ -0,0 +1,68 @@
+package chat
+
+import (
+	"context"
+	"errors"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// checkConversationMember 校验用户是会话成员并返回成员记录
+func checkConversationMember(ctx context.Context, conversationID uint32, uuid string) (*model.ChatConversationMember, error) {
+	mem, e := dao.ChatConversationMember.WithContext(ctx).
+		Where(
+			dao.ChatConversationMember.ConversationID.Eq(conversationID),
+			dao.ChatConversationMember.UserUUID.Eq(uuid),
+		).
+		Take()
+	if e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrAuthSession
+		}
+		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	return mem, nil
+}
+
+// loadMessageForMutation 校验操作者是会话成员，并读取该会话中的消息
+func loadMessageForMutation(ctx context.Context, conversationID uint32, messageID uint64, operator string) (*model.ChatMessage, *model.ChatConversationMember, error) {
+	mem, err := checkConversationMember(ctx, conversationID, operator)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg, e := dao.ChatMessage.WithContext(ctx).
+		Where(
+			dao.ChatMessage.ID.Eq(messageID),
+			dao.ChatMessage.ConversationID.Eq(conversationID),
+		).
+		Take()
+	if e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return nil, nil, errcode.ErrInvalidParam
+		}
+		return nil, nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+	return msg, mem, nil
+}
+
+// broadcastToConversation 向会话内所有成员推送事件
+func broadcastToConversation(svcCtx *svc.ServiceContext, conversationID uint32, payload any) {
+	defer func() { recover() }()
+	members, err := dao.ChatConversationMember.WithContext(context.Background()).
+		Where(dao.ChatConversationMember.ConversationID.Eq(conversationID)).
+		Find()
+	if err != nil {
+		logx.Errorf("ws broadcast to conversation %d failed: %v", conversationID, err)
+		return
+	}
+	for _, m := range members {
+		svcCtx.Ws.SendJSON(m.UserUUID, payload)
+	}
+}
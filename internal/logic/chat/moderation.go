@@ -0,0 +1,97 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/pkg/moderation"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// msgTypeText 文本消息，只有文本内容允许被审核打码改写
+const msgTypeText = 1
+
+// moderationErrors 审核拒绝原因对应返回给客户端的错误码
+var moderationErrors = map[moderation.Reason]error{
+	moderation.ReasonSpam:      errcode.ErrMessageSpam,
+	moderation.ReasonProfanity: errcode.ErrMessageProfanity,
+	moderation.ReasonTooLarge:  errcode.ErrMessageTooLarge,
+	moderation.ReasonType:      errcode.ErrMessageType,
+	moderation.ReasonEmpty:     errcode.ErrMessageEmpty,
+}
+
+// moderateContent 写入前同步审核消息内容，返回审核后的内容
+// 拒绝时返回对应的错误码；非文本消息的内容不能被改写，被打码时视为包含敏感词
+func moderateContent(ctx context.Context, svcCtx *svc.ServiceContext, review *moderation.Message) (string, error) {
+	if svcCtx.Moderation == nil {
+		return string(review.Content), nil
+	}
+	original := string(review.Content)
+	if err := svcCtx.Moderation.Moderate(ctx, review); err != nil {
+		var rejection *moderation.Rejection
+		if errors.As(err, &rejection) {
+			logx.WithContext(ctx).Infof("message of %s in conversation %s rejected: %v", review.SenderID, review.ConversationID, rejection)
+			if code, ok := moderationErrors[rejection.Reason]; ok {
+				return "", code
+			}
+			return "", errcode.ErrMessageRejected
+		}
+		return "", errcode.ErrMessageRejected.WithError(err)
+	}
+	content := string(review.Content)
+	if content != original && review.Type != msgTypeText {
+		return "", errcode.ErrMessageProfanity
+	}
+	return content, nil
+}
+
+// newReviewMessage 构造待审核的消息
+func newReviewMessage(conversationID uint32, sender string, msgType uint32, content string, mentions []string) *moderation.Message {
+	return &moderation.Message{
+		ConversationID: strconv.FormatUint(uint64(conversationID), 10),
+		SenderID:       sender,
+		Type:           msgType,
+		Content:        []byte(content),
+		Mentions:       mentions,
+	}
+}
+
+// reviewAfterWrite 写入后异步审核消息，发现违规时撤回消息并通知会话成员
+func reviewAfterWrite(svcCtx *svc.ServiceContext, review *moderation.Message, msg *model.ChatMessage) {
+	if svcCtx.Moderation == nil {
+		return
+	}
+	review.ID = strconv.FormatUint(msg.ID, 10)
+	svcCtx.Moderation.ModerateAsync(*review, func(_ moderation.Message, rejection *moderation.Rejection) {
+		now := time.Now()
+		revoked := &model.ChatMessage{ID: msg.ID, IsRevoked: true, RevokedAt: &now}
+		if err := dao.ChatMessage.Update(context.Background(), revoked, "IsRevoked", "RevokedAt"); err != nil {
+			logx.Errorf("recall message %d flagged by moderation failed: %v", msg.ID, err)
+			return
+		}
+		logx.Infof("message %d in conversation %d recalled by moderation: %v", msg.ID, msg.ConversationID, rejection)
+
+		payload := struct {
+			Op   string `json:"op"`
+			Data struct {
+				ConversationId uint32 `json:"conversationId"`
+				MessageId      uint64 `json:"messageId"`
+				OperatorUuid   string `json:"operatorUuid"`
+				RevokedAt      string `json:"revokedAt"`
+				Reason         string `json:"reason"`
+			} `json:"data"`
+		}{Op: "message_recalled"}
+		payload.Data.ConversationId = msg.ConversationID
+		payload.Data.MessageId = msg.ID
+		payload.Data.RevokedAt = now.UTC().Format(time.RFC3339)
+		payload.Data.Reason = string(rejection.Reason)
+		broadcastToConversation(svcCtx, msg.ConversationID, payload)
+	})
+}
@@ -0,0 +1,108 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"gorm.io/gorm"
+)
+
+// maxPinnedMessages 每个会话最多置顶的消息数
+const maxPinnedMessages = 50
+
+type PinMessageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 置顶消息
+func NewPinMessageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *PinMessageLogic {
+	return &PinMessageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// PinMessage 置顶会话中的一条消息，重复置顶直接返回成功
+func (l *PinMessageLogic) PinMessage(req *types.PinMessageReq) error {
+	if req.UUID == "" || req.ConversationId == 0 || req.MessageId == 0 {
+		return errcode.ErrInvalidParam
+	}
+	if err := checkPinPermission(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return err
+	}
+
+	msg, e := dao.ChatMessage.WithContext(l.ctx).
+		Where(dao.ChatMessage.ID.Eq(req.MessageId), dao.ChatMessage.ConversationID.Eq(req.ConversationId)).
+		Take()
+	if e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return errcode.ErrInvalidParam
+		}
+		return errcode.ErrDataQueryFail.WithError(e)
+	}
+	if msg.IsRevoked {
+		return errcode.ErrInvalidParam
+	}
+
+	pinned := dao.ChatPinnedMessage
+	count, e := pinned.WithContext(l.ctx).Where(pinned.ConversationID.Eq(req.ConversationId)).Count()
+	if e != nil {
+		return errcode.ErrDataQueryFail.WithError(e)
+	}
+	exists, e := pinned.WithContext(l.ctx).
+		Where(pinned.ConversationID.Eq(req.ConversationId), pinned.MessageID.Eq(req.MessageId)).
+		Count()
+	if e != nil {
+		return errcode.ErrDataQueryFail.WithError(e)
+	}
+	if exists > 0 {
+		return nil
+	}
+	if count >= maxPinnedMessages {
+		return errcode.ErrConvPinLimit
+	}
+
+	pin := &model.ChatPinnedMessage{ConversationID: req.ConversationId, MessageID: req.MessageId, PinUUID: req.UUID}
+	if e := pinned.WithContext(l.ctx).Create(pin); e != nil {
+		return errcode.ErrDataCreateFail.WithError(e)
+	}
+
+	payload := struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32              `json:"conversationId"`
+			Pin            types.PinnedMessage `json:"pin"`
+		} `json:"data"`
+	}{Op: "message_pinned"}
+	payload.Data.ConversationId = req.ConversationId
+	payload.Data.Pin = types.PinnedMessage{
+		Message:  messageInfoFromModel(msg),
+		PinUuid:  pin.PinUUID,
+		PinnedAt: pin.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	go broadcastToConversation(l.svcCtx, req.ConversationId, payload)
+	return nil
+}
+
+// checkPinPermission 群聊只有群主和管理员可以置顶或取消置顶，单聊双方都可以
+func checkPinPermission(ctx context.Context, conversationID uint32, uuid string) error {
+	conv, _, role, err := loadConversationRole(ctx, conversationID, uuid)
+	if err != nil {
+		return err
+	}
+	if conv.Type == conversationTypeGroup && role < memberRoleAdmin {
+		return errcode.ErrConvPermission
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
+	"imy/pkg/events"
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"gorm.io/gorm"
@@ -36,13 +37,9 @@ func (l *RemoveMemberLogic) RemoveMember(req *types.RemoveMemberReq) error {
 	}
 
 	// 校验操作者是成员
-	if _, e := dao.ChatConversationMember.WithContext(l.ctx).
-		Where(dao.ChatConversationMember.ConversationID.Eq(req.ConversationId), dao.ChatConversationMember.UserUUID.Eq(req.UUID)).
-		Take(); e != nil {
-		if errors.Is(e, gorm.ErrRecordNotFound) {
-			return errcode.ErrAuthSession
-		}
-		return errcode.ErrDataQueryFail.WithError(e)
+	conv, _, role, err := loadConversationRole(l.ctx, req.ConversationId, req.UUID)
+	if err != nil {
+		return err
 	}
 
 	// 被移除者是否在群内
@@ -56,16 +53,35 @@ func (l *RemoveMemberLogic) RemoveMember(req *types.RemoveMemberReq) error {
 		return errcode.ErrDataQueryFail.WithError(e)
 	}
 
+	// 成员可以自己退群；移除他人需要是群主或管理员，且只能移除角色低于自己的成员
+	if req.RemoveUUID == req.UUID {
+		// 群里只剩群主时可以直接退出
+		if role == memberRoleOwner && conv.MemberCount > 1 {
+			return errcode.ErrConvOwnerLeave
+		}
+	} else {
+		if conv.Type != conversationTypeGroup {
+			return errcode.ErrConvNotGroup
+		}
+		target, err := memberRole(l.ctx, conv, mem)
+		if err != nil {
+			return err
+		}
+		if role < memberRoleAdmin || target >= role {
+			return errcode.ErrConvPermission
+		}
+	}
+
 	// 删除成员
 	if e := dao.ChatConversationMember.DeleteByID(l.ctx, mem.ID); e != nil {
 		return errcode.ErrDataModifyFail.WithError(e)
 	}
 
 	// 更新会话成员数（忽略错误）
-	conv, _ := dao.ChatConversation.Get(l.ctx, req.ConversationId)
-	if conv != nil && conv.MemberCount > 0 {
+	if conv.MemberCount > 0 {
 		_ = dao.ChatConversation.Update(l.ctx, &model.ChatConversation{ID: conv.ID, MemberCount: conv.MemberCount - 1}, "MemberCount")
 	}
+	publishEvent(l.svcCtx, "", events.TypeMemberRemoved, req.ConversationId, events.MemberData{Members: []string{req.RemoveUUID}, Operator: req.UUID})
 
 	// 广播 member_removed
 	go func(conversationID uint32, removed string) {
@@ -0,0 +1,96 @@
+package chat
+
+import (
+	"context"
+	"errors"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+
+	"gorm.io/gorm"
+)
+
+// conversationTypeGroup 群聊
+const conversationTypeGroup = 2
+
+// 会话成员角色：群主可以任免管理员、转让群主；管理员可以修改群资料、增删普通成员；
+// 仅群主和管理员可发言的群里普通成员不能发消息
+const (
+	memberRoleMember = 1
+	memberRoleAdmin  = 2
+	memberRoleOwner  = 3
+)
+
+// memberRole 成员在会话中的实际角色
+// 引入群主角色之前创建的群里没有角色为群主的成员，此时由创建者担任群主
+func memberRole(ctx context.Context, conv *model.ChatConversation, mem *model.ChatConversationMember) (int8, error) {
+	if mem.Role >= memberRoleOwner || conv.Type != conversationTypeGroup || conv.CreateUUID != mem.UserUUID {
+		return mem.Role, nil
+	}
+	owners, err := dao.ChatConversationMember.WithContext(ctx).
+		Where(
+			dao.ChatConversationMember.ConversationID.Eq(conv.ID),
+			dao.ChatConversationMember.Role.Eq(memberRoleOwner),
+		).
+		Count()
+	if err != nil {
+		return 0, errcode.ErrDataQueryFail.WithError(err)
+	}
+	if owners == 0 {
+		return memberRoleOwner, nil
+	}
+	return mem.Role, nil
+}
+
+// loadConversationRole 校验用户是会话成员，返回会话、成员记录及其角色
+func loadConversationRole(ctx context.Context, conversationID uint32, uuid string) (*model.ChatConversation, *model.ChatConversationMember, int8, error) {
+	mem, err := checkConversationMember(ctx, conversationID, uuid)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	conv, e := dao.ChatConversation.WithContext(ctx).Where(dao.ChatConversation.ID.Eq(conversationID)).Take()
+	if e != nil {
+		if errors.Is(e, gorm.ErrRecordNotFound) {
+			return nil, nil, 0, errcode.ErrInvalidParam
+		}
+		return nil, nil, 0, errcode.ErrDataQueryFail.WithError(e)
+	}
+	role, err := memberRole(ctx, conv, mem)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return conv, mem, role, nil
+}
+
+// loadGroupRole 同loadConversationRole，并要求会话是群聊且操作者角色不低于minRole
+func loadGroupRole(ctx context.Context, conversationID uint32, uuid string, minRole int8) (*model.ChatConversation, int8, error) {
+	conv, _, role, err := loadConversationRole(ctx, conversationID, uuid)
+	if err != nil {
+		return nil, 0, err
+	}
+	if conv.Type != conversationTypeGroup {
+		return nil, 0, errcode.ErrConvNotGroup
+	}
+	if role < minRole {
+		return nil, 0, errcode.ErrConvPermission
+	}
+	return conv, role, nil
+}
+
+// broadcastRoleChanged 通知会话成员角色变化
+func broadcastRoleChanged(svcCtx *svc.ServiceContext, conversationID uint32, uuid string, role int8) {
+	payload := struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32 `json:"conversationId"`
+			UserUuid       string `json:"userUuid"`
+			Role           uint32 `json:"role"`
+		} `json:"data"`
+	}{Op: "member_role_changed"}
+	payload.Data.ConversationId = conversationID
+	payload.Data.UserUuid = uuid
+	payload.Data.Role = uint32(role)
+	broadcastToConversation(svcCtx, conversationID, payload)
+}
@@ -3,6 +3,7 @@ package chat
 import (
 	"context"
 	"errors"
+	"slices"
 	"strings"
 	"time"
 
@@ -37,14 +38,20 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 		return nil, errcode.ErrInvalidParam
 	}
 
-	// 2) 校验是否会话成员
-	if _, e := dao.ChatConversationMember.WithContext(l.ctx).
-		Where(dao.ChatConversationMember.ConversationID.Eq(req.ConversationId), dao.ChatConversationMember.UserUUID.Eq(req.UUID)).
-		Take(); e != nil {
-		if errors.Is(e, gorm.ErrRecordNotFound) {
-			return nil, errcode.ErrAuthSession
+	// 1.1) 附件消息的内容必须是上传到该会话的附件
+	if req.MsgType == msgTypeAttachment {
+		if _, err := parseAttachmentContent(req.Content, req.ConversationId); err != nil {
+			return nil, err
 		}
-		return nil, errcode.ErrDataQueryFail.WithError(e)
+	}
+
+	// 2) 校验是否会话成员；仅群主和管理员可发言时普通成员不能发消息
+	conv, _, role, err := loadConversationRole(l.ctx, req.ConversationId, req.UUID)
+	if err != nil {
+		return nil, err
+	}
+	if conv.AnnouncementOnly && role < memberRoleAdmin {
+		return nil, errcode.ErrConvAnnouncementOnly
 	}
 
 	// 3) 幂等：检查是否已存在相同 clientMsgId 的消息
@@ -56,18 +63,27 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 		).
 		Take()
 	if e == nil {
-		// 已存在，直接返回
+		// 已存在，直接返回；客户端重发时再次推送送达回执
 		createdAt := exist.CreatedAt.UTC().Format(time.RFC3339)
-		return &types.SendMessageResp{
+		resp = &types.SendMessageResp{
 			ServerMsgId: exist.ID,
 			ClientMsgId: exist.ClientMsgID,
 			CreatedAt:   createdAt,
-		}, nil
+		}
+		go l.sendAck(req.UUID, req.ConversationId, resp)
+		return resp, nil
 	}
 	if !errors.Is(e, gorm.ErrRecordNotFound) {
 		return nil, errcode.ErrDataQueryFail.WithError(e)
 	}
 
+	// 3.1) 内容审核：拒绝时返回对应错误码，文本中的敏感词可能被打码
+	review := newReviewMessage(req.ConversationId, req.UUID, req.MsgType, req.Content, req.MentionedUuids)
+	content, err := moderateContent(l.ctx, l.svcCtx, review)
+	if err != nil {
+		return nil, err
+	}
+
 	// 4) 写入消息
 	mentionedStr := ""
 	if len(req.MentionedUuids) > 0 {
@@ -79,7 +95,7 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 		SendUUID:         req.UUID,
 		ClientMsgID:      req.ClientMsgId,
 		MsgType:          int8(req.MsgType),
-		Content:          req.Content,
+		Content:          content,
 		ContentExtra:     req.ContentExtra,
 		ReplyToMessageID: req.ReplyToMessageId,
 		MentionedUuids:   mentionedStr,
@@ -89,8 +105,17 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 	if e := dao.ChatMessage.WithContext(l.ctx).Create(msg); e != nil {
 		return nil, errcode.ErrDataCreateFail.WithError(e)
 	}
+	// 4.0) 写入后异步审核，违规时撤回
+	reviewAfterWrite(l.svcCtx, review, msg)
+	publishMessageCreated(l.svcCtx, msg, req.MentionedUuids)
+
+	// 4.1) 为被提及的成员写入提及记录（忽略错误，不阻塞发送流程）
+	mentioned, e := recordMentions(l.ctx, msg, req.MentionedUuids)
+	if e != nil {
+		l.Errorf("record mentions of message %d failed: %v", msg.ID, e)
+	}
 
-	// 4.1) 更新会话的最后消息ID（忽略错误，不阻塞发送流程）
+	// 4.2) 更新会话的最后消息ID（忽略错误，不阻塞发送流程）
 	_ = dao.ChatConversation.Update(l.ctx, &model.ChatConversation{
 		ID:            req.ConversationId,
 		LastMessageID: msg.ID,
@@ -104,8 +129,11 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 		CreatedAt:   createdAt,
 	}
 
-	// 6) 广播 WS 事件给该会话的所有成员
-	go func(m *model.ChatMessage) {
+	// 6) 推送送达回执给发送者，HTTP 响应丢失时客户端仍可确认消息已落库
+	go l.sendAck(req.UUID, req.ConversationId, resp)
+
+	// 7) 广播 WS 事件给该会话的所有成员
+	go func(m *model.ChatMessage, mentioned []string) {
 		defer func() { recover() }()
 		members, e := dao.ChatConversationMember.WithContext(l.ctx).
 			Where(dao.ChatConversationMember.ConversationID.Eq(req.ConversationId)).
@@ -114,32 +142,20 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 			logx.Errorf("ws broadcast list members failed: %v", e)
 			return
 		}
-		var mentioned []string
-		if m.MentionedUuids != "" {
-			mentioned = strings.Split(m.MentionedUuids, ",")
-		}
+		info := messageInfoFromModel(m)
 		payloadNew := struct {
 			Op   string            `json:"op"`
 			Data types.MessageInfo `json:"data"`
 		}{
-			Op: "message_new",
-			Data: types.MessageInfo{
-				Id:               m.ID,
-				ConversationId:   m.ConversationID,
-				SendUuid:         m.SendUUID,
-				MsgType:          uint32(m.MsgType),
-				Content:          m.Content,
-				ContentExtra:     m.ContentExtra,
-				ReplyToMessageId: m.ReplyToMessageID,
-				MentionedUuids:   mentioned,
-				IsSystem:         ternary(m.IsSystem, uint32(1), uint32(0)),
-				IsRevoked:        ternary(m.IsRevoked, uint32(1), uint32(0)),
-				CreatedAt:        m.CreatedAt.UTC().Format(time.RFC3339),
-			},
+			Op:   "message_new",
+			Data: info,
 		}
 		for _, mem := range members {
-			// 推送新消息
+			// 推送新消息，被提及的成员额外收到提及事件
 			l.svcCtx.Ws.SendJSON(mem.UserUUID, payloadNew)
+			if slices.Contains(mentioned, mem.UserUUID) {
+				l.svcCtx.Ws.SendJSON(mem.UserUUID, mentionPayload(info))
+			}
 
 			// 计算并推送未读变更：统计 > last_read_message_id 且 发送者 != 自己 的消息数
 			cnt, errCnt := dao.ChatMessage.WithContext(l.ctx).
@@ -169,11 +185,29 @@ func (l *SendMessageLogic) SendMessage(req *types.SendMessageReq) (resp *types.S
 			}
 			l.svcCtx.Ws.SendJSON(mem.UserUUID, payloadUnread)
 		}
-	}(msg)
+	}(msg, mentioned)
 
 	return resp, nil
 }
 
+// sendAck 向发送者推送 clientMsgId -> serverMsgId 的送达回执
+func (l *SendMessageLogic) sendAck(sender string, conversationID uint32, resp *types.SendMessageResp) {
+	payload := struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32 `json:"conversationId"`
+			ServerMsgId    uint64 `json:"serverMsgId"`
+			ClientMsgId    string `json:"clientMsgId"`
+			CreatedAt      string `json:"createdAt"`
+		} `json:"data"`
+	}{Op: "message_ack"}
+	payload.Data.ConversationId = conversationID
+	payload.Data.ServerMsgId = resp.ServerMsgId
+	payload.Data.ClientMsgId = resp.ClientMsgId
+	payload.Data.CreatedAt = resp.CreatedAt
+	l.svcCtx.Ws.SendJSON(sender, payload)
+}
+
 // ternary is a tiny helper to convert bool to uint32(1/0)
 func ternary(cond bool, a, b uint32) uint32 {
 	if cond {
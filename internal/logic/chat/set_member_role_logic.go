@@ -0,0 +1,59 @@
+package chat
+
+import (
+	"context"
+	"errors"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type SetMemberRoleLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 设置/取消管理员（群主）
+func NewSetMemberRoleLogic(ctx context.Context, svcCtx *svc.ServiceContext) *SetMemberRoleLogic {
+	return &SetMemberRoleLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// SetMemberRole 群主把成员设为管理员或降为普通成员，群主本人的角色只能通过转让群主改变
+func (l *SetMemberRoleLogic) SetMemberRole(req *types.SetMemberRoleReq) error {
+	if req.UUID == "" || req.ConversationId == 0 || req.MemberUuid == "" || req.MemberUuid == req.UUID {
+		return errcode.ErrInvalidParam
+	}
+	if req.Role != memberRoleMember && req.Role != memberRoleAdmin {
+		return errcode.ErrInvalidParam
+	}
+	if _, _, err := loadGroupRole(l.ctx, req.ConversationId, req.UUID, memberRoleOwner); err != nil {
+		return err
+	}
+
+	mem, err := checkConversationMember(l.ctx, req.ConversationId, req.MemberUuid)
+	if err != nil {
+		if errors.Is(err, errcode.ErrAuthSession) {
+			return errcode.ErrInvalidParam
+		}
+		return err
+	}
+	if mem.Role == int8(req.Role) {
+		return nil
+	}
+	mem.Role = int8(req.Role)
+	if e := dao.ChatConversationMember.Update(l.ctx, mem, "Role"); e != nil {
+		return errcode.ErrDataModifyFail.WithError(e)
+	}
+
+	go broadcastRoleChanged(l.svcCtx, req.ConversationId, req.MemberUuid, mem.Role)
+	return nil
+}
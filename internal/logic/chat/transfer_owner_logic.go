@@ -0,0 +1,70 @@
+package chat
+
+import (
+	"context"
+	"errors"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type TransferOwnerLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 转让群主
+func NewTransferOwnerLogic(ctx context.Context, svcCtx *svc.ServiceContext) *TransferOwnerLogic {
+	return &TransferOwnerLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// TransferOwner 把群主转让给另一名成员，原群主成为管理员
+func (l *TransferOwnerLogic) TransferOwner(req *types.TransferOwnerReq) error {
+	if req.UUID == "" || req.ConversationId == 0 || req.NewOwnerUuid == "" || req.NewOwnerUuid == req.UUID {
+		return errcode.ErrInvalidParam
+	}
+	if _, _, err := loadGroupRole(l.ctx, req.ConversationId, req.UUID, memberRoleOwner); err != nil {
+		return err
+	}
+	newOwner, err := checkConversationMember(l.ctx, req.ConversationId, req.NewOwnerUuid)
+	if err != nil {
+		if errors.Is(err, errcode.ErrAuthSession) {
+			return errcode.ErrInvalidParam
+		}
+		return err
+	}
+
+	// 同一事务内交换角色，避免出现两个群主或没有群主
+	err = dao.Q.Transaction(func(tx *dao.Query) error {
+		member := tx.ChatConversationMember
+		if _, e := member.WithContext(l.ctx).
+			Where(member.ConversationID.Eq(req.ConversationId), member.UserUUID.Eq(req.UUID)).
+			Update(member.Role, memberRoleAdmin); e != nil {
+			return e
+		}
+		if _, e := member.WithContext(l.ctx).
+			Where(member.ID.Eq(newOwner.ID)).
+			Update(member.Role, memberRoleOwner); e != nil {
+			return e
+		}
+		return nil
+	})
+	if err != nil {
+		return errcode.ErrDataModifyFail.WithError(err)
+	}
+
+	go func() {
+		broadcastRoleChanged(l.svcCtx, req.ConversationId, req.UUID, memberRoleAdmin)
+		broadcastRoleChanged(l.svcCtx, req.ConversationId, req.NewOwnerUuid, memberRoleOwner)
+	}()
+	return nil
+}
@@ -0,0 +1,62 @@
+package chat
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UnpinMessageLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 取消置顶消息
+func NewUnpinMessageLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UnpinMessageLogic {
+	return &UnpinMessageLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *UnpinMessageLogic) UnpinMessage(req *types.UnpinMessageReq) error {
+	if req.UUID == "" || req.ConversationId == 0 || req.MessageId == 0 {
+		return errcode.ErrInvalidParam
+	}
+	if err := checkPinPermission(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return err
+	}
+
+	// 置顶表有唯一索引，直接删除记录以便之后可以再次置顶
+	pinned := dao.ChatPinnedMessage
+	info, e := pinned.WithContext(l.ctx).Unscoped().
+		Where(pinned.ConversationID.Eq(req.ConversationId), pinned.MessageID.Eq(req.MessageId)).
+		Delete()
+	if e != nil {
+		return errcode.ErrDataModifyFail.WithError(e)
+	}
+	if info.RowsAffected == 0 {
+		return nil
+	}
+
+	payload := struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32 `json:"conversationId"`
+			MessageId      uint64 `json:"messageId"`
+			UnpinUuid      string `json:"unpinUuid"`
+		} `json:"data"`
+	}{Op: "message_unpinned"}
+	payload.Data.ConversationId = req.ConversationId
+	payload.Data.MessageId = req.MessageId
+	payload.Data.UnpinUuid = req.UUID
+	go broadcastToConversation(l.svcCtx, req.ConversationId, payload)
+	return nil
+}
@@ -0,0 +1,75 @@
+package chat
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UpdateConversationInfoLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 修改群资料（群主/管理员）
+func NewUpdateConversationInfoLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UpdateConversationInfoLogic {
+	return &UpdateConversationInfoLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UpdateConversationInfo 修改群名称、头像以及是否仅群主和管理员可发言，只更新传入的字段
+func (l *UpdateConversationInfoLogic) UpdateConversationInfo(req *types.UpdateConversationInfoReq) error {
+	if req.UUID == "" || req.ConversationId == 0 || req.AnnouncementOnly < -1 || req.AnnouncementOnly > 1 {
+		return errcode.ErrInvalidParam
+	}
+	conv, _, err := loadGroupRole(l.ctx, req.ConversationId, req.UUID, memberRoleAdmin)
+	if err != nil {
+		return err
+	}
+
+	cols := make([]string, 0, 3)
+	if req.Name != "" {
+		conv.Name = req.Name
+		cols = append(cols, "Name")
+	}
+	if req.Avatar != "" {
+		conv.Avatar = req.Avatar
+		cols = append(cols, "Avatar")
+	}
+	if req.AnnouncementOnly >= 0 {
+		conv.AnnouncementOnly = req.AnnouncementOnly == 1
+		cols = append(cols, "AnnouncementOnly")
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+	if e := dao.ChatConversation.Update(l.ctx, conv, cols...); e != nil {
+		return errcode.ErrDataModifyFail.WithError(e)
+	}
+
+	payload := struct {
+		Op   string                 `json:"op"`
+		Data types.ConversationInfo `json:"data"`
+	}{Op: "conversation_updated", Data: types.ConversationInfo{
+		ConversationId:   conv.ID,
+		Type:             uint32(conv.Type),
+		PrivateKey:       conv.PrivateKey,
+		Name:             conv.Name,
+		MemberCount:      conv.MemberCount,
+		LastMessageId:    conv.LastMessageID,
+		Avatar:           conv.Avatar,
+		Extra:            conv.Extra,
+		AnnouncementOnly: ternary(conv.AnnouncementOnly, uint32(1), uint32(0)),
+	}}
+	go broadcastToConversation(l.svcCtx, conv.ID, payload)
+	return nil
+}
@@ -0,0 +1,91 @@
+package chat
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	xhttp "imy/pkg/httpx"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UploadAttachmentLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 上传附件（multipart，字段 file）
+func NewUploadAttachmentLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UploadAttachmentLogic {
+	return &UploadAttachmentLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *UploadAttachmentLogic) UploadAttachment(req *types.UploadAttachmentReq) (resp *types.AttachmentInfo, err error) {
+	// 1) 参数校验
+	if req.UUID == "" || req.ConversationId == 0 {
+		return nil, errcode.ErrInvalidParam
+	}
+
+	// 2) 校验是否会话成员
+	if _, err := checkConversationMember(l.ctx, req.ConversationId, req.UUID); err != nil {
+		return nil, err
+	}
+
+	// 3) 读取上传的文件
+	r, ok := xhttp.GetRequest(l.ctx)
+	if !ok {
+		return nil, errcode.ErrInternalErr
+	}
+	file, header, e := r.FormFile("file")
+	if e != nil {
+		return nil, errcode.ErrInvalidParam.WithError(e)
+	}
+	defer file.Close()
+
+	maxSize := l.svcCtx.Config.Attachment.MaxSize
+	if maxSize <= 0 {
+		maxSize = defaultAttachmentMaxSize
+	}
+	if header.Size <= 0 || header.Size > maxSize {
+		return nil, errcode.ErrInvalidParam
+	}
+
+	// 4) 确定文件类型：优先使用客户端声明的类型，其次按扩展名和内容推断
+	name := filepath.Base(header.Filename)
+	mimeType := header.Header.Get("Content-Type")
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if mimeType == "" {
+		head := make([]byte, 512)
+		n, _ := file.Read(head)
+		mimeType = http.DetectContentType(head[:n])
+		if _, e := file.Seek(0, 0); e != nil {
+			return nil, errcode.ErrFileOpenFail.WithError(e)
+		}
+	}
+
+	// 5) 写入存储后端
+	key := attachmentKey(req.ConversationId, l.svcCtx.Snow.Generate().String(), name)
+	if e := l.svcCtx.Blob.Put(l.ctx, key, file, header.Size, mimeType); e != nil {
+		return nil, errcode.ErrFileSave.WithError(e)
+	}
+
+	return &types.AttachmentInfo{
+		Key:  key,
+		Name: name,
+		Size: header.Size,
+		Mime: mimeType,
+		Url:  attachmentURL(req.ConversationId, key, name),
+	}, nil
+}
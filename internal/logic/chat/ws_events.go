@@ -0,0 +1,82 @@
+package chat
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	ws "imy/pkg/websocket"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// NotifyPresence 向用户所在会话的其他成员推送上线/下线事件，仅v2连接接收
+func NotifyPresence(ctx context.Context, svcCtx *svc.ServiceContext, uuid string, online bool) {
+	joined, err := dao.ChatConversationMember.WithContext(ctx).
+		Where(dao.ChatConversationMember.UserUUID.Eq(uuid)).
+		Find()
+	if err != nil {
+		logx.Errorf("ws presence: load conversations of %s failed: %v", uuid, err)
+		return
+	}
+	if len(joined) == 0 {
+		return
+	}
+	convIDs := make([]uint32, 0, len(joined))
+	for _, m := range joined {
+		convIDs = append(convIDs, m.ConversationID)
+	}
+
+	peers, err := dao.ChatConversationMember.WithContext(ctx).
+		Where(
+			dao.ChatConversationMember.ConversationID.In(convIDs...),
+			dao.ChatConversationMember.UserUUID.Neq(uuid),
+		).
+		Find()
+	if err != nil {
+		logx.Errorf("ws presence: load peers of %s failed: %v", uuid, err)
+		return
+	}
+
+	env, err := ws.NewEnvelope(ws.EnvelopePresence, 0, ws.PresencePayload{UUID: uuid, Online: online})
+	if err != nil {
+		return
+	}
+	sent := make(map[string]struct{}, len(peers))
+	for _, p := range peers {
+		if _, ok := sent[p.UserUUID]; ok {
+			continue
+		}
+		sent[p.UserUUID] = struct{}{}
+		svcCtx.Ws.SendEnvelope(p.UserUUID, env)
+	}
+}
+
+// ForwardTyping 校验用户是会话成员后，向会话其他成员转发输入状态，仅v2连接接收
+func ForwardTyping(ctx context.Context, svcCtx *svc.ServiceContext, uuid string, conversationID uint32, typing bool) error {
+	if conversationID == 0 {
+		return errcode.ErrInvalidParam
+	}
+	if _, err := checkConversationMember(ctx, conversationID, uuid); err != nil {
+		return err
+	}
+	members, err := dao.ChatConversationMember.WithContext(ctx).
+		Where(
+			dao.ChatConversationMember.ConversationID.Eq(conversationID),
+			dao.ChatConversationMember.UserUUID.Neq(uuid),
+		).
+		Find()
+	if err != nil {
+		return errcode.ErrDataQueryFail.WithError(err)
+	}
+
+	env, err := ws.NewEnvelope(ws.EnvelopeTyping, conversationID, ws.TypingPayload{UUID: uuid, Typing: typing})
+	if err != nil {
+		return errcode.ErrInternalErr
+	}
+	for _, m := range members {
+		svcCtx.Ws.SendEnvelope(m.UserUUID, env)
+	}
+	return nil
+}
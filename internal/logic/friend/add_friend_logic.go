@@ -43,8 +43,17 @@ func (l *AddFriendLogic) AddFriend(req *types.AddFriendReq) error {
 		return errcode.ErrInvalidParam
 	}
 
+	// 任意一方拉黑了对方时不能发起好友请求
+	blocked, err := IsBlocked(l.ctx, req.UUID, req.RevId)
+	if err != nil {
+		return errcode.ErrDataQueryFail.WithError(err)
+	}
+	if blocked {
+		return errcode.ErrUserBlocked
+	}
+
 	// 新增校验：如果已经是好友，则不能再次添加
-	_, err := dao.Friend.WithContext(l.ctx).
+	_, err = dao.Friend.WithContext(l.ctx).
 		Where(dao.Friend.SendUUID.Eq(req.UUID), dao.Friend.RevUUID.Eq(req.RevId)).
 		Or(dao.Friend.SendUUID.Eq(req.RevId), dao.Friend.RevUUID.Eq(req.UUID)).
 		Take()
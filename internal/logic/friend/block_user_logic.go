@@ -0,0 +1,72 @@
+package friend
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/dao/model"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type BlockUserLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 拉黑用户
+func NewBlockUserLogic(ctx context.Context, svcCtx *svc.ServiceContext) *BlockUserLogic {
+	return &BlockUserLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// BlockUser 拉黑对方，同时解除好友关系并拒绝双方之间未处理的好友请求，重复拉黑直接返回成功
+func (l *BlockUserLogic) BlockUser(req *types.BlockUserReq) error {
+	if req.UUID == "" || req.BlockUuid == "" || req.UUID == req.BlockUuid {
+		return errcode.ErrInvalidParam
+	}
+	if _, err := dao.User.WithContext(l.ctx).Where(dao.User.UUID.Eq(req.BlockUuid)).Take(); err != nil {
+		return errcode.ErrAuthUserNotFund.WithError(err)
+	}
+
+	return dao.Q.Transaction(func(tx *dao.Query) error {
+		count, err := tx.FriendBlock.WithContext(l.ctx).
+			Where(tx.FriendBlock.UserUUID.Eq(req.UUID), tx.FriendBlock.BlockUUID.Eq(req.BlockUuid)).
+			Count()
+		if err != nil {
+			return errcode.ErrDataQueryFail.WithError(err)
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := tx.FriendBlock.WithContext(l.ctx).Create(&model.FriendBlock{UserUUID: req.UUID, BlockUUID: req.BlockUuid}); err != nil {
+			return errcode.ErrDataCreateFail.WithError(err)
+		}
+
+		if _, err := tx.Friend.WithContext(l.ctx).
+			Where(tx.Friend.SendUUID.Eq(req.UUID), tx.Friend.RevUUID.Eq(req.BlockUuid)).
+			Or(tx.Friend.SendUUID.Eq(req.BlockUuid), tx.Friend.RevUUID.Eq(req.UUID)).
+			Delete(); err != nil {
+			return errcode.ErrDataModifyFail.WithError(err)
+		}
+		// 未处理的请求置为拒绝（3）
+		if _, err := tx.FriendVerify.WithContext(l.ctx).
+			Where(tx.FriendVerify.RevStatus.Eq(1)).
+			Where(
+				tx.FriendVerify.WithContext(l.ctx).
+					Where(tx.FriendVerify.SendUUID.Eq(req.UUID), tx.FriendVerify.RevUUID.Eq(req.BlockUuid)).
+					Or(tx.FriendVerify.SendUUID.Eq(req.BlockUuid), tx.FriendVerify.RevUUID.Eq(req.UUID)),
+			).
+			Update(tx.FriendVerify.RevStatus, 3); err != nil {
+			return errcode.ErrDataModifyFail.WithError(err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,49 @@
+package friend
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type GetBlockListLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 获取黑名单
+func NewGetBlockListLogic(ctx context.Context, svcCtx *svc.ServiceContext) *GetBlockListLogic {
+	return &GetBlockListLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+func (l *GetBlockListLogic) GetBlockList(req *types.GetBlockListReq) (resp *types.GetBlockListResp, err error) {
+	if req.UUID == "" {
+		return nil, errcode.ErrInvalidParam
+	}
+	list, err := dao.FriendBlock.WithContext(l.ctx).
+		Where(dao.FriendBlock.UserUUID.Eq(req.UUID)).
+		Order(dao.FriendBlock.ID.Desc()).
+		Find()
+	if err != nil {
+		return nil, errcode.ErrDataQueryFail.WithError(err)
+	}
+
+	blocks := make([]types.BlockInfo, 0, len(list))
+	for _, b := range list {
+		blocks = append(blocks, types.BlockInfo{
+			UUID:      b.BlockUUID,
+			CreatedAt: b.CreatedAt.Format("2006-01-02 15:04:05"),
+		})
+	}
+	return &types.GetBlockListResp{Blocks: blocks}, nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"imy/internal/dao"
+	"imy/internal/dao/model"
 	"imy/internal/errcode"
 	"imy/internal/svc"
 	"imy/internal/types"
@@ -32,11 +33,21 @@ func (l *GetFriendListLogic) GetFriendList(req *types.GetFriendListReq) (resp *t
 		return nil, errcode.ErrInvalidParam
 	}
 
-	// 根据 uuid 查询好友表，查出自己的好友及其备注
-	list, err := dao.Friend.WithContext(l.ctx).
+	// 根据 uuid 查询好友表，查出自己的好友及其备注，按添加时间倒序
+	q := dao.Friend.WithContext(l.ctx).
 		Where(dao.Friend.SendUUID.Eq(req.UUID)).
 		Or(dao.Friend.RevUUID.Eq(req.UUID)).
-		Find()
+		Order(dao.Friend.ID.Desc())
+	var list []*model.Friend
+	var total int64
+	if req.PageSize > 0 {
+		pageSize := min(req.PageSize, 100)
+		pageIndex := max(req.PageIndex, 1)
+		list, total, err = q.FindByPage((pageIndex-1)*pageSize, pageSize)
+	} else {
+		list, err = q.Find()
+		total = int64(len(list))
+	}
 	if err != nil {
 		return nil, errcode.ErrDataQueryFail.WithError(err)
 	}
@@ -57,5 +68,5 @@ func (l *GetFriendListLogic) GetFriendList(req *types.GetFriendListReq) (resp *t
 		})
 	}
 
-	return &types.GetFriendListResp{Friends: friends}, nil
+	return &types.GetFriendListResp{Friends: friends, Total: total}, nil
 }
@@ -0,0 +1,57 @@
+package friend
+
+import (
+	"context"
+	"errors"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+
+	"gorm.io/gorm"
+)
+
+// IsBlocked 任意一方拉黑了另一方
+func IsBlocked(ctx context.Context, a, b string) (bool, error) {
+	count, err := dao.FriendBlock.WithContext(ctx).
+		Where(dao.FriendBlock.UserUUID.Eq(a), dao.FriendBlock.BlockUUID.Eq(b)).
+		Or(dao.FriendBlock.UserUUID.Eq(b), dao.FriendBlock.BlockUUID.Eq(a)).
+		Count()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// IsFriend 双方是否已是好友，好友关系只存一条记录，发起方可能是任意一方
+func IsFriend(ctx context.Context, a, b string) (bool, error) {
+	_, err := dao.Friend.WithContext(ctx).
+		Where(dao.Friend.SendUUID.Eq(a), dao.Friend.RevUUID.Eq(b)).
+		Or(dao.Friend.SendUUID.Eq(b), dao.Friend.RevUUID.Eq(a)).
+		Take()
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return false, err
+}
+
+// CheckPrivateChat 单聊只能在未拉黑的好友之间建立
+func CheckPrivateChat(ctx context.Context, uuid, peer string) error {
+	blocked, err := IsBlocked(ctx, uuid, peer)
+	if err != nil {
+		return errcode.ErrDataQueryFail.WithError(err)
+	}
+	if blocked {
+		return errcode.ErrUserBlocked
+	}
+	friend, err := IsFriend(ctx, uuid, peer)
+	if err != nil {
+		return errcode.ErrDataQueryFail.WithError(err)
+	}
+	if !friend {
+		return errcode.ErrFriendNotExist
+	}
+	return nil
+}
@@ -10,7 +10,6 @@ import (
 	"imy/internal/svc"
 	"imy/internal/types"
 	"imy/pkg/httpx"
-	"imy/pkg/jwt"
 
 	"github.com/zeromicro/go-zero/core/logx"
 	"gorm.io/gorm"
@@ -56,7 +55,7 @@ func (l *SearchUserLogic) SearchUser(req *types.SearchUserReq) (resp *types.Sear
 			token = r.Header.Get("token")
 		}
 		if token != "" {
-			if claims, perr := jwt.ParseToken(token, l.svcCtx.Config.Auth.AccessSecret); perr == nil && claims != nil {
+			if claims, perr := l.svcCtx.JWTKeys.ParseToken(token); perr == nil && claims != nil {
 				if claims.UUID == user.UUID {
 					// 与当前用户相同，则视为未找到
 					return nil, errcode.ErrAuthUserNotFund
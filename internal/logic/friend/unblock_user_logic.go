@@ -0,0 +1,41 @@
+package friend
+
+import (
+	"context"
+
+	"imy/internal/dao"
+	"imy/internal/errcode"
+	"imy/internal/svc"
+	"imy/internal/types"
+
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type UnblockUserLogic struct {
+	logx.Logger
+	ctx    context.Context
+	svcCtx *svc.ServiceContext
+}
+
+// 解除拉黑
+func NewUnblockUserLogic(ctx context.Context, svcCtx *svc.ServiceContext) *UnblockUserLogic {
+	return &UnblockUserLogic{
+		Logger: logx.WithContext(ctx),
+		ctx:    ctx,
+		svcCtx: svcCtx,
+	}
+}
+
+// UnblockUser 解除拉黑，不恢复之前的好友关系，需要重新发起好友请求
+func (l *UnblockUserLogic) UnblockUser(req *types.UnblockUserReq) error {
+	if req.UUID == "" || req.BlockUuid == "" {
+		return errcode.ErrInvalidParam
+	}
+	// 黑名单表有唯一索引，直接删除记录以便之后可以再次拉黑
+	if _, err := dao.FriendBlock.WithContext(l.ctx).Unscoped().
+		Where(dao.FriendBlock.UserUUID.Eq(req.UUID), dao.FriendBlock.BlockUUID.Eq(req.BlockUuid)).
+		Delete(); err != nil {
+		return errcode.ErrDataModifyFail.WithError(err)
+	}
+	return nil
+}
@@ -38,7 +38,7 @@ func (l *ValidFriendLogic) ValidFriend(req *types.ValidFriendReq) error {
 	verify, err := dao.FriendVerify.WithContext(l.ctx).Where(dao.FriendVerify.ID.Eq(req.VerifyId)).Take()
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errcode.ErrDataQueryFail.WithError(err)
+			return errcode.ErrVerifyNotFound
 		}
 		return errcode.ErrDataQueryFail.WithError(err)
 	}
@@ -47,6 +47,19 @@ func (l *ValidFriendLogic) ValidFriend(req *types.ValidFriendReq) error {
 	if verify.RevUUID != req.UUID {
 		return errcode.ErrInvalidParam
 	}
+	// 已拒绝的请求不能再同意，拉黑时未处理的请求会被置为拒绝
+	if verify.RevStatus == 3 && req.Status == 1 {
+		return errcode.ErrVerifyDeal
+	}
+	if req.Status == 1 {
+		blocked, err := IsBlocked(l.ctx, verify.SendUUID, verify.RevUUID)
+		if err != nil {
+			return errcode.ErrDataQueryFail.WithError(err)
+		}
+		if blocked {
+			return errcode.ErrUserBlocked
+		}
+	}
 
 	switch req.Status {
 	case 1: // 同意
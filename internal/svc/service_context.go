@@ -14,20 +14,41 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"imy/internal/config"
+	"imy/pkg/blob"
 	"imy/pkg/dbgen"
+	"imy/pkg/events"
+	"imy/pkg/jwt"
+	"imy/pkg/moderation"
 	ws "imy/pkg/websocket"
 )
 
 // WsHub maintains active websocket connections keyed by uuid.
 // It supports register/unregister and JSON push to a user's all connections.
+// Connections negotiated as protocol v2 receive typed envelopes; durable events
+// are journaled per user so that a reconnecting v2 client can resume.
 type WsHub struct {
-	mu     sync.RWMutex
-	byUUID map[string]map[*websocket.Conn]struct{}
-	locks  map[*websocket.Conn]*sync.Mutex
+	mu       sync.RWMutex
+	byUUID   map[string]map[*websocket.Conn]struct{}
+	locks    map[*websocket.Conn]*sync.Mutex
+	versions map[*websocket.Conn]int
+	sessions map[*websocket.Conn]string // login session of the connection token
+	journal  *WsJournal                 // nil disables journaling and resume
 }
 
 func NewWsHub() *WsHub {
-	return &WsHub{byUUID: make(map[string]map[*websocket.Conn]struct{}), locks: make(map[*websocket.Conn]*sync.Mutex)}
+	return &WsHub{
+		byUUID:   make(map[string]map[*websocket.Conn]struct{}),
+		locks:    make(map[*websocket.Conn]*sync.Mutex),
+		versions: make(map[*websocket.Conn]int),
+		sessions: make(map[*websocket.Conn]string),
+	}
+}
+
+// NewWsHubWithJournal creates a hub that journals durable events before delivery.
+func NewWsHubWithJournal(journal *WsJournal) *WsHub {
+	h := NewWsHub()
+	h.journal = journal
+	return h
 }
 
 func (h *WsHub) getLock(c *websocket.Conn) *sync.Mutex {
@@ -39,7 +60,14 @@ func (h *WsHub) getLock(c *websocket.Conn) *sync.Mutex {
 	return l
 }
 
-func (h *WsHub) Register(uuid string, conn *websocket.Conn) {
+// Register adds a legacy (v1) connection.
+func (h *WsHub) Register(uuid string, conn *websocket.Conn) bool {
+	return h.RegisterVersion(uuid, conn, 1)
+}
+
+// RegisterVersion adds a connection speaking the given protocol version and
+// reports whether it is the user's first active connection.
+func (h *WsHub) RegisterVersion(uuid string, conn *websocket.Conn, version int) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	set, ok := h.byUUID[uuid]
@@ -51,18 +79,98 @@ func (h *WsHub) Register(uuid string, conn *websocket.Conn) {
 	if _, ok := h.locks[conn]; !ok {
 		h.locks[conn] = &sync.Mutex{}
 	}
+	h.versions[conn] = version
+	return len(set) == 1
 }
 
-func (h *WsHub) Unregister(uuid string, conn *websocket.Conn) {
+// Unregister removes a connection and reports whether it was the user's last one.
+func (h *WsHub) Unregister(uuid string, conn *websocket.Conn) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	return h.removeLocked(uuid, conn)
+}
+
+func (h *WsHub) removeLocked(uuid string, conn *websocket.Conn) bool {
+	last := false
 	if set, ok := h.byUUID[uuid]; ok {
-		delete(set, conn)
-		if len(set) == 0 {
-			delete(h.byUUID, uuid)
+		if _, ok := set[conn]; ok {
+			delete(set, conn)
+			if len(set) == 0 {
+				delete(h.byUUID, uuid)
+				last = true
+			}
 		}
 	}
 	delete(h.locks, conn)
+	delete(h.versions, conn)
+	delete(h.sessions, conn)
+	return last
+}
+
+// SetSession records the login session of a registered connection; it is
+// updated when the client renews its token in band.
+func (h *WsHub) SetSession(conn *websocket.Conn, sessionID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.versions[conn]; !ok {
+		return
+	}
+	if sessionID == "" {
+		delete(h.sessions, conn)
+		return
+	}
+	h.sessions[conn] = sessionID
+}
+
+// SessionConns returns the number of active connections per login session of the uuid.
+func (h *WsHub) SessionConns(uuid string) map[string]int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	counts := make(map[string]int)
+	for c := range h.byUUID[uuid] {
+		if id, ok := h.sessions[c]; ok {
+			counts[id]++
+		}
+	}
+	return counts
+}
+
+// CloseSession pushes a logout notice to the connections of one login session
+// and closes them with 1008; legacy goes to v1 connections and env to v2 ones.
+// It returns the number of connections closed.
+func (h *WsHub) CloseSession(uuid, sessionID string, legacy any, env *ws.Envelope) int {
+	h.mu.RLock()
+	targets := make(map[*websocket.Conn]int)
+	for c := range h.byUUID[uuid] {
+		if h.sessions[c] == sessionID {
+			targets[c] = h.versions[c]
+		}
+	}
+	h.mu.RUnlock()
+	for c, version := range targets {
+		var payload any = env
+		if version < 2 {
+			payload = legacy
+		}
+		_ = h.WithConnWrite(c, func(conn *websocket.Conn) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := conn.WriteJSON(payload); err != nil {
+				return err
+			}
+			msg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+			return conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(10*time.Second))
+		})
+		// the read loop of the connection ends and unregisters it
+		_ = c.Close()
+	}
+	return len(targets)
+}
+
+// Online reports whether the uuid has any active connection.
+func (h *WsHub) Online(uuid string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.byUUID[uuid]) > 0
 }
 
 // WithConnWrite acquires the per-connection write lock, executes fn, and releases the lock.
@@ -81,36 +189,106 @@ func (h *WsHub) WithConnWrite(conn *websocket.Conn, fn func(*websocket.Conn) err
 	return fn(conn)
 }
 
-// SendJSON pushes one JSON payload to all active connections of the uuid.
+// SendJSON pushes one legacy {op, data} payload to all active connections of the uuid.
+// v2 connections receive it wrapped in an envelope; durable events are journaled
+// first, even when the user is offline, so they can be replayed on resume.
 // It removes broken connections on write error.
 func (h *WsHub) SendJSON(uuid string, v any) {
+	env, err := ws.WrapLegacy(v)
+	if err != nil {
+		logx.Errorf("ws wrap payload error: %v", err)
+		return
+	}
+	if h.journal != nil && env.Type.Durable() {
+		if err := h.journal.Append(uuid, env); err != nil {
+			logx.Errorf("ws journal append error, uuid=%s: %v", uuid, err)
+		}
+	}
+	h.deliver(uuid, v, env)
+}
+
+// SendEnvelope pushes an ephemeral envelope (presence, typing) to the v2 connections of the uuid.
+// It is not journaled.
+func (h *WsHub) SendEnvelope(uuid string, env *ws.Envelope) {
+	h.deliver(uuid, nil, env)
+}
+
+// deliver writes legacy to v1 connections (skipped when nil) and env to v2 connections.
+func (h *WsHub) deliver(uuid string, legacy any, env *ws.Envelope) {
 	h.mu.RLock()
-	set := h.byUUID[uuid]
+	targets := make(map[*websocket.Conn]int, len(h.byUUID[uuid]))
+	for c := range h.byUUID[uuid] {
+		targets[c] = h.versions[c]
+	}
 	h.mu.RUnlock()
-	if len(set) == 0 {
-		return
+	for c, version := range targets {
+		var payload any = env
+		if version < 2 {
+			if legacy == nil {
+				continue
+			}
+			payload = legacy
+		}
+		if err := h.WriteConn(c, payload); err != nil {
+			logx.Infof("ws write error, removing conn: %v", err)
+			// cleanup broken conn
+			h.mu.Lock()
+			h.removeLocked(uuid, c)
+			h.mu.Unlock()
+			_ = c.Close()
+		}
 	}
-	deadline := time.Now().Add(10 * time.Second)
-	for c := range set {
-		_ = h.WithConnWrite(c, func(conn *websocket.Conn) error {
-			_ = conn.SetWriteDeadline(deadline)
-			if err := conn.WriteJSON(v); err != nil {
-				logx.Infof("ws write error, removing conn: %v", err)
-				// cleanup broken conn
-				h.mu.Lock()
-				if s, ok := h.byUUID[uuid]; ok {
-					delete(s, conn)
-					if len(s) == 0 {
-						delete(h.byUUID, uuid)
-					}
-				}
-				delete(h.locks, conn)
-				h.mu.Unlock()
-				_ = conn.Close()
+}
+
+// WriteConn writes one JSON frame to a single connection under its write lock.
+func (h *WsHub) WriteConn(conn *websocket.Conn, v any) error {
+	return h.WithConnWrite(conn, func(c *websocket.Conn) error {
+		_ = c.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return c.WriteJSON(v)
+	})
+}
+
+// Resume replays journaled events after lastSeq to one connection and finishes
+// with a resume envelope. lastSeq <= 0 falls back to the user's acked checkpoint.
+// Events pushed concurrently may arrive interleaved with the replay; clients
+// drop envelopes whose seq they have already processed.
+func (h *WsHub) Resume(uuid string, conn *websocket.Conn, lastSeq int64) error {
+	result := ws.ResumeResult{LastSeq: lastSeq}
+	if h.journal != nil {
+		if lastSeq <= 0 {
+			lastSeq = h.journal.Checkpoint(uuid)
+			result.LastSeq = lastSeq
+		}
+		envs, more := h.journal.After(uuid, lastSeq)
+		for _, env := range envs {
+			if err := h.WriteConn(conn, env); err != nil {
+				return err
 			}
-			return nil
-		})
+			result.LastSeq = env.Seq
+		}
+		result.Replayed = len(envs)
+		result.HasMore = more
+	}
+	env, err := ws.NewEnvelope(ws.EnvelopeResume, 0, result)
+	if err != nil {
+		return err
 	}
+	return h.WriteConn(conn, env)
+}
+
+// Ack records that the user processed every durable event up to seq.
+func (h *WsHub) Ack(uuid string, seq int64) {
+	if h.journal != nil {
+		h.journal.Ack(uuid, seq)
+	}
+}
+
+// Checkpoint returns the user's acked seq, 0 when journaling is disabled.
+func (h *WsHub) Checkpoint(uuid string) int64 {
+	if h.journal == nil {
+		return 0
+	}
+	return h.journal.Checkpoint(uuid)
 }
 
 type ServiceContext struct {
@@ -120,6 +298,15 @@ type ServiceContext struct {
 	Ws     *WsHub
 	Snow   *snowflake.Node
 	WsHub  *ws.Hub
+	Blob   blob.Store
+	// 访问令牌的签发与校验
+	JWTKeys *jwt.KeySet
+	// 登录会话，每次登录一个，可按设备查看与吊销
+	Sessions *SessionStore
+	// 消息内容审核，未启用时为nil
+	Moderation *moderation.Pipeline
+	// 向外部系统发布消息与成员事件，未启用时为nil
+	Events *events.Bus
 }
 
 func NewServiceContext(c config.Config) *ServiceContext {
@@ -137,15 +324,56 @@ func NewServiceContext(c config.Config) *ServiceContext {
 	if err != nil {
 		logx.Errorf("snowflake.NewNode err: %s", err)
 	}
+	jwtKeys, err := jwt.LoadKeySet(c.Auth.Keys, c.Auth.AccessSecret)
+	if err == nil && jwtKeys.Active() == nil {
+		err = jwt.ErrNoSigningKey
+	}
+	if err != nil {
+		logx.Errorf("jwt keys init err: %s", err)
+		panic("jwt keys cannot be initialized!")
+	}
+	blobStore, err := blob.New(c.Attachment.Blob)
+	if err != nil {
+		logx.Errorf("blob store init err: %s", err)
+		panic("blob store cannot be initialized!")
+	}
+	moderationPipeline, err := moderation.New(c.Moderation)
+	if err != nil {
+		logx.Errorf("moderation init err: %s", err)
+		panic("moderation cannot be initialized!")
+	}
+	if c.Events.Source == "" {
+		c.Events.Source = c.Name
+	}
+	eventBus, err := events.New(c.Events)
+	if err != nil {
+		logx.Errorf("event bus init err: %s", err)
+		panic("event bus cannot be initialized!")
+	}
 	wsHub := ws.NewHub()
 	go wsHub.Run()
+	chatWs := NewWsHub()
+	if !c.WsJournal.Disable {
+		journal, err := NewWsJournal(c.WsJournal)
+		if err != nil {
+			logx.Errorf("ws journal init err: %s", err)
+			panic("ws journal cannot be initialized!")
+		}
+		chatWs = NewWsHubWithJournal(journal)
+	}
 	return &ServiceContext{
 		Config: c,
 		Redis:  redisClient,
 		Mysql:  mysqldb,
-		Ws:     NewWsHub(),
+		Ws:     chatWs,
 		Snow:   Node,
 		WsHub:  wsHub,
+		Blob:   blobStore,
+
+		JWTKeys:    jwtKeys,
+		Sessions:   NewSessionStore(redisClient, c.Auth.MaxSessions),
+		Moderation: moderationPipeline,
+		Events:     eventBus,
 	}
 }
 
@@ -0,0 +1,295 @@
+package svc
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// ErrSessionNotFound 会话不存在：已过期、被吊销或被新的登录淘汰
+var ErrSessionNotFound = errors.New("session not found")
+
+// sessionTouchInterval 同一会话两次记录活跃时间的最小间隔
+const sessionTouchInterval = time.Minute
+
+// Session 一次登录产生的会话，每台设备各自持有，轮换刷新令牌时沿用同一会话
+type Session struct {
+	ID        string `json:"id"`
+	UUID      string `json:"uuid"`
+	Email     string `json:"email,omitempty"`
+	Nickname  string `json:"nickname,omitempty"`
+	Device    string `json:"device"`
+	UserAgent string `json:"userAgent,omitempty"`
+	IP        string `json:"ip"`        // 登录时的IP
+	RefreshID string `json:"refreshId"` // 当前有效的刷新令牌ID
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// SessionActivity 会话最近一次活跃的时间与IP
+type SessionActivity struct {
+	At int64  `json:"at"`
+	IP string `json:"ip"`
+}
+
+// SessionStore 在redis中按用户保存登录会话
+// sessions_<uuid> 保存会话本身，sessions_active_<uuid> 单独保存活跃信息，
+// 记录活跃不会覆盖刷新时轮换的RefreshID
+type SessionStore struct {
+	redis *redis.Client
+	max   int
+
+	mu      sync.Mutex
+	touched map[string]time.Time // 会话ID -> 本进程最近一次记录活跃的时间
+}
+
+// updateSessionScript 只在会话仍存在时覆盖，避免刷新与吊销并发时把已吊销的会话写回
+var updateSessionScript = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+return 1
+`)
+
+// NewSessionStore 创建会话存储，max为每个用户保留的会话数，不大于0时不限制
+func NewSessionStore(client *redis.Client, max int) *SessionStore {
+	return &SessionStore{redis: client, max: max, touched: make(map[string]time.Time)}
+}
+
+func sessionsKey(uuid string) string {
+	return "sessions_" + uuid
+}
+
+func sessionActivityKey(uuid string) string {
+	return "sessions_active_" + uuid
+}
+
+// Create 保存新会话，超出数量上限时淘汰最久未活跃的会话并返回它们的ID
+func (s *SessionStore) Create(sess *Session, ttl time.Duration) ([]string, error) {
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return nil, err
+	}
+	activity, err := json.Marshal(SessionActivity{At: sess.CreatedAt, IP: sess.IP})
+	if err != nil {
+		return nil, err
+	}
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(sessionsKey(sess.UUID), sess.ID, string(b))
+	pipe.HSet(sessionActivityKey(sess.UUID), sess.ID, string(activity))
+	pipe.Expire(sessionsKey(sess.UUID), ttl)
+	pipe.Expire(sessionActivityKey(sess.UUID), ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return nil, err
+	}
+	s.markTouched(sess.ID)
+	if s.max <= 0 {
+		return nil, nil
+	}
+	sessions, err := s.List(sess.UUID)
+	if err != nil || len(sessions) <= s.max {
+		return nil, err
+	}
+	var evicted []string
+	// List按活跃时间倒序，新会话刚写入，不会被淘汰
+	for _, old := range sessions[s.max:] {
+		if old.ID == sess.ID {
+			continue
+		}
+		if _, err := s.Delete(sess.UUID, old.ID); err != nil {
+			return evicted, err
+		}
+		evicted = append(evicted, old.ID)
+	}
+	return evicted, nil
+}
+
+// Update 覆盖仍存在的会话，会话已被删除时返回ErrSessionNotFound
+func (s *SessionStore) Update(sess *Session, ttl time.Duration) error {
+	b, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	keys := []string{sessionsKey(sess.UUID), sessionActivityKey(sess.UUID)}
+	n, err := updateSessionScript.Run(s.redis, keys, sess.ID, string(b), int64(ttl/time.Second)).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+// Get 查询会话，不存在或已过期时返回ErrSessionNotFound
+func (s *SessionStore) Get(uuid, id string) (*Session, error) {
+	str, err := s.redis.HGet(sessionsKey(uuid), id).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sess Session
+	if err := json.Unmarshal([]byte(str), &sess); err != nil {
+		return nil, err
+	}
+	if sess.ExpiresAt > 0 && time.Now().Unix() >= sess.ExpiresAt {
+		_, _ = s.Delete(uuid, id)
+		return nil, ErrSessionNotFound
+	}
+	return &sess, nil
+}
+
+// SessionWithActivity 会话及其最近活跃信息
+type SessionWithActivity struct {
+	*Session
+	Activity SessionActivity
+}
+
+// List 返回用户未过期的会话，按最近活跃时间倒序；过期的会话顺带清理
+func (s *SessionStore) List(uuid string) ([]SessionWithActivity, error) {
+	all, err := s.redis.HGetAll(sessionsKey(uuid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	activities, err := s.redis.HGetAll(sessionActivityKey(uuid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	sessions := make([]SessionWithActivity, 0, len(all))
+	for id, str := range all {
+		var sess Session
+		if err := json.Unmarshal([]byte(str), &sess); err != nil {
+			logx.Errorf("会话信息格式出错 %s/%s：%v", uuid, id, err)
+			continue
+		}
+		if sess.ExpiresAt > 0 && now >= sess.ExpiresAt {
+			_, _ = s.Delete(uuid, id)
+			continue
+		}
+		item := SessionWithActivity{Session: &sess, Activity: SessionActivity{At: sess.CreatedAt, IP: sess.IP}}
+		if a, ok := activities[id]; ok {
+			_ = json.Unmarshal([]byte(a), &item.Activity)
+		}
+		sessions = append(sessions, item)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Activity.At != sessions[j].Activity.At {
+			return sessions[i].Activity.At > sessions[j].Activity.At
+		}
+		return sessions[i].CreatedAt > sessions[j].CreatedAt
+	})
+	return sessions, nil
+}
+
+// Delete 删除会话，返回会话此前是否存在
+func (s *SessionStore) Delete(uuid, id string) (bool, error) {
+	pipe := s.redis.TxPipeline()
+	deleted := pipe.HDel(sessionsKey(uuid), id)
+	pipe.HDel(sessionActivityKey(uuid), id)
+	if _, err := pipe.Exec(); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	delete(s.touched, id)
+	s.mu.Unlock()
+	return deleted.Val() > 0, nil
+}
+
+// Exists 会话是否仍然有效，供每个请求调用，只查redis中是否存在
+func (s *SessionStore) Exists(uuid, id string) (bool, error) {
+	return s.redis.HExists(sessionsKey(uuid), id).Result()
+}
+
+// Touch 记录会话活跃，同一会话在sessionTouchInterval内只写一次
+func (s *SessionStore) Touch(uuid, id, ip string) {
+	if !s.shouldTouch(id) {
+		return
+	}
+	b, err := json.Marshal(SessionActivity{At: time.Now().Unix(), IP: ip})
+	if err != nil {
+		return
+	}
+	// HSET不会创建会话本身，已删除会话的活跃记录会随key过期
+	if err := s.redis.HSet(sessionActivityKey(uuid), id, string(b)).Err(); err != nil {
+		logx.Errorf("记录会话活跃失败：%v", err)
+	}
+}
+
+func (s *SessionStore) shouldTouch(id string) bool {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if last, ok := s.touched[id]; ok && now.Sub(last) < sessionTouchInterval {
+		return false
+	}
+	if len(s.touched) >= 100000 {
+		for k, last := range s.touched {
+			if now.Sub(last) >= sessionTouchInterval {
+				delete(s.touched, k)
+			}
+		}
+	}
+	s.touched[id] = now
+	return true
+}
+
+func (s *SessionStore) markTouched(id string) {
+	s.mu.Lock()
+	s.touched[id] = time.Now()
+	s.mu.Unlock()
+}
+
+// Middleware 拒绝所属会话已被吊销的请求并记录会话活跃
+// 会话ID由网关从访问令牌的sid声明注入到sid请求头，没有该请求头的请求直接放行
+func (s *SessionStore) Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uuid, id := r.Header.Get("uuid"), r.Header.Get("sid")
+		if uuid == "" || id == "" {
+			next(w, r)
+			return
+		}
+		ok, err := s.Exists(uuid, id)
+		if err != nil {
+			// redis不可用时不拦截请求，访问令牌本身仍然有效
+			logx.Errorf("查询会话失败：%v", err)
+			next(w, r)
+			return
+		}
+		if !ok {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code":401,"msg":"session revoked"}`))
+			return
+		}
+		s.Touch(uuid, id, RequestIP(r))
+		next(w, r)
+	}
+}
+
+// RequestIP 客户端IP，经网关转发时取X-Forwarded-For中的第一个地址
+func RequestIP(r *http.Request) string {
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		if i := strings.IndexByte(xff, ','); i >= 0 {
+			xff = xff[:i]
+		}
+		return strings.TrimSpace(xff)
+	}
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return ip
+	}
+	return r.RemoteAddr
+}
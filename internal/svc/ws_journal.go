@@ -0,0 +1,96 @@
+package svc
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/zeromicro/go-zero/core/logx"
+	"imy/internal/config"
+	"imy/pkg/storage"
+	ws "imy/pkg/websocket"
+)
+
+// wsJournalCapacity 日志Store容量上限，过期事件由保留策略清理
+const wsJournalCapacity = 1 << 40
+
+// WsJournal 按用户记录推送过的持久事件，事件写入用户Timeline，SeqID即envelope的seq
+type WsJournal struct {
+	store     *storage.Store
+	retention *storage.RetentionManager
+	maxReplay int
+}
+
+// NewWsJournal 创建WebSocket事件日志
+func NewWsJournal(c config.WsJournal) (*WsJournal, error) {
+	store, err := storage.NewStore(&storage.StoreConfig{
+		MaxCapacity:     wsJournalCapacity,
+		TimelineMaxSize: 100,
+		DataDir:         c.Dir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	retention := storage.NewRetentionManager(store, nil, &storage.RetentionPolicy{
+		MaxAge:        c.Retention,
+		CheckInterval: storage.DefaultRetentionPolicy().CheckInterval,
+	})
+	if c.Retention > 0 {
+		if err := retention.Start(context.Background()); err != nil {
+			store.Close()
+			return nil, err
+		}
+	}
+	maxReplay := c.MaxReplay
+	if maxReplay <= 0 {
+		maxReplay = 500
+	}
+	return &WsJournal{store: store, retention: retention, maxReplay: maxReplay}, nil
+}
+
+// Append 记录一条事件并回填env.Seq
+func (j *WsJournal) Append(uuid string, env *ws.Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	msg, err := j.store.AppendUserEvent(uuid, data)
+	if err != nil {
+		return err
+	}
+	env.Seq = msg.SeqID
+	return nil
+}
+
+// After 获取afterSeq之后最多maxReplay条事件，第二个返回值表示是否还有剩余
+func (j *WsJournal) After(uuid string, afterSeq int64) ([]*ws.Envelope, bool) {
+	msgs, more := j.store.GetUserMessagesAfter(uuid, afterSeq, j.maxReplay)
+	envs := make([]*ws.Envelope, 0, len(msgs))
+	for _, msg := range msgs {
+		env := &ws.Envelope{}
+		if err := json.Unmarshal(msg.Data, env); err != nil {
+			logx.Errorf("ws journal: skip corrupt event %d of %s: %v", msg.SeqID, uuid, err)
+			continue
+		}
+		env.Seq = msg.SeqID
+		envs = append(envs, env)
+	}
+	return envs, more
+}
+
+// Checkpoint 用户最后确认的seq
+func (j *WsJournal) Checkpoint(uuid string) int64 {
+	return j.store.GetUserCheckpoint(uuid)
+}
+
+// Ack 确认seq及之前的事件，只前进不后退
+func (j *WsJournal) Ack(uuid string, seq int64) {
+	if seq > j.store.GetUserCheckpoint(uuid) {
+		j.store.UpdateUserCheckpoint(uuid, seq)
+	}
+}
+
+// Close 停止清理并关闭日志Store
+func (j *WsJournal) Close() error {
+	j.retention.Stop()
+	return j.store.Close()
+}
@@ -40,6 +40,14 @@ type AllVerifyResp struct {
 	Infos []VerifyInfo `json:"infos"`
 }
 
+type AttachmentInfo struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Mime string `json:"mime"`
+	Url  string `json:"url"`
+}
+
 type AuthCheckReq struct {
 	Token     string `head:"token"`
 	ValidPath string `head:"validPath"`
@@ -49,20 +57,31 @@ type AuthCheckResp struct {
 	UUID string `json:"uuid"`
 }
 
+type BlockInfo struct {
+	UUID      string `json:"uuid"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type BlockUserReq struct {
+	UUID      string `head:"uuid"`
+	BlockUuid string `json:"blockUuid"`
+}
+
 type ConversationInfo struct {
-	ConversationId uint32 `json:"conversationId"`
-	Type           uint32 `json:"type"` // 1:单聊 2:群聊
-	PrivateKey     string `json:"privateKey"`
-	Name           string `json:"name"`
-	MemberCount    uint32 `json:"memberCount"`
-	LastMessageId  uint64 `json:"lastMessageId"`
-	Avatar         string `json:"avatar"`
-	Extra          string `json:"extra"`
+	ConversationId   uint32 `json:"conversationId"`
+	Type             uint32 `json:"type"` // 1:单聊 2:群聊
+	PrivateKey       string `json:"privateKey"`
+	Name             string `json:"name"`
+	MemberCount      uint32 `json:"memberCount"`
+	LastMessageId    uint64 `json:"lastMessageId"`
+	Avatar           string `json:"avatar"`
+	Extra            string `json:"extra"`
+	AnnouncementOnly uint32 `json:"announcementOnly"` // 0/1，1表示仅群主和管理员可发言
 }
 
 type ConversationMember struct {
 	UserUUID  string `json:"userUuid"`
-	Role      uint32 `json:"role"`
+	Role      uint32 `json:"role"` // 1:普通成员 2:管理员 3:群主
 	Alias     string `json:"alias"`
 	MuteUntil string `json:"muteUntil"` // RFC3339 字符串
 	IsPinned  uint32 `json:"isPinned"`  // 0/1
@@ -84,6 +103,27 @@ type DealVerifyReq struct {
 	Status uint8  `json:"status"`
 }
 
+type DeleteMessageReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	MessageId      uint64 `json:"messageId"`
+}
+
+type DownloadAttachmentReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `form:"conversationId"`
+	Key            string `form:"key"`
+	Name           string `form:"name,optional"`
+}
+
+type EditMessageReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	MessageId      uint64 `json:"messageId"`
+	Content        string `json:"content"`
+	ContentExtra   string `json:"contentExtra,optional"`
+}
+
 type EmailCodeReq struct {
 	Code  string `json:"code"`
 	Email string `json:"email"`
@@ -96,10 +136,15 @@ type EmailCodeResp struct {
 type EmailPasswordLoginReq struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	Device   string `json:"device,optional"`
 }
 
 type EmailPasswordLoginResp struct {
-	UUID string `json:"uuid"`
+	UUID         string `json:"uuid"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+	SessionId    string `json:"sessionId"`
 }
 
 type EmailPasswordRegisterReq struct {
@@ -112,6 +157,12 @@ type EmailPasswordRegisterResp struct {
 	UUID string `json:"uuid"`
 }
 
+type ExportConversationReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `form:"conversationId"`
+	Format         string `form:"format,default=ndjson,options=ndjson|csv"`
+}
+
 type FriendInfo struct {
 	UUID   string `json:"uuid"`
 	Notice string `json:"notice"`
@@ -130,6 +181,14 @@ type GetAccountByNameResp struct {
 	Account string `json:"account"`
 }
 
+type GetBlockListReq struct {
+	UUID string `head:"uuid"`
+}
+
+type GetBlockListResp struct {
+	Blocks []BlockInfo `json:"blocks"`
+}
+
 type GetConversationDetailReq struct {
 	UUID           string `head:"uuid"`
 	ConversationId uint32 `json:"conversationId"`
@@ -159,11 +218,27 @@ type GetEmailCodeResp struct {
 }
 
 type GetFriendListReq struct {
-	UUID string `json:"uuid"`
+	UUID      string `json:"uuid"`
+	PageSize  int    `json:"pageSize,optional"`
+	PageIndex int    `json:"pageIndex,optional"`
 }
 
 type GetFriendListResp struct {
 	Friends []FriendInfo `json:"friends"`
+	Total   int64        `json:"total"`
+}
+
+type GetMentionsReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId,optional"`
+	BeforeId       uint64 `json:"beforeId,optional"`
+	Limit          int    `json:"limit,default=20"`
+}
+
+type GetMentionsResp struct {
+	Mentions     []MentionInfo       `json:"mentions"`
+	UnreadCounts []MentionUnreadItem `json:"unreadCounts"`
+	HasMore      bool                `json:"hasMore"`
 }
 
 type GetMessagesReq struct {
@@ -178,6 +253,38 @@ type GetMessagesResp struct {
 	Messages []MessageInfo `json:"messages"`
 }
 
+type GetPinnedMessagesReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+}
+
+type GetPinnedMessagesResp struct {
+	Pins []PinnedMessage `json:"pins"`
+}
+
+type GetSessionsReq struct {
+	UUID      string `header:"uuid"`
+	SessionId string `header:"sid,optional"`
+}
+
+type GetSessionsResp struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type GetThreadReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	RootId         uint64 `json:"rootId"`
+	AfterId        uint64 `json:"afterId,optional"`
+	Limit          int    `json:"limit,default=20"`
+}
+
+type GetThreadResp struct {
+	Root    MessageInfo   `json:"root"`
+	Replies []MessageInfo `json:"replies"`
+	HasMore bool          `json:"hasMore"`
+}
+
 type GetUnreadCountsReq struct {
 	UUID string `head:"uuid"`
 }
@@ -186,6 +293,16 @@ type GetUnreadCountsResp struct {
 	Items []UnreadItem `json:"items"`
 }
 
+type MentionInfo struct {
+	Message MessageInfo `json:"message"`
+	IsRead  uint32      `json:"isRead"` // 0/1，消息ID不大于已读进度时为已读
+}
+
+type MentionUnreadItem struct {
+	ConversationId uint32 `json:"conversationId"`
+	Unread         uint32 `json:"unread"`
+}
+
 type MessageInfo struct {
 	Id               uint64   `json:"id"`
 	ConversationId   uint32   `json:"conversationId"`
@@ -195,8 +312,9 @@ type MessageInfo struct {
 	ContentExtra     string   `json:"contentExtra"`
 	ReplyToMessageId uint64   `json:"replyToMessageId"`
 	MentionedUuids   []string `json:"mentionedUuids"`
-	IsSystem         uint32   `json:"isSystem"`  // 0/1
-	IsRevoked        uint32   `json:"isRevoked"` // 0/1
+	IsSystem         uint32   `json:"isSystem"`   // 0/1
+	IsRevoked        uint32   `json:"isRevoked"`  // 0/1
+	ReplyCount       uint32   `json:"replyCount"` // 直接回复该消息的数量
 	CreatedAt        string   `json:"createdAt"`
 }
 
@@ -210,6 +328,18 @@ type PagerForm struct {
 	PageIndex int `form:"pageIndex,default=1"` // 分页页码, 需要大于 0
 }
 
+type PinMessageReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	MessageId      uint64 `json:"messageId"`
+}
+
+type PinnedMessage struct {
+	Message  MessageInfo `json:"message"`
+	PinUuid  string      `json:"pinUuid"`
+	PinnedAt string      `json:"pinnedAt"` // RFC3339
+}
+
 type ReadMessagesReq struct {
 	UUID           string `head:"uuid"`
 	ConversationId uint32 `json:"conversationId"`
@@ -220,6 +350,18 @@ type ReadMessagesResp struct {
 	LastReadMessageId uint64 `json:"lastReadMessageId"`
 }
 
+type RefreshTokenReq struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+type RefreshTokenResp struct {
+	UUID         string `json:"uuid"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+	SessionId    string `json:"sessionId"`
+}
+
 type RecallMessageReq struct {
 	UUID           string `head:"uuid"`
 	ConversationId uint32 `json:"conversationId"`
@@ -232,6 +374,15 @@ type RemoveMemberReq struct {
 	RemoveUUID     string `json:"removeUuid"`
 }
 
+type RevokeSessionReq struct {
+	UUID      string `header:"uuid"`
+	SessionId string `json:"sessionId"`
+}
+
+type RevokeSessionResp struct {
+	WsClosed int `json:"wsClosed"`
+}
+
 type SearchUserReq struct {
 	Email string `json:"email"`
 }
@@ -240,11 +391,23 @@ type SearchUserResp struct {
 	RevId string `json:"revId"`
 }
 
+type ServiceTokenReq struct {
+	ClientId     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+type ServiceTokenResp struct {
+	UUID        string `json:"uuid"`
+	AccessToken string `json:"accessToken"`
+	ExpiresIn   int64  `json:"expiresIn"`
+	Scope       string `json:"scope"`
+}
+
 type SendMessageReq struct {
 	UUID             string   `head:"uuid"`
 	ConversationId   uint32   `json:"conversationId"`
 	ClientMsgId      string   `json:"clientMsgId"`
-	MsgType          uint32   `json:"msgType"` // 1文本、2图片、3语音、4视频、5文件、6系统
+	MsgType          uint32   `json:"msgType"` // 1文本、2图片、3语音、4视频、5文件、6系统、7附件（content 为 AttachmentInfo JSON）
 	Content          string   `json:"content"`
 	ContentExtra     string   `json:"contentExtra,optional"`
 	ReplyToMessageId uint64   `json:"replyToMessageId,optional"`
@@ -261,11 +424,56 @@ type SendVerifyReq struct {
 	Account string `json:"account"`
 }
 
+type SessionInfo struct {
+	SessionId     string `json:"sessionId"`
+	Device        string `json:"device"`
+	UserAgent     string `json:"userAgent"`
+	LoginIp       string `json:"loginIp"`
+	LastIp        string `json:"lastIp"`
+	CreatedAt     int64  `json:"createdAt"`
+	LastActive    int64  `json:"lastActive"`
+	ExpiresAt     int64  `json:"expiresAt"`
+	WsConnections int    `json:"wsConnections"`
+	Current       bool   `json:"current"`
+}
+
+type SetMemberRoleReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	MemberUuid     string `json:"memberUuid"`
+	Role           uint32 `json:"role,options=1|2"` // 1:普通成员 2:管理员
+}
+
+type TransferOwnerReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	NewOwnerUuid   string `json:"newOwnerUuid"`
+}
+
+type UnblockUserReq struct {
+	UUID      string `head:"uuid"`
+	BlockUuid string `json:"blockUuid"`
+}
+
+type UnpinMessageReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `json:"conversationId"`
+	MessageId      uint64 `json:"messageId"`
+}
+
 type UnreadItem struct {
 	ConversationId uint32 `json:"conversationId"`
 	Unread         uint32 `json:"unread"`
 }
 
+type UpdateConversationInfoReq struct {
+	UUID             string `head:"uuid"`
+	ConversationId   uint32 `json:"conversationId"`
+	Name             string `json:"name,optional"`
+	Avatar           string `json:"avatar,optional"`
+	AnnouncementOnly int32  `json:"announcementOnly,default=-1"`
+}
+
 type UpdateConversationSettingsReq struct {
 	UUID           string `head:"uuid"`
 	ConversationId uint32 `json:"conversationId"`
@@ -274,6 +482,11 @@ type UpdateConversationSettingsReq struct {
 	IsPinned       uint32 `json:"isPinned,optional"` // 0/1
 }
 
+type UploadAttachmentReq struct {
+	UUID           string `head:"uuid"`
+	ConversationId uint32 `form:"conversationId"`
+}
+
 type ValidFriendInfo struct {
 	Id        uint32 `json:"id"`
 	RevId     string `json:"revId"`
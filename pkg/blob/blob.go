@@ -0,0 +1,60 @@
+// Package blob 提供附件等二进制对象的存储后端，支持本地目录和S3兼容存储
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrNotFound 对象不存在
+var ErrNotFound = errors.New("blob: object not found")
+
+// Info 对象元信息
+type Info struct {
+	Size        int64
+	ContentType string
+}
+
+// Store 对象存储后端
+type Store interface {
+	// Put 写入对象，size为-1表示长度未知
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get 读取对象，调用方负责关闭返回的Reader
+	Get(ctx context.Context, key string) (io.ReadCloser, *Info, error)
+	// Delete 删除对象，对象不存在时不报错
+	Delete(ctx context.Context, key string) error
+}
+
+// Config 存储后端配置
+type Config struct {
+	Backend string      `json:",default=local,options=local|s3"`
+	Local   LocalConfig `json:",optional"`
+	S3      S3Config    `json:",optional"`
+}
+
+// New 按配置创建存储后端
+func New(c Config) (Store, error) {
+	switch c.Backend {
+	case "", "local":
+		return NewLocalStore(c.Local.Dir)
+	case "s3":
+		return NewS3Store(c.S3)
+	}
+	return nil, fmt.Errorf("blob: unknown backend %q", c.Backend)
+}
+
+// CleanKey 校验并规范化对象键，拒绝绝对路径和跳出根目录的键
+func CleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", fmt.Errorf("blob: invalid key %q", key)
+	}
+	cleaned := path.Clean(key)
+	if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("blob: invalid key %q", key)
+	}
+	return cleaned, nil
+}
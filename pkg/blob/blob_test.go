@@ -0,0 +1,122 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+
+	if err := store.Put(ctx, "conv/1/a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	rc, info, err := store.Get(ctx, "conv/1/a.txt")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" || info.Size != 5 {
+		t.Fatalf("Unexpected object: %q %+v", data, info)
+	}
+
+	if err := store.Delete(ctx, "conv/1/a.txt"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, _, err := store.Get(ctx, "conv/1/a.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	for _, key := range []string{"../escape", "/abs", "a/../../b", ""} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, ""); err == nil {
+			t.Errorf("Key %q should be rejected", key)
+		}
+	}
+}
+
+// fakeS3 内存实现的S3接口，只校验请求带有SigV4签名
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	types   map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=ak/") || !strings.Contains(auth, "host;x-amz-content-sha256;x-amz-date") {
+		http.Error(w, "unsigned request", http.StatusForbidden)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+		f.types[r.URL.Path] = r.Header.Get("Content-Type")
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", f.types[r.URL.Path])
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3StoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeS3{objects: map[string][]byte{}, types: map[string]string{}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	store, err := New(Config{Backend: "s3", S3: S3Config{
+		Endpoint:  server.URL,
+		Bucket:    "imy",
+		AccessKey: "ak",
+		SecretKey: "sk",
+		PathStyle: true,
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create s3 store: %v", err)
+	}
+
+	if err := store.Put(ctx, "conv/1/b.png", strings.NewReader("png"), 3, "image/png"); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if _, ok := fake.objects["/imy/conv/1/b.png"]; !ok {
+		t.Fatalf("Object should be stored under the bucket path: %v", fake.objects)
+	}
+
+	rc, info, err := store.Get(ctx, "conv/1/b.png")
+	if err != nil {
+		t.Fatalf("Failed to get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "png" || info.ContentType != "image/png" {
+		t.Fatalf("Unexpected object: %q %+v", data, info)
+	}
+
+	if err := store.Delete(ctx, "conv/1/b.png"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, _, err := store.Get(ctx, "conv/1/b.png"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,273 @@
+// Package errs 服务之间共享的结构化错误
+// 错误按语义归为少量Code，HTTP状态码、gRPC状态码和存储RPC错误码都映射到同一组Code，
+// 调用方用CodeOf或errors.Is(err, errs.ErrNotFound)判断错误类型，不再匹配错误字符串
+package errs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Code 错误类型，取值稳定，可以直接序列化
+type Code string
+
+const (
+	Unknown          Code = "unknown"
+	InvalidArgument  Code = "invalid_argument"
+	NotFound         Code = "not_found"
+	Conflict         Code = "conflict"
+	Unauthorized     Code = "unauthorized"
+	PermissionDenied Code = "permission_denied"
+	Overloaded       Code = "overloaded" // 限流、配额或容量不足，稍后可以重试
+	Unavailable      Code = "unavailable"
+	Timeout          Code = "timeout"
+	Canceled         Code = "canceled"
+	Unimplemented    Code = "unimplemented"
+	Internal         Code = "internal"
+)
+
+// 只带Code的哨兵错误，errors.Is对任何同Code的错误都返回true
+var (
+	ErrInvalidArgument  = &Error{Code: InvalidArgument}
+	ErrNotFound         = &Error{Code: NotFound}
+	ErrConflict         = &Error{Code: Conflict}
+	ErrUnauthorized     = &Error{Code: Unauthorized}
+	ErrPermissionDenied = &Error{Code: PermissionDenied}
+	ErrOverloaded       = &Error{Code: Overloaded}
+	ErrUnavailable      = &Error{Code: Unavailable}
+	ErrTimeout          = &Error{Code: Timeout}
+	ErrInternal         = &Error{Code: Internal}
+)
+
+// Coder 自带错误码的错误类型实现该接口即可被CodeOf识别
+type Coder interface {
+	ErrorCode() Code
+}
+
+// Error 带Code的错误，Err为原始错误
+type Error struct {
+	Code    Code
+	Message string
+	Err     error
+}
+
+// New 创建错误
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap 为err附加Code，err为nil时返回nil
+func Wrap(code Code, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Code: code, Err: err}
+}
+
+func (e *Error) Error() string {
+	switch {
+	case e.Message != "" && e.Err != nil:
+		return e.Message + ": " + e.Err.Error()
+	case e.Message != "":
+		return e.Message
+	case e.Err != nil:
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCode 实现Coder
+func (e *Error) ErrorCode() Code {
+	return e.Code
+}
+
+// Is 只带Code的哨兵错误按Code比较
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok || t.Message != "" || t.Err != nil {
+		return false
+	}
+	return t.Code == e.Code
+}
+
+// GRPCStatus 使gRPC服务端直接返回Error时带上对应的状态码
+func (e *Error) GRPCStatus() *status.Status {
+	return status.New(GRPCCode(e.Code), e.Error())
+}
+
+// CodeOf 返回错误的Code，err为nil时返回空字符串
+// 依次识别Coder、gRPC状态错误和context错误，其余错误为Unknown
+func CodeOf(err error) Code {
+	if err == nil {
+		return ""
+	}
+	var coder Coder
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	var grpcErr interface{ GRPCStatus() *status.Status }
+	if errors.As(err, &grpcErr) {
+		return FromGRPCCode(grpcErr.GRPCStatus().Code())
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	}
+	return Unknown
+}
+
+// Is err的Code是否为code
+func Is(err error, code Code) bool {
+	return err != nil && CodeOf(err) == code
+}
+
+// MatchSentinel 供实现Coder的错误类型在Is方法中调用，target为code对应的哨兵错误时返回true
+func MatchSentinel(code Code, target error) bool {
+	return (&Error{Code: code}).Is(target)
+}
+
+// Retryable 该类错误稍后重试可能成功
+func Retryable(code Code) bool {
+	switch code {
+	case Overloaded, Unavailable, Timeout:
+		return true
+	}
+	return false
+}
+
+// HTTPStatus Code对应的HTTP状态码
+func HTTPStatus(code Code) int {
+	switch code {
+	case "":
+		return http.StatusOK
+	case InvalidArgument:
+		return http.StatusBadRequest
+	case Unauthorized:
+		return http.StatusUnauthorized
+	case PermissionDenied:
+		return http.StatusForbidden
+	case NotFound:
+		return http.StatusNotFound
+	case Conflict:
+		return http.StatusConflict
+	case Overloaded:
+		return http.StatusTooManyRequests
+	case Canceled:
+		return 499 // 客户端已断开，沿用nginx的约定
+	case Unimplemented:
+		return http.StatusNotImplemented
+	case Unavailable:
+		return http.StatusServiceUnavailable
+	case Timeout:
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// FromHTTPStatus HTTP状态码对应的Code，2xx返回空字符串
+func FromHTTPStatus(statusCode int) Code {
+	switch statusCode {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusRequestEntityTooLarge,
+		http.StatusUnsupportedMediaType, http.StatusMethodNotAllowed:
+		return InvalidArgument
+	case http.StatusUnauthorized:
+		return Unauthorized
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound, http.StatusGone:
+		return NotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return Conflict
+	case http.StatusTooManyRequests, http.StatusInsufficientStorage:
+		return Overloaded
+	case 499:
+		return Canceled
+	case http.StatusNotImplemented:
+		return Unimplemented
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return Timeout
+	}
+	switch {
+	case statusCode >= 200 && statusCode < 300:
+		return ""
+	case statusCode >= 400 && statusCode < 500:
+		return InvalidArgument
+	case statusCode >= 500:
+		return Internal
+	}
+	return Unknown
+}
+
+// GRPCCode Code对应的gRPC状态码
+func GRPCCode(code Code) codes.Code {
+	switch code {
+	case "":
+		return codes.OK
+	case InvalidArgument:
+		return codes.InvalidArgument
+	case NotFound:
+		return codes.NotFound
+	case Conflict:
+		return codes.FailedPrecondition
+	case Unauthorized:
+		return codes.Unauthenticated
+	case PermissionDenied:
+		return codes.PermissionDenied
+	case Overloaded:
+		return codes.ResourceExhausted
+	case Unavailable:
+		return codes.Unavailable
+	case Timeout:
+		return codes.DeadlineExceeded
+	case Canceled:
+		return codes.Canceled
+	case Unimplemented:
+		return codes.Unimplemented
+	case Internal:
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// FromGRPCCode gRPC状态码对应的Code，OK返回空字符串
+func FromGRPCCode(code codes.Code) Code {
+	switch code {
+	case codes.OK:
+		return ""
+	case codes.InvalidArgument, codes.OutOfRange:
+		return InvalidArgument
+	case codes.NotFound:
+		return NotFound
+	case codes.AlreadyExists, codes.Aborted, codes.FailedPrecondition:
+		return Conflict
+	case codes.Unauthenticated:
+		return Unauthorized
+	case codes.PermissionDenied:
+		return PermissionDenied
+	case codes.ResourceExhausted:
+		return Overloaded
+	case codes.Unavailable:
+		return Unavailable
+	case codes.DeadlineExceeded:
+		return Timeout
+	case codes.Canceled:
+		return Canceled
+	case codes.Unimplemented:
+		return Unimplemented
+	case codes.Internal, codes.DataLoss:
+		return Internal
+	}
+	return Unknown
+}
@@ -0,0 +1,78 @@
+package errs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCodeOf(t *testing.T) {
+	cases := []struct {
+		err  error
+		code Code
+	}{
+		{nil, ""},
+		{errors.New("boom"), Unknown},
+		{New(NotFound, "user not found"), NotFound},
+		{fmt.Errorf("load: %w", Wrap(Conflict, errors.New("version mismatch"))), Conflict},
+		{status.Error(codes.ResourceExhausted, "slow down"), Overloaded},
+		{fmt.Errorf("call: %w", context.DeadlineExceeded), Timeout},
+		{context.Canceled, Canceled},
+	}
+	for _, tc := range cases {
+		if got := CodeOf(tc.err); got != tc.code {
+			t.Errorf("CodeOf(%v) = %q, expected %q", tc.err, got, tc.code)
+		}
+	}
+}
+
+func TestSentinels(t *testing.T) {
+	err := fmt.Errorf("get user: %w", New(NotFound, "user not found"))
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v to match ErrNotFound", err)
+	}
+	if errors.Is(err, ErrConflict) {
+		t.Errorf("Expected %v not to match ErrConflict", err)
+	}
+	// 带信息的错误只与自身相等
+	unauthorized := New(Unauthorized, "token revoked")
+	if errors.Is(New(Unauthorized, "other"), unauthorized) {
+		t.Error("Expected errors with messages to compare by identity")
+	}
+	if !errors.Is(unauthorized, ErrUnauthorized) {
+		t.Error("Expected an unauthorized error to match ErrUnauthorized")
+	}
+
+	wrapped := Wrap(Unavailable, context.DeadlineExceeded)
+	if !errors.Is(wrapped, context.DeadlineExceeded) {
+		t.Error("Expected Wrap to keep the original error")
+	}
+	if Wrap(Internal, nil) != nil {
+		t.Error("Expected Wrap(nil) to be nil")
+	}
+}
+
+func TestStatusMappingsRoundTrip(t *testing.T) {
+	for _, code := range []Code{InvalidArgument, NotFound, Conflict, Unauthorized, PermissionDenied,
+		Overloaded, Unavailable, Timeout, Canceled, Unimplemented, Internal} {
+		if got := FromHTTPStatus(HTTPStatus(code)); got != code {
+			t.Errorf("HTTP round trip of %q gave %q", code, got)
+		}
+		if got := FromGRPCCode(GRPCCode(code)); got != code {
+			t.Errorf("gRPC round trip of %q gave %q", code, got)
+		}
+	}
+	if FromHTTPStatus(http.StatusNoContent) != "" || FromHTTPStatus(http.StatusTeapot) != InvalidArgument || FromHTTPStatus(599) != Internal {
+		t.Error("Unexpected mapping for unlisted HTTP statuses")
+	}
+
+	st, ok := status.FromError(New(NotFound, "missing"))
+	if !ok || st.Code() != codes.NotFound || st.Message() != "missing" {
+		t.Errorf("Expected a NotFound grpc status, got %v", st)
+	}
+}
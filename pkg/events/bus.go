@@ -0,0 +1,389 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrQueueFull 投递目标的队列已满，事件被丢弃
+var ErrQueueFull = errors.New("events: queue full")
+
+// ErrClosed 事件总线已关闭
+var ErrClosed = errors.New("events: bus closed")
+
+// deadDir 落盘目录中超过重试次数的事件所在的子目录
+const deadDir = "dead"
+
+// Sink 投递目标
+type Sink struct {
+	Name      string
+	Types     []Type // 为空时投递全部类型
+	Publisher Publisher
+}
+
+// Options 事件总线参数
+type Options struct {
+	Source      string
+	Dir         string // 为空时不落盘
+	QueueSize   int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	MaxAttempts int // 0表示一直重试
+}
+
+// SinkStats 单个投递目标的统计
+type SinkStats struct {
+	Name         string `json:"name"`
+	Pending      int    `json:"pending"`       // 等待投递（含正在投递）的事件数
+	Published    int64  `json:"published"`     // 进入队列的事件数
+	Delivered    int64  `json:"delivered"`     // 投递成功的事件数
+	Failures     int64  `json:"failures"`      // 失败的投递次数
+	DeadLettered int64  `json:"dead_lettered"` // 超过重试次数被放弃的事件数
+	Dropped      int64  `json:"dropped"`       // 队列已满被丢弃的事件数
+	LastError    string `json:"last_error,omitempty"`
+}
+
+// Bus 事件总线，每个投递目标有独立的有序队列与投递协程，并发安全，nil表示不发布
+type Bus struct {
+	opts   Options
+	queues []*sinkQueue
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	closeOnce sync.Once
+}
+
+// sinkQueue 一个投递目标的待投递事件，队首为正在投递的事件
+type sinkQueue struct {
+	sink Sink
+	dir  string // 落盘目录，为空时不落盘
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []*pendingEvent
+	nextSeq uint64
+	closed  bool
+	stats   SinkStats
+}
+
+type pendingEvent struct {
+	seq     uint64
+	event   *Event
+	payload []byte
+}
+
+// NewBus 创建事件总线并启动投递，配置了落盘目录时先装载上次未投递完的事件
+func NewBus(opts Options, sinks ...Sink) (*Bus, error) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 10000
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = time.Second
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = opts.MinBackoff
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Bus{opts: opts, ctx: ctx, cancel: cancel}
+
+	names := make(map[string]bool, len(sinks))
+	for _, sink := range sinks {
+		if sink.Name == "" || names[sink.Name] {
+			cancel()
+			return nil, fmt.Errorf("events: sink name %q is empty or duplicated", sink.Name)
+		}
+		names[sink.Name] = true
+		q := &sinkQueue{sink: sink, stats: SinkStats{Name: sink.Name}}
+		q.cond = sync.NewCond(&q.mu)
+		if opts.Dir != "" {
+			q.dir = filepath.Join(opts.Dir, sink.Name)
+			if err := q.load(); err != nil {
+				cancel()
+				return nil, err
+			}
+		}
+		b.queues = append(b.queues, q)
+	}
+	for _, q := range b.queues {
+		b.wg.Add(1)
+		go b.deliver(q)
+	}
+	return b, nil
+}
+
+// Publish 把事件放入所有匹配的投递目标的队列，不等待投递
+// 某个目标的队列已满时该目标丢弃事件并返回ErrQueueFull，其他目标不受影响
+func (b *Bus) Publish(event *Event) error {
+	if b == nil {
+		return nil
+	}
+	if event.ID == "" {
+		event.ID = newID()
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	if event.Source == "" {
+		event.Source = b.opts.Source
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("events: encode event: %w", err)
+	}
+
+	var firstErr error
+	for _, q := range b.queues {
+		if !q.accepts(event.Type) {
+			continue
+		}
+		if err := q.push(event, payload, b.opts.QueueSize); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Flush 等待当前所有排队的事件投递完成或被放弃
+func (b *Bus) Flush(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		pending := 0
+		for _, q := range b.queues {
+			q.mu.Lock()
+			pending += len(q.items)
+			q.mu.Unlock()
+		}
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close 停止投递并关闭后端，落盘的未投递事件在下次启动时继续投递
+func (b *Bus) Close() error {
+	if b == nil {
+		return nil
+	}
+	var firstErr error
+	b.closeOnce.Do(func() {
+		b.cancel()
+		for _, q := range b.queues {
+			q.mu.Lock()
+			q.closed = true
+			q.cond.Broadcast()
+			q.mu.Unlock()
+		}
+		b.wg.Wait()
+		for _, q := range b.queues {
+			if err := q.sink.Publisher.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	})
+	return firstErr
+}
+
+// Stats 获取各投递目标的统计
+func (b *Bus) Stats() []SinkStats {
+	if b == nil {
+		return nil
+	}
+	stats := make([]SinkStats, 0, len(b.queues))
+	for _, q := range b.queues {
+		q.mu.Lock()
+		s := q.stats
+		s.Pending = len(q.items)
+		q.mu.Unlock()
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// deliver 按顺序投递队首事件，失败时退避重试
+func (b *Bus) deliver(q *sinkQueue) {
+	defer b.wg.Done()
+	for {
+		q.mu.Lock()
+		for len(q.items) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if q.closed {
+			q.mu.Unlock()
+			return
+		}
+		item := q.items[0]
+		q.mu.Unlock()
+
+		for attempt := 1; ; attempt++ {
+			err := q.sink.Publisher.Publish(b.ctx, item.event, item.payload)
+			if err == nil {
+				q.finish(item, true)
+				break
+			}
+			if b.ctx.Err() != nil {
+				return
+			}
+			q.mu.Lock()
+			q.stats.Failures++
+			q.stats.LastError = err.Error()
+			q.mu.Unlock()
+			if b.opts.MaxAttempts > 0 && attempt >= b.opts.MaxAttempts {
+				log.Printf("events: giving up %s event %s for sink %s after %d attempts: %v", item.event.Type, item.event.ID, q.sink.Name, attempt, err)
+				q.finish(item, false)
+				break
+			}
+			select {
+			case <-b.ctx.Done():
+				return
+			case <-time.After(b.backoff(attempt)):
+			}
+		}
+	}
+}
+
+// backoff 第attempt次失败后的等待时间，指数增长
+func (b *Bus) backoff(attempt int) time.Duration {
+	delay := b.opts.MinBackoff
+	for i := 1; i < attempt && delay < b.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > b.opts.MaxBackoff {
+		delay = b.opts.MaxBackoff
+	}
+	return delay
+}
+
+func (q *sinkQueue) accepts(eventType Type) bool {
+	if len(q.sink.Types) == 0 {
+		return true
+	}
+	for _, t := range q.sink.Types {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// push 事件入队，落盘成功后才进入内存队列
+func (q *sinkQueue) push(event *Event, payload []byte, limit int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return ErrClosed
+	}
+	if len(q.items) >= limit {
+		q.stats.Dropped++
+		return fmt.Errorf("%w: sink %s", ErrQueueFull, q.sink.Name)
+	}
+	item := &pendingEvent{seq: q.nextSeq, event: event, payload: payload}
+	if q.dir != "" {
+		if err := writeFileAtomic(q.file(item.seq), payload); err != nil {
+			return fmt.Errorf("events: spool event for sink %s: %w", q.sink.Name, err)
+		}
+	}
+	q.nextSeq++
+	q.items = append(q.items, item)
+	q.stats.Published++
+	q.cond.Broadcast()
+	return nil
+}
+
+// finish 移除队首事件，投递失败的事件移入死信目录
+func (q *sinkQueue) finish(item *pendingEvent, delivered bool) {
+	if q.dir != "" {
+		var err error
+		if delivered {
+			err = os.Remove(q.file(item.seq))
+		} else if err = os.MkdirAll(filepath.Join(q.dir, deadDir), 0o755); err == nil {
+			err = os.Rename(q.file(item.seq), filepath.Join(q.dir, deadDir, filepath.Base(q.file(item.seq))))
+		}
+		if err != nil && !os.IsNotExist(err) {
+			log.Printf("events: failed to clear spooled event %s of sink %s: %v", item.event.ID, q.sink.Name, err)
+		}
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = q.items[1:]
+	if delivered {
+		q.stats.Delivered++
+	} else {
+		q.stats.DeadLettered++
+	}
+	q.cond.Broadcast()
+}
+
+func (q *sinkQueue) file(seq uint64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d.json", seq))
+}
+
+// load 装载落盘目录中未投递的事件，按写入顺序排队
+func (q *sinkQueue) load() error {
+	if err := os.MkdirAll(q.dir, 0o755); err != nil {
+		return fmt.Errorf("events: create spool dir: %w", err)
+	}
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("events: read spool dir: %w", err)
+	}
+	var seqs []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+
+	for _, seq := range seqs {
+		payload, err := os.ReadFile(q.file(seq))
+		if err != nil {
+			return fmt.Errorf("events: read spooled event: %w", err)
+		}
+		event := &Event{}
+		if err := json.Unmarshal(payload, event); err != nil {
+			log.Printf("events: dropping unreadable spooled event %s: %v", q.file(seq), err)
+			os.Remove(q.file(seq))
+			continue
+		}
+		q.items = append(q.items, &pendingEvent{seq: seq, event: event, payload: payload})
+		q.nextSeq = seq + 1
+	}
+	q.stats.Published = int64(len(q.items))
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再改名，避免进程崩溃时留下不完整的事件
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
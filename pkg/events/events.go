@@ -0,0 +1,157 @@
+// Package events 向外部系统（机器人、统计分析等）发布消息与成员变更事件
+// 事件先写入每个投递目标的本地队列（配置了目录时同时落盘），后台按顺序投递，失败时退避重试，
+// 因此是至少一次投递：消费方应按事件ID去重。支持Webhook、NATS和Kafka（经REST Proxy）三种后端
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Type 事件类型
+type Type string
+
+const (
+	TypeMessageCreated      Type = "message.created"
+	TypeMemberAdded         Type = "member.added"
+	TypeMemberRemoved       Type = "member.removed"
+	TypeConversationCreated Type = "conversation.created"
+)
+
+// Event 发布的事件
+type Event struct {
+	ID             string          `json:"id"` // 消费方据此去重
+	Type           Type            `json:"type"`
+	Time           time.Time       `json:"time"`
+	Source         string          `json:"source,omitempty"` // 产生事件的服务，如Store ID
+	ConversationID string          `json:"conversationId,omitempty"`
+	Data           json.RawMessage `json:"data,omitempty"`
+}
+
+// MessageData message.created事件的数据
+type MessageData struct {
+	MessageID string   `json:"messageId"`
+	SenderID  string   `json:"senderId"`
+	MsgType   uint32   `json:"msgType,omitempty"`
+	Content   string   `json:"content"`
+	Mentions  []string `json:"mentions,omitempty"`
+}
+
+// MemberData member.added与member.removed事件的数据
+type MemberData struct {
+	Members  []string `json:"members"`
+	Operator string   `json:"operator,omitempty"`
+}
+
+// ConversationData conversation.created事件的数据
+type ConversationData struct {
+	Type    uint32   `json:"type"` // 1单聊，2群聊
+	Name    string   `json:"name,omitempty"`
+	Creator string   `json:"creator"`
+	Members []string `json:"members"`
+}
+
+// NewEvent 创建事件，id为空时随机生成
+func NewEvent(id string, eventType Type, conversationID string, data interface{}) (*Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("events: encode %s data: %w", eventType, err)
+	}
+	if id == "" {
+		id = newID()
+	}
+	return &Event{ID: id, Type: eventType, Time: time.Now().UTC(), ConversationID: conversationID, Data: raw}, nil
+}
+
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Publisher 事件投递后端
+type Publisher interface {
+	// Publish 投递一个事件，payload为事件的JSON编码；返回nil表示对方已确认收到
+	Publish(ctx context.Context, event *Event, payload []byte) error
+	// Close 释放连接等资源
+	Close() error
+}
+
+// SinkConfig 一个投递目标
+type SinkConfig struct {
+	Name    string        `json:",optional"` // 为空时使用Backend，同一Bus内不能重复
+	Backend string        `json:",options=webhook|nats|kafka"`
+	Types   []Type        `json:",optional"` // 只投递这些类型，为空时投递全部
+	Webhook WebhookConfig `json:",optional"`
+	NATS    NATSConfig    `json:",optional"`
+	Kafka   KafkaConfig   `json:",optional"`
+}
+
+// Config 事件发布配置，Enabled为false时New返回nil，即不发布
+type Config struct {
+	Enabled bool   `json:",optional"`
+	Source  string `json:",optional"` // 填入事件的Source，为空时由调用方决定
+	// Dir 待投递事件的落盘目录，为空时只保存在内存中，进程退出时未投递的事件丢失
+	Dir         string        `json:",optional"`
+	QueueSize   int           `json:",default=10000"` // 每个目标内存中最多排队的事件数
+	MinBackoff  time.Duration `json:",default=1s"`
+	MaxBackoff  time.Duration `json:",default=1m"`
+	MaxAttempts int           `json:",optional"` // 超过后移入死信目录，0表示一直重试
+	Sinks       []SinkConfig  `json:",optional"`
+}
+
+// New 按配置创建事件总线
+func New(c Config) (*Bus, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if len(c.Sinks) == 0 {
+		return nil, fmt.Errorf("events: no sinks configured")
+	}
+	sinks := make([]Sink, 0, len(c.Sinks))
+	for _, sc := range c.Sinks {
+		publisher, err := newPublisher(sc)
+		if err != nil {
+			for _, sink := range sinks {
+				sink.Publisher.Close()
+			}
+			return nil, err
+		}
+		name := sc.Name
+		if name == "" {
+			name = sc.Backend
+		}
+		sinks = append(sinks, Sink{Name: name, Types: sc.Types, Publisher: publisher})
+	}
+	bus, err := NewBus(Options{
+		Source:      c.Source,
+		Dir:         c.Dir,
+		QueueSize:   c.QueueSize,
+		MinBackoff:  c.MinBackoff,
+		MaxBackoff:  c.MaxBackoff,
+		MaxAttempts: c.MaxAttempts,
+	}, sinks...)
+	if err != nil {
+		for _, sink := range sinks {
+			sink.Publisher.Close()
+		}
+		return nil, err
+	}
+	return bus, nil
+}
+
+func newPublisher(sc SinkConfig) (Publisher, error) {
+	switch sc.Backend {
+	case "webhook":
+		return NewWebhookPublisher(sc.Webhook)
+	case "nats":
+		return NewNATSPublisher(sc.NATS)
+	case "kafka":
+		return NewKafkaPublisher(sc.Kafka)
+	}
+	return nil, fmt.Errorf("events: unknown backend %q", sc.Backend)
+}
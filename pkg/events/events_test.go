@@ -0,0 +1,287 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingPublisher 记录投递的事件，前failures次投递失败
+type recordingPublisher struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	ids      []string
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event *Event, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.attempts++
+	if p.failures > 0 {
+		p.failures--
+		return io.ErrUnexpectedEOF
+	}
+	p.ids = append(p.ids, event.ID)
+	return nil
+}
+
+func (p *recordingPublisher) Close() error { return nil }
+
+func (p *recordingPublisher) delivered() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.ids...)
+}
+
+func flush(t *testing.T, bus *Bus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bus.Flush(ctx); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+}
+
+func TestBusRetriesInOrder(t *testing.T) {
+	messages := &recordingPublisher{failures: 2}
+	members := &recordingPublisher{}
+	bus, err := NewBus(Options{Source: "test", MinBackoff: time.Millisecond},
+		Sink{Name: "messages", Types: []Type{TypeMessageCreated}, Publisher: messages},
+		Sink{Name: "all", Publisher: members},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	defer bus.Close()
+
+	for i := 0; i < 3; i++ {
+		event, _ := NewEvent("m"+strconv.Itoa(i), TypeMessageCreated, "c1", MessageData{MessageID: strconv.Itoa(i)})
+		if err := bus.Publish(event); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+	}
+	event, _ := NewEvent("", TypeMemberAdded, "c1", MemberData{Members: []string{"u2"}})
+	bus.Publish(event)
+	flush(t, bus)
+
+	if ids := messages.delivered(); strings.Join(ids, ",") != "m0,m1,m2" || messages.attempts != 5 {
+		t.Errorf("Expected ordered delivery after retries, got %v in %d attempts", ids, messages.attempts)
+	}
+	if ids := members.delivered(); len(ids) != 4 || ids[3] != event.ID || event.Source != "test" {
+		t.Errorf("Unexpected deliveries %v of %+v", ids, event)
+	}
+	stats := bus.Stats()
+	if stats[0].Delivered != 3 || stats[0].Failures != 2 || stats[0].Pending != 0 || stats[1].Published != 4 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestBusSpoolSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	down := &recordingPublisher{failures: 1 << 30}
+	bus, err := NewBus(Options{Dir: dir, MinBackoff: time.Hour}, Sink{Name: "hook", Publisher: down})
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	for _, id := range []string{"a", "b"} {
+		event, _ := NewEvent(id, TypeMessageCreated, "c1", MessageData{})
+		bus.Publish(event)
+	}
+	bus.Close()
+
+	up := &recordingPublisher{}
+	bus, err = NewBus(Options{Dir: dir}, Sink{Name: "hook", Publisher: up})
+	if err != nil {
+		t.Fatalf("Failed to reopen bus: %v", err)
+	}
+	defer bus.Close()
+	flush(t, bus)
+	if ids := up.delivered(); strings.Join(ids, ",") != "a,b" {
+		t.Errorf("Expected spooled events to be delivered after restart, got %v", ids)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "hook")); len(entries) != 0 {
+		t.Errorf("Expected an empty spool, got %d files", len(entries))
+	}
+}
+
+func TestBusDeadLetter(t *testing.T) {
+	dir := t.TempDir()
+	bus, err := NewBus(Options{Dir: dir, MinBackoff: time.Millisecond, MaxAttempts: 2},
+		Sink{Name: "hook", Publisher: &recordingPublisher{failures: 1 << 30}})
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	defer bus.Close()
+	event, _ := NewEvent("a", TypeMessageCreated, "c1", MessageData{})
+	bus.Publish(event)
+	flush(t, bus)
+
+	if entries, _ := os.ReadDir(filepath.Join(dir, "hook", deadDir)); len(entries) != 1 {
+		t.Errorf("Expected the event in the dead letter directory, got %d files", len(entries))
+	}
+	if stats := bus.Stats(); stats[0].DeadLettered != 1 || stats[0].Failures != 2 || stats[0].LastError == "" {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	var nilBus *Bus
+	if err := nilBus.Publish(event); err != nil || nilBus.Close() != nil {
+		t.Errorf("Expected a nil bus to be a no-op")
+	}
+}
+
+func TestWebhookPublisher(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		calls++
+		first := calls == 1
+		mu.Unlock()
+		if r.Header.Get(HeaderSignature) != Sign("secret", body) || r.Header.Get(HeaderEventType) != string(TypeMemberAdded) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if first {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	bus, err := New(Config{
+		Enabled:    true,
+		MinBackoff: time.Millisecond,
+		Sinks:      []SinkConfig{{Backend: "webhook", Webhook: WebhookConfig{URL: server.URL, Secret: "secret"}}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	defer bus.Close()
+	event, _ := NewEvent("", TypeMemberAdded, "c1", MemberData{Members: []string{"u1"}})
+	bus.Publish(event)
+	flush(t, bus)
+	if stats := bus.Stats(); stats[0].Name != "webhook" || stats[0].Delivered != 1 || stats[0].Failures != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestKafkaPublisher(t *testing.T) {
+	var got struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value Event  `json:"value"`
+		} `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/topics/imy-events" || r.Header.Get("Content-Type") != kafkaContentType {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+		if got.Records[0].Value.ID == "fail" {
+			w.Write([]byte(`{"offsets":[{"partition":0,"offset":null,"error_code":50003,"error":"timeout"}]}`))
+			return
+		}
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":7,"error_code":null,"error":null}]}`))
+	}))
+	defer server.Close()
+
+	publisher, err := NewKafkaPublisher(KafkaConfig{ProxyURL: server.URL + "/", Topic: "imy-events"})
+	if err != nil {
+		t.Fatalf("Failed to create publisher: %v", err)
+	}
+	for _, id := range []string{"ok", "fail"} {
+		event, _ := NewEvent(id, TypeMessageCreated, "c1", MessageData{})
+		payload, _ := json.Marshal(event)
+		err := publisher.Publish(context.Background(), event, payload)
+		if (id == "ok") != (err == nil) {
+			t.Errorf("Unexpected result for %s: %v", id, err)
+		}
+	}
+	if got.Records[0].Key != "c1" || got.Records[0].Value.Type != TypeMessageCreated {
+		t.Errorf("Unexpected record %+v", got.Records[0])
+	}
+}
+
+func TestNATSPublisher(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	published := make(chan string, 4)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					fields := strings.Fields(line)
+					switch {
+					case len(fields) == 0:
+					case fields[0] == "CONNECT":
+						if !strings.Contains(line, `"auth_token":"token"`) {
+							conn.Write([]byte("-ERR 'Authorization Violation'\r\n"))
+							return
+						}
+					case fields[0] == "PING":
+						conn.Write([]byte("PONG\r\n"))
+					case fields[0] == "PUB":
+						size, _ := strconv.Atoi(fields[2])
+						payload := make([]byte, size+2)
+						io.ReadFull(reader, payload)
+						published <- fields[1] + " " + string(payload[:size])
+						// 服务端也会主动PING，发布方需要回复PONG
+						conn.Write([]byte("PING\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	url := "nats://" + listener.Addr().String()
+	if _, err := NewNATSPublisher(NATSConfig{URL: "http://" + listener.Addr().String()}); err == nil {
+		t.Errorf("Expected a non-nats url to be rejected")
+	}
+	denied, _ := NewNATSPublisher(NATSConfig{URL: url, Timeout: time.Second})
+	event, _ := NewEvent("e1", TypeConversationCreated, "c1", ConversationData{Creator: "u1"})
+	payload, _ := json.Marshal(event)
+	if err := denied.Publish(context.Background(), event, payload); err == nil || !strings.Contains(err.Error(), "Authorization") {
+		t.Errorf("Expected an authorization error, got %v", err)
+	}
+
+	publisher, _ := NewNATSPublisher(NATSConfig{URL: url, Token: "token", Timeout: time.Second})
+	defer publisher.Close()
+	for i := 0; i < 2; i++ {
+		if err := publisher.Publish(context.Background(), event, payload); err != nil {
+			t.Fatalf("Failed to publish: %v", err)
+		}
+		got := <-published
+		if got != "imy.events.conversation.created "+string(payload) {
+			t.Errorf("Unexpected publish %q", got)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// kafkaContentType Kafka REST Proxy v2以JSON格式写入消息的请求类型
+const kafkaContentType = "application/vnd.kafka.json.v2+json"
+
+// KafkaConfig Kafka后端配置
+// 通过Kafka REST Proxy（v2接口）写入，消息键为会话ID，同一会话的事件落在同一分区中保持有序
+type KafkaConfig struct {
+	ProxyURL string // REST Proxy地址，如http://127.0.0.1:8082
+	Topic    string
+	Username string        `json:",optional"` // 配置时使用Basic认证
+	Password string        `json:",optional,env=EVENTS_KAFKA_PASSWORD"`
+	Timeout  time.Duration `json:",default=10s"`
+}
+
+// KafkaPublisher 经Kafka REST Proxy写入事件
+type KafkaPublisher struct {
+	config   KafkaConfig
+	endpoint string
+	client   *http.Client
+}
+
+// NewKafkaPublisher 创建Kafka后端
+func NewKafkaPublisher(c KafkaConfig) (*KafkaPublisher, error) {
+	u, err := url.Parse(c.ProxyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("events: invalid kafka proxy url %q", c.ProxyURL)
+	}
+	if c.Topic == "" {
+		return nil, fmt.Errorf("events: kafka topic is required")
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return &KafkaPublisher{
+		config:   c,
+		endpoint: strings.TrimSuffix(c.ProxyURL, "/") + "/topics/" + url.PathEscape(c.Topic),
+		client:   &http.Client{Timeout: c.Timeout},
+	}, nil
+}
+
+type kafkaRecord struct {
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value"`
+}
+
+type kafkaProduceResponse struct {
+	Offsets []struct {
+		Partition int    `json:"partition"`
+		Offset    int64  `json:"offset"`
+		ErrorCode *int   `json:"error_code"`
+		Error     string `json:"error"`
+	} `json:"offsets"`
+}
+
+func (p *KafkaPublisher) Publish(ctx context.Context, event *Event, payload []byte) error {
+	body, err := json.Marshal(struct {
+		Records []kafkaRecord `json:"records"`
+	}{Records: []kafkaRecord{{Key: event.ConversationID, Value: payload}}})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", "application/vnd.kafka.v2+json")
+	if p.config.Username != "" {
+		req.SetBasicAuth(p.config.Username, p.config.Password)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("events: kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("events: kafka: unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	// REST Proxy对每条记录分别返回结果，写入失败的记录带有错误信息
+	var result kafkaProduceResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("events: kafka: decode response: %w", err)
+	}
+	for _, offset := range result.Offsets {
+		if offset.ErrorCode != nil || offset.Error != "" {
+			return fmt.Errorf("events: kafka: produce to partition %d failed: %s", offset.Partition, offset.Error)
+		}
+	}
+	return nil
+}
+
+func (p *KafkaPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NATSConfig NATS后端配置，事件发布到主题<Subject>.<事件类型>
+type NATSConfig struct {
+	URL      string        // 如nats://127.0.0.1:4222
+	Subject  string        `json:",default=imy.events"`
+	User     string        `json:",optional"`
+	Password string        `json:",optional,env=EVENTS_NATS_PASSWORD"`
+	Token    string        `json:",optional,env=EVENTS_NATS_TOKEN"`
+	Timeout  time.Duration `json:",default=5s"`
+}
+
+// NATSPublisher 使用NATS文本协议发布事件
+// 每次发布后发送PING并等待PONG，收到PONG说明服务端已处理之前的PUB
+type NATSPublisher struct {
+	config NATSConfig
+	addr   string
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewNATSPublisher 创建NATS后端，连接在首次发布时建立，断开后自动重连
+func NewNATSPublisher(c NATSConfig) (*NATSPublisher, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || u.Scheme != "nats" || u.Host == "" {
+		return nil, fmt.Errorf("events: invalid nats url %q", c.URL)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "4222")
+	}
+	if u.User != nil && c.User == "" {
+		c.User = u.User.Username()
+		c.Password, _ = u.User.Password()
+	}
+	if c.Subject == "" {
+		c.Subject = "imy.events"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 5 * time.Second
+	}
+	return &NATSPublisher{config: c, addr: addr}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, event *Event, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.connect(ctx); err != nil {
+		return err
+	}
+	subject := p.config.Subject + "." + string(event.Type)
+	err := p.roundTrip(ctx, fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload))
+	if err != nil {
+		p.reset()
+		return fmt.Errorf("events: nats: %w", err)
+	}
+	return nil
+}
+
+func (p *NATSPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
+
+// connect 建立连接并完成握手，调用方持有mu
+func (p *NATSPublisher) connect(ctx context.Context) error {
+	if p.conn != nil {
+		return nil
+	}
+	dialer := net.Dialer{Timeout: p.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.addr)
+	if err != nil {
+		return fmt.Errorf("events: nats: %w", err)
+	}
+	p.conn, p.reader = conn, bufio.NewReader(conn)
+
+	// 服务端先发送INFO
+	conn.SetReadDeadline(time.Now().Add(p.config.Timeout))
+	line, err := p.reader.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "INFO") {
+		p.reset()
+		return fmt.Errorf("events: nats: unexpected greeting %q: %v", strings.TrimSpace(line), err)
+	}
+	options, _ := json.Marshal(struct {
+		Verbose  bool   `json:"verbose"`
+		Pedantic bool   `json:"pedantic"`
+		Name     string `json:"name"`
+		Lang     string `json:"lang"`
+		Version  string `json:"version"`
+		User     string `json:"user,omitempty"`
+		Pass     string `json:"pass,omitempty"`
+		Token    string `json:"auth_token,omitempty"`
+	}{Name: "imy-events", Lang: "go", Version: "1.0.0", User: p.config.User, Pass: p.config.Password, Token: p.config.Token})
+	// 认证失败时服务端在PONG之前返回-ERR
+	if err := p.roundTrip(ctx, "CONNECT "+string(options)+"\r\nPING\r\n"); err != nil {
+		p.reset()
+		return fmt.Errorf("events: nats: connect: %w", err)
+	}
+	return nil
+}
+
+// roundTrip 发送命令并读取到PONG为止，期间响应服务端的PING
+func (p *NATSPublisher) roundTrip(ctx context.Context, command string) error {
+	deadline := time.Now().Add(p.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	p.conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { p.conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := p.conn.Write([]byte(command)); err != nil {
+		return err
+	}
+	for {
+		line, err := p.reader.ReadString('\n')
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.New(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK与INFO无需处理
+	}
+}
+
+// reset 关闭连接，下次发布时重连，调用方持有mu
+func (p *NATSPublisher) reset() {
+	if p.conn != nil {
+		p.conn.Close()
+		p.conn, p.reader = nil, nil
+	}
+}
@@ -0,0 +1,88 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Webhook请求头
+const (
+	HeaderEventType = "X-Imy-Event"
+	HeaderEventID   = "X-Imy-Event-Id"
+	// HeaderSignature 请求体的HMAC-SHA256签名，格式为sha256=<hex>
+	HeaderSignature = "X-Imy-Signature"
+)
+
+// WebhookConfig Webhook后端配置
+type WebhookConfig struct {
+	URL     string
+	Secret  string            `json:",optional,env=EVENTS_WEBHOOK_SECRET"` // 为空时不签名
+	Timeout time.Duration     `json:",default=10s"`
+	Headers map[string]string `json:",optional"`
+}
+
+// WebhookPublisher 把事件以JSON POST到指定地址，2xx响应表示收到
+type WebhookPublisher struct {
+	config WebhookConfig
+	client *http.Client
+}
+
+// NewWebhookPublisher 创建Webhook后端
+func NewWebhookPublisher(c WebhookConfig) (*WebhookPublisher, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("events: invalid webhook url %q", c.URL)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	return &WebhookPublisher{config: c, client: &http.Client{Timeout: c.Timeout}}, nil
+}
+
+// Sign 计算Webhook签名，接收方用同一Secret校验HeaderSignature
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event *Event, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.config.URL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	for key, value := range p.config.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEventType, string(event.Type))
+	req.Header.Set(HeaderEventID, event.ID)
+	if p.config.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(p.config.Secret, payload))
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("events: webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("events: webhook: unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+func (p *WebhookPublisher) Close() error {
+	p.client.CloseIdleConnections()
+	return nil
+}
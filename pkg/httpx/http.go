@@ -61,6 +61,11 @@ func (c *CustomResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap 返回原始响应写入器，使 http.ResponseController 可以对流式响应执行 Flush
+func (c *CustomResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
 type FileType int
 
 const (
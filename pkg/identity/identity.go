@@ -0,0 +1,226 @@
+package identity
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 网关注入的身份请求头签名
+// 网关在转发前去掉客户端自带的身份请求头，注入uuid等请求头后用共享密钥对其签名，
+// 上游服务校验签名，未经网关签名的身份请求头一律拒绝，防止绕过网关或伪造请求头。
+//
+// 签名请求头格式：X-Identity-Signature: t=<unix秒>,n=<随机nonce>,h=<小写请求头名，分号分隔>,s=<十六进制HMAC-SHA256>
+// 签名内容依次为版本v2、时间戳、nonce、请求方法、上游收到的请求路径与原始查询串，以及按名称排序的 name:value，以换行分隔。
+// Middleware记住时间窗口内见过的nonce，同一签名第二次出现时拒绝。
+
+// SignatureHeader 签名所在的请求头
+const SignatureHeader = "X-Identity-Signature"
+
+// 签名校验错误
+var (
+	ErrMissingSignature = errors.New("identity: signature required")
+	ErrMalformed        = errors.New("identity: malformed signature")
+	ErrExpired          = errors.New("identity: signature timestamp out of range")
+	ErrMismatch         = errors.New("identity: signature mismatch")
+	ErrUnsigned         = errors.New("identity: header not covered by signature")
+	ErrReplayed         = errors.New("identity: signature replayed")
+)
+
+// Sign 对header中names列出的请求头签名，返回SignatureHeader的值
+// path与rawQuery为上游收到的请求路径与查询串；names中不存在的请求头被忽略，没有可签名的请求头时返回空串
+func Sign(secret []byte, method, path, rawQuery string, header http.Header, names []string, now time.Time) string {
+	signed := make([]string, 0, len(names))
+	for _, name := range names {
+		if len(header.Values(name)) > 0 {
+			signed = append(signed, strings.ToLower(name))
+		}
+	}
+	if len(signed) == 0 {
+		return ""
+	}
+	sort.Strings(signed)
+	signed = dedup(signed)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	nonce := make([]byte, 16)
+	_, _ = rand.Read(nonce)
+	nonceHex := hex.EncodeToString(nonce)
+	mac := signature(secret, ts, nonceHex, method, path, rawQuery, header, signed)
+	return "t=" + ts + ",n=" + nonceHex + ",h=" + strings.Join(signed, ";") + ",s=" + mac
+}
+
+// Verify 校验请求的签名，返回签名覆盖的请求头名（小写）
+// 时间戳与now相差超过maxSkew、签名不符或被签名的请求头出现多个值时返回错误；不检查重放，见Middleware
+func Verify(secret []byte, r *http.Request, maxSkew time.Duration, now time.Time) ([]string, error) {
+	verified, err := verify(secret, r, maxSkew, now)
+	if err != nil {
+		return nil, err
+	}
+	return verified.names, nil
+}
+
+// verified 通过校验的签名
+type verified struct {
+	names []string
+	nonce string
+	unix  int64
+}
+
+func verify(secret []byte, r *http.Request, maxSkew time.Duration, now time.Time) (verified, error) {
+	value := r.Header.Get(SignatureHeader)
+	if value == "" {
+		return verified{}, ErrMissingSignature
+	}
+	var ts, nonce, mac string
+	var names []string
+	for _, part := range strings.Split(value, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return verified{}, ErrMalformed
+		}
+		switch key {
+		case "t":
+			ts = val
+		case "n":
+			nonce = val
+		case "h":
+			names = strings.Split(val, ";")
+		case "s":
+			mac = val
+		}
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || nonce == "" || len(names) == 0 || mac == "" {
+		return verified{}, ErrMalformed
+	}
+	if skew := now.Sub(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return verified{}, ErrExpired
+	}
+	for _, name := range names {
+		if len(r.Header.Values(name)) != 1 {
+			return verified{}, ErrMismatch
+		}
+	}
+	expected := signature(secret, ts, nonce, r.Method, r.URL.Path, r.URL.RawQuery, r.Header, names)
+	if !hmac.Equal([]byte(expected), []byte(mac)) {
+		return verified{}, ErrMismatch
+	}
+	return verified{names: names, nonce: nonce, unix: unix}, nil
+}
+
+// replayCache 记住时间窗口内见过的nonce，超出窗口的签名已被拒绝，nonce只需记到窗口结束
+type replayCache struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // nonce -> 过期时间
+	lastSweep time.Time
+}
+
+func newReplayCache(window time.Duration) *replayCache {
+	return &replayCache{window: window, seen: make(map[string]time.Time)}
+}
+
+// add 登记nonce，窗口内已出现过时返回false
+func (c *replayCache) add(nonce string, signedAt, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if now.Sub(c.lastSweep) >= c.window {
+		for seen, expiry := range c.seen {
+			if now.After(expiry) {
+				delete(c.seen, seen)
+			}
+		}
+		c.lastSweep = now
+	}
+	if _, exists := c.seen[nonce]; exists {
+		return false
+	}
+	c.seen[nonce] = signedAt.Add(c.window)
+	return true
+}
+
+// Middleware 校验protected中的请求头：请求带有其中任一请求头时，必须有覆盖它的有效且未用过的签名
+// 校验失败时返回401，请求不会到达next
+func Middleware(secret []byte, maxSkew time.Duration, protected []string) func(next http.HandlerFunc) http.HandlerFunc {
+	replays := newReplayCache(maxSkew)
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var present []string
+			for _, name := range protected {
+				if len(r.Header.Values(name)) > 0 {
+					present = append(present, strings.ToLower(name))
+				}
+			}
+			if len(present) == 0 {
+				next(w, r)
+				return
+			}
+			now := time.Now()
+			signed, err := verify(secret, r, maxSkew, now)
+			if err == nil {
+				for _, name := range present {
+					if !contains(signed.names, name) {
+						err = ErrUnsigned
+						break
+					}
+				}
+			}
+			if err == nil && !replays.add(signed.nonce, time.Unix(signed.unix, 0), now) {
+				err = ErrReplayed
+			}
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json; charset=utf-8")
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"code":401,"msg":"` + err.Error() + `"}`))
+				return
+			}
+			next(w, r)
+		}
+	}
+}
+
+func signature(secret []byte, ts, nonce, method, path, rawQuery string, header http.Header, names []string) string {
+	var b strings.Builder
+	b.WriteString("v2")
+	for _, field := range []string{ts, nonce, method, path, rawQuery} {
+		b.WriteByte('\n')
+		b.WriteString(field)
+	}
+	for _, name := range names {
+		b.WriteByte('\n')
+		b.WriteString(name)
+		b.WriteByte(':')
+		b.WriteString(header.Get(name))
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(b.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func dedup(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
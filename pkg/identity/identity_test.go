@@ -0,0 +1,100 @@
+package identity
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func signedRequest(secret string, now time.Time) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/api/chat/sendMessage?convId=c-1", nil)
+	r.Header.Set("uuid", "u-1")
+	r.Header.Set("X-User-Nickname", "nick")
+	r.Header.Set(SignatureHeader, Sign([]byte(secret), r.Method, r.URL.Path, r.URL.RawQuery, r.Header, []string{"uuid", "scope", "X-User-Nickname"}, now))
+	return r
+}
+
+func TestSignAndVerify(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	r := signedRequest("secret", now)
+	names, err := Verify([]byte("secret"), r, time.Minute, now.Add(30*time.Second))
+	if err != nil {
+		t.Fatalf("Failed to verify signature: %v", err)
+	}
+	if len(names) != 2 || names[0] != "uuid" || names[1] != "x-user-nickname" {
+		t.Errorf("Expected uuid and x-user-nickname to be signed, got %v", names)
+	}
+
+	if _, err := Verify([]byte("other"), r, time.Minute, now); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected a wrong secret to fail, got %v", err)
+	}
+	if _, err := Verify([]byte("secret"), r, time.Minute, now.Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected an old signature to fail, got %v", err)
+	}
+
+	spoofed := signedRequest("secret", now)
+	spoofed.Header.Set("uuid", "u-2")
+	if _, err := Verify([]byte("secret"), spoofed, time.Minute, now); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected a changed header to fail, got %v", err)
+	}
+	duplicated := signedRequest("secret", now)
+	duplicated.Header.Add("uuid", "u-2")
+	if _, err := Verify([]byte("secret"), duplicated, time.Minute, now); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected a repeated header to fail, got %v", err)
+	}
+	moved := signedRequest("secret", now)
+	moved.URL.Path = "/api/chat/deleteMessage"
+	if _, err := Verify([]byte("secret"), moved, time.Minute, now); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected a signature replayed on another path to fail, got %v", err)
+	}
+	tampered := signedRequest("secret", now)
+	tampered.URL.RawQuery = "convId=c-2"
+	if _, err := Verify([]byte("secret"), tampered, time.Minute, now); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected a tampered query to fail, got %v", err)
+	}
+	dropped := signedRequest("secret", now)
+	dropped.URL.RawQuery = ""
+	if _, err := Verify([]byte("secret"), dropped, time.Minute, now); !errors.Is(err, ErrMismatch) {
+		t.Errorf("Expected a removed query to fail, got %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	handler := Middleware([]byte("secret"), time.Minute, []string{"uuid", "scope", "X-User-Nickname"})(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	serve := func(r *http.Request) int {
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w.Code
+	}
+
+	if code := serve(httptest.NewRequest(http.MethodGet, "/api/version", nil)); code != http.StatusNoContent {
+		t.Errorf("Expected requests without identity headers to pass, got %d", code)
+	}
+	if code := serve(signedRequest("secret", time.Now())); code != http.StatusNoContent {
+		t.Errorf("Expected a signed request to pass, got %d", code)
+	}
+
+	unsigned := httptest.NewRequest(http.MethodGet, "/api/user/info", nil)
+	unsigned.Header.Set("uuid", "u-1")
+	if code := serve(unsigned); code != http.StatusUnauthorized {
+		t.Errorf("Expected an unsigned uuid header to be rejected, got %d", code)
+	}
+	// 签名只覆盖uuid时，额外带上的scope被拒绝
+	extra := signedRequest("secret", time.Now())
+	extra.Header.Set("scope", "bot")
+	if code := serve(extra); code != http.StatusUnauthorized {
+		t.Errorf("Expected an unsigned scope header to be rejected, got %d", code)
+	}
+	// 同一签名第二次出现时被拒绝
+	replayed := signedRequest("secret", time.Now())
+	if code := serve(replayed); code != http.StatusNoContent {
+		t.Errorf("Expected the first use of a signature to pass, got %d", code)
+	}
+	if code := serve(replayed); code != http.StatusUnauthorized {
+		t.Errorf("Expected a replayed signature to be rejected, got %d", code)
+	}
+}
@@ -0,0 +1,197 @@
+package imyclient
+
+import (
+	"context"
+	"time"
+)
+
+// refreshAhead 访问令牌剩余有效期小于该值时提前刷新
+const refreshAhead = time.Minute
+
+// scopeBot 服务账号令牌的访问范围，对应的会话与消息接口在 /api/bot 下
+const scopeBot = "bot"
+
+// Session 登录会话
+type Session struct {
+	UUID         string
+	AccessToken  string
+	RefreshToken string // 服务账号没有刷新令牌
+	ExpiresAt    time.Time
+	Scope        string // 服务账号为bot，普通用户为空
+	SessionID    string // 服务端的登录会话ID，服务账号为空
+}
+
+type tokenResp struct {
+	UUID         string `json:"uuid"`
+	AccessToken  string `json:"accessToken"`
+	RefreshToken string `json:"refreshToken"`
+	ExpiresIn    int64  `json:"expiresIn"`
+	Scope        string `json:"scope"`
+	SessionID    string `json:"sessionId"`
+}
+
+func (t *tokenResp) session() Session {
+	return Session{
+		UUID:         t.UUID,
+		AccessToken:  t.AccessToken,
+		RefreshToken: t.RefreshToken,
+		ExpiresAt:    time.Now().Add(time.Duration(t.ExpiresIn) * time.Second),
+		Scope:        t.Scope,
+		SessionID:    t.SessionID,
+	}
+}
+
+// Register 邮箱密码注册，返回新用户的uuid，注册后需要再调用Login
+func (c *Client) Register(ctx context.Context, email, password, code string) (string, error) {
+	var resp struct {
+		UUID string `json:"uuid"`
+	}
+	req := map[string]string{"email": email, "password": password, "code": code}
+	if err := c.post(ctx, "/api/auth/emailPasswordRegister", "", req, &resp); err != nil {
+		return "", err
+	}
+	return resp.UUID, nil
+}
+
+// Login 邮箱密码登录，之后访问令牌过期时用刷新令牌续期
+// 每次登录在服务端产生一个会话，以Config.Device作为设备名称
+func (c *Client) Login(ctx context.Context, email, password string) (Session, error) {
+	var resp tokenResp
+	req := map[string]string{"email": email, "password": password, "device": c.config.Device}
+	if err := c.post(ctx, "/api/auth/emailPasswordLogin", "", req, &resp); err != nil {
+		return Session{}, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = resp.session()
+	c.clientID, c.clientSecret = "", ""
+	return c.session, nil
+}
+
+// LoginService 用服务账号凭据换取机器人令牌，令牌过期后用同一凭据重新换取
+func (c *Client) LoginService(ctx context.Context, clientID, clientSecret string) (Session, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clientID, c.clientSecret = clientID, clientSecret
+	if err := c.serviceTokenLocked(ctx); err != nil {
+		return Session{}, err
+	}
+	return c.session, nil
+}
+
+// SetSession 恢复之前保存的会话
+func (c *Client) SetSession(s Session) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = s
+}
+
+// Session 返回当前会话，刷新令牌后会变化，调用方可据此持久化
+func (c *Client) Session() Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.session
+}
+
+// UUID 当前登录用户的uuid
+func (c *Client) UUID() string {
+	return c.Session().UUID
+}
+
+// accessToken 返回可用的访问令牌，即将过期时先刷新
+func (c *Client) accessToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session.AccessToken == "" && c.clientID == "" {
+		return "", ErrUnauthorized
+	}
+	if c.session.AccessToken == "" || time.Until(c.session.ExpiresAt) < refreshAhead {
+		if err := c.refreshLocked(ctx); err != nil {
+			return "", err
+		}
+	}
+	return c.session.AccessToken, nil
+}
+
+// refresh 在rejected被服务端拒绝后刷新令牌；其他请求已经刷新过时直接返回新令牌
+func (c *Client) refresh(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.session.AccessToken != rejected {
+		return c.session.AccessToken, nil
+	}
+	if err := c.refreshLocked(ctx); err != nil {
+		return "", err
+	}
+	return c.session.AccessToken, nil
+}
+
+// refreshLocked 用刷新令牌或服务账号凭据续期，调用方持有c.mu
+func (c *Client) refreshLocked(ctx context.Context) error {
+	if c.clientID != "" {
+		return c.serviceTokenLocked(ctx)
+	}
+	if c.session.RefreshToken == "" {
+		return ErrUnauthorized
+	}
+	var resp tokenResp
+	req := map[string]string{"refreshToken": c.session.RefreshToken}
+	if err := c.post(ctx, "/api/auth/refreshToken", "", req, &resp); err != nil {
+		if _, ok := err.(*APIError); ok {
+			return ErrUnauthorized
+		}
+		return err
+	}
+	c.session = resp.session()
+	return nil
+}
+
+func (c *Client) serviceTokenLocked(ctx context.Context) error {
+	var resp tokenResp
+	req := map[string]string{"clientId": c.clientID, "clientSecret": c.clientSecret}
+	if err := c.post(ctx, "/api/auth/serviceToken", "", req, &resp); err != nil {
+		return err
+	}
+	c.session = resp.session()
+	return nil
+}
+
+// SessionInfo 一个登录会话，对应一台登录过的设备
+type SessionInfo struct {
+	SessionId     string `json:"sessionId"`
+	Device        string `json:"device"`
+	UserAgent     string `json:"userAgent"`
+	LoginIp       string `json:"loginIp"`
+	LastIp        string `json:"lastIp"`
+	CreatedAt     int64  `json:"createdAt"`
+	LastActive    int64  `json:"lastActive"`
+	ExpiresAt     int64  `json:"expiresAt"`
+	WsConnections int    `json:"wsConnections"` // 该会话当前的WebSocket连接数
+	Current       bool   `json:"current"`       // 是否为本客户端的会话
+}
+
+// GetSessions 列出当前用户的登录会话，最近活跃的在前
+func (c *Client) GetSessions(ctx context.Context) ([]SessionInfo, error) {
+	var resp struct {
+		Sessions []SessionInfo `json:"sessions"`
+	}
+	if err := c.Call(ctx, "/api/auth/getSessions", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Sessions, nil
+}
+
+// RevokeSession 吊销一个登录会话，对应设备的刷新令牌失效，WebSocket连接被关闭
+// 吊销本客户端的会话相当于退出登录，返回被关闭的连接数
+func (c *Client) RevokeSession(ctx context.Context, sessionID string) (int, error) {
+	var resp struct {
+		WsClosed int `json:"wsClosed"`
+	}
+	if err := c.Call(ctx, "/api/auth/revokeSession", map[string]string{"sessionId": sessionID}, &resp); err != nil {
+		return 0, err
+	}
+	if sessionID == c.Session().SessionID {
+		c.SetSession(Session{})
+	}
+	return resp.WsClosed, nil
+}
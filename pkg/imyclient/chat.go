@@ -0,0 +1,123 @@
+package imyclient
+
+import "context"
+
+// 消息类型
+const (
+	MsgTypeText   = 1
+	MsgTypeSystem = 6
+)
+
+// Conversation 会话信息
+type Conversation struct {
+	ConversationId   uint32 `json:"conversationId"`
+	Type             uint32 `json:"type"` // 1:单聊 2:群聊
+	PrivateKey       string `json:"privateKey"`
+	Name             string `json:"name"`
+	MemberCount      uint32 `json:"memberCount"`
+	LastMessageId    uint64 `json:"lastMessageId"`
+	Avatar           string `json:"avatar"`
+	Extra            string `json:"extra"`
+	AnnouncementOnly uint32 `json:"announcementOnly"`
+}
+
+// Message 消息
+type Message struct {
+	Id               uint64   `json:"id"`
+	ConversationId   uint32   `json:"conversationId"`
+	SendUuid         string   `json:"sendUuid"`
+	MsgType          uint32   `json:"msgType"`
+	Content          string   `json:"content"`
+	ContentExtra     string   `json:"contentExtra"`
+	ReplyToMessageId uint64   `json:"replyToMessageId"`
+	MentionedUuids   []string `json:"mentionedUuids"`
+	IsSystem         uint32   `json:"isSystem"`
+	IsRevoked        uint32   `json:"isRevoked"`
+	ReplyCount       uint32   `json:"replyCount"`
+	CreatedAt        string   `json:"createdAt"`
+}
+
+// SendMessageRequest 发送消息参数，ClientMsgId必填，重试时保持不变即可去重
+type SendMessageRequest struct {
+	ConversationId   uint32   `json:"conversationId"`
+	ClientMsgId      string   `json:"clientMsgId"`
+	MsgType          uint32   `json:"msgType"`
+	Content          string   `json:"content"`
+	ContentExtra     string   `json:"contentExtra,omitempty"`
+	ReplyToMessageId uint64   `json:"replyToMessageId,omitempty"`
+	MentionedUuids   []string `json:"mentionedUuids,omitempty"`
+}
+
+// SendMessageResult 发送结果
+type SendMessageResult struct {
+	ServerMsgId uint64 `json:"serverMsgId"`
+	ClientMsgId string `json:"clientMsgId"`
+	CreatedAt   string `json:"createdAt"`
+}
+
+// GetMessagesRequest 拉取消息参数，AfterId与BeforeId都为空时返回最新的消息
+type GetMessagesRequest struct {
+	ConversationId uint32 `json:"conversationId"`
+	BeforeId       uint64 `json:"beforeId,omitempty"`
+	AfterId        uint64 `json:"afterId,omitempty"`
+	Limit          int    `json:"limit,omitempty"`
+}
+
+// chatPath 会话与消息接口的路径，机器人令牌只能访问 /api/bot 下的同名接口
+func (c *Client) chatPath(name string) string {
+	if c.Session().Scope == scopeBot {
+		return "/api/bot/" + name
+	}
+	return "/api/chat/" + name
+}
+
+// CreatePrivate 创建或获取与peerUUID的单聊会话
+func (c *Client) CreatePrivate(ctx context.Context, peerUUID string) (*Conversation, error) {
+	var resp Conversation
+	if err := c.Call(ctx, "/api/chat/createPrivate", map[string]string{"peerUuid": peerUUID}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CreateGroup 创建群聊，当前用户为群主
+func (c *Client) CreateGroup(ctx context.Context, name string, memberUUIDs []string) (*Conversation, error) {
+	var resp Conversation
+	req := map[string]any{"name": name, "memberUuids": memberUUIDs}
+	if err := c.Call(ctx, "/api/chat/createGroup", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetConversations 分页获取所在的会话，按最后一条消息倒序
+func (c *Client) GetConversations(ctx context.Context, pageSize, pageIndex int) ([]Conversation, error) {
+	var resp struct {
+		Conversations []Conversation `json:"conversations"`
+	}
+	req := map[string]int{"pageSize": pageSize, "pageIndex": pageIndex}
+	if err := c.Call(ctx, c.chatPath("getConversations"), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Conversations, nil
+}
+
+// GetMessages 拉取会话消息，结果按消息ID升序
+func (c *Client) GetMessages(ctx context.Context, req *GetMessagesRequest) ([]Message, error) {
+	var resp struct {
+		Messages []Message `json:"messages"`
+	}
+	if err := c.Call(ctx, c.chatPath("getMessages"), req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// SendMessage 发送消息
+func (c *Client) SendMessage(ctx context.Context, req *SendMessageRequest) (*SendMessageResult, error) {
+	var resp SendMessageResult
+	if err := c.Call(ctx, c.chatPath("sendMessage"), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
@@ -0,0 +1,210 @@
+// Package imyclient imy服务端的Go客户端
+// 封装登录与令牌自动刷新、统一的{code,msg,data}响应解包、失败重试和WebSocket事件流，
+// 所有方法都接受context，Client可以并发使用
+package imyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"imy/pkg/errs"
+)
+
+// ErrUnauthorized 访问令牌无效且无法刷新，需要重新登录
+var ErrUnauthorized error = errs.New(errs.Unauthorized, "imyclient: unauthorized")
+
+// APIError 服务端返回的非0业务错误码
+type APIError struct {
+	Path string
+	Code int
+	Msg  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("imyclient: %s: %d %s", e.Path, e.Code, e.Msg)
+}
+
+// ErrorCode 实现errs.Coder，网关以HTTP状态码作为业务错误码，其余业务错误码为errs.Unknown
+func (e *APIError) ErrorCode() errs.Code {
+	if e.Code >= 400 && e.Code < 600 {
+		return errs.FromHTTPStatus(e.Code)
+	}
+	return errs.Unknown
+}
+
+// Is 使APIError可以用errors.Is与errs中的哨兵错误比较
+func (e *APIError) Is(target error) bool {
+	return errs.MatchSentinel(e.ErrorCode(), target)
+}
+
+// StatusError 非200的HTTP响应，按状态码归类为errs.Code
+type StatusError struct {
+	Path   string
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("imyclient: %s: unexpected status %d: %s", e.Path, e.Status, e.Body)
+}
+
+// ErrorCode 实现errs.Coder
+func (e *StatusError) ErrorCode() errs.Code {
+	return errs.FromHTTPStatus(e.Status)
+}
+
+// Is 使StatusError可以用errors.Is与errs中的哨兵错误比较
+func (e *StatusError) Is(target error) bool {
+	return errs.MatchSentinel(e.ErrorCode(), target)
+}
+
+// Config 客户端配置
+type Config struct {
+	BaseURL      string        // 网关地址，如 http://127.0.0.1:8081
+	Timeout      time.Duration // 单次HTTP请求超时，默认10s
+	MaxRetries   int           // 网络错误和5xx响应的重试次数，默认2，小于0时不重试
+	RetryBackoff time.Duration // 首次重试的等待时间，之后逐次翻倍，默认200ms
+	HTTPClient   *http.Client  // 为空时按Timeout创建
+	Device       string        // 登录时上报的设备名称，显示在会话列表中
+}
+
+// Client imy客户端
+type Client struct {
+	config  Config
+	baseURL string
+	http    *http.Client
+
+	// mu 保护会话，刷新令牌期间持有，避免并发请求重复刷新
+	mu      sync.Mutex
+	session Session
+	// 服务账号凭据，没有刷新令牌时用于重新换取访问令牌
+	clientID     string
+	clientSecret string
+}
+
+type baseResponse struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// New 创建客户端
+func New(c Config) (*Client, error) {
+	if !strings.HasPrefix(c.BaseURL, "http://") && !strings.HasPrefix(c.BaseURL, "https://") {
+		return nil, fmt.Errorf("imyclient: invalid base url %q", c.BaseURL)
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 10 * time.Second
+	}
+	if c.MaxRetries == 0 {
+		c.MaxRetries = 2
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = 200 * time.Millisecond
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: c.Timeout}
+	}
+	return &Client{config: c, baseURL: strings.TrimSuffix(c.BaseURL, "/"), http: httpClient}, nil
+}
+
+// Call 以当前会话调用接口，req为请求体，resp为data的解码目标，可以为nil
+// 令牌即将过期时先刷新；服务端返回401时刷新一次后重试
+func (c *Client) Call(ctx context.Context, path string, req, resp any) error {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	err = c.post(ctx, path, token, req, resp)
+	if !errors.Is(err, ErrUnauthorized) {
+		return err
+	}
+	if token, err = c.refresh(ctx, token); err != nil {
+		return err
+	}
+	return c.post(ctx, path, token, req, resp)
+}
+
+// post 发送请求并解包响应，网络错误和可重试的错误码（限流、5xx）按退避重试
+// 写接口依赖服务端幂等（如发送消息的clientMsgId）保证重试安全
+func (c *Client) post(ctx context.Context, path, token string, req, resp any) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	backoff := c.config.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err = c.postOnce(ctx, path, token, body, resp)
+		var retry *retryableError
+		if !errors.As(err, &retry) {
+			return err
+		}
+		if attempt >= c.config.MaxRetries {
+			return retry.err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// retryableError 可以重试的失败
+type retryableError struct{ err error }
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (c *Client) postOnce(ctx context.Context, path, token string, body []byte, resp any) error {
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := c.http.Do(r)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &retryableError{&errs.Error{Code: errs.Unavailable, Message: "imyclient: " + path, Err: err}}
+	}
+	defer res.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 16<<20))
+	if res.StatusCode != http.StatusOK {
+		statusErr := &StatusError{Path: path, Status: res.StatusCode, Body: string(bytes.TrimSpace(data))}
+		switch code := statusErr.ErrorCode(); {
+		case code == errs.Unauthorized:
+			return ErrUnauthorized
+		case errs.Retryable(code) || code == errs.Internal:
+			return &retryableError{statusErr}
+		}
+		return statusErr
+	}
+
+	var base baseResponse
+	if err := json.Unmarshal(data, &base); err != nil {
+		return fmt.Errorf("imyclient: %s: decode response: %w", path, err)
+	}
+	if base.Code != 0 {
+		return &APIError{Path: path, Code: base.Code, Msg: base.Msg}
+	}
+	if resp != nil && len(base.Data) > 0 && string(base.Data) != "null" {
+		if err := json.Unmarshal(base.Data, resp); err != nil {
+			return fmt.Errorf("imyclient: %s: decode data: %w", path, err)
+		}
+	}
+	return nil
+}
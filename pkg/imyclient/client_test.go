@@ -0,0 +1,263 @@
+package imyclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"imy/pkg/errs"
+)
+
+// writeData 按服务端的统一格式返回data
+func writeData(w http.ResponseWriter, data any) {
+	json.NewEncoder(w).Encode(map[string]any{"code": 0, "msg": "success", "data": data})
+}
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := New(Config{BaseURL: server.URL, RetryBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	return client
+}
+
+func TestCallRefreshesRejectedToken(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/emailPasswordLogin", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"uuid": "u1", "accessToken": "old", "refreshToken": "r1", "expiresIn": 3600})
+	})
+	mux.HandleFunc("/api/auth/refreshToken", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"uuid": "u1", "accessToken": "new", "refreshToken": "r2", "expiresIn": 3600})
+	})
+	mux.HandleFunc("/api/chat/createPrivate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeData(w, map[string]any{"conversationId": 7, "type": 1})
+	})
+	client := newTestClient(t, mux)
+
+	if _, err := client.Login(context.Background(), "a@b.c", "pw"); err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	conv, err := client.CreatePrivate(context.Background(), "u2")
+	if err != nil {
+		t.Fatalf("CreatePrivate failed: %v", err)
+	}
+	if conv.ConversationId != 7 {
+		t.Errorf("Expected conversation 7, got %d", conv.ConversationId)
+	}
+	if s := client.Session(); s.AccessToken != "new" || s.RefreshToken != "r2" {
+		t.Errorf("Expected refreshed session, got %+v", s)
+	}
+}
+
+func TestCallUnwrapsErrorsAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		writeData(w, map[string]any{"serverMsgId": 42, "clientMsgId": "c1"})
+	})
+	mux.HandleFunc("/api/chat/getMessages", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"code": 1003, "msg": "参数错误"})
+	})
+	client := newTestClient(t, mux)
+	client.SetSession(Session{UUID: "u1", AccessToken: "t", ExpiresAt: time.Now().Add(time.Hour)})
+
+	result, err := client.SendMessage(context.Background(), &SendMessageRequest{ConversationId: 1, ClientMsgId: "c1", MsgType: MsgTypeText, Content: "hi"})
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	if result.ServerMsgId != 42 || attempts.Load() != 3 {
+		t.Errorf("Expected message 42 after 3 attempts, got %d after %d", result.ServerMsgId, attempts.Load())
+	}
+
+	_, err = client.GetMessages(context.Background(), &GetMessagesRequest{ConversationId: 1})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Code != 1003 {
+		t.Errorf("Expected APIError 1003, got %v", err)
+	}
+}
+
+func TestCallReportsStatusCodes(t *testing.T) {
+	var attempts atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat/getMessages", func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "Forbidden: path not allowed for bot tokens", http.StatusForbidden)
+	})
+	mux.HandleFunc("/api/chat/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+	})
+	client := newTestClient(t, mux)
+	client.SetSession(Session{UUID: "u1", AccessToken: "t", ExpiresAt: time.Now().Add(time.Hour)})
+
+	_, err := client.GetMessages(context.Background(), &GetMessagesRequest{ConversationId: 1})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Status != http.StatusForbidden || !errors.Is(err, errs.ErrPermissionDenied) {
+		t.Errorf("Expected a permission denied StatusError, got %v", err)
+	}
+	if attempts.Load() != 1 {
+		t.Errorf("Expected no retries for 403, got %d attempts", attempts.Load())
+	}
+
+	_, err = client.SendMessage(context.Background(), &SendMessageRequest{ConversationId: 1, ClientMsgId: "c1", MsgType: MsgTypeText, Content: "hi"})
+	if errs.CodeOf(err) != errs.Overloaded {
+		t.Errorf("Expected an overloaded error after retries, got %v", err)
+	}
+}
+
+func TestServiceAccountUsesBotAPI(t *testing.T) {
+	var exchanges atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/serviceToken", func(w http.ResponseWriter, r *http.Request) {
+		n := exchanges.Add(1)
+		// 第一个令牌立即过期
+		expiresIn := 0
+		if n > 1 {
+			expiresIn = 3600
+		}
+		writeData(w, map[string]any{"uuid": "bot1", "accessToken": "t", "expiresIn": expiresIn, "scope": "bot"})
+	})
+	mux.HandleFunc("/api/bot/getConversations", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"conversations": []map[string]any{{"conversationId": 3}}})
+	})
+	client := newTestClient(t, mux)
+
+	if _, err := client.LoginService(context.Background(), "id", "secret"); err != nil {
+		t.Fatalf("LoginService failed: %v", err)
+	}
+	convs, err := client.GetConversations(context.Background(), 10, 1)
+	if err != nil {
+		t.Fatalf("GetConversations failed: %v", err)
+	}
+	if len(convs) != 1 || convs[0].ConversationId != 3 {
+		t.Errorf("Expected conversation 3, got %+v", convs)
+	}
+	if exchanges.Load() != 2 {
+		t.Errorf("Expected the expired token to be exchanged again, got %d exchanges", exchanges.Load())
+	}
+}
+
+func TestStreamEventsResumes(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{protocolV2}}
+	var connections atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/chat/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if connections.Add(1) == 1 {
+			// 第一个连接推送一条事件后断开
+			conn.WriteJSON(Event{Type: "message", Seq: 1})
+			var receipt Event
+			conn.ReadJSON(&receipt)
+			return
+		}
+		var resume Event
+		if err := conn.ReadJSON(&resume); err != nil || resume.Type != envelopeResume || string(resume.Payload) != `{"lastSeq":1}` {
+			t.Errorf("Expected resume after seq 1, got %+v", resume)
+			return
+		}
+		conn.WriteJSON(Event{Type: "message", Seq: 1})
+		conn.WriteJSON(Event{Type: "message", Seq: 2})
+		conn.ReadMessage()
+	})
+	client := newTestClient(t, mux)
+	client.SetSession(Session{UUID: "u1", AccessToken: "t", ExpiresAt: time.Now().Add(time.Hour)})
+
+	stop := errors.New("stop")
+	var seqs []int64
+	err := client.StreamEvents(context.Background(), 0, func(event *Event) error {
+		seqs = append(seqs, event.Seq)
+		if event.Seq == 2 {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("Expected the handler error, got %v", err)
+	}
+	if len(seqs) != 2 || seqs[0] != 1 || seqs[1] != 2 {
+		t.Errorf("Expected events 1 and 2 once each, got %v", seqs)
+	}
+}
+
+func TestSessionsRevokeEndsStream(t *testing.T) {
+	upgrader := websocket.Upgrader{Subprotocols: []string{protocolV2}}
+	connected, revoked := make(chan struct{}), make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/emailPasswordLogin", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if req["device"] != "laptop" {
+			t.Errorf("Expected the device name in the login request, got %v", req)
+		}
+		writeData(w, map[string]any{"uuid": "u1", "accessToken": "t", "refreshToken": "r", "expiresIn": 3600, "sessionId": "s1"})
+	})
+	mux.HandleFunc("/api/auth/getSessions", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"sessions": []map[string]any{
+			{"sessionId": "s1", "device": "laptop", "current": true},
+			{"sessionId": "s2", "device": "phone", "wsConnections": 1},
+		}})
+	})
+	mux.HandleFunc("/api/auth/revokeSession", func(w http.ResponseWriter, r *http.Request) {
+		writeData(w, map[string]any{"wsClosed": 1})
+		close(revoked)
+	})
+	mux.HandleFunc("/api/chat/ws", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		close(connected)
+		<-revoked
+		conn.WriteJSON(Event{Type: envelopeLogout, Payload: json.RawMessage(`{"sessionId":"s1","reason":"revoked"}`)})
+		conn.ReadMessage()
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	client, _ := New(Config{BaseURL: server.URL, RetryBackoff: time.Millisecond, Device: "laptop"})
+
+	session, err := client.Login(context.Background(), "a@b.c", "pw")
+	if err != nil || session.SessionID != "s1" {
+		t.Fatalf("Expected session s1, got %+v: %v", session, err)
+	}
+	sessions, err := client.GetSessions(context.Background())
+	if err != nil || len(sessions) != 2 || !sessions[0].Current || sessions[1].WsConnections != 1 {
+		t.Fatalf("Unexpected sessions %+v: %v", sessions, err)
+	}
+
+	streamErr := make(chan error, 1)
+	go func() {
+		streamErr <- client.StreamEvents(context.Background(), 0, func(*Event) error { return nil })
+	}()
+	<-connected
+	closed, err := client.RevokeSession(context.Background(), "s1")
+	if err != nil || closed != 1 {
+		t.Fatalf("Expected one closed connection, got %d: %v", closed, err)
+	}
+	if err := <-streamErr; !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Expected ErrSessionRevoked, got %v", err)
+	}
+	if _, err := client.GetSessions(context.Background()); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("Expected the revoked client to be signed out, got %v", err)
+	}
+}
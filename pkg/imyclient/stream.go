@@ -0,0 +1,168 @@
+package imyclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"imy/pkg/errs"
+)
+
+// WebSocket v2协议，与 pkg/websocket 中的定义保持一致
+const (
+	protocolV2        = "imy.v2"
+	envelopeResume    = "resume"
+	envelopeReceipt   = "receipt"
+	envelopeLogout    = "logout"
+	maxStreamBackoff  = 30 * time.Second
+	streamReadTimeout = 90 * time.Second // 服务端每30s发一次ping
+)
+
+// Event WebSocket推送的事件
+// Seq为持久事件在用户日志中的序号，非持久事件（在线状态、输入中、错误）为0
+type Event struct {
+	Type    string          `json:"type"`
+	Seq     int64           `json:"seq,omitempty"`
+	ConvID  uint32          `json:"convId,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type resumeResult struct {
+	Replayed int   `json:"replayed"`
+	LastSeq  int64 `json:"lastSeq"`
+	HasMore  bool  `json:"hasMore"`
+}
+
+// ErrSessionRevoked 登录会话已在其他设备上被吊销，需要重新登录
+var ErrSessionRevoked error = errs.New(errs.Unauthorized, "imyclient: session revoked")
+
+// handlerError 事件处理函数返回的错误，直接结束事件流
+type handlerError struct{ err error }
+
+func (e *handlerError) Error() string { return e.err.Error() }
+
+// StreamEvents 订阅当前用户的事件，按顺序交给handle处理，直到ctx结束或handle返回错误
+// 连接断开后按退避自动重连，并从最后处理的Seq续传，处理成功的持久事件会回执给服务端；
+// lastSeq为上次处理到的Seq，为0时只接收之后的新事件
+// 会话被吊销时logout事件先交给handle，随后清空会话并返回ErrSessionRevoked
+func (c *Client) StreamEvents(ctx context.Context, lastSeq int64, handle func(*Event) error) error {
+	backoff := c.config.RetryBackoff
+	for {
+		connected, err := c.streamOnce(ctx, &lastSeq, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var herr *handlerError
+		if errors.As(err, &herr) {
+			return herr.err
+		}
+		switch errs.CodeOf(err) {
+		case errs.Unauthorized, errs.PermissionDenied:
+			// 令牌无法刷新、会话被吊销或无权订阅，重连也不会成功
+			return err
+		}
+		if connected {
+			backoff = c.config.RetryBackoff
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxStreamBackoff)
+	}
+}
+
+// streamOnce 建立一次连接并读取事件，connected表示握手是否成功
+func (c *Client) streamOnce(ctx context.Context, lastSeq *int64, handle func(*Event) error) (connected bool, err error) {
+	conn, err := c.dialStream(ctx)
+	if errors.Is(err, ErrUnauthorized) {
+		// 令牌被拒绝时刷新一次，仍然失败说明需要重新登录
+		if _, err = c.refresh(ctx, c.Session().AccessToken); err != nil {
+			return false, err
+		}
+		conn, err = c.dialStream(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if *lastSeq > 0 {
+		if err := writeEnvelope(conn, envelopeResume, map[string]int64{"lastSeq": *lastSeq}); err != nil {
+			return true, err
+		}
+	}
+	for {
+		conn.SetReadDeadline(time.Now().Add(streamReadTimeout))
+		var event Event
+		if err := conn.ReadJSON(&event); err != nil {
+			return true, err
+		}
+		if event.Seq > 0 && event.Seq <= *lastSeq {
+			// 续传与实时推送重叠的部分
+			continue
+		}
+		if err := handle(&event); err != nil {
+			return true, &handlerError{err}
+		}
+		if event.Type == envelopeLogout {
+			c.SetSession(Session{})
+			return true, ErrSessionRevoked
+		}
+		if event.Seq > 0 {
+			*lastSeq = event.Seq
+			if err := writeEnvelope(conn, envelopeReceipt, map[string]int64{"seq": event.Seq}); err != nil {
+				return true, err
+			}
+		}
+		if event.Type == envelopeResume {
+			var result resumeResult
+			if json.Unmarshal(event.Payload, &result) == nil && result.HasMore {
+				if err := writeEnvelope(conn, envelopeResume, map[string]int64{"lastSeq": *lastSeq}); err != nil {
+					return true, err
+				}
+			}
+		}
+	}
+}
+
+func (c *Client) dialStream(ctx context.Context) (*websocket.Conn, error) {
+	token, err := c.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	url := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/api/chat/ws?v=2"
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: c.config.Timeout,
+		Subprotocols:     []string{protocolV2},
+	}
+	header := http.Header{"Authorization": {"Bearer " + token}}
+	conn, resp, err := dialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			statusErr := &StatusError{Path: "/api/chat/ws", Status: resp.StatusCode}
+			if statusErr.ErrorCode() == errs.Unauthorized {
+				return nil, ErrUnauthorized
+			}
+			return nil, statusErr
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func writeEnvelope(conn *websocket.Conn, envelopeType string, payload any) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return conn.WriteJSON(&Event{Type: envelopeType, Payload: raw})
+}
@@ -0,0 +1,123 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// JWK 一个公钥，字段见RFC 7517/7518
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// EC
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JWKS 公钥集合
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回仍有效的RS256/ES256公钥，HMAC密钥不会发布
+func (s *KeySet) JWKS() JWKS {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range s.keys {
+		if key.retired(now) {
+			continue
+		}
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA", Kid: key.ID, Use: "sig", Alg: AlgRS256,
+				N: b64(pub.N.Bytes()),
+				E: b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "EC", Kid: key.ID, Use: "sig", Alg: AlgES256, Crv: "P-256",
+				X: b64(pub.X.FillBytes(make([]byte, 32))),
+				Y: b64(pub.Y.FillBytes(make([]byte, 32))),
+			})
+		}
+	}
+	return set
+}
+
+// ParseJWKS 由JWKS文档创建只用于校验的密钥集，不支持的密钥被忽略
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("jwt: invalid jwks: %w", err)
+	}
+	var keys []*Key
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.publicKey()
+		if err != nil {
+			return nil, err
+		}
+		if pub == nil {
+			continue
+		}
+		key, err := NewPublicKey(jwk.Kid, pub)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(nil, keys...)
+}
+
+func (k JWK) publicKey() (any, error) {
+	switch {
+	case k.Kty == "RSA" && (k.Alg == "" || k.Alg == AlgRS256):
+		n, err1 := unb64(k.N)
+		e, err2 := unb64(k.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 {
+			return nil, fmt.Errorf("jwt: jwks key %s: invalid RSA parameters", k.Kid)
+		}
+		exp := new(big.Int).SetBytes(e)
+		if !exp.IsInt64() || exp.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwt: jwks key %s: RSA exponent too large", k.Kid)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exp.Int64())}, nil
+	case k.Kty == "EC" && k.Crv == "P-256" && (k.Alg == "" || k.Alg == AlgES256):
+		x, err1 := unb64(k.X)
+		y, err2 := unb64(k.Y)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("jwt: jwks key %s: invalid EC parameters", k.Kid)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("jwt: jwks key %s: point not on curve", k.Kid)
+		}
+		return pub, nil
+	}
+	return nil, nil
+}
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func unb64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
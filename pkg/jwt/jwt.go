@@ -1,9 +1,11 @@
 package jwt
 
 import (
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
 )
 
 // Config JWT配置
@@ -20,6 +22,7 @@ func GenerateToken(config Config, payload map[string]interface{}) (string, error
 
 	// 创建Claims
 	claims := jwt.MapClaims{
+		"jti": NewTokenID(),                     // 令牌ID，用于吊销
 		"iat": now.Unix(),                       // 签发时间
 		"iss": config.Issuer,                    // 签发者
 		"exp": now.Add(config.ExpiresAt).Unix(), // 过期时间
@@ -40,17 +43,36 @@ func GenerateToken(config Config, payload map[string]interface{}) (string, error
 type JwtPayLoad struct {
 	Nickname string `json:"nickName"`
 	UUID     string `json:"uuid"`
+	Type     string `json:"typ,omitempty"`   // 令牌类型，访问令牌为空
+	Scope    string `json:"scope,omitempty"` // 访问范围，普通用户为空
+	Session  string `json:"sid,omitempty"`   // 登录会话ID，服务账号与旧令牌为空
 }
 
+// TokenTypeRefresh 刷新令牌类型
+const TokenTypeRefresh = "refresh"
+
+// ScopeBot 服务账号令牌的访问范围，网关只允许其访问机器人接口
+const ScopeBot = "bot"
+
+// ErrTokenType 令牌类型不符，如把访问令牌当作刷新令牌使用
+var ErrTokenType = errors.New("jwt: unexpected token type")
+
 type CustomClaims struct {
 	JwtPayLoad
 	jwt.RegisteredClaims
 }
 
+// NewTokenID 生成写入jti的令牌ID，网关按它吊销令牌
+func NewTokenID() string {
+	return uuid.NewString()
+}
+
 func GenToken(payload JwtPayLoad, accessSecret string, expires int64) (string, error) {
 	claims := CustomClaims{
 		JwtPayLoad: payload,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        NewTokenID(),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expires))),
 		},
 	}
@@ -58,6 +80,69 @@ func GenToken(payload JwtPayLoad, accessSecret string, expires int64) (string, e
 	return token.SignedString([]byte(accessSecret))
 }
 
+// GenAccessToken 签发有效期为ttl的访问令牌，每个令牌带有唯一的jti
+func GenAccessToken(payload JwtPayLoad, accessSecret string, ttl time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims(payload, ttl))
+	return token.SignedString([]byte(accessSecret))
+}
+
+func accessClaims(payload JwtPayLoad, ttl time.Duration) CustomClaims {
+	payload.Type = ""
+	now := time.Now()
+	return CustomClaims{
+		JwtPayLoad: payload,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        NewTokenID(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// GenServiceToken 签发服务账号的访问令牌，令牌带有scope声明且没有对应的刷新令牌
+func GenServiceToken(payload JwtPayLoad, accessSecret string, ttl time.Duration, scope string) (string, error) {
+	payload.Scope = scope
+	return GenAccessToken(payload, accessSecret, ttl)
+}
+
+// GenRefreshToken 签发刷新令牌，id写入jti用于轮换时识别令牌是否已被使用
+// 刷新令牌使用由accessSecret派生的密钥签名，ParseToken及其他只认访问令牌的校验都不会接受它
+func GenRefreshToken(payload JwtPayLoad, accessSecret string, ttl time.Duration, id string) (string, error) {
+	payload.Type = TokenTypeRefresh
+	claims := CustomClaims{
+		JwtPayLoad: payload,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+		},
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(refreshKey(accessSecret))
+}
+
+// ParseRefreshToken 解析并校验刷新令牌
+func ParseRefreshToken(tokenStr string, accessSecret string) (*CustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenStr, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return refreshKey(accessSecret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if claims.Type != TokenTypeRefresh || claims.ID == "" {
+		return nil, ErrTokenType
+	}
+	return claims, nil
+}
+
+func refreshKey(accessSecret string) []byte {
+	return []byte("refresh:" + accessSecret)
+}
+
 func ParseToken(tokenStr string, accessSecret string) (*CustomClaims, error) {
 	token, err := jwt.ParseWithClaims(tokenStr, &CustomClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(accessSecret), nil
@@ -66,6 +151,9 @@ func ParseToken(tokenStr string, accessSecret string) (*CustomClaims, error) {
 		return nil, err
 	}
 	if clains, ok := token.Claims.(*CustomClaims); ok && token.Valid {
+		if clains.Type == TokenTypeRefresh {
+			return nil, ErrTokenType
+		}
 		return clains, nil
 	}
 	return nil, err
@@ -0,0 +1,77 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenIsNotAnAccessToken(t *testing.T) {
+	payload := JwtPayLoad{Nickname: "nick", UUID: "u-1"}
+
+	refresh, err := GenRefreshToken(payload, "secret", time.Hour, "rid-1")
+	if err != nil {
+		t.Fatalf("Failed to generate refresh token: %v", err)
+	}
+	claims, err := ParseRefreshToken(refresh, "secret")
+	if err != nil {
+		t.Fatalf("Failed to parse refresh token: %v", err)
+	}
+	if claims.UUID != "u-1" || claims.ID != "rid-1" || claims.Type != TokenTypeRefresh {
+		t.Errorf("Unexpected refresh claims: %+v", claims)
+	}
+	if _, err := ParseToken(refresh, "secret"); err == nil {
+		t.Error("Refresh token must not be accepted as an access token")
+	}
+
+	access, err := GenAccessToken(payload, "secret", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to generate access token: %v", err)
+	}
+	if claims, err := ParseToken(access, "secret"); err != nil || claims.UUID != "u-1" {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+	if _, err := ParseRefreshToken(access, "secret"); err == nil {
+		t.Error("Access token must not be accepted as a refresh token")
+	}
+
+	expired, _ := GenRefreshToken(payload, "secret", -time.Minute, "rid-2")
+	if _, err := ParseRefreshToken(expired, "secret"); err == nil || errors.Is(err, ErrTokenType) {
+		t.Errorf("Expected expiry error, got %v", err)
+	}
+}
+
+func TestServiceTokenCarriesScope(t *testing.T) {
+	token, err := GenServiceToken(JwtPayLoad{Nickname: "echo", UUID: "bot-1"}, "secret", time.Hour, ScopeBot)
+	if err != nil {
+		t.Fatalf("Failed to generate service token: %v", err)
+	}
+	claims, err := ParseToken(token, "secret")
+	if err != nil || claims.UUID != "bot-1" || claims.Scope != ScopeBot {
+		t.Fatalf("Unexpected service claims %+v: %v", claims, err)
+	}
+
+	access, _ := GenAccessToken(JwtPayLoad{UUID: "u-1"}, "secret", time.Hour)
+	if claims, err := ParseToken(access, "secret"); err != nil || claims.Scope != "" {
+		t.Errorf("Expected user tokens without scope, got %+v: %v", claims, err)
+	}
+}
+
+func TestAccessTokensCarryIDAndIssuedAt(t *testing.T) {
+	first, _ := GenAccessToken(JwtPayLoad{UUID: "u-1"}, "secret", time.Hour)
+	second, _ := GenAccessToken(JwtPayLoad{UUID: "u-1"}, "secret", time.Hour)
+	a, err := ParseToken(first, "secret")
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+	b, err := ParseToken(second, "secret")
+	if err != nil {
+		t.Fatalf("Failed to parse access token: %v", err)
+	}
+	if a.ID == "" || a.ID == b.ID {
+		t.Errorf("Expected distinct token ids, got %q and %q", a.ID, b.ID)
+	}
+	if a.IssuedAt == nil || time.Since(a.IssuedAt.Time) > time.Minute {
+		t.Errorf("Expected iat to be set, got %v", a.IssuedAt)
+	}
+}
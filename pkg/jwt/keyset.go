@@ -0,0 +1,342 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// 多密钥签名与轮换
+// 令牌头部的kid指明签名密钥，KeySet按kid选取校验密钥，并只接受该密钥对应的算法。
+// 轮换时新密钥成为签名密钥，旧密钥在宽限期内仍可校验，之后拒绝；
+// RS256/ES256的公钥可以JWKS形式发布，其他服务无需共享密钥即可校验令牌。
+// 没有kid的令牌由旧配置的AccessSecret签发，按kid为空的HS256密钥校验。
+
+// 支持的签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// 密钥错误
+var (
+	ErrUnknownKey    = errors.New("jwt: unknown signing key")
+	ErrKeyRetired    = errors.New("jwt: signing key retired")
+	ErrKeyAlgorithm  = errors.New("jwt: algorithm does not match the key")
+	ErrNoSigningKey  = errors.New("jwt: key set has no signing key")
+	ErrVerifyOnlyKey = errors.New("jwt: key cannot sign")
+)
+
+// KeyConfig 一个签名密钥的配置
+type KeyConfig struct {
+	ID             string
+	Algorithm      string `json:",default=HS256,options=HS256|RS256|ES256"`
+	Secret         string `json:",optional"` // HS256
+	PrivateKeyFile string `json:",optional"` // RS256/ES256 的PEM私钥
+	PublicKeyFile  string `json:",optional"` // 只校验不签名时的PEM公钥
+	NotAfter       string `json:",optional"` // RFC3339，此后不再接受该密钥签发的令牌
+}
+
+// KeySetConfig 密钥集配置，Active为签名使用的kid
+type KeySetConfig struct {
+	Active string      `json:",optional"`
+	Keys   []KeyConfig `json:",optional"`
+	// 配置了Keys后，没有kid的旧令牌（AccessSecret签发）接受到此时间，为空时一直接受
+	LegacyNotAfter string `json:",optional"`
+}
+
+// Key 签名或校验密钥
+type Key struct {
+	ID        string
+	Algorithm string
+	NotAfter  time.Time // 零值表示一直有效
+
+	signKey   any // []byte、*rsa.PrivateKey 或 *ecdsa.PrivateKey，为nil时只能校验
+	verifyKey any // []byte、*rsa.PublicKey 或 *ecdsa.PublicKey
+}
+
+// NewHMACKey 创建HS256密钥
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: AlgHS256, signKey: secret, verifyKey: secret}
+}
+
+// NewRSAKey 创建RS256签名密钥
+func NewRSAKey(id string, priv *rsa.PrivateKey) *Key {
+	return &Key{ID: id, Algorithm: AlgRS256, signKey: priv, verifyKey: &priv.PublicKey}
+}
+
+// NewECKey 创建ES256签名密钥，只支持P-256曲线
+func NewECKey(id string, priv *ecdsa.PrivateKey) (*Key, error) {
+	if priv.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("jwt: key %s: ES256 requires a P-256 key", id)
+	}
+	return &Key{ID: id, Algorithm: AlgES256, signKey: priv, verifyKey: &priv.PublicKey}, nil
+}
+
+// NewPublicKey 创建只用于校验的RS256/ES256密钥
+func NewPublicKey(id string, pub any) (*Key, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: id, Algorithm: AlgRS256, verifyKey: pub}, nil
+	case *ecdsa.PublicKey:
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("jwt: key %s: ES256 requires a P-256 key", id)
+		}
+		return &Key{ID: id, Algorithm: AlgES256, verifyKey: pub}, nil
+	}
+	return nil, fmt.Errorf("jwt: key %s: unsupported public key %T", id, pub)
+}
+
+// CanSign 是否持有私钥（或HMAC密钥）
+func (k *Key) CanSign() bool {
+	return k.signKey != nil
+}
+
+func (k *Key) retired(now time.Time) bool {
+	return !k.NotAfter.IsZero() && now.After(k.NotAfter)
+}
+
+// LoadKey 按配置读取密钥文件
+func LoadKey(c KeyConfig) (*Key, error) {
+	var key *Key
+	switch c.Algorithm {
+	case "", AlgHS256:
+		if c.Secret == "" {
+			return nil, fmt.Errorf("jwt: key %s: HS256 requires a Secret", c.ID)
+		}
+		key = NewHMACKey(c.ID, []byte(c.Secret))
+	case AlgRS256, AlgES256:
+		var err error
+		if key, err = loadAsymmetricKey(c); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("jwt: key %s: unsupported algorithm %q", c.ID, c.Algorithm)
+	}
+	if c.NotAfter != "" {
+		t, err := time.Parse(time.RFC3339, c.NotAfter)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %s: invalid NotAfter: %w", c.ID, err)
+		}
+		key.NotAfter = t
+	}
+	return key, nil
+}
+
+func loadAsymmetricKey(c KeyConfig) (*Key, error) {
+	file := c.PrivateKeyFile
+	if file == "" {
+		file = c.PublicKeyFile
+	}
+	if file == "" {
+		return nil, fmt.Errorf("jwt: key %s: %s requires PrivateKeyFile or PublicKeyFile", c.ID, c.Algorithm)
+	}
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: key %s: %w", c.ID, err)
+	}
+	private := c.PrivateKeyFile != ""
+	switch {
+	case c.Algorithm == AlgRS256 && private:
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %s: %w", c.ID, err)
+		}
+		return NewRSAKey(c.ID, priv), nil
+	case c.Algorithm == AlgRS256:
+		pub, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %s: %w", c.ID, err)
+		}
+		return NewPublicKey(c.ID, pub)
+	case private:
+		priv, err := jwt.ParseECPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %s: %w", c.ID, err)
+		}
+		return NewECKey(c.ID, priv)
+	default:
+		pub, err := jwt.ParseECPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %s: %w", c.ID, err)
+		}
+		return NewPublicKey(c.ID, pub)
+	}
+}
+
+// KeySet 按kid选择密钥的密钥集，可并发使用
+type KeySet struct {
+	mu     sync.RWMutex
+	active *Key // nil 时只能校验
+	keys   map[string]*Key
+}
+
+// NewKeySet 创建密钥集，active用于签名（可为nil），others只用于校验
+func NewKeySet(active *Key, others ...*Key) (*KeySet, error) {
+	s := &KeySet{keys: make(map[string]*Key)}
+	for _, key := range append([]*Key{active}, others...) {
+		if key == nil {
+			continue
+		}
+		if _, ok := s.keys[key.ID]; ok {
+			return nil, fmt.Errorf("jwt: duplicate key id %q", key.ID)
+		}
+		s.keys[key.ID] = key
+	}
+	if active != nil {
+		if !active.CanSign() {
+			return nil, fmt.Errorf("jwt: key %s: %w", active.ID, ErrVerifyOnlyKey)
+		}
+		s.active = active
+	}
+	return s, nil
+}
+
+// LoadKeySet 按配置创建密钥集
+// 没有配置Keys时legacySecret即签名密钥，与只有AccessSecret时的行为一致；
+// 配置了Keys时legacySecret只用于校验没有kid的旧令牌
+func LoadKeySet(c KeySetConfig, legacySecret string) (*KeySet, error) {
+	if len(c.Keys) == 0 {
+		if legacySecret == "" {
+			return nil, ErrNoSigningKey
+		}
+		return NewKeySet(NewHMACKey("", []byte(legacySecret)))
+	}
+	var active *Key
+	var others []*Key
+	for _, kc := range c.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("jwt: configured keys need an ID")
+		}
+		key, err := LoadKey(kc)
+		if err != nil {
+			return nil, err
+		}
+		if kc.ID == c.Active {
+			active = key
+			continue
+		}
+		others = append(others, key)
+	}
+	if c.Active != "" && active == nil {
+		return nil, fmt.Errorf("jwt: active key %q not configured", c.Active)
+	}
+	if legacySecret != "" {
+		legacy := NewHMACKey("", []byte(legacySecret))
+		if c.LegacyNotAfter != "" {
+			t, err := time.Parse(time.RFC3339, c.LegacyNotAfter)
+			if err != nil {
+				return nil, fmt.Errorf("jwt: invalid LegacyNotAfter: %w", err)
+			}
+			legacy.NotAfter = t
+		}
+		others = append(others, legacy)
+	}
+	return NewKeySet(active, others...)
+}
+
+// Active 返回当前签名密钥
+func (s *KeySet) Active() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.active
+}
+
+// Rotate 以next为签名密钥，原签名密钥在grace内仍可校验；同时清理已过期的密钥
+// next可以是已在密钥集中只用于校验的密钥，先发布公钥、再切换签名可避免其他服务拒绝新令牌
+func (s *KeySet) Rotate(next *Key, grace time.Duration) error {
+	if !next.CanSign() {
+		return fmt.Errorf("jwt: key %s: %w", next.ID, ErrVerifyOnlyKey)
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev := s.active; prev != nil && prev.ID != next.ID {
+		// 共享的Key可能在别处使用，以副本记录退役时间
+		retired := *prev
+		if retired.NotAfter.IsZero() || now.Add(grace).Before(retired.NotAfter) {
+			retired.NotAfter = now.Add(grace)
+		}
+		s.keys[prev.ID] = &retired
+	}
+	for id, key := range s.keys {
+		if key.retired(now) {
+			delete(s.keys, id)
+		}
+	}
+	s.keys[next.ID] = next
+	s.active = next
+	return nil
+}
+
+// Sign 用签名密钥签发令牌，头部带上kid
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := s.Active()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	return token.SignedString(key.signKey)
+}
+
+// Parse 按kid选取密钥校验令牌并解析到claims
+func (s *KeySet) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, s.keyFunc,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgES256}))
+}
+
+func (s *KeySet) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if key.retired(time.Now()) {
+		return nil, ErrKeyRetired
+	}
+	// 只接受密钥自身的算法，防止用公钥当HMAC密钥伪造令牌
+	if token.Method.Alg() != key.Algorithm {
+		return nil, ErrKeyAlgorithm
+	}
+	return key.verifyKey, nil
+}
+
+// GenAccessToken 用签名密钥签发有效期为ttl的访问令牌
+func (s *KeySet) GenAccessToken(payload JwtPayLoad, ttl time.Duration) (string, error) {
+	return s.Sign(accessClaims(payload, ttl))
+}
+
+// GenServiceToken 用签名密钥签发服务账号的访问令牌
+func (s *KeySet) GenServiceToken(payload JwtPayLoad, ttl time.Duration, scope string) (string, error) {
+	payload.Scope = scope
+	return s.GenAccessToken(payload, ttl)
+}
+
+// ParseToken 校验并解析访问令牌，刷新令牌返回ErrTokenType
+func (s *KeySet) ParseToken(tokenStr string) (*CustomClaims, error) {
+	token, err := s.Parse(tokenStr, &CustomClaims{})
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(*CustomClaims)
+	if !ok || !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	if claims.Type == TokenTypeRefresh {
+		return nil, ErrTokenType
+	}
+	return claims, nil
+}
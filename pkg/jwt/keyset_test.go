@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+func TestKeySetAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate rsa key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ec key: %v", err)
+	}
+	es, err := NewECKey("es-1", ecKey)
+	if err != nil {
+		t.Fatalf("Failed to create ec key: %v", err)
+	}
+
+	for _, key := range []*Key{NewHMACKey("hs-1", []byte("secret")), NewRSAKey("rs-1", rsaKey), es} {
+		set, err := NewKeySet(key)
+		if err != nil {
+			t.Fatalf("Failed to create key set: %v", err)
+		}
+		token, err := set.GenAccessToken(JwtPayLoad{UUID: "u-1"}, time.Hour)
+		if err != nil {
+			t.Fatalf("%s: failed to sign: %v", key.Algorithm, err)
+		}
+		claims, err := set.ParseToken(token)
+		if err != nil || claims.UUID != "u-1" || claims.ID == "" {
+			t.Fatalf("%s: unexpected claims %+v: %v", key.Algorithm, claims, err)
+		}
+		parsed, _ := jwt.Parse(token, nil)
+		if parsed == nil || parsed.Header["kid"] != key.ID || parsed.Method.Alg() != key.Algorithm {
+			t.Errorf("%s: expected kid %s in header, got %v", key.Algorithm, key.ID, parsed)
+		}
+	}
+}
+
+func TestKeySetRejectsAlgorithmConfusion(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	set, _ := NewKeySet(NewRSAKey("rs-1", rsaKey))
+
+	// HS256 signed with the public modulus as secret, claiming the rsa kid
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, accessClaims(JwtPayLoad{UUID: "admin"}, time.Hour))
+	forged.Header["kid"] = "rs-1"
+	token, _ := forged.SignedString(rsaKey.PublicKey.N.Bytes())
+	if _, err := set.ParseToken(token); !errors.Is(err, ErrKeyAlgorithm) {
+		t.Errorf("Expected ErrKeyAlgorithm, got %v", err)
+	}
+
+	other := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims(JwtPayLoad{UUID: "u-1"}, time.Hour))
+	other.Header["kid"] = "rs-2"
+	token, _ = other.SignedString(rsaKey)
+	if _, err := set.ParseToken(token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	set, _ := NewKeySet(NewHMACKey("k1", []byte("one")))
+	old, _ := set.GenAccessToken(JwtPayLoad{UUID: "u-1"}, time.Hour)
+
+	if err := set.Rotate(NewHMACKey("k2", []byte("two")), time.Hour); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	fresh, _ := set.GenAccessToken(JwtPayLoad{UUID: "u-1"}, time.Hour)
+	if parsed, _ := jwt.Parse(fresh, nil); parsed == nil || parsed.Header["kid"] != "k2" {
+		t.Errorf("Expected new tokens to be signed with k2")
+	}
+	if _, err := set.ParseToken(old); err != nil {
+		t.Errorf("Expected the old key to be accepted during the grace period, got %v", err)
+	}
+
+	if err := set.Rotate(NewHMACKey("k3", []byte("three")), -time.Second); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	// k2 had no grace and is dropped, k1 is still within its hour
+	if _, err := set.ParseToken(fresh); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected k2 to be dropped, got %v", err)
+	}
+	if _, err := set.ParseToken(old); err != nil {
+		t.Errorf("Expected k1 to be accepted within its grace period, got %v", err)
+	}
+}
+
+func TestLoadKeySetAcceptsLegacyTokens(t *testing.T) {
+	legacy, _ := GenAccessToken(JwtPayLoad{UUID: "u-1"}, "legacy", time.Hour)
+
+	set, err := LoadKeySet(KeySetConfig{
+		Active: "k1",
+		Keys:   []KeyConfig{{ID: "k1", Algorithm: AlgHS256, Secret: "new"}},
+	}, "legacy")
+	if err != nil {
+		t.Fatalf("Failed to load key set: %v", err)
+	}
+	if _, err := set.ParseToken(legacy); err != nil {
+		t.Errorf("Expected tokens without kid to be accepted, got %v", err)
+	}
+
+	set, _ = LoadKeySet(KeySetConfig{
+		Active:         "k1",
+		Keys:           []KeyConfig{{ID: "k1", Algorithm: AlgHS256, Secret: "new"}},
+		LegacyNotAfter: time.Now().Add(-time.Minute).Format(time.RFC3339),
+	}, "legacy")
+	if _, err := set.ParseToken(legacy); !errors.Is(err, ErrKeyRetired) {
+		t.Errorf("Expected legacy tokens to be rejected after LegacyNotAfter, got %v", err)
+	}
+
+	if _, err := LoadKeySet(KeySetConfig{Active: "missing", Keys: []KeyConfig{{ID: "k1", Secret: "x"}}}, ""); err == nil {
+		t.Error("Expected an unknown active key to fail")
+	}
+}
+
+func TestJWKSRoundTrip(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	es, _ := NewECKey("es-1", ecKey)
+	signer, _ := NewKeySet(NewRSAKey("rs-1", rsaKey), es, NewHMACKey("hs-1", []byte("secret")))
+
+	data, err := json.Marshal(signer.JWKS())
+	if err != nil {
+		t.Fatalf("Failed to marshal jwks: %v", err)
+	}
+	verifier, err := ParseJWKS(data)
+	if err != nil {
+		t.Fatalf("Failed to parse jwks: %v", err)
+	}
+	if n := len(verifier.JWKS().Keys); n != 2 {
+		t.Errorf("Expected only the rsa and ec keys to be published, got %d", n)
+	}
+	if _, err := verifier.GenAccessToken(JwtPayLoad{UUID: "u-1"}, time.Hour); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("Expected a jwks key set not to sign, got %v", err)
+	}
+
+	token, _ := signer.GenAccessToken(JwtPayLoad{UUID: "u-1"}, time.Hour)
+	if claims, err := verifier.ParseToken(token); err != nil || claims.UUID != "u-1" {
+		t.Errorf("Expected the jwks to verify rs256 tokens, got %+v: %v", claims, err)
+	}
+	if err := signer.Rotate(es, time.Hour); err != nil {
+		t.Fatalf("Failed to rotate: %v", err)
+	}
+	token, _ = signer.GenAccessToken(JwtPayLoad{UUID: "u-2"}, time.Hour)
+	if claims, err := verifier.ParseToken(token); err != nil || claims.UUID != "u-2" {
+		t.Errorf("Expected the jwks to verify es256 tokens, got %+v: %v", claims, err)
+	}
+}
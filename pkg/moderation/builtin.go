@@ -0,0 +1,289 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 内置拦截器名称
+const (
+	NameSize      = "size"
+	NameType      = "type"
+	NameProfanity = "profanity"
+	NameSpam      = "spam"
+)
+
+// sizeValidator 校验内容大小，拒绝空内容和超过限制的内容
+type sizeValidator struct {
+	maxBytes int
+}
+
+// NewSizeValidator 创建大小校验器，maxBytes为0时只拒绝空内容；策略的MaxContentBytes优先
+func NewSizeValidator(maxBytes int) Interceptor {
+	return &sizeValidator{maxBytes: maxBytes}
+}
+
+func (v *sizeValidator) Name() string { return NameSize }
+
+func (v *sizeValidator) Intercept(ctx context.Context, msg *Message, policy *Policy) error {
+	if len(msg.Content) == 0 {
+		return Reject(ReasonEmpty, "content is empty")
+	}
+	limit := v.maxBytes
+	if policy.MaxContentBytes > 0 {
+		limit = policy.MaxContentBytes
+	}
+	if limit > 0 && len(msg.Content) > limit {
+		return Reject(ReasonTooLarge, "%d bytes exceeds the limit of %d", len(msg.Content), limit)
+	}
+	return nil
+}
+
+// typeValidator 只允许指定的消息类型
+type typeValidator struct {
+	allowed []uint32
+}
+
+// NewTypeValidator 创建类型校验器，allowed为空时不限制；策略的AllowedTypes优先
+func NewTypeValidator(allowed ...uint32) Interceptor {
+	return &typeValidator{allowed: allowed}
+}
+
+func (v *typeValidator) Name() string { return NameType }
+
+func (v *typeValidator) Intercept(ctx context.Context, msg *Message, policy *Policy) error {
+	allowed := v.allowed
+	if len(policy.AllowedTypes) > 0 {
+		allowed = policy.AllowedTypes
+	}
+	if msg.Type == 0 || len(allowed) == 0 {
+		return nil
+	}
+	for _, msgType := range allowed {
+		if msgType == msg.Type {
+			return nil
+		}
+	}
+	return Reject(ReasonType, "message type %d is not allowed", msg.Type)
+}
+
+// profanityFilter 把敏感词替换为*，策略要求时拒绝消息
+type profanityFilter struct {
+	words [][]rune // 小写形式
+}
+
+// NewProfanityFilter 创建敏感词过滤器，匹配不区分大小写
+func NewProfanityFilter(words []string) Interceptor {
+	f := &profanityFilter{}
+	for _, word := range words {
+		if word == "" {
+			continue
+		}
+		runes := []rune(word)
+		for i, r := range runes {
+			runes[i] = unicode.ToLower(r)
+		}
+		f.words = append(f.words, runes)
+	}
+	return f
+}
+
+func (f *profanityFilter) Name() string { return NameProfanity }
+
+func (f *profanityFilter) Intercept(ctx context.Context, msg *Message, policy *Policy) error {
+	if len(f.words) == 0 || !utf8.Valid(msg.Content) {
+		return nil
+	}
+	content := []rune(string(msg.Content))
+	lower := make([]rune, len(content))
+	for i, r := range content {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	masked := make([]bool, len(content))
+	found := 0
+	for _, word := range f.words {
+		for start := 0; start+len(word) <= len(lower); start++ {
+			if !runesEqual(lower[start:start+len(word)], word) {
+				continue
+			}
+			found++
+			for i := start; i < start+len(word); i++ {
+				masked[i] = true
+			}
+		}
+	}
+	if found == 0 {
+		return nil
+	}
+	if policy.RejectProfanity {
+		return Reject(ReasonProfanity, "content contains %d blocked words", found)
+	}
+	for i := range content {
+		if masked[i] {
+			content[i] = '*'
+		}
+	}
+	// 赋值新的切片，调用方据此判断内容是否被改写
+	msg.Content = []byte(string(content))
+	return nil
+}
+
+func runesEqual(a, b []rune) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// SpamConfig 垃圾消息过滤配置，按发送者在每个会话中分别统计
+type SpamConfig struct {
+	Window        time.Duration // 统计窗口
+	MaxMessages   int           // 窗口内最多发送的消息数，0表示不限制
+	MaxDuplicates int           // 窗口内相同内容最多发送的次数，0表示不限制
+}
+
+// spamFilter 按发送者与会话限制窗口内的消息数和重复内容
+type spamFilter struct {
+	config  SpamConfig
+	mu      sync.Mutex
+	senders map[string][]spamRecord // 会话ID/发送者ID -> 窗口内的记录
+	checked int                     // 上次清理后的检查次数
+	now     func() time.Time
+}
+
+type spamRecord struct {
+	at   time.Time
+	hash uint64
+}
+
+// spamSweepEvery 每检查这么多条消息清理一次不再活跃的发送者
+const spamSweepEvery = 1024
+
+// NewSpamFilter 创建垃圾消息过滤器
+func NewSpamFilter(config SpamConfig) Interceptor {
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	return &spamFilter{config: config, senders: make(map[string][]spamRecord), now: time.Now}
+}
+
+func (f *spamFilter) Name() string { return NameSpam }
+
+func (f *spamFilter) Intercept(ctx context.Context, msg *Message, policy *Policy) error {
+	hasher := fnv.New64a()
+	hasher.Write(msg.Content)
+	hash := hasher.Sum64()
+	key := msg.ConversationID + "/" + msg.SenderID
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := f.now()
+	cutoff := now.Add(-f.config.Window)
+	if f.checked++; f.checked >= spamSweepEvery {
+		f.sweep(cutoff)
+	}
+
+	records := f.senders[key]
+	kept := records[:0]
+	duplicates := 0
+	for _, record := range records {
+		if record.at.After(cutoff) {
+			kept = append(kept, record)
+			if record.hash == hash {
+				duplicates++
+			}
+		}
+	}
+	f.senders[key] = kept
+
+	if f.config.MaxMessages > 0 && len(kept) >= f.config.MaxMessages {
+		return Reject(ReasonSpam, "more than %d messages in %s", f.config.MaxMessages, f.config.Window)
+	}
+	if f.config.MaxDuplicates > 0 && duplicates >= f.config.MaxDuplicates {
+		return Reject(ReasonSpam, "same content sent %d times in %s", duplicates+1, f.config.Window)
+	}
+	f.senders[key] = append(kept, spamRecord{at: now, hash: hash})
+	return nil
+}
+
+// sweep 删除窗口内没有记录的发送者，调用方持有mu
+func (f *spamFilter) sweep(cutoff time.Time) {
+	f.checked = 0
+	for key, records := range f.senders {
+		if len(records) == 0 || !records[len(records)-1].at.After(cutoff) {
+			delete(f.senders, key)
+		}
+	}
+}
+
+// ConversationPolicy 配置文件中单个会话的策略
+type ConversationPolicy struct {
+	ConversationID  string   `json:",optional"`
+	Disabled        bool     `json:",optional"`
+	Sync            []string `json:",optional"`
+	Async           []string `json:",optional"`
+	MaxContentBytes int      `json:",optional"`
+	AllowedTypes    []uint32 `json:",optional"`
+	RejectProfanity bool     `json:",optional"`
+}
+
+// Config 审核配置，Enabled为false时New返回nil，即不审核
+type Config struct {
+	Enabled         bool     `json:",optional"`
+	MaxContentBytes int      `json:",optional"` // 0表示只拒绝空内容
+	AllowedTypes    []uint32 `json:",optional"` // 为空时不限制
+	ProfanityWords  []string `json:",optional"`
+	RejectProfanity bool     `json:",optional"` // 默认打码
+
+	SpamWindow        time.Duration `json:",default=1m"`
+	SpamMaxMessages   int           `json:",optional"`
+	SpamMaxDuplicates int           `json:",optional"`
+
+	// Async 写入后才执行的内置拦截器，如["profanity"]
+	Async         []string             `json:",optional"`
+	Conversations []ConversationPolicy `json:",optional"`
+}
+
+// New 按配置创建拦截链，注册全部内置拦截器
+func New(c Config) (*Pipeline, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	p := NewPipeline(
+		NewSizeValidator(c.MaxContentBytes),
+		NewTypeValidator(c.AllowedTypes...),
+		NewSpamFilter(SpamConfig{Window: c.SpamWindow, MaxMessages: c.SpamMaxMessages, MaxDuplicates: c.SpamMaxDuplicates}),
+		NewProfanityFilter(c.ProfanityWords),
+	)
+	if err := p.SetDefaultPolicy(Policy{Async: c.Async, RejectProfanity: c.RejectProfanity}); err != nil {
+		return nil, err
+	}
+	for _, conv := range c.Conversations {
+		if conv.ConversationID == "" {
+			return nil, fmt.Errorf("moderation: conversation policy without ConversationID")
+		}
+		policy := Policy{
+			Disabled:        conv.Disabled,
+			Sync:            conv.Sync,
+			Async:           conv.Async,
+			MaxContentBytes: conv.MaxContentBytes,
+			AllowedTypes:    conv.AllowedTypes,
+			RejectProfanity: conv.RejectProfanity,
+		}
+		if policy.Async == nil {
+			policy.Async = c.Async
+		}
+		if err := p.SetPolicy(conv.ConversationID, policy); err != nil {
+			return nil, fmt.Errorf("conversation %s: %w", conv.ConversationID, err)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,362 @@
+// Package moderation 提供消息写入前后的审核拦截链，用于垃圾消息过滤、敏感词打码以及大小和类型校验
+// 同步拦截器在消息写入前依次执行，可以拒绝消息或改写内容；异步拦截器在消息写入后于后台执行，
+// 只能发现违规，由调用方处理（如撤回消息）。每个会话可以设置自己的策略，未设置时使用默认策略
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRejected 消息被审核拒绝，具体原因见Rejection
+var ErrRejected = errors.New("moderation: message rejected")
+
+// asyncTimeout 单条消息异步审核的最长时间
+const asyncTimeout = 30 * time.Second
+
+// Reason 拒绝原因，调用方据此向客户端返回不同的错误码
+type Reason string
+
+const (
+	ReasonSpam      Reason = "spam"             // 发送过于频繁或重复内容
+	ReasonProfanity Reason = "profanity"        // 包含敏感词
+	ReasonTooLarge  Reason = "too_large"        // 内容超过大小限制
+	ReasonType      Reason = "type_not_allowed" // 消息类型不允许
+	ReasonEmpty     Reason = "empty"            // 内容为空
+)
+
+// Rejection 审核拒绝的详细信息
+type Rejection struct {
+	Interceptor string // 拒绝消息的拦截器
+	Reason      Reason
+	Detail      string
+}
+
+func (r *Rejection) Error() string {
+	if r.Detail != "" {
+		return fmt.Sprintf("moderation: message rejected by %s (%s): %s", r.Interceptor, r.Reason, r.Detail)
+	}
+	return fmt.Sprintf("moderation: message rejected by %s (%s)", r.Interceptor, r.Reason)
+}
+
+// Is 使errors.Is(err, ErrRejected)对所有Rejection成立
+func (r *Rejection) Is(target error) bool {
+	return target == ErrRejected
+}
+
+// Reject 创建拒绝，Interceptor由Pipeline填写
+func Reject(reason Reason, format string, args ...interface{}) *Rejection {
+	return &Rejection{Reason: reason, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Message 待审核的消息，同步拦截器可以修改Content
+type Message struct {
+	ID             string // 写入后的消息ID，同步审核时为空
+	ConversationID string
+	SenderID       string
+	Type           uint32 // 业务消息类型，0表示未知，不做类型校验
+	Content        []byte
+	Mentions       []string
+}
+
+// Interceptor 审核拦截器
+type Interceptor interface {
+	// Name 拦截器名称，用于策略配置与统计
+	Name() string
+	// Intercept 审核消息，返回*Rejection表示拒绝，返回其他错误时消息同样不能写入
+	Intercept(ctx context.Context, msg *Message, policy *Policy) error
+}
+
+// funcInterceptor 由函数实现的拦截器
+type funcInterceptor struct {
+	name string
+	fn   func(ctx context.Context, msg *Message, policy *Policy) error
+}
+
+func (f *funcInterceptor) Name() string { return f.name }
+
+func (f *funcInterceptor) Intercept(ctx context.Context, msg *Message, policy *Policy) error {
+	return f.fn(ctx, msg, policy)
+}
+
+// NewInterceptor 用函数创建拦截器
+func NewInterceptor(name string, fn func(ctx context.Context, msg *Message, policy *Policy) error) Interceptor {
+	return &funcInterceptor{name: name, fn: fn}
+}
+
+// Policy 会话的审核策略
+type Policy struct {
+	Disabled bool `json:"disabled,omitempty"` // 不审核该会话的消息
+	// Sync 写入前执行的拦截器，为nil时执行所有已注册且不在Async中的拦截器
+	Sync []string `json:"sync,omitempty"`
+	// Async 写入后异步执行的拦截器
+	Async []string `json:"async,omitempty"`
+
+	MaxContentBytes int      `json:"max_content_bytes,omitempty"` // 覆盖大小校验器的默认限制
+	AllowedTypes    []uint32 `json:"allowed_types,omitempty"`     // 覆盖类型校验器的默认类型
+	RejectProfanity bool     `json:"reject_profanity,omitempty"`  // 包含敏感词时拒绝而不是打码
+}
+
+// Stats 审核统计
+type Stats struct {
+	Checked         int64            `json:"checked"`          // 同步审核的消息数
+	Rejected        int64            `json:"rejected"`         // 同步审核拒绝的消息数
+	Modified        int64            `json:"modified"`         // 内容被改写的消息数
+	Errors          int64            `json:"errors"`           // 拦截器返回非拒绝错误的次数
+	AsyncChecked    int64            `json:"async_checked"`    // 异步审核的消息数
+	AsyncViolations int64            `json:"async_violations"` // 异步审核发现违规的消息数
+	ByReason        map[Reason]int64 `json:"by_reason"`        // 按原因统计的拒绝与违规
+	ByInterceptor   map[string]int64 `json:"by_interceptor"`   // 按拦截器统计的拒绝与违规
+}
+
+// Pipeline 审核拦截链，并发安全，nil表示不审核
+type Pipeline struct {
+	mu            sync.RWMutex
+	interceptors  map[string]Interceptor
+	order         []string // 注册顺序，策略未指定Sync时按此顺序执行
+	defaultPolicy Policy
+	policies      map[string]Policy // 会话ID -> 策略
+
+	statsMu sync.Mutex
+	stats   Stats
+
+	async sync.WaitGroup
+}
+
+// NewPipeline 创建拦截链，按参数顺序注册拦截器
+func NewPipeline(interceptors ...Interceptor) *Pipeline {
+	p := &Pipeline{
+		interceptors: make(map[string]Interceptor),
+		policies:     make(map[string]Policy),
+		stats: Stats{
+			ByReason:      make(map[Reason]int64),
+			ByInterceptor: make(map[string]int64),
+		},
+	}
+	for _, interceptor := range interceptors {
+		p.Use(interceptor)
+	}
+	return p
+}
+
+// Use 注册拦截器，同名拦截器会被替换并保留原来的位置
+func (p *Pipeline) Use(interceptor Interceptor) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	name := interceptor.Name()
+	if _, exists := p.interceptors[name]; !exists {
+		p.order = append(p.order, name)
+	}
+	p.interceptors[name] = interceptor
+}
+
+// SetDefaultPolicy 设置未单独配置的会话使用的策略
+func (p *Pipeline) SetDefaultPolicy(policy Policy) error {
+	if err := p.validatePolicy(policy); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.defaultPolicy = policy
+	return nil
+}
+
+// SetPolicy 设置会话的审核策略
+func (p *Pipeline) SetPolicy(conversationID string, policy Policy) error {
+	if err := p.validatePolicy(policy); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.policies[conversationID] = policy
+	return nil
+}
+
+// RemovePolicy 删除会话的审核策略，之后使用默认策略
+func (p *Pipeline) RemovePolicy(conversationID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.policies, conversationID)
+}
+
+// Policy 获取会话生效的审核策略
+func (p *Pipeline) Policy(conversationID string) Policy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if policy, exists := p.policies[conversationID]; exists {
+		return policy
+	}
+	return p.defaultPolicy
+}
+
+// validatePolicy 策略引用的拦截器必须已注册
+func (p *Pipeline) validatePolicy(policy Policy) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, names := range [][]string{policy.Sync, policy.Async} {
+		for _, name := range names {
+			if _, exists := p.interceptors[name]; !exists {
+				return fmt.Errorf("moderation: unknown interceptor %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// chains 按策略解析同步与异步拦截器
+func (p *Pipeline) chains(policy *Policy) (syncChain, asyncChain []Interceptor) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	asyncNames := make(map[string]bool, len(policy.Async))
+	for _, name := range policy.Async {
+		asyncNames[name] = true
+		asyncChain = append(asyncChain, p.interceptors[name])
+	}
+	syncNames := policy.Sync
+	if syncNames == nil {
+		syncNames = p.order
+	}
+	for _, name := range syncNames {
+		if !asyncNames[name] {
+			syncChain = append(syncChain, p.interceptors[name])
+		}
+	}
+	return syncChain, asyncChain
+}
+
+// Moderate 写入前同步审核消息，可能改写msg.Content
+// 被拒绝时返回*Rejection，拦截器出错时返回其错误，两种情况消息都不应写入
+func (p *Pipeline) Moderate(ctx context.Context, msg *Message) error {
+	if p == nil {
+		return nil
+	}
+	policy := p.Policy(msg.ConversationID)
+	if policy.Disabled {
+		return nil
+	}
+	interceptors, _ := p.chains(&policy)
+	original := msg.Content
+
+	p.statsMu.Lock()
+	p.stats.Checked++
+	p.statsMu.Unlock()
+	for _, interceptor := range interceptors {
+		if err := p.run(ctx, interceptor, msg, &policy); err != nil {
+			var rejection *Rejection
+			if errors.As(err, &rejection) {
+				p.record(rejection, false)
+			} else {
+				p.statsMu.Lock()
+				p.stats.Errors++
+				p.statsMu.Unlock()
+			}
+			return err
+		}
+	}
+	if !bytes.Equal(original, msg.Content) {
+		p.statsMu.Lock()
+		p.stats.Modified++
+		p.statsMu.Unlock()
+	}
+	return nil
+}
+
+// ModerateAsync 在后台对已写入的消息执行策略中的异步拦截器
+// 发现违规时调用onViolation，拦截器的其他错误只计入统计
+func (p *Pipeline) ModerateAsync(msg Message, onViolation func(msg Message, rejection *Rejection)) {
+	if p == nil {
+		return
+	}
+	policy := p.Policy(msg.ConversationID)
+	if policy.Disabled {
+		return
+	}
+	_, interceptors := p.chains(&policy)
+	if len(interceptors) == 0 {
+		return
+	}
+	msg.Content = append([]byte(nil), msg.Content...)
+
+	p.async.Add(1)
+	go func() {
+		defer p.async.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), asyncTimeout)
+		defer cancel()
+
+		p.statsMu.Lock()
+		p.stats.AsyncChecked++
+		p.statsMu.Unlock()
+		for _, interceptor := range interceptors {
+			// 异步拦截器不能改写已写入的内容，使用副本
+			checked := msg
+			err := p.run(ctx, interceptor, &checked, &policy)
+			var rejection *Rejection
+			if errors.As(err, &rejection) {
+				p.record(rejection, true)
+				if onViolation != nil {
+					onViolation(msg, rejection)
+				}
+				return
+			}
+			if err != nil {
+				p.statsMu.Lock()
+				p.stats.Errors++
+				p.statsMu.Unlock()
+			}
+		}
+	}()
+}
+
+// Wait 等待进行中的异步审核结束
+func (p *Pipeline) Wait() {
+	if p == nil {
+		return
+	}
+	p.async.Wait()
+}
+
+// run 执行拦截器，拒绝信息中补充拦截器名称
+func (p *Pipeline) run(ctx context.Context, interceptor Interceptor, msg *Message, policy *Policy) error {
+	err := interceptor.Intercept(ctx, msg, policy)
+	var rejection *Rejection
+	if errors.As(err, &rejection) && rejection.Interceptor == "" {
+		rejection.Interceptor = interceptor.Name()
+	}
+	return err
+}
+
+// record 统计拒绝或违规
+func (p *Pipeline) record(rejection *Rejection, async bool) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	if async {
+		p.stats.AsyncViolations++
+	} else {
+		p.stats.Rejected++
+	}
+	p.stats.ByReason[rejection.Reason]++
+	p.stats.ByInterceptor[rejection.Interceptor]++
+}
+
+// Stats 获取审核统计
+func (p *Pipeline) Stats() Stats {
+	if p == nil {
+		return Stats{}
+	}
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	stats := p.stats
+	stats.ByReason = make(map[Reason]int64, len(p.stats.ByReason))
+	for reason, count := range p.stats.ByReason {
+		stats.ByReason[reason] = count
+	}
+	stats.ByInterceptor = make(map[string]int64, len(p.stats.ByInterceptor))
+	for name, count := range p.stats.ByInterceptor {
+		stats.ByInterceptor[name] = count
+	}
+	return stats
+}
@@ -0,0 +1,166 @@
+package moderation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipelineMasksAndRejects(t *testing.T) {
+	ctx := context.Background()
+	p, err := New(Config{Enabled: true, MaxContentBytes: 16, AllowedTypes: []uint32{1}, ProfanityWords: []string{"Bad"}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+
+	msg := &Message{ConversationID: "c1", SenderID: "u1", Type: 1, Content: []byte("so BAD, bad")}
+	if err := p.Moderate(ctx, msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(msg.Content) != "so ***, ***" {
+		t.Errorf("Unexpected masked content %q", msg.Content)
+	}
+
+	cases := []struct {
+		msg    *Message
+		reason Reason
+	}{
+		{&Message{ConversationID: "c1", SenderID: "u1", Type: 1}, ReasonEmpty},
+		{&Message{ConversationID: "c1", SenderID: "u1", Type: 1, Content: []byte("this is far too long")}, ReasonTooLarge},
+		{&Message{ConversationID: "c1", SenderID: "u1", Type: 2, Content: []byte("image")}, ReasonType},
+	}
+	for _, c := range cases {
+		err := p.Moderate(ctx, c.msg)
+		var rejection *Rejection
+		if !errors.Is(err, ErrRejected) || !errors.As(err, &rejection) || rejection.Reason != c.reason {
+			t.Errorf("Expected %s rejection, got %v", c.reason, err)
+		}
+	}
+
+	stats := p.Stats()
+	if stats.Checked != 4 || stats.Rejected != 3 || stats.Modified != 1 || stats.ByReason[ReasonType] != 1 || stats.ByInterceptor[NameSize] != 2 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestConversationPolicy(t *testing.T) {
+	ctx := context.Background()
+	p, err := New(Config{
+		Enabled:        true,
+		ProfanityWords: []string{"bad"},
+		Conversations: []ConversationPolicy{
+			{ConversationID: "strict", RejectProfanity: true},
+			{ConversationID: "open", Disabled: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+
+	err = p.Moderate(ctx, &Message{ConversationID: "strict", Content: []byte("bad")})
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || rejection.Reason != ReasonProfanity || rejection.Interceptor != NameProfanity {
+		t.Errorf("Expected a profanity rejection, got %v", err)
+	}
+	msg := &Message{ConversationID: "open", Content: []byte("bad")}
+	if err := p.Moderate(ctx, msg); err != nil || string(msg.Content) != "bad" {
+		t.Errorf("Expected a disabled policy to skip moderation, got %v %q", err, msg.Content)
+	}
+
+	p.RemovePolicy("strict")
+	msg = &Message{ConversationID: "strict", Content: []byte("bad")}
+	if err := p.Moderate(ctx, msg); err != nil || string(msg.Content) != "***" {
+		t.Errorf("Expected the default policy to mask, got %v %q", err, msg.Content)
+	}
+
+	if err := p.SetPolicy("c1", Policy{Sync: []string{"missing"}}); err == nil {
+		t.Errorf("Expected an unknown interceptor to be rejected")
+	}
+	if _, err := New(Config{Enabled: true, Async: []string{"missing"}}); err == nil {
+		t.Errorf("Expected an unknown async interceptor to be rejected")
+	}
+}
+
+func TestSpamFilter(t *testing.T) {
+	ctx := context.Background()
+	filter := NewSpamFilter(SpamConfig{Window: time.Minute, MaxMessages: 3, MaxDuplicates: 1}).(*spamFilter)
+	now := time.Unix(1000, 0)
+	filter.now = func() time.Time { return now }
+	p := NewPipeline(filter)
+
+	send := func(sender, content string) error {
+		return p.Moderate(ctx, &Message{ConversationID: "c1", SenderID: sender, Content: []byte(content)})
+	}
+	if err := send("u1", "hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := send("u1", "hello"); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected a duplicate to be rejected, got %v", err)
+	}
+	for _, content := range []string{"a", "b"} {
+		if err := send("u1", content); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	if err := send("u1", "c"); !errors.Is(err, ErrRejected) {
+		t.Errorf("Expected the rate limit to reject, got %v", err)
+	}
+	if err := send("u2", "c"); err != nil {
+		t.Errorf("Expected other senders to be unaffected, got %v", err)
+	}
+
+	now = now.Add(time.Minute)
+	if err := send("u1", "hello"); err != nil {
+		t.Errorf("Expected the window to expire, got %v", err)
+	}
+}
+
+func TestModerateAsync(t *testing.T) {
+	var checked []string
+	var mu sync.Mutex
+	slow := NewInterceptor("slow", func(ctx context.Context, msg *Message, policy *Policy) error {
+		mu.Lock()
+		checked = append(checked, string(msg.Content))
+		mu.Unlock()
+		if string(msg.Content) == "spam" {
+			return Reject(ReasonSpam, "flagged")
+		}
+		return nil
+	})
+	p := NewPipeline(NewSizeValidator(0), slow)
+	if err := p.SetDefaultPolicy(Policy{Async: []string{"slow"}}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+
+	var violations []string
+	onViolation := func(msg Message, rejection *Rejection) {
+		mu.Lock()
+		violations = append(violations, msg.ID+":"+rejection.Interceptor)
+		mu.Unlock()
+	}
+	for i, content := range []string{"fine", "spam"} {
+		msg := &Message{ID: string(rune('1' + i)), ConversationID: "c1", Content: []byte(content)}
+		// 异步拦截器不在同步链中执行
+		if err := p.Moderate(context.Background(), msg); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		p.ModerateAsync(*msg, onViolation)
+	}
+	p.Wait()
+
+	if len(checked) != 2 || len(violations) != 1 || violations[0] != "2:slow" {
+		t.Errorf("Unexpected async results %v %v", checked, violations)
+	}
+	if stats := p.Stats(); stats.AsyncChecked != 2 || stats.AsyncViolations != 1 || stats.Rejected != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	var nilPipeline *Pipeline
+	if err := nilPipeline.Moderate(context.Background(), &Message{}); err != nil {
+		t.Errorf("Expected a nil pipeline to accept, got %v", err)
+	}
+	nilPipeline.ModerateAsync(Message{}, onViolation)
+	nilPipeline.Wait()
+}
@@ -0,0 +1,711 @@
+package storage
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"imy/pkg/events"
+	"imy/pkg/moderation"
+)
+
+// TransactionLister 可列出活跃事务的事务协调器
+type TransactionLister interface {
+	ListActiveTransactions(ctx context.Context) ([]*DistributedTransaction, error)
+}
+
+// LockLister 可列出当前锁的锁管理器
+type LockLister interface {
+	ListLocks(ctx context.Context) ([]*LockInfo, error)
+}
+
+// AdminDependencies 管理接口依赖的集群组件，未提供的组件对应接口返回501
+type AdminDependencies struct {
+	Registry         StoreRegistry
+	GlobalIndex      GlobalIndexManager
+	ShardManager     ShardManager
+	MigrationManager MigrationManager
+	RouterManager    *RouterManager
+	Coordinator      TransactionCoordinator
+	LockManager      DistributedLockManager
+	ConnectionPool   *ConnectionPool
+	Metrics          *MetricsCollector
+	CircuitBreakers  *CircuitBreakerGroup
+	Store            *Store // 本节点的Store，用于租户与内容审核统计
+	// 设置后排空Store只能在协调者领导者上发起，其他节点返回409
+	LeaderElector LeaderElector
+}
+
+// AdminServer 存储集群管理HTTP服务，与RPC服务使用不同端口
+// 所有接口都要求 Authorization: Bearer <token>
+type AdminServer struct {
+	mu        sync.RWMutex
+	deps      AdminDependencies
+	token     string
+	server    *http.Server
+	tlsConfig *tls.Config
+	running   bool
+
+	// 迁移和自动重平衡在请求结束后继续运行，使用服务生命周期的上下文
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewAdminServer 创建管理服务
+func NewAdminServer(deps AdminDependencies, token string) *AdminServer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AdminServer{
+		deps:   deps,
+		token:  token,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// SetTLSConfig 设置TLS配置，需在Start之前调用
+func (s *AdminServer) SetTLSConfig(config *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsConfig = config
+}
+
+// Handler 返回带认证的路由
+func (s *AdminServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/stores", s.handleListStores)
+	mux.HandleFunc("GET /admin/stores/{id}/timelines", s.handleListTimelines)
+	mux.HandleFunc("POST /admin/stores/{id}/drain", s.handleDrainStore)
+	mux.HandleFunc("PUT /admin/stores/{id}/status", s.handleUpdateStoreStatus)
+	mux.HandleFunc("GET /admin/stats", s.handleShardStats)
+	mux.HandleFunc("GET /admin/stats/history", s.handleStatsHistory)
+	mux.HandleFunc("GET /admin/stats/rebalances", s.handleRebalanceReport)
+	mux.HandleFunc("GET /admin/metrics", s.handleMetrics)
+	mux.HandleFunc("GET /admin/tenants", s.handleListTenants)
+	mux.HandleFunc("GET /admin/migrations", s.handleListMigrations)
+	mux.HandleFunc("POST /admin/migrations", s.handleStartMigration)
+	mux.HandleFunc("GET /admin/migrations/{id}", s.handleGetMigration)
+	mux.HandleFunc("POST /admin/migrations/{id}/cancel", s.handleCancelMigration)
+	mux.HandleFunc("GET /admin/policy", s.handleGetPolicy)
+	mux.HandleFunc("PUT /admin/policy", s.handleUpdatePolicy)
+	mux.HandleFunc("GET /admin/transactions", s.handleListTransactions)
+	mux.HandleFunc("GET /admin/locks", s.handleListLocks)
+	mux.HandleFunc("GET /admin/leader", s.handleLeader)
+	return s.authenticate(mux)
+}
+
+// Start 启动管理服务
+func (s *AdminServer) Start(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("admin server is already running")
+	}
+	if s.token == "" {
+		return fmt.Errorf("admin server requires a token")
+	}
+
+	s.server = &http.Server{
+		Addr:      address,
+		Handler:   s.Handler(),
+		TLSConfig: s.tlsConfig,
+	}
+	s.running = true
+
+	server := s.server
+	go func() {
+		var err error
+		if server.TLSConfig != nil {
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("admin server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止管理服务，已发起的迁移不受影响
+func (s *AdminServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+	s.running = false
+	s.cancel()
+
+	if s.server != nil {
+		return s.server.Shutdown(ctx)
+	}
+	return nil
+}
+
+// authenticate 校验Bearer令牌
+func (s *AdminServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if s.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeAdminError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		if r.Method != http.MethodGet {
+			log.Printf("admin: %s %s from %s", r.Method, r.URL.Path, r.RemoteAddr)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminStoreView Store及其负载
+type AdminStoreView struct {
+	*StoreInfo
+	Load *StoreLoadInfo `json:"load,omitempty"`
+}
+
+// handleListStores 列出所有Store及负载
+func (s *AdminServer) handleListStores(w http.ResponseWriter, r *http.Request) {
+	if s.deps.Registry == nil {
+		writeAdminError(w, http.StatusNotImplemented, "registry not configured")
+		return
+	}
+	stores, err := s.deps.Registry.ListStores(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Slice(stores, func(i, j int) bool { return stores[i].ID < stores[j].ID })
+
+	views := make([]*AdminStoreView, 0, len(stores))
+	for _, store := range stores {
+		view := &AdminStoreView{StoreInfo: store}
+		if s.deps.GlobalIndex != nil {
+			if load, err := s.deps.GlobalIndex.GetStoreLoad(r.Context(), store.ID); err == nil {
+				view.Load = load
+			}
+		}
+		views = append(views, view)
+	}
+	writeAdminJSON(w, http.StatusOK, views)
+}
+
+// handleListTimelines 列出Store上的Timeline
+func (s *AdminServer) handleListTimelines(w http.ResponseWriter, r *http.Request) {
+	if s.deps.GlobalIndex == nil {
+		writeAdminError(w, http.StatusNotImplemented, "global index not configured")
+		return
+	}
+	timelines, err := s.deps.GlobalIndex.ListTimelinesByStore(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Strings(timelines)
+	writeAdminJSON(w, http.StatusOK, timelines)
+}
+
+// DrainResult 排空Store的结果
+type DrainResult struct {
+	StoreID    string            `json:"store_id"`
+	Migrations []*MigrationTask  `json:"migrations"`
+	Failed     map[string]string `json:"failed,omitempty"` // Timeline -> 错误
+}
+
+// handleDrainStore 排空Store：标记为draining并从路由移除，再把其上的Timeline迁到其他Store
+func (s *AdminServer) handleDrainStore(w http.ResponseWriter, r *http.Request) {
+	if s.deps.Registry == nil || s.deps.GlobalIndex == nil || s.deps.ShardManager == nil || s.deps.MigrationManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "drain requires registry, global index, shard and migration managers")
+		return
+	}
+	storeID := r.PathValue("id")
+	ctx := r.Context()
+
+	// 迁移在领导者任期内发起，失去领导者身份时中断
+	migrationCtx := s.ctx
+	if s.deps.LeaderElector != nil {
+		termCtx, err := s.deps.LeaderElector.LeaderContext()
+		if err != nil {
+			writeAdminError(w, http.StatusConflict, notLeaderError(ctx, s.deps.LeaderElector).Error())
+			return
+		}
+		migrationCtx = termCtx
+	}
+
+	if _, err := s.deps.Registry.GetStore(ctx, storeID); err != nil {
+		writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err := s.deps.Registry.UpdateStatus(ctx, storeID, StoreStatusDraining); err != nil {
+		writeAdminError(w, storeStatusErrorCode(err), err.Error())
+		return
+	}
+	s.syncRouter(&StoreInfo{ID: storeID, Status: StoreStatusDraining})
+
+	timelines, err := s.deps.GlobalIndex.ListTimelinesByStore(ctx, storeID)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Strings(timelines)
+
+	result := &DrainResult{StoreID: storeID, Migrations: make([]*MigrationTask, 0), Failed: make(map[string]string)}
+	for _, timelineKey := range timelines {
+		target, err := s.drainTarget(ctx, timelineKey, storeID)
+		if err != nil {
+			result.Failed[timelineKey] = err.Error()
+			continue
+		}
+		task, err := s.deps.MigrationManager.StartMigration(migrationCtx, timelineKey, target)
+		if err != nil {
+			result.Failed[timelineKey] = err.Error()
+			continue
+		}
+		result.Migrations = append(result.Migrations, task)
+	}
+	log.Printf("admin: draining store %s, %d migrations started, %d failed", storeID, len(result.Migrations), len(result.Failed))
+	writeAdminJSON(w, http.StatusAccepted, result)
+}
+
+// StoreStatusRequest 修改Store状态的请求
+type StoreStatusRequest struct {
+	Status StoreStatus `json:"status"`
+}
+
+// handleUpdateStoreStatus 隔离、恢复或下线Store，不迁移数据；unhealthy由健康检查维护，不能手动设置
+// 下线前Store上不能再有Timeline
+func (s *AdminServer) handleUpdateStoreStatus(w http.ResponseWriter, r *http.Request) {
+	if s.deps.Registry == nil {
+		writeAdminError(w, http.StatusNotImplemented, "registry not configured")
+		return
+	}
+	storeID := r.PathValue("id")
+	ctx := r.Context()
+
+	var req StoreStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	switch req.Status {
+	case StoreStatusActive, StoreStatusDraining, StoreStatusDecommissioned:
+	default:
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("status must be active, draining or decommissioned, got %q", req.Status))
+		return
+	}
+	if _, err := s.deps.Registry.GetStore(ctx, storeID); err != nil {
+		writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if req.Status == StoreStatusDecommissioned && s.deps.GlobalIndex != nil {
+		timelines, err := s.deps.GlobalIndex.ListTimelinesByStore(ctx, storeID)
+		if err != nil {
+			writeAdminError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(timelines) > 0 {
+			writeAdminError(w, http.StatusConflict, fmt.Sprintf("store %s still holds %d timelines, drain it first", storeID, len(timelines)))
+			return
+		}
+	}
+	if err := s.deps.Registry.UpdateStatus(ctx, storeID, req.Status); err != nil {
+		writeAdminError(w, storeStatusErrorCode(err), err.Error())
+		return
+	}
+
+	info, err := s.deps.Registry.GetStore(ctx, storeID)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.syncRouter(info)
+	log.Printf("admin: store %s is now %s", storeID, info.Status)
+	writeAdminJSON(w, http.StatusOK, &AdminStoreView{StoreInfo: info})
+}
+
+// syncRouter 让默认路由器立即反映Store状态，只有active的Store参与路由
+func (s *AdminServer) syncRouter(info *StoreInfo) {
+	if s.deps.RouterManager == nil {
+		return
+	}
+	router, err := s.deps.RouterManager.GetRouter("")
+	if err != nil {
+		return
+	}
+	if info.Status.AcceptsPlacements() {
+		routed := *info
+		router.AddStore(&routed)
+		return
+	}
+	router.RemoveStore(info.ID)
+}
+
+// storeStatusErrorCode 状态转换错误对应的HTTP状态码
+func storeStatusErrorCode(err error) int {
+	if errors.Is(err, ErrInvalidStoreTransition) {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// drainTarget 为Timeline选择排空的目标Store
+func (s *AdminServer) drainTarget(ctx context.Context, timelineKey, drainingID string) (string, error) {
+	var size int64
+	if location, err := s.deps.GlobalIndex.GetTimelineLocation(ctx, timelineKey); err == nil {
+		size = location.TotalSize
+	}
+	rec, err := s.deps.ShardManager.GetShardRecommendation(ctx, timelineKey, size)
+	if err != nil {
+		return "", err
+	}
+	if rec.RecommendedStore != drainingID {
+		return rec.RecommendedStore, nil
+	}
+	for _, alt := range rec.Alternatives {
+		if alt != drainingID {
+			return alt, nil
+		}
+	}
+	return "", fmt.Errorf("no other store available")
+}
+
+// handleShardStats 分片统计
+func (s *AdminServer) handleShardStats(w http.ResponseWriter, r *http.Request) {
+	if s.deps.ShardManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "shard manager not configured")
+		return
+	}
+	stats, err := s.deps.ShardManager.GetShardStats(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, stats)
+}
+
+// handleStatsHistory 分片负载采样历史，since可以是RFC3339时间或距今的时长(如1h)，默认返回全部
+func (s *AdminServer) handleStatsHistory(w http.ResponseWriter, r *http.Request) {
+	if s.deps.ShardManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "shard manager not configured")
+		return
+	}
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		if ago, err := time.ParseDuration(value); err == nil {
+			since = time.Now().Add(-ago)
+		} else if since, err = time.Parse(time.RFC3339, value); err != nil {
+			writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid since: %s", value))
+			return
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, s.deps.ShardManager.GetStatsHistory(since))
+}
+
+// handleRebalanceReport 自动重平衡前后的负载方差对比
+func (s *AdminServer) handleRebalanceReport(w http.ResponseWriter, r *http.Request) {
+	if s.deps.ShardManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "shard manager not configured")
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, s.deps.ShardManager.GetRebalanceReport())
+}
+
+// handleMetrics 连接池统计、各Store熔断器状态、各操作的延迟指标、内容审核与事件投递统计，只返回已配置的部分
+func (s *AdminServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	var pipeline *moderation.Pipeline
+	var bus *events.Bus
+	if s.deps.Store != nil {
+		pipeline = s.deps.Store.moderation.Load()
+		bus = s.deps.Store.events.Load()
+	}
+	if s.deps.ConnectionPool == nil && s.deps.Metrics == nil && s.deps.CircuitBreakers == nil && pipeline == nil && bus == nil {
+		writeAdminError(w, http.StatusNotImplemented, "no metrics source configured")
+		return
+	}
+	resp := make(map[string]interface{})
+	if s.deps.ConnectionPool != nil {
+		resp["connection_pool"] = s.deps.ConnectionPool.Stats()
+	}
+	if s.deps.Metrics != nil {
+		resp["operations"] = s.deps.Metrics.GetMetrics()
+	}
+	if s.deps.CircuitBreakers != nil {
+		resp["circuit_breakers"] = s.deps.CircuitBreakers.Stats()
+	}
+	if pipeline != nil {
+		resp["moderation"] = pipeline.Stats()
+	}
+	if bus != nil {
+		resp["events"] = bus.Stats()
+	}
+	writeAdminJSON(w, http.StatusOK, resp)
+}
+
+// handleListTenants 本节点Store上各租户的配额与用量，tenant参数只返回指定租户
+func (s *AdminServer) handleListTenants(w http.ResponseWriter, r *http.Request) {
+	if s.deps.Store == nil {
+		writeAdminError(w, http.StatusNotImplemented, "store not configured")
+		return
+	}
+	if r.URL.Query().Has("tenant") {
+		writeAdminJSON(w, http.StatusOK, []TenantMetrics{s.deps.Store.GetTenantMetrics(r.URL.Query().Get("tenant"))})
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, s.deps.Store.ListTenantMetrics())
+}
+
+// handleListMigrations 列出迁移任务，可按status过滤
+func (s *AdminServer) handleListMigrations(w http.ResponseWriter, r *http.Request) {
+	if s.deps.MigrationManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "migration manager not configured")
+		return
+	}
+	tasks, err := s.deps.MigrationManager.ListMigrations(r.Context(), MigrationStatus(r.URL.Query().Get("status")))
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].CreatedAt.Before(tasks[j].CreatedAt) })
+	if tasks == nil {
+		tasks = make([]*MigrationTask, 0)
+	}
+	writeAdminJSON(w, http.StatusOK, tasks)
+}
+
+// StartMigrationRequest 发起迁移请求
+type StartMigrationRequest struct {
+	TimelineKey string `json:"timeline_key"`
+	TargetStore string `json:"target_store"`
+}
+
+// handleStartMigration 发起迁移
+func (s *AdminServer) handleStartMigration(w http.ResponseWriter, r *http.Request) {
+	if s.deps.MigrationManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "migration manager not configured")
+		return
+	}
+	var req StartMigrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
+		return
+	}
+	if req.TimelineKey == "" || req.TargetStore == "" {
+		writeAdminError(w, http.StatusBadRequest, "timeline_key and target_store are required")
+		return
+	}
+	task, err := s.deps.MigrationManager.StartMigration(s.ctx, req.TimelineKey, req.TargetStore)
+	if err != nil {
+		writeAdminError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusAccepted, task)
+}
+
+// handleGetMigration 查询迁移任务
+func (s *AdminServer) handleGetMigration(w http.ResponseWriter, r *http.Request) {
+	if s.deps.MigrationManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "migration manager not configured")
+		return
+	}
+	task, err := s.deps.MigrationManager.GetMigrationStatus(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, task)
+}
+
+// handleCancelMigration 取消迁移任务
+func (s *AdminServer) handleCancelMigration(w http.ResponseWriter, r *http.Request) {
+	if s.deps.MigrationManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "migration manager not configured")
+		return
+	}
+	taskID := r.PathValue("id")
+	if err := s.deps.MigrationManager.CancelMigration(r.Context(), taskID); err != nil {
+		writeAdminError(w, http.StatusConflict, err.Error())
+		return
+	}
+	task, err := s.deps.MigrationManager.GetMigrationStatus(r.Context(), taskID)
+	if err != nil {
+		writeAdminError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, task)
+}
+
+// handleGetPolicy 获取分片策略
+func (s *AdminServer) handleGetPolicy(w http.ResponseWriter, r *http.Request) {
+	if s.deps.ShardManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "shard manager not configured")
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, s.deps.ShardManager.GetShardPolicy())
+}
+
+// handleUpdatePolicy 更新分片策略，请求体中未出现的字段保持原值
+// 自动重平衡开关变化时同步启停重平衡任务
+func (s *AdminServer) handleUpdatePolicy(w http.ResponseWriter, r *http.Request) {
+	if s.deps.ShardManager == nil {
+		writeAdminError(w, http.StatusNotImplemented, "shard manager not configured")
+		return
+	}
+	current := s.deps.ShardManager.GetShardPolicy()
+	policy := *current
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeAdminError(w, http.StatusBadRequest, fmt.Sprintf("invalid policy: %v", err))
+		return
+	}
+	if err := validateShardPolicy(&policy); err != nil {
+		writeAdminError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.deps.ShardManager.UpdateShardPolicy(&policy); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if policy.AutoRebalance != current.AutoRebalance {
+		var err error
+		if policy.AutoRebalance {
+			err = s.deps.ShardManager.StartAutoRebalance(s.ctx)
+		} else {
+			err = s.deps.ShardManager.StopAutoRebalance()
+		}
+		if err != nil {
+			log.Printf("admin: toggle auto rebalance: %v", err)
+		}
+	}
+	writeAdminJSON(w, http.StatusOK, s.deps.ShardManager.GetShardPolicy())
+}
+
+// validateShardPolicy 校验分片策略
+func validateShardPolicy(policy *ShardPolicy) error {
+	switch policy.Strategy {
+	case ShardByHash, ShardByLoad, ShardBySize, ShardByGeography:
+	default:
+		return fmt.Errorf("unknown strategy: %s", policy.Strategy)
+	}
+	switch policy.ReplicationMode {
+	case ReplicationSync, ReplicationAsync:
+	default:
+		return fmt.Errorf("unknown replication mode: %v", policy.ReplicationMode)
+	}
+	if policy.LoadBalanceThreshold <= 0 || policy.LoadBalanceThreshold > 1 {
+		return fmt.Errorf("load_balance_threshold must be in (0, 1]")
+	}
+	if policy.ReplicationFactor < 1 {
+		return fmt.Errorf("replication_factor must be at least 1")
+	}
+	if policy.MaxTimelinePerStore <= 0 || policy.MaxSizePerStore <= 0 {
+		return fmt.Errorf("store limits must be positive")
+	}
+	if policy.AutoRebalance && policy.RebalanceInterval < time.Second {
+		return fmt.Errorf("rebalance_interval must be at least 1s")
+	}
+	return validateRebalanceThrottle(policy)
+}
+
+// AdminTransactionView 事务的管理视图，状态以字符串展示
+type AdminTransactionView struct {
+	TransactionID string    `json:"transaction_id"`
+	CoordinatorID string    `json:"coordinator_id"`
+	Status        string    `json:"status"`
+	Participants  []string  `json:"participants"` // store_id:operation:status
+	Locks         []string  `json:"locks"`
+	CreatedAt     time.Time `json:"created_at"`
+	Age           string    `json:"age"`
+	Timeout       string    `json:"timeout"`
+}
+
+// handleListTransactions 列出活跃事务
+func (s *AdminServer) handleListTransactions(w http.ResponseWriter, r *http.Request) {
+	lister, ok := s.deps.Coordinator.(TransactionLister)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "transaction coordinator cannot list transactions")
+		return
+	}
+	txns, err := lister.ListActiveTransactions(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	views := make([]*AdminTransactionView, 0, len(txns))
+	for _, txn := range txns {
+		participants := make([]string, 0, len(txn.Participants))
+		for _, p := range txn.Participants {
+			participants = append(participants, fmt.Sprintf("%s:%s:%s", p.StoreID, p.Operation, p.Status))
+		}
+		views = append(views, &AdminTransactionView{
+			TransactionID: txn.TransactionID,
+			CoordinatorID: txn.CoordinatorID,
+			Status:        txn.Status.String(),
+			Participants:  participants,
+			Locks:         txn.Locks,
+			CreatedAt:     txn.CreatedAt,
+			Age:           time.Since(txn.CreatedAt).Round(time.Millisecond).String(),
+			Timeout:       txn.Timeout.String(),
+		})
+	}
+	writeAdminJSON(w, http.StatusOK, views)
+}
+
+// handleListLocks 列出当前持有的锁
+func (s *AdminServer) handleListLocks(w http.ResponseWriter, r *http.Request) {
+	lister, ok := s.deps.LockManager.(LockLister)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "lock manager cannot list locks")
+		return
+	}
+	locks, err := lister.ListLocks(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, locks)
+}
+
+// LeaderView 协调者领导者
+type LeaderView struct {
+	Leader   string `json:"leader"`    // 没有领导者时为空
+	IsLeader bool   `json:"is_leader"` // 本节点是否为领导者
+}
+
+// handleLeader 当前的协调者领导者，排空Store需要发给领导者
+func (s *AdminServer) handleLeader(w http.ResponseWriter, r *http.Request) {
+	if s.deps.LeaderElector == nil {
+		writeAdminError(w, http.StatusNotImplemented, "leader election not configured")
+		return
+	}
+	leader, err := s.deps.LeaderElector.Leader(r.Context())
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, &LeaderView{Leader: leader, IsLeader: s.deps.LeaderElector.IsLeader()})
+}
+
+// writeAdminJSON 写入JSON响应
+func writeAdminJSON(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Failed to encode JSON response: %v", err)
+	}
+}
+
+// writeAdminError 写入错误响应
+func writeAdminError(w http.ResponseWriter, statusCode int, message string) {
+	writeAdminJSON(w, statusCode, map[string]string{"error": message})
+}
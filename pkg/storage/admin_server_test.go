@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAdminServer(t *testing.T) {
+	ctx := context.Background()
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: "store_a", Address: "http://a"})
+	registry.Register(ctx, &StoreInfo{ID: "store_b", Address: "http://b"})
+
+	globalIndex := NewInMemoryGlobalIndex()
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_1", StoreID: "store_a", BlockID: "block_1", Size: 10})
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_2", StoreID: "store_a", BlockID: "block_2", Size: 20})
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_3", StoreID: "store_b", BlockID: "block_3", Size: 30})
+
+	routerManager := NewRouterManager()
+	router := NewConsistentHashRouter(1, 10, 0.8)
+	router.AddStore(&StoreInfo{ID: "store_a", Status: StoreStatusActive})
+	router.AddStore(&StoreInfo{ID: "store_b", Status: StoreStatusActive})
+	routerManager.RegisterRouter("hash", router)
+
+	migrations := &recordingMigrationManager{}
+	shardManager := NewTimelineShardManager(globalIndex, registry, routerManager, migrations)
+	lockManager := NewInMemoryDistributedLockManager("store_a")
+	defer lockManager.Close()
+	coordinator := NewInMemoryTransactionCoordinator("store_a", lockManager)
+	defer coordinator.Close()
+
+	admin := NewAdminServer(AdminDependencies{
+		Registry:         registry,
+		GlobalIndex:      globalIndex,
+		ShardManager:     shardManager,
+		MigrationManager: migrations,
+		RouterManager:    routerManager,
+		Coordinator:      coordinator,
+		LockManager:      lockManager,
+	}, "secret")
+	defer admin.Stop(ctx)
+	ts := httptest.NewServer(admin.Handler())
+	defer ts.Close()
+
+	call := func(method, path, token, body string, out interface{}) int {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				t.Fatalf("%s %s: decode response: %v", method, path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	if code := call("GET", "/admin/stores", "", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without token, got %d", code)
+	}
+	if code := call("GET", "/admin/stores", "wrong", "", nil); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 with wrong token, got %d", code)
+	}
+
+	var stores []*AdminStoreView
+	if code := call("GET", "/admin/stores", "secret", "", &stores); code != http.StatusOK {
+		t.Fatalf("Expected 200 listing stores, got %d", code)
+	}
+	if len(stores) != 2 || stores[0].ID != "store_a" || stores[0].Load == nil || stores[0].Load.TimelineCount != 2 {
+		t.Fatalf("Unexpected stores: %+v", stores)
+	}
+
+	var timelines []string
+	call("GET", "/admin/stores/store_a/timelines", "secret", "", &timelines)
+	if len(timelines) != 2 || timelines[0] != "conv_1" {
+		t.Errorf("Unexpected timelines: %v", timelines)
+	}
+
+	// 只更新部分字段，其余保持原值
+	var policy ShardPolicy
+	if code := call("PUT", "/admin/policy", "secret", `{"replication_factor":2,"auto_rebalance":false}`, &policy); code != http.StatusOK {
+		t.Fatalf("Expected 200 updating policy, got %d", code)
+	}
+	if policy.ReplicationFactor != 2 || policy.Strategy != ShardByLoad || shardManager.GetShardPolicy().ReplicationFactor != 2 {
+		t.Errorf("Unexpected policy after update: %+v", policy)
+	}
+	if code := call("PUT", "/admin/policy", "secret", `{"strategy":"random"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid strategy, got %d", code)
+	}
+
+	shardManager.SampleShardStats(ctx)
+	var history []*ShardStatsSample
+	if code := call("GET", "/admin/stats/history?since=1h", "secret", "", &history); code != http.StatusOK || len(history) != 1 {
+		t.Fatalf("Expected one sample in the history, got %d %+v", code, history)
+	}
+	if history[0].TotalTimelines != 3 || len(history[0].StoreLoads) != 2 {
+		t.Errorf("Unexpected sample %+v", history[0])
+	}
+	if code := call("GET", "/admin/stats/history?since=yesterday", "secret", "", nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for invalid since, got %d", code)
+	}
+	var report RebalanceReport
+	if code := call("GET", "/admin/stats/rebalances", "secret", "", &report); code != http.StatusOK || report.CurrentVariance == nil {
+		t.Errorf("Expected a report with the current variance, got %d %+v", code, report)
+	}
+
+	// 隔离和恢复只改状态，仍有Timeline的Store不能下线
+	var view AdminStoreView
+	if code := call("PUT", "/admin/stores/store_b/status", "secret", `{"status":"draining"}`, &view); code != http.StatusOK || view.Status != StoreStatusDraining {
+		t.Fatalf("Expected store_b to be cordoned, got %d %+v", code, view.StoreInfo)
+	}
+	if target, _ := router.RouteTimeline("conv_new"); target == "store_b" {
+		t.Errorf("Expected the cordoned store to leave the router")
+	}
+	if code := call("PUT", "/admin/stores/store_b/status", "secret", `{"status":"decommissioned"}`, nil); code != http.StatusConflict {
+		t.Errorf("Expected 409 decommissioning a store with timelines, got %d", code)
+	}
+	if code := call("PUT", "/admin/stores/store_b/status", "secret", `{"status":"unhealthy"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 setting unhealthy by hand, got %d", code)
+	}
+	if code := call("PUT", "/admin/stores/store_b/status", "secret", `{"status":"active"}`, &view); code != http.StatusOK || view.Status != StoreStatusActive {
+		t.Fatalf("Expected store_b to be active again, got %d %+v", code, view.StoreInfo)
+	}
+
+	var drain DrainResult
+	if code := call("POST", "/admin/stores/store_a/drain", "secret", "", &drain); code != http.StatusAccepted {
+		t.Fatalf("Expected 202 draining store, got %d", code)
+	}
+	if len(drain.Migrations) != 2 || len(drain.Failed) != 0 {
+		t.Fatalf("Unexpected drain result: %+v", drain)
+	}
+	for _, task := range drain.Migrations {
+		if task.TargetStore != "store_b" {
+			t.Errorf("Expected %s to move to store_b, got %s", task.TimelineKey, task.TargetStore)
+		}
+	}
+	if info, _ := registry.GetStore(ctx, "store_a"); info.Status != StoreStatusDraining {
+		t.Errorf("Expected store_a to be draining, got %s", info.Status)
+	}
+	if target, _ := router.RouteTimeline("conv_new"); target == "store_a" {
+		t.Error("Expected draining store to be removed from the router")
+	}
+
+	var task MigrationTask
+	if code := call("POST", "/admin/migrations/task_0/cancel", "secret", "", &task); code != http.StatusOK || task.Status != MigrationCancelled {
+		t.Errorf("Expected cancelled migration, got %d %+v", code, task)
+	}
+	if code := call("POST", "/admin/migrations", "secret", `{"timeline_key":"conv_3"}`, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without target store, got %d", code)
+	}
+
+	lock, err := lockManager.AcquireLock(ctx, "timeline:conv_3", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer lock.Release(ctx)
+	var locks []*LockInfo
+	call("GET", "/admin/locks", "secret", "", &locks)
+	if len(locks) != 1 || locks[0].LockKey != "timeline:conv_3" {
+		t.Errorf("Unexpected locks: %+v", locks)
+	}
+
+	if _, err := coordinator.BeginTransaction(ctx, []*TransactionParticipant{{StoreID: "store_b", Operation: OpAddMessage}}, time.Minute); err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	var txns []*AdminTransactionView
+	call("GET", "/admin/transactions", "secret", "", &txns)
+	if len(txns) != 1 || txns[0].Status != "pending" || txns[0].Participants[0] != "store_b:add_message:pending" {
+		t.Errorf("Unexpected transactions: %+v", txns)
+	}
+}
+
+func TestAdminServerMetrics(t *testing.T) {
+	ctx := context.Background()
+	get := func(admin *AdminServer, out interface{}) int {
+		t.Helper()
+		ts := httptest.NewServer(admin.Handler())
+		defer ts.Close()
+		req, _ := http.NewRequest("GET", ts.URL+"/admin/metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /admin/metrics failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if out != nil {
+			json.NewDecoder(resp.Body).Decode(out)
+		}
+		return resp.StatusCode
+	}
+
+	if code := get(NewAdminServer(AdminDependencies{}, "secret"), nil); code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without pool or metrics, got %d", code)
+	}
+
+	pool, _ := newTestConnectionPool(t, ConnectionPoolConfig{}, "store_a")
+	conn, err := pool.Get(ctx, "store_a")
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	pool.Release(conn)
+	metrics := NewMetricsCollector()
+	metrics.Record("get_messages", 5*time.Millisecond, true)
+	breakers := NewCircuitBreakerGroup(CircuitBreakerConfig{FailureThreshold: 1})
+	breakers.Get("store_b").Call(ctx, func() error { return errors.New("connection refused") })
+
+	var body struct {
+		ConnectionPool PoolStats                      `json:"connection_pool"`
+		Operations     PerformanceMetrics             `json:"operations"`
+		Breakers       map[string]CircuitBreakerStats `json:"circuit_breakers"`
+	}
+	admin := NewAdminServer(AdminDependencies{ConnectionPool: pool, Metrics: metrics, CircuitBreakers: breakers}, "secret")
+	if code := get(admin, &body); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if body.ConnectionPool.IdleConnections != 1 || body.ConnectionPool.ConnectionsCreated != 1 {
+		t.Errorf("Unexpected pool stats %+v", body.ConnectionPool)
+	}
+	if body.Operations.OperationCounts["get_messages"] != 1 || body.Operations.Latencies["get_messages"].Count != 1 {
+		t.Errorf("Unexpected operation metrics %+v", body.Operations)
+	}
+	if body.Breakers["store_b"].State != CircuitOpen {
+		t.Errorf("Expected store_b's breaker to be reported open, got %+v", body.Breakers)
+	}
+}
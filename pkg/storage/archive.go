@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 归档文件格式
+// 每个归档的Timeline对应数据目录下 archives/{type}_{id}.gz 一个文件，
+// 内容为gob编码的timelineArchive经gzip压缩，包含全部块（含未写满的块）的消息。
+
+const (
+	archiveDirName = "archives"
+	archiveVersion = 1
+)
+
+var (
+	// ErrTimelineNotFound Timeline不存在
+	ErrTimelineNotFound = errors.New("timeline not found")
+	// ErrArchiveNotFound Timeline没有归档文件
+	ErrArchiveNotFound = errors.New("timeline archive not found")
+)
+
+// TimelineArchiveInfo 归档或恢复结果
+type TimelineArchiveInfo struct {
+	TimelineType string    `json:"timeline_type"`
+	TimelineID   string    `json:"timeline_id"`
+	Path         string    `json:"path"`
+	Blocks       int       `json:"blocks"`
+	Messages     int64     `json:"messages"`
+	Size         int64     `json:"size"` // 归档文件字节数
+	ArchivedAt   time.Time `json:"archived_at"`
+}
+
+// timelineArchive 归档文件内容
+type timelineArchive struct {
+	Version    int
+	Type       string
+	ID         string
+	LastSeqID  int64
+	ArchivedAt time.Time
+	Blocks     []archivedBlock
+}
+
+// archivedBlock 归档中的一个块，恢复时按校验和验证
+type archivedBlock struct {
+	BlockID  string
+	Checksum uint32
+	Messages []*Message
+}
+
+// ArchiveTimeline 将Timeline打包为单个压缩归档文件后删除，归档写入并刷盘成功后才会删除数据
+// 同一Timeline已有归档时返回错误，需先恢复或删除旧归档
+func (s *Store) ArchiveTimeline(timelineType, timelineID string) (*TimelineArchiveInfo, error) {
+	path := s.archivePath(timelineType, timelineID)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("timeline %s_%s is already archived", timelineType, timelineID)
+	}
+
+	var info *TimelineArchiveInfo
+	deleted, err := s.deleteTimeline(timelineType, timelineID, func(tl *Timeline) error {
+		if err := s.loadBlocks(tl.Blocks); err != nil {
+			return err
+		}
+		archive := &timelineArchive{
+			Version:    archiveVersion,
+			Type:       tl.Type,
+			ID:         tl.ID,
+			LastSeqID:  tl.LastSeqID,
+			ArchivedAt: time.Now(),
+			Blocks:     make([]archivedBlock, 0, len(tl.Blocks)),
+		}
+		var messages int64
+		for _, block := range tl.Blocks {
+			block.mu.RLock()
+			archive.Blocks = append(archive.Blocks, archivedBlock{
+				BlockID:  block.BlockID,
+				Checksum: blockChecksum(block.Messages),
+				Messages: append([]*Message(nil), block.Messages...),
+			})
+			messages += int64(len(block.Messages))
+			block.mu.RUnlock()
+		}
+
+		size, err := writeTimelineArchive(path, archive)
+		if err != nil {
+			return err
+		}
+		info = &TimelineArchiveInfo{
+			TimelineType: tl.Type,
+			TimelineID:   tl.ID,
+			Path:         path,
+			Blocks:       len(archive.Blocks),
+			Messages:     messages,
+			Size:         size,
+			ArchivedAt:   archive.ArchivedAt,
+		}
+		return nil
+	})
+	if err != nil {
+		// 删除失败时保留已写入的归档，Timeline数据仍在，重新归档前需手动清理
+		return nil, fmt.Errorf("failed to archive timeline %s_%s: %w", timelineType, timelineID, err)
+	}
+	if !deleted {
+		return nil, ErrTimelineNotFound
+	}
+	return info, nil
+}
+
+// RestoreTimeline 从归档恢复Timeline，保留原有块ID与消息SeqID，恢复成功后删除归档文件
+// 恢复的块视为已写满；Timeline在归档后又有新的写入时拒绝恢复
+func (s *Store) RestoreTimeline(timelineType, timelineID string) (*TimelineArchiveInfo, error) {
+	path := s.archivePath(timelineType, timelineID)
+	archive, size, err := readTimelineArchive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if tl, exists := s.GetTimeline(timelineType, timelineID); exists {
+		tl.mu.RLock()
+		blocks := len(tl.Blocks)
+		tl.mu.RUnlock()
+		if blocks > 0 {
+			return nil, fmt.Errorf("timeline %s_%s already exists, delete it before restoring", timelineType, timelineID)
+		}
+	}
+
+	info := &TimelineArchiveInfo{
+		TimelineType: archive.Type,
+		TimelineID:   archive.ID,
+		Path:         path,
+		Size:         size,
+		ArchivedAt:   archive.ArchivedAt,
+	}
+	for _, block := range archive.Blocks {
+		if blockChecksum(block.Messages) != block.Checksum {
+			return nil, fmt.Errorf("archive %s: checksum mismatch in block %s", path, block.BlockID)
+		}
+		// 未写满的块归档时可能为空，无需恢复
+		if len(block.Messages) == 0 {
+			continue
+		}
+		if _, err := s.ImportTimelineBlock(timelineType, timelineID, &TimelineBlockData{
+			TimelineKey:  timelineID,
+			TimelineType: timelineType,
+			Block:        &TimelineBlock{BlockID: block.BlockID},
+			Messages:     block.Messages,
+		}); err != nil {
+			return nil, fmt.Errorf("failed to restore block %s: %w", block.BlockID, err)
+		}
+		info.Blocks++
+		info.Messages += int64(len(block.Messages))
+	}
+
+	// 没有消息的Timeline也要恢复其元数据
+	var tl *Timeline
+	if timelineType == "user" {
+		tl = s.GetOrCreateUserTimeline(timelineID)
+	} else {
+		tl = s.GetOrCreateConvTimeline(timelineID)
+	}
+	tl.mu.Lock()
+	if archive.LastSeqID > tl.LastSeqID {
+		tl.LastSeqID = archive.LastSeqID
+	}
+	tl.mu.Unlock()
+	if err := s.saveTimelineMetadata(tl); err != nil {
+		return nil, err
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return info, nil
+}
+
+// HasArchive 检查Timeline是否有归档文件
+func (s *Store) HasArchive(timelineType, timelineID string) bool {
+	_, err := os.Stat(s.archivePath(timelineType, timelineID))
+	return err == nil
+}
+
+func (s *Store) archivePath(timelineType, timelineID string) string {
+	return filepath.Join(s.Config.DataDir, archiveDirName, fmt.Sprintf("%s_%s.gz", timelineType, timelineID))
+}
+
+// writeTimelineArchive 先写临时文件并刷盘再重命名，返回归档文件大小
+func writeTimelineArchive(path string, archive *timelineArchive) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	writer := gzip.NewWriter(file)
+	err = gob.NewEncoder(writer).Encode(archive)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	var size int64
+	if err == nil {
+		var stat os.FileInfo
+		if stat, err = file.Stat(); err == nil {
+			size = stat.Size()
+		}
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to write archive: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to replace archive: %w", err)
+	}
+	return size, nil
+}
+
+// readTimelineArchive 读取并解压归档文件，返回内容和文件大小
+func readTimelineArchive(path string) (*timelineArchive, int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, 0, ErrArchiveNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, 0, fmt.Errorf("archive %s: %w", path, err)
+	}
+	defer reader.Close()
+
+	var archive timelineArchive
+	if err := gob.NewDecoder(reader).Decode(&archive); err != nil {
+		return nil, 0, fmt.Errorf("archive %s: %w", path, err)
+	}
+	if archive.Version != archiveVersion {
+		return nil, 0, fmt.Errorf("archive %s: unsupported version %d", path, archive.Version)
+	}
+	return &archive, stat.Size(), nil
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestArchiveAndRestoreTimeline(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// 两个写满的块和一个未写满的块
+	for i := 1; i <= 5; i++ {
+		if err := store.AddMessage("conv_archive", 1, []byte(fmt.Sprintf("m%d", i)), []string{"user_archive"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	before := store.Capacity()
+
+	info, err := store.ArchiveTimeline("conv", "conv_archive")
+	if err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+	if info.Blocks != 3 || info.Messages != 5 || info.Size <= 0 {
+		t.Errorf("Unexpected archive info %+v", info)
+	}
+	if _, exists := store.GetTimeline("conv", "conv_archive"); exists {
+		t.Error("Archived timeline should be removed")
+	}
+	if after := store.Capacity(); after.BlockBytes >= before.BlockBytes || after.WALBytes >= before.WALBytes {
+		t.Errorf("Expected archiving to release capacity, before %+v after %+v", before, after)
+	}
+	if !store.HasArchive("conv", "conv_archive") {
+		t.Error("Expected archive file to exist")
+	}
+	if _, err := store.ArchiveTimeline("conv", "conv_missing"); !errors.Is(err, ErrTimelineNotFound) {
+		t.Errorf("Expected ErrTimelineNotFound, got %v", err)
+	}
+	// 用户Timeline不受影响
+	if messages, _ := store.GetUserMessagesAfter("user_archive", 0, 0); len(messages) != 5 {
+		t.Errorf("Expected user timeline to keep 5 messages, got %d", len(messages))
+	}
+	store.Close()
+
+	// 重启后从归档恢复
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if messages, _ := reopened.GetConvMessages("conv_archive", 10, 0); len(messages) != 0 {
+		t.Fatalf("Archived timeline should stay empty after reopen, got %d messages", len(messages))
+	}
+
+	restored, err := reopened.RestoreTimeline("conv", "conv_archive")
+	if err != nil {
+		t.Fatalf("Failed to restore: %v", err)
+	}
+	if restored.Blocks != 3 || restored.Messages != 5 {
+		t.Errorf("Unexpected restore info %+v", restored)
+	}
+	messages, _ := reopened.GetConvMessages("conv_archive", 10, 0)
+	if len(messages) != 5 {
+		t.Fatalf("Expected 5 restored messages, got %d", len(messages))
+	}
+	for i, msg := range messages {
+		if string(msg.Data) != fmt.Sprintf("m%d", i+1) {
+			t.Errorf("Message %d: expected m%d, got %s", i, i+1, msg.Data)
+		}
+	}
+	if reopened.HasArchive("conv", "conv_archive") {
+		t.Error("Archive should be removed after restore")
+	}
+	if _, err := reopened.RestoreTimeline("conv", "conv_archive"); !errors.Is(err, ErrArchiveNotFound) {
+		t.Errorf("Expected ErrArchiveNotFound, got %v", err)
+	}
+
+	// 恢复后继续写入，SeqID不回退
+	if err := reopened.AddMessage("conv_archive", 1, []byte("m6"), nil); err != nil {
+		t.Fatalf("Failed to write after restore: %v", err)
+	}
+	messages, _ = reopened.GetConvMessages("conv_archive", 10, 0)
+	if len(messages) != 6 || messages[5].SeqID <= messages[4].SeqID {
+		t.Errorf("Expected a 6th message with a larger SeqID, got %d messages", len(messages))
+	}
+}
+
+func TestRestoreTimelineRejectsConflicts(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.AddMessage("conv_conflict", 1, []byte("old"), nil)
+	if _, err := store.ArchiveTimeline("conv", "conv_conflict"); err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+
+	// 归档后又有新写入时拒绝恢复，也不能再次归档覆盖旧归档
+	store.AddMessage("conv_conflict", 1, []byte("new"), nil)
+	if _, err := store.RestoreTimeline("conv", "conv_conflict"); err == nil {
+		t.Error("Expected restore over a live timeline to fail")
+	}
+	if _, err := store.ArchiveTimeline("conv", "conv_conflict"); err == nil {
+		t.Error("Expected archiving over an existing archive to fail")
+	}
+
+	// 损坏的归档不会被恢复
+	if _, err := store.DeleteTimeline("conv", "conv_conflict"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if err := os.WriteFile(store.archivePath("conv", "conv_conflict"), []byte("not gzip"), 0644); err != nil {
+		t.Fatalf("Failed to corrupt archive: %v", err)
+	}
+	if _, err := store.RestoreTimeline("conv", "conv_conflict"); err == nil {
+		t.Error("Expected corrupt archive to fail")
+	}
+}
@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+
+	"imy/pkg/storage/storepb"
+)
+
+// 块记录编码
+// 段记录的负载以 [魔数"IMYB"][版本uint8][编码uint8] 开头，其后为按编码序列化的块记录，
+// 版本号用于之后调整负载布局，编码标识允许同一段文件中混合存放不同编码的记录。
+// 版本1的记录头校验和为CRC32(IEEE)；版本2改为CRC32C，并在块记录中保存块内消息的CRC32C校验和，
+// 解码后按消息重新计算，发现编码之外的损坏（如写入前内存中的数据已被破坏）。
+// 没有魔数的负载是加入版本头之前写入的裸gob流，按gob解码：gob流首条消息是类型定义，
+// 其类型ID为负数且用户类型从65开始，编码后第二个字节不小于0xF8，不会与魔数冲突。
+
+// BlockCodec 块记录的编码方式
+type BlockCodec string
+
+const (
+	BlockCodecProtobuf BlockCodec = "protobuf" // storepb.BlockRecord，默认
+	BlockCodecGob      BlockCodec = "gob"      // gob编码的segmentRecord，兼容旧版本
+)
+
+const (
+	blockPayloadMagic      = "IMYB"
+	blockPayloadVersion    = 2
+	blockPayloadHeaderSize = len(blockPayloadMagic) + 2
+
+	blockCodecIDGob      = 1
+	blockCodecIDProtobuf = 2
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// recordChecksum 计算段记录头中的校验和，版本2及之后的负载使用CRC32C，之前的使用CRC32(IEEE)
+func recordChecksum(payload []byte) uint32 {
+	if bytes.HasPrefix(payload, []byte(blockPayloadMagic)) && len(payload) > len(blockPayloadMagic) && payload[len(blockPayloadMagic)] >= 2 {
+		return crc32.Checksum(payload, castagnoliTable)
+	}
+	return crc32.ChecksumIEEE(payload)
+}
+
+// blockContentChecksum 按顺序计算块内消息的CRC32C校验和，随块记录保存
+func blockContentChecksum(messages []*Message) uint32 {
+	hash := crc32.New(castagnoliTable)
+	hashMessages(hash, messages)
+	return hash.Sum32()
+}
+
+// validBlockCodec 检查编码方式，为空时使用protobuf
+func validBlockCodec(codec BlockCodec) (BlockCodec, error) {
+	switch codec {
+	case "":
+		return BlockCodecProtobuf, nil
+	case BlockCodecProtobuf, BlockCodecGob:
+		return codec, nil
+	default:
+		return "", fmt.Errorf("unknown block codec %q", codec)
+	}
+}
+
+// encodeBlockPayload 按编码序列化块记录并加上版本头，同时填写块内消息的校验和
+func encodeBlockPayload(record *segmentRecord, codec BlockCodec) ([]byte, error) {
+	if !record.Deleted {
+		record.Checksum = blockContentChecksum(record.Messages)
+	}
+
+	var payload bytes.Buffer
+	payload.WriteString(blockPayloadMagic)
+	payload.WriteByte(blockPayloadVersion)
+
+	switch codec {
+	case BlockCodecGob:
+		payload.WriteByte(blockCodecIDGob)
+		if err := gob.NewEncoder(&payload).Encode(record); err != nil {
+			return nil, err
+		}
+	case BlockCodecProtobuf, "":
+		payload.WriteByte(blockCodecIDProtobuf)
+		body, err := proto.Marshal(blockRecordToPB(record))
+		if err != nil {
+			return nil, err
+		}
+		payload.Write(body)
+	default:
+		return nil, fmt.Errorf("unknown block codec %q", codec)
+	}
+	return payload.Bytes(), nil
+}
+
+// decodeBlockPayload 解析块记录并校验块内消息，返回记录与其编码方式
+func decodeBlockPayload(payload []byte) (*segmentRecord, BlockCodec, error) {
+	record, codec, err := decodeBlockBody(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	if record.Checksum != 0 && blockContentChecksum(record.Messages) != record.Checksum {
+		return nil, "", fmt.Errorf("%w: block %s checksum mismatch", ErrBlockCorrupted, record.BlockID)
+	}
+	return record, codec, nil
+}
+
+func decodeBlockBody(payload []byte) (*segmentRecord, BlockCodec, error) {
+	if !bytes.HasPrefix(payload, []byte(blockPayloadMagic)) {
+		record, err := decodeGobRecord(payload)
+		return record, BlockCodecGob, err
+	}
+	if len(payload) < blockPayloadHeaderSize {
+		return nil, "", fmt.Errorf("block payload header truncated")
+	}
+	version, codecID := payload[len(blockPayloadMagic)], payload[len(blockPayloadMagic)+1]
+	if version == 0 || version > blockPayloadVersion {
+		return nil, "", fmt.Errorf("unsupported block payload version %d", version)
+	}
+
+	body := payload[blockPayloadHeaderSize:]
+	switch codecID {
+	case blockCodecIDGob:
+		record, err := decodeGobRecord(body)
+		return record, BlockCodecGob, err
+	case blockCodecIDProtobuf:
+		var pb storepb.BlockRecord
+		if err := proto.Unmarshal(body, &pb); err != nil {
+			return nil, "", err
+		}
+		return blockRecordFromPB(&pb), BlockCodecProtobuf, nil
+	default:
+		return nil, "", fmt.Errorf("unknown block codec id %d", codecID)
+	}
+}
+
+func decodeGobRecord(body []byte) (*segmentRecord, error) {
+	var record segmentRecord
+	if err := gob.NewDecoder(bytes.NewReader(body)).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func blockRecordToPB(record *segmentRecord) *storepb.BlockRecord {
+	pb := &storepb.BlockRecord{
+		BlockId:  record.BlockID,
+		Deleted:  record.Deleted,
+		Messages: messagesToPB(record.Messages),
+		Checksum: record.Checksum,
+	}
+	if record.Index != nil {
+		pb.Index = blockIndexToPB(*record.Index)
+	}
+	if record.Filters != nil {
+		pb.Senders = bloomFilterToPB(record.Filters.Senders)
+		pb.Mentions = bloomFilterToPB(record.Filters.Mentions)
+		pb.Replies = bloomFilterToPB(record.Filters.Replies)
+	}
+	return pb
+}
+
+func blockRecordFromPB(pb *storepb.BlockRecord) *segmentRecord {
+	record := &segmentRecord{
+		BlockID:  pb.GetBlockId(),
+		Deleted:  pb.GetDeleted(),
+		Checksum: pb.GetChecksum(),
+	}
+	if len(pb.GetMessages()) > 0 {
+		record.Messages = messagesFromPB(pb.GetMessages())
+	}
+	if pb.Index != nil {
+		index := blockIndexFromPB(pb.Index)
+		record.Index = &index
+	}
+	if pb.Senders != nil || pb.Mentions != nil || pb.Replies != nil {
+		record.Filters = &blockFilters{
+			Senders:  bloomFilterFromPB(pb.Senders),
+			Mentions: bloomFilterFromPB(pb.Mentions),
+			Replies:  bloomFilterFromPB(pb.Replies),
+		}
+	}
+	return record
+}
+
+func blockIndexToPB(index BlockIndex) *storepb.BlockIndex {
+	return &storepb.BlockIndex{
+		MinSeqId: index.MinSeqID,
+		MaxSeqId: index.MaxSeqID,
+		MinTime:  index.MinTime,
+		MaxTime:  index.MaxTime,
+		MinHlc:   index.MinHLC,
+		MaxHlc:   index.MaxHLC,
+		Count:    index.Count,
+	}
+}
+
+func blockIndexFromPB(index *storepb.BlockIndex) BlockIndex {
+	return BlockIndex{
+		MinSeqID: index.GetMinSeqId(),
+		MaxSeqID: index.GetMaxSeqId(),
+		MinTime:  index.GetMinTime(),
+		MaxTime:  index.GetMaxTime(),
+		MinHLC:   index.GetMinHlc(),
+		MaxHLC:   index.GetMaxHlc(),
+		Count:    index.GetCount(),
+	}
+}
+
+func bloomFilterToPB(filter *bloomFilter) *storepb.BloomFilter {
+	if filter == nil {
+		return nil
+	}
+	return &storepb.BloomFilter{Bits: filter.Bits, Hashes: uint32(filter.Hashes)}
+}
+
+func bloomFilterFromPB(filter *storepb.BloomFilter) *bloomFilter {
+	if filter == nil {
+		return nil
+	}
+	return &bloomFilter{Bits: filter.GetBits(), Hashes: uint8(filter.GetHashes())}
+}
+
+// ConvertBlockCodec 将数据目录下所有块改写为指定编码，返回改写的块数
+// 旧格式的 block_*.gob 文件会先导入段文件。改写通过在新段中追加记录完成，
+// 旧记录所在的段在不再被引用后删除。需在Store停止时执行
+func ConvertBlockCodec(dir string, codec BlockCodec) (int, error) {
+	segments, err := openSegmentStore(dir, 0, codec)
+	if err != nil {
+		return 0, err
+	}
+	defer segments.Close()
+
+	migrated, err := segments.migrateGobBlocks(dir)
+	if err != nil {
+		return migrated, err
+	}
+
+	segments.mu.RLock()
+	blockIDs := make([]string, 0, len(segments.index))
+	for blockID := range segments.index {
+		blockIDs = append(blockIDs, blockID)
+	}
+	segments.mu.RUnlock()
+	sort.Strings(blockIDs)
+
+	converted := migrated
+	for _, blockID := range blockIDs {
+		record, recordCodec, err := segments.readRecord(blockID)
+		if err != nil {
+			return converted, err
+		}
+		if record == nil || recordCodec == segments.codec {
+			continue
+		}
+		if record.Index == nil {
+			index := buildBlockIndex(record.Messages)
+			record.Index = &index
+		}
+		// 改写的记录写入新段，旧段中的记录全部改写后即可整段删除
+		if converted == migrated {
+			if err := segments.rollNonEmpty(); err != nil {
+				return converted, err
+			}
+		}
+		if _, err := segments.appendRecord(record); err != nil {
+			return converted, err
+		}
+		converted++
+	}
+	return converted, nil
+}
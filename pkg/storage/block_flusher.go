@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// 未写满块的后台刷盘
+// 块写满前消息只在内存中，崩溃后依赖WAL恢复；关闭WAL时最近写入的消息会丢失。
+// 开启BlockFlushInterval后，后台协程按间隔把有新消息的活跃块整块写入段文件，
+// 新记录覆盖该块之前的记录，旧记录所在的段在不再被引用后删除。
+// Timeline元数据记录活跃块ID，重新加载时该块保持未写满，后续消息继续写入同一块；
+// WAL压缩按块已写入的最后一条SeqID判断记录是否已落盘，刷盘之后追加的消息仍保留在WAL中。
+// 开启刷盘或关闭WAL时，Close在关闭前写入所有活跃块。
+//
+// 写满的块由写入最后一条消息的一方落盘。落盘失败时消息已在WAL中并且可见，写入不返回错误，
+// 块登记为待重试，在下一个块写满、后台刷盘、Flush与Close时重新写入；在此之前WAL压缩保留块中的记录。
+
+// flushesOpenBlocks 是否在关闭时写入未写满的块
+func (s *Store) flushesOpenBlocks() bool {
+	return s.Config.BlockFlushInterval > 0 || s.Config.DisableWAL
+}
+
+// writeFullBlock 写入刚写满的块并按需压缩WAL，失败时记录日志，块留待重试
+func (s *Store) writeFullBlock(block *TimelineBlock) {
+	s.deferredMu.Lock()
+	s.deferredBlocks[block.BlockID] = block
+	s.deferredMu.Unlock()
+	if err := s.retryDeferredBlocks(); err != nil {
+		log.Printf("store %s: %v", s.StoreID, err)
+		return
+	}
+	if err := s.maybeCompactWAL(); err != nil {
+		log.Printf("store %s: failed to compact WAL: %v", s.StoreID, err)
+	}
+}
+
+// retryDeferredBlocks 写入落盘失败的写满块，返回第一个错误，写入失败的块继续等待重试
+// 已被删除或卸载的块不再写入
+func (s *Store) retryDeferredBlocks() error {
+	s.deferredMu.Lock()
+	blocks := make([]*TimelineBlock, 0, len(s.deferredBlocks))
+	for _, block := range s.deferredBlocks {
+		blocks = append(blocks, block)
+	}
+	s.deferredMu.Unlock()
+
+	var firstErr error
+	for _, block := range blocks {
+		s.indexMu.RLock()
+		loaded := s.TimelineBlocks[block.BlockID] == block
+		s.indexMu.RUnlock()
+		if loaded {
+			if err := s.writeTimelineBlock(block); err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to write full block %s, will retry: %w", block.BlockID, err)
+				}
+				continue
+			}
+		}
+		s.deferredMu.Lock()
+		if s.deferredBlocks[block.BlockID] == block {
+			delete(s.deferredBlocks, block.BlockID)
+		}
+		s.deferredMu.Unlock()
+	}
+	return firstErr
+}
+
+// PendingBlockWrites 返回写满后尚未落盘、等待重试的块数
+func (s *Store) PendingBlockWrites() int {
+	s.deferredMu.Lock()
+	defer s.deferredMu.Unlock()
+	return len(s.deferredBlocks)
+}
+
+// FlushOpenBlocks 将已加载Timeline中有未落盘消息的活跃块写入段文件，返回写入的块数
+// 同时重试写满后落盘失败的块
+func (s *Store) FlushOpenBlocks() (int, error) {
+	flushed := 0
+	firstErr := s.retryDeferredBlocks()
+	for _, tl := range s.ListTimelines() {
+		tl.mu.RLock()
+		block := tl.CurrentBlock
+		tl.mu.RUnlock()
+		if block == nil {
+			continue
+		}
+		written, err := s.flushOpenBlock(block)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to flush block %s: %w", block.BlockID, err)
+			}
+			continue
+		}
+		if written {
+			flushed++
+		}
+	}
+
+	// 刷盘后WAL中对应的记录已不再需要
+	if flushed > 0 {
+		if err := s.maybeCompactWAL(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return flushed, firstErr
+}
+
+// flushOpenBlock 块未写满且有未落盘的消息时写入段文件
+// 写满的块由写入消息的一方落盘；已被删除或损坏待恢复的块不写入
+func (s *Store) flushOpenBlock(block *TimelineBlock) (bool, error) {
+	block.mu.Lock()
+	defer block.mu.Unlock()
+
+	n := len(block.Messages)
+	if block.IsFull || n == 0 || s.segments.IsCorrupt(block.BlockID) {
+		return false, nil
+	}
+	if seqID, persisted := s.segments.PersistedSeqID(block.BlockID); persisted && block.Messages[n-1].SeqID <= seqID {
+		return false, nil
+	}
+	s.indexMu.RLock()
+	loaded := s.TimelineBlocks[block.BlockID] == block
+	s.indexMu.RUnlock()
+	if !loaded {
+		return false, nil
+	}
+
+	if err := s.writeTimelineBlockLocked(block); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// startBlockFlusher 配置了BlockFlushInterval时启动后台刷盘协程
+func (s *Store) startBlockFlusher() {
+	interval := s.Config.BlockFlushInterval
+	if interval <= 0 {
+		return
+	}
+
+	s.flushStop = make(chan struct{})
+	s.flushDone = make(chan struct{})
+	go func() {
+		defer close(s.flushDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := s.FlushOpenBlocks(); err != nil {
+					log.Printf("store %s: %v", s.StoreID, err)
+				}
+			case <-s.flushStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopBlockFlusher 停止后台刷盘协程
+func (s *Store) stopBlockFlusher() {
+	if s.flushStop == nil {
+		return
+	}
+	close(s.flushStop)
+	<-s.flushDone
+	s.flushStop = nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+)
+
+func addFlusherMessages(t *testing.T, store *Store, from, to int) {
+	t.Helper()
+	for i := from; i <= to; i++ {
+		if err := store.AddMessage("flush", 1, []byte(fmt.Sprintf("message-%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+}
+
+func TestBlockFlusherPersistsOpenBlockWithoutWAL(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: dir, DisableWAL: true, BlockFlushInterval: 10 * time.Millisecond}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	addFlusherMessages(t, store, 1, 3)
+
+	tl := store.GetOrCreateConvTimeline("flush")
+	blockID := tl.CurrentBlock.BlockID
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if seqID, ok := store.segments.PersistedSeqID(blockID); ok && seqID == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Open block was not flushed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// 不关闭原Store，直接重新打开以模拟进程崩溃
+	store.stopBlockFlusher()
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	assertConvMessages(t, reopened, "flush", 3)
+
+	// 刷盘写入的块仍是活跃块，新消息继续写入该块
+	addFlusherMessages(t, reopened, 4, 5)
+	reopenedTL := reopened.GetOrCreateConvTimeline("flush")
+	if len(reopenedTL.Blocks) != 1 || reopenedTL.CurrentBlock.BlockID != blockID {
+		t.Fatalf("Expected messages to be appended to block %s, got %d blocks", blockID, len(reopenedTL.Blocks))
+	}
+	assertConvMessages(t, reopened, "flush", 5)
+}
+
+func TestWALKeepsMessagesAppendedAfterFlush(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: dir, WALSyncPolicy: WALSyncAlways}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	addFlusherMessages(t, store, 1, 3)
+	if flushed, err := store.FlushOpenBlocks(); err != nil || flushed != 1 {
+		t.Fatalf("Expected the open block to be flushed, got %d %v", flushed, err)
+	}
+	if flushed, _ := store.FlushOpenBlocks(); flushed != 0 {
+		t.Errorf("Expected unchanged blocks to be skipped, got %d", flushed)
+	}
+
+	// 刷盘之后的消息只在WAL中，压缩时不能移除
+	addFlusherMessages(t, store, 4, 5)
+	if err := store.compactWAL(); err != nil {
+		t.Fatalf("Failed to compact WAL: %v", err)
+	}
+	records, _, err := readWALRecords(store.wal.path)
+	if err != nil || len(records) != 2 {
+		t.Fatalf("Expected 2 WAL records after compaction, got %d %v", len(records), err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	assertConvMessages(t, reopened, "flush", 5)
+
+	// WAL补齐后块写满时落盘
+	addFlusherMessages(t, reopened, 6, 11)
+	tl := reopened.GetOrCreateConvTimeline("flush")
+	if len(tl.Blocks) != 2 || !tl.Blocks[0].IsFull || tl.Blocks[0].Size != 10 {
+		t.Fatalf("Expected a full first block and a new block, got %d blocks", len(tl.Blocks))
+	}
+	if seqID, _ := reopened.segments.PersistedSeqID(tl.Blocks[0].BlockID); seqID != 10 {
+		t.Errorf("Expected the full block to be persisted up to SeqID 10, got %d", seqID)
+	}
+	assertConvMessages(t, reopened, "flush", 11)
+}
+
+func TestCloseFlushesOpenBlocksWithoutWAL(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: dir, DisableWAL: true}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	addFlusherMessages(t, store, 1, 4)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	assertConvMessages(t, reopened, "flush", 4)
+}
+
+func TestFullBlockWriteFailureKeepsMessage(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{TimelineMaxSize: 3, DataDir: dir, WALSyncPolicy: WALSyncAlways}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	addFlusherMessages(t, store, 1, 2)
+
+	// 以只读句柄替换活跃段文件，使写满块的落盘失败
+	store.segments.mu.Lock()
+	active := store.segments.active
+	file := active.file
+	readOnly, err := os.Open(active.path)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	active.file = readOnly
+	store.segments.mu.Unlock()
+
+	// 消息已写入WAL，落盘失败不返回错误，消息可见且块等待重试
+	addFlusherMessages(t, store, 3, 3)
+	assertConvMessages(t, store, "flush", 3)
+	if store.PendingBlockWrites() != 1 || store.HealthStatus() != HealthStatusDegraded {
+		t.Fatalf("Expected the full block to wait for a retry, got %d pending and %s", store.PendingBlockWrites(), store.HealthStatus())
+	}
+	blockID := store.GetOrCreateConvTimeline("flush").Blocks[0].BlockID
+	if _, persisted := store.segments.PersistedSeqID(blockID); persisted {
+		t.Fatal("Expected the full block not to be persisted")
+	}
+
+	// 段文件恢复后，下一个写满的块一并写入之前失败的块
+	store.segments.mu.Lock()
+	active.file = file
+	store.segments.mu.Unlock()
+	readOnly.Close()
+	addFlusherMessages(t, store, 4, 6)
+	if store.PendingBlockWrites() != 0 || store.HealthStatus() != HealthStatusHealthy {
+		t.Fatalf("Expected the deferred block to be written, got %d pending", store.PendingBlockWrites())
+	}
+	if seqID, persisted := store.segments.PersistedSeqID(blockID); !persisted || seqID != 3 {
+		t.Fatalf("Expected the deferred block to be persisted through SeqID 3, got %d %v", seqID, persisted)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	assertConvMessages(t, reopened, "flush", 6)
+}
@@ -0,0 +1,146 @@
+package storage
+
+import "fmt"
+
+// 块的按需加载
+// 打开Timeline时只有活跃块读入内存，已写满的块只建立占位：SeqID/时间/HLC范围、条数、校验和
+// 与布隆过滤器取自段文件索引（见BlockLocation），消息不读入内存。
+// 按SeqID范围读取（GetConvMessages、GetMessagesAfterCheckpoint等）时根据块索引跳过范围外的块，
+// 范围内的块从段文件读取后放入按字节数限制的LRU缓存，不挂到块上，
+// 因此打开再长的会话，常驻内存的也只有活跃块与缓存中的块。
+// 保留策略、归档等需要修改或搬走块内消息的操作先调用loadBlocks把块读回内存。
+// 已分层到对象存储的块（见tiering.go）按存根建立占位，读取时从对象存储下载，同样经过块缓存。
+
+const defaultBlockCacheSize = 64 * 1024 * 1024
+
+// blockLoader 读取块的全部消息，视图遍历到未加载的块时调用
+type blockLoader func(block *TimelineBlock) ([]*Message, error)
+
+// newLazyBlock 为已落盘的块建立未加载消息的占位，块既不在段文件中也未分层时返回nil
+func (s *Store) newLazyBlock(blockID string) *TimelineBlock {
+	location, exists := s.segments.Location(blockID)
+	if !exists {
+		return s.newTieredBlock(blockID)
+	}
+	return &TimelineBlock{
+		BlockID:   blockID,
+		StoreID:   s.StoreID,
+		SegmentID: location.SegmentID,
+		Offset:    location.Offset,
+		Size:      location.Index.Count,
+		IsFull:    true,
+		Checksum:  location.Checksum,
+		Index:     location.Index,
+		Filters:   location.Filters,
+		lazy:      true,
+	}
+}
+
+// readBlockMessages 读取块的消息，已加载的块返回内存中消息的快照，未加载的块经块缓存从段文件读取
+func (s *Store) readBlockMessages(block *TimelineBlock) ([]*Message, error) {
+	block.mu.RLock()
+	if !block.lazy {
+		messages := block.Messages[:len(block.Messages):len(block.Messages)]
+		block.mu.RUnlock()
+		return messages, nil
+	}
+	blockID := block.BlockID
+	block.mu.RUnlock()
+
+	location, exists := s.segments.Location(blockID)
+	if !exists {
+		return s.readTieredBlockMessages(blockID)
+	}
+	// 块重写后位置改变，旧位置的缓存条目不会再被命中，由LRU淘汰
+	key := fmt.Sprintf("%s@%d:%d", blockID, location.SegmentID, location.Offset)
+	if cached, ok := s.blockCache.Get(key); ok {
+		return cached.([]*Message), nil
+	}
+
+	messages, exists, err := s.segments.ReadBlock(blockID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("block %s not found in segments", blockID)
+	}
+	s.blockCache.Set(key, messages, 0)
+	return messages, nil
+}
+
+// BlockMessages 返回块的全部消息，未加载的块从段文件或对象存储读取，不改变块的加载状态
+func (s *Store) BlockMessages(block *TimelineBlock) ([]*Message, error) {
+	return s.readBlockMessages(block)
+}
+
+// readTieredBlockMessages 经块缓存读取已分层块的消息，块重新写入段文件时缓存条目不再被命中
+func (s *Store) readTieredBlockMessages(blockID string) ([]*Message, error) {
+	key := blockID + "@tier"
+	if cached, ok := s.blockCache.Get(key); ok {
+		if _, tiered := s.tieredStub(blockID); tiered {
+			return cached.([]*Message), nil
+		}
+	}
+	messages, _, exists, err := s.readTieredBlock(blockID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("block %s not found in segments", blockID)
+	}
+	s.blockCache.Set(key, messages, 0)
+	return messages, nil
+}
+
+// loadBlockLocked 把未加载块的消息与过滤器读入内存，调用方持有块的写锁
+func (s *Store) loadBlockLocked(block *TimelineBlock) error {
+	if !block.lazy {
+		return nil
+	}
+	messages, meta, exists, err := s.segments.ReadBlockWithMeta(block.BlockID)
+	if err == nil && !exists {
+		messages, meta, exists, err = s.readTieredBlock(block.BlockID)
+	}
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("block %s not found in segments", block.BlockID)
+	}
+	block.Messages = messages
+	block.Filters = meta.Filters
+	block.lazy = false
+	return nil
+}
+
+// loadBlocks 把块中未加载的消息全部读入内存，供需要修改或导出完整消息的操作使用
+// 调用方可以持有Timeline锁
+func (s *Store) loadBlocks(blocks []*TimelineBlock) error {
+	for _, block := range blocks {
+		block.mu.Lock()
+		err := s.loadBlockLocked(block)
+		block.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to load block %s: %w", block.BlockID, err)
+		}
+	}
+	return nil
+}
+
+// blockSnapshot 复制块的元信息及其全部消息，未加载的块从段文件读取
+func (s *Store) blockSnapshot(block *TimelineBlock) (*TimelineBlock, []*Message, error) {
+	block.mu.RLock()
+	snapshot := block.snapshotLocked()
+	messages := append([]*Message(nil), block.Messages...)
+	lazy := block.lazy
+	block.mu.RUnlock()
+
+	// 未加载的块已写满，消息不会再变化
+	if lazy {
+		var err error
+		if messages, err = s.readBlockMessages(block); err != nil {
+			return nil, nil, err
+		}
+	}
+	return snapshot, messages, nil
+}
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// reopenLazyStore 写入n条消息（每块10条）后关闭，返回重新打开的Store
+func reopenLazyStore(t *testing.T, n int, cacheSize int64) *Store {
+	t.Helper()
+	// 去重索引只保留最近5条，重新打开时不需要读取已写满的块
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir(), BlockCacheSize: cacheSize, DedupMaxEntries: 5}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= n; i++ {
+		if _, err := store.AppendMessage("lazy", 1, []byte(fmt.Sprintf("message-%d", i)), []string{"user_lazy"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+	return reopened
+}
+
+// residentBlocks 统计Timeline中消息已在内存中的块数
+func residentBlocks(tl *Timeline) int {
+	tl.mu.RLock()
+	defer tl.mu.RUnlock()
+	resident := 0
+	for _, block := range tl.Blocks {
+		block.mu.RLock()
+		if !block.lazy {
+			resident++
+		}
+		block.mu.RUnlock()
+	}
+	return resident
+}
+
+func assertSeqRange(t *testing.T, messages []*Message, from, to int64) {
+	t.Helper()
+	if int64(len(messages)) != to-from+1 {
+		t.Fatalf("Expected SeqIDs %d..%d, got %d messages", from, to, len(messages))
+	}
+	for i, msg := range messages {
+		if msg.SeqID != from+int64(i) || string(msg.Data) != fmt.Sprintf("message-%d", msg.convSeqID()) {
+			t.Fatalf("Unexpected message %d: SeqID %d %q", i, msg.SeqID, msg.Data)
+		}
+	}
+}
+
+func TestReadsLoadOnlyBlocksInRange(t *testing.T) {
+	store := reopenLazyStore(t, 105, 0)
+	convTL := store.GetOrCreateConvTimeline("lazy")
+	if len(convTL.Blocks) != 11 || residentBlocks(convTL) != 1 {
+		t.Fatalf("Expected only the open block of 11 to be resident, got %d of %d", residentBlocks(convTL), len(convTL.Blocks))
+	}
+
+	// 最新一页只在活跃块中
+	messages, err := store.GetConvMessages("lazy", 5, 0)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	assertSeqRange(t, messages, 101, 105)
+	if entries := store.blockCache.Stats().EntryCount; entries != 0 {
+		t.Errorf("Expected no block to be read, got %d", entries)
+	}
+
+	messages, err = store.GetConvMessages("lazy", 10, 51)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	assertSeqRange(t, messages, 41, 50)
+	if entries := store.blockCache.Stats().EntryCount; entries != 1 {
+		t.Errorf("Expected only the block of SeqIDs 41..50 to be read, got %d blocks", entries)
+	}
+
+	// 跨越两个块的一页
+	messages, err = store.GetConvMessages("lazy", 10, 36)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	assertSeqRange(t, messages, 26, 35)
+
+	store.UpdateUserCheckpoint("user_lazy", 95)
+	messages, err = store.GetMessagesAfterCheckpoint("user_lazy")
+	if err != nil {
+		t.Fatalf("Failed to read messages after checkpoint: %v", err)
+	}
+	assertSeqRange(t, messages, 96, 105)
+	if entries := store.blockCache.Stats().EntryCount; entries != 4 {
+		t.Errorf("Expected 4 blocks to be read in total, got %d", entries)
+	}
+	userTL := store.GetOrCreateUserTimeline("user_lazy")
+	if residentBlocks(convTL) != 1 || residentBlocks(userTL) != 1 {
+		t.Error("Expected reads not to keep blocks resident")
+	}
+}
+
+func TestUserMessagesAfterPagesAcrossUnorderedLazyBlocks(t *testing.T) {
+	store := reopenLazyStore(t, 35, 0)
+	userTL := store.GetOrCreateUserTimeline("user_lazy")
+	if len(userTL.Blocks) != 4 || residentBlocks(userTL) != 1 {
+		t.Fatalf("Expected 3 lazy blocks and the open block, got %d resident of %d", residentBlocks(userTL), len(userTL.Blocks))
+	}
+
+	// 块在Timeline中不按SeqID排列时（如从WAL恢复后），分页仍按SeqID连续返回
+	userTL.mu.Lock()
+	userTL.Blocks[0], userTL.Blocks[1] = userTL.Blocks[1], userTL.Blocks[0]
+	userTL.rebuildViewLocked()
+	userTL.mu.Unlock()
+
+	var afterSeq int64
+	for page, want := range [][2]int64{{1, 8}, {9, 16}, {17, 24}, {25, 32}, {33, 35}} {
+		messages, more := store.GetUserMessagesAfter("user_lazy", afterSeq, 8)
+		assertSeqRange(t, messages, want[0], want[1])
+		if wantMore := want[1] < 35; more != wantMore {
+			t.Errorf("Page %d: expected more=%v, got %v", page, wantMore, more)
+		}
+		afterSeq = want[1]
+	}
+
+	// 排在前面的11..20已读取，取满后不再读取SeqID更大的21..30
+	store.blockCache.Clear()
+	messages, _ := store.GetUserMessagesAfter("user_lazy", 0, 5)
+	assertSeqRange(t, messages, 1, 5)
+	if entries := store.blockCache.Stats().EntryCount; entries != 2 {
+		t.Errorf("Expected only the blocks of SeqIDs 1..20 to be read, got %d blocks", entries)
+	}
+}
+
+func TestConvMessagesPagesAcrossUnorderedBlocksAfterRecovery(t *testing.T) {
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir(), WALSyncPolicy: WALSyncAlways, DedupMaxEntries: 5}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 35; i++ {
+		if err := store.AddMessage("lazy", 1, []byte(fmt.Sprintf("message-%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	// 不关闭原Store，31..35只在WAL中，重新打开时恢复为活跃块
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	convTL := reopened.GetOrCreateConvTimeline("lazy")
+	if len(convTL.Blocks) != 4 || residentBlocks(convTL) != 1 {
+		t.Fatalf("Expected 3 lazy blocks and the recovered block, got %d resident of %d", residentBlocks(convTL), len(convTL.Blocks))
+	}
+
+	// 块在Timeline中不按SeqID排列：恢复的活跃块在最前，未加载的块顺序打乱
+	convTL.mu.Lock()
+	blocks := convTL.Blocks
+	convTL.Blocks = []*TimelineBlock{blocks[3], blocks[1], blocks[0], blocks[2]}
+	convTL.rebuildViewLocked()
+	convTL.mu.Unlock()
+
+	var before int64
+	for _, want := range [][2]int64{{28, 35}, {20, 27}, {12, 19}, {4, 11}, {1, 3}} {
+		messages, err := reopened.GetConvMessages("lazy", 8, before)
+		if err != nil {
+			t.Fatalf("Failed to read messages: %v", err)
+		}
+		assertSeqRange(t, messages, want[0], want[1])
+		before = want[0]
+	}
+
+	// 排在最后的21..30先被读取，取满后不再读取SeqID更小的1..20
+	reopened.blockCache.Clear()
+	messages, err := reopened.GetConvMessages("lazy", 5, 0)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	assertSeqRange(t, messages, 31, 35)
+	if entries := reopened.blockCache.Stats().EntryCount; entries != 1 {
+		t.Errorf("Expected only the block of SeqIDs 21..30 to be read, got %d blocks", entries)
+	}
+}
+
+func TestBlockCacheIsBounded(t *testing.T) {
+	const cacheSize = 4096
+	store := reopenLazyStore(t, 300, cacheSize)
+
+	var before int64
+	for {
+		messages, err := store.GetConvMessages("lazy", 20, before)
+		if err != nil {
+			t.Fatalf("Failed to read messages: %v", err)
+		}
+		if len(messages) == 0 {
+			break
+		}
+		if size := store.blockCache.Size(); size > cacheSize {
+			t.Fatalf("Block cache grew to %d bytes, limit %d", size, cacheSize)
+		}
+		before = messages[0].SeqID
+	}
+	if before != 1 {
+		t.Errorf("Expected paging to reach SeqID 1, stopped at %d", before)
+	}
+}
+
+func TestWholeTimelineOperationsLoadLazyBlocks(t *testing.T) {
+	store := reopenLazyStore(t, 45, 0)
+	store.GetOrCreateConvTimeline("lazy")
+
+	// 保留策略裁剪未加载的块
+	manager := NewRetentionManager(store, nil, &RetentionPolicy{MaxMessages: 25})
+	result, err := manager.RunOnce(context.Background())
+	if err != nil || result.MessagesDeleted != 20 {
+		t.Fatalf("Expected 20 messages to be deleted, got %+v %v", result, err)
+	}
+	messages, err := store.GetConvMessages("lazy", 100, 0)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	assertSeqRange(t, messages, 21, 45)
+
+	// 编辑与查询访问未加载块中的消息
+	if _, err := store.EditMessage("lazy", 1, 22, []byte("edited"), nil); err != nil {
+		t.Fatalf("Failed to edit message in a lazy block: %v", err)
+	}
+	queried, err := store.Query(&Query{TimelineID: "lazy", AfterSeqID: 30, BeforeSeqID: 33})
+	if err != nil || len(queried.Messages) != 2 {
+		t.Fatalf("Expected 2 queried messages, got %d %v", len(queried.Messages), err)
+	}
+
+	info, err := store.ArchiveTimeline("conv", "lazy")
+	if err != nil || info.Messages != 26 {
+		t.Fatalf("Expected all 26 records to be archived, got %+v %v", info, err)
+	}
+}
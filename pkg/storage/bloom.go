@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+)
+
+// 块布隆过滤器
+// 开启StoreConfig.BlockBloomFilters后，块落盘时为块内消息的SenderID、提及的用户UUID
+// 和回复的消息SeqID各生成一个布隆过滤器，随块写入段文件。按发送者、提及用户或回复的消息查询时，
+// 过滤器判定不包含的块直接跳过；布隆过滤器没有假阴性，跳过的块中一定没有匹配的消息
+
+const (
+	// defaultBloomBitsPerKey 每个键占用的位数，对应约1%的误判率
+	defaultBloomBitsPerKey = 10
+	maxBloomHashes         = 30
+)
+
+// bloomFilter 布隆过滤器，字段导出以便随段记录gob编码
+type bloomFilter struct {
+	Bits   []uint64
+	Hashes uint8
+}
+
+func newBloomFilter(keys, bitsPerKey int) *bloomFilter {
+	if bitsPerKey <= 0 {
+		bitsPerKey = defaultBloomBitsPerKey
+	}
+	bits := keys * bitsPerKey
+	if bits < 64 {
+		bits = 64
+	}
+	// 最优哈希函数个数为 bitsPerKey * ln2
+	hashes := bitsPerKey * 69 / 100
+	if hashes < 1 {
+		hashes = 1
+	}
+	if hashes > maxBloomHashes {
+		hashes = maxBloomHashes
+	}
+	return &bloomFilter{Bits: make([]uint64, (bits+63)/64), Hashes: uint8(hashes)}
+}
+
+// bloomHash 由一次FNV哈希派生双重哈希所需的两个值
+func bloomHash(key []byte) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write(key)
+	sum := h.Sum64()
+	return sum, (sum>>32 | sum<<32) | 1
+}
+
+func (f *bloomFilter) add(key []byte) {
+	h1, h2 := bloomHash(key)
+	m := uint64(len(f.Bits)) * 64
+	for i := uint64(0); i < uint64(f.Hashes); i++ {
+		bit := (h1 + i*h2) % m
+		f.Bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// mayContain 键可能存在时返回true，返回false时键一定不存在
+func (f *bloomFilter) mayContain(key []byte) bool {
+	if len(f.Bits) == 0 {
+		return true
+	}
+	h1, h2 := bloomHash(key)
+	m := uint64(len(f.Bits)) * 64
+	for i := uint64(0); i < uint64(f.Hashes); i++ {
+		bit := (h1 + i*h2) % m
+		if f.Bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func senderBloomKey(senderID uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, senderID)
+}
+
+func replyBloomKey(seqID int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(seqID))
+}
+
+// blockFilters 块内消息的发送者、提及用户与回复消息过滤器
+// Replies在之前写入的段中不存在，解码后为nil，此时不按回复关系跳块
+type blockFilters struct {
+	Senders  *bloomFilter
+	Mentions *bloomFilter
+	Replies  *bloomFilter
+}
+
+// buildBlockFilters 按块内消息生成过滤器，capacity为块的消息上限，
+// 未写满的块之后追加的消息也能保持预期的误判率
+func buildBlockFilters(messages []*Message, capacity, bitsPerKey int) *blockFilters {
+	mentions := 0
+	for _, msg := range messages {
+		mentions += len(msg.Mentions)
+	}
+	filters := &blockFilters{
+		Senders:  newBloomFilter(max(len(messages), capacity), bitsPerKey),
+		Mentions: newBloomFilter(max(mentions, capacity), bitsPerKey),
+		Replies:  newBloomFilter(capacity, bitsPerKey),
+	}
+	for _, msg := range messages {
+		filters.add(msg)
+	}
+	return filters
+}
+
+// add 将消息计入过滤器，过滤器为nil时忽略。调用方持有块锁
+func (f *blockFilters) add(msg *Message) {
+	if f == nil {
+		return
+	}
+	f.Senders.add(senderBloomKey(msg.SenderID))
+	for _, mention := range msg.Mentions {
+		f.Mentions.add([]byte(mention))
+	}
+	if msg.ReplyToSeqID > 0 && f.Replies != nil {
+		f.Replies.add(replyBloomKey(msg.ReplyToSeqID))
+	}
+}
+
+// mayMatch 块中是否可能有满足发送者、提及与回复条件的消息，没有过滤器时总是返回true
+func (f *blockFilters) mayMatch(q *compiledQuery) bool {
+	if f == nil {
+		return true
+	}
+	if q.senderID != nil && !f.Senders.mayContain(senderBloomKey(*q.senderID)) {
+		return false
+	}
+	if q.mention != nil && !f.Mentions.mayContain([]byte(*q.mention)) {
+		return false
+	}
+	return q.replyTo == nil || f.Replies == nil || f.Replies.mayContain(replyBloomKey(*q.replyTo))
+}
@@ -0,0 +1,167 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(1000, defaultBloomBitsPerKey)
+	for i := 0; i < 1000; i++ {
+		filter.add([]byte(fmt.Sprintf("key-%d", i)))
+	}
+	for i := 0; i < 1000; i++ {
+		if !filter.mayContain([]byte(fmt.Sprintf("key-%d", i))) {
+			t.Fatalf("False negative for key-%d", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if filter.mayContain([]byte(fmt.Sprintf("other-%d", i))) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("Expected about 1%% false positives, got %d of 10000", falsePositives)
+	}
+}
+
+// writeBloomTestMessages 写入3个块：发送者7只出现在第二个块，u-alice只在第三个块被提及
+func writeBloomTestMessages(t *testing.T, store *Store) {
+	t.Helper()
+	for i := 0; i < 12; i++ {
+		source := &Message{SenderID: uint32(i%2 + 1), Data: []byte{byte(i)}}
+		if i == 5 {
+			source.SenderID = 7
+		}
+		if i == 9 || i == 11 {
+			source.Mentions = []string{"u-alice", "u-bob"}
+		}
+		if _, _, err := store.SubmitMessage("conv_bloom", source, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+}
+
+func TestGetMessagesBySenderSkipsBlocks(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 4, DataDir: dir, BlockBloomFilters: true}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	writeBloomTestMessages(t, store)
+
+	messages, err := store.GetMessagesBySender("conv_bloom", 7, 0, 0)
+	if err != nil {
+		t.Fatalf("GetMessagesBySender failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].SeqID != 6 {
+		t.Fatalf("Expected only message 6 from sender 7, got %d messages", len(messages))
+	}
+	result, err := store.Query(&Query{TimelineID: "conv_bloom", Filters: map[string]interface{}{"sender_id": 7}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.ScannedBlocks != 1 || result.SkippedBlocks != 2 {
+		t.Errorf("Expected bloom filters to skip 2 of 3 blocks, got %+v", result)
+	}
+
+	messages, err = store.GetMessagesMentioning("conv_bloom", "u-alice", 10, 0)
+	if err != nil {
+		t.Fatalf("GetMessagesMentioning failed: %v", err)
+	}
+	if len(messages) != 1 || messages[0].SeqID != 12 {
+		t.Errorf("Expected message 12 mentioning u-alice after SeqID 10, got %d messages", len(messages))
+	}
+	store.Close()
+
+	// 过滤器随块持久化，重启后仍能跳块
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	result, err = reopened.Query(&Query{TimelineID: "conv_bloom", Filters: map[string]interface{}{"mention": "u-bob"}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if len(result.Messages) != 2 || result.ScannedBlocks != 1 {
+		t.Errorf("Expected 2 messages from 1 block after reopen, got %d from %d blocks", len(result.Messages), result.ScannedBlocks)
+	}
+	if msg := result.Messages[0]; len(msg.Mentions) != 2 || msg.Mentions[0] != "u-alice" {
+		t.Errorf("Expected mentions to be persisted, got %v", msg.Mentions)
+	}
+}
+
+func TestGetMessagesBySenderWithoutFilters(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 4, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	writeBloomTestMessages(t, store)
+	// 未写满的块没有过滤器，总是被扫描
+	store.SubmitMessage("conv_bloom", &Message{SenderID: 7, Data: []byte("tail")}, nil)
+
+	messages, err := store.GetMessagesBySender("conv_bloom", 7, 0, 0)
+	if err != nil {
+		t.Fatalf("GetMessagesBySender failed: %v", err)
+	}
+	if len(messages) != 2 || messages[0].SeqID != 6 || messages[1].SeqID != 13 {
+		t.Errorf("Expected messages 6 and 13 from sender 7, got %d messages", len(messages))
+	}
+	result, _ := store.Query(&Query{TimelineID: "conv_bloom", Filters: map[string]interface{}{"sender_id": 7}})
+	if result.SkippedBlocks != 0 {
+		t.Errorf("Expected no blocks to be skipped without bloom filters, got %d", result.SkippedBlocks)
+	}
+}
+
+func TestGetThreadRepliesSkipsBlocks(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 4, DataDir: dir, BlockBloomFilters: true}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	// 回复消息2的记录只在第一和第三个块中
+	for i := 0; i < 12; i++ {
+		source := &Message{SenderID: 1, Data: []byte{byte(i)}}
+		if i == 2 || i == 3 || i == 10 {
+			source.ReplyToSeqID = 2
+		}
+		if _, _, err := store.SubmitMessage("conv_thread", source, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	replies, err := store.GetThreadReplies("conv_thread", 2, 0, 0)
+	if err != nil {
+		t.Fatalf("GetThreadReplies failed: %v", err)
+	}
+	if len(replies) != 3 || replies[0].SeqID != 3 || replies[2].SeqID != 11 {
+		t.Fatalf("Expected replies 3, 4 and 11, got %d messages", len(replies))
+	}
+	replies, _ = store.GetThreadReplies("conv_thread", 2, 3, 1)
+	if len(replies) != 1 || replies[0].SeqID != 4 {
+		t.Errorf("Expected reply 4 after SeqID 3, got %d messages", len(replies))
+	}
+	result, err := store.Query(&Query{TimelineID: "conv_thread", AfterSeqID: 2, Filters: map[string]interface{}{"reply_to": 2}})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.ScannedBlocks != 2 || result.SkippedBlocks != 1 {
+		t.Errorf("Expected bloom filters to skip 1 of 3 blocks, got %+v", result)
+	}
+	store.Close()
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	replies, _ = reopened.GetThreadReplies("conv_thread", 2, 0, 0)
+	if len(replies) != 3 || replies[0].ReplyToSeqID != 2 {
+		t.Errorf("Expected reply relation to be persisted, got %d messages", len(replies))
+	}
+}
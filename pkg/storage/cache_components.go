@@ -2,10 +2,15 @@ package storage
 
 import (
 	"bytes"
+	"cmp"
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"log"
+	"slices"
 	"sync"
 	"time"
 )
@@ -162,137 +167,267 @@ func (js *JSONSerializer) Deserialize(data []byte, target interface{}) error {
 	return json.Unmarshal(data, target)
 }
 
-// BatchManager 批处理管理器
+// BatchManager 批处理管理器，负责WriteBack策略下L2/L3的延迟写入
+// 同一key在两次刷写之间的多次操作合并为最后一次，刷写按各key最后一次操作的先后顺序执行。
+// 待写数据只保存在内存中：Close会刷写全部待写数据，进程崩溃时尚未刷写的写入丢失（L1中的值同样不保留）。
+// 写入失败的操作留到下一次刷写重试，期间有新操作时以新操作为准；超过MaxRetries后记录日志并丢弃
 type BatchManager struct {
 	cacheManager *MultiLevelCacheManager
-	batchQueue   chan *BatchItem
-	stopCh       chan struct{}
-	wg           sync.WaitGroup
-	batchSize    int
-	flushInterval time.Duration
+	config       BatchManagerConfig
+
+	mu       sync.Mutex
+	pending  map[string]*BatchItem
+	inflight map[string]bool // 正在刷写的key
+	seq      uint64
+	stats    BatchStats
+
+	flushMu  sync.Mutex // 保证刷写串行，同一key的操作按顺序落到L2/L3
+	flushCh  chan struct{}
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// BatchManagerConfig 延迟写入配置
+type BatchManagerConfig struct {
+	BatchSize     int           // 待写key达到该数量时立即刷写，默认100
+	FlushInterval time.Duration // 定时刷写间隔，默认5秒
+	MaxRetries    int           // 单个操作写入失败后的最大重试次数，默认3
+}
+
+// DefaultBatchManagerConfig 默认延迟写入配置
+func DefaultBatchManagerConfig() BatchManagerConfig {
+	return BatchManagerConfig{
+		BatchSize:     100,
+		FlushInterval: 5 * time.Second,
+		MaxRetries:    3,
+	}
+}
+
+func (c BatchManagerConfig) withDefaults() BatchManagerConfig {
+	defaults := DefaultBatchManagerConfig()
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaults.FlushInterval
+	}
+	if c.MaxRetries < 0 {
+		c.MaxRetries = 0
+	}
+	return c
 }
 
 // BatchItem 批处理项
 type BatchItem struct {
-	Key   string
-	Value interface{}
-	TTL   time.Duration
-	Op    string // "set" or "delete"
+	Key      string
+	Value    interface{} // set操作为序列化后未压缩的值
+	TTL      time.Duration
+	Op       string // "set" or "delete"
+	Attempts int    // 已失败的写入次数
+
+	seq uint64
+}
+
+// BatchStats 延迟写入统计
+type BatchStats struct {
+	Pending     int   `json:"pending"`
+	Flushed     int64 `json:"flushed"`      // 成功写入的操作数
+	Coalesced   int64 `json:"coalesced"`    // 被同一key的后续操作覆盖的操作数
+	Retries     int64 `json:"retries"`      // 失败后重试的次数
+	DeadLetters int64 `json:"dead_letters"` // 超过重试次数被丢弃的操作数
 }
 
 // NewBatchManager 创建批处理管理器
 func NewBatchManager(cacheManager *MultiLevelCacheManager) *BatchManager {
+	return NewBatchManagerWithConfig(cacheManager, DefaultBatchManagerConfig())
+}
+
+// NewBatchManagerWithConfig 按配置创建批处理管理器
+func NewBatchManagerWithConfig(cacheManager *MultiLevelCacheManager, config BatchManagerConfig) *BatchManager {
 	bm := &BatchManager{
-		cacheManager:  cacheManager,
-		batchQueue:    make(chan *BatchItem, 10000),
-		stopCh:        make(chan struct{}),
-		batchSize:     100,
-		flushInterval: 5 * time.Second,
+		cacheManager: cacheManager,
+		config:       config.withDefaults(),
+		pending:      make(map[string]*BatchItem),
+		inflight:     make(map[string]bool),
+		flushCh:      make(chan struct{}, 1),
+		stopCh:       make(chan struct{}),
 	}
-	
+
 	// 启动批处理工作协程
 	bm.wg.Add(1)
 	go bm.batchWorker()
-	
+
 	return bm
 }
 
 // ScheduleWrite 调度写入
 func (bm *BatchManager) ScheduleWrite(key string, value interface{}, ttl time.Duration) {
-	item := &BatchItem{
+	bm.schedule(&BatchItem{
 		Key:   key,
 		Value: value,
 		TTL:   ttl,
 		Op:    "set",
-	}
-	
-	select {
-	case bm.batchQueue <- item:
-	default:
-		// 队列满时直接执行
-		bm.executeItem(item)
-	}
+	})
 }
 
 // ScheduleDelete 调度删除
 func (bm *BatchManager) ScheduleDelete(key string) {
-	item := &BatchItem{
+	bm.schedule(&BatchItem{
 		Key: key,
 		Op:  "delete",
+	})
+}
+
+// CancelWrite 取消key尚未完成的延迟写入，正在刷写的写入改为随后删除，避免已删除的key被写回
+func (bm *BatchManager) CancelWrite(key string) {
+	bm.mu.Lock()
+	item, pending := bm.pending[key]
+	inflight := bm.inflight[key]
+	if !inflight {
+		if pending && item.Op == "set" {
+			delete(bm.pending, key)
+			bm.stats.Coalesced++
+		}
+		bm.mu.Unlock()
+		return
 	}
-	
-	select {
-	case bm.batchQueue <- item:
-	default:
-		// 队列满时直接执行
-		bm.executeItem(item)
+	bm.mu.Unlock()
+	bm.ScheduleDelete(key)
+}
+
+func (bm *BatchManager) schedule(item *BatchItem) {
+	bm.mu.Lock()
+	bm.seq++
+	item.seq = bm.seq
+	if _, exists := bm.pending[item.Key]; exists {
+		bm.stats.Coalesced++
 	}
+	bm.pending[item.Key] = item
+	full := len(bm.pending) >= bm.config.BatchSize
+	bm.mu.Unlock()
+
+	if full {
+		select {
+		case bm.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush 立即刷写全部待写操作，返回本次失败的操作数
+func (bm *BatchManager) Flush() int {
+	bm.flushMu.Lock()
+	defer bm.flushMu.Unlock()
+
+	bm.mu.Lock()
+	batch := make([]*BatchItem, 0, len(bm.pending))
+	for key, item := range bm.pending {
+		batch = append(batch, item)
+		bm.inflight[key] = true
+	}
+	clear(bm.pending)
+	bm.mu.Unlock()
+
+	slices.SortFunc(batch, func(a, b *BatchItem) int {
+		return cmp.Compare(a.seq, b.seq)
+	})
+
+	failed := 0
+	for _, item := range batch {
+		err := bm.executeItem(item)
+
+		bm.mu.Lock()
+		delete(bm.inflight, item.Key)
+		switch {
+		case err == nil:
+			bm.stats.Flushed++
+		case bm.pending[item.Key] != nil:
+			// 刷写期间有新操作，失败的旧操作作废
+			bm.stats.Coalesced++
+		case item.Attempts >= bm.config.MaxRetries:
+			bm.stats.DeadLetters++
+			log.Printf("write-back dead letter: %s %s after %d attempts: %v", item.Op, item.Key, item.Attempts+1, err)
+		default:
+			item.Attempts++
+			bm.stats.Retries++
+			bm.pending[item.Key] = item
+		}
+		bm.mu.Unlock()
+
+		if err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Stats 返回延迟写入统计
+func (bm *BatchManager) Stats() BatchStats {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	stats := bm.stats
+	stats.Pending = len(bm.pending)
+	return stats
 }
 
-// Stop 停止批处理管理器
+// Stop 停止批处理管理器，刷写全部待写操作，失败的操作重试到成功或超过重试次数
 func (bm *BatchManager) Stop() {
-	close(bm.stopCh)
-	bm.wg.Wait()
+	bm.stopOnce.Do(func() {
+		close(bm.stopCh)
+		bm.wg.Wait()
+		for {
+			bm.Flush()
+			if bm.Stats().Pending == 0 {
+				return
+			}
+		}
+	})
 }
 
 // batchWorker 批处理工作协程
 func (bm *BatchManager) batchWorker() {
 	defer bm.wg.Done()
-	
-	batch := make([]*BatchItem, 0, bm.batchSize)
-	ticker := time.NewTicker(bm.flushInterval)
+
+	ticker := time.NewTicker(bm.config.FlushInterval)
 	defer ticker.Stop()
-	
+
 	for {
 		select {
 		case <-bm.stopCh:
-			// 处理剩余批次
-			if len(batch) > 0 {
-				bm.executeBatch(batch)
-			}
 			return
-			
-		case item := <-bm.batchQueue:
-			batch = append(batch, item)
-			if len(batch) >= bm.batchSize {
-				bm.executeBatch(batch)
-				batch = batch[:0] // 重置切片
-			}
-			
+		case <-bm.flushCh:
+			bm.Flush()
 		case <-ticker.C:
-			if len(batch) > 0 {
-				bm.executeBatch(batch)
-				batch = batch[:0] // 重置切片
-			}
+			bm.Flush()
 		}
 	}
 }
 
-// executeBatch 执行批次
-func (bm *BatchManager) executeBatch(batch []*BatchItem) {
-	for _, item := range batch {
-		bm.executeItem(item)
-	}
-}
-
 // executeItem 执行单个项目
-func (bm *BatchManager) executeItem(item *BatchItem) {
+func (bm *BatchManager) executeItem(item *BatchItem) error {
+	var errs []error
 	switch item.Op {
 	case "set":
-		// 写入L2和L3缓存
-		if bm.cacheManager.l2Cache != nil {
-			bm.cacheManager.l2Cache.Set(item.Key, item.Value, item.TTL)
+		// 按L2和L3各自的压缩策略写入
+		data, ok := item.Value.([]byte)
+		if !ok {
+			return fmt.Errorf("unexpected write-back value type %T", item.Value)
 		}
-		if bm.cacheManager.l3Cache != nil {
-			bm.cacheManager.l3Cache.Set(item.Key, item.Value, item.TTL)
+		for _, level := range []CacheLevel{L2Cache, L3Cache} {
+			if err := bm.cacheManager.setLevel(level, item.Key, data, item.TTL); err != nil {
+				errs = append(errs, err)
+			}
 		}
-		
+
 	case "delete":
-		if bm.cacheManager.l2Cache != nil {
-			bm.cacheManager.l2Cache.Delete(item.Key)
-		}
-		if bm.cacheManager.l3Cache != nil {
-			bm.cacheManager.l3Cache.Delete(item.Key)
+		for _, cache := range []Cache{bm.cacheManager.l2Cache, bm.cacheManager.l3Cache} {
+			if cache != nil {
+				if err := cache.Delete(item.Key); err != nil {
+					errs = append(errs, err)
+				}
+			}
 		}
 	}
-}
\ No newline at end of file
+	return errors.Join(errs...)
+}
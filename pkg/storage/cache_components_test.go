@@ -0,0 +1,175 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingCache 记录写入顺序的缓存，failures中的key写入失败指定次数（-1为一直失败）
+type recordingCache struct {
+	mu       sync.Mutex
+	ops      []string
+	values   map[string]interface{}
+	failures map[string]int
+}
+
+func newRecordingCache() *recordingCache {
+	return &recordingCache{values: make(map[string]interface{}), failures: make(map[string]int)}
+}
+
+func (rc *recordingCache) Get(key string) (interface{}, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	value, ok := rc.values[key]
+	return value, ok
+}
+
+func (rc *recordingCache) Set(key string, value interface{}, ttl time.Duration) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if n := rc.failures[key]; n != 0 {
+		rc.failures[key] = n - 1
+		return errors.New("disk full")
+	}
+	rc.ops = append(rc.ops, "set "+key)
+	rc.values[key] = value
+	return nil
+}
+
+func (rc *recordingCache) Delete(key string) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.ops = append(rc.ops, "delete "+key)
+	delete(rc.values, key)
+	return nil
+}
+
+func (rc *recordingCache) Clear() error       { return nil }
+func (rc *recordingCache) Size() int64        { return 0 }
+func (rc *recordingCache) Stats() *CacheStats { return &CacheStats{} }
+func (rc *recordingCache) opsSnapshot() []string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return append([]string(nil), rc.ops...)
+}
+
+func newWriteBackManager(t *testing.T, l2 Cache, config BatchManagerConfig) *MultiLevelCacheManager {
+	t.Helper()
+	manager := NewMultiLevelCacheManagerWithConfig(NewMemoryCache(1<<20), l2, nil, config)
+	policy := &CachePolicy{TTL: time.Minute, WritePolicy: "WriteBack", Compression: CodecNone}
+	if err := manager.UpdatePolicy(L1Cache, policy); err != nil {
+		t.Fatalf("Failed to update policy: %v", err)
+	}
+	return manager
+}
+
+func TestWriteBackCoalescesAndKeepsOrder(t *testing.T) {
+	ctx := context.Background()
+	l2 := newRecordingCache()
+	manager := newWriteBackManager(t, l2, BatchManagerConfig{FlushInterval: time.Hour})
+	defer manager.Close()
+
+	manager.Set(ctx, "a", 1, time.Minute)
+	manager.Set(ctx, "b", 1, time.Minute)
+	manager.Set(ctx, "a", 2, time.Minute)
+	manager.Set(ctx, "c", 1, time.Minute)
+	manager.Delete(ctx, "c")
+	if ops := l2.opsSnapshot(); len(ops) != 1 || ops[0] != "delete c" {
+		t.Fatalf("Expected nothing but the direct delete before flushing, got %v", ops)
+	}
+
+	manager.Flush()
+	ops := l2.opsSnapshot()[1:]
+	if len(ops) != 2 || ops[0] != "set b" || ops[1] != "set a" {
+		t.Fatalf("Expected coalesced writes in order of last update, got %v", ops)
+	}
+	value, _ := l2.Get("a")
+	if data, err := decodeCacheValue(value.([]byte)); err != nil || string(data) != "2" {
+		t.Errorf("Expected the last value of a, got %q %v", data, err)
+	}
+	if stats := manager.WriteBackStats(); stats.Flushed != 2 || stats.Coalesced != 2 || stats.Pending != 0 {
+		t.Errorf("Unexpected write-back stats %+v", stats)
+	}
+}
+
+func TestWriteBackFlushesOnBatchSize(t *testing.T) {
+	ctx := context.Background()
+	l2 := newRecordingCache()
+	manager := newWriteBackManager(t, l2, BatchManagerConfig{BatchSize: 2, FlushInterval: time.Hour})
+	defer manager.Close()
+
+	manager.Set(ctx, "a", 1, time.Minute)
+	manager.Set(ctx, "b", 1, time.Minute)
+	deadline := time.Now().Add(time.Second)
+	for len(l2.opsSnapshot()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected a full batch to be flushed without waiting for the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWriteBackRetriesAndDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	l2 := newRecordingCache()
+	l2.failures["flaky"] = 1
+	l2.failures["broken"] = -1
+	manager := newWriteBackManager(t, l2, BatchManagerConfig{FlushInterval: time.Hour, MaxRetries: 2})
+	defer manager.Close()
+
+	manager.Set(ctx, "flaky", 1, time.Minute)
+	manager.Set(ctx, "broken", 1, time.Minute)
+	if failed := manager.batchManager.Flush(); failed != 2 {
+		t.Fatalf("Expected 2 failed writes, got %d", failed)
+	}
+	if _, ok := l2.Get("flaky"); ok {
+		t.Fatalf("Expected the failed write to be pending")
+	}
+
+	manager.Flush()
+	if _, ok := l2.Get("flaky"); !ok {
+		t.Errorf("Expected the failed write to succeed on retry")
+	}
+	manager.Flush()
+	stats := manager.WriteBackStats()
+	if stats.Retries != 3 || stats.DeadLetters != 1 || stats.Flushed != 1 || stats.Pending != 0 {
+		t.Errorf("Unexpected write-back stats %+v", stats)
+	}
+
+	// 失败的写入被新写入取代
+	l2.failures["replaced"] = 1
+	manager.Set(ctx, "replaced", 1, time.Minute)
+	manager.Flush()
+	manager.Set(ctx, "replaced", 2, time.Minute)
+	manager.Flush()
+	value, _ := l2.Get("replaced")
+	if data, _ := decodeCacheValue(value.([]byte)); string(data) != "2" {
+		t.Errorf("Expected the newer write to win, got %q", data)
+	}
+}
+
+func TestWriteBackDurability(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	manager := newWriteBackManager(t, NewDiskCache(dir), BatchManagerConfig{FlushInterval: time.Hour})
+	manager.Set(ctx, "user:1", map[string]string{"name": "alice"}, time.Minute)
+
+	// 刷写前崩溃：磁盘上还没有该值
+	if _, ok := NewDiskCache(dir).Get("user:1"); ok {
+		t.Fatalf("Expected the unflushed write to be lost on crash")
+	}
+
+	// Close刷写全部待写数据
+	if err := manager.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+	reopened := NewMultiLevelCacheManager(NewMemoryCache(1<<20), NewDiskCache(dir), nil)
+	defer reopened.Close()
+	var got map[string]string
+	if found, err := reopened.GetInto(ctx, "user:1", &got); err != nil || !found || got["name"] != "alice" {
+		t.Errorf("Expected the write to survive Close, got %v %v %v", got, found, err)
+	}
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 缓存值的压缩编码
+// 写入各级缓存的值先经Serializer序列化，达到该级别CompressionThreshold的再用该级别的算法压缩，
+// 结果的第一个字节记录使用的算法（CodecNone表示未压缩），读取时据此解压，
+// 因此修改某一级别的算法后旧条目仍可读取，不同级别也可以使用不同算法。
+// 压缩后不比原数据小的值按未压缩保存
+
+// DefaultCompressionThreshold 默认压缩阈值，小于该大小的值不压缩
+const DefaultCompressionThreshold = 1024
+
+// CompressionCodec 缓存值的压缩算法
+type CompressionCodec uint8
+
+const (
+	CodecNone   CompressionCodec = iota // 不压缩
+	CodecGzip                           // gzip，压缩率较高，速度较慢
+	CodecSnappy                         // snappy，速度最快，压缩率较低
+	CodecZstd                           // zstd，压缩率与速度较均衡
+)
+
+var compressionCodecNames = map[CompressionCodec]string{
+	CodecNone:   "none",
+	CodecGzip:   "gzip",
+	CodecSnappy: "snappy",
+	CodecZstd:   "zstd",
+}
+
+// String 返回算法名称
+func (c CompressionCodec) String() string {
+	if name, ok := compressionCodecNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("codec(%d)", uint8(c))
+}
+
+// ParseCompressionCodec 按名称解析压缩算法，空字符串表示不压缩
+func ParseCompressionCodec(name string) (CompressionCodec, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return CodecNone, nil
+	}
+	for codec, codecName := range compressionCodecNames {
+		if codecName == name {
+			return codec, nil
+		}
+	}
+	return CodecNone, fmt.Errorf("unknown compression codec: %s", name)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[CompressionCodec]Compressor{
+		CodecGzip:   NewGzipCompressor(),
+		CodecSnappy: NewSnappyCompressor(),
+		CodecZstd:   NewZstdCompressor(),
+	}
+)
+
+// RegisterCompressor 注册或替换算法的压缩器实现，例如使用不同压缩级别的实现
+func RegisterCompressor(codec CompressionCodec, compressor Compressor) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+	compressors[codec] = compressor
+}
+
+// compressorFor 返回算法对应的压缩器，CodecNone返回nil
+func compressorFor(codec CompressionCodec) (Compressor, error) {
+	if codec == CodecNone {
+		return nil, nil
+	}
+	compressorsMu.RLock()
+	defer compressorsMu.RUnlock()
+	compressor, ok := compressors[codec]
+	if !ok {
+		return nil, fmt.Errorf("no compressor registered for %s", codec)
+	}
+	return compressor, nil
+}
+
+// encodeCacheValue 按算法和阈值编码序列化后的值，返回编码结果以及是否实际压缩
+func encodeCacheValue(data []byte, codec CompressionCodec, threshold int) ([]byte, bool, error) {
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+	if codec != CodecNone && len(data) >= threshold {
+		compressor, err := compressorFor(codec)
+		if err != nil {
+			return nil, false, err
+		}
+		compressed, err := compressor.Compress(data)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to compress with %s: %w", codec, err)
+		}
+		if len(compressed) < len(data) {
+			return append([]byte{byte(codec)}, compressed...), true, nil
+		}
+	}
+	return append([]byte{byte(CodecNone)}, data...), false, nil
+}
+
+// decodeCacheValue 解码encodeCacheValue的结果，返回序列化后的值
+func decodeCacheValue(encoded []byte) ([]byte, error) {
+	if len(encoded) == 0 {
+		return nil, fmt.Errorf("empty cache value")
+	}
+	codec, payload := CompressionCodec(encoded[0]), encoded[1:]
+	if codec == CodecNone {
+		return payload, nil
+	}
+	compressor, err := compressorFor(codec)
+	if err != nil {
+		return nil, err
+	}
+	data, err := compressor.Decompress(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress with %s: %w", codec, err)
+	}
+	return data, nil
+}
+
+// SnappyCompressor Snappy压缩器
+type SnappyCompressor struct{}
+
+// NewSnappyCompressor 创建Snappy压缩器
+func NewSnappyCompressor() *SnappyCompressor {
+	return &SnappyCompressor{}
+}
+
+// Compress 压缩数据
+func (sc *SnappyCompressor) Compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+// Decompress 解压数据
+func (sc *SnappyCompressor) Decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// ZstdCompressor Zstd压缩器，编码器和解码器在首次使用时创建，可并发使用
+type ZstdCompressor struct {
+	once    sync.Once
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+	err     error
+}
+
+// NewZstdCompressor 创建Zstd压缩器
+func NewZstdCompressor() *ZstdCompressor {
+	return &ZstdCompressor{}
+}
+
+func (zc *ZstdCompressor) init() error {
+	zc.once.Do(func() {
+		if zc.encoder, zc.err = zstd.NewWriter(nil); zc.err != nil {
+			return
+		}
+		zc.decoder, zc.err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+	return zc.err
+}
+
+// Compress 压缩数据
+func (zc *ZstdCompressor) Compress(data []byte) ([]byte, error) {
+	if err := zc.init(); err != nil {
+		return nil, err
+	}
+	return zc.encoder.EncodeAll(data, nil), nil
+}
+
+// Decompress 解压数据
+func (zc *ZstdCompressor) Decompress(data []byte) ([]byte, error) {
+	if err := zc.init(); err != nil {
+		return nil, err
+	}
+	return zc.decoder.DecodeAll(data, nil)
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCacheValueCodecs(t *testing.T) {
+	data := []byte(strings.Repeat("hello timeline ", 200))
+	for _, codec := range []CompressionCodec{CodecGzip, CodecSnappy, CodecZstd} {
+		encoded, compressed, err := encodeCacheValue(data, codec, 1024)
+		if err != nil || !compressed {
+			t.Fatalf("%s: expected value to be compressed, got %v %v", codec, compressed, err)
+		}
+		if CompressionCodec(encoded[0]) != codec || len(encoded) >= len(data) {
+			t.Errorf("%s: unexpected encoding of %d bytes", codec, len(encoded))
+		}
+		decoded, err := decodeCacheValue(encoded)
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Errorf("%s: round trip failed: %v", codec, err)
+		}
+	}
+
+	// 低于阈值的值以及压缩后不变小的值不压缩
+	for _, value := range [][]byte{[]byte("small"), []byte("\x8f\x01\x33\xa7\x5c\x90\xee\x14")} {
+		encoded, compressed, err := encodeCacheValue(value, CodecZstd, 4)
+		if err != nil || compressed || CompressionCodec(encoded[0]) != CodecNone {
+			t.Errorf("Expected %q to be stored raw, got %v %v", value, compressed, err)
+		}
+	}
+
+	if codec, err := ParseCompressionCodec("ZSTD"); err != nil || codec != CodecZstd {
+		t.Errorf("Expected zstd, got %v %v", codec, err)
+	}
+	if _, err := ParseCompressionCodec("lz4"); err == nil {
+		t.Error("Expected an unknown codec to be rejected")
+	}
+}
+
+func TestMultiLevelCacheCompressionRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	l1 := NewMemoryCache(1 << 20)
+	l2 := NewDiskCache(t.TempDir())
+	manager := NewMultiLevelCacheManager(l1, l2, nil)
+	defer manager.Close()
+
+	if err := manager.UpdatePolicy(L1Cache, &CachePolicy{TTL: time.Minute, WritePolicy: "WriteThrough", Compression: CodecSnappy, CompressionThreshold: 64}); err != nil {
+		t.Fatalf("Failed to update L1 policy: %v", err)
+	}
+	if err := manager.UpdatePolicy(L2Cache, &CachePolicy{TTL: time.Minute, Compression: CodecZstd, CompressionThreshold: 64}); err != nil {
+		t.Fatalf("Failed to update L2 policy: %v", err)
+	}
+	if err := manager.UpdatePolicy(L2Cache, &CachePolicy{Compression: CompressionCodec(42)}); err == nil {
+		t.Error("Expected a policy with an unregistered codec to be rejected")
+	}
+
+	messages := []*Message{{SeqID: 1, ConvID: "conv_a", Data: []byte(strings.Repeat("payload ", 100))}}
+	if err := manager.Set(ctx, "messages", messages, time.Minute); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+	if err := manager.Set(ctx, "small", map[string]interface{}{"name": "alice"}, time.Minute); err != nil {
+		t.Fatalf("Failed to set: %v", err)
+	}
+
+	// 各级别按自己的算法保存
+	for cache, codec := range map[Cache]CompressionCodec{l1: CodecSnappy, l2: CodecZstd} {
+		value, found := cache.Get("messages")
+		if !found || CompressionCodec(value.([]byte)[0]) != codec {
+			t.Errorf("Expected the entry to be stored with %s", codec)
+		}
+	}
+
+	var got []*Message
+	if found, err := manager.GetInto(ctx, "messages", &got); err != nil || !found {
+		t.Fatalf("Failed to get messages: %v %v", found, err)
+	}
+	if len(got) != 1 || !bytes.Equal(got[0].Data, messages[0].Data) {
+		t.Errorf("Unexpected messages: %+v", got)
+	}
+	value, found, err := manager.Get(ctx, "small")
+	if err != nil || !found || value.(map[string]interface{})["name"] != "alice" {
+		t.Errorf("Expected the decoded value, got %v %v %v", value, found, err)
+	}
+
+	// L1未命中时从L2解压，并按L1的算法提升
+	l1.Clear()
+	if found, err := manager.GetInto(ctx, "messages", &got); err != nil || !found || len(got) != 1 {
+		t.Fatalf("Expected the value from L2, got %v %v", found, err)
+	}
+	if promoted, found := l1.Get("messages"); !found || CompressionCodec(promoted.([]byte)[0]) != CodecSnappy {
+		t.Errorf("Expected the promoted entry to use the L1 codec")
+	}
+
+	stats := manager.GetStats(L2Cache)
+	if stats.CompressedCount != 1 || stats.RawBytes <= stats.StoredBytes || stats.CompressionRatio <= 0 || stats.CompressionRatio >= 1 {
+		t.Errorf("Unexpected compression stats %+v", stats)
+	}
+}
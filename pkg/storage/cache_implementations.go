@@ -14,6 +14,7 @@ type MemoryCache struct {
 	maxSize  int64
 	curSize  int64
 	stats    *CacheStats
+	onRemove func(key string) // 条目被删除、过期或淘汰时回调，调用时持有缓存锁
 }
 
 // memoryCacheItem 内存缓存项
@@ -34,6 +35,13 @@ func NewMemoryCache(maxSize int64) *MemoryCache {
 	}
 }
 
+// SetRemoveCallback 设置条目移除回调，回调中不能再访问该缓存
+func (mc *MemoryCache) SetRemoveCallback(fn func(key string)) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.onRemove = fn
+}
+
 // Get 获取缓存值
 func (mc *MemoryCache) Get(key string) (interface{}, bool) {
 	mc.mu.Lock()
@@ -120,6 +128,11 @@ func (mc *MemoryCache) Clear() error {
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 	
+	if mc.onRemove != nil {
+		for key := range mc.cache {
+			mc.onRemove(key)
+		}
+	}
 	mc.cache = make(map[string]*list.Element)
 	mc.lruList = list.New()
 	mc.curSize = 0
@@ -169,6 +182,9 @@ func (mc *MemoryCache) removeElement(elem *list.Element) {
 	mc.lruList.Remove(elem)
 	mc.curSize -= item.size
 	mc.stats.EntryCount--
+	if mc.onRemove != nil {
+		mc.onRemove(item.key)
+	}
 }
 
 // estimateSize 估算值的大小
@@ -179,83 +195,31 @@ func (mc *MemoryCache) estimateSize(value interface{}) int64 {
 		return int64(len(v))
 	case []byte:
 		return int64(len(v))
+	case *Message:
+		return 64 + int64(len(v.Data))
+	case []*Message:
+		size := int64(24)
+		for _, msg := range v {
+			size += mc.estimateSize(msg)
+		}
+		return size
+	case *TimelineBlock:
+		v.mu.RLock()
+		defer v.mu.RUnlock()
+		return 64 + mc.estimateSize(v.Messages)
+	case *Timeline:
+		v.mu.RLock()
+		defer v.mu.RUnlock()
+		size := int64(64)
+		for _, block := range v.Blocks {
+			size += mc.estimateSize(block)
+		}
+		return size
 	default:
 		return 64 // 默认64字节
 	}
 }
 
-// DiskCache 磁盘缓存实现（简化版）
-type DiskCache struct {
-	mu      sync.RWMutex
-	baseDir string
-	stats   *CacheStats
-}
-
-// NewDiskCache 创建磁盘缓存
-func NewDiskCache(baseDir string) *DiskCache {
-	return &DiskCache{
-		baseDir: baseDir,
-		stats:   &CacheStats{},
-	}
-}
-
-// Get 获取缓存值
-func (dc *DiskCache) Get(key string) (interface{}, bool) {
-	dc.mu.RLock()
-	defer dc.mu.RUnlock()
-	
-	// 简化实现：实际应该从磁盘读取文件
-	// 这里返回false表示未找到
-	dc.stats.Misses++
-	return nil, false
-}
-
-// Set 设置缓存值
-func (dc *DiskCache) Set(key string, value interface{}, ttl time.Duration) error {
-	dc.mu.Lock()
-	defer dc.mu.Unlock()
-	
-	// 简化实现：实际应该写入磁盘文件
-	// 这里只更新统计
-	dc.stats.EntryCount++
-	return nil
-}
-
-// Delete 删除缓存
-func (dc *DiskCache) Delete(key string) error {
-	dc.mu.Lock()
-	defer dc.mu.Unlock()
-	
-	// 简化实现：实际应该删除磁盘文件
-	dc.stats.EntryCount--
-	return nil
-}
-
-// Clear 清空缓存
-func (dc *DiskCache) Clear() error {
-	dc.mu.Lock()
-	defer dc.mu.Unlock()
-	
-	// 简化实现：实际应该清空磁盘目录
-	dc.stats.EntryCount = 0
-	dc.stats.TotalSize = 0
-	return nil
-}
-
-// Size 获取当前大小
-func (dc *DiskCache) Size() int64 {
-	dc.mu.RLock()
-	defer dc.mu.RUnlock()
-	return dc.stats.TotalSize
-}
-
-// Stats 获取统计信息
-func (dc *DiskCache) Stats() *CacheStats {
-	dc.mu.RLock()
-	defer dc.mu.RUnlock()
-	return dc.stats
-}
-
 // DistributedCache 分布式缓存实现（简化版）
 type DistributedCache struct {
 	mu      sync.RWMutex
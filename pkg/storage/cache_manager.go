@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"io"
 	"sync"
 	"time"
 )
@@ -34,6 +35,12 @@ type CacheStats struct {
 	TotalSize   int64
 	EntryCount  int64
 	HitRatio    float64
+	
+	// 压缩统计，只由MultiLevelCacheManager按级别记录
+	CompressedCount  int64   // 实际压缩的写入次数
+	RawBytes         int64   // 写入值序列化后的总字节数
+	StoredBytes      int64   // 编码（压缩）后实际写入的总字节数
+	CompressionRatio float64 // StoredBytes/RawBytes，越小压缩效果越好
 }
 
 // CachePolicy 缓存策略
@@ -42,13 +49,19 @@ type CachePolicy struct {
 	TTL        time.Duration // 生存时间
 	EvictPolicy string       // 淘汰策略: LRU, LFU, FIFO
 	WritePolicy string       // 写策略: WriteThrough, WriteBack, WriteAround
+	
+	Compression          CompressionCodec // 压缩算法，CodecNone表示不压缩
+	CompressionThreshold int              // 不小于该大小（字节）的值才压缩，0表示DefaultCompressionThreshold
 }
 
 // CacheManager 多级缓存管理器接口
 type CacheManager interface {
-	// Get 获取缓存值
+	// Get 获取缓存值，返回反序列化后的通用值（JSON对象为map[string]interface{}）
 	Get(ctx context.Context, key string) (interface{}, bool, error)
 	
+	// GetInto 获取缓存值并反序列化到target
+	GetInto(ctx context.Context, key string, target interface{}) (bool, error)
+	
 	// Set 设置缓存值
 	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
 	
@@ -80,10 +93,10 @@ type MultiLevelCacheManager struct {
 	policies map[CacheLevel]*CachePolicy
 	stats    map[CacheLevel]*CacheStats
 	mu       sync.RWMutex
+	statsMu  sync.Mutex // 保护stats，读路径只持有mu的读锁
 	
 	// 性能优化相关
 	prefetcher   *Prefetcher
-	compressor   Compressor
 	serializer   Serializer
 	batchManager *BatchManager
 }
@@ -100,6 +113,11 @@ type Cache interface {
 
 // NewMultiLevelCacheManager 创建多级缓存管理器
 func NewMultiLevelCacheManager(l1, l2, l3 Cache) *MultiLevelCacheManager {
+	return NewMultiLevelCacheManagerWithConfig(l1, l2, l3, DefaultBatchManagerConfig())
+}
+
+// NewMultiLevelCacheManagerWithConfig 创建多级缓存管理器，batch为WriteBack策略的延迟写入配置
+func NewMultiLevelCacheManagerWithConfig(l1, l2, l3 Cache, batch BatchManagerConfig) *MultiLevelCacheManager {
 	mcm := &MultiLevelCacheManager{
 		l1Cache: l1,
 		l2Cache: l2,
@@ -114,6 +132,8 @@ func NewMultiLevelCacheManager(l1, l2, l3 Cache) *MultiLevelCacheManager {
 		TTL:         5 * time.Minute,
 		EvictPolicy: "LRU",
 		WritePolicy: "WriteThrough",
+		Compression: CodecGzip,
+		CompressionThreshold: DefaultCompressionThreshold,
 	}
 	
 	mcm.policies[L2Cache] = &CachePolicy{
@@ -121,6 +141,8 @@ func NewMultiLevelCacheManager(l1, l2, l3 Cache) *MultiLevelCacheManager {
 		TTL:         30 * time.Minute,
 		EvictPolicy: "LRU",
 		WritePolicy: "WriteBack",
+		Compression: CodecGzip,
+		CompressionThreshold: DefaultCompressionThreshold,
 	}
 	
 	mcm.policies[L3Cache] = &CachePolicy{
@@ -128,6 +150,8 @@ func NewMultiLevelCacheManager(l1, l2, l3 Cache) *MultiLevelCacheManager {
 		TTL:         2 * time.Hour,
 		EvictPolicy: "LFU",
 		WritePolicy: "WriteAround",
+		Compression: CodecGzip,
+		CompressionThreshold: DefaultCompressionThreshold,
 	}
 	
 	// 初始化统计
@@ -137,51 +161,77 @@ func NewMultiLevelCacheManager(l1, l2, l3 Cache) *MultiLevelCacheManager {
 	
 	// 初始化性能优化组件
 	mcm.prefetcher = NewPrefetcher(mcm)
-	mcm.compressor = NewGzipCompressor()
 	mcm.serializer = NewJSONSerializer()
-	mcm.batchManager = NewBatchManager(mcm)
+	mcm.batchManager = NewBatchManagerWithConfig(mcm, batch)
 	
 	return mcm
 }
 
 // Get 多级缓存获取
 func (mcm *MultiLevelCacheManager) Get(ctx context.Context, key string) (interface{}, bool, error) {
+	var value interface{}
+	found, err := mcm.GetInto(ctx, key, &value)
+	if !found || err != nil {
+		return nil, found, err
+	}
+	return value, true, nil
+}
+
+// GetInto 多级缓存获取，解压并反序列化到target
+func (mcm *MultiLevelCacheManager) GetInto(ctx context.Context, key string, target interface{}) (bool, error) {
+	data, found, err := mcm.lookup(key)
+	if !found || err != nil {
+		return found, err
+	}
+	if err := mcm.serializer.Deserialize(data, target); err != nil {
+		return false, fmt.Errorf("failed to deserialize cache value %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// lookup 逐级查找并返回序列化后的值，命中低级别时按各级别的编码提升到更高级别
+func (mcm *MultiLevelCacheManager) lookup(key string) ([]byte, bool, error) {
 	mcm.mu.RLock()
 	defer mcm.mu.RUnlock()
 	
-	// L1缓存查找
-	if value, found := mcm.l1Cache.Get(key); found {
-		mcm.stats[L1Cache].Hits++
-		mcm.updateHitRatio(L1Cache)
-		return value, true, nil
+	levels := []struct {
+		level CacheLevel
+		cache Cache
+	}{
+		{L1Cache, mcm.l1Cache},
+		{L2Cache, mcm.l2Cache},
+		{L3Cache, mcm.l3Cache},
 	}
-	mcm.stats[L1Cache].Misses++
-	
-	// L2缓存查找
-	if mcm.l2Cache != nil {
-		if value, found := mcm.l2Cache.Get(key); found {
-			mcm.stats[L2Cache].Hits++
-			// 提升到L1缓存
-			mcm.l1Cache.Set(key, value, mcm.policies[L1Cache].TTL)
-			mcm.updateHitRatio(L2Cache)
-			return value, true, nil
+	for i, l := range levels {
+		if l.cache == nil {
+			continue
 		}
-		mcm.stats[L2Cache].Misses++
-	}
-	
-	// L3缓存查找
-	if mcm.l3Cache != nil {
-		if value, found := mcm.l3Cache.Get(key); found {
-			mcm.stats[L3Cache].Hits++
-			// 提升到L2和L1缓存
-			if mcm.l2Cache != nil {
-				mcm.l2Cache.Set(key, value, mcm.policies[L2Cache].TTL)
+		value, found := l.cache.Get(key)
+		if !found {
+			mcm.recordAccess(l.level, false)
+			continue
+		}
+		mcm.recordAccess(l.level, true)
+		
+		encoded, ok := value.([]byte)
+		if !ok {
+			return nil, false, fmt.Errorf("unexpected cache value type %T for %s", value, key)
+		}
+		data, err := decodeCacheValue(encoded)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to decode cache value %s: %w", key, err)
+		}
+		
+		// 提升到更高级别的缓存
+		for _, upper := range levels[:i] {
+			if upper.cache == nil {
+				continue
+			}
+			if promoted, err := mcm.encodeLocked(upper.level, data); err == nil {
+				upper.cache.Set(key, promoted, mcm.policies[upper.level].TTL)
 			}
-			mcm.l1Cache.Set(key, value, mcm.policies[L1Cache].TTL)
-			mcm.updateHitRatio(L3Cache)
-			return value, true, nil
 		}
-		mcm.stats[L3Cache].Misses++
+		return data, true, nil
 	}
 	
 	// 触发预取
@@ -195,44 +245,60 @@ func (mcm *MultiLevelCacheManager) Set(ctx context.Context, key string, value in
 	mcm.mu.Lock()
 	defer mcm.mu.Unlock()
 	
-	// 序列化和压缩
-	processedValue, err := mcm.processValue(value)
+	// 序列化，压缩按各级别的策略分别进行
+	data, err := mcm.serializer.Serialize(value)
 	if err != nil {
-		return fmt.Errorf("failed to process value: %w", err)
+		return fmt.Errorf("failed to serialize value: %w", err)
 	}
 	
 	// 根据写策略决定写入行为
 	l1Policy := mcm.policies[L1Cache]
 	
+	var levels []CacheLevel
 	switch l1Policy.WritePolicy {
 	case "WriteThrough":
 		// 同时写入所有级别
-		mcm.l1Cache.Set(key, processedValue, ttl)
-		if mcm.l2Cache != nil {
-			mcm.l2Cache.Set(key, processedValue, ttl)
-		}
-		if mcm.l3Cache != nil {
-			mcm.l3Cache.Set(key, processedValue, ttl)
-		}
+		levels = []CacheLevel{L1Cache, L2Cache, L3Cache}
 		
 	case "WriteBack":
 		// 只写入L1，延迟写入其他级别
-		mcm.l1Cache.Set(key, processedValue, ttl)
-		go mcm.batchManager.ScheduleWrite(key, processedValue, ttl)
+		levels = []CacheLevel{L1Cache}
+		mcm.batchManager.ScheduleWrite(key, data, ttl)
 		
 	case "WriteAround":
 		// 跳过L1，直接写入L2和L3
-		if mcm.l2Cache != nil {
-			mcm.l2Cache.Set(key, processedValue, ttl)
-		}
-		if mcm.l3Cache != nil {
-			mcm.l3Cache.Set(key, processedValue, ttl)
+		levels = []CacheLevel{L2Cache, L3Cache}
+	}
+	
+	for _, level := range levels {
+		if err := mcm.setLevelLocked(level, key, data, ttl); err != nil {
+			return err
 		}
 	}
 	
 	return nil
 }
 
+// setLevel 将序列化后的值按级别的策略编码后写入该级别，供延迟写入使用
+func (mcm *MultiLevelCacheManager) setLevel(level CacheLevel, key string, data []byte, ttl time.Duration) error {
+	mcm.mu.RLock()
+	defer mcm.mu.RUnlock()
+	
+	return mcm.setLevelLocked(level, key, data, ttl)
+}
+
+func (mcm *MultiLevelCacheManager) setLevelLocked(level CacheLevel, key string, data []byte, ttl time.Duration) error {
+	cache := mcm.cacheAt(level)
+	if cache == nil {
+		return nil
+	}
+	encoded, err := mcm.encodeLocked(level, data)
+	if err != nil {
+		return fmt.Errorf("failed to encode value for level %d: %w", level, err)
+	}
+	return cache.Set(key, encoded, ttl)
+}
+
 // Delete 删除缓存
 func (mcm *MultiLevelCacheManager) Delete(ctx context.Context, key string) error {
 	mcm.mu.Lock()
@@ -245,10 +311,22 @@ func (mcm *MultiLevelCacheManager) Delete(ctx context.Context, key string) error
 	if mcm.l3Cache != nil {
 		mcm.l3Cache.Delete(key)
 	}
+	// 尚未刷写的延迟写入不能再把值写回
+	mcm.batchManager.CancelWrite(key)
 	
 	return nil
 }
 
+// Flush 立即将WriteBack策略下待写的值刷写到L2/L3
+func (mcm *MultiLevelCacheManager) Flush() {
+	mcm.batchManager.Flush()
+}
+
+// WriteBackStats 返回延迟写入统计
+func (mcm *MultiLevelCacheManager) WriteBackStats() BatchStats {
+	return mcm.batchManager.Stats()
+}
+
 // Clear 清空指定级别缓存
 func (mcm *MultiLevelCacheManager) Clear(ctx context.Context, level CacheLevel) error {
 	mcm.mu.Lock()
@@ -270,16 +348,25 @@ func (mcm *MultiLevelCacheManager) Clear(ctx context.Context, level CacheLevel)
 	return nil
 }
 
-// GetStats 获取缓存统计
+// GetStats 获取缓存统计的快照
 func (mcm *MultiLevelCacheManager) GetStats(level CacheLevel) *CacheStats {
-	mcm.mu.RLock()
-	defer mcm.mu.RUnlock()
+	mcm.statsMu.Lock()
+	defer mcm.statsMu.Unlock()
 	
-	return mcm.stats[level]
+	stats, exists := mcm.stats[level]
+	if !exists {
+		return nil
+	}
+	snapshot := *stats
+	return &snapshot
 }
 
-// UpdatePolicy 更新缓存策略
+// UpdatePolicy 更新缓存策略，压缩算法和阈值只影响之后的写入
 func (mcm *MultiLevelCacheManager) UpdatePolicy(level CacheLevel, policy *CachePolicy) error {
+	if _, err := compressorFor(policy.Compression); err != nil {
+		return err
+	}
+	
 	mcm.mu.Lock()
 	defer mcm.mu.Unlock()
 	
@@ -300,30 +387,68 @@ func (mcm *MultiLevelCacheManager) Close() error {
 	if mcm.batchManager != nil {
 		mcm.batchManager.Stop()
 	}
+	
+	// 关闭需要落盘的缓存（如磁盘缓存的索引）
+	var firstErr error
+	for _, cache := range []Cache{mcm.l1Cache, mcm.l2Cache, mcm.l3Cache} {
+		if closer, ok := cache.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// cacheAt 返回级别对应的缓存，未配置时为nil
+func (mcm *MultiLevelCacheManager) cacheAt(level CacheLevel) Cache {
+	switch level {
+	case L1Cache:
+		return mcm.l1Cache
+	case L2Cache:
+		return mcm.l2Cache
+	case L3Cache:
+		return mcm.l3Cache
+	}
 	return nil
 }
 
-// processValue 处理值（序列化和压缩）
-func (mcm *MultiLevelCacheManager) processValue(value interface{}) (interface{}, error) {
-	// 序列化
-	data, err := mcm.serializer.Serialize(value)
+// encodeLocked 按级别的压缩策略编码序列化后的值并记录压缩统计，调用方需持有mu
+func (mcm *MultiLevelCacheManager) encodeLocked(level CacheLevel, data []byte) ([]byte, error) {
+	policy := mcm.policies[level]
+	encoded, compressed, err := encodeCacheValue(data, policy.Compression, policy.CompressionThreshold)
 	if err != nil {
 		return nil, err
 	}
 	
-	// 压缩（如果数据较大）
-	if len(data) > 1024 { // 1KB阈值
-		compressed, err := mcm.compressor.Compress(data)
-		if err != nil {
-			return nil, err
-		}
-		return compressed, nil
+	mcm.statsMu.Lock()
+	defer mcm.statsMu.Unlock()
+	stats := mcm.stats[level]
+	if compressed {
+		stats.CompressedCount++
 	}
-	
-	return data, nil
+	stats.RawBytes += int64(len(data))
+	stats.StoredBytes += int64(len(encoded))
+	if stats.RawBytes > 0 {
+		stats.CompressionRatio = float64(stats.StoredBytes) / float64(stats.RawBytes)
+	}
+	return encoded, nil
+}
+
+// recordAccess 记录一次命中或未命中
+func (mcm *MultiLevelCacheManager) recordAccess(level CacheLevel, hit bool) {
+	mcm.statsMu.Lock()
+	defer mcm.statsMu.Unlock()
+	
+	if hit {
+		mcm.stats[level].Hits++
+	} else {
+		mcm.stats[level].Misses++
+	}
+	mcm.updateHitRatio(level)
 }
 
-// updateHitRatio 更新命中率
+// updateHitRatio 更新命中率，调用方需持有statsMu
 func (mcm *MultiLevelCacheManager) updateHitRatio(level CacheLevel) {
 	stats := mcm.stats[level]
 	total := stats.Hits + stats.Misses
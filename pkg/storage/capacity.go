@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+const (
+	// defaultCapacityHighWatermark 已用容量达到MaxCapacity的该比例后拒绝写入，为并发写入留出余量
+	defaultCapacityHighWatermark = 0.95
+	// messageOverheadBytes 消息除Data外编码后的大致字节数，用于写入前估算
+	messageOverheadBytes = 128
+)
+
+// ErrStorageFull Store已用容量超过高水位，调用方应稍后重试或将Timeline迁移到其他Store
+var ErrStorageFull = errors.New("store capacity exceeded")
+
+// StoreCapacity Store容量统计（字节）
+// 块按段文件中的实际记录大小计算，被覆盖或删除的旧记录不计入；未落盘块的消息只存在于WAL中，按WAL文件大小计算；
+// 已分层到对象存储的块不占用本地磁盘，单独统计，不计入UsedBytes
+type StoreCapacity struct {
+	MaxCapacity   int64 `json:"maxCapacity"`   // 0表示不限制
+	HighWatermark int64 `json:"highWatermark"` // 已用容量超过该值后拒绝写入
+	BlockBytes    int64 `json:"blockBytes"`    // 已落盘块占用的字节数
+	WALBytes      int64 `json:"walBytes"`      // WAL占用的字节数
+	UsedBytes     int64 `json:"usedBytes"`     // BlockBytes + WALBytes
+	TieredBlocks  int   `json:"tieredBlocks"`  // 已分层的块数
+	TieredBytes   int64 `json:"tieredBytes"`   // 已分层的块在对象存储中占用的字节数
+}
+
+// Capacity 获取Store当前的容量统计
+func (s *Store) Capacity() StoreCapacity {
+	capacity := StoreCapacity{
+		MaxCapacity:   s.Config.MaxCapacity,
+		HighWatermark: s.highWatermark(),
+		BlockBytes:    s.segments.LiveBytes(),
+	}
+	if s.wal != nil {
+		capacity.WALBytes = s.wal.Size()
+	}
+	capacity.UsedBytes = capacity.BlockBytes + capacity.WALBytes
+	capacity.TieredBlocks, capacity.TieredBytes = s.TieredUsage()
+	return capacity
+}
+
+// UsedCapacity 获取Store已用容量（字节）
+func (s *Store) UsedCapacity() int64 {
+	return s.Capacity().UsedBytes
+}
+
+// highWatermark 拒绝写入的容量阈值，未限制容量时返回0
+func (s *Store) highWatermark() int64 {
+	if s.Config.MaxCapacity <= 0 {
+		return 0
+	}
+	ratio := s.Config.CapacityHighWatermark
+	if ratio <= 0 || ratio > 1 {
+		ratio = defaultCapacityHighWatermark
+	}
+	return int64(float64(s.Config.MaxCapacity) * ratio)
+}
+
+// checkCapacity 写入前检查容量，incoming为预计新增的字节数
+// 超过高水位时先压缩WAL回收已落盘块的记录，仍然超过则返回ErrStorageFull
+func (s *Store) checkCapacity(incoming int64) error {
+	watermark := s.highWatermark()
+	if watermark <= 0 {
+		return nil
+	}
+
+	capacity := s.Capacity()
+	if capacity.UsedBytes+incoming <= watermark {
+		return nil
+	}
+
+	// WAL在上次压缩后有增长时才值得再压缩
+	if capacity.WALBytes > atomic.LoadInt64(&s.walCompactedSize) {
+		if err := s.compactWAL(); err != nil {
+			return err
+		}
+		atomic.StoreInt64(&s.walCompactedSize, s.wal.Size())
+
+		capacity = s.Capacity()
+		if capacity.UsedBytes+incoming <= watermark {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %d of %d bytes used", ErrStorageFull, capacity.UsedBytes, capacity.MaxCapacity)
+}
+
+// estimateMessageBytes 估算一条记录写入timelines条Timeline后新增的字节数
+func estimateMessageBytes(msg *Message, timelines int) int64 {
+	return int64(len(msg.Data)+messageOverheadBytes) * int64(timelines)
+}
@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStoreCapacityCountsBytes(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	payload := make([]byte, 1000)
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage("conv_bytes", 1, payload, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	// 一个块已落盘，其记录至少包含两条消息的数据；第三条消息只在WAL中
+	capacity := store.Capacity()
+	if capacity.BlockBytes < 2*int64(len(payload)) {
+		t.Errorf("Expected block bytes to cover the flushed block, got %d", capacity.BlockBytes)
+	}
+	if capacity.WALBytes < 3*int64(len(payload)) {
+		t.Errorf("Expected WAL bytes to cover all messages, got %d", capacity.WALBytes)
+	}
+	if capacity.UsedBytes != capacity.BlockBytes+capacity.WALBytes || capacity.MaxCapacity != 1<<20 {
+		t.Errorf("Unexpected capacity %+v", capacity)
+	}
+
+	stats, err := NewLocalStoreService(store).GetStoreStats(context.Background(), &GetStoreStatsRequest{})
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	if stats.TotalSize != capacity.UsedBytes || stats.BlockBytes != capacity.BlockBytes || stats.WALBytes != capacity.WALBytes {
+		t.Errorf("Stats %+v do not match capacity %+v", stats, capacity)
+	}
+	store.Close()
+
+	// 重启后按段文件重建块容量
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if blockBytes := reopened.Capacity().BlockBytes; blockBytes != capacity.BlockBytes {
+		t.Errorf("Expected %d block bytes after reopen, got %d", capacity.BlockBytes, blockBytes)
+	}
+}
+
+func TestStoreCapacityBackPressure(t *testing.T) {
+	store, err := NewStore(&StoreConfig{
+		MaxCapacity:           16 << 10,
+		CapacityHighWatermark: 0.5,
+		TimelineMaxSize:       4,
+		DataDir:               t.TempDir(),
+		WALMaxSize:            1 << 30, // 只在容量不足时压缩WAL
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	payload := make([]byte, 512)
+	var written int
+	for ; written < 100; written++ {
+		err = store.AddMessage(fmt.Sprintf("conv_%d", written%3), 1, payload, []string{"user_1"})
+		if err != nil {
+			break
+		}
+	}
+	if !errors.Is(err, ErrStorageFull) {
+		t.Fatalf("Expected ErrStorageFull, got %v after %d messages", err, written)
+	}
+	if used := store.UsedCapacity(); used > 16<<10 {
+		t.Errorf("Expected writes to stop before MaxCapacity, used %d", used)
+	}
+
+	// 被拒绝的消息不应写入任何Timeline
+	messages, _ := store.GetUserMessagesAfter("user_1", 0, 0)
+	if len(messages) != written {
+		t.Errorf("Expected %d messages for user, got %d", written, len(messages))
+	}
+
+	service := NewLocalStoreService(store)
+	_, err = service.AddMessage(context.Background(), &AddMessageRequest{TimelineKey: "conv_0", Message: &Message{SenderID: 1, Data: payload}})
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrCodeStorageFull {
+		t.Errorf("Expected ErrCodeStorageFull, got %v", err)
+	}
+
+	// 删除Timeline释放容量后恢复写入
+	for i := 0; i < 3; i++ {
+		if _, err := store.DeleteTimeline("conv", fmt.Sprintf("conv_%d", i)); err != nil {
+			t.Fatalf("Failed to delete timeline: %v", err)
+		}
+	}
+	if _, err := store.DeleteTimeline("user", "user_1"); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if err := store.AddMessage("conv_new", 1, payload, nil); err != nil {
+		t.Errorf("Expected writes to resume after freeing capacity, got %v", err)
+	}
+}
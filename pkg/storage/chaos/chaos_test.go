@@ -0,0 +1,116 @@
+package chaos
+
+import (
+	"context"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+// runWithFaults 在负载运行期间每隔interval执行一次inject，结束后恢复集群并校验不变量
+func runWithFaults(t *testing.T, duration, interval time.Duration, inject func(step int, c *Cluster)) {
+	t.Helper()
+	cluster, err := NewCluster(Config{Nodes: 3, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewCluster failed: %v", err)
+	}
+	t.Cleanup(func() { cluster.Close() })
+	workload := NewWorkload(cluster, WorkloadConfig{Conversations: 6, Writers: 6, Timeout: 200 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		workload.Run(ctx)
+	}()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for step := 0; ; step++ {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+			inject(step, cluster)
+			continue
+		}
+		break
+	}
+	<-done
+
+	if err := cluster.Heal(); err != nil {
+		t.Fatalf("Heal failed: %v", err)
+	}
+	stats := workload.Stats()
+	if stats.Acked == 0 {
+		t.Fatalf("Expected some acked messages, got %+v", stats)
+	}
+	if err := workload.Verify(); err != nil {
+		t.Fatalf("Invariants violated after %+v:\n%v", stats, err)
+	}
+	t.Logf("%+v", stats)
+}
+
+func TestKillAndRestartStores(t *testing.T) {
+	runWithFaults(t, 2*time.Second, 150*time.Millisecond, func(step int, c *Cluster) {
+		node := c.Node(step % len(c.Nodes()))
+		if node.Running() {
+			if err := node.Kill(); err != nil {
+				t.Errorf("Kill %s failed: %v", node.ID, err)
+			}
+			return
+		}
+		if err := node.Restart(); err != nil {
+			t.Errorf("Restart %s failed: %v", node.ID, err)
+		}
+	})
+}
+
+func TestPartitionedRPC(t *testing.T) {
+	modes := []int32{PartitionFull, PartitionReplies, PartitionNone}
+	runWithFaults(t, 2*time.Second, 100*time.Millisecond, func(step int, c *Cluster) {
+		c.Node(rand.IntN(len(c.Nodes()))).Partition(modes[step%len(modes)])
+	})
+}
+
+func TestSlowDisk(t *testing.T) {
+	// 延迟超过客户端超时，已写入的消息会被重试
+	delays := []time.Duration{300 * time.Millisecond, 50 * time.Millisecond, 0}
+	runWithFaults(t, 2*time.Second, 200*time.Millisecond, func(step int, c *Cluster) {
+		c.Node(rand.IntN(len(c.Nodes()))).SetDiskDelay(delays[step%len(delays)])
+	})
+}
+
+func TestClockSkewAcrossRestarts(t *testing.T) {
+	// 时钟回拨后重启，恢复的Timeline需要推进HLC
+	skews := []time.Duration{time.Hour, -time.Hour, 0}
+	runWithFaults(t, 2*time.Second, 150*time.Millisecond, func(step int, c *Cluster) {
+		node := c.Node(step % len(c.Nodes()))
+		node.SetClockSkew(skews[step%len(skews)])
+		if err := node.Kill(); err != nil {
+			t.Errorf("Kill %s failed: %v", node.ID, err)
+		}
+		if err := node.Restart(); err != nil {
+			t.Errorf("Restart %s failed: %v", node.ID, err)
+		}
+	})
+}
+
+func TestCombinedFaults(t *testing.T) {
+	runWithFaults(t, 3*time.Second, 100*time.Millisecond, func(step int, c *Cluster) {
+		node := c.Node(rand.IntN(len(c.Nodes())))
+		switch rand.IntN(6) {
+		case 0:
+			node.Kill()
+		case 1:
+			node.Restart()
+		case 2:
+			node.Partition(int32(rand.IntN(3)))
+		case 3:
+			node.SetDiskDelay(time.Duration(rand.IntN(300)) * time.Millisecond)
+		case 4:
+			node.SetClockSkew(time.Duration(rand.IntN(7200)-3600) * time.Second)
+		default:
+			node.Heal()
+		}
+	})
+}
@@ -0,0 +1,326 @@
+// Package chaos 存储层的故障注入测试工具
+//
+// Cluster 在进程内启动多个Store及其HTTP RPC服务，每个节点可以单独注入故障：
+// 停止进程（Kill/Restart）、RPC网络分区、慢盘与时钟偏移。Workload 通过RPC持续写入消息并记录
+// 已确认的写入，Verify 在故障恢复后检查不变量：已确认的消息不丢失、SeqID不重复且连续、
+// HLC随SeqID递增、块索引与查询结果和块内消息一致。
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"imy/pkg/storage"
+)
+
+// 分区模式
+const (
+	PartitionNone    int32 = iota // 网络正常
+	PartitionFull                 // 请求到达前断开连接，Store不会处理请求
+	PartitionReplies              // Store处理请求后断开连接，调用方收不到响应
+)
+
+// Config 集群配置
+type Config struct {
+	Nodes           int    // 节点数量，默认3
+	DataDir         string // 各节点数据目录的父目录
+	TimelineMaxSize int64  // 块最大消息数，较小的值让测试覆盖块落盘与WAL压缩，默认16
+	WALMaxSize      int64  // WAL超过该大小时压缩，默认64KB
+}
+
+// Cluster 进程内的多Store集群
+type Cluster struct {
+	config Config
+	nodes  []*Node
+}
+
+// Node 集群中的一个Store节点，重启后保持数据目录与地址不变
+type Node struct {
+	ID      string
+	DataDir string
+	Address string // 监听地址 host:port
+	URL     string // RPC客户端使用的地址
+
+	config Config
+
+	mu      sync.Mutex
+	store   *storage.Store
+	server  *storage.HTTPStoreRPCServer
+	running bool
+
+	partition atomic.Int32
+	diskDelay atomic.Int64 // 纳秒
+	clockSkew atomic.Int64 // 纳秒
+}
+
+// NewCluster 创建并启动集群
+func NewCluster(config Config) (*Cluster, error) {
+	if config.Nodes <= 0 {
+		config.Nodes = 3
+	}
+	if config.TimelineMaxSize <= 0 {
+		config.TimelineMaxSize = 16
+	}
+	if config.WALMaxSize <= 0 {
+		config.WALMaxSize = 64 << 10
+	}
+	if config.DataDir == "" {
+		return nil, errors.New("chaos: DataDir is required")
+	}
+
+	cluster := &Cluster{config: config}
+	for i := 0; i < config.Nodes; i++ {
+		address, err := freeAddress()
+		if err != nil {
+			cluster.Close()
+			return nil, err
+		}
+		id := fmt.Sprintf("store-%d", i)
+		node := &Node{
+			ID:      id,
+			DataDir: filepath.Join(config.DataDir, id),
+			Address: address,
+			URL:     "http://" + address,
+			config:  config,
+		}
+		cluster.nodes = append(cluster.nodes, node)
+		if err := node.Restart(); err != nil {
+			cluster.Close()
+			return nil, err
+		}
+	}
+	return cluster, nil
+}
+
+// freeAddress 分配一个空闲的本地端口，节点重启时复用该端口
+func freeAddress() (string, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// Nodes 返回所有节点
+func (c *Cluster) Nodes() []*Node {
+	return c.nodes
+}
+
+// Node 返回第i个节点
+func (c *Cluster) Node(i int) *Node {
+	return c.nodes[i]
+}
+
+// Owner 返回负责该会话的节点，同一会话总是写入同一个节点
+func (c *Cluster) Owner(convID string) *Node {
+	var h uint32 = 2166136261
+	for i := 0; i < len(convID); i++ {
+		h = (h ^ uint32(convID[i])) * 16777619
+	}
+	return c.nodes[h%uint32(len(c.nodes))]
+}
+
+// Heal 清除所有节点注入的故障并启动已停止的节点
+func (c *Cluster) Heal() error {
+	var errs []error
+	for _, node := range c.nodes {
+		node.Heal()
+		if !node.Running() {
+			errs = append(errs, node.Restart())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Close 停止所有节点
+func (c *Cluster) Close() error {
+	var errs []error
+	for _, node := range c.nodes {
+		errs = append(errs, node.Kill())
+	}
+	return errors.Join(errs...)
+}
+
+// Store 返回节点当前的Store，节点停止时为nil
+func (n *Node) Store() *storage.Store {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.store
+}
+
+// Running 节点是否在运行
+func (n *Node) Running() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.running
+}
+
+// Kill 停止节点：等待进行中的请求结束后关闭RPC服务与Store
+// 不调用Store.Flush，未保存的元数据与未满的块只能在重启后从段文件和WAL恢复，相当于进程崩溃
+func (n *Node) Kill() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.running {
+		return nil
+	}
+	n.running = false
+	err := n.server.Stop(context.Background())
+	if closeErr := n.store.Close(); err == nil {
+		err = closeErr
+	}
+	n.store, n.server = nil, nil
+	return err
+}
+
+// Restart 在原数据目录上重新打开Store，并在原地址上启动RPC服务
+func (n *Node) Restart() error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.running {
+		return nil
+	}
+
+	store, err := storage.NewStore(&storage.StoreConfig{
+		StoreID:         n.ID,
+		DataDir:         n.DataDir,
+		TimelineMaxSize: n.config.TimelineMaxSize,
+		WALSyncPolicy:   storage.WALSyncAlways,
+		WALMaxSize:      n.config.WALMaxSize,
+		Clock:           n.now,
+	})
+	if err != nil {
+		return fmt.Errorf("chaos: open store %s: %w", n.ID, err)
+	}
+	server := storage.NewHTTPStoreRPCServer(store)
+	server.AddMiddleware(n.faultMiddleware)
+	if err := server.Start(n.Address); err != nil {
+		store.Close()
+		return fmt.Errorf("chaos: start store %s: %w", n.ID, err)
+	}
+	if err := waitHealthy(n.URL); err != nil {
+		server.Stop(context.Background())
+		store.Close()
+		return fmt.Errorf("chaos: start store %s: %w", n.ID, err)
+	}
+	n.store, n.server, n.running = store, server, true
+	return nil
+}
+
+// waitHealthy 等待RPC服务开始监听，Start在后台goroutine中监听端口
+func waitHealthy(url string) error {
+	client := &http.Client{Timeout: time.Second}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		resp, err := client.Get(url + "/health")
+		if err == nil {
+			resp.Body.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Partition 设置节点的RPC分区模式，取值为PartitionNone、PartitionFull或PartitionReplies
+func (n *Node) Partition(mode int32) {
+	n.partition.Store(mode)
+}
+
+// SetDiskDelay 模拟慢盘：请求处理完成后延迟d才返回响应，相当于每次写入的fsync变慢；
+// 延迟超过客户端超时时，调用方会重试已经写入的消息
+func (n *Node) SetDiskDelay(d time.Duration) {
+	n.diskDelay.Store(int64(d))
+}
+
+// SetClockSkew 让节点的物理时钟偏移d，d为负数时时钟回拨
+func (n *Node) SetClockSkew(d time.Duration) {
+	n.clockSkew.Store(int64(d))
+}
+
+// Heal 清除节点的分区、慢盘与时钟偏移
+func (n *Node) Heal() {
+	n.Partition(PartitionNone)
+	n.SetDiskDelay(0)
+	n.SetClockSkew(0)
+}
+
+// now 节点Store使用的物理时钟
+func (n *Node) now() time.Time {
+	return time.Now().Add(time.Duration(n.clockSkew.Load()))
+}
+
+// faultMiddleware 按节点当前的故障状态处理RPC请求
+func (n *Node) faultMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mode := n.partition.Load()
+		if mode == PartitionFull {
+			dropConnection(w)
+			return
+		}
+		if mode == PartitionReplies {
+			// 丢弃响应，只保留处理的副作用
+			next.ServeHTTP(discardResponse{header: http.Header{}}, r)
+			dropConnection(w)
+			return
+		}
+		next.ServeHTTP(&delayedResponse{ResponseWriter: w, delay: time.Duration(n.diskDelay.Load())}, r)
+	})
+}
+
+// dropConnection 不返回响应直接断开连接，调用方看到的是网络错误
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	conn.Close()
+}
+
+// discardResponse 丢弃写入的响应
+type discardResponse struct {
+	header http.Header
+}
+
+func (d discardResponse) Header() http.Header         { return d.header }
+func (d discardResponse) Write(b []byte) (int, error) { return len(b), nil }
+func (d discardResponse) WriteHeader(int)             {}
+
+// delayedResponse 在写出响应前等待delay
+type delayedResponse struct {
+	http.ResponseWriter
+	delay   time.Duration
+	delayed bool
+}
+
+func (d *delayedResponse) wait() {
+	if !d.delayed {
+		d.delayed = true
+		time.Sleep(d.delay)
+	}
+}
+
+func (d *delayedResponse) WriteHeader(statusCode int) {
+	d.wait()
+	d.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (d *delayedResponse) Write(b []byte) (int, error) {
+	d.wait()
+	return d.ResponseWriter.Write(b)
+}
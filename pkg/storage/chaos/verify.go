@@ -0,0 +1,180 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"imy/pkg/storage"
+)
+
+// Verify 检查负载写入的会话是否满足不变量，返回所有违反项
+// 需在负载停止、集群恢复（Cluster.Heal）之后调用；检查前重启每个节点，确保校验的是恢复后的数据
+func (w *Workload) Verify() error {
+	cluster := w.cluster
+	for _, node := range cluster.Nodes() {
+		if err := node.Kill(); err != nil {
+			return fmt.Errorf("chaos: stop %s before verify: %w", node.ID, err)
+		}
+		if err := node.Restart(); err != nil {
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var errs []error
+	for _, convID := range w.Conversations() {
+		store := cluster.Owner(convID).Store()
+		errs = append(errs, verifyTimeline(store, convID, w.acked[convID])...)
+	}
+	return errors.Join(errs...)
+}
+
+// verifyTimeline 检查一个会话Timeline：
+//   - 已确认的消息都存在，且SeqID与确认时返回的一致
+//   - SeqID从1开始连续、不重复，LastSeqID为最大的SeqID
+//   - ClientMsgID不重复，重试没有产生新消息
+//   - HLC随SeqID严格递增
+//   - 每个块的索引与块内消息一致，按索引跳块的查询与分页读取都与全量扫描结果相同
+func verifyTimeline(store *storage.Store, convID string, acked map[string]ackedMessage) []error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", convID, fmt.Sprintf(format, args...)))
+	}
+
+	timeline, exists := store.FindTimeline(convID)
+	if !exists {
+		if len(acked) > 0 {
+			fail("timeline lost with %d acked messages", len(acked))
+		}
+		return errs
+	}
+
+	// 全量扫描所有块，节点已重启且没有写入；已写满的块按需加载，经Store读取块内消息
+	var messages []*storage.Message
+	for i, block := range timeline.Blocks {
+		blockMessages, err := store.BlockMessages(block)
+		if err != nil {
+			fail("failed to read block %d (%s): %v", i, block.BlockID, err)
+			continue
+		}
+		if index := indexOf(blockMessages); index != block.Index {
+			fail("block %d (%s) index %+v does not match its messages %+v", i, block.BlockID, block.Index, index)
+		}
+		messages = append(messages, blockMessages...)
+	}
+
+	bySeq := make(map[int64]*storage.Message, len(messages))
+	byClientID := make(map[string]*storage.Message, len(messages))
+	for i, msg := range messages {
+		if want := int64(i + 1); msg.SeqID != want {
+			fail("message %d has SeqID %d, want %d", i, msg.SeqID, want)
+		}
+		if _, dup := bySeq[msg.SeqID]; dup {
+			fail("duplicate SeqID %d", msg.SeqID)
+		}
+		bySeq[msg.SeqID] = msg
+		if msg.ClientMsgID != "" {
+			if existing, dup := byClientID[msg.ClientMsgID]; dup {
+				fail("ClientMsgID %s stored twice as SeqID %d and %d", msg.ClientMsgID, existing.SeqID, msg.SeqID)
+			}
+			byClientID[msg.ClientMsgID] = msg
+		}
+		if i > 0 && msg.HLC <= messages[i-1].HLC {
+			fail("HLC of SeqID %d (%d) is not after SeqID %d (%d)", msg.SeqID, msg.HLC, messages[i-1].SeqID, messages[i-1].HLC)
+		}
+	}
+	if n := int64(len(messages)); timeline.LastSeqID != n {
+		fail("LastSeqID is %d with %d messages", timeline.LastSeqID, n)
+	}
+
+	for clientMsgID, result := range acked {
+		msg, ok := byClientID[clientMsgID]
+		if !ok {
+			fail("acked message %s (SeqID %d) lost", clientMsgID, result.SeqID)
+			continue
+		}
+		if msg.SeqID != result.SeqID || msg.HLC != result.HLC {
+			fail("acked message %s was SeqID %d HLC %d, stored as SeqID %d HLC %d", clientMsgID, result.SeqID, result.HLC, msg.SeqID, msg.HLC)
+		}
+	}
+
+	errs = append(errs, verifyReads(store, convID, messages)...)
+	return errs
+}
+
+// verifyReads 比较按块索引执行的查询、分页读取与全量扫描的结果
+func verifyReads(store *storage.Store, convID string, messages []*storage.Message) []error {
+	var errs []error
+	compare := func(name string, got, want []*storage.Message) {
+		if !slices.EqualFunc(got, want, func(a, b *storage.Message) bool { return a.SeqID == b.SeqID }) {
+			errs = append(errs, fmt.Errorf("%s: %s returned %v, want %v", convID, name, seqIDs(got), seqIDs(want)))
+		}
+	}
+
+	n := int64(len(messages))
+	for _, r := range [][2]int64{{0, 0}, {0, n / 2}, {n / 3, 2 * n / 3}, {n - 5, 0}} {
+		after, before := max(r[0], 0), r[1]
+		result, err := store.Query(&storage.Query{TimelineID: convID, AfterSeqID: after, BeforeSeqID: before})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: query (%d, %d): %w", convID, after, before, err))
+			continue
+		}
+		var want []*storage.Message
+		for _, msg := range messages {
+			if msg.SeqID > after && (before == 0 || msg.SeqID < before) {
+				want = append(want, msg)
+			}
+		}
+		compare(fmt.Sprintf("query (%d, %d)", after, before), result.Messages, want)
+	}
+
+	// 从最新的消息向前分页读完整个会话
+	var paged []*storage.Message
+	for before := int64(0); ; {
+		page, err := store.GetConvMessages(convID, 7, before)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: page before %d: %w", convID, before, err))
+			break
+		}
+		if len(page) == 0 {
+			break
+		}
+		paged = append(page, paged...)
+		before = page[0].SeqID
+	}
+	compare("paged reads", paged, messages)
+	return errs
+}
+
+// indexOf 按消息计算块索引
+func indexOf(messages []*storage.Message) storage.BlockIndex {
+	var index storage.BlockIndex
+	for i, msg := range messages {
+		createTime := msg.CreateTime.UnixNano()
+		if i == 0 {
+			index = storage.BlockIndex{
+				MinSeqID: msg.SeqID, MaxSeqID: msg.SeqID,
+				MinTime: createTime, MaxTime: createTime,
+				MinHLC: msg.HLC, MaxHLC: msg.HLC,
+			}
+		}
+		index.MinSeqID = min(index.MinSeqID, msg.SeqID)
+		index.MaxSeqID = max(index.MaxSeqID, msg.SeqID)
+		index.MinTime = min(index.MinTime, createTime)
+		index.MaxTime = max(index.MaxTime, createTime)
+		index.MinHLC = min(index.MinHLC, msg.HLC)
+		index.MaxHLC = max(index.MaxHLC, msg.HLC)
+		index.Count++
+	}
+	return index
+}
+
+func seqIDs(messages []*storage.Message) []int64 {
+	ids := make([]int64, len(messages))
+	for i, msg := range messages {
+		ids[i] = msg.SeqID
+	}
+	return ids
+}
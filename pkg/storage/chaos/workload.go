@@ -0,0 +1,169 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"imy/pkg/storage"
+)
+
+// WorkloadConfig 写入负载配置
+type WorkloadConfig struct {
+	Conversations int           // 会话数量，默认8
+	Writers       int           // 并发写入者数量，默认4
+	Timeout       time.Duration // 单次RPC超时，默认500ms
+	RetryBackoff  time.Duration // 写入失败后重试同一条消息前的等待，默认20ms
+}
+
+// Workload 通过RPC向集群持续写入消息，失败时用同一个ClientMsgID重试直到确认，
+// 并记录每条已确认消息的SeqID与HLC供Verify检查
+type Workload struct {
+	cluster *Cluster
+	config  WorkloadConfig
+
+	mu    sync.Mutex
+	acked map[string]map[string]ackedMessage // 会话 -> ClientMsgID -> 确认结果
+
+	attempts atomic.Int64
+	failures atomic.Int64
+}
+
+// ackedMessage 写入成功时Store返回的结果
+type ackedMessage struct {
+	SeqID int64
+	HLC   int64
+}
+
+// WorkloadStats 负载统计
+type WorkloadStats struct {
+	Acked    int   // 已确认的消息数
+	Attempts int64 // RPC调用次数，包括重试
+	Failures int64 // 失败的RPC调用次数
+}
+
+// NewWorkload 创建写入负载
+func NewWorkload(cluster *Cluster, config WorkloadConfig) *Workload {
+	if config.Conversations <= 0 {
+		config.Conversations = 8
+	}
+	if config.Writers <= 0 {
+		config.Writers = 4
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 500 * time.Millisecond
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 20 * time.Millisecond
+	}
+	return &Workload{
+		cluster: cluster,
+		config:  config,
+		acked:   make(map[string]map[string]ackedMessage),
+	}
+}
+
+// Conversations 返回负载写入的会话ID
+func (w *Workload) Conversations() []string {
+	convIDs := make([]string, w.config.Conversations)
+	for i := range convIDs {
+		convIDs[i] = fmt.Sprintf("chaos_conv_%d", i)
+	}
+	return convIDs
+}
+
+// Run 启动写入者并阻塞到ctx结束
+// ctx结束时仍未确认的消息可能已经写入也可能没有，不计入已确认的消息
+func (w *Workload) Run(ctx context.Context) {
+	convIDs := w.Conversations()
+	var wg sync.WaitGroup
+	for i := 0; i < w.config.Writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.writer(ctx, i, convIDs)
+		}()
+	}
+	wg.Wait()
+}
+
+// writer 逐条写入消息，每条消息确认后再写下一条
+func (w *Workload) writer(ctx context.Context, id int, convIDs []string) {
+	clients := make(map[*Node]*storage.HTTPStoreRPCClient)
+	defer func() {
+		for _, client := range clients {
+			client.Disconnect()
+		}
+	}()
+	for seq := 0; ctx.Err() == nil; seq++ {
+		convID := convIDs[rand.IntN(len(convIDs))]
+		message := &storage.Message{
+			ConvID:      convID,
+			SenderID:    uint32(id + 1),
+			CreateTime:  time.Now(),
+			Data:        []byte(fmt.Sprintf("writer %d message %d", id, seq)),
+			ClientMsgID: fmt.Sprintf("w%d-%d", id, seq),
+		}
+		for ctx.Err() == nil {
+			resp, err := w.send(ctx, clients, convID, message)
+			if err == nil {
+				w.ack(convID, message.ClientMsgID, ackedMessage{SeqID: resp.SeqID, HLC: resp.HLC})
+				break
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.config.RetryBackoff):
+			}
+		}
+	}
+}
+
+// send 向会话所在节点发送一次写入，连接断开时下次调用重新连接
+func (w *Workload) send(ctx context.Context, clients map[*Node]*storage.HTTPStoreRPCClient, convID string, message *storage.Message) (*storage.AddMessageResponse, error) {
+	w.attempts.Add(1)
+	node := w.cluster.Owner(convID)
+	client := clients[node]
+	if client == nil {
+		client = storage.NewHTTPStoreRPCClient(w.config.Timeout)
+		// 由负载自己重试，便于统计并在ctx结束时及时退出
+		client.SetRetryCount(0)
+		if err := client.Connect(ctx, node.URL); err != nil {
+			w.failures.Add(1)
+			return nil, err
+		}
+		clients[node] = client
+	}
+	// 每次发送独立的副本，避免请求序列化时与重试并发修改
+	copied := *message
+	resp, err := client.AddMessage(ctx, &storage.AddMessageRequest{TimelineKey: convID, Message: &copied})
+	if err != nil {
+		w.failures.Add(1)
+		client.Disconnect()
+		delete(clients, node)
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (w *Workload) ack(convID, clientMsgID string, result ackedMessage) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.acked[convID] == nil {
+		w.acked[convID] = make(map[string]ackedMessage)
+	}
+	w.acked[convID][clientMsgID] = result
+}
+
+// Stats 返回负载统计
+func (w *Workload) Stats() WorkloadStats {
+	w.mu.Lock()
+	acked := 0
+	for _, messages := range w.acked {
+		acked += len(messages)
+	}
+	w.mu.Unlock()
+	return WorkloadStats{Acked: acked, Attempts: w.attempts.Load(), Failures: w.failures.Load()}
+}
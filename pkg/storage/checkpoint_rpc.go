@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+)
+
+// checkpoint的RPC接口
+// 其他节点（网关、推送服务）通过Store RPC读取和批量更新用户的checkpoint、会话已读位置与拉模式游标，
+// 一次UpdateCheckpoints请求在Store上作为一批原子写入，见checkpoint_store.go。
+// 目前只有HTTP传输支持，gRPC客户端不实现该接口。
+
+// StoreCheckpointService 支持读写checkpoint的RPC接口
+type StoreCheckpointService interface {
+	GetCheckpoints(ctx context.Context, req *GetCheckpointsRequest) (*GetCheckpointsResponse, error)
+	UpdateCheckpoints(ctx context.Context, req *UpdateCheckpointsRequest) (*UpdateCheckpointsResponse, error)
+}
+
+var (
+	_ StoreCheckpointService = (*LocalStoreService)(nil)
+	_ StoreCheckpointService = (*HTTPStoreRPCClient)(nil)
+)
+
+// GetCheckpointsRequest 读取checkpoint请求
+type GetCheckpointsRequest struct {
+	UserIDs []string `json:"userIds"`
+}
+
+// GetCheckpointsResponse 读取checkpoint响应，顺序与请求中的UserIDs一致
+type GetCheckpointsResponse struct {
+	Checkpoints []*UserCheckpointState `json:"checkpoints"`
+}
+
+// UpdateCheckpointsRequest 批量更新checkpoint请求，全部更新原子生效
+type UpdateCheckpointsRequest struct {
+	Updates []CheckpointUpdate `json:"updates"`
+}
+
+// UpdateCheckpointsResponse 批量更新checkpoint响应
+type UpdateCheckpointsResponse struct {
+	Updated int `json:"updated"`
+}
+
+// GetCheckpoints 读取用户的全部同步与已读位置
+func (s *LocalStoreService) GetCheckpoints(ctx context.Context, req *GetCheckpointsRequest) (*GetCheckpointsResponse, error) {
+	return &GetCheckpointsResponse{Checkpoints: s.store.GetCheckpoints(req.UserIDs)}, nil
+}
+
+// UpdateCheckpoints 原子地应用一批checkpoint更新
+func (s *LocalStoreService) UpdateCheckpoints(ctx context.Context, req *UpdateCheckpointsRequest) (*UpdateCheckpointsResponse, error) {
+	for i := range req.Updates {
+		if err := req.Updates[i].validate(); err != nil {
+			return nil, NewRPCError(ErrCodeInvalidRequest, err.Error())
+		}
+	}
+	if err := s.store.UpdateCheckpoints(req.Updates); err != nil {
+		return nil, err
+	}
+	return &UpdateCheckpointsResponse{Updated: len(req.Updates)}, nil
+}
+
+// GetCheckpoints 读取远程Store上用户的checkpoint
+func (c *HTTPStoreRPCClient) GetCheckpoints(ctx context.Context, req *GetCheckpointsRequest) (*GetCheckpointsResponse, error) {
+	response, err := c.makeRequest(ctx, MethodGetCheckpoints, req)
+	if err != nil {
+		return nil, err
+	}
+	var result GetCheckpointsResponse
+	if err := parseResponse(response, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateCheckpoints 批量更新远程Store上的checkpoint
+func (c *HTTPStoreRPCClient) UpdateCheckpoints(ctx context.Context, req *UpdateCheckpointsRequest) (*UpdateCheckpointsResponse, error) {
+	response, err := c.makeRequest(ctx, MethodUpdateCheckpoints, req)
+	if err != nil {
+		return nil, err
+	}
+	var result UpdateCheckpointsResponse
+	if err := parseResponse(response, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,531 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkpoint的持久化
+// 用户checkpoint、会话已读位置与拉模式会话游标保存在数据目录下：
+//   - checkpoints.log：追加写入的日志，每次UpdateCheckpoints写入一条记录（长度+CRC32+JSON），
+//     一条记录包含一批更新，重放时整批生效或整批丢弃，因此多用户的更新是原子的
+//   - checkpoints.json：全部位置的快照，日志超过checkpointLogMaxSize及Close时写入快照并清空日志
+//
+// 启动时先读快照再按顺序重放日志，日志末尾残缺或校验失败的记录被截断。
+// 日志与WAL使用相同的刷盘策略（见StoreConfig.walSyncPolicy）：always每批fsync，interval由后台协程按间隔fsync，
+// none不主动fsync；需要每次确认都落盘的调用方应把多个用户的更新合并为一批。
+// 快照之后、清空日志之前崩溃时日志会被重放到快照上，重放的结果与快照一致。
+// 元数据后端为kv时（见metadata_backend.go）不使用上述文件，每个用户的全部位置作为一个键，
+// 一批更新涉及的用户在一个事务中写入。
+
+const (
+	checkpointLogFile      = "checkpoints.log"
+	checkpointSnapshotFile = "checkpoints.json"
+	checkpointLogMaxSize   = 16 * 1024 * 1024
+	checkpointHeaderSize   = 8 // 4字节长度 + 4字节CRC32
+)
+
+// CheckpointKind checkpoint的类型
+type CheckpointKind string
+
+const (
+	CheckpointUser CheckpointKind = "user" // 用户时间线的同步位置，直接设置
+	CheckpointConv CheckpointKind = "conv" // 用户在会话中的已读位置，只前进不后退
+	CheckpointPull CheckpointKind = "pull" // 拉模式会话的同步游标，只前进不后退，见pull_fanout.go
+)
+
+// CheckpointUpdate 一条checkpoint更新，ConvID只用于conv与pull类型
+type CheckpointUpdate struct {
+	Kind   CheckpointKind `json:"kind"`
+	UserID string         `json:"userId"`
+	ConvID string         `json:"convId,omitempty"`
+	SeqID  int64          `json:"seqId"`
+}
+
+// UserCheckpointState 用户的全部同步与已读位置
+type UserCheckpointState struct {
+	UserID      string           `json:"userId"`
+	SeqID       int64            `json:"seqId"`                 // 用户时间线的checkpoint
+	Convs       map[string]int64 `json:"convs,omitempty"`       // 会话已读位置
+	PullCursors map[string]int64 `json:"pullCursors,omitempty"` // 拉模式会话的同步游标
+}
+
+// checkpointSnapshot checkpoints.json的内容
+type checkpointSnapshot struct {
+	Users map[string]int64            `json:"users"`
+	Convs map[string]map[string]int64 `json:"convs"`
+	Pull  map[string]map[string]int64 `json:"pull"`
+}
+
+// checkpointLog checkpoint更新日志
+type checkpointLog struct {
+	mu     sync.Mutex
+	path   string
+	policy WALSyncPolicy
+	file   *os.File
+	size   int64
+	dirty  bool
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+// UpdateCheckpoints 原子地应用一批checkpoint更新：先写入日志，写入失败时不修改任何位置
+// user类型确认到GetMessagesAfterCheckpoint返回的拉模式消息时，同时推进对应会话的游标
+func (s *Store) UpdateCheckpoints(updates []CheckpointUpdate) error {
+	for _, update := range updates {
+		if err := update.validate(); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	resolved := make([]CheckpointUpdate, 0, len(updates))
+	var acked []string
+	for _, update := range updates {
+		if update.Kind == CheckpointUser {
+			seqID, cursors, done := s.resolvePullAckLocked(update.UserID, update.SeqID)
+			resolved = append(resolved, cursors...)
+			update.SeqID = seqID
+			if done {
+				acked = append(acked, update.UserID)
+			}
+		}
+		resolved = append(resolved, update)
+	}
+
+	if err := s.persistCheckpointsLocked(resolved); err != nil {
+		return fmt.Errorf("failed to persist checkpoints: %w", err)
+	}
+	for _, update := range resolved {
+		s.applyCheckpointLocked(update)
+	}
+	for _, userID := range acked {
+		delete(s.pullReads, userID)
+	}
+
+	if s.checkpoints.oversized() {
+		if err := s.compactCheckpointsLocked(); err != nil {
+			log.Printf("store %s: failed to compact checkpoints: %v", s.StoreID, err)
+		}
+	}
+	return nil
+}
+
+// GetCheckpoints 返回用户的全部同步与已读位置
+func (s *Store) GetCheckpoints(userIDs []string) []*UserCheckpointState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	states := make([]*UserCheckpointState, 0, len(userIDs))
+	for _, userID := range userIDs {
+		states = append(states, s.checkpointMaps().state(userID))
+	}
+	return states
+}
+
+// persistCheckpointsLocked 写入一批已解析的更新，调用方持有s.mu
+// files方式追加到checkpoint日志；kv方式按更新后的结果重写涉及的用户
+func (s *Store) persistCheckpointsLocked(updates []CheckpointUpdate) error {
+	if s.checkpoints != nil || s.metadata == nil {
+		return s.checkpoints.append(updates)
+	}
+	current := s.checkpointMaps()
+	touched := newCheckpointSnapshot()
+	for _, update := range updates {
+		if _, exists := touched.Users[update.UserID]; !exists {
+			touched.Users[update.UserID] = current.Users[update.UserID]
+			if convs := current.Convs[update.UserID]; len(convs) > 0 {
+				touched.Convs[update.UserID] = copyCheckpoints(convs)
+			}
+			if cursors := current.Pull[update.UserID]; len(cursors) > 0 {
+				touched.Pull[update.UserID] = copyCheckpoints(cursors)
+			}
+		}
+		touched.apply(update)
+	}
+	return s.metadata.Update(func(tx MetadataTx) error {
+		for _, state := range touched.states() {
+			data, err := json.Marshal(state)
+			if err != nil {
+				return err
+			}
+			tx.Put(metaBucketCheckpoints, state.UserID, data)
+		}
+		return nil
+	})
+}
+
+func (u *CheckpointUpdate) validate() error {
+	if u.UserID == "" {
+		return errors.New("checkpoint update requires a user id")
+	}
+	switch u.Kind {
+	case CheckpointUser:
+		return nil
+	case CheckpointConv, CheckpointPull:
+		if u.ConvID == "" {
+			return fmt.Errorf("%s checkpoint of %s requires a conversation id", u.Kind, u.UserID)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown checkpoint kind %q", u.Kind)
+	}
+}
+
+// applyCheckpointLocked 在内存中应用一条更新，调用方持有s.mu或处于启动阶段
+func (s *Store) applyCheckpointLocked(update CheckpointUpdate) {
+	s.checkpointMaps().apply(update)
+}
+
+// checkpointMaps 以快照的形式引用Store中的位置，修改直接作用于Store
+func (s *Store) checkpointMaps() checkpointSnapshot {
+	return checkpointSnapshot{Users: s.UserCheckpoints, Convs: s.ConvCheckpoints, Pull: s.PullCursors}
+}
+
+func newCheckpointSnapshot() checkpointSnapshot {
+	return checkpointSnapshot{
+		Users: make(map[string]int64),
+		Convs: make(map[string]map[string]int64),
+		Pull:  make(map[string]map[string]int64),
+	}
+}
+
+func (c checkpointSnapshot) apply(update CheckpointUpdate) {
+	switch update.Kind {
+	case CheckpointUser:
+		c.Users[update.UserID] = update.SeqID
+	case CheckpointConv:
+		advanceCheckpoint(c.Convs, update)
+	case CheckpointPull:
+		advanceCheckpoint(c.Pull, update)
+	}
+}
+
+// state 复制一个用户的全部位置
+func (c checkpointSnapshot) state(userID string) *UserCheckpointState {
+	state := &UserCheckpointState{UserID: userID, SeqID: c.Users[userID]}
+	if convs := c.Convs[userID]; len(convs) > 0 {
+		state.Convs = copyCheckpoints(convs)
+	}
+	if cursors := c.Pull[userID]; len(cursors) > 0 {
+		state.PullCursors = copyCheckpoints(cursors)
+	}
+	return state
+}
+
+// states 快照中所有用户的位置，按UserID排序
+func (c checkpointSnapshot) states() []*UserCheckpointState {
+	userIDs := make(map[string]struct{}, len(c.Users))
+	for userID := range c.Users {
+		userIDs[userID] = struct{}{}
+	}
+	for userID := range c.Convs {
+		userIDs[userID] = struct{}{}
+	}
+	for userID := range c.Pull {
+		userIDs[userID] = struct{}{}
+	}
+	sorted := make([]string, 0, len(userIDs))
+	for userID := range userIDs {
+		sorted = append(sorted, userID)
+	}
+	sort.Strings(sorted)
+	states := make([]*UserCheckpointState, 0, len(sorted))
+	for _, userID := range sorted {
+		states = append(states, c.state(userID))
+	}
+	return states
+}
+
+// advanceCheckpoint 推进UserID -> ConvID -> SeqID中的位置，只前进不后退
+func advanceCheckpoint(checkpoints map[string]map[string]int64, update CheckpointUpdate) {
+	convs := checkpoints[update.UserID]
+	if convs == nil {
+		convs = make(map[string]int64)
+		checkpoints[update.UserID] = convs
+	}
+	if update.SeqID > convs[update.ConvID] {
+		convs[update.ConvID] = update.SeqID
+	}
+}
+
+func copyCheckpoints(checkpoints map[string]int64) map[string]int64 {
+	copied := make(map[string]int64, len(checkpoints))
+	for key, seqID := range checkpoints {
+		copied[key] = seqID
+	}
+	return copied
+}
+
+// loadCheckpoints 启动时读取快照并重放日志，之后打开日志用于追加；kv方式下从元数据后端读取
+func (s *Store) loadCheckpoints() error {
+	if s.Config.MetadataBackend == MetadataBackendKV {
+		return s.metadata.ForEach(metaBucketCheckpoints, func(userID string, data []byte) error {
+			var state UserCheckpointState
+			if err := json.Unmarshal(data, &state); err != nil {
+				return fmt.Errorf("failed to parse checkpoints of %s: %w", userID, err)
+			}
+			s.UserCheckpoints[userID] = state.SeqID
+			if len(state.Convs) > 0 {
+				s.ConvCheckpoints[userID] = state.Convs
+			}
+			if len(state.PullCursors) > 0 {
+				s.PullCursors[userID] = state.PullCursors
+			}
+			return nil
+		})
+	}
+
+	snapshot, size, err := readCheckpointFiles(s.Config.DataDir)
+	if err != nil {
+		return err
+	}
+	for userID, seqID := range snapshot.Users {
+		s.UserCheckpoints[userID] = seqID
+	}
+	for userID, convs := range snapshot.Convs {
+		s.ConvCheckpoints[userID] = convs
+	}
+	for userID, cursors := range snapshot.Pull {
+		s.PullCursors[userID] = cursors
+	}
+
+	logPath := filepath.Join(s.Config.DataDir, checkpointLogFile)
+	file, err := os.OpenFile(logPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open checkpoint log: %w", err)
+	}
+	// 丢弃末尾残缺的记录
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(size, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	policy, interval := s.Config.walSyncPolicy()
+	if policy == "" {
+		policy = WALSyncInterval
+	}
+	if interval <= 0 {
+		interval = defaultWALSyncInterval
+	}
+	l := &checkpointLog{path: logPath, policy: policy, file: file, size: size, stopCh: make(chan struct{})}
+	if policy == WALSyncInterval {
+		l.wg.Add(1)
+		go l.syncLoop(interval)
+	}
+	s.checkpoints = l
+	return nil
+}
+
+// readCheckpointFiles 读取快照并重放日志，返回全部位置及日志中有效记录占用的字节数
+func readCheckpointFiles(dir string) (checkpointSnapshot, int64, error) {
+	snapshot := newCheckpointSnapshot()
+	if data, err := os.ReadFile(filepath.Join(dir, checkpointSnapshotFile)); err == nil {
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return snapshot, 0, fmt.Errorf("failed to parse checkpoint snapshot: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return snapshot, 0, err
+	}
+	// 快照中为空的map解码为nil
+	if snapshot.Users == nil {
+		snapshot.Users = make(map[string]int64)
+	}
+	if snapshot.Convs == nil {
+		snapshot.Convs = make(map[string]map[string]int64)
+	}
+	if snapshot.Pull == nil {
+		snapshot.Pull = make(map[string]map[string]int64)
+	}
+
+	batches, size, err := readCheckpointLog(filepath.Join(dir, checkpointLogFile))
+	if err != nil {
+		return snapshot, 0, err
+	}
+	for _, batch := range batches {
+		for _, update := range batch {
+			snapshot.apply(update)
+		}
+	}
+	return snapshot, size, nil
+}
+
+// readCheckpointLog 顺序读取日志中的批次，返回有效记录占用的字节数
+func readCheckpointLog(path string) ([][]CheckpointUpdate, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open checkpoint log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var batches [][]CheckpointUpdate
+	var offset int64
+	header := make([]byte, checkpointHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		var batch []CheckpointUpdate
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) || json.Unmarshal(payload, &batch) != nil {
+			log.Printf("checkpoint log %s: invalid record at offset %d, truncating", path, offset)
+			break
+		}
+		batches = append(batches, batch)
+		offset += checkpointHeaderSize + int64(length)
+	}
+	return batches, offset, nil
+}
+
+// append 写入一批更新，checkpoint日志未打开时忽略
+func (l *checkpointLog) append(batch []CheckpointUpdate) error {
+	if l == nil || len(batch) == 0 {
+		return nil
+	}
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, checkpointHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[checkpointHeaderSize:], payload)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return errors.New("checkpoint log is closed")
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return err
+	}
+	l.size += int64(len(buf))
+	if l.policy == WALSyncAlways {
+		return l.file.Sync()
+	}
+	l.dirty = l.policy == WALSyncInterval
+	return nil
+}
+
+// syncLoop 按间隔刷盘
+func (l *checkpointLog) syncLoop(interval time.Duration) {
+	defer l.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := l.Sync(); err != nil {
+				log.Printf("checkpoint log %s: sync failed: %v", l.path, err)
+			}
+		case <-l.stopCh:
+			return
+		}
+	}
+}
+
+// Sync 将已写入的更新刷盘
+func (l *checkpointLog) Sync() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil || !l.dirty {
+		return nil
+	}
+	l.dirty = false
+	return l.file.Sync()
+}
+
+func (l *checkpointLog) oversized() bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.size > checkpointLogMaxSize
+}
+
+// compactCheckpointsLocked 写入全部位置的快照并清空日志，调用方持有s.mu
+func (s *Store) compactCheckpointsLocked() error {
+	l := s.checkpoints
+	if l == nil {
+		return nil
+	}
+	data, err := json.Marshal(s.checkpointMaps())
+	if err != nil {
+		return err
+	}
+	if err := writeFileDurable(filepath.Join(s.Config.DataDir, checkpointSnapshotFile), data, s.durability); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := l.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	l.size = 0
+	l.dirty = l.policy == WALSyncInterval
+	return nil
+}
+
+// closeCheckpoints 关闭前写入快照并关闭日志
+func (s *Store) closeCheckpoints() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.checkpoints
+	if l == nil || l.file == nil {
+		return nil
+	}
+	var err error
+	if l.size > 0 {
+		err = s.compactCheckpointsLocked()
+	}
+	close(l.stopCh)
+	l.wg.Wait()
+	if l.policy != WALSyncNone {
+		if syncErr := l.Sync(); err == nil {
+			err = syncErr
+		}
+	}
+	l.mu.Lock()
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	l.mu.Unlock()
+	return err
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckpointsPersistAcrossRestart(t *testing.T) {
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	store.UpdateUserCheckpoint("1", 5)
+	store.UpdateConvCheckpoint("1", "c1", 7)
+	store.UpdateConvCheckpoint("1", "c1", 3) // 已读位置不后退
+	err = store.UpdateCheckpoints([]CheckpointUpdate{
+		{Kind: CheckpointUser, UserID: "2", SeqID: 9},
+		{Kind: CheckpointPull, UserID: "2", ConvID: "big", SeqID: 40},
+	})
+	if err != nil {
+		t.Fatalf("Failed to update checkpoints: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	if info, err := os.Stat(filepath.Join(config.DataDir, checkpointLogFile)); err != nil || info.Size() != 0 {
+		t.Fatalf("Expected the checkpoint log to be compacted on close: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	states := reopened.GetCheckpoints([]string{"1", "2", "3"})
+	if states[0].SeqID != 5 || states[0].Convs["c1"] != 7 {
+		t.Errorf("Unexpected checkpoints of user 1: %+v", states[0])
+	}
+	if states[1].SeqID != 9 || states[1].PullCursors["big"] != 40 {
+		t.Errorf("Unexpected checkpoints of user 2: %+v", states[1])
+	}
+	if states[2].SeqID != 0 || states[2].Convs != nil {
+		t.Errorf("Expected no checkpoints for user 3, got %+v", states[2])
+	}
+}
+
+func TestCheckpointLogReplay(t *testing.T) {
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for seqID := int64(1); seqID <= 3; seqID++ {
+		err := store.UpdateCheckpoints([]CheckpointUpdate{
+			{Kind: CheckpointUser, UserID: "1", SeqID: seqID},
+			{Kind: CheckpointUser, UserID: "2", SeqID: seqID * 10},
+		})
+		if err != nil {
+			t.Fatalf("Failed to update checkpoints: %v", err)
+		}
+	}
+	// 模拟崩溃：不写快照，日志末尾留下半条记录
+	store.checkpoints.Sync()
+	logPath := filepath.Join(config.DataDir, checkpointLogFile)
+	file, err := os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open checkpoint log: %v", err)
+	}
+	file.Write([]byte{0, 0, 0, 64, 1, 2})
+	file.Close()
+	info, _ := os.Stat(logPath)
+	store.checkpoints = nil
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if got := reopened.GetUserCheckpoint("1"); got != 3 {
+		t.Errorf("Expected user 1 checkpoint 3, got %d", got)
+	}
+	if got := reopened.GetUserCheckpoint("2"); got != 30 {
+		t.Errorf("Expected user 2 checkpoint 30, got %d", got)
+	}
+	if reopened.checkpoints.size != info.Size()-6 {
+		t.Errorf("Expected the partial record to be truncated, log size %d", reopened.checkpoints.size)
+	}
+}
+
+func TestUpdateCheckpointsRejectsInvalidBatch(t *testing.T) {
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	err = store.UpdateCheckpoints([]CheckpointUpdate{
+		{Kind: CheckpointUser, UserID: "1", SeqID: 4},
+		{Kind: CheckpointConv, UserID: "1", SeqID: 4}, // 缺少会话ID
+	})
+	if err == nil {
+		t.Fatal("Expected an invalid batch to be rejected")
+	}
+	if got := store.GetUserCheckpoint("1"); got != 0 {
+		t.Errorf("Expected no update from a rejected batch, got %d", got)
+	}
+}
+
+func TestCheckpointsOverRPC(t *testing.T) {
+	ctx := context.Background()
+	remote, ts := newTestRemoteStore(t)
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	updated, err := client.UpdateCheckpoints(ctx, &UpdateCheckpointsRequest{Updates: []CheckpointUpdate{
+		{Kind: CheckpointUser, UserID: "1", SeqID: 12},
+		{Kind: CheckpointConv, UserID: "2", ConvID: "c1", SeqID: 8},
+	}})
+	if err != nil || updated.Updated != 2 {
+		t.Fatalf("Unexpected update response %+v: %v", updated, err)
+	}
+	if got := remote.GetUserCheckpoint("1"); got != 12 {
+		t.Errorf("Expected the remote checkpoint to be 12, got %d", got)
+	}
+
+	resp, err := client.GetCheckpoints(ctx, &GetCheckpointsRequest{UserIDs: []string{"1", "2"}})
+	if err != nil || len(resp.Checkpoints) != 2 {
+		t.Fatalf("Unexpected get response %+v: %v", resp, err)
+	}
+	if resp.Checkpoints[0].SeqID != 12 || resp.Checkpoints[1].Convs["c1"] != 8 {
+		t.Errorf("Unexpected checkpoints %+v %+v", resp.Checkpoints[0], resp.Checkpoints[1])
+	}
+
+	_, err = client.UpdateCheckpoints(ctx, &UpdateCheckpointsRequest{Updates: []CheckpointUpdate{{Kind: "bogus", UserID: "1"}}})
+	if err == nil || !strings.Contains(err.Error(), "unknown checkpoint kind") {
+		t.Errorf("Expected an invalid request error, got %v", err)
+	}
+}
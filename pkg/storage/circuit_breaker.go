@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// 熔断器
+// 连续失败达到FailureThreshold后熔断器打开，OpenTimeout内的请求直接返回ErrCircuitBreakerOpen；
+// 超时后进入半开状态，最多放行HalfOpenProbes个试探请求，试探成功SuccessThreshold次后关闭，
+// 任一试探失败则重新打开。只有传输层错误计为失败，RPC返回的业务错误说明Store仍然可用；
+// 调用方取消或超时的请求不计入结果
+
+// ErrCircuitBreakerOpen 熔断器开启错误
+var ErrCircuitBreakerOpen = errors.New("circuit breaker is open")
+
+// CircuitState 熔断器状态
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerConfig 熔断器配置
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // 打开熔断器的连续失败次数，默认5
+	OpenTimeout      time.Duration // 打开后到放行试探请求的时间，默认30秒
+	HalfOpenProbes   int           // 半开状态下同时放行的试探请求数，默认1
+	SuccessThreshold int           // 半开状态下关闭熔断器所需的成功次数，默认1
+}
+
+// DefaultCircuitBreakerConfig 默认熔断器配置
+func DefaultCircuitBreakerConfig() CircuitBreakerConfig {
+	return CircuitBreakerConfig{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+		HalfOpenProbes:   1,
+		SuccessThreshold: 1,
+	}
+}
+
+func (c CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	defaults := DefaultCircuitBreakerConfig()
+	if c.FailureThreshold <= 0 {
+		c.FailureThreshold = defaults.FailureThreshold
+	}
+	if c.OpenTimeout <= 0 {
+		c.OpenTimeout = defaults.OpenTimeout
+	}
+	if c.HalfOpenProbes <= 0 {
+		c.HalfOpenProbes = defaults.HalfOpenProbes
+	}
+	if c.SuccessThreshold <= 0 {
+		c.SuccessThreshold = defaults.SuccessThreshold
+	}
+	return c
+}
+
+// CircuitBreakerStats 熔断器状态与统计
+type CircuitBreakerStats struct {
+	State      CircuitState  `json:"state"`
+	Failures   int           `json:"failures"`            // 当前连续失败次数
+	Opens      int64         `json:"opens"`               // 累计打开次数
+	Rejected   int64         `json:"rejected"`            // 累计被拒绝的请求数
+	OpenedAt   time.Time     `json:"opened_at,omitempty"` // 最近一次打开的时间
+	RetryAfter time.Duration `json:"retry_after,omitempty"`
+}
+
+// CircuitBreaker 熔断器
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	name      string
+	config    CircuitBreakerConfig
+	state     CircuitState
+	failures  int
+	successes int // 半开状态下的成功次数
+	inflight  int // 半开状态下未完成的试探请求
+	openedAt  time.Time
+	opens     int64
+	rejected  int64
+	now       func() time.Time
+}
+
+// NewCircuitBreaker 创建熔断器
+func NewCircuitBreaker(threshold int64, timeout time.Duration) *CircuitBreaker {
+	return NewCircuitBreakerWithConfig("", CircuitBreakerConfig{
+		FailureThreshold: int(threshold),
+		OpenTimeout:      timeout,
+	})
+}
+
+// NewCircuitBreakerWithConfig 创建熔断器，name用于日志，未设置的配置项使用默认值
+func NewCircuitBreakerWithConfig(name string, config CircuitBreakerConfig) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:   name,
+		config: config.withDefaults(),
+		state:  CircuitClosed,
+		now:    time.Now,
+	}
+}
+
+// Call 执行调用，熔断器打开时不调用fn直接返回ErrCircuitBreakerOpen
+func (cb *CircuitBreaker) Call(ctx context.Context, fn func() error) error {
+	if !cb.allow() {
+		return ErrCircuitBreakerOpen
+	}
+	err := fn()
+	cb.done(ctx, err)
+	return err
+}
+
+// allow 是否放行请求，放行的请求之后必须调用done
+func (cb *CircuitBreaker) allow() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if cb.now().Sub(cb.openedAt) < cb.config.OpenTimeout {
+			cb.rejected++
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		cb.successes = 0
+		cb.inflight = 0
+		fallthrough
+	case CircuitHalfOpen:
+		if cb.inflight >= cb.config.HalfOpenProbes {
+			cb.rejected++
+			return false
+		}
+		cb.inflight++
+	}
+	return true
+}
+
+// done 记录allow放行的请求的结果
+func (cb *CircuitBreaker) done(ctx context.Context, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// 调用方放弃的请求不能说明Store的状态
+	neutral := err != nil && ctx != nil && ctx.Err() != nil
+	if cb.state == CircuitHalfOpen {
+		if cb.inflight > 0 {
+			cb.inflight--
+		}
+		switch {
+		case neutral:
+		case err != nil:
+			cb.openLocked("probe failed: " + err.Error())
+		default:
+			cb.successes++
+			if cb.successes >= cb.config.SuccessThreshold {
+				cb.closeLocked()
+			}
+		}
+		return
+	}
+	if neutral || cb.state == CircuitOpen {
+		return
+	}
+	if err == nil {
+		cb.failures = 0
+		return
+	}
+	cb.failures++
+	if cb.failures >= cb.config.FailureThreshold {
+		cb.openLocked(err.Error())
+	}
+}
+
+func (cb *CircuitBreaker) openLocked(reason string) {
+	if cb.state != CircuitOpen {
+		log.Printf("circuit breaker %s opened: %s", cb.name, reason)
+	}
+	cb.state = CircuitOpen
+	cb.openedAt = cb.now()
+	cb.inflight = 0
+	cb.successes = 0
+	cb.opens++
+}
+
+func (cb *CircuitBreaker) closeLocked() {
+	log.Printf("circuit breaker %s closed", cb.name)
+	cb.state = CircuitClosed
+	cb.failures = 0
+	cb.successes = 0
+	cb.inflight = 0
+}
+
+// Rejecting 熔断器当前是否会拒绝请求，不占用试探名额
+func (cb *CircuitBreaker) Rejecting() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		return cb.now().Sub(cb.openedAt) < cb.config.OpenTimeout
+	case CircuitHalfOpen:
+		return cb.inflight >= cb.config.HalfOpenProbes
+	}
+	return false
+}
+
+// State 返回熔断器状态
+func (cb *CircuitBreaker) State() CircuitState {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+// Stats 返回熔断器状态与统计
+func (cb *CircuitBreaker) Stats() CircuitBreakerStats {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	stats := CircuitBreakerStats{
+		State:    cb.state,
+		Failures: cb.failures,
+		Opens:    cb.opens,
+		Rejected: cb.rejected,
+		OpenedAt: cb.openedAt,
+	}
+	if cb.state == CircuitOpen {
+		stats.RetryAfter = max(cb.config.OpenTimeout-cb.now().Sub(cb.openedAt), 0)
+	}
+	return stats
+}
+
+// CircuitBreakerGroup 按StoreID维护的熔断器，同一Store的所有RPC客户端共用一个熔断器
+type CircuitBreakerGroup struct {
+	mu       sync.RWMutex
+	config   CircuitBreakerConfig
+	breakers map[string]*CircuitBreaker
+}
+
+// NewCircuitBreakerGroup 创建熔断器组，所有Store使用相同的配置
+func NewCircuitBreakerGroup(config CircuitBreakerConfig) *CircuitBreakerGroup {
+	return &CircuitBreakerGroup{
+		config:   config.withDefaults(),
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// Get 获取Store的熔断器，不存在时创建
+func (g *CircuitBreakerGroup) Get(storeID string) *CircuitBreaker {
+	g.mu.RLock()
+	cb, exists := g.breakers[storeID]
+	g.mu.RUnlock()
+	if exists {
+		return cb
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cb, exists := g.breakers[storeID]; exists {
+		return cb
+	}
+	cb = NewCircuitBreakerWithConfig(storeID, g.config)
+	g.breakers[storeID] = cb
+	return cb
+}
+
+// Rejecting Store的熔断器当前是否会拒绝请求，没有熔断器的Store返回false
+func (g *CircuitBreakerGroup) Rejecting(storeID string) bool {
+	g.mu.RLock()
+	cb, exists := g.breakers[storeID]
+	g.mu.RUnlock()
+	return exists && cb.Rejecting()
+}
+
+// Remove 移除已下线Store的熔断器
+func (g *CircuitBreakerGroup) Remove(storeID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.breakers, storeID)
+}
+
+// Stats 返回各Store熔断器的状态
+func (g *CircuitBreakerGroup) Stats() map[string]CircuitBreakerStats {
+	g.mu.RLock()
+	breakers := make(map[string]*CircuitBreaker, len(g.breakers))
+	for storeID, cb := range g.breakers {
+		breakers[storeID] = cb
+	}
+	g.mu.RUnlock()
+
+	stats := make(map[string]CircuitBreakerStats, len(breakers))
+	for storeID, cb := range breakers {
+		stats[storeID] = cb.Stats()
+	}
+	return stats
+}
@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStateTransitions(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	cb := NewCircuitBreakerWithConfig("store_a", CircuitBreakerConfig{
+		FailureThreshold: 3,
+		OpenTimeout:      10 * time.Second,
+		HalfOpenProbes:   1,
+		SuccessThreshold: 2,
+	})
+	cb.now = func() time.Time { return now }
+	failure := errors.New("connection refused")
+	fail := func() error { return failure }
+	succeed := func() error { return nil }
+
+	// 成功会清零连续失败次数
+	cb.Call(ctx, fail)
+	cb.Call(ctx, fail)
+	cb.Call(ctx, succeed)
+	cb.Call(ctx, fail)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Expected breaker to stay closed, got %s", cb.State())
+	}
+	cb.Call(ctx, fail)
+	cb.Call(ctx, fail)
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Expected breaker to open after 3 consecutive failures, got %s", cb.State())
+	}
+
+	called := false
+	if err := cb.Call(ctx, func() error { called = true; return nil }); !errors.Is(err, ErrCircuitBreakerOpen) || called {
+		t.Fatalf("Expected open breaker to reject without calling, got %v", err)
+	}
+
+	// 超时后只放行一个试探请求，试探失败重新打开
+	now = now.Add(11 * time.Second)
+	if cb.Rejecting() {
+		t.Fatalf("Expected a probe to be allowed after OpenTimeout")
+	}
+	release := make(chan struct{})
+	probeDone := make(chan error)
+	go func() {
+		probeDone <- cb.Call(ctx, func() error { <-release; return failure })
+	}()
+	for !cb.Rejecting() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := cb.Call(ctx, succeed); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("Expected concurrent request to be rejected while probing, got %v", err)
+	}
+	close(release)
+	<-probeDone
+	if cb.State() != CircuitOpen {
+		t.Fatalf("Expected failed probe to reopen the breaker, got %s", cb.State())
+	}
+
+	// 试探成功两次后关闭
+	now = now.Add(11 * time.Second)
+	cb.Call(ctx, succeed)
+	if cb.State() != CircuitHalfOpen {
+		t.Fatalf("Expected breaker to stay half-open after one success, got %s", cb.State())
+	}
+	cb.Call(ctx, succeed)
+	if cb.State() != CircuitClosed {
+		t.Fatalf("Expected breaker to close after two successful probes, got %s", cb.State())
+	}
+
+	stats := cb.Stats()
+	if stats.Opens != 2 || stats.Rejected != 2 || stats.Failures != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// 调用方取消的请求不计为失败
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 0; i < 5; i++ {
+		cb.Call(cancelled, func() error { return cancelled.Err() })
+	}
+	if cb.State() != CircuitClosed {
+		t.Errorf("Expected cancelled requests not to open the breaker, got %s", cb.State())
+	}
+}
+
+func TestRPCClientPoolCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	remote, ts := newTestRemoteStore(t)
+
+	breakers := NewCircuitBreakerGroup(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	pool := NewStoreRPCClientPool(time.Second)
+	defer pool.Close()
+	pool.SetCircuitBreakers(breakers)
+
+	client, err := pool.GetClient(ctx, remote.StoreID, ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to get client: %v", err)
+	}
+	if _, err := client.HealthCheck(ctx, &HealthCheckRequest{Ping: "ping"}); err != nil {
+		t.Fatalf("Health check failed: %v", err)
+	}
+	// 业务错误不打开熔断器
+	if _, err := client.EditMessage(ctx, &EditMessageRequest{TimelineKey: "conv_missing", SeqID: 1, SenderID: 1}); err == nil {
+		t.Fatalf("Expected an error editing a missing message")
+	}
+	if state := breakers.Get(remote.StoreID).State(); state != CircuitClosed {
+		t.Fatalf("Expected RPC errors to keep the breaker closed, got %s", state)
+	}
+
+	ts.Close()
+	if _, err := client.HealthCheck(ctx, &HealthCheckRequest{Ping: "ping"}); err == nil || errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Fatalf("Expected a transport error, got %v", err)
+	}
+	if _, err := client.HealthCheck(ctx, &HealthCheckRequest{Ping: "ping"}); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("Expected fast failure while open, got %v", err)
+	}
+	pool.RemoveClient(remote.StoreID)
+	if _, err := pool.GetClient(ctx, remote.StoreID, ts.URL); !errors.Is(err, ErrCircuitBreakerOpen) {
+		t.Errorf("Expected the pool to refuse new clients while open, got %v", err)
+	}
+
+	stats := breakers.Stats()[remote.StoreID]
+	if stats.State != CircuitOpen || stats.Opens != 1 || stats.Rejected == 0 || stats.RetryAfter <= 0 {
+		t.Errorf("Unexpected breaker stats %+v", stats)
+	}
+}
+
+func TestReadFallsBackToReplicaWhenCircuitOpen(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	defer local.Close()
+	primary, primaryServer := newTestRemoteStore(t)
+	replica, replicaServer := newTestRemoteStore(t)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	router := NewConsistentHashRouter(3, 10, 0.8)
+	for _, info := range []*StoreInfo{
+		{ID: local.StoreID},
+		{ID: primary.StoreID, Address: primaryServer.URL},
+		{ID: replica.StoreID, Address: replicaServer.URL},
+	} {
+		registry.Register(ctx, info)
+		router.AddStore(&StoreInfo{ID: info.ID, Status: StoreStatusActive})
+	}
+
+	timelineKey := "conv_fallback"
+	globalIndex := NewInMemoryGlobalIndex()
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: primary.StoreID, BlockID: "block_1"})
+	primary.AddMessage(timelineKey, 1, []byte("hello"), nil)
+	replica.AddMessage(timelineKey, 1, []byte("hello"), nil)
+
+	breakers := NewCircuitBreakerGroup(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	pool := NewStoreRPCClientPool(time.Second)
+	defer pool.Close()
+	pool.SetCircuitBreakers(breakers)
+
+	policy := DefaultShardPolicy()
+	policy.ReplicationFactor = 3
+	accessor := NewDistributedStoreAccessor(local, pool, globalIndex, router, registry)
+	accessor.SetReplicationManager(NewReplicationManager(local, router, registry, globalIndex, pool, policy))
+
+	// 主Store宕机，熔断器打开前的读取直接失败
+	primaryServer.Close()
+	if _, err := accessor.GetMessages(ctx, timelineKey, 0, time.Now().Unix()+1, 10); err == nil {
+		t.Fatalf("Expected the read to fail while the breaker is still closed")
+	}
+	if state := breakers.Get(primary.StoreID).State(); state != CircuitOpen {
+		t.Fatalf("Expected the primary's breaker to open, got %s", state)
+	}
+
+	messages, err := accessor.GetMessages(ctx, timelineKey, 0, time.Now().Unix()+1, 10)
+	if err != nil {
+		t.Fatalf("Expected the read to be served by the replica, got %v", err)
+	}
+	if len(messages) != 1 || string(messages[0].Data) != "hello" {
+		t.Errorf("Unexpected messages from replica: %+v", messages)
+	}
+	timeline, err := accessor.GetTimeline(ctx, timelineKey)
+	if err != nil || timeline.ID != timelineKey {
+		t.Errorf("Expected the timeline from the replica, got %v", err)
+	}
+}
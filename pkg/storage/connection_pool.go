@@ -0,0 +1,465 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 连接池
+// 按StoreID缓存HTTPStoreRPCClient，Store地址从注册中心解析。每个Store与全部Store的连接数
+// （使用中与空闲之和）分别受限，达到上限时先关闭其他Store最久未用的空闲连接腾出名额，
+// 仍不够时返回ErrPoolExhausted，不排队等待。
+// 空闲超过IdleTimeout的连接被关闭；后台健康检查失败或已从注册中心注销的Store，
+// 其空闲连接全部关闭，没有连接的Store不再保留条目
+
+var (
+	ErrPoolExhausted = errors.New("connection pool exhausted")
+	ErrPoolClosed    = errors.New("connection pool is closed")
+)
+
+// ConnectionPoolConfig 连接池配置
+type ConnectionPoolConfig struct {
+	MaxPerStore         int           // 每个Store的最大连接数，默认10
+	MaxTotal            int           // 全部Store的最大连接数，默认100
+	IdleTimeout         time.Duration // 空闲超过该时间的连接被关闭，默认60秒
+	RequestTimeout      time.Duration // 连接上RPC请求的超时，默认10秒
+	HealthCheckInterval time.Duration // 后台空闲回收与健康检查的间隔，默认30秒
+	TLSConfig           *tls.Config   // 为nil时使用明文连接
+	RetryPolicy         *RetryPolicy  // 为nil时使用DefaultRetryPolicy，同一Store的连接共用一个重试预算
+	Signer              *RPCSigner    // 启用共享密钥认证时为请求签名
+}
+
+// DefaultConnectionPoolConfig 默认连接池配置
+func DefaultConnectionPoolConfig() ConnectionPoolConfig {
+	return ConnectionPoolConfig{
+		MaxPerStore:         10,
+		MaxTotal:            100,
+		IdleTimeout:         60 * time.Second,
+		RequestTimeout:      10 * time.Second,
+		HealthCheckInterval: 30 * time.Second,
+	}
+}
+
+// Connection 连接池中的连接
+type Connection struct {
+	ID       string
+	StoreID  string
+	Address  string
+	Client   *HTTPStoreRPCClient
+	LastUsed time.Time
+	InUse    bool
+}
+
+// PoolStats 连接池统计
+type PoolStats struct {
+	Stores               int   `json:"stores"`
+	TotalConnections     int64 `json:"total_connections"`
+	ActiveConnections    int64 `json:"active_connections"`
+	IdleConnections      int64 `json:"idle_connections"`
+	ConnectionsCreated   int64 `json:"connections_created"`
+	ConnectionsDestroyed int64 `json:"connections_destroyed"`
+	IdleEvictions        int64 `json:"idle_evictions"`
+	UnhealthyEvictions   int64 `json:"unhealthy_evictions"`
+	HealthChecks         int64 `json:"health_checks"`
+	HealthCheckFailures  int64 `json:"health_check_failures"`
+	Exhausted            int64 `json:"exhausted"` // 因达到上限而拒绝的获取次数
+}
+
+// storeConnections 单个Store的连接，idle按释放时间排列，末尾为最近释放的连接
+type storeConnections struct {
+	idle   []*Connection
+	active int
+}
+
+// ConnectionPool Store RPC连接池
+type ConnectionPool struct {
+	mu       sync.Mutex
+	registry StoreRegistry
+	config   ConnectionPoolConfig
+	stores   map[string]*storeConnections
+	total    int // 所有Store使用中与空闲连接之和，包括正在建立的连接
+	stats    PoolStats
+	nextID   int64
+	closed   bool
+	running  bool
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+	now      func() time.Time
+	breakers *CircuitBreakerGroup
+	retries  *RetryBudgets
+}
+
+// NewConnectionPool 创建连接池，registry用于解析Store地址，未设置的配置项使用默认值
+func NewConnectionPool(registry StoreRegistry, config ConnectionPoolConfig) *ConnectionPool {
+	defaults := DefaultConnectionPoolConfig()
+	if config.MaxPerStore <= 0 {
+		config.MaxPerStore = defaults.MaxPerStore
+	}
+	if config.MaxTotal <= 0 {
+		config.MaxTotal = defaults.MaxTotal
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = defaults.IdleTimeout
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = defaults.RequestTimeout
+	}
+	if config.HealthCheckInterval <= 0 {
+		config.HealthCheckInterval = defaults.HealthCheckInterval
+	}
+	retryPolicy := DefaultRetryPolicy()
+	if config.RetryPolicy != nil {
+		retryPolicy = *config.RetryPolicy
+	}
+	return &ConnectionPool{
+		registry: registry,
+		config:   config,
+		stores:   make(map[string]*storeConnections),
+		now:      time.Now,
+		retries:  NewRetryBudgets(retryPolicy),
+	}
+}
+
+// SetCircuitBreakers 设置按Store划分的熔断器，之后新建的连接都经过对应Store的熔断器
+func (cp *ConnectionPool) SetCircuitBreakers(breakers *CircuitBreakerGroup) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	cp.breakers = breakers
+}
+
+// Get 获取到Store的连接，优先复用空闲连接，否则从注册中心解析地址并新建
+// 使用完毕后调用Release归还，请求失败时调用Discard关闭
+func (cp *ConnectionPool) Get(ctx context.Context, storeID string) (*Connection, error) {
+	cp.mu.Lock()
+	if cp.closed {
+		cp.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	if cp.breakers != nil && cp.breakers.Rejecting(storeID) {
+		cp.mu.Unlock()
+		return nil, fmt.Errorf("store %s: %w", storeID, ErrCircuitBreakerOpen)
+	}
+	now := cp.now()
+	sc := cp.stores[storeID]
+	if sc == nil {
+		sc = &storeConnections{}
+		cp.stores[storeID] = sc
+	}
+	cp.evictIdleLocked(sc, now)
+
+	if n := len(sc.idle); n > 0 {
+		conn := sc.idle[n-1]
+		sc.idle[n-1] = nil
+		sc.idle = sc.idle[:n-1]
+		sc.active++
+		conn.InUse = true
+		conn.LastUsed = now
+		cp.mu.Unlock()
+		return conn, nil
+	}
+	if sc.active >= cp.config.MaxPerStore || (cp.total >= cp.config.MaxTotal && !cp.evictOldestIdleLocked()) {
+		cp.stats.Exhausted++
+		cp.pruneLocked(storeID)
+		cp.mu.Unlock()
+		return nil, fmt.Errorf("%w: store %s", ErrPoolExhausted, storeID)
+	}
+	// 先占用名额再在锁外建立连接
+	sc.active++
+	cp.total++
+	cp.nextID++
+	id := fmt.Sprintf("%s-%d", storeID, cp.nextID)
+	var breaker *CircuitBreaker
+	if cp.breakers != nil {
+		breaker = cp.breakers.Get(storeID)
+	}
+	cp.mu.Unlock()
+
+	client, address, err := cp.dial(ctx, storeID, breaker)
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if err != nil {
+		sc.active--
+		cp.total--
+		cp.pruneLocked(storeID)
+		return nil, err
+	}
+	cp.stats.ConnectionsCreated++
+	return &Connection{
+		ID:       id,
+		StoreID:  storeID,
+		Address:  address,
+		Client:   client,
+		LastUsed: cp.now(),
+		InUse:    true,
+	}, nil
+}
+
+// dial 解析Store地址并建立经过健康检查的客户端
+func (cp *ConnectionPool) dial(ctx context.Context, storeID string, breaker *CircuitBreaker) (*HTTPStoreRPCClient, string, error) {
+	if cp.registry == nil {
+		return nil, "", fmt.Errorf("no registry to resolve store %s", storeID)
+	}
+	info, err := cp.registry.GetStore(ctx, storeID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to resolve store %s: %w", storeID, err)
+	}
+	if !info.Status.Serving() {
+		return nil, "", fmt.Errorf("store %s is %s", storeID, info.Status)
+	}
+
+	client := NewHTTPStoreRPCClient(cp.config.RequestTimeout)
+	if cp.config.TLSConfig != nil {
+		client.SetTLSConfig(cp.config.TLSConfig)
+	}
+	if breaker != nil {
+		client.SetCircuitBreaker(breaker)
+	}
+	client.SetRetryPolicy(cp.retries.Policy(), cp.retries.Get(storeID))
+	if cp.config.Signer != nil {
+		client.SetRequestSigner(cp.config.Signer)
+	}
+	if err := client.Connect(ctx, info.Address); err != nil {
+		return nil, "", err
+	}
+	return client, info.Address, nil
+}
+
+// Release 归还连接，连接池已关闭或连接已断开时直接关闭
+func (cp *ConnectionPool) Release(conn *Connection) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	sc := cp.stores[conn.StoreID]
+	if sc == nil || !conn.InUse {
+		return
+	}
+	sc.active--
+	conn.InUse = false
+	if cp.closed || !conn.Client.IsConnected() {
+		cp.destroyLocked(conn)
+		cp.pruneLocked(conn.StoreID)
+		return
+	}
+	conn.LastUsed = cp.now()
+	sc.idle = append(sc.idle, conn)
+}
+
+// Discard 关闭请求失败的连接，不再放回连接池
+func (cp *ConnectionPool) Discard(conn *Connection) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	sc := cp.stores[conn.StoreID]
+	if sc == nil || !conn.InUse {
+		return
+	}
+	sc.active--
+	conn.InUse = false
+	cp.destroyLocked(conn)
+	cp.pruneLocked(conn.StoreID)
+}
+
+// destroyLocked 关闭连接并释放名额，调用方已将连接移出空闲列表
+func (cp *ConnectionPool) destroyLocked(conn *Connection) {
+	conn.Client.Disconnect()
+	cp.total--
+	cp.stats.ConnectionsDestroyed++
+}
+
+// evictIdleLocked 关闭Store中空闲超过IdleTimeout的连接
+func (cp *ConnectionPool) evictIdleLocked(sc *storeConnections, now time.Time) {
+	expired := 0
+	for expired < len(sc.idle) && now.Sub(sc.idle[expired].LastUsed) >= cp.config.IdleTimeout {
+		cp.destroyLocked(sc.idle[expired])
+		sc.idle[expired] = nil
+		expired++
+	}
+	if expired > 0 {
+		cp.stats.IdleEvictions += int64(expired)
+		sc.idle = sc.idle[expired:]
+	}
+}
+
+// evictOldestIdleLocked 关闭所有Store中最久未用的空闲连接，没有空闲连接时返回false
+func (cp *ConnectionPool) evictOldestIdleLocked() bool {
+	var oldestID string
+	var oldest *Connection
+	for storeID, sc := range cp.stores {
+		if len(sc.idle) > 0 && (oldest == nil || sc.idle[0].LastUsed.Before(oldest.LastUsed)) {
+			oldestID, oldest = storeID, sc.idle[0]
+		}
+	}
+	if oldest == nil {
+		return false
+	}
+	sc := cp.stores[oldestID]
+	sc.idle[0] = nil
+	sc.idle = sc.idle[1:]
+	cp.destroyLocked(oldest)
+	cp.pruneLocked(oldestID)
+	return true
+}
+
+// pruneLocked 移除没有任何连接的Store条目
+func (cp *ConnectionPool) pruneLocked(storeID string) {
+	if sc := cp.stores[storeID]; sc != nil && sc.active == 0 && len(sc.idle) == 0 {
+		delete(cp.stores, storeID)
+	}
+}
+
+// evictStoreLocked 关闭Store的全部空闲连接
+func (cp *ConnectionPool) evictStoreLocked(storeID string) {
+	sc := cp.stores[storeID]
+	if sc == nil {
+		return
+	}
+	for _, conn := range sc.idle {
+		cp.destroyLocked(conn)
+	}
+	cp.stats.UnhealthyEvictions += int64(len(sc.idle))
+	sc.idle = nil
+	cp.pruneLocked(storeID)
+}
+
+// Maintain 回收超时的空闲连接，并对每个有空闲连接的Store做一次健康检查，
+// 检查失败或已注销的Store关闭其全部空闲连接。后台循环按HealthCheckInterval调用
+func (cp *ConnectionPool) Maintain(ctx context.Context) {
+	cp.mu.Lock()
+	now := cp.now()
+	probes := make(map[string]*Connection)
+	for storeID, sc := range cp.stores {
+		cp.evictIdleLocked(sc, now)
+		// 取出最近使用的空闲连接做检查，检查期间不会被分配
+		if n := len(sc.idle); n > 0 {
+			probe := sc.idle[n-1]
+			sc.idle[n-1] = nil
+			sc.idle = sc.idle[:n-1]
+			sc.active++
+			probe.InUse = true
+			probes[storeID] = probe
+		}
+		cp.pruneLocked(storeID)
+	}
+	cp.mu.Unlock()
+
+	for storeID, probe := range probes {
+		err := cp.checkStore(ctx, probe)
+
+		cp.mu.Lock()
+		cp.stats.HealthChecks++
+		if err != nil {
+			cp.stats.HealthCheckFailures++
+			log.Printf("connection pool: store %s failed health check, closing idle connections: %v", storeID, err)
+			cp.evictStoreLocked(storeID)
+		}
+		cp.mu.Unlock()
+
+		if err != nil {
+			cp.Discard(probe)
+		} else {
+			cp.Release(probe)
+		}
+	}
+}
+
+// checkStore 确认Store仍在注册中心且能响应健康检查
+func (cp *ConnectionPool) checkStore(ctx context.Context, probe *Connection) error {
+	if cp.registry != nil {
+		info, err := cp.registry.GetStore(ctx, probe.StoreID)
+		if err != nil {
+			return err
+		}
+		if !info.Status.Serving() {
+			return fmt.Errorf("store %s is %s", probe.StoreID, info.Status)
+		}
+		if info.Address != probe.Address {
+			return fmt.Errorf("store %s moved to %s", probe.StoreID, info.Address)
+		}
+	}
+	checkCtx, cancel := context.WithTimeout(ctx, cp.config.RequestTimeout)
+	defer cancel()
+	_, err := probe.Client.HealthCheck(checkCtx, &HealthCheckRequest{Ping: "ping"})
+	return err
+}
+
+// Start 启动后台空闲回收与健康检查
+func (cp *ConnectionPool) Start(ctx context.Context) error {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.closed {
+		return ErrPoolClosed
+	}
+	if cp.running {
+		return fmt.Errorf("connection pool maintenance is already running")
+	}
+	cp.stopCh = make(chan struct{})
+	cp.running = true
+
+	cp.wg.Add(1)
+	go cp.maintainLoop(ctx, cp.stopCh)
+	return nil
+}
+
+func (cp *ConnectionPool) maintainLoop(ctx context.Context, stopCh chan struct{}) {
+	defer cp.wg.Done()
+
+	ticker := time.NewTicker(cp.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			cp.Maintain(ctx)
+		}
+	}
+}
+
+// Close 停止后台任务并关闭所有空闲连接，使用中的连接在归还时关闭
+func (cp *ConnectionPool) Close() {
+	cp.mu.Lock()
+	if cp.closed {
+		cp.mu.Unlock()
+		return
+	}
+	cp.closed = true
+	if cp.running {
+		close(cp.stopCh)
+		cp.running = false
+	}
+	for storeID, sc := range cp.stores {
+		for _, conn := range sc.idle {
+			cp.destroyLocked(conn)
+		}
+		sc.idle = nil
+		cp.pruneLocked(storeID)
+	}
+	cp.mu.Unlock()
+
+	cp.wg.Wait()
+}
+
+// Stats 返回连接池统计
+func (cp *ConnectionPool) Stats() PoolStats {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	stats := cp.stats
+	stats.Stores = len(cp.stores)
+	stats.TotalConnections = int64(cp.total)
+	for _, sc := range cp.stores {
+		stats.ActiveConnections += int64(sc.active)
+		stats.IdleConnections += int64(len(sc.idle))
+	}
+	return stats
+}
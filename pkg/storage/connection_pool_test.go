@@ -0,0 +1,193 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestConnectionPool(t *testing.T, config ConnectionPoolConfig, storeIDs ...string) (*ConnectionPool, *InMemoryRegistry) {
+	t.Helper()
+	ctx := context.Background()
+	registry := NewInMemoryRegistry()
+	t.Cleanup(func() { registry.Close() })
+	for _, id := range storeIDs {
+		_, ts := newTestRemoteStore(t)
+		registry.Register(ctx, &StoreInfo{ID: id, Address: ts.URL, Status: StoreStatusActive})
+	}
+	pool := NewConnectionPool(registry, config)
+	t.Cleanup(pool.Close)
+	return pool, registry
+}
+
+func TestConnectionPoolReusesConnections(t *testing.T) {
+	ctx := context.Background()
+	pool, _ := newTestConnectionPool(t, ConnectionPoolConfig{}, "store_a")
+
+	conn, err := pool.Get(ctx, "store_a")
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	if conn.Client == nil || !conn.Client.IsConnected() {
+		t.Fatalf("Expected a connected client")
+	}
+	if _, err := conn.Client.HealthCheck(ctx, &HealthCheckRequest{Ping: "ping"}); err != nil {
+		t.Fatalf("Health check through pooled client failed: %v", err)
+	}
+	pool.Release(conn)
+
+	again, err := pool.Get(ctx, "store_a")
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	if again != conn {
+		t.Errorf("Expected the idle connection to be reused")
+	}
+	stats := pool.Stats()
+	if stats.ConnectionsCreated != 1 || stats.ActiveConnections != 1 || stats.IdleConnections != 0 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+
+	// 失败的连接不再放回连接池
+	pool.Discard(again)
+	if stats := pool.Stats(); stats.TotalConnections != 0 || stats.Stores != 0 || stats.ConnectionsDestroyed != 1 {
+		t.Errorf("Expected discarded connection to be destroyed, got %+v", stats)
+	}
+
+	if _, err := pool.Get(ctx, "store_missing"); err == nil {
+		t.Errorf("Expected an error for an unregistered store")
+	}
+	if stats := pool.Stats(); stats.Stores != 0 || stats.TotalConnections != 0 {
+		t.Errorf("Expected failed dial to leave no entry, got %+v", stats)
+	}
+}
+
+func TestConnectionPoolLimits(t *testing.T) {
+	ctx := context.Background()
+	pool, _ := newTestConnectionPool(t, ConnectionPoolConfig{MaxPerStore: 2, MaxTotal: 3}, "store_a", "store_b")
+
+	a1, _ := pool.Get(ctx, "store_a")
+	a2, _ := pool.Get(ctx, "store_a")
+	if _, err := pool.Get(ctx, "store_a"); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("Expected per-store limit, got %v", err)
+	}
+
+	b1, err := pool.Get(ctx, "store_b")
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	if _, err := pool.Get(ctx, "store_b"); !errors.Is(err, ErrPoolExhausted) {
+		t.Fatalf("Expected global limit, got %v", err)
+	}
+
+	// 达到全局上限时关闭其他Store的空闲连接腾出名额
+	pool.Release(a1)
+	if _, err := pool.Get(ctx, "store_b"); err != nil {
+		t.Fatalf("Expected an idle connection of store_a to be evicted, got %v", err)
+	}
+	stats := pool.Stats()
+	if stats.TotalConnections != 3 || stats.IdleConnections != 0 || stats.Exhausted != 2 || stats.ConnectionsDestroyed != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	pool.Release(a2)
+	pool.Release(b1)
+}
+
+func TestConnectionPoolEvictsIdleConnections(t *testing.T) {
+	ctx := context.Background()
+	pool, _ := newTestConnectionPool(t, ConnectionPoolConfig{IdleTimeout: time.Minute}, "store_a", "store_b")
+	now := time.Now()
+	pool.now = func() time.Time { return now }
+
+	a, _ := pool.Get(ctx, "store_a")
+	b, _ := pool.Get(ctx, "store_b")
+	pool.Release(a)
+	now = now.Add(30 * time.Second)
+	pool.Release(b)
+
+	now = now.Add(45 * time.Second)
+	pool.Maintain(ctx)
+	stats := pool.Stats()
+	if stats.IdleEvictions != 1 || stats.Stores != 1 || stats.IdleConnections != 1 {
+		t.Errorf("Expected only store_a's connection to expire, got %+v", stats)
+	}
+	if stats.HealthChecks != 1 || stats.HealthCheckFailures != 0 {
+		t.Errorf("Expected one successful health check, got %+v", stats)
+	}
+
+	// Get同样不会返回过期的连接
+	now = now.Add(2 * time.Minute)
+	conn, err := pool.Get(ctx, "store_b")
+	if err != nil {
+		t.Fatalf("Failed to get connection: %v", err)
+	}
+	if conn == b {
+		t.Errorf("Expected an expired connection to be replaced")
+	}
+	pool.Release(conn)
+}
+
+func TestConnectionPoolHealthEviction(t *testing.T) {
+	ctx := context.Background()
+	pool, registry := newTestConnectionPool(t, ConnectionPoolConfig{}, "store_b")
+	_, tsA := newTestRemoteStore(t)
+	registry.Register(ctx, &StoreInfo{ID: "store_a", Address: tsA.URL, Status: StoreStatusActive})
+
+	var conns []*Connection
+	for i := 0; i < 3; i++ {
+		conn, err := pool.Get(ctx, "store_a")
+		if err != nil {
+			t.Fatalf("Failed to get connection: %v", err)
+		}
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns {
+		pool.Release(conn)
+	}
+	b, _ := pool.Get(ctx, "store_b")
+	pool.Release(b)
+
+	// store_a宕机后其空闲连接全部关闭，条目随之移除
+	tsA.Close()
+	pool.Maintain(ctx)
+	stats := pool.Stats()
+	if stats.HealthCheckFailures != 1 || stats.UnhealthyEvictions != 2 || stats.Stores != 1 || stats.TotalConnections != 1 {
+		t.Errorf("Unexpected stats after store_a failed: %+v", stats)
+	}
+
+	// 注销的Store同样被清理
+	registry.Unregister(ctx, "store_b")
+	pool.Maintain(ctx)
+	if stats := pool.Stats(); stats.Stores != 0 || stats.TotalConnections != 0 {
+		t.Errorf("Expected unregistered store to be pruned, got %+v", stats)
+	}
+}
+
+func TestConnectionPoolClose(t *testing.T) {
+	ctx := context.Background()
+	pool, _ := newTestConnectionPool(t, ConnectionPoolConfig{HealthCheckInterval: 10 * time.Millisecond}, "store_a")
+	if err := pool.Start(ctx); err != nil {
+		t.Fatalf("Failed to start maintenance: %v", err)
+	}
+
+	idle, _ := pool.Get(ctx, "store_a")
+	active, _ := pool.Get(ctx, "store_a")
+	pool.Release(idle)
+	time.Sleep(30 * time.Millisecond)
+
+	pool.Close()
+	if idle.Client.IsConnected() {
+		t.Errorf("Expected idle connections to be closed")
+	}
+	pool.Release(active)
+	if active.Client.IsConnected() {
+		t.Errorf("Expected connections released after close to be closed")
+	}
+	if _, err := pool.Get(ctx, "store_a"); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Expected ErrPoolClosed, got %v", err)
+	}
+	if stats := pool.Stats(); stats.TotalConnections != 0 || stats.Stores != 0 {
+		t.Errorf("Unexpected stats after close: %+v", stats)
+	}
+}
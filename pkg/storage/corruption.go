@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 块损坏的检测与恢复
+// 扫描段文件时校验每条记录的CRC32C与块内消息的校验和，损坏的记录被复制到隔离目录，
+// 所属块从索引中移除，不再读取该块之前的旧记录（见segment.go）。打开时按段索引文件建立索引的
+// 已封存段不做扫描，其中的损坏在读取块时以同样方式发现并标记（见segment_index.go）。
+// Timeline加载时，WAL中仍保留的消息会重建损坏的块并重新写入段文件；WAL已压缩的会话块
+// 由ReplicationManager从副本读取同一SeqID区间的消息，经RestoreCorruptBlock校验后恢复。
+// 仍有未恢复的损坏时，健康检查返回degraded
+
+// CorruptBlock 尚未恢复的损坏块，BlockID为空表示无法确定所属块的损坏记录
+type CorruptBlock struct {
+	CorruptRecord
+	TimelineKey string `json:"timeline_key,omitempty"` // 所属Timeline，格式为 {type}_{id}
+}
+
+// corruptGap 损坏块在Timeline中对应的区间，由按创建顺序相邻的块确定
+type corruptGap struct {
+	AfterSeqID  int64 // 前一个块的最大SeqID，没有时为0
+	BeforeSeqID int64 // 后一个块的最小SeqID，没有时为LastSeqID+1
+	AfterHLC    int64 // 前一个块的最大HLC，没有时为0
+	BeforeHLC   int64 // 后一个块的最小HLC，没有时为0表示不限制
+}
+
+// CorruptBlocks 返回尚未恢复的损坏块
+func (s *Store) CorruptBlocks() []CorruptBlock {
+	records := s.segments.CorruptRecords()
+	blocks := make([]CorruptBlock, 0, len(records))
+	for _, record := range records {
+		block := CorruptBlock{CorruptRecord: record}
+		if record.BlockID != "" {
+			block.TimelineKey = blockTimelineKey(record.BlockID)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
+
+// HealthStatus 健康检查上报的状态，存在未恢复的损坏块或写满后未能落盘的块时为degraded
+func (s *Store) HealthStatus() string {
+	if len(s.segments.CorruptRecords()) > 0 || s.PendingBlockWrites() > 0 {
+		return HealthStatusDegraded
+	}
+	return HealthStatusHealthy
+}
+
+// blockCreatedAt 从块ID中解析创建时间（纳秒），解析失败时返回false
+func blockCreatedAt(blockID string) (int64, bool) {
+	i := strings.LastIndex(blockID, "_")
+	if i < 0 {
+		return 0, false
+	}
+	created, err := strconv.ParseInt(blockID[i+1:], 10, 64)
+	return created, err == nil
+}
+
+// corruptGapLocked 按块的创建顺序计算损坏块的区间，忽略同ID的块（如WAL中恢复的部分消息），调用方持有Timeline锁
+func (tl *Timeline) corruptGapLocked(blockID string) (corruptGap, error) {
+	created, ok := blockCreatedAt(blockID)
+	if !ok {
+		return corruptGap{}, fmt.Errorf("cannot order block %s", blockID)
+	}
+
+	gap := corruptGap{BeforeSeqID: tl.LastSeqID + 1}
+	var prevCreated, nextCreated int64
+	for _, block := range tl.Blocks {
+		if block.BlockID == blockID {
+			continue
+		}
+		at, ok := blockCreatedAt(block.BlockID)
+		if !ok {
+			continue
+		}
+		// 未加载的块按块索引取首尾消息的SeqID与HLC
+		block.mu.RLock()
+		var first, last *Message
+		if block.lazy && block.Index.Count > 0 {
+			first = &Message{SeqID: block.Index.MinSeqID, HLC: block.Index.MinHLC}
+			last = &Message{SeqID: block.Index.MaxSeqID, HLC: block.Index.MaxHLC}
+		} else if n := len(block.Messages); n > 0 {
+			first, last = block.Messages[0], block.Messages[n-1]
+		}
+		block.mu.RUnlock()
+		if first == nil {
+			continue
+		}
+		if at < created && at >= prevCreated {
+			prevCreated = at
+			gap.AfterSeqID, gap.AfterHLC = last.SeqID, last.HLC
+		}
+		if at > created && (nextCreated == 0 || at < nextCreated) {
+			nextCreated = at
+			gap.BeforeSeqID, gap.BeforeHLC = first.SeqID, first.HLC
+		}
+	}
+	if gap.BeforeSeqID <= gap.AfterSeqID+1 {
+		return gap, fmt.Errorf("no sequence gap left for block %s", blockID)
+	}
+	return gap, nil
+}
+
+// validate 检查恢复用的消息是否恰好覆盖区间，SeqID连续且HLC位于相邻块之间
+func (gap corruptGap) validate(messages []*Message) error {
+	if want := gap.BeforeSeqID - gap.AfterSeqID - 1; int64(len(messages)) != want {
+		return fmt.Errorf("got %d messages for sequence gap (%d, %d)", len(messages), gap.AfterSeqID, gap.BeforeSeqID)
+	}
+	for i, msg := range messages {
+		if want := gap.AfterSeqID + 1 + int64(i); msg.SeqID != want {
+			return fmt.Errorf("message %d has SeqID %d, want %d", i, msg.SeqID, want)
+		}
+		if msg.HLC <= gap.AfterHLC || (gap.BeforeHLC > 0 && msg.HLC >= gap.BeforeHLC) {
+			return fmt.Errorf("message %d HLC %d is outside (%d, %d)", msg.SeqID, msg.HLC, gap.AfterHLC, gap.BeforeHLC)
+		}
+	}
+	return nil
+}
+
+// RestoreCorruptBlock 用fetch读取的消息恢复会话Timeline中损坏的块，块未损坏时直接返回
+// fetch返回会话中SeqID位于(afterSeqID, beforeSeqID)区间的消息，必须恰好覆盖损坏块的区间，
+// 否则不写入并返回错误。用户Timeline的记录引用本地会话的SeqID，只能从WAL恢复
+func (s *Store) RestoreCorruptBlock(blockID string, fetch func(convID string, afterSeqID, beforeSeqID int64) ([]*Message, error)) error {
+	if !s.segments.IsCorrupt(blockID) {
+		return nil
+	}
+	convID, ok := strings.CutPrefix(blockTimelineKey(blockID), "conv_")
+	if !ok {
+		return fmt.Errorf("block %s is not a conversation block", blockID)
+	}
+
+	// 加载Timeline时可能已从WAL恢复
+	tl := s.GetOrCreateConvTimeline(convID)
+	if !s.segments.IsCorrupt(blockID) {
+		return nil
+	}
+	tl.mu.RLock()
+	gap, err := tl.corruptGapLocked(blockID)
+	tl.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	messages, err := fetch(convID, gap.AfterSeqID, gap.BeforeSeqID)
+	if err != nil {
+		return err
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].SeqID < messages[j].SeqID })
+	if err := gap.validate(messages); err != nil {
+		return fmt.Errorf("cannot restore block %s: %w", blockID, err)
+	}
+
+	tl.mu.Lock()
+	// 读取副本期间区间发生变化（如块被删除）时放弃本次恢复
+	if current, err := tl.corruptGapLocked(blockID); err != nil || current != gap {
+		tl.mu.Unlock()
+		return fmt.Errorf("timeline %s changed while restoring block %s", convID, blockID)
+	}
+
+	restored := make([]*Message, len(messages))
+	for i, msg := range messages {
+		copied := *msg
+		restored[i] = &copied
+	}
+	block := &TimelineBlock{
+		BlockID:  blockID,
+		StoreID:  s.StoreID,
+		Messages: restored,
+		Size:     int64(len(restored)),
+		IsFull:   true,
+	}
+	if err := s.writeTimelineBlock(block); err != nil {
+		tl.mu.Unlock()
+		return err
+	}
+
+	// 替换WAL中恢复出的部分消息
+	blocks := tl.Blocks[:0]
+	for _, existing := range tl.Blocks {
+		if existing.BlockID != blockID {
+			blocks = append(blocks, existing)
+		}
+	}
+	tl.Blocks = append(blocks, block)
+	tl.sortBlocksLocked()
+	s.rememberClientMessages(tl, restored)
+	tl.rebuildViewLocked()
+	tl.mu.Unlock()
+
+	s.indexMu.Lock()
+	s.TimelineBlocks[blockID] = block
+	s.indexMu.Unlock()
+
+	log.Printf("store %s: restored corrupt block %s with %d messages", s.StoreID, blockID, len(restored))
+	return s.saveTimelineMetadata(tl)
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// writeCorruptedConv 写入5条消息（每块2条）后关闭Store并破坏第一个块，返回重新打开的Store
+func writeCorruptedConv(t *testing.T, dir string, walMaxSize int64) *Store {
+	t.Helper()
+	config := StoreConfig{StoreID: "store_local", MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir, WALSyncPolicy: WALSyncAlways, WALMaxSize: walMaxSize}
+	store, err := NewStore(&config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := store.AddMessage("corrupt", 1, []byte(fmt.Sprintf("message-%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	store.Flush()
+	store.Close()
+
+	flipSegmentByte(t, dir, []byte("message-1"))
+	reopened, err := NewStore(&config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	t.Cleanup(func() { reopened.Close() })
+	return reopened
+}
+
+func assertConvMessages(t *testing.T, store *Store, convID string, want int) {
+	t.Helper()
+	messages, err := store.GetConvMessages(convID, 100, 0)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	if len(messages) != want {
+		t.Fatalf("Expected %d messages, got %d", want, len(messages))
+	}
+	for i, msg := range messages {
+		if msg.SeqID != int64(i+1) || string(msg.Data) != fmt.Sprintf("message-%d", i+1) {
+			t.Errorf("Unexpected message %d: SeqID %d %q", i, msg.SeqID, msg.Data)
+		}
+	}
+}
+
+func TestStoreRecoversCorruptBlockFromWAL(t *testing.T) {
+	store := writeCorruptedConv(t, t.TempDir(), 0)
+
+	if store.HealthStatus() != HealthStatusDegraded {
+		t.Errorf("Expected degraded status before the timeline is loaded, got %s", store.HealthStatus())
+	}
+	// WAL未压缩，加载Timeline时用WAL中的消息重建损坏的块
+	assertConvMessages(t, store, "corrupt", 5)
+	if corrupt := store.CorruptBlocks(); len(corrupt) != 0 {
+		t.Errorf("Expected no corrupt blocks after WAL recovery, got %+v", corrupt)
+	}
+	if store.HealthStatus() != HealthStatusHealthy {
+		t.Errorf("Expected healthy status, got %s", store.HealthStatus())
+	}
+}
+
+func TestStoreRestoresCorruptBlockFromReplica(t *testing.T) {
+	dir := t.TempDir()
+	// WAL在每个块落盘后压缩，损坏的块只能从副本恢复
+	store := writeCorruptedConv(t, dir, 1)
+
+	corrupt := store.CorruptBlocks()
+	if len(corrupt) != 1 || corrupt[0].TimelineKey != "conv_corrupt" {
+		t.Fatalf("Expected one corrupt block of conv_corrupt, got %+v", corrupt)
+	}
+	blockID := corrupt[0].BlockID
+	messages, _ := store.Query(&Query{TimelineID: "corrupt"})
+	if len(messages.Messages) != 3 {
+		t.Fatalf("Expected the 3 intact messages, got %d", len(messages.Messages))
+	}
+
+	// 副本按相同顺序复制了同样的消息
+	replica, err := NewStore(&StoreConfig{StoreID: "store_replica", MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create replica: %v", err)
+	}
+	defer replica.Close()
+	for _, msg := range messages.Messages {
+		if msg.SeqID == 3 {
+			for i := int64(1); i <= 2; i++ {
+				replica.ReplicateMessage("corrupt", &Message{Data: []byte(fmt.Sprintf("message-%d", i)), HLC: msg.HLC - 10 + i, CreateTime: time.Now()}, nil)
+			}
+		}
+		replica.ReplicateMessage("corrupt", msg, nil)
+	}
+	fetch := func(convID string, afterSeqID, beforeSeqID int64) ([]*Message, error) {
+		result, err := replica.Query(&Query{TimelineID: convID, AfterSeqID: afterSeqID, BeforeSeqID: beforeSeqID})
+		if err != nil {
+			return nil, err
+		}
+		return result.Messages, nil
+	}
+
+	// 副本缺少消息时拒绝恢复
+	short := func(convID string, afterSeqID, beforeSeqID int64) ([]*Message, error) {
+		messages, err := fetch(convID, afterSeqID, beforeSeqID)
+		return messages[:1], err
+	}
+	if err := store.RestoreCorruptBlock(blockID, short); err == nil {
+		t.Fatal("Expected restore from an incomplete replica to fail")
+	}
+
+	if err := store.RestoreCorruptBlock(blockID, fetch); err != nil {
+		t.Fatalf("Failed to restore block: %v", err)
+	}
+	assertConvMessages(t, store, "corrupt", 5)
+	if store.HealthStatus() != HealthStatusHealthy {
+		t.Errorf("Expected healthy status after restore, got %s", store.HealthStatus())
+	}
+
+	// 恢复的块已写入段文件，新消息接在之后
+	if err := store.AddMessage("corrupt", 1, []byte("message-6"), nil); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	store.Flush()
+	store.Close()
+	reopened, err := NewStore(&StoreConfig{StoreID: "store_local", MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	assertConvMessages(t, reopened, "corrupt", 6)
+}
+
+func TestReplicationManagerRecoversCorruptBlocks(t *testing.T) {
+	ctx := context.Background()
+	local := writeCorruptedConv(t, t.TempDir(), 1)
+	intact, _ := local.Query(&Query{TimelineID: "corrupt"})
+
+	remote, ts := newTestRemoteStore(t)
+	for i := int64(1); i <= 2; i++ {
+		remote.ReplicateMessage("corrupt", &Message{Data: []byte(fmt.Sprintf("message-%d", i)), HLC: intact.Messages[0].HLC - 10 + i}, nil)
+	}
+	for _, msg := range intact.Messages {
+		remote.ReplicateMessage("corrupt", msg, nil)
+	}
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: local.StoreID})
+	registry.Register(ctx, &StoreInfo{ID: remote.StoreID, Address: ts.URL})
+	router := NewConsistentHashRouter(2, 10, 0.8)
+	router.AddStore(&StoreInfo{ID: local.StoreID, Status: StoreStatusActive})
+	router.AddStore(&StoreInfo{ID: remote.StoreID, Status: StoreStatusActive})
+	pool := NewStoreRPCClientPool(5 * time.Second)
+	defer pool.Close()
+
+	policy := DefaultShardPolicy()
+	policy.ReplicationFactor = 2
+	replication := NewReplicationManager(local, router, registry, nil, pool, policy)
+
+	recovered, err := replication.RecoverCorruptBlocks(ctx)
+	if err != nil || recovered != 1 {
+		t.Fatalf("Expected 1 recovered block, got %d %v", recovered, err)
+	}
+	assertConvMessages(t, local, "corrupt", 5)
+}
+
+func TestScrubReportsCorruptSealedSegment(t *testing.T) {
+	dir := t.TempDir()
+	config := StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir, SegmentMaxSize: 256}
+	store, err := NewStore(&config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 8; i++ {
+		if err := store.AddMessage("scrub", 1, []byte(fmt.Sprintf("message-%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	location, _ := store.segments.Location(store.GetOrCreateConvTimeline("scrub").Blocks[0].BlockID)
+	if location.SegmentID == store.segments.active.id {
+		t.Fatal("Expected the first block in a sealed segment")
+	}
+	store.Close()
+
+	// 已封存段按段索引文件打开，不读取块也能由后台校验发现损坏
+	flipSegmentByte(t, dir, []byte("message-1"))
+	reopened, err := NewStore(&config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for reopened.HealthStatus() != HealthStatusDegraded {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the scrub to report the corrupt block")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	blocks := reopened.CorruptBlocks()
+	if len(blocks) != 1 || blocks[0].TimelineKey != "conv_scrub" || blocks[0].SegmentID != location.SegmentID {
+		t.Errorf("Expected the first block of conv_scrub to be reported, got %+v", blocks)
+	}
+}
@@ -2,7 +2,11 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -17,6 +21,8 @@ type CrossStoreAccessor interface {
 	// 消息操作
 	AddMessage(ctx context.Context, timelineKey string, senderID uint32, data []byte, userIDs []string) error
 	GetMessages(ctx context.Context, timelineKey string, startTime, endTime int64, limit int) ([]*Message, error)
+	SendMessage(ctx context.Context, timelineKey string, senderID uint32, data []byte, userIDs []string) (*ConsistencyToken, error)
+	GetMessagesConsistent(ctx context.Context, timelineKey string, startTime, endTime int64, limit int, token *ConsistencyToken) ([]*Message, error)
 	
 	// Store状态
 	GetStoreStats(ctx context.Context, storeID string) (*StoreStats, error)
@@ -34,6 +40,10 @@ type DistributedStoreAccessor struct {
 	router        TimelineRouter
 	storeRegistry StoreRegistry
 	cacheManager  *CrossStoreCacheManager
+	replication   *ReplicationManager
+	splits        TimelineSplitIndex   // 全局索引支持拆分表时不为nil
+	hotTimelines  *HotTimelineDetector // 为nil时不统计写入速率
+	replicaWait   time.Duration        // 带一致性令牌的读取等待副本追上的最长时间
 	mu            sync.RWMutex
 }
 
@@ -42,37 +52,73 @@ type StoreStats struct {
 	StoreID        string    `json:"store_id"`
 	TimelineCount  int       `json:"timeline_count"`
 	MessageCount   int64     `json:"message_count"`
-	StorageSize    int64     `json:"storage_size"`
+	StorageSize    int64     `json:"storage_size"` // 已用容量（字节）
+	MaxCapacity    int64     `json:"max_capacity"` // 0表示不限制
 	LastHeartbeat  time.Time `json:"last_heartbeat"`
 	Status         string    `json:"status"`
 }
 
 // CrossStoreCacheManager 跨Store缓存管理器
+// 三类缓存都是带TTL的LRU缓存，按估算的内存大小限制容量；
+// 设置全局索引后，每个有缓存条目的Timeline会监听索引变化，块增删或迁移时清除该Timeline的全部缓存
 type CrossStoreCacheManager struct {
 	timelineCache *TimelineCache
 	messageCache  *MessageCache
 	blockCache    *BlockCache
 	mu            sync.RWMutex
+	globalIndex   GlobalIndexManager
+	owners        map[string]string              // 缓存条目 -> TimelineKey
+	entries       map[string]map[string]struct{} // TimelineKey -> 缓存条目
+	watches       map[string]context.CancelFunc  // TimelineKey -> 索引监听
+}
+
+// CrossStoreCacheConfig 跨Store缓存配置，容量单位为字节（估算值）
+type CrossStoreCacheConfig struct {
+	TimelineCacheSize int64         `json:"timeline_cache_size"`
+	MessageCacheSize  int64         `json:"message_cache_size"`
+	BlockCacheSize    int64         `json:"block_cache_size"`
+	TimelineTTL       time.Duration `json:"timeline_ttl"`
+	MessageTTL        time.Duration `json:"message_ttl"`
+	BlockTTL          time.Duration `json:"block_ttl"`
+}
+
+// DefaultCrossStoreCacheConfig 默认跨Store缓存配置
+func DefaultCrossStoreCacheConfig() *CrossStoreCacheConfig {
+	return &CrossStoreCacheConfig{
+		TimelineCacheSize: 16 << 20,
+		MessageCacheSize:  64 << 20,
+		BlockCacheSize:    64 << 20,
+		TimelineTTL:       time.Minute,
+		MessageTTL:        30 * time.Second,
+		BlockTTL:          5 * time.Minute,
+	}
 }
 
 // TimelineCache Timeline缓存
 type TimelineCache struct {
-	cache map[string]*Timeline
-	mu    sync.RWMutex
+	cache *MemoryCache
+	ttl   time.Duration
 }
 
 // MessageCache 消息缓存
 type MessageCache struct {
-	cache map[string][]*Message
-	mu    sync.RWMutex
+	cache *MemoryCache
+	ttl   time.Duration
 }
 
 // BlockCache 块缓存
 type BlockCache struct {
-	cache map[string]*TimelineBlock
-	mu    sync.RWMutex
+	cache *MemoryCache
+	ttl   time.Duration
 }
 
+// 缓存条目前缀，区分三类缓存中相同的键
+const (
+	timelineEntryPrefix = "timeline:"
+	messageEntryPrefix  = "message:"
+	blockEntryPrefix    = "block:"
+)
+
 // NewDistributedStoreAccessor 创建分布式Store访问器
 func NewDistributedStoreAccessor(
 	localStore *Store,
@@ -81,26 +127,59 @@ func NewDistributedStoreAccessor(
 	router TimelineRouter,
 	storeRegistry StoreRegistry,
 ) *DistributedStoreAccessor {
+	splits, _ := globalIndex.(TimelineSplitIndex)
 	return &DistributedStoreAccessor{
 		localStore:    localStore,
 		rpcClientPool: rpcClientPool,
 		globalIndex:   globalIndex,
 		router:        router,
 		storeRegistry: storeRegistry,
-		cacheManager:  NewCrossStoreCacheManager(),
+		cacheManager:  NewCrossStoreCacheManager(globalIndex),
+		splits:        splits,
+		replicaWait:   defaultReplicaCatchUpTimeout,
 	}
 }
 
-// NewCrossStoreCacheManager 创建跨Store缓存管理器
-func NewCrossStoreCacheManager() *CrossStoreCacheManager {
-	return &CrossStoreCacheManager{
-		timelineCache: &TimelineCache{cache: make(map[string]*Timeline)},
-		messageCache:  &MessageCache{cache: make(map[string][]*Message)},
-		blockCache:    &BlockCache{cache: make(map[string]*TimelineBlock)},
+// NewCrossStoreCacheManager 使用默认配置创建跨Store缓存管理器，globalIndex为nil时不监听索引变化
+func NewCrossStoreCacheManager(globalIndex GlobalIndexManager) *CrossStoreCacheManager {
+	return NewCrossStoreCacheManagerWithConfig(globalIndex, DefaultCrossStoreCacheConfig())
+}
+
+// NewCrossStoreCacheManagerWithConfig 创建跨Store缓存管理器
+func NewCrossStoreCacheManagerWithConfig(globalIndex GlobalIndexManager, config *CrossStoreCacheConfig) *CrossStoreCacheManager {
+	if config == nil {
+		config = DefaultCrossStoreCacheConfig()
+	}
+	c := &CrossStoreCacheManager{
+		timelineCache: &TimelineCache{cache: NewMemoryCache(config.TimelineCacheSize), ttl: config.TimelineTTL},
+		messageCache:  &MessageCache{cache: NewMemoryCache(config.MessageCacheSize), ttl: config.MessageTTL},
+		blockCache:    &BlockCache{cache: NewMemoryCache(config.BlockCacheSize), ttl: config.BlockTTL},
+		globalIndex:   globalIndex,
+		owners:        make(map[string]string),
+		entries:       make(map[string]map[string]struct{}),
+		watches:       make(map[string]context.CancelFunc),
 	}
+	c.timelineCache.cache.SetRemoveCallback(func(key string) { c.release(timelineEntryPrefix + key) })
+	c.messageCache.cache.SetRemoveCallback(func(key string) { c.release(messageEntryPrefix + key) })
+	c.blockCache.cache.SetRemoveCallback(func(key string) { c.release(blockEntryPrefix + key) })
+	return c
+}
+
+// SetReplicationManager 设置副本管理器，设置后写入会复制到副本Store
+func (d *DistributedStoreAccessor) SetReplicationManager(replication *ReplicationManager) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replication = replication
+}
+
+// SetHotTimelineDetector 设置热点检测器，之后的写入计入写入速率统计
+func (d *DistributedStoreAccessor) SetHotTimelineDetector(detector *HotTimelineDetector) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.hotTimelines = detector
 }
 
-// GetTimeline 获取Timeline
+// GetTimeline 获取Timeline，拆分后的Timeline只返回第一段
 func (d *DistributedStoreAccessor) GetTimeline(ctx context.Context, timelineKey string) (*Timeline, error) {
 	// 1. 检查缓存
 	if timeline := d.cacheManager.GetTimeline(timelineKey); timeline != nil {
@@ -145,8 +224,21 @@ func (d *DistributedStoreAccessor) GetTimeline(ctx context.Context, timelineKey
 		return nil, fmt.Errorf("timeline not found locally: %s", timelineKey)
 	}
 	
-	// 5. 远程访问
-	timeline, err := d.getRemoteTimeline(ctx, primaryStoreID, timelineKey)
+	// 5. 远程访问，主Store熔断时从副本读取
+	var timeline *Timeline
+	err = d.readWithReplicaFallback(ctx, timelineKey, primaryStoreID, 0, func(storeID string) error {
+		if storeID == d.localStore.StoreID {
+			local, exists := d.localStore.FindTimeline(timelineKey)
+			if !exists {
+				return fmt.Errorf("timeline not found locally: %s", timelineKey)
+			}
+			timeline = local
+			return nil
+		}
+		var err error
+		timeline, err = d.getRemoteTimeline(ctx, storeID, timelineKey)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -166,7 +258,11 @@ func (d *DistributedStoreAccessor) CreateTimeline(ctx context.Context, timelineK
 	if err != nil {
 		return fmt.Errorf("failed to route timeline: %w", err)
 	}
-	
+	return d.createTimelineOn(ctx, targetStoreID, timelineKey, timelineType)
+}
+
+// createTimelineOn 在指定Store上创建Timeline并更新全局索引
+func (d *DistributedStoreAccessor) createTimelineOn(ctx context.Context, targetStoreID, timelineKey, timelineType string) error {
 	// 2. 如果在本地Store
 	if targetStoreID == d.localStore.StoreID {
 		// 根据Timeline类型创建
@@ -185,7 +281,7 @@ func (d *DistributedStoreAccessor) CreateTimeline(ctx context.Context, timelineK
 	}
 	
 	// 3. 远程创建
-	err = d.createRemoteTimeline(ctx, targetStoreID, timelineKey, timelineType)
+	err := d.createRemoteTimeline(ctx, targetStoreID, timelineKey, timelineType)
 	if err != nil {
 		return err
 	}
@@ -239,12 +335,36 @@ func (d *DistributedStoreAccessor) DeleteTimeline(ctx context.Context, timelineK
 	return nil
 }
 
-// AddMessage 添加消息到Timeline
+// AddMessage 添加消息到Timeline，拆分后的Timeline写入最后一段
 func (d *DistributedStoreAccessor) AddMessage(ctx context.Context, timelineKey string, senderID uint32, data []byte, userIDs []string) error {
+	_, err := d.SendMessage(ctx, timelineKey, senderID, data, userIDs)
+	return err
+}
+
+// SendMessage 添加消息并返回一致性令牌，读取时传入令牌可以读到这条消息
+func (d *DistributedStoreAccessor) SendMessage(ctx context.Context, timelineKey string, senderID uint32, data []byte, userIDs []string) (*ConsistencyToken, error) {
+	d.mu.RLock()
+	hotTimelines := d.hotTimelines
+	d.mu.RUnlock()
+	if hotTimelines != nil {
+		hotTimelines.Observe(timelineKey, len(data))
+	}
+	
+	token := &ConsistencyToken{TimelineKey: timelineKey}
+	segments, err := d.timelineSegments(ctx, timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		// 合并读取的结果缓存在原Timeline下
+		defer d.cacheManager.InvalidateMessages(timelineKey)
+		timelineKey = segments[len(segments)-1].TimelineKey
+	}
+	
 	// 1. 查找Timeline位置
 	location, err := d.globalIndex.GetTimelineLocation(ctx, timelineKey)
 	if err != nil {
-		return fmt.Errorf("failed to get timeline location: %w", err)
+		return nil, fmt.Errorf("failed to get timeline location: %w", err)
 	}
 	
 	// 2. 确定主Store（从第一个Block获取）
@@ -252,27 +372,96 @@ func (d *DistributedStoreAccessor) AddMessage(ctx context.Context, timelineKey s
 	if len(location.Blocks) > 0 {
 		primaryStoreID = location.Blocks[0].StoreID
 	} else {
-		return fmt.Errorf("timeline has no blocks")
+		return nil, fmt.Errorf("timeline has no blocks")
+	}
+	
+	// 主Store不健康时先提升副本
+	d.mu.RLock()
+	replication := d.replication
+	d.mu.RUnlock()
+	if replication != nil {
+		primaryStoreID, err = replication.EnsurePrimary(ctx, timelineKey, primaryStoreID)
+		if err != nil {
+			return nil, err
+		}
 	}
 	
 	// 3. 如果在本地Store
+	message := &Message{
+		ConvID:     timelineKey,
+		SenderID:   senderID,
+		CreateTime: time.Now(),
+		Data:       data,
+	}
 	if primaryStoreID == d.localStore.StoreID {
-		return d.localStore.AddMessage(timelineKey, senderID, data, userIDs)
+		written, err := d.localStore.AppendMessage(timelineKey, senderID, data, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		message.CreateTime = written.CreateTime
+		message.HLC = written.HLC
+		token.SeqID = written.SeqID
+		d.cacheManager.InvalidateMessages(timelineKey)
+	} else {
+		// 4. 远程添加
+		resp, err := d.addRemoteMessage(ctx, primaryStoreID, timelineKey, message, userIDs)
+		if err != nil {
+			return nil, err
+		}
+		message.HLC = resp.HLC
+		token.SeqID = resp.SeqID
+	}
+	token.HLC = message.HLC
+	
+	// 5. 复制到副本Store，副本沿用主Store分配的HLC
+	if replication != nil {
+		if err := replication.Replicate(ctx, primaryStoreID, timelineKey, message, userIDs); err != nil {
+			return nil, err
+		}
 	}
 	
-	// 4. 远程添加
-	return d.addRemoteMessage(ctx, primaryStoreID, timelineKey, senderID, data, userIDs)
+	return token, nil
 }
 
-// GetMessages 获取消息列表
+// GetMessages 获取消息列表，拆分后的Timeline合并各段中时间范围内的消息
 func (d *DistributedStoreAccessor) GetMessages(ctx context.Context, timelineKey string, startTime, endTime int64, limit int) ([]*Message, error) {
+	return d.getMessages(ctx, timelineKey, startTime, endTime, limit, 0)
+}
+
+// getMessages 读取消息，minHLC大于0时只使用包含该HLC写入的缓存和副本
+func (d *DistributedStoreAccessor) getMessages(ctx context.Context, timelineKey string, startTime, endTime int64, limit int, minHLC int64) ([]*Message, error) {
 	// 1. 检查缓存
 	cacheKey := fmt.Sprintf("%s:%d:%d:%d", timelineKey, startTime, endTime, limit)
-	if messages := d.cacheManager.GetMessages(cacheKey); messages != nil {
+	if messages := d.cacheManager.GetMessages(cacheKey); messages != nil && cachedMessagesFresh(messages, minHLC) {
 		return messages, nil
 	}
 	
-	// 2. 查找Timeline位置
+	// 2. 读取消息，拆分后的Timeline合并各段
+	segments, err := d.timelineSegments(ctx, timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	var messages []*Message
+	if len(segments) > 0 {
+		messages, err = d.getSplitMessages(ctx, timelineKey, segments, startTime, endTime, limit, minHLC)
+	} else {
+		messages, err = d.getTimelineMessages(ctx, timelineKey, startTime, endTime, limit, minHLC)
+	}
+	if err != nil {
+		return nil, err
+	}
+	
+	// 3. 缓存结果
+	if messages != nil {
+		d.cacheManager.SetMessages(timelineKey, cacheKey, messages)
+	}
+	
+	return messages, nil
+}
+
+// getTimelineMessages 从Timeline所在的Store读取消息
+func (d *DistributedStoreAccessor) getTimelineMessages(ctx context.Context, timelineKey string, startTime, endTime int64, limit int, minHLC int64) ([]*Message, error) {
+	// 1. 查找Timeline位置
 	location, err := d.globalIndex.GetTimelineLocation(ctx, timelineKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get timeline location: %w", err)
@@ -280,7 +469,7 @@ func (d *DistributedStoreAccessor) GetMessages(ctx context.Context, timelineKey
 	
 	var messages []*Message
 	
-	// 3. 确定主Store（从第一个Block获取）
+	// 2. 确定主Store（从第一个Block获取）
 	var primaryStoreID string
 	if len(location.Blocks) > 0 {
 		primaryStoreID = location.Blocks[0].StoreID
@@ -288,41 +477,74 @@ func (d *DistributedStoreAccessor) GetMessages(ctx context.Context, timelineKey
 		return nil, fmt.Errorf("timeline has no blocks")
 	}
 	
-	// 4. 如果在本地Store
+	// 3. 如果在本地Store
 	if primaryStoreID == d.localStore.StoreID {
 		// 由于Store没有GetMessages方法，这里需要通过Timeline获取
 		timeline, err := d.GetTimeline(ctx, timelineKey)
 		if err != nil {
 			return nil, err
 		}
-		// 从Timeline的blocks中获取消息
-		for _, block := range timeline.Blocks {
-			for _, msg := range block.Messages {
-				msgTime := msg.CreateTime.Unix()
-				if msgTime >= startTime && msgTime <= endTime {
-					messages = append(messages, msg)
-					if len(messages) >= limit {
-						break
-					}
-				}
-			}
-			if len(messages) >= limit {
-				break
-			}
+		messages, err = d.localStore.messagesInRange(timeline, startTime, endTime, limit)
+		if err != nil {
+			return nil, err
 		}
 	} else {
-		// 5. 远程获取
-		messages, err = d.getRemoteMessages(ctx, primaryStoreID, timelineKey, startTime, endTime, limit)
+		// 4. 远程获取，主Store熔断时从副本读取
+		err = d.readWithReplicaFallback(ctx, timelineKey, primaryStoreID, minHLC, func(storeID string) error {
+			if storeID == d.localStore.StoreID {
+				timeline, exists := d.localStore.FindTimeline(timelineKey)
+				if !exists {
+					return fmt.Errorf("timeline not found locally: %s", timelineKey)
+				}
+				var err error
+				messages, err = d.localStore.messagesInRange(timeline, startTime, endTime, limit)
+				return err
+			}
+			var err error
+			messages, err = d.getRemoteMessages(ctx, storeID, timelineKey, startTime, endTime, limit)
+			return err
+		})
 		if err != nil {
 			return nil, err
 		}
 	}
 	
-	// 6. 缓存结果
-	if messages != nil {
-		d.cacheManager.SetMessages(cacheKey, messages)
+	return messages, nil
+}
+
+// timelineSegments 获取Timeline的拆分表，未拆分或全局索引不支持拆分时返回nil
+func (d *DistributedStoreAccessor) timelineSegments(ctx context.Context, timelineKey string) ([]*TimelineSegment, error) {
+	if d.splits == nil {
+		return nil, nil
+	}
+	segments, err := d.splits.GetTimelineSplits(ctx, timelineKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline splits: %w", err)
+	}
+	return segments, nil
+}
+
+// getSplitMessages 读取与时间范围重叠的各段，按创建时间合并后最多返回limit条
+func (d *DistributedStoreAccessor) getSplitMessages(ctx context.Context, timelineKey string, segments []*TimelineSegment, startTime, endTime int64, limit int, minHLC int64) ([]*Message, error) {
+	var messages []*Message
+	for _, segment := range segmentsInRange(segments, startTime, endTime) {
+		segmentMessages, err := d.getTimelineMessages(ctx, segment.TimelineKey, startTime, endTime, limit, minHLC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segment %s: %w", segment.TimelineKey, err)
+		}
+		for _, msg := range segmentMessages {
+			copied := *msg
+			copied.ConvID = timelineKey
+			messages = append(messages, &copied)
+		}
 	}
 	
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].CreateTime.Before(messages[j].CreateTime)
+	})
+	if len(messages) > limit {
+		messages = messages[:limit]
+	}
 	return messages, nil
 }
 
@@ -330,12 +552,14 @@ func (d *DistributedStoreAccessor) GetMessages(ctx context.Context, timelineKey
 func (d *DistributedStoreAccessor) GetStoreStats(ctx context.Context, storeID string) (*StoreStats, error) {
 	if storeID == d.localStore.StoreID {
 		// 本地Store统计
+		capacity := d.localStore.Capacity()
 		return &StoreStats{
 			StoreID:       d.localStore.StoreID,
-			TimelineCount: len(d.localStore.ConvTimelines) + len(d.localStore.UserTimelines),
-			StorageSize:   0, // 需要计算实际存储大小
+			TimelineCount: len(d.localStore.ListTimelines()),
+			StorageSize:   capacity.UsedBytes,
+			MaxCapacity:   capacity.MaxCapacity,
 			LastHeartbeat: time.Now(),
-			Status:        "healthy",
+			Status:        HealthStatusHealthy,
 		}, nil
 	}
 	
@@ -397,71 +621,393 @@ func (d *DistributedStoreAccessor) MigrateTimeline(ctx context.Context, timeline
 }
 
 // 缓存管理方法
+
+// GetTimeline 获取缓存的Timeline
 func (c *CrossStoreCacheManager) GetTimeline(key string) *Timeline {
-	c.timelineCache.mu.RLock()
-	defer c.timelineCache.mu.RUnlock()
-	return c.timelineCache.cache[key]
+	if value, ok := c.timelineCache.cache.Get(key); ok {
+		return value.(*Timeline)
+	}
+	return nil
 }
 
+// SetTimeline 缓存Timeline
 func (c *CrossStoreCacheManager) SetTimeline(key string, timeline *Timeline) {
-	c.timelineCache.mu.Lock()
-	defer c.timelineCache.mu.Unlock()
-	c.timelineCache.cache[key] = timeline
+	c.track(key, timelineEntryPrefix+key)
+	c.timelineCache.cache.Set(key, timeline, c.timelineCache.ttl)
 }
 
+// RemoveTimeline 清除Timeline的全部缓存（Timeline、消息和块）
 func (c *CrossStoreCacheManager) RemoveTimeline(key string) {
-	c.timelineCache.mu.Lock()
-	defer c.timelineCache.mu.Unlock()
-	delete(c.timelineCache.cache, key)
+	c.Invalidate(key)
 }
 
+// GetMessages 获取缓存的消息列表
 func (c *CrossStoreCacheManager) GetMessages(key string) []*Message {
-	c.messageCache.mu.RLock()
-	defer c.messageCache.mu.RUnlock()
-	return c.messageCache.cache[key]
+	if value, ok := c.messageCache.cache.Get(key); ok {
+		return value.([]*Message)
+	}
+	return nil
+}
+
+// SetMessages 缓存timelineKey的一次查询结果
+func (c *CrossStoreCacheManager) SetMessages(timelineKey, key string, messages []*Message) {
+	c.track(timelineKey, messageEntryPrefix+key)
+	c.messageCache.cache.Set(key, messages, c.messageCache.ttl)
+}
+
+// InvalidateMessages 清除指定Timeline的所有消息缓存
+func (c *CrossStoreCacheManager) InvalidateMessages(timelineKey string) {
+	for _, entry := range c.ownedEntries(timelineKey) {
+		if key, ok := strings.CutPrefix(entry, messageEntryPrefix); ok {
+			c.messageCache.cache.Delete(key)
+		}
+	}
+}
+
+// GetBlock 获取缓存的块
+func (c *CrossStoreCacheManager) GetBlock(blockID string) *TimelineBlock {
+	if value, ok := c.blockCache.cache.Get(blockID); ok {
+		return value.(*TimelineBlock)
+	}
+	return nil
+}
+
+// SetBlock 缓存timelineKey的块
+func (c *CrossStoreCacheManager) SetBlock(timelineKey string, block *TimelineBlock) {
+	c.track(timelineKey, blockEntryPrefix+block.BlockID)
+	c.blockCache.cache.Set(block.BlockID, block, c.blockCache.ttl)
+}
+
+// Invalidate 清除Timeline的全部缓存并停止监听其索引
+func (c *CrossStoreCacheManager) Invalidate(timelineKey string) {
+	for _, entry := range c.ownedEntries(timelineKey) {
+		switch {
+		case strings.HasPrefix(entry, timelineEntryPrefix):
+			c.timelineCache.cache.Delete(strings.TrimPrefix(entry, timelineEntryPrefix))
+		case strings.HasPrefix(entry, messageEntryPrefix):
+			c.messageCache.cache.Delete(strings.TrimPrefix(entry, messageEntryPrefix))
+		case strings.HasPrefix(entry, blockEntryPrefix):
+			c.blockCache.cache.Delete(strings.TrimPrefix(entry, blockEntryPrefix))
+		}
+	}
+}
+
+// Close 停止所有索引监听
+func (c *CrossStoreCacheManager) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for timelineKey, cancel := range c.watches {
+		cancel()
+		delete(c.watches, timelineKey)
+	}
 }
 
-func (c *CrossStoreCacheManager) SetMessages(key string, messages []*Message) {
-	c.messageCache.mu.Lock()
-	defer c.messageCache.mu.Unlock()
-	c.messageCache.cache[key] = messages
+// ownedEntries 复制Timeline当前的缓存条目，删除条目时不能持有c.mu（移除回调会获取它）
+func (c *CrossStoreCacheManager) ownedEntries(timelineKey string) []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	result := make([]string, 0, len(c.entries[timelineKey]))
+	for entry := range c.entries[timelineKey] {
+		result = append(result, entry)
+	}
+	return result
+}
+
+// track 记录缓存条目所属的Timeline，Timeline的第一个条目开始监听其索引
+// 需在写入缓存前调用，保证条目被淘汰时一定能找到所属Timeline
+func (c *CrossStoreCacheManager) track(timelineKey, entry string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	if owner, exists := c.owners[entry]; exists && owner != timelineKey {
+		delete(c.entries[owner], entry)
+	}
+	c.owners[entry] = timelineKey
+	if c.entries[timelineKey] == nil {
+		c.entries[timelineKey] = make(map[string]struct{})
+	}
+	c.entries[timelineKey][entry] = struct{}{}
+	
+	if c.globalIndex == nil {
+		return
+	}
+	if _, watching := c.watches[timelineKey]; watching {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.watches[timelineKey] = cancel
+	go c.watchIndex(ctx, timelineKey)
+}
+
+// release 缓存条目被移除时调用，Timeline没有缓存条目后停止监听
+func (c *CrossStoreCacheManager) release(entry string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	
+	timelineKey, exists := c.owners[entry]
+	if !exists {
+		return
+	}
+	delete(c.owners, entry)
+	delete(c.entries[timelineKey], entry)
+	if len(c.entries[timelineKey]) > 0 {
+		return
+	}
+	delete(c.entries, timelineKey)
+	if cancel, watching := c.watches[timelineKey]; watching {
+		cancel()
+		delete(c.watches, timelineKey)
+	}
+}
+
+// watchIndex 监听Timeline的索引变化，有变化时清除其缓存
+func (c *CrossStoreCacheManager) watchIndex(ctx context.Context, timelineKey string) {
+	events, err := c.globalIndex.Watch(ctx, timelineKey)
+	if err != nil {
+		log.Printf("failed to watch index of %s, cache relies on TTL: %v", timelineKey, err)
+		return
+	}
+	
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+			c.Invalidate(timelineKey)
+		}
+	}
+}
+
+// 远程访问辅助方法
+
+// getRemoteClient 通过StoreRegistry查找Store地址并从连接池获取RPC客户端
+func (d *DistributedStoreAccessor) getRemoteClient(ctx context.Context, storeID string) (StoreRPCClient, error) {
+	if d.rpcClientPool == nil || d.storeRegistry == nil {
+		return nil, fmt.Errorf("remote access is not configured")
+	}
+	
+	info, err := d.storeRegistry.GetStore(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup store %s: %w", storeID, err)
+	}
+	
+	return d.rpcClientPool.GetClient(ctx, storeID, info.Address)
+}
+
+// readWithReplicaFallback 从主Store读取，主Store的熔断器打开时依次尝试副本Store
+// minHLC大于0时只从已复制到该HLC的副本读取，副本都落后时等待追上，最多等待replicaCatchUpTimeout。
+// 副本都读取失败时返回主Store的错误，副本落后时返回ErrReplicaBehind
+func (d *DistributedStoreAccessor) readWithReplicaFallback(ctx context.Context, timelineKey, primaryStoreID string, minHLC int64, read func(storeID string) error) error {
+	err := read(primaryStoreID)
+	if err == nil || !errors.Is(err, ErrCircuitBreakerOpen) {
+		return err
+	}
+	
+	d.mu.RLock()
+	replication := d.replication
+	catchUpTimeout := d.replicaWait
+	d.mu.RUnlock()
+	if replication == nil {
+		return err
+	}
+	replicas, replicaErr := replication.replicaTargets(timelineKey, primaryStoreID)
+	if replicaErr != nil {
+		return err
+	}
+	
+	deadline := time.Now().Add(catchUpTimeout)
+	for {
+		behind := false
+		for _, storeID := range replicas {
+			if minHLC > 0 && !replication.replicaCaughtUp(timelineKey, storeID, minHLC) {
+				behind = true
+				continue
+			}
+			if readErr := read(storeID); readErr == nil {
+				log.Printf("store %s circuit open, served %s from replica %s", primaryStoreID, timelineKey, storeID)
+				return nil
+			}
+		}
+		if !behind {
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			return fmt.Errorf("%w: %s on store %s: %v", ErrReplicaBehind, timelineKey, primaryStoreID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(replicaCatchUpPollInterval):
+		}
+	}
+}
+
+// messagesInRange 按创建时间（秒）过滤Timeline中的消息，最多返回limit条
+func (s *Store) messagesInRange(timeline *Timeline, startTime, endTime int64, limit int) ([]*Message, error) {
+	var messages []*Message
+	for msg, err := range timeline.readView().forward(s.readBlockMessages, 0) {
+		if err != nil {
+			return nil, err
+		}
+		msgTime := msg.CreateTime.Unix()
+		if msgTime >= startTime && msgTime <= endTime {
+			messages = append(messages, msg)
+			if len(messages) >= limit {
+				break
+			}
+		}
+	}
+	return messages, nil
+}
+
+// handleRemoteError 远程调用失败时移除连接，下次调用重新建立
+func (d *DistributedStoreAccessor) handleRemoteError(storeID string, err error) {
+	if err != nil {
+		d.rpcClientPool.RemoveClient(storeID)
+	}
 }
 
-// 远程访问辅助方法（简化实现）
 func (d *DistributedStoreAccessor) getRemoteTimeline(ctx context.Context, storeID, timelineKey string) (*Timeline, error) {
-	// 这里需要实现RPC调用逻辑
-	// 暂时返回错误，实际实现需要根据RPC接口定义
-	return nil, fmt.Errorf("remote timeline access not implemented")
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	
+	resp, err := client.GetTimeline(ctx, &GetTimelineRequest{TimelineKey: timelineKey})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline from store %s: %w", storeID, err)
+	}
+	
+	if !resp.Exists || resp.Timeline == nil {
+		return nil, fmt.Errorf("timeline not found on store %s: %s", storeID, timelineKey)
+	}
+	
+	return resp.Timeline, nil
 }
 
 func (d *DistributedStoreAccessor) createRemoteTimeline(ctx context.Context, storeID, timelineKey, timelineType string) error {
-	// 这里需要实现RPC调用逻辑
-	return fmt.Errorf("remote timeline creation not implemented")
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	
+	_, err = client.CreateTimeline(ctx, &CreateTimelineRequest{
+		TimelineKey: timelineKey,
+		Metadata:    map[string]interface{}{"type": timelineType},
+	})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return fmt.Errorf("failed to create timeline on store %s: %w", storeID, err)
+	}
+	
+	return nil
 }
 
 func (d *DistributedStoreAccessor) deleteRemoteTimeline(ctx context.Context, storeID, timelineKey string) error {
-	// 这里需要实现RPC调用逻辑
-	return fmt.Errorf("remote timeline deletion not implemented")
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	
+	resp, err := client.DeleteTimeline(ctx, &DeleteTimelineRequest{TimelineKey: timelineKey})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return fmt.Errorf("failed to delete timeline on store %s: %w", storeID, err)
+	}
+	
+	if !resp.Deleted {
+		return fmt.Errorf("timeline not found on store %s: %s", storeID, timelineKey)
+	}
+	
+	return nil
 }
 
-func (d *DistributedStoreAccessor) addRemoteMessage(ctx context.Context, storeID, timelineKey string, senderID uint32, data []byte, userIDs []string) error {
-	// 这里需要实现RPC调用逻辑
-	return fmt.Errorf("remote message addition not implemented")
+// addRemoteMessage 在远程Store写入消息，返回远程Store分配的SeqID和HLC
+func (d *DistributedStoreAccessor) addRemoteMessage(ctx context.Context, storeID, timelineKey string, message *Message, userIDs []string) (*AddMessageResponse, error) {
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	
+	resp, err := client.AddMessage(ctx, &AddMessageRequest{
+		TimelineKey: timelineKey,
+		Message:     message,
+		UserIDs:     userIDs,
+	})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to add message on store %s: %w", storeID, err)
+	}
+	
+	// 远程写入后，本地缓存的消息列表已过期
+	d.cacheManager.InvalidateMessages(timelineKey)
+	
+	return resp, nil
 }
 
 func (d *DistributedStoreAccessor) getRemoteMessages(ctx context.Context, storeID, timelineKey string, startTime, endTime int64, limit int) ([]*Message, error) {
-	// 这里需要实现RPC调用逻辑
-	return nil, fmt.Errorf("remote message retrieval not implemented")
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	
+	resp, err := client.GetMessages(ctx, &GetMessagesRequest{
+		TimelineKey: timelineKey,
+		StartTime:   startTime,
+		EndTime:     endTime,
+		Limit:       limit,
+	})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get messages from store %s: %w", storeID, err)
+	}
+	
+	return resp.Messages, nil
 }
 
 func (d *DistributedStoreAccessor) getRemoteStoreStats(ctx context.Context, storeID string) (*StoreStats, error) {
-	// 这里需要实现RPC调用逻辑
-	return nil, fmt.Errorf("remote store stats not implemented")
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	
+	resp, err := client.GetStoreStats(ctx, &GetStoreStatsRequest{})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stats from store %s: %w", storeID, err)
+	}
+	
+	return &StoreStats{
+		StoreID:       resp.StoreID,
+		TimelineCount: resp.TimelineCount,
+		StorageSize:   resp.TotalSize,
+		MaxCapacity:   resp.MaxCapacity,
+		LastHeartbeat: time.Unix(resp.LastUpdate, 0),
+		Status:        HealthStatusHealthy,
+	}, nil
 }
 
 func (d *DistributedStoreAccessor) remoteHealthCheck(ctx context.Context, storeID string) error {
-	// 这里需要实现RPC调用逻辑
-	return fmt.Errorf("remote health check not implemented")
+	client, err := d.getRemoteClient(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	
+	resp, err := client.HealthCheck(ctx, &HealthCheckRequest{Ping: "ping"})
+	d.handleRemoteError(storeID, err)
+	if err != nil {
+		return fmt.Errorf("health check failed for store %s: %w", storeID, err)
+	}
+	
+	if !healthStatusServing(resp.Status) {
+		return fmt.Errorf("store %s is %s", storeID, resp.Status)
+	}
+	
+	return nil
 }
 
 func (d *DistributedStoreAccessor) executeMigration(ctx context.Context, timelineKey, sourceStoreID, targetStoreID string) error {
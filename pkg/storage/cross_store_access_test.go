@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestRemoteStore 启动一个通过HTTP RPC暴露的远程Store
+func newTestRemoteStore(t *testing.T) (*Store, *httptest.Server) {
+	t.Helper()
+
+	remote, err := NewStore(&StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 10,
+		DataDir:         t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create remote store: %v", err)
+	}
+
+	rpcServer := NewHTTPStoreRPCServer(remote)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rpc", rpcServer.handleRPC)
+	ts := httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return remote, ts
+}
+
+func TestDistributedStoreAccessorRemoteOperations(t *testing.T) {
+	ctx := context.Background()
+
+	local, err := NewStore(&StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 10,
+		DataDir:         t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+
+	remote, ts := newTestRemoteStore(t)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	if err := registry.Register(ctx, &StoreInfo{ID: remote.StoreID, Address: ts.URL}); err != nil {
+		t.Fatalf("Failed to register remote store: %v", err)
+	}
+
+	globalIndex := NewInMemoryGlobalIndex()
+	timelineKey := "conv_remote"
+	if err := globalIndex.AddIndex(ctx, &GlobalStoreIndex{
+		TimelineKey: timelineKey,
+		StoreID:     remote.StoreID,
+		BlockID:     "block_1",
+	}); err != nil {
+		t.Fatalf("Failed to add index: %v", err)
+	}
+
+	pool := NewStoreRPCClientPool(5 * time.Second)
+	defer pool.Close()
+
+	accessor := NewDistributedStoreAccessor(local, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+
+	if err := accessor.HealthCheck(ctx, remote.StoreID); err != nil {
+		t.Fatalf("Remote health check failed: %v", err)
+	}
+
+	if err := accessor.AddMessage(ctx, timelineKey, 1, []byte("hello"), []string{"user_a"}); err != nil {
+		t.Fatalf("Failed to add remote message: %v", err)
+	}
+
+	if msgs, _ := remote.GetConvMessages(timelineKey, 10, 0); len(msgs) != 1 {
+		t.Fatalf("Expected 1 message on remote store, got %d", len(msgs))
+	}
+	if msgs, _ := remote.GetMessagesAfterCheckpoint("user_a"); len(msgs) != 1 {
+		t.Errorf("Expected remote user timeline to receive the message, got %d", len(msgs))
+	}
+
+	messages, err := accessor.GetMessages(ctx, timelineKey, 0, time.Now().Unix()+1, 10)
+	if err != nil {
+		t.Fatalf("Failed to get remote messages: %v", err)
+	}
+	if len(messages) != 1 || string(messages[0].Data) != "hello" {
+		t.Fatalf("Unexpected remote messages: %+v", messages)
+	}
+
+	timeline, err := accessor.GetTimeline(ctx, timelineKey)
+	if err != nil {
+		t.Fatalf("Failed to get remote timeline: %v", err)
+	}
+	if timeline.ID != timelineKey {
+		t.Errorf("Expected timeline %s, got %s", timelineKey, timeline.ID)
+	}
+
+	stats, err := accessor.GetStoreStats(ctx, remote.StoreID)
+	if err != nil {
+		t.Fatalf("Failed to get remote stats: %v", err)
+	}
+	if stats.StoreID != remote.StoreID {
+		t.Errorf("Expected stats for %s, got %s", remote.StoreID, stats.StoreID)
+	}
+}
+
+func TestDistributedStoreAccessorUnknownStore(t *testing.T) {
+	local, err := NewStore(&StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 10,
+		DataDir:         t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+
+	accessor := NewDistributedStoreAccessor(local, NewStoreRPCClientPool(time.Second), NewInMemoryGlobalIndex(), NewConsistentHashRouter(1, 10, 0.8), registry)
+	if err := accessor.HealthCheck(context.Background(), "store_missing"); err == nil {
+		t.Fatal("Expected health check against unregistered store to fail")
+	}
+}
+
+func TestGetMessagesSeqIDCursor(t *testing.T) {
+	ctx := context.Background()
+	remote, ts := newTestRemoteStore(t)
+
+	timelineKey := "conv_cursor"
+	tl := remote.GetOrCreateConvTimeline(timelineKey)
+	for i := 1; i <= 7; i++ {
+		msg := &Message{SeqID: int64(i), ConvID: timelineKey, SenderID: 1, CreateTime: time.Now()}
+		if err := tl.AddMessage(msg, remote); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	collect := func(req GetMessagesRequest, backward bool) []int64 {
+		var seqs []int64
+		for {
+			resp, err := client.GetMessages(ctx, &req)
+			if err != nil {
+				t.Fatalf("Failed to get messages: %v", err)
+			}
+			for _, msg := range resp.Messages {
+				seqs = append(seqs, msg.SeqID)
+			}
+			if !resp.HasMore {
+				if resp.NextCursor != 0 {
+					t.Errorf("Expected zero cursor on last page, got %d", resp.NextCursor)
+				}
+				return seqs
+			}
+			if backward {
+				req.BeforeSeqID = resp.NextCursor
+			} else {
+				req.AfterSeqID = resp.NextCursor
+			}
+		}
+	}
+
+	// 向前翻页，每页按SeqID升序
+	backward := collect(GetMessagesRequest{TimelineKey: timelineKey, Limit: 3, BeforeSeqID: 100}, true)
+	if want := []int64{5, 6, 7, 2, 3, 4, 1}; !equalSeqIDs(backward, want) {
+		t.Errorf("Backward paging: expected %v, got %v", want, backward)
+	}
+
+	// 向后翻页
+	forward := collect(GetMessagesRequest{TimelineKey: timelineKey, Limit: 3, AfterSeqID: 2}, false)
+	if want := []int64{3, 4, 5, 6, 7}; !equalSeqIDs(forward, want) {
+		t.Errorf("Forward paging: expected %v, got %v", want, forward)
+	}
+}
+
+func equalSeqIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCrossStoreCacheEvictionAndTTL(t *testing.T) {
+	cache := NewCrossStoreCacheManagerWithConfig(nil, &CrossStoreCacheConfig{
+		TimelineCacheSize: 1 << 20,
+		MessageCacheSize:  300,
+		BlockCacheSize:    1 << 20,
+		TimelineTTL:       20 * time.Millisecond,
+		MessageTTL:        time.Minute,
+		BlockTTL:          time.Minute,
+	})
+
+	payload := make([]byte, 100)
+	for i := 0; i < 5; i++ {
+		cache.SetMessages("conv_lru", fmt.Sprintf("conv_lru:%d", i), []*Message{{SeqID: int64(i), Data: payload}})
+	}
+	if cache.GetMessages("conv_lru:0") != nil {
+		t.Error("Expected the oldest message entry to be evicted")
+	}
+	if cache.GetMessages("conv_lru:4") == nil {
+		t.Error("Expected the newest message entry to be cached")
+	}
+	if size := cache.messageCache.cache.Size(); size > 300 {
+		t.Errorf("Message cache exceeds its limit: %d", size)
+	}
+
+	cache.SetTimeline("conv_ttl", &Timeline{ID: "conv_ttl"})
+	if cache.GetTimeline("conv_ttl") == nil {
+		t.Fatal("Expected timeline to be cached")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if cache.GetTimeline("conv_ttl") != nil {
+		t.Error("Expected timeline to expire")
+	}
+
+	// 所有条目被移除后不再记录归属
+	cache.InvalidateMessages("conv_lru")
+	cache.mu.RLock()
+	defer cache.mu.RUnlock()
+	if len(cache.owners) != 0 || len(cache.entries) != 0 {
+		t.Errorf("Expected ownership to be released, got %d owners %d timelines", len(cache.owners), len(cache.entries))
+	}
+}
+
+func TestCrossStoreCacheInvalidatedByIndexEvents(t *testing.T) {
+	ctx := context.Background()
+	globalIndex := NewInMemoryGlobalIndex()
+	cache := NewCrossStoreCacheManager(globalIndex)
+	defer cache.Close()
+
+	timelineKey := "conv_watch"
+	cache.SetTimeline(timelineKey, &Timeline{ID: timelineKey})
+	cache.SetMessages(timelineKey, timelineKey+":0:0:10", []*Message{{SeqID: 1}})
+	cache.SetBlock(timelineKey, &TimelineBlock{BlockID: "conv_conv_watch_1"})
+	cache.SetTimeline("conv_other", &Timeline{ID: "conv_other"})
+
+	// 等待监听建立
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		globalIndex.mu.RLock()
+		watching := len(globalIndex.watchers[timelineKey]) > 0
+		globalIndex.mu.RUnlock()
+		if watching {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: "store-b", BlockID: "conv_conv_watch_2"})
+
+	for time.Now().Before(deadline) && cache.GetTimeline(timelineKey) != nil {
+		time.Sleep(time.Millisecond)
+	}
+	if cache.GetTimeline(timelineKey) != nil || cache.GetMessages(timelineKey+":0:0:10") != nil || cache.GetBlock("conv_conv_watch_1") != nil {
+		t.Error("Expected all cache entries of the timeline to be invalidated")
+	}
+	if cache.GetTimeline("conv_other") == nil {
+		t.Error("Unrelated timeline should stay cached")
+	}
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	// defaultDedupTTL clientMsgID的去重窗口，客户端重试通常在该时间内完成
+	defaultDedupTTL = 10 * time.Minute
+	// defaultDedupMaxEntries 每个会话保留的去重记录上限
+	defaultDedupMaxEntries = 1024
+)
+
+// dedupIndex 会话内clientMsgID到已写入消息的索引，按写入顺序淘汰，
+// 超过TTL或条数上限的记录被移除。由所属Timeline的锁保护
+type dedupIndex struct {
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*Message
+	order      []string // 按写入顺序排列的clientMsgID，与entries一一对应
+
+	// 元数据后端为kv时记录上次保存之后新增与淘汰的clientMsgID，随会话元数据写入，见metadata_backend.go
+	track   bool
+	added   []string
+	evicted []string
+}
+
+func newDedupIndex(ttl time.Duration, maxEntries int) *dedupIndex {
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultDedupMaxEntries
+	}
+	return &dedupIndex{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*Message),
+	}
+}
+
+// lookup 返回去重窗口内clientMsgID对应的消息，索引未创建或clientMsgID为空时返回nil
+func (d *dedupIndex) lookup(clientMsgID string, now time.Time) *Message {
+	if d == nil || clientMsgID == "" {
+		return nil
+	}
+	d.expire(now)
+	return d.entries[clientMsgID]
+}
+
+// remember 记录已写入的消息，已过期的消息不记录
+func (d *dedupIndex) remember(msg *Message, now time.Time) {
+	if msg.ClientMsgID == "" || now.Sub(msg.CreateTime) >= d.ttl {
+		return
+	}
+	if _, exists := d.entries[msg.ClientMsgID]; exists {
+		return
+	}
+	d.entries[msg.ClientMsgID] = msg
+	d.order = append(d.order, msg.ClientMsgID)
+	if d.track {
+		d.added = append(d.added, msg.ClientMsgID)
+	}
+	for len(d.order) > d.maxEntries {
+		d.evictOldest()
+	}
+	d.expire(now)
+}
+
+// expire 移除超过TTL的记录
+func (d *dedupIndex) expire(now time.Time) {
+	for len(d.order) > 0 {
+		oldest := d.entries[d.order[0]]
+		if now.Sub(oldest.CreateTime) < d.ttl {
+			return
+		}
+		d.evictOldest()
+	}
+}
+
+func (d *dedupIndex) evictOldest() {
+	if d.track {
+		d.evicted = append(d.evicted, d.order[0])
+	}
+	delete(d.entries, d.order[0])
+	d.order[0] = ""
+	d.order = d.order[1:]
+}
+
+// duplicateMessageError Timeline.AddMessage发现clientMsgID重复时返回，携带已有的消息
+type duplicateMessageError struct {
+	existing *Message
+}
+
+func (e *duplicateMessageError) Error() string {
+	return fmt.Sprintf("duplicate client message %s", e.existing.ClientMsgID)
+}
+
+// findDuplicate 在去重索引中查找clientMsgID对应的消息
+func (tl *Timeline) findDuplicate(clientMsgID string) *Message {
+	if clientMsgID == "" {
+		return nil
+	}
+	// lookup会淘汰过期记录，需要写锁
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	return tl.dedup.lookup(clientMsgID, time.Now())
+}
+
+// newDedupIndex 按Store配置为会话Timeline创建去重索引
+func (s *Store) newDedupIndex() *dedupIndex {
+	d := newDedupIndex(s.Config.DedupTTL, s.Config.DedupMaxEntries)
+	d.track = s.metadataKV()
+	return d
+}
+
+// rememberClientMessages 将带clientMsgID的消息登记到会话Timeline的去重索引，调用方持有Timeline锁
+func (s *Store) rememberClientMessages(tl *Timeline, messages []*Message) {
+	if tl.Type != "conv" {
+		return
+	}
+	now := time.Now()
+	for _, msg := range messages {
+		if msg.ClientMsgID == "" {
+			continue
+		}
+		if tl.dedup == nil {
+			tl.dedup = s.newDedupIndex()
+		}
+		tl.dedup.remember(msg, now)
+	}
+}
+
+// rebuildDedupIndex 从已加载的消息重建去重索引，重启后仍能识别去重窗口内的重试
+// 调用方持有Timeline锁或Timeline尚未发布
+func (s *Store) rebuildDedupIndex(tl *Timeline) {
+	if tl.Type != "conv" {
+		return
+	}
+	ttl, maxEntries := s.Config.DedupTTL, int64(s.Config.DedupMaxEntries)
+	if ttl <= 0 {
+		ttl = defaultDedupTTL
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultDedupMaxEntries
+	}
+
+	// 未加载的块（见block_loader.go）只在位于最近maxEntries条消息之内且可能包含去重窗口内的消息时读取
+	recent := len(tl.Blocks)
+	var count int64
+	for recent > 0 && count < maxEntries {
+		block := tl.Blocks[recent-1]
+		block.mu.RLock()
+		expired := block.lazy && time.Since(time.Unix(0, block.Index.MaxTime)) >= ttl
+		count += block.Size
+		block.mu.RUnlock()
+		if expired {
+			break
+		}
+		recent--
+	}
+
+	for i, block := range tl.Blocks {
+		block.mu.RLock()
+		lazy := block.lazy
+		if !lazy {
+			s.rememberClientMessages(tl, block.Messages)
+		}
+		block.mu.RUnlock()
+		if !lazy || i < recent {
+			continue
+		}
+		messages, err := s.readBlockMessages(block)
+		if err != nil {
+			log.Printf("store %s: failed to read block %s for dedup index: %v", s.StoreID, block.BlockID, err)
+			continue
+		}
+		s.rememberClientMessages(tl, messages)
+	}
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppendClientMessageDeduplicates(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	first, duplicate, err := store.AppendClientMessage("conv_d", "c1", 1, []byte("hello"), []string{"1"})
+	if err != nil || duplicate {
+		t.Fatalf("Unexpected first write: %v duplicate=%v", err, duplicate)
+	}
+	store.AddMessage("conv_d", 2, []byte("other"), []string{"1"})
+
+	retry, duplicate, err := store.AppendClientMessage("conv_d", "c1", 1, []byte("hello"), []string{"1"})
+	if err != nil || !duplicate || retry.SeqID != first.SeqID {
+		t.Errorf("Expected retry to return SeqID %d, got %+v duplicate=%v err=%v", first.SeqID, retry, duplicate, err)
+	}
+	if messages, _ := store.GetConvMessages("conv_d", 10, 0); len(messages) != 2 {
+		t.Errorf("Expected 2 conv messages, got %d", len(messages))
+	}
+	if messages, _ := store.GetUserMessagesAfter("1", 0, 0); len(messages) != 2 {
+		t.Errorf("Expected 2 user messages, got %d", len(messages))
+	}
+	// 去重只在同一会话内生效
+	if _, duplicate, _ := store.AppendClientMessage("conv_e", "c1", 1, []byte("hello"), nil); duplicate {
+		t.Error("Client message ids should be scoped to a conversation")
+	}
+	store.Close()
+
+	// 重启后从已持久化的消息重建去重索引
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	retry, duplicate, err = reopened.AppendClientMessage("conv_d", "c1", 1, []byte("hello"), []string{"1"})
+	if err != nil || !duplicate || retry.SeqID != first.SeqID {
+		t.Errorf("Expected retry after reopen to be deduplicated, got %+v duplicate=%v err=%v", retry, duplicate, err)
+	}
+}
+
+func TestAppendClientMessageConcurrentRetries(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 3, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	const retries = 8
+	var wg sync.WaitGroup
+	seqIDs := make(chan int64, retries)
+	written := make(chan struct{}, retries)
+	for i := 0; i < retries; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg, duplicate, err := store.AppendClientMessage("conv_c", "same", 1, []byte("x"), []string{"1"})
+			if err != nil {
+				t.Errorf("Failed to add message: %v", err)
+				return
+			}
+			if !duplicate {
+				written <- struct{}{}
+			}
+			seqIDs <- msg.SeqID
+		}()
+	}
+	wg.Wait()
+	close(seqIDs)
+
+	if len(written) != 1 {
+		t.Errorf("Expected exactly one write, got %d", len(written))
+	}
+	for seqID := range seqIDs {
+		if seqID != 1 {
+			t.Errorf("Expected every retry to see SeqID 1, got %d", seqID)
+		}
+	}
+	if messages, _ := store.GetUserMessagesAfter("1", 0, 0); len(messages) != 1 {
+		t.Errorf("Expected one user message, got %d", len(messages))
+	}
+}
+
+func TestDedupIndexBounds(t *testing.T) {
+	index := newDedupIndex(time.Minute, 2)
+	now := time.Now()
+	for i := 1; i <= 3; i++ {
+		index.remember(&Message{SeqID: int64(i), ClientMsgID: fmt.Sprintf("c%d", i), CreateTime: now}, now)
+	}
+	if index.lookup("c1", now) != nil {
+		t.Error("Expected the oldest entry to be evicted")
+	}
+	if msg := index.lookup("c3", now); msg == nil || msg.SeqID != 3 {
+		t.Errorf("Expected c3 to be indexed, got %+v", msg)
+	}
+	if index.lookup("c2", now.Add(time.Minute)) != nil || len(index.order) != 0 {
+		t.Errorf("Expected entries to expire after the TTL, %d left", len(index.order))
+	}
+	// 创建时间已超过TTL的消息不登记
+	index.remember(&Message{ClientMsgID: "old", CreateTime: now.Add(-time.Hour)}, now)
+	if index.lookup("old", now) != nil {
+		t.Error("Expired messages should not be indexed")
+	}
+}
+
+func TestAddMessageRPCReportsDuplicate(t *testing.T) {
+	ctx := context.Background()
+	_, ts := newTestRemoteStore(t)
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	req := &AddMessageRequest{TimelineKey: "conv_rpc", Message: &Message{SenderID: 1, Data: []byte("hi"), ClientMsgID: "c1"}}
+	first, err := client.AddMessage(ctx, req)
+	if err != nil || first.Duplicate || first.SeqID != 1 {
+		t.Fatalf("Unexpected first response %+v: %v", first, err)
+	}
+	retry, err := client.AddMessage(ctx, req)
+	if err != nil || !retry.Duplicate || retry.SeqID != first.SeqID || retry.HLC != first.HLC {
+		t.Errorf("Expected duplicate of %+v, got %+v: %v", first, retry, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 )
 
@@ -220,7 +221,7 @@ func (m *StoreManager) handleEvents() {
 			
 		case "unhealthy":
 			if store, exists := m.stores[event.Store.ID]; exists {
-				store.Status = "unhealthy"
+				store.Status = StoreStatusUnhealthy
 				log.Printf("Store %s marked as unhealthy", event.Store.ID)
 			}
 		}
@@ -231,7 +232,7 @@ func (m *StoreManager) handleEvents() {
 func (m *StoreManager) GetActiveStores() []*StoreInfo {
 	stores := make([]*StoreInfo, 0)
 	for _, store := range m.stores {
-		if store.Status == "active" {
+		if store.Status == StoreStatusActive {
 			stores = append(stores, store)
 		}
 	}
@@ -247,4 +248,96 @@ func (m *StoreManager) GetStore(storeID string) (*StoreInfo, bool) {
 // GetAllStores 获取所有Store
 func (m *StoreManager) GetAllStores() map[string]*StoreInfo {
 	return m.stores
-}
\ No newline at end of file
+}
+// StoreRouterSync 根据注册中心的变化自动维护RouterManager中各路由器的Store
+// 状态为active的Store加入路由，注销、排空、不健康或已下线的Store从路由中移除
+type StoreRouterSync struct {
+	registry      StoreRegistry
+	routerManager *RouterManager
+
+	mu     sync.Mutex
+	routed map[string]bool // 当前已加入路由的Store
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewStoreRouterSync 创建路由同步器
+func NewStoreRouterSync(registry StoreRegistry, routerManager *RouterManager) *StoreRouterSync {
+	return &StoreRouterSync{
+		registry:      registry,
+		routerManager: routerManager,
+		routed:        make(map[string]bool),
+	}
+}
+
+// Start 按当前Store列表同步一次路由，然后持续处理注册中心事件
+// 先开始监听再读取列表，避免两者之间的变化被遗漏
+func (s *StoreRouterSync) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	events, err := s.registry.Watch(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to watch store registry: %w", err)
+	}
+	stores, err := s.registry.ListStores(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to list stores: %w", err)
+	}
+	for _, store := range stores {
+		s.apply(store, false)
+	}
+
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		for event := range events {
+			if event.Store != nil {
+				s.apply(event.Store, event.Type == "unregister")
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop 停止同步，已同步的路由保持不变
+func (s *StoreRouterSync) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+}
+
+// apply 将单个Store的最新状态应用到路由器
+func (s *StoreRouterSync) apply(info *StoreInfo, removed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !removed && info.Status.AcceptsPlacements() {
+		routed := *info
+		if err := s.routerManager.AddStore(&routed); err != nil {
+			log.Printf("router sync: failed to add store %s: %v", info.ID, err)
+			return
+		}
+		if !s.routed[info.ID] {
+			s.routed[info.ID] = true
+			log.Printf("router sync: store %s added at %s", info.ID, info.Address)
+		}
+		return
+	}
+
+	if !s.routed[info.ID] {
+		return
+	}
+	if err := s.routerManager.RemoveStore(info.ID); err != nil {
+		log.Printf("router sync: failed to remove store %s: %v", info.ID, err)
+		return
+	}
+	delete(s.routed, info.ID)
+	reason := string(info.Status)
+	if removed {
+		reason = "unregistered"
+	}
+	log.Printf("router sync: store %s removed (%s)", info.ID, reason)
+}
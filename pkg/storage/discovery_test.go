@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStoreRouterSync(t *testing.T) {
+	ctx := context.Background()
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: "store_a", Address: "http://a"})
+
+	router := NewConsistentHashRouter(1, 10, 0.8)
+	routerManager := NewRouterManager()
+	routerManager.RegisterRouter("hash", router)
+
+	routerSync := NewStoreRouterSync(registry, routerManager)
+	if err := routerSync.Start(ctx); err != nil {
+		t.Fatalf("Failed to start router sync: %v", err)
+	}
+	defer routerSync.Stop()
+
+	routesTo := func(storeIDs ...string) bool {
+		expected := make(map[string]bool)
+		for _, id := range storeIDs {
+			expected[id] = true
+		}
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			seen := make(map[string]bool)
+			for i := 0; i < 50; i++ {
+				if target, err := router.RouteTimeline(string(rune('a' + i))); err == nil {
+					seen[target] = true
+				}
+			}
+			if len(seen) == len(expected) {
+				match := true
+				for id := range seen {
+					match = match && expected[id]
+				}
+				if match {
+					return true
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return false
+	}
+
+	if !routesTo("store_a") {
+		t.Fatal("Expected existing store to be routed after start")
+	}
+
+	registry.Register(ctx, &StoreInfo{ID: "store_b", Address: "http://b"})
+	if !routesTo("store_a", "store_b") {
+		t.Fatal("Expected registered store to join the router")
+	}
+
+	// 心跳不应在哈希环上重复添加虚拟节点
+	registry.UpdateHeartbeat(ctx, "store_b")
+	time.Sleep(20 * time.Millisecond)
+	if nodes := len(router.hashRing.nodes); nodes != 20 {
+		t.Errorf("Expected 20 virtual nodes, got %d", nodes)
+	}
+
+	registry.UpdateStatus(ctx, "store_b", StoreStatusDraining)
+	if !routesTo("store_a") {
+		t.Fatal("Expected draining store to leave the router")
+	}
+	registry.UpdateStatus(ctx, "store_b", "active")
+	if !routesTo("store_a", "store_b") {
+		t.Fatal("Expected store to rejoin once active again")
+	}
+
+	registry.Unregister(ctx, "store_a")
+	if !routesTo("store_b") {
+		t.Fatal("Expected unregistered store to leave the router")
+	}
+}
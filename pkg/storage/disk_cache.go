@@ -0,0 +1,460 @@
+package storage
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	diskCacheMagic       = "IMDC"
+	diskCacheVersion     = 1
+	diskCacheIndexFile   = "index"
+	diskCacheEntrySuffix = ".entry"
+	diskCacheTmpSuffix   = ".tmp"
+
+	// DefaultDiskCacheSize 默认磁盘缓存容量
+	DefaultDiskCacheSize int64 = 1 << 30
+
+	// diskCacheIndexSyncEvery 累计多少次变更后落盘一次索引
+	diskCacheIndexSyncEvery = 64
+)
+
+// 磁盘缓存中常见的值类型，其他类型需调用方自行gob.Register
+func init() {
+	gob.Register([]byte(nil))
+	gob.Register("")
+	gob.Register(&Message{})
+	gob.Register([]*Message(nil))
+	gob.Register(&TimelineBlock{})
+	gob.Register(&Timeline{})
+	gob.Register(map[string]interface{}(nil))
+}
+
+// DiskCache 基于本地文件的L2缓存
+// 每个条目一个文件，按键哈希的前两位分到256个子目录；文件头记录键和过期时间并带CRC校验，
+// 因此索引只是加速启动和保存LRU顺序的快照，丢失或过期时可由条目文件重建
+type DiskCache struct {
+	mu      sync.RWMutex
+	baseDir string
+	maxSize int64
+	curSize int64
+	entries map[string]*list.Element
+	lruList *list.List
+	dirty   int // 上次保存索引后的变更次数
+	stats   *CacheStats
+}
+
+// diskCacheEntry 磁盘缓存条目元数据，同时是索引文件的记录
+type diskCacheEntry struct {
+	Key        string
+	File       string // 相对baseDir的路径
+	Size       int64
+	ExpireTime time.Time
+}
+
+// diskCacheValue 条目文件中的值，用接口包装以支持任意已注册类型
+type diskCacheValue struct {
+	Value interface{}
+}
+
+// NewDiskCache 创建默认容量的磁盘缓存
+func NewDiskCache(baseDir string) *DiskCache {
+	return NewDiskCacheWithSize(baseDir, DefaultDiskCacheSize)
+}
+
+// NewDiskCacheWithSize 创建磁盘缓存，启动时加载索引并与磁盘上的条目文件对账
+func NewDiskCacheWithSize(baseDir string, maxSize int64) *DiskCache {
+	dc := &DiskCache{
+		baseDir: baseDir,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lruList: list.New(),
+		stats:   &CacheStats{},
+	}
+	if err := dc.load(); err != nil {
+		log.Printf("disk cache %s: failed to load: %v", baseDir, err)
+	}
+	return dc
+}
+
+// Get 获取缓存值，过期或损坏的条目会被删除
+func (dc *DiskCache) Get(key string) (interface{}, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	elem, exists := dc.entries[key]
+	if !exists {
+		dc.stats.Misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*diskCacheEntry)
+	if !entry.ExpireTime.IsZero() && time.Now().After(entry.ExpireTime) {
+		dc.removeElement(elem)
+		dc.stats.Misses++
+		return nil, false
+	}
+
+	_, value, err := readDiskCacheFile(filepath.Join(dc.baseDir, entry.File), true)
+	if err != nil {
+		log.Printf("disk cache %s: dropping unreadable entry %s: %v", dc.baseDir, key, err)
+		dc.removeElement(elem)
+		dc.stats.Misses++
+		return nil, false
+	}
+
+	dc.lruList.MoveToFront(elem)
+	dc.stats.Hits++
+	return value, true
+}
+
+// Set 设置缓存值，条目文件先写临时文件再重命名，超出容量时淘汰最久未访问的条目
+func (dc *DiskCache) Set(key string, value interface{}, ttl time.Duration) error {
+	var expireTime time.Time
+	if ttl > 0 {
+		expireTime = time.Now().Add(ttl)
+	}
+
+	data, err := encodeDiskCacheFile(key, expireTime, value)
+	if err != nil {
+		return err
+	}
+	size := int64(len(data))
+	if size > dc.maxSize {
+		return fmt.Errorf("disk cache entry too large: %d > %d", size, dc.maxSize)
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	relPath := diskCachePath(key)
+	if err := writeFileAtomic(filepath.Join(dc.baseDir, relPath), data); err != nil {
+		return fmt.Errorf("failed to write disk cache entry: %w", err)
+	}
+
+	entry := &diskCacheEntry{Key: key, File: relPath, Size: size, ExpireTime: expireTime}
+	if elem, exists := dc.entries[key]; exists {
+		dc.curSize -= elem.Value.(*diskCacheEntry).Size
+		elem.Value = entry
+		dc.lruList.MoveToFront(elem)
+	} else {
+		dc.entries[key] = dc.lruList.PushFront(entry)
+	}
+	dc.curSize += size
+
+	for dc.curSize > dc.maxSize {
+		oldest := dc.lruList.Back()
+		if oldest == nil || oldest.Value.(*diskCacheEntry).Key == key {
+			break
+		}
+		dc.removeElement(oldest)
+		dc.stats.Evictions++
+	}
+
+	dc.markDirty()
+	return nil
+}
+
+// Delete 删除缓存
+func (dc *DiskCache) Delete(key string) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if elem, exists := dc.entries[key]; exists {
+		dc.removeElement(elem)
+		dc.markDirty()
+	}
+	return nil
+}
+
+// Clear 清空缓存，删除所有条目文件和索引
+func (dc *DiskCache) Clear() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.entries = make(map[string]*list.Element)
+	dc.lruList = list.New()
+	dc.curSize = 0
+	dc.dirty = 0
+
+	if err := os.RemoveAll(dc.baseDir); err != nil {
+		return fmt.Errorf("failed to clear disk cache: %w", err)
+	}
+	return nil
+}
+
+// Size 获取当前大小
+func (dc *DiskCache) Size() int64 {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+	return dc.curSize
+}
+
+// Stats 获取统计信息
+func (dc *DiskCache) Stats() *CacheStats {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	stats := *dc.stats
+	stats.TotalSize = dc.curSize
+	stats.EntryCount = int64(len(dc.entries))
+
+	total := stats.Hits + stats.Misses
+	if total > 0 {
+		stats.HitRatio = float64(stats.Hits) / float64(total)
+	}
+
+	return &stats
+}
+
+// Close 保存索引
+func (dc *DiskCache) Close() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+	return dc.saveIndex()
+}
+
+// removeElement 删除条目及其文件
+func (dc *DiskCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*diskCacheEntry)
+	delete(dc.entries, entry.Key)
+	dc.lruList.Remove(elem)
+	dc.curSize -= entry.Size
+	if err := os.Remove(filepath.Join(dc.baseDir, entry.File)); err != nil && !os.IsNotExist(err) {
+		log.Printf("disk cache %s: failed to remove %s: %v", dc.baseDir, entry.File, err)
+	}
+}
+
+// markDirty 记录一次变更，累计到阈值时保存索引
+func (dc *DiskCache) markDirty() {
+	dc.dirty++
+	if dc.dirty < diskCacheIndexSyncEvery {
+		return
+	}
+	if err := dc.saveIndex(); err != nil {
+		log.Printf("disk cache %s: failed to save index: %v", dc.baseDir, err)
+	}
+}
+
+// saveIndex 按从旧到新的LRU顺序保存索引
+func (dc *DiskCache) saveIndex() error {
+	records := make([]*diskCacheEntry, 0, len(dc.entries))
+	for elem := dc.lruList.Back(); elem != nil; elem = elem.Prev() {
+		records = append(records, elem.Value.(*diskCacheEntry))
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(records); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(dc.baseDir, diskCacheIndexFile), buf.Bytes()); err != nil {
+		return err
+	}
+	dc.dirty = 0
+	return nil
+}
+
+// load 加载索引并与条目文件对账：
+// 索引中文件缺失或大小不符的记录丢弃，未进索引的有效条目文件（上次保存索引后写入）补回，
+// 损坏文件、残留的临时文件和已过期条目删除
+func (dc *DiskCache) load() error {
+	if err := os.MkdirAll(dc.baseDir, 0755); err != nil {
+		return err
+	}
+
+	indexed := make(map[string]*diskCacheEntry)
+	if data, err := os.ReadFile(filepath.Join(dc.baseDir, diskCacheIndexFile)); err == nil {
+		var records []*diskCacheEntry
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&records); err != nil {
+			log.Printf("disk cache %s: index is corrupt, rebuilding: %v", dc.baseDir, err)
+		} else {
+			for _, record := range records {
+				indexed[record.File] = record
+			}
+			for _, record := range records {
+				dc.entries[record.Key] = dc.lruList.PushFront(record)
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	found := make(map[string]bool)
+	now := time.Now()
+	err := filepath.WalkDir(dc.baseDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dc.baseDir, path)
+		switch {
+		case strings.HasSuffix(rel, diskCacheTmpSuffix):
+			os.Remove(path)
+			return nil
+		case !strings.HasSuffix(rel, diskCacheEntrySuffix):
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if record, ok := indexed[rel]; ok && record.Size == info.Size() {
+			found[rel] = true
+			return nil
+		}
+
+		// 未进索引或与索引不一致，以文件头为准
+		header, _, err := readDiskCacheFile(path, false)
+		if err != nil || header.File != rel {
+			os.Remove(path)
+			return nil
+		}
+		header.Size = info.Size()
+		if elem, exists := dc.entries[header.Key]; exists {
+			elem.Value = header
+		} else {
+			dc.entries[header.Key] = dc.lruList.PushBack(header)
+		}
+		found[rel] = true
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for elem := dc.lruList.Front(); elem != nil; {
+		next := elem.Next()
+		entry := elem.Value.(*diskCacheEntry)
+		switch {
+		case !found[entry.File]:
+			delete(dc.entries, entry.Key)
+			dc.lruList.Remove(elem)
+		case !entry.ExpireTime.IsZero() && now.After(entry.ExpireTime):
+			dc.curSize += entry.Size
+			dc.removeElement(elem)
+		default:
+			dc.curSize += entry.Size
+		}
+		elem = next
+	}
+
+	for dc.curSize > dc.maxSize && dc.lruList.Len() > 0 {
+		dc.removeElement(dc.lruList.Back())
+	}
+
+	return dc.saveIndex()
+}
+
+// diskCachePath 键对应的条目文件相对路径，按哈希前两位分子目录
+func diskCachePath(key string) string {
+	sum := sha1.Sum([]byte(key))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(name[:2], name+diskCacheEntrySuffix)
+}
+
+// encodeDiskCacheFile 编码条目文件：魔数、版本、过期时间、键、gob编码的值，末尾为CRC32
+func encodeDiskCacheFile(key string, expireTime time.Time, value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(diskCacheMagic)
+	buf.WriteByte(diskCacheVersion)
+
+	var expire int64
+	if !expireTime.IsZero() {
+		expire = expireTime.UnixNano()
+	}
+	binary.Write(&buf, binary.BigEndian, expire)
+	binary.Write(&buf, binary.BigEndian, uint32(len(key)))
+	buf.WriteString(key)
+
+	if err := gob.NewEncoder(&buf).Encode(&diskCacheValue{Value: value}); err != nil {
+		return nil, fmt.Errorf("failed to encode disk cache value: %w", err)
+	}
+
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+// readDiskCacheFile 读取并校验条目文件，decodeValue为false时只解析文件头
+func readDiskCacheFile(path string, decodeValue bool) (*diskCacheEntry, interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	headerLen := len(diskCacheMagic) + 1 + 8 + 4
+	if len(data) < headerLen+4 || string(data[:len(diskCacheMagic)]) != diskCacheMagic {
+		return nil, nil, errors.New("invalid disk cache entry")
+	}
+	if data[len(diskCacheMagic)] != diskCacheVersion {
+		return nil, nil, fmt.Errorf("unsupported disk cache entry version: %d", data[len(diskCacheMagic)])
+	}
+	body, checksum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != checksum {
+		return nil, nil, errors.New("disk cache entry checksum mismatch")
+	}
+
+	reader := bytes.NewReader(body[len(diskCacheMagic)+1:])
+	var expire int64
+	var keyLen uint32
+	binary.Read(reader, binary.BigEndian, &expire)
+	binary.Read(reader, binary.BigEndian, &keyLen)
+	if int64(keyLen) > int64(reader.Len()) {
+		return nil, nil, errors.New("invalid disk cache entry key")
+	}
+	key := make([]byte, keyLen)
+	io.ReadFull(reader, key)
+
+	entry := &diskCacheEntry{Key: string(key), File: diskCachePath(string(key))}
+	if expire != 0 {
+		entry.ExpireTime = time.Unix(0, expire)
+	}
+	if !decodeValue {
+		return entry, nil, nil
+	}
+
+	var value diskCacheValue
+	if err := gob.NewDecoder(reader).Decode(&value); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode disk cache value: %w", err)
+	}
+	return entry, value.Value, nil
+}
+
+// writeFileAtomic 写临时文件并fsync后重命名，崩溃时要么是旧文件要么是完整的新文件
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmpPath := path + diskCacheTmpSuffix
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDiskCacheRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewDiskCache(dir)
+
+	if err := cache.Set("bytes", []byte("hello"), 0); err != nil {
+		t.Fatalf("Failed to set bytes: %v", err)
+	}
+	messages := []*Message{{SeqID: 1, ConvID: "conv_a", Data: []byte("hi")}}
+	if err := cache.Set("messages", messages, 0); err != nil {
+		t.Fatalf("Failed to set messages: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, diskCachePath("bytes"))); err != nil {
+		t.Fatalf("Expected entry file on disk: %v", err)
+	}
+
+	value, ok := cache.Get("bytes")
+	if !ok || string(value.([]byte)) != "hello" {
+		t.Fatalf("Unexpected bytes value: %v %v", value, ok)
+	}
+	value, ok = cache.Get("messages")
+	if !ok || value.([]*Message)[0].ConvID != "conv_a" {
+		t.Fatalf("Unexpected messages value: %v %v", value, ok)
+	}
+
+	if err := cache.Delete("bytes"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, ok := cache.Get("bytes"); ok {
+		t.Error("Deleted entry should be missing")
+	}
+	if _, err := os.Stat(filepath.Join(dir, diskCachePath("bytes"))); !os.IsNotExist(err) {
+		t.Error("Deleted entry file should be removed")
+	}
+
+	// 未Close即重新打开，索引尚未保存，条目从文件重建
+	reopened := NewDiskCache(dir)
+	value, ok = reopened.Get("messages")
+	if !ok || value.([]*Message)[0].SeqID != 1 {
+		t.Fatalf("Entry should survive reopen: %v %v", value, ok)
+	}
+
+	if err := reopened.Clear(); err != nil {
+		t.Fatalf("Failed to clear: %v", err)
+	}
+	if _, ok := reopened.Get("messages"); ok || reopened.Size() != 0 {
+		t.Error("Cache should be empty after clear")
+	}
+	if _, err := os.Stat(filepath.Join(dir, diskCachePath("messages"))); !os.IsNotExist(err) {
+		t.Error("Entry files should be removed after clear")
+	}
+	if err := reopened.Set("again", "value", 0); err != nil {
+		t.Fatalf("Failed to set after clear: %v", err)
+	}
+}
+
+func TestDiskCacheEvictionAndTTL(t *testing.T) {
+	dir := t.TempDir()
+	probe, _ := encodeDiskCacheFile("key-0", time.Time{}, make([]byte, 100))
+	cache := NewDiskCacheWithSize(dir, int64(len(probe))*3)
+
+	for _, key := range []string{"key-0", "key-1", "key-2"} {
+		if err := cache.Set(key, make([]byte, 100), 0); err != nil {
+			t.Fatalf("Failed to set %s: %v", key, err)
+		}
+	}
+	cache.Get("key-0")
+	cache.Set("key-3", make([]byte, 100), 0)
+
+	if _, ok := cache.Get("key-1"); ok {
+		t.Error("Least recently used entry should be evicted")
+	}
+	if _, ok := cache.Get("key-0"); !ok {
+		t.Error("Recently read entry should be kept")
+	}
+	if cache.Size() > int64(len(probe))*3 {
+		t.Errorf("Cache exceeds its limit: %d", cache.Size())
+	}
+
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	// 重新打开时保留LRU顺序
+	reopened := NewDiskCacheWithSize(dir, int64(len(probe))*3)
+	if stats := reopened.Stats(); stats.EntryCount != 3 {
+		t.Fatalf("Expected 3 entries after reopen, got %d", stats.EntryCount)
+	}
+	reopened.Set("key-4", make([]byte, 100), 0)
+	if _, ok := reopened.Get("key-2"); ok {
+		t.Error("Oldest entry should be evicted after reopen")
+	}
+
+	ttlDir := t.TempDir()
+	ttlCache := NewDiskCache(ttlDir)
+	ttlCache.Set("short", []byte("x"), 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := ttlCache.Get("short"); ok {
+		t.Error("Expired entry should be missing")
+	}
+	if _, err := os.Stat(filepath.Join(ttlDir, diskCachePath("short"))); !os.IsNotExist(err) {
+		t.Error("Expired entry file should be removed on read")
+	}
+}
+
+func TestDiskCacheRecoversFromCorruption(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewDiskCache(dir)
+	cache.Set("good", []byte("ok"), 0)
+	cache.Set("bad", []byte("broken"), 0)
+	if err := cache.Close(); err != nil {
+		t.Fatalf("Failed to close: %v", err)
+	}
+
+	// 损坏条目文件和索引，并留下写入中途崩溃的临时文件
+	badPath := filepath.Join(dir, diskCachePath("bad"))
+	data, _ := os.ReadFile(badPath)
+	data[len(data)-5] ^= 0xff
+	os.WriteFile(badPath, data, 0644)
+	os.WriteFile(filepath.Join(dir, diskCacheIndexFile), []byte("garbage"), 0644)
+	tmpPath := filepath.Join(dir, diskCachePath("partial")) + diskCacheTmpSuffix
+	os.MkdirAll(filepath.Dir(tmpPath), 0755)
+	os.WriteFile(tmpPath, []byte("partial"), 0644)
+
+	reopened := NewDiskCache(dir)
+	if value, ok := reopened.Get("good"); !ok || string(value.([]byte)) != "ok" {
+		t.Errorf("Intact entry should be recovered: %v %v", value, ok)
+	}
+	if _, ok := reopened.Get("bad"); ok {
+		t.Error("Corrupt entry should be dropped")
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Error("Leftover temp file should be removed")
+	}
+}
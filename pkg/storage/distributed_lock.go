@@ -2,15 +2,22 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"sort"
 	"sync"
 	"time"
 )
 
 // DistributedLockManager 分布式锁管理器接口
 type DistributedLockManager interface {
-	// 获取锁
+	// 获取排他锁，锁被占用时立即失败
 	AcquireLock(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error)
+	// 获取排他锁，锁被占用时排队等待直到ctx结束
+	AcquireWithWait(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error)
+	// 获取共享锁，锁被排他占用时排队等待直到ctx结束
+	AcquireRLock(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error)
 	// 释放锁
 	ReleaseLock(ctx context.Context, lock *DistributedLock) error
 	// 续期锁
@@ -21,12 +28,21 @@ type DistributedLockManager interface {
 	GetLockInfo(ctx context.Context, lockKey string) (*LockInfo, error)
 }
 
+// LockMode 锁模式
+type LockMode string
+
+const (
+	LockExclusive LockMode = "exclusive" // 排他（写）锁
+	LockShared    LockMode = "shared"    // 共享（读）锁
+)
+
 // DistributedLock 分布式锁
 type DistributedLock struct {
 	LockKey   string    `json:"lock_key"`
 	LockID    string    `json:"lock_id"`
 	OwnerID   string    `json:"owner_id"`
 	StoreID   string    `json:"store_id"`
+	Mode      LockMode  `json:"mode"`
 	AcquiredAt time.Time `json:"acquired_at"`
 	ExpiresAt  time.Time `json:"expires_at"`
 	TTL        time.Duration `json:"ttl"`
@@ -42,91 +58,218 @@ type LockInfo struct {
 	AcquiredAt time.Time `json:"acquired_at"`
 	ExpiresAt  time.Time `json:"expires_at"`
 	IsActive   bool      `json:"is_active"`
+	Mode       LockMode  `json:"mode"`
+	Holders    int       `json:"holders"`   // 持有者数量，共享锁可以有多个
+	Waiters    int       `json:"waiters"`   // 排队等待的请求数
+	Reentries  int       `json:"reentries"` // 该持有者的重入次数
+}
+
+// 内存锁管理器
+// 每个锁key可以由一个排他（写）持有者或多个共享（读）持有者持有，持有者过期后视为已释放。
+// 阻塞获取的请求按到达顺序排队，队首请求与当前持有者兼容时才授予，共享请求也不会越过排队的排他请求，
+// 因此写锁不会因源源不断的读锁而饿死。通过WithLockOwner在ctx中指定所有者后，同一所有者可以重入已持有的锁，
+// 每次获取返回同一个LockID，释放相同次数后锁才真正释放；持有共享锁的所有者不能再获取排他锁。
+// 等待中的所有者之间形成环时，最年轻的所有者的获取请求以ErrDeadlock失败，见distributed_lock_deadlock.go
+
+// ErrLockHeld 非阻塞获取时锁已被其他所有者持有
+var ErrLockHeld = errors.New("lock already acquired")
+
+// ErrLockManagerClosed 锁管理器已在停机时释放全部锁，不再授予新的锁
+var ErrLockManagerClosed = errors.New("lock manager is closed")
+
+type lockOwnerKey struct{}
+
+// WithLockOwner 返回指定锁所有者的ctx，同一所有者可以重入已持有的锁
+func WithLockOwner(ctx context.Context, ownerID string) context.Context {
+	return context.WithValue(ctx, lockOwnerKey{}, ownerID)
+}
+
+// LockOwnerFromContext 获取ctx中指定的锁所有者
+func LockOwnerFromContext(ctx context.Context) string {
+	ownerID, _ := ctx.Value(lockOwnerKey{}).(string)
+	return ownerID
 }
 
 // InMemoryDistributedLockManager 内存分布式锁管理器实现
 type InMemoryDistributedLockManager struct {
-	locks     map[string]*LockInfo
+	locks     map[string]*lockEntry
 	storeID   string
-	mu        sync.RWMutex
+	mu        sync.Mutex
+	sequence  int64
 	cleanupCh chan struct{}
+	closed    bool // ReleaseAll之后拒绝新的获取请求
+}
+
+// lockEntry 一个锁key的持有者与等待队列
+type lockEntry struct {
+	holders []*lockHolder
+	waiters []*lockWaiter
+}
+
+// lockHolder 锁的一个持有者，count为重入次数
+type lockHolder struct {
+	info  LockInfo
+	ttl   time.Duration
+	count int
+}
+
+// lockWaiter 排队等待的获取请求，授予后锁通过ready传给等待者，请求失败时传nil并设置err
+type lockWaiter struct {
+	ownerID  string
+	mode     LockMode
+	ttl      time.Duration
+	queuedAt time.Time
+	ready    chan *DistributedLock
+	err      error
 }
 
 // NewInMemoryDistributedLockManager 创建内存分布式锁管理器
 func NewInMemoryDistributedLockManager(storeID string) *InMemoryDistributedLockManager {
 	manager := &InMemoryDistributedLockManager{
-		locks:     make(map[string]*LockInfo),
+		locks:     make(map[string]*lockEntry),
 		storeID:   storeID,
 		cleanupCh: make(chan struct{}),
 	}
-	
+
 	// 启动清理过期锁的goroutine
 	go manager.cleanupExpiredLocks()
-	
+
 	return manager
 }
 
-// AcquireLock 获取分布式锁
+// AcquireLock 获取排他锁，锁被其他所有者持有或有请求在排队时立即返回ErrLockHeld
 func (m *InMemoryDistributedLockManager) AcquireLock(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error) {
+	return m.acquire(ctx, lockKey, ttl, LockExclusive, false)
+}
+
+// AcquireWithWait 获取排他锁，锁被占用时排队等待，直到获取成功或ctx结束
+func (m *InMemoryDistributedLockManager) AcquireWithWait(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error) {
+	return m.acquire(ctx, lockKey, ttl, LockExclusive, true)
+}
+
+// AcquireRLock 获取共享锁，锁被排他持有或有排他请求在排队时等待，直到获取成功或ctx结束
+func (m *InMemoryDistributedLockManager) AcquireRLock(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error) {
+	return m.acquire(ctx, lockKey, ttl, LockShared, true)
+}
+
+func (m *InMemoryDistributedLockManager) acquire(ctx context.Context, lockKey string, ttl time.Duration, mode LockMode, wait bool) (*DistributedLock, error) {
+	ownerID := LockOwnerFromContext(ctx)
+
 	m.mu.Lock()
-	defer m.mu.Unlock()
-	
-	// 检查是否已存在锁
-	if existingLock, exists := m.locks[lockKey]; exists {
-		// 检查锁是否过期
-		if time.Now().Before(existingLock.ExpiresAt) {
-			return nil, fmt.Errorf("lock already acquired by %s", existingLock.OwnerID)
+	if m.closed {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("acquire lock %s: %w", lockKey, ErrLockManagerClosed)
+	}
+	if ownerID == "" {
+		ownerID = m.nextIDLocked()
+	}
+	entry := m.entryLocked(lockKey)
+	m.purgeExpiredLocked(lockKey, entry)
+
+	// 重入
+	if holder := entry.holder(ownerID); holder != nil {
+		defer m.mu.Unlock()
+		if mode == LockExclusive && holder.info.Mode == LockShared {
+			return nil, fmt.Errorf("lock %s is held shared by %s and cannot be upgraded", lockKey, ownerID)
 		}
-		// 锁已过期，删除
-		delete(m.locks, lockKey)
+		holder.count++
+		if expiresAt := time.Now().Add(ttl); expiresAt.After(holder.info.ExpiresAt) {
+			holder.info.ExpiresAt = expiresAt
+			holder.ttl = ttl
+		}
+		return m.lockFor(holder), nil
 	}
-	
-	// 创建新锁
-	lockID := fmt.Sprintf("%s_%d", m.storeID, time.Now().UnixNano())
-	ownerID := fmt.Sprintf("%s_%d", m.storeID, time.Now().UnixNano())
-	now := time.Now()
-	
-	lockInfo := &LockInfo{
-		LockKey:    lockKey,
-		LockID:     lockID,
-		OwnerID:    ownerID,
-		StoreID:    m.storeID,
-		AcquiredAt: now,
-		ExpiresAt:  now.Add(ttl),
-		IsActive:   true,
+
+	if len(entry.waiters) == 0 && entry.compatible(mode) {
+		lock := m.grantLocked(lockKey, entry, ownerID, mode, ttl)
+		m.mu.Unlock()
+		return lock, nil
+	}
+	if !wait {
+		defer m.mu.Unlock()
+		m.removeIfIdleLocked(lockKey, entry)
+		return nil, fmt.Errorf("%w by %s", ErrLockHeld, entry.holders[0].info.OwnerID)
+	}
+
+	waiter := &lockWaiter{ownerID: ownerID, mode: mode, ttl: ttl, queuedAt: time.Now(), ready: make(chan *DistributedLock, 1)}
+	entry.waiters = append(entry.waiters, waiter)
+	m.detectDeadlocksLocked()
+	m.mu.Unlock()
+
+	for {
+		// 持有者过期不会触发通知，等到最早的过期时间重新检查
+		m.mu.Lock()
+		expiry, ok := entry.nextExpiry()
+		m.mu.Unlock()
+		var expired <-chan time.Time
+		var timer *time.Timer
+		if ok {
+			timer = time.NewTimer(time.Until(expiry) + time.Millisecond)
+			expired = timer.C
+		}
+		stop := func() {
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+
+		select {
+		case lock := <-waiter.ready:
+			stop()
+			if lock == nil {
+				return nil, waiter.err
+			}
+			return lock, nil
+		case <-expired:
+			m.mu.Lock()
+			m.purgeExpiredLocked(lockKey, entry)
+			m.grantWaitersLocked(lockKey, entry)
+			m.mu.Unlock()
+		case <-ctx.Done():
+			stop()
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			select {
+			case lock := <-waiter.ready:
+				if lock == nil {
+					return nil, waiter.err
+				}
+				// 取消的同时已被授予，释放后再返回
+				m.releaseLocked(lock)
+			default:
+				entry.removeWaiter(waiter)
+				m.grantWaitersLocked(lockKey, entry)
+				m.removeIfIdleLocked(lockKey, entry)
+			}
+			return nil, fmt.Errorf("waiting for lock %s: %w", lockKey, ctx.Err())
+		}
 	}
-	
-	m.locks[lockKey] = lockInfo
-	
-	return &DistributedLock{
-		LockKey:    lockKey,
-		LockID:     lockID,
-		OwnerID:    ownerID,
-		StoreID:    m.storeID,
-		AcquiredAt: now,
-		ExpiresAt:  now.Add(ttl),
-		TTL:        ttl,
-		manager:    m,
-	}, nil
 }
 
-// ReleaseLock 释放分布式锁
+// ReleaseLock 释放分布式锁，重入的锁需要释放相同次数
 func (m *InMemoryDistributedLockManager) ReleaseLock(ctx context.Context, lock *DistributedLock) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	
-	existingLock, exists := m.locks[lock.LockKey]
-	if !exists {
+	return m.releaseLocked(lock)
+}
+
+func (m *InMemoryDistributedLockManager) releaseLocked(lock *DistributedLock) error {
+	entry, exists := m.locks[lock.LockKey]
+	if !exists || len(entry.holders) == 0 {
 		return fmt.Errorf("lock not found: %s", lock.LockKey)
 	}
-	
+
 	// 验证锁的所有者
-	if existingLock.OwnerID != lock.OwnerID {
-		return fmt.Errorf("lock owned by different owner: %s", existingLock.OwnerID)
+	holder := entry.holder(lock.OwnerID)
+	if holder == nil || holder.info.LockID != lock.LockID {
+		return fmt.Errorf("lock owned by different owner: %s", entry.holders[0].info.OwnerID)
 	}
-	
-	// 删除锁
-	delete(m.locks, lock.LockKey)
+
+	if holder.count--; holder.count <= 0 {
+		entry.removeHolder(holder)
+		m.grantWaitersLocked(lock.LockKey, entry)
+	}
+	m.removeIfIdleLocked(lock.LockKey, entry)
 	return nil
 }
 
@@ -134,90 +277,103 @@ func (m *InMemoryDistributedLockManager) ReleaseLock(ctx context.Context, lock *
 func (m *InMemoryDistributedLockManager) RenewLock(ctx context.Context, lock *DistributedLock, ttl time.Duration) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	
-	existingLock, exists := m.locks[lock.LockKey]
-	if !exists {
+
+	entry, exists := m.locks[lock.LockKey]
+	if !exists || len(entry.holders) == 0 {
 		return fmt.Errorf("lock not found: %s", lock.LockKey)
 	}
-	
+
 	// 验证锁的所有者
-	if existingLock.OwnerID != lock.OwnerID {
-		return fmt.Errorf("lock owned by different owner: %s", existingLock.OwnerID)
+	holder := entry.holder(lock.OwnerID)
+	if holder == nil || holder.info.LockID != lock.LockID {
+		return fmt.Errorf("lock owned by different owner: %s", entry.holders[0].info.OwnerID)
 	}
-	
+
 	// 检查锁是否过期
-	if time.Now().After(existingLock.ExpiresAt) {
+	if time.Now().After(holder.info.ExpiresAt) {
 		return fmt.Errorf("lock has expired")
 	}
-	
+
 	// 续期锁
 	now := time.Now()
-	existingLock.ExpiresAt = now.Add(ttl)
+	holder.info.ExpiresAt = now.Add(ttl)
+	holder.ttl = ttl
 	lock.ExpiresAt = now.Add(ttl)
 	lock.TTL = ttl
-	
+
 	return nil
 }
 
 // IsLocked 检查是否被锁定
 func (m *InMemoryDistributedLockManager) IsLocked(ctx context.Context, lockKey string) (bool, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	
-	lockInfo, exists := m.locks[lockKey]
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.locks[lockKey]
 	if !exists {
 		return false, nil
 	}
-	
-	// 检查锁是否过期
-	if time.Now().After(lockInfo.ExpiresAt) {
-		return false, nil
+	now := time.Now()
+	for _, holder := range entry.holders {
+		if !now.After(holder.info.ExpiresAt) {
+			return true, nil
+		}
 	}
-	
-	return true, nil
+	return false, nil
 }
 
-// GetLockInfo 获取锁信息
+// GetLockInfo 获取锁信息，共享锁有多个持有者时返回最早获取的持有者
 func (m *InMemoryDistributedLockManager) GetLockInfo(ctx context.Context, lockKey string) (*LockInfo, error) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	
-	lockInfo, exists := m.locks[lockKey]
-	if !exists {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, exists := m.locks[lockKey]
+	if !exists || len(entry.holders) == 0 {
 		return nil, fmt.Errorf("lock not found: %s", lockKey)
 	}
-	
-	// 检查锁是否过期
-	if time.Now().After(lockInfo.ExpiresAt) {
-		lockInfo.IsActive = false
+	return entry.info(entry.holders[0], time.Now()), nil
+}
+
+// ListLocks 列出当前未过期的锁，每个锁key一项，按获取时间排序
+func (m *InMemoryDistributedLockManager) ListLocks(ctx context.Context) ([]*LockInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	result := make([]*LockInfo, 0, len(m.locks))
+	for _, entry := range m.locks {
+		for _, holder := range entry.holders {
+			if !now.After(holder.info.ExpiresAt) {
+				result = append(result, entry.info(holder, now))
+				break
+			}
+		}
 	}
-	
-	// 返回锁信息的副本
-	return &LockInfo{
-		LockKey:    lockInfo.LockKey,
-		LockID:     lockInfo.LockID,
-		OwnerID:    lockInfo.OwnerID,
-		StoreID:    lockInfo.StoreID,
-		AcquiredAt: lockInfo.AcquiredAt,
-		ExpiresAt:  lockInfo.ExpiresAt,
-		IsActive:   lockInfo.IsActive,
-	}, nil
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].AcquiredAt.Before(result[j].AcquiredAt)
+	})
+	return result, nil
 }
 
 // cleanupExpiredLocks 清理过期锁
 func (m *InMemoryDistributedLockManager) cleanupExpiredLocks() {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
-	
+	deadlockTicker := time.NewTicker(DeadlockDetectionInterval)
+	defer deadlockTicker.Stop()
+
 	for {
 		select {
+		case <-deadlockTicker.C:
+			m.mu.Lock()
+			m.detectDeadlocksLocked()
+			m.mu.Unlock()
 		case <-ticker.C:
 			m.mu.Lock()
-			now := time.Now()
-			for key, lockInfo := range m.locks {
-				if now.After(lockInfo.ExpiresAt) {
-					delete(m.locks, key)
-				}
+			for key, entry := range m.locks {
+				m.purgeExpiredLocked(key, entry)
+				m.grantWaitersLocked(key, entry)
+				m.removeIfIdleLocked(key, entry)
 			}
 			m.mu.Unlock()
 		case <-m.cleanupCh:
@@ -226,11 +382,168 @@ func (m *InMemoryDistributedLockManager) cleanupExpiredLocks() {
 	}
 }
 
+// ReleaseAll 停机时释放本Store授予的全部锁，排队中的请求以ErrLockManagerClosed失败，
+// 之后的获取请求同样失败。返回释放的持有者数量
+func (m *InMemoryDistributedLockManager) ReleaseAll() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	released := 0
+	for key, entry := range m.locks {
+		released += len(entry.holders)
+		for _, waiter := range entry.waiters {
+			waiter.err = fmt.Errorf("waiting for lock %s: %w", key, ErrLockManagerClosed)
+			waiter.ready <- nil
+		}
+		delete(m.locks, key)
+	}
+	return released
+}
+
 // Close 关闭锁管理器
 func (m *InMemoryDistributedLockManager) Close() {
 	close(m.cleanupCh)
 }
 
+func (m *InMemoryDistributedLockManager) nextIDLocked() string {
+	m.sequence++
+	return fmt.Sprintf("%s_%d_%d", m.storeID, time.Now().UnixNano(), m.sequence)
+}
+
+func (m *InMemoryDistributedLockManager) entryLocked(lockKey string) *lockEntry {
+	entry, exists := m.locks[lockKey]
+	if !exists {
+		entry = &lockEntry{}
+		m.locks[lockKey] = entry
+	}
+	return entry
+}
+
+// removeIfIdleLocked 没有持有者和等待者时删除锁key
+func (m *InMemoryDistributedLockManager) removeIfIdleLocked(lockKey string, entry *lockEntry) {
+	if len(entry.holders) == 0 && len(entry.waiters) == 0 && m.locks[lockKey] == entry {
+		delete(m.locks, lockKey)
+	}
+}
+
+// purgeExpiredLocked 移除过期的持有者
+func (m *InMemoryDistributedLockManager) purgeExpiredLocked(lockKey string, entry *lockEntry) {
+	now := time.Now()
+	holders := entry.holders[:0]
+	for _, holder := range entry.holders {
+		if now.After(holder.info.ExpiresAt) {
+			log.Printf("lock %s held by %s expired", lockKey, holder.info.OwnerID)
+			continue
+		}
+		holders = append(holders, holder)
+	}
+	entry.holders = holders
+}
+
+// grantLocked 创建持有者并返回对应的锁
+func (m *InMemoryDistributedLockManager) grantLocked(lockKey string, entry *lockEntry, ownerID string, mode LockMode, ttl time.Duration) *DistributedLock {
+	now := time.Now()
+	holder := &lockHolder{
+		info: LockInfo{
+			LockKey:    lockKey,
+			LockID:     m.nextIDLocked(),
+			OwnerID:    ownerID,
+			StoreID:    m.storeID,
+			Mode:       mode,
+			AcquiredAt: now,
+			ExpiresAt:  now.Add(ttl),
+			IsActive:   true,
+		},
+		ttl:   ttl,
+		count: 1,
+	}
+	entry.holders = append(entry.holders, holder)
+	return m.lockFor(holder)
+}
+
+// grantWaitersLocked 按顺序授予与当前持有者兼容的排队请求，遇到第一个不兼容的请求停止
+func (m *InMemoryDistributedLockManager) grantWaitersLocked(lockKey string, entry *lockEntry) {
+	for len(entry.waiters) > 0 {
+		waiter := entry.waiters[0]
+		if !entry.compatible(waiter.mode) {
+			return
+		}
+		entry.waiters = entry.waiters[1:]
+		waiter.ready <- m.grantLocked(lockKey, entry, waiter.ownerID, waiter.mode, waiter.ttl)
+	}
+}
+
+func (m *InMemoryDistributedLockManager) lockFor(holder *lockHolder) *DistributedLock {
+	return &DistributedLock{
+		LockKey:    holder.info.LockKey,
+		LockID:     holder.info.LockID,
+		OwnerID:    holder.info.OwnerID,
+		StoreID:    holder.info.StoreID,
+		Mode:       holder.info.Mode,
+		AcquiredAt: holder.info.AcquiredAt,
+		ExpiresAt:  holder.info.ExpiresAt,
+		TTL:        holder.ttl,
+		manager:    m,
+	}
+}
+
+// compatible 请求的模式能否与当前持有者同时持有
+func (e *lockEntry) compatible(mode LockMode) bool {
+	if len(e.holders) == 0 {
+		return true
+	}
+	return mode == LockShared && e.holders[0].info.Mode == LockShared
+}
+
+func (e *lockEntry) holder(ownerID string) *lockHolder {
+	for _, holder := range e.holders {
+		if holder.info.OwnerID == ownerID {
+			return holder
+		}
+	}
+	return nil
+}
+
+func (e *lockEntry) removeHolder(target *lockHolder) {
+	for i, holder := range e.holders {
+		if holder == target {
+			e.holders = append(e.holders[:i], e.holders[i+1:]...)
+			return
+		}
+	}
+}
+
+func (e *lockEntry) removeWaiter(target *lockWaiter) {
+	for i, waiter := range e.waiters {
+		if waiter == target {
+			e.waiters = append(e.waiters[:i], e.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// nextExpiry 最早过期的持有者的过期时间
+func (e *lockEntry) nextExpiry() (time.Time, bool) {
+	var earliest time.Time
+	for _, holder := range e.holders {
+		if earliest.IsZero() || holder.info.ExpiresAt.Before(earliest) {
+			earliest = holder.info.ExpiresAt
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// info 返回持有者信息的副本
+func (e *lockEntry) info(holder *lockHolder, now time.Time) *LockInfo {
+	info := holder.info
+	info.IsActive = !now.After(info.ExpiresAt)
+	info.Holders = len(e.holders)
+	info.Waiters = len(e.waiters)
+	info.Reentries = holder.count
+	return &info
+}
+
 // DistributedLock 方法
 
 // Release 释放锁
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// 死锁检测
+// 等待中的获取请求构成所有者之间的等待图：等待者指向与它不兼容的持有者，以及排在它前面、它不能越过的等待者。
+// 新请求排队时和每隔DeadlockDetectionInterval检查一次图中的环，发现环时选择环上最年轻的所有者
+// （持有锁或开始排队最晚的）作为牺牲者，它的所有排队请求以ErrDeadlock失败，已持有的锁由调用方释放后重试。
+// 总是牺牲较年轻的所有者，较老的事务最终能获得全部锁，不会被反复牺牲
+
+// ErrDeadlock 获取锁的请求因死锁被放弃，调用方应释放已持有的锁后重试
+var ErrDeadlock = errors.New("lock wait aborted to break a deadlock")
+
+// DeadlockDetectionInterval 周期性死锁检测的间隔
+var DeadlockDetectionInterval = time.Second
+
+// waitForGraphLocked 构建所有者之间的等待图
+func (m *InMemoryDistributedLockManager) waitForGraphLocked() map[string]map[string]bool {
+	graph := make(map[string]map[string]bool)
+	addEdge := func(from, to string) {
+		if from == to {
+			return
+		}
+		if graph[from] == nil {
+			graph[from] = make(map[string]bool)
+		}
+		graph[from][to] = true
+	}
+
+	for _, entry := range m.locks {
+		for i, waiter := range entry.waiters {
+			for _, holder := range entry.holders {
+				if waiter.mode == LockExclusive || holder.info.Mode == LockExclusive {
+					addEdge(waiter.ownerID, holder.info.OwnerID)
+				}
+			}
+			for _, ahead := range entry.waiters[:i] {
+				if waiter.mode == LockExclusive || ahead.mode == LockExclusive {
+					addEdge(waiter.ownerID, ahead.ownerID)
+				}
+			}
+		}
+	}
+	return graph
+}
+
+// findCycle 返回等待图中的一个环，没有环时返回nil
+func findCycle(graph map[string]map[string]bool) []string {
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int)
+	var path []string
+
+	// 按固定顺序遍历，使检测结果可重现
+	sortedKeys := func(set map[string]bool) []string {
+		keys := make([]string, 0, len(set))
+		for key := range set {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	owners := make(map[string]bool, len(graph))
+	for owner := range graph {
+		owners[owner] = true
+	}
+
+	var visit func(owner string) []string
+	visit = func(owner string) []string {
+		state[owner] = visiting
+		path = append(path, owner)
+		for _, next := range sortedKeys(graph[owner]) {
+			switch state[next] {
+			case visiting:
+				for i, member := range path {
+					if member == next {
+						return append([]string(nil), path[i:]...)
+					}
+				}
+			case unvisited:
+				if cycle := visit(next); cycle != nil {
+					return cycle
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[owner] = done
+		return nil
+	}
+
+	for _, owner := range sortedKeys(owners) {
+		if state[owner] == unvisited {
+			if cycle := visit(owner); cycle != nil {
+				return cycle
+			}
+		}
+	}
+	return nil
+}
+
+// detectDeadlocksLocked 打破等待图中的所有环
+func (m *InMemoryDistributedLockManager) detectDeadlocksLocked() {
+	for {
+		cycle := findCycle(m.waitForGraphLocked())
+		if cycle == nil {
+			return
+		}
+		victim := m.youngestOwnerLocked(cycle)
+		log.Printf("deadlock detected among lock owners %v, aborting %s", cycle, victim)
+		m.abortWaitsLocked(victim, fmt.Errorf("%w: %s waits in a cycle with %v", ErrDeadlock, victim, cycle))
+	}
+}
+
+// youngestOwnerLocked 返回最晚开始持有或等待锁的所有者
+func (m *InMemoryDistributedLockManager) youngestOwnerLocked(owners []string) string {
+	startedAt := make(map[string]time.Time, len(owners))
+	for _, owner := range owners {
+		startedAt[owner] = time.Time{}
+	}
+	observe := func(owner string, at time.Time) {
+		if current, ok := startedAt[owner]; ok && (current.IsZero() || at.Before(current)) {
+			startedAt[owner] = at
+		}
+	}
+	for _, entry := range m.locks {
+		for _, holder := range entry.holders {
+			observe(holder.info.OwnerID, holder.info.AcquiredAt)
+		}
+		for _, waiter := range entry.waiters {
+			observe(waiter.ownerID, waiter.queuedAt)
+		}
+	}
+
+	victim := owners[0]
+	for _, owner := range owners[1:] {
+		if startedAt[owner].After(startedAt[victim]) || (startedAt[owner].Equal(startedAt[victim]) && owner > victim) {
+			victim = owner
+		}
+	}
+	return victim
+}
+
+// abortWaitsLocked 让所有者的全部排队请求以err失败
+func (m *InMemoryDistributedLockManager) abortWaitsLocked(ownerID string, err error) {
+	for key, entry := range m.locks {
+		waiters := entry.waiters[:0]
+		aborted := false
+		for _, waiter := range entry.waiters {
+			if waiter.ownerID != ownerID {
+				waiters = append(waiters, waiter)
+				continue
+			}
+			waiter.err = err
+			waiter.ready <- nil
+			aborted = true
+		}
+		entry.waiters = waiters
+		if aborted {
+			m.grantWaitersLocked(key, entry)
+			m.removeIfIdleLocked(key, entry)
+		}
+	}
+}
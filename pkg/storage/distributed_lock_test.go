@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestLockManager(t *testing.T) *InMemoryDistributedLockManager {
+	t.Helper()
+	manager := NewInMemoryDistributedLockManager("store_a")
+	t.Cleanup(manager.Close)
+	return manager
+}
+
+// acquireAsync 在goroutine中获取锁，结果通过返回的channel传回
+func acquireAsync(ctx context.Context, acquire func(ctx context.Context, lockKey string, ttl time.Duration) (*DistributedLock, error), lockKey string) <-chan *DistributedLock {
+	result := make(chan *DistributedLock, 1)
+	go func() {
+		lock, err := acquire(ctx, lockKey, time.Minute)
+		if err != nil {
+			lock = nil
+		}
+		result <- lock
+	}()
+	return result
+}
+
+// waitForWaiters 等待锁的排队请求数达到n
+func waitForWaiters(t *testing.T, manager *InMemoryDistributedLockManager, lockKey string, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if info, err := manager.GetLockInfo(context.Background(), lockKey); err == nil && info.Waiters == n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Expected %d waiters on %s", n, lockKey)
+}
+
+func expectPending(t *testing.T, result <-chan *DistributedLock) {
+	t.Helper()
+	select {
+	case lock := <-result:
+		t.Fatalf("Expected the request to still be waiting, got %+v", lock)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func expectGranted(t *testing.T, result <-chan *DistributedLock) *DistributedLock {
+	t.Helper()
+	select {
+	case lock := <-result:
+		if lock == nil {
+			t.Fatalf("Expected the lock to be granted")
+		}
+		return lock
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the lock")
+		return nil
+	}
+}
+
+func TestLockManagerWaitQueue(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestLockManager(t)
+
+	writer, err := manager.AcquireLock(ctx, "timeline:conv_a", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+	if _, err := manager.AcquireLock(ctx, "timeline:conv_a", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("Expected the non-blocking acquire to fail with ErrLockHeld, got %v", err)
+	}
+
+	// 写锁释放后排队的读锁一起获得，之后排队的写锁不能插队
+	reader1 := acquireAsync(ctx, manager.AcquireRLock, "timeline:conv_a")
+	waitForWaiters(t, manager, "timeline:conv_a", 1)
+	reader2 := acquireAsync(ctx, manager.AcquireRLock, "timeline:conv_a")
+	waitForWaiters(t, manager, "timeline:conv_a", 2)
+	expectPending(t, reader1)
+
+	if err := writer.Release(ctx); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	r1, r2 := expectGranted(t, reader1), expectGranted(t, reader2)
+	if r1.Mode != LockShared || r1.LockID == r2.LockID {
+		t.Fatalf("Expected two distinct shared holders, got %+v and %+v", r1, r2)
+	}
+	if info, _ := manager.GetLockInfo(ctx, "timeline:conv_a"); info.Holders != 2 || info.Mode != LockShared {
+		t.Fatalf("Expected two shared holders, got %+v", info)
+	}
+
+	// 排队的写锁挡住之后的读锁，保证写锁不会饿死
+	writer2 := acquireAsync(ctx, manager.AcquireWithWait, "timeline:conv_a")
+	waitForWaiters(t, manager, "timeline:conv_a", 1)
+	reader3 := acquireAsync(ctx, manager.AcquireRLock, "timeline:conv_a")
+	waitForWaiters(t, manager, "timeline:conv_a", 2)
+
+	r1.Release(ctx)
+	expectPending(t, writer2)
+	r2.Release(ctx)
+	w2 := expectGranted(t, writer2)
+	expectPending(t, reader3)
+	w2.Release(ctx)
+	expectGranted(t, reader3).Release(ctx)
+
+	if locked, _ := manager.IsLocked(ctx, "timeline:conv_a"); locked {
+		t.Errorf("Expected the lock to be free")
+	}
+	if locks, _ := manager.ListLocks(ctx); len(locks) != 0 {
+		t.Errorf("Expected no locks left, got %d", len(locks))
+	}
+}
+
+func TestLockManagerReentrancy(t *testing.T) {
+	manager := newTestLockManager(t)
+	ctx := WithLockOwner(context.Background(), "txn_1")
+
+	first, err := manager.AcquireWithWait(ctx, "index:conv_a", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+	second, err := manager.AcquireLock(ctx, "index:conv_a", time.Minute)
+	if err != nil || second.LockID != first.LockID {
+		t.Fatalf("Expected the owner to re-enter the lock, got %v", err)
+	}
+	if _, err := manager.AcquireRLock(ctx, "index:conv_a", time.Minute); err != nil {
+		t.Fatalf("Expected the exclusive holder to take the lock shared: %v", err)
+	}
+	if _, err := manager.AcquireLock(context.Background(), "index:conv_a", time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("Expected another owner to be refused, got %v", err)
+	}
+
+	// 释放相同次数后才真正释放
+	for i := 0; i < 3; i++ {
+		if locked, _ := manager.IsLocked(ctx, "index:conv_a"); !locked {
+			t.Fatalf("Expected the lock to be held after %d releases", i)
+		}
+		if err := first.Release(ctx); err != nil {
+			t.Fatalf("Failed to release: %v", err)
+		}
+	}
+	if locked, _ := manager.IsLocked(ctx, "index:conv_a"); locked {
+		t.Errorf("Expected the lock to be released")
+	}
+
+	// 共享锁不能升级为排他锁
+	if _, err := manager.AcquireRLock(ctx, "index:conv_b", time.Minute); err != nil {
+		t.Fatalf("Failed to acquire shared: %v", err)
+	}
+	if _, err := manager.AcquireWithWait(ctx, "index:conv_b", time.Minute); err == nil {
+		t.Errorf("Expected the upgrade to be refused")
+	}
+}
+
+func TestLockManagerWaiterCancelAndExpiry(t *testing.T) {
+	ctx := context.Background()
+	manager := newTestLockManager(t)
+
+	holder, _ := manager.AcquireLock(ctx, "timeline:conv_a", time.Minute)
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := manager.AcquireWithWait(waitCtx, "timeline:conv_a", time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the wait to time out, got %v", err)
+	}
+	if info, _ := manager.GetLockInfo(ctx, "timeline:conv_a"); info.Waiters != 0 {
+		t.Fatalf("Expected the cancelled waiter to leave the queue, got %d", info.Waiters)
+	}
+	holder.Release(ctx)
+
+	// 持有者过期后唤醒等待者
+	if _, err := manager.AcquireLock(ctx, "timeline:conv_b", 30*time.Millisecond); err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+	waiter := acquireAsync(ctx, manager.AcquireWithWait, "timeline:conv_b")
+	if lock := expectGranted(t, waiter); lock.Mode != LockExclusive {
+		t.Errorf("Expected an exclusive lock, got %s", lock.Mode)
+	}
+}
+
+func TestCoordinatorWaitsForConflictingTransaction(t *testing.T) {
+	ctx := context.Background()
+	handler, store, _ := newTestTransactionHandler(t)
+	coordinator := newTestCoordinator(t, handler)
+
+	first, err := coordinator.BeginTransaction(ctx, createTimelineParticipants("conv_a"), time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+
+	// 第二个事务等待第一个事务释放锁，而不是立即失败
+	done := make(chan error, 1)
+	go func() {
+		done <- ExecuteTransaction(ctx, coordinator, createTimelineParticipants("conv_b")[1:], time.Minute)
+	}()
+	second := make(chan error, 1)
+	go func() {
+		second <- ExecuteTransaction(ctx, coordinator, []*TransactionParticipant{{StoreID: "store_a", Operation: OpDeleteTimeline,
+			Params: map[string]interface{}{"timeline_key": "conv_a"}}}, time.Minute)
+	}()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected an unrelated transaction to proceed: %v", err)
+	}
+	select {
+	case err := <-second:
+		t.Fatalf("Expected the conflicting transaction to wait, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	if err := coordinator.PrepareTransaction(ctx, first.TransactionID); err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	if err := coordinator.CommitTransaction(ctx, first.TransactionID); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	select {
+	case err := <-second:
+		if err != nil {
+			t.Fatalf("Expected the waiting transaction to succeed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for the second transaction")
+	}
+	// 删除在创建提交之后执行
+	if _, exists := store.FindTimeline("conv_a"); exists {
+		t.Errorf("Expected the timeline to be deleted after it was created")
+	}
+
+	// 等待超过事务超时时间后失败
+	blocker, _ := coordinator.BeginTransaction(ctx, createTimelineParticipants("conv_c"), time.Minute)
+	defer coordinator.AbortTransaction(ctx, blocker.TransactionID)
+	if _, err := coordinator.BeginTransaction(ctx, createTimelineParticipants("conv_c"), 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected the lock wait to time out, got %v", err)
+	}
+}
+
+func TestLockManagerDetectsDeadlock(t *testing.T) {
+	manager := newTestLockManager(t)
+	older := WithLockOwner(context.Background(), "txn_old")
+	younger := WithLockOwner(context.Background(), "txn_young")
+
+	oldLock, err := manager.AcquireWithWait(older, "timeline:conv_a", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+	time.Sleep(time.Millisecond)
+	youngLock, err := manager.AcquireWithWait(younger, "timeline:conv_b", time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to acquire: %v", err)
+	}
+
+	// 两个所有者按相反顺序获取，较年轻的一方被牺牲
+	oldWait := acquireAsync(older, manager.AcquireWithWait, "timeline:conv_b")
+	waitForWaiters(t, manager, "timeline:conv_b", 1)
+	if _, err := manager.AcquireWithWait(younger, "timeline:conv_a", time.Minute); !errors.Is(err, ErrDeadlock) {
+		t.Fatalf("Expected the younger owner to get ErrDeadlock, got %v", err)
+	}
+	if info, _ := manager.GetLockInfo(context.Background(), "timeline:conv_a"); info.Waiters != 0 || info.OwnerID != "txn_old" {
+		t.Fatalf("Expected the aborted request to leave the queue, got %+v", info)
+	}
+
+	// 牺牲者释放已持有的锁后较老的一方继续
+	expectPending(t, oldWait)
+	if err := youngLock.Release(younger); err != nil {
+		t.Fatalf("Failed to release: %v", err)
+	}
+	expectGranted(t, oldWait).Release(older)
+	oldLock.Release(older)
+
+	// 读锁之间不等待，不构成环
+	manager.AcquireRLock(older, "index:conv_a", time.Minute)
+	manager.AcquireRLock(younger, "index:conv_b", time.Minute)
+	if _, err := manager.AcquireRLock(older, "index:conv_b", time.Minute); err != nil {
+		t.Fatalf("Expected shared locks to be granted: %v", err)
+	}
+	if _, err := manager.AcquireRLock(younger, "index:conv_a", time.Minute); err != nil {
+		t.Fatalf("Expected shared locks to be granted: %v", err)
+	}
+}
@@ -2,7 +2,15 @@ package storage
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 )
 
@@ -16,7 +24,13 @@ type DistributedStorageManager struct {
 	crossStoreAccess *DistributedStoreAccessor
 	lockManager      DistributedLockManager
 	txnCoordinator   TransactionCoordinator
+	shardManager     ShardManager // 为nil时新Timeline按哈希路由放置
+	migrationManager MigrationManager // 下线Store时迁移Timeline
+	leaderElector    LeaderElector    // 设置后只有协调者领导者可以下线Store
 	storeID          string
+
+	decommissionMu sync.Mutex
+	decommissions  map[string]*DecommissionProgress // Store ID -> 最近一次下线的进度
 }
 
 // NewDistributedStorageManager 创建分布式存储管理器
@@ -50,6 +64,9 @@ func NewDistributedStorageManager(
 		storeRegistry,
 	)
 	
+	// 其他Store上的参与者通过RPC调用
+	txnCoordinator.SetRemoteHandler(NewRemoteTransactionParticipant(crossStoreAccess))
+	
 	return &DistributedStorageManager{
 		localStore:       localStore,
 		globalIndex:      globalIndex,
@@ -63,16 +80,82 @@ func NewDistributedStorageManager(
 	}
 }
 
+// SetShardManager 设置分片管理器，新Timeline按它的推荐放置
+func (dsm *DistributedStorageManager) SetShardManager(shardManager ShardManager) {
+	dsm.shardManager = shardManager
+}
+
+// EstimatedTimelineSize 按Timeline类型估计新Timeline的数据大小，用于放置推荐
+func EstimatedTimelineSize(timelineType string) int64 {
+	switch timelineType {
+	case "conversation":
+		return 64 * 1024 * 1024
+	case "user":
+		return 16 * 1024 * 1024
+	default:
+		return 32 * 1024 * 1024
+	}
+}
+
 // CreateTimelineWithTransaction 使用事务创建Timeline
+// 设置了分片管理器时先在推荐的Store上创建，失败后依次尝试备选Store；
+// 推荐失败或没有分片管理器时按哈希路由。Timeline已存在或事务冲突时不再尝试其他Store
 func (dsm *DistributedStorageManager) CreateTimelineWithTransaction(ctx context.Context, timelineKey string, timelineType string) error {
+	if _, err := dsm.globalIndex.GetTimelineLocation(ctx, timelineKey); err == nil {
+		return fmt.Errorf("%w: %s", ErrTimelineExists, timelineKey)
+	}
+	
+	estimatedSize := EstimatedTimelineSize(timelineType)
+	candidates, err := dsm.placementCandidates(ctx, timelineKey, estimatedSize)
+	if err != nil {
+		return err
+	}
+	
+	for i, targetStoreID := range candidates {
+		err = ExecuteTransaction(ctx, dsm.txnCoordinator, dsm.createTimelineParticipants(timelineKey, timelineType, targetStoreID), 30*time.Second)
+		if err == nil {
+			if dsm.shardManager != nil {
+				dsm.shardManager.RecordPlacement(timelineKey, targetStoreID, estimatedSize)
+			}
+			return nil
+		}
+		if ctx.Err() != nil || errors.Is(err, ErrTimelineExists) || errors.Is(err, ErrTransactionConflict) || errors.Is(err, ErrDeadlock) {
+			return err
+		}
+		if i < len(candidates)-1 {
+			log.Printf("failed to create timeline %s on store %s, trying %s: %v", timelineKey, targetStoreID, candidates[i+1], err)
+		}
+	}
+	return err
+}
+
+// placementCandidates 返回按优先级排列的候选Store
+func (dsm *DistributedStorageManager) placementCandidates(ctx context.Context, timelineKey string, estimatedSize int64) ([]string, error) {
+	if dsm.shardManager != nil {
+		rec, err := dsm.shardManager.GetShardRecommendation(ctx, timelineKey, estimatedSize)
+		if err == nil && rec.RecommendedStore != "" {
+			candidates := []string{rec.RecommendedStore}
+			for _, storeID := range rec.Alternatives {
+				if storeID != rec.RecommendedStore {
+					candidates = append(candidates, storeID)
+				}
+			}
+			return candidates, nil
+		}
+		log.Printf("no shard recommendation for timeline %s, routing by hash: %v", timelineKey, err)
+	}
+	
 	// 确定目标Store
 	targetStoreID, err := dsm.routerManager.RouteTimeline(timelineKey)
 	if err != nil {
-		return fmt.Errorf("failed to route timeline: %w", err)
+		return nil, fmt.Errorf("failed to route timeline: %w", err)
 	}
-	
-	// 创建事务参与者
-	participants := []*TransactionParticipant{
+	return []string{targetStoreID}, nil
+}
+
+// createTimelineParticipants 在targetStoreID上创建Timeline并更新全局索引的事务参与者
+func (dsm *DistributedStorageManager) createTimelineParticipants(timelineKey, timelineType, targetStoreID string) []*TransactionParticipant {
+	return []*TransactionParticipant{
 		{
 			StoreID:   targetStoreID,
 			Operation: OpCreateTimeline,
@@ -91,9 +174,6 @@ func (dsm *DistributedStorageManager) CreateTimelineWithTransaction(ctx context.
 			},
 		},
 	}
-	
-	// 执行事务
-	return ExecuteTransaction(ctx, dsm.txnCoordinator, participants, 30*time.Second)
 }
 
 // DeleteTimelineWithTransaction 使用事务删除Timeline
@@ -300,12 +380,54 @@ func (dsm *DistributedStorageManager) Close() error {
 	return nil
 }
 
+// 参与者侧的两阶段提交
+// Prepare校验操作并把要做的修改暂存在内存中的待提交区，同时为涉及的Timeline和索引登记意向，
+// 冲突的事务在Prepare时失败；Commit应用暂存的修改，Abort丢弃。暂存的修改在提交前对读取不可见。
+// 协调者在Prepare之后失联时，暂存超过StagedTimeout的事务按回滚处理并被丢弃（presumed abort），
+// 因此StagedTimeout应大于协调者的事务超时时间。处理器只执行本Store上的操作，远程操作和迁移在Prepare时拒绝。
+// 远程协调者通过RPC调用时可能重试，重复的Prepare和Abort本身是幂等的，已提交的操作在StagedTimeout内记住，
+// 重复的Commit直接成功。经RPC传来的参数按JSON解码，[]byte为base64字符串，数字为json.Number或float64
+
+var (
+	ErrTransactionConflict    = errors.New("transaction conflicts with a prepared transaction")
+	ErrTransactionNotPrepared = errors.New("transaction is not prepared")
+	ErrTimelineExists         = errors.New("timeline already exists")
+)
+
+const defaultStagedTimeout = 2 * time.Minute
+
 // DefaultTransactionHandler 默认事务处理器实现
 type DefaultTransactionHandler struct {
 	localStore    *Store
 	globalIndex   GlobalIndexManager
 	rpcClientPool *StoreRPCClientPool
 	storeID       string
+
+	mu            sync.Mutex
+	staged        map[string]*stagedTransaction // txnID -> 暂存的修改
+	intents       map[string]*stagedIntent      // 意向key -> 持有的事务
+	committed     map[string]time.Time          // txnID/stageKey -> 提交时间，用于幂等的重复提交
+	stagedTimeout time.Duration
+	now           func() time.Time
+}
+
+// stagedTransaction 一个事务在本参与者上暂存的修改
+type stagedTransaction struct {
+	preparedAt time.Time
+	ops        map[string]*stagedOperation // stageKey -> 操作
+}
+
+// stagedOperation 暂存的单个操作，apply在提交时执行
+type stagedOperation struct {
+	intent    string
+	exclusive bool
+	apply     func(ctx context.Context) error
+}
+
+// stagedIntent 意向的持有者，排他意向只能由一个事务持有，共享意向可以由多个事务同时持有
+type stagedIntent struct {
+	exclusive bool
+	txns      map[string]int // txnID -> 持有次数
 }
 
 // NewDefaultTransactionHandler 创建默认事务处理器
@@ -320,145 +442,409 @@ func NewDefaultTransactionHandler(
 		globalIndex:   globalIndex,
 		rpcClientPool: rpcClientPool,
 		storeID:       storeID,
+		staged:        make(map[string]*stagedTransaction),
+		intents:       make(map[string]*stagedIntent),
+		committed:     make(map[string]time.Time),
+		stagedTimeout: defaultStagedTimeout,
+		now:           time.Now,
 	}
 }
 
-// Prepare 准备操作
+// SetStagedTimeout 设置暂存修改的保留时间，超时未提交的事务按回滚处理
+func (h *DefaultTransactionHandler) SetStagedTimeout(timeout time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if timeout > 0 {
+		h.stagedTimeout = timeout
+	}
+}
+
+// PreparedTransactions 返回已准备但尚未提交或回滚的事务ID，供协调者恢复时处理
+func (h *DefaultTransactionHandler) PreparedTransactions() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.abortExpiredLocked()
+
+	txnIDs := make([]string, 0, len(h.staged))
+	for txnID := range h.staged {
+		txnIDs = append(txnIDs, txnID)
+	}
+	sort.Strings(txnIDs)
+	return txnIDs
+}
+
+// AbortExpired 丢弃超过StagedTimeout仍未提交的事务，返回丢弃的事务数
+func (h *DefaultTransactionHandler) AbortExpired() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.abortExpiredLocked()
+}
+
+func (h *DefaultTransactionHandler) abortExpiredLocked() int {
+	now := h.now()
+	for key, committedAt := range h.committed {
+		if now.Sub(committedAt) > h.stagedTimeout {
+			delete(h.committed, key)
+		}
+	}
+
+	expired := 0
+	for txnID, txn := range h.staged {
+		if now.Sub(txn.preparedAt) <= h.stagedTimeout {
+			continue
+		}
+		for key := range txn.ops {
+			h.unstageLocked(txnID, key)
+		}
+		log.Printf("store %s: discarded transaction %s prepared at %s without commit", h.storeID, txnID, txn.preparedAt.Format(time.RFC3339))
+		expired++
+	}
+	return expired
+}
+
+// Prepare 校验操作并暂存修改
 func (h *DefaultTransactionHandler) Prepare(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	if participant.StoreID != h.storeID {
+		return fmt.Errorf("%s on remote store %s is not supported", participant.Operation, participant.StoreID)
+	}
+	key, err := stageKey(participant)
+	if err != nil {
+		return err
+	}
+	op, err := h.stage(ctx, participant)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.abortExpiredLocked()
+
+	txn, exists := h.staged[txnID]
+	if exists {
+		if _, prepared := txn.ops[key]; prepared {
+			// 重复的Prepare以最新的校验结果为准
+			h.unstageLocked(txnID, key)
+		}
+	}
+	if holders, held := h.intents[op.intent]; held {
+		for owner := range holders.txns {
+			if owner != txnID && (op.exclusive || holders.exclusive) {
+				return fmt.Errorf("%w: %s is held by %s", ErrTransactionConflict, op.intent, owner)
+			}
+		}
+	}
+
+	txn, exists = h.staged[txnID]
+	if !exists {
+		txn = &stagedTransaction{preparedAt: h.now(), ops: make(map[string]*stagedOperation)}
+		h.staged[txnID] = txn
+	}
+	txn.ops[key] = op
+
+	holders, held := h.intents[op.intent]
+	if !held {
+		holders = &stagedIntent{txns: make(map[string]int)}
+		h.intents[op.intent] = holders
+	}
+	holders.exclusive = holders.exclusive || op.exclusive
+	holders.txns[txnID]++
+	return nil
+}
+
+// Commit 应用Prepare暂存的修改，应用失败时保留暂存以便协调者回滚
+func (h *DefaultTransactionHandler) Commit(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	key, err := stageKey(participant)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	var op *stagedOperation
+	if txn, exists := h.staged[txnID]; exists {
+		op = txn.ops[key]
+	}
+	_, committed := h.committed[txnID+"/"+key]
+	h.mu.Unlock()
+	if op == nil {
+		if committed {
+			return nil
+		}
+		return fmt.Errorf("%w: %s %s", ErrTransactionNotPrepared, txnID, key)
+	}
+
+	if err := op.apply(ctx); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.unstageLocked(txnID, key)
+	h.committed[txnID+"/"+key] = h.now()
+	h.mu.Unlock()
+	return nil
+}
+
+// Abort 丢弃Prepare暂存的修改，未暂存时不做任何事
+func (h *DefaultTransactionHandler) Abort(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	key, err := stageKey(participant)
+	if err != nil {
+		return nil
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unstageLocked(txnID, key)
+	return nil
+}
+
+// unstageLocked 移除暂存的操作并释放其意向
+func (h *DefaultTransactionHandler) unstageLocked(txnID, key string) {
+	txn, exists := h.staged[txnID]
+	if !exists {
+		return
+	}
+	op, exists := txn.ops[key]
+	if !exists {
+		return
+	}
+	delete(txn.ops, key)
+	if len(txn.ops) == 0 {
+		delete(h.staged, txnID)
+	}
+
+	holders := h.intents[op.intent]
+	if holders == nil {
+		return
+	}
+	if holders.txns[txnID]--; holders.txns[txnID] <= 0 {
+		delete(holders.txns, txnID)
+	}
+	if len(holders.txns) == 0 {
+		delete(h.intents, op.intent)
+	}
+}
+
+// stageKey 标识事务中的一个参与者操作
+func stageKey(participant *TransactionParticipant) (string, error) {
+	name := "timeline_key"
+	if participant.Operation == OpUpdateIndex {
+		name = "index_key"
+	}
+	key, err := stringParam(participant.Params, name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s", participant.Operation, key), nil
+}
+
+// stage 校验操作并生成暂存的修改
+func (h *DefaultTransactionHandler) stage(ctx context.Context, participant *TransactionParticipant) (*stagedOperation, error) {
+	params := participant.Params
 	switch participant.Operation {
 	case OpCreateTimeline:
+		timelineKey, err := stringParam(params, "timeline_key")
+		if err != nil {
+			return nil, err
+		}
+		timelineType, err := stringParam(params, "timeline_type")
+		if err != nil {
+			return nil, err
+		}
 		// 验证Timeline不存在
-		timelineKey := participant.Params["timeline_key"].(string)
-		_, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey)
-		if err == nil {
-			return fmt.Errorf("timeline already exists: %s", timelineKey)
+		if _, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrTimelineExists, timelineKey)
 		}
-		return nil
-		
+		if _, exists := h.localStore.FindTimeline(timelineKey); exists {
+			return nil, fmt.Errorf("%w: %s", ErrTimelineExists, timelineKey)
+		}
+		return &stagedOperation{
+			intent:    "timeline:" + timelineKey,
+			exclusive: true,
+			apply: func(ctx context.Context) error {
+				if timelineType == "conversation" {
+					h.localStore.GetOrCreateConvTimeline(timelineKey)
+				} else {
+					h.localStore.GetOrCreateUserTimeline(timelineKey)
+				}
+				return nil
+			},
+		}, nil
+
 	case OpDeleteTimeline:
-		// 验证Timeline存在
-		timelineKey := participant.Params["timeline_key"].(string)
-		_, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey)
+		timelineKey, err := stringParam(params, "timeline_key")
 		if err != nil {
-			return fmt.Errorf("timeline not found: %s", timelineKey)
+			return nil, err
 		}
-		return nil
-		
-	case OpAddMessage:
 		// 验证Timeline存在
-		timelineKey := participant.Params["timeline_key"].(string)
-		_, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey)
+		if _, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey); err != nil {
+			return nil, fmt.Errorf("timeline not found: %s", timelineKey)
+		}
+		timeline, exists := h.localStore.FindTimeline(timelineKey)
+		if !exists {
+			return nil, fmt.Errorf("timeline not found on store %s: %s", h.storeID, timelineKey)
+		}
+		timelineType := timeline.Type
+		return &stagedOperation{
+			intent:    "timeline:" + timelineKey,
+			exclusive: true,
+			apply: func(ctx context.Context) error {
+				deleted, err := h.localStore.DeleteTimeline(timelineType, timelineKey)
+				if err != nil {
+					return err
+				}
+				if !deleted {
+					return fmt.Errorf("timeline not found: %s", timelineKey)
+				}
+				return nil
+			},
+		}, nil
+
+	case OpAddMessage:
+		timelineKey, err := stringParam(params, "timeline_key")
 		if err != nil {
-			return fmt.Errorf("timeline not found: %s", timelineKey)
+			return nil, err
 		}
-		return nil
-		
-	case OpMigrateTimeline:
-		// 验证迁移条件
-		timelineKey := participant.Params["timeline_key"].(string)
-		_, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey)
+		senderID, err := senderIDParam(params["sender_id"])
 		if err != nil {
-			return fmt.Errorf("timeline not found: %s", timelineKey)
+			return nil, err
 		}
-		return nil
-		
-	case OpUpdateIndex:
-		// 索引更新总是可以准备
-		return nil
-		
-	default:
-		return fmt.Errorf("unsupported operation: %s", participant.Operation)
-	}
-}
-
-// Commit 提交操作
-func (h *DefaultTransactionHandler) Commit(ctx context.Context, txnID string, participant *TransactionParticipant) error {
-	switch participant.Operation {
-	case OpCreateTimeline:
-		timelineKey := participant.Params["timeline_key"].(string)
-		timelineType := participant.Params["timeline_type"].(string)
-		
-		if participant.StoreID == h.storeID {
-			// 本地创建
-			if timelineType == "conversation" {
-				_ = h.localStore.GetOrCreateConvTimeline(timelineKey)
-				return nil
-			} else {
-				_ = h.localStore.GetOrCreateUserTimeline(timelineKey)
-				return nil
-			}
-		} else {
-			// 远程创建
-			client, err := h.rpcClientPool.GetClient(ctx, participant.StoreID, "")
-			if err != nil {
-				return err
-			}
-			// 这里需要实现RPC调用
-			_ = client
-			return fmt.Errorf("remote timeline creation not implemented")
+		data, err := bytesParam(params, "data")
+		if err != nil {
+			return nil, err
 		}
-		
-	case OpDeleteTimeline:
-		// 删除Timeline的实现
-		return fmt.Errorf("timeline deletion not implemented")
-		
-	case OpAddMessage:
-		timelineKey := participant.Params["timeline_key"].(string)
-		senderID := participant.Params["sender_id"].(uint32)
-		data := participant.Params["data"].([]byte)
-		userIDs := participant.Params["user_ids"].([]string)
-		
-		if participant.StoreID == h.storeID {
-			// 本地添加消息
-			return h.localStore.AddMessage(timelineKey, senderID, data, userIDs)
-		} else {
-			// 远程添加消息
-			client, err := h.rpcClientPool.GetClient(ctx, participant.StoreID, "")
-			if err != nil {
-				return err
-			}
-			// 这里需要实现RPC调用
-			_ = client
-			return fmt.Errorf("remote message addition not implemented")
+		userIDs, err := stringsParam(params, "user_ids")
+		if err != nil {
+			return nil, err
 		}
-		
-	case OpMigrateTimeline:
-		// Timeline迁移的实现
-		return fmt.Errorf("timeline migration not implemented")
-		
+		// 验证Timeline存在
+		if _, err := h.globalIndex.GetTimelineLocation(ctx, timelineKey); err != nil {
+			return nil, fmt.Errorf("timeline not found: %s", timelineKey)
+		}
+		// 暂存消息副本，调用方之后修改参数不影响提交的内容
+		data = append([]byte(nil), data...)
+		userIDs = append([]string(nil), userIDs...)
+		return &stagedOperation{
+			intent: "timeline:" + timelineKey,
+			apply: func(ctx context.Context) error {
+				return h.localStore.AddMessage(timelineKey, senderID, data, userIDs)
+			},
+		}, nil
+
 	case OpUpdateIndex:
-		indexKey := participant.Params["index_key"].(string)
-		operation := participant.Params["operation"].(string)
-		
+		indexKey, err := stringParam(params, "index_key")
+		if err != nil {
+			return nil, err
+		}
+		operation, err := stringParam(params, "operation")
+		if err != nil {
+			return nil, err
+		}
+		_, locateErr := h.globalIndex.GetTimelineLocation(ctx, indexKey)
+		op := &stagedOperation{intent: "index:" + indexKey, exclusive: true}
+
 		switch operation {
 		case "add":
-			targetStore := participant.Params["target_store"].(string)
-			return h.globalIndex.AddIndex(ctx, &GlobalStoreIndex{
-				TimelineKey: indexKey,
-				StoreID:     targetStore,
-				BlockID:     fmt.Sprintf("%s_block_1", indexKey),
-				Offset:      0,
-				Size:        0,
-				CreatedAt:   time.Now(),
-				UpdatedAt:   time.Now(),
-			})
+			targetStore, err := stringParam(params, "target_store")
+			if err != nil {
+				return nil, err
+			}
+			if locateErr == nil {
+				return nil, fmt.Errorf("index already exists: %s", indexKey)
+			}
+			op.apply = func(ctx context.Context) error {
+				now := time.Now()
+				return h.globalIndex.AddIndex(ctx, &GlobalStoreIndex{
+					TimelineKey: indexKey,
+					StoreID:     targetStore,
+					BlockID:     fmt.Sprintf("%s_block_1", indexKey),
+					CreatedAt:   now,
+					UpdatedAt:   now,
+				})
+			}
 		case "remove":
-			return h.globalIndex.RemoveIndex(ctx, indexKey, "")
-		case "migrate":
-			// 迁移索引更新的实现
-			return fmt.Errorf("index migration not implemented")
+			if locateErr != nil {
+				return nil, fmt.Errorf("index not found: %s", indexKey)
+			}
+			op.apply = func(ctx context.Context) error {
+				return h.globalIndex.RemoveIndex(ctx, indexKey, "")
+			}
 		default:
-			return fmt.Errorf("unsupported index operation: %s", operation)
+			return nil, fmt.Errorf("unsupported index operation: %s", operation)
 		}
-		
+		return op, nil
+
+	case OpMigrateTimeline:
+		return nil, fmt.Errorf("timeline migration is not supported in transactions")
+
 	default:
-		return fmt.Errorf("unsupported operation: %s", participant.Operation)
+		return nil, fmt.Errorf("unsupported operation: %s", participant.Operation)
 	}
 }
 
-// Abort 回滚操作
-func (h *DefaultTransactionHandler) Abort(ctx context.Context, txnID string, participant *TransactionParticipant) error {
-	// 大多数情况下，准备阶段的操作是只读的，不需要回滚
-	// 如果有需要清理的资源，在这里实现
-	return nil
-}
\ No newline at end of file
+// stringParam 读取字符串参数
+func stringParam(params map[string]interface{}, name string) (string, error) {
+	value, ok := params[name].(string)
+	if !ok || value == "" {
+		return "", fmt.Errorf("missing %s", name)
+	}
+	return value, nil
+}
+
+// bytesParam 读取字节参数，经RPC传来的为base64字符串
+func bytesParam(params map[string]interface{}, name string) ([]byte, error) {
+	switch v := params[name].(type) {
+	case []byte:
+		return v, nil
+	case string:
+		if data, err := base64.StdEncoding.DecodeString(v); err == nil {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid %s", name)
+}
+
+// stringsParam 读取字符串列表参数，参数不存在时返回nil
+func stringsParam(params map[string]interface{}, name string) ([]string, error) {
+	switch v := params[name].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			str, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s", name)
+			}
+			values[i] = str
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("invalid %s", name)
+}
+
+// senderIDParam 解析发送者ID，接受数字或十进制字符串
+func senderIDParam(value interface{}) (uint32, error) {
+	switch v := value.(type) {
+	case uint32:
+		return v, nil
+	case int:
+		if v >= 0 && v <= math.MaxUint32 {
+			return uint32(v), nil
+		}
+	case float64:
+		if v >= 0 && v <= math.MaxUint32 && v == math.Trunc(v) {
+			return uint32(v), nil
+		}
+	case json.Number:
+		if id, err := strconv.ParseUint(v.String(), 10, 32); err == nil {
+			return uint32(id), nil
+		}
+	case string:
+		if id, err := strconv.ParseUint(v, 10, 32); err == nil {
+			return uint32(id), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid sender_id: %v", value)
+}
@@ -0,0 +1,246 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestTransactionHandler(t *testing.T) (*DefaultTransactionHandler, *Store, *InMemoryGlobalIndex) {
+	t.Helper()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	globalIndex := NewInMemoryGlobalIndex()
+	return NewDefaultTransactionHandler(store, globalIndex, nil, "store_a"), store, globalIndex
+}
+
+// newTestCoordinator 创建协调者，每个协调者有独立的锁管理器，模拟协调者进程
+func newTestCoordinator(t *testing.T, handler TransactionParticipantHandler) *InMemoryTransactionCoordinator {
+	t.Helper()
+	lockManager := NewInMemoryDistributedLockManager("store_a")
+	coordinator := NewInMemoryTransactionCoordinator("store_a", lockManager)
+	coordinator.RegisterHandler("store_a", handler)
+	t.Cleanup(func() {
+		coordinator.Close()
+		lockManager.Close()
+	})
+	return coordinator
+}
+
+func createTimelineParticipants(timelineKey string) []*TransactionParticipant {
+	return []*TransactionParticipant{
+		{StoreID: "store_a", Operation: OpCreateTimeline, Params: map[string]interface{}{"timeline_key": timelineKey, "timeline_type": "conversation"}},
+		{StoreID: "store_a", Operation: OpUpdateIndex, Params: map[string]interface{}{"index_key": timelineKey, "target_store": "store_a", "operation": "add"}},
+	}
+}
+
+func TestTransactionHandlerStagesUntilCommit(t *testing.T) {
+	ctx := context.Background()
+	handler, store, globalIndex := newTestTransactionHandler(t)
+	coordinator := newTestCoordinator(t, handler)
+
+	txn, err := coordinator.BeginTransaction(ctx, createTimelineParticipants("conv_a"), time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to begin: %v", err)
+	}
+	if err := coordinator.PrepareTransaction(ctx, txn.TransactionID); err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	// 准备后修改不可见
+	if _, exists := store.FindTimeline("conv_a"); exists {
+		t.Fatalf("Expected the prepared timeline to be invisible")
+	}
+	if _, err := globalIndex.GetTimelineLocation(ctx, "conv_a"); err == nil {
+		t.Fatalf("Expected the prepared index entry to be invisible")
+	}
+
+	if err := coordinator.CommitTransaction(ctx, txn.TransactionID); err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	if _, exists := store.FindTimeline("conv_a"); !exists {
+		t.Errorf("Expected the timeline after commit")
+	}
+	if _, err := globalIndex.GetTimelineLocation(ctx, "conv_a"); err != nil {
+		t.Errorf("Expected the index entry after commit: %v", err)
+	}
+	if prepared := handler.PreparedTransactions(); len(prepared) != 0 {
+		t.Errorf("Expected nothing staged after commit, got %v", prepared)
+	}
+
+	// 回滚丢弃暂存的消息，发送者ID可以是字符串
+	addMessage := []*TransactionParticipant{{StoreID: "store_a", Operation: OpAddMessage, Params: map[string]interface{}{
+		"timeline_key": "conv_a", "sender_id": "42", "data": []byte("hello"), "user_ids": []string{"u1"},
+	}}}
+	txn, _ = coordinator.BeginTransaction(ctx, addMessage, time.Minute)
+	if err := coordinator.PrepareTransaction(ctx, txn.TransactionID); err != nil {
+		t.Fatalf("Failed to prepare message: %v", err)
+	}
+	if err := coordinator.AbortTransaction(ctx, txn.TransactionID); err != nil {
+		t.Fatalf("Failed to abort: %v", err)
+	}
+	if messages, _ := store.GetConvMessages("conv_a", 10, 0); len(messages) != 0 {
+		t.Errorf("Expected the aborted message to be discarded, got %d", len(messages))
+	}
+	if err := ExecuteTransaction(ctx, coordinator, addMessage, time.Minute); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if messages, _ := store.GetConvMessages("conv_a", 10, 0); len(messages) != 1 || messages[0].SenderID != 42 {
+		t.Errorf("Expected the committed message, got %+v", messages)
+	}
+
+	// 远程操作在准备阶段拒绝
+	remote := []*TransactionParticipant{{StoreID: "store_b", Operation: OpCreateTimeline, Params: map[string]interface{}{"timeline_key": "conv_b", "timeline_type": "conversation"}}}
+	if err := handler.Prepare(ctx, "txn_remote", remote[0]); err == nil {
+		t.Errorf("Expected a remote operation to be rejected in prepare")
+	}
+}
+
+func TestTransactionHandlerCoordinatorFailure(t *testing.T) {
+	ctx := context.Background()
+	handler, store, globalIndex := newTestTransactionHandler(t)
+	now := time.Now()
+	handler.now = func() time.Time { return now }
+	handler.SetStagedTimeout(time.Minute)
+
+	// 协调者在准备后、提交前宕机
+	crashed := newTestCoordinator(t, handler)
+	txn, _ := crashed.BeginTransaction(ctx, createTimelineParticipants("conv_a"), 30*time.Second)
+	if err := crashed.PrepareTransaction(ctx, txn.TransactionID); err != nil {
+		t.Fatalf("Failed to prepare: %v", err)
+	}
+	if prepared := handler.PreparedTransactions(); len(prepared) != 1 || prepared[0] != txn.TransactionID {
+		t.Fatalf("Expected the in-doubt transaction, got %v", prepared)
+	}
+
+	// 新协调者的冲突事务在准备阶段失败，已暂存的修改仍不可见
+	recovered := newTestCoordinator(t, handler)
+	err := ExecuteTransaction(ctx, recovered, createTimelineParticipants("conv_a"), 30*time.Second)
+	if !errors.Is(err, ErrTransactionConflict) {
+		t.Fatalf("Expected a conflict with the in-doubt transaction, got %v", err)
+	}
+	if _, exists := store.FindTimeline("conv_a"); exists {
+		t.Fatalf("Expected no partial effects from the in-doubt transaction")
+	}
+	if prepared := handler.PreparedTransactions(); len(prepared) != 1 {
+		t.Fatalf("Expected the failed transaction's abort to keep the in-doubt one, got %v", prepared)
+	}
+
+	// 超时后按回滚处理，迟到的提交失败
+	now = now.Add(2 * time.Minute)
+	if err := ExecuteTransaction(ctx, recovered, createTimelineParticipants("conv_a"), 30*time.Second); err != nil {
+		t.Fatalf("Expected the retry to succeed after the staged transaction expired: %v", err)
+	}
+	if err := handler.Commit(ctx, txn.TransactionID, txn.Participants[0]); !errors.Is(err, ErrTransactionNotPrepared) {
+		t.Errorf("Expected the late commit to be refused, got %v", err)
+	}
+	if _, err := globalIndex.GetTimelineLocation(ctx, "conv_a"); err != nil {
+		t.Errorf("Expected the retried transaction's index entry: %v", err)
+	}
+}
+
+// fixedShardManager 总是返回固定推荐的分片管理器
+type fixedShardManager struct {
+	ShardManager
+	recommendation *ShardRecommendation
+	err            error
+	estimatedSize  int64
+	placed         map[string]string
+}
+
+func (f *fixedShardManager) GetShardRecommendation(ctx context.Context, timelineKey string, estimatedSize int64) (*ShardRecommendation, error) {
+	f.estimatedSize = estimatedSize
+	return f.recommendation, f.err
+}
+
+func (f *fixedShardManager) RecordPlacement(timelineKey string, storeID string, estimatedSize int64) {
+	f.placed[timelineKey] = storeID
+}
+
+// refusingHandler 拒绝所有准备请求的参与者，模拟不可用的Store
+type refusingHandler struct{}
+
+func (refusingHandler) Prepare(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	return errors.New("store unavailable")
+}
+
+func (refusingHandler) Commit(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	return nil
+}
+
+func (refusingHandler) Abort(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	return nil
+}
+
+func TestCreateTimelineFollowsShardRecommendation(t *testing.T) {
+	ctx := context.Background()
+	handler, store, globalIndex := newTestTransactionHandler(t)
+	routerManager := NewRouterManager()
+	router := NewConsistentHashRouter(1, 10, 0.8)
+	router.AddStore(&StoreInfo{ID: "store_c"})
+	routerManager.RegisterRouter("hash", router)
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	pool := NewStoreRPCClientPool(time.Second)
+	defer pool.Close()
+
+	dsm := NewDistributedStorageManager(store, globalIndex, routerManager, registry, pool, "store_a")
+	defer dsm.Close()
+	dsm.RegisterTransactionHandler("store_a", handler)
+	dsm.RegisterTransactionHandler("store_b", refusingHandler{})
+	shards := &fixedShardManager{
+		recommendation: &ShardRecommendation{RecommendedStore: "store_b", Alternatives: []string{"store_a"}},
+		placed:         make(map[string]string),
+	}
+	dsm.SetShardManager(shards)
+
+	// 推荐的Store失败后使用备选Store，并记录实际放置的Store
+	if err := dsm.CreateTimelineWithTransaction(ctx, "conv_a", "conversation"); err != nil {
+		t.Fatalf("Expected the alternative store to be used: %v", err)
+	}
+	if shards.estimatedSize != EstimatedTimelineSize("conversation") {
+		t.Errorf("Expected the conversation size estimate, got %d", shards.estimatedSize)
+	}
+	if shards.placed["conv_a"] != "store_a" {
+		t.Errorf("Expected the placement on store_a to be recorded, got %v", shards.placed)
+	}
+	if _, exists := store.FindTimeline("conv_a"); !exists {
+		t.Errorf("Expected the timeline on store_a")
+	}
+
+	// 已存在的Timeline不尝试其他Store
+	if err := dsm.CreateTimelineWithTransaction(ctx, "conv_a", "conversation"); !errors.Is(err, ErrTimelineExists) {
+		t.Errorf("Expected ErrTimelineExists, got %v", err)
+	}
+
+	// 没有推荐时按哈希路由
+	shards.err = errors.New("all stores are overloaded")
+	if err := dsm.CreateTimelineWithTransaction(ctx, "conv_b", "conversation"); err == nil {
+		t.Fatalf("Expected the hash-routed store without a handler to fail")
+	}
+	if _, exists := shards.placed["conv_b"]; exists {
+		t.Errorf("Expected no placement recorded for a failed creation")
+	}
+}
+
+func TestShardManagerRecordsPlacements(t *testing.T) {
+	ctx := context.Background()
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: "store_a"})
+	shards := NewTimelineShardManager(NewInMemoryGlobalIndex(), registry, NewRouterManager(), nil)
+
+	shards.RecordPlacement("conv_a", "store_a", 100)
+	shards.RecordPlacement("conv_b", "store_a", 50)
+	stats, err := shards.GetShardStats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	placed := stats.StoreStats["store_a"]
+	if placed == nil || placed.Placements != 2 || placed.PlacedSize != 150 || placed.LastPlacement == nil {
+		t.Errorf("Expected the recorded placements in the stats, got %+v", placed)
+	}
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 )
@@ -82,6 +83,7 @@ type DistributedTransaction struct {
 	UpdatedAt     time.Time                 `json:"updated_at"`
 	Timeout       time.Duration             `json:"timeout"`
 	Locks         []string                  `json:"locks"`
+	heldLocks     []*DistributedLock // 事务持有的锁，提交或回滚时释放
 	mu            sync.RWMutex
 }
 
@@ -115,6 +117,7 @@ type TransactionParticipantHandler interface {
 type InMemoryTransactionCoordinator struct {
 	transactions map[string]*DistributedTransaction
 	handlers     map[string]TransactionParticipantHandler
+	remote       TransactionParticipantHandler // 没有注册本地处理器的Store使用的处理器
 	lockManager  DistributedLockManager
 	storeID      string
 	mu           sync.RWMutex
@@ -144,11 +147,27 @@ func (c *InMemoryTransactionCoordinator) RegisterHandler(storeID string, handler
 	c.handlers[storeID] = handler
 }
 
-// BeginTransaction 开始分布式事务
-func (c *InMemoryTransactionCoordinator) BeginTransaction(ctx context.Context, participants []*TransactionParticipant, timeout time.Duration) (*DistributedTransaction, error) {
+// SetRemoteHandler 设置没有注册本地处理器的Store使用的处理器，通常为RemoteTransactionParticipant
+func (c *InMemoryTransactionCoordinator) SetRemoteHandler(handler TransactionParticipantHandler) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	
+	c.remote = handler
+}
+
+// handlerFor 获取Store的参与者处理器，优先使用本地注册的处理器
+func (c *InMemoryTransactionCoordinator) handlerFor(storeID string) (TransactionParticipantHandler, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if handler, exists := c.handlers[storeID]; exists {
+		return handler, true
+	}
+	return c.remote, c.remote != nil
+}
+
+// BeginTransaction 开始分布式事务
+// 锁按固定顺序排队获取，被其他事务持有时最多等待事务超时时间，等待期间不阻塞协调者的其他操作。
+// 与其他锁持有者形成死锁时返回的错误可用errors.Is与ErrDeadlock比较，已获取的锁会被释放，调用方可以重试
+func (c *InMemoryTransactionCoordinator) BeginTransaction(ctx context.Context, participants []*TransactionParticipant, timeout time.Duration) (*DistributedTransaction, error) {
 	// 生成事务ID
 	txnID := fmt.Sprintf("%s_%d", c.storeID, time.Now().UnixNano())
 	
@@ -170,18 +189,23 @@ func (c *InMemoryTransactionCoordinator) BeginTransaction(ctx context.Context, p
 	}
 	
 	// 获取必要的锁
+	lockCtx, cancel := context.WithTimeout(WithLockOwner(ctx, txnID), timeout)
+	defer cancel()
 	lockKeys := c.generateLockKeys(participants)
 	for _, lockKey := range lockKeys {
-		lock, err := c.lockManager.AcquireLock(ctx, lockKey, timeout)
+		lock, err := c.lockManager.AcquireWithWait(lockCtx, lockKey, timeout)
 		if err != nil {
 			// 释放已获取的锁
-			c.releaseLocks(ctx, txn.Locks)
+			c.releaseLocks(ctx, txn.heldLocks)
 			return nil, fmt.Errorf("failed to acquire lock %s: %w", lockKey, err)
 		}
 		txn.Locks = append(txn.Locks, lock.LockKey)
+		txn.heldLocks = append(txn.heldLocks, lock)
 	}
 	
+	c.mu.Lock()
 	c.transactions[txnID] = txn
+	c.mu.Unlock()
 	return txn, nil
 }
 
@@ -210,11 +234,11 @@ func (c *InMemoryTransactionCoordinator) PrepareTransaction(ctx context.Context,
 	
 	// 对所有参与者执行准备操作
 	for _, participant := range txn.Participants {
-		handler, exists := c.handlers[participant.StoreID]
+		handler, exists := c.handlerFor(participant.StoreID)
 		if !exists {
 			participant.Status = TransactionStatusAborted
 			participant.Error = fmt.Sprintf("handler not found for store %s", participant.StoreID)
-			continue
+			return fmt.Errorf("prepare failed for participant %s: %s", participant.StoreID, participant.Error)
 		}
 		
 		if err := handler.Prepare(ctx, txnID, participant); err != nil {
@@ -255,7 +279,7 @@ func (c *InMemoryTransactionCoordinator) CommitTransaction(ctx context.Context,
 			continue
 		}
 		
-		handler, exists := c.handlers[participant.StoreID]
+		handler, exists := c.handlerFor(participant.StoreID)
 		if !exists {
 			commitErrors = append(commitErrors, fmt.Errorf("handler not found for store %s", participant.StoreID))
 			continue
@@ -278,8 +302,9 @@ func (c *InMemoryTransactionCoordinator) CommitTransaction(ctx context.Context,
 	txn.UpdatedAt = time.Now()
 	
 	// 释放锁
-	c.releaseLocks(ctx, txn.Locks)
+	c.releaseLocks(ctx, txn.heldLocks)
 	txn.Locks = nil
+	txn.heldLocks = nil
 	
 	return nil
 }
@@ -307,7 +332,7 @@ func (c *InMemoryTransactionCoordinator) AbortTransaction(ctx context.Context, t
 			continue
 		}
 		
-		handler, exists := c.handlers[participant.StoreID]
+		handler, exists := c.handlerFor(participant.StoreID)
 		if !exists {
 			continue
 		}
@@ -323,8 +348,9 @@ func (c *InMemoryTransactionCoordinator) AbortTransaction(ctx context.Context, t
 	txn.UpdatedAt = time.Now()
 	
 	// 释放锁
-	c.releaseLocks(ctx, txn.Locks)
+	c.releaseLocks(ctx, txn.heldLocks)
 	txn.Locks = nil
+	txn.heldLocks = nil
 	
 	return nil
 }
@@ -339,10 +365,32 @@ func (c *InMemoryTransactionCoordinator) GetTransactionStatus(ctx context.Contex
 		return nil, fmt.Errorf("transaction not found: %s", txnID)
 	}
 	
+	return txn.snapshot(), nil
+}
+
+// ListActiveTransactions 列出未结束（pending/prepared）的事务，按创建时间排序
+func (c *InMemoryTransactionCoordinator) ListActiveTransactions(ctx context.Context) ([]*DistributedTransaction, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	
+	result := make([]*DistributedTransaction, 0)
+	for _, txn := range c.transactions {
+		snapshot := txn.snapshot()
+		if snapshot.Status == TransactionStatusPending || snapshot.Status == TransactionStatusPrepared {
+			result = append(result, snapshot)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+// snapshot 返回事务的副本
+func (txn *DistributedTransaction) snapshot() *DistributedTransaction {
 	txn.mu.RLock()
 	defer txn.mu.RUnlock()
 	
-	// 返回事务的副本
 	participants := make([]*TransactionParticipant, len(txn.Participants))
 	for i, p := range txn.Participants {
 		participants[i] = &TransactionParticipant{
@@ -363,7 +411,7 @@ func (c *InMemoryTransactionCoordinator) GetTransactionStatus(ctx context.Contex
 		UpdatedAt:     txn.UpdatedAt,
 		Timeout:       txn.Timeout,
 		Locks:         append([]string(nil), txn.Locks...),
-	}, nil
+	}
 }
 
 // CleanupTimeoutTransactions 清理超时事务
@@ -423,27 +471,17 @@ func (c *InMemoryTransactionCoordinator) generateLockKeys(participants []*Transa
 	for key := range lockKeySet {
 		lockKeys = append(lockKeys, key)
 	}
+	// 所有事务按相同顺序获取锁，避免互相等待造成死锁
+	sort.Strings(lockKeys)
 	
 	return lockKeys
 }
 
 // releaseLocks 释放锁
-func (c *InMemoryTransactionCoordinator) releaseLocks(ctx context.Context, lockKeys []string) {
-	for _, lockKey := range lockKeys {
-		lockInfo, err := c.lockManager.GetLockInfo(ctx, lockKey)
-		if err != nil {
-			continue
-		}
-		
-		lock := &DistributedLock{
-			LockKey: lockInfo.LockKey,
-			LockID:  lockInfo.LockID,
-			OwnerID: lockInfo.OwnerID,
-			StoreID: lockInfo.StoreID,
-		}
-		
+func (c *InMemoryTransactionCoordinator) releaseLocks(ctx context.Context, locks []*DistributedLock) {
+	for _, lock := range locks {
 		if err := c.lockManager.ReleaseLock(ctx, lock); err != nil {
-			fmt.Printf("Warning: failed to release lock %s: %v\n", lockKey, err)
+			fmt.Printf("Warning: failed to release lock %s: %v\n", lock.LockKey, err)
 		}
 	}
 }
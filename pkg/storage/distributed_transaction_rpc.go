@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"imy/pkg/errs"
+)
+
+// 远程事务参与者
+// 协调者对没有注册本地处理器的Store，通过Store RPC把Prepare/Commit/Abort发给参与者所在的Store，
+// 由对方注册的参与者处理器执行。每次调用有独立的超时，请求未送达或没有响应时按退避时间重试；
+// 参与者处理器对重复的Prepare、Commit和Abort都是幂等的，重试不会重复应用修改。
+// 参与者明确拒绝（如冲突、参数错误）时不重试，错误可用errors.Is与ErrTransactionConflict等比较
+
+// StoreTransactionParticipant 支持事务参与者请求的RPC客户端接口
+type StoreTransactionParticipant interface {
+	PrepareTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error)
+	CommitTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error)
+	AbortTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error)
+}
+
+// DefaultRemoteTransactionTimeout 单次远程事务调用的默认超时
+const DefaultRemoteTransactionTimeout = 5 * time.Second
+
+// RemoteTransactionParticipant 通过Store RPC调用远程Store上的事务参与者处理器
+type RemoteTransactionParticipant struct {
+	clients func(ctx context.Context, storeID string) (StoreRPCClient, error)
+	timeout time.Duration
+	retry   RetryPolicy
+}
+
+// NewRemoteTransactionParticipant 创建远程参与者，通过accessor查找Store地址并获取RPC客户端
+func NewRemoteTransactionParticipant(accessor *DistributedStoreAccessor) *RemoteTransactionParticipant {
+	return &RemoteTransactionParticipant{
+		clients: accessor.getRemoteClient,
+		timeout: DefaultRemoteTransactionTimeout,
+		retry: RetryPolicy{
+			MaxRetries:     3,
+			InitialBackoff: 100 * time.Millisecond,
+			MaxBackoff:     2 * time.Second,
+			Multiplier:     2,
+			Jitter:         0.5,
+		},
+	}
+}
+
+// SetTimeout 设置单次调用的超时，包括重试在内的总时长受ctx限制
+func (r *RemoteTransactionParticipant) SetTimeout(timeout time.Duration) {
+	r.timeout = timeout
+}
+
+// SetRetryPolicy 设置调用失败时的重试次数与退避时间，重试预算相关的字段不使用
+func (r *RemoteTransactionParticipant) SetRetryPolicy(policy RetryPolicy) {
+	r.retry = policy
+}
+
+// Prepare 请求参与者准备操作
+func (r *RemoteTransactionParticipant) Prepare(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	return r.call(ctx, MethodPrepareTransaction, txnID, participant)
+}
+
+// Commit 请求参与者提交操作
+func (r *RemoteTransactionParticipant) Commit(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	return r.call(ctx, MethodCommitTransaction, txnID, participant)
+}
+
+// Abort 请求参与者回滚操作
+func (r *RemoteTransactionParticipant) Abort(ctx context.Context, txnID string, participant *TransactionParticipant) error {
+	return r.call(ctx, MethodAbortTransaction, txnID, participant)
+}
+
+// call 发送请求，未送达或没有响应时重试，参与者的拒绝转换为RPCError
+func (r *RemoteTransactionParticipant) call(ctx context.Context, method, txnID string, participant *TransactionParticipant) error {
+	req := &TransactionRequest{TransactionID: txnID, Participant: participant}
+	for attempt := 0; ; attempt++ {
+		resp, err := r.attempt(ctx, method, req)
+		if err == nil {
+			if !resp.Success {
+				return fmt.Errorf("store %s rejected %s: %w", participant.StoreID, method, NewRPCError(resp.Code, resp.Error))
+			}
+			return nil
+		}
+		if attempt >= r.retry.MaxRetries || !retryableTransactionError(ctx, err) {
+			return fmt.Errorf("%s on store %s failed: %w", method, participant.StoreID, err)
+		}
+
+		timer := time.NewTimer(r.retry.backoff(attempt + 1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%s on store %s failed: %w", method, participant.StoreID, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt 发送一次请求
+func (r *RemoteTransactionParticipant) attempt(ctx context.Context, method string, req *TransactionRequest) (*TransactionResponse, error) {
+	client, err := r.clients(ctx, req.Participant.StoreID)
+	if err != nil {
+		return nil, err
+	}
+	participant, ok := client.(StoreTransactionParticipant)
+	if !ok {
+		return &TransactionResponse{Code: ErrCodeMethodNotFound, Error: "rpc client does not support transactions"}, nil
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+	switch method {
+	case MethodPrepareTransaction:
+		return participant.PrepareTransaction(ctx, req)
+	case MethodCommitTransaction:
+		return participant.CommitTransaction(ctx, req)
+	default:
+		return participant.AbortTransaction(ctx, req)
+	}
+}
+
+// retryableTransactionError 调用失败后重试是否可能成功，调用方取消、熔断器打开以及错误码明确不可重试的错误不重试
+func retryableTransactionError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrCircuitBreakerOpen) {
+		return false
+	}
+	// 没有错误码的是连接失败等传输错误
+	code := errs.CodeOf(err)
+	return code == errs.Unknown || errs.Retryable(code)
+}
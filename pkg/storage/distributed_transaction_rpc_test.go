@@ -0,0 +1,223 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// startTransactionParticipant 通过指定传输方式暴露参与者Store，返回注册到registry的地址
+func startTransactionParticipant(t *testing.T, transport RPCTransport, store *Store, handler TransactionParticipantHandler) string {
+	t.Helper()
+	if transport == TransportHTTP {
+		server := NewHTTPStoreRPCServer(store)
+		server.SetTransactionHandler(handler)
+		ts := httptest.NewServer(server.Handler())
+		t.Cleanup(ts.Close)
+		return ts.URL
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(store)
+	server.SetTransactionHandler(handler)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	t.Cleanup(func() { server.Stop(context.Background()) })
+	return address
+}
+
+func TestRemoteTransactionParticipantOverRPC(t *testing.T) {
+	for _, transport := range []RPCTransport{TransportHTTP, TransportGRPC} {
+		t.Run(string(transport), func(t *testing.T) {
+			ctx := context.Background()
+			local, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("Failed to create local store: %v", err)
+			}
+			defer local.Close()
+			remote, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+			if err != nil {
+				t.Fatalf("Failed to create remote store: %v", err)
+			}
+			defer remote.Close()
+
+			globalIndex := NewInMemoryGlobalIndex()
+			remoteHandler := NewDefaultTransactionHandler(remote, globalIndex, nil, remote.StoreID)
+			address := startTransactionParticipant(t, transport, remote, remoteHandler)
+
+			registry := NewInMemoryRegistry()
+			defer registry.Close()
+			registry.Register(ctx, &StoreInfo{ID: remote.StoreID, Address: address})
+			pool := NewStoreRPCClientPoolWithTransport(transport, 5*time.Second)
+			defer pool.Close()
+			accessor := NewDistributedStoreAccessor(local, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+
+			lockManager := NewInMemoryDistributedLockManager(local.StoreID)
+			defer lockManager.Close()
+			coordinator := NewInMemoryTransactionCoordinator(local.StoreID, lockManager)
+			defer coordinator.Close()
+			coordinator.RegisterHandler(local.StoreID, NewDefaultTransactionHandler(local, globalIndex, nil, local.StoreID))
+			participant := NewRemoteTransactionParticipant(accessor)
+			coordinator.SetRemoteHandler(participant)
+
+			// 远程Store上创建Timeline，本地更新索引
+			participants := []*TransactionParticipant{
+				{StoreID: remote.StoreID, Operation: OpCreateTimeline, Params: map[string]interface{}{"timeline_key": "conv_remote", "timeline_type": "conversation"}},
+				{StoreID: local.StoreID, Operation: OpUpdateIndex, Params: map[string]interface{}{"index_key": "conv_remote", "target_store": remote.StoreID, "operation": "add"}},
+			}
+			txn, err := coordinator.BeginTransaction(ctx, participants, time.Minute)
+			if err != nil {
+				t.Fatalf("Failed to begin: %v", err)
+			}
+			if err := coordinator.PrepareTransaction(ctx, txn.TransactionID); err != nil {
+				t.Fatalf("Failed to prepare: %v", err)
+			}
+			if _, exists := remote.FindTimeline("conv_remote"); exists {
+				t.Fatalf("Expected the prepared timeline to be invisible on the remote store")
+			}
+			if prepared := remoteHandler.PreparedTransactions(); len(prepared) != 1 || prepared[0] != txn.TransactionID {
+				t.Fatalf("Expected the transaction to be staged remotely, got %v", prepared)
+			}
+			if err := coordinator.CommitTransaction(ctx, txn.TransactionID); err != nil {
+				t.Fatalf("Failed to commit: %v", err)
+			}
+			if _, exists := remote.FindTimeline("conv_remote"); !exists {
+				t.Fatalf("Expected the timeline on the remote store after commit")
+			}
+
+			// 参数经JSON传输后仍能解析
+			addMessage := []*TransactionParticipant{{StoreID: remote.StoreID, Operation: OpAddMessage, Params: map[string]interface{}{
+				"timeline_key": "conv_remote", "sender_id": uint32(42), "data": []byte("hello"), "user_ids": []string{"u1"},
+			}}}
+			if err := ExecuteTransaction(ctx, coordinator, addMessage, time.Minute); err != nil {
+				t.Fatalf("Failed to add message remotely: %v", err)
+			}
+			messages, _ := remote.GetConvMessages("conv_remote", 10, 0)
+			if len(messages) != 1 || messages[0].SenderID != 42 || string(messages[0].Data) != "hello" {
+				t.Fatalf("Unexpected remote messages %+v", messages)
+			}
+
+			// 参与者的拒绝不重试，冲突可以用errors.Is判断
+			deleteTimeline := &TransactionParticipant{StoreID: remote.StoreID, Operation: OpDeleteTimeline, Params: map[string]interface{}{"timeline_key": "conv_remote"}}
+			if err := participant.Prepare(ctx, "txn_delete_1", deleteTimeline); err != nil {
+				t.Fatalf("Failed to prepare delete: %v", err)
+			}
+			if err := participant.Prepare(ctx, "txn_delete_2", deleteTimeline); !errors.Is(err, ErrTransactionConflict) {
+				t.Errorf("Expected a conflict from the remote participant, got %v", err)
+			}
+			if err := participant.Commit(ctx, "txn_delete_2", deleteTimeline); !errors.Is(err, ErrTransactionNotPrepared) {
+				t.Errorf("Expected the unprepared commit to be refused, got %v", err)
+			}
+			if err := participant.Abort(ctx, "txn_delete_1", deleteTimeline); err != nil {
+				t.Errorf("Failed to abort: %v", err)
+			}
+			if prepared := remoteHandler.PreparedTransactions(); len(prepared) != 0 {
+				t.Errorf("Expected nothing staged after abort, got %v", prepared)
+			}
+		})
+	}
+}
+
+// lossyParticipantClient 把请求交给本地服务处理，但丢弃前drops个响应，模拟请求已执行而响应丢失
+type lossyParticipantClient struct {
+	StoreRPCClient
+	service *LocalStoreService
+	drops   int
+	calls   int
+	err     error // 不为nil时直接返回该错误
+}
+
+func (c *lossyParticipantClient) do(ctx context.Context, req *TransactionRequest, call func(context.Context, *TransactionRequest) (*TransactionResponse, error)) (*TransactionResponse, error) {
+	c.calls++
+	if c.err != nil {
+		return nil, c.err
+	}
+	resp, err := call(ctx, req)
+	if c.drops > 0 {
+		c.drops--
+		return nil, status.Error(codes.Unavailable, "connection reset")
+	}
+	return resp, err
+}
+
+func (c *lossyParticipantClient) PrepareTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.do(ctx, req, c.service.PrepareTransaction)
+}
+
+func (c *lossyParticipantClient) CommitTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.do(ctx, req, c.service.CommitTransaction)
+}
+
+func (c *lossyParticipantClient) AbortTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.do(ctx, req, c.service.AbortTransaction)
+}
+
+func TestRemoteTransactionParticipantRetries(t *testing.T) {
+	ctx := context.Background()
+	handler, store, _ := newTestTransactionHandler(t)
+	service := NewLocalStoreService(store)
+	service.SetTransactionHandler(handler)
+	client := &lossyParticipantClient{service: service}
+
+	participant := &RemoteTransactionParticipant{
+		clients: func(ctx context.Context, storeID string) (StoreRPCClient, error) { return client, nil },
+		timeout: time.Second,
+		retry:   RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond},
+	}
+	create := createTimelineParticipants("conv_a")[0]
+
+	// 响应丢失后重试，重复的Prepare和Commit是幂等的
+	client.drops = 1
+	if err := participant.Prepare(ctx, "txn_1", create); err != nil || client.calls != 2 {
+		t.Fatalf("Expected prepare to succeed on retry, got %v after %d calls", err, client.calls)
+	}
+	client.drops, client.calls = 1, 0
+	if err := participant.Commit(ctx, "txn_1", create); err != nil || client.calls != 2 {
+		t.Fatalf("Expected commit to succeed on retry, got %v after %d calls", err, client.calls)
+	}
+	if _, exists := store.FindTimeline("conv_a"); !exists {
+		t.Fatalf("Expected the committed timeline")
+	}
+
+	// 重试次数用尽
+	client.drops, client.calls = 5, 0
+	if err := participant.Abort(ctx, "txn_2", create); err == nil || client.calls != 3 {
+		t.Errorf("Expected abort to fail after 3 attempts, got %v after %d calls", err, client.calls)
+	}
+
+	// 明确的错误不重试
+	client.drops, client.calls = 0, 0
+	client.err = status.Error(codes.PermissionDenied, "unknown caller")
+	if err := participant.Prepare(ctx, "txn_3", create); err == nil || client.calls != 1 {
+		t.Errorf("Expected no retry for a permission error, got %v after %d calls", err, client.calls)
+	}
+}
+
+func TestCoordinatorFailsPrepareWithoutHandler(t *testing.T) {
+	ctx := context.Background()
+	handler, _, _ := newTestTransactionHandler(t)
+	coordinator := newTestCoordinator(t, handler)
+
+	participants := append(createTimelineParticipants("conv_a"), &TransactionParticipant{
+		StoreID: "store_b", Operation: OpCreateTimeline, Params: map[string]interface{}{"timeline_key": "conv_b", "timeline_type": "conversation"},
+	})
+	if err := ExecuteTransaction(ctx, coordinator, participants, time.Minute); err == nil {
+		t.Fatalf("Expected a participant without a handler to fail the transaction")
+	}
+	if prepared := handler.PreparedTransactions(); len(prepared) != 0 {
+		t.Errorf("Expected the local participant to be aborted, got %v", prepared)
+	}
+}
@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 持久化策略
+// DurabilityPolicy 统一控制段文件、Timeline元数据与WAL的fsync时机，三者的崩溃一致性如下：
+//
+//   - always：每条块记录写入后fsync段文件，新建段文件、替换元数据与压缩WAL后fsync数据目录，
+//     WAL每条记录fsync。写入返回即已落盘，进程崩溃与操作系统崩溃都不丢失已确认的写入。
+//   - periodic：段文件与WAL按DurabilityInterval在后台fsync，段滚动与WAL压缩前先fsync，
+//     元数据写入临时文件并fsync后再重命名。进程崩溃不丢失数据；操作系统崩溃最多丢失最近一个
+//     间隔内的写入，未落盘的段尾在重新打开时按残留写入截断。
+//   - none：从不主动fsync，由操作系统决定回写时机。进程崩溃不丢失数据；操作系统崩溃可能丢失
+//     任意未回写的数据，非末尾段中的残缺记录按损坏处理（见corruption.go）。
+//
+// 任何策略下元数据都通过临时文件重命名原子替换，读取方只会看到完整的旧版本或新版本。
+// 未配置时段文件按always写入，WAL按WALSyncPolicy（默认interval）刷盘，与之前的行为一致。
+
+// DurabilityPolicy 持久化策略
+type DurabilityPolicy string
+
+const (
+	DurabilityAlways   DurabilityPolicy = "always"   // 每次写入后fsync
+	DurabilityPeriodic DurabilityPolicy = "periodic" // 后台按固定间隔fsync
+	DurabilityNone     DurabilityPolicy = "none"     // 不主动fsync
+)
+
+const defaultDurabilityInterval = time.Second
+
+// validDurability 检查持久化策略，为空时返回always
+func validDurability(policy DurabilityPolicy) (DurabilityPolicy, error) {
+	switch policy {
+	case "":
+		return DurabilityAlways, nil
+	case DurabilityAlways, DurabilityPeriodic, DurabilityNone:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown durability policy %q", policy)
+	}
+}
+
+// walSyncPolicy 未单独配置WALSyncPolicy时由持久化策略决定WAL的刷盘策略
+func (c *StoreConfig) walSyncPolicy() (WALSyncPolicy, time.Duration) {
+	if c.WALSyncPolicy != "" || c.Durability == "" {
+		return c.WALSyncPolicy, c.WALSyncInterval
+	}
+	interval := c.WALSyncInterval
+	if interval <= 0 {
+		interval = c.DurabilityInterval
+	}
+	switch c.Durability {
+	case DurabilityAlways:
+		return WALSyncAlways, interval
+	case DurabilityNone:
+		return WALSyncNone, interval
+	default:
+		return WALSyncInterval, interval
+	}
+}
+
+// writeFileDurable 写入同目录下的临时文件后重命名替换path，崩溃后path只会是完整的旧内容或新内容
+// 策略为none时不fsync临时文件；为always时重命名后fsync目录，保证替换本身已落盘
+func writeFileDurable(path string, data []byte, policy DurabilityPolicy) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil && policy != DurabilityNone {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if policy == DurabilityAlways {
+		return syncDir(filepath.Dir(path))
+	}
+	return nil
+}
+
+// syncDir fsync目录，使其中新建、删除与重命名的文件项落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// startDurabilityLoop periodic策略下启动后台协程，定期fsync活跃段与数据目录（含替换后的元数据文件项）
+func (s *Store) startDurabilityLoop() {
+	if s.durability != DurabilityPeriodic {
+		return
+	}
+	interval := s.Config.DurabilityInterval
+	if interval <= 0 {
+		interval = defaultDurabilityInterval
+	}
+
+	s.syncStop = make(chan struct{})
+	s.syncDone = make(chan struct{})
+	go func() {
+		defer close(s.syncDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.segments.Sync(); err != nil {
+					log.Printf("store %s: segment sync failed: %v", s.StoreID, err)
+				}
+				if err := s.metadata.Sync(); err != nil {
+					log.Printf("store %s: metadata sync failed: %v", s.StoreID, err)
+				}
+			case <-s.syncStop:
+				return
+			}
+		}
+	}()
+}
+
+// stopDurabilityLoop 停止后台刷盘协程
+func (s *Store) stopDurabilityLoop() {
+	if s.syncStop == nil {
+		return
+	}
+	close(s.syncStop)
+	<-s.syncDone
+	s.syncStop = nil
+}
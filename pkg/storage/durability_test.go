@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 崩溃测试的子进程通过环境变量获得数据目录与持久化策略
+const (
+	crashWriterDirEnv    = "IMY_CRASH_WRITER_DIR"
+	crashWriterPolicyEnv = "IMY_CRASH_WRITER_POLICY"
+)
+
+func crashTestConfig(dir string, policy DurabilityPolicy) *StoreConfig {
+	// 小块、小段与小WAL阈值让写入过程中频繁发生块落盘、段滚动、元数据替换与WAL压缩
+	return &StoreConfig{
+		StoreID:            "store_crash",
+		TimelineMaxSize:    7,
+		DataDir:            dir,
+		SegmentMaxSize:     4096,
+		WALMaxSize:         2048,
+		Durability:         policy,
+		DurabilityInterval: 10 * time.Millisecond,
+	}
+}
+
+// TestCrashWriterProcess 崩溃测试的子进程：持续写入消息，每条写入返回后输出 "{会话} {SeqID}"
+func TestCrashWriterProcess(t *testing.T) {
+	dir := os.Getenv(crashWriterDirEnv)
+	if dir == "" {
+		t.Skip("helper process for TestKillDuringWrite")
+	}
+	store, err := NewStore(crashTestConfig(dir, DurabilityPolicy(os.Getenv(crashWriterPolicyEnv))))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create store: %v\n", err)
+		os.Exit(1)
+	}
+	for i := 0; i < 100000; i++ {
+		convID := fmt.Sprintf("crash_%d", i%3)
+		msg, err := store.AppendMessage(convID, 1, []byte(strconv.Itoa(i/3+1)), []string{"user_crash"})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to append: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("%s %d\n", convID, msg.SeqID)
+	}
+	os.Exit(0)
+}
+
+// TestKillDuringWrite 写入过程中SIGKILL进程，重新打开后已确认的消息都在且SeqID连续，元数据完整
+func TestKillDuringWrite(t *testing.T) {
+	if testing.Short() {
+		t.Skip("spawns a writer process")
+	}
+	for _, policy := range []DurabilityPolicy{DurabilityAlways, DurabilityPeriodic, DurabilityNone} {
+		t.Run(string(policy), func(t *testing.T) {
+			dir := t.TempDir()
+			acked := runCrashWriter(t, dir, policy, 300)
+
+			store, err := NewStore(crashTestConfig(dir, policy))
+			if err != nil {
+				t.Fatalf("Failed to reopen store: %v", err)
+			}
+			defer store.Close()
+
+			for convID, ackedSeqID := range acked {
+				messages, err := store.GetConvMessages(convID, 1<<20, 0)
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", convID, err)
+				}
+				if int64(len(messages)) < ackedSeqID {
+					t.Errorf("%s: acknowledged %d messages, recovered %d", convID, ackedSeqID, len(messages))
+				}
+				for i, msg := range messages {
+					if msg.SeqID != int64(i+1) || string(msg.Data) != strconv.Itoa(i+1) {
+						t.Fatalf("%s: unexpected message %d: SeqID %d %q", convID, i, msg.SeqID, msg.Data)
+					}
+				}
+			}
+			if corrupt := store.CorruptBlocks(); len(corrupt) != 0 {
+				t.Errorf("Expected no corrupt blocks after a process crash, got %+v", corrupt)
+			}
+
+			metas, _ := filepath.Glob(filepath.Join(dir, "*.meta"))
+			if len(metas) == 0 {
+				t.Fatal("Expected timeline metadata files")
+			}
+			for _, path := range metas {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatalf("Failed to read %s: %v", path, err)
+				}
+				var metadata map[string]any
+				if err := json.Unmarshal(data, &metadata); err != nil {
+					t.Errorf("Metadata %s is not complete: %v", filepath.Base(path), err)
+				}
+			}
+		})
+	}
+}
+
+// runCrashWriter 启动写入子进程，确认至少want条写入后SIGKILL，返回每个会话已确认的最大SeqID
+func runCrashWriter(t *testing.T, dir string, policy DurabilityPolicy, want int) map[string]int64 {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCrashWriterProcess$")
+	cmd.Env = append(os.Environ(), crashWriterDirEnv+"="+dir, crashWriterPolicyEnv+"="+string(policy))
+	cmd.Stderr = os.Stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start writer: %v", err)
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	acked := make(map[string]int64)
+	count := 0
+	record := func(line string) {
+		convID, seq, ok := strings.Cut(line, " ")
+		if !ok || !strings.HasPrefix(convID, "crash_") {
+			return
+		}
+		seqID, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil {
+			return
+		}
+		acked[convID] = seqID
+		count++
+	}
+
+	timeout := time.After(30 * time.Second)
+	for count < want {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				cmd.Wait()
+				t.Fatalf("Writer exited after %d writes", count)
+			}
+			record(line)
+		case <-timeout:
+			cmd.Process.Kill()
+			cmd.Wait()
+			t.Fatalf("Writer acknowledged only %d writes", count)
+		}
+	}
+
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatalf("Failed to kill writer: %v", err)
+	}
+	// 被杀死前已输出的确认仍在管道中
+	for line := range lines {
+		record(line)
+	}
+	cmd.Wait()
+	return acked
+}
+
+func TestDurabilityPolicyConfiguresWAL(t *testing.T) {
+	cases := []struct {
+		config StoreConfig
+		policy WALSyncPolicy
+	}{
+		{StoreConfig{}, ""},
+		{StoreConfig{Durability: DurabilityAlways}, WALSyncAlways},
+		{StoreConfig{Durability: DurabilityPeriodic}, WALSyncInterval},
+		{StoreConfig{Durability: DurabilityNone}, WALSyncNone},
+		{StoreConfig{Durability: DurabilityNone, WALSyncPolicy: WALSyncAlways}, WALSyncAlways},
+	}
+	for _, c := range cases {
+		if policy, _ := c.config.walSyncPolicy(); policy != c.policy {
+			t.Errorf("Durability %q WALSyncPolicy %q: expected %q, got %q", c.config.Durability, c.config.WALSyncPolicy, c.policy, policy)
+		}
+	}
+
+	config := StoreConfig{Durability: DurabilityPeriodic, DurabilityInterval: 50 * time.Millisecond}
+	if _, interval := config.walSyncPolicy(); interval != 50*time.Millisecond {
+		t.Errorf("Expected WAL to sync every 50ms, got %v", interval)
+	}
+
+	if _, err := NewStore(&StoreConfig{DataDir: t.TempDir(), Durability: "sometimes"}); err == nil {
+		t.Error("Expected unknown durability policy to be rejected")
+	}
+}
+
+func TestTimelineMetadataReplacedAtomically(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{TimelineMaxSize: 2, DataDir: dir, Durability: DurabilityPeriodic}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := store.AddMessage("atomic", 1, []byte(strconv.Itoa(i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	store.Flush()
+	store.Close()
+
+	if tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp")); len(tmps) != 0 {
+		t.Errorf("Expected no leftover temporary files, got %v", tmps)
+	}
+
+	// 替换元数据时崩溃留下的残缺临时文件不影响加载
+	metaPath := filepath.Join(dir, "conv_atomic.meta")
+	if err := os.WriteFile(metaPath+".tmp", []byte(`{"id":"atom`), 0644); err != nil {
+		t.Fatalf("Failed to write temporary file: %v", err)
+	}
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	messages, err := reopened.GetConvMessages("atomic", 10, 0)
+	if err != nil || len(messages) != 5 {
+		t.Fatalf("Expected 5 messages, got %d %v", len(messages), err)
+	}
+}
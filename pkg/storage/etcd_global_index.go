@@ -0,0 +1,562 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcd中的键布局（均位于Prefix之下）:
+//   timelines/{timelineKey}/blocks/{blockID}  -> GlobalStoreIndex JSON（主索引）
+//   timelines/{timelineKey}/migration         -> 最近一次迁移的IndexEvent JSON（迁移标记）
+//   timelines/{timelineKey}/splits            -> 拆分表，[]TimelineSegment JSON
+//   stores/{storeID}/{timelineKey}/{blockID}  -> GlobalStoreIndex JSON（按Store的二级索引）
+// 各段均经过url.PathEscape编码，允许键中出现'/'。
+
+const (
+	defaultEtcdIndexPrefix    = "/imy/index/"
+	defaultEtcdDialTimeout    = 5 * time.Second
+	defaultEtcdRequestTimeout = 3 * time.Second
+	etcdMigrationMarker       = "migration"
+	etcdSplitsMarker          = "splits"
+)
+
+// EtcdGlobalIndexConfig etcd全局索引配置
+type EtcdGlobalIndexConfig struct {
+	Endpoints      []string      `json:"endpoints"`      // etcd节点地址
+	Username       string        `json:"username"`       // 用户名
+	Password       string        `json:"password"`       // 密码
+	Prefix         string        `json:"prefix"`         // 键前缀
+	DialTimeout    time.Duration `json:"dialTimeout"`    // 连接超时
+	RequestTimeout time.Duration `json:"requestTimeout"` // 单次请求超时
+}
+
+// EtcdGlobalIndex etcd实现的全局索引管理器，索引状态可在多节点间共享并在重启后保留
+type EtcdGlobalIndex struct {
+	client         *clientv3.Client
+	prefix         string
+	requestTimeout time.Duration
+	ownsClient     bool
+}
+
+// NewEtcdGlobalIndex 连接etcd并创建全局索引管理器
+func NewEtcdGlobalIndex(config *EtcdGlobalIndexConfig) (*EtcdGlobalIndex, error) {
+	if config == nil || len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("etcd endpoints are required")
+	}
+
+	dialTimeout := config.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultEtcdDialTimeout
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   config.Endpoints,
+		Username:    config.Username,
+		Password:    config.Password,
+		DialTimeout: dialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect etcd: %w", err)
+	}
+
+	index := NewEtcdGlobalIndexWithClient(client, config.Prefix)
+	if config.RequestTimeout > 0 {
+		index.requestTimeout = config.RequestTimeout
+	}
+	index.ownsClient = true
+	return index, nil
+}
+
+// NewEtcdGlobalIndexWithClient 使用已有的etcd客户端创建全局索引管理器，Close时不会关闭该客户端
+func NewEtcdGlobalIndexWithClient(client *clientv3.Client, prefix string) *EtcdGlobalIndex {
+	if prefix == "" {
+		prefix = defaultEtcdIndexPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &EtcdGlobalIndex{
+		client:         client,
+		prefix:         prefix,
+		requestTimeout: defaultEtcdRequestTimeout,
+	}
+}
+
+// Close 关闭etcd连接
+func (e *EtcdGlobalIndex) Close() error {
+	if e.ownsClient {
+		return e.client.Close()
+	}
+	return nil
+}
+
+// AddIndex 添加索引条目
+func (e *EtcdGlobalIndex) AddIndex(ctx context.Context, index *GlobalStoreIndex) error {
+	now := time.Now()
+	index.UpdatedAt = now
+	if index.CreatedAt.IsZero() {
+		index.CreatedAt = now
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	blockKey := e.blockKey(index.TimelineKey, index.BlockID)
+	ops := []clientv3.Op{
+		clientv3.OpPut(blockKey, string(data)),
+		clientv3.OpPut(e.storeBlockKey(index.StoreID, index.TimelineKey, index.BlockID), string(data)),
+	}
+
+	// 若块已存在于其他Store，清理旧的二级索引
+	resp, err := e.client.Get(ctx, blockKey)
+	if err != nil {
+		return fmt.Errorf("failed to get index: %w", err)
+	}
+	compare := clientv3.Compare(clientv3.CreateRevision(blockKey), "=", 0)
+	if len(resp.Kvs) > 0 {
+		var existing GlobalStoreIndex
+		if err := json.Unmarshal(resp.Kvs[0].Value, &existing); err == nil && existing.StoreID != index.StoreID {
+			ops = append(ops, clientv3.OpDelete(e.storeBlockKey(existing.StoreID, index.TimelineKey, index.BlockID)))
+		}
+		compare = clientv3.Compare(clientv3.ModRevision(blockKey), "=", resp.Kvs[0].ModRevision)
+	}
+
+	txnResp, err := e.client.Txn(ctx).If(compare).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("failed to add index: %w", err)
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("index %s/%s modified concurrently", index.TimelineKey, index.BlockID)
+	}
+
+	return nil
+}
+
+// RemoveIndex 移除索引条目
+func (e *EtcdGlobalIndex) RemoveIndex(ctx context.Context, timelineKey, blockID string) error {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	existing, revision, err := e.getIndex(ctx, timelineKey, blockID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		if e.timelineExists(ctx, timelineKey) {
+			return fmt.Errorf("block %s not found in timeline %s", blockID, timelineKey)
+		}
+		return fmt.Errorf("timeline %s not found", timelineKey)
+	}
+
+	blockKey := e.blockKey(timelineKey, blockID)
+	txnResp, err := e.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(blockKey), "=", revision)).
+		Then(
+			clientv3.OpDelete(blockKey),
+			clientv3.OpDelete(e.storeBlockKey(existing.StoreID, timelineKey, blockID)),
+		).Commit()
+	if err != nil {
+		return fmt.Errorf("failed to remove index: %w", err)
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("index %s/%s modified concurrently", timelineKey, blockID)
+	}
+
+	return nil
+}
+
+// GetTimelineLocation 获取Timeline位置信息
+func (e *EtcdGlobalIndex) GetTimelineLocation(ctx context.Context, timelineKey string) (*TimelineLocation, error) {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.blocksPrefix(timelineKey), clientv3.WithPrefix(),
+		clientv3.WithSort(clientv3.SortByCreateRevision, clientv3.SortAscend))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline location: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, fmt.Errorf("timeline %s not found", timelineKey)
+	}
+
+	location := &TimelineLocation{
+		TimelineKey: timelineKey,
+		Blocks:      make([]*GlobalStoreIndex, 0, len(resp.Kvs)),
+		StoreMap:    make(map[string][]*GlobalStoreIndex),
+	}
+	for _, kv := range resp.Kvs {
+		var index GlobalStoreIndex
+		if err := json.Unmarshal(kv.Value, &index); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal index %s: %w", kv.Key, err)
+		}
+		location.Blocks = append(location.Blocks, &index)
+		location.StoreMap[index.StoreID] = append(location.StoreMap[index.StoreID], &index)
+		location.TotalSize += index.Size
+		location.BlockCount++
+		if index.UpdatedAt.After(location.LastUpdate) {
+			location.LastUpdate = index.UpdatedAt
+		}
+	}
+
+	return location, nil
+}
+
+// ListTimelinesByStore 获取指定Store上的所有Timeline
+func (e *EtcdGlobalIndex) ListTimelinesByStore(ctx context.Context, storeID string) ([]string, error) {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	storePrefix := e.storePrefix(storeID)
+	resp, err := e.client.Get(ctx, storePrefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list timelines: %w", err)
+	}
+
+	timelineSet := make(map[string]bool)
+	timelines := make([]string, 0)
+	for _, kv := range resp.Kvs {
+		parts := strings.SplitN(strings.TrimPrefix(string(kv.Key), storePrefix), "/", 2)
+		timelineKey, err := url.PathUnescape(parts[0])
+		if err != nil || timelineSet[timelineKey] {
+			continue
+		}
+		timelineSet[timelineKey] = true
+		timelines = append(timelines, timelineKey)
+	}
+
+	return timelines, nil
+}
+
+// UpdateIndex 更新索引条目
+func (e *EtcdGlobalIndex) UpdateIndex(ctx context.Context, index *GlobalStoreIndex) error {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	existing, revision, err := e.getIndex(ctx, index.TimelineKey, index.BlockID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		if e.timelineExists(ctx, index.TimelineKey) {
+			return fmt.Errorf("block %s not found in timeline %s", index.BlockID, index.TimelineKey)
+		}
+		return fmt.Errorf("timeline %s not found", index.TimelineKey)
+	}
+
+	index.UpdatedAt = time.Now()
+	if index.CreatedAt.IsZero() {
+		index.CreatedAt = existing.CreatedAt
+	}
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+
+	blockKey := e.blockKey(index.TimelineKey, index.BlockID)
+	ops := []clientv3.Op{
+		clientv3.OpPut(blockKey, string(data)),
+		clientv3.OpPut(e.storeBlockKey(index.StoreID, index.TimelineKey, index.BlockID), string(data)),
+	}
+	if existing.StoreID != index.StoreID {
+		ops = append(ops, clientv3.OpDelete(e.storeBlockKey(existing.StoreID, index.TimelineKey, index.BlockID)))
+	}
+
+	txnResp, err := e.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(blockKey), "=", revision)).
+		Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("failed to update index: %w", err)
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("index %s/%s modified concurrently", index.TimelineKey, index.BlockID)
+	}
+
+	return nil
+}
+
+// MigrateTimeline 迁移Timeline到新Store，所有块与迁移标记在同一事务中写入
+func (e *EtcdGlobalIndex) MigrateTimeline(ctx context.Context, timelineKey, fromStoreID, toStoreID string) error {
+	if fromStoreID == toStoreID {
+		return fmt.Errorf("source and target store are the same: %s", fromStoreID)
+	}
+
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.blocksPrefix(timelineKey), clientv3.WithPrefix())
+	if err != nil {
+		return fmt.Errorf("failed to get timeline: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return fmt.Errorf("timeline %s not found", timelineKey)
+	}
+
+	now := time.Now()
+	compares := make([]clientv3.Cmp, 0, len(resp.Kvs))
+	ops := make([]clientv3.Op, 0, len(resp.Kvs)*3+1)
+	for _, kv := range resp.Kvs {
+		var index GlobalStoreIndex
+		if err := json.Unmarshal(kv.Value, &index); err != nil {
+			return fmt.Errorf("failed to unmarshal index %s: %w", kv.Key, err)
+		}
+		if index.StoreID != fromStoreID {
+			continue
+		}
+
+		index.StoreID = toStoreID
+		index.UpdatedAt = now
+		data, err := json.Marshal(&index)
+		if err != nil {
+			return fmt.Errorf("failed to marshal index: %w", err)
+		}
+
+		compares = append(compares, clientv3.Compare(clientv3.ModRevision(string(kv.Key)), "=", kv.ModRevision))
+		ops = append(ops,
+			clientv3.OpPut(string(kv.Key), string(data)),
+			clientv3.OpDelete(e.storeBlockKey(fromStoreID, timelineKey, index.BlockID)),
+			clientv3.OpPut(e.storeBlockKey(toStoreID, timelineKey, index.BlockID), string(data)),
+		)
+	}
+
+	marker, err := json.Marshal(&IndexEvent{
+		Type:        "migrate",
+		TimelineKey: timelineKey,
+		OldStoreID:  fromStoreID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal migration event: %w", err)
+	}
+	ops = append(ops, clientv3.OpPut(e.migrationKey(timelineKey), string(marker)))
+
+	txnResp, err := e.client.Txn(ctx).If(compares...).Then(ops...).Commit()
+	if err != nil {
+		return fmt.Errorf("failed to migrate timeline: %w", err)
+	}
+	if !txnResp.Succeeded {
+		return fmt.Errorf("timeline %s modified concurrently during migration", timelineKey)
+	}
+
+	return nil
+}
+
+// GetStoreLoad 获取Store负载信息
+func (e *EtcdGlobalIndex) GetStoreLoad(ctx context.Context, storeID string) (*StoreLoadInfo, error) {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	storePrefix := e.storePrefix(storeID)
+	resp, err := e.client.Get(ctx, storePrefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store load: %w", err)
+	}
+
+	timelineSet := make(map[string]bool)
+	loadInfo := &StoreLoadInfo{
+		StoreID:    storeID,
+		BlockCount: len(resp.Kvs),
+		LastUpdate: time.Now(),
+	}
+	for _, kv := range resp.Kvs {
+		var index GlobalStoreIndex
+		if err := json.Unmarshal(kv.Value, &index); err != nil {
+			continue
+		}
+		timelineSet[index.TimelineKey] = true
+		loadInfo.TotalSize += index.Size
+	}
+	loadInfo.TimelineCount = len(timelineSet)
+
+	return loadInfo, nil
+}
+
+// GetTimelineSplits 获取Timeline的拆分表，未拆分时返回nil
+func (e *EtcdGlobalIndex) GetTimelineSplits(ctx context.Context, timelineKey string) ([]*TimelineSegment, error) {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	resp, err := e.client.Get(ctx, e.splitsKey(timelineKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline splits: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	var segments []*TimelineSegment
+	if err := json.Unmarshal(resp.Kvs[0].Value, &segments); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal timeline splits: %w", err)
+	}
+	return segments, nil
+}
+
+// SetTimelineSplits 替换Timeline的拆分表
+func (e *EtcdGlobalIndex) SetTimelineSplits(ctx context.Context, timelineKey string, segments []*TimelineSegment) error {
+	ctx, cancel := e.requestContext(ctx)
+	defer cancel()
+
+	if len(segments) == 0 {
+		if _, err := e.client.Delete(ctx, e.splitsKey(timelineKey)); err != nil {
+			return fmt.Errorf("failed to delete timeline splits: %w", err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(segments)
+	if err != nil {
+		return fmt.Errorf("failed to marshal timeline splits: %w", err)
+	}
+	if _, err := e.client.Put(ctx, e.splitsKey(timelineKey), string(data)); err != nil {
+		return fmt.Errorf("failed to put timeline splits: %w", err)
+	}
+	return nil
+}
+
+// Watch 监听索引变化，基于etcd watch实现，事件语义与InMemoryGlobalIndex一致
+func (e *EtcdGlobalIndex) Watch(ctx context.Context, timelineKey string) (<-chan IndexEvent, error) {
+	ch := make(chan IndexEvent, 100)
+	watchCh := e.client.Watch(clientv3.WithRequireLeader(ctx), e.timelinePrefix(timelineKey),
+		clientv3.WithPrefix(), clientv3.WithPrevKV())
+
+	go func() {
+		defer close(ch)
+		for resp := range watchCh {
+			if resp.Err() != nil {
+				continue
+			}
+			for _, event := range e.translateEvents(timelineKey, resp.Events) {
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// translateEvents 将etcd事件转换为索引事件
+// 迁移事务会同时写入多个块和迁移标记，同一revision内出现迁移标记时只产生一个migrate事件
+func (e *EtcdGlobalIndex) translateEvents(timelineKey string, events []*clientv3.Event) []IndexEvent {
+	migrated := make(map[int64]bool)
+	migrationKey := e.migrationKey(timelineKey)
+	for _, ev := range events {
+		if string(ev.Kv.Key) == migrationKey && ev.Type == clientv3.EventTypePut {
+			migrated[ev.Kv.ModRevision] = true
+		}
+	}
+
+	splitsKey := e.splitsKey(timelineKey)
+	result := make([]IndexEvent, 0, len(events))
+	for _, ev := range events {
+		key := string(ev.Kv.Key)
+		if key == splitsKey {
+			result = append(result, IndexEvent{Type: "split", TimelineKey: timelineKey})
+			continue
+		}
+		if key == migrationKey {
+			if ev.Type != clientv3.EventTypePut {
+				continue
+			}
+			var event IndexEvent
+			if err := json.Unmarshal(ev.Kv.Value, &event); err == nil {
+				result = append(result, event)
+			}
+			continue
+		}
+		if migrated[ev.Kv.ModRevision] {
+			continue
+		}
+
+		switch {
+		case ev.Type == clientv3.EventTypeDelete:
+			event := IndexEvent{Type: "remove", TimelineKey: timelineKey}
+			if ev.PrevKv != nil {
+				var index GlobalStoreIndex
+				if err := json.Unmarshal(ev.PrevKv.Value, &index); err == nil {
+					event.Index = &index
+				}
+			}
+			result = append(result, event)
+		case ev.IsCreate():
+			var index GlobalStoreIndex
+			if err := json.Unmarshal(ev.Kv.Value, &index); err == nil {
+				result = append(result, IndexEvent{Type: "add", TimelineKey: timelineKey, Index: &index})
+			}
+		default:
+			var index GlobalStoreIndex
+			if err := json.Unmarshal(ev.Kv.Value, &index); err == nil {
+				result = append(result, IndexEvent{Type: "update", TimelineKey: timelineKey, Index: &index})
+			}
+		}
+	}
+
+	return result
+}
+
+// getIndex 读取单个索引条目及其revision，不存在时返回nil
+func (e *EtcdGlobalIndex) getIndex(ctx context.Context, timelineKey, blockID string) (*GlobalStoreIndex, int64, error) {
+	resp, err := e.client.Get(ctx, e.blockKey(timelineKey, blockID))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get index: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, nil
+	}
+
+	var index GlobalStoreIndex
+	if err := json.Unmarshal(resp.Kvs[0].Value, &index); err != nil {
+		return nil, 0, fmt.Errorf("failed to unmarshal index: %w", err)
+	}
+	return &index, resp.Kvs[0].ModRevision, nil
+}
+
+// timelineExists 检查Timeline是否有任何块
+func (e *EtcdGlobalIndex) timelineExists(ctx context.Context, timelineKey string) bool {
+	resp, err := e.client.Get(ctx, e.blocksPrefix(timelineKey), clientv3.WithPrefix(), clientv3.WithCountOnly())
+	return err == nil && resp.Count > 0
+}
+
+// requestContext 为单次请求附加超时
+func (e *EtcdGlobalIndex) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, e.requestTimeout)
+}
+
+func (e *EtcdGlobalIndex) timelinePrefix(timelineKey string) string {
+	return e.prefix + "timelines/" + url.PathEscape(timelineKey) + "/"
+}
+
+func (e *EtcdGlobalIndex) blocksPrefix(timelineKey string) string {
+	return e.timelinePrefix(timelineKey) + "blocks/"
+}
+
+func (e *EtcdGlobalIndex) blockKey(timelineKey, blockID string) string {
+	return e.blocksPrefix(timelineKey) + url.PathEscape(blockID)
+}
+
+func (e *EtcdGlobalIndex) migrationKey(timelineKey string) string {
+	return e.timelinePrefix(timelineKey) + etcdMigrationMarker
+}
+
+func (e *EtcdGlobalIndex) splitsKey(timelineKey string) string {
+	return e.timelinePrefix(timelineKey) + etcdSplitsMarker
+}
+
+func (e *EtcdGlobalIndex) storePrefix(storeID string) string {
+	return e.prefix + "stores/" + url.PathEscape(storeID) + "/"
+}
+
+func (e *EtcdGlobalIndex) storeBlockKey(storeID, timelineKey, blockID string) string {
+	return e.storePrefix(storeID) + url.PathEscape(timelineKey) + "/" + url.PathEscape(blockID)
+}
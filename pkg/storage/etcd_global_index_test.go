@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdGlobalIndexKeyLayout(t *testing.T) {
+	index := NewEtcdGlobalIndexWithClient(nil, "/test")
+
+	if got := index.blockKey("conv/a", "block_1"); got != "/test/timelines/conv%2Fa/blocks/block_1" {
+		t.Errorf("Unexpected block key: %s", got)
+	}
+	if got := index.storeBlockKey("store_1", "conv/a", "block_1"); got != "/test/stores/store_1/conv%2Fa/block_1" {
+		t.Errorf("Unexpected store block key: %s", got)
+	}
+}
+
+func TestEtcdGlobalIndexTranslateEvents(t *testing.T) {
+	index := NewEtcdGlobalIndexWithClient(nil, "")
+	timelineKey := "conv_watch"
+
+	indexValue := func(storeID string) []byte {
+		data, _ := json.Marshal(&GlobalStoreIndex{TimelineKey: timelineKey, StoreID: storeID, BlockID: "block_1"})
+		return data
+	}
+	blockKey := []byte(index.blockKey(timelineKey, "block_1"))
+
+	events := index.translateEvents(timelineKey, []*clientv3.Event{
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: blockKey, Value: indexValue("store_a"), CreateRevision: 2, ModRevision: 2}},
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: blockKey, Value: indexValue("store_a"), CreateRevision: 2, ModRevision: 3}},
+		{Type: clientv3.EventTypeDelete, Kv: &mvccpb.KeyValue{Key: blockKey, ModRevision: 4}, PrevKv: &mvccpb.KeyValue{Key: blockKey, Value: indexValue("store_a")}},
+	})
+
+	expected := []string{"add", "update", "remove"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(events))
+	}
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, expected[i], event.Type)
+		}
+		if event.Index == nil || event.Index.BlockID != "block_1" {
+			t.Errorf("Event %d: missing index payload", i)
+		}
+	}
+
+	// 迁移事务中的块更新应合并为一个migrate事件
+	marker, _ := json.Marshal(&IndexEvent{Type: "migrate", TimelineKey: timelineKey, OldStoreID: "store_a"})
+	events = index.translateEvents(timelineKey, []*clientv3.Event{
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: blockKey, Value: indexValue("store_b"), CreateRevision: 2, ModRevision: 5}},
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: []byte(index.migrationKey(timelineKey)), Value: marker, CreateRevision: 5, ModRevision: 5}},
+	})
+	if len(events) != 1 || events[0].Type != "migrate" || events[0].OldStoreID != "store_a" {
+		t.Fatalf("Expected a single migrate event, got %+v", events)
+	}
+}
+
+func TestEtcdGlobalIndexSplitEvents(t *testing.T) {
+	index := NewEtcdGlobalIndexWithClient(nil, "/test")
+	if got := index.splitsKey("conv/a"); got != "/test/timelines/conv%2Fa/splits" {
+		t.Errorf("Unexpected splits key: %s", got)
+	}
+
+	segments, _ := json.Marshal([]*TimelineSegment{{TimelineKey: "conv_a", StoreID: "store_a"}})
+	events := index.translateEvents("conv_a", []*clientv3.Event{
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: []byte(index.splitsKey("conv_a")), Value: segments, CreateRevision: 2, ModRevision: 2}},
+	})
+	if len(events) != 1 || events[0].Type != "split" {
+		t.Fatalf("Expected a split event, got %+v", events)
+	}
+}
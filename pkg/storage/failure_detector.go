@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// FailureDetectorConfig 故障检测配置
+type FailureDetectorConfig struct {
+	ProbeInterval    time.Duration `json:"probe_interval"`    // 探测间隔
+	ProbeTimeout     time.Duration `json:"probe_timeout"`     // 单次探测超时
+	HeartbeatTimeout time.Duration `json:"heartbeat_timeout"` // 无RPC连接池时按注册中心心跳判断的超时
+	FailureThreshold int           `json:"failure_threshold"` // 连续探测失败多少次判定为故障
+	AutoFailover     bool          `json:"auto_failover"`     // 判定故障后是否自动迁移其上的Timeline
+}
+
+// DefaultFailureDetectorConfig 默认故障检测配置
+func DefaultFailureDetectorConfig() *FailureDetectorConfig {
+	return &FailureDetectorConfig{
+		ProbeInterval:    5 * time.Second,
+		ProbeTimeout:     2 * time.Second,
+		HeartbeatTimeout: 30 * time.Second,
+		FailureThreshold: 3,
+		AutoFailover:     true,
+	}
+}
+
+// StoreHealth Store健康状态
+type StoreHealth struct {
+	StoreID             string    `json:"store_id"`
+	Healthy             bool      `json:"healthy"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastSuccess         time.Time `json:"last_success"`
+	LastProbe           time.Time `json:"last_probe"`
+	LastError           string    `json:"last_error,omitempty"`
+}
+
+// FailoverResult 一次故障转移的结果
+type FailoverResult struct {
+	StoreID    string           `json:"store_id"`
+	Migrations []*MigrationTask `json:"migrations"`
+	Errors     []string         `json:"errors,omitempty"`
+}
+
+// FailureDetector 定期探测注册中心中的Store，连续失败达到阈值时判定故障：
+// 在注册中心标记为unhealthy、从路由器移除，并将其上的Timeline迁移到健康Store
+type FailureDetector struct {
+	mu               sync.RWMutex
+	config           *FailureDetectorConfig
+	localStoreID     string
+	registry         StoreRegistry
+	router           TimelineRouter
+	globalIndex      GlobalIndexManager
+	migrationManager MigrationManager
+	rpcClientPool    *StoreRPCClientPool
+	health           map[string]*StoreHealth
+	statusBefore     map[string]StoreStatus // 故障前的状态，恢复时还原
+	probe            func(ctx context.Context, info *StoreInfo) error
+	stopCh           chan struct{}
+	wg               sync.WaitGroup
+	running          bool
+}
+
+// NewFailureDetector 创建故障检测器
+func NewFailureDetector(
+	localStoreID string,
+	registry StoreRegistry,
+	router TimelineRouter,
+	globalIndex GlobalIndexManager,
+	migrationManager MigrationManager,
+	rpcClientPool *StoreRPCClientPool,
+	config *FailureDetectorConfig,
+) *FailureDetector {
+	if config == nil {
+		config = DefaultFailureDetectorConfig()
+	}
+	fd := &FailureDetector{
+		config:           config,
+		localStoreID:     localStoreID,
+		registry:         registry,
+		router:           router,
+		globalIndex:      globalIndex,
+		migrationManager: migrationManager,
+		rpcClientPool:    rpcClientPool,
+		health:           make(map[string]*StoreHealth),
+		statusBefore:     make(map[string]StoreStatus),
+	}
+	fd.probe = fd.probeStore
+	return fd
+}
+
+// Start 启动故障检测
+func (fd *FailureDetector) Start(ctx context.Context) error {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	if fd.running {
+		return fmt.Errorf("failure detector is already running")
+	}
+	if fd.config.ProbeInterval <= 0 {
+		return fmt.Errorf("invalid probe interval: %v", fd.config.ProbeInterval)
+	}
+
+	fd.stopCh = make(chan struct{})
+	fd.running = true
+
+	fd.wg.Add(1)
+	go fd.detectLoop(ctx, fd.stopCh)
+
+	return nil
+}
+
+// Stop 停止故障检测
+func (fd *FailureDetector) Stop() error {
+	fd.mu.Lock()
+	if !fd.running {
+		fd.mu.Unlock()
+		return fmt.Errorf("failure detector is not running")
+	}
+	close(fd.stopCh)
+	fd.running = false
+	fd.mu.Unlock()
+
+	fd.wg.Wait()
+	return nil
+}
+
+// detectLoop 定期探测循环
+func (fd *FailureDetector) detectLoop(ctx context.Context, stopCh chan struct{}) {
+	defer fd.wg.Done()
+
+	ticker := time.NewTicker(fd.config.ProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if _, err := fd.CheckOnce(ctx); err != nil {
+				log.Printf("failure detector: %v", err)
+			}
+		}
+	}
+}
+
+// CheckOnce 探测所有已注册的Store一次，返回本轮新判定故障的Store的故障转移结果
+func (fd *FailureDetector) CheckOnce(ctx context.Context) ([]*FailoverResult, error) {
+	stores, err := fd.registry.ListStores(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stores: %w", err)
+	}
+
+	results := make([]*FailoverResult, 0)
+	for _, info := range stores {
+		if info.ID == fd.localStoreID || info.Status == StoreStatusDecommissioned {
+			continue
+		}
+
+		probeCtx, cancel := context.WithTimeout(ctx, fd.config.ProbeTimeout)
+		probeErr := fd.probe(probeCtx, info)
+		cancel()
+
+		failed, recovered := fd.recordProbe(info.ID, probeErr)
+		switch {
+		case failed:
+			results = append(results, fd.handleFailure(ctx, info))
+		case recovered:
+			fd.handleRecovery(ctx, info)
+		}
+	}
+
+	return results, nil
+}
+
+// recordProbe 记录探测结果，返回是否刚判定为故障或刚恢复
+func (fd *FailureDetector) recordProbe(storeID string, probeErr error) (failed, recovered bool) {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+
+	health, exists := fd.health[storeID]
+	if !exists {
+		health = &StoreHealth{StoreID: storeID, Healthy: true}
+		fd.health[storeID] = health
+	}
+
+	now := time.Now()
+	health.LastProbe = now
+
+	if probeErr == nil {
+		recovered = !health.Healthy
+		health.Healthy = true
+		health.ConsecutiveFailures = 0
+		health.LastSuccess = now
+		health.LastError = ""
+		return false, recovered
+	}
+
+	health.ConsecutiveFailures++
+	health.LastError = probeErr.Error()
+	if health.Healthy && health.ConsecutiveFailures >= fd.config.FailureThreshold {
+		health.Healthy = false
+		return true, false
+	}
+	return false, false
+}
+
+// probeStore 默认探测：通过RPC健康检查；未配置RPC连接池时按注册中心心跳判断
+func (fd *FailureDetector) probeStore(ctx context.Context, info *StoreInfo) error {
+	if fd.rpcClientPool == nil {
+		if time.Since(info.LastSeen) > fd.config.HeartbeatTimeout {
+			return fmt.Errorf("no heartbeat from store %s since %v", info.ID, info.LastSeen)
+		}
+		return nil
+	}
+
+	client, err := fd.rpcClientPool.GetClient(ctx, info.ID, info.Address)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.HealthCheck(ctx, &HealthCheckRequest{Ping: "ping"})
+	if err != nil {
+		fd.rpcClientPool.RemoveClient(info.ID)
+		return err
+	}
+	if !healthStatusServing(resp.Status) {
+		return fmt.Errorf("store %s reported status %s", info.ID, resp.Status)
+	}
+	return nil
+}
+
+// handleFailure 处理Store故障
+func (fd *FailureDetector) handleFailure(ctx context.Context, info *StoreInfo) *FailoverResult {
+	result := &FailoverResult{StoreID: info.ID, Migrations: make([]*MigrationTask, 0)}
+	log.Printf("failure detector: store %s is unhealthy", info.ID)
+
+	if info.Status != StoreStatusUnhealthy {
+		fd.mu.Lock()
+		fd.statusBefore[info.ID] = info.Status
+		fd.mu.Unlock()
+	}
+	if err := fd.registry.UpdateStatus(ctx, info.ID, StoreStatusUnhealthy); err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	if fd.router != nil {
+		if err := fd.router.RemoveStore(info.ID); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+	}
+
+	if fd.config.AutoFailover {
+		fd.failover(ctx, info.ID, result)
+	}
+
+	return result
+}
+
+// failover 将故障Store上的Timeline迁移到路由器选出的健康Store
+func (fd *FailureDetector) failover(ctx context.Context, storeID string, result *FailoverResult) {
+	if fd.globalIndex == nil || fd.migrationManager == nil || fd.router == nil {
+		return
+	}
+
+	timelineKeys, err := fd.globalIndex.ListTimelinesByStore(ctx, storeID)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to list timelines on %s: %v", storeID, err))
+		return
+	}
+
+	for _, timelineKey := range timelineKeys {
+		targetStoreID, err := fd.router.RouteTimeline(timelineKey)
+		if err != nil || targetStoreID == storeID {
+			result.Errors = append(result.Errors, fmt.Sprintf("no healthy target for %s", timelineKey))
+			continue
+		}
+
+		task, err := fd.migrationManager.StartMigration(ctx, timelineKey, targetStoreID)
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("failed to migrate %s: %v", timelineKey, err))
+			continue
+		}
+		result.Migrations = append(result.Migrations, task)
+	}
+}
+
+// handleRecovery Store恢复后还原故障前的状态，active的Store重新加入路由
+func (fd *FailureDetector) handleRecovery(ctx context.Context, info *StoreInfo) {
+	log.Printf("failure detector: store %s recovered", info.ID)
+
+	fd.mu.Lock()
+	status, exists := fd.statusBefore[info.ID]
+	delete(fd.statusBefore, info.ID)
+	fd.mu.Unlock()
+	if !exists {
+		status = StoreStatusActive
+	}
+	if err := fd.registry.UpdateStatus(ctx, info.ID, status); err != nil {
+		log.Printf("failure detector: failed to update status of %s: %v", info.ID, err)
+	}
+
+	if fd.router != nil && status.AcceptsPlacements() {
+		restored := *info
+		restored.Status = StoreStatusActive
+		if err := fd.router.AddStore(&restored); err != nil {
+			log.Printf("failure detector: failed to add store %s back to router: %v", info.ID, err)
+		}
+	}
+}
+
+// GetStoreHealth 获取Store的健康状态
+func (fd *FailureDetector) GetStoreHealth(storeID string) (*StoreHealth, bool) {
+	fd.mu.RLock()
+	defer fd.mu.RUnlock()
+
+	health, exists := fd.health[storeID]
+	if !exists {
+		return nil, false
+	}
+	copied := *health
+	return &copied, true
+}
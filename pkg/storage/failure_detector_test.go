@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMigrationManager 只记录迁移请求的MigrationManager
+type recordingMigrationManager struct {
+	mu    sync.Mutex
+	tasks []*MigrationTask
+}
+
+func (m *recordingMigrationManager) StartMigration(ctx context.Context, timelineKey, targetStoreID string) (*MigrationTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	task := &MigrationTask{ID: fmt.Sprintf("task_%d", len(m.tasks)), TimelineKey: timelineKey, TargetStore: targetStoreID, Status: MigrationPending}
+	m.tasks = append(m.tasks, task)
+	return task, nil
+}
+
+func (m *recordingMigrationManager) ResumeMigration(ctx context.Context, taskID string) (*MigrationTask, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (m *recordingMigrationManager) GetMigrationStatus(ctx context.Context, taskID string) (*MigrationTask, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, task := range m.tasks {
+		if task.ID == taskID {
+			return task, nil
+		}
+	}
+	return nil, fmt.Errorf("migration task not found: %s", taskID)
+}
+
+func (m *recordingMigrationManager) CancelMigration(ctx context.Context, taskID string) error {
+	task, err := m.GetMigrationStatus(ctx, taskID)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	task.Status = MigrationCancelled
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *recordingMigrationManager) ListMigrations(ctx context.Context, status MigrationStatus) ([]*MigrationTask, error) {
+	return m.tasks, nil
+}
+
+func (m *recordingMigrationManager) CleanupCompletedMigrations(ctx context.Context, olderThan time.Duration) error {
+	return nil
+}
+
+func TestFailureDetectorFailover(t *testing.T) {
+	ctx := context.Background()
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	router := NewConsistentHashRouter(1, 10, 0.8)
+	for _, id := range []string{"store_local", "store_a", "store_b"} {
+		registry.Register(ctx, &StoreInfo{ID: id})
+		router.AddStore(&StoreInfo{ID: id, Status: StoreStatusActive})
+	}
+
+	globalIndex := NewInMemoryGlobalIndex()
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_1", StoreID: "store_a", BlockID: "block_1"})
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_2", StoreID: "store_a", BlockID: "block_2"})
+
+	migrations := &recordingMigrationManager{}
+	config := DefaultFailureDetectorConfig()
+	config.FailureThreshold = 2
+	detector := NewFailureDetector("store_local", registry, router, globalIndex, migrations, nil, config)
+
+	down := map[string]bool{"store_a": true}
+	var probeMu sync.Mutex
+	detector.probe = func(ctx context.Context, info *StoreInfo) error {
+		probeMu.Lock()
+		defer probeMu.Unlock()
+		if info.ID == "store_local" {
+			t.Errorf("Local store should not be probed")
+		}
+		if down[info.ID] {
+			return fmt.Errorf("connection refused")
+		}
+		return nil
+	}
+
+	// 第一次失败未达到阈值
+	results, err := detector.CheckOnce(ctx)
+	if err != nil || len(results) != 0 {
+		t.Fatalf("Expected no failover after first failure, got %v %v", results, err)
+	}
+	if info, _ := registry.GetStore(ctx, "store_a"); info.Status != "active" {
+		t.Fatalf("Store should still be active, got %s", info.Status)
+	}
+
+	results, _ = detector.CheckOnce(ctx)
+	if len(results) != 1 || results[0].StoreID != "store_a" {
+		t.Fatalf("Expected store_a to fail over, got %+v", results)
+	}
+	if info, _ := registry.GetStore(ctx, "store_a"); info.Status != StoreStatusUnhealthy {
+		t.Errorf("Expected store_a to be unhealthy, got %s", info.Status)
+	}
+	if len(results[0].Migrations) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d (%v)", len(results[0].Migrations), results[0].Errors)
+	}
+	for _, task := range results[0].Migrations {
+		if task.TargetStore == "store_a" {
+			t.Errorf("Timeline %s migrated to the failed store", task.TimelineKey)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		if storeID, _ := router.RouteTimeline(fmt.Sprintf("conv_%d", i)); storeID == "store_a" {
+			t.Fatalf("Failed store should be removed from router")
+		}
+	}
+
+	// 已判定故障的Store不重复触发迁移
+	if results, _ := detector.CheckOnce(ctx); len(results) != 0 {
+		t.Errorf("Expected no repeated failover, got %d", len(results))
+	}
+
+	// 恢复后重新加入
+	probeMu.Lock()
+	down["store_a"] = false
+	probeMu.Unlock()
+	detector.CheckOnce(ctx)
+
+	if info, _ := registry.GetStore(ctx, "store_a"); info.Status != "active" {
+		t.Errorf("Expected store_a to be active again, got %s", info.Status)
+	}
+	if health, _ := detector.GetStoreHealth("store_a"); !health.Healthy || health.ConsecutiveFailures != 0 {
+		t.Errorf("Unexpected health after recovery: %+v", health)
+	}
+
+	// 排空中的Store恢复后保持排空，不重新加入路由
+	registry.UpdateStatus(ctx, "store_b", StoreStatusDraining)
+	router.RemoveStore("store_b")
+	probeMu.Lock()
+	down["store_b"] = true
+	probeMu.Unlock()
+	detector.CheckOnce(ctx)
+	detector.CheckOnce(ctx)
+	if info, _ := registry.GetStore(ctx, "store_b"); info.Status != StoreStatusUnhealthy {
+		t.Fatalf("Expected store_b to be unhealthy, got %s", info.Status)
+	}
+	probeMu.Lock()
+	down["store_b"] = false
+	probeMu.Unlock()
+	detector.CheckOnce(ctx)
+	if info, _ := registry.GetStore(ctx, "store_b"); info.Status != StoreStatusDraining {
+		t.Errorf("Expected store_b to be draining again, got %s", info.Status)
+	}
+	for i := 0; i < 20; i++ {
+		if storeID, _ := router.RouteTimeline(fmt.Sprintf("conv_%d", i)); storeID == "store_b" {
+			t.Fatalf("Draining store should stay out of the router")
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package storage
+
+import (
+	"hash/fnv"
+	"log"
+	"sync"
+)
+
+// 用户时间线的扇出
+// 默认在写入方协程中逐个写入成员的用户时间线并保存元数据，大群的每条消息需要O(成员数)次元数据落盘。
+// 配置FanoutWorkers后，会话时间线仍同步写入并在返回前落盘元数据，用户时间线交给固定数量的后台协程写入：
+//   - 按用户ID分片，同一用户的记录总由同一协程按入队顺序写入，用户时间线中的顺序与同步扇出一致
+//   - 协程每次取出队列中已有的记录（至多fanoutBatchSize条）作为一批，批内每个用户只保存一次元数据
+//   - 每个协程的队列长度有上限，队列满时写入方阻塞，写入速度受扇出速度约束
+//
+// 用户时间线中的记录在写入返回后才陆续出现，WaitFanout等待已入队的记录全部写入。
+// Close先处理完队列；进程崩溃时队列中尚未写入的记录丢失，会话时间线中的消息不受影响。
+
+const (
+	defaultFanoutQueueSize = 1024
+	fanoutBatchSize        = 256
+)
+
+// fanoutTask 写入一个用户时间线的记录
+type fanoutTask struct {
+	userID string
+	entry  *Message
+}
+
+// fanoutPool 异步写入用户时间线的协程池
+type fanoutPool struct {
+	store  *Store
+	queues []chan fanoutTask
+	wg     sync.WaitGroup
+
+	// 入队持读锁，关闭时持写锁，关闭后不再向队列发送
+	mu     sync.RWMutex
+	closed bool
+
+	pendingMu sync.Mutex
+	idle      *sync.Cond
+	pending   int // 已入队未写入的记录数
+}
+
+func newFanoutPool(store *Store, workers, queueSize int) *fanoutPool {
+	if queueSize <= 0 {
+		queueSize = defaultFanoutQueueSize
+	}
+	p := &fanoutPool{store: store, queues: make([]chan fanoutTask, workers)}
+	p.idle = sync.NewCond(&p.pendingMu)
+	for i := range p.queues {
+		p.queues[i] = make(chan fanoutTask, queueSize)
+		p.wg.Add(1)
+		go p.run(p.queues[i])
+	}
+	return p
+}
+
+// enqueue 把消息的用户时间线副本放入各用户所在分片的队列，队列满时阻塞
+// 协程池已关闭时返回false，由调用方同步写入
+func (p *fanoutPool) enqueue(msg *Message, userIDs []string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return false
+	}
+
+	p.pendingMu.Lock()
+	p.pending += len(userIDs)
+	p.pendingMu.Unlock()
+	for _, userID := range userIDs {
+		p.queues[p.shard(userID)] <- fanoutTask{userID: userID, entry: userEntry(msg)}
+	}
+	return true
+}
+
+func (p *fanoutPool) shard(userID string) int {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return int(h.Sum32() % uint32(len(p.queues)))
+}
+
+// run 按批处理一个分片的队列，队列关闭且取空后退出
+func (p *fanoutPool) run(queue <-chan fanoutTask) {
+	defer p.wg.Done()
+	batch := make([]fanoutTask, 0, fanoutBatchSize)
+	for task := range queue {
+		batch = append(batch[:0], task)
+	drain:
+		for len(batch) < fanoutBatchSize {
+			select {
+			case task, ok := <-queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, task)
+			default:
+				break drain
+			}
+		}
+		p.apply(batch)
+	}
+}
+
+// apply 写入一批记录，每个用户时间线在批末保存一次元数据
+// 容量与租户配额已在写入会话时间线前按全部成员检查，这里的失败只记录日志
+func (p *fanoutPool) apply(batch []fanoutTask) {
+	s := p.store
+	touched := make(map[string]*Timeline)
+	for _, task := range batch {
+		userTL := s.GetOrCreateUserTimeline(task.userID)
+		if err := userTL.AddMessage(task.entry, s); err != nil {
+			log.Printf("store %s: failed to fan out message %s/%d to %s: %v", s.StoreID, task.entry.ConvID, task.entry.ConvSeqID, task.userID, err)
+			continue
+		}
+		touched[task.userID] = userTL
+	}
+	for userID, userTL := range touched {
+		if err := s.saveTimelineMetadata(userTL); err != nil {
+			log.Printf("store %s: failed to save user timeline %s: %v", s.StoreID, userID, err)
+		}
+	}
+
+	p.pendingMu.Lock()
+	p.pending -= len(batch)
+	if p.pending == 0 {
+		p.idle.Broadcast()
+	}
+	p.pendingMu.Unlock()
+}
+
+// wait 等待已入队的记录全部写入
+func (p *fanoutPool) wait() {
+	p.pendingMu.Lock()
+	defer p.pendingMu.Unlock()
+	for p.pending > 0 {
+		p.idle.Wait()
+	}
+}
+
+// close 停止接收新记录，处理完队列后返回
+func (p *fanoutPool) close() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	p.closed = true
+	for _, queue := range p.queues {
+		close(queue)
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// userEntry 消息写入用户时间线的副本，SeqID由用户时间线重新分配
+func userEntry(msg *Message) *Message {
+	entry := *msg
+	entry.ConvSeqID = msg.SeqID
+	return &entry
+}
+
+// fanOutToUsers 把会话时间线中的消息写入成员的用户时间线
+// 配置了FanoutWorkers时入队后立即返回，否则同步写入并保存各用户时间线的元数据
+func (s *Store) fanOutToUsers(msg *Message, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+	if s.fanout != nil && s.fanout.enqueue(msg, userIDs) {
+		return nil
+	}
+
+	timelines := make([]*Timeline, 0, len(userIDs))
+	for _, userID := range userIDs {
+		userTL := s.GetOrCreateUserTimeline(userID)
+		if err := userTL.AddMessage(userEntry(msg), s); err != nil {
+			return err
+		}
+		timelines = append(timelines, userTL)
+	}
+	for _, userTL := range timelines {
+		if err := s.saveTimelineMetadata(userTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitFanout 等待已提交消息的用户时间线记录全部写入，未配置FanoutWorkers时立即返回
+func (s *Store) WaitFanout() {
+	if s.fanout != nil {
+		s.fanout.wait()
+	}
+}
+
+// PendingFanout 已入队尚未写入用户时间线的记录数
+func (s *Store) PendingFanout() int {
+	if s.fanout == nil {
+		return 0
+	}
+	s.fanout.pendingMu.Lock()
+	defer s.fanout.pendingMu.Unlock()
+	return s.fanout.pending
+}
+
+// stopFanout 处理完队列中的记录并停止扇出协程，之后的写入同步扇出
+func (s *Store) stopFanout() {
+	if s.fanout != nil {
+		s.fanout.close()
+	}
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAsyncFanout(t *testing.T) {
+	// 队列很短，写入方会因背压阻塞
+	config := &StoreConfig{TimelineMaxSize: 16, DataDir: t.TempDir(), FanoutWorkers: 3, FanoutQueueSize: 2}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	const members, messages = 20, 50
+	userIDs := make([]string, members)
+	for i := range userIDs {
+		userIDs[i] = fmt.Sprintf("user_%d", i)
+	}
+	for i := 1; i <= messages; i++ {
+		msg, err := store.AppendMessage("fanout", 1, []byte(fmt.Sprintf("message-%d", i)), userIDs)
+		if err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+		// 会话时间线同步写入
+		if msg.SeqID != int64(i) {
+			t.Fatalf("Expected conversation SeqID %d, got %d", i, msg.SeqID)
+		}
+	}
+
+	store.WaitFanout()
+	if pending := store.PendingFanout(); pending != 0 {
+		t.Fatalf("Expected no pending fan-out, got %d", pending)
+	}
+	assertUserTimelines := func(store *Store) {
+		t.Helper()
+		for _, userID := range userIDs {
+			entries, _ := store.GetUserMessagesAfter(userID, 0, 0)
+			if len(entries) != messages {
+				t.Fatalf("Expected %d entries for %s, got %d", messages, userID, len(entries))
+			}
+			// 同一用户的记录保持会话中的顺序
+			for i, entry := range entries {
+				if entry.SeqID != int64(i+1) || entry.ConvSeqID != int64(i+1) {
+					t.Fatalf("Unexpected entry %d for %s: SeqID %d ConvSeqID %d", i, userID, entry.SeqID, entry.ConvSeqID)
+				}
+			}
+		}
+	}
+	assertUserTimelines(store)
+
+	// 关闭前未处理完的记录在Close中写入
+	for i := messages + 1; i <= messages+10; i++ {
+		if _, err := store.AppendMessage("other", 1, []byte(fmt.Sprintf("message-%d", i)), userIDs[:1]); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if last := reopened.GetOrCreateUserTimeline(userIDs[0]).LastSeqID; last != messages+10 {
+		t.Errorf("Expected %s to have %d entries after reopen, got %d", userIDs[0], messages+10, last)
+	}
+	userIDs = userIDs[1:]
+	assertUserTimelines(reopened)
+}
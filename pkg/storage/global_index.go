@@ -59,7 +59,7 @@ type StoreLoadInfo struct {
 
 // IndexEvent 索引事件
 type IndexEvent struct {
-	Type        string             `json:"type"`        // 事件类型: add, remove, update, migrate
+	Type        string             `json:"type"`        // 事件类型: add, remove, update, migrate, split
 	TimelineKey string             `json:"timelineKey"`
 	Index       *GlobalStoreIndex  `json:"index"`
 	OldStoreID  string             `json:"oldStoreId,omitempty"` // 迁移时的原Store ID
@@ -72,6 +72,7 @@ type InMemoryGlobalIndex struct {
 	storeIndex    map[string]map[string]*GlobalStoreIndex // StoreID -> TimelineKey -> Index
 	loadInfo      map[string]*StoreLoadInfo               // StoreID -> LoadInfo
 	watchers      map[string][]chan IndexEvent            // TimelineKey -> Watchers
+	splits        map[string][]*TimelineSegment           // TimelineKey -> 拆分后的各段
 }
 
 // NewInMemoryGlobalIndex 创建内存全局索引管理器
@@ -81,6 +82,7 @@ func NewInMemoryGlobalIndex() *InMemoryGlobalIndex {
 		storeIndex:    make(map[string]map[string]*GlobalStoreIndex),
 		loadInfo:      make(map[string]*StoreLoadInfo),
 		watchers:      make(map[string][]chan IndexEvent),
+		splits:        make(map[string][]*TimelineSegment),
 	}
 }
 
@@ -223,12 +225,9 @@ func (g *InMemoryGlobalIndex) ListTimelinesByStore(ctx context.Context, storeID
 	}
 	
 	timelineSet := make(map[string]bool)
-	for key := range storeIndexes {
-		// 从 "timelineKey:blockID" 格式中提取timelineKey
-		parts := splitTimelineKey(key)
-		if len(parts) > 0 {
-			timelineSet[parts[0]] = true
-		}
+	for _, index := range storeIndexes {
+		// Timeline键本身可能含有":"，不能从 "timelineKey:blockID" 中解析
+		timelineSet[index.TimelineKey] = true
 	}
 	
 	timelines := make([]string, 0, len(timelineSet))
@@ -356,6 +355,27 @@ func (g *InMemoryGlobalIndex) GetStoreLoad(ctx context.Context, storeID string)
 	return loadInfo, nil
 }
 
+// GetTimelineSplits 获取Timeline的拆分表，未拆分时返回nil
+func (g *InMemoryGlobalIndex) GetTimelineSplits(ctx context.Context, timelineKey string) ([]*TimelineSegment, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return copySegments(g.splits[timelineKey]), nil
+}
+
+// SetTimelineSplits 替换Timeline的拆分表
+func (g *InMemoryGlobalIndex) SetTimelineSplits(ctx context.Context, timelineKey string, segments []*TimelineSegment) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	
+	if len(segments) == 0 {
+		delete(g.splits, timelineKey)
+	} else {
+		g.splits[timelineKey] = copySegments(segments)
+	}
+	g.notifyWatchers(timelineKey, IndexEvent{Type: "split", TimelineKey: timelineKey})
+	return nil
+}
+
 // Watch 监听索引变化
 func (g *InMemoryGlobalIndex) Watch(ctx context.Context, timelineKey string) (<-chan IndexEvent, error) {
 	g.mu.Lock()
@@ -410,11 +430,8 @@ func (g *InMemoryGlobalIndex) updateStoreLoad(storeID string) {
 	var totalSize int64
 	blockCount := len(storeIndexes)
 	
-	for key, index := range storeIndexes {
-		parts := splitTimelineKey(key)
-		if len(parts) > 0 {
-			timelineSet[parts[0]] = true
-		}
+	for _, index := range storeIndexes {
+		timelineSet[index.TimelineKey] = true
 		totalSize += index.Size
 	}
 	
@@ -442,20 +459,3 @@ func (g *InMemoryGlobalIndex) notifyWatchers(timelineKey string, event IndexEven
 		}
 	}
 }
-
-// splitTimelineKey 分割Timeline键
-func splitTimelineKey(key string) []string {
-	// 简单的字符串分割实现
-	result := make([]string, 0)
-	start := 0
-	for i, char := range key {
-		if char == ':' {
-			result = append(result, key[start:i])
-			start = i + 1
-		}
-	}
-	if start < len(key) {
-		result = append(result, key[start:])
-	}
-	return result
-}
\ No newline at end of file
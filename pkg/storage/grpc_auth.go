@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// gRPC调用的认证
+// 与HTTP传输共用RPCAuthenticator：签名字段放在gRPC元数据中，调用方证书取自对端TLS状态，
+// 转换为http.Request后交给同一个认证器校验。
+// 一元调用的签名内容为方法名加确定性序列化的请求，流式调用在建立时签名，签名内容只有方法名，
+// 流中的消息依靠传输层保护
+
+var rpcAuthHeaders = []string{headerRPCStoreID, headerRPCRequestID, headerRPCTimestamp, headerRPCNonce, headerRPCSignature}
+
+// grpcSigningBody 返回gRPC调用的签名内容，req为nil时只有方法名
+func grpcSigningBody(method string, req any) ([]byte, error) {
+	body := []byte(method + "\n")
+	if req == nil {
+		return body, nil
+	}
+	message, ok := req.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("cannot sign non-protobuf request %T", req)
+	}
+	return proto.MarshalOptions{Deterministic: true}.MarshalAppend(body, message)
+}
+
+// grpcAuthRequest 把gRPC调用的元数据与对端TLS状态转换为认证器使用的请求
+func grpcAuthRequest(ctx context.Context, method string) *http.Request {
+	r := &http.Request{Method: http.MethodPost, URL: &url.URL{Path: method}, Header: make(http.Header)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, name := range rpcAuthHeaders {
+			if values := md.Get(name); len(values) > 0 {
+				r.Header.Set(name, values[0])
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if p.Addr != nil {
+			r.RemoteAddr = p.Addr.String()
+		}
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			state := info.State
+			r.TLS = &state
+		}
+	}
+	return r.WithContext(ctx)
+}
+
+// authenticateGRPC 先校验元数据再校验签名，返回带调用方身份的上下文
+func authenticateGRPC(ctx context.Context, auth RPCAuthenticator, method string, req any) (context.Context, error) {
+	r := grpcAuthRequest(ctx, method)
+	reject := func(err error) (context.Context, error) {
+		log.Printf("rejected rpc %s from %s: %v", method, r.RemoteAddr, err)
+		return nil, status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+	if verifier, ok := auth.(rpcHeaderVerifier); ok {
+		if err := verifier.VerifyHeaders(r); err != nil {
+			return reject(err)
+		}
+	}
+	body, err := grpcSigningBody(method, req)
+	if err != nil {
+		return reject(err)
+	}
+	caller, err := auth.Authenticate(r, body)
+	if err != nil {
+		return reject(err)
+	}
+	return context.WithValue(ctx, rpcCallerKey{}, caller), nil
+}
+
+// rpcAuthUnaryServerInterceptor 认证一元调用，失败返回Unauthenticated
+func rpcAuthUnaryServerInterceptor(auth RPCAuthenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticateGRPC(ctx, auth, info.FullMethod, req)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// authServerStream 替换流的上下文，使处理器拿到调用方身份
+type authServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// rpcAuthStreamServerInterceptor 在流建立时认证，失败返回Unauthenticated
+func rpcAuthStreamServerInterceptor(auth RPCAuthenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticateGRPC(ss.Context(), auth, info.FullMethod, nil)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authServerStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// signGRPCContext 把签名字段放入发出调用的元数据
+func signGRPCContext(ctx context.Context, signer *RPCSigner, body []byte) (context.Context, error) {
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	r := &http.Request{Header: make(http.Header)}
+	if err := signer.Sign(r, requestID, body); err != nil {
+		return nil, err
+	}
+	pairs := make([]string, 0, 2*len(rpcAuthHeaders))
+	for _, name := range rpcAuthHeaders {
+		pairs = append(pairs, strings.ToLower(name), r.Header.Get(name))
+	}
+	return metadata.AppendToOutgoingContext(ctx, pairs...), nil
+}
+
+// signingUnaryClientInterceptor 为每次一元调用签名
+func signingUnaryClientInterceptor(signer *RPCSigner) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		body, err := grpcSigningBody(method, req)
+		if err != nil {
+			return err
+		}
+		ctx, err = signGRPCContext(ctx, signer, body)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// signingStreamClientInterceptor 在建立流时签名
+func signingStreamClientInterceptor(signer *RPCSigner) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		body, err := grpcSigningBody(method, nil)
+		if err != nil {
+			return nil, err
+		}
+		ctx, err = signGRPCContext(ctx, signer, body)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// checkAuthTransport 检查传输能否提供认证方式需要的信息：
+// 双向TLS认证需要服务端要求并验证客户端证书，否则所有调用都会被拒绝或无法认证，拒绝启动
+func checkAuthTransport(auth RPCAuthenticator, config *tls.Config) error {
+	if _, ok := auth.(*TLSIdentityAuthenticator); !ok {
+		return nil
+	}
+	if config == nil || config.ClientAuth < tls.VerifyClientCertIfGiven {
+		return errors.New("tls identity authentication needs a TLS config that verifies client certificates")
+	}
+	return nil
+}
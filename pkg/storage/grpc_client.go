@@ -0,0 +1,562 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"imy/pkg/storage/storepb"
+)
+
+// RPCTransport Store间RPC传输方式
+type RPCTransport string
+
+const (
+	TransportHTTP RPCTransport = "http" // HTTP JSON传输
+	TransportGRPC RPCTransport = "grpc" // gRPC传输
+)
+
+// StoreBlockStreamer 支持流式导出与导入Timeline块的接口，用于块级迁移
+type StoreBlockStreamer interface {
+	StreamTimelineBlocks(ctx context.Context, req *StreamTimelineBlocksRequest, fn func(*TimelineBlockData) error) error
+	ImportTimelineBlocks(ctx context.Context, fn func(send func(*TimelineBlockData) error) error) (*ImportTimelineBlocksResponse, error)
+}
+
+// StoreMessageStreamer 支持按SeqID范围流式读取消息的接口，用于导出与迁移大范围消息
+// fn按SeqID升序逐条调用，返回错误时终止；接收方只需保留当前消息，内存占用与消息总数无关
+type StoreMessageStreamer interface {
+	StreamMessages(ctx context.Context, req *StreamMessagesRequest, fn func(*Message) error) error
+}
+
+var (
+	_ StoreRPCClient       = (*GRPCStoreRPCClient)(nil)
+	_ StoreBlockStreamer   = (*GRPCStoreRPCClient)(nil)
+	_ StoreMessageStreamer = (*GRPCStoreRPCClient)(nil)
+	_ StoreRPCService      = (*LocalStoreService)(nil)
+	_ StoreBlockStreamer   = (*LocalStoreService)(nil)
+	_ StoreMessageStreamer = (*LocalStoreService)(nil)
+	_ StoreMessageStreamer = (*HTTPStoreRPCClient)(nil)
+
+	_ StoreTransactionParticipant = (*GRPCStoreRPCClient)(nil)
+	_ StoreTransactionParticipant = (*HTTPStoreRPCClient)(nil)
+	_ StoreTransactionParticipant = (*LocalStoreService)(nil)
+)
+
+// NewStoreRPCClient 根据传输方式创建RPC客户端
+func NewStoreRPCClient(transport RPCTransport, timeout time.Duration) (StoreRPCClient, error) {
+	return NewStoreRPCClientWithTLS(transport, timeout, nil)
+}
+
+// NewStoreRPCClientWithTLS 根据传输方式创建RPC客户端，tlsConfig为nil时使用明文连接
+func NewStoreRPCClientWithTLS(transport RPCTransport, timeout time.Duration, tlsConfig *tls.Config) (StoreRPCClient, error) {
+	switch transport {
+	case "", TransportHTTP:
+		client := NewHTTPStoreRPCClient(timeout)
+		if tlsConfig != nil {
+			client.SetTLSConfig(tlsConfig)
+		}
+		return client, nil
+	case TransportGRPC:
+		if tlsConfig != nil {
+			return NewGRPCStoreRPCClient(timeout, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))), nil
+		}
+		return NewGRPCStoreRPCClient(timeout), nil
+	default:
+		return nil, fmt.Errorf("unsupported rpc transport: %s", transport)
+	}
+}
+
+// GRPCStoreRPCClient gRPC实现的Store RPC客户端
+type GRPCStoreRPCClient struct {
+	mu        sync.RWMutex
+	address   string
+	conn      *grpc.ClientConn
+	client    storepb.StoreRPCClient
+	connected bool
+	timeout   time.Duration
+	options   []grpc.DialOption
+	signer    *RPCSigner
+}
+
+// NewGRPCStoreRPCClient 创建gRPC RPC客户端
+func NewGRPCStoreRPCClient(timeout time.Duration, options ...grpc.DialOption) *GRPCStoreRPCClient {
+	if len(options) == 0 {
+		options = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+	return &GRPCStoreRPCClient{
+		timeout: timeout,
+		options: options,
+	}
+}
+
+// SetRequestSigner 设置共享密钥签名器，之后Connect建立的连接为每次调用签名
+func (c *GRPCStoreRPCClient) SetRequestSigner(signer *RPCSigner) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.signer = signer
+}
+
+// Connect 连接到Store服务
+func (c *GRPCStoreRPCClient) Connect(ctx context.Context, address string) error {
+	unary := []grpc.UnaryClientInterceptor{tracingUnaryClientInterceptor, statusErrorUnaryClientInterceptor}
+	stream := []grpc.StreamClientInterceptor{tracingStreamClientInterceptor, statusErrorStreamClientInterceptor}
+	c.mu.RLock()
+	if c.signer != nil {
+		unary = append(unary, signingUnaryClientInterceptor(c.signer))
+		stream = append(stream, signingStreamClientInterceptor(c.signer))
+	}
+	c.mu.RUnlock()
+	options := append([]grpc.DialOption{
+		grpc.WithChainUnaryInterceptor(unary...),
+		grpc.WithChainStreamInterceptor(stream...),
+	}, c.options...)
+	conn, err := grpc.NewClient(address, options...)
+	if err != nil {
+		return fmt.Errorf("failed to dial %s: %w", address, err)
+	}
+	client := storepb.NewStoreRPCClient(conn)
+
+	// 测试连接
+	callCtx, cancel := c.callContext(ctx)
+	defer cancel()
+	if _, err := client.HealthCheck(callCtx, &storepb.HealthCheckRequest{Ping: "ping"}); err != nil {
+		conn.Close()
+		return fmt.Errorf("health check failed: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.address = address
+	c.conn = conn
+	c.client = client
+	c.connected = true
+	return nil
+}
+
+// Disconnect 断开连接
+func (c *GRPCStoreRPCClient) Disconnect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.connected = false
+	c.client = nil
+	if c.conn != nil {
+		err := c.conn.Close()
+		c.conn = nil
+		return err
+	}
+	return nil
+}
+
+// IsConnected 检查连接状态
+func (c *GRPCStoreRPCClient) IsConnected() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.connected
+}
+
+// stub 获取当前连接的gRPC客户端
+func (c *GRPCStoreRPCClient) stub() (storepb.StoreRPCClient, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if !c.connected {
+		return nil, fmt.Errorf("client not connected")
+	}
+	return c.client, nil
+}
+
+// callContext 为单次调用附加超时
+func (c *GRPCStoreRPCClient) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// GetTimeline 获取Timeline
+func (c *GRPCStoreRPCClient) GetTimeline(ctx context.Context, req *GetTimelineRequest) (*GetTimelineResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.GetTimeline(ctx, &storepb.GetTimelineRequest{TimelineKey: req.TimelineKey})
+	if err != nil {
+		return nil, err
+	}
+	return &GetTimelineResponse{
+		Timeline: timelineFromPB(resp.GetTimeline()),
+		Exists:   resp.GetExists(),
+	}, nil
+}
+
+// CreateTimeline 创建Timeline
+func (c *GRPCStoreRPCClient) CreateTimeline(ctx context.Context, req *CreateTimelineRequest) (*CreateTimelineResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.CreateTimeline(ctx, &storepb.CreateTimelineRequest{
+		TimelineKey: req.TimelineKey,
+		Metadata:    metadataToPB(req.Metadata),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CreateTimelineResponse{
+		Timeline: timelineFromPB(resp.GetTimeline()),
+		Created:  resp.GetCreated(),
+	}, nil
+}
+
+// DeleteTimeline 删除Timeline
+func (c *GRPCStoreRPCClient) DeleteTimeline(ctx context.Context, req *DeleteTimelineRequest) (*DeleteTimelineResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.DeleteTimeline(ctx, &storepb.DeleteTimelineRequest{
+		TimelineKey: req.TimelineKey,
+		Force:       req.Force,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteTimelineResponse{Deleted: resp.GetDeleted()}, nil
+}
+
+// MigrateTimeline 迁移Timeline
+func (c *GRPCStoreRPCClient) MigrateTimeline(ctx context.Context, req *MigrateTimelineRequest) (*MigrateTimelineResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.MigrateTimeline(ctx, &storepb.MigrateTimelineRequest{
+		TimelineKey:   req.TimelineKey,
+		TargetStoreId: req.TargetStoreID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MigrateTimelineResponse{
+		Success:        resp.GetSuccess(),
+		MigratedBlocks: resp.GetMigratedBlocks(),
+	}, nil
+}
+
+// AddMessage 添加消息
+func (c *GRPCStoreRPCClient) AddMessage(ctx context.Context, req *AddMessageRequest) (*AddMessageResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.AddMessage(ctx, &storepb.AddMessageRequest{
+		TimelineKey: req.TimelineKey,
+		Message:     messageToPB(req.Message),
+		UserIds:     req.UserIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &AddMessageResponse{
+		BlockID:   resp.GetBlockId(),
+		Offset:    resp.GetOffset(),
+		MessageID: resp.GetMessageId(),
+		HLC:       resp.GetHlc(),
+		SeqID:     resp.GetSeqId(),
+		Duplicate: resp.GetDuplicate(),
+	}, nil
+}
+
+// GetMessages 获取消息
+func (c *GRPCStoreRPCClient) GetMessages(ctx context.Context, req *GetMessagesRequest) (*GetMessagesResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.GetMessages(ctx, &storepb.GetMessagesRequest{
+		TimelineKey: req.TimelineKey,
+		StartTime:   req.StartTime,
+		EndTime:     req.EndTime,
+		Limit:       int32(req.Limit),
+		Offset:      int32(req.Offset),
+		BeforeSeqId: req.BeforeSeqID,
+		AfterSeqId:  req.AfterSeqID,
+		StartHlc:    req.StartHLC,
+		EndHlc:      req.EndHLC,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &GetMessagesResponse{
+		Messages:   messagesFromPB(resp.GetMessages()),
+		Total:      int(resp.GetTotal()),
+		HasMore:    resp.GetHasMore(),
+		NextCursor: resp.GetNextCursor(),
+	}, nil
+}
+
+// EditMessage 编辑消息
+func (c *GRPCStoreRPCClient) EditMessage(ctx context.Context, req *EditMessageRequest) (*EditMessageResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.EditMessage(ctx, &storepb.EditMessageRequest{
+		TimelineKey: req.TimelineKey,
+		SeqId:       req.SeqID,
+		SenderId:    req.SenderID,
+		Data:        req.Data,
+		UserIds:     req.UserIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &EditMessageResponse{Message: messageFromPB(resp.GetMessage())}, nil
+}
+
+// DeleteMessage 删除消息
+func (c *GRPCStoreRPCClient) DeleteMessage(ctx context.Context, req *DeleteMessageRequest) (*DeleteMessageResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.DeleteMessage(ctx, &storepb.DeleteMessageRequest{
+		TimelineKey: req.TimelineKey,
+		SeqId:       req.SeqID,
+		SenderId:    req.SenderID,
+		UserIds:     req.UserIDs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteMessageResponse{Message: messageFromPB(resp.GetMessage())}, nil
+}
+
+// GetTimelineBlock 获取Timeline块
+func (c *GRPCStoreRPCClient) GetTimelineBlock(ctx context.Context, req *GetTimelineBlockRequest) (*GetTimelineBlockResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.GetTimelineBlock(ctx, &storepb.GetTimelineBlockRequest{BlockId: req.BlockID})
+	if err != nil {
+		return nil, err
+	}
+	return &GetTimelineBlockResponse{
+		Block:  blockFromPB(resp.GetBlock()),
+		Exists: resp.GetExists(),
+	}, nil
+}
+
+// StreamTimelineBlocks 流式拉取Timeline的所有块，每收到一个块调用一次fn
+// 流式传输不受单次调用超时限制，由调用方通过ctx控制
+func (c *GRPCStoreRPCClient) StreamTimelineBlocks(ctx context.Context, req *StreamTimelineBlocksRequest, fn func(*TimelineBlockData) error) error {
+	client, err := c.stub()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.StreamTimelineBlocks(ctx, &storepb.StreamTimelineBlocksRequest{
+		TimelineKey:  req.TimelineKey,
+		AfterBlockId: req.AfterBlockID,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		data, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(blockDataFromPB(data)); err != nil {
+			return err
+		}
+	}
+}
+
+// StreamMessages 流式读取消息，基于StreamTimelineBlocks逐块接收并按SeqID范围过滤
+// 同一时刻只持有一个块的消息；越过BeforeSeqID后取消流，不再传输后面的块
+func (c *GRPCStoreRPCClient) StreamMessages(ctx context.Context, req *StreamMessagesRequest, fn func(*Message) error) error {
+	errDone := errors.New("stream messages done")
+	err := c.StreamTimelineBlocks(ctx, &StreamTimelineBlocksRequest{TimelineKey: req.TimelineKey}, func(data *TimelineBlockData) error {
+		for _, msg := range data.Messages {
+			if msg.SeqID <= req.AfterSeqID {
+				continue
+			}
+			if req.BeforeSeqID > 0 && msg.SeqID >= req.BeforeSeqID {
+				return errDone
+			}
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errDone) {
+		return nil
+	}
+	return err
+}
+
+// ImportTimelineBlocks 流式推送块到目标Store，fn通过send逐个发送块
+// fn返回错误时取消流，目标Store不会导入任何块
+func (c *GRPCStoreRPCClient) ImportTimelineBlocks(ctx context.Context, fn func(send func(*TimelineBlockData) error) error) (*ImportTimelineBlocksResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := client.ImportTimelineBlocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 服务端提前结束时Send返回io.EOF，真实错误由CloseAndRecv返回
+	if err := fn(func(data *TimelineBlockData) error {
+		return stream.Send(blockDataToPB(data))
+	}); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return nil, err
+	}
+	return &ImportTimelineBlocksResponse{
+		TimelineKey:      resp.GetTimelineKey(),
+		ImportedBlocks:   resp.GetImportedBlocks(),
+		ImportedMessages: resp.GetImportedMessages(),
+		LastSeqID:        resp.GetLastSeqId(),
+	}, nil
+}
+
+// GetStoreStats 获取Store统计
+func (c *GRPCStoreRPCClient) GetStoreStats(ctx context.Context, req *GetStoreStatsRequest) (*GetStoreStatsResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.GetStoreStats(ctx, &storepb.GetStoreStatsRequest{IncludeTimelines: req.IncludeTimelines})
+	if err != nil {
+		return nil, err
+	}
+	return &GetStoreStatsResponse{
+		StoreID:       resp.GetStoreId(),
+		TimelineCount: int(resp.GetTimelineCount()),
+		BlockCount:    int(resp.GetBlockCount()),
+		TotalSize:     resp.GetTotalSize(),
+		Timelines:     resp.GetTimelines(),
+		Uptime:        resp.GetUptime(),
+		LastUpdate:    resp.GetLastUpdate(),
+		MaxCapacity:   resp.GetMaxCapacity(),
+		BlockBytes:    resp.GetBlockBytes(),
+		WALBytes:      resp.GetWalBytes(),
+	}, nil
+}
+
+// HealthCheck 健康检查
+func (c *GRPCStoreRPCClient) HealthCheck(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := client.HealthCheck(ctx, &storepb.HealthCheckRequest{Ping: req.Ping})
+	if err != nil {
+		return nil, err
+	}
+	return &HealthCheckResponse{
+		Pong:      resp.GetPong(),
+		Status:    resp.GetStatus(),
+		Timestamp: resp.GetTimestamp(),
+	}, nil
+}
+
+// PrepareTransaction 请求参与者准备事务中的操作
+func (c *GRPCStoreRPCClient) PrepareTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.transaction(ctx, req, storepb.StoreRPCClient.PrepareTransaction)
+}
+
+// CommitTransaction 请求参与者提交事务中的操作
+func (c *GRPCStoreRPCClient) CommitTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.transaction(ctx, req, storepb.StoreRPCClient.CommitTransaction)
+}
+
+// AbortTransaction 请求参与者回滚事务中的操作
+func (c *GRPCStoreRPCClient) AbortTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.transaction(ctx, req, storepb.StoreRPCClient.AbortTransaction)
+}
+
+func (c *GRPCStoreRPCClient) transaction(ctx context.Context, req *TransactionRequest, call func(storepb.StoreRPCClient, context.Context, *storepb.TransactionRequest, ...grpc.CallOption) (*storepb.TransactionResponse, error)) (*TransactionResponse, error) {
+	client, err := c.stub()
+	if err != nil {
+		return nil, err
+	}
+	pbReq, err := transactionRequestToPB(req)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.callContext(ctx)
+	defer cancel()
+
+	resp, err := call(client, ctx, pbReq)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionResponse{
+		Success: resp.GetSuccess(),
+		Code:    int(resp.GetCode()),
+		Error:   resp.GetError(),
+	}, nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestGRPCStoreRPCRoundTrip(t *testing.T) {
+	ctx := context.Background()
+
+	remote, err := NewStore(&StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 2,
+		DataDir:         t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create remote store: %v", err)
+	}
+
+	// 预留一个空闲端口
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(remote)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	pool := NewStoreRPCClientPoolWithTransport(TransportGRPC, 5*time.Second)
+	defer pool.Close()
+
+	client, err := pool.GetClient(ctx, remote.StoreID, address)
+	if err != nil {
+		t.Fatalf("Failed to connect gRPC client: %v", err)
+	}
+
+	timelineKey := "conv_grpc"
+	for i := 0; i < 5; i++ {
+		msg := &Message{
+			SeqID:      int64(i + 1),
+			ConvID:     timelineKey,
+			SenderID:   1,
+			CreateTime: time.Now(),
+			Data:       []byte("grpc"),
+		}
+		if _, err := client.AddMessage(ctx, &AddMessageRequest{TimelineKey: timelineKey, Message: msg}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	msgs, err := client.GetMessages(ctx, &GetMessagesRequest{TimelineKey: timelineKey, Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(msgs.Messages) != 5 || string(msgs.Messages[0].Data) != "grpc" {
+		t.Fatalf("Unexpected messages: %+v", msgs.Messages)
+	}
+
+	tl, err := client.GetTimeline(ctx, &GetTimelineRequest{TimelineKey: timelineKey})
+	if err != nil || !tl.Exists {
+		t.Fatalf("Failed to get timeline: %v", err)
+	}
+
+	streamer, ok := client.(StoreBlockStreamer)
+	if !ok {
+		t.Fatal("gRPC client should support block streaming")
+	}
+
+	var blocks, messages int
+	err = streamer.StreamTimelineBlocks(ctx, &StreamTimelineBlocksRequest{TimelineKey: timelineKey}, func(data *TimelineBlockData) error {
+		blocks++
+		messages += len(data.Messages)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream blocks: %v", err)
+	}
+	if blocks != len(tl.Timeline.Blocks) || messages != 5 {
+		t.Errorf("Expected %d blocks with 5 messages, got %d blocks with %d messages", len(tl.Timeline.Blocks), blocks, messages)
+	}
+
+	err = streamer.StreamTimelineBlocks(ctx, &StreamTimelineBlocksRequest{TimelineKey: "conv_missing"}, func(*TimelineBlockData) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("Expected streaming a missing timeline to fail")
+	}
+
+	var seqIDs []int64
+	err = client.(StoreMessageStreamer).StreamMessages(ctx, &StreamMessagesRequest{TimelineKey: timelineKey, AfterSeqID: 1, BeforeSeqID: 5}, func(msg *Message) error {
+		seqIDs = append(seqIDs, msg.SeqID)
+		return nil
+	})
+	if err != nil || fmt.Sprint(seqIDs) != "[2 3 4]" {
+		t.Errorf("Expected SeqIDs [2 3 4], got %v %v", seqIDs, err)
+	}
+}
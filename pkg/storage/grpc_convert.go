@@ -0,0 +1,212 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"imy/pkg/storage/storepb"
+)
+
+// Store内部结构与protobuf消息之间的转换
+
+func messageToPB(msg *Message) *storepb.Message {
+	if msg == nil {
+		return nil
+	}
+	return &storepb.Message{
+		SeqId:        msg.SeqID,
+		ConvId:       msg.ConvID,
+		SenderId:     msg.SenderID,
+		CreateTime:   msg.CreateTime.UnixNano(),
+		Data:         msg.Data,
+		Type:         int32(msg.Type),
+		RefSeqId:     msg.RefSeqID,
+		ConvSeqId:    msg.ConvSeqID,
+		Hlc:          msg.HLC,
+		ClientMsgId:  msg.ClientMsgID,
+		Mentions:     msg.Mentions,
+		ReplyToSeqId: msg.ReplyToSeqID,
+	}
+}
+
+func messageFromPB(msg *storepb.Message) *Message {
+	if msg == nil {
+		return nil
+	}
+	return &Message{
+		SeqID:        msg.GetSeqId(),
+		ConvID:       msg.GetConvId(),
+		SenderID:     msg.GetSenderId(),
+		CreateTime:   time.Unix(0, msg.GetCreateTime()),
+		Data:         msg.GetData(),
+		Type:         MsgType(msg.GetType()),
+		RefSeqID:     msg.GetRefSeqId(),
+		ConvSeqID:    msg.GetConvSeqId(),
+		HLC:          msg.GetHlc(),
+		ClientMsgID:  msg.GetClientMsgId(),
+		Mentions:     msg.GetMentions(),
+		ReplyToSeqID: msg.GetReplyToSeqId(),
+	}
+}
+
+func messagesToPB(msgs []*Message) []*storepb.Message {
+	result := make([]*storepb.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		result = append(result, messageToPB(msg))
+	}
+	return result
+}
+
+func messagesFromPB(msgs []*storepb.Message) []*Message {
+	result := make([]*Message, 0, len(msgs))
+	for _, msg := range msgs {
+		result = append(result, messageFromPB(msg))
+	}
+	return result
+}
+
+func blockToPB(block *TimelineBlock) *storepb.TimelineBlock {
+	if block == nil {
+		return nil
+	}
+	block.mu.RLock()
+	defer block.mu.RUnlock()
+	return &storepb.TimelineBlock{
+		BlockId:  block.BlockID,
+		StoreId:  block.StoreID,
+		Offset:   block.Offset,
+		Size:     block.Size,
+		IsFull:   block.IsFull,
+		Checksum: block.Checksum,
+	}
+}
+
+func blockFromPB(block *storepb.TimelineBlock) *TimelineBlock {
+	if block == nil {
+		return nil
+	}
+	return &TimelineBlock{
+		BlockID:  block.GetBlockId(),
+		StoreID:  block.GetStoreId(),
+		Offset:   block.GetOffset(),
+		Size:     block.GetSize(),
+		IsFull:   block.GetIsFull(),
+		Checksum: block.GetChecksum(),
+	}
+}
+
+func timelineToPB(tl *Timeline) *storepb.Timeline {
+	if tl == nil {
+		return nil
+	}
+	tl.mu.RLock()
+	defer tl.mu.RUnlock()
+	blocks := make([]*storepb.TimelineBlock, 0, len(tl.Blocks))
+	for _, block := range tl.Blocks {
+		blocks = append(blocks, blockToPB(block))
+	}
+	return &storepb.Timeline{
+		Id:        tl.ID,
+		Type:      tl.Type,
+		Blocks:    blocks,
+		LastSeqId: tl.LastSeqID,
+	}
+}
+
+func timelineFromPB(tl *storepb.Timeline) *Timeline {
+	if tl == nil {
+		return nil
+	}
+	blocks := make([]*TimelineBlock, 0, len(tl.GetBlocks()))
+	for _, block := range tl.GetBlocks() {
+		blocks = append(blocks, blockFromPB(block))
+	}
+	return &Timeline{
+		ID:        tl.GetId(),
+		Type:      tl.GetType(),
+		Blocks:    blocks,
+		LastSeqID: tl.GetLastSeqId(),
+	}
+}
+
+func blockDataToPB(data *TimelineBlockData) *storepb.TimelineBlockData {
+	return &storepb.TimelineBlockData{
+		TimelineKey:  data.TimelineKey,
+		TimelineType: data.TimelineType,
+		Block:        blockToPB(data.Block),
+		Messages:     messagesToPB(data.Messages),
+	}
+}
+
+func blockDataFromPB(data *storepb.TimelineBlockData) *TimelineBlockData {
+	block := blockFromPB(data.GetBlock())
+	messages := messagesFromPB(data.GetMessages())
+	if block != nil {
+		block.Messages = messages
+	}
+	return &TimelineBlockData{
+		TimelineKey:  data.GetTimelineKey(),
+		TimelineType: data.GetTimelineType(),
+		Block:        block,
+		Messages:     messages,
+	}
+}
+
+// metadataToPB protobuf的map只支持字符串值，其他类型按fmt格式化
+func metadataToPB(metadata map[string]interface{}) map[string]string {
+	if metadata == nil {
+		return nil
+	}
+	result := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if str, ok := v.(string); ok {
+			result[k] = str
+		} else {
+			result[k] = fmt.Sprint(v)
+		}
+	}
+	return result
+}
+
+func metadataFromPB(metadata map[string]string) map[string]interface{} {
+	if metadata == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(metadata))
+	for k, v := range metadata {
+		result[k] = v
+	}
+	return result
+}
+
+// transactionRequestToPB 参与者参数是任意值的map，按JSON编码传输
+func transactionRequestToPB(req *TransactionRequest) (*storepb.TransactionRequest, error) {
+	params, err := json.Marshal(req.Participant.Params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal participant params: %w", err)
+	}
+	return &storepb.TransactionRequest{
+		TransactionId: req.TransactionID,
+		StoreId:       req.Participant.StoreID,
+		Operation:     int32(req.Participant.Operation),
+		Params:        params,
+	}, nil
+}
+
+func transactionRequestFromPB(req *storepb.TransactionRequest) (*TransactionRequest, error) {
+	var params map[string]interface{}
+	if len(req.GetParams()) > 0 {
+		if err := unmarshalRPCJSON(req.GetParams(), &params); err != nil {
+			return nil, fmt.Errorf("invalid participant params: %w", err)
+		}
+	}
+	return &TransactionRequest{
+		TransactionID: req.GetTransactionId(),
+		Participant: &TransactionParticipant{
+			StoreID:   req.GetStoreId(),
+			Operation: TransactionOperation(req.GetOperation()),
+			Params:    params,
+		},
+	}, nil
+}
@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	"imy/pkg/storage/storepb"
+)
+
+// GRPCStoreRPCServer gRPC实现的Store RPC服务端
+type GRPCStoreRPCServer struct {
+	storepb.UnimplementedStoreRPCServer
+
+	mu      sync.RWMutex
+	store   *Store
+	service *LocalStoreService
+	server  *grpc.Server
+	options []grpc.ServerOption
+	running bool
+
+	tlsConfig *tls.Config
+	auth      RPCAuthenticator
+}
+
+// NewGRPCStoreRPCServer 创建gRPC RPC服务端，启用TLS时调用SetTLSConfig或传入grpc.Creds(credentials.NewTLS(config))
+func NewGRPCStoreRPCServer(store *Store, options ...grpc.ServerOption) *GRPCStoreRPCServer {
+	return &GRPCStoreRPCServer{
+		store:   store,
+		service: NewLocalStoreService(store),
+		options: options,
+	}
+}
+
+// SetTLSConfig 设置TLS配置，配置中要求客户端证书时即为双向TLS，需在Start之前调用
+func (s *GRPCStoreRPCServer) SetTLSConfig(config *tls.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tlsConfig = config
+}
+
+// SetAuthenticator 设置调用的认证方式，为nil时接受任何调用方，需在Start之前调用
+// 双向TLS认证需先通过SetTLSConfig要求客户端证书，否则Start返回错误
+func (s *GRPCStoreRPCServer) SetAuthenticator(auth RPCAuthenticator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = auth
+}
+
+// Start 启动RPC服务
+func (s *GRPCStoreRPCServer) Start(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("server is already running")
+	}
+	if err := checkAuthTransport(s.auth, s.tlsConfig); err != nil {
+		return err
+	}
+
+	unary := []grpc.UnaryServerInterceptor{tracingUnaryServerInterceptor}
+	stream := []grpc.StreamServerInterceptor{tracingStreamServerInterceptor}
+	if s.auth != nil {
+		unary = append(unary, rpcAuthUnaryServerInterceptor(s.auth))
+		stream = append(stream, rpcAuthStreamServerInterceptor(s.auth))
+	}
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+	if s.tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(s.tlsConfig)))
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", address, err)
+	}
+
+	s.server = grpc.NewServer(append(options, s.options...)...)
+	storepb.RegisterStoreRPCServer(s.server, s)
+	s.running = true
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			log.Printf("gRPC server error: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// Stop 停止RPC服务，ctx到期前未完成优雅关闭则强制停止
+func (s *GRPCStoreRPCServer) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	s.running = false
+
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// IsRunning 检查服务是否运行中
+func (s *GRPCStoreRPCServer) IsRunning() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.running
+}
+
+// toStatusError 将服务层错误转换为gRPC状态错误，状态详情携带RPC错误码供客户端还原
+func toStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return rpcStatus(toRPCError(err), err.Error()).Err()
+}
+
+// Timeline操作
+
+// GetTimeline 获取Timeline
+func (s *GRPCStoreRPCServer) GetTimeline(ctx context.Context, req *storepb.GetTimelineRequest) (*storepb.GetTimelineResponse, error) {
+	resp, err := s.service.GetTimeline(ctx, &GetTimelineRequest{TimelineKey: req.GetTimelineKey()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.GetTimelineResponse{
+		Timeline: timelineToPB(resp.Timeline),
+		Exists:   resp.Exists,
+	}, nil
+}
+
+// CreateTimeline 创建Timeline
+func (s *GRPCStoreRPCServer) CreateTimeline(ctx context.Context, req *storepb.CreateTimelineRequest) (*storepb.CreateTimelineResponse, error) {
+	resp, err := s.service.CreateTimeline(ctx, &CreateTimelineRequest{
+		TimelineKey: req.GetTimelineKey(),
+		Metadata:    metadataFromPB(req.GetMetadata()),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.CreateTimelineResponse{
+		Timeline: timelineToPB(resp.Timeline),
+		Created:  resp.Created,
+	}, nil
+}
+
+// DeleteTimeline 删除Timeline
+func (s *GRPCStoreRPCServer) DeleteTimeline(ctx context.Context, req *storepb.DeleteTimelineRequest) (*storepb.DeleteTimelineResponse, error) {
+	resp, err := s.service.DeleteTimeline(ctx, &DeleteTimelineRequest{
+		TimelineKey: req.GetTimelineKey(),
+		Force:       req.GetForce(),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.DeleteTimelineResponse{Deleted: resp.Deleted}, nil
+}
+
+// MigrateTimeline 迁移Timeline
+func (s *GRPCStoreRPCServer) MigrateTimeline(ctx context.Context, req *storepb.MigrateTimelineRequest) (*storepb.MigrateTimelineResponse, error) {
+	resp, err := s.service.MigrateTimeline(ctx, &MigrateTimelineRequest{
+		TimelineKey:   req.GetTimelineKey(),
+		TargetStoreID: req.GetTargetStoreId(),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.MigrateTimelineResponse{
+		Success:        resp.Success,
+		MigratedBlocks: resp.MigratedBlocks,
+	}, nil
+}
+
+// 消息操作
+
+// AddMessage 添加消息
+func (s *GRPCStoreRPCServer) AddMessage(ctx context.Context, req *storepb.AddMessageRequest) (*storepb.AddMessageResponse, error) {
+	resp, err := s.service.AddMessage(ctx, &AddMessageRequest{
+		TimelineKey: req.GetTimelineKey(),
+		Message:     messageFromPB(req.GetMessage()),
+		UserIDs:     req.GetUserIds(),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.AddMessageResponse{
+		BlockId:   resp.BlockID,
+		Offset:    resp.Offset,
+		MessageId: resp.MessageID,
+		Hlc:       resp.HLC,
+		SeqId:     resp.SeqID,
+		Duplicate: resp.Duplicate,
+	}, nil
+}
+
+// GetMessages 获取消息
+func (s *GRPCStoreRPCServer) GetMessages(ctx context.Context, req *storepb.GetMessagesRequest) (*storepb.GetMessagesResponse, error) {
+	resp, err := s.service.GetMessages(ctx, &GetMessagesRequest{
+		TimelineKey: req.GetTimelineKey(),
+		StartTime:   req.GetStartTime(),
+		EndTime:     req.GetEndTime(),
+		Limit:       int(req.GetLimit()),
+		Offset:      int(req.GetOffset()),
+		BeforeSeqID: req.GetBeforeSeqId(),
+		AfterSeqID:  req.GetAfterSeqId(),
+		StartHLC:    req.GetStartHlc(),
+		EndHLC:      req.GetEndHlc(),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.GetMessagesResponse{
+		Messages:   messagesToPB(resp.Messages),
+		Total:      int32(resp.Total),
+		HasMore:    resp.HasMore,
+		NextCursor: resp.NextCursor,
+	}, nil
+}
+
+// EditMessage 编辑消息
+func (s *GRPCStoreRPCServer) EditMessage(ctx context.Context, req *storepb.EditMessageRequest) (*storepb.EditMessageResponse, error) {
+	resp, err := s.service.EditMessage(ctx, &EditMessageRequest{
+		TimelineKey: req.GetTimelineKey(),
+		SeqID:       req.GetSeqId(),
+		SenderID:    req.GetSenderId(),
+		Data:        req.GetData(),
+		UserIDs:     req.GetUserIds(),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.EditMessageResponse{Message: messageToPB(resp.Message)}, nil
+}
+
+// DeleteMessage 删除消息
+func (s *GRPCStoreRPCServer) DeleteMessage(ctx context.Context, req *storepb.DeleteMessageRequest) (*storepb.DeleteMessageResponse, error) {
+	resp, err := s.service.DeleteMessage(ctx, &DeleteMessageRequest{
+		TimelineKey: req.GetTimelineKey(),
+		SeqID:       req.GetSeqId(),
+		SenderID:    req.GetSenderId(),
+		UserIDs:     req.GetUserIds(),
+	})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.DeleteMessageResponse{Message: messageToPB(resp.Message)}, nil
+}
+
+// 块操作
+
+// GetTimelineBlock 获取Timeline块
+func (s *GRPCStoreRPCServer) GetTimelineBlock(ctx context.Context, req *storepb.GetTimelineBlockRequest) (*storepb.GetTimelineBlockResponse, error) {
+	resp, err := s.service.GetTimelineBlock(ctx, &GetTimelineBlockRequest{BlockID: req.GetBlockId()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.GetTimelineBlockResponse{
+		Block:  blockToPB(resp.Block),
+		Exists: resp.Exists,
+	}, nil
+}
+
+// StreamTimelineBlocks 流式导出Timeline的所有块
+func (s *GRPCStoreRPCServer) StreamTimelineBlocks(req *storepb.StreamTimelineBlocksRequest, stream grpc.ServerStreamingServer[storepb.TimelineBlockData]) error {
+	err := s.service.StreamTimelineBlocks(stream.Context(), &StreamTimelineBlocksRequest{
+		TimelineKey:  req.GetTimelineKey(),
+		AfterBlockID: req.GetAfterBlockId(),
+	}, func(data *TimelineBlockData) error {
+		return stream.Send(blockDataToPB(data))
+	})
+	return toStatusError(err)
+}
+
+// ImportTimelineBlocks 接收客户端流式发送的块，全部接收后一次性导入
+func (s *GRPCStoreRPCServer) ImportTimelineBlocks(stream grpc.ClientStreamingServer[storepb.TimelineBlockData, storepb.ImportTimelineBlocksResponse]) error {
+	resp, err := s.service.ImportTimelineBlocks(stream.Context(), func(send func(*TimelineBlockData) error) error {
+		for {
+			data, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := send(blockDataFromPB(data)); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		return toStatusError(err)
+	}
+	return stream.SendAndClose(&storepb.ImportTimelineBlocksResponse{
+		TimelineKey:      resp.TimelineKey,
+		ImportedBlocks:   resp.ImportedBlocks,
+		ImportedMessages: resp.ImportedMessages,
+		LastSeqId:        resp.LastSeqID,
+	})
+}
+
+// Store状态
+
+// GetStoreStats 获取Store统计
+func (s *GRPCStoreRPCServer) GetStoreStats(ctx context.Context, req *storepb.GetStoreStatsRequest) (*storepb.GetStoreStatsResponse, error) {
+	resp, err := s.service.GetStoreStats(ctx, &GetStoreStatsRequest{IncludeTimelines: req.GetIncludeTimelines()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.GetStoreStatsResponse{
+		StoreId:       resp.StoreID,
+		TimelineCount: int32(resp.TimelineCount),
+		BlockCount:    int32(resp.BlockCount),
+		TotalSize:     resp.TotalSize,
+		Timelines:     resp.Timelines,
+		Uptime:        resp.Uptime,
+		LastUpdate:    resp.LastUpdate,
+		MaxCapacity:   resp.MaxCapacity,
+		BlockBytes:    resp.BlockBytes,
+		WalBytes:      resp.WALBytes,
+	}, nil
+}
+
+// HealthCheck 健康检查
+func (s *GRPCStoreRPCServer) HealthCheck(ctx context.Context, req *storepb.HealthCheckRequest) (*storepb.HealthCheckResponse, error) {
+	resp, err := s.service.HealthCheck(ctx, &HealthCheckRequest{Ping: req.GetPing()})
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.HealthCheckResponse{
+		Pong:      resp.Pong,
+		Status:    resp.Status,
+		Timestamp: resp.Timestamp,
+	}, nil
+}
+
+// 事务参与者
+
+// SetTransactionHandler 设置执行其他Store协调的事务的参与者处理器
+func (s *GRPCStoreRPCServer) SetTransactionHandler(handler TransactionParticipantHandler) {
+	s.service.SetTransactionHandler(handler)
+}
+
+// PrepareTransaction 准备事务中本Store的操作
+func (s *GRPCStoreRPCServer) PrepareTransaction(ctx context.Context, req *storepb.TransactionRequest) (*storepb.TransactionResponse, error) {
+	return s.handleTransaction(ctx, req, s.service.PrepareTransaction)
+}
+
+// CommitTransaction 提交事务中本Store已准备的操作
+func (s *GRPCStoreRPCServer) CommitTransaction(ctx context.Context, req *storepb.TransactionRequest) (*storepb.TransactionResponse, error) {
+	return s.handleTransaction(ctx, req, s.service.CommitTransaction)
+}
+
+// AbortTransaction 回滚事务中本Store已准备的操作
+func (s *GRPCStoreRPCServer) AbortTransaction(ctx context.Context, req *storepb.TransactionRequest) (*storepb.TransactionResponse, error) {
+	return s.handleTransaction(ctx, req, s.service.AbortTransaction)
+}
+
+func (s *GRPCStoreRPCServer) handleTransaction(ctx context.Context, req *storepb.TransactionRequest, call func(context.Context, *TransactionRequest) (*TransactionResponse, error)) (*storepb.TransactionResponse, error) {
+	txnReq, err := transactionRequestFromPB(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	resp, err := call(ctx, txnReq)
+	if err != nil {
+		return nil, toStatusError(err)
+	}
+	return &storepb.TransactionResponse{
+		Success: resp.Success,
+		Code:    int32(resp.Code),
+		Error:   resp.Error,
+	}, nil
+}
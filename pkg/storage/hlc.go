@@ -0,0 +1,82 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// 混合逻辑时钟（HLC）
+// 时间戳高48位为Unix毫秒，低16位为同一毫秒内的逻辑计数。SeqID只在单个Timeline内有序，
+// 需要跨Store或跨Timeline比较先后时使用HLC：同一Store内严格递增，
+// 收到其他Store的时间戳后推进本地时钟，保证因果在后的记录HLC更大。
+// 每条消息写入时生成HLC并随块持久化，复制到其他Store时保持不变
+
+const hlcLogicalBits = 16
+
+// hybridClock 混合逻辑时钟
+type hybridClock struct {
+	mu   sync.Mutex
+	last int64
+	now  func() time.Time
+}
+
+// newHybridClock 以now为物理时钟创建HLC，now为nil时使用time.Now
+func newHybridClock(now func() time.Time) *hybridClock {
+	if now == nil {
+		now = time.Now
+	}
+	return &hybridClock{now: now}
+}
+
+// Now 生成一个大于之前所有时间戳的HLC
+func (c *hybridClock) Now() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	physical := c.now().UnixMilli() << hlcLogicalBits
+	if physical > c.last {
+		c.last = physical
+	} else {
+		c.last++
+	}
+	return c.last
+}
+
+// Observe 合并其他Store的HLC，之后生成的时间戳都大于remote
+func (c *hybridClock) Observe(remote int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if remote > c.last {
+		c.last = remote
+	}
+}
+
+// HLCTime 返回HLC中的物理时间部分
+func HLCTime(hlc int64) time.Time {
+	return time.UnixMilli(hlc >> hlcLogicalBits)
+}
+
+// messageHLC 消息的HLC，之前写入的没有HLC的消息按创建时间推算
+func messageHLC(msg *Message) int64 {
+	if msg.HLC > 0 {
+		return msg.HLC
+	}
+	return msg.CreateTime.UnixMilli() << hlcLogicalBits
+}
+
+// SortMessagesByHLC 按HLC对来自多个Store或Timeline的消息排序，
+// HLC相同时依次按ConvID、SeqID排序，保证任何节点得到相同的顺序
+func SortMessagesByHLC(messages []*Message) {
+	sort.SliceStable(messages, func(i, j int) bool {
+		a, b := messages[i], messages[j]
+		if ha, hb := messageHLC(a), messageHLC(b); ha != hb {
+			return ha < hb
+		}
+		if a.ConvID != b.ConvID {
+			return a.ConvID < b.ConvID
+		}
+		return a.SeqID < b.SeqID
+	})
+}
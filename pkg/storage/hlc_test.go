@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHybridClock(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	clock := &hybridClock{now: func() time.Time { return now }}
+
+	// 物理时间不变时逻辑计数递增
+	first := clock.Now()
+	second := clock.Now()
+	if second != first+1 || !HLCTime(second).Equal(now) {
+		t.Errorf("Expected logical tick, got %d then %d", first, second)
+	}
+
+	// 物理时间回拨时仍然递增
+	now = now.Add(-time.Second)
+	if third := clock.Now(); third <= second {
+		t.Errorf("Expected clock to stay monotonic, got %d after %d", third, second)
+	}
+
+	// 合并其他Store更大的时间戳
+	remote := time.UnixMilli(1_700_000_060_000).UnixMilli() << hlcLogicalBits
+	clock.Observe(remote)
+	if next := clock.Now(); next <= remote {
+		t.Errorf("Expected %d to follow remote %d", next, remote)
+	}
+}
+
+func TestMessagesCarryHLC(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// 不同会话的SeqID不可比较，HLC保持写入顺序
+	var last int64
+	for i := 0; i < 3; i++ {
+		for _, convID := range []string{"conv_x", "conv_y"} {
+			msg, err := store.AppendMessage(convID, 1, []byte("m"), []string{"1"})
+			if err != nil {
+				t.Fatalf("Failed to add message: %v", err)
+			}
+			if msg.HLC <= last {
+				t.Fatalf("Expected increasing HLC, got %d after %d", msg.HLC, last)
+			}
+			last = msg.HLC
+		}
+	}
+	if userMessages, _ := store.GetUserMessagesAfter("1", 0, 0); userMessages[5].HLC != last {
+		t.Errorf("Expected user copy to share the conv HLC")
+	}
+	store.Close()
+
+	// 重启后从WAL中的记录推进时钟
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	reopened.clock.now = func() time.Time { return time.Unix(0, 0) }
+	msg, err := reopened.AppendMessage("conv_x", 1, []byte("m"), nil)
+	if err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if msg.HLC <= last {
+		t.Errorf("Expected HLC after reopen to exceed %d, got %d", last, msg.HLC)
+	}
+}
+
+func TestConcurrentWritesKeepHLCInSeqOrder(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 24, TimelineMaxSize: 50, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// 并发写入同一会话，SeqID与HLC须在同一把锁内分配，否则两者顺序可能相反
+	const writers, perWriter = 8, 100
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := store.AppendMessage("conv_hlc", 1, []byte("m"), nil); err != nil {
+					t.Errorf("Failed to add message: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	messages, err := store.GetConvMessages("conv_hlc", writers*perWriter, 0)
+	if err != nil || len(messages) != writers*perWriter {
+		t.Fatalf("Expected %d messages, got %d: %v", writers*perWriter, len(messages), err)
+	}
+	for i := 1; i < len(messages); i++ {
+		if messages[i].SeqID != messages[i-1].SeqID+1 {
+			t.Fatalf("Expected consecutive SeqIDs, got %d after %d", messages[i].SeqID, messages[i-1].SeqID)
+		}
+		if messages[i].HLC <= messages[i-1].HLC {
+			t.Fatalf("HLC of SeqID %d (%d) is not after SeqID %d (%d)",
+				messages[i].SeqID, messages[i].HLC, messages[i-1].SeqID, messages[i-1].HLC)
+		}
+	}
+}
+
+func TestReplicatedMessagesKeepHLC(t *testing.T) {
+	ctx := context.Background()
+	primary, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer primary.Close()
+	replica, ts := newTestRemoteStore(t)
+	defer replica.Close()
+	// 副本的物理时钟落后于主Store
+	replica.clock.now = func() time.Time { return time.Unix(0, 0) }
+
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	var written []*Message
+	for _, data := range []string{"a", "b", "c"} {
+		msg, err := primary.AppendMessage("conv_r", 1, []byte(data), nil)
+		if err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+		resp, err := client.AddMessage(ctx, &AddMessageRequest{TimelineKey: "conv_r", Message: msg})
+		if err != nil {
+			t.Fatalf("Failed to replicate: %v", err)
+		}
+		if resp.HLC != msg.HLC {
+			t.Errorf("Expected replica to keep HLC %d, got %d", msg.HLC, resp.HLC)
+		}
+		written = append(written, msg)
+	}
+
+	// 副本上的本地写入排在复制来的消息之后
+	local, err := replica.AppendMessage("conv_r", 2, []byte("d"), nil)
+	if err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if local.HLC <= written[2].HLC {
+		t.Errorf("Expected local HLC %d to follow replicated %d", local.HLC, written[2].HLC)
+	}
+
+	// 按HLC区间查询
+	resp, err := client.GetMessages(ctx, &GetMessagesRequest{TimelineKey: "conv_r", StartHLC: written[1].HLC, EndHLC: local.HLC})
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(resp.Messages) != 3 || string(resp.Messages[0].Data) != "b" || resp.Messages[2].HLC != local.HLC {
+		t.Errorf("Unexpected messages in HLC range: %+v", resp.Messages)
+	}
+}
+
+func TestSortMessagesByHLC(t *testing.T) {
+	created := time.UnixMilli(1_700_000_000_000)
+	messages := []*Message{
+		{ConvID: "conv_b", SeqID: 1, HLC: 5 << hlcLogicalBits},
+		{ConvID: "conv_a", SeqID: 2, HLC: 5 << hlcLogicalBits},
+		{ConvID: "conv_a", SeqID: 1, CreateTime: created}, // 没有HLC的旧消息按创建时间排序
+		{ConvID: "conv_a", SeqID: 1, HLC: 3 << hlcLogicalBits},
+	}
+	SortMessagesByHLC(messages)
+
+	expected := []string{"conv_a/1", "conv_a/2", "conv_b/1", "conv_a/1"}
+	for i, msg := range messages {
+		if got := fmt.Sprintf("%s/%d", msg.ConvID, msg.SeqID); got != expected[i] {
+			t.Errorf("Position %d: expected %s, got %s", i, expected[i], got)
+		}
+	}
+	if messages[3].HLC != 0 {
+		t.Errorf("Expected the legacy message last, got %+v", messages[3])
+	}
+}
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// 延迟分布统计
+// 采用与HDR直方图相同的对数线性分桶：每个2的幂区间再等分为32个子桶，
+// 任意延迟的相对误差不超过约3%，每个直方图的桶数固定，内存与记录次数无关。
+// 滚动窗口由若干时间片组成，每个时间片一个直方图，过期的时间片在复用时清空
+
+const (
+	latencySubBucketBits = 5
+	latencySubBuckets    = 1 << latencySubBucketBits
+	// maxTrackableLatency 超过该值的延迟按该值统计
+	maxTrackableLatency = time.Hour
+	// defaultMetricsWindow 百分位与吞吐量的统计窗口
+	defaultMetricsWindow = time.Minute
+	// metricsWindowSlots 窗口划分的时间片数，窗口按时间片粒度滚动
+	metricsWindowSlots = 6
+)
+
+// latencyBuckets 覆盖[0, maxTrackableLatency]所需的桶数
+var latencyBuckets = latencyBucketIndex(uint64(maxTrackableLatency)) + 1
+
+// latencyBucketIndex 延迟（纳秒）所在的桶
+func latencyBucketIndex(v uint64) int {
+	if v < latencySubBuckets {
+		return int(v)
+	}
+	shift := bits.Len64(v) - latencySubBucketBits - 1
+	return (shift+1)*latencySubBuckets + int(v>>shift) - latencySubBuckets
+}
+
+// latencyBucketValue 桶内延迟的代表值，取桶的中点
+func latencyBucketValue(index int) time.Duration {
+	if index < latencySubBuckets {
+		return time.Duration(index)
+	}
+	shift := index/latencySubBuckets - 1
+	lower := uint64(index%latencySubBuckets+latencySubBuckets) << shift
+	return time.Duration(lower + (uint64(1)<<shift)/2)
+}
+
+// latencyHistogram 固定桶数的延迟直方图
+type latencyHistogram struct {
+	counts []uint64
+	total  uint64
+	max    time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]uint64, latencyBuckets)}
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	if d > maxTrackableLatency {
+		d = maxTrackableLatency
+	}
+	h.counts[latencyBucketIndex(uint64(d))]++
+	h.total++
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	for i, count := range other.counts {
+		h.counts[i] += count
+	}
+	h.total += other.total
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+func (h *latencyHistogram) reset() {
+	clear(h.counts)
+	h.total = 0
+	h.max = 0
+}
+
+// percentile 返回第q百分位（0-100）的延迟，没有记录时返回0
+func (h *latencyHistogram) percentile(q float64) time.Duration {
+	if h.total == 0 {
+		return 0
+	}
+	// 排名为ceil(q% * total)的记录所在的桶
+	rank := max(uint64(math.Ceil(q/100*float64(h.total))), 1)
+	var seen uint64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			// 代表值不超过实际记录到的最大值
+			if value := latencyBucketValue(i); value < h.max {
+				return value
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
+
+// latencySlot 滚动窗口中的一个时间片
+type latencySlot struct {
+	start  time.Time
+	hist   *latencyHistogram
+	errors int64
+}
+
+// latencyWindow 单个操作的滚动窗口统计，由MetricsCollector的锁保护
+type latencyWindow struct {
+	slotWidth time.Duration
+	slots     []latencySlot
+	created   time.Time
+}
+
+func newLatencyWindow(window time.Duration, now time.Time) *latencyWindow {
+	w := &latencyWindow{
+		slotWidth: window / metricsWindowSlots,
+		slots:     make([]latencySlot, metricsWindowSlots),
+		created:   now,
+	}
+	for i := range w.slots {
+		w.slots[i].hist = newLatencyHistogram()
+	}
+	return w
+}
+
+func (w *latencyWindow) record(d time.Duration, success bool, now time.Time) {
+	start := now.Truncate(w.slotWidth)
+	slot := &w.slots[int(start.UnixNano()/int64(w.slotWidth))%len(w.slots)]
+	if !slot.start.Equal(start) {
+		// 时间片已过期，复用前清空
+		slot.start = start
+		slot.hist.reset()
+		slot.errors = 0
+	}
+	slot.hist.record(d)
+	if !success {
+		slot.errors++
+	}
+}
+
+// collect 将窗口内仍有效的时间片合并到hist，返回窗口内的错误数
+func (w *latencyWindow) collect(hist *latencyHistogram, now time.Time) int64 {
+	oldest := now.Truncate(w.slotWidth).Add(-w.slotWidth * time.Duration(len(w.slots)-1))
+	var errors int64
+	for i := range w.slots {
+		slot := &w.slots[i]
+		if slot.start.IsZero() || slot.start.Before(oldest) || slot.start.After(now) {
+			continue
+		}
+		hist.merge(slot.hist)
+		errors += slot.errors
+	}
+	return errors
+}
+
+// span collect合并的记录覆盖的时长，用于计算吞吐量；刚开始统计时按实际经过的时间计算，至少为1秒
+func (w *latencyWindow) span(now time.Time) time.Duration {
+	from := now.Truncate(w.slotWidth).Add(-w.slotWidth * time.Duration(len(w.slots)-1))
+	if w.created.After(from) {
+		from = w.created
+	}
+	return max(now.Sub(from), time.Second)
+}
+
+// LatencyStats 单个操作在统计窗口内的延迟分布与吞吐量
+type LatencyStats struct {
+	Count      int64
+	Errors     int64
+	P50        time.Duration
+	P95        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	Throughput float64 // 每秒操作数
+}
+
+func latencyStatsOf(hist *latencyHistogram, errors int64, span time.Duration) LatencyStats {
+	stats := LatencyStats{
+		Count:  int64(hist.total),
+		Errors: errors,
+		P50:    hist.percentile(50),
+		P95:    hist.percentile(95),
+		P99:    hist.percentile(99),
+		Max:    hist.max,
+	}
+	if span > 0 {
+		stats.Throughput = float64(hist.total) / span.Seconds()
+	}
+	return stats
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramPercentiles(t *testing.T) {
+	hist := newLatencyHistogram()
+	for i := 1; i <= 10000; i++ {
+		hist.record(time.Duration(i) * time.Microsecond)
+	}
+
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{50, 5000 * time.Microsecond},
+		{95, 9500 * time.Microsecond},
+		{99, 9900 * time.Microsecond},
+	} {
+		got := hist.percentile(tc.q)
+		if diff := got - tc.want; diff < -tc.want/30 || diff > tc.want/30 {
+			t.Errorf("P%v: expected about %v, got %v", tc.q, tc.want, got)
+		}
+	}
+	if got := hist.percentile(100); got != 10*time.Millisecond {
+		t.Errorf("Expected P100 to be the maximum, got %v", got)
+	}
+	if got := newLatencyHistogram().percentile(99); got != 0 {
+		t.Errorf("Expected 0 for an empty histogram, got %v", got)
+	}
+
+	// 超出范围的延迟按上限统计，桶数不随记录增长
+	hist.record(2 * maxTrackableLatency)
+	if hist.max != maxTrackableLatency || len(hist.counts) != latencyBuckets {
+		t.Errorf("Expected latencies to be clamped to %v, got max %v", maxTrackableLatency, hist.max)
+	}
+}
+
+func TestMetricsCollectorRollingWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	mc := NewMetricsCollectorWithWindow(time.Minute)
+	mc.now = func() time.Time { return now }
+
+	// 一分钟内每100ms一次读，每秒一次失败的写
+	for i := 0; i < 600; i++ {
+		mc.Record("read", time.Duration(i%100+1)*time.Millisecond, true)
+		if i%10 == 0 {
+			mc.Record("write", 50*time.Millisecond, false)
+		}
+		now = now.Add(100 * time.Millisecond)
+	}
+
+	metrics := mc.GetMetrics()
+	read := metrics.Latencies["read"]
+	if read.Count < 500 || read.P50 < 45*time.Millisecond || read.P50 > 55*time.Millisecond || read.P99 < 95*time.Millisecond {
+		t.Errorf("Unexpected read stats %+v", read)
+	}
+	if read.Throughput < 9 || read.Throughput > 11 {
+		t.Errorf("Expected about 10 reads/s, got %.2f", read.Throughput)
+	}
+	if write := metrics.Latencies["write"]; write.Errors != write.Count || write.P99 < 48*time.Millisecond || write.Max != 50*time.Millisecond {
+		t.Errorf("Unexpected write stats %+v", write)
+	}
+	if metrics.LatencyP50 == 0 || metrics.LatencyP99 < metrics.LatencyP50 || metrics.Throughput < read.Throughput {
+		t.Errorf("Unexpected overall metrics P50 %v P99 %v throughput %.2f", metrics.LatencyP50, metrics.LatencyP99, metrics.Throughput)
+	}
+	// 累计计数不受窗口影响
+	if metrics.OperationCounts["read"] != 600 || metrics.ErrorCounts["write"] != 60 {
+		t.Errorf("Unexpected cumulative counts %v %v", metrics.OperationCounts, metrics.ErrorCounts)
+	}
+
+	// 窗口滚动过去后百分位与吞吐量归零
+	now = now.Add(2 * time.Minute)
+	metrics = mc.GetMetrics()
+	if read := metrics.Latencies["read"]; read.Count != 0 || read.P99 != 0 || read.Throughput != 0 {
+		t.Errorf("Expected expired window to be empty, got %+v", read)
+	}
+	if metrics.OperationCounts["read"] != 600 {
+		t.Errorf("Expected cumulative count to survive, got %d", metrics.OperationCounts["read"])
+	}
+}
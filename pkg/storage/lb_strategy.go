@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+)
+
+// StoreMetadataWeight StoreInfo.Metadata中的路由权重，未设置时按容量推算
+const StoreMetadataWeight = "weight"
+
+// 内置负载均衡策略名称
+const (
+	StrategyNameRoundRobin         = "round_robin"
+	StrategyNameLeastLoad          = "least_load"
+	StrategyNameWeightedRoundRobin = "weighted_round_robin"
+	StrategyNameRandom             = "random"
+	StrategyNameLeastConnections   = "least_connections"
+)
+
+// StoreCandidate 参与选择的健康Store
+type StoreCandidate struct {
+	Info        *StoreInfo
+	Load        *StoreLoad // 未上报负载时为nil
+	Connections int64      // 当前连接数，优先使用路由器跟踪的值，否则取负载上报的值
+}
+
+// Strategy 负载均衡策略
+// Select在路由器的锁内调用，candidates按Store ID排序且不为空；
+// 有状态的策略实例只应被一个路由器使用
+type Strategy interface {
+	Name() string
+	Select(timelineKey string, candidates []*StoreCandidate) (string, error)
+}
+
+// StrategyFactory 创建策略实例
+type StrategyFactory func() Strategy
+
+var (
+	strategyMu        sync.RWMutex
+	strategyFactories = map[string]StrategyFactory{
+		StrategyNameRoundRobin:         func() Strategy { return &roundRobinStrategy{} },
+		StrategyNameLeastLoad:          func() Strategy { return leastLoadStrategy{} },
+		StrategyNameWeightedRoundRobin: func() Strategy { return newWeightedRoundRobinStrategy() },
+		StrategyNameRandom:             func() Strategy { return randomStrategy{} },
+		StrategyNameLeastConnections:   func() Strategy { return leastConnectionsStrategy{} },
+	}
+)
+
+// RegisterStrategy 注册自定义负载均衡策略，同名策略会被覆盖
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategyMu.Lock()
+	defer strategyMu.Unlock()
+	strategyFactories[name] = factory
+}
+
+// NewStrategy 按名称创建负载均衡策略
+func NewStrategy(name string) (Strategy, error) {
+	strategyMu.RLock()
+	factory, exists := strategyFactories[name]
+	strategyMu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("load balancing strategy %s not found", name)
+	}
+	return factory(), nil
+}
+
+// roundRobinStrategy 轮询
+type roundRobinStrategy struct {
+	next int
+}
+
+func (s *roundRobinStrategy) Name() string { return StrategyNameRoundRobin }
+
+func (s *roundRobinStrategy) Select(timelineKey string, candidates []*StoreCandidate) (string, error) {
+	candidate := candidates[s.next%len(candidates)]
+	s.next++
+	return candidate.Info.ID, nil
+}
+
+// leastLoadStrategy 选择负载评分最高的Store，没有负载信息的Store优先
+type leastLoadStrategy struct{}
+
+func (leastLoadStrategy) Name() string { return StrategyNameLeastLoad }
+
+func (leastLoadStrategy) Select(timelineKey string, candidates []*StoreCandidate) (string, error) {
+	best := candidates[0].Info.ID
+	bestScore := -1.0
+	for _, candidate := range candidates {
+		if candidate.Load == nil {
+			return candidate.Info.ID, nil
+		}
+		if score := storeLoadScore(candidate.Load); score > bestScore {
+			bestScore = score
+			best = candidate.Info.ID
+		}
+	}
+	return best, nil
+}
+
+// weightedRoundRobinStrategy 平滑加权轮询
+// 每次选择时各Store的当前权重加上其权重，选出当前权重最大者并减去总权重，
+// 权重为5:1:1时的选择序列为a a b a c a a，而不是连续选择同一Store
+type weightedRoundRobinStrategy struct {
+	current map[string]int64
+}
+
+func newWeightedRoundRobinStrategy() *weightedRoundRobinStrategy {
+	return &weightedRoundRobinStrategy{current: make(map[string]int64)}
+}
+
+func (s *weightedRoundRobinStrategy) Name() string { return StrategyNameWeightedRoundRobin }
+
+func (s *weightedRoundRobinStrategy) Select(timelineKey string, candidates []*StoreCandidate) (string, error) {
+	var total int64
+	var best string
+	present := make(map[string]bool, len(candidates))
+	for _, candidate := range candidates {
+		id := candidate.Info.ID
+		weight := storeWeight(candidate)
+		present[id] = true
+		total += weight
+		s.current[id] += weight
+		if best == "" || s.current[id] > s.current[best] {
+			best = id
+		}
+	}
+	s.current[best] -= total
+
+	// 已下线Store的累计值不再参与计算
+	for id := range s.current {
+		if !present[id] {
+			delete(s.current, id)
+		}
+	}
+	return best, nil
+}
+
+// randomStrategy 按权重随机选择
+type randomStrategy struct{}
+
+func (randomStrategy) Name() string { return StrategyNameRandom }
+
+func (randomStrategy) Select(timelineKey string, candidates []*StoreCandidate) (string, error) {
+	var total int64
+	for _, candidate := range candidates {
+		total += storeWeight(candidate)
+	}
+	n := rand.Int64N(total)
+	for _, candidate := range candidates {
+		if n -= storeWeight(candidate); n < 0 {
+			return candidate.Info.ID, nil
+		}
+	}
+	return candidates[len(candidates)-1].Info.ID, nil
+}
+
+// leastConnectionsStrategy 选择连接数与权重之比最小的Store，相同时取ID较小者
+type leastConnectionsStrategy struct{}
+
+func (leastConnectionsStrategy) Name() string { return StrategyNameLeastConnections }
+
+func (leastConnectionsStrategy) Select(timelineKey string, candidates []*StoreCandidate) (string, error) {
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		// 交叉相乘比较 conns/weight，避免浮点误差
+		if candidate.Connections*storeWeight(best) < best.Connections*storeWeight(candidate) {
+			best = candidate
+		}
+	}
+	return best.Info.ID, nil
+}
+
+// storeWeight 读取Store权重：优先使用weight元数据，其次按容量每GB计1，至少为1
+func storeWeight(candidate *StoreCandidate) int64 {
+	metadata := candidate.Info.Metadata
+	if weight, ok := metadataNumber(metadata, StoreMetadataWeight); ok && weight >= 1 {
+		return int64(weight)
+	}
+	capacity, ok := metadataNumber(metadata, StoreMetadataCapacity)
+	if !ok && candidate.Load != nil {
+		capacity, ok = float64(candidate.Load.MaxCapacity), candidate.Load.MaxCapacity > 0
+	}
+	if ok {
+		if weight := int64(capacity / (1 << 30)); weight >= 1 {
+			return weight
+		}
+	}
+	return 1
+}
+
+// metadataNumber 读取数值型元数据，兼容进程内写入的整数和经JSON解码得到的float64
+func metadataNumber(metadata map[string]interface{}, key string) (float64, bool) {
+	switch v := metadata[key].(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// storeLoadScore 计算Store负载评分，越高越空闲
+func storeLoadScore(load *StoreLoad) float64 {
+	capacityRatio := 0.0
+	if load.MaxCapacity > 0 {
+		capacityRatio = float64(load.UsedCapacity) / float64(load.MaxCapacity)
+	}
+	if capacityRatio > 1.0 {
+		capacityRatio = 1.0
+	}
+
+	cpuScore := 1.0 - load.CPUUsage
+	if cpuScore < 0 {
+		cpuScore = 0
+	}
+
+	memoryScore := 1.0 - load.MemoryUsage
+	if memoryScore < 0 {
+		memoryScore = 0
+	}
+
+	latencyScore := 1.0
+	if load.NetworkLatency > 0 {
+		latencyScore = 1.0 / (1.0 + float64(load.NetworkLatency)/1000.0)
+	}
+
+	return (1.0-capacityRatio)*0.3 + cpuScore*0.25 + memoryScore*0.25 + latencyScore*0.2
+}
+
+// sortedCandidates 按Store ID排序，使轮询类策略的顺序稳定
+func sortedCandidates(candidates []*StoreCandidate) []*StoreCandidate {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Info.ID < candidates[j].Info.ID })
+	return candidates
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func newTestLBRouter(strategy LoadBalancingStrategy, weights map[string]int) *LoadBalancingRouter {
+	router := NewLoadBalancingRouter(strategy)
+	for id, weight := range weights {
+		router.AddStore(&StoreInfo{
+			ID:       id,
+			Status:   StoreStatusActive,
+			Metadata: map[string]interface{}{StoreMetadataWeight: weight},
+		})
+	}
+	return router
+}
+
+func TestWeightedRoundRobinIsSmooth(t *testing.T) {
+	router := newTestLBRouter(StrategyWeightedRoundRobin, map[string]int{"a": 5, "b": 1, "c": 1})
+
+	picks := make([]string, 0, 7)
+	for i := 0; i < 7; i++ {
+		storeID, err := router.RouteTimeline("")
+		if err != nil {
+			t.Fatalf("Failed to route: %v", err)
+		}
+		picks = append(picks, storeID)
+	}
+	if got := strings.Join(picks, ""); got != "aabacaa" {
+		t.Errorf("Expected smooth sequence aabacaa, got %s", got)
+	}
+
+	// 权重来自JSON解码的元数据与容量
+	router = NewLoadBalancingRouter(StrategyWeightedRoundRobin)
+	router.AddStore(&StoreInfo{ID: "big", Status: StoreStatusActive, Metadata: map[string]interface{}{StoreMetadataCapacity: float64(3 << 30)}})
+	router.AddStore(&StoreInfo{ID: "small", Status: StoreStatusActive})
+	counts := make(map[string]int)
+	for i := 0; i < 400; i++ {
+		storeID, _ := router.RouteTimeline("")
+		counts[storeID]++
+	}
+	if counts["big"] != 300 || counts["small"] != 100 {
+		t.Errorf("Expected a 3:1 split by capacity, got %v", counts)
+	}
+}
+
+func TestLeastConnectionsStrategy(t *testing.T) {
+	router := newTestLBRouter(StrategyLeastConnections, map[string]int{"a": 1, "b": 1, "c": 2})
+
+	releaseA := router.TrackConnection("a")
+	router.TrackConnection("b")
+	router.TrackConnection("c")
+	router.TrackConnection("c")
+	// a:1 b:1 c:2/2 全部相同时取ID最小者
+	if storeID, _ := router.RouteTimeline(""); storeID != "a" {
+		t.Errorf("Expected a on a tie, got %s", storeID)
+	}
+	releaseA()
+	releaseA() // 重复释放不应使计数变为负数
+	if storeID, _ := router.RouteTimeline(""); storeID != "a" {
+		t.Errorf("Expected a after its connection closed, got %s", storeID)
+	}
+	router.TrackConnection("a")
+	router.TrackConnection("a")
+	if storeID, _ := router.RouteTimeline(""); storeID != "b" {
+		t.Errorf("Expected b with the fewest connections, got %s", storeID)
+	}
+
+	// 未跟踪连接的Store使用负载上报的连接数
+	router = newTestLBRouter(StrategyLeastConnections, map[string]int{"a": 1, "b": 1})
+	router.UpdateStoreLoad("a", &StoreLoad{StoreID: "a", ActiveConnections: 10})
+	router.UpdateStoreLoad("b", &StoreLoad{StoreID: "b", ActiveConnections: 3})
+	if storeID, _ := router.RouteTimeline(""); storeID != "b" {
+		t.Errorf("Expected b from reported connections, got %s", storeID)
+	}
+}
+
+func TestRandomStrategyRespectsWeights(t *testing.T) {
+	router := newTestLBRouter(StrategyRandom, map[string]int{"a": 9, "b": 1})
+	counts := make(map[string]int)
+	for i := 0; i < 2000; i++ {
+		storeID, _ := router.RouteTimeline("")
+		counts[storeID]++
+	}
+	if counts["a"] < 1600 || counts["b"] < 100 {
+		t.Errorf("Unexpected weighted random distribution: %v", counts)
+	}
+}
+
+// firstStoreStrategy 测试用的自定义策略
+type firstStoreStrategy struct{}
+
+func (firstStoreStrategy) Name() string { return "first" }
+
+func (firstStoreStrategy) Select(timelineKey string, candidates []*StoreCandidate) (string, error) {
+	if timelineKey == "reject" {
+		return "", fmt.Errorf("rejected")
+	}
+	return candidates[0].Info.ID, nil
+}
+
+func TestCustomStrategy(t *testing.T) {
+	if _, err := NewStrategy("first"); err == nil {
+		t.Fatal("Expected unknown strategy to fail")
+	}
+	RegisterStrategy("first", func() Strategy { return firstStoreStrategy{} })
+	strategy, err := NewStrategy("first")
+	if err != nil {
+		t.Fatalf("Failed to create custom strategy: %v", err)
+	}
+
+	router := NewLoadBalancingRouterWithStrategy(strategy)
+	router.AddStore(&StoreInfo{ID: "b", Status: StoreStatusActive})
+	router.AddStore(&StoreInfo{ID: "a", Status: StoreStatusActive})
+	router.AddStore(&StoreInfo{ID: "0", Status: StoreStatusUnhealthy})
+	if storeID, _ := router.RouteTimeline("conv_1"); storeID != "a" {
+		t.Errorf("Expected sorted healthy candidates, got %s", storeID)
+	}
+	if _, err := router.RouteTimeline("reject"); err == nil {
+		t.Error("Expected strategy error to be returned")
+	}
+
+	router.SetStrategy(&roundRobinStrategy{})
+	first, _ := router.RouteTimeline("")
+	second, _ := router.RouteTimeline("")
+	if first != "a" || second != "b" {
+		t.Errorf("Expected round robin in id order, got %s %s", first, second)
+	}
+}
@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 协调者选举
+// 自动重平衡和Store下线编排会发起迁移，多个节点同时执行会对同一Timeline发起相互冲突的迁移。
+// 节点通过LeaderElector竞选协调者，同一时刻只有领导者执行这两项工作，其他节点跳过重平衡、拒绝下线请求。
+// 领导者身份绑定租约：领导者进程退出或与选举后端失联超过TTL后租约过期，其他参选节点自动接任。
+// 每个任期对应一个ctx，失去领导者身份时结束，在任期内发起的迁移随之中断并保留检查点。
+
+// ErrNotLeader 本节点不是协调者领导者
+var ErrNotLeader = errors.New("not the coordinator leader")
+
+// DefaultLeaderTTL 默认领导者租约TTL
+const DefaultLeaderTTL = 10 * time.Second
+
+// LeaderElector 协调者选举
+type LeaderElector interface {
+	// Start 开始参选，失去领导者身份后自动重新参选，ctx结束时退出选举但不主动让出租约
+	Start(ctx context.Context) error
+	// Stop 退出选举，是领导者时立即让出
+	Stop() error
+	// IsLeader 本节点当前是否为领导者
+	IsLeader() bool
+	// Leader 当前领导者的节点ID，没有领导者时返回空字符串
+	Leader(ctx context.Context) (string, error)
+	// LeaderContext 返回本节点当前任期的ctx，失去领导者身份时结束；不是领导者时返回ErrNotLeader
+	LeaderContext() (context.Context, error)
+}
+
+// leaderTerm 领导者任期状态，由各选举实现共用
+type leaderTerm struct {
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// begin 开始新任期，已是领导者时不变
+func (t *leaderTerm) begin(parent context.Context) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx != nil {
+		return false
+	}
+	t.ctx, t.cancel = context.WithCancel(parent)
+	return true
+}
+
+// end 结束当前任期，不是领导者时不变
+func (t *leaderTerm) end() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx == nil {
+		return false
+	}
+	t.cancel()
+	t.ctx, t.cancel = nil, nil
+	return true
+}
+
+func (t *leaderTerm) IsLeader() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.ctx != nil
+}
+
+func (t *leaderTerm) LeaderContext() (context.Context, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ctx == nil {
+		return nil, ErrNotLeader
+	}
+	return t.ctx, nil
+}
+
+// notLeaderError 带上当前领导者，方便调用方把请求转给领导者
+func notLeaderError(ctx context.Context, elector LeaderElector) error {
+	leader, err := elector.Leader(ctx)
+	if err != nil || leader == "" {
+		return ErrNotLeader
+	}
+	return fmt.Errorf("%w, the leader is %s", ErrNotLeader, leader)
+}
+
+// InMemoryElection 进程内的选举租约，同一进程的参选者共享，用于单进程部署和测试
+type InMemoryElection struct {
+	mu        sync.Mutex
+	leader    string
+	expiresAt time.Time
+	released  chan struct{} // 领导者让出时关闭，通知其他参选者立即竞选
+}
+
+// NewInMemoryElection 创建进程内选举
+func NewInMemoryElection() *InMemoryElection {
+	return &InMemoryElection{released: make(chan struct{})}
+}
+
+// acquire 没有领导者或租约已过期时由nodeID当选，领导者续期租约，返回nodeID是否为领导者
+func (e *InMemoryElection) acquire(nodeID string, ttl time.Duration, now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader != "" && e.leader != nodeID && now.Before(e.expiresAt) {
+		return false
+	}
+	e.leader = nodeID
+	e.expiresAt = now.Add(ttl)
+	return true
+}
+
+// release nodeID是领导者时让出
+func (e *InMemoryElection) release(nodeID string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader == nodeID {
+		e.leader = ""
+		close(e.released)
+		e.released = make(chan struct{})
+	}
+}
+
+// releasedCh 领导者下一次让出时关闭的channel
+func (e *InMemoryElection) releasedCh() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.released
+}
+
+// current 当前租约有效的领导者
+func (e *InMemoryElection) current(now time.Time) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader == "" || !now.Before(e.expiresAt) {
+		return ""
+	}
+	return e.leader
+}
+
+// InMemoryLeaderElector 参与进程内选举，每TTL的三分之一续期或重新竞选一次，领导者让出时立即竞选
+type InMemoryLeaderElector struct {
+	leaderTerm
+	election *InMemoryElection
+	nodeID   string
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	running bool
+	stopCh  chan struct{}
+	done    chan struct{}
+}
+
+// NewInMemoryLeaderElector 创建进程内参选者，ttl为0时使用DefaultLeaderTTL
+func NewInMemoryLeaderElector(election *InMemoryElection, nodeID string, ttl time.Duration) *InMemoryLeaderElector {
+	if ttl <= 0 {
+		ttl = DefaultLeaderTTL
+	}
+	return &InMemoryLeaderElector{election: election, nodeID: nodeID, ttl: ttl}
+}
+
+// Start 开始参选
+func (e *InMemoryLeaderElector) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return fmt.Errorf("leader election is already running")
+	}
+	e.running = true
+	e.stopCh = make(chan struct{})
+	e.done = make(chan struct{})
+	go e.run(ctx, e.stopCh, e.done)
+	return nil
+}
+
+func (e *InMemoryLeaderElector) run(ctx context.Context, stopCh, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		released := e.election.releasedCh()
+		if e.election.acquire(e.nodeID, e.ttl, time.Now()) {
+			if e.begin(ctx) {
+				log.Printf("store %s: became the coordinator leader", e.nodeID)
+			}
+		} else if e.end() {
+			log.Printf("store %s: lost coordinator leadership", e.nodeID)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-released:
+		case <-stopCh:
+			e.election.release(e.nodeID)
+			e.end()
+			return
+		case <-ctx.Done():
+			// 不让出租约，等它过期后由其他节点接任
+			e.end()
+			return
+		}
+	}
+}
+
+// Stop 退出选举并让出领导者身份
+func (e *InMemoryLeaderElector) Stop() error {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return nil
+	}
+	e.running = false
+	close(e.stopCh)
+	done := e.done
+	e.mu.Unlock()
+	<-done
+	return nil
+}
+
+// Leader 当前领导者
+func (e *InMemoryLeaderElector) Leader(ctx context.Context) (string, error) {
+	return e.election.current(time.Now()), nil
+}
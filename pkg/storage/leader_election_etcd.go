@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/client/v3/concurrency"
+)
+
+// etcd中的键布局（位于Prefix之下）:
+//   {leaseID} -> 参选节点ID，绑定该节点会话的租约
+// 创建版本最早的键为领导者；领导者进程退出或与etcd失联超过TTL后租约过期，键被删除，下一个参选者当选。
+
+const (
+	defaultEtcdElectionPrefix = "/imy/coordinator"
+	electionRetryInterval     = time.Second
+)
+
+// EtcdLeaderElector 基于etcd租约的协调者选举
+type EtcdLeaderElector struct {
+	leaderTerm
+	client *clientv3.Client
+	prefix string
+	nodeID string
+	ttl    time.Duration
+
+	mu      sync.Mutex
+	running bool
+	cancel  context.CancelFunc
+	resign  bool // Stop时主动让出，ctx结束时不让出
+	done    chan struct{}
+}
+
+// NewEtcdLeaderElector 使用已有的etcd客户端参选，prefix为空时使用/imy/coordinator，ttl为0时使用DefaultLeaderTTL
+func NewEtcdLeaderElector(client *clientv3.Client, prefix, nodeID string, ttl time.Duration) *EtcdLeaderElector {
+	if prefix == "" {
+		prefix = defaultEtcdElectionPrefix
+	}
+	if ttl <= 0 {
+		ttl = DefaultLeaderTTL
+	}
+	return &EtcdLeaderElector{
+		client: client,
+		prefix: strings.TrimSuffix(prefix, "/"),
+		nodeID: nodeID,
+		ttl:    ttl,
+	}
+}
+
+// Start 开始参选
+func (e *EtcdLeaderElector) Start(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.running {
+		return fmt.Errorf("leader election is already running")
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	e.running = true
+	e.cancel = cancel
+	e.resign = false
+	e.done = make(chan struct{})
+	go e.run(runCtx, e.done)
+	return nil
+}
+
+func (e *EtcdLeaderElector) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	for ctx.Err() == nil {
+		if err := e.campaign(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("store %s: coordinator election: %v", e.nodeID, err)
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(electionRetryInterval):
+		}
+	}
+}
+
+// campaign 建立会话并竞选，当选后保持到会话失效或ctx结束
+func (e *EtcdLeaderElector) campaign(ctx context.Context) error {
+	ttlSeconds := int((e.ttl + time.Second - 1) / time.Second)
+	// 会话不绑定ctx，否则Stop时无法再撤销租约
+	session, err := concurrency.NewSession(e.client, concurrency.WithTTL(ttlSeconds))
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	defer func() {
+		e.mu.Lock()
+		resign := e.resign
+		e.mu.Unlock()
+		if resign {
+			// 撤销租约，领导者键随之删除
+			session.Close()
+		} else {
+			// 进程仍可能在退出，保留租约等它过期
+			session.Orphan()
+		}
+	}()
+
+	// 会话失效时放弃排队中的竞选
+	campaignCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-session.Done():
+			cancel()
+		case <-campaignCtx.Done():
+		}
+	}()
+
+	election := concurrency.NewElection(session, e.prefix)
+	if err := election.Campaign(campaignCtx, e.nodeID); err != nil {
+		return fmt.Errorf("campaign failed: %w", err)
+	}
+
+	e.begin(ctx)
+	log.Printf("store %s: became the coordinator leader", e.nodeID)
+	select {
+	case <-session.Done():
+		log.Printf("store %s: lost coordinator leadership, session expired", e.nodeID)
+	case <-ctx.Done():
+	}
+	e.end()
+	return nil
+}
+
+// Stop 退出选举，是领导者时撤销租约立即让出
+func (e *EtcdLeaderElector) Stop() error {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return nil
+	}
+	e.running = false
+	e.resign = true
+	e.cancel()
+	done := e.done
+	e.mu.Unlock()
+	<-done
+	return nil
+}
+
+// Leader 创建版本最早的参选者即为领导者
+func (e *EtcdLeaderElector) Leader(ctx context.Context) (string, error) {
+	resp, err := e.client.Get(ctx, e.prefix+"/", clientv3.WithFirstCreate()...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get coordinator leader: %w", err)
+	}
+	if len(resp.Kvs) == 0 {
+		return "", nil
+	}
+	return string(resp.Kvs[0].Value), nil
+}
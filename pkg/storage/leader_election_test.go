@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// waitForLeadership 等待参选者的领导者身份变为leading
+func waitForLeadership(t *testing.T, elector LeaderElector, leading bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for elector.IsLeader() != leading {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected leadership to become %v", leading)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestInMemoryLeaderElectionFailover(t *testing.T) {
+	ctx := context.Background()
+	election := NewInMemoryElection()
+	a := NewInMemoryLeaderElector(election, "store_a", 60*time.Millisecond)
+	b := NewInMemoryLeaderElector(election, "store_b", 60*time.Millisecond)
+	defer b.Stop()
+
+	ctxA, killA := context.WithCancel(ctx)
+	defer killA()
+	if err := a.Start(ctxA); err != nil {
+		t.Fatalf("Failed to start election: %v", err)
+	}
+	waitForLeadership(t, a, true)
+	if err := b.Start(ctx); err != nil {
+		t.Fatalf("Failed to start election: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if b.IsLeader() {
+		t.Fatal("Expected only one leader while the leader renews its lease")
+	}
+	if leader, _ := b.Leader(ctx); leader != "store_a" {
+		t.Fatalf("Expected store_a to lead, got %q", leader)
+	}
+	if _, err := b.LeaderContext(); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("Expected ErrNotLeader on a follower, got %v", err)
+	}
+	term, err := a.LeaderContext()
+	if err != nil {
+		t.Fatalf("Expected a term on the leader: %v", err)
+	}
+
+	// 领导者进程退出不让出租约，租约过期后另一个节点接任
+	killA()
+	select {
+	case <-term.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the term to end with the leader")
+	}
+	waitForLeadership(t, b, true)
+	if leader, _ := a.Leader(ctx); leader != "store_b" {
+		t.Fatalf("Expected store_b to take over, got %q", leader)
+	}
+
+	// 主动退出立即让出
+	b.Stop()
+	if leader, _ := b.Leader(ctx); leader != "" || b.IsLeader() {
+		t.Fatalf("Expected no leader after resigning, got %q", leader)
+	}
+}
+
+func TestAutoRebalanceOnlyOnLeader(t *testing.T) {
+	ctx := context.Background()
+	election := NewInMemoryElection()
+	leaderShards, leaderMigrations := newTestRebalanceShardManager(t, DefaultShardPolicy())
+	followerShards, followerMigrations := newTestRebalanceShardManager(t, DefaultShardPolicy())
+
+	leader := NewInMemoryLeaderElector(election, "store_a", time.Minute)
+	follower := NewInMemoryLeaderElector(election, "store_b", 60*time.Millisecond)
+	leaderShards.SetLeaderElector(leader)
+	followerShards.SetLeaderElector(follower)
+	defer follower.Stop()
+
+	if err := leader.Start(ctx); err != nil {
+		t.Fatalf("Failed to start election: %v", err)
+	}
+	waitForLeadership(t, leader, true)
+	if err := follower.Start(ctx); err != nil {
+		t.Fatalf("Failed to start election: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if started := startedBy(followerShards, followerMigrations); len(started) != 0 {
+		t.Fatalf("Expected the follower not to rebalance, got %v", started)
+	}
+	if started := startedBy(leaderShards, leaderMigrations); len(started) == 0 {
+		t.Fatal("Expected the leader to start migrations")
+	}
+
+	// 下线编排同样只在领导者上执行
+	dsm := &DistributedStorageManager{migrationManager: followerMigrations, leaderElector: follower}
+	if _, err := dsm.DecommissionStore(ctx, "store_c"); !errors.Is(err, ErrNotLeader) {
+		t.Fatalf("Expected decommission on a follower to fail with ErrNotLeader, got %v", err)
+	}
+
+	leader.Stop()
+	waitForLeadership(t, follower, true)
+	if started := startedBy(followerShards, followerMigrations); len(started) == 0 {
+		t.Fatal("Expected the new leader to start migrations")
+	}
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"log"
+	"strconv"
+
+	"imy/pkg/events"
+)
+
+// 消息事件
+// 本地提交的普通消息写入成功后发布message.created事件，复制来的消息由接受写入的Store发布，
+// 编辑与删除记录不发布。事件ID由会话与SeqID组成，重试投递时消费方据此去重
+
+// SetEventBus 设置发布消息事件的事件总线，nil表示不发布
+func (s *Store) SetEventBus(bus *events.Bus) {
+	s.events.Store(bus)
+}
+
+// publishMessageCreated 发布消息写入事件，失败只记录日志，不影响写入结果
+func (s *Store) publishMessageCreated(msg *Message) {
+	bus := s.events.Load()
+	if bus == nil || msg.Type != MsgTypeNormal {
+		return
+	}
+	event, err := events.NewEvent(msg.ConvID+"/"+strconv.FormatInt(msg.SeqID, 10), events.TypeMessageCreated, msg.ConvID, events.MessageData{
+		MessageID: strconv.FormatInt(msg.SeqID, 10),
+		SenderID:  strconv.FormatUint(uint64(msg.SenderID), 10),
+		Content:   string(msg.Data),
+		Mentions:  msg.Mentions,
+	})
+	if err == nil {
+		event.Source = s.StoreID
+		err = bus.Publish(event)
+	}
+	if err != nil {
+		log.Printf("store %s: failed to publish message %d of %s: %v", s.StoreID, msg.SeqID, msg.ConvID, err)
+	}
+}
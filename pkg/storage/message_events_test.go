@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"imy/pkg/events"
+)
+
+type capturePublisher struct {
+	mu     sync.Mutex
+	events []*events.Event
+}
+
+func (p *capturePublisher) Publish(ctx context.Context, event *events.Event, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *capturePublisher) Close() error { return nil }
+
+func TestStorePublishesMessageEvents(t *testing.T) {
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	publisher := &capturePublisher{}
+	bus, err := events.NewBus(events.Options{}, events.Sink{Name: "capture", Publisher: publisher})
+	if err != nil {
+		t.Fatalf("Failed to create bus: %v", err)
+	}
+	defer bus.Close()
+	store.SetEventBus(bus)
+
+	msg, err := store.AppendMessage("conv_1", 7, []byte("hello"), []string{"user_1"})
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	// 编辑、删除与复制来的消息不发布事件
+	if _, err := store.EditMessage("conv_1", 7, msg.SeqID, []byte("edited"), []string{"user_1"}); err != nil {
+		t.Fatalf("Failed to edit: %v", err)
+	}
+	if _, _, err := store.ReplicateMessage("conv_1", &Message{SenderID: 8, Data: []byte("remote"), HLC: 1}, nil); err != nil {
+		t.Fatalf("Failed to replicate: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := bus.Flush(ctx); err != nil {
+		t.Fatalf("Failed to flush: %v", err)
+	}
+
+	publisher.mu.Lock()
+	defer publisher.mu.Unlock()
+	if len(publisher.events) != 1 {
+		t.Fatalf("Expected one event, got %d", len(publisher.events))
+	}
+	event := publisher.events[0]
+	var data events.MessageData
+	json.Unmarshal(event.Data, &data)
+	if event.Type != events.TypeMessageCreated || event.ID != "conv_1/1" || event.Source != store.StoreID || data.SenderID != "7" || data.Content != "hello" {
+		t.Errorf("Unexpected event %+v with data %+v", event, data)
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"fmt"
+	"iter"
+)
+
+// MessageIterator 按SeqID顺序逐条读取消息，用法与database/sql.Rows一致：
+//
+//	it := store.IterateMessages("conv", convID, 0, 0)
+//	defer it.Close()
+//	for it.Next() {
+//		msg := it.Message()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// 遍历时只持有当前块的消息，导出与迁移大范围消息时内存占用与总条数无关
+type MessageIterator interface {
+	// Next 前进到下一条消息，没有更多消息或出错时返回false
+	Next() bool
+	// Message 返回当前消息，仅在Next返回true后有效
+	Message() *Message
+	// Err 返回遍历中遇到的错误
+	Err() error
+	// Close 提前结束遍历并释放资源，可重复调用
+	Close() error
+}
+
+// IterateMessages 返回Timeline中SeqID在(afterSeqID, beforeSeqID)区间内的消息迭代器，beforeSeqID为0表示不限制
+// 迭代器遍历创建时的视图，之后写入的消息不会出现；Timeline不存在时Err返回ErrTimelineNotFound
+func (s *Store) IterateMessages(timelineType, timelineID string, afterSeqID, beforeSeqID int64) MessageIterator {
+	tl, exists := s.GetTimeline(timelineType, timelineID)
+	if !exists {
+		return &timelineIterator{err: fmt.Errorf("%w: %s/%s", ErrTimelineNotFound, timelineType, timelineID)}
+	}
+	return s.iterateTimeline(tl, afterSeqID, beforeSeqID)
+}
+
+// iterateTimeline 为已打开的Timeline创建消息迭代器
+func (s *Store) iterateTimeline(tl *Timeline, afterSeqID, beforeSeqID int64) *timelineIterator {
+	next, stop := iter.Pull2(tl.readView().forward(s.readBlockMessages, afterSeqID))
+	return &timelineIterator{next: next, stop: stop, beforeSeqID: beforeSeqID}
+}
+
+// timelineIterator MessageIterator的实现，把视图的推式遍历转为拉式
+type timelineIterator struct {
+	next        func() (*Message, error, bool)
+	stop        func()
+	beforeSeqID int64
+
+	current *Message
+	err     error
+	done    bool
+}
+
+func (it *timelineIterator) Next() bool {
+	if it.done || it.err != nil || it.next == nil {
+		return false
+	}
+	msg, err, ok := it.next()
+	switch {
+	case err != nil:
+		it.err = err
+	case !ok || (it.beforeSeqID > 0 && msg.SeqID >= it.beforeSeqID):
+		// 视图按SeqID升序遍历，越过上界后不再读取后面的块
+	default:
+		it.current = msg
+		return true
+	}
+	it.current = nil
+	it.Close()
+	return false
+}
+
+func (it *timelineIterator) Message() *Message {
+	return it.current
+}
+
+func (it *timelineIterator) Err() error {
+	return it.err
+}
+
+func (it *timelineIterator) Close() error {
+	if !it.done {
+		it.done = true
+		if it.stop != nil {
+			it.stop()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func collectIterator(t *testing.T, it MessageIterator) []*Message {
+	t.Helper()
+	defer it.Close()
+	var messages []*Message
+	for it.Next() {
+		messages = append(messages, it.Message())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("Iterator failed: %v", err)
+	}
+	return messages
+}
+
+func TestIterateMessages(t *testing.T) {
+	store := reopenLazyStore(t, 105, 0)
+	convTL := store.GetOrCreateConvTimeline("lazy")
+
+	assertSeqRange(t, collectIterator(t, store.IterateMessages("conv", "lazy", 0, 0)), 1, 105)
+	assertSeqRange(t, collectIterator(t, store.IterateMessages("conv", "lazy", 37, 64)), 38, 63)
+
+	// 提前关闭时不再读取后面的块
+	store.blockCache.Clear()
+	it := store.IterateMessages("conv", "lazy", 0, 0)
+	for i := 0; i < 15 && it.Next(); i++ {
+	}
+	it.Close()
+	if it.Next() || it.Err() != nil {
+		t.Error("Expected a closed iterator to stop without error")
+	}
+	if entries := store.blockCache.Stats().EntryCount; entries != 2 {
+		t.Errorf("Expected only the first 2 blocks to be read, got %d", entries)
+	}
+	if residentBlocks(convTL) != 1 {
+		t.Error("Expected iteration not to keep blocks resident")
+	}
+
+	it = store.IterateMessages("conv", "missing", 0, 0)
+	if it.Next() || !errors.Is(it.Err(), ErrTimelineNotFound) {
+		t.Errorf("Expected ErrTimelineNotFound, got %v", it.Err())
+	}
+}
+
+func TestHTTPStreamMessages(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	// 超过一批的消息，响应分多行写出
+	total := streamBatchSize + 44
+	for i := 1; i <= total; i++ {
+		if _, err := store.AppendMessage("stream", 1, []byte(fmt.Sprintf("message-%d", i)), []string{"user_stream"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	ts := httptest.NewServer(NewHTTPStoreRPCServer(store).Handler())
+	defer ts.Close()
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	var messages []*Message
+	err = client.StreamMessages(ctx, &StreamMessagesRequest{TimelineKey: "stream"}, func(msg *Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream messages: %v", err)
+	}
+	assertSeqRange(t, messages, 1, int64(total))
+
+	messages = nil
+	err = client.StreamMessages(ctx, &StreamMessagesRequest{TimelineKey: "stream", AfterSeqID: 10, BeforeSeqID: 21}, func(msg *Message) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream messages: %v", err)
+	}
+	assertSeqRange(t, messages, 11, 20)
+
+	// 回调出错时终止
+	stopErr := errors.New("stop")
+	count := 0
+	err = client.StreamMessages(ctx, &StreamMessagesRequest{TimelineKey: "stream"}, func(*Message) error {
+		count++
+		return stopErr
+	})
+	if !errors.Is(err, stopErr) || count != 1 {
+		t.Errorf("Expected streaming to stop after the first message, got %d messages, %v", count, err)
+	}
+
+	err = client.StreamMessages(ctx, &StreamMessagesRequest{TimelineKey: "missing"}, func(*Message) error { return nil })
+	if err == nil {
+		t.Error("Expected streaming a missing timeline to fail")
+	}
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// MsgType 消息记录类型
+// 编辑和删除不修改原消息，而是向Timeline追加引用原消息的记录，保持Timeline只追加
+type MsgType int32
+
+const (
+	MsgTypeNormal MsgType = iota // 普通消息
+	MsgTypeEdit                  // 编辑记录，Data为修改后的内容
+	MsgTypeDelete                // 删除墓碑，Data为空
+)
+
+// 消息修改错误
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrMessageDeleted     = errors.New("message already deleted")
+	ErrMessageNotEditable = errors.New("only normal messages can be edited or deleted")
+	ErrNotMessageAuthor   = errors.New("only the author can edit a message")
+)
+
+// EditMessage 编辑会话中的消息，只有原发送者可以编辑
+// 向会话和用户Timeline追加一条引用原消息的编辑记录并返回该记录
+func (s *Store) EditMessage(convID string, senderID uint32, seqID int64, data []byte, userIDs []string) (*Message, error) {
+	target, err := s.findMutableMessage(convID, seqID)
+	if err != nil {
+		return nil, err
+	}
+	if target.SenderID != senderID {
+		return nil, ErrNotMessageAuthor
+	}
+	return s.appendMutation(convID, senderID, MsgTypeEdit, seqID, data, userIDs)
+}
+
+// DeleteMessage 删除会话中的消息，追加一条删除墓碑并返回该记录
+// 操作者权限（发送者本人或管理员）由调用方校验，Store只记录操作者
+func (s *Store) DeleteMessage(convID string, senderID uint32, seqID int64, userIDs []string) (*Message, error) {
+	if _, err := s.findMutableMessage(convID, seqID); err != nil {
+		return nil, err
+	}
+	return s.appendMutation(convID, senderID, MsgTypeDelete, seqID, nil, userIDs)
+}
+
+// findMutableMessage 在会话Timeline中查找可修改的原消息
+func (s *Store) findMutableMessage(convID string, seqID int64) (*Message, error) {
+	convTL := s.GetOrCreateConvTimeline(convID)
+
+	convTL.mu.RLock()
+	defer convTL.mu.RUnlock()
+
+	var target *Message
+	deleted := false
+	for _, block := range convTL.Blocks {
+		// 原消息及引用它的删除记录都不早于seqID，之前的未加载块无需读取
+		block.mu.RLock()
+		skip := block.lazy && block.Index.MaxSeqID < seqID
+		block.mu.RUnlock()
+		if skip {
+			continue
+		}
+		messages, err := s.readBlockMessages(block)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			if msg.SeqID == seqID {
+				target = msg
+			} else if msg.Type == MsgTypeDelete && msg.RefSeqID == seqID {
+				deleted = true
+			}
+		}
+	}
+
+	switch {
+	case target == nil:
+		return nil, ErrMessageNotFound
+	case target.Type != MsgTypeNormal:
+		return nil, ErrMessageNotEditable
+	case deleted:
+		return nil, ErrMessageDeleted
+	}
+	return target, nil
+}
+
+// appendMutation 追加编辑或删除记录
+func (s *Store) appendMutation(convID string, senderID uint32, msgType MsgType, refSeqID int64, data []byte, userIDs []string) (*Message, error) {
+	msg := &Message{
+		ConvID:     convID,
+		SenderID:   senderID,
+		CreateTime: time.Now(),
+		Data:       data,
+		Type:       msgType,
+		RefSeqID:   refSeqID,
+	}
+	if _, _, err := s.appendMessage(msg, userIDs); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// ApplyMessageMutations 将编辑和删除记录合并到原消息上，返回可直接展示的消息列表
+// 被编辑的消息返回副本，Data为最后一次编辑的内容；被删除的消息不再返回
+// 引用的原消息不在列表中的记录会被忽略。RefSeqID为会话Timeline中的SeqID，
+// 因此同样适用于包含多个会话记录的用户时间线
+func ApplyMessageMutations(messages []*Message) []*Message {
+	type messageRef struct {
+		convID string
+		seqID  int64
+	}
+	edits := make(map[messageRef]*Message)
+	deleted := make(map[messageRef]bool)
+	for _, msg := range messages {
+		ref := messageRef{msg.ConvID, msg.RefSeqID}
+		switch msg.Type {
+		case MsgTypeEdit:
+			if last, ok := edits[ref]; !ok || msg.SeqID > last.SeqID {
+				edits[ref] = msg
+			}
+		case MsgTypeDelete:
+			deleted[ref] = true
+		}
+	}
+
+	result := make([]*Message, 0, len(messages))
+	for _, msg := range messages {
+		ref := messageRef{msg.ConvID, msg.convSeqID()}
+		if msg.Type != MsgTypeNormal || deleted[ref] {
+			continue
+		}
+		if edit, ok := edits[ref]; ok {
+			edited := *msg
+			edited.Data = edit.Data
+			msg = &edited
+		}
+		result = append(result, msg)
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestEditAndDeleteMessage(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	members := []string{"1", "2"}
+	store.AddMessage("conv_a", 1, []byte("hello"), members)
+	store.AddMessage("conv_a", 2, []byte("world"), members)
+
+	if _, err := store.EditMessage("conv_a", 2, 1, []byte("hijack"), members); !errors.Is(err, ErrNotMessageAuthor) {
+		t.Errorf("Expected ErrNotMessageAuthor, got %v", err)
+	}
+	edit, err := store.EditMessage("conv_a", 1, 1, []byte("hello, edited"), members)
+	if err != nil {
+		t.Fatalf("Failed to edit message: %v", err)
+	}
+	if edit.Type != MsgTypeEdit || edit.RefSeqID != 1 {
+		t.Errorf("Unexpected edit record: %+v", edit)
+	}
+	if _, err := store.EditMessage("conv_a", 1, edit.SeqID, []byte("x"), members); !errors.Is(err, ErrMessageNotEditable) {
+		t.Errorf("Edit records should not be editable, got %v", err)
+	}
+
+	if _, err := store.DeleteMessage("conv_a", 1, 2, members); err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	if _, err := store.DeleteMessage("conv_a", 1, 2, members); !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("Expected ErrMessageDeleted, got %v", err)
+	}
+	if _, err := store.EditMessage("conv_a", 2, 2, []byte("x"), members); !errors.Is(err, ErrMessageDeleted) {
+		t.Errorf("Deleted messages should not be editable, got %v", err)
+	}
+	if _, err := store.DeleteMessage("conv_a", 1, 99, members); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("Expected ErrMessageNotFound, got %v", err)
+	}
+
+	// 原消息保持不变，修改以追加记录的形式存在
+	raw, _ := store.GetConvMessages("conv_a", 10, 0)
+	if len(raw) != 4 || string(raw[0].Data) != "hello" {
+		t.Fatalf("Timeline should stay append-only: %+v", raw)
+	}
+
+	visible := ApplyMessageMutations(raw)
+	if len(visible) != 1 || visible[0].SeqID != 1 || string(visible[0].Data) != "hello, edited" {
+		t.Fatalf("Unexpected visible messages: %+v", visible)
+	}
+	if counts := store.GetUnreadCounts("2"); counts["conv_a"] != 1 {
+		t.Errorf("Mutation records should not count as unread: %v", counts)
+	}
+
+	// 用户时间线中的记录按会话与ConvSeqID合并，另一个会话的同号消息不受影响
+	store.AddMessage("conv_b", 2, []byte("other"), members)
+	store.AddMessage("conv_b", 2, []byte("other 2"), members)
+	userMessages, _ := store.GetUserMessagesAfter("1", 0, 0)
+	visible = ApplyMessageMutations(userMessages)
+	if len(visible) != 3 || string(visible[0].Data) != "hello, edited" || visible[1].ConvID != "conv_b" || visible[1].ConvSeqID != 1 {
+		t.Errorf("Unexpected visible user messages: %+v", visible)
+	}
+}
+
+func TestGRPCEditAndDeleteMessage(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	store.AddMessage("conv_grpc", 1, []byte("hello"), nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(store)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	client := NewGRPCStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, address); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	_, err = client.EditMessage(ctx, &EditMessageRequest{TimelineKey: "conv_grpc", SeqID: 1, SenderID: 2, Data: []byte("x")})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied, got %v", err)
+	}
+
+	edited, err := client.EditMessage(ctx, &EditMessageRequest{TimelineKey: "conv_grpc", SeqID: 1, SenderID: 1, Data: []byte("edited")})
+	if err != nil {
+		t.Fatalf("Failed to edit message: %v", err)
+	}
+	if edited.Message.Type != MsgTypeEdit || edited.Message.RefSeqID != 1 || string(edited.Message.Data) != "edited" {
+		t.Errorf("Unexpected edit record: %+v", edited.Message)
+	}
+
+	deleted, err := client.DeleteMessage(ctx, &DeleteMessageRequest{TimelineKey: "conv_grpc", SeqID: 1, SenderID: 2})
+	if err != nil {
+		t.Fatalf("Failed to delete message: %v", err)
+	}
+	if deleted.Message.Type != MsgTypeDelete || deleted.Message.RefSeqID != 1 {
+		t.Errorf("Unexpected tombstone: %+v", deleted.Message)
+	}
+
+	_, err = client.DeleteMessage(ctx, &DeleteMessageRequest{TimelineKey: "conv_grpc", SeqID: 42, SenderID: 1})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	msgs, err := client.GetMessages(ctx, &GetMessagesRequest{TimelineKey: "conv_grpc", Limit: 10})
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(msgs.Messages) != 3 || msgs.Messages[2].Type != MsgTypeDelete {
+		t.Errorf("Mutation records should round-trip through gRPC: %+v", msgs.Messages)
+	}
+}
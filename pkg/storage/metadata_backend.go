@@ -0,0 +1,406 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store元数据的存储后端
+// Timeline元数据、会话扇出状态、checkpoint、StoreIndex与去重索引按 bucket/key 保存在MetadataBackend中：
+//   - files（默认）：沿用数据目录下的独立文件，{type}_{id}.meta、conv_{id}.fanout 与 tiered/{blockID}.stub 各自原子替换，
+//     checkpoint写入checkpoints.log（见checkpoint_store.go），StoreIndex与去重索引不落盘、启动时从块重建
+//   - kv：全部写入数据目录下的bbolt数据库metadata.kv（见metadata_kv.go），一次Update中的修改整体生效或整体丢弃，
+//     追加消息时会话元数据、StoreIndex与新的去重记录在同一事务中写入，checkpoint的一批更新也是一个事务
+//
+// 首次以kv方式打开已有数据目录时，把元数据文件、扇出状态文件与checkpoint快照/日志导入metadata.kv，
+// 导入提交并落盘后删除原文件；之后再切回files方式不会反向导出。
+// 两种方式下启动扫描（见recovery_scan.go）都以段文件中的块为准修复元数据与StoreIndex。
+
+// MetadataBackendType 元数据后端类型
+type MetadataBackendType string
+
+const (
+	MetadataBackendFiles MetadataBackendType = "files" // 每个Timeline/会话一个文件
+	MetadataBackendKV    MetadataBackendType = "kv"    // 内嵌的bbolt键值存储
+)
+
+// 元数据的bucket
+const (
+	metaBucketTimelines   = "timelines"   // {type}_{id} -> timelineMetadata
+	metaBucketFanouts     = "fanouts"     // convID -> convFanout
+	metaBucketTiered      = "tiered"      // blockID -> tierStub，见tiering.go
+	metaBucketCheckpoints = "checkpoints" // userID -> UserCheckpointState，仅kv
+	metaBucketIndex       = "index"       // {type}_{id} -> []*StoreIndex，仅kv
+	metaBucketStore       = "store"       // 后端自身的信息，如导入标记，仅kv
+	metaDedupBucketPrefix = "dedup:"      // dedup:{convID} 下 clientMsgID -> Message，仅kv
+
+	metaKeyMigrated = "migrated"
+)
+
+// errMetadataBucketUnsupported files后端不支持的bucket
+var errMetadataBucketUnsupported = errors.New("metadata bucket is not supported by the files backend")
+
+// MetadataBackend Store元数据的存储后端
+type MetadataBackend interface {
+	// Get 读取一个键，不存在时返回false
+	Get(bucket, key string) ([]byte, bool, error)
+	// ForEach 遍历bucket中的所有键，顺序不确定；fn返回错误时停止遍历
+	ForEach(bucket string, fn func(key string, value []byte) error) error
+	// Update 在一个事务中执行fn中的修改，fn返回错误时放弃全部修改
+	Update(fn func(tx MetadataTx) error) error
+	// Sync 将已提交的修改刷盘
+	Sync() error
+	Close() error
+}
+
+// MetadataTx Update中的修改，提交时才写入
+type MetadataTx interface {
+	Put(bucket, key string, value []byte)
+	Delete(bucket, key string)
+	DeleteBucket(bucket string)
+}
+
+// dedupBucket 会话去重索引所在的bucket
+func dedupBucket(convID string) string {
+	return metaDedupBucketPrefix + convID
+}
+
+// openMetadataBackend 按配置打开元数据后端，kv方式首次打开时导入已有的元数据文件
+func openMetadataBackend(config *StoreConfig, durability DurabilityPolicy) (MetadataBackend, error) {
+	switch config.MetadataBackend {
+	case "", MetadataBackendFiles:
+		return &fileMetadataBackend{dir: config.DataDir, durability: durability}, nil
+	case MetadataBackendKV:
+		kv, err := openKVMetadataBackend(filepath.Join(config.DataDir, kvMetadataFile), durability)
+		if err != nil {
+			return nil, err
+		}
+		if err := migrateFileMetadata(config.DataDir, kv); err != nil {
+			kv.Close()
+			return nil, fmt.Errorf("failed to migrate metadata files: %w", err)
+		}
+		return kv, nil
+	default:
+		return nil, fmt.Errorf("unknown metadata backend %q", config.MetadataBackend)
+	}
+}
+
+// fileMetadataBackend files方式：timelines、fanouts与tiered三个bucket对应数据目录下的文件
+// 每个文件通过临时文件重命名原子替换，一次Update中的多个修改依次写入，不保证整体原子
+type fileMetadataBackend struct {
+	dir        string
+	durability DurabilityPolicy
+}
+
+// layout bucket中的键所在的目录及文件名的前后缀，ok为false表示bucket不以文件保存
+func (b *fileMetadataBackend) layout(bucket string) (dir, prefix, suffix string, ok bool) {
+	switch bucket {
+	case metaBucketTimelines:
+		return b.dir, "", ".meta", true
+	case metaBucketFanouts:
+		return b.dir, "conv_", ".fanout", true
+	case metaBucketTiered:
+		return filepath.Join(b.dir, "tiered"), "", ".stub", true
+	default:
+		return "", "", "", false
+	}
+}
+
+// path 键对应的文件
+func (b *fileMetadataBackend) path(bucket, key string) (string, bool) {
+	dir, prefix, suffix, ok := b.layout(bucket)
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(dir, prefix+key+suffix), true
+}
+
+func (b *fileMetadataBackend) Get(bucket, key string) ([]byte, bool, error) {
+	path, ok := b.path(bucket, key)
+	if !ok {
+		return nil, false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (b *fileMetadataBackend) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	dir, prefix, suffix, ok := b.layout(bucket)
+	if !ok {
+		return nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"*"+suffix))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		key := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), prefix), suffix)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(key, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *fileMetadataBackend) Update(fn func(tx MetadataTx) error) error {
+	tx := &metadataBatch{}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for _, op := range tx.ops {
+		if _, ok := b.path(op.bucket, op.key); !ok || op.kind == metaOpDeleteBucket {
+			return fmt.Errorf("%w: %s", errMetadataBucketUnsupported, op.bucket)
+		}
+	}
+	for _, op := range tx.ops {
+		path, _ := b.path(op.bucket, op.key)
+		if op.kind == metaOpPut {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := writeFileDurable(path, op.value, b.durability); err != nil {
+				return err
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync 文件在替换时已按持久化策略落盘
+func (b *fileMetadataBackend) Sync() error { return nil }
+
+func (b *fileMetadataBackend) Close() error { return nil }
+
+type metaOpKind byte
+
+const (
+	metaOpPut metaOpKind = iota + 1
+	metaOpDelete
+	metaOpDeleteBucket
+)
+
+// metadataOp 事务中的一个修改
+type metadataOp struct {
+	kind   metaOpKind
+	bucket string
+	key    string
+	value  []byte
+}
+
+// metadataBatch MetadataTx的实现，按顺序记录修改
+type metadataBatch struct {
+	ops []metadataOp
+}
+
+func (t *metadataBatch) Put(bucket, key string, value []byte) {
+	t.ops = append(t.ops, metadataOp{kind: metaOpPut, bucket: bucket, key: key, value: value})
+}
+
+func (t *metadataBatch) Delete(bucket, key string) {
+	t.ops = append(t.ops, metadataOp{kind: metaOpDelete, bucket: bucket, key: key})
+}
+
+func (t *metadataBatch) DeleteBucket(bucket string) {
+	t.ops = append(t.ops, metadataOp{kind: metaOpDeleteBucket, bucket: bucket})
+}
+
+// migrateFileMetadata 把files方式的元数据导入kv后端，已导入过时直接返回
+// 导入在一个事务中提交并落盘后才删除原文件，中途崩溃时下次启动重新导入
+func migrateFileMetadata(dir string, kv MetadataBackend) error {
+	if _, migrated, err := kv.Get(metaBucketStore, metaKeyMigrated); err != nil || migrated {
+		return err
+	}
+
+	files := &fileMetadataBackend{dir: dir}
+	var migratedFiles []string
+	snapshot, _, err := readCheckpointFiles(dir)
+	if err != nil {
+		return err
+	}
+	err = kv.Update(func(tx MetadataTx) error {
+		for _, bucket := range []string{metaBucketTimelines, metaBucketFanouts, metaBucketTiered} {
+			err := files.ForEach(bucket, func(key string, value []byte) error {
+				if bucket == metaBucketTimelines {
+					if _, _, ok := parseTimelineKey(key); !ok {
+						return nil
+					}
+				}
+				tx.Put(bucket, key, value)
+				path, _ := files.path(bucket, key)
+				migratedFiles = append(migratedFiles, path)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		for _, state := range snapshot.states() {
+			data, err := json.Marshal(state)
+			if err != nil {
+				return err
+			}
+			tx.Put(metaBucketCheckpoints, state.UserID, data)
+		}
+		tx.Put(metaBucketStore, metaKeyMigrated, []byte("1"))
+		return nil
+	})
+	if err == nil {
+		err = kv.Sync()
+	}
+	if err != nil {
+		return err
+	}
+
+	migratedFiles = append(migratedFiles, filepath.Join(dir, checkpointSnapshotFile), filepath.Join(dir, checkpointLogFile))
+	for _, path := range migratedFiles {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("metadata migration: failed to remove %s: %v", path, err)
+		}
+	}
+	if len(migratedFiles) > 2 || len(snapshot.Users)+len(snapshot.Convs)+len(snapshot.Pull) > 0 {
+		log.Printf("metadata migration: imported %d metadata files and %d user checkpoints from %s", len(migratedFiles)-2, len(snapshot.states()), dir)
+	}
+	return nil
+}
+
+// loadMetadata 打开元数据后端，按段文件修复Timeline元数据并加载扇出状态与checkpoint
+func (s *Store) loadMetadata() error {
+	metadata, err := openMetadataBackend(s.Config, s.durability)
+	if err != nil {
+		return err
+	}
+	s.metadata = metadata
+	if s.metadataKV() {
+		if err := s.loadStoreIndex(); err != nil {
+			return err
+		}
+	}
+	if err := s.loadTierStubs(); err != nil {
+		return err
+	}
+	if err := s.scanBlocks(); err != nil {
+		return err
+	}
+	if err := s.loadConvFanouts(); err != nil {
+		return err
+	}
+	return s.loadCheckpoints()
+}
+
+// metadataKV 元数据后端是否为kv，StoreIndex、去重索引与checkpoint只在kv方式下写入后端
+func (s *Store) metadataKV() bool {
+	return s.Config.MetadataBackend == MetadataBackendKV
+}
+
+// readTimelineMetadata 读取 {type}_{id} 的元数据，不存在时返回nil
+func (s *Store) readTimelineMetadata(key string) ([]byte, error) {
+	data, _, err := s.metadata.Get(metaBucketTimelines, key)
+	return data, err
+}
+
+// hasTimelineMetadata 是否保存过Timeline的元数据
+func (s *Store) hasTimelineMetadata(key string) bool {
+	_, exists, err := s.metadata.Get(metaBucketTimelines, key)
+	return err == nil && exists
+}
+
+// putTimelineIndexes kv方式下与元数据一同写入StoreIndex及去重索引的变化，调用方持有Timeline读锁与metaMu
+func (s *Store) putTimelineIndexes(tx MetadataTx, tl *Timeline, key string) error {
+	s.indexMu.RLock()
+	data, err := json.Marshal(s.StoreIndex[key])
+	s.indexMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tx.Put(metaBucketIndex, key, data)
+
+	if tl.dedup == nil {
+		return nil
+	}
+	bucket := dedupBucket(tl.ID)
+	for _, clientMsgID := range tl.dedup.evicted {
+		tx.Delete(bucket, clientMsgID)
+	}
+	for _, clientMsgID := range tl.dedup.added {
+		msg := tl.dedup.entries[clientMsgID]
+		if msg == nil {
+			continue
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		tx.Put(bucket, clientMsgID, data)
+	}
+	return nil
+}
+
+// loadStoreIndex kv方式下启动时加载保存的StoreIndex，之后由启动扫描按段文件校正
+func (s *Store) loadStoreIndex() error {
+	return s.metadata.ForEach(metaBucketIndex, func(key string, data []byte) error {
+		var indexes []*StoreIndex
+		if err := json.Unmarshal(data, &indexes); err != nil {
+			return fmt.Errorf("failed to parse store index of %s: %w", key, err)
+		}
+		if len(indexes) > 0 {
+			s.StoreIndex[key] = indexes
+		}
+		return nil
+	})
+}
+
+// loadDedupIndex kv方式下从保存的去重记录恢复会话的去重索引，没有记录时返回false
+// 已过期或超出条数上限的记录在下一次保存元数据时删除
+func (s *Store) loadDedupIndex(tl *Timeline) bool {
+	if tl.Type != "conv" || !s.metadataKV() {
+		return false
+	}
+	var messages []*Message
+	err := s.metadata.ForEach(dedupBucket(tl.ID), func(clientMsgID string, data []byte) error {
+		msg := &Message{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			return fmt.Errorf("failed to parse dedup record %s: %w", clientMsgID, err)
+		}
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		log.Printf("store %s: failed to load dedup index of %s, rebuilding from blocks: %v", s.StoreID, tl.ID, err)
+		return false
+	}
+	if len(messages) == 0 {
+		return false
+	}
+
+	sort.Slice(messages, func(i, j int) bool { return messages[i].SeqID < messages[j].SeqID })
+	dedup := s.newDedupIndex()
+	now := time.Now()
+	for _, msg := range messages {
+		dedup.remember(msg, now)
+		if dedup.entries[msg.ClientMsgID] != msg {
+			dedup.evicted = append(dedup.evicted, msg.ClientMsgID)
+		}
+	}
+	dedup.added = nil
+	tl.dedup = dedup
+	return true
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	bolterrors "go.etcd.io/bbolt/errors"
+)
+
+// 内嵌的事务性键值存储
+// 基于bbolt，数据目录下的metadata.kv为bolt数据库文件，每个bucket对应一个bolt bucket。
+// Update中的修改在一个bolt写事务中提交，整体生效或整体丢弃；崩溃恢复由bolt的双meta页保证。
+// 刷盘遵循DurabilityPolicy：always每个事务fsync，periodic与none打开NoSync，
+// periodic由Store的后台刷盘协程调用Sync，none在Close时也不fsync。
+// 文件被另一进程打开时，打开等待kvOpenTimeout后失败。
+
+const (
+	kvMetadataFile = "metadata.kv"
+	kvOpenTimeout  = time.Second
+)
+
+var errMetadataClosed = errors.New("metadata backend is closed")
+
+// kvMetadataBackend MetadataBackend的kv实现
+type kvMetadataBackend struct {
+	mu         sync.Mutex // 保护closed，避免关闭后刷盘
+	closed     bool
+	db         *bolt.DB
+	durability DurabilityPolicy
+}
+
+// openKVMetadataBackend 打开（或创建）bolt数据库文件
+func openKVMetadataBackend(path string, durability DurabilityPolicy) (*kvMetadataBackend, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: kvOpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open metadata store %s: %w", path, err)
+	}
+	db.NoSync = durability != DurabilityAlways
+	return &kvMetadataBackend{db: db, durability: durability}, nil
+}
+
+// Get 返回值的副本，bolt中的值只在事务内有效
+func (b *kvMetadataBackend) Get(bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	var ok bool
+	err := b.db.View(func(tx *bolt.Tx) error {
+		entries := tx.Bucket([]byte(bucket))
+		if entries == nil {
+			return nil
+		}
+		if v := entries.Get([]byte(key)); v != nil {
+			value, ok = append([]byte(nil), v...), true
+		}
+		return nil
+	})
+	if errors.Is(err, bolterrors.ErrDatabaseNotOpen) {
+		err = errMetadataClosed
+	}
+	return value, ok, err
+}
+
+// ForEach 在读事务内遍历，传给fn的值是副本；fn中不能调用Update
+func (b *kvMetadataBackend) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	err := b.db.View(func(tx *bolt.Tx) error {
+		entries := tx.Bucket([]byte(bucket))
+		if entries == nil {
+			return nil
+		}
+		return entries.ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil // 嵌套bucket，不会由MetadataTx创建
+			}
+			return fn(string(k), append([]byte(nil), v...))
+		})
+	})
+	if errors.Is(err, bolterrors.ErrDatabaseNotOpen) {
+		err = errMetadataClosed
+	}
+	return err
+}
+
+// Update fn中记录的修改在一个bolt写事务中应用，fn返回错误时不打开事务
+func (b *kvMetadataBackend) Update(fn func(tx MetadataTx) error) error {
+	batch := &metadataBatch{}
+	if err := fn(batch); err != nil {
+		return err
+	}
+	if len(batch.ops) == 0 {
+		return nil
+	}
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		for _, op := range batch.ops {
+			if err := applyMetadataOp(tx, op); err != nil {
+				return fmt.Errorf("metadata %s/%s: %w", op.bucket, op.key, err)
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, bolterrors.ErrDatabaseNotOpen) {
+		err = errMetadataClosed
+	}
+	return err
+}
+
+// applyMetadataOp 在写事务中应用一个修改，删除不存在的键或bucket不是错误
+func applyMetadataOp(tx *bolt.Tx, op metadataOp) error {
+	switch op.kind {
+	case metaOpPut:
+		entries, err := tx.CreateBucketIfNotExists([]byte(op.bucket))
+		if err != nil {
+			return err
+		}
+		return entries.Put([]byte(op.key), op.value)
+	case metaOpDelete:
+		if entries := tx.Bucket([]byte(op.bucket)); entries != nil {
+			return entries.Delete([]byte(op.key))
+		}
+	case metaOpDeleteBucket:
+		if err := tx.DeleteBucket([]byte(op.bucket)); err != nil && !errors.Is(err, bolterrors.ErrBucketNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sync periodic策略下把NoSync提交的事务刷盘
+func (b *kvMetadataBackend) Sync() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed || b.durability != DurabilityPeriodic {
+		return nil
+	}
+	return b.db.Sync()
+}
+
+func (b *kvMetadataBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	var err error
+	if b.durability == DurabilityPeriodic {
+		err = b.db.Sync()
+	}
+	if closeErr := b.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
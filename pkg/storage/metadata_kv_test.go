@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestKVMetadataBackendTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), kvMetadataFile)
+	kv, err := openKVMetadataBackend(path, DurabilityAlways)
+	if err != nil {
+		t.Fatalf("Failed to open metadata store: %v", err)
+	}
+	err = kv.Update(func(tx MetadataTx) error {
+		tx.Put("a", "1", []byte("one"))
+		tx.Put("a", "2", []byte("two"))
+		tx.Put("b", "1", []byte("three"))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to commit: %v", err)
+	}
+	// fn返回错误时整个事务不生效
+	err = kv.Update(func(tx MetadataTx) error {
+		tx.Put("a", "1", []byte("changed"))
+		return fmt.Errorf("abort")
+	})
+	if err == nil {
+		t.Fatal("Expected the aborted transaction to return its error")
+	}
+	// 应用到一半失败的事务整体回滚
+	err = kv.Update(func(tx MetadataTx) error {
+		tx.Put("a", "1", []byte("changed"))
+		tx.Put("a", "", []byte("empty key"))
+		return nil
+	})
+	if err == nil {
+		t.Fatal("Expected the transaction with an empty key to fail")
+	}
+	kv.Update(func(tx MetadataTx) error {
+		tx.Delete("a", "2")
+		tx.DeleteBucket("b")
+		tx.DeleteBucket("missing")
+		return nil
+	})
+	if err := kv.Close(); err != nil {
+		t.Fatalf("Failed to close metadata store: %v", err)
+	}
+	if _, _, err := kv.Get("a", "1"); err != errMetadataClosed {
+		t.Errorf("Expected reads after close to fail, got %v", err)
+	}
+
+	reopened, err := openKVMetadataBackend(path, DurabilityAlways)
+	if err != nil {
+		t.Fatalf("Failed to reopen metadata store: %v", err)
+	}
+	defer reopened.Close()
+	if value, ok, _ := reopened.Get("a", "1"); !ok || string(value) != "one" {
+		t.Errorf("Expected a/1 to be one, got %q %v", value, ok)
+	}
+	if _, ok, _ := reopened.Get("a", "2"); ok {
+		t.Error("Expected a/2 to be deleted")
+	}
+	count := 0
+	reopened.ForEach("b", func(string, []byte) error { count++; return nil })
+	if count != 0 {
+		t.Errorf("Expected bucket b to be dropped, found %d keys", count)
+	}
+}
+
+func TestKVMetadataBackendCrashRecovery(t *testing.T) {
+	dir := t.TempDir()
+	kv, err := openKVMetadataBackend(filepath.Join(dir, kvMetadataFile), DurabilityNone)
+	if err != nil {
+		t.Fatalf("Failed to open metadata store: %v", err)
+	}
+	defer kv.Close()
+	kv.Update(func(tx MetadataTx) error {
+		tx.Put("timelines", "conv_1", []byte("v1"))
+		return nil
+	})
+	kv.Update(func(tx MetadataTx) error {
+		tx.Put("timelines", "conv_1", []byte("v2"))
+		tx.Put("timelines", "conv_2", []byte("v1"))
+		return nil
+	})
+
+	// 进程崩溃时文件保持最后一次提交后的内容，未Sync的事务也不丢失
+	snapshot, err := os.ReadFile(filepath.Join(dir, kvMetadataFile))
+	if err != nil {
+		t.Fatalf("Failed to read metadata file: %v", err)
+	}
+	crashed := filepath.Join(dir, "crashed.kv")
+	os.WriteFile(crashed, snapshot, 0644)
+	recovered, err := openKVMetadataBackend(crashed, DurabilityNone)
+	if err != nil {
+		t.Fatalf("Failed to open the crashed copy: %v", err)
+	}
+	if value, _, _ := recovered.Get("timelines", "conv_1"); string(value) != "v2" {
+		t.Errorf("Expected the last commit after a process crash, got %q", value)
+	}
+	recovered.Close()
+
+	// 最后一次提交的meta页写到一半时回到上一次提交，整个事务被丢弃
+	var txid int
+	kv.db.View(func(tx *bolt.Tx) error {
+		txid = tx.ID()
+		return nil
+	})
+	// 事务txid的meta页是第txid%2页，翻转页头（16字节）之后meta中的txid字段使其校验失败
+	torn := filepath.Join(dir, "torn.kv")
+	snapshot[(txid%2)*kv.db.Info().PageSize+16+48] ^= 0xff
+	os.WriteFile(torn, snapshot, 0644)
+	recovered, err = openKVMetadataBackend(torn, DurabilityNone)
+	if err != nil {
+		t.Fatalf("Failed to open the copy with a torn meta page: %v", err)
+	}
+	defer recovered.Close()
+	if value, _, _ := recovered.Get("timelines", "conv_1"); string(value) != "v1" {
+		t.Errorf("Expected the previous commit after a torn commit, got %q", value)
+	}
+	if _, ok, _ := recovered.Get("timelines", "conv_2"); ok {
+		t.Error("Expected the torn commit to be discarded as a whole")
+	}
+}
+
+func TestStoreWithKVMetadata(t *testing.T) {
+	config := &StoreConfig{TimelineMaxSize: 4, DataDir: t.TempDir(), MetadataBackend: MetadataBackendKV}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 6; i++ {
+		if _, _, err := store.AppendClientMessage("kv", fmt.Sprintf("c%d", i), 1, []byte("hi"), []string{"1", "2"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if err := store.SetConvFanoutPolicy("kv", ConvFanoutPolicy{Mode: FanoutPush}); err != nil {
+		t.Fatalf("Failed to set fan-out policy: %v", err)
+	}
+	store.UpdateUserCheckpoint("1", 4)
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	if metas, _ := filepath.Glob(filepath.Join(config.DataDir, "*.meta")); len(metas) != 0 {
+		t.Errorf("Expected no metadata files with the kv backend, found %v", metas)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if messages, _ := reopened.GetConvMessages("kv", 10, 0); len(messages) != 6 {
+		t.Errorf("Expected 6 conversation messages, got %d", len(messages))
+	}
+	if got := reopened.GetUserCheckpoint("1"); got != 4 {
+		t.Errorf("Expected checkpoint 4, got %d", got)
+	}
+	if policy, _ := reopened.ConvFanout("kv"); policy.Mode != FanoutPush {
+		t.Errorf("Expected the fan-out policy to survive a restart, got %+v", policy)
+	}
+	if _, ok, _ := reopened.metadata.Get(dedupBucket("kv"), "c6"); !ok {
+		t.Error("Expected dedup records to be kept in the metadata store")
+	}
+	if _, duplicate, err := reopened.AppendClientMessage("kv", "c6", 1, []byte("hi"), nil); err != nil || !duplicate {
+		t.Errorf("Expected the retry to be deduplicated after reopen, duplicate=%v err=%v", duplicate, err)
+	}
+	if len(reopened.StoreIndex["conv_kv"]) == 0 {
+		t.Error("Expected the store index of conv_kv to be loaded")
+	}
+
+	if _, err := reopened.DeleteTimeline("conv", "kv"); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if _, ok, _ := reopened.metadata.Get(dedupBucket("kv"), "c6"); ok {
+		t.Error("Expected dedup records to be removed with the timeline")
+	}
+}
+
+func TestMigrateFileMetadataToKV(t *testing.T) {
+	config := &StoreConfig{TimelineMaxSize: 4, DataDir: t.TempDir()}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := store.AppendMessage("legacy", 1, []byte("hi"), []string{"1"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	store.UpdateConvCheckpoint("1", "legacy", 3)
+	if err := store.SetConvFanoutPolicy("legacy", ConvFanoutPolicy{Mode: FanoutPush}); err != nil {
+		t.Fatalf("Failed to set fan-out policy: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	config.MetadataBackend = MetadataBackendKV
+	migrated, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to open store with the kv backend: %v", err)
+	}
+	defer migrated.Close()
+	for _, pattern := range []string{"*.meta", "*.fanout", checkpointSnapshotFile, checkpointLogFile} {
+		if leftover, _ := filepath.Glob(filepath.Join(config.DataDir, pattern)); len(leftover) != 0 {
+			t.Errorf("Expected migrated files to be removed, found %v", leftover)
+		}
+	}
+	if messages, _ := migrated.GetConvMessages("legacy", 10, 0); len(messages) != 5 {
+		t.Errorf("Expected 5 conversation messages after migration, got %d", len(messages))
+	}
+	if messages, _ := migrated.GetUserMessagesAfter("1", 0, 0); len(messages) != 5 {
+		t.Errorf("Expected 5 user messages after migration, got %d", len(messages))
+	}
+	if got := migrated.GetCheckpoints([]string{"1"})[0].Convs["legacy"]; got != 3 {
+		t.Errorf("Expected the read position to be migrated, got %d", got)
+	}
+	if policy, _ := migrated.ConvFanout("legacy"); policy.Mode != FanoutPush {
+		t.Errorf("Expected the fan-out policy to be migrated, got %+v", policy)
+	}
+}
@@ -2,7 +2,14 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -33,20 +40,52 @@ type MigrationTask struct {
 	UpdatedAt    time.Time       `json:"updated_at"`
 }
 
+// MigratedBlock 已发送到目标Store的块
+type MigratedBlock struct {
+	BlockID  string `json:"block_id"`
+	Messages int64  `json:"messages"`
+	Checksum uint32 `json:"checksum"`
+}
+
+// MigrationCheckpoint 迁移检查点，按顺序记录已发送的块和Saga执行状态，持久化后可在中断后续传
+type MigrationCheckpoint struct {
+	Task    *MigrationTask   `json:"task"`
+	Blocks  []*MigratedBlock `json:"blocks"`
+	Saga    *SagaState       `json:"saga,omitempty"`
+	Handoff bool             `json:"handoff,omitempty"` // 停机时被中断，下次启动由ResumeHandedOff续传
+}
+
+// ErrMigrationDraining 迁移管理器正在停机排空，不再启动新的迁移
+var ErrMigrationDraining = errors.New("migration manager is draining")
+
+// migrationInterruptGrace 排空期限到达后，等待被中断的迁移保存检查点的时间
+const migrationInterruptGrace = 5 * time.Second
+
+// lastBlockID 最后一个已发送块的ID
+func (cp *MigrationCheckpoint) lastBlockID() string {
+	if len(cp.Blocks) == 0 {
+		return ""
+	}
+	return cp.Blocks[len(cp.Blocks)-1].BlockID
+}
+
 // MigrationManager 迁移管理器接口
 type MigrationManager interface {
 	// StartMigration 开始迁移Timeline
 	StartMigration(ctx context.Context, timelineKey, targetStoreID string) (*MigrationTask, error)
-	
+
+	// ResumeMigration 从检查点继续失败或中断的迁移
+	ResumeMigration(ctx context.Context, taskID string) (*MigrationTask, error)
+
 	// GetMigrationStatus 获取迁移状态
 	GetMigrationStatus(ctx context.Context, taskID string) (*MigrationTask, error)
-	
+
 	// CancelMigration 取消迁移
 	CancelMigration(ctx context.Context, taskID string) error
-	
+
 	// ListMigrations 列出迁移任务
 	ListMigrations(ctx context.Context, status MigrationStatus) ([]*MigrationTask, error)
-	
+
 	// CleanupCompletedMigrations 清理已完成的迁移任务
 	CleanupCompletedMigrations(ctx context.Context, olderThan time.Duration) error
 }
@@ -55,6 +94,8 @@ type MigrationManager interface {
 type TimelineMigrationManager struct {
 	mu                sync.RWMutex
 	tasks             map[string]*MigrationTask
+	checkpoints       map[string]*MigrationCheckpoint // 未完成任务的检查点
+	checkpointDir     string                          // 检查点持久化目录，为空时不持久化
 	localStore        *Store
 	globalIndex       GlobalIndexManager
 	rpcClientPool     *StoreRPCClientPool
@@ -62,9 +103,15 @@ type TimelineMigrationManager struct {
 	lockManager       DistributedLockManager
 	storeID           string
 	runningTasks      map[string]context.CancelFunc // 正在运行的任务取消函数
+	stepAttempts      int                           // 迁移步骤及补偿操作的最大尝试次数
+	stepBackoff       time.Duration                 // 步骤首次重试前的等待时间
+	inflight          sync.WaitGroup                // 执行中的迁移协程
+	draining          bool                          // 停机排空中，不再启动新的迁移
+	interrupted       bool                          // 排空期限已到，新注册的执行协程立即中断
 }
 
 // NewTimelineMigrationManager 创建Timeline迁移管理器
+// 检查点保存在本地Store数据目录的migrations子目录，启动时加载上次未完成的任务
 func NewTimelineMigrationManager(
 	localStore *Store,
 	globalIndex GlobalIndexManager,
@@ -73,8 +120,9 @@ func NewTimelineMigrationManager(
 	lockManager DistributedLockManager,
 	storeID string,
 ) *TimelineMigrationManager {
-	return &TimelineMigrationManager{
+	tmm := &TimelineMigrationManager{
 		tasks:            make(map[string]*MigrationTask),
+		checkpoints:      make(map[string]*MigrationCheckpoint),
 		localStore:       localStore,
 		globalIndex:      globalIndex,
 		rpcClientPool:    rpcClientPool,
@@ -82,27 +130,71 @@ func NewTimelineMigrationManager(
 		lockManager:      lockManager,
 		storeID:          storeID,
 		runningTasks:     make(map[string]context.CancelFunc),
+		stepAttempts:     3,
+		stepBackoff:      time.Second,
 	}
+
+	if localStore != nil && localStore.Config != nil {
+		tmm.checkpointDir = filepath.Join(localStore.Config.DataDir, "migrations")
+		if err := tmm.loadCheckpoints(); err != nil {
+			log.Printf("failed to load migration checkpoints: %v", err)
+		}
+	}
+
+	return tmm
+}
+
+// SetStepRetry 设置迁移步骤及补偿操作的最大尝试次数与首次重试前的等待时间
+func (tmm *TimelineMigrationManager) SetStepRetry(maxAttempts int, backoff time.Duration) {
+	tmm.mu.Lock()
+	defer tmm.mu.Unlock()
+	tmm.stepAttempts = maxAttempts
+	tmm.stepBackoff = backoff
 }
 
 // StartMigration 开始迁移Timeline
+// 同一Timeline到同一目标Store已有未完成的任务时不重复创建：进行中的任务直接返回，失败的任务从检查点续传
 func (tmm *TimelineMigrationManager) StartMigration(ctx context.Context, timelineKey, targetStoreID string) (*MigrationTask, error) {
+	tmm.mu.RLock()
+	if tmm.draining {
+		tmm.mu.RUnlock()
+		return nil, ErrMigrationDraining
+	}
+	var existing *MigrationTask
+	for _, task := range tmm.tasks {
+		if task.TimelineKey != timelineKey || task.TargetStore != targetStoreID {
+			continue
+		}
+		if task.Status == MigrationPending || task.Status == MigrationRunning || task.Status == MigrationFailed {
+			existing = task
+			break
+		}
+	}
+	tmm.mu.RUnlock()
+
+	if existing != nil {
+		if existing.Status == MigrationFailed {
+			return tmm.ResumeMigration(ctx, existing.ID)
+		}
+		return tmm.GetMigrationStatus(ctx, existing.ID)
+	}
+
 	// 获取当前Timeline位置
 	location, err := tmm.globalIndex.GetTimelineLocation(ctx, timelineKey)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get timeline location: %w", err)
 	}
-	
+
 	// 从第一个块获取源Store ID
 	if len(location.Blocks) == 0 {
 		return nil, fmt.Errorf("timeline has no blocks: %s", timelineKey)
 	}
-	
+
 	sourceStoreID := location.Blocks[0].StoreID
 	if sourceStoreID == targetStoreID {
 		return nil, fmt.Errorf("timeline is already on target store")
 	}
-	
+
 	// 创建迁移任务
 	taskID := fmt.Sprintf("migration_%s_%d", timelineKey, time.Now().UnixNano())
 	task := &MigrationTask{
@@ -115,124 +207,607 @@ func (tmm *TimelineMigrationManager) StartMigration(ctx context.Context, timelin
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 	}
-	
+	checkpoint := &MigrationCheckpoint{Task: task, Blocks: make([]*MigratedBlock, 0)}
+
 	tmm.mu.Lock()
+	if tmm.draining {
+		tmm.mu.Unlock()
+		return nil, ErrMigrationDraining
+	}
 	tmm.tasks[taskID] = task
+	tmm.checkpoints[taskID] = checkpoint
+	tmm.inflight.Add(1)
 	tmm.mu.Unlock()
-	
+
+	if err := tmm.saveCheckpoint(checkpoint); err != nil {
+		tmm.mu.Lock()
+		delete(tmm.tasks, taskID)
+		delete(tmm.checkpoints, taskID)
+		tmm.mu.Unlock()
+		tmm.inflight.Done()
+		return nil, err
+	}
+
 	// 启动异步迁移
 	go tmm.executeMigration(ctx, task)
-	
+
 	return task, nil
 }
 
+// ResumeMigration 从检查点继续失败或中断的迁移
+func (tmm *TimelineMigrationManager) ResumeMigration(ctx context.Context, taskID string) (*MigrationTask, error) {
+	tmm.mu.Lock()
+	task, exists := tmm.tasks[taskID]
+	if !exists {
+		tmm.mu.Unlock()
+		return nil, fmt.Errorf("migration task not found: %s", taskID)
+	}
+	if task.Status != MigrationFailed {
+		tmm.mu.Unlock()
+		return nil, fmt.Errorf("cannot resume migration in status: %s", task.Status)
+	}
+	if tmm.draining {
+		tmm.mu.Unlock()
+		return nil, ErrMigrationDraining
+	}
+	if checkpoint, exists := tmm.checkpoints[taskID]; exists {
+		checkpoint.Handoff = false
+	} else {
+		tmm.checkpoints[taskID] = &MigrationCheckpoint{Task: task, Blocks: make([]*MigratedBlock, 0)}
+	}
+	tmm.inflight.Add(1)
+	task.Status = MigrationPending
+	task.Error = ""
+	task.EndTime = nil
+	task.UpdatedAt = time.Now()
+	taskCopy := *task
+	tmm.mu.Unlock()
+
+	go tmm.executeMigration(ctx, task)
+
+	return &taskCopy, nil
+}
+
 // executeMigration 执行迁移
 func (tmm *TimelineMigrationManager) executeMigration(parentCtx context.Context, task *MigrationTask) {
+	defer tmm.inflight.Done()
+
 	// 创建可取消的上下文
 	ctx, cancel := context.WithCancel(parentCtx)
 	defer cancel()
-	
+
 	tmm.mu.Lock()
 	tmm.runningTasks[task.ID] = cancel
+	checkpoint := tmm.checkpoints[task.ID]
+	if tmm.interrupted {
+		cancel()
+	}
 	tmm.mu.Unlock()
-	
+
 	defer func() {
 		tmm.mu.Lock()
 		delete(tmm.runningTasks, task.ID)
 		tmm.mu.Unlock()
 	}()
-	
+
 	// 更新任务状态为运行中
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.0, "")
+	tmm.updateTaskStatus(task.ID, MigrationRunning, task.Progress, "")
 	task.StartTime = time.Now()
-	
+
+	// 补偿完成的迁移重新开始，中断的迁移从Saga记录的步骤继续
+	if checkpoint.Saga == nil || checkpoint.Saga.Status == SagaCompensated {
+		checkpoint.Saga = NewSagaState(task.ID)
+	}
+	saga := tmm.migrationSaga(task, checkpoint)
+
 	// 获取迁移锁
 	lockKey := fmt.Sprintf("migration:%s", task.TimelineKey)
 	err := WithLock(ctx, tmm.lockManager, lockKey, 30*time.Minute, func() error {
-		return tmm.performMigration(ctx, task)
+		return saga.Run(ctx, checkpoint.Saga)
 	})
-	
-	if err != nil {
-		tmm.updateTaskStatus(task.ID, MigrationFailed, task.Progress, err.Error())
-	} else {
+
+	tmm.mu.RLock()
+	cancelled := task.Status == MigrationCancelled
+	tmm.mu.RUnlock()
+
+	switch {
+	case cancelled:
+		// 取消的迁移补偿已执行的步骤，补偿失败时保留检查点，任务标记为失败以便重试
+		if err := saga.Compensate(parentCtx, checkpoint.Saga); err != nil {
+			log.Printf("migration %s: failed to compensate cancelled migration: %v", task.ID, err)
+			tmm.mu.Lock()
+			task.Status = MigrationFailed
+			task.Error = err.Error()
+			tmm.mu.Unlock()
+			break
+		}
+		tmm.removeCheckpoint(task.ID)
+	case err != nil:
+		message := err.Error()
+		tmm.mu.Lock()
+		if tmm.interrupted && ctx.Err() != nil {
+			// 停机中断的迁移不补偿，保留检查点交给下次启动续传
+			checkpoint.Handoff = true
+			message = "migration interrupted by shutdown"
+		}
+		tmm.mu.Unlock()
+		tmm.updateTaskStatus(task.ID, MigrationFailed, task.Progress, message)
+		if saveErr := tmm.saveCheckpoint(checkpoint); saveErr != nil {
+			log.Printf("failed to save migration checkpoint %s: %v", task.ID, saveErr)
+		}
+	default:
 		tmm.updateTaskStatus(task.ID, MigrationCompleted, 1.0, "")
+		tmm.removeCheckpoint(task.ID)
 	}
-	
+
+	tmm.mu.Lock()
 	now := time.Now()
 	task.EndTime = &now
+	tmm.mu.Unlock()
 }
 
-// performMigration 执行具体的迁移操作
-func (tmm *TimelineMigrationManager) performMigration(ctx context.Context, task *MigrationTask) error {
-	// 步骤1: 获取源Timeline数据 (20%)
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.1, "Getting source timeline")
-	
-	sourceTimeline, err := tmm.crossStoreAccess.GetTimeline(ctx, task.TimelineKey)
+// Drain 停机时排空迁移：不再启动新的迁移，等待进行中的迁移在ctx到期前完成。
+// 到期后中断仍在进行的迁移，已执行的步骤不补偿，检查点标记为交接后保存，
+// 返回这些任务的ID，下次启动时由ResumeHandedOff续传
+func (tmm *TimelineMigrationManager) Drain(ctx context.Context) ([]string, error) {
+	tmm.mu.Lock()
+	tmm.draining = true
+	tmm.mu.Unlock()
+
+	if waitGroupWait(ctx, &tmm.inflight) == nil {
+		return nil, nil
+	}
+
+	tmm.mu.Lock()
+	tmm.interrupted = true
+	handedOff := make([]string, 0, len(tmm.runningTasks))
+	for taskID, cancel := range tmm.runningTasks {
+		handedOff = append(handedOff, taskID)
+		cancel()
+	}
+	tmm.mu.Unlock()
+	sort.Strings(handedOff)
+
+	graceCtx, cancel := context.WithTimeout(context.Background(), migrationInterruptGrace)
+	defer cancel()
+	if err := waitGroupWait(graceCtx, &tmm.inflight); err != nil {
+		return handedOff, fmt.Errorf("interrupted migrations did not stop within %s", migrationInterruptGrace)
+	}
+	return handedOff, nil
+}
+
+// ResumeHandedOff 续传上次停机时交接的迁移，在Store重新加入集群后调用
+func (tmm *TimelineMigrationManager) ResumeHandedOff(ctx context.Context) []*MigrationTask {
+	tmm.mu.RLock()
+	var taskIDs []string
+	for taskID, checkpoint := range tmm.checkpoints {
+		if checkpoint.Handoff && checkpoint.Task.Status == MigrationFailed {
+			taskIDs = append(taskIDs, taskID)
+		}
+	}
+	tmm.mu.RUnlock()
+	sort.Strings(taskIDs)
+
+	resumed := make([]*MigrationTask, 0, len(taskIDs))
+	for _, taskID := range taskIDs {
+		task, err := tmm.ResumeMigration(ctx, taskID)
+		if err != nil {
+			log.Printf("migration %s: failed to resume handed off migration: %v", taskID, err)
+			continue
+		}
+		resumed = append(resumed, task)
+	}
+	return resumed
+}
+
+// waitGroupWait 等待wg完成，ctx先结束时返回ctx的错误
+func waitGroupWait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// migrationEndpoint 迁移两端需要的能力：块级导出/导入、查询与删除Timeline
+type migrationEndpoint interface {
+	StoreBlockStreamer
+	GetTimeline(ctx context.Context, req *GetTimelineRequest) (*GetTimelineResponse, error)
+	DeleteTimeline(ctx context.Context, req *DeleteTimelineRequest) (*DeleteTimelineResponse, error)
+}
+
+// endpoint 获取Store的迁移端点，本地Store直接访问，远程Store需使用支持块流的传输（gRPC）
+func (tmm *TimelineMigrationManager) endpoint(ctx context.Context, storeID string) (migrationEndpoint, error) {
+	if tmm.localStore != nil && storeID == tmm.localStore.StoreID {
+		return NewLocalStoreService(tmm.localStore), nil
+	}
+
+	if tmm.crossStoreAccess == nil {
+		return nil, fmt.Errorf("remote access is not configured")
+	}
+	client, err := tmm.crossStoreAccess.getRemoteClient(ctx, storeID)
 	if err != nil {
-		return fmt.Errorf("failed to get source timeline: %w", err)
+		return nil, err
 	}
-	
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.2, "Got source timeline")
-	
-	// 步骤2: 在目标Store创建Timeline (40%)
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.3, "Creating target timeline")
-	
-	err = tmm.crossStoreAccess.CreateTimeline(ctx, task.TimelineKey, sourceTimeline.Type)
+	endpoint, ok := client.(migrationEndpoint)
+	if !ok {
+		return nil, fmt.Errorf("store %s does not support block streaming, use grpc transport", storeID)
+	}
+	return endpoint, nil
+}
+
+// timelineBlocks 获取端点上Timeline的块列表，Timeline不存在时返回空列表
+func timelineBlocks(ctx context.Context, endpoint migrationEndpoint, timelineKey string) ([]*TimelineBlock, error) {
+	resp, err := endpoint.GetTimeline(ctx, &GetTimelineRequest{TimelineKey: timelineKey})
 	if err != nil {
-		return fmt.Errorf("failed to create target timeline: %w", err)
+		return nil, err
 	}
-	
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.4, "Created target timeline")
-	
-	// 步骤3: 迁移消息数据 (70%)
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.5, "Migrating messages")
-	
-	// 获取所有消息（这里简化处理，实际应该分批处理）
-	messages, err := tmm.crossStoreAccess.GetMessages(ctx, task.TimelineKey, 0, time.Now().Unix(), 10000)
+	if !resp.Exists || resp.Timeline == nil {
+		return nil, nil
+	}
+	// 本地端点返回的也是Timeline快照，可直接使用
+	return resp.Timeline.Blocks, nil
+}
+
+// migrationSaga 块级迁移的步骤：准备目标Store → 复制块 → 切换全局索引 → 删除源数据
+// 源Store流式导出块，直接转发到目标Store导入，块ID与消息SeqID保持不变。每发送一个块更新一次检查点，
+// 续传时先与目标Store已有的块对账；全部传输后校验块数、消息数与校验和，一致才切换全局索引。
+// 复制或切换失败时删除目标Store上导入的数据并把索引切回源Store；删除源数据之后不再补偿
+func (tmm *TimelineMigrationManager) migrationSaga(task *MigrationTask, checkpoint *MigrationCheckpoint) *SagaOrchestrator {
+	steps := []SagaStep{
+		{
+			// 目标Timeline由第一个导入的块创建，这里只确认目标上没有其他数据并确定续传位置
+			Name: "create_target",
+			Action: func(ctx context.Context) error {
+				tmm.updateTaskStatus(task.ID, MigrationRunning, 0.05, "Preparing target store")
+				target, err := tmm.endpoint(ctx, task.TargetStore)
+				if err != nil {
+					return fmt.Errorf("failed to connect target store: %w", err)
+				}
+				return tmm.reconcileTarget(ctx, target, checkpoint)
+			},
+		},
+		{
+			Name: "copy_blocks",
+			Action: func(ctx context.Context) error {
+				return tmm.copyBlocks(ctx, task, checkpoint)
+			},
+			Compensate: func(ctx context.Context) error {
+				if err := tmm.cleanupTarget(ctx, checkpoint); err != nil {
+					return err
+				}
+				checkpoint.Blocks = checkpoint.Blocks[:0]
+				return nil
+			},
+		},
+		{
+			Name: "switch_index",
+			Action: func(ctx context.Context) error {
+				tmm.updateTaskStatus(task.ID, MigrationRunning, 0.85, "Updating global index")
+				if err := tmm.switchIndex(ctx, task.TimelineKey, task.SourceStore, task.TargetStore); err != nil {
+					return fmt.Errorf("failed to update global index: %w", err)
+				}
+				tmm.updateTaskStatus(task.ID, MigrationRunning, 0.9, "Global index updated")
+				return nil
+			},
+			Compensate: func(ctx context.Context) error {
+				return tmm.switchIndex(ctx, task.TimelineKey, task.TargetStore, task.SourceStore)
+			},
+		},
+		{
+			Name: "delete_source",
+			Action: func(ctx context.Context) error {
+				tmm.updateTaskStatus(task.ID, MigrationRunning, 0.95, "Cleaning up source store")
+				source, err := tmm.endpoint(ctx, task.SourceStore)
+				if err == nil {
+					_, err = source.DeleteTimeline(ctx, &DeleteTimelineRequest{TimelineKey: task.TimelineKey, Force: true})
+				}
+				if err != nil {
+					// 记录警告但不失败，因为数据已经迁移成功
+					log.Printf("Warning: failed to cleanup source timeline %s: %v", task.TimelineKey, err)
+				}
+				tmm.updateTaskStatus(task.ID, MigrationRunning, 1.0, "Migration completed")
+				return nil
+			},
+		},
+	}
+
+	tmm.mu.RLock()
+	attempts, backoff := tmm.stepAttempts, tmm.stepBackoff
+	tmm.mu.RUnlock()
+
+	saga := NewSagaOrchestrator(steps, func(*SagaState) error {
+		return tmm.saveCheckpoint(checkpoint)
+	})
+	saga.SetRetry(attempts, backoff)
+	return saga
+}
+
+// copyBlocks 从检查点位置继续流式传输块并校验目标Store
+func (tmm *TimelineMigrationManager) copyBlocks(ctx context.Context, task *MigrationTask, checkpoint *MigrationCheckpoint) error {
+	source, err := tmm.endpoint(ctx, task.SourceStore)
 	if err != nil {
-		return fmt.Errorf("failed to get messages: %w", err)
+		return fmt.Errorf("failed to connect source store: %w", err)
 	}
-	
-	// 批量添加消息到目标Store
-	for i, msg := range messages {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
+	target, err := tmm.endpoint(ctx, task.TargetStore)
+	if err != nil {
+		return fmt.Errorf("failed to connect target store: %w", err)
+	}
+
+	// 全局索引中的块数只用于估算进度
+	expectedBlocks := 0
+	if location, err := tmm.globalIndex.GetTimelineLocation(ctx, task.TimelineKey); err == nil {
+		expectedBlocks = location.BlockCount
+	}
+
+	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.1, fmt.Sprintf("Streaming blocks after %d transferred", len(checkpoint.Blocks)))
+
+	_, err = target.ImportTimelineBlocks(ctx, func(send func(*TimelineBlockData) error) error {
+		req := &StreamTimelineBlocksRequest{
+			TimelineKey:  task.TimelineKey,
+			AfterBlockID: checkpoint.lastBlockID(),
 		}
-		
-		err = tmm.crossStoreAccess.AddMessage(ctx, task.TimelineKey, msg.SenderID, msg.Data, nil)
-		if err != nil {
-			return fmt.Errorf("failed to add message %d: %w", i, err)
+		return source.StreamTimelineBlocks(ctx, req, func(data *TimelineBlockData) error {
+			if err := send(data); err != nil {
+				return err
+			}
+
+			checkpoint.Blocks = append(checkpoint.Blocks, &MigratedBlock{
+				BlockID:  data.Block.BlockID,
+				Messages: int64(len(data.Messages)),
+				Checksum: blockChecksum(data.Messages),
+			})
+			if err := tmm.saveCheckpoint(checkpoint); err != nil {
+				return err
+			}
+
+			transferred := len(checkpoint.Blocks)
+			progress := 0.8
+			if expectedBlocks > transferred {
+				progress = 0.1 + 0.7*float64(transferred)/float64(expectedBlocks)
+			}
+			tmm.updateTaskStatus(task.ID, MigrationRunning, progress, fmt.Sprintf("Transferred %d blocks", transferred))
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to transfer blocks: %w", err)
+	}
+
+	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.8, "Verifying target store")
+	return tmm.verifyMigration(ctx, source, target, checkpoint)
+}
+
+// switchIndex 把全局索引中位于from的块切换到to，已没有位于from的块时视为已完成
+func (tmm *TimelineMigrationManager) switchIndex(ctx context.Context, timelineKey, from, to string) error {
+	location, err := tmm.globalIndex.GetTimelineLocation(ctx, timelineKey)
+	if err != nil {
+		return err
+	}
+	for _, index := range location.Blocks {
+		if index.StoreID == from {
+			return tmm.globalIndex.MigrateTimeline(ctx, timelineKey, from, to)
 		}
-		
-		// 更新进度
-		progress := 0.5 + 0.2*float64(i+1)/float64(len(messages))
-		tmm.updateTaskStatus(task.ID, MigrationRunning, progress, fmt.Sprintf("Migrated %d/%d messages", i+1, len(messages)))
 	}
-	
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.7, "Messages migrated")
-	
-	// 步骤4: 更新全局索引 (90%)
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.8, "Updating global index")
-	
-	err = tmm.globalIndex.MigrateTimeline(ctx, task.TimelineKey, task.SourceStore, task.TargetStore)
+	return nil
+}
+
+// reconcileTarget 用目标Store上实际存在的块修正检查点
+// 目标块与检查点前缀一致时从该位置续传；内容不一致但都来自本次迁移时清空目标重新开始；
+// 目标上存在检查点之外的块说明有其他数据，拒绝迁移
+func (tmm *TimelineMigrationManager) reconcileTarget(ctx context.Context, target migrationEndpoint, checkpoint *MigrationCheckpoint) error {
+	blocks, err := timelineBlocks(ctx, target, checkpoint.Task.TimelineKey)
 	if err != nil {
-		return fmt.Errorf("failed to update global index: %w", err)
+		return fmt.Errorf("failed to get target timeline: %w", err)
 	}
-	
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.9, "Global index updated")
-	
-	// 步骤5: 清理源Store数据 (100%)
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 0.95, "Cleaning up source store")
-	
-	err = tmm.crossStoreAccess.DeleteTimeline(ctx, task.TimelineKey)
+
+	sent := make(map[string]bool, len(checkpoint.Blocks))
+	for _, migrated := range checkpoint.Blocks {
+		sent[migrated.BlockID] = true
+	}
+
+	consistent := len(blocks) <= len(checkpoint.Blocks)
+	for i, block := range blocks {
+		if !sent[block.BlockID] {
+			return fmt.Errorf("target store %s already has timeline data: %s", checkpoint.Task.TargetStore, checkpoint.Task.TimelineKey)
+		}
+		if consistent {
+			migrated := checkpoint.Blocks[i]
+			consistent = block.BlockID == migrated.BlockID && block.Size == migrated.Messages && block.Checksum == migrated.Checksum
+		}
+	}
+
+	if consistent {
+		// 已发送但目标未提交的块需要重新发送
+		checkpoint.Blocks = checkpoint.Blocks[:len(blocks)]
+	} else {
+		log.Printf("migration %s: target data does not match checkpoint, restarting", checkpoint.Task.ID)
+		if _, err := target.DeleteTimeline(ctx, &DeleteTimelineRequest{TimelineKey: checkpoint.Task.TimelineKey, Force: true}); err != nil {
+			return fmt.Errorf("failed to cleanup target timeline: %w", err)
+		}
+		checkpoint.Blocks = checkpoint.Blocks[:0]
+	}
+
+	return tmm.saveCheckpoint(checkpoint)
+}
+
+// verifyMigration 校验目标Store的块与检查点记录的源数据一致，且源Timeline在迁移期间没有变化
+func (tmm *TimelineMigrationManager) verifyMigration(ctx context.Context, source, target migrationEndpoint, checkpoint *MigrationCheckpoint) error {
+	timelineKey := checkpoint.Task.TimelineKey
+
+	var expectedMessages int64
+	for _, migrated := range checkpoint.Blocks {
+		expectedMessages += migrated.Messages
+	}
+
+	targetBlocks, err := timelineBlocks(ctx, target, timelineKey)
 	if err != nil {
-		// 记录警告但不失败，因为数据已经迁移成功
-		fmt.Printf("Warning: failed to cleanup source timeline: %v\n", err)
+		return fmt.Errorf("failed to get target timeline: %w", err)
 	}
-	
-	tmm.updateTaskStatus(task.ID, MigrationRunning, 1.0, "Migration completed")
+	if len(targetBlocks) != len(checkpoint.Blocks) {
+		return fmt.Errorf("verification failed: target has %d blocks, expected %d", len(targetBlocks), len(checkpoint.Blocks))
+	}
+	var targetMessages int64
+	for i, block := range targetBlocks {
+		migrated := checkpoint.Blocks[i]
+		if block.BlockID != migrated.BlockID || block.Size != migrated.Messages || block.Checksum != migrated.Checksum {
+			return fmt.Errorf("verification failed: block %s mismatch on target", migrated.BlockID)
+		}
+		targetMessages += block.Size
+	}
+	if targetMessages != expectedMessages {
+		return fmt.Errorf("verification failed: target has %d messages, expected %d", targetMessages, expectedMessages)
+	}
+
+	sourceBlocks, err := timelineBlocks(ctx, source, timelineKey)
+	if err != nil {
+		return fmt.Errorf("failed to get source timeline: %w", err)
+	}
+	if len(sourceBlocks) != len(checkpoint.Blocks) {
+		return fmt.Errorf("verification failed: source timeline changed during migration")
+	}
+	for i, block := range sourceBlocks {
+		if block.BlockID != checkpoint.Blocks[i].BlockID || block.Size != checkpoint.Blocks[i].Messages {
+			return fmt.Errorf("verification failed: source timeline changed during migration")
+		}
+	}
+
+	return nil
+}
+
+// cleanupTarget 删除目标Store上本次迁移导入的部分数据
+func (tmm *TimelineMigrationManager) cleanupTarget(ctx context.Context, checkpoint *MigrationCheckpoint) error {
+	if checkpoint == nil || len(checkpoint.Blocks) == 0 {
+		return nil
+	}
+
+	target, err := tmm.endpoint(ctx, checkpoint.Task.TargetStore)
+	if err != nil {
+		return fmt.Errorf("failed to connect target for cleanup: %w", err)
+	}
+
+	// 目标上有检查点之外的块时不删除，避免误删其他数据
+	blocks, err := timelineBlocks(ctx, target, checkpoint.Task.TimelineKey)
+	if err != nil {
+		return fmt.Errorf("failed to get target timeline for cleanup: %w", err)
+	}
+	sent := make(map[string]bool, len(checkpoint.Blocks))
+	for _, migrated := range checkpoint.Blocks {
+		sent[migrated.BlockID] = true
+	}
+	for _, block := range blocks {
+		if !sent[block.BlockID] {
+			log.Printf("migration %s: target has other data, skip cleanup", checkpoint.Task.ID)
+			return nil
+		}
+	}
+
+	if _, err := target.DeleteTimeline(ctx, &DeleteTimelineRequest{TimelineKey: checkpoint.Task.TimelineKey, Force: true}); err != nil {
+		return fmt.Errorf("failed to cleanup target timeline: %w", err)
+	}
+	return nil
+}
+
+// compensateMigration 撤销迁移已产生的影响，没有Saga状态的旧检查点只清理目标Store
+func (tmm *TimelineMigrationManager) compensateMigration(ctx context.Context, checkpoint *MigrationCheckpoint) error {
+	if checkpoint == nil {
+		return nil
+	}
+	if checkpoint.Saga == nil {
+		return tmm.cleanupTarget(ctx, checkpoint)
+	}
+	return tmm.migrationSaga(checkpoint.Task, checkpoint).Compensate(ctx, checkpoint.Saga)
+}
+
+// checkpointPath 检查点文件路径
+func (tmm *TimelineMigrationManager) checkpointPath(taskID string) string {
+	return filepath.Join(tmm.checkpointDir, taskID+".json")
+}
+
+// saveCheckpoint 持久化检查点，先写临时文件再重命名
+func (tmm *TimelineMigrationManager) saveCheckpoint(checkpoint *MigrationCheckpoint) error {
+	if tmm.checkpointDir == "" || checkpoint == nil {
+		return nil
+	}
+
+	tmm.mu.RLock()
+	data, err := json.Marshal(checkpoint)
+	tmm.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode migration checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(tmm.checkpointDir, 0755); err != nil {
+		return fmt.Errorf("failed to create checkpoint dir: %w", err)
+	}
+
+	path := tmm.checkpointPath(checkpoint.Task.ID)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write migration checkpoint: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write migration checkpoint: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint 删除已结束任务的检查点
+func (tmm *TimelineMigrationManager) removeCheckpoint(taskID string) {
+	tmm.mu.Lock()
+	delete(tmm.checkpoints, taskID)
+	tmm.mu.Unlock()
+
+	if tmm.checkpointDir == "" {
+		return
+	}
+	if err := os.Remove(tmm.checkpointPath(taskID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("failed to remove migration checkpoint %s: %v", taskID, err)
+	}
+}
+
+// loadCheckpoints 加载未完成任务的检查点，上次运行中断的任务标记为失败，可通过ResumeMigration续传
+func (tmm *TimelineMigrationManager) loadCheckpoints() error {
+	entries, err := os.ReadDir(tmm.checkpointDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(tmm.checkpointDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		var checkpoint MigrationCheckpoint
+		if err := json.Unmarshal(data, &checkpoint); err != nil || checkpoint.Task == nil {
+			log.Printf("skip invalid migration checkpoint %s", entry.Name())
+			continue
+		}
+
+		task := checkpoint.Task
+		if task.Status == MigrationPending || task.Status == MigrationRunning {
+			task.Status = MigrationFailed
+			task.Error = "migration interrupted"
+			task.UpdatedAt = time.Now()
+		}
+		tmm.tasks[task.ID] = task
+		tmm.checkpoints[task.ID] = &checkpoint
+	}
+
 	return nil
 }
 
@@ -241,7 +816,8 @@ func (tmm *TimelineMigrationManager) updateTaskStatus(taskID string, status Migr
 	tmm.mu.Lock()
 	defer tmm.mu.Unlock()
 	
-	if task, exists := tmm.tasks[taskID]; exists {
+	// 已取消的任务不再被执行协程的进度更新覆盖
+	if task, exists := tmm.tasks[taskID]; exists && task.Status != MigrationCancelled {
 		task.Status = status
 		task.Progress = progress
 		if message != "" {
@@ -267,21 +843,24 @@ func (tmm *TimelineMigrationManager) GetMigrationStatus(ctx context.Context, tas
 }
 
 // CancelMigration 取消迁移
+// 运行中的任务由执行协程在退出时补偿已执行的步骤；失败的任务在此直接补偿并删除检查点
 func (tmm *TimelineMigrationManager) CancelMigration(ctx context.Context, taskID string) error {
 	tmm.mu.Lock()
-	defer tmm.mu.Unlock()
 	
 	task, exists := tmm.tasks[taskID]
 	if !exists {
+		tmm.mu.Unlock()
 		return fmt.Errorf("migration task not found: %s", taskID)
 	}
 	
-	if task.Status != MigrationRunning && task.Status != MigrationPending {
+	if task.Status != MigrationRunning && task.Status != MigrationPending && task.Status != MigrationFailed {
+		tmm.mu.Unlock()
 		return fmt.Errorf("cannot cancel migration in status: %s", task.Status)
 	}
 	
 	// 取消正在运行的任务
-	if cancel, exists := tmm.runningTasks[taskID]; exists {
+	cancel, running := tmm.runningTasks[taskID]
+	if running {
 		cancel()
 	}
 	
@@ -289,6 +868,15 @@ func (tmm *TimelineMigrationManager) CancelMigration(ctx context.Context, taskID
 	task.UpdatedAt = time.Now()
 	now := time.Now()
 	task.EndTime = &now
+	checkpoint := tmm.checkpoints[taskID]
+	tmm.mu.Unlock()
+	
+	if !running {
+		if err := tmm.compensateMigration(ctx, checkpoint); err != nil {
+			log.Printf("migration %s: failed to compensate cancelled migration: %v", taskID, err)
+		}
+		tmm.removeCheckpoint(taskID)
+	}
 	
 	return nil
 }
@@ -323,4 +911,4 @@ func (tmm *TimelineMigrationManager) CleanupCompletedMigrations(ctx context.Cont
 	}
 	
 	return nil
-}
\ No newline at end of file
+}
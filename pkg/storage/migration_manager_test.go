@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlockLevelMigrationOverGRPC(t *testing.T) {
+	ctx := context.Background()
+
+	source, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create source store: %v", err)
+	}
+	targetDir := t.TempDir()
+	target, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: targetDir})
+	if err != nil {
+		t.Fatalf("Failed to create target store: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(target)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: target.StoreID, Address: address})
+
+	timelineKey := "conv_migrate"
+	for i := 0; i < 5; i++ {
+		if err := source.AddMessage(timelineKey, 1, []byte{byte(i)}, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	sourceMessages, _ := source.GetConvMessages(timelineKey, 10, 0)
+	// 源Timeline在迁移完成后被删除，先保存块列表
+	sourceBlocks := append([]*TimelineBlock(nil), source.GetOrCreateConvTimeline(timelineKey).Blocks...)
+
+	globalIndex := NewInMemoryGlobalIndex()
+	for _, block := range sourceBlocks {
+		globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: source.StoreID, BlockID: block.BlockID})
+	}
+
+	pool := NewStoreRPCClientPoolWithTransport(TransportGRPC, 5*time.Second)
+	defer pool.Close()
+	accessor := NewDistributedStoreAccessor(source, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+	manager := NewTimelineMigrationManager(source, globalIndex, pool, accessor, NewInMemoryDistributedLockManager(source.StoreID), source.StoreID)
+
+	task, err := manager.StartMigration(ctx, timelineKey, target.StoreID)
+	if err != nil {
+		t.Fatalf("Failed to start migration: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var status *MigrationTask
+	for time.Now().Before(deadline) {
+		status, _ = manager.GetMigrationStatus(ctx, task.ID)
+		if status.Status == MigrationCompleted || status.Status == MigrationFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Status != MigrationCompleted {
+		t.Fatalf("Migration did not complete: %s %s", status.Status, status.Error)
+	}
+
+	// 块ID与SeqID保持不变
+	targetTimeline := target.GetOrCreateConvTimeline(timelineKey)
+	if len(targetTimeline.Blocks) != len(sourceBlocks) {
+		t.Fatalf("Expected %d blocks on target, got %d", len(sourceBlocks), len(targetTimeline.Blocks))
+	}
+	for i, block := range targetTimeline.Blocks {
+		if block.BlockID != sourceBlocks[i].BlockID {
+			t.Errorf("Block %d id changed: %s != %s", i, block.BlockID, sourceBlocks[i].BlockID)
+		}
+	}
+	targetMessages, _ := target.GetConvMessages(timelineKey, 10, 0)
+	if len(targetMessages) != len(sourceMessages) {
+		t.Fatalf("Expected %d messages on target, got %d", len(sourceMessages), len(targetMessages))
+	}
+	for i, msg := range targetMessages {
+		if msg.SeqID != sourceMessages[i].SeqID || msg.Data[0] != sourceMessages[i].Data[0] {
+			t.Errorf("Message %d mismatch: %+v != %+v", i, msg, sourceMessages[i])
+		}
+	}
+
+	location, _ := globalIndex.GetTimelineLocation(ctx, timelineKey)
+	for _, index := range location.Blocks {
+		if index.StoreID != target.StoreID {
+			t.Errorf("Global index still points to %s", index.StoreID)
+		}
+	}
+
+	// 新写入的SeqID接在迁移的消息之后，且导入的块重启后可读
+	if err := target.AddMessage(timelineKey, 1, []byte{9}, nil); err != nil {
+		t.Fatalf("Failed to add message after migration: %v", err)
+	}
+	server.Stop(ctx)
+	target.Close()
+
+	reopened, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: targetDir})
+	if err != nil {
+		t.Fatalf("Failed to reopen target store: %v", err)
+	}
+	defer reopened.Close()
+	messages, _ := reopened.GetConvMessages(timelineKey, 10, 0)
+	if len(messages) != 6 || messages[5].SeqID <= sourceMessages[4].SeqID {
+		t.Errorf("Unexpected messages after reopen: %d", len(messages))
+	}
+}
+
+func TestImportTimelineBlocksRejectsExistingTimeline(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	store.AddMessage("conv_existing", 1, []byte("local"), nil)
+
+	blocks := []*TimelineBlockData{{
+		TimelineKey:  "conv_existing",
+		TimelineType: "conv",
+		Block:        &TimelineBlock{BlockID: "conv_conv_existing_1"},
+		Messages:     []*Message{{SeqID: 100, Data: []byte("remote")}},
+	}}
+	if _, err := store.ImportTimelineBlock("conv", "conv_existing", blocks[0]); err == nil {
+		t.Fatal("Expected import into non-empty timeline to fail")
+	}
+	if store.segments.HasBlock("conv_conv_existing_1") {
+		t.Error("Rejected block should not be persisted")
+	}
+}
+
+func TestResumeMigrationFromCheckpoint(t *testing.T) {
+	ctx := context.Background()
+
+	sourceDir := t.TempDir()
+	source, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: sourceDir})
+	if err != nil {
+		t.Fatalf("Failed to create source store: %v", err)
+	}
+	target, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create target store: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(target)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: target.StoreID, Address: address})
+
+	timelineKey := "conv_resume"
+	for i := 0; i < 5; i++ {
+		if err := source.AddMessage(timelineKey, 1, []byte{byte(i)}, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	sourceMessages, _ := source.GetConvMessages(timelineKey, 10, 0)
+
+	globalIndex := NewInMemoryGlobalIndex()
+	var first *TimelineBlockData
+	NewLocalStoreService(source).StreamTimelineBlocks(ctx, &StreamTimelineBlocksRequest{TimelineKey: timelineKey}, func(data *TimelineBlockData) error {
+		if first == nil {
+			first = data
+		}
+		globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: source.StoreID, BlockID: data.Block.BlockID})
+		return nil
+	})
+
+	// 模拟上次迁移在发送第一个块后中断：目标已导入该块，检查点已落盘
+	if _, err := target.ImportTimelineBlock("conv", timelineKey, first); err != nil {
+		t.Fatalf("Failed to pre-import block: %v", err)
+	}
+	interrupted := &TimelineMigrationManager{checkpointDir: filepath.Join(sourceDir, "migrations")}
+	task := &MigrationTask{ID: "migration_resume", TimelineKey: timelineKey, SourceStore: source.StoreID, TargetStore: target.StoreID, Status: MigrationRunning}
+	checkpoint := &MigrationCheckpoint{Task: task, Blocks: []*MigratedBlock{{
+		BlockID:  first.Block.BlockID,
+		Messages: int64(len(first.Messages)),
+		Checksum: blockChecksum(first.Messages),
+	}}}
+	if err := interrupted.saveCheckpoint(checkpoint); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	pool := NewStoreRPCClientPoolWithTransport(TransportGRPC, 5*time.Second)
+	defer pool.Close()
+	accessor := NewDistributedStoreAccessor(source, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+	manager := NewTimelineMigrationManager(source, globalIndex, pool, accessor, NewInMemoryDistributedLockManager(source.StoreID), source.StoreID)
+
+	status, err := manager.GetMigrationStatus(ctx, task.ID)
+	if err != nil || status.Status != MigrationFailed {
+		t.Fatalf("Expected interrupted task to be loaded as failed, got %+v %v", status, err)
+	}
+
+	// 再次发起同一迁移会从检查点续传而不是创建新任务
+	resumed, err := manager.StartMigration(ctx, timelineKey, target.StoreID)
+	if err != nil {
+		t.Fatalf("Failed to resume migration: %v", err)
+	}
+	if resumed.ID != task.ID {
+		t.Fatalf("Expected task %s to be resumed, got %s", task.ID, resumed.ID)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		status, _ = manager.GetMigrationStatus(ctx, task.ID)
+		if status.Status == MigrationCompleted || status.Status == MigrationFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Status != MigrationCompleted {
+		t.Fatalf("Migration did not complete: %s %s", status.Status, status.Error)
+	}
+
+	targetMessages, _ := target.GetConvMessages(timelineKey, 10, 0)
+	if len(targetMessages) != len(sourceMessages) {
+		t.Fatalf("Expected %d messages on target, got %d", len(sourceMessages), len(targetMessages))
+	}
+	for i, msg := range targetMessages {
+		if msg.SeqID != sourceMessages[i].SeqID {
+			t.Errorf("Message %d seq mismatch: %d != %d", i, msg.SeqID, sourceMessages[i].SeqID)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(sourceDir, "migrations", task.ID+".json")); !os.IsNotExist(err) {
+		t.Error("Checkpoint should be removed after completion")
+	}
+}
+
+func TestImportTimelineBlockIsIdempotent(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	data := &TimelineBlockData{
+		TimelineKey:  "conv_import",
+		TimelineType: "conv",
+		Block:        &TimelineBlock{BlockID: "conv_conv_import_1"},
+		Messages:     []*Message{{SeqID: 1, Data: []byte("a")}, {SeqID: 2, Data: []byte("b")}},
+	}
+	if imported, err := store.ImportTimelineBlock("conv", "conv_import", data); err != nil || !imported {
+		t.Fatalf("Expected first import to succeed, got %v %v", imported, err)
+	}
+	if imported, err := store.ImportTimelineBlock("conv", "conv_import", data); err != nil || imported {
+		t.Fatalf("Expected duplicate import to be skipped, got %v %v", imported, err)
+	}
+
+	changed := *data
+	changed.Messages = []*Message{{SeqID: 1, Data: []byte("a")}, {SeqID: 2, Data: []byte("c")}}
+	if _, err := store.ImportTimelineBlock("conv", "conv_import", &changed); err == nil {
+		t.Fatal("Expected import with different content to fail")
+	}
+
+	deleted, err := store.DeleteTimeline("conv", "conv_import")
+	if err != nil || !deleted {
+		t.Fatalf("Failed to delete timeline: %v %v", deleted, err)
+	}
+	store.Close()
+
+	reopened, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if messages, _ := reopened.GetConvMessages("conv_import", 10, 0); len(messages) != 0 {
+		t.Errorf("Deleted timeline should stay empty after reopen, got %d messages", len(messages))
+	}
+	if used := reopened.UsedCapacity(); used != 0 {
+		t.Errorf("Expected capacity 0 after delete, got %d", used)
+	}
+}
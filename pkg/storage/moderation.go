@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+
+	"imy/pkg/moderation"
+)
+
+// 写入消息的内容审核
+// 本地提交的消息和编辑在写入前经过同步拦截器，可能被拒绝（返回的错误满足errors.Is(err, moderation.ErrRejected)）
+// 或改写内容；复制来的消息已在主Store审核过，不再审核。写入后策略中的异步拦截器发现违规时，
+// 向原消息追加删除墓碑，墓碑同样写入消息的用户Timeline
+
+// SetModeration 设置写入消息使用的审核拦截链，nil表示不审核
+func (s *Store) SetModeration(pipeline *moderation.Pipeline) {
+	s.moderation.Store(pipeline)
+}
+
+// moderateMessage 写入前同步审核消息，通过时可能改写msg.Data；不需要审核时返回nil
+func (s *Store) moderateMessage(msg *Message) (*moderation.Message, error) {
+	pipeline := s.moderation.Load()
+	if pipeline == nil || msg.HLC != 0 || msg.Type == MsgTypeDelete {
+		return nil, nil
+	}
+	review := &moderation.Message{
+		ConversationID: msg.ConvID,
+		SenderID:       strconv.FormatUint(uint64(msg.SenderID), 10),
+		Content:        msg.Data,
+		Mentions:       msg.Mentions,
+	}
+	if err := pipeline.Moderate(context.Background(), review); err != nil {
+		return nil, err
+	}
+	msg.Data = review.Content
+	return review, nil
+}
+
+// reviewAsync 写入后异步审核，违规时删除消息；编辑记录违规时删除被编辑的原消息
+func (s *Store) reviewAsync(review *moderation.Message, msg *Message, userIDs []string) {
+	pipeline := s.moderation.Load()
+	if pipeline == nil {
+		return
+	}
+	review.ID = strconv.FormatInt(msg.SeqID, 10)
+	pipeline.ModerateAsync(*review, func(_ moderation.Message, rejection *moderation.Rejection) {
+		target := msg.SeqID
+		if msg.Type == MsgTypeEdit {
+			target = msg.RefSeqID
+		}
+		if _, err := s.DeleteMessage(msg.ConvID, msg.SenderID, target, userIDs); err != nil && !errors.Is(err, ErrMessageDeleted) {
+			log.Printf("store %s: failed to delete message %d of %s flagged by moderation: %v", s.StoreID, target, msg.ConvID, err)
+			return
+		}
+		log.Printf("store %s: deleted message %d of %s: %v", s.StoreID, target, msg.ConvID, rejection)
+	})
+}
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"imy/pkg/moderation"
+)
+
+func TestStoreModeration(t *testing.T) {
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	pipeline, err := moderation.New(moderation.Config{Enabled: true, MaxContentBytes: 32, ProfanityWords: []string{"bad"}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	store.SetModeration(pipeline)
+
+	msg, err := store.AppendMessage("conv_1", 1, []byte("not bad"), []string{"user_1"})
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if string(msg.Data) != "not ***" {
+		t.Errorf("Expected masked content, got %q", msg.Data)
+	}
+	if _, err := store.AppendMessage("conv_1", 1, make([]byte, 64), []string{"user_1"}); !errors.Is(err, moderation.ErrRejected) {
+		t.Fatalf("Expected the oversized message to be rejected, got %v", err)
+	}
+	if _, err := store.EditMessage("conv_1", 1, msg.SeqID, nil, []string{"user_1"}); !errors.Is(err, moderation.ErrRejected) {
+		t.Errorf("Expected an empty edit to be rejected, got %v", err)
+	}
+	// 复制来的消息已在主Store审核过
+	if _, _, err := store.ReplicateMessage("conv_1", &Message{SenderID: 2, Data: make([]byte, 64), HLC: 1}, nil); err != nil {
+		t.Errorf("Expected replicated messages to bypass moderation, got %v", err)
+	}
+	if messages, _ := store.GetUserMessagesAfter("user_1", 0, 0); len(messages) != 1 {
+		t.Errorf("Expected rejected messages to stay out of the timeline, got %d", len(messages))
+	}
+
+	service := NewLocalStoreService(store)
+	_, err = service.AddMessage(context.Background(), &AddMessageRequest{TimelineKey: "conv_1", Message: &Message{SenderID: 1}})
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != ErrCodeMessageRejected {
+		t.Errorf("Expected ErrCodeMessageRejected, got %v", err)
+	}
+	if stats := pipeline.Stats(); stats.Rejected != 3 || stats.Modified != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
+
+func TestStoreAsyncModerationDeletesMessage(t *testing.T) {
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	pipeline, err := moderation.New(moderation.Config{Enabled: true, ProfanityWords: []string{"bad"}, RejectProfanity: true, Async: []string{moderation.NameProfanity}})
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+	store.SetModeration(pipeline)
+
+	good, err := store.AppendMessage("conv_1", 1, []byte("hello"), []string{"user_1"})
+	if err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	flagged, err := store.AppendMessage("conv_1", 1, []byte("bad words"), []string{"user_1"})
+	if err != nil {
+		t.Fatalf("Expected the async interceptor to accept the write, got %v", err)
+	}
+	pipeline.Wait()
+
+	messages, err := store.GetConvMessages("conv_1", 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	var tombstones []int64
+	for _, m := range messages {
+		if m.Type == MsgTypeDelete {
+			tombstones = append(tombstones, m.RefSeqID)
+		}
+	}
+	if len(tombstones) != 1 || tombstones[0] != flagged.SeqID {
+		t.Errorf("Expected only message %d to be deleted, got %v (kept %d)", flagged.SeqID, tombstones, good.SeqID)
+	}
+	if stats := pipeline.Stats(); stats.AsyncChecked != 2 || stats.AsyncViolations != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
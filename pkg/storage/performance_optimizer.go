@@ -14,18 +14,21 @@ type PerformanceOptimizer struct {
 	queryOptimizer   *QueryOptimizer
 	metricsCollector *MetricsCollector
 	loadBalancer     *LoadBalancer
-	circuitBreaker   *CircuitBreaker
+	circuitBreakers  *CircuitBreakerGroup
 	mu               sync.RWMutex
 }
 
-// NewPerformanceOptimizer 创建性能优化器
-func NewPerformanceOptimizer() *PerformanceOptimizer {
+// NewPerformanceOptimizer 创建性能优化器，registry用于解析连接池中Store的地址
+func NewPerformanceOptimizer(registry StoreRegistry) *PerformanceOptimizer {
+	circuitBreakers := NewCircuitBreakerGroup(DefaultCircuitBreakerConfig())
+	connectionPool := NewConnectionPool(registry, DefaultConnectionPoolConfig())
+	connectionPool.SetCircuitBreakers(circuitBreakers)
 	return &PerformanceOptimizer{
-		connectionPool:   NewConnectionPool(100, 10*time.Second),
+		connectionPool:   connectionPool,
 		queryOptimizer:   NewQueryOptimizer(),
 		metricsCollector: NewMetricsCollector(),
 		loadBalancer:     NewLoadBalancer(),
-		circuitBreaker:   NewCircuitBreaker(5, 30*time.Second),
+		circuitBreakers:  circuitBreakers,
 	}
 }
 
@@ -35,8 +38,8 @@ func (po *PerformanceOptimizer) OptimizeQuery(query *Query) (*OptimizedQuery, er
 }
 
 // GetConnection 获取连接
-func (po *PerformanceOptimizer) GetConnection(storeID string) (*Connection, error) {
-	return po.connectionPool.Get(storeID)
+func (po *PerformanceOptimizer) GetConnection(ctx context.Context, storeID string) (*Connection, error) {
+	return po.connectionPool.Get(ctx, storeID)
 }
 
 // ReleaseConnection 释放连接
@@ -44,122 +47,72 @@ func (po *PerformanceOptimizer) ReleaseConnection(conn *Connection) {
 	po.connectionPool.Release(conn)
 }
 
-// RecordMetrics 记录指标
-func (po *PerformanceOptimizer) RecordMetrics(operation string, duration time.Duration, success bool) {
-	po.metricsCollector.Record(operation, duration, success)
+// DiscardConnection 关闭请求失败的连接
+func (po *PerformanceOptimizer) DiscardConnection(conn *Connection) {
+	po.connectionPool.Discard(conn)
 }
 
-// GetMetrics 获取指标
-func (po *PerformanceOptimizer) GetMetrics() *PerformanceMetrics {
-	return po.metricsCollector.GetMetrics()
+// ConnectionPool 返回连接池，用于启动后台健康检查与导出统计
+func (po *PerformanceOptimizer) ConnectionPool() *ConnectionPool {
+	return po.connectionPool
 }
 
-// ConnectionPool 连接池
-type ConnectionPool struct {
-	mu          sync.RWMutex
-	connections map[string][]*Connection
-	maxSize     int
-	timeout     time.Duration
-	stats       *PoolStats
+// CircuitBreakers 返回连接池使用的各Store熔断器
+func (po *PerformanceOptimizer) CircuitBreakers() *CircuitBreakerGroup {
+	return po.circuitBreakers
 }
 
-// Connection 连接
-type Connection struct {
-	ID       string
-	StoreID  string
-	Client   interface{} // RPC客户端
-	LastUsed time.Time
-	InUse    bool
-}
-
-// PoolStats 连接池统计
-type PoolStats struct {
-	TotalConnections int64
-	ActiveConnections int64
-	IdleConnections  int64
-	ConnectionsCreated int64
-	ConnectionsDestroyed int64
-}
-
-// NewConnectionPool 创建连接池
-func NewConnectionPool(maxSize int, timeout time.Duration) *ConnectionPool {
-	return &ConnectionPool{
-		connections: make(map[string][]*Connection),
-		maxSize:     maxSize,
-		timeout:     timeout,
-		stats:       &PoolStats{},
-	}
+// MetricsCollector 返回指标收集器
+func (po *PerformanceOptimizer) MetricsCollector() *MetricsCollector {
+	return po.metricsCollector
 }
 
-// Get 获取连接
-func (cp *ConnectionPool) Get(storeID string) (*Connection, error) {
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
-	
-	conns, exists := cp.connections[storeID]
-	if exists && len(conns) > 0 {
-		// 从池中获取连接
-		conn := conns[len(conns)-1]
-		cp.connections[storeID] = conns[:len(conns)-1]
-		conn.InUse = true
-		conn.LastUsed = time.Now()
-		atomic.AddInt64(&cp.stats.ActiveConnections, 1)
-		atomic.AddInt64(&cp.stats.IdleConnections, -1)
-		return conn, nil
-	}
-	
-	// 创建新连接
-	conn := &Connection{
-		ID:       generateConnectionID(),
-		StoreID:  storeID,
-		LastUsed: time.Now(),
-		InUse:    true,
-		// Client: 这里应该创建实际的RPC客户端
-	}
-	
-	atomic.AddInt64(&cp.stats.TotalConnections, 1)
-	atomic.AddInt64(&cp.stats.ActiveConnections, 1)
-	atomic.AddInt64(&cp.stats.ConnectionsCreated, 1)
-	
-	return conn, nil
+// RecordMetrics 记录指标
+func (po *PerformanceOptimizer) RecordMetrics(operation string, duration time.Duration, success bool) {
+	po.metricsCollector.Record(operation, duration, success)
 }
 
-// Release 释放连接
-func (cp *ConnectionPool) Release(conn *Connection) {
-	cp.mu.Lock()
-	defer cp.mu.Unlock()
-	
-	conn.InUse = false
-	conn.LastUsed = time.Now()
-	
-	conns := cp.connections[conn.StoreID]
-	if len(conns) < cp.maxSize {
-		cp.connections[conn.StoreID] = append(conns, conn)
-		atomic.AddInt64(&cp.stats.ActiveConnections, -1)
-		atomic.AddInt64(&cp.stats.IdleConnections, 1)
-	} else {
-		// 池已满，销毁连接
-		atomic.AddInt64(&cp.stats.TotalConnections, -1)
-		atomic.AddInt64(&cp.stats.ActiveConnections, -1)
-		atomic.AddInt64(&cp.stats.ConnectionsDestroyed, 1)
-	}
+// GetMetrics 获取指标
+func (po *PerformanceOptimizer) GetMetrics() *PerformanceMetrics {
+	return po.metricsCollector.GetMetrics()
 }
 
 // QueryOptimizer 查询优化器
 type QueryOptimizer struct {
 	mu    sync.RWMutex
 	cache map[string]*OptimizedQuery
+	// 各执行计划实测的每单位工作量耗时（纳秒），按指数移动平均更新，未执行过的计划没有记录
+	nsPerUnit map[string]float64
 }
 
 // Query 查询
 type Query struct {
-	TimelineID string
-	StartTime  time.Time
-	EndTime    time.Time
-	Filters    map[string]interface{}
-	Limit      int
-	Offset     int
-}
+	TimelineID  string
+	StartTime   time.Time
+	EndTime     time.Time
+	AfterSeqID  int64                  // 只返回SeqID大于该值的消息
+	BeforeSeqID int64                  // 只返回SeqID小于该值的消息，0表示不限制
+	Filters     map[string]interface{} // 支持 sender_id、type、mention、reply_to
+	Limit       int
+	Offset      int
+}
+
+// QueryStats 查询涉及的块统计，Candidate为按块索引可能包含结果的块
+type QueryStats struct {
+	Blocks            int
+	Messages          int64
+	CandidateBlocks   int
+	CandidateMessages int64
+}
+
+// 成本模型：扫描一条消息为1个单位，访问一个块（加锁、遍历准备）计blockVisitUnits，
+// 检查一个块索引计indexCheckUnits；单位耗时由实际执行测得
+const (
+	blockVisitUnits  = 8
+	indexCheckUnits  = 1
+	defaultNsPerUnit = 20
+	costSmoothing    = 0.2
+)
 
 // OptimizedQuery 优化后的查询
 type OptimizedQuery struct {
@@ -172,7 +125,56 @@ type OptimizedQuery struct {
 // NewQueryOptimizer 创建查询优化器
 func NewQueryOptimizer() *QueryOptimizer {
 	return &QueryOptimizer{
-		cache: make(map[string]*OptimizedQuery),
+		cache:     make(map[string]*OptimizedQuery),
+		nsPerUnit: make(map[string]float64),
+	}
+}
+
+// Plan 根据块统计与实测单位耗时选择执行计划，EstimatedCost为预计耗时（纳秒）
+// 查询区间能排除部分块时按块索引执行通常更快，区间覆盖全部块时顺序扫描省去索引检查
+func (qo *QueryOptimizer) Plan(query *Query, stats QueryStats) *OptimizedQuery {
+	// 两种计划都有实测数据后才按实测单位耗时比较，否则只执行过的计划会因为测得的真实耗时吃亏
+	scanNs, indexNs := float64(defaultNsPerUnit), float64(defaultNsPerUnit)
+	qo.mu.RLock()
+	measuredScan, scanOK := qo.nsPerUnit[QueryPlanSequentialScan]
+	measuredIndex, indexOK := qo.nsPerUnit[QueryPlanTimeIndex]
+	qo.mu.RUnlock()
+	if scanOK && indexOK {
+		scanNs, indexNs = measuredScan, measuredIndex
+	}
+	scanCost := float64(stats.Blocks*blockVisitUnits+int(stats.Messages)) * scanNs
+	indexCost := float64(stats.Blocks*indexCheckUnits+stats.CandidateBlocks*blockVisitUnits+int(stats.CandidateMessages)) * indexNs
+
+	optimized := &OptimizedQuery{
+		Original:      query,
+		IndexHints:    qo.suggestIndexes(query),
+		ExecutionPlan: QueryPlanSequentialScan,
+		EstimatedCost: scanCost,
+	}
+	if indexCost < scanCost {
+		optimized.ExecutionPlan = QueryPlanTimeIndex
+		optimized.EstimatedCost = indexCost
+	}
+	return optimized
+}
+
+// RecordExecution 记录一次查询的实际工作量与耗时，更新该计划的单位耗时
+func (qo *QueryOptimizer) RecordExecution(plan string, blocks, scannedBlocks int, scannedMessages int64, elapsed time.Duration) {
+	units := scannedBlocks*blockVisitUnits + int(scannedMessages)
+	if plan == QueryPlanTimeIndex {
+		units += blocks * indexCheckUnits
+	}
+	if units == 0 {
+		return
+	}
+	measured := float64(elapsed.Nanoseconds()) / float64(units)
+
+	qo.mu.Lock()
+	defer qo.mu.Unlock()
+	if current, exists := qo.nsPerUnit[plan]; exists {
+		qo.nsPerUnit[plan] = current + costSmoothing*(measured-current)
+	} else {
+		qo.nsPerUnit[plan] = measured
 	}
 }
 
@@ -204,8 +206,8 @@ func (qo *QueryOptimizer) Optimize(query *Query) (*OptimizedQuery, error) {
 
 // generateCacheKey 生成缓存键
 func (qo *QueryOptimizer) generateCacheKey(query *Query) string {
-	// 简化实现
-	return query.TimelineID + "_" + query.StartTime.Format(time.RFC3339)
+	// 执行计划取决于查询区间，缓存键需包含全部区间条件
+	return fmt.Sprintf("%s_%d_%d_%d_%d", query.TimelineID, query.StartTime.UnixNano(), query.EndTime.UnixNano(), query.AfterSeqID, query.BeforeSeqID)
 }
 
 // suggestIndexes 建议索引
@@ -216,6 +218,9 @@ func (qo *QueryOptimizer) suggestIndexes(query *Query) []string {
 	if !query.StartTime.IsZero() || !query.EndTime.IsZero() {
 		hints = append(hints, "time_index")
 	}
+	if query.AfterSeqID > 0 || query.BeforeSeqID > 0 {
+		hints = append(hints, "seq_index")
+	}
 	
 	for field := range query.Filters {
 		hints = append(hints, field+"_index")
@@ -224,10 +229,12 @@ func (qo *QueryOptimizer) suggestIndexes(query *Query) []string {
 	return hints
 }
 
-// generateExecutionPlan 生成执行计划
+// generateExecutionPlan 生成执行计划，没有块统计时只要有区间条件就使用块索引
 func (qo *QueryOptimizer) generateExecutionPlan(query *Query) string {
-	// 简化实现
-	return "sequential_scan"
+	if !query.StartTime.IsZero() || !query.EndTime.IsZero() || query.AfterSeqID > 0 || query.BeforeSeqID > 0 {
+		return QueryPlanTimeIndex
+	}
+	return QueryPlanSequentialScan
 }
 
 // estimateCost 估算成本
@@ -241,9 +248,13 @@ func (qo *QueryOptimizer) estimateCost(query *Query) float64 {
 }
 
 // MetricsCollector 指标收集器
+// 次数、耗时与成功率为累计值，延迟百分位与吞吐量按最近window的滚动窗口统计
 type MetricsCollector struct {
-	mu      sync.RWMutex
-	metrics *PerformanceMetrics
+	mu        sync.RWMutex
+	metrics   *PerformanceMetrics
+	window    time.Duration
+	latencies map[string]*latencyWindow
+	now       func() time.Time
 }
 
 // PerformanceMetrics 性能指标
@@ -252,14 +263,24 @@ type PerformanceMetrics struct {
 	OperationDurations map[string]time.Duration
 	SuccessRates      map[string]float64
 	ErrorCounts       map[string]int64
-	Throughput        float64
-	LatencyP50        time.Duration
+	Throughput        float64       // 窗口内所有操作的每秒次数
+	LatencyP50        time.Duration // 窗口内所有操作的延迟百分位
 	LatencyP95        time.Duration
 	LatencyP99        time.Duration
+	Latencies         map[string]LatencyStats // 各操作在窗口内的延迟分布与吞吐量
+	Window            time.Duration
 }
 
-// NewMetricsCollector 创建指标收集器
+// NewMetricsCollector 创建指标收集器，百分位与吞吐量按最近一分钟统计
 func NewMetricsCollector() *MetricsCollector {
+	return NewMetricsCollectorWithWindow(defaultMetricsWindow)
+}
+
+// NewMetricsCollectorWithWindow 创建指定统计窗口的指标收集器
+func NewMetricsCollectorWithWindow(window time.Duration) *MetricsCollector {
+	if window < metricsWindowSlots {
+		window = defaultMetricsWindow
+	}
 	return &MetricsCollector{
 		metrics: &PerformanceMetrics{
 			OperationCounts:    make(map[string]int64),
@@ -267,6 +288,9 @@ func NewMetricsCollector() *MetricsCollector {
 			SuccessRates:       make(map[string]float64),
 			ErrorCounts:        make(map[string]int64),
 		},
+		window:    window,
+		latencies: make(map[string]*latencyWindow),
+		now:       time.Now,
 	}
 }
 
@@ -286,6 +310,14 @@ func (mc *MetricsCollector) Record(operation string, duration time.Duration, suc
 	total := mc.metrics.OperationCounts[operation]
 	errors := mc.metrics.ErrorCounts[operation]
 	mc.metrics.SuccessRates[operation] = float64(total-errors) / float64(total)
+
+	now := mc.now()
+	latency, exists := mc.latencies[operation]
+	if !exists {
+		latency = newLatencyWindow(mc.window, now)
+		mc.latencies[operation] = latency
+	}
+	latency.record(duration, success, now)
 }
 
 // GetMetrics 获取指标
@@ -313,7 +345,25 @@ func (mc *MetricsCollector) GetMetrics() *PerformanceMetrics {
 	for k, v := range mc.metrics.ErrorCounts {
 		metrics.ErrorCounts[k] = v
 	}
-	
+
+	// 合并窗口内的直方图计算百分位
+	now := mc.now()
+	metrics.Window = mc.window
+	metrics.Latencies = make(map[string]LatencyStats, len(mc.latencies))
+	overall := newLatencyHistogram()
+	hist := newLatencyHistogram()
+	for operation, latency := range mc.latencies {
+		hist.reset()
+		errors := latency.collect(hist, now)
+		stats := latencyStatsOf(hist, errors, latency.span(now))
+		metrics.Latencies[operation] = stats
+		metrics.Throughput += stats.Throughput
+		overall.merge(hist)
+	}
+	metrics.LatencyP50 = overall.percentile(50)
+	metrics.LatencyP95 = overall.percentile(95)
+	metrics.LatencyP99 = overall.percentile(99)
+
 	return metrics
 }
 
@@ -376,79 +426,3 @@ func (lb *LoadBalancer) selectLeastLoaded() *LoadBalancerNode {
 	
 	return selected
 }
-
-// CircuitBreaker 熔断器
-type CircuitBreaker struct {
-	mu           sync.RWMutex
-	failureCount int64
-	threshold    int64
-	timeout      time.Duration
-	lastFailTime time.Time
-	state        string // "closed", "open", "half-open"
-}
-
-// NewCircuitBreaker 创建熔断器
-func NewCircuitBreaker(threshold int64, timeout time.Duration) *CircuitBreaker {
-	return &CircuitBreaker{
-		threshold: threshold,
-		timeout:   timeout,
-		state:     "closed",
-	}
-}
-
-// Call 执行调用
-func (cb *CircuitBreaker) Call(ctx context.Context, fn func() error) error {
-	if !cb.allowRequest() {
-		return ErrCircuitBreakerOpen
-	}
-	
-	err := fn()
-	cb.recordResult(err == nil)
-	
-	return err
-}
-
-// allowRequest 是否允许请求
-func (cb *CircuitBreaker) allowRequest() bool {
-	cb.mu.RLock()
-	defer cb.mu.RUnlock()
-	
-	switch cb.state {
-	case "closed":
-		return true
-	case "open":
-		return time.Since(cb.lastFailTime) > cb.timeout
-	case "half-open":
-		return true
-	default:
-		return false
-	}
-}
-
-// recordResult 记录结果
-func (cb *CircuitBreaker) recordResult(success bool) {
-	cb.mu.Lock()
-	defer cb.mu.Unlock()
-	
-	if success {
-		cb.failureCount = 0
-		if cb.state == "half-open" {
-			cb.state = "closed"
-		}
-	} else {
-		cb.failureCount++
-		cb.lastFailTime = time.Now()
-		
-		if cb.failureCount >= cb.threshold {
-			cb.state = "open"
-		}
-	}
-}
-
-// generateConnectionID 生成连接ID
-func generateConnectionID() string {
-	return time.Now().Format("20060102150405") + "_" + "conn"
-}
-
-// ErrCircuitBreakerOpen 熔断器开启错误
-var ErrCircuitBreakerOpen = fmt.Errorf("circuit breaker is open")
\ No newline at end of file
@@ -0,0 +1,350 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// 大群的拉模式扇出
+// 推模式下每条消息复制到所有成员的用户时间线；成员很多的会话改为拉模式后消息只写入会话时间线，
+// 会话记录每个成员可见的SeqID区间，成员同步时按(用户, 会话)游标从会话时间线读取。
+//   - 扇出方式按会话配置（ConvFanoutPolicy）：auto在成员数达到阈值后切换为拉模式且不再切回，
+//     push/pull固定方式；阈值默认取StoreConfig.PullFanoutThreshold
+//   - 配置与成员区间保存在元数据后端的fanouts中（files方式为 conv_{id}.fanout），只在成员变化或切换方式时重写，启动时全部加载
+//   - GetMessagesAfterCheckpoint在用户时间线的记录之后按HLC顺序追加拉模式会话中游标之后的消息，
+//     这些消息的SeqID接在用户时间线之后、ConvSeqID为会话中的SeqID；UpdateUserCheckpoint确认到这些SeqID时
+//     推进对应会话的游标，用户时间线的checkpoint只前进到其中最后一条真实记录；游标与checkpoint一同持久化
+//   - GetUnreadCounts对拉模式会话按会话已读位置在读取时计算未读数
+//
+// 成员离开后再加入时只保留新的可见区间；迁移会话时不携带拉模式状态，目标Store按其配置重新判断。
+
+// FanoutMode 会话的扇出方式
+type FanoutMode string
+
+const (
+	FanoutAuto FanoutMode = ""     // 成员数达到阈值后由推模式切换为拉模式
+	FanoutPush FanoutMode = "push" // 每条消息写入所有成员的用户时间线
+	FanoutPull FanoutMode = "pull" // 消息只写入会话时间线，成员同步时从会话时间线读取
+)
+
+// ConvFanoutPolicy 会话的扇出配置
+type ConvFanoutPolicy struct {
+	Mode      FanoutMode `json:"mode,omitempty"`
+	Threshold int        `json:"threshold,omitempty"` // auto下切换为拉模式的成员数，0表示使用StoreConfig.PullFanoutThreshold
+}
+
+// pullMember 拉模式会话的成员，可见SeqID在(After, Until]区间内的消息，Until为0表示仍是成员
+type pullMember struct {
+	After int64 `json:"after"`
+	Until int64 `json:"until,omitempty"`
+}
+
+// convFanout 会话的扇出状态
+type convFanout struct {
+	Policy  ConvFanoutPolicy       `json:"policy"`
+	Pull    bool                   `json:"pull"` // 新消息是否以拉模式写入
+	Members map[string]*pullMember `json:"members,omitempty"`
+}
+
+// pullSpan 用户在一个拉模式会话中待读取的SeqID区间(after, until]，until为0表示不限制
+type pullSpan struct {
+	convID string
+	after  int64
+	until  int64
+}
+
+// pullRead 最近一次返回给用户的拉模式消息，其中第i条的SeqID为base+i+1
+type pullRead struct {
+	base      int64
+	positions []pullSpan // 只使用convID与after（消息在会话中的SeqID）
+}
+
+// wantsPull 有members个成员时新消息是否以拉模式写入
+func (f *convFanout) wantsPull(members, defaultThreshold int) bool {
+	switch f.Policy.Mode {
+	case FanoutPush:
+		return false
+	case FanoutPull:
+		return true
+	}
+	// 自动切换后不再切回，避免成员数在阈值附近波动时反复切换
+	if f.Pull {
+		return true
+	}
+	threshold := f.Policy.Threshold
+	if threshold <= 0 {
+		threshold = defaultThreshold
+	}
+	return threshold > 0 && members >= threshold
+}
+
+// SetConvFanoutPolicy 设置会话的扇出方式，从下一条消息起生效
+// 切换前已写入用户时间线的记录保留，切回推模式时拉模式期间的消息仍按游标读取
+func (s *Store) SetConvFanoutPolicy(convID string, policy ConvFanoutPolicy) error {
+	switch policy.Mode {
+	case FanoutAuto, FanoutPush, FanoutPull:
+	default:
+		return fmt.Errorf("unknown fanout mode %q", policy.Mode)
+	}
+
+	s.fanoutMu.Lock()
+	defer s.fanoutMu.Unlock()
+	f := s.convFanouts[convID]
+	if f == nil {
+		f = &convFanout{Members: make(map[string]*pullMember)}
+		s.convFanouts[convID] = f
+	}
+	f.Policy = policy
+	return s.saveConvFanoutLocked(convID, f)
+}
+
+// ConvFanout 返回会话的扇出配置，以及新消息当前是否以拉模式写入
+func (s *Store) ConvFanout(convID string) (ConvFanoutPolicy, bool) {
+	s.fanoutMu.RLock()
+	defer s.fanoutMu.RUnlock()
+	if f := s.convFanouts[convID]; f != nil {
+		return f.Policy, f.Pull
+	}
+	return ConvFanoutPolicy{}, false
+}
+
+// pullsFanout 会话中发给members个成员的新消息是否以拉模式写入
+func (s *Store) pullsFanout(convID string, members int) bool {
+	s.fanoutMu.RLock()
+	defer s.fanoutMu.RUnlock()
+	f := s.convFanouts[convID]
+	if f == nil {
+		f = &convFanout{}
+	}
+	return f.wantsPull(members, s.Config.PullFanoutThreshold)
+}
+
+// recordFanout 记录会话中SeqID为seqID的消息的扇出方式，拉模式下更新成员的可见区间
+// 不在userIDs中的成员视为已离开，其区间在seqID之前结束
+func (s *Store) recordFanout(convID string, seqID int64, userIDs []string, pull bool) error {
+	s.fanoutMu.Lock()
+	defer s.fanoutMu.Unlock()
+
+	f := s.convFanouts[convID]
+	if !pull {
+		if f == nil || !f.Pull {
+			return nil
+		}
+		// 切回推模式，拉模式期间的消息仍按原区间读取
+		f.Pull = false
+		for _, member := range f.Members {
+			if member.Until == 0 {
+				member.Until = seqID - 1
+			}
+		}
+		return s.saveConvFanoutLocked(convID, f)
+	}
+
+	if f == nil {
+		f = &convFanout{Members: make(map[string]*pullMember)}
+		s.convFanouts[convID] = f
+	}
+	changed := !f.Pull
+	f.Pull = true
+	current := make(map[string]struct{}, len(userIDs))
+	for _, userID := range userIDs {
+		current[userID] = struct{}{}
+		if member := f.Members[userID]; member == nil || member.Until != 0 {
+			f.Members[userID] = &pullMember{After: seqID - 1}
+			s.indexPullMember(userID, convID)
+			changed = true
+		}
+	}
+	for userID, member := range f.Members {
+		if _, ok := current[userID]; !ok && member.Until == 0 {
+			member.Until = seqID - 1
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveConvFanoutLocked(convID, f)
+}
+
+// indexPullMember 登记用户所在的拉模式会话，调用方持有fanoutMu
+func (s *Store) indexPullMember(userID, convID string) {
+	convs := s.userPullConvs[userID]
+	if convs == nil {
+		convs = make(map[string]struct{})
+		s.userPullConvs[userID] = convs
+	}
+	convs[convID] = struct{}{}
+}
+
+// pullSpans 用户在各拉模式会话中游标之后尚未读取的区间，after取成员区间起点与游标中较大者
+func (s *Store) pullSpans(userID string, cursors map[string]int64) []pullSpan {
+	s.fanoutMu.RLock()
+	spans := make([]pullSpan, 0, len(s.userPullConvs[userID]))
+	for convID := range s.userPullConvs[userID] {
+		member := s.convFanouts[convID].Members[userID]
+		span := pullSpan{convID: convID, after: max(member.After, cursors[convID]), until: member.Until}
+		if span.until == 0 || span.after < span.until {
+			spans = append(spans, span)
+		}
+	}
+	s.fanoutMu.RUnlock()
+	sort.Slice(spans, func(i, j int) bool { return spans[i].convID < spans[j].convID })
+	return spans
+}
+
+// iteratePullSpan 遍历区间内的会话消息，会话不存在时返回nil
+func (s *Store) iteratePullSpan(span pullSpan) *timelineIterator {
+	tl, exists := s.GetTimeline("conv", span.convID)
+	if !exists {
+		return nil
+	}
+	var before int64
+	if span.until > 0 {
+		before = span.until + 1
+	}
+	return s.iterateTimeline(tl, span.after, before)
+}
+
+// pullMessagesAfter 读取用户所在拉模式会话中游标之后的消息，按HLC合并
+func (s *Store) pullMessagesAfter(userID string) ([]*Message, error) {
+	s.mu.RLock()
+	cursors := make(map[string]int64, len(s.PullCursors[userID]))
+	for convID, seqID := range s.PullCursors[userID] {
+		cursors[convID] = seqID
+	}
+	s.mu.RUnlock()
+
+	var result []*Message
+	for _, span := range s.pullSpans(userID, cursors) {
+		it := s.iteratePullSpan(span)
+		if it == nil {
+			continue
+		}
+		for it.Next() {
+			result = append(result, it.Message())
+		}
+		it.Close()
+		if err := it.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read conversation %s: %w", span.convID, err)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].HLC < result[j].HLC })
+	return result, nil
+}
+
+// appendPullMessages 把拉模式消息接在用户时间线记录之后返回，并记录其SeqID与会话位置的对应关系
+// base为已返回的最后一条用户时间线记录的SeqID
+func (s *Store) appendPullMessages(userID string, result, pulled []*Message, base int64) []*Message {
+	read := &pullRead{base: base, positions: make([]pullSpan, len(pulled))}
+	for i, msg := range pulled {
+		entry := *msg
+		entry.ConvSeqID = msg.SeqID
+		entry.SeqID = base + int64(i) + 1
+		read.positions[i] = pullSpan{convID: msg.ConvID, after: msg.SeqID}
+		result = append(result, &entry)
+	}
+
+	s.mu.Lock()
+	s.pullReads[userID] = read
+	s.mu.Unlock()
+	return result
+}
+
+// resolvePullAckLocked 确认到seqID时需要推进的拉模式会话游标，返回用户时间线应确认到的SeqID，
+// done表示最近一次返回的拉模式消息已全部确认。只计算不修改，调用方持有s.mu
+func (s *Store) resolvePullAckLocked(userID string, seqID int64) (int64, []CheckpointUpdate, bool) {
+	read := s.pullReads[userID]
+	if read == nil || seqID <= read.base {
+		return seqID, nil, false
+	}
+
+	acked := len(read.positions)
+	if seqID-read.base < int64(acked) {
+		acked = int(seqID - read.base)
+	}
+	latest := make(map[string]int64)
+	for _, pos := range read.positions[:acked] {
+		latest[pos.convID] = max(latest[pos.convID], pos.after)
+	}
+	cursors := make([]CheckpointUpdate, 0, len(latest))
+	for convID, convSeqID := range latest {
+		cursors = append(cursors, CheckpointUpdate{Kind: CheckpointPull, UserID: userID, ConvID: convID, SeqID: convSeqID})
+	}
+	sort.Slice(cursors, func(i, j int) bool { return cursors[i].ConvID < cursors[j].ConvID })
+	return read.base, cursors, acked == len(read.positions)
+}
+
+// countPullUnread 统计拉模式会话中已读位置之后的未读消息数，规则同GetUnreadCounts
+func (s *Store) countPullUnread(userID string, checkpoints map[string]int64, counts map[string]int64) error {
+	for _, span := range s.pullSpans(userID, checkpoints) {
+		it := s.iteratePullSpan(span)
+		if it == nil {
+			continue
+		}
+		for it.Next() {
+			msg := it.Message()
+			if msg.Type == MsgTypeNormal && strconv.FormatUint(uint64(msg.SenderID), 10) != userID {
+				counts[span.convID]++
+			}
+		}
+		it.Close()
+		if err := it.Err(); err != nil {
+			return fmt.Errorf("failed to read conversation %s: %w", span.convID, err)
+		}
+	}
+	return nil
+}
+
+// dropConvFanout 删除会话时清除其扇出状态
+func (s *Store) dropConvFanout(convID string) error {
+	s.fanoutMu.Lock()
+	defer s.fanoutMu.Unlock()
+	f := s.convFanouts[convID]
+	if f == nil {
+		return nil
+	}
+	for userID := range f.Members {
+		delete(s.userPullConvs[userID], convID)
+		if len(s.userPullConvs[userID]) == 0 {
+			delete(s.userPullConvs, userID)
+		}
+	}
+	delete(s.convFanouts, convID)
+	return s.metadata.Update(func(tx MetadataTx) error {
+		tx.Delete(metaBucketFanouts, convID)
+		return nil
+	})
+}
+
+// saveConvFanoutLocked 保存会话的扇出状态，调用方持有fanoutMu
+func (s *Store) saveConvFanoutLocked(convID string, f *convFanout) error {
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return s.metadata.Update(func(tx MetadataTx) error {
+		tx.Put(metaBucketFanouts, convID, data)
+		return nil
+	})
+}
+
+// loadConvFanouts 启动时加载所有会话的扇出状态并建立成员索引
+func (s *Store) loadConvFanouts() error {
+	return s.metadata.ForEach(metaBucketFanouts, func(convID string, data []byte) error {
+		f := &convFanout{}
+		if err := json.Unmarshal(data, f); err != nil {
+			return fmt.Errorf("failed to parse fanout state of %s: %w", convID, err)
+		}
+		if f.Members == nil {
+			f.Members = make(map[string]*pullMember)
+		}
+		s.convFanouts[convID] = f
+		for userID := range f.Members {
+			s.indexPullMember(userID, convID)
+		}
+		return nil
+	})
+}
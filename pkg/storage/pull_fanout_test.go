@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestPullFanoutSync(t *testing.T) {
+	config := &StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir(), PullFanoutThreshold: 3}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	group := []string{"1", "2", "3"}
+	// 小会话仍写入用户时间线
+	for i := 1; i <= 2; i++ {
+		if _, err := store.AppendMessage("small", 1, []byte(fmt.Sprintf("small-%d", i)), group[:2]); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	for i := 1; i <= 25; i++ {
+		if _, err := store.AppendMessage("big", 1, []byte(fmt.Sprintf("big-%d", i)), group); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if _, pull := store.ConvFanout("big"); !pull {
+		t.Fatal("Expected the big conversation to switch to pull fan-out")
+	}
+	if last := store.GetOrCreateUserTimeline("2").LastSeqID; last != 2 {
+		t.Fatalf("Expected only the small conversation in the user timeline, got %d entries", last)
+	}
+
+	messages, err := store.GetMessagesAfterCheckpoint("2")
+	if err != nil {
+		t.Fatalf("Failed to read messages: %v", err)
+	}
+	if len(messages) != 27 {
+		t.Fatalf("Expected 27 messages, got %d", len(messages))
+	}
+	for i, msg := range messages {
+		if msg.SeqID != int64(i+1) {
+			t.Fatalf("Expected consecutive SeqIDs, message %d has %d", i, msg.SeqID)
+		}
+		if i >= 2 && (msg.ConvID != "big" || msg.ConvSeqID != int64(i-1)) {
+			t.Fatalf("Unexpected pulled message %d: %s/%d", i, msg.ConvID, msg.ConvSeqID)
+		}
+	}
+
+	// 逐条确认：用户时间线的checkpoint停在真实记录上，会话游标随确认推进
+	store.UpdateUserCheckpoint("2", 12)
+	if checkpoint := store.GetUserCheckpoint("2"); checkpoint != 2 {
+		t.Errorf("Expected the user checkpoint to stay at 2, got %d", checkpoint)
+	}
+	messages, err = store.GetMessagesAfterCheckpoint("2")
+	if err != nil || len(messages) != 15 || messages[0].ConvSeqID != 11 || messages[0].SeqID != 3 {
+		t.Fatalf("Expected 15 messages from conversation SeqID 11, got %d %v", len(messages), err)
+	}
+
+	// 新成员只看到加入之后的消息，离开的成员不再收到新消息
+	if _, err := store.AppendMessage("big", 1, []byte("big-26"), []string{"1", "2", "4"}); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if messages, _ := store.GetMessagesAfterCheckpoint("4"); len(messages) != 1 || messages[0].ConvSeqID != 26 {
+		t.Errorf("Expected the new member to see only message 26, got %d messages", len(messages))
+	}
+	if messages, _ := store.GetMessagesAfterCheckpoint("3"); len(messages) != 25 {
+		t.Errorf("Expected the departed member to keep 25 messages, got %d", len(messages))
+	}
+	if counts := store.GetUnreadCounts("2"); counts["big"] != 26 || counts["small"] != 2 {
+		t.Errorf("Unexpected unread counts: %v", counts)
+	}
+	store.UpdateConvCheckpoint("2", "big", 20)
+	if counts := store.GetUnreadCounts("2"); counts["big"] != 6 {
+		t.Errorf("Expected 6 unread messages after reading up to 20, got %d", counts["big"])
+	}
+	// 发送者自己的消息不计入
+	if counts := store.GetUnreadCounts("1"); counts["big"] != 0 {
+		t.Errorf("Expected no unread messages for the sender, got %d", counts["big"])
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if messages, _ := reopened.GetMessagesAfterCheckpoint("4"); len(messages) != 1 {
+		t.Errorf("Expected pull membership to survive a restart, got %d messages", len(messages))
+	}
+
+	if _, err := reopened.DeleteTimeline("conv", "big"); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if messages, _ := reopened.GetMessagesAfterCheckpoint("4"); len(messages) != 0 {
+		t.Errorf("Expected no messages after the conversation is deleted, got %d", len(messages))
+	}
+}
+
+func TestConvFanoutPolicy(t *testing.T) {
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.SetConvFanoutPolicy("conv", ConvFanoutPolicy{Mode: "broadcast"}); err == nil {
+		t.Error("Expected an unknown mode to be rejected")
+	}
+	if err := store.SetConvFanoutPolicy("conv", ConvFanoutPolicy{Mode: FanoutPull}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+	users := []string{"1", "2"}
+	for i := 0; i < 3; i++ {
+		store.AppendMessage("conv", 1, []byte("pulled"), users)
+	}
+	// 切回推模式后新消息写入用户时间线，拉模式期间的消息仍可读取
+	if err := store.SetConvFanoutPolicy("conv", ConvFanoutPolicy{Mode: FanoutPush}); err != nil {
+		t.Fatalf("Failed to set policy: %v", err)
+	}
+	store.AppendMessage("conv", 1, []byte("pushed"), users)
+
+	messages, err := store.GetMessagesAfterCheckpoint("2")
+	if err != nil || len(messages) != 4 {
+		t.Fatalf("Expected 4 messages, got %d %v", len(messages), err)
+	}
+	if string(messages[0].Data) != "pushed" || messages[0].SeqID != 1 {
+		t.Errorf("Expected the pushed copy first, got %q SeqID %d", messages[0].Data, messages[0].SeqID)
+	}
+	store.UpdateUserCheckpoint("2", messages[len(messages)-1].SeqID)
+	if messages, _ := store.GetMessagesAfterCheckpoint("2"); len(messages) != 0 {
+		t.Errorf("Expected all messages to be acknowledged, got %d", len(messages))
+	}
+	if checkpoint := store.GetUserCheckpoint("2"); checkpoint != 1 {
+		t.Errorf("Expected the user checkpoint at 1, got %d", checkpoint)
+	}
+}
@@ -0,0 +1,299 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+)
+
+// 块内消息范围索引
+// 每个块记录消息SeqID、创建时间与HLC的最小/最大值，随块写入段文件。
+// Store.Query按索引跳过与查询区间不相交的块，不再逐条扫描其中的消息
+
+// BlockIndex 块内消息的范围索引，空块的各字段为0
+type BlockIndex struct {
+	MinSeqID int64 `json:"min_seq_id"`
+	MaxSeqID int64 `json:"max_seq_id"`
+	MinTime  int64 `json:"min_time"` // UnixNano
+	MaxTime  int64 `json:"max_time"`
+	MinHLC   int64 `json:"min_hlc"`
+	MaxHLC   int64 `json:"max_hlc"`
+	Count    int64 `json:"count"`
+}
+
+// add 将一条消息计入索引
+func (idx *BlockIndex) add(msg *Message) {
+	createTime := msg.CreateTime.UnixNano()
+	hlc := messageHLC(msg)
+	if idx.Count == 0 {
+		*idx = BlockIndex{
+			MinSeqID: msg.SeqID, MaxSeqID: msg.SeqID,
+			MinTime: createTime, MaxTime: createTime,
+			MinHLC: hlc, MaxHLC: hlc,
+			Count: 1,
+		}
+		return
+	}
+	widenRange(&idx.MinSeqID, &idx.MaxSeqID, msg.SeqID)
+	widenRange(&idx.MinTime, &idx.MaxTime, createTime)
+	widenRange(&idx.MinHLC, &idx.MaxHLC, hlc)
+	idx.Count++
+}
+
+// widenRange 扩展[lo, hi]使其包含v
+func widenRange(lo, hi *int64, v int64) {
+	if v < *lo {
+		*lo = v
+	}
+	if v > *hi {
+		*hi = v
+	}
+}
+
+// buildBlockIndex 按块内消息计算索引
+func buildBlockIndex(messages []*Message) BlockIndex {
+	var idx BlockIndex
+	for _, msg := range messages {
+		idx.add(msg)
+	}
+	return idx
+}
+
+// mayMatch 块中是否可能有满足查询区间的消息
+func (idx BlockIndex) mayMatch(q *compiledQuery) bool {
+	if idx.Count == 0 {
+		return false
+	}
+	return idx.MaxSeqID > q.afterSeqID && idx.MinSeqID < q.beforeSeqID &&
+		idx.MaxTime >= q.startTime && idx.MinTime <= q.endTime
+}
+
+// 查询执行计划
+const (
+	QueryPlanTimeIndex      = "time_index"      // 按块索引跳过不相交的块
+	QueryPlanSequentialScan = "sequential_scan" // 逐块扫描全部消息
+)
+
+// QueryResult 查询结果与执行统计
+type QueryResult struct {
+	Messages        []*Message    `json:"messages"`
+	Plan            string        `json:"plan"`
+	EstimatedCost   float64       `json:"estimated_cost"`
+	ScannedBlocks   int           `json:"scanned_blocks"`
+	SkippedBlocks   int           `json:"skipped_blocks"`
+	ScannedMessages int64         `json:"scanned_messages"`
+	Duration        time.Duration `json:"duration"`
+}
+
+// compiledQuery 归一化后的查询条件，未设置的边界取极值
+type compiledQuery struct {
+	startTime   int64
+	endTime     int64
+	afterSeqID  int64
+	beforeSeqID int64
+	senderID    *uint32
+	msgType     *MsgType
+	mention     *string
+	replyTo     *int64
+}
+
+func compileQuery(query *Query) (*compiledQuery, error) {
+	q := &compiledQuery{
+		startTime:   math.MinInt64,
+		endTime:     math.MaxInt64,
+		afterSeqID:  query.AfterSeqID,
+		beforeSeqID: math.MaxInt64,
+	}
+	if !query.StartTime.IsZero() {
+		q.startTime = query.StartTime.UnixNano()
+	}
+	if !query.EndTime.IsZero() {
+		q.endTime = query.EndTime.UnixNano()
+	}
+	if query.BeforeSeqID > 0 {
+		q.beforeSeqID = query.BeforeSeqID
+	}
+
+	for field, value := range query.Filters {
+		if field == "mention" {
+			mention, ok := value.(string)
+			if !ok || mention == "" {
+				return nil, fmt.Errorf("invalid value %v for filter %s", value, field)
+			}
+			q.mention = &mention
+			continue
+		}
+		n, ok := filterInt(value)
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for filter %s", value, field)
+		}
+		switch field {
+		case "sender_id":
+			senderID := uint32(n)
+			q.senderID = &senderID
+		case "type":
+			msgType := MsgType(n)
+			q.msgType = &msgType
+		case "reply_to":
+			q.replyTo = &n
+		default:
+			return nil, fmt.Errorf("unsupported query filter: %s", field)
+		}
+	}
+	return q, nil
+}
+
+// filterInt 将过滤条件的值转为整数，兼容JSON解码得到的float64
+func filterInt(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint32:
+		return int64(v), true
+	case MsgType:
+		return int64(v), true
+	case float64:
+		return int64(v), v == math.Trunc(v)
+	}
+	return 0, false
+}
+
+func (q *compiledQuery) match(msg *Message) bool {
+	if msg.SeqID <= q.afterSeqID || msg.SeqID >= q.beforeSeqID {
+		return false
+	}
+	if createTime := msg.CreateTime.UnixNano(); createTime < q.startTime || createTime > q.endTime {
+		return false
+	}
+	if q.senderID != nil && msg.SenderID != *q.senderID {
+		return false
+	}
+	if q.msgType != nil && msg.Type != *q.msgType {
+		return false
+	}
+	if q.replyTo != nil && msg.ReplyToSeqID != *q.replyTo {
+		return false
+	}
+	return q.mention == nil || slices.Contains(msg.Mentions, *q.mention)
+}
+
+// Query 在Timeline中按时间、SeqID区间与过滤条件查询消息，结果按SeqID升序并应用Offset/Limit
+// 由查询优化器根据块索引统计与实测成本选择按索引跳块或顺序扫描
+func (s *Store) Query(query *Query) (*QueryResult, error) {
+	q, err := compileQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	result := &QueryResult{Messages: []*Message{}}
+	timeline, exists := s.FindTimeline(query.TimelineID)
+	if !exists {
+		result.Plan = QueryPlanSequentialScan
+		return result, nil
+	}
+
+	timeline.mu.RLock()
+	defer timeline.mu.RUnlock()
+
+	// 按块索引与布隆过滤器确定候选块，统计供优化器估算成本
+	candidates := make([]bool, len(timeline.Blocks))
+	var stats QueryStats
+	for i, block := range timeline.Blocks {
+		block.mu.RLock()
+		count := block.Index.Count
+		candidates[i] = block.Index.mayMatch(q) && block.Filters.mayMatch(q)
+		block.mu.RUnlock()
+		stats.Blocks++
+		stats.Messages += count
+		if candidates[i] {
+			stats.CandidateBlocks++
+			stats.CandidateMessages += count
+		}
+	}
+	plan := s.queryOptimizer.Plan(query, stats)
+	result.Plan = plan.ExecutionPlan
+	result.EstimatedCost = plan.EstimatedCost
+
+	start := time.Now()
+	skip := query.Offset
+	for i, block := range timeline.Blocks {
+		if query.Limit > 0 && len(result.Messages) >= query.Limit {
+			break
+		}
+		if result.Plan == QueryPlanTimeIndex && !candidates[i] {
+			result.SkippedBlocks++
+			continue
+		}
+		result.ScannedBlocks++
+
+		messages, err := s.readBlockMessages(block)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range messages {
+			result.ScannedMessages++
+			if !q.match(msg) {
+				continue
+			}
+			if skip > 0 {
+				skip--
+				continue
+			}
+			result.Messages = append(result.Messages, msg)
+			if query.Limit > 0 && len(result.Messages) >= query.Limit {
+				break
+			}
+		}
+	}
+	result.Duration = time.Since(start)
+	s.queryOptimizer.RecordExecution(result.Plan, stats.Blocks, result.ScannedBlocks, result.ScannedMessages, result.Duration)
+	return result, nil
+}
+
+// GetMessagesBySender 获取会话中某个发送者SeqID大于afterSeqID的消息，limit为0时不限制条数
+// 开启块布隆过滤器后跳过不包含该发送者的块
+func (s *Store) GetMessagesBySender(convID string, senderID uint32, afterSeqID int64, limit int) ([]*Message, error) {
+	result, err := s.Query(&Query{
+		TimelineID: convID,
+		AfterSeqID: afterSeqID,
+		Filters:    map[string]interface{}{"sender_id": senderID},
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
+
+// GetThreadReplies 获取会话中回复rootSeqID且SeqID大于afterSeqID的消息，limit为0时不限制条数
+// 开启块布隆过滤器后跳过不包含对该消息回复的块，读取话题不必扫描整个会话
+func (s *Store) GetThreadReplies(convID string, rootSeqID, afterSeqID int64, limit int) ([]*Message, error) {
+	result, err := s.Query(&Query{
+		TimelineID: convID,
+		AfterSeqID: max(afterSeqID, rootSeqID),
+		Filters:    map[string]interface{}{"reply_to": rootSeqID},
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
+
+// GetMessagesMentioning 获取会话中提及某个用户且SeqID大于afterSeqID的消息，limit为0时不限制条数
+func (s *Store) GetMessagesMentioning(convID, userUUID string, afterSeqID int64, limit int) ([]*Message, error) {
+	result, err := s.Query(&Query{
+		TimelineID: convID,
+		AfterSeqID: afterSeqID,
+		Filters:    map[string]interface{}{"mention": userUUID},
+		Limit:      limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Messages, nil
+}
@@ -0,0 +1,156 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+// newQueryTestStore 创建每块4条消息的Store，写入12条间隔1分钟的消息（3个块），
+// 发送者在1和2之间交替
+func newQueryTestStore(t *testing.T, dir string) (*Store, time.Time) {
+	t.Helper()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 4, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 12; i++ {
+		source := &Message{SenderID: uint32(i%2 + 1), CreateTime: base.Add(time.Duration(i) * time.Minute), Data: []byte{byte(i)}}
+		if _, _, err := store.ReplicateMessage("conv_query", source, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	return store, base
+}
+
+func TestQuerySkipsBlocksOutsideRange(t *testing.T) {
+	store, base := newQueryTestStore(t, t.TempDir())
+	defer store.Close()
+
+	// 时间区间只覆盖第二个块
+	result, err := store.Query(&Query{
+		TimelineID: "conv_query",
+		StartTime:  base.Add(4 * time.Minute),
+		EndTime:    base.Add(6 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Plan != QueryPlanTimeIndex {
+		t.Errorf("Expected %s plan, got %s", QueryPlanTimeIndex, result.Plan)
+	}
+	if result.ScannedBlocks != 1 || result.SkippedBlocks != 2 || result.ScannedMessages != 4 {
+		t.Errorf("Expected to scan 1 block of 4 messages and skip 2, got %+v", result)
+	}
+	if len(result.Messages) != 3 || result.Messages[0].SeqID != 5 || result.Messages[2].SeqID != 7 {
+		t.Errorf("Expected SeqIDs 5..7, got %d messages", len(result.Messages))
+	}
+
+	// SeqID区间同样按块索引跳过
+	result, err = store.Query(&Query{TimelineID: "conv_query", AfterSeqID: 8, BeforeSeqID: 11})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.ScannedBlocks != 1 || len(result.Messages) != 2 || result.Messages[0].SeqID != 9 || result.Messages[1].SeqID != 10 {
+		t.Errorf("Expected SeqIDs 9 and 10 from one block, got %d messages from %d blocks", len(result.Messages), result.ScannedBlocks)
+	}
+}
+
+func TestQueryFiltersAndPaging(t *testing.T) {
+	store, _ := newQueryTestStore(t, t.TempDir())
+	defer store.Close()
+
+	// 没有区间条件时索引无法排除任何块，选择顺序扫描
+	result, err := store.Query(&Query{
+		TimelineID: "conv_query",
+		Filters:    map[string]interface{}{"sender_id": float64(2)},
+		Offset:     1,
+		Limit:      3,
+	})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.Plan != QueryPlanSequentialScan {
+		t.Errorf("Expected %s plan, got %s", QueryPlanSequentialScan, result.Plan)
+	}
+	var seqIDs []int64
+	for _, msg := range result.Messages {
+		if msg.SenderID != 2 {
+			t.Errorf("Unexpected sender %d", msg.SenderID)
+		}
+		seqIDs = append(seqIDs, msg.SeqID)
+	}
+	if len(seqIDs) != 3 || seqIDs[0] != 4 || seqIDs[2] != 8 {
+		t.Errorf("Expected SeqIDs [4 6 8], got %v", seqIDs)
+	}
+	// 取满Limit后不再扫描剩余的块
+	if result.ScannedBlocks != 2 {
+		t.Errorf("Expected to stop after 2 blocks, scanned %d", result.ScannedBlocks)
+	}
+
+	if _, err := store.Query(&Query{TimelineID: "conv_query", Filters: map[string]interface{}{"unknown": 1}}); err == nil {
+		t.Error("Expected unsupported filter to fail")
+	}
+	if _, err := store.Query(&Query{TimelineID: "conv_query", Filters: map[string]interface{}{"type": "edit"}}); err == nil {
+		t.Error("Expected non-numeric filter value to fail")
+	}
+	result, err = store.Query(&Query{TimelineID: "conv_missing"})
+	if err != nil || len(result.Messages) != 0 {
+		t.Errorf("Expected empty result for missing timeline, got %v, %v", result, err)
+	}
+}
+
+func TestBlockIndexPersistsAcrossReopen(t *testing.T) {
+	dir := t.TempDir()
+	store, base := newQueryTestStore(t, dir)
+	first, _ := store.GetTimeline("conv", "conv_query")
+	want := first.Blocks[0].Snapshot().Index
+	store.Close()
+
+	if want.Count != 4 || want.MinSeqID != 1 || want.MaxSeqID != 4 || want.MinTime != base.UnixNano() {
+		t.Fatalf("Unexpected index for the first block: %+v", want)
+	}
+
+	reopened, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 4, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	tl, _ := reopened.GetTimeline("conv", "conv_query")
+	if got := tl.Blocks[0].Snapshot().Index; got != want {
+		t.Errorf("Expected index %+v after reopen, got %+v", want, got)
+	}
+	result, err := reopened.Query(&Query{TimelineID: "conv_query", StartTime: base.Add(10 * time.Minute)})
+	if err != nil {
+		t.Fatalf("Query failed: %v", err)
+	}
+	if result.ScannedBlocks != 1 || len(result.Messages) != 2 {
+		t.Errorf("Expected 2 messages from the last block, got %d from %d blocks", len(result.Messages), result.ScannedBlocks)
+	}
+}
+
+func TestQueryOptimizerPlan(t *testing.T) {
+	qo := NewQueryOptimizer()
+	query := &Query{TimelineID: "conv"}
+
+	if plan := qo.Plan(query, QueryStats{Blocks: 10, Messages: 1000, CandidateBlocks: 10, CandidateMessages: 1000}); plan.ExecutionPlan != QueryPlanSequentialScan {
+		t.Errorf("Expected sequential scan when every block matches, got %s", plan.ExecutionPlan)
+	}
+	plan := qo.Plan(query, QueryStats{Blocks: 10, Messages: 1000, CandidateBlocks: 1, CandidateMessages: 100})
+	if plan.ExecutionPlan != QueryPlanTimeIndex || plan.EstimatedCost <= 0 {
+		t.Errorf("Expected time index plan with a positive cost, got %+v", plan)
+	}
+
+	// 只有一种计划的实测数据时仍按默认单位耗时比较
+	qo.RecordExecution(QueryPlanTimeIndex, 10, 8, 800, 100*time.Millisecond)
+	if plan := qo.Plan(query, QueryStats{Blocks: 10, Messages: 1000, CandidateBlocks: 8, CandidateMessages: 800}); plan.ExecutionPlan != QueryPlanTimeIndex {
+		t.Errorf("Expected default costs before both plans are measured, got %s", plan.ExecutionPlan)
+	}
+
+	// 索引执行实测明显偏慢后，少量跳块不再值得使用索引
+	qo.RecordExecution(QueryPlanSequentialScan, 10, 10, 1000, time.Millisecond)
+	if plan := qo.Plan(query, QueryStats{Blocks: 10, Messages: 1000, CandidateBlocks: 8, CandidateMessages: 800}); plan.ExecutionPlan != QueryPlanSequentialScan {
+		t.Errorf("Expected measured cost to favor sequential scan, got %s", plan.ExecutionPlan)
+	}
+}
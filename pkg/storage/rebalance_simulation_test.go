@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+// 重平衡的确定性仿真
+// 用虚拟时钟驱动真实的TimelineShardManager，迁移由simulatedMigrationManager按传输速度在虚拟时间内完成，
+// 完成时更新全局索引。每一步推进一个重平衡间隔：执行脚本中的负载变化、完成到期的迁移、执行一次自动重平衡，
+// 并记录负载方差与迁移，供测试断言收敛、不抖动和节流限制
+
+// simulatedMove 仿真中开始的一次迁移
+type simulatedMove struct {
+	Step        int
+	TimelineKey string
+	From        string
+	To          string
+	Size        int64
+	StartedAt   time.Time
+}
+
+// simulatedMigrationManager 在虚拟时间内完成迁移，每秒传输bandwidth字节
+type simulatedMigrationManager struct {
+	recordingMigrationManager
+	sim       *rebalanceSimulation
+	bandwidth int64
+	finishAt  map[string]time.Time
+}
+
+// StartMigration 与TimelineMigrationManager一致：同一Timeline到同一目标的未完成任务直接返回
+func (m *simulatedMigrationManager) StartMigration(ctx context.Context, timelineKey, targetStoreID string) (*MigrationTask, error) {
+	for _, task := range m.tasks {
+		if task.TimelineKey == timelineKey && task.TargetStore == targetStoreID && task.Status == MigrationRunning {
+			return task, nil
+		}
+	}
+	location, err := m.sim.index.GetTimelineLocation(ctx, timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	source := location.Blocks[0].StoreID
+	if source == targetStoreID {
+		return nil, fmt.Errorf("timeline is already on target store")
+	}
+	task := &MigrationTask{
+		ID:          fmt.Sprintf("sim_%d", len(m.tasks)),
+		TimelineKey: timelineKey,
+		SourceStore: source,
+		TargetStore: targetStoreID,
+		Status:      MigrationRunning,
+		CreatedAt:   m.sim.now,
+	}
+	m.tasks = append(m.tasks, task)
+	duration := time.Duration(location.TotalSize/m.bandwidth+1) * time.Second
+	m.finishAt[task.ID] = m.sim.now.Add(duration)
+	m.sim.moves = append(m.sim.moves, simulatedMove{
+		Step:        m.sim.step,
+		TimelineKey: timelineKey,
+		From:        source,
+		To:          targetStoreID,
+		Size:        location.TotalSize,
+		StartedAt:   m.sim.now,
+	})
+	return task, nil
+}
+
+// complete 完成到期的迁移并把Timeline切换到目标Store
+func (m *simulatedMigrationManager) complete(ctx context.Context) error {
+	for _, task := range m.tasks {
+		if task.Status != MigrationRunning || m.sim.now.Before(m.finishAt[task.ID]) {
+			continue
+		}
+		if err := m.sim.index.MigrateTimeline(ctx, task.TimelineKey, task.SourceStore, task.TargetStore); err != nil {
+			return err
+		}
+		task.Status = MigrationCompleted
+		task.Progress = 1
+	}
+	return nil
+}
+
+// active 进行中的迁移数
+func (m *simulatedMigrationManager) active() int {
+	active := 0
+	for _, task := range m.tasks {
+		if task.Status == MigrationRunning {
+			active++
+		}
+	}
+	return active
+}
+
+// rebalanceSimulation 仿真状态
+type rebalanceSimulation struct {
+	t          *testing.T
+	ctx        context.Context
+	now        time.Time
+	step       int
+	policy     *ShardPolicy
+	registry   *InMemoryRegistry
+	index      *InMemoryGlobalIndex
+	shards     *TimelineShardManager
+	migrations *simulatedMigrationManager
+	blocks     int
+
+	// 仿真结果
+	moves     []simulatedMove
+	variances []float64 // 每一步结束时的负载方差
+	maxActive int       // 同时进行的迁移数的最大值
+}
+
+// simulationScript 在第step步开始时修改负载，例如新增Timeline、写入数据或加入Store
+type simulationScript func(sim *rebalanceSimulation, step int)
+
+func newRebalanceSimulation(t *testing.T, policy *ShardPolicy, bandwidth int64) *rebalanceSimulation {
+	t.Helper()
+	sim := &rebalanceSimulation{
+		t:        t,
+		ctx:      context.Background(),
+		now:      time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC),
+		policy:   policy,
+		registry: NewInMemoryRegistry(),
+		index:    NewInMemoryGlobalIndex(),
+	}
+	t.Cleanup(func() { sim.registry.Close() })
+	sim.migrations = &simulatedMigrationManager{sim: sim, bandwidth: bandwidth, finishAt: make(map[string]time.Time)}
+	sim.shards = NewTimelineShardManager(sim.index, sim.registry, NewRouterManager(), sim.migrations)
+	sim.shards.now = func() time.Time { return sim.now }
+	if err := sim.shards.UpdateShardPolicy(policy); err != nil {
+		t.Fatalf("Failed to update policy: %v", err)
+	}
+	return sim
+}
+
+// addStore 加入一个Store
+func (sim *rebalanceSimulation) addStore(storeID string) {
+	if err := sim.registry.Register(sim.ctx, &StoreInfo{ID: storeID}); err != nil {
+		sim.t.Fatalf("Failed to register %s: %v", storeID, err)
+	}
+}
+
+// write 向Timeline写入size字节的新块，Timeline不存在时放在storeID上
+func (sim *rebalanceSimulation) write(storeID, timelineKey string, size int64) {
+	if location, err := sim.index.GetTimelineLocation(sim.ctx, timelineKey); err == nil {
+		storeID = location.Blocks[0].StoreID
+	}
+	sim.blocks++
+	index := &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: storeID, BlockID: fmt.Sprintf("block_%d", sim.blocks), Size: size}
+	if err := sim.index.AddIndex(sim.ctx, index); err != nil {
+		sim.t.Fatalf("Failed to add block to %s: %v", timelineKey, err)
+	}
+}
+
+// run 执行steps步，每步推进一个重平衡间隔
+func (sim *rebalanceSimulation) run(steps int, script simulationScript) {
+	for ; sim.step < steps; sim.step++ {
+		if script != nil {
+			script(sim, sim.step)
+		}
+		if err := sim.migrations.complete(sim.ctx); err != nil {
+			sim.t.Fatalf("Step %d: failed to complete migrations: %v", sim.step, err)
+		}
+		sim.shards.performAutoRebalance(sim.ctx)
+		sim.maxActive = max(sim.maxActive, sim.migrations.active())
+
+		stats, err := sim.shards.GetShardStats(sim.ctx)
+		if err != nil {
+			sim.t.Fatalf("Step %d: failed to get stats: %v", sim.step, err)
+		}
+		sim.variances = append(sim.variances, stats.LoadVariance)
+		sim.now = sim.now.Add(sim.policy.RebalanceInterval)
+	}
+	// 完成最后一步开始的迁移，之后的负载即为收敛结果
+	sim.now = sim.now.Add(24 * time.Hour)
+	sim.migrations.complete(sim.ctx)
+}
+
+// finalVariance 所有迁移完成后的负载方差
+func (sim *rebalanceSimulation) finalVariance() float64 {
+	stats, err := sim.shards.GetShardStats(sim.ctx)
+	if err != nil {
+		sim.t.Fatalf("Failed to get stats: %v", err)
+	}
+	return stats.LoadVariance
+}
+
+// assertConverged 检查负载方差下降，且最后quiet步没有开始新的迁移
+func (sim *rebalanceSimulation) assertConverged(initialVariance float64, quiet int) {
+	sim.t.Helper()
+	final := sim.finalVariance()
+	if final >= initialVariance/2 {
+		sim.t.Errorf("Expected the load variance to drop from %.4f, got %.4f", initialVariance, final)
+	}
+	for _, move := range sim.moves {
+		if move.Step >= sim.step-quiet {
+			sim.t.Errorf("Expected no migrations in the last %d steps, got %+v", quiet, move)
+		}
+	}
+}
+
+// assertNoThrashing 检查没有Timeline被迁回它迁出过的Store；
+// static为true表示仿真中负载不变，此时一个Store也不应既迁出又迁入
+func (sim *rebalanceSimulation) assertNoThrashing(static bool) {
+	sim.t.Helper()
+	left := make(map[string][]string)
+	for _, move := range sim.moves {
+		if slices.Contains(left[move.TimelineKey], move.To) {
+			sim.t.Errorf("Timeline %s moved back to %s at step %d", move.TimelineKey, move.To, move.Step)
+		}
+		left[move.TimelineKey] = append(left[move.TimelineKey], move.From)
+	}
+	if !static {
+		return
+	}
+	donors, receivers := make(map[string]bool), make(map[string]bool)
+	for _, move := range sim.moves {
+		donors[move.From] = true
+		receivers[move.To] = true
+	}
+	for store := range donors {
+		if receivers[store] {
+			sim.t.Errorf("Store %s both gave and received timelines: %+v", store, sim.moves)
+		}
+	}
+}
+
+// hotStoreScript 第0步在store_0上放置12个大小不一的Timeline，其余Store为空
+func hotStoreScript(sim *rebalanceSimulation, step int) {
+	if step != 0 {
+		return
+	}
+	for i := 0; i < 12; i++ {
+		sim.write("store_0", fmt.Sprintf("conv_%02d", i), int64(400+(i*173)%600))
+	}
+}
+
+// newHotStoreSimulation 4个Store，每个容量10000字节，迁移速度为每秒bandwidth字节
+func newHotStoreSimulation(t *testing.T, policy *ShardPolicy, bandwidth int64) *rebalanceSimulation {
+	policy.MaxTimelinePerStore = 1000
+	policy.MaxSizePerStore = 10000
+	policy.LoadBalanceThreshold = 0.2
+	policy.RebalanceInterval = time.Minute
+	sim := newRebalanceSimulation(t, policy, bandwidth)
+	for i := 0; i < 4; i++ {
+		sim.addStore(fmt.Sprintf("store_%d", i))
+	}
+	return sim
+}
+
+func TestRebalanceSimulationConverges(t *testing.T) {
+	policy := DefaultShardPolicy()
+	policy.TimelineCooldown = 0
+	sim := newHotStoreSimulation(t, policy, 100)
+	hotStoreScript(sim, 0)
+	initial := sim.finalVariance()
+
+	sim.step = 1
+	sim.run(60, nil)
+	if len(sim.moves) == 0 {
+		t.Fatalf("Expected the hot store to be rebalanced")
+	}
+	sim.assertConverged(initial, 20)
+	sim.assertNoThrashing(true)
+
+	// 方差在每次迁移完成后不增加
+	for i := 1; i < len(sim.variances); i++ {
+		if sim.variances[i] > sim.variances[i-1]+1e-12 {
+			t.Errorf("Variance increased at step %d: %.4f -> %.4f", i+1, sim.variances[i-1], sim.variances[i])
+		}
+	}
+
+	// 同样的脚本得到同样的迁移
+	copied := *policy
+	again := newHotStoreSimulation(t, &copied, 100)
+	hotStoreScript(again, 0)
+	again.step = 1
+	again.run(60, nil)
+	if !slices.Equal(sim.moves, again.moves) {
+		t.Errorf("Expected a deterministic simulation, got\n%+v\n%+v", sim.moves, again.moves)
+	}
+}
+
+func TestRebalanceSimulationRespectsPolicyLimits(t *testing.T) {
+	policy := DefaultShardPolicy()
+	policy.MaxConcurrentMigrations = 1
+	policy.MaxTransferBytesPerSecond = 4 // 每个间隔240字节
+	policy.TimelineCooldown = 30 * time.Minute
+	policy.BlackoutWindows = []MaintenanceWindow{{Start: "01:00", End: "02:00"}}
+	// 传输速度为每秒2字节，每个迁移需要多个间隔才能完成
+	sim := newHotStoreSimulation(t, policy, 2)
+	hotStoreScript(sim, 0)
+	initial := sim.finalVariance()
+
+	sim.step = 1
+	sim.run(360, nil)
+	sim.assertConverged(initial, 30)
+	sim.assertNoThrashing(true)
+
+	if sim.maxActive > policy.MaxConcurrentMigrations {
+		t.Errorf("Expected at most %d concurrent migrations, got %d", policy.MaxConcurrentMigrations, sim.maxActive)
+	}
+	capacity := float64(policy.MaxTransferBytesPerSecond) * policy.RebalanceInterval.Seconds()
+	var largest int64
+	for i, move := range sim.moves {
+		largest = max(largest, move.Size)
+		for _, window := range policy.BlackoutWindows {
+			if window.contains(move.StartedAt) {
+				t.Errorf("Migration started inside the blackout window: %+v", move)
+			}
+		}
+		// 令牌桶：任意时间段内开始的迁移量不超过补充量加桶容量，超出桶容量的Timeline只在桶满时放行
+		var transferred int64
+		for _, later := range sim.moves[i:] {
+			transferred += later.Size
+			elapsed := later.StartedAt.Sub(move.StartedAt).Seconds()
+			if limit := capacity + float64(policy.MaxTransferBytesPerSecond)*elapsed + float64(largest); float64(transferred) > limit {
+				t.Errorf("Transferred %d bytes in %.0fs from step %d, budget allows %.0f", transferred, elapsed, move.Step, limit)
+			}
+		}
+		for _, earlier := range sim.moves[:i] {
+			if earlier.TimelineKey == move.TimelineKey && move.StartedAt.Sub(earlier.StartedAt) < policy.TimelineCooldown {
+				t.Errorf("Timeline %s migrated again within the cooldown at step %d", move.TimelineKey, move.Step)
+			}
+		}
+	}
+}
+
+func TestRebalanceSimulationFollowsSkewedGrowth(t *testing.T) {
+	// 前30步三个Store上各有Timeline，只有store_0上的4个会话持续写入；第40步新的Store加入
+	script := func(sim *rebalanceSimulation, step int) {
+		switch {
+		case step == 0:
+			for i := 0; i < 12; i++ {
+				sim.write(fmt.Sprintf("store_%d", i%3), fmt.Sprintf("conv_%02d", i), 200)
+			}
+		case step < 30:
+			sim.write("store_0", fmt.Sprintf("conv_hot_%d", step%4), 300)
+		case step == 40:
+			sim.addStore("store_4")
+		}
+	}
+	policy := DefaultShardPolicy()
+	policy.MaxConcurrentMigrations = 2
+	policy.TimelineCooldown = 10 * time.Minute
+
+	// 不重平衡时的负载作为对照
+	unbalanced := *policy
+	unbalanced.AutoRebalance = false
+	baseline := newHotStoreSimulation(t, &unbalanced, 100)
+	baseline.run(41, script)
+
+	sim := newHotStoreSimulation(t, policy, 100)
+	sim.run(120, script)
+
+	// 写入期间重平衡跟上负载变化，方差始终低于不重平衡时
+	if peak, worst := slices.Max(sim.variances[:30]), baseline.variances[29]; peak >= worst/2 {
+		t.Errorf("Expected rebalancing to keep up with the writes, peak variance %.4f vs %.4f without rebalancing", peak, worst)
+	}
+	// 新加入的Store分担负载
+	joined := sim.variances[40]
+	if final := sim.finalVariance(); final >= joined {
+		t.Errorf("Expected the new store to take load, variance %.4f after it joined, %.4f at the end", joined, final)
+	}
+	if !slices.ContainsFunc(sim.moves, func(m simulatedMove) bool { return m.To == "store_4" }) {
+		t.Errorf("Expected a migration to the new store, got %+v", sim.moves)
+	}
+	for _, move := range sim.moves {
+		if move.Step >= 60 {
+			t.Errorf("Expected rebalancing to settle, got %+v", move)
+		}
+	}
+	sim.assertNoThrashing(false)
+}
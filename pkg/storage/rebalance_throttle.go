@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+// 自动重平衡的节流
+// performAutoRebalance在调用StartMigration之前依次检查：当前时间是否在允许的时间段内且不在禁止时间段内，
+// 进行中的迁移数是否达到MaxConcurrentMigrations，Timeline是否仍在上次自动迁移后的冷却期内，以及传输预算是否足够。
+// 传输预算是一个令牌桶，按MaxTransferBytesPerSecond补充，最多积累一个重平衡间隔的量；
+// 大于桶容量的Timeline在桶满时放行，预算变为负数，之后的迁移等预算恢复后再开始
+
+// MaintenanceWindow 每天的一个时间段(UTC)，End早于Start时跨越午夜，Start等于End表示全天
+type MaintenanceWindow struct {
+	Start    string         `json:"start"`              // HH:MM
+	End      string         `json:"end"`                // HH:MM
+	Weekdays []time.Weekday `json:"weekdays,omitempty"` // 时间段开始的星期(0为星期日)，为空表示每天
+}
+
+// parseClock 解析HH:MM，返回距离零点的时长
+func parseClock(value string) (time.Duration, error) {
+	clock, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", value)
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, nil
+}
+
+// validate 校验时间段
+func (w MaintenanceWindow) validate() error {
+	if _, err := parseClock(w.Start); err != nil {
+		return err
+	}
+	if _, err := parseClock(w.End); err != nil {
+		return err
+	}
+	for _, day := range w.Weekdays {
+		if day < time.Sunday || day > time.Saturday {
+			return fmt.Errorf("invalid weekday %d", day)
+		}
+	}
+	return nil
+}
+
+// contains 判断时刻是否落在时间段内，跨越午夜的部分属于时间段开始的那一天
+func (w MaintenanceWindow) contains(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+	t = t.UTC()
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	clock := t.Sub(midnight)
+	day := t.Weekday()
+
+	switch {
+	case start == end:
+	case start < end:
+		if clock < start || clock >= end {
+			return false
+		}
+	case clock >= start:
+	case clock < end:
+		day = (day + 6) % 7
+	default:
+		return false
+	}
+
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, weekday := range w.Weekdays {
+		if weekday == day {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRebalanceThrottle 校验分片策略中的节流配置
+func validateRebalanceThrottle(policy *ShardPolicy) error {
+	if policy.MaxConcurrentMigrations < 0 {
+		return fmt.Errorf("max_concurrent_migrations must not be negative")
+	}
+	if policy.MaxTransferBytesPerSecond < 0 {
+		return fmt.Errorf("max_transfer_bytes_per_second must not be negative")
+	}
+	if policy.TimelineCooldown < 0 {
+		return fmt.Errorf("timeline_cooldown must not be negative")
+	}
+	for _, window := range policy.RebalanceWindows {
+		if err := window.validate(); err != nil {
+			return fmt.Errorf("rebalance_windows: %w", err)
+		}
+	}
+	for _, window := range policy.BlackoutWindows {
+		if err := window.validate(); err != nil {
+			return fmt.Errorf("blackout_windows: %w", err)
+		}
+	}
+	return nil
+}
+
+// rebalanceWindowOpen 判断当前是否允许开始自动迁移，不允许时返回原因
+func rebalanceWindowOpen(policy *ShardPolicy, now time.Time) (bool, string) {
+	for _, window := range policy.BlackoutWindows {
+		if window.contains(now) {
+			return false, fmt.Sprintf("inside blackout window %s-%s", window.Start, window.End)
+		}
+	}
+	if len(policy.RebalanceWindows) == 0 {
+		return true, ""
+	}
+	for _, window := range policy.RebalanceWindows {
+		if window.contains(now) {
+			return true, ""
+		}
+	}
+	return false, "outside rebalance windows"
+}
+
+// rebalanceThrottle 自动迁移的传输预算和冷却记录，由TimelineShardManager.mu保护
+type rebalanceThrottle struct {
+	tokens       float64
+	refilledAt   time.Time
+	lastMigrated map[string]time.Time
+}
+
+func newRebalanceThrottle() *rebalanceThrottle {
+	return &rebalanceThrottle{lastMigrated: make(map[string]time.Time)}
+}
+
+// capacity 传输预算的上限
+func (t *rebalanceThrottle) capacity(policy *ShardPolicy) float64 {
+	interval := policy.RebalanceInterval
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return float64(policy.MaxTransferBytesPerSecond) * interval.Seconds()
+}
+
+// refill 按经过的时间补充传输预算，第一次使用时预算是满的
+func (t *rebalanceThrottle) refill(policy *ShardPolicy, now time.Time) {
+	capacity := t.capacity(policy)
+	if t.refilledAt.IsZero() {
+		t.tokens = capacity
+	} else if elapsed := now.Sub(t.refilledAt); elapsed > 0 {
+		t.tokens += float64(policy.MaxTransferBytesPerSecond) * elapsed.Seconds()
+	}
+	if t.tokens > capacity {
+		t.tokens = capacity
+	}
+	t.refilledAt = now
+}
+
+// reserve 检查冷却期和传输预算，通过时记录本次迁移并扣除预算，不通过时返回原因
+func (t *rebalanceThrottle) reserve(policy *ShardPolicy, timelineKey string, size int64, now time.Time) string {
+	if policy.TimelineCooldown > 0 {
+		for key, migratedAt := range t.lastMigrated {
+			if now.Sub(migratedAt) >= policy.TimelineCooldown {
+				delete(t.lastMigrated, key)
+			}
+		}
+		if migratedAt, ok := t.lastMigrated[timelineKey]; ok {
+			return fmt.Sprintf("%s is cooling down until %s", timelineKey, migratedAt.Add(policy.TimelineCooldown).Format(time.RFC3339))
+		}
+	}
+
+	if policy.MaxTransferBytesPerSecond > 0 {
+		t.refill(policy, now)
+		if float64(size) > t.tokens && t.tokens < t.capacity(policy) {
+			return fmt.Sprintf("transfer budget exhausted, %s needs %d bytes", timelineKey, size)
+		}
+		t.tokens -= float64(size)
+	}
+
+	if policy.TimelineCooldown > 0 {
+		t.lastMigrated[timelineKey] = now
+	}
+	return ""
+}
+
+// release 迁移没有开始时退回reserve扣除的预算和冷却记录
+func (t *rebalanceThrottle) release(policy *ShardPolicy, timelineKey string, size int64) {
+	if policy.MaxTransferBytesPerSecond > 0 {
+		t.tokens += float64(size)
+	}
+	delete(t.lastMigrated, timelineKey)
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 启动扫描与索引重建
+// Timeline只加载元数据中列出的块，元数据文件丢失、损坏或漏记块时，段文件中的块不可见。
+// 打开Store时按段文件索引枚举所有块，块ID的前缀即所属Timeline（{type}_{id}_{纳秒时间戳}），
+// 元数据缺失或漏记的块按SeqID顺序补回元数据，并按块的位置重建StoreIndex。
+// 无法从块ID确定Timeline的块记为孤立块，只报告不删除。
+// 全局索引在Store打开之后才可用，由ReconcileGlobalIndex补登本地Timeline并报告不一致的登记。
+
+// RecoveryReport 启动扫描与全局索引核对的结果
+type RecoveryReport struct {
+	BlocksScanned    int      `json:"blocks_scanned"`
+	RebuiltTimelines []string `json:"rebuilt_timelines,omitempty"` // 元数据缺失或无法解析、按块重建的Timeline键
+	RepairedBlocks   []string `json:"repaired_blocks,omitempty"`   // 补回元数据的块
+	OrphanedBlocks   []string `json:"orphaned_blocks,omitempty"`   // 无法确定所属Timeline的块
+
+	IndexRegistered []string `json:"index_registered,omitempty"`  // 补登到全局索引的Timeline ID
+	IndexOtherStore []string `json:"index_other_store,omitempty"` // 全局索引只登记在其他Store上的本地Timeline，如副本或未完成的迁移
+	IndexStale      []string `json:"index_stale,omitempty"`       // 全局索引登记在本Store但本地没有块的Timeline
+}
+
+// RecoveryReport 返回启动扫描与全局索引核对的结果
+func (s *Store) RecoveryReport() RecoveryReport {
+	s.recoveryMu.Lock()
+	defer s.recoveryMu.Unlock()
+	report := s.recovery
+	report.RebuiltTimelines = append([]string(nil), report.RebuiltTimelines...)
+	report.RepairedBlocks = append([]string(nil), report.RepairedBlocks...)
+	report.OrphanedBlocks = append([]string(nil), report.OrphanedBlocks...)
+	report.IndexRegistered = append([]string(nil), report.IndexRegistered...)
+	report.IndexOtherStore = append([]string(nil), report.IndexOtherStore...)
+	report.IndexStale = append([]string(nil), report.IndexStale...)
+	return report
+}
+
+// parseTimelineKey 解析 {type}_{id} 格式的Timeline键
+func parseTimelineKey(key string) (timelineType, timelineID string, ok bool) {
+	timelineType, timelineID, ok = strings.Cut(key, "_")
+	if !ok || timelineID == "" || (timelineType != "conv" && timelineType != "user") {
+		return "", "", false
+	}
+	return timelineType, timelineID, true
+}
+
+// scanBlocks 按段文件中的块修复Timeline元数据并重建StoreIndex
+func (s *Store) scanBlocks() error {
+	locations := s.segments.Locations()
+	// 已分层的块按存根参与修复，不算作缺失
+	for blockID, location := range s.tieredLocations() {
+		locations[blockID] = location
+	}
+	report := RecoveryReport{BlocksScanned: len(locations)}
+
+	byTimeline := make(map[string][]string)
+	for blockID := range locations {
+		key := blockTimelineKey(blockID)
+		if _, _, ok := parseTimelineKey(key); !ok || key == blockID {
+			report.OrphanedBlocks = append(report.OrphanedBlocks, blockID)
+			continue
+		}
+		byTimeline[key] = append(byTimeline[key], blockID)
+	}
+	sort.Strings(report.OrphanedBlocks)
+
+	keys := make([]string, 0, len(byTimeline))
+	for key := range byTimeline {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		blockIDs := byTimeline[key]
+		// 同一Timeline的块覆盖连续且不重叠的SeqID区间
+		sort.Slice(blockIDs, func(i, j int) bool {
+			a, b := locations[blockIDs[i]], locations[blockIDs[j]]
+			if a.Index.MaxSeqID != b.Index.MaxSeqID {
+				return a.Index.MaxSeqID < b.Index.MaxSeqID
+			}
+			return blockIDs[i] < blockIDs[j]
+		})
+		s.rebuildStoreIndex(key, blockIDs, locations)
+		if err := s.repairTimelineMetadata(key, blockIDs, locations, &report); err != nil {
+			return fmt.Errorf("failed to repair metadata of %s: %w", key, err)
+		}
+	}
+
+	if len(report.RebuiltTimelines) > 0 || len(report.RepairedBlocks) > 0 || len(report.OrphanedBlocks) > 0 {
+		log.Printf("store %s: startup scan of %d blocks rebuilt %d timelines, repaired %d blocks, found %d orphaned blocks",
+			s.StoreID, report.BlocksScanned, len(report.RebuiltTimelines), len(report.RepairedBlocks), len(report.OrphanedBlocks))
+	}
+	s.recoveryMu.Lock()
+	s.recovery = report
+	s.recoveryMu.Unlock()
+	return nil
+}
+
+// rebuildStoreIndex 按块在段文件中的位置重建Timeline的StoreIndex
+func (s *Store) rebuildStoreIndex(key string, blockIDs []string, locations map[string]BlockLocation) {
+	indexes := make([]*StoreIndex, 0, len(blockIDs))
+	for _, blockID := range blockIDs {
+		location := locations[blockID]
+		index := &StoreIndex{
+			StoreID:   s.StoreID,
+			BlockID:   blockID,
+			SegmentID: location.SegmentID,
+			Offset:    location.Offset,
+			Size:      location.Length,
+		}
+		if created, ok := blockCreatedAt(blockID); ok {
+			index.CreatedAt = time.Unix(0, created).Unix()
+		}
+		indexes = append(indexes, index)
+	}
+	s.indexMu.Lock()
+	s.StoreIndex[key] = indexes
+	s.indexMu.Unlock()
+}
+
+// repairTimelineMetadata 元数据缺失、无法解析或漏记块时按段文件中的块重写元数据
+// 已落盘的块按SeqID排在前面，元数据中记录的其他块（如只在WAL中的活跃块）保持原顺序排在之后
+func (s *Store) repairTimelineMetadata(key string, blockIDs []string, locations map[string]BlockLocation, report *RecoveryReport) error {
+	timelineType, timelineID, _ := parseTimelineKey(key)
+
+	var metadata timelineMetadata
+	rebuilt := false
+	data, err := s.readTimelineMetadata(key)
+	switch {
+	case err != nil:
+		return err
+	case data == nil:
+		rebuilt = true
+	default:
+		if err := json.Unmarshal(data, &metadata); err != nil {
+			log.Printf("store %s: unreadable metadata of %s, rebuilding from blocks: %v", s.StoreID, key, err)
+			metadata = timelineMetadata{}
+			rebuilt = true
+		}
+	}
+
+	listed := make(map[string]bool, len(metadata.BlockIDs))
+	for _, blockID := range metadata.BlockIDs {
+		listed[blockID] = true
+	}
+	var missing []string
+	for _, blockID := range blockIDs {
+		if !listed[blockID] {
+			missing = append(missing, blockID)
+		}
+	}
+	if !rebuilt && len(missing) == 0 {
+		return nil
+	}
+
+	ordered := append([]string(nil), blockIDs...)
+	for _, blockID := range metadata.BlockIDs {
+		if _, persisted := locations[blockID]; !persisted {
+			ordered = append(ordered, blockID)
+		}
+	}
+	metadata.ID = timelineID
+	metadata.Type = timelineType
+	metadata.BlockIDs = ordered
+	for _, blockID := range blockIDs {
+		metadata.LastSeqID = max(metadata.LastSeqID, locations[blockID].Index.MaxSeqID)
+	}
+
+	data, err = json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	err = s.metadata.Update(func(tx MetadataTx) error {
+		tx.Put(metaBucketTimelines, key, data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if rebuilt {
+		report.RebuiltTimelines = append(report.RebuiltTimelines, key)
+	}
+	report.RepairedBlocks = append(report.RepairedBlocks, missing...)
+	return nil
+}
+
+// ReconcileGlobalIndex 核对全局索引与本地的Timeline：本地有块但全局索引中没有登记的Timeline补登到本Store；
+// 已登记在其他Store上的不修改，与全局索引中登记在本Store但本地没有块的Timeline一起报告
+func (s *Store) ReconcileGlobalIndex(ctx context.Context, index GlobalIndexManager) (RecoveryReport, error) {
+	registered, err := index.ListTimelinesByStore(ctx, s.StoreID)
+	if err != nil {
+		return s.RecoveryReport(), fmt.Errorf("failed to list timelines of store %s: %w", s.StoreID, err)
+	}
+	onStore := make(map[string]bool, len(registered))
+	for _, timelineID := range registered {
+		onStore[timelineID] = true
+	}
+
+	local := make(map[string]time.Time)
+	s.indexMu.RLock()
+	for key, indexes := range s.StoreIndex {
+		_, timelineID, ok := parseTimelineKey(key)
+		if !ok || len(indexes) == 0 {
+			continue
+		}
+		created := time.Unix(indexes[0].CreatedAt, 0)
+		if existing, seen := local[timelineID]; !seen || created.Before(existing) {
+			local[timelineID] = created
+		}
+	}
+	s.indexMu.RUnlock()
+
+	timelineIDs := make([]string, 0, len(local))
+	for timelineID := range local {
+		timelineIDs = append(timelineIDs, timelineID)
+	}
+	sort.Strings(timelineIDs)
+
+	var added, otherStore, stale []string
+	for _, timelineID := range timelineIDs {
+		if onStore[timelineID] {
+			continue
+		}
+		// 全局索引没有区分“不存在”与其他错误，查询失败时按未登记处理
+		if location, err := index.GetTimelineLocation(ctx, timelineID); err == nil && len(location.Blocks) > 0 {
+			otherStore = append(otherStore, timelineID)
+			continue
+		}
+		if err := index.AddIndex(ctx, &GlobalStoreIndex{
+			TimelineKey: timelineID,
+			StoreID:     s.StoreID,
+			CreatedAt:   local[timelineID],
+			UpdatedAt:   time.Now(),
+		}); err != nil {
+			return s.RecoveryReport(), fmt.Errorf("failed to register timeline %s: %w", timelineID, err)
+		}
+		added = append(added, timelineID)
+	}
+	for _, timelineID := range registered {
+		if _, exists := local[timelineID]; !exists {
+			stale = append(stale, timelineID)
+		}
+	}
+	sort.Strings(stale)
+
+	s.recoveryMu.Lock()
+	s.recovery.IndexRegistered = added
+	s.recovery.IndexOtherStore = otherStore
+	s.recovery.IndexStale = stale
+	s.recoveryMu.Unlock()
+	return s.RecoveryReport(), nil
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeScanStore(t *testing.T, dir string) *StoreConfig {
+	t.Helper()
+	config := &StoreConfig{StoreID: "store_scan", TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if err := store.AddMessage("scan", 1, []byte(fmt.Sprintf("message-%d", i)), []string{"user_scan"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+	return config
+}
+
+func TestStartupScanRebuildsLostMetadata(t *testing.T) {
+	dir := t.TempDir()
+	config := writeScanStore(t, dir)
+
+	// 会话元数据丢失，用户元数据损坏
+	if err := os.Remove(filepath.Join(dir, "conv_scan.meta")); err != nil {
+		t.Fatalf("Failed to remove metadata: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "user_user_scan.meta"), []byte(`{"id":`), 0644); err != nil {
+		t.Fatalf("Failed to overwrite metadata: %v", err)
+	}
+
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	report := store.RecoveryReport()
+	if want := []string{"conv_scan", "user_user_scan"}; !reflect.DeepEqual(report.RebuiltTimelines, want) {
+		t.Errorf("Expected rebuilt timelines %v, got %v", want, report.RebuiltTimelines)
+	}
+	if len(report.RepairedBlocks) != 4 || report.BlocksScanned != 4 {
+		t.Errorf("Expected 4 repaired of 4 scanned blocks, got %d of %d", len(report.RepairedBlocks), report.BlocksScanned)
+	}
+	if indexes := store.StoreIndex["conv_scan"]; len(indexes) != 2 || indexes[0].Size == 0 {
+		t.Errorf("Expected 2 rebuilt store index entries, got %+v", indexes)
+	}
+
+	// 写满的块从段文件加载，第5条消息从WAL恢复
+	assertConvMessages(t, store, "scan", 5)
+	if messages, _ := store.GetMessagesAfterCheckpoint("user_scan"); len(messages) != 5 {
+		t.Errorf("Expected 5 user timeline records, got %d", len(messages))
+	}
+	if err := store.AddMessage("scan", 1, []byte("message-6"), nil); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	assertConvMessages(t, store, "scan", 6)
+}
+
+func TestStartupScanRepairsMissingBlocks(t *testing.T) {
+	dir := t.TempDir()
+	config := writeScanStore(t, dir)
+
+	// 元数据漏记了第一个块
+	metaPath := filepath.Join(dir, "conv_scan.meta")
+	data, _ := os.ReadFile(metaPath)
+	var metadata timelineMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatalf("Failed to parse metadata: %v", err)
+	}
+	dropped := metadata.BlockIDs[0]
+	metadata.BlockIDs = metadata.BlockIDs[1:]
+	data, _ = json.Marshal(metadata)
+	os.WriteFile(metaPath, data, 0644)
+
+	// 无法确定Timeline的块只报告
+	segments, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	if _, err := segments.WriteBlock("legacy", []*Message{{SeqID: 1, Data: []byte("legacy")}}); err != nil {
+		t.Fatalf("Failed to write block: %v", err)
+	}
+	segments.Close()
+
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer store.Close()
+
+	report := store.RecoveryReport()
+	if len(report.RebuiltTimelines) != 0 {
+		t.Errorf("Expected no rebuilt timelines, got %v", report.RebuiltTimelines)
+	}
+	if !reflect.DeepEqual(report.RepairedBlocks, []string{dropped}) {
+		t.Errorf("Expected repaired block %s, got %v", dropped, report.RepairedBlocks)
+	}
+	if !reflect.DeepEqual(report.OrphanedBlocks, []string{"legacy"}) {
+		t.Errorf("Expected orphaned block legacy, got %v", report.OrphanedBlocks)
+	}
+	assertConvMessages(t, store, "scan", 5)
+
+	// 元数据完整时不再改写
+	store.Close()
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if report := reopened.RecoveryReport(); len(report.RepairedBlocks) != 0 || len(report.OrphanedBlocks) != 1 {
+		t.Errorf("Expected only the orphaned block to be reported, got %+v", report)
+	}
+}
+
+func TestReconcileGlobalIndex(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(&StoreConfig{StoreID: "store_a", TimelineMaxSize: 1, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	for _, convID := range []string{"local", "replica"} {
+		if err := store.AddMessage(convID, 1, []byte("message"), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	index := NewInMemoryGlobalIndex()
+	index.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "replica", StoreID: "store_b"})
+	index.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "ghost", StoreID: "store_a"})
+
+	report, err := store.ReconcileGlobalIndex(ctx, index)
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if !reflect.DeepEqual(report.IndexRegistered, []string{"local"}) {
+		t.Errorf("Expected local to be registered, got %v", report.IndexRegistered)
+	}
+	if !reflect.DeepEqual(report.IndexOtherStore, []string{"replica"}) {
+		t.Errorf("Expected replica to be reported on another store, got %v", report.IndexOtherStore)
+	}
+	if !reflect.DeepEqual(report.IndexStale, []string{"ghost"}) {
+		t.Errorf("Expected ghost to be reported stale, got %v", report.IndexStale)
+	}
+	if location, err := index.GetTimelineLocation(ctx, "local"); err != nil || len(location.StoreMap["store_a"]) != 1 {
+		t.Errorf("Expected local to be registered on store_a, got %+v %v", location, err)
+	}
+
+	// 再次核对时不重复登记
+	if report, _ := store.ReconcileGlobalIndex(ctx, index); len(report.IndexRegistered) != 0 {
+		t.Errorf("Expected nothing to register, got %v", report.IndexRegistered)
+	}
+}
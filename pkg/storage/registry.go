@@ -11,11 +11,18 @@ import (
 type StoreInfo struct {
 	ID       string    `json:"id"`       // Store唯一标识
 	Address  string    `json:"address"`  // Store服务地址
-	Status   string    `json:"status"`   // 状态: active, inactive, unhealthy
+	Status   StoreStatus `json:"status"` // 生命周期状态，见store_status.go
 	LastSeen time.Time `json:"lastSeen"` // 最后心跳时间
 	Metadata map[string]interface{} `json:"metadata"` // 扩展元数据
 }
 
+// StoreInfo.Metadata中由节点配置同步的键
+const (
+	StoreMetadataRegion   = "region"   // 所在地域
+	StoreMetadataTier     = "tier"     // 存储层级，如ssd、hdd
+	StoreMetadataCapacity = "capacity" // 最大容量（字节）
+)
+
 // StoreRegistry Store注册中心接口
 type StoreRegistry interface {
 	// Register 注册Store节点
@@ -30,6 +37,8 @@ type StoreRegistry interface {
 	ListActiveStores(ctx context.Context) ([]*StoreInfo, error)
 	// UpdateHeartbeat 更新心跳
 	UpdateHeartbeat(ctx context.Context, storeID string) error
+	// UpdateStatus 转换Store状态，不允许的转换返回ErrInvalidStoreTransition
+	UpdateStatus(ctx context.Context, storeID string, status StoreStatus) error
 	// Watch 监听Store变化
 	Watch(ctx context.Context) (<-chan StoreEvent, error)
 }
@@ -40,6 +49,17 @@ type StoreEvent struct {
 	Store *StoreInfo `json:"store"` // Store信息
 }
 
+// storeEventType 根据变更前后的Store信息确定事件类型，供外部注册中心转换变更通知
+func storeEventType(prev, cur *StoreInfo, created bool) string {
+	if created {
+		return "register"
+	}
+	if cur.Status == StoreStatusUnhealthy && (prev == nil || prev.Status != StoreStatusUnhealthy) {
+		return "unhealthy"
+	}
+	return "heartbeat"
+}
+
 // InMemoryRegistry 内存实现的Store注册中心
 type InMemoryRegistry struct {
 	mu       sync.RWMutex
@@ -65,12 +85,16 @@ func NewInMemoryRegistry() *InMemoryRegistry {
 	return r
 }
 
-// Register 注册Store节点
+// Register 注册Store节点，重复注册时排空中的Store保持排空
 func (r *InMemoryRegistry) Register(ctx context.Context, info *StoreInfo) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	
-	info.Status = "active"
+	status, err := registrationStatus(r.stores[info.ID])
+	if err != nil {
+		return err
+	}
+	info.Status = status
 	info.LastSeen = time.Now()
 	r.stores[info.ID] = info
 	
@@ -137,7 +161,7 @@ func (r *InMemoryRegistry) ListActiveStores(ctx context.Context) ([]*StoreInfo,
 	
 	stores := make([]*StoreInfo, 0)
 	for _, store := range r.stores {
-		if store.Status == "active" {
+		if store.Status == StoreStatusActive {
 			stores = append(stores, store)
 		}
 	}
@@ -156,8 +180,8 @@ func (r *InMemoryRegistry) UpdateHeartbeat(ctx context.Context, storeID string)
 	}
 	
 	store.LastSeen = time.Now()
-	if store.Status == "unhealthy" {
-		store.Status = "active"
+	if store.Status == StoreStatusUnhealthy {
+		store.Status = StoreStatusActive
 	}
 	
 	// 发送心跳事件
@@ -169,6 +193,36 @@ func (r *InMemoryRegistry) UpdateHeartbeat(ctx context.Context, storeID string)
 	return nil
 }
 
+// UpdateStatus 转换Store状态，状态变化时发送事件
+func (r *InMemoryRegistry) UpdateStatus(ctx context.Context, storeID string, status StoreStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	store, exists := r.stores[storeID]
+	if !exists {
+		return fmt.Errorf("store %s not found", storeID)
+	}
+	
+	if err := validateStoreTransition(storeID, store.Status, status); err != nil {
+		return err
+	}
+	if store.Status == status {
+		return nil
+	}
+	store.Status = status
+	
+	eventType := "heartbeat"
+	if status == StoreStatusUnhealthy {
+		eventType = "unhealthy"
+	}
+	r.notifyWatchers(StoreEvent{
+		Type:  eventType,
+		Store: store,
+	})
+	
+	return nil
+}
+
 // Watch 监听Store变化
 func (r *InMemoryRegistry) Watch(ctx context.Context) (<-chan StoreEvent, error) {
 	r.mu.Lock()
@@ -198,6 +252,11 @@ func (r *InMemoryRegistry) Watch(ctx context.Context) (<-chan StoreEvent, error)
 
 // notifyWatchers 通知所有监听者
 func (r *InMemoryRegistry) notifyWatchers(event StoreEvent) {
+	// 发送副本，监听者读取时不与后续的状态更新竞争
+	if event.Store != nil {
+		store := *event.Store
+		event.Store = &store
+	}
 	for _, watcher := range r.watchers {
 		select {
 		case watcher <- event:
@@ -230,8 +289,8 @@ func (r *InMemoryRegistry) checkUnhealthyStores() {
 	now := time.Now()
 	for _, store := range r.stores {
 		// 如果超过60秒没有心跳，标记为不健康
-		if now.Sub(store.LastSeen) > 60*time.Second && store.Status == "active" {
-			store.Status = "unhealthy"
+		if now.Sub(store.LastSeen) > 60*time.Second && store.Status == StoreStatusActive {
+			store.Status = StoreStatusUnhealthy
 			
 			// 发送不健康事件
 			r.notifyWatchers(StoreEvent{
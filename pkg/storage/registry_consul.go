@@ -0,0 +1,621 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Consul中的键布局（位于Prefix之下）:
+//   {storeID} -> StoreInfo JSON，由注册该Store的进程的会话锁定
+// 会话的Behavior为delete，进程退出或停止续期超过TTL后会话失效，键随之删除。
+// 通过Consul HTTP API访问，不依赖Consul客户端库。
+
+const (
+	defaultConsulAddress        = "http://127.0.0.1:8500"
+	defaultConsulRegistryPrefix = "imy/stores/"
+	defaultConsulWatchWait      = 5 * time.Minute
+)
+
+// ConsulRegistryConfig Consul注册中心配置
+type ConsulRegistryConfig struct {
+	Address        string        `json:"address"`        // Consul HTTP地址，默认http://127.0.0.1:8500
+	Token          string        `json:"token"`          // ACL Token
+	Datacenter     string        `json:"datacenter"`     // 数据中心，为空时使用agent所在的数据中心
+	Prefix         string        `json:"prefix"`         // KV前缀
+	TTL            time.Duration `json:"ttl"`            // 会话TTL，默认10秒，Consul要求不小于10秒
+	RequestTimeout time.Duration `json:"requestTimeout"` // 单次请求超时
+}
+
+// ConsulRegistry Consul实现的Store注册中心，注册信息对所有节点可见
+type ConsulRegistry struct {
+	address        string
+	token          string
+	datacenter     string
+	prefix         string
+	ttl            time.Duration
+	requestTimeout time.Duration
+	watchWait      time.Duration // 阻塞查询的最长等待时间
+	httpClient     *http.Client
+
+	mu       sync.Mutex
+	sessions map[string]*consulRegistration // 本进程注册的Store
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// consulRegistration 本进程持有的注册及其会话
+type consulRegistration struct {
+	info      *StoreInfo
+	sessionID string
+	cancel    context.CancelFunc
+}
+
+// consulKVPair Consul KV接口返回的条目
+type consulKVPair struct {
+	Key         string `json:"Key"`
+	Value       []byte `json:"Value"` // base64，由encoding/json解码
+	CreateIndex uint64 `json:"CreateIndex"`
+	ModifyIndex uint64 `json:"ModifyIndex"`
+	Session     string `json:"Session"`
+}
+
+// NewConsulRegistry 创建Consul注册中心
+func NewConsulRegistry(config *ConsulRegistryConfig) *ConsulRegistry {
+	if config == nil {
+		config = &ConsulRegistryConfig{}
+	}
+	address := strings.TrimSuffix(config.Address, "/")
+	if address == "" {
+		address = defaultConsulAddress
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	prefix := strings.TrimPrefix(config.Prefix, "/")
+	if prefix == "" {
+		prefix = defaultConsulRegistryPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	ttl := config.TTL
+	if ttl < defaultRegistryTTL {
+		ttl = defaultRegistryTTL
+	}
+	requestTimeout := config.RequestTimeout
+	if requestTimeout <= 0 {
+		requestTimeout = defaultEtcdRequestTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ConsulRegistry{
+		address:        address,
+		token:          config.Token,
+		datacenter:     config.Datacenter,
+		prefix:         prefix,
+		ttl:            ttl,
+		requestTimeout: requestTimeout,
+		watchWait:      defaultConsulWatchWait,
+		httpClient:     &http.Client{},
+		sessions:       make(map[string]*consulRegistration),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Close 停止会话续期，已注册的Store在TTL后过期
+func (r *ConsulRegistry) Close() error {
+	r.cancel()
+	return nil
+}
+
+// Register 注册Store节点，重复注册时沿用已有会话只更新注册信息，排空中的Store保持排空
+func (r *ConsulRegistry) Register(ctx context.Context, info *StoreInfo) error {
+	var existing *StoreInfo
+	pair, err := r.getPair(ctx, info.ID)
+	switch {
+	case err == nil:
+		if existing, err = decodeConsulStore(pair); err != nil {
+			return err
+		}
+	case !errors.Is(err, errStoreNotFound):
+		return err
+	}
+	if info.Status, err = registrationStatus(existing); err != nil {
+		return err
+	}
+	info.LastSeen = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if reg, exists := r.sessions[info.ID]; exists {
+		if err := r.acquire(ctx, info, reg.sessionID); err != nil {
+			return err
+		}
+		reg.info = info
+		return nil
+	}
+
+	sessionID, err := r.createSession(ctx, info.ID)
+	if err != nil {
+		return err
+	}
+	if err := r.acquire(ctx, info, sessionID); err != nil {
+		r.destroySession(context.Background(), sessionID)
+		return err
+	}
+
+	renewCtx, renewCancel := context.WithCancel(r.ctx)
+	r.sessions[info.ID] = &consulRegistration{info: info, sessionID: sessionID, cancel: renewCancel}
+	go r.renew(renewCtx, info.ID)
+
+	return nil
+}
+
+// renew 按TTL的一半续期会话，会话失效后重新注册
+func (r *ConsulRegistry) renew(ctx context.Context, storeID string) {
+	ticker := time.NewTicker(r.ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.Lock()
+		reg, exists := r.sessions[storeID]
+		r.mu.Unlock()
+		if !exists {
+			return
+		}
+
+		resp, err := r.do(ctx, http.MethodPut, "/v1/session/renew/"+reg.sessionID, nil, nil)
+		if err != nil {
+			log.Printf("consul registry: failed to renew session of store %s: %v", storeID, err)
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			continue
+		}
+
+		log.Printf("consul registry: session of store %s expired, registering again", storeID)
+		if err := r.reregister(ctx, storeID); err != nil {
+			log.Printf("consul registry: failed to register store %s again: %v", storeID, err)
+		}
+	}
+}
+
+// reregister 创建新会话并重新写入注册信息
+func (r *ConsulRegistry) reregister(ctx context.Context, storeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, exists := r.sessions[storeID]
+	if !exists {
+		return fmt.Errorf("store %s is no longer registered", storeID)
+	}
+	sessionID, err := r.createSession(ctx, storeID)
+	if err != nil {
+		return err
+	}
+	reg.info.LastSeen = time.Now()
+	if err := r.acquire(ctx, reg.info, sessionID); err != nil {
+		r.destroySession(context.Background(), sessionID)
+		return err
+	}
+	reg.sessionID = sessionID
+	return nil
+}
+
+// Unregister 注销Store节点，本进程注册的Store销毁会话，其余直接删除
+func (r *ConsulRegistry) Unregister(ctx context.Context, storeID string) error {
+	r.mu.Lock()
+	reg, owned := r.sessions[storeID]
+	delete(r.sessions, storeID)
+	r.mu.Unlock()
+
+	if owned {
+		reg.cancel()
+		return r.destroySession(ctx, reg.sessionID)
+	}
+
+	if _, err := r.getPair(ctx, storeID); err != nil {
+		return err
+	}
+	resp, err := r.do(ctx, http.MethodDelete, "/v1/kv/"+r.storeKey(storeID), nil, nil)
+	if err != nil {
+		return err
+	}
+	return consulResult(resp, nil)
+}
+
+// GetStore 获取指定Store信息
+func (r *ConsulRegistry) GetStore(ctx context.Context, storeID string) (*StoreInfo, error) {
+	pair, err := r.getPair(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	return decodeConsulStore(pair)
+}
+
+// ListStores 获取所有Store列表
+func (r *ConsulRegistry) ListStores(ctx context.Context) ([]*StoreInfo, error) {
+	pairs, _, err := r.list(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	stores := make([]*StoreInfo, 0, len(pairs))
+	for _, pair := range pairs {
+		info, err := decodeConsulStore(pair)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, info)
+	}
+	return stores, nil
+}
+
+// ListActiveStores 获取活跃Store列表
+func (r *ConsulRegistry) ListActiveStores(ctx context.Context) ([]*StoreInfo, error) {
+	stores, err := r.ListStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*StoreInfo, 0, len(stores))
+	for _, store := range stores {
+		if store.Status == StoreStatusActive {
+			active = append(active, store)
+		}
+	}
+	return active, nil
+}
+
+// UpdateHeartbeat 更新心跳时间，会话由后台续期，这里只刷新LastSeen
+func (r *ConsulRegistry) UpdateHeartbeat(ctx context.Context, storeID string) error {
+	return r.update(ctx, storeID, func(info *StoreInfo) bool {
+		info.LastSeen = time.Now()
+		if info.Status == StoreStatusUnhealthy {
+			info.Status = StoreStatusActive
+		}
+		return true
+	})
+}
+
+// UpdateStatus 转换Store状态，不改变持有该键的会话
+func (r *ConsulRegistry) UpdateStatus(ctx context.Context, storeID string, status StoreStatus) error {
+	var transitionErr error
+	err := r.update(ctx, storeID, func(info *StoreInfo) bool {
+		if transitionErr = validateStoreTransition(storeID, info.Status, status); transitionErr != nil {
+			return false
+		}
+		if info.Status == status {
+			return false
+		}
+		info.Status = status
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return transitionErr
+}
+
+// update 以ModifyIndex做CAS写入，避免并发更新相互覆盖
+func (r *ConsulRegistry) update(ctx context.Context, storeID string, fn func(*StoreInfo) bool) error {
+	for i := 0; i < registryUpdateRetries; i++ {
+		pair, err := r.getPair(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		info, err := decodeConsulStore(pair)
+		if err != nil {
+			return err
+		}
+		if !fn(info) {
+			return nil
+		}
+
+		ok, err := r.put(ctx, info, url.Values{"cas": {strconv.FormatUint(pair.ModifyIndex, 10)}})
+		if err != nil {
+			return err
+		}
+		if ok {
+			r.mu.Lock()
+			if reg, owned := r.sessions[storeID]; owned {
+				reg.info = info
+			}
+			r.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("store %s was modified concurrently", storeID)
+}
+
+// Watch 通过阻塞查询监听Store变化，ctx取消后关闭通道
+func (r *ConsulRegistry) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	pairs, index, err := r.list(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	index = nextConsulIndex(0, index)
+
+	ch := make(chan StoreEvent, 100)
+	go func() {
+		defer close(ch)
+		known := consulPairMap(pairs)
+		for ctx.Err() == nil {
+			pairs, next, err := r.list(ctx, index)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("consul registry: watch failed: %v", err)
+					select {
+					case <-ctx.Done():
+					case <-time.After(time.Second):
+					}
+				}
+				continue
+			}
+			index = nextConsulIndex(index, next)
+
+			current := consulPairMap(pairs)
+			for _, event := range diffConsulPairs(known, current) {
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+			known = current
+		}
+	}()
+
+	return ch, nil
+}
+
+// nextConsulIndex 计算下一次阻塞查询的索引
+// 索引回退或为0时从1开始，避免阻塞查询退化为不断立即返回，见Consul阻塞查询文档
+func nextConsulIndex(index, next uint64) uint64 {
+	if next == 0 || next < index {
+		return 1
+	}
+	return next
+}
+
+// diffConsulPairs 比较两次查询结果得到Store事件
+func diffConsulPairs(known, current map[string]*consulKVPair) []StoreEvent {
+	events := make([]StoreEvent, 0)
+	for key, pair := range current {
+		old, exists := known[key]
+		if exists && old.ModifyIndex == pair.ModifyIndex {
+			continue
+		}
+		info, err := decodeConsulStore(pair)
+		if err != nil {
+			continue
+		}
+		var prev *StoreInfo
+		if exists {
+			prev, _ = decodeConsulStore(old)
+		}
+		// 会话失效后重新创建的键CreateIndex会变化，视为重新注册
+		created := !exists || old.CreateIndex != pair.CreateIndex
+		events = append(events, StoreEvent{Type: storeEventType(prev, info, created), Store: info})
+	}
+	for key, pair := range known {
+		if _, exists := current[key]; exists {
+			continue
+		}
+		if info, err := decodeConsulStore(pair); err == nil {
+			events = append(events, StoreEvent{Type: "unregister", Store: info})
+		}
+	}
+	return events
+}
+
+func consulPairMap(pairs []*consulKVPair) map[string]*consulKVPair {
+	m := make(map[string]*consulKVPair, len(pairs))
+	for _, pair := range pairs {
+		m[pair.Key] = pair
+	}
+	return m
+}
+
+func decodeConsulStore(pair *consulKVPair) (*StoreInfo, error) {
+	var info StoreInfo
+	if err := json.Unmarshal(pair.Value, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode store %s: %w", pair.Key, err)
+	}
+	return &info, nil
+}
+
+// createSession 创建会话，失效时删除其锁定的键
+func (r *ConsulRegistry) createSession(ctx context.Context, storeID string) (string, error) {
+	body, _ := json.Marshal(map[string]string{
+		"Name":      "imy-store-" + storeID,
+		"TTL":       r.ttl.String(),
+		"Behavior":  "delete",
+		"LockDelay": "0s",
+	})
+	resp, err := r.do(ctx, http.MethodPut, "/v1/session/create", nil, body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	var session struct {
+		ID string `json:"ID"`
+	}
+	if err := consulResult(resp, &session); err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	return session.ID, nil
+}
+
+func (r *ConsulRegistry) destroySession(ctx context.Context, sessionID string) error {
+	resp, err := r.do(ctx, http.MethodPut, "/v1/session/destroy/"+sessionID, nil, nil)
+	if err != nil {
+		return err
+	}
+	return consulResult(resp, nil)
+}
+
+// acquire 以会话锁定并写入注册信息
+// 键仍被上一个进程的会话持有（如重启时旧会话尚未过期）时先删除再锁定
+func (r *ConsulRegistry) acquire(ctx context.Context, info *StoreInfo, sessionID string) error {
+	query := url.Values{"acquire": {sessionID}}
+	ok, err := r.put(ctx, info, query)
+	if err == nil && !ok {
+		var resp *http.Response
+		if resp, err = r.do(ctx, http.MethodDelete, "/v1/kv/"+r.storeKey(info.ID), nil, nil); err == nil {
+			if err = consulResult(resp, nil); err == nil {
+				ok, err = r.put(ctx, info, query)
+			}
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to register store %s: %w", info.ID, err)
+	}
+	if !ok {
+		return fmt.Errorf("failed to register store %s: key is locked by another session", info.ID)
+	}
+	return nil
+}
+
+// put 写入注册信息，返回Consul对acquire或cas条件的判定结果
+func (r *ConsulRegistry) put(ctx context.Context, info *StoreInfo, query url.Values) (bool, error) {
+	data, err := json.Marshal(info)
+	if err != nil {
+		return false, err
+	}
+	resp, err := r.do(ctx, http.MethodPut, "/v1/kv/"+r.storeKey(info.ID), query, data)
+	if err != nil {
+		return false, err
+	}
+	var ok bool
+	if err := consulResult(resp, &ok); err != nil {
+		return false, err
+	}
+	return ok, nil
+}
+
+func (r *ConsulRegistry) getPair(ctx context.Context, storeID string) (*consulKVPair, error) {
+	resp, err := r.do(ctx, http.MethodGet, "/v1/kv/"+r.storeKey(storeID), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("store %s %w", storeID, errStoreNotFound)
+	}
+	var pairs []*consulKVPair
+	if err := consulResult(resp, &pairs); err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("store %s %w", storeID, errStoreNotFound)
+	}
+	return pairs[0], nil
+}
+
+// list 列出前缀下的所有条目，index大于0时为阻塞查询
+func (r *ConsulRegistry) list(ctx context.Context, index uint64) ([]*consulKVPair, uint64, error) {
+	query := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", r.watchWait.String())
+	}
+	resp, err := r.do(ctx, http.MethodGet, "/v1/kv/"+r.prefix, query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	next, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, next, nil
+	}
+	var pairs []*consulKVPair
+	if err := consulResult(resp, &pairs); err != nil {
+		return nil, 0, err
+	}
+	return pairs, next, nil
+}
+
+// do 发送请求，阻塞查询的超时在等待时间之上额外留出请求超时
+func (r *ConsulRegistry) do(ctx context.Context, method, path string, query url.Values, body []byte) (*http.Response, error) {
+	timeout := r.requestTimeout
+	if query.Get("index") != "" {
+		timeout += r.watchWait + r.watchWait/16 // Consul会在wait上附加最多1/16的随机抖动
+	}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+
+	if query == nil {
+		query = url.Values{}
+	}
+	if r.datacenter != "" {
+		query.Set("dc", r.datacenter)
+	}
+	target := r.address + (&url.URL{Path: path}).EscapedPath()
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+
+	req, err := http.NewRequestWithContext(reqCtx, method, target, bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if r.token != "" {
+		req.Header.Set("X-Consul-Token", r.token)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// consulResult 检查状态码并解码响应，out为nil时丢弃响应体
+func consulResult(resp *http.Response, out interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("consul returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// cancelOnClose 关闭响应体时释放请求的context
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+func (r *ConsulRegistry) storeKey(storeID string) string {
+	return r.prefix + url.PathEscape(storeID)
+}
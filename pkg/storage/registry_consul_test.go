@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul 实现注册中心用到的Consul会话与KV接口
+type fakeConsul struct {
+	mu       sync.Mutex
+	index    uint64
+	kv       map[string]*consulKVPair
+	sessions map[string]bool
+	changed  chan struct{}
+}
+
+func newFakeConsul() *fakeConsul {
+	return &fakeConsul{
+		index:    1,
+		kv:       make(map[string]*consulKVPair),
+		sessions: make(map[string]bool),
+		changed:  make(chan struct{}),
+	}
+}
+
+// bump 递增索引并唤醒阻塞查询，调用方需持有锁
+func (f *fakeConsul) bump() uint64 {
+	f.index++
+	close(f.changed)
+	f.changed = make(chan struct{})
+	return f.index
+}
+
+// expire 模拟会话失效，删除其锁定的键
+func (f *fakeConsul) expire(sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.expireLocked(sessionID)
+}
+
+func (f *fakeConsul) expireLocked(sessionID string) {
+	delete(f.sessions, sessionID)
+	for key, pair := range f.kv {
+		if pair.Session == sessionID {
+			delete(f.kv, key)
+		}
+	}
+	f.bump()
+}
+
+func (f *fakeConsul) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := r.URL.Path
+	query := r.URL.Query()
+	reply := func(v interface{}) {
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+		json.NewEncoder(w).Encode(v)
+	}
+
+	switch {
+	case path == "/v1/session/create":
+		id := fmt.Sprintf("session-%d", f.bump())
+		f.sessions[id] = true
+		reply(map[string]string{"ID": id})
+	case strings.HasPrefix(path, "/v1/session/renew/"):
+		if !f.sessions[strings.TrimPrefix(path, "/v1/session/renew/")] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply([]struct{}{})
+	case strings.HasPrefix(path, "/v1/session/destroy/"):
+		f.expireLocked(strings.TrimPrefix(path, "/v1/session/destroy/"))
+		reply(true)
+	case strings.HasPrefix(path, "/v1/kv/"):
+		f.serveKV(w, r, strings.TrimPrefix(path, "/v1/kv/"), query, reply)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakeConsul) serveKV(w http.ResponseWriter, r *http.Request, key string, query map[string][]string, reply func(interface{})) {
+	get := func(name string) string {
+		if v := query[name]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if index, _ := strconv.ParseUint(get("index"), 10, 64); index >= f.index {
+			changed := f.changed
+			f.mu.Unlock()
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			f.mu.Lock()
+		}
+		pairs := make([]*consulKVPair, 0)
+		for k, pair := range f.kv {
+			if k == key || (get("recurse") != "" && strings.HasPrefix(k, key)) {
+				pairs = append(pairs, pair)
+			}
+		}
+		sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+		if len(pairs) == 0 {
+			w.Header().Set("X-Consul-Index", strconv.FormatUint(f.index, 10))
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		reply(pairs)
+	case http.MethodPut:
+		value, _ := io.ReadAll(r.Body)
+		existing := f.kv[key]
+		if session := get("acquire"); session != "" {
+			if !f.sessions[session] || (existing != nil && existing.Session != "" && existing.Session != session) {
+				reply(false)
+				return
+			}
+		}
+		if cas := get("cas"); cas != "" {
+			index, _ := strconv.ParseUint(cas, 10, 64)
+			if existing == nil || existing.ModifyIndex != index {
+				reply(false)
+				return
+			}
+		}
+		pair := &consulKVPair{Key: key, Value: value}
+		if existing != nil {
+			pair.CreateIndex = existing.CreateIndex
+			pair.Session = existing.Session
+		}
+		if session := get("acquire"); session != "" {
+			pair.Session = session
+		}
+		pair.ModifyIndex = f.bump()
+		if pair.CreateIndex == 0 {
+			pair.CreateIndex = pair.ModifyIndex
+		}
+		f.kv[key] = pair
+		reply(true)
+	case http.MethodDelete:
+		delete(f.kv, key)
+		f.bump()
+		reply(true)
+	}
+}
+
+func TestConsulRegistry(t *testing.T) {
+	fake := newFakeConsul()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	registry := NewConsulRegistry(&ConsulRegistryConfig{Address: server.URL, Prefix: "test/stores"})
+	defer registry.Close()
+
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	defer cancelWatch()
+	events, err := registry.Watch(watchCtx)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+	next := func() StoreEvent {
+		t.Helper()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(3 * time.Second):
+			t.Fatal("Timed out waiting for event")
+			return StoreEvent{}
+		}
+	}
+
+	info := &StoreInfo{ID: "store_a", Address: "http://a", Metadata: map[string]interface{}{StoreMetadataRegion: "cn-east"}}
+	if err := registry.Register(ctx, info); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	if event := next(); event.Type != "register" || event.Store.ID != "store_a" {
+		t.Errorf("Expected register event, got %+v", event)
+	}
+
+	got, err := registry.GetStore(ctx, "store_a")
+	if err != nil || got.Status != "active" || got.Metadata[StoreMetadataRegion] != "cn-east" {
+		t.Fatalf("Unexpected store: %+v %v", got, err)
+	}
+
+	if err := registry.UpdateStatus(ctx, "store_a", StoreStatusUnhealthy); err != nil {
+		t.Fatalf("Failed to update status: %v", err)
+	}
+	if event := next(); event.Type != "unhealthy" {
+		t.Errorf("Expected unhealthy event, got %+v", event)
+	}
+	if active, _ := registry.ListActiveStores(ctx); len(active) != 0 {
+		t.Errorf("Expected no active stores, got %d", len(active))
+	}
+	if err := registry.UpdateHeartbeat(ctx, "store_a"); err != nil {
+		t.Fatalf("Failed to update heartbeat: %v", err)
+	}
+	if event := next(); event.Type != "heartbeat" || event.Store.Status != "active" {
+		t.Errorf("Expected heartbeat event restoring the store, got %+v", event)
+	}
+
+	// 状态更新不能丢失会话锁，否则会话失效时键不会被删除
+	fake.mu.Lock()
+	session := fake.kv["test/stores/store_a"].Session
+	fake.mu.Unlock()
+	if session == "" {
+		t.Fatal("Expected the store key to be locked by a session")
+	}
+
+	// 另一个进程以同一ID注册时接管旧会话留下的键
+	other := NewConsulRegistry(&ConsulRegistryConfig{Address: server.URL, Prefix: "test/stores"})
+	defer other.Close()
+	if err := other.Register(ctx, &StoreInfo{ID: "store_a", Address: "http://a2"}); err != nil {
+		t.Fatalf("Expected a new process to take over the registration: %v", err)
+	}
+	// 删除旧键与重新写入可能合并在同一次阻塞查询结果中
+	event := next()
+	if event.Type == "unregister" {
+		event = next()
+	}
+	if event.Type != "register" || event.Store.Address != "http://a2" {
+		t.Errorf("Expected register from the new process, got %+v", event)
+	}
+
+	if err := other.Unregister(ctx, "store_a"); err != nil {
+		t.Fatalf("Failed to unregister: %v", err)
+	}
+	if event := next(); event.Type != "unregister" {
+		t.Errorf("Expected unregister event, got %+v", event)
+	}
+	if _, err := registry.GetStore(ctx, "store_a"); err == nil {
+		t.Error("Expected store to be gone after unregister")
+	}
+	if err := registry.Unregister(ctx, "store_missing"); err == nil {
+		t.Error("Expected error unregistering unknown store")
+	}
+}
+
+func TestConsulRegistrySessionExpiry(t *testing.T) {
+	fake := newFakeConsul()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	ctx := context.Background()
+	registry := NewConsulRegistry(&ConsulRegistryConfig{Address: server.URL})
+	registry.ttl = 100 * time.Millisecond
+	defer registry.Close()
+
+	if err := registry.Register(ctx, &StoreInfo{ID: "store_a", Address: "http://a"}); err != nil {
+		t.Fatalf("Failed to register: %v", err)
+	}
+	registry.mu.Lock()
+	session := registry.sessions["store_a"].sessionID
+	registry.mu.Unlock()
+
+	// 会话失效后续期返回404，注册中心应以新会话重新注册
+	fake.expire(session)
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, err := registry.GetStore(ctx, "store_a"); err == nil && info.Address == "http://a" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("Expected store to be registered again after session expiry")
+}
@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcd中的键布局（位于Prefix之下）:
+//   {storeID} -> StoreInfo JSON，绑定注册该Store的进程持有的租约
+// 进程退出或与etcd失联超过TTL后租约过期，注册信息随之删除并产生unregister事件。
+
+const (
+	defaultEtcdRegistryPrefix = "/imy/stores/"
+	defaultRegistryTTL        = 10 * time.Second
+	registryUpdateRetries     = 3
+)
+
+// EtcdRegistryConfig etcd注册中心配置
+type EtcdRegistryConfig struct {
+	Endpoints      []string      `json:"endpoints"`      // etcd节点地址
+	Username       string        `json:"username"`       // 用户名
+	Password       string        `json:"password"`       // 密码
+	Prefix         string        `json:"prefix"`         // 键前缀
+	TTL            time.Duration `json:"ttl"`            // 注册租约的TTL，默认10秒
+	DialTimeout    time.Duration `json:"dialTimeout"`    // 连接超时
+	RequestTimeout time.Duration `json:"requestTimeout"` // 单次请求超时
+}
+
+// EtcdRegistry etcd实现的Store注册中心，注册信息对所有节点可见
+type EtcdRegistry struct {
+	client         *clientv3.Client
+	prefix         string
+	ttl            time.Duration
+	requestTimeout time.Duration
+	ownsClient     bool
+
+	mu     sync.Mutex
+	leases map[string]*etcdRegistration // 本进程注册的Store
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// etcdRegistration 本进程持有的注册及其租约
+type etcdRegistration struct {
+	info    *StoreInfo
+	leaseID clientv3.LeaseID
+	cancel  context.CancelFunc
+}
+
+// NewEtcdRegistry 连接etcd并创建注册中心
+func NewEtcdRegistry(config *EtcdRegistryConfig) (*EtcdRegistry, error) {
+	if config == nil || len(config.Endpoints) == 0 {
+		return nil, fmt.Errorf("etcd endpoints are required")
+	}
+
+	dialTimeout := config.DialTimeout
+	if dialTimeout <= 0 {
+		dialTimeout = defaultEtcdDialTimeout
+	}
+
+	client, err := clientv3.New(clientv3.Config{
+		Endpoints:   config.Endpoints,
+		Username:    config.Username,
+		Password:    config.Password,
+		DialTimeout: dialTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect etcd: %w", err)
+	}
+
+	registry := NewEtcdRegistryWithClient(client, config.Prefix, config.TTL)
+	if config.RequestTimeout > 0 {
+		registry.requestTimeout = config.RequestTimeout
+	}
+	registry.ownsClient = true
+	return registry, nil
+}
+
+// NewEtcdRegistryWithClient 使用已有的etcd客户端创建注册中心，Close时不会关闭该客户端
+func NewEtcdRegistryWithClient(client *clientv3.Client, prefix string, ttl time.Duration) *EtcdRegistry {
+	if prefix == "" {
+		prefix = defaultEtcdRegistryPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if ttl < time.Second {
+		ttl = defaultRegistryTTL
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &EtcdRegistry{
+		client:         client,
+		prefix:         prefix,
+		ttl:            ttl,
+		requestTimeout: defaultEtcdRequestTimeout,
+		leases:         make(map[string]*etcdRegistration),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// Client 注册中心使用的etcd客户端，供协调者选举等共用
+func (r *EtcdRegistry) Client() *clientv3.Client {
+	return r.client
+}
+
+// Close 停止续约并关闭etcd连接，已注册的Store在TTL后过期
+func (r *EtcdRegistry) Close() error {
+	r.cancel()
+	if r.ownsClient {
+		return r.client.Close()
+	}
+	return nil
+}
+
+// Register 注册Store节点，重复注册时沿用已有租约只更新注册信息，排空中的Store保持排空
+func (r *EtcdRegistry) Register(ctx context.Context, info *StoreInfo) error {
+	existing, _, err := r.getStore(ctx, info.ID)
+	if err != nil && !errors.Is(err, errStoreNotFound) {
+		return err
+	}
+	if info.Status, err = registrationStatus(existing); err != nil {
+		return err
+	}
+	info.LastSeen = time.Now()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reqCtx, cancel := r.requestContext(ctx)
+	defer cancel()
+
+	if reg, exists := r.leases[info.ID]; exists {
+		if _, err := r.client.Put(reqCtx, r.storeKey(info.ID), string(data), clientv3.WithLease(reg.leaseID)); err != nil {
+			return fmt.Errorf("failed to update store %s: %w", info.ID, err)
+		}
+		reg.info = info
+		return nil
+	}
+
+	lease, err := r.client.Grant(reqCtx, int64(r.ttl/time.Second))
+	if err != nil {
+		return fmt.Errorf("failed to grant lease: %w", err)
+	}
+	if _, err := r.client.Put(reqCtx, r.storeKey(info.ID), string(data), clientv3.WithLease(lease.ID)); err != nil {
+		return fmt.Errorf("failed to register store %s: %w", info.ID, err)
+	}
+
+	keepCtx, keepCancel := context.WithCancel(r.ctx)
+	reg := &etcdRegistration{info: info, leaseID: lease.ID, cancel: keepCancel}
+	r.leases[info.ID] = reg
+	go r.keepAlive(keepCtx, info.ID, lease.ID)
+
+	return nil
+}
+
+// keepAlive 持续续约，租约丢失（如长时间失联）后重新注册
+func (r *EtcdRegistry) keepAlive(ctx context.Context, storeID string, leaseID clientv3.LeaseID) {
+	for {
+		responses, err := r.client.KeepAlive(ctx, leaseID)
+		if err == nil {
+			for range responses {
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("etcd registry: lease of store %s lost, registering again", storeID)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.ttl / 3):
+		}
+
+		newLease, err := r.regrant(ctx, storeID)
+		if err != nil {
+			log.Printf("etcd registry: failed to register store %s again: %v", storeID, err)
+			continue
+		}
+		leaseID = newLease
+	}
+}
+
+// regrant 申请新租约并重新写入注册信息
+func (r *EtcdRegistry) regrant(ctx context.Context, storeID string) (clientv3.LeaseID, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reg, exists := r.leases[storeID]
+	if !exists {
+		return 0, fmt.Errorf("store %s is no longer registered", storeID)
+	}
+	reqCtx, cancel := r.requestContext(ctx)
+	defer cancel()
+
+	lease, err := r.client.Grant(reqCtx, int64(r.ttl/time.Second))
+	if err != nil {
+		return 0, err
+	}
+	reg.info.LastSeen = time.Now()
+	data, err := json.Marshal(reg.info)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.client.Put(reqCtx, r.storeKey(storeID), string(data), clientv3.WithLease(lease.ID)); err != nil {
+		return 0, err
+	}
+	reg.leaseID = lease.ID
+	return lease.ID, nil
+}
+
+// Unregister 注销Store节点，本进程注册的Store撤销租约，其余直接删除
+func (r *EtcdRegistry) Unregister(ctx context.Context, storeID string) error {
+	r.mu.Lock()
+	reg, owned := r.leases[storeID]
+	delete(r.leases, storeID)
+	r.mu.Unlock()
+
+	reqCtx, cancel := r.requestContext(ctx)
+	defer cancel()
+
+	if owned {
+		reg.cancel()
+		if _, err := r.client.Revoke(reqCtx, reg.leaseID); err != nil {
+			return fmt.Errorf("failed to revoke lease of store %s: %w", storeID, err)
+		}
+		return nil
+	}
+
+	resp, err := r.client.Delete(reqCtx, r.storeKey(storeID))
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return fmt.Errorf("store %s not found", storeID)
+	}
+	return nil
+}
+
+// GetStore 获取指定Store信息
+func (r *EtcdRegistry) GetStore(ctx context.Context, storeID string) (*StoreInfo, error) {
+	info, _, err := r.getStore(ctx, storeID)
+	return info, err
+}
+
+// ListStores 获取所有Store列表
+func (r *EtcdRegistry) ListStores(ctx context.Context) ([]*StoreInfo, error) {
+	reqCtx, cancel := r.requestContext(ctx)
+	defer cancel()
+
+	resp, err := r.client.Get(reqCtx, r.prefix, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+
+	stores := make([]*StoreInfo, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		var info StoreInfo
+		if err := json.Unmarshal(kv.Value, &info); err != nil {
+			return nil, fmt.Errorf("failed to decode store %s: %w", kv.Key, err)
+		}
+		stores = append(stores, &info)
+	}
+	return stores, nil
+}
+
+// ListActiveStores 获取活跃Store列表
+func (r *EtcdRegistry) ListActiveStores(ctx context.Context) ([]*StoreInfo, error) {
+	stores, err := r.ListStores(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active := make([]*StoreInfo, 0, len(stores))
+	for _, store := range stores {
+		if store.Status == StoreStatusActive {
+			active = append(active, store)
+		}
+	}
+	return active, nil
+}
+
+// UpdateHeartbeat 更新心跳时间，租约由后台续约，这里只刷新LastSeen
+func (r *EtcdRegistry) UpdateHeartbeat(ctx context.Context, storeID string) error {
+	return r.update(ctx, storeID, func(info *StoreInfo) bool {
+		info.LastSeen = time.Now()
+		if info.Status == StoreStatusUnhealthy {
+			info.Status = StoreStatusActive
+		}
+		return true
+	})
+}
+
+// UpdateStatus 转换Store状态，不改变注册信息绑定的租约
+func (r *EtcdRegistry) UpdateStatus(ctx context.Context, storeID string, status StoreStatus) error {
+	var transitionErr error
+	err := r.update(ctx, storeID, func(info *StoreInfo) bool {
+		if transitionErr = validateStoreTransition(storeID, info.Status, status); transitionErr != nil {
+			return false
+		}
+		if info.Status == status {
+			return false
+		}
+		info.Status = status
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return transitionErr
+}
+
+// update 以ModRevision做比较写入，避免并发更新相互覆盖
+func (r *EtcdRegistry) update(ctx context.Context, storeID string, fn func(*StoreInfo) bool) error {
+	for i := 0; i < registryUpdateRetries; i++ {
+		info, revision, err := r.getStore(ctx, storeID)
+		if err != nil {
+			return err
+		}
+		if !fn(info) {
+			return nil
+		}
+		data, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+
+		reqCtx, cancel := r.requestContext(ctx)
+		key := r.storeKey(storeID)
+		resp, err := r.client.Txn(reqCtx).
+			If(clientv3.Compare(clientv3.ModRevision(key), "=", revision)).
+			Then(clientv3.OpPut(key, string(data), clientv3.WithIgnoreLease())).
+			Commit()
+		cancel()
+		if err != nil {
+			return err
+		}
+		if resp.Succeeded {
+			r.mu.Lock()
+			if reg, owned := r.leases[storeID]; owned {
+				reg.info = info
+			}
+			r.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("store %s was modified concurrently", storeID)
+}
+
+// Watch 监听Store变化，ctx取消后关闭通道
+func (r *EtcdRegistry) Watch(ctx context.Context) (<-chan StoreEvent, error) {
+	ch := make(chan StoreEvent, 100)
+	watchCh := r.client.Watch(clientv3.WithRequireLeader(ctx), r.prefix,
+		clientv3.WithPrefix(), clientv3.WithPrevKV())
+
+	go func() {
+		defer close(ch)
+		for resp := range watchCh {
+			if resp.Err() != nil {
+				continue
+			}
+			for _, event := range r.translateEvents(resp.Events) {
+				select {
+				case ch <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// translateEvents 将etcd事件转换为Store事件
+func (r *EtcdRegistry) translateEvents(events []*clientv3.Event) []StoreEvent {
+	result := make([]StoreEvent, 0, len(events))
+	for _, ev := range events {
+		storeID, err := url.PathUnescape(strings.TrimPrefix(string(ev.Kv.Key), r.prefix))
+		if err != nil {
+			continue
+		}
+
+		if ev.Type == clientv3.EventTypeDelete {
+			info := &StoreInfo{ID: storeID}
+			if ev.PrevKv != nil {
+				json.Unmarshal(ev.PrevKv.Value, info)
+			}
+			result = append(result, StoreEvent{Type: "unregister", Store: info})
+			continue
+		}
+
+		var info StoreInfo
+		if err := json.Unmarshal(ev.Kv.Value, &info); err != nil {
+			continue
+		}
+		var prev *StoreInfo
+		if ev.PrevKv != nil && ev.Kv.CreateRevision != ev.Kv.ModRevision {
+			prev = &StoreInfo{}
+			if json.Unmarshal(ev.PrevKv.Value, prev) != nil {
+				prev = nil
+			}
+		}
+		result = append(result, StoreEvent{Type: storeEventType(prev, &info, ev.IsCreate()), Store: &info})
+	}
+	return result
+}
+
+// getStore 读取Store信息及其ModRevision
+func (r *EtcdRegistry) getStore(ctx context.Context, storeID string) (*StoreInfo, int64, error) {
+	reqCtx, cancel := r.requestContext(ctx)
+	defer cancel()
+
+	resp, err := r.client.Get(reqCtx, r.storeKey(storeID))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, fmt.Errorf("store %s %w", storeID, errStoreNotFound)
+	}
+	var info StoreInfo
+	if err := json.Unmarshal(resp.Kvs[0].Value, &info); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode store %s: %w", storeID, err)
+	}
+	return &info, resp.Kvs[0].ModRevision, nil
+}
+
+// requestContext 为单次请求附加超时
+func (r *EtcdRegistry) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, r.requestTimeout)
+}
+
+func (r *EtcdRegistry) storeKey(storeID string) string {
+	return r.prefix + url.PathEscape(storeID)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"encoding/json"
+	"testing"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+func TestEtcdRegistryTranslateEvents(t *testing.T) {
+	registry := NewEtcdRegistryWithClient(nil, "/test", 0)
+	defer registry.Close()
+
+	if got := registry.storeKey("store/1"); got != "/test/store%2F1" {
+		t.Errorf("Unexpected store key: %s", got)
+	}
+
+	value := func(status StoreStatus) []byte {
+		data, _ := json.Marshal(&StoreInfo{ID: "store/1", Address: "http://a", Status: status})
+		return data
+	}
+	key := []byte(registry.storeKey("store/1"))
+
+	events := registry.translateEvents([]*clientv3.Event{
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: key, Value: value("active"), CreateRevision: 2, ModRevision: 2}},
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: key, Value: value("active"), CreateRevision: 2, ModRevision: 3},
+			PrevKv: &mvccpb.KeyValue{Key: key, Value: value("active")}},
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: key, Value: value("unhealthy"), CreateRevision: 2, ModRevision: 4},
+			PrevKv: &mvccpb.KeyValue{Key: key, Value: value("active")}},
+		{Type: clientv3.EventTypePut, Kv: &mvccpb.KeyValue{Key: key, Value: value("unhealthy"), CreateRevision: 2, ModRevision: 5},
+			PrevKv: &mvccpb.KeyValue{Key: key, Value: value("unhealthy")}},
+		{Type: clientv3.EventTypeDelete, Kv: &mvccpb.KeyValue{Key: key, ModRevision: 6}},
+	})
+
+	expected := []string{"register", "heartbeat", "unhealthy", "heartbeat", "unregister"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(events))
+	}
+	for i, event := range events {
+		if event.Type != expected[i] {
+			t.Errorf("Event %d: expected %s, got %s", i, expected[i], event.Type)
+		}
+		if event.Store == nil || event.Store.ID != "store/1" {
+			t.Errorf("Event %d: unexpected store %+v", i, event.Store)
+		}
+	}
+}
@@ -0,0 +1,611 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplicationMode 副本写入模式
+type ReplicationMode string
+
+const (
+	ReplicationSync  ReplicationMode = "sync"  // 等待所有副本写入成功后返回
+	ReplicationAsync ReplicationMode = "async" // 主Store写入成功即返回，副本后台写入
+)
+
+const (
+	defaultReplicationQueueSize = 1024
+	defaultReplicationWorkers   = 4
+	corruptionRecoveryInterval  = time.Minute
+)
+
+// ReplicaStatus 单个副本的复制状态
+type ReplicaStatus struct {
+	TimelineKey    string    `json:"timeline_key"`
+	StoreID        string    `json:"store_id"`
+	Replicated     int64     `json:"replicated"`      // 已成功复制的消息数
+	Pending        int64     `json:"pending"`         // 尚未复制成功的消息数，即副本落后量
+	Failed         int64     `json:"failed"`          // 复制失败的消息数
+	LastReplicated time.Time `json:"last_replicated"` // 最后一次复制成功的时间
+	LastError      string    `json:"last_error,omitempty"`
+	AppliedHLC     int64     `json:"applied_hlc"` // 已复制成功的最大HLC
+
+	pendingHLCs map[int64]int // 尚未复制成功的消息HLC及条数
+}
+
+// replicationTask 异步复制任务
+type replicationTask struct {
+	timelineKey string
+	storeID     string
+	message     *Message
+	userIDs     []string
+}
+
+// ReplicationManager 将Timeline的写入扇出到副本Store
+// 副本由路由器的GetTimelineReplicas决定，副本数为ShardPolicy.ReplicationFactor-1；
+// 主Store不健康时将落后最少的健康副本提升为主Store
+type ReplicationManager struct {
+	mu            sync.RWMutex
+	localStore    *Store
+	router        TimelineRouter
+	storeRegistry StoreRegistry
+	globalIndex   GlobalIndexManager
+	rpcClientPool *StoreRPCClientPool
+	policy        *ShardPolicy
+
+	primaries map[string]string                    // TimelineKey -> 主Store
+	replicas  map[string]map[string]*ReplicaStatus // TimelineKey -> StoreID -> 状态
+
+	queue   chan *replicationTask
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+	running bool
+}
+
+// NewReplicationManager 创建副本管理器
+func NewReplicationManager(
+	localStore *Store,
+	router TimelineRouter,
+	storeRegistry StoreRegistry,
+	globalIndex GlobalIndexManager,
+	rpcClientPool *StoreRPCClientPool,
+	policy *ShardPolicy,
+) *ReplicationManager {
+	if policy == nil {
+		policy = DefaultShardPolicy()
+	}
+	return &ReplicationManager{
+		localStore:    localStore,
+		router:        router,
+		storeRegistry: storeRegistry,
+		globalIndex:   globalIndex,
+		rpcClientPool: rpcClientPool,
+		policy:        policy,
+		primaries:     make(map[string]string),
+		replicas:      make(map[string]map[string]*ReplicaStatus),
+		queue:         make(chan *replicationTask, defaultReplicationQueueSize),
+	}
+}
+
+// Start 启动异步复制协程，并监听Store健康状态以便自动提升副本
+func (rm *ReplicationManager) Start(ctx context.Context) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.running {
+		return fmt.Errorf("replication manager is already running")
+	}
+
+	rm.stopCh = make(chan struct{})
+	rm.running = true
+
+	for i := 0; i < defaultReplicationWorkers; i++ {
+		rm.wg.Add(1)
+		go rm.replicationWorker(ctx, rm.stopCh)
+	}
+	rm.wg.Add(1)
+	go rm.corruptionRecoveryLoop(ctx, rm.stopCh)
+
+	if rm.storeRegistry != nil {
+		events, err := rm.storeRegistry.Watch(ctx)
+		if err != nil {
+			log.Printf("replication: failed to watch store registry: %v", err)
+		} else {
+			rm.wg.Add(1)
+			go rm.watchStores(ctx, events, rm.stopCh)
+		}
+	}
+
+	return nil
+}
+
+// Stop 停止复制，队列中尚未处理的任务计为失败
+func (rm *ReplicationManager) Stop() error {
+	rm.mu.Lock()
+	if !rm.running {
+		rm.mu.Unlock()
+		return fmt.Errorf("replication manager is not running")
+	}
+	close(rm.stopCh)
+	rm.running = false
+	rm.mu.Unlock()
+
+	rm.wg.Wait()
+
+	for {
+		select {
+		case task := <-rm.queue:
+			rm.recordResult(task.timelineKey, task.storeID, task.message.HLC, fmt.Errorf("replication stopped"))
+		default:
+			return nil
+		}
+	}
+}
+
+// Replicate 将主Store上已写入的消息复制到副本
+// 同步模式下任一副本失败即返回错误；异步模式下只负责入队
+func (rm *ReplicationManager) Replicate(ctx context.Context, primaryStoreID, timelineKey string, message *Message, userIDs []string) error {
+	targets, err := rm.replicaTargets(timelineKey, primaryStoreID)
+	if err != nil {
+		return err
+	}
+
+	rm.mu.Lock()
+	rm.primaries[timelineKey] = primaryStoreID
+	for _, storeID := range targets {
+		status := rm.replicaStatusLocked(timelineKey, storeID)
+		status.Pending++
+		status.pendingHLCs[message.HLC]++
+	}
+	mode := rm.policy.ReplicationMode
+	rm.mu.Unlock()
+
+	if len(targets) == 0 {
+		return nil
+	}
+
+	if mode == ReplicationSync {
+		return rm.replicateSync(ctx, timelineKey, targets, message, userIDs)
+	}
+
+	for _, storeID := range targets {
+		task := &replicationTask{
+			timelineKey: timelineKey,
+			storeID:     storeID,
+			message:     message,
+			userIDs:     userIDs,
+		}
+		select {
+		case rm.queue <- task:
+		default:
+			// 队列已满时丢弃，副本落后量保留在状态中
+			rm.recordResult(timelineKey, storeID, message.HLC, fmt.Errorf("replication queue is full"))
+		}
+	}
+
+	return nil
+}
+
+// replicateSync 并发写入所有副本并等待结果
+func (rm *ReplicationManager) replicateSync(ctx context.Context, timelineKey string, targets []string, message *Message, userIDs []string) error {
+	errs := make([]error, len(targets))
+	var wg sync.WaitGroup
+	for i, storeID := range targets {
+		wg.Add(1)
+		go func(i int, storeID string) {
+			defer wg.Done()
+			errs[i] = rm.sendToReplica(ctx, timelineKey, storeID, message, userIDs)
+		}(i, storeID)
+	}
+	wg.Wait()
+
+	failed := 0
+	var firstErr error
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to replicate %s to %d/%d replicas: %w", timelineKey, failed, len(targets), firstErr)
+	}
+	return nil
+}
+
+// replicationWorker 异步复制协程
+func (rm *ReplicationManager) replicationWorker(ctx context.Context, stopCh chan struct{}) {
+	defer rm.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case task := <-rm.queue:
+			rm.sendToReplica(ctx, task.timelineKey, task.storeID, task.message, task.userIDs)
+		}
+	}
+}
+
+// sendToReplica 写入单个副本并记录结果
+func (rm *ReplicationManager) sendToReplica(ctx context.Context, timelineKey, storeID string, message *Message, userIDs []string) error {
+	var err error
+	if rm.localStore != nil && storeID == rm.localStore.StoreID {
+		_, _, err = rm.localStore.ReplicateMessage(timelineKey, message, userIDs)
+	} else {
+		err = rm.sendToRemoteReplica(ctx, storeID, timelineKey, message, userIDs)
+	}
+
+	rm.recordResult(timelineKey, storeID, message.HLC, err)
+	return err
+}
+
+func (rm *ReplicationManager) sendToRemoteReplica(ctx context.Context, storeID, timelineKey string, message *Message, userIDs []string) error {
+	if rm.rpcClientPool == nil || rm.storeRegistry == nil {
+		return fmt.Errorf("remote replication is not configured")
+	}
+
+	info, err := rm.storeRegistry.GetStore(ctx, storeID)
+	if err != nil {
+		return fmt.Errorf("failed to lookup store %s: %w", storeID, err)
+	}
+
+	client, err := rm.rpcClientPool.GetClient(ctx, storeID, info.Address)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.AddMessage(ctx, &AddMessageRequest{
+		TimelineKey: timelineKey,
+		Message:     message,
+		UserIDs:     userIDs,
+	})
+	if err != nil {
+		rm.rpcClientPool.RemoveClient(storeID)
+		return fmt.Errorf("failed to replicate to store %s: %w", storeID, err)
+	}
+
+	return nil
+}
+
+// recordResult 更新副本复制状态，hlc为这次复制的消息的HLC
+func (rm *ReplicationManager) recordResult(timelineKey, storeID string, hlc int64, err error) {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	status := rm.replicaStatusLocked(timelineKey, storeID)
+	if err != nil {
+		status.Failed++
+		status.LastError = err.Error()
+		return
+	}
+
+	status.Replicated++
+	if status.Pending > 0 {
+		status.Pending--
+	}
+	if status.pendingHLCs[hlc] > 1 {
+		status.pendingHLCs[hlc]--
+	} else {
+		delete(status.pendingHLCs, hlc)
+	}
+	if hlc > status.AppliedHLC {
+		status.AppliedHLC = hlc
+	}
+	status.LastReplicated = time.Now()
+	status.LastError = ""
+}
+
+func (rm *ReplicationManager) replicaStatusLocked(timelineKey, storeID string) *ReplicaStatus {
+	statuses, exists := rm.replicas[timelineKey]
+	if !exists {
+		statuses = make(map[string]*ReplicaStatus)
+		rm.replicas[timelineKey] = statuses
+	}
+	status, exists := statuses[storeID]
+	if !exists {
+		status = &ReplicaStatus{TimelineKey: timelineKey, StoreID: storeID, pendingHLCs: make(map[int64]int)}
+		statuses[storeID] = status
+	}
+	return status
+}
+
+// replicaCaughtUp 副本是否已复制HLC不超过hlc的全部消息
+// 异步复制可能乱序完成，只看最大HLC不够，还要求没有更早的消息在等待或复制失败
+func (rm *ReplicationManager) replicaCaughtUp(timelineKey, storeID string, hlc int64) bool {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	status, exists := rm.replicas[timelineKey][storeID]
+	if !exists || status.AppliedHLC < hlc {
+		return false
+	}
+	for pending := range status.pendingHLCs {
+		if pending <= hlc {
+			return false
+		}
+	}
+	return true
+}
+
+// replicaTargets 计算需要写入的副本Store，不包含主Store
+func (rm *ReplicationManager) replicaTargets(timelineKey, primaryStoreID string) ([]string, error) {
+	rm.mu.RLock()
+	count := rm.policy.ReplicationFactor - 1
+	rm.mu.RUnlock()
+
+	if count <= 0 || rm.router == nil {
+		return nil, nil
+	}
+
+	candidates, err := rm.router.GetTimelineReplicas(timelineKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get replicas for %s: %w", timelineKey, err)
+	}
+
+	targets := make([]string, 0, count)
+	for _, storeID := range candidates {
+		if storeID == primaryStoreID {
+			continue
+		}
+		targets = append(targets, storeID)
+		if len(targets) == count {
+			break
+		}
+	}
+	return targets, nil
+}
+
+// GetReplicaStatus 获取Timeline所有副本的复制状态
+func (rm *ReplicationManager) GetReplicaStatus(timelineKey string) []*ReplicaStatus {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+
+	statuses := make([]*ReplicaStatus, 0, len(rm.replicas[timelineKey]))
+	for _, status := range rm.replicas[timelineKey] {
+		copied := *status
+		copied.pendingHLCs = nil
+		statuses = append(statuses, &copied)
+	}
+	return statuses
+}
+
+// GetPrimary 获取Timeline当前的主Store
+func (rm *ReplicationManager) GetPrimary(timelineKey string) (string, bool) {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	storeID, exists := rm.primaries[timelineKey]
+	return storeID, exists
+}
+
+// UpdatePolicy 更新副本因子与写入模式
+func (rm *ReplicationManager) UpdatePolicy(policy *ShardPolicy) {
+	if policy == nil {
+		return
+	}
+	rm.mu.Lock()
+	rm.policy = policy
+	rm.mu.Unlock()
+}
+
+// isStoreHealthy 通过注册中心判断Store是否健康，排空中的Store仍在服务，未配置注册中心时视为健康
+func (rm *ReplicationManager) isStoreHealthy(ctx context.Context, storeID string) bool {
+	if rm.storeRegistry == nil {
+		return true
+	}
+	info, err := rm.storeRegistry.GetStore(ctx, storeID)
+	if err != nil {
+		return false
+	}
+	return info.Status.Serving()
+}
+
+// EnsurePrimary 检查主Store健康状态，不健康时提升副本并返回新的主Store
+func (rm *ReplicationManager) EnsurePrimary(ctx context.Context, timelineKey, primaryStoreID string) (string, error) {
+	if rm.isStoreHealthy(ctx, primaryStoreID) {
+		return primaryStoreID, nil
+	}
+	return rm.PromoteReplica(ctx, timelineKey, primaryStoreID)
+}
+
+// PromoteReplica 将落后最少的健康副本提升为主Store，并把全局索引中的块迁移到新主Store
+func (rm *ReplicationManager) PromoteReplica(ctx context.Context, timelineKey, failedStoreID string) (string, error) {
+	rm.mu.RLock()
+	if current, exists := rm.primaries[timelineKey]; exists && current != failedStoreID {
+		// 已被其他调用提升
+		rm.mu.RUnlock()
+		return current, nil
+	}
+	candidates := make([]*ReplicaStatus, 0, len(rm.replicas[timelineKey]))
+	for storeID, status := range rm.replicas[timelineKey] {
+		if storeID != failedStoreID {
+			copied := *status
+			candidates = append(candidates, &copied)
+		}
+	}
+	rm.mu.RUnlock()
+
+	var best *ReplicaStatus
+	for _, candidate := range candidates {
+		if !rm.isStoreHealthy(ctx, candidate.StoreID) {
+			continue
+		}
+		if best == nil || candidate.Pending < best.Pending ||
+			(candidate.Pending == best.Pending && candidate.LastReplicated.After(best.LastReplicated)) {
+			best = candidate
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("no healthy replica available for %s", timelineKey)
+	}
+
+	if rm.globalIndex != nil {
+		if err := rm.globalIndex.MigrateTimeline(ctx, timelineKey, failedStoreID, best.StoreID); err != nil {
+			return "", fmt.Errorf("failed to promote replica %s for %s: %w", best.StoreID, timelineKey, err)
+		}
+	}
+
+	rm.mu.Lock()
+	rm.primaries[timelineKey] = best.StoreID
+	// 新主Store不再作为副本，原主Store恢复后按副本重新追赶
+	delete(rm.replicas[timelineKey], best.StoreID)
+	rm.mu.Unlock()
+
+	if best.Pending > 0 {
+		log.Printf("replication: promoted %s for %s with %d pending messages", best.StoreID, timelineKey, best.Pending)
+	}
+
+	return best.StoreID, nil
+}
+
+// RecoverCorruptBlocks 从副本恢复本地Store中损坏的会话块，返回恢复的块数
+// 依次尝试Timeline的每个副本，副本缺少消息或SeqID与本地不一致时换下一个副本
+func (rm *ReplicationManager) RecoverCorruptBlocks(ctx context.Context) (int, error) {
+	recovered := 0
+	var errs []error
+	for _, corrupt := range rm.localStore.CorruptBlocks() {
+		convID, ok := strings.CutPrefix(corrupt.TimelineKey, "conv_")
+		if !ok {
+			continue
+		}
+		if err := rm.restoreBlock(ctx, convID, corrupt.BlockID); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		recovered++
+	}
+	return recovered, errors.Join(errs...)
+}
+
+func (rm *ReplicationManager) restoreBlock(ctx context.Context, convID, blockID string) error {
+	if rm.router == nil {
+		return fmt.Errorf("no router to locate replicas of %s", convID)
+	}
+	candidates, err := rm.router.GetTimelineReplicas(convID)
+	if err != nil {
+		return fmt.Errorf("failed to get replicas for %s: %w", convID, err)
+	}
+
+	var errs []error
+	for _, storeID := range candidates {
+		if storeID == rm.localStore.StoreID {
+			continue
+		}
+		err := rm.localStore.RestoreCorruptBlock(blockID, func(convID string, afterSeqID, beforeSeqID int64) ([]*Message, error) {
+			return rm.fetchFromReplica(ctx, storeID, convID, afterSeqID, beforeSeqID)
+		})
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("store %s: %w", storeID, err))
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no replica of %s to restore block %s", convID, blockID)
+	}
+	return fmt.Errorf("failed to restore block %s: %w", blockID, errors.Join(errs...))
+}
+
+// fetchFromReplica 读取副本上会话SeqID位于(afterSeqID, beforeSeqID)区间的消息
+func (rm *ReplicationManager) fetchFromReplica(ctx context.Context, storeID, convID string, afterSeqID, beforeSeqID int64) ([]*Message, error) {
+	if rm.rpcClientPool == nil || rm.storeRegistry == nil {
+		return nil, fmt.Errorf("remote replication is not configured")
+	}
+
+	info, err := rm.storeRegistry.GetStore(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup store %s: %w", storeID, err)
+	}
+
+	client, err := rm.rpcClientPool.GetClient(ctx, storeID, info.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.GetMessages(ctx, &GetMessagesRequest{
+		TimelineKey: convID,
+		AfterSeqID:  afterSeqID,
+		BeforeSeqID: beforeSeqID,
+	})
+	if err != nil {
+		rm.rpcClientPool.RemoveClient(storeID)
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// corruptionRecoveryLoop 启动时及之后定期尝试从副本恢复损坏的块
+func (rm *ReplicationManager) corruptionRecoveryLoop(ctx context.Context, stopCh chan struct{}) {
+	defer rm.wg.Done()
+
+	ticker := time.NewTicker(corruptionRecoveryInterval)
+	defer ticker.Stop()
+
+	for {
+		if len(rm.localStore.CorruptBlocks()) > 0 {
+			recovered, err := rm.RecoverCorruptBlocks(ctx)
+			if recovered > 0 {
+				log.Printf("replication: restored %d corrupt blocks from replicas", recovered)
+			}
+			if err != nil {
+				log.Printf("replication: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// watchStores 监听Store事件，主Store不健康或注销时提升其上所有Timeline的副本
+func (rm *ReplicationManager) watchStores(ctx context.Context, events <-chan StoreEvent, stopCh chan struct{}) {
+	defer rm.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if event.Store == nil || (event.Type != "unhealthy" && event.Type != "unregister") {
+				continue
+			}
+			rm.handleStoreFailure(ctx, event.Store.ID)
+		}
+	}
+}
+
+// handleStoreFailure 处理Store故障
+func (rm *ReplicationManager) handleStoreFailure(ctx context.Context, storeID string) {
+	rm.mu.RLock()
+	timelineKeys := make([]string, 0)
+	for timelineKey, primary := range rm.primaries {
+		if primary == storeID {
+			timelineKeys = append(timelineKeys, timelineKey)
+		}
+	}
+	rm.mu.RUnlock()
+
+	for _, timelineKey := range timelineKeys {
+		if _, err := rm.PromoteReplica(ctx, timelineKey, storeID); err != nil {
+			log.Printf("replication: %v", err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReplicationManagerReplicateAndPromote(t *testing.T) {
+	ctx := context.Background()
+
+	local, err := NewStore(&StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 10,
+		DataDir:         t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	remote, ts := newTestRemoteStore(t)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: local.StoreID})
+	registry.Register(ctx, &StoreInfo{ID: remote.StoreID, Address: ts.URL})
+
+	router := NewConsistentHashRouter(2, 10, 0.8)
+	router.AddStore(&StoreInfo{ID: local.StoreID, Status: StoreStatusActive})
+	router.AddStore(&StoreInfo{ID: remote.StoreID, Status: StoreStatusActive})
+
+	globalIndex := NewInMemoryGlobalIndex()
+	timelineKey := "conv_replicated"
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: local.StoreID, BlockID: "block_1"})
+
+	pool := NewStoreRPCClientPool(5 * time.Second)
+	defer pool.Close()
+
+	policy := DefaultShardPolicy()
+	policy.ReplicationFactor = 2
+	policy.ReplicationMode = ReplicationSync
+	replication := NewReplicationManager(local, router, registry, globalIndex, pool, policy)
+
+	accessor := NewDistributedStoreAccessor(local, pool, globalIndex, router, registry)
+	accessor.SetReplicationManager(replication)
+
+	if err := accessor.AddMessage(ctx, timelineKey, 1, []byte("hello"), nil); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+
+	if msgs, _ := local.GetConvMessages(timelineKey, 10, 0); len(msgs) != 1 {
+		t.Fatalf("Expected 1 message on primary, got %d", len(msgs))
+	}
+	if msgs, _ := remote.GetConvMessages(timelineKey, 10, 0); len(msgs) != 1 || string(msgs[0].Data) != "hello" {
+		t.Fatalf("Expected message to be replicated, got %d", len(msgs))
+	}
+
+	statuses := replication.GetReplicaStatus(timelineKey)
+	if len(statuses) != 1 || statuses[0].StoreID != remote.StoreID || statuses[0].Pending != 0 || statuses[0].Replicated != 1 {
+		t.Fatalf("Unexpected replica status: %+v", statuses)
+	}
+
+	// 主Store不健康后写入应转到提升后的副本
+	info, _ := registry.GetStore(ctx, local.StoreID)
+	info.Status = "unhealthy"
+
+	if err := accessor.AddMessage(ctx, timelineKey, 1, []byte("after failover"), nil); err != nil {
+		t.Fatalf("Failed to add message after failover: %v", err)
+	}
+
+	if primary, _ := replication.GetPrimary(timelineKey); primary != remote.StoreID {
+		t.Errorf("Expected %s to be promoted, got %s", remote.StoreID, primary)
+	}
+	location, _ := globalIndex.GetTimelineLocation(ctx, timelineKey)
+	if location.Blocks[0].StoreID != remote.StoreID {
+		t.Errorf("Expected global index to point to %s, got %s", remote.StoreID, location.Blocks[0].StoreID)
+	}
+	if msgs, _ := remote.GetConvMessages(timelineKey, 10, 0); len(msgs) != 2 {
+		t.Errorf("Expected 2 messages on promoted store, got %d", len(msgs))
+	}
+}
+
+func TestReplicationManagerAsyncLag(t *testing.T) {
+	ctx := context.Background()
+
+	primary, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	replica, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	router := NewConsistentHashRouter(2, 10, 0.8)
+	router.AddStore(&StoreInfo{ID: primary.StoreID, Status: StoreStatusActive})
+	router.AddStore(&StoreInfo{ID: replica.StoreID, Status: StoreStatusActive})
+
+	policy := DefaultShardPolicy()
+	policy.ReplicationFactor = 2
+	replication := NewReplicationManager(replica, router, nil, nil, nil, policy)
+
+	timelineKey := "conv_async"
+	for i := 0; i < 5; i++ {
+		replication.Replicate(ctx, primary.StoreID, timelineKey, &Message{SenderID: 1, Data: []byte("msg")}, nil)
+	}
+
+	statuses := replication.GetReplicaStatus(timelineKey)
+	if len(statuses) != 1 || statuses[0].Pending != 5 {
+		t.Fatalf("Expected 5 pending messages before start, got %+v", statuses)
+	}
+
+	if err := replication.Start(ctx); err != nil {
+		t.Fatalf("Failed to start replication: %v", err)
+	}
+	defer replication.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if statuses := replication.GetReplicaStatus(timelineKey); statuses[0].Pending == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if statuses := replication.GetReplicaStatus(timelineKey); statuses[0].Pending != 0 || statuses[0].Replicated != 5 {
+		t.Fatalf("Expected replica to catch up, got %+v", statuses[0])
+	}
+	if msgs, _ := replica.GetConvMessages(timelineKey, 10, 0); len(msgs) != 5 {
+		t.Errorf("Expected 5 replicated messages, got %d", len(msgs))
+	}
+}
@@ -0,0 +1,332 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy Timeline保留策略，MaxAge与MaxMessages均为0表示不限制
+type RetentionPolicy struct {
+	MaxAge        time.Duration `json:"max_age"`        // 消息最长保留时间
+	MaxMessages   int64         `json:"max_messages"`   // 每个Timeline最多保留的消息数
+	CheckInterval time.Duration `json:"check_interval"` // 自动清理间隔
+}
+
+// DefaultRetentionPolicy 默认保留策略：不过期，每小时检查一次
+func DefaultRetentionPolicy() *RetentionPolicy {
+	return &RetentionPolicy{
+		CheckInterval: time.Hour,
+	}
+}
+
+// RetentionResult 一次清理的结果
+type RetentionResult struct {
+	TimelinesScanned int   `json:"timelines_scanned"`
+	BlocksDeleted    int   `json:"blocks_deleted"`
+	BlocksCompacted  int   `json:"blocks_compacted"`
+	MessagesDeleted  int64 `json:"messages_deleted"`
+	ReleasedCapacity int64 `json:"released_capacity"` // 释放的Store容量（字节）
+}
+
+// RetentionManager 按保留策略清理Timeline中过期的块
+// 只处理已写满的块：整块过期时删除，部分过期时重写块只保留未过期的消息；
+// 当前活跃块仍在接收写入，不做处理
+type RetentionManager struct {
+	mu          sync.Mutex
+	store       *Store
+	globalIndex GlobalIndexManager // 可选，删除或压缩块时同步更新全局索引
+	policy      *RetentionPolicy
+	stopCh      chan struct{}
+	running     bool
+}
+
+// NewRetentionManager 创建保留策略管理器
+func NewRetentionManager(store *Store, globalIndex GlobalIndexManager, policy *RetentionPolicy) *RetentionManager {
+	if policy == nil {
+		policy = DefaultRetentionPolicy()
+	}
+	return &RetentionManager{
+		store:       store,
+		globalIndex: globalIndex,
+		policy:      policy,
+	}
+}
+
+// Start 启动定期清理
+func (rm *RetentionManager) Start(ctx context.Context) error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if rm.running {
+		return fmt.Errorf("retention manager is already running")
+	}
+	if rm.policy.CheckInterval <= 0 {
+		return fmt.Errorf("invalid retention check interval: %v", rm.policy.CheckInterval)
+	}
+
+	rm.stopCh = make(chan struct{})
+	rm.running = true
+
+	go rm.retentionLoop(ctx, rm.stopCh)
+
+	return nil
+}
+
+// Stop 停止定期清理
+func (rm *RetentionManager) Stop() error {
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+
+	if !rm.running {
+		return fmt.Errorf("retention manager is not running")
+	}
+
+	close(rm.stopCh)
+	rm.running = false
+
+	return nil
+}
+
+// retentionLoop 定期清理循环
+func (rm *RetentionManager) retentionLoop(ctx context.Context, stopCh chan struct{}) {
+	ticker := time.NewTicker(rm.policy.CheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-stopCh:
+			return
+		case <-ticker.C:
+			if _, err := rm.RunOnce(ctx); err != nil {
+				log.Printf("retention run failed: %v", err)
+			}
+		}
+	}
+}
+
+// RunOnce 对Store上所有已加载的Timeline执行一次清理
+func (rm *RetentionManager) RunOnce(ctx context.Context) (*RetentionResult, error) {
+	result := &RetentionResult{}
+	if rm.policy.MaxAge <= 0 && rm.policy.MaxMessages <= 0 {
+		return result, nil
+	}
+
+	timelines := rm.store.ListTimelines()
+
+	var cutoff time.Time
+	if rm.policy.MaxAge > 0 {
+		cutoff = time.Now().Add(-rm.policy.MaxAge)
+	}
+
+	removed := make(map[string]bool)
+	for _, tl := range timelines {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		result.TimelinesScanned++
+		if err := rm.applyToTimeline(ctx, tl, cutoff, result, removed); err != nil {
+			return result, fmt.Errorf("failed to apply retention to %s_%s: %w", tl.Type, tl.ID, err)
+		}
+	}
+
+	// 已删除块的WAL记录必须清除，否则重启回放会恢复这些消息
+	if len(removed) > 0 && rm.store.wal != nil {
+		walSize := rm.store.wal.Size()
+		if err := rm.store.wal.Compact(func(record *walRecord) bool {
+			return !removed[record.BlockID] && !rm.store.walRecordPersisted(record)
+		}); err != nil {
+			return result, err
+		}
+		result.ReleasedCapacity += walSize - rm.store.wal.Size()
+	}
+
+	return result, nil
+}
+
+// blockRetention 单个块的清理计划
+type blockRetention struct {
+	block *TimelineBlock
+	keep  []*Message // 为nil表示整块删除
+	drop  int64
+}
+
+// applyToTimeline 对单个Timeline执行清理
+func (rm *RetentionManager) applyToTimeline(ctx context.Context, tl *Timeline, cutoff time.Time, result *RetentionResult, removed map[string]bool) error {
+	store := rm.store
+
+	tl.mu.Lock()
+	var total int64
+	for _, block := range tl.Blocks {
+		block.mu.RLock()
+		total += block.Size
+		block.mu.RUnlock()
+	}
+
+	// 超出数量限制需要删除的最旧消息数
+	var excess int64
+	if rm.policy.MaxMessages > 0 && total > rm.policy.MaxMessages {
+		excess = total - rm.policy.MaxMessages
+	}
+
+	plans := make([]*blockRetention, 0)
+	for _, block := range tl.Blocks {
+		if !block.IsFull || block == tl.CurrentBlock {
+			break
+		}
+
+		// 未加载的块按需读取，只读到第一个不需要清理的块为止
+		messages, err := store.readBlockMessages(block)
+		if err != nil {
+			tl.mu.Unlock()
+			return err
+		}
+		keep := make([]*Message, 0, len(messages))
+		var drop int64
+		for _, msg := range messages {
+			if excess > 0 || (!cutoff.IsZero() && msg.CreateTime.Before(cutoff)) {
+				drop++
+				if excess > 0 {
+					excess--
+				}
+				continue
+			}
+			keep = append(keep, msg)
+		}
+
+		if drop == 0 {
+			// 块内消息按时间顺序排列，之后的块不会更旧
+			break
+		}
+		if len(keep) == 0 {
+			keep = nil
+		}
+		plans = append(plans, &blockRetention{block: block, keep: keep, drop: drop})
+	}
+
+	if len(plans) == 0 {
+		tl.mu.Unlock()
+		return nil
+	}
+
+	deleted := make([]*TimelineBlock, 0)
+	compacted := make([]*TimelineBlock, 0)
+	var released int64
+	for _, plan := range plans {
+		block := plan.block
+		oldLocation, _ := store.segments.Location(block.BlockID)
+
+		if plan.keep == nil {
+			if err := store.segments.DeleteBlock(block.BlockID); err != nil {
+				// 之前的块可能已被裁剪
+				tl.rebuildViewLocked()
+				tl.mu.Unlock()
+				return err
+			}
+			store.dropTieredBlock(block.BlockID)
+			deleted = append(deleted, block)
+			removed[block.BlockID] = true
+			released += oldLocation.Length
+		} else {
+			block.mu.Lock()
+			block.Messages = plan.keep
+			block.Size = int64(len(plan.keep))
+			block.lazy = false
+			block.mu.Unlock()
+			if err := store.writeTimelineBlock(block); err != nil {
+				tl.rebuildViewLocked()
+				tl.mu.Unlock()
+				return err
+			}
+			compacted = append(compacted, block)
+			newLocation, _ := store.segments.Location(block.BlockID)
+			released += oldLocation.Length - newLocation.Length
+		}
+
+		result.MessagesDeleted += plan.drop
+	}
+
+	// 从Timeline中摘除已删除的块
+	if len(deleted) > 0 {
+		remaining := make([]*TimelineBlock, 0, len(tl.Blocks)-len(deleted))
+		for _, block := range tl.Blocks {
+			if !removed[block.BlockID] {
+				remaining = append(remaining, block)
+			}
+		}
+		tl.Blocks = remaining
+	}
+	tl.rebuildViewLocked()
+	tl.mu.Unlock()
+
+	// 更新Store索引，容量由段文件的记录大小自动反映
+	timelineKey := fmt.Sprintf("%s_%s", tl.Type, tl.ID)
+	store.indexMu.Lock()
+	for _, block := range deleted {
+		delete(store.TimelineBlocks, block.BlockID)
+		store.StoreIndex[timelineKey] = removeStoreIndex(store.StoreIndex[timelineKey], block.BlockID)
+	}
+	if len(store.StoreIndex[timelineKey]) == 0 {
+		delete(store.StoreIndex, timelineKey)
+	}
+	store.indexMu.Unlock()
+	store.tenants.invalidate(TenantOf(tl.ID))
+
+	if err := store.saveTimelineMetadata(tl); err != nil {
+		return err
+	}
+
+	result.BlocksDeleted += len(deleted)
+	result.BlocksCompacted += len(compacted)
+	result.ReleasedCapacity += released
+
+	rm.syncGlobalIndex(ctx, tl.ID, deleted, compacted)
+	return nil
+}
+
+// syncGlobalIndex 同步全局索引，全局索引中未登记的块直接忽略
+func (rm *RetentionManager) syncGlobalIndex(ctx context.Context, timelineKey string, deleted, compacted []*TimelineBlock) {
+	if rm.globalIndex == nil {
+		return
+	}
+
+	for _, block := range deleted {
+		if err := rm.globalIndex.RemoveIndex(ctx, timelineKey, block.BlockID); err != nil {
+			log.Printf("retention: failed to remove index %s/%s: %v", timelineKey, block.BlockID, err)
+		}
+	}
+
+	if len(compacted) == 0 {
+		return
+	}
+	location, err := rm.globalIndex.GetTimelineLocation(ctx, timelineKey)
+	if err != nil {
+		return
+	}
+	for _, block := range compacted {
+		for _, index := range location.Blocks {
+			if index.BlockID != block.BlockID {
+				continue
+			}
+			updated := *index
+			updated.Size = block.Size
+			if err := rm.globalIndex.UpdateIndex(ctx, &updated); err != nil {
+				log.Printf("retention: failed to update index %s/%s: %v", timelineKey, block.BlockID, err)
+			}
+		}
+	}
+}
+
+// removeStoreIndex 移除指定块的Store索引条目
+func removeStoreIndex(indexes []*StoreIndex, blockID string) []*StoreIndex {
+	for i, index := range indexes {
+		if index.BlockID == blockID {
+			return append(indexes[:i], indexes[i+1:]...)
+		}
+	}
+	return indexes
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newRetentionTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	store, err := NewStore(&StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 2,
+		DataDir:         dir,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	return store
+}
+
+func TestRetentionMaxMessages(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := newRetentionTestStore(t, dir)
+
+	convID := "conv_retention"
+	for i := 0; i < 7; i++ {
+		if err := store.AddMessage(convID, 1, []byte("x"), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	tl := store.GetOrCreateConvTimeline(convID)
+	oldBlocks := append([]*TimelineBlock(nil), tl.Blocks...)
+
+	globalIndex := NewInMemoryGlobalIndex()
+	for _, block := range oldBlocks {
+		globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: convID, StoreID: store.StoreID, BlockID: block.BlockID, Size: block.Size})
+	}
+
+	capacity := store.UsedCapacity()
+	manager := NewRetentionManager(store, globalIndex, &RetentionPolicy{MaxMessages: 3})
+	result, err := manager.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("Retention failed: %v", err)
+	}
+
+	if result.BlocksDeleted != 2 || result.MessagesDeleted != 4 {
+		t.Errorf("Expected 2 blocks / 4 messages deleted, got %+v", result)
+	}
+	if result.ReleasedCapacity <= 0 || store.UsedCapacity() != capacity-result.ReleasedCapacity {
+		t.Errorf("Expected capacity %d, got %d", capacity-result.ReleasedCapacity, store.UsedCapacity())
+	}
+	for _, block := range oldBlocks[:2] {
+		if store.segments.HasBlock(block.BlockID) {
+			t.Errorf("Block %s should be removed from segments", block.BlockID)
+		}
+		if _, exists := store.TimelineBlocks[block.BlockID]; exists {
+			t.Errorf("Block %s should be evicted from store", block.BlockID)
+		}
+	}
+
+	location, err := globalIndex.GetTimelineLocation(ctx, convID)
+	if err != nil || location.BlockCount != 2 {
+		t.Errorf("Expected 2 blocks left in global index, got %+v (%v)", location, err)
+	}
+
+	messages, _ := store.GetConvMessages(convID, 10, 0)
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 messages after retention, got %d", len(messages))
+	}
+
+	// 重启后已删除的消息不能从WAL中恢复
+	reopened := newRetentionTestStore(t, dir)
+	messages, _ = reopened.GetConvMessages(convID, 10, 0)
+	if len(messages) != 3 {
+		t.Errorf("Expected 3 messages after reopen, got %d", len(messages))
+	}
+}
+
+func TestRetentionMaxAgeCompactsPartialBlock(t *testing.T) {
+	ctx := context.Background()
+	store := newRetentionTestStore(t, t.TempDir())
+
+	convID := "conv_age"
+	for i := 0; i < 5; i++ {
+		if err := store.AddMessage(convID, 1, []byte("x"), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	// 第一个块整块过期，第二个块只有第一条过期
+	tl := store.GetOrCreateConvTimeline(convID)
+	old := time.Now().Add(-2 * time.Hour)
+	tl.Blocks[0].Messages[0].CreateTime = old
+	tl.Blocks[0].Messages[1].CreateTime = old
+	tl.Blocks[1].Messages[0].CreateTime = old
+
+	manager := NewRetentionManager(store, nil, &RetentionPolicy{MaxAge: time.Hour})
+	result, err := manager.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("Retention failed: %v", err)
+	}
+	if result.BlocksDeleted != 1 || result.BlocksCompacted != 1 || result.MessagesDeleted != 3 {
+		t.Errorf("Unexpected retention result: %+v", result)
+	}
+
+	compacted := tl.Blocks[0]
+	loaded, err := store.loadTimelineBlock(compacted.BlockID)
+	if err != nil || loaded == nil || len(loaded.Messages) != 1 {
+		t.Errorf("Compacted block should hold 1 message")
+	}
+
+	messages, _ := store.GetConvMessages(convID, 10, 0)
+	if len(messages) != 2 {
+		t.Errorf("Expected 2 messages after retention, got %d", len(messages))
+	}
+}
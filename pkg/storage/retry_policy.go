@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RPC重试策略
+// 只有可能因重试而成功的错误才会重试：连接失败、读取响应中断以及429/502/503/504，
+// 其他4xx、证书错误、参数或响应无法解析以及调用方取消都直接返回。
+// 退避时间按指数增长并随机缩短，避免大量客户端同时重试；重试前若剩余时间不足以完成退避则放弃。
+// 每个Store有一个重试预算：每个请求存入BudgetRatio个令牌，每次重试取出一个，
+// 另外每秒补充BudgetMinRetriesPerSecond个，Store整体故障时重试量不会超过正常请求量的一定比例
+
+// RetryPolicy RPC客户端的重试策略
+type RetryPolicy struct {
+	MaxRetries     int           // 每个请求的最大重试次数，0表示不重试
+	InitialBackoff time.Duration // 第一次重试前的退避时间
+	MaxBackoff     time.Duration // 退避时间上限
+	Multiplier     float64       // 每次重试退避时间的增长倍数
+	Jitter         float64       // 退避时间随机缩短的最大比例，取值0-1
+	// RequestBudget 包括重试在内单个请求的总时长上限，0表示只受ctx的截止时间限制
+	RequestBudget time.Duration
+	// BudgetRatio 每个请求存入重试预算的令牌数，即重试量相对请求量的上限比例，0表示不限制重试量
+	BudgetRatio float64
+	// BudgetMinRetriesPerSecond 请求很少时每秒仍允许的重试次数
+	BudgetMinRetriesPerSecond float64
+}
+
+// DefaultRetryPolicy 默认重试策略
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:                3,
+		InitialBackoff:            100 * time.Millisecond,
+		MaxBackoff:                2 * time.Second,
+		Multiplier:                2,
+		Jitter:                    0.5,
+		BudgetRatio:               0.1,
+		BudgetMinRetriesPerSecond: 10,
+	}
+}
+
+// backoff 第attempt次重试（从1开始）前的退避时间
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	if p.InitialBackoff <= 0 {
+		return 0
+	}
+	multiplier := math.Max(p.Multiplier, 1)
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	jitter := math.Min(math.Max(p.Jitter, 0), 1)
+	return time.Duration(backoff * (1 - jitter*rand.Float64()))
+}
+
+// retryableStatus 可以重试的HTTP状态码
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryableTransportError 发送请求或读取响应的错误是否可以重试
+func retryableTransportError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	// 证书问题重试也不会成功
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidCert x509.CertificateInvalidError
+	if errors.As(err, &verifyErr) || errors.As(err, &unknownAuthority) || errors.As(err, &hostnameErr) || errors.As(err, &invalidCert) {
+		return false
+	}
+	return true
+}
+
+// RetryBudget 单个Store的重试预算，并发安全
+type RetryBudget struct {
+	mu         sync.Mutex
+	ratio      float64
+	minPerSec  float64
+	maxTokens  float64
+	tokens     float64
+	lastRefill time.Time
+	throttled  int64
+	now        func() time.Time
+}
+
+// NewRetryBudget 按策略创建重试预算，BudgetRatio不大于0时返回nil，表示不限制重试量
+func NewRetryBudget(policy RetryPolicy) *RetryBudget {
+	if policy.BudgetRatio <= 0 {
+		return nil
+	}
+	minPerSec := math.Max(policy.BudgetMinRetriesPerSecond, 0)
+	// 令牌最多积累10秒的最低重试量，且至少能支撑一次完整的重试
+	maxTokens := math.Max(minPerSec*10, float64(max(policy.MaxRetries, 1)))
+	return &RetryBudget{
+		ratio:      policy.BudgetRatio,
+		minPerSec:  minPerSec,
+		maxTokens:  maxTokens,
+		tokens:     maxTokens,
+		lastRefill: time.Now(),
+		now:        time.Now,
+	}
+}
+
+// deposit 记录一次请求，nil预算不做任何事
+func (b *RetryBudget) deposit() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+	b.tokens = math.Min(b.tokens+b.ratio, b.maxTokens)
+}
+
+// withdraw 申请一次重试，预算不足时返回false，nil预算总是允许
+func (b *RetryBudget) withdraw() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refillLocked()
+	if b.tokens < 1 {
+		b.throttled++
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+func (b *RetryBudget) refillLocked() {
+	now := b.now()
+	if elapsed := now.Sub(b.lastRefill); elapsed > 0 {
+		b.tokens = math.Min(b.tokens+elapsed.Seconds()*b.minPerSec, b.maxTokens)
+	}
+	b.lastRefill = now
+}
+
+// Throttled 因预算不足而放弃的重试次数
+func (b *RetryBudget) Throttled() int64 {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.throttled
+}
+
+// RetryBudgets 按StoreID维护的重试预算，同一Store的所有客户端共用一个预算
+type RetryBudgets struct {
+	mu      sync.Mutex
+	policy  RetryPolicy
+	budgets map[string]*RetryBudget
+}
+
+// NewRetryBudgets 创建按Store划分的重试预算
+func NewRetryBudgets(policy RetryPolicy) *RetryBudgets {
+	return &RetryBudgets{
+		policy:  policy,
+		budgets: make(map[string]*RetryBudget),
+	}
+}
+
+// Policy 返回创建预算使用的重试策略
+func (g *RetryBudgets) Policy() RetryPolicy {
+	return g.policy
+}
+
+// Get 获取Store的重试预算，不存在时创建；策略不限制重试量时返回nil
+func (g *RetryBudgets) Get(storeID string) *RetryBudget {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	budget, exists := g.budgets[storeID]
+	if !exists {
+		budget = NewRetryBudget(g.policy)
+		g.budgets[storeID] = budget
+	}
+	return budget
+}
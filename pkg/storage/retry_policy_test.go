@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+	for attempt, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 4: 800 * time.Millisecond, 6: time.Second} {
+		if got := policy.backoff(attempt); got != want {
+			t.Errorf("Attempt %d: expected %v, got %v", attempt, want, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Expected jittered backoff within [100ms, 200ms], got %v", got)
+		}
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	now := time.Now()
+	budget := NewRetryBudget(RetryPolicy{MaxRetries: 2, BudgetRatio: 0.5, BudgetMinRetriesPerSecond: 0.1})
+	budget.now = func() time.Time { return now }
+	budget.lastRefill = now
+
+	// 初始令牌支撑一次完整的重试
+	if !budget.withdraw() || !budget.withdraw() || budget.withdraw() {
+		t.Fatalf("Expected exactly 2 retries from a full budget")
+	}
+	// 两个请求存入一次重试
+	budget.deposit()
+	budget.deposit()
+	if !budget.withdraw() || budget.withdraw() {
+		t.Errorf("Expected 2 requests to fund one retry")
+	}
+	// 没有请求时按最低速率补充
+	now = now.Add(10 * time.Second)
+	if !budget.withdraw() {
+		t.Errorf("Expected the minimum rate to refill one retry")
+	}
+	if budget.Throttled() != 2 {
+		t.Errorf("Expected 2 throttled retries, got %d", budget.Throttled())
+	}
+	if NewRetryBudget(RetryPolicy{}) != nil || !(*RetryBudget)(nil).withdraw() {
+		t.Errorf("Expected a nil budget to allow unlimited retries")
+	}
+}
+
+func TestHTTPClientRetryClassification(t *testing.T) {
+	var requests atomic.Int64
+	var status atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer ts.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.BudgetRatio = 0
+	client := NewHTTPStoreRPCClient(time.Second)
+	client.SetRetryPolicy(policy, nil)
+
+	for _, tc := range []struct {
+		status   int
+		attempts int64
+	}{
+		{http.StatusBadRequest, 1},
+		{http.StatusNotFound, 1},
+		{http.StatusInternalServerError, 1},
+		{http.StatusServiceUnavailable, 4},
+		{http.StatusTooManyRequests, 4},
+	} {
+		requests.Store(0)
+		status.Store(int64(tc.status))
+		if err := client.Connect(context.Background(), ts.URL); err == nil {
+			t.Fatalf("Expected status %d to fail", tc.status)
+		}
+		if got := requests.Load(); got != tc.attempts {
+			t.Errorf("Status %d: expected %d attempts, got %d", tc.status, tc.attempts, got)
+		}
+	}
+
+	// 取消的请求不重试
+	requests.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client.Connect(ctx, ts.URL)
+	if got := requests.Load(); got != 0 {
+		t.Errorf("Expected no attempts for a cancelled request, got %d", got)
+	}
+}
+
+func TestHTTPClientRetryBudgets(t *testing.T) {
+	var requests atomic.Int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	// 剩余时间不足以退避时放弃重试
+	policy := DefaultRetryPolicy()
+	policy.MaxRetries = 10
+	policy.InitialBackoff = 50 * time.Millisecond
+	policy.Jitter = 0
+	policy.RequestBudget = 120 * time.Millisecond
+	client := NewHTTPStoreRPCClient(time.Second)
+	client.SetRetryPolicy(policy, nil)
+	start := time.Now()
+	client.Connect(context.Background(), ts.URL)
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected the request budget to allow 2 attempts, got %d", got)
+	}
+	if elapsed := time.Since(start); elapsed > 120*time.Millisecond {
+		t.Errorf("Expected to give up within the request budget, took %v", elapsed)
+	}
+
+	// 同一Store的客户端共用重试预算
+	policy = DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.BudgetRatio = 0.1
+	policy.BudgetMinRetriesPerSecond = 0.3
+	budgets := NewRetryBudgets(policy)
+	first, second := NewHTTPStoreRPCClient(time.Second), NewHTTPStoreRPCClient(time.Second)
+	first.SetRetryPolicy(policy, budgets.Get("store_a"))
+	second.SetRetryPolicy(policy, budgets.Get("store_a"))
+
+	requests.Store(0)
+	first.Connect(context.Background(), ts.URL)
+	err := second.Connect(context.Background(), ts.URL)
+	if err == nil || !strings.Contains(err.Error(), "retry budget exhausted") {
+		t.Errorf("Expected the shared budget to be exhausted, got %v", err)
+	}
+	if got := requests.Load(); got != 5 {
+		t.Errorf("Expected 3 retries across both clients, got %d requests", got)
+	}
+}
@@ -8,12 +8,6 @@ import (
 	"time"
 )
 
-// StoreStatus Store状态常量
-const (
-	StoreStatusHealthy = "healthy"
-	StoreStatusUnhealthy = "unhealthy"
-)
-
 // TimelineRouter Timeline路由器接口
 type TimelineRouter interface {
 	// 路由Timeline到指定Store
@@ -43,6 +37,7 @@ type StoreLoad struct {
 	CPUUsage        float64   `json:"cpu_usage"`        // CPU使用率
 	MemoryUsage     float64   `json:"memory_usage"`     // 内存使用率
 	NetworkLatency  int64     `json:"network_latency"`  // 网络延迟（毫秒）
+	ActiveConnections int64   `json:"active_connections"` // 当前连接数
 	LastUpdate      time.Time `json:"last_update"`      // 最后更新时间
 }
 
@@ -186,29 +181,52 @@ func (r *ConsistentHashRouter) RouteTimeline(timelineKey string) (string, error)
 		return "", fmt.Errorf("no available stores")
 	}
 	
-	// 首先尝试使用一致性哈希
-	storeID := r.hashRing.GetNode(timelineKey)
-	if storeID == "" {
+	// 首先尝试使用一致性哈希，跳过不能放置该租户的Store
+	nodes := r.tenantNodes(timelineKey, 1)
+	if len(nodes) == 0 {
 		return "", fmt.Errorf("failed to route timeline")
 	}
+	storeID := nodes[0]
+	tenant := TenantOf(timelineKey)
 	
 	// 检查Store是否健康且负载不过高
 	store, exists := r.stores[storeID]
-	if !exists || store.Status != StoreStatusHealthy {
+	if !exists || store.Status != StoreStatusActive {
 		// 如果主Store不可用，选择备用Store
-		return r.getBestAvailableStore()
+		return r.getBestAvailableStore(tenant)
 	}
 	
 	// 检查负载
 	load, hasLoad := r.loads[storeID]
 	if hasLoad && r.isOverloaded(load) {
 		// 如果负载过高，选择负载较低的Store
-		return r.getBestAvailableStore()
+		return r.getBestAvailableStore(tenant)
 	}
 	
 	return storeID, nil
 }
 
+// tenantNodes 沿哈希环取最多count个能放置Timeline所属租户的Store（内部方法，需要持有锁）
+func (r *ConsistentHashRouter) tenantNodes(timelineKey string, count int) []string {
+	tenant := TenantOf(timelineKey)
+	dedicated := tenantHasDedicatedStores(r.stores, tenant)
+	filter := func(nodes []string) []string {
+		served := make([]string, 0, len(nodes))
+		for _, storeID := range nodes {
+			if len(served) < count && storeServesTenant(r.stores[storeID], tenant, dedicated) {
+				served = append(served, storeID)
+			}
+		}
+		return served
+	}
+	
+	nodes := r.hashRing.GetNodes(timelineKey, count)
+	if served := filter(nodes); len(served) == len(nodes) {
+		return served
+	}
+	return filter(r.hashRing.GetNodes(timelineKey, len(r.stores)))
+}
+
 // GetTimelineReplicas 获取Timeline的所有副本Store
 func (r *ConsistentHashRouter) GetTimelineReplicas(timelineKey string) ([]string, error) {
 	r.mu.RLock()
@@ -218,12 +236,12 @@ func (r *ConsistentHashRouter) GetTimelineReplicas(timelineKey string) ([]string
 		return nil, fmt.Errorf("no available stores")
 	}
 	
-	replicas := r.hashRing.GetNodes(timelineKey, r.replicas)
+	replicas := r.tenantNodes(timelineKey, r.replicas)
 	
 	// 过滤掉不健康的Store
 	healthyReplicas := make([]string, 0, len(replicas))
 	for _, storeID := range replicas {
-		if store, exists := r.stores[storeID]; exists && store.Status == StoreStatusHealthy {
+		if store, exists := r.stores[storeID]; exists && store.Status == StoreStatusActive {
 			healthyReplicas = append(healthyReplicas, storeID)
 		}
 	}
@@ -236,8 +254,11 @@ func (r *ConsistentHashRouter) AddStore(storeInfo *StoreInfo) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	
+	// 重复添加只更新Store信息，避免哈希环中出现重复的虚拟节点
+	if _, exists := r.stores[storeInfo.ID]; !exists {
+		r.hashRing.AddNode(storeInfo.ID)
+	}
 	r.stores[storeInfo.ID] = storeInfo
-	r.hashRing.AddNode(storeInfo.ID)
 	
 	return nil
 }
@@ -270,20 +291,21 @@ func (r *ConsistentHashRouter) GetBestStore() (string, error) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 	
-	return r.getBestAvailableStore()
+	return r.getBestAvailableStore(DefaultTenant)
 }
 
-// getBestAvailableStore 获取最佳可用Store（内部方法，需要持有锁）
-func (r *ConsistentHashRouter) getBestAvailableStore() (string, error) {
+// getBestAvailableStore 获取能放置租户Timeline的最佳可用Store（内部方法，需要持有锁）
+func (r *ConsistentHashRouter) getBestAvailableStore(tenant string) (string, error) {
 	if len(r.stores) == 0 {
 		return "", fmt.Errorf("no available stores")
 	}
 	
 	var bestStoreID string
 	var bestScore float64 = -1
+	dedicated := tenantHasDedicatedStores(r.stores, tenant)
 	
 	for storeID, store := range r.stores {
-		if store.Status != StoreStatusHealthy {
+		if store.Status != StoreStatusActive || !storeServesTenant(store, tenant, dedicated) {
 			continue
 		}
 		
@@ -378,7 +400,7 @@ func (r *ConsistentHashRouter) Rebalance() ([]*MigrationPlan, error) {
 	underloadedStores := make([]string, 0)
 	
 	for storeID, store := range r.stores {
-		if store.Status != StoreStatusHealthy {
+		if store.Status != StoreStatusActive {
 			continue
 		}
 		
@@ -421,13 +443,13 @@ func (r *ConsistentHashRouter) Rebalance() ([]*MigrationPlan, error) {
 	return plans, nil
 }
 
-// LoadBalancingRouter 负载均衡路由器
+// LoadBalancingRouter 负载均衡路由器，选择逻辑由可替换的Strategy实现
 type LoadBalancingRouter struct {
-	mu           sync.RWMutex
-	stores       map[string]*StoreInfo
-	loads        map[string]*StoreLoad
-	strategy     LoadBalancingStrategy
-	roundRobinIdx int
+	mu          sync.RWMutex
+	stores      map[string]*StoreInfo
+	loads       map[string]*StoreLoad
+	connections map[string]int64 // 通过TrackConnection跟踪的连接数
+	strategy    Strategy
 }
 
 // LoadBalancingStrategy 负载均衡策略
@@ -436,124 +458,95 @@ type LoadBalancingStrategy int
 const (
 	StrategyRoundRobin LoadBalancingStrategy = iota // 轮询
 	StrategyLeastLoad                               // 最少负载
-	StrategyWeightedRoundRobin                      // 加权轮询
-	StrategyRandom                                  // 随机
+	StrategyWeightedRoundRobin                      // 平滑加权轮询
+	StrategyRandom                                  // 按权重随机
+	StrategyLeastConnections                        // 最少连接
 )
 
-// NewLoadBalancingRouter 创建负载均衡路由器
+// strategyNames 内置策略常量对应的策略名称
+var strategyNames = map[LoadBalancingStrategy]string{
+	StrategyRoundRobin:         StrategyNameRoundRobin,
+	StrategyLeastLoad:          StrategyNameLeastLoad,
+	StrategyWeightedRoundRobin: StrategyNameWeightedRoundRobin,
+	StrategyRandom:             StrategyNameRandom,
+	StrategyLeastConnections:   StrategyNameLeastConnections,
+}
+
+// NewLoadBalancingRouter 创建使用内置策略的负载均衡路由器，未知策略按轮询处理
 func NewLoadBalancingRouter(strategy LoadBalancingStrategy) *LoadBalancingRouter {
+	name, exists := strategyNames[strategy]
+	if !exists {
+		name = StrategyNameRoundRobin
+	}
+	s, _ := NewStrategy(name)
+	return NewLoadBalancingRouterWithStrategy(s)
+}
+
+// NewLoadBalancingRouterWithStrategy 创建使用指定策略的负载均衡路由器
+func NewLoadBalancingRouterWithStrategy(strategy Strategy) *LoadBalancingRouter {
 	return &LoadBalancingRouter{
-		stores:   make(map[string]*StoreInfo),
-		loads:    make(map[string]*StoreLoad),
-		strategy: strategy,
+		stores:      make(map[string]*StoreInfo),
+		loads:       make(map[string]*StoreLoad),
+		connections: make(map[string]int64),
+		strategy:    strategy,
 	}
 }
 
+// SetStrategy 替换负载均衡策略
+func (r *LoadBalancingRouter) SetStrategy(strategy Strategy) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.strategy = strategy
+}
+
 // RouteTimeline 路由Timeline（负载均衡）
 func (r *LoadBalancingRouter) RouteTimeline(timelineKey string) (string, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	
-	healthyStores := r.getHealthyStores()
-	if len(healthyStores) == 0 {
-		return "", fmt.Errorf("no healthy stores available")
-	}
-	
-	switch r.strategy {
-	case StrategyRoundRobin:
-		return r.roundRobinSelect(healthyStores), nil
-	case StrategyLeastLoad:
-		return r.leastLoadSelect(healthyStores), nil
-	case StrategyWeightedRoundRobin:
-		return r.roundRobinSelect(healthyStores), nil
-	case StrategyRandom:
-		return r.randomSelect(healthyStores), nil
-	default:
-		return r.roundRobinSelect(healthyStores), nil
-	}
-}
 
-// getHealthyStores 获取健康的Store列表
-func (r *LoadBalancingRouter) getHealthyStores() []string {
-	healthyStores := make([]string, 0)
-	for storeID, store := range r.stores {
-		if store.Status == StoreStatusHealthy {
-			healthyStores = append(healthyStores, storeID)
-		}
+	candidates := r.getHealthyCandidates(TenantOf(timelineKey))
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no healthy stores available")
 	}
-	return healthyStores
+	return r.strategy.Select(timelineKey, candidates)
 }
 
-// roundRobinSelect 轮询选择
-func (r *LoadBalancingRouter) roundRobinSelect(stores []string) string {
-	if len(stores) == 0 {
-		return ""
+// TrackConnection 记录到Store的一个新连接，返回的函数在连接结束时调用
+// 跟踪的连接数供最少连接策略使用，优先于负载上报的ActiveConnections
+func (r *LoadBalancingRouter) TrackConnection(storeID string) func() {
+	r.mu.Lock()
+	r.connections[storeID]++
+	r.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if _, tracked := r.connections[storeID]; tracked {
+				r.connections[storeID]--
+			}
+		})
 	}
-	storeID := stores[r.roundRobinIdx%len(stores)]
-	r.roundRobinIdx++
-	return storeID
 }
 
-// leastLoadSelect 最少负载选择
-func (r *LoadBalancingRouter) leastLoadSelect(stores []string) string {
-	if len(stores) == 0 {
-		return ""
-	}
-	
-	bestStore := stores[0]
-	bestScore := -1.0
-	
-	for _, storeID := range stores {
-		load, hasLoad := r.loads[storeID]
-		if !hasLoad {
-			return storeID // 没有负载信息的Store优先
+// getHealthyCandidates 获取能放置租户Timeline的健康Store，按ID排序
+func (r *LoadBalancingRouter) getHealthyCandidates(tenant string) []*StoreCandidate {
+	candidates := make([]*StoreCandidate, 0, len(r.stores))
+	dedicated := tenantHasDedicatedStores(r.stores, tenant)
+	for storeID, store := range r.stores {
+		if store.Status != StoreStatusActive || !storeServesTenant(store, tenant, dedicated) {
+			continue
 		}
-		
-		score := r.calculateStoreScore(load)
-		if score > bestScore {
-			bestScore = score
-			bestStore = storeID
+		candidate := &StoreCandidate{Info: store, Load: r.loads[storeID]}
+		if conns, tracked := r.connections[storeID]; tracked {
+			candidate.Connections = conns
+		} else if candidate.Load != nil {
+			candidate.Connections = candidate.Load.ActiveConnections
 		}
+		candidates = append(candidates, candidate)
 	}
-	
-	return bestStore
-}
-
-// randomSelect 随机选择
-func (r *LoadBalancingRouter) randomSelect(stores []string) string {
-	if len(stores) == 0 {
-		return ""
-	}
-	// 简单的伪随机选择
-	idx := int(time.Now().UnixNano()) % len(stores)
-	return stores[idx]
-}
-
-// calculateStoreScore 计算Store评分（与一致性哈希路由器相同）
-func (r *LoadBalancingRouter) calculateStoreScore(load *StoreLoad) float64 {
-	capacityRatio := float64(load.UsedCapacity) / float64(load.MaxCapacity)
-	if capacityRatio > 1.0 {
-		capacityRatio = 1.0
-	}
-	
-	cpuScore := 1.0 - load.CPUUsage
-	if cpuScore < 0 {
-		cpuScore = 0
-	}
-	
-	memoryScore := 1.0 - load.MemoryUsage
-	if memoryScore < 0 {
-		memoryScore = 0
-	}
-	
-	latencyScore := 1.0
-	if load.NetworkLatency > 0 {
-		latencyScore = 1.0 / (1.0 + float64(load.NetworkLatency)/1000.0)
-	}
-	
-	score := (1.0-capacityRatio)*0.3 + cpuScore*0.25 + memoryScore*0.25 + latencyScore*0.2
-	
-	return score
+	return sortedCandidates(candidates)
 }
 
 // 实现TimelineRouter接口的其他方法
@@ -578,6 +571,7 @@ func (r *LoadBalancingRouter) RemoveStore(storeID string) error {
 	defer r.mu.Unlock()
 	delete(r.stores, storeID)
 	delete(r.loads, storeID)
+	delete(r.connections, storeID)
 	return nil
 }
 
@@ -647,6 +641,30 @@ func (rm *RouterManager) GetRouter(name string) (TimelineRouter, error) {
 	return router, nil
 }
 
+// AddStore 将Store加入所有已注册的路由器
+func (rm *RouterManager) AddStore(storeInfo *StoreInfo) error {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	for name, router := range rm.routers {
+		if err := router.AddStore(storeInfo); err != nil {
+			return fmt.Errorf("router %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// RemoveStore 从所有已注册的路由器中移除Store
+func (rm *RouterManager) RemoveStore(storeID string) error {
+	rm.mu.RLock()
+	defer rm.mu.RUnlock()
+	for name, router := range rm.routers {
+		if err := router.RemoveStore(storeID); err != nil {
+			return fmt.Errorf("router %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
 // RouteTimeline 使用默认路由器路由Timeline
 func (rm *RouterManager) RouteTimeline(timelineKey string) (string, error) {
 	router, err := rm.GetRouter("")
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 内置路由器在RouterManager中的注册名称
+const (
+	RouterNameConsistentHash = "hash"
+	RouterNameRendezvous     = "rendezvous"
+)
+
+// RendezvousRouter 基于加权最高随机权重（HRW）哈希的路由器
+// 每个Timeline对每个Store计算 weight / -ln(hash(key, store))，得分最高者为主Store，其后依次为副本。
+// 与一致性哈希相比不需要虚拟节点，分布更均匀；增删Store时只有归属该Store的Timeline会移动。
+type RendezvousRouter struct {
+	mu            sync.RWMutex
+	stores        map[string]*StoreInfo
+	loads         map[string]*StoreLoad
+	seeds         map[string]uint64 // Store ID的哈希，避免每次路由重复计算
+	replicas      int
+	loadThreshold float64
+}
+
+// rendezvousScore 单个Store对某个Timeline的得分
+type rendezvousScore struct {
+	storeID string
+	score   float64
+}
+
+// NewRendezvousRouter 创建HRW路由器，权重取自Store的weight或capacity元数据
+func NewRendezvousRouter(replicas int, loadThreshold float64) *RendezvousRouter {
+	if replicas < 1 {
+		replicas = 1
+	}
+	return &RendezvousRouter{
+		stores:        make(map[string]*StoreInfo),
+		loads:         make(map[string]*StoreLoad),
+		seeds:         make(map[string]uint64),
+		replicas:      replicas,
+		loadThreshold: loadThreshold,
+	}
+}
+
+// RouteTimeline 路由Timeline到得分最高的健康Store
+// 主Store过载时按得分顺序选择下一个未过载的Store，全部过载时仍返回得分最高者
+func (r *RendezvousRouter) RouteTimeline(timelineKey string) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ranked := r.rank(timelineKey)
+	if len(ranked) == 0 {
+		return "", fmt.Errorf("no healthy stores available")
+	}
+	for _, candidate := range ranked {
+		if load, hasLoad := r.loads[candidate.storeID]; !hasLoad || !r.isOverloaded(load) {
+			return candidate.storeID, nil
+		}
+	}
+	return ranked[0].storeID, nil
+}
+
+// GetTimelineReplicas 获取Timeline的副本Store，按得分从高到低
+func (r *RendezvousRouter) GetTimelineReplicas(timelineKey string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if len(r.stores) == 0 {
+		return nil, fmt.Errorf("no available stores")
+	}
+	ranked := r.rank(timelineKey)
+	if len(ranked) > r.replicas {
+		ranked = ranked[:r.replicas]
+	}
+	replicas := make([]string, 0, len(ranked))
+	for _, candidate := range ranked {
+		replicas = append(replicas, candidate.storeID)
+	}
+	return replicas, nil
+}
+
+// AddStore 添加或更新Store节点
+func (r *RendezvousRouter) AddStore(storeInfo *StoreInfo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stores[storeInfo.ID] = storeInfo
+	r.seeds[storeInfo.ID] = hashString(storeInfo.ID)
+	return nil
+}
+
+// RemoveStore 移除Store节点
+func (r *RendezvousRouter) RemoveStore(storeID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.stores, storeID)
+	delete(r.loads, storeID)
+	delete(r.seeds, storeID)
+	return nil
+}
+
+// UpdateStoreLoad 更新Store负载信息
+func (r *RendezvousRouter) UpdateStoreLoad(storeID string, load *StoreLoad) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	load.LastUpdate = time.Now()
+	r.loads[storeID] = load
+	return nil
+}
+
+// GetBestStore 获取负载评分最高的健康共享Store，没有负载信息的Store优先
+func (r *RendezvousRouter) GetBestStore() (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	candidates := make([]*StoreCandidate, 0, len(r.stores))
+	for storeID, store := range r.stores {
+		if store.Status == StoreStatusActive && storeServesTenant(store, DefaultTenant, false) {
+			candidates = append(candidates, &StoreCandidate{Info: store, Load: r.loads[storeID]})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no healthy stores available")
+	}
+	return leastLoadStrategy{}.Select("", sortedCandidates(candidates))
+}
+
+// Rebalance HRW的归属随拓扑变化自动重新计算，这里不产生额外的迁移计划
+// 基于负载的迁移由TimelineShardManager根据实际Timeline列表生成
+func (r *RendezvousRouter) Rebalance() ([]*MigrationPlan, error) {
+	return []*MigrationPlan{}, nil
+}
+
+// rank 按得分从高到低排列能放置该Timeline租户的健康Store，调用方需持有锁
+func (r *RendezvousRouter) rank(timelineKey string) []rendezvousScore {
+	keyHash := hashString(timelineKey)
+	tenant := TenantOf(timelineKey)
+	dedicated := tenantHasDedicatedStores(r.stores, tenant)
+	ranked := make([]rendezvousScore, 0, len(r.stores))
+	for storeID, store := range r.stores {
+		if store.Status != StoreStatusActive || !storeServesTenant(store, tenant, dedicated) {
+			continue
+		}
+		weight := storeWeight(&StoreCandidate{Info: store, Load: r.loads[storeID]})
+		ranked = append(ranked, rendezvousScore{
+			storeID: storeID,
+			score:   rendezvousWeight(keyHash, r.seeds[storeID], weight),
+		})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].storeID < ranked[j].storeID
+	})
+	return ranked
+}
+
+// isOverloaded 检查Store是否过载
+func (r *RendezvousRouter) isOverloaded(load *StoreLoad) bool {
+	if load.MaxCapacity > 0 && float64(load.UsedCapacity)/float64(load.MaxCapacity) > r.loadThreshold {
+		return true
+	}
+	return load.CPUUsage > r.loadThreshold || load.MemoryUsage > r.loadThreshold
+}
+
+// rendezvousWeight 计算加权HRW得分 weight / -ln(u)，u为(0,1)内均匀分布的哈希值
+// 在该形式下每个Store成为得分最高者的概率与其权重成正比
+func rendezvousWeight(keyHash, storeSeed uint64, weight int64) float64 {
+	h := mix64(keyHash ^ storeSeed)
+	u := (float64(h>>11) + 0.5) / (1 << 53)
+	return float64(weight) / -math.Log(u)
+}
+
+func hashString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// mix64 splitmix64的最终混合步骤，使相近的输入得到不相关的输出
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
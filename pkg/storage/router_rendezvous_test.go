@@ -0,0 +1,157 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// routeAll 返回每个Timeline的主Store
+func routeAll(router TimelineRouter, keys []string) map[string]string {
+	owners := make(map[string]string, len(keys))
+	for _, key := range keys {
+		owners[key], _ = router.RouteTimeline(key)
+	}
+	return owners
+}
+
+func testTimelineKeys(n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("conv_%d", i)
+	}
+	return keys
+}
+
+func addTestStores(router TimelineRouter, n int) {
+	for i := 0; i < n; i++ {
+		router.AddStore(&StoreInfo{ID: fmt.Sprintf("store_%d", i), Status: StoreStatusActive})
+	}
+}
+
+// movedFraction 统计加入一个Store后主Store发生变化的Timeline比例
+func movedFraction(router TimelineRouter, stores int, keys []string) float64 {
+	addTestStores(router, stores)
+	before := routeAll(router, keys)
+	router.AddStore(&StoreInfo{ID: fmt.Sprintf("store_%d", stores), Status: StoreStatusActive})
+	after := routeAll(router, keys)
+
+	moved := 0
+	for key, owner := range before {
+		if after[key] != owner {
+			moved++
+		}
+	}
+	return float64(moved) / float64(len(keys))
+}
+
+func TestRendezvousRouterDistribution(t *testing.T) {
+	keys := testTimelineKeys(20000)
+	router := NewRendezvousRouter(2, 0.8)
+	addTestStores(router, 4)
+
+	counts := make(map[string]int)
+	for _, owner := range routeAll(router, keys) {
+		counts[owner]++
+	}
+	for storeID, count := range counts {
+		if share := float64(count) / float64(len(keys)); math.Abs(share-0.25) > 0.02 {
+			t.Errorf("Store %s owns %.3f of timelines, expected about 0.25", storeID, share)
+		}
+	}
+
+	// 加入第5个Store后约1/5的Timeline移动，且只移动到新Store
+	before := routeAll(router, keys)
+	router.AddStore(&StoreInfo{ID: "store_4", Status: StoreStatusActive})
+	moved := 0
+	for key, owner := range routeAll(router, keys) {
+		if owner != before[key] {
+			moved++
+			if owner != "store_4" {
+				t.Fatalf("Timeline %s moved from %s to %s instead of the new store", key, before[key], owner)
+			}
+		}
+	}
+	if share := float64(moved) / float64(len(keys)); math.Abs(share-0.2) > 0.02 {
+		t.Errorf("Expected about 0.2 of timelines to move, got %.3f", share)
+	}
+
+	// 副本按得分排序，主Store不健康后由第一个副本接替
+	replicas, err := router.GetTimelineReplicas("conv_1")
+	if err != nil || len(replicas) != 2 || replicas[0] == replicas[1] {
+		t.Fatalf("Unexpected replicas: %v %v", replicas, err)
+	}
+	primary, _ := router.RouteTimeline("conv_1")
+	if primary != replicas[0] {
+		t.Errorf("Expected primary %s to lead the replicas %v", primary, replicas)
+	}
+	router.AddStore(&StoreInfo{ID: primary, Status: StoreStatusUnhealthy})
+	if next, _ := router.RouteTimeline("conv_1"); next != replicas[1] {
+		t.Errorf("Expected %s to take over, got %s", replicas[1], next)
+	}
+}
+
+func TestRendezvousRouterWeightsAndLoad(t *testing.T) {
+	keys := testTimelineKeys(20000)
+	router := NewRendezvousRouter(1, 0.8)
+	router.AddStore(&StoreInfo{ID: "heavy", Status: StoreStatusActive, Metadata: map[string]interface{}{StoreMetadataWeight: 3}})
+	router.AddStore(&StoreInfo{ID: "light", Status: StoreStatusActive, Metadata: map[string]interface{}{StoreMetadataWeight: 1}})
+
+	counts := make(map[string]int)
+	for _, owner := range routeAll(router, keys) {
+		counts[owner]++
+	}
+	if share := float64(counts["heavy"]) / float64(len(keys)); math.Abs(share-0.75) > 0.02 {
+		t.Errorf("Expected heavy store to own about 0.75, got %.3f", share)
+	}
+
+	// 过载的Store不再接收新Timeline，除非所有Store都过载
+	router.UpdateStoreLoad("heavy", &StoreLoad{StoreID: "heavy", UsedCapacity: 90, MaxCapacity: 100})
+	for _, key := range keys[:100] {
+		if owner, _ := router.RouteTimeline(key); owner != "light" {
+			t.Fatalf("Expected overloaded store to be skipped, got %s", owner)
+		}
+	}
+	router.UpdateStoreLoad("light", &StoreLoad{StoreID: "light", CPUUsage: 0.95})
+	if owner, _ := router.RouteTimeline(keys[0]); owner == "" {
+		t.Error("Expected a store even when all are overloaded")
+	}
+}
+
+func BenchmarkRouteTimeline(b *testing.B) {
+	routers := map[string]TimelineRouter{
+		"ConsistentHash": NewConsistentHashRouter(1, 100, 0.8),
+		"Rendezvous":     NewRendezvousRouter(1, 0.8),
+	}
+	for name, router := range routers {
+		addTestStores(router, 8)
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				router.RouteTimeline(fmt.Sprintf("conv_%d", i))
+			}
+		})
+	}
+}
+
+// BenchmarkKeyMovement 比较加入一个Store时需要移动的Timeline比例，理想值为1/(n+1)
+func BenchmarkKeyMovement(b *testing.B) {
+	keys := testTimelineKeys(10000)
+	for _, stores := range []int{3, 8} {
+		b.Run(fmt.Sprintf("ConsistentHash/%d", stores), func(b *testing.B) {
+			var moved float64
+			for i := 0; i < b.N; i++ {
+				moved = movedFraction(NewConsistentHashRouter(1, 100, 0.8), stores, keys)
+			}
+			b.ReportMetric(moved, "moved")
+			b.ReportMetric(1/float64(stores+1), "ideal")
+		})
+		b.Run(fmt.Sprintf("Rendezvous/%d", stores), func(b *testing.B) {
+			var moved float64
+			for i := 0; i < b.N; i++ {
+				moved = movedFraction(NewRendezvousRouter(1, 0.8), stores, keys)
+			}
+			b.ReportMetric(moved, "moved")
+			b.ReportMetric(1/float64(stores+1), "ideal")
+		})
+	}
+}
@@ -0,0 +1,264 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store之间的RPC认证
+// 共享密钥模式下客户端为每次发送（包括重试）生成随机nonce，用集群密钥对
+// 调用方StoreID、RequestID、时间戳、nonce和请求体摘要做HMAC-SHA256签名，放在请求头中。
+// 服务端校验签名，拒绝时间戳偏差超过MaxClockSkew的请求，并记住窗口内见过的nonce以拒绝重放。
+// 双向TLS模式下调用方身份取自已验证的客户端证书（CN或DNS SAN），可限制允许的身份。
+// 认证只作用于/rpc，/health保持开放供负载均衡探测。
+// 服务端先校验请求头（签名字段、时间戳与nonce，或客户端证书），通过后才按大小上限读取请求体校验签名
+
+const (
+	headerRPCStoreID   = "X-Store-Id"
+	headerRPCRequestID = "X-Store-Request-Id"
+	headerRPCTimestamp = "X-Store-Timestamp"
+	headerRPCNonce     = "X-Store-Nonce"
+	headerRPCSignature = "X-Store-Signature"
+
+	defaultMaxClockSkew = 5 * time.Minute
+	// defaultMaxRPCBodySize 认证时读取的请求体上限
+	defaultMaxRPCBodySize = 64 << 20
+)
+
+var (
+	ErrRPCUnauthenticated = errors.New("rpc request is not authenticated")
+	ErrRPCReplayed        = errors.New("rpc request was replayed")
+)
+
+// RPCAuthenticator 认证RPC请求，返回调用方身份
+type RPCAuthenticator interface {
+	Authenticate(r *http.Request, body []byte) (string, error)
+}
+
+// rpcHeaderVerifier 可在读取请求体之前只凭请求头拒绝请求的认证方式
+type rpcHeaderVerifier interface {
+	VerifyHeaders(r *http.Request) error
+}
+
+type rpcCallerKey struct{}
+
+// RPCCallerFromContext 返回认证后的调用方身份，未启用认证时为空
+func RPCCallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(rpcCallerKey{}).(string)
+	return caller
+}
+
+// rpcSigningPayload 签名内容，各字段以换行分隔
+func rpcSigningPayload(storeID, requestID, timestamp, nonce string, body []byte) []byte {
+	digest := sha256.Sum256(body)
+	var payload bytes.Buffer
+	for _, field := range []string{storeID, requestID, timestamp, nonce, hex.EncodeToString(digest[:])} {
+		payload.WriteString(field)
+		payload.WriteByte('\n')
+	}
+	return payload.Bytes()
+}
+
+func rpcSignature(secret, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RPCSigner 用集群共享密钥为发出的RPC请求签名
+type RPCSigner struct {
+	storeID string
+	secret  []byte
+	now     func() time.Time
+}
+
+// NewRPCSigner 创建签名器，storeID为本Store的ID
+func NewRPCSigner(storeID string, secret []byte) *RPCSigner {
+	return &RPCSigner{storeID: storeID, secret: secret, now: time.Now}
+}
+
+// Sign 为一次发送设置认证请求头，重试时需重新签名
+func (s *RPCSigner) Sign(req *http.Request, requestID string, body []byte) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	timestamp := strconv.FormatInt(s.now().UnixMilli(), 10)
+	nonceHex := hex.EncodeToString(nonce)
+
+	req.Header.Set(headerRPCStoreID, s.storeID)
+	req.Header.Set(headerRPCRequestID, requestID)
+	req.Header.Set(headerRPCTimestamp, timestamp)
+	req.Header.Set(headerRPCNonce, nonceHex)
+	req.Header.Set(headerRPCSignature, rpcSignature(s.secret, rpcSigningPayload(s.storeID, requestID, timestamp, nonceHex, body)))
+	return nil
+}
+
+// SharedSecretAuthenticator 校验共享密钥签名并拒绝重放
+type SharedSecretAuthenticator struct {
+	secret  []byte
+	maxSkew time.Duration
+	now     func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // nonce -> 过期时间
+	lastSweep time.Time
+}
+
+// NewSharedSecretAuthenticator 创建共享密钥认证，maxSkew为允许的时钟偏差，不大于0时为5分钟
+func NewSharedSecretAuthenticator(secret []byte, maxSkew time.Duration) *SharedSecretAuthenticator {
+	if maxSkew <= 0 {
+		maxSkew = defaultMaxClockSkew
+	}
+	return &SharedSecretAuthenticator{
+		secret:  secret,
+		maxSkew: maxSkew,
+		now:     time.Now,
+		seen:    make(map[string]time.Time),
+	}
+}
+
+// VerifyHeaders 在读取请求体之前校验签名字段齐全、时间戳在窗口内且nonce未出现过
+func (a *SharedSecretAuthenticator) VerifyHeaders(r *http.Request) error {
+	_, err := a.verifyHeaders(r, a.now())
+	return err
+}
+
+// verifyHeaders 返回请求头中的时间戳
+func (a *SharedSecretAuthenticator) verifyHeaders(r *http.Request, now time.Time) (int64, error) {
+	storeID := r.Header.Get(headerRPCStoreID)
+	timestamp := r.Header.Get(headerRPCTimestamp)
+	nonce := r.Header.Get(headerRPCNonce)
+	if storeID == "" || timestamp == "" || nonce == "" || r.Header.Get(headerRPCSignature) == "" {
+		return 0, fmt.Errorf("%w: missing signature headers", ErrRPCUnauthenticated)
+	}
+
+	millis, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: bad timestamp", ErrRPCUnauthenticated)
+	}
+	if skew := now.Sub(time.UnixMilli(millis)); skew > a.maxSkew || skew < -a.maxSkew {
+		return 0, fmt.Errorf("%w: timestamp from %s is off by %v", ErrRPCUnauthenticated, storeID, skew)
+	}
+
+	a.mu.Lock()
+	_, seen := a.seen[nonce]
+	a.mu.Unlock()
+	if seen {
+		return 0, fmt.Errorf("%w: nonce %s from %s", ErrRPCReplayed, nonce, storeID)
+	}
+	return millis, nil
+}
+
+// Authenticate 校验签名、时间戳与nonce，返回调用方StoreID
+func (a *SharedSecretAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	now := a.now()
+	millis, err := a.verifyHeaders(r, now)
+	if err != nil {
+		return "", err
+	}
+	storeID := r.Header.Get(headerRPCStoreID)
+	nonce := r.Header.Get(headerRPCNonce)
+
+	expected := rpcSignature(a.secret, rpcSigningPayload(storeID, r.Header.Get(headerRPCRequestID), r.Header.Get(headerRPCTimestamp), nonce, body))
+	if !hmac.Equal([]byte(r.Header.Get(headerRPCSignature)), []byte(expected)) {
+		return "", fmt.Errorf("%w: bad signature from %s", ErrRPCUnauthenticated, storeID)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.lastSweep) >= a.maxSkew {
+		for seenNonce, expiry := range a.seen {
+			if now.After(expiry) {
+				delete(a.seen, seenNonce)
+			}
+		}
+		a.lastSweep = now
+	}
+	if _, exists := a.seen[nonce]; exists {
+		return "", fmt.Errorf("%w: nonce %s from %s", ErrRPCReplayed, nonce, storeID)
+	}
+	// 超出时间窗口的请求已被拒绝，nonce只需记住到窗口结束
+	a.seen[nonce] = time.UnixMilli(millis).Add(a.maxSkew)
+	return storeID, nil
+}
+
+// TLSIdentityAuthenticator 以已验证的客户端证书作为调用方身份，服务端需要求客户端证书
+type TLSIdentityAuthenticator struct {
+	allowed []string
+}
+
+// NewTLSIdentityAuthenticator 创建双向TLS身份认证，allowed为允许的证书CN或DNS SAN，为空时接受CA签发的任意证书
+func NewTLSIdentityAuthenticator(allowed []string) *TLSIdentityAuthenticator {
+	return &TLSIdentityAuthenticator{allowed: allowed}
+}
+
+// VerifyHeaders 校验客户端证书，不需要请求体
+func (a *TLSIdentityAuthenticator) VerifyHeaders(r *http.Request) error {
+	_, err := a.Authenticate(r, nil)
+	return err
+}
+
+// Authenticate 返回客户端证书的CN
+func (a *TLSIdentityAuthenticator) Authenticate(r *http.Request, body []byte) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", fmt.Errorf("%w: no verified client certificate", ErrRPCUnauthenticated)
+	}
+	cert := r.TLS.VerifiedChains[0][0]
+	if len(a.allowed) == 0 {
+		return cert.Subject.CommonName, nil
+	}
+	for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+		if slices.Contains(a.allowed, name) {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("%w: certificate %q is not allowed", ErrRPCUnauthenticated, cert.Subject.CommonName)
+}
+
+// rpcAuthMiddleware 认证/rpc请求，失败返回401，认证后的调用方身份放入请求上下文
+// 请求头未通过校验时不读取请求体，请求体超过maxBodySize时返回413
+func rpcAuthMiddleware(auth RPCAuthenticator, maxBodySize int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if verifier, ok := auth.(rpcHeaderVerifier); ok {
+				if err := verifier.VerifyHeaders(r); err != nil {
+					log.Printf("rejected rpc from %s: %v", r.RemoteAddr, err)
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			caller, err := auth.Authenticate(r, body)
+			if err != nil {
+				log.Printf("rejected rpc from %s: %v", r.RemoteAddr, err)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), rpcCallerKey{}, caller)))
+		})
+	}
+}
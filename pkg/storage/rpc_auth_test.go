@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newAuthTestServer(t *testing.T, auth RPCAuthenticator) *HTTPStoreRPCServer {
+	t.Helper()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	server := NewHTTPStoreRPCServer(store)
+	server.SetAuthenticator(auth)
+	server.RegisterHandler("whoami", func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		return map[string]string{"caller": RPCCallerFromContext(ctx)}, nil
+	})
+	return server
+}
+
+func TestSharedSecretRPCAuth(t *testing.T) {
+	ctx := context.Background()
+	auth := NewSharedSecretAuthenticator([]byte("cluster-secret"), time.Minute)
+	server := newAuthTestServer(t, auth)
+
+	// 记录最后一次签名请求，用于重放
+	var lastHeader http.Header
+	var lastBody []byte
+	handler := server.Handler()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/rpc" {
+			lastHeader = r.Header.Clone()
+			lastBody, _ = io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(lastBody))
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPStoreRPCClient(time.Second)
+	client.SetRequestSigner(NewRPCSigner("store_a", []byte("cluster-secret")))
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Expected signed client to connect: %v", err)
+	}
+	response, err := client.makeRequest(ctx, "whoami", nil)
+	if err != nil || response.Data["caller"] != "store_a" {
+		t.Fatalf("Expected caller store_a, got %+v %v", response, err)
+	}
+
+	// 重放同一请求被拒绝
+	req, _ := http.NewRequest("POST", ts.URL+"/rpc", bytes.NewReader(lastBody))
+	req.Header = lastHeader
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected replayed request to be rejected, got %v %v", resp, err)
+	}
+
+	// 未签名、密钥错误以及时间戳过期的请求被拒绝
+	unsigned := NewHTTPStoreRPCClient(time.Second)
+	wrongKey := NewHTTPStoreRPCClient(time.Second)
+	wrongKey.SetRequestSigner(NewRPCSigner("store_a", []byte("other-secret")))
+	staleSigner := NewRPCSigner("store_a", []byte("cluster-secret"))
+	staleSigner.now = func() time.Time { return time.Now().Add(-2 * time.Minute) }
+	stale := NewHTTPStoreRPCClient(time.Second)
+	stale.SetRequestSigner(staleSigner)
+	for name, c := range map[string]*HTTPStoreRPCClient{"unsigned": unsigned, "wrong key": wrongKey, "stale": stale} {
+		if err := c.Connect(ctx, ts.URL); err == nil {
+			t.Errorf("Expected %s client to be rejected", name)
+		}
+	}
+
+	// 健康检查接口不需要认证
+	if resp, err := http.Get(ts.URL + "/health"); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected /health to stay open, got %v %v", resp, err)
+	}
+}
+
+func TestTLSIdentityRPCAuth(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCert(t, dir, "ca", nil, nil)
+	writeTestCert(t, dir, "server", ca, caKey)
+	writeTestCert(t, dir, "store_a", ca, caKey)
+	writeTestCert(t, dir, "intruder", ca, caKey)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	serverTLS, err := (&TLSConfig{CertFile: path("server.crt"), KeyFile: path("server.key"), CAFile: path("ca.crt")}).ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to build server tls config: %v", err)
+	}
+	server := newAuthTestServer(t, NewTLSIdentityAuthenticator([]string{"store_a"}))
+	ts := httptest.NewUnstartedServer(server.Handler())
+	ts.TLS = serverTLS
+	ts.StartTLS()
+	defer ts.Close()
+
+	newClient := func(name string) *HTTPStoreRPCClient {
+		clientTLS, err := (&TLSConfig{CertFile: path(name + ".crt"), KeyFile: path(name + ".key"), CAFile: path("ca.crt")}).ClientTLSConfig()
+		if err != nil {
+			t.Fatalf("Failed to build client tls config: %v", err)
+		}
+		client := NewHTTPStoreRPCClient(time.Second)
+		client.SetTLSConfig(clientTLS)
+		client.SetRetryCount(0)
+		return client
+	}
+
+	ctx := context.Background()
+	allowed := newClient("store_a")
+	if err := allowed.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Expected store_a to connect: %v", err)
+	}
+	if response, err := allowed.makeRequest(ctx, "whoami", nil); err != nil || response.Data["caller"] != "store_a" {
+		t.Errorf("Expected caller store_a, got %+v %v", response, err)
+	}
+	if err := newClient("intruder").Connect(ctx, ts.URL); err == nil {
+		t.Errorf("Expected a certificate outside the allowed identities to be rejected")
+	}
+}
+
+func TestRPCAuthChecksHeadersBeforeBody(t *testing.T) {
+	server := newAuthTestServer(t, NewSharedSecretAuthenticator([]byte("cluster-secret"), time.Minute))
+	server.SetMaxRequestBodySize(1024)
+	handler := server.Handler()
+
+	// 未签名的请求不读取请求体
+	body := &countingReader{Reader: strings.NewReader(strings.Repeat("x", 4096))}
+	req := httptest.NewRequest("POST", "/rpc", body)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized || body.read != 0 {
+		t.Errorf("Expected unsigned request to be rejected before reading the body, got %d after %d bytes", rec.Code, body.read)
+	}
+
+	// 签名请求的请求体超过上限时返回413
+	large := []byte(strings.Repeat("x", 4096))
+	req = httptest.NewRequest("POST", "/rpc", bytes.NewReader(large))
+	if err := NewRPCSigner("store_a", []byte("cluster-secret")).Sign(req, "req-1", large); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected oversized body to be refused, got %d", rec.Code)
+	}
+}
+
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestGRPCSharedSecretAuth(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(store)
+	server.SetAuthenticator(NewSharedSecretAuthenticator([]byte("cluster-secret"), time.Minute))
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	// 未签名与密钥错误的调用被拒绝
+	unsigned := NewGRPCStoreRPCClient(time.Second)
+	if err := unsigned.Connect(ctx, address); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected unsigned gRPC call to be rejected, got %v", err)
+	}
+	wrongKey := NewGRPCStoreRPCClient(time.Second)
+	wrongKey.SetRequestSigner(NewRPCSigner("store_a", []byte("other-secret")))
+	if err := wrongKey.Connect(ctx, address); err == nil {
+		t.Errorf("Expected gRPC call signed with another key to be rejected")
+	}
+
+	// 签名的一元调用与流式调用通过认证
+	pool := NewStoreRPCClientPoolWithTransport(TransportGRPC, time.Second)
+	pool.SetRequestSigner(NewRPCSigner("store_a", []byte("cluster-secret")))
+	defer pool.Close()
+	client, err := pool.GetClient(ctx, store.StoreID, address)
+	if err != nil {
+		t.Fatalf("Expected signed client to connect: %v", err)
+	}
+	msg := &Message{ConvID: "conv_auth", SenderID: 1, CreateTime: time.Now(), Data: []byte("signed")}
+	if _, err := client.AddMessage(ctx, &AddMessageRequest{TimelineKey: "conv_auth", Message: msg}); err != nil {
+		t.Fatalf("Expected signed call to succeed: %v", err)
+	}
+	var streamed int
+	err = client.(StoreMessageStreamer).StreamMessages(ctx, &StreamMessagesRequest{TimelineKey: "conv_auth"}, func(*Message) error {
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != 1 {
+		t.Errorf("Expected signed stream to return 1 message, got %d %v", streamed, err)
+	}
+}
+
+func TestRPCServersRefuseUnauthenticatableTransport(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	auth := NewTLSIdentityAuthenticator(nil)
+
+	grpcServer := NewGRPCStoreRPCServer(store)
+	grpcServer.SetAuthenticator(auth)
+	if err := grpcServer.Start("127.0.0.1:0"); err == nil {
+		grpcServer.Stop(context.Background())
+		t.Errorf("Expected gRPC server with tls identity auth and no TLS to refuse to start")
+	}
+	httpServer := NewHTTPStoreRPCServer(store)
+	httpServer.SetAuthenticator(auth)
+	if err := httpServer.Start("127.0.0.1:0"); err == nil {
+		httpServer.Stop(context.Background())
+		t.Errorf("Expected HTTP server with tls identity auth and no TLS to refuse to start")
+	}
+}
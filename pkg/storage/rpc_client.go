@@ -3,7 +3,9 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,6 +13,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HTTPStoreRPCClient HTTP实现的Store RPC客户端
@@ -21,36 +25,46 @@ type HTTPStoreRPCClient struct {
 	connected  bool
 	timeout    time.Duration
 	headers    map[string]string
-	retryCount int
+	retry      RetryPolicy
+	budget     *RetryBudget
+	breaker    *CircuitBreaker
+	signer     *RPCSigner
 }
 
 // NewHTTPStoreRPCClient 创建HTTP RPC客户端
 func NewHTTPStoreRPCClient(timeout time.Duration) *HTTPStoreRPCClient {
+	retry := DefaultRetryPolicy()
 	return &HTTPStoreRPCClient{
 		client: &http.Client{
 			Timeout: timeout,
 		},
-		timeout:    timeout,
-		headers:    make(map[string]string),
-		retryCount: 3,
+		timeout: timeout,
+		headers: make(map[string]string),
+		retry:   retry,
+		budget:  NewRetryBudget(retry),
 	}
 }
 
 // Connect 连接到Store服务
 func (c *HTTPStoreRPCClient) Connect(ctx context.Context, address string) error {
 	c.mu.Lock()
-	defer c.mu.Unlock()
-	
 	c.address = address
+	c.connected = false
+	c.mu.Unlock()
 	
-	// 执行健康检查验证连接
-	req := &HealthCheckRequest{Ping: "ping"}
-	_, err := c.healthCheck(ctx, req)
+	// 执行健康检查验证连接（连接建立前不能走makeRequest的连接状态检查）
+	response, err := c.send(ctx, address, MethodHealthCheck, &HealthCheckRequest{Ping: "ping"})
+	if err == nil {
+		var result HealthCheckResponse
+		err = parseResponse(response, &result)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to connect to store %s: %w", address, err)
 	}
 	
+	c.mu.Lock()
 	c.connected = true
+	c.mu.Unlock()
 	return nil
 }
 
@@ -78,11 +92,51 @@ func (c *HTTPStoreRPCClient) SetHeader(key, value string) {
 	c.headers[key] = value
 }
 
+// SetTLSConfig 设置TLS配置，服务端地址需使用https
+// 配置中带有证书时握手会出示客户端证书，用于双向TLS
+func (c *HTTPStoreRPCClient) SetTLSConfig(config *tls.Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	c.client = &http.Client{
+		Timeout:   c.timeout,
+		Transport: transport,
+	}
+}
+
+// SetCircuitBreaker 设置熔断器，之后的请求（包括Connect的健康检查）都经过熔断器
+// 同一Store的客户端应共用CircuitBreakerGroup中的同一个熔断器
+func (c *HTTPStoreRPCClient) SetCircuitBreaker(breaker *CircuitBreaker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.breaker = breaker
+}
+
+// SetRequestSigner 设置请求签名器，之后的每次发送（包括重试）都带有签名
+func (c *HTTPStoreRPCClient) SetRequestSigner(signer *RPCSigner) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.signer = signer
+}
+
 // SetRetryCount 设置重试次数
 func (c *HTTPStoreRPCClient) SetRetryCount(count int) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	c.retryCount = count
+	c.retry.MaxRetries = count
+}
+
+// SetRetryPolicy 设置重试策略与重试预算，budget为nil时客户端按策略使用自己的预算
+// 同一Store的客户端应共用RetryBudgets中的同一个预算
+func (c *HTTPStoreRPCClient) SetRetryPolicy(policy RetryPolicy, budget *RetryBudget) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if budget == nil {
+		budget = NewRetryBudget(policy)
+	}
+	c.retry = policy
+	c.budget = budget
 }
 
 // makeRequest 发送RPC请求的通用方法
@@ -93,16 +147,62 @@ func (c *HTTPStoreRPCClient) makeRequest(ctx context.Context, method string, par
 		return nil, fmt.Errorf("client not connected")
 	}
 	address := c.address
+	c.mu.RUnlock()
+	
+	return c.send(ctx, address, method, params)
+}
+
+// send 经过熔断器发送请求，熔断器打开时直接返回ErrCircuitBreakerOpen
+// 重试全部失败才记为一次失败，RPC返回的业务错误不计入
+func (c *HTTPStoreRPCClient) send(ctx context.Context, address, method string, params interface{}) (*StoreRPCResponse, error) {
+	c.mu.RLock()
+	breaker := c.breaker
+	c.mu.RUnlock()
+	if breaker == nil {
+		return c.sendRequest(ctx, address, method, params)
+	}
+	
+	var response *StoreRPCResponse
+	err := breaker.Call(ctx, func() error {
+		var err error
+		response, err = c.sendRequest(ctx, address, method, params)
+		return err
+	})
+	if errors.Is(err, ErrCircuitBreakerOpen) {
+		return nil, fmt.Errorf("store %s: %w", address, err)
+	}
+	return response, err
+}
+
+// sendRequest 向指定地址发送RPC请求，不检查连接状态
+// 每次调用生成一个客户端span，追踪上下文通过请求头传给服务端
+func (c *HTTPStoreRPCClient) sendRequest(ctx context.Context, address, method string, params interface{}) (response *StoreRPCResponse, err error) {
+	ctx, span := startRPCSpan(ctx, trace.SpanKindClient, "http", method, address)
+	defer func() {
+		if err == nil && !response.Success {
+			span.SetStatus(codes.Error, response.Error)
+		}
+		endRPCSpan(span, err)
+	}()
+	
+	c.mu.RLock()
 	headers := make(map[string]string)
 	for k, v := range c.headers {
 		headers[k] = v
 	}
-	retryCount := c.retryCount
+	policy := c.retry
+	budget := c.budget
+	client := c.client
+	signer := c.signer
 	c.mu.RUnlock()
 	
 	// 构建请求
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
 	request := &StoreRPCRequest{
-		RequestID: uuid.New().String(),
+		RequestID: requestID,
 		Method:    method,
 		Params:    make(map[string]interface{}),
 		Timestamp: time.Now(),
@@ -115,7 +215,7 @@ func (c *HTTPStoreRPCClient) makeRequest(ctx context.Context, method string, par
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal params: %w", err)
 		}
-		err = json.Unmarshal(paramsBytes, &request.Params)
+		err = unmarshalRPCJSON(paramsBytes, &request.Params)
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal params: %w", err)
 		}
@@ -127,63 +227,90 @@ func (c *HTTPStoreRPCClient) makeRequest(ctx context.Context, method string, par
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 	
-	var lastErr error
-	for i := 0; i <= retryCount; i++ {
-		// 创建HTTP请求
-		httpReq, err := http.NewRequestWithContext(ctx, "POST", address+"/rpc", bytes.NewReader(requestBytes))
-		if err != nil {
-			lastErr = fmt.Errorf("failed to create HTTP request: %w", err)
-			continue
+	// 单个请求的总时长预算，包括所有重试
+	if policy.RequestBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, policy.RequestBudget)
+		defer cancel()
+	}
+	
+	budget.deposit()
+	for attempt := 0; ; attempt++ {
+		response, retryable, err := c.attempt(ctx, client, address, headers, signer, requestID, requestBytes)
+		if err == nil || !retryable || attempt >= policy.MaxRetries {
+			return response, err
 		}
 		
-		// 设置请求头
-		httpReq.Header.Set("Content-Type", "application/json")
-		for k, v := range headers {
-			httpReq.Header.Set(k, v)
+		// 剩余时间不足以完成退避或预算用尽时放弃重试
+		backoff := policy.backoff(attempt + 1)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+			return nil, err
 		}
-		
-		// 发送请求
-		resp, err := c.client.Do(httpReq)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to send HTTP request: %w", err)
-			if i < retryCount {
-				time.Sleep(time.Duration(i+1) * 100 * time.Millisecond)
-			}
-			continue
+		if !budget.withdraw() {
+			return nil, fmt.Errorf("retry budget exhausted: %w", err)
 		}
-		
-		// 读取响应
-		respBody, err := io.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			lastErr = fmt.Errorf("failed to read response body: %w", err)
-			continue
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
 		}
-		
-		// 检查HTTP状态码
-		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("HTTP error: %d %s", resp.StatusCode, string(respBody))
-			continue
-		}
-		
-		// 解析响应
-		var response StoreRPCResponse
-		err = json.Unmarshal(respBody, &response)
-		if err != nil {
-			lastErr = fmt.Errorf("failed to unmarshal response: %w", err)
-			continue
+	}
+}
+
+// attempt 发送一次HTTP请求，返回的retryable表示错误是否值得重试
+func (c *HTTPStoreRPCClient) attempt(ctx context.Context, client *http.Client, address string, headers map[string]string, signer *RPCSigner, requestID string, requestBytes []byte) (*StoreRPCResponse, bool, error) {
+	// 创建HTTP请求
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", address+"/rpc", bytes.NewReader(requestBytes))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	
+	// 设置请求头
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+	injectHTTPTrace(ctx, httpReq.Header)
+	if signer != nil {
+		if err := signer.Sign(httpReq, requestID, requestBytes); err != nil {
+			return nil, false, err
 		}
-		
-		return &response, nil
 	}
 	
-	return nil, lastErr
+	// 发送请求
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, retryableTransportError(err), fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	
+	// 读取响应
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, retryableTransportError(err), fmt.Errorf("failed to read response body: %w", err)
+	}
+	
+	// 检查HTTP状态码
+	if resp.StatusCode != http.StatusOK {
+		return nil, retryableStatus(resp.StatusCode), httpStatusError(resp.StatusCode, respBody)
+	}
+	
+	// 解析响应
+	var response StoreRPCResponse
+	err = unmarshalRPCJSON(respBody, &response)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	
+	return &response, false, nil
 }
 
-// parseResponse 解析响应数据的通用方法
+// parseResponse 解析响应数据的通用方法，失败的响应返回带错误码的RPCError
 func parseResponse[T any](response *StoreRPCResponse, result *T) error {
 	if !response.Success {
-		return fmt.Errorf("RPC error: %s", response.Error)
+		return responseError(response.Code, response.Error)
 	}
 	
 	if response.Data == nil {
@@ -303,6 +430,129 @@ func (c *HTTPStoreRPCClient) GetMessages(ctx context.Context, req *GetMessagesRe
 	return &result, nil
 }
 
+// EditMessage 编辑消息
+func (c *HTTPStoreRPCClient) EditMessage(ctx context.Context, req *EditMessageRequest) (*EditMessageResponse, error) {
+	response, err := c.makeRequest(ctx, MethodEditMessage, req)
+	if err != nil {
+		return nil, err
+	}
+	
+	var result EditMessageResponse
+	err = parseResponse(response, &result)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &result, nil
+}
+
+// DeleteMessage 删除消息
+func (c *HTTPStoreRPCClient) DeleteMessage(ctx context.Context, req *DeleteMessageRequest) (*DeleteMessageResponse, error) {
+	response, err := c.makeRequest(ctx, MethodDeleteMessage, req)
+	if err != nil {
+		return nil, err
+	}
+	
+	var result DeleteMessageResponse
+	err = parseResponse(response, &result)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &result, nil
+}
+
+// StreamMessages 通过/rpc/stream流式读取消息，服务端按批写出，客户端逐条调用fn
+// 流式请求不受客户端超时限制也不重试，由调用方通过ctx控制；中断后可以最后收到的SeqID为AfterSeqID续读
+func (c *HTTPStoreRPCClient) StreamMessages(ctx context.Context, req *StreamMessagesRequest, fn func(*Message) error) (err error) {
+	c.mu.RLock()
+	if !c.connected {
+		c.mu.RUnlock()
+		return fmt.Errorf("client not connected")
+	}
+	address := c.address
+	headers := make(map[string]string)
+	for k, v := range c.headers {
+		headers[k] = v
+	}
+	client := &http.Client{Transport: c.client.Transport}
+	signer := c.signer
+	c.mu.RUnlock()
+	
+	ctx, span := startRPCSpan(ctx, trace.SpanKindClient, "http", MethodStreamMessages, address)
+	defer func() { endRPCSpan(span, err) }()
+	
+	requestID := RequestIDFromContext(ctx)
+	if requestID == "" {
+		requestID = uuid.New().String()
+	}
+	request := &StoreRPCRequest{
+		RequestID: requestID,
+		Method:    MethodStreamMessages,
+		Params:    make(map[string]interface{}),
+		Timestamp: time.Now(),
+	}
+	paramsBytes, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("failed to marshal params: %w", err)
+	}
+	if err := unmarshalRPCJSON(paramsBytes, &request.Params); err != nil {
+		return fmt.Errorf("failed to unmarshal params: %w", err)
+	}
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+	
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", address+"/rpc/stream", bytes.NewReader(requestBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+	injectHTTPTrace(ctx, httpReq.Header)
+	if signer != nil {
+		if err := signer.Sign(httpReq, requestID, requestBytes); err != nil {
+			return err
+		}
+	}
+	
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return httpStatusError(resp.StatusCode, respBody)
+	}
+	
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var chunk StreamMessagesChunk
+		if err := decoder.Decode(&chunk); err != nil {
+			// 没有收到结束行说明服务端中途断开，已收到的消息不完整
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return fmt.Errorf("failed to read message stream: %w", err)
+		}
+		for _, msg := range chunk.Messages {
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+		if chunk.Error != "" {
+			return responseError(chunk.Code, chunk.Error)
+		}
+		if chunk.Done {
+			return nil
+		}
+	}
+}
+
 // 块操作方法
 
 // GetTimelineBlock 获取Timeline块
@@ -360,22 +610,104 @@ func (c *HTTPStoreRPCClient) healthCheck(ctx context.Context, req *HealthCheckRe
 	return &result, nil
 }
 
+// 事务参与者方法
+
+// PrepareTransaction 请求参与者准备事务中的操作
+func (c *HTTPStoreRPCClient) PrepareTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.transaction(ctx, MethodPrepareTransaction, req)
+}
+
+// CommitTransaction 请求参与者提交事务中的操作
+func (c *HTTPStoreRPCClient) CommitTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.transaction(ctx, MethodCommitTransaction, req)
+}
+
+// AbortTransaction 请求参与者回滚事务中的操作
+func (c *HTTPStoreRPCClient) AbortTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return c.transaction(ctx, MethodAbortTransaction, req)
+}
+
+// transaction 发送事务参与者请求，服务端未能处理（如不支持该方法）同样视为参与者拒绝
+func (c *HTTPStoreRPCClient) transaction(ctx context.Context, method string, req *TransactionRequest) (*TransactionResponse, error) {
+	response, err := c.makeRequest(ctx, method, req)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return &TransactionResponse{Code: responseError(response.Code, response.Error).Code, Error: response.Error}, nil
+	}
+	
+	var result TransactionResponse
+	err = parseResponse(response, &result)
+	if err != nil {
+		return nil, err
+	}
+	
+	return &result, nil
+}
+
 // StoreRPCClientPool RPC客户端连接池
 type StoreRPCClientPool struct {
-	mu      sync.RWMutex
-	clients map[string]StoreRPCClient
-	timeout time.Duration
+	mu        sync.RWMutex
+	clients   map[string]StoreRPCClient
+	timeout   time.Duration
+	transport RPCTransport
+	tlsConfig *tls.Config
+	breakers  *CircuitBreakerGroup
+	retries   *RetryBudgets
+	signer    *RPCSigner
 }
 
-// NewStoreRPCClientPool 创建RPC客户端连接池
+// NewStoreRPCClientPool 创建RPC客户端连接池，默认使用HTTP传输
 func NewStoreRPCClientPool(timeout time.Duration) *StoreRPCClientPool {
+	return NewStoreRPCClientPoolWithTransport(TransportHTTP, timeout)
+}
+
+// NewStoreRPCClientPoolWithTransport 创建指定传输方式的RPC客户端连接池
+func NewStoreRPCClientPoolWithTransport(transport RPCTransport, timeout time.Duration) *StoreRPCClientPool {
 	return &StoreRPCClientPool{
-		clients: make(map[string]StoreRPCClient),
-		timeout: timeout,
+		clients:   make(map[string]StoreRPCClient),
+		timeout:   timeout,
+		transport: transport,
 	}
 }
 
-// GetClient 获取或创建客户端连接
+// SetTLSConfig 设置之后新建客户端使用的TLS配置
+func (p *StoreRPCClientPool) SetTLSConfig(config *tls.Config) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tlsConfig = config
+}
+
+// SetCircuitBreakers 设置按Store划分的熔断器，之后新建的HTTP客户端都经过对应Store的熔断器
+func (p *StoreRPCClientPool) SetCircuitBreakers(breakers *CircuitBreakerGroup) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.breakers = breakers
+}
+
+// SetRetryPolicy 设置之后新建的HTTP客户端使用的重试策略，同一Store的客户端共用一个重试预算
+func (p *StoreRPCClientPool) SetRetryPolicy(policy RetryPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries = NewRetryBudgets(policy)
+}
+
+// SetRequestSigner 设置之后新建的HTTP与gRPC客户端使用的请求签名器
+func (p *StoreRPCClientPool) SetRequestSigner(signer *RPCSigner) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.signer = signer
+}
+
+// CircuitBreakers 返回连接池使用的熔断器，未设置时为nil
+func (p *StoreRPCClientPool) CircuitBreakers() *CircuitBreakerGroup {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.breakers
+}
+
+// GetClient 获取或创建客户端连接，Store的熔断器打开时直接返回ErrCircuitBreakerOpen
 func (p *StoreRPCClientPool) GetClient(ctx context.Context, storeID, address string) (StoreRPCClient, error) {
 	p.mu.RLock()
 	client, exists := p.clients[storeID]
@@ -393,10 +725,30 @@ func (p *StoreRPCClientPool) GetClient(ctx context.Context, storeID, address str
 	if exists && client.IsConnected() {
 		return client, nil
 	}
+	if p.breakers != nil && p.breakers.Rejecting(storeID) {
+		return nil, fmt.Errorf("store %s: %w", storeID, ErrCircuitBreakerOpen)
+	}
 	
 	// 创建新客户端
-	client = NewHTTPStoreRPCClient(p.timeout)
-	err := client.Connect(ctx, address)
+	client, err := NewStoreRPCClientWithTLS(p.transport, p.timeout, p.tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient, ok := client.(*HTTPStoreRPCClient); ok {
+		if p.breakers != nil {
+			httpClient.SetCircuitBreaker(p.breakers.Get(storeID))
+		}
+		if p.retries != nil {
+			httpClient.SetRetryPolicy(p.retries.Policy(), p.retries.Get(storeID))
+		}
+		if p.signer != nil {
+			httpClient.SetRequestSigner(p.signer)
+		}
+	}
+	if grpcClient, ok := client.(*GRPCStoreRPCClient); ok && p.signer != nil {
+		grpcClient.SetRequestSigner(p.signer)
+	}
+	err = client.Connect(ctx, address)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to store %s: %w", storeID, err)
 	}
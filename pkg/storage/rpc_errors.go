@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"imy/pkg/errs"
+	"imy/pkg/moderation"
+)
+
+// rpcErrorDomain gRPC状态详情中ErrorInfo的Domain，Reason为RPC错误码
+const rpcErrorDomain = "imy.storage"
+
+// ErrorCode 实现errs.Coder，RPC错误码归类为通用错误码
+func (e *RPCError) ErrorCode() errs.Code {
+	switch e.Code {
+	case ErrCodeSuccess:
+		return ""
+	case ErrCodeInvalidRequest, ErrCodeInvalidMessage, ErrCodeMessageRejected:
+		return errs.InvalidArgument
+	case ErrCodeMethodNotFound:
+		return errs.Unimplemented
+	case ErrCodeTimeout:
+		return errs.Timeout
+	case ErrCodeUnauthenticated:
+		return errs.Unauthorized
+	case ErrCodePermissionDenied:
+		return errs.PermissionDenied
+	case ErrCodeTimelineNotFound, ErrCodeBlockNotFound, ErrCodeMessageNotFound:
+		return errs.NotFound
+	case ErrCodeTimelineExists, ErrCodeMigrationFailed, ErrCodeTransactionConflict, ErrCodeTransactionNotPrepared:
+		return errs.Conflict
+	case ErrCodeStorageFull, ErrCodeOverloaded:
+		return errs.Overloaded
+	case ErrCodeUnavailable:
+		return errs.Unavailable
+	case ErrCodeInternalError:
+		return errs.Internal
+	}
+	return errs.Unknown
+}
+
+// GRPCStatus 使RPCError可以直接作为gRPC状态返回，RPC错误码放在ErrorInfo中
+func (e *RPCError) GRPCStatus() *status.Status {
+	return rpcStatus(e, e.Error())
+}
+
+func rpcStatus(e *RPCError, message string) *status.Status {
+	st := status.New(errs.GRPCCode(e.ErrorCode()), message)
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{Reason: strconv.Itoa(e.Code), Domain: rpcErrorDomain})
+	if err != nil {
+		return st
+	}
+	return detailed
+}
+
+// toRPCError 将服务层错误转换为RPCError，未识别的错误为ErrCodeInternalError
+func toRPCError(err error) *RPCError {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	code := ErrCodeInternalError
+	switch {
+	case errors.Is(err, ErrTimelineNotFound):
+		code = ErrCodeTimelineNotFound
+	case errors.Is(err, ErrTimelineExists):
+		code = ErrCodeTimelineExists
+	case errors.Is(err, ErrMessageNotFound):
+		code = ErrCodeMessageNotFound
+	case errors.Is(err, ErrNotMessageAuthor):
+		code = ErrCodePermissionDenied
+	case errors.Is(err, ErrMessageDeleted), errors.Is(err, ErrMessageNotEditable):
+		code = ErrCodeInvalidMessage
+	case errors.Is(err, moderation.ErrRejected):
+		code = ErrCodeMessageRejected
+	case errors.Is(err, ErrStorageFull):
+		code = ErrCodeStorageFull
+	case errors.Is(err, ErrTransactionConflict):
+		code = ErrCodeTransactionConflict
+	case errors.Is(err, ErrTransactionNotPrepared):
+		code = ErrCodeTransactionNotPrepared
+	case errors.Is(err, ErrTenantQuotaExceeded), errors.Is(err, ErrCircuitBreakerOpen), errors.Is(err, ErrPoolExhausted):
+		code = ErrCodeOverloaded
+	case errors.Is(err, ErrStoreDecommissioned), errors.Is(err, ErrReplicaBehind), errors.Is(err, ErrPoolClosed),
+		errors.Is(err, ErrMigrationDraining), errors.Is(err, ErrLockManagerClosed):
+		code = ErrCodeUnavailable
+	case errors.Is(err, ErrRPCUnauthenticated), errors.Is(err, ErrRPCReplayed):
+		code = ErrCodeUnauthenticated
+	case errors.Is(err, context.DeadlineExceeded):
+		code = ErrCodeTimeout
+	}
+	return NewRPCError(code, err.Error())
+}
+
+// responseError 由失败的响应还原RPCError，错误信息保持服务端原样
+// 旧版本服务端不返回错误码，按ErrCodeInternalError处理
+func responseError(code int, message string) *RPCError {
+	if code == ErrCodeSuccess {
+		code = ErrCodeInternalError
+	}
+	return &RPCError{Code: code, Message: message}
+}
+
+// httpStatusError 非200的HTTP响应，按状态码归类
+func httpStatusError(statusCode int, body []byte) error {
+	return errs.New(errs.FromHTTPStatus(statusCode), fmt.Sprintf("HTTP error: %d %s", statusCode, body))
+}
+
+// fromStatusError 还原服务端通过gRPC状态返回的RPCError，没有RPC错误码的状态错误原样返回，
+// 仍可由errs.CodeOf按gRPC状态码归类
+func fromStatusError(err error) error {
+	st, ok := status.FromError(err)
+	if !ok || st.Code() == codes.OK {
+		return err
+	}
+	for _, detail := range st.Details() {
+		info, ok := detail.(*errdetails.ErrorInfo)
+		if !ok || info.GetDomain() != rpcErrorDomain {
+			continue
+		}
+		if code, convErr := strconv.Atoi(info.GetReason()); convErr == nil {
+			return &RPCError{Code: code, Message: st.Message()}
+		}
+	}
+	return err
+}
+
+// statusErrorUnaryClientInterceptor 一元调用返回的状态错误还原为RPCError
+func statusErrorUnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return fromStatusError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+// statusErrorStreamClientInterceptor 流式调用各步骤返回的状态错误还原为RPCError
+func statusErrorStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	if err != nil {
+		return nil, fromStatusError(err)
+	}
+	return &statusErrorClientStream{ClientStream: stream}, nil
+}
+
+type statusErrorClientStream struct {
+	grpc.ClientStream
+}
+
+func (s *statusErrorClientStream) SendMsg(m any) error {
+	return fromStatusError(s.ClientStream.SendMsg(m))
+}
+
+// RecvMsg 正常结束时的io.EOF不是状态错误，原样返回
+func (s *statusErrorClientStream) RecvMsg(m any) error {
+	return fromStatusError(s.ClientStream.RecvMsg(m))
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"imy/pkg/errs"
+)
+
+func TestRPCErrorCodesSurviveTransports(t *testing.T) {
+	ctx := context.Background()
+
+	remote, ts := newTestRemoteStore(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	server := NewGRPCStoreRPCServer(remote)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	msg := &Message{SeqID: 1, ConvID: "conv_errs", SenderID: 1, CreateTime: time.Now(), Data: []byte("hi")}
+	if _, _, err := remote.SubmitMessage("conv_errs", msg, nil); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+
+	for _, tc := range []struct {
+		transport RPCTransport
+		address   string
+	}{
+		{TransportHTTP, ts.URL},
+		{TransportGRPC, address},
+	} {
+		pool := NewStoreRPCClientPoolWithTransport(tc.transport, 5*time.Second)
+		defer pool.Close()
+		client, err := pool.GetClient(ctx, remote.StoreID, tc.address)
+		if err != nil {
+			t.Fatalf("%s: failed to connect: %v", tc.transport, err)
+		}
+
+		_, err = client.EditMessage(ctx, &EditMessageRequest{TimelineKey: "conv_errs", SeqID: 99, SenderID: 1, Data: []byte("x")})
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != ErrCodeMessageNotFound {
+			t.Errorf("%s: expected ErrCodeMessageNotFound, got %v", tc.transport, err)
+		}
+		if !errors.Is(err, ErrMessageNotFound) || !errors.Is(err, errs.ErrNotFound) || errs.CodeOf(err) != errs.NotFound {
+			t.Errorf("%s: expected a not found error, got %v", tc.transport, err)
+		}
+
+		_, err = client.EditMessage(ctx, &EditMessageRequest{TimelineKey: "conv_errs", SeqID: 1, SenderID: 2, Data: []byte("x")})
+		if errs.CodeOf(err) != errs.PermissionDenied {
+			t.Errorf("%s: expected permission denied editing another author's message, got %v", tc.transport, err)
+		}
+	}
+}
+
+func TestRPCErrorFromHTTPStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	client := NewHTTPStoreRPCClient(time.Second)
+	err := client.Connect(context.Background(), ts.URL)
+	if !errs.Is(err, errs.Unauthorized) {
+		t.Errorf("Expected an unauthorized error, got %v", err)
+	}
+
+	// 旧版本服务端不返回错误码
+	err = parseResponse(&StoreRPCResponse{Error: "boom"}, &HealthCheckResponse{})
+	if errs.CodeOf(err) != errs.Internal || err.Error() != "boom" {
+		t.Errorf("Expected an internal error keeping the message, got %v", err)
+	}
+}
+
+func TestToRPCErrorMapsSentinels(t *testing.T) {
+	cases := map[error]int{
+		ErrTimelineNotFound:                     ErrCodeTimelineNotFound,
+		ErrTimelineExists:                       ErrCodeTimelineExists,
+		ErrTenantQuotaExceeded:                  ErrCodeOverloaded,
+		ErrStoreDecommissioned:                  ErrCodeUnavailable,
+		context.DeadlineExceeded:                ErrCodeTimeout,
+		errors.New("disk on fire"):              ErrCodeInternalError,
+		NewRPCError(ErrCodeBlockNotFound, "b1"): ErrCodeBlockNotFound,
+	}
+	for err, code := range cases {
+		rpcErr := toRPCError(err)
+		if rpcErr.Code != code {
+			t.Errorf("%v: expected code %d, got %d", err, code, rpcErr.Code)
+		}
+	}
+	if !errors.Is(responseError(ErrCodeTimelineExists, "exists"), ErrTimelineExists) {
+		t.Error("Expected a remote timeline exists error to match ErrTimelineExists")
+	}
+}
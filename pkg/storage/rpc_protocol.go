@@ -1,8 +1,12 @@
 package storage
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"time"
+
+	"imy/pkg/errs"
 )
 
 // StoreRPCRequest RPC请求基础结构
@@ -17,11 +21,12 @@ type StoreRPCRequest struct {
 
 // StoreRPCResponse RPC响应基础结构
 type StoreRPCResponse struct {
-	RequestID string                 `json:"requestId"` // 对应的请求ID
-	Success   bool                   `json:"success"`   // 是否成功
-	Data      map[string]interface{} `json:"data"`      // 响应数据
-	Error     string                 `json:"error"`     // 错误信息
-	Timestamp time.Time              `json:"timestamp"` // 响应时间戳
+	RequestID string                 `json:"requestId"`      // 对应的请求ID
+	Success   bool                   `json:"success"`        // 是否成功
+	Data      map[string]interface{} `json:"data"`           // 响应数据
+	Error     string                 `json:"error"`          // 错误信息
+	Code      int                    `json:"code,omitempty"` // 错误码，旧版本服务端不返回
+	Timestamp time.Time              `json:"timestamp"`      // 响应时间戳
 }
 
 // Timeline相关RPC方法参数和响应
@@ -41,6 +46,7 @@ type GetTimelineResponse struct {
 type AddMessageRequest struct {
 	TimelineKey string   `json:"timelineKey"`
 	Message     *Message `json:"message"`
+	UserIDs     []string `json:"userIds,omitempty"` // 需要同步写入的用户Timeline
 }
 
 // AddMessageResponse 添加消息响应
@@ -48,22 +54,63 @@ type AddMessageResponse struct {
 	BlockID   string `json:"blockId"`
 	Offset    int64  `json:"offset"`
 	MessageID string `json:"messageId"`
+	HLC       int64  `json:"hlc,omitempty"` // 消息的HLC，复制到副本时随消息传递
+	SeqID     int64  `json:"seqId"`
+	Duplicate bool   `json:"duplicate,omitempty"` // Message.ClientMsgID重复，SeqID为已有消息的SeqID，未写入新消息
 }
 
 // GetMessagesRequest 获取消息请求
+// 设置BeforeSeqID或AfterSeqID时按SeqID游标分页，此时忽略Offset:
+//   - BeforeSeqID: 返回SeqID小于该值的最新Limit条消息（向前翻页，与Store.GetConvMessages一致）
+//   - AfterSeqID: 返回SeqID大于该值的最早Limit条消息（向后翻页），两者同时设置时限定在区间内
+//
+// StartHLC/EndHLC按HLC闭区间过滤（0表示不限制），设置后结果按HLC排序，可与其他Store的结果合并
 type GetMessagesRequest struct {
 	TimelineKey string `json:"timelineKey"`
 	StartTime   int64  `json:"startTime"`
 	EndTime     int64  `json:"endTime"`
 	Limit       int    `json:"limit"`
 	Offset      int    `json:"offset"`
+	BeforeSeqID int64  `json:"beforeSeqId,omitempty"`
+	AfterSeqID  int64  `json:"afterSeqId,omitempty"`
+	StartHLC    int64  `json:"startHlc,omitempty"`
+	EndHLC      int64  `json:"endHlc,omitempty"`
 }
 
 // GetMessagesResponse 获取消息响应
+// NextCursor为继续翻页时应传入的SeqID：向前翻页时作为BeforeSeqID，否则作为AfterSeqID；没有更多消息时为0
 type GetMessagesResponse struct {
-	Messages []*Message `json:"messages"`
-	Total    int        `json:"total"`
-	HasMore  bool       `json:"hasMore"`
+	Messages   []*Message `json:"messages"`
+	Total      int        `json:"total"`
+	HasMore    bool       `json:"hasMore"`
+	NextCursor int64      `json:"nextCursor,omitempty"`
+}
+
+// EditMessageRequest 编辑消息请求，只有原发送者可以编辑
+type EditMessageRequest struct {
+	TimelineKey string   `json:"timelineKey"`
+	SeqID       int64    `json:"seqId"`    // 被编辑消息的SeqID
+	SenderID    uint32   `json:"senderId"` // 操作者
+	Data        []byte   `json:"data"`
+	UserIDs     []string `json:"userIds,omitempty"` // 需要同步写入的用户Timeline
+}
+
+// EditMessageResponse 编辑消息响应
+type EditMessageResponse struct {
+	Message *Message `json:"message"` // 追加的编辑记录
+}
+
+// DeleteMessageRequest 删除消息请求，操作者权限由调用方校验
+type DeleteMessageRequest struct {
+	TimelineKey string   `json:"timelineKey"`
+	SeqID       int64    `json:"seqId"`    // 被删除消息的SeqID
+	SenderID    uint32   `json:"senderId"` // 操作者
+	UserIDs     []string `json:"userIds,omitempty"`
+}
+
+// DeleteMessageResponse 删除消息响应
+type DeleteMessageResponse struct {
+	Message *Message `json:"message"` // 追加的删除墓碑
 }
 
 // CreateTimelineRequest 创建Timeline请求
@@ -100,6 +147,44 @@ type GetTimelineBlockResponse struct {
 	Exists bool           `json:"exists"`
 }
 
+// StreamTimelineBlocksRequest 流式导出Timeline块请求
+type StreamTimelineBlocksRequest struct {
+	TimelineKey  string `json:"timelineKey"`
+	AfterBlockID string `json:"afterBlockId,omitempty"` // 从该块之后开始导出，为空时导出全部
+}
+
+// StreamMessagesRequest 流式读取消息请求，按SeqID升序返回(AfterSeqID, BeforeSeqID)区间内的全部消息
+// BeforeSeqID为0表示读到最新消息；中断后以收到的最后一条消息的SeqID作为AfterSeqID续读
+type StreamMessagesRequest struct {
+	TimelineKey string `json:"timelineKey"`
+	AfterSeqID  int64  `json:"afterSeqId,omitempty"`
+	BeforeSeqID int64  `json:"beforeSeqId,omitempty"`
+}
+
+// StreamMessagesChunk HTTP流式响应中的一行（NDJSON），最后一行Done为true或Error非空
+type StreamMessagesChunk struct {
+	Messages []*Message `json:"messages,omitempty"`
+	Done     bool       `json:"done,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Code     int        `json:"code,omitempty"` // Error对应的错误码
+}
+
+// TimelineBlockData 块传输单元，包含块元数据及其完整消息
+type TimelineBlockData struct {
+	TimelineKey  string         `json:"timelineKey"`
+	TimelineType string         `json:"timelineType"`
+	Block        *TimelineBlock `json:"block"`
+	Messages     []*Message     `json:"messages"`
+}
+
+// ImportTimelineBlocksResponse 块导入响应
+type ImportTimelineBlocksResponse struct {
+	TimelineKey      string `json:"timelineKey"`
+	ImportedBlocks   int32  `json:"importedBlocks"`
+	ImportedMessages int64  `json:"importedMessages"`
+	LastSeqID        int64  `json:"lastSeqId"`
+}
+
 // MigrateTimelineRequest 迁移Timeline请求
 type MigrateTimelineRequest struct {
 	TimelineKey   string `json:"timelineKey"`
@@ -124,10 +209,29 @@ type GetStoreStatsResponse struct {
 	StoreID       string   `json:"storeId"`
 	TimelineCount int      `json:"timelineCount"`
 	BlockCount    int      `json:"blockCount"`
-	TotalSize     int64    `json:"totalSize"`
+	TotalSize     int64    `json:"totalSize"` // 已用容量（字节）
 	Timelines     []string `json:"timelines,omitempty"`
 	Uptime        int64    `json:"uptime"`
 	LastUpdate    int64    `json:"lastUpdate"`
+	MaxCapacity   int64    `json:"maxCapacity"`
+	BlockBytes    int64    `json:"blockBytes"`
+	WALBytes      int64    `json:"walBytes"`
+	TieredBlocks  int      `json:"tieredBlocks,omitempty"` // 已分层到对象存储的块，不计入TotalSize
+	TieredBytes   int64    `json:"tieredBytes,omitempty"`
+}
+
+// TransactionRequest 两阶段提交中协调者发给参与者Store的请求
+type TransactionRequest struct {
+	TransactionID string                  `json:"transactionId"`
+	Participant   *TransactionParticipant `json:"participant"`
+}
+
+// TransactionResponse 参与者的处理结果
+// 参与者拒绝请求时Success为false，Code为RPC错误码；请求未送达或无响应时调用返回错误，可以重试
+type TransactionResponse struct {
+	Success bool   `json:"success"`
+	Code    int    `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
 }
 
 // HealthCheckRequest 健康检查请求
@@ -153,6 +257,8 @@ type StoreRPCService interface {
 	// 消息操作
 	AddMessage(ctx context.Context, req *AddMessageRequest) (*AddMessageResponse, error)
 	GetMessages(ctx context.Context, req *GetMessagesRequest) (*GetMessagesResponse, error)
+	EditMessage(ctx context.Context, req *EditMessageRequest) (*EditMessageResponse, error)
+	DeleteMessage(ctx context.Context, req *DeleteMessageRequest) (*DeleteMessageResponse, error)
 	
 	// 块操作
 	GetTimelineBlock(ctx context.Context, req *GetTimelineBlockRequest) (*GetTimelineBlockResponse, error)
@@ -178,6 +284,8 @@ type StoreRPCClient interface {
 	// 消息操作
 	AddMessage(ctx context.Context, req *AddMessageRequest) (*AddMessageResponse, error)
 	GetMessages(ctx context.Context, req *GetMessagesRequest) (*GetMessagesResponse, error)
+	EditMessage(ctx context.Context, req *EditMessageRequest) (*EditMessageResponse, error)
+	DeleteMessage(ctx context.Context, req *DeleteMessageRequest) (*DeleteMessageResponse, error)
 	
 	// 块操作
 	GetTimelineBlock(ctx context.Context, req *GetTimelineBlockRequest) (*GetTimelineBlockResponse, error)
@@ -196,8 +304,11 @@ const (
 	MethodMigrateTimeline = "MigrateTimeline"
 	
 	// 消息操作方法
-	MethodAddMessage  = "AddMessage"
-	MethodGetMessages = "GetMessages"
+	MethodAddMessage     = "AddMessage"
+	MethodGetMessages    = "GetMessages"
+	MethodEditMessage    = "EditMessage"
+	MethodDeleteMessage  = "DeleteMessage"
+	MethodStreamMessages = "StreamMessages" // 仅用于/rpc/stream流式接口
 	
 	// 块操作方法
 	MethodGetTimelineBlock = "GetTimelineBlock"
@@ -205,6 +316,15 @@ const (
 	// Store状态方法
 	MethodGetStoreStats = "GetStoreStats"
 	MethodHealthCheck   = "HealthCheck"
+	
+	// 事务参与者方法
+	MethodPrepareTransaction = "PrepareTransaction"
+	MethodCommitTransaction  = "CommitTransaction"
+	MethodAbortTransaction   = "AbortTransaction"
+	
+	// checkpoint操作
+	MethodGetCheckpoints    = "GetCheckpoints"
+	MethodUpdateCheckpoints = "UpdateCheckpoints"
 )
 
 // RPC错误码
@@ -214,11 +334,25 @@ const (
 	ErrCodeMethodNotFound   = 1002
 	ErrCodeInternalError    = 1003
 	ErrCodeTimeout          = 1004
+	ErrCodeUnauthenticated  = 1005
+	ErrCodeOverloaded       = 1006
+	ErrCodeUnavailable      = 1007
 	ErrCodeTimelineNotFound = 2001
 	ErrCodeBlockNotFound    = 2002
 	ErrCodeInvalidMessage   = 2003
 	ErrCodeStorageFull      = 2004
 	ErrCodeMigrationFailed  = 2005
+	ErrCodeMessageNotFound  = 2006
+	ErrCodePermissionDenied = 2007
+	
+	// 事务参与者错误
+	ErrCodeTransactionConflict    = 2008
+	ErrCodeTransactionNotPrepared = 2009
+	
+	// 消息被内容审核拒绝
+	ErrCodeMessageRejected = 2010
+	
+	ErrCodeTimelineExists = 2011
 )
 
 // RPC错误信息
@@ -228,11 +362,23 @@ var ErrMessages = map[int]string{
 	ErrCodeMethodNotFound:   "Method not found",
 	ErrCodeInternalError:    "Internal error",
 	ErrCodeTimeout:          "Request timeout",
+	ErrCodeUnauthenticated:  "Unauthenticated",
+	ErrCodeOverloaded:       "Overloaded",
+	ErrCodeUnavailable:      "Unavailable",
 	ErrCodeTimelineNotFound: "Timeline not found",
 	ErrCodeBlockNotFound:    "Block not found",
 	ErrCodeInvalidMessage:   "Invalid message",
 	ErrCodeStorageFull:      "Storage full",
 	ErrCodeMigrationFailed:  "Migration failed",
+	ErrCodeMessageNotFound:  "Message not found",
+	ErrCodePermissionDenied: "Permission denied",
+	
+	ErrCodeTransactionConflict:    "Transaction conflict",
+	ErrCodeTransactionNotPrepared: "Transaction not prepared",
+	
+	ErrCodeMessageRejected: "Message rejected",
+	
+	ErrCodeTimelineExists: "Timeline already exists",
 }
 
 // RPCError RPC错误结构
@@ -250,6 +396,26 @@ func (e *RPCError) Error() string {
 	return e.Message
 }
 
+// Is 使远程返回的错误可以用errors.Is与本地的哨兵错误（如ErrTransactionConflict、ErrTimelineNotFound）
+// 以及errs中同类的哨兵错误比较
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case ErrTransactionConflict:
+		return e.Code == ErrCodeTransactionConflict
+	case ErrTransactionNotPrepared:
+		return e.Code == ErrCodeTransactionNotPrepared
+	case ErrTimelineNotFound:
+		return e.Code == ErrCodeTimelineNotFound
+	case ErrTimelineExists:
+		return e.Code == ErrCodeTimelineExists
+	case ErrMessageNotFound:
+		return e.Code == ErrCodeMessageNotFound
+	case ErrStorageFull:
+		return e.Code == ErrCodeStorageFull
+	}
+	return errs.MatchSentinel(e.ErrorCode(), target)
+}
+
 // NewRPCError 创建RPC错误
 func NewRPCError(code int, detail string) *RPCError {
 	message, exists := ErrMessages[code]
@@ -261,4 +427,11 @@ func NewRPCError(code int, detail string) *RPCError {
 		Message: message,
 		Detail:  detail,
 	}
-}
\ No newline at end of file
+}
+// unmarshalRPCJSON 解析RPC的JSON数据，数字保留为json.Number，
+// 经map[string]interface{}中转时HLC等超过2^53的int64不会丢失精度
+func unmarshalRPCJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,16 +10,23 @@ import (
 	"net/http"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // HTTPStoreRPCServer HTTP实现的Store RPC服务端
 type HTTPStoreRPCServer struct {
 	mu       sync.RWMutex
 	store    *Store
+	service  *LocalStoreService
 	server   *http.Server
 	handlers map[string]RPCHandler
 	running  bool
 	middlewares []Middleware
+	tlsConfig   *tls.Config
+	auth        RPCAuthenticator
+	maxBodySize int64
 }
 
 // RPCHandler RPC处理函数类型
@@ -31,7 +39,9 @@ type Middleware func(next http.Handler) http.Handler
 func NewHTTPStoreRPCServer(store *Store) *HTTPStoreRPCServer {
 	server := &HTTPStoreRPCServer{
 		store:    store,
+		service:  NewLocalStoreService(store),
 		handlers: make(map[string]RPCHandler),
+		maxBodySize: defaultMaxRPCBodySize,
 	}
 	
 	// 注册默认处理器
@@ -51,6 +61,8 @@ func (s *HTTPStoreRPCServer) registerDefaultHandlers() {
 	// 消息操作
 	s.handlers[MethodAddMessage] = s.handleAddMessage
 	s.handlers[MethodGetMessages] = s.handleGetMessages
+	s.handlers[MethodEditMessage] = s.handleEditMessage
+	s.handlers[MethodDeleteMessage] = s.handleDeleteMessage
 	
 	// 块操作
 	s.handlers[MethodGetTimelineBlock] = s.handleGetTimelineBlock
@@ -58,6 +70,15 @@ func (s *HTTPStoreRPCServer) registerDefaultHandlers() {
 	// Store状态
 	s.handlers[MethodGetStoreStats] = s.handleGetStoreStats
 	s.handlers[MethodHealthCheck] = s.handleHealthCheck
+	
+	// 事务参与者
+	s.handlers[MethodPrepareTransaction] = s.transactionHandler(s.service.PrepareTransaction)
+	s.handlers[MethodCommitTransaction] = s.transactionHandler(s.service.CommitTransaction)
+	s.handlers[MethodAbortTransaction] = s.transactionHandler(s.service.AbortTransaction)
+	
+	// checkpoint
+	s.handlers[MethodGetCheckpoints] = s.handleGetCheckpoints
+	s.handlers[MethodUpdateCheckpoints] = s.handleUpdateCheckpoints
 }
 
 // RegisterHandler 注册自定义RPC处理器
@@ -67,6 +88,11 @@ func (s *HTTPStoreRPCServer) RegisterHandler(method string, handler RPCHandler)
 	s.handlers[method] = handler
 }
 
+// SetTransactionHandler 设置执行其他Store协调的事务的参与者处理器
+func (s *HTTPStoreRPCServer) SetTransactionHandler(handler TransactionParticipantHandler) {
+	s.service.SetTransactionHandler(handler)
+}
+
 // AddMiddleware 添加中间件
 func (s *HTTPStoreRPCServer) AddMiddleware(middleware Middleware) {
 	s.mu.Lock()
@@ -74,17 +100,49 @@ func (s *HTTPStoreRPCServer) AddMiddleware(middleware Middleware) {
 	s.middlewares = append(s.middlewares, middleware)
 }
 
-// Start 启动RPC服务
-func (s *HTTPStoreRPCServer) Start(address string) error {
+// SetTLSConfig 设置TLS配置，Start后以HTTPS提供服务
+// 配置中要求客户端证书时即为双向TLS，需在Start之前调用
+func (s *HTTPStoreRPCServer) SetTLSConfig(config *tls.Config) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	
-	if s.running {
-		return fmt.Errorf("server is already running")
+	s.tlsConfig = config
+}
+
+// SetAuthenticator 设置/rpc请求的认证方式，为nil时接受任何调用方，需在Start之前调用
+func (s *HTTPStoreRPCServer) SetAuthenticator(auth RPCAuthenticator) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth = auth
+}
+
+// SetMaxRequestBodySize 设置认证时读取的请求体上限，不大于0时为64MB，需在Start之前调用
+func (s *HTTPStoreRPCServer) SetMaxRequestBodySize(size int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if size <= 0 {
+		size = defaultMaxRPCBodySize
+	}
+	s.maxBodySize = size
+}
+
+// Handler 返回应用了认证与中间件的路由
+func (s *HTTPStoreRPCServer) Handler() http.Handler {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.handlerLocked()
+}
+
+func (s *HTTPStoreRPCServer) handlerLocked() http.Handler {
+	var rpc http.Handler = http.HandlerFunc(s.handleRPC)
+	var stream http.Handler = http.HandlerFunc(s.handleStream)
+	if s.auth != nil {
+		rpc = rpcAuthMiddleware(s.auth, s.maxBodySize)(rpc)
+		stream = rpcAuthMiddleware(s.auth, s.maxBodySize)(stream)
 	}
 	
 	mux := http.NewServeMux()
-	mux.HandleFunc("/rpc", s.handleRPC)
+	mux.Handle("/rpc", rpc)
+	mux.Handle("/rpc/stream", stream)
 	mux.HandleFunc("/health", s.handleHealth)
 	
 	// 应用中间件
@@ -92,16 +150,40 @@ func (s *HTTPStoreRPCServer) Start(address string) error {
 	for i := len(s.middlewares) - 1; i >= 0; i-- {
 		handler = s.middlewares[i](handler)
 	}
+	return handler
+}
+
+// Start 启动RPC服务
+func (s *HTTPStoreRPCServer) Start(address string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	
+	if s.running {
+		return fmt.Errorf("server is already running")
+	}
+	if err := checkAuthTransport(s.auth, s.tlsConfig); err != nil {
+		return err
+	}
 	
+	handler := s.handlerLocked()
 	s.server = &http.Server{
-		Addr:    address,
-		Handler: handler,
+		Addr:      address,
+		Handler:   handler,
+		TLSConfig: s.tlsConfig,
 	}
 	
 	s.running = true
 	
+	server := s.server
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if server.TLSConfig != nil {
+			// 证书已在TLSConfig中，无需再传文件
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Printf("RPC server error: %v", err)
 		}
 	}()
@@ -150,7 +232,7 @@ func (s *HTTPStoreRPCServer) handleRPC(w http.ResponseWriter, r *http.Request) {
 	
 	// 解析请求
 	var request StoreRPCRequest
-	err = json.Unmarshal(body, &request)
+	err = unmarshalRPCJSON(body, &request)
 	if err != nil {
 		s.writeErrorResponse(w, "Invalid JSON request", http.StatusBadRequest)
 		return
@@ -172,8 +254,9 @@ func (s *HTTPStoreRPCServer) handleRPC(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	
-	// 创建上下文
-	ctx := r.Context()
+	// 创建上下文，恢复调用方的追踪上下文
+	ctx, span := startRPCSpan(WithRequestID(extractHTTPTrace(r), request.RequestID), trace.SpanKindServer, "http", request.Method, "")
+	defer span.End()
 	if request.Timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, request.Timeout)
@@ -183,7 +266,9 @@ func (s *HTTPStoreRPCServer) handleRPC(w http.ResponseWriter, r *http.Request) {
 	// 执行处理器
 	result, err := handler(ctx, request.Params)
 	if err != nil {
-		s.writeRPCErrorResponse(w, request.RequestID, ErrCodeInternalError, err.Error())
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		s.writeRPCErrorResponse(w, request.RequestID, toRPCError(err).Code, err.Error())
 		return
 	}
 	
@@ -203,7 +288,7 @@ func (s *HTTPStoreRPCServer) handleRPC(w http.ResponseWriter, r *http.Request) {
 		}
 		
 		var resultMap map[string]interface{}
-		err = json.Unmarshal(resultBytes, &resultMap)
+		err = unmarshalRPCJSON(resultBytes, &resultMap)
 		if err != nil {
 			s.writeRPCErrorResponse(w, request.RequestID, ErrCodeInternalError, "Failed to unmarshal result")
 			return
@@ -216,13 +301,91 @@ func (s *HTTPStoreRPCServer) handleRPC(w http.ResponseWriter, r *http.Request) {
 	s.writeJSONResponse(w, response, http.StatusOK)
 }
 
+// streamBatchSize 流式响应每行携带的消息条数
+const streamBatchSize = 256
+
+// handleStream 处理流式读取消息请求，请求体与/rpc相同，响应为分块传输的NDJSON：
+// 每行一个StreamMessagesChunk，最后一行Done为true，出错时最后一行携带Error
+// 流式传输不受请求中Timeout限制，由调用方断开连接结束
+func (s *HTTPStoreRPCServer) handleStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.writeErrorResponse(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	var request StoreRPCRequest
+	if err := unmarshalRPCJSON(body, &request); err != nil {
+		s.writeErrorResponse(w, "Invalid JSON request", http.StatusBadRequest)
+		return
+	}
+	if request.Method != MethodStreamMessages {
+		s.writeErrorResponse(w, "Method not streamable: "+request.Method, http.StatusBadRequest)
+		return
+	}
+	var req StreamMessagesRequest
+	if err := parseParams(request.Params, &req); err != nil {
+		s.writeErrorResponse(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	
+	ctx, span := startRPCSpan(WithRequestID(extractHTTPTrace(r), request.RequestID), trace.SpanKindServer, "http", request.Method, "")
+	defer span.End()
+	
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	writeChunk := func(chunk *StreamMessagesChunk) error {
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+	
+	batch := make([]*Message, 0, streamBatchSize)
+	err = s.service.StreamMessages(ctx, &req, func(msg *Message) error {
+		batch = append(batch, msg)
+		if len(batch) < streamBatchSize {
+			return nil
+		}
+		err := writeChunk(&StreamMessagesChunk{Messages: batch})
+		batch = batch[:0]
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = writeChunk(&StreamMessagesChunk{Messages: batch})
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		// 连接已断开时写入失败，无需处理
+		writeChunk(&StreamMessagesChunk{Error: err.Error(), Code: toRPCError(err).Code})
+		return
+	}
+	writeChunk(&StreamMessagesChunk{Done: true})
+}
+
 // handleHealth 处理健康检查请求
 func (s *HTTPStoreRPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	response := map[string]interface{}{
-		"status":    "healthy",
+		"status":    s.store.HealthStatus(),
 		"timestamp": time.Now().Unix(),
 		"store_id":  s.store.StoreID,
 	}
+	if corrupt := s.store.CorruptBlocks(); len(corrupt) > 0 {
+		response["corrupt_blocks"] = corrupt
+	}
+	if orphaned := s.store.RecoveryReport().OrphanedBlocks; len(orphaned) > 0 {
+		response["orphaned_blocks"] = orphaned
+	}
 	s.writeJSONResponse(w, response, http.StatusOK)
 }
 
@@ -251,6 +414,7 @@ func (s *HTTPStoreRPCServer) writeRPCErrorResponse(w http.ResponseWriter, reques
 		RequestID: requestID,
 		Success:   false,
 		Error:     errorMessage,
+		Code:      errorCode,
 		Timestamp: time.Now(),
 	}
 	s.writeJSONResponse(w, response, http.StatusOK)
@@ -276,101 +440,37 @@ func parseParams[T any](params map[string]interface{}, result *T) error {
 // handleGetTimeline 处理获取Timeline请求
 func (s *HTTPStoreRPCServer) handleGetTimeline(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req GetTimelineRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	timeline, exists := s.store.ConvTimelines[req.TimelineKey]
-	if !exists {
-		// 尝试加载Timeline
-		timeline = s.store.GetOrCreateConvTimeline(req.TimelineKey)
-	}
-	
-	return &GetTimelineResponse{
-		Timeline: timeline,
-		Exists:   timeline != nil,
-	}, nil
+	return s.service.GetTimeline(ctx, &req)
 }
 
 // handleCreateTimeline 处理创建Timeline请求
 func (s *HTTPStoreRPCServer) handleCreateTimeline(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req CreateTimelineRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	// 检查Timeline是否已存在
-	if _, exists := s.store.ConvTimelines[req.TimelineKey]; exists {
-		return &CreateTimelineResponse{
-			Timeline: s.store.ConvTimelines[req.TimelineKey],
-			Created:  false,
-		}, nil
-	}
-	
-	// 创建新Timeline
-	timeline := s.store.GetOrCreateConvTimeline(req.TimelineKey)
-	
-	// TODO: 设置元数据 - Timeline结构体需要添加Metadata字段
-	// if req.Metadata != nil {
-	//     for k, v := range req.Metadata {
-	//         timeline.Metadata[k] = v
-	//     }
-	//     // 保存元数据
-	//     err = s.store.saveTimelineMetadata(timeline)
-	//     if err != nil {
-	//         return nil, fmt.Errorf("failed to save timeline metadata: %w", err)
-	//     }
-	// }
-	
-	return &CreateTimelineResponse{
-		Timeline: timeline,
-		Created:  true,
-	}, nil
+	return s.service.CreateTimeline(ctx, &req)
 }
 
 // handleDeleteTimeline 处理删除Timeline请求
 func (s *HTTPStoreRPCServer) handleDeleteTimeline(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req DeleteTimelineRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	// 检查Timeline是否存在
-	_, exists := s.store.ConvTimelines[req.TimelineKey]
-	if !exists {
-		return &DeleteTimelineResponse{Deleted: false}, nil
-	}
-	
-	// TODO: 实现删除Timeline文件和块的逻辑
-	// err = s.store.deleteTimeline(timeline)
-	// if err != nil && !req.Force {
-	//     return nil, fmt.Errorf("failed to delete timeline: %w", err)
-	// }
-	
-	// 从内存中移除
-	delete(s.store.ConvTimelines, req.TimelineKey)
-	
-	return &DeleteTimelineResponse{Deleted: true}, nil
+	return s.service.DeleteTimeline(ctx, &req)
 }
 
 // handleMigrateTimeline 处理迁移Timeline请求
 func (s *HTTPStoreRPCServer) handleMigrateTimeline(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req MigrateTimelineRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	// TODO: 实现Timeline迁移逻辑
-	// 这里需要与目标Store协调，传输Timeline数据
-	
-	return &MigrateTimelineResponse{
-		Success:        false,
-		MigratedBlocks: []string{},
-	}, fmt.Errorf("timeline migration not implemented yet")
+	return s.service.MigrateTimeline(ctx, &req)
 }
 
 // 消息操作处理器
@@ -378,54 +478,37 @@ func (s *HTTPStoreRPCServer) handleMigrateTimeline(ctx context.Context, params m
 // handleAddMessage 处理添加消息请求
 func (s *HTTPStoreRPCServer) handleAddMessage(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req AddMessageRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	// 获取或创建Timeline
-	timeline := s.store.GetOrCreateConvTimeline(req.TimelineKey)
-	
-	// 添加消息 - 使用Store的AddMessage方法
-	err = s.store.AddMessage(req.TimelineKey, req.Message.SenderID, req.Message.Data, []string{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to add message: %w", err)
-	}
-	
-	// 返回响应 - 这里简化处理，实际应该返回具体的块ID和偏移量
-	return &AddMessageResponse{
-		BlockID:   timeline.CurrentBlock.BlockID,
-		Offset:    int64(len(timeline.CurrentBlock.Messages)),
-		MessageID: fmt.Sprintf("%d", req.Message.SeqID),
-	}, nil
+	return s.service.AddMessage(ctx, &req)
 }
 
 // handleGetMessages 处理获取消息请求
 func (s *HTTPStoreRPCServer) handleGetMessages(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req GetMessagesRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	// 获取Timeline
-	_, exists := s.store.ConvTimelines[req.TimelineKey]
-	if !exists {
-		return &GetMessagesResponse{
-			Messages: []*Message{},
-			Total:    0,
-			HasMore:  false,
-		}, nil
+	return s.service.GetMessages(ctx, &req)
+}
+
+// handleEditMessage 处理编辑消息请求
+func (s *HTTPStoreRPCServer) handleEditMessage(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var req EditMessageRequest
+	if err := parseParams(params, &req); err != nil {
+		return nil, err
 	}
-	
-	// TODO: 实现消息查询逻辑
-	// 这里需要根据时间范围、限制和偏移量查询消息
-	
-	return &GetMessagesResponse{
-		Messages: []*Message{},
-		Total:    0,
-		HasMore:  false,
-	}, nil
+	return s.service.EditMessage(ctx, &req)
+}
+
+// handleDeleteMessage 处理删除消息请求
+func (s *HTTPStoreRPCServer) handleDeleteMessage(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var req DeleteMessageRequest
+	if err := parseParams(params, &req); err != nil {
+		return nil, err
+	}
+	return s.service.DeleteMessage(ctx, &req)
 }
 
 // 块操作处理器
@@ -433,24 +516,10 @@ func (s *HTTPStoreRPCServer) handleGetMessages(ctx context.Context, params map[s
 // handleGetTimelineBlock 处理获取Timeline块请求
 func (s *HTTPStoreRPCServer) handleGetTimelineBlock(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req GetTimelineBlockRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	// 从缓存中查找块
-	block, exists := s.store.TimelineBlocks[req.BlockID]
-	if !exists {
-		return &GetTimelineBlockResponse{
-			Block:  nil,
-			Exists: false,
-		}, nil
-	}
-	
-	return &GetTimelineBlockResponse{
-		Block:  block,
-		Exists: true,
-	}, nil
+	return s.service.GetTimelineBlock(ctx, &req)
 }
 
 // Store状态处理器
@@ -458,50 +527,48 @@ func (s *HTTPStoreRPCServer) handleGetTimelineBlock(ctx context.Context, params
 // handleGetStoreStats 处理获取Store统计请求
 func (s *HTTPStoreRPCServer) handleGetStoreStats(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req GetStoreStatsRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	timelineCount := len(s.store.ConvTimelines) + len(s.store.UserTimelines)
-	blockCount := len(s.store.TimelineBlocks)
-	
-	response := &GetStoreStatsResponse{
-		StoreID:       s.store.StoreID,
-		TimelineCount: timelineCount,
-		BlockCount:    blockCount,
-		TotalSize:     s.store.CurrentCapacity,
-		Uptime:        0, // TODO: 添加Store创建时间字段来计算uptime
-		LastUpdate:    time.Now().Unix(),
-	}
-	
-	if req.IncludeTimelines {
-		timelines := make([]string, 0, timelineCount)
-		for key := range s.store.ConvTimelines {
-			timelines = append(timelines, key)
-		}
-		for key := range s.store.UserTimelines {
-			timelines = append(timelines, key)
-		}
-		response.Timelines = timelines
-	}
-	
-	return response, nil
+	return s.service.GetStoreStats(ctx, &req)
 }
 
 // handleHealthCheck 处理健康检查请求
 func (s *HTTPStoreRPCServer) handleHealthCheck(ctx context.Context, params map[string]interface{}) (interface{}, error) {
 	var req HealthCheckRequest
-	err := parseParams(params, &req)
-	if err != nil {
+	if err := parseParams(params, &req); err != nil {
 		return nil, err
 	}
-	
-	return &HealthCheckResponse{
-		Pong:      "pong",
-		Status:    "healthy",
-		Timestamp: time.Now().Unix(),
-	}, nil
+	return s.service.HealthCheck(ctx, &req)
+}
+
+// handleGetCheckpoints 处理读取checkpoint请求
+func (s *HTTPStoreRPCServer) handleGetCheckpoints(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var req GetCheckpointsRequest
+	if err := parseParams(params, &req); err != nil {
+		return nil, err
+	}
+	return s.service.GetCheckpoints(ctx, &req)
+}
+
+// handleUpdateCheckpoints 处理批量更新checkpoint请求
+func (s *HTTPStoreRPCServer) handleUpdateCheckpoints(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+	var req UpdateCheckpointsRequest
+	if err := parseParams(params, &req); err != nil {
+		return nil, err
+	}
+	return s.service.UpdateCheckpoints(ctx, &req)
+}
+
+// transactionHandler 把事务参与者方法包装为RPC处理器
+func (s *HTTPStoreRPCServer) transactionHandler(call func(context.Context, *TransactionRequest) (*TransactionResponse, error)) RPCHandler {
+	return func(ctx context.Context, params map[string]interface{}) (interface{}, error) {
+		var req TransactionRequest
+		if err := parseParams(params, &req); err != nil {
+			return nil, err
+		}
+		return call(ctx, &req)
+	}
 }
 
 // 中间件
@@ -537,4 +604,4 @@ func RateLimitMiddleware(requestsPerSecond int) Middleware {
 	return func(next http.Handler) http.Handler {
 		return next
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Saga编排
+// 耗时较长、不适合两阶段提交超时的流程（如Timeline迁移）拆成按顺序执行的步骤，每个步骤可带补偿操作。
+// 每个步骤完成后持久化状态，进程中断后从下一个步骤继续；步骤重试仍失败时按相反顺序补偿
+// 失败的步骤及之前已完成的步骤，失败步骤可能只完成了一部分，因此补偿操作必须能处理部分完成和未执行的情况。
+// 步骤在持久化前中断会被重新执行，步骤和补偿操作都需要幂等
+
+// SagaStatus Saga状态
+type SagaStatus string
+
+const (
+	SagaRunning      SagaStatus = "running"      // 正向执行中
+	SagaCompleted    SagaStatus = "completed"    // 全部步骤完成
+	SagaCompensating SagaStatus = "compensating" // 补偿中
+	SagaCompensated  SagaStatus = "compensated"  // 补偿完成
+	SagaStuck        SagaStatus = "stuck"        // 补偿失败，需要人工处理
+)
+
+// SagaStep Saga的一个步骤
+type SagaStep struct {
+	Name       string
+	Action     func(ctx context.Context) error
+	Compensate func(ctx context.Context) error // 为nil表示没有需要撤销的影响
+}
+
+// SagaState 可持久化的Saga执行状态
+type SagaState struct {
+	ID        string     `json:"id"`
+	Status    SagaStatus `json:"status"`
+	Step      int        `json:"step"` // 正向执行时为下一个要执行的步骤，补偿时为尚未补偿的步骤数
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// NewSagaState 创建从第一个步骤开始的Saga状态
+func NewSagaState(id string) *SagaState {
+	return &SagaState{ID: id, Status: SagaRunning, UpdatedAt: time.Now()}
+}
+
+// SagaOrchestrator 按顺序执行步骤并在失败时补偿
+type SagaOrchestrator struct {
+	steps       []SagaStep
+	save        func(state *SagaState) error
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewSagaOrchestrator 创建Saga编排器，save在每次状态变化后调用以持久化状态
+func NewSagaOrchestrator(steps []SagaStep, save func(state *SagaState) error) *SagaOrchestrator {
+	return &SagaOrchestrator{
+		steps:       steps,
+		save:        save,
+		maxAttempts: 3,
+		backoff:     time.Second,
+	}
+}
+
+// SetRetry 设置步骤和补偿操作的最大尝试次数与首次重试前的等待时间，等待时间每次翻倍
+func (o *SagaOrchestrator) SetRetry(maxAttempts int, backoff time.Duration) {
+	o.maxAttempts = max(maxAttempts, 1)
+	o.backoff = backoff
+}
+
+// Run 从state记录的位置继续执行Saga
+// 步骤失败时补偿并返回步骤的错误；ctx取消时保留当前状态并返回ctx的错误，由调用方决定续传或调用Compensate
+func (o *SagaOrchestrator) Run(ctx context.Context, state *SagaState) error {
+	switch state.Status {
+	case SagaCompleted:
+		return nil
+	case SagaCompensated:
+		return fmt.Errorf("saga %s was compensated: %s", state.ID, state.Error)
+	case SagaCompensating, SagaStuck:
+		return o.Compensate(ctx, state)
+	}
+
+	for state.Step < len(o.steps) {
+		step := o.steps[state.Step]
+		err := o.attempt(ctx, step.Action)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Printf("saga %s: step %s failed, compensating: %v", state.ID, step.Name, err)
+			state.Error = fmt.Sprintf("step %s failed: %v", step.Name, err)
+			if compensateErr := o.Compensate(ctx, state); compensateErr != nil {
+				return errors.Join(fmt.Errorf("saga step %s failed: %w", step.Name, err), compensateErr)
+			}
+			return fmt.Errorf("saga step %s failed: %w", step.Name, err)
+		}
+
+		state.Step++
+		if err := o.persist(state); err != nil {
+			return err
+		}
+	}
+
+	state.Status = SagaCompleted
+	return o.persist(state)
+}
+
+// Compensate 按相反顺序补偿已执行的步骤，补偿失败时状态为SagaStuck，可稍后再次调用
+// 正向执行中断或失败的步骤可能已部分完成，一并补偿
+func (o *SagaOrchestrator) Compensate(ctx context.Context, state *SagaState) error {
+	if state.Status == SagaCompensated {
+		return nil
+	}
+	if state.Status == SagaRunning && state.Step < len(o.steps) {
+		state.Step++
+	}
+	state.Status = SagaCompensating
+	if err := o.persist(state); err != nil {
+		return err
+	}
+
+	for state.Step > 0 {
+		step := o.steps[state.Step-1]
+		if step.Compensate != nil {
+			if err := o.attempt(ctx, step.Compensate); err != nil {
+				state.Status = SagaStuck
+				state.Error = fmt.Sprintf("compensating %s failed: %v", step.Name, err)
+				if saveErr := o.persist(state); saveErr != nil {
+					log.Printf("saga %s: failed to save state: %v", state.ID, saveErr)
+				}
+				return fmt.Errorf("saga %s: compensating step %s failed: %w", state.ID, step.Name, err)
+			}
+		}
+		state.Step--
+		if err := o.persist(state); err != nil {
+			return err
+		}
+	}
+
+	state.Status = SagaCompensated
+	return o.persist(state)
+}
+
+// attempt 执行操作，失败时按退避时间重试
+func (o *SagaOrchestrator) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	backoff := o.backoff
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil || attempt >= o.maxAttempts || ctx.Err() != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+func (o *SagaOrchestrator) persist(state *SagaState) error {
+	state.UpdatedAt = time.Now()
+	if o.save == nil {
+		return nil
+	}
+	if err := o.save(state); err != nil {
+		return fmt.Errorf("failed to save saga %s: %w", state.ID, err)
+	}
+	return nil
+}
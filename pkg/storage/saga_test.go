@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// recordingSaga 创建记录执行顺序的步骤，failing中的步骤一直失败
+func recordingSaga(names []string, failing map[string]bool) ([]SagaStep, *[]string) {
+	var calls []string
+	steps := make([]SagaStep, len(names))
+	for i, name := range names {
+		name := name
+		steps[i] = SagaStep{
+			Name: name,
+			Action: func(ctx context.Context) error {
+				calls = append(calls, name)
+				if failing[name] {
+					return errors.New(name + " failed")
+				}
+				return nil
+			},
+			Compensate: func(ctx context.Context) error {
+				calls = append(calls, "undo "+name)
+				if failing["undo "+name] {
+					return errors.New("undo " + name + " failed")
+				}
+				return nil
+			},
+		}
+	}
+	return steps, &calls
+}
+
+func TestSagaCompensatesInReverseOrder(t *testing.T) {
+	ctx := context.Background()
+	steps, calls := recordingSaga([]string{"a", "b", "c"}, map[string]bool{"c": true})
+	var saved []SagaStatus
+	saga := NewSagaOrchestrator(steps, func(state *SagaState) error {
+		saved = append(saved, state.Status)
+		return nil
+	})
+	saga.SetRetry(2, time.Millisecond)
+
+	state := NewSagaState("saga_1")
+	if err := saga.Run(ctx, state); err == nil {
+		t.Fatalf("Expected the failed step to be reported")
+	}
+	// 失败的步骤重试后补偿，自身也参与补偿
+	expected := []string{"a", "b", "c", "c", "undo c", "undo b", "undo a"}
+	if !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, *calls)
+	}
+	if state.Status != SagaCompensated || state.Step != 0 || state.Error == "" {
+		t.Errorf("Unexpected final state %+v", state)
+	}
+	if saved[0] != SagaRunning || saved[len(saved)-1] != SagaCompensated {
+		t.Errorf("Expected every transition to be persisted, got %v", saved)
+	}
+
+	// 已补偿的Saga不再执行
+	*calls = nil
+	if err := saga.Run(ctx, state); err == nil || len(*calls) != 0 {
+		t.Errorf("Expected a compensated saga to stay compensated, got %v %v", err, *calls)
+	}
+}
+
+func TestSagaStuckAndResume(t *testing.T) {
+	ctx := context.Background()
+	failing := map[string]bool{"c": true, "undo b": true}
+	steps, calls := recordingSaga([]string{"a", "b", "c"}, failing)
+	saga := NewSagaOrchestrator(steps, nil)
+	saga.SetRetry(1, 0)
+
+	state := NewSagaState("saga_stuck")
+	if err := saga.Run(ctx, state); err == nil {
+		t.Fatalf("Expected the saga to fail")
+	}
+	if state.Status != SagaStuck || state.Step != 2 {
+		t.Fatalf("Expected the saga to be stuck at b, got %+v", state)
+	}
+
+	// 补偿操作恢复后从卡住的步骤继续补偿
+	delete(failing, "undo b")
+	*calls = nil
+	if err := saga.Run(ctx, state); err != nil {
+		t.Fatalf("Failed to finish compensation: %v", err)
+	}
+	if expected := []string{"undo b", "undo a"}; !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, *calls)
+	}
+	if state.Status != SagaCompensated {
+		t.Errorf("Expected compensated, got %s", state.Status)
+	}
+}
+
+func TestSagaCancelKeepsProgress(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	steps, calls := recordingSaga([]string{"a", "b", "c"}, nil)
+	steps[1].Action = func(ctx context.Context) error {
+		cancel()
+		return ctx.Err()
+	}
+	saga := NewSagaOrchestrator(steps, nil)
+
+	state := NewSagaState("saga_cancel")
+	if err := saga.Run(ctx, state); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the cancellation, got %v", err)
+	}
+	if state.Status != SagaRunning || state.Step != 1 {
+		t.Fatalf("Expected the state to be kept for resuming, got %+v", state)
+	}
+
+	// 中断的步骤可能已部分完成，补偿时一并撤销
+	*calls = nil
+	if err := saga.Compensate(context.Background(), state); err != nil {
+		t.Fatalf("Failed to compensate: %v", err)
+	}
+	if expected := []string{"undo b", "undo a"}; !reflect.DeepEqual(*calls, expected) {
+		t.Errorf("Expected %v, got %v", expected, *calls)
+	}
+
+	// 续传从下一个步骤开始
+	steps, calls = recordingSaga([]string{"a", "b", "c"}, nil)
+	state = &SagaState{ID: "saga_resume", Status: SagaRunning, Step: 2}
+	if err := NewSagaOrchestrator(steps, nil).Run(context.Background(), state); err != nil || !reflect.DeepEqual(*calls, []string{"c"}) {
+		t.Errorf("Expected only the remaining step to run, got %v %v", *calls, err)
+	}
+}
+
+// failingSwitchIndex 切换到目标Store时失败的全局索引
+type failingSwitchIndex struct {
+	*InMemoryGlobalIndex
+	target string
+}
+
+func (fi *failingSwitchIndex) MigrateTimeline(ctx context.Context, timelineKey, fromStore, toStore string) error {
+	if toStore == fi.target {
+		return errors.New("index unavailable")
+	}
+	return fi.InMemoryGlobalIndex.MigrateTimeline(ctx, timelineKey, fromStore, toStore)
+}
+
+func TestMigrationCompensatesWhenIndexSwitchFails(t *testing.T) {
+	ctx := context.Background()
+
+	sourceDir := t.TempDir()
+	source, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: sourceDir})
+	if err != nil {
+		t.Fatalf("Failed to create source store: %v", err)
+	}
+	target, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create target store: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(target)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(ctx)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: target.StoreID, Address: address})
+
+	timelineKey := "conv_saga"
+	for i := 0; i < 5; i++ {
+		if err := source.AddMessage(timelineKey, 1, []byte{byte(i)}, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	globalIndex := &failingSwitchIndex{InMemoryGlobalIndex: NewInMemoryGlobalIndex(), target: target.StoreID}
+	for _, block := range source.GetOrCreateConvTimeline(timelineKey).Blocks {
+		globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: source.StoreID, BlockID: block.BlockID})
+	}
+
+	pool := NewStoreRPCClientPoolWithTransport(TransportGRPC, 5*time.Second)
+	defer pool.Close()
+	accessor := NewDistributedStoreAccessor(source, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+	manager := NewTimelineMigrationManager(source, globalIndex, pool, accessor, NewInMemoryDistributedLockManager(source.StoreID), source.StoreID)
+	manager.SetStepRetry(2, time.Millisecond)
+
+	task, err := manager.StartMigration(ctx, timelineKey, target.StoreID)
+	if err != nil {
+		t.Fatalf("Failed to start migration: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	var status *MigrationTask
+	for time.Now().Before(deadline) {
+		status, _ = manager.GetMigrationStatus(ctx, task.ID)
+		if status.Status == MigrationCompleted || status.Status == MigrationFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.Status != MigrationFailed {
+		t.Fatalf("Expected the migration to fail, got %s", status.Status)
+	}
+
+	// 补偿删除了目标Store上导入的块，源数据和全局索引保持不变
+	if _, exists := target.FindTimeline(timelineKey); exists {
+		t.Errorf("Expected the imported timeline to be removed from target")
+	}
+	if messages, _ := source.GetConvMessages(timelineKey, 10, 0); len(messages) != 5 {
+		t.Errorf("Expected the source timeline to be kept, got %d messages", len(messages))
+	}
+	location, _ := globalIndex.GetTimelineLocation(ctx, timelineKey)
+	for _, index := range location.Blocks {
+		if index.StoreID != source.StoreID {
+			t.Errorf("Global index points to %s after compensation", index.StoreID)
+		}
+	}
+
+	// 持久化的Saga状态记录了补偿结果
+	reloaded := NewTimelineMigrationManager(source, globalIndex, pool, accessor, NewInMemoryDistributedLockManager(source.StoreID), source.StoreID)
+	reloaded.mu.RLock()
+	checkpoint := reloaded.checkpoints[task.ID]
+	reloaded.mu.RUnlock()
+	if checkpoint == nil || checkpoint.Saga == nil || checkpoint.Saga.Status != SagaCompensated || len(checkpoint.Blocks) != 0 {
+		t.Fatalf("Expected a compensated saga in the checkpoint, got %+v", checkpoint)
+	}
+	if _, err := os.Stat(filepath.Join(sourceDir, "migrations", task.ID+".json")); err != nil {
+		t.Errorf("Expected the failed migration's checkpoint to be kept: %v", err)
+	}
+}
@@ -0,0 +1,767 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 段文件存储格式
+// 每个Store的数据目录下有若干追加写的段文件 segment_{id}.seg，写满SegmentMaxSize后滚动到下一个段。
+// 每条记录为 [长度uint32][CRC32 uint32][带版本头的块记录]，一条记录保存一个完整的块，负载格式见block_codec.go；
+// 同一块后写入的记录覆盖之前的记录，Deleted记录表示块已删除。
+// 块索引（块ID -> 段号/偏移/长度）在打开时重建：已封存的段读取段索引文件，活跃段逐条扫描，见segment_index.go。
+
+const (
+	segmentFilePrefix     = "segment_"
+	segmentFileSuffix     = ".seg"
+	segmentHeaderSize     = 8 // 4字节长度 + 4字节CRC32C（版本2之前的记录为CRC32）
+	defaultSegmentMaxSize = 64 * 1024 * 1024
+	segmentQuarantineDir  = "quarantine" // 数据目录下存放损坏记录副本的目录
+)
+
+// ErrBlockCorrupted 块记录校验失败
+var ErrBlockCorrupted = errors.New("block corrupted")
+
+// CorruptRecord 扫描段文件时发现的损坏记录
+type CorruptRecord struct {
+	BlockID    string `json:"block_id,omitempty"` // 损坏记录所属的块，无法解析时为空
+	SegmentID  int    `json:"segment_id"`
+	Offset     int64  `json:"offset"`
+	Length     int64  `json:"length"`
+	Reason     string `json:"reason"`
+	Quarantine string `json:"quarantine,omitempty"` // 隔离副本的路径，复制失败时为空
+}
+
+// segmentRecord 段文件中的一条块记录
+type segmentRecord struct {
+	BlockID  string
+	Deleted  bool
+	Messages []*Message
+	Index    *BlockIndex   // 块索引，旧版本写入的记录没有该字段
+	Filters  *blockFilters // 块布隆过滤器，未开启时为nil
+	Checksum uint32        // 块内消息的CRC32C校验和，版本2之前写入的记录为0
+}
+
+// blockIndex 记录的块索引，旧版本写入的记录没有索引时按消息重建
+func (r *segmentRecord) blockIndex() BlockIndex {
+	if r.Index != nil {
+		return *r.Index
+	}
+	return buildBlockIndex(r.Messages)
+}
+
+// blockMeta 随块记录保存的索引与过滤器
+type blockMeta struct {
+	Index   BlockIndex
+	Filters *blockFilters
+}
+
+// BlockLocation 块在段文件中的位置
+type BlockLocation struct {
+	SegmentID int           `json:"segment_id"`
+	Offset    int64         `json:"offset"`   // 记录在段文件中的起始偏移
+	Length    int64         `json:"length"`   // 记录总长度（含头部）
+	Index     BlockIndex    `json:"index"`    // 记录的块索引，未写满的块写入后仍会追加消息，MaxSeqID为已写入的最后一条
+	Checksum  uint32        `json:"checksum"` // 记录中消息的校验和（同TimelineBlock.Checksum），块未加载时使用
+	Filters   *blockFilters `json:"-"`        // 记录的布隆过滤器，块未加载时查询据此跳块
+}
+
+// segment 单个段文件
+type segment struct {
+	id   int
+	path string
+	file *os.File
+	size int64
+	live int // 仍被索引引用的记录数
+
+	entries    []segmentIndexEntry // 扫描或写入的记录，封存时写入段索引文件
+	corrupt    bool                // 扫描时发现损坏记录，不写段索引文件
+	unverified bool                // 按段索引文件打开，记录尚未校验，见segment_scrub.go
+}
+
+// segmentStore 追加写的段文件块存储
+type segmentStore struct {
+	mu       sync.RWMutex
+	dir      string
+	maxSize  int64
+	codec    BlockCodec // 新写入记录的编码
+	segments map[int]*segment
+	active   *segment
+	index    map[string]BlockLocation
+	live     int64 // 索引引用的记录总字节数，即已落盘块实际占用的容量
+
+	corrupt      map[string]CorruptRecord // 最新记录损坏的块，写入该块的新记录后移除
+	unattributed []CorruptRecord          // 无法确定所属块的损坏记录
+
+	durability DurabilityPolicy // 段文件的fsync时机，为空时按always，periodic与none下由Sync刷盘
+	unsynced   bool             // 活跃段有尚未fsync的写入
+}
+
+// openSegmentStore 打开数据目录下的段文件并重建块索引
+func openSegmentStore(dir string, maxSize int64, codec BlockCodec) (*segmentStore, error) {
+	if maxSize <= 0 {
+		maxSize = defaultSegmentMaxSize
+	}
+	codec, err := validBlockCodec(codec)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := &segmentStore{
+		dir:      dir,
+		maxSize:  maxSize,
+		codec:    codec,
+		segments: make(map[int]*segment),
+		index:    make(map[string]BlockLocation),
+		corrupt:  make(map[string]CorruptRecord),
+	}
+
+	ids, err := listSegmentIDs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		seg, err := ss.openSegment(id)
+		if err != nil {
+			ss.Close()
+			return nil, err
+		}
+		// 已封存的段按段索引文件建立索引，不读取段文件
+		last := i == len(ids)-1
+		if !last && ss.loadSegmentIndex(seg) {
+			continue
+		}
+		if err := ss.scanSegment(seg, last); err != nil {
+			ss.Close()
+			return nil, err
+		}
+		if !last {
+			ss.sealSegment(seg)
+		}
+	}
+
+	// 没有段文件时创建第一个段
+	if ss.active == nil {
+		if _, err := ss.rollSegment(); err != nil {
+			ss.Close()
+			return nil, err
+		}
+	}
+
+	return ss, nil
+}
+
+// listSegmentIDs 列出目录下的段号，按升序排列
+func listSegmentIDs(dir string) ([]int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int, 0)
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+			continue
+		}
+		var id int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, segmentFilePrefix), segmentFileSuffix), "%d", &id); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids, nil
+}
+
+func (ss *segmentStore) segmentPath(id int) string {
+	return filepath.Join(ss.dir, fmt.Sprintf("%s%06d%s", segmentFilePrefix, id, segmentFileSuffix))
+}
+
+// openSegment 打开段文件，最后打开的段作为活跃段
+func (ss *segmentStore) openSegment(id int) (*segment, error) {
+	path := ss.segmentPath(id)
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open segment %d: %w", id, err)
+	}
+
+	seg := &segment{id: id, path: path, file: file}
+	ss.segments[id] = seg
+	ss.active = seg
+	return seg, nil
+}
+
+// scanSegment 扫描段文件重建索引
+// 校验失败的记录之后还能找到完整的记录时，说明损坏发生在已写入的记录中：
+// 将损坏的字节复制到隔离目录，并把所属块标记为损坏，块之前的旧记录不再使用。
+// 否则是崩溃时未写完的尾部记录，截断即可；非最后一个段只在写满后滚动，尾部的损坏同样按损坏处理
+func (ss *segmentStore) scanSegment(seg *segment, last bool) error {
+	data, err := os.ReadFile(seg.path)
+	if err != nil {
+		return fmt.Errorf("failed to read segment %d: %w", seg.id, err)
+	}
+
+	var offset int64
+	for offset < int64(len(data)) {
+		record, length, err := parseSegmentRecord(data[offset:])
+		if err == nil {
+			location := BlockLocation{
+				SegmentID: seg.id,
+				Offset:    offset,
+				Length:    length,
+				Index:     record.blockIndex(),
+				Checksum:  blockChecksum(record.Messages),
+				Filters:   record.Filters,
+			}
+			ss.applyRecord(record.BlockID, record.Deleted, location)
+			seg.addIndexEntry(record.BlockID, record.Deleted, location)
+			offset += length
+			continue
+		}
+
+		next := findSegmentRecord(data, offset+1)
+		if next < 0 {
+			if last {
+				if !errors.Is(err, errRecordIncomplete) {
+					ss.quarantine(seg, offset, data[offset:], err)
+				}
+				log.Printf("segment %s: incomplete record at offset %d, truncating", seg.path, offset)
+			} else {
+				ss.markCorrupt(seg, offset, data[offset:], err)
+			}
+			break
+		}
+		ss.markCorrupt(seg, offset, data[offset:next], err)
+		offset = next
+	}
+
+	if offset < int64(len(data)) {
+		if err := seg.file.Truncate(offset); err != nil {
+			return fmt.Errorf("failed to truncate segment %d: %w", seg.id, err)
+		}
+	}
+	seg.size = offset
+	return nil
+}
+
+// errRecordIncomplete 剩余字节不足一条完整记录
+var errRecordIncomplete = errors.New("incomplete segment record")
+
+// parseSegmentRecord 解析buf开头的一条记录，返回记录及其总长度
+func parseSegmentRecord(buf []byte) (*segmentRecord, int64, error) {
+	if len(buf) < segmentHeaderSize {
+		return nil, 0, errRecordIncomplete
+	}
+	length := int64(binary.BigEndian.Uint32(buf[0:4]))
+	if length > int64(len(buf)-segmentHeaderSize) {
+		return nil, 0, errRecordIncomplete
+	}
+	payload := buf[segmentHeaderSize : segmentHeaderSize+length]
+	if recordChecksum(payload) != binary.BigEndian.Uint32(buf[4:8]) {
+		return nil, 0, fmt.Errorf("%w: checksum mismatch", ErrBlockCorrupted)
+	}
+	record, _, err := decodeBlockPayload(payload)
+	if err != nil {
+		if !errors.Is(err, ErrBlockCorrupted) {
+			err = fmt.Errorf("%w: %v", ErrBlockCorrupted, err)
+		}
+		return nil, 0, err
+	}
+	return record, segmentHeaderSize + length, nil
+}
+
+// findSegmentRecord 从from开始查找下一条校验通过的记录的偏移，找不到时返回-1
+func findSegmentRecord(data []byte, from int64) int64 {
+	for offset := from; offset+segmentHeaderSize <= int64(len(data)); offset++ {
+		if _, _, err := parseSegmentRecord(data[offset:]); err == nil {
+			return offset
+		}
+	}
+	return -1
+}
+
+// markCorrupt 隔离损坏的记录，能解析出块ID时将该块标记为损坏并从索引中移除
+func (ss *segmentStore) markCorrupt(seg *segment, offset int64, raw []byte, cause error) {
+	seg.corrupt = true
+	corrupt := ss.quarantine(seg, offset, raw, cause)
+	if len(raw) > segmentHeaderSize {
+		// 校验和不匹配的负载仍可能解析出块ID，解析失败时无法确定是哪个块
+		if record, _, err := decodeBlockBody(raw[segmentHeaderSize:]); err == nil && record.BlockID != "" {
+			corrupt.BlockID = record.BlockID
+		}
+	}
+	if corrupt.BlockID == "" {
+		ss.unattributed = append(ss.unattributed, corrupt)
+		return
+	}
+	ss.applyRecord(corrupt.BlockID, true, BlockLocation{})
+	ss.corrupt[corrupt.BlockID] = corrupt
+}
+
+// quarantine 将损坏的字节复制到隔离目录
+func (ss *segmentStore) quarantine(seg *segment, offset int64, raw []byte, cause error) CorruptRecord {
+	corrupt := CorruptRecord{
+		SegmentID: seg.id,
+		Offset:    offset,
+		Length:    int64(len(raw)),
+		Reason:    cause.Error(),
+	}
+	dir := filepath.Join(ss.dir, segmentQuarantineDir)
+	path := filepath.Join(dir, fmt.Sprintf("%s%06d_%d.rec", segmentFilePrefix, seg.id, offset))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.Printf("segment %s: failed to quarantine record at offset %d: %v", seg.path, offset, err)
+	} else if err := os.WriteFile(path, raw, 0644); err != nil {
+		log.Printf("segment %s: failed to quarantine record at offset %d: %v", seg.path, offset, err)
+	} else {
+		corrupt.Quarantine = path
+	}
+	log.Printf("segment %s: corrupt record at offset %d (%d bytes): %v", seg.path, offset, len(raw), cause)
+	return corrupt
+}
+
+// applyRecord 用新记录更新索引与段引用计数
+func (ss *segmentStore) applyRecord(blockID string, deleted bool, location BlockLocation) {
+	// 块的新记录取代之前损坏的记录
+	delete(ss.corrupt, blockID)
+	if old, exists := ss.index[blockID]; exists {
+		if seg := ss.segments[old.SegmentID]; seg != nil {
+			seg.live--
+		}
+		ss.live -= old.Length
+		delete(ss.index, blockID)
+	}
+	if deleted {
+		return
+	}
+	ss.index[blockID] = location
+	ss.segments[location.SegmentID].live++
+	ss.live += location.Length
+}
+
+// rollSegment 封存当前活跃段并创建新的活跃段
+func (ss *segmentStore) rollSegment() (*segment, error) {
+	nextID := 1
+	if ss.active != nil {
+		ss.sealSegment(ss.active)
+		nextID = ss.active.id + 1
+	}
+	return ss.openSegment(nextID)
+}
+
+// rollNonEmpty 活跃段已有记录时滚动到新段
+func (ss *segmentStore) rollNonEmpty() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.active == nil {
+		return fmt.Errorf("segment store is closed")
+	}
+	if ss.active.size == 0 {
+		return nil
+	}
+	if err := ss.active.file.Sync(); err != nil {
+		return err
+	}
+	ss.unsynced = false
+	_, err := ss.rollSegment()
+	return err
+}
+
+func encodeSegmentRecord(record *segmentRecord, codec BlockCodec) ([]byte, error) {
+	payload, err := encodeBlockPayload(record, codec)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, segmentHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], recordChecksum(payload))
+	copy(buf[segmentHeaderSize:], payload)
+	return buf, nil
+}
+
+// appendRecord 追加一条记录到活跃段并更新索引
+func (ss *segmentStore) appendRecord(record *segmentRecord) (BlockLocation, error) {
+	buf, err := encodeSegmentRecord(record, ss.codec)
+	if err != nil {
+		return BlockLocation{}, fmt.Errorf("failed to encode block %s: %w", record.BlockID, err)
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.writeRecordLocked(record, buf)
+}
+
+// writeRecordLocked 把已编码的记录写入活跃段，调用方持有写锁
+func (ss *segmentStore) writeRecordLocked(record *segmentRecord, buf []byte) (BlockLocation, error) {
+	if ss.active == nil {
+		return BlockLocation{}, fmt.Errorf("segment store is closed")
+	}
+
+	// 活跃段写满后滚动
+	if ss.active.size > 0 && ss.active.size+int64(len(buf)) > ss.maxSize {
+		// 滚动前将旧段刷盘，非末尾段的残缺记录在重新打开时按损坏处理
+		if ss.durability != DurabilityNone {
+			if err := ss.active.file.Sync(); err != nil {
+				return BlockLocation{}, err
+			}
+			ss.unsynced = false
+		}
+		if _, err := ss.rollSegment(); err != nil {
+			return BlockLocation{}, err
+		}
+		if ss.syncEachWrite() {
+			if err := syncDir(ss.dir); err != nil {
+				return BlockLocation{}, fmt.Errorf("failed to sync segment directory: %w", err)
+			}
+		}
+	}
+
+	seg := ss.active
+	if _, err := seg.file.WriteAt(buf, seg.size); err != nil {
+		return BlockLocation{}, fmt.Errorf("failed to write segment %d: %w", seg.id, err)
+	}
+	if ss.syncEachWrite() {
+		if err := seg.file.Sync(); err != nil {
+			return BlockLocation{}, fmt.Errorf("failed to sync segment %d: %w", seg.id, err)
+		}
+	} else {
+		ss.unsynced = true
+	}
+
+	location := BlockLocation{
+		SegmentID: seg.id,
+		Offset:    seg.size,
+		Length:    int64(len(buf)),
+		Index:     record.blockIndex(),
+		Checksum:  blockChecksum(record.Messages),
+		Filters:   record.Filters,
+	}
+	seg.size += location.Length
+
+	ss.applyRecord(record.BlockID, record.Deleted, location)
+	seg.addIndexEntry(record.BlockID, record.Deleted, location)
+	ss.releaseEmptySegments()
+
+	return location, nil
+}
+
+// syncEachWrite 是否在每条记录写入后fsync
+func (ss *segmentStore) syncEachWrite() bool {
+	return ss.durability == "" || ss.durability == DurabilityAlways
+}
+
+// Sync 将活跃段中尚未fsync的写入刷盘，并fsync数据目录使新建与重命名的文件项落盘
+func (ss *segmentStore) Sync() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.active == nil {
+		return nil
+	}
+	if ss.unsynced {
+		if err := ss.active.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync segment %d: %w", ss.active.id, err)
+		}
+		ss.unsynced = false
+	}
+	return syncDir(ss.dir)
+}
+
+// releaseEmptySegments 从最旧的段开始删除没有有效记录的非活跃段，回收磁盘空间
+// 删除记录只会指向更旧的段，因此只有在更旧的段都已删除时，才能安全删除包含删除记录的段，
+// 否则重新扫描时被删除的块会复活
+func (ss *segmentStore) releaseEmptySegments() {
+	ids := make([]int, 0, len(ss.segments))
+	for id := range ss.segments {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		seg := ss.segments[id]
+		if seg == ss.active || seg.live > 0 {
+			return
+		}
+		// 先删除段索引文件，中途崩溃时留下的段文件会被重新扫描
+		if err := ss.removeSegmentIndex(seg); err != nil {
+			log.Printf("segment %s: failed to remove index file: %v", seg.path, err)
+			return
+		}
+		seg.file.Close()
+		if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+			log.Printf("segment %s: failed to remove: %v", seg.path, err)
+			return
+		}
+		delete(ss.segments, id)
+	}
+}
+
+// WriteBlock 写入块的全部消息，返回块的位置
+func (ss *segmentStore) WriteBlock(blockID string, messages []*Message) (BlockLocation, error) {
+	return ss.WriteBlockWithMeta(blockID, messages, blockMeta{Index: buildBlockIndex(messages)})
+}
+
+// WriteBlockWithMeta 写入块的全部消息及调用方已计算的块索引与过滤器
+func (ss *segmentStore) WriteBlockWithMeta(blockID string, messages []*Message, meta blockMeta) (BlockLocation, error) {
+	return ss.appendRecord(&segmentRecord{BlockID: blockID, Messages: messages, Index: &meta.Index, Filters: meta.Filters})
+}
+
+// DeleteBlock 删除块
+func (ss *segmentStore) DeleteBlock(blockID string) error {
+	if !ss.HasBlock(blockID) {
+		return nil
+	}
+	_, err := ss.appendRecord(&segmentRecord{BlockID: blockID, Deleted: true})
+	return err
+}
+
+// DeleteBlockAt 块的最新记录仍位于location时删除块，块已被重写或删除时返回false
+func (ss *segmentStore) DeleteBlockAt(blockID string, location BlockLocation) (bool, error) {
+	record := &segmentRecord{BlockID: blockID, Deleted: true}
+	buf, err := encodeSegmentRecord(record, ss.codec)
+	if err != nil {
+		return false, err
+	}
+
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	current, exists := ss.index[blockID]
+	if !exists || current.SegmentID != location.SegmentID || current.Offset != location.Offset {
+		return false, nil
+	}
+	if _, err := ss.writeRecordLocked(record, buf); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HasBlock 检查块是否已写入
+func (ss *segmentStore) HasBlock(blockID string) bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	_, exists := ss.index[blockID]
+	return exists
+}
+
+// Locations 返回所有块的位置
+func (ss *segmentStore) Locations() map[string]BlockLocation {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	locations := make(map[string]BlockLocation, len(ss.index))
+	for blockID, location := range ss.index {
+		locations[blockID] = location
+	}
+	return locations
+}
+
+// PersistedSeqID 块已写入的最后一条消息的SeqID，块未写入时返回false
+func (ss *segmentStore) PersistedSeqID(blockID string) (int64, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	location, exists := ss.index[blockID]
+	return location.Index.MaxSeqID, exists
+}
+
+// Location 获取块的位置
+func (ss *segmentStore) Location(blockID string) (BlockLocation, bool) {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	location, exists := ss.index[blockID]
+	return location, exists
+}
+
+// CorruptRecords 返回扫描时发现且尚未被新记录取代的损坏记录
+func (ss *segmentStore) CorruptRecords() []CorruptRecord {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	records := append([]CorruptRecord(nil), ss.unattributed...)
+	for _, record := range ss.corrupt {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].SegmentID != records[j].SegmentID {
+			return records[i].SegmentID < records[j].SegmentID
+		}
+		return records[i].Offset < records[j].Offset
+	})
+	return records
+}
+
+// IsCorrupt 检查块的最新记录是否损坏
+func (ss *segmentStore) IsCorrupt(blockID string) bool {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	_, corrupt := ss.corrupt[blockID]
+	return corrupt
+}
+
+// LiveBytes 已落盘块的记录总字节数，被覆盖或删除的旧记录不计入
+func (ss *segmentStore) LiveBytes() int64 {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+	return ss.live
+}
+
+// ReadBlock 读取块的全部消息，块不存在时返回 nil, false
+func (ss *segmentStore) ReadBlock(blockID string) ([]*Message, bool, error) {
+	record, _, err := ss.readRecord(blockID)
+	if err != nil || record == nil {
+		return nil, false, err
+	}
+	return record.Messages, true, nil
+}
+
+// ReadBlockWithMeta 读取块的全部消息及块索引与过滤器，旧记录没有索引时按消息重建
+func (ss *segmentStore) ReadBlockWithMeta(blockID string) ([]*Message, blockMeta, bool, error) {
+	record, _, err := ss.readRecord(blockID)
+	if err != nil || record == nil {
+		return nil, blockMeta{}, false, err
+	}
+	return record.Messages, blockMeta{Index: record.blockIndex(), Filters: record.Filters}, true, nil
+}
+
+// RawRecord 读取块最新记录的原始字节（含头部）及其位置，校验失败时返回ErrBlockCorrupted
+func (ss *segmentStore) RawRecord(blockID string) ([]byte, BlockLocation, bool, error) {
+	ss.mu.RLock()
+	location, exists := ss.index[blockID]
+	if !exists {
+		ss.mu.RUnlock()
+		return nil, BlockLocation{}, false, nil
+	}
+	seg := ss.segments[location.SegmentID]
+	if seg == nil {
+		ss.mu.RUnlock()
+		return nil, location, false, fmt.Errorf("segment %d not found for block %s", location.SegmentID, blockID)
+	}
+	buf := make([]byte, location.Length)
+	_, err := seg.file.ReadAt(buf, location.Offset)
+	ss.mu.RUnlock()
+	if err != nil {
+		return nil, location, false, fmt.Errorf("failed to read block %s: %w", blockID, err)
+	}
+
+	if recordChecksum(buf[segmentHeaderSize:]) != binary.BigEndian.Uint32(buf[4:8]) {
+		err := fmt.Errorf("%w: block %s checksum mismatch", ErrBlockCorrupted, blockID)
+		ss.markCorruptOnRead(blockID, location, buf, err)
+		return nil, location, false, err
+	}
+	return buf, location, true, nil
+}
+
+// readRecord 读取块的最新记录及其编码，块不存在时返回nil
+// 已封存段的记录在打开时没有校验，读取时校验失败的记录按损坏处理
+func (ss *segmentStore) readRecord(blockID string) (*segmentRecord, BlockCodec, error) {
+	buf, location, exists, err := ss.RawRecord(blockID)
+	if err != nil || !exists {
+		return nil, "", err
+	}
+
+	record, codec, err := decodeBlockPayload(buf[segmentHeaderSize:])
+	if err != nil {
+		if !errors.Is(err, ErrBlockCorrupted) {
+			err = fmt.Errorf("%w: %v", ErrBlockCorrupted, err)
+		}
+		ss.markCorruptOnRead(blockID, location, buf, err)
+		return nil, "", fmt.Errorf("failed to decode block %s: %w", blockID, err)
+	}
+	return record, codec, nil
+}
+
+// markCorruptOnRead 读取时发现块的最新记录损坏，与打开时发现的损坏一样隔离记录并将块标记为损坏
+// 删除所在段的段索引文件，下次打开时重新扫描该段并再次报告；块已被重写时不处理
+func (ss *segmentStore) markCorruptOnRead(blockID string, location BlockLocation, raw []byte, cause error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	current, exists := ss.index[blockID]
+	seg := ss.segments[location.SegmentID]
+	if !exists || seg == nil || current.SegmentID != location.SegmentID || current.Offset != location.Offset {
+		return
+	}
+	seg.corrupt = true
+	if err := ss.removeSegmentIndex(seg); err != nil {
+		log.Printf("segment %s: failed to remove index file: %v", seg.path, err)
+	}
+	corrupt := ss.quarantine(seg, location.Offset, raw, cause)
+	corrupt.BlockID = blockID
+	ss.applyRecord(blockID, true, BlockLocation{})
+	ss.corrupt[blockID] = corrupt
+}
+
+// Close 关闭所有段文件
+func (ss *segmentStore) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	var firstErr error
+	for _, seg := range ss.segments {
+		if err := seg.file.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	ss.segments = make(map[int]*segment)
+	ss.active = nil
+	return firstErr
+}
+
+// migrateGobBlocks 将旧格式的 block_*.gob 文件导入段文件，导入成功后删除旧文件
+func (ss *segmentStore) migrateGobBlocks(dir string) (int, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "block_*.gob"))
+	if err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for _, path := range paths {
+		blockID := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(path), "block_"), ".gob")
+
+		if !ss.HasBlock(blockID) {
+			messages, err := readGobBlockFile(path)
+			if err != nil {
+				return migrated, fmt.Errorf("failed to read legacy block %s: %w", blockID, err)
+			}
+			if _, err := ss.WriteBlock(blockID, messages); err != nil {
+				return migrated, err
+			}
+		}
+
+		if err := os.Remove(path); err != nil {
+			return migrated, fmt.Errorf("failed to remove legacy block %s: %w", blockID, err)
+		}
+		migrated++
+	}
+
+	return migrated, nil
+}
+
+// readGobBlockFile 读取旧格式的块文件（连续gob编码的Message）
+func readGobBlockFile(path string) ([]*Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := gob.NewDecoder(file)
+	var messages []*Message
+	for {
+		var msg Message
+		if err := decoder.Decode(&msg); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"hash/crc32"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// 段索引文件
+// 段写满滚动后不再修改，封存时把段内每条记录的块ID、位置、块索引、校验和与过滤器写入 segment_{id}.idx。
+// 打开时已封存的段直接按段索引文件建立BlockLocation，不读取段文件，记录的解码与校验推迟到读取块时，
+// 读取时发现的损坏同样隔离并标记（见readRecord），打开后后台校验也会逐条检查这些段（见segment_scrub.go）。
+// 活跃段以及没有有效段索引文件的段（旧版本写入、封存前崩溃或含有损坏记录）仍逐条扫描，
+// 扫描后没有损坏的已封存段随即补写段索引文件。
+// 文件格式为 [CRC32C uint32][gob编码的segmentIndexFile]，校验失败或记录的段长度与段文件不一致时按扫描处理。
+
+const segmentIndexSuffix = ".idx"
+
+// segmentIndexEntry 段内一条记录的索引，按写入顺序保存
+type segmentIndexEntry struct {
+	BlockID  string
+	Deleted  bool
+	Offset   int64
+	Length   int64
+	Index    BlockIndex
+	Checksum uint32
+	Filters  *blockFilters
+}
+
+// segmentIndexFile 段索引文件的内容
+type segmentIndexFile struct {
+	Size    int64 // 封存时段文件的长度
+	Entries []segmentIndexEntry
+}
+
+func (entry segmentIndexEntry) location(segmentID int) BlockLocation {
+	return BlockLocation{
+		SegmentID: segmentID,
+		Offset:    entry.Offset,
+		Length:    entry.Length,
+		Index:     entry.Index,
+		Checksum:  entry.Checksum,
+		Filters:   entry.Filters,
+	}
+}
+
+func (ss *segmentStore) segmentIndexPath(id int) string {
+	return filepath.Join(ss.dir, fmt.Sprintf("%s%06d%s", segmentFilePrefix, id, segmentIndexSuffix))
+}
+
+// addIndexEntry 登记段内新扫描或写入的记录，封存时写入段索引文件
+func (seg *segment) addIndexEntry(blockID string, deleted bool, location BlockLocation) {
+	seg.entries = append(seg.entries, segmentIndexEntry{
+		BlockID:  blockID,
+		Deleted:  deleted,
+		Offset:   location.Offset,
+		Length:   location.Length,
+		Index:    location.Index,
+		Checksum: location.Checksum,
+		Filters:  location.Filters,
+	})
+}
+
+// loadSegmentIndex 按段索引文件重建已封存段的索引，文件不存在或无效时返回false，由调用方扫描该段
+func (ss *segmentStore) loadSegmentIndex(seg *segment) bool {
+	data, err := os.ReadFile(ss.segmentIndexPath(seg.id))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("segment %s: failed to read index file, scanning: %v", seg.path, err)
+		}
+		return false
+	}
+	if len(data) < 4 || crc32.Checksum(data[4:], castagnoliTable) != binary.BigEndian.Uint32(data[:4]) {
+		log.Printf("segment %s: index file checksum mismatch, scanning", seg.path)
+		return false
+	}
+	var file segmentIndexFile
+	if err := gob.NewDecoder(bytes.NewReader(data[4:])).Decode(&file); err != nil {
+		log.Printf("segment %s: failed to decode index file, scanning: %v", seg.path, err)
+		return false
+	}
+	info, err := seg.file.Stat()
+	if err != nil || info.Size() != file.Size {
+		log.Printf("segment %s: index file does not match the segment, scanning", seg.path)
+		return false
+	}
+
+	for _, entry := range file.Entries {
+		ss.applyRecord(entry.BlockID, entry.Deleted, entry.location(seg.id))
+	}
+	seg.size = file.Size
+	seg.unverified = true
+	return true
+}
+
+// sealSegment 为不再写入的段写入段索引文件，段内有损坏记录时不写入，下次打开时重新扫描以报告损坏
+// 写入失败只影响下次打开的速度，记录日志后继续
+func (ss *segmentStore) sealSegment(seg *segment) {
+	entries := seg.entries
+	seg.entries = nil
+	if seg.corrupt {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(segmentIndexFile{Size: seg.size, Entries: entries}); err != nil {
+		log.Printf("segment %s: failed to encode index file: %v", seg.path, err)
+		return
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data[:4], crc32.Checksum(data[4:], castagnoliTable))
+	if err := writeFileDurable(ss.segmentIndexPath(seg.id), data, ss.durability); err != nil {
+		log.Printf("segment %s: failed to write index file: %v", seg.path, err)
+	}
+}
+
+// removeSegmentIndex 删除段索引文件，下次打开时重新扫描该段
+func (ss *segmentStore) removeSegmentIndex(seg *segment) error {
+	if err := os.Remove(ss.segmentIndexPath(seg.id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,477 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSegmentStoreRollAndReopen(t *testing.T) {
+	dir := t.TempDir()
+	ss, err := openSegmentStore(dir, 256, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+
+	payload := make([]byte, 100)
+	var last BlockLocation
+	for i, blockID := range []string{"b1", "b2", "b3", "b4"} {
+		location, err := ss.WriteBlock(blockID, []*Message{{SeqID: int64(i + 1), Data: payload}})
+		if err != nil {
+			t.Fatalf("Failed to write block: %v", err)
+		}
+		if i > 0 && location.SegmentID == last.SegmentID && location.Offset != last.Offset+last.Length {
+			t.Errorf("Block %s offset %d should follow previous record", blockID, location.Offset)
+		}
+		last = location
+	}
+	if last.SegmentID < 2 {
+		t.Errorf("Expected segments to roll, last segment is %d", last.SegmentID)
+	}
+
+	if err := ss.DeleteBlock("b2"); err != nil {
+		t.Fatalf("Failed to delete block: %v", err)
+	}
+	if _, err := ss.WriteBlock("b3", []*Message{{SeqID: 30}}); err != nil {
+		t.Fatalf("Failed to rewrite block: %v", err)
+	}
+	ss.Close()
+
+	reopened, err := openSegmentStore(dir, 256, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer reopened.Close()
+
+	if reopened.HasBlock("b2") {
+		t.Error("Deleted block should stay deleted after reopen")
+	}
+	messages, exists, err := reopened.ReadBlock("b3")
+	if err != nil || !exists || len(messages) != 1 || messages[0].SeqID != 30 {
+		t.Errorf("Expected rewritten block b3, got %v %v %v", messages, exists, err)
+	}
+	if messages, exists, _ := reopened.ReadBlock("b1"); !exists || len(messages[0].Data) != 100 {
+		t.Error("Expected block b1 to survive reopen")
+	}
+}
+
+func TestSegmentStoreOpensSealedSegmentsFromIndex(t *testing.T) {
+	dir := t.TempDir()
+	ss, err := openSegmentStore(dir, 256, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	locations := make(map[string]BlockLocation)
+	for i, blockID := range []string{"b1", "b2", "b3"} {
+		data := append([]byte(blockID+"-payload"), make([]byte, 100)...)
+		messages := []*Message{{SeqID: int64(i + 1), SenderID: 7, Data: data}}
+		location, err := ss.WriteBlockWithMeta(blockID, messages, blockMeta{Index: buildBlockIndex(messages), Filters: buildBlockFilters(messages, 1, 10)})
+		if err != nil {
+			t.Fatalf("Failed to write block: %v", err)
+		}
+		locations[blockID] = location
+	}
+	active := ss.active.id
+	ss.Close()
+
+	if _, err := os.Stat(ss.segmentIndexPath(locations["b1"].SegmentID)); err != nil {
+		t.Fatalf("Expected an index file for the sealed segment: %v", err)
+	}
+	if _, err := os.Stat(ss.segmentIndexPath(active)); !os.IsNotExist(err) {
+		t.Errorf("Expected no index file for the active segment, got %v", err)
+	}
+
+	// 已封存段的损坏在打开时不会被发现，位置、块索引与过滤器取自段索引文件
+	flipSegmentByte(t, dir, []byte("b1-payload"))
+	reopened, err := openSegmentStore(dir, 256, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	for blockID, want := range locations {
+		location, exists := reopened.Location(blockID)
+		if !exists || location.Offset != want.Offset || location.Index != want.Index || location.Checksum != want.Checksum {
+			t.Errorf("Expected location %+v for %s, got %+v", want, blockID, location)
+		}
+	}
+	if location, _ := reopened.Location("b1"); location.Filters == nil || !location.Filters.Senders.mayContain(senderBloomKey(7)) {
+		t.Error("Expected filters to be restored from the index file")
+	}
+	if reopened.IsCorrupt("b1") {
+		t.Error("Sealed segments should not be scanned on open")
+	}
+
+	// 读取时发现损坏，隔离记录并将块标记为损坏
+	if _, _, err := reopened.ReadBlock("b1"); !errors.Is(err, ErrBlockCorrupted) {
+		t.Fatalf("Expected ErrBlockCorrupted, got %v", err)
+	}
+	if reopened.HasBlock("b1") || !reopened.IsCorrupt("b1") {
+		t.Error("Expected b1 to be marked corrupt after the failed read")
+	}
+	if records := reopened.CorruptRecords(); len(records) != 1 || records[0].Quarantine == "" {
+		t.Errorf("Expected one quarantined record, got %+v", records)
+	}
+	if messages, exists, err := reopened.ReadBlock("b2"); err != nil || !exists || len(messages) != 1 {
+		t.Errorf("Expected block b2 to be readable, got %v %v", exists, err)
+	}
+	reopened.Close()
+
+	// 段索引文件已删除，再次打开时扫描该段并报告损坏
+	again, err := openSegmentStore(dir, 256, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer again.Close()
+	if again.HasBlock("b1") || !again.IsCorrupt("b1") {
+		t.Error("Expected the corruption to be reported again after reopening")
+	}
+}
+
+func TestSegmentStoreReleasesEmptySegments(t *testing.T) {
+	dir := t.TempDir()
+	ss, err := openSegmentStore(dir, 128, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	defer ss.Close()
+
+	payload := make([]byte, 100)
+	ss.WriteBlock("old", []*Message{{SeqID: 1, Data: payload}})
+	ss.WriteBlock("new", []*Message{{SeqID: 2, Data: payload}})
+	if err := ss.DeleteBlock("old"); err != nil {
+		t.Fatalf("Failed to delete block: %v", err)
+	}
+
+	if _, err := os.Stat(ss.segmentPath(1)); !os.IsNotExist(err) {
+		t.Error("Segment without live blocks should be removed")
+	}
+	if !ss.HasBlock("new") {
+		t.Error("Live block should remain")
+	}
+}
+
+func TestStoreMigratesLegacyGobBlocks(t *testing.T) {
+	dir := t.TempDir()
+
+	// 按旧格式写入块文件与元数据
+	blockID := "conv_legacy_1"
+	file, err := os.Create(filepath.Join(dir, "block_"+blockID+".gob"))
+	if err != nil {
+		t.Fatalf("Failed to create legacy block: %v", err)
+	}
+	encoder := gob.NewEncoder(file)
+	for i := 1; i <= 2; i++ {
+		encoder.Encode(&Message{SeqID: int64(i), ConvID: "legacy", CreateTime: time.Now(), Data: []byte("legacy")})
+	}
+	file.Close()
+	meta := `{"id":"legacy","type":"conv","last_seq_id":2,"block_ids":["` + blockID + `"]}`
+	if err := os.WriteFile(filepath.Join(dir, "conv_legacy.meta"), []byte(meta), 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, "block_"+blockID+".gob")); !os.IsNotExist(err) {
+		t.Error("Legacy block file should be removed after migration")
+	}
+
+	messages, _ := store.GetConvMessages("legacy", 10, 0)
+	if len(messages) != 2 || string(messages[0].Data) != "legacy" {
+		t.Fatalf("Expected 2 migrated messages, got %d", len(messages))
+	}
+
+	// 新消息的序列号接在旧数据之后
+	if err := store.AddMessage("legacy", 1, []byte("new"), nil); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	messages, _ = store.GetConvMessages("legacy", 10, 0)
+	if len(messages) != 3 || messages[2].SeqID <= 2 {
+		t.Errorf("Unexpected messages after migration: %d", len(messages))
+	}
+}
+
+func TestSegmentStoreProtobufRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ss, err := openSegmentStore(dir, 0, BlockCodecProtobuf)
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+
+	messages := []*Message{
+		{SeqID: 1, ConvID: "c1", SenderID: 7, CreateTime: time.Unix(0, 1000), Data: []byte("hello"), HLC: 11, ClientMsgID: "m1"},
+		{SeqID: 2, ConvID: "c1", SenderID: 8, CreateTime: time.Unix(0, 2000), Data: []byte("hi @u1"), HLC: 12, Mentions: []string{"u1"}, ReplyToSeqID: 1},
+	}
+	meta := blockMeta{Index: buildBlockIndex(messages), Filters: buildBlockFilters(messages, 10, 0)}
+	if _, err := ss.WriteBlockWithMeta("b1", messages, meta); err != nil {
+		t.Fatalf("Failed to write block: %v", err)
+	}
+	ss.Close()
+
+	reopened, err := openSegmentStore(dir, 0, BlockCodecProtobuf)
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer reopened.Close()
+
+	got, gotMeta, exists, err := reopened.ReadBlockWithMeta("b1")
+	if err != nil || !exists {
+		t.Fatalf("Expected block b1, got %v %v", exists, err)
+	}
+	if !reflect.DeepEqual(got, messages) {
+		t.Errorf("Messages changed after round trip: %+v", got)
+	}
+	if !reflect.DeepEqual(gotMeta, meta) {
+		t.Errorf("Expected meta %+v, got %+v", meta, gotMeta)
+	}
+	if _, codec, _ := reopened.readRecord("b1"); codec != BlockCodecProtobuf {
+		t.Errorf("Expected protobuf record, got %s", codec)
+	}
+}
+
+// writeLegacySegment 按加入版本头之前的格式写入一个段文件，负载为裸gob流
+func writeLegacySegment(t *testing.T, dir string, records ...*segmentRecord) {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, record := range records {
+		var payload bytes.Buffer
+		if err := gob.NewEncoder(&payload).Encode(record); err != nil {
+			t.Fatalf("Failed to encode legacy record: %v", err)
+		}
+		header := make([]byte, segmentHeaderSize)
+		binary.BigEndian.PutUint32(header[0:4], uint32(payload.Len()))
+		binary.BigEndian.PutUint32(header[4:8], crc32.ChecksumIEEE(payload.Bytes()))
+		buf.Write(header)
+		buf.Write(payload.Bytes())
+	}
+	path := filepath.Join(dir, segmentFilePrefix+"000001"+segmentFileSuffix)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("Failed to write legacy segment: %v", err)
+	}
+}
+
+func TestSegmentStoreReadsLegacyGobRecords(t *testing.T) {
+	dir := t.TempDir()
+	writeLegacySegment(t, dir,
+		&segmentRecord{BlockID: "old", Messages: []*Message{{SeqID: 1, Data: []byte("legacy")}}},
+		&segmentRecord{BlockID: "gone", Messages: []*Message{{SeqID: 2}}},
+		&segmentRecord{BlockID: "gone", Deleted: true},
+	)
+
+	ss, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	if ss.HasBlock("gone") {
+		t.Error("Legacy delete record should be applied")
+	}
+	messages, meta, exists, err := ss.ReadBlockWithMeta("old")
+	if err != nil || !exists || len(messages) != 1 || string(messages[0].Data) != "legacy" {
+		t.Fatalf("Expected legacy block, got %v %v %v", messages, exists, err)
+	}
+	if meta.Index.MaxSeqID != 1 || meta.Index.Count != 1 {
+		t.Errorf("Expected index rebuilt from messages, got %+v", meta.Index)
+	}
+
+	// 新记录追加在旧记录之后，重新打开后两种格式都能读取
+	if _, err := ss.WriteBlock("new", []*Message{{SeqID: 3}}); err != nil {
+		t.Fatalf("Failed to write block: %v", err)
+	}
+	ss.Close()
+
+	reopened, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer reopened.Close()
+	if _, codec, err := reopened.readRecord("old"); err != nil || codec != BlockCodecGob {
+		t.Errorf("Expected legacy gob record, got %s %v", codec, err)
+	}
+	if _, codec, err := reopened.readRecord("new"); err != nil || codec != BlockCodecProtobuf {
+		t.Errorf("Expected protobuf record, got %s %v", codec, err)
+	}
+}
+
+func TestConvertBlockCodec(t *testing.T) {
+	dir := t.TempDir()
+	writeLegacySegment(t, dir, &segmentRecord{BlockID: "legacy", Messages: []*Message{{SeqID: 1}}})
+	ss, err := openSegmentStore(dir, 0, BlockCodecGob)
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	for _, blockID := range []string{"b1", "b2"} {
+		if _, err := ss.WriteBlock(blockID, []*Message{{SeqID: 2, Data: []byte(blockID)}}); err != nil {
+			t.Fatalf("Failed to write block: %v", err)
+		}
+	}
+	ss.Close()
+
+	converted, err := ConvertBlockCodec(dir, BlockCodecProtobuf)
+	if err != nil || converted != 3 {
+		t.Fatalf("Expected 3 converted blocks, got %d %v", converted, err)
+	}
+	if converted, err := ConvertBlockCodec(dir, BlockCodecProtobuf); err != nil || converted != 0 {
+		t.Errorf("Second conversion should be a no-op, got %d %v", converted, err)
+	}
+
+	reopened, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer reopened.Close()
+	for _, blockID := range []string{"legacy", "b1", "b2"} {
+		record, codec, err := reopened.readRecord(blockID)
+		if err != nil || record == nil || codec != BlockCodecProtobuf {
+			t.Fatalf("Expected %s converted to protobuf, got %s %v", blockID, codec, err)
+		}
+		if record.Index == nil || record.Index.Count != 1 {
+			t.Errorf("Expected %s to carry its index after conversion, got %+v", blockID, record.Index)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, segmentFilePrefix+"000001"+segmentFileSuffix)); !os.IsNotExist(err) {
+		t.Error("Segment holding only converted records should be removed")
+	}
+
+	if _, err := ConvertBlockCodec(dir, "json"); err == nil {
+		t.Error("Expected unknown codec to be rejected")
+	}
+}
+
+func TestDecodeBlockPayloadRejectsUnknownHeader(t *testing.T) {
+	payload, err := encodeBlockPayload(&segmentRecord{BlockID: "b1"}, BlockCodecProtobuf)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	version := bytes.Clone(payload)
+	version[len(blockPayloadMagic)] = blockPayloadVersion + 1
+	if _, _, err := decodeBlockPayload(version); err == nil {
+		t.Error("Expected unsupported version to be rejected")
+	}
+	codec := bytes.Clone(payload)
+	codec[len(blockPayloadMagic)+1] = 9
+	if _, _, err := decodeBlockPayload(codec); err == nil {
+		t.Error("Expected unknown codec to be rejected")
+	}
+}
+
+// flipSegmentByte 在数据目录的段文件中找到marker并翻转其第一个字节
+func flipSegmentByte(t *testing.T, dir string, marker []byte) {
+	t.Helper()
+	paths, _ := filepath.Glob(filepath.Join(dir, segmentFilePrefix+"*"+segmentFileSuffix))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("Failed to read segment: %v", err)
+		}
+		if i := bytes.Index(data, marker); i >= 0 {
+			data[i] ^= 0xFF
+			if err := os.WriteFile(path, data, 0644); err != nil {
+				t.Fatalf("Failed to write segment: %v", err)
+			}
+			return
+		}
+	}
+	t.Fatalf("Marker %q not found in segments", marker)
+}
+
+func TestSegmentStoreQuarantinesCorruptRecord(t *testing.T) {
+	dir := t.TempDir()
+	ss, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	ss.WriteBlock("a", []*Message{{SeqID: 1, Data: []byte("a-old")}})
+	ss.WriteBlock("b", []*Message{{SeqID: 2, Data: []byte("b-only")}})
+	ss.WriteBlock("a", []*Message{{SeqID: 1, Data: []byte("a-new")}})
+	ss.WriteBlock("c", []*Message{{SeqID: 3, Data: []byte("c-only")}})
+	ss.Close()
+
+	flipSegmentByte(t, dir, []byte("a-new"))
+	reopened, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer reopened.Close()
+
+	// 损坏的最新记录不能回退到旧记录，之后的记录不受影响
+	if reopened.HasBlock("a") || !reopened.IsCorrupt("a") {
+		t.Error("Block with a corrupt latest record should be marked corrupt")
+	}
+	for _, blockID := range []string{"b", "c"} {
+		if messages, exists, err := reopened.ReadBlock(blockID); err != nil || !exists || len(messages) != 1 {
+			t.Errorf("Expected block %s to survive, got %v %v", blockID, exists, err)
+		}
+	}
+	records := reopened.CorruptRecords()
+	if len(records) != 1 || records[0].BlockID != "a" || records[0].Quarantine == "" {
+		t.Fatalf("Expected one quarantined record for a, got %+v", records)
+	}
+	if _, err := os.Stat(records[0].Quarantine); err != nil {
+		t.Errorf("Expected quarantine copy: %v", err)
+	}
+
+	if _, err := reopened.WriteBlock("a", []*Message{{SeqID: 1, Data: []byte("a-restored")}}); err != nil {
+		t.Fatalf("Failed to rewrite block: %v", err)
+	}
+	if reopened.IsCorrupt("a") || len(reopened.CorruptRecords()) != 0 {
+		t.Error("Rewriting the block should clear the corruption")
+	}
+}
+
+func TestSegmentStoreTruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+	ss, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to open segments: %v", err)
+	}
+	first, _ := ss.WriteBlock("a", []*Message{{SeqID: 1}})
+	ss.WriteBlock("b", []*Message{{SeqID: 2, Data: make([]byte, 64)}})
+	path := ss.segmentPath(first.SegmentID)
+	ss.Close()
+
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatalf("Failed to truncate segment: %v", err)
+	}
+	reopened, err := openSegmentStore(dir, 0, "")
+	if err != nil {
+		t.Fatalf("Failed to reopen segments: %v", err)
+	}
+	defer reopened.Close()
+
+	if !reopened.HasBlock("a") || reopened.HasBlock("b") {
+		t.Error("Expected only the complete record to survive")
+	}
+	if records := reopened.CorruptRecords(); len(records) != 0 {
+		t.Errorf("Torn tail should not be reported as corruption, got %+v", records)
+	}
+	if info, _ := os.Stat(path); info.Size() != first.Length {
+		t.Errorf("Expected segment truncated to %d bytes, got %d", first.Length, info.Size())
+	}
+}
+
+func TestDecodeBlockPayloadVerifiesBlockChecksum(t *testing.T) {
+	payload, err := encodeBlockPayload(&segmentRecord{BlockID: "b1", Messages: []*Message{{SeqID: 1, Data: []byte("intact")}}}, BlockCodecProtobuf)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	if _, _, err := decodeBlockPayload(payload); err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+
+	// 记录头CRC之外的损坏（如编码前内存中的数据被破坏）由块校验和发现
+	payload[bytes.Index(payload, []byte("intact"))] ^= 0xFF
+	if _, _, err := decodeBlockPayload(payload); !errors.Is(err, ErrBlockCorrupted) {
+		t.Errorf("Expected ErrBlockCorrupted, got %v", err)
+	}
+}
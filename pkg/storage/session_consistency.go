@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// 会话一致性（读自己的写）
+// SendMessage返回写入消息的SeqID和HLC作为一致性令牌，读取时传入令牌：
+// 消息缓存中的结果只有包含HLC不小于令牌的消息时才使用，否则从Store重新读取；
+// 主Store熔断需要读副本时，只使用已复制到令牌HLC的副本，副本都落后时等待追上，
+// 超过SetReplicaCatchUpTimeout设置的时间仍未追上则返回ErrReplicaBehind。
+// 拆分后的Timeline各段的SeqID互不可比，判断时只使用HLC
+
+// ErrReplicaBehind 主Store不可用且副本尚未复制到令牌的写入
+var ErrReplicaBehind = errors.New("replicas have not caught up with the session")
+
+const (
+	defaultReplicaCatchUpTimeout = 2 * time.Second
+	replicaCatchUpPollInterval   = 20 * time.Millisecond
+)
+
+// ConsistencyToken 一致性令牌，标识会话最近一次写入
+type ConsistencyToken struct {
+	TimelineKey string `json:"timeline_key"`
+	SeqID       int64  `json:"seq_id"` // 写入所在Timeline（拆分后为最后一段）分配的SeqID
+	HLC         int64  `json:"hlc"`
+}
+
+// SetReplicaCatchUpTimeout 设置带令牌的读取等待副本追上的最长时间，0表示不等待
+func (d *DistributedStoreAccessor) SetReplicaCatchUpTimeout(timeout time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.replicaWait = timeout
+}
+
+// GetMessagesConsistent 获取消息列表，保证结果不早于token对应的写入；token为nil时与GetMessages相同
+func (d *DistributedStoreAccessor) GetMessagesConsistent(ctx context.Context, timelineKey string, startTime, endTime int64, limit int, token *ConsistencyToken) ([]*Message, error) {
+	var minHLC int64
+	if token != nil {
+		minHLC = token.HLC
+	}
+	return d.getMessages(ctx, timelineKey, startTime, endTime, limit, minHLC)
+}
+
+// cachedMessagesFresh 缓存的结果是否包含HLC为minHLC的写入
+// 结果中有HLC不小于minHLC的消息说明缓存是在写入之后填充的
+func cachedMessagesFresh(messages []*Message, minHLC int64) bool {
+	if minHLC <= 0 {
+		return true
+	}
+	for _, msg := range messages {
+		if messageHLC(msg) >= minHLC {
+			return true
+		}
+	}
+	return false
+}
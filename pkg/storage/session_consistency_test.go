@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReadYourWritesBypassesStaleCache(t *testing.T) {
+	ctx := context.Background()
+	primary, ts := newTestRemoteStore(t)
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: primary.StoreID, Address: ts.URL})
+
+	timelineKey := "conv_session"
+	globalIndex := NewInMemoryGlobalIndex()
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: primary.StoreID, BlockID: "block_1"})
+	pool := NewStoreRPCClientPool(time.Second)
+	defer pool.Close()
+
+	// 两个节点各自有缓存，reader缓存的结果不知道writer的写入
+	newNode := func() *DistributedStoreAccessor {
+		local, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+		if err != nil {
+			t.Fatalf("Failed to create local store: %v", err)
+		}
+		t.Cleanup(func() { local.Close() })
+		return NewDistributedStoreAccessor(local, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+	}
+	reader, writer := newNode(), newNode()
+	end := time.Now().Unix() + 60
+
+	if _, err := writer.SendMessage(ctx, timelineKey, 1, []byte("first"), nil); err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if messages, _ := reader.GetMessages(ctx, timelineKey, 0, end, 10); len(messages) != 1 {
+		t.Fatalf("Expected one message, got %d", len(messages))
+	}
+	token, err := writer.SendMessage(ctx, timelineKey, 1, []byte("second"), nil)
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	if token.TimelineKey != timelineKey || token.SeqID != 2 || token.HLC == 0 {
+		t.Fatalf("Unexpected token %+v", token)
+	}
+
+	if messages, _ := reader.GetMessages(ctx, timelineKey, 0, end, 10); len(messages) != 1 {
+		t.Fatalf("Expected the cached result without a token, got %d messages", len(messages))
+	}
+	messages, err := reader.GetMessagesConsistent(ctx, timelineKey, 0, end, 10, token)
+	if err != nil {
+		t.Fatalf("Failed to read with the token: %v", err)
+	}
+	if len(messages) != 2 || string(messages[1].Data) != "second" {
+		t.Fatalf("Expected the session's write to be visible, got %d messages", len(messages))
+	}
+	// 重新读取的结果刷新了缓存
+	if messages, _ := reader.GetMessages(ctx, timelineKey, 0, end, 10); len(messages) != 2 {
+		t.Errorf("Expected the refreshed cache, got %d messages", len(messages))
+	}
+}
+
+func TestReadYourWritesWaitsForReplicaCatchUp(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	defer local.Close()
+	primary, primaryServer := newTestRemoteStore(t)
+	replica, replicaServer := newTestRemoteStore(t)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	router := NewConsistentHashRouter(3, 10, 0.8)
+	for _, info := range []*StoreInfo{
+		{ID: local.StoreID},
+		{ID: primary.StoreID, Address: primaryServer.URL},
+		{ID: replica.StoreID, Address: replicaServer.URL},
+	} {
+		registry.Register(ctx, info)
+		router.AddStore(&StoreInfo{ID: info.ID, Status: StoreStatusActive})
+	}
+
+	timelineKey := "conv_session_replica"
+	globalIndex := NewInMemoryGlobalIndex()
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: primary.StoreID, BlockID: "block_1"})
+	breakers := NewCircuitBreakerGroup(CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: time.Minute})
+	pool := NewStoreRPCClientPool(time.Second)
+	defer pool.Close()
+	pool.SetCircuitBreakers(breakers)
+
+	// 异步复制尚未启动，副本落后于主Store
+	policy := DefaultShardPolicy()
+	policy.ReplicationFactor = 3
+	replication := NewReplicationManager(local, router, registry, globalIndex, pool, policy)
+	accessor := NewDistributedStoreAccessor(local, pool, globalIndex, router, registry)
+	accessor.SetReplicationManager(replication)
+	accessor.SetReplicaCatchUpTimeout(50 * time.Millisecond)
+
+	token, err := accessor.SendMessage(ctx, timelineKey, 1, []byte("hello"), nil)
+	if err != nil {
+		t.Fatalf("Failed to send: %v", err)
+	}
+	primaryServer.Close()
+	end := time.Now().Unix() + 60
+	if _, err := accessor.GetMessagesConsistent(ctx, timelineKey, 0, end, 10, token); err == nil {
+		t.Fatalf("Expected the read to fail while the breaker is still closed")
+	}
+
+	if _, err := accessor.GetMessagesConsistent(ctx, timelineKey, 0, end, 10, token); !errors.Is(err, ErrReplicaBehind) {
+		t.Fatalf("Expected ErrReplicaBehind, got %v", err)
+	}
+
+	// 副本追上后从副本读到这次写入
+	accessor.SetReplicaCatchUpTimeout(2 * time.Second)
+	if err := replication.Start(ctx); err != nil {
+		t.Fatalf("Failed to start replication: %v", err)
+	}
+	defer replication.Stop()
+	messages, err := accessor.GetMessagesConsistent(ctx, timelineKey, 0, end, 10, token)
+	if err != nil {
+		t.Fatalf("Expected the caught-up replica to serve the read, got %v", err)
+	}
+	if len(messages) != 1 || messages[0].HLC != token.HLC {
+		t.Errorf("Expected the session's write from the replica, got %+v", messages)
+	}
+}
@@ -26,8 +26,16 @@ type ShardPolicy struct {
 	MaxSizePerStore     int64       `json:"max_size_per_store"`      // 每个Store最大数据大小(字节)
 	LoadBalanceThreshold float64    `json:"load_balance_threshold"`  // 负载均衡阈值(0.0-1.0)
 	ReplicationFactor   int         `json:"replication_factor"`      // 副本因子
+	ReplicationMode     ReplicationMode `json:"replication_mode"`     // 副本写入模式
 	AutoRebalance       bool        `json:"auto_rebalance"`          // 是否自动重平衡
 	RebalanceInterval   time.Duration `json:"rebalance_interval"`    // 重平衡检查间隔
+
+	// 自动重平衡的节流，见rebalance_throttle.go
+	MaxConcurrentMigrations   int                 `json:"max_concurrent_migrations"`     // 同时进行的迁移上限，0表示不限制
+	MaxTransferBytesPerSecond int64               `json:"max_transfer_bytes_per_second"` // 自动迁移的平均传输速率上限，0表示不限制
+	TimelineCooldown          time.Duration       `json:"timeline_cooldown"`             // 同一Timeline两次自动迁移的最小间隔
+	RebalanceWindows          []MaintenanceWindow `json:"rebalance_windows,omitempty"`   // 允许自动迁移的时间段，为空表示任何时间
+	BlackoutWindows           []MaintenanceWindow `json:"blackout_windows,omitempty"`    // 禁止自动迁移的时间段，优先于RebalanceWindows
 }
 
 // DefaultShardPolicy 默认分片策略
@@ -38,8 +46,12 @@ func DefaultShardPolicy() *ShardPolicy {
 		MaxSizePerStore:      10 * 1024 * 1024 * 1024, // 10GB
 		LoadBalanceThreshold: 0.8,
 		ReplicationFactor:    1,
+		ReplicationMode:      ReplicationAsync,
 		AutoRebalance:        true,
 		RebalanceInterval:    5 * time.Minute,
+
+		MaxConcurrentMigrations: 2,
+		TimelineCooldown:        time.Hour,
 	}
 }
 
@@ -60,6 +72,7 @@ type RebalanceRecommendation struct {
 	Reason      string `json:"reason"`
 	Priority    int    `json:"priority"`    // 优先级(1-10, 10最高)
 	ExpectedGain float64 `json:"expected_gain"` // 预期收益
+	Size        int64  `json:"size"`        // 需要迁移的数据大小(字节)
 }
 
 // ShardManager 分片管理器接口
@@ -84,6 +97,15 @@ type ShardManager interface {
 	
 	// GetShardStats 获取分片统计信息
 	GetShardStats(ctx context.Context) (*ShardStats, error)
+	
+	// RecordPlacement 记录新Timeline实际放置的Store
+	RecordPlacement(timelineKey string, storeID string, estimatedSize int64)
+
+	// GetStatsHistory 获取不早于since的分片负载采样
+	GetStatsHistory(since time.Time) []*ShardStatsSample
+
+	// GetRebalanceReport 获取自动重平衡的效果报告
+	GetRebalanceReport() *RebalanceReport
 }
 
 // ShardStats 分片统计信息
@@ -96,6 +118,9 @@ type ShardStats struct {
 	StoreStats       map[string]*ShardStoreStats `json:"store_stats"`
 	LastRebalance    *time.Time                  `json:"last_rebalance,omitempty"`
 	RebalanceCount   int                         `json:"rebalance_count"`
+
+	ThrottledRebalances int    `json:"throttled_rebalances"`           // 因节流没有开始的自动迁移次数
+	LastThrottleReason  string `json:"last_throttle_reason,omitempty"` // 最近一次节流的原因
 }
 
 // ShardStoreStats Store分片统计信息
@@ -105,7 +130,13 @@ type ShardStoreStats struct {
 	TotalSize      int64   `json:"total_size"`
 	LoadFactor     float64 `json:"load_factor"`     // 负载因子(0.0-1.0)
 	HealthScore    float64 `json:"health_score"`    // 健康评分(0.0-1.0)
+	Status         StoreStatus `json:"status,omitempty"`
 	LastUpdate     time.Time `json:"last_update"`
+
+	// 放置记录，来自RecordPlacement
+	Placements    int        `json:"placements"`               // 放置到该Store的新Timeline数
+	PlacedSize    int64      `json:"placed_size"`              // 放置时估计的数据大小之和
+	LastPlacement *time.Time `json:"last_placement,omitempty"` // 最近一次放置的时间
 }
 
 // TimelineShardManager Timeline分片管理器实现
@@ -119,6 +150,15 @@ type TimelineShardManager struct {
 	autoRebalanceStop chan struct{}
 	autoRebalanceRunning bool
 	stats             *ShardStats
+	throttle          *rebalanceThrottle
+	now               func() time.Time
+	leaderElector     LeaderElector // 设置后只有协调者领导者执行自动重平衡
+
+	// 统计历史，见shard_stats_history.go
+	history           *shardStatsHistory
+	rebalanceEvents   []*RebalanceEvent
+	samplingStop      chan struct{}
+	samplingRunning   bool
 }
 
 // NewTimelineShardManager 创建Timeline分片管理器
@@ -135,6 +175,9 @@ func NewTimelineShardManager(
 		routerManager:    routerManager,
 		migrationManager: migrationManager,
 		stats:            &ShardStats{StoreStats: make(map[string]*ShardStoreStats)},
+		throttle:         newRebalanceThrottle(),
+		now:              time.Now,
+		history:          newShardStatsHistory(DefaultShardStatsHistorySize),
 	}
 }
 
@@ -149,6 +192,8 @@ func (tsm *TimelineShardManager) GetShardRecommendation(ctx context.Context, tim
 		return nil, fmt.Errorf("failed to list stores: %w", err)
 	}
 	
+	// 租户有专属Store时只在专属Store中选择
+	stores = filterStoresForTenant(stores, timelineKey)
 	if len(stores) == 0 {
 		return nil, fmt.Errorf("no available stores")
 	}
@@ -182,7 +227,7 @@ func (tsm *TimelineShardManager) recommendByHash(ctx context.Context, timelineKe
 	// 获取备选Store
 	alternatives := make([]string, 0, len(stores)-1)
 	for _, store := range stores {
-		if store.ID != recommendedStore {
+		if store.ID != recommendedStore && store.Status.AcceptsPlacements() {
 			alternatives = append(alternatives, store.ID)
 		}
 	}
@@ -207,7 +252,7 @@ func (tsm *TimelineShardManager) recommendByLoad(ctx context.Context, timelineKe
 	
 	storeLoads := make([]*storeLoad, 0, len(stores))
 	for _, store := range stores {
-		if store.Status != "active" {
+		if !store.Status.AcceptsPlacements() {
 			continue // 跳过不活跃的Store
 		}
 		
@@ -271,7 +316,7 @@ func (tsm *TimelineShardManager) recommendBySize(ctx context.Context, timelineKe
 	
 	validStores := make([]*storeCapacity, 0, len(stores))
 	for _, store := range stores {
-		if store.Status != "active" {
+		if !store.Status.AcceptsPlacements() {
 			continue
 		}
 		
@@ -357,7 +402,7 @@ func (tsm *TimelineShardManager) GetRebalanceRecommendations(ctx context.Context
 	
 	storeLoads := make([]*storeLoadData, 0, len(stores))
 	for _, store := range stores {
-		if store.Status != "active" {
+		if !store.Status.AcceptsPlacements() {
 			continue
 		}
 		
@@ -370,12 +415,16 @@ func (tsm *TimelineShardManager) GetRebalanceRecommendations(ctx context.Context
 		if err != nil {
 			continue
 		}
+		// 索引与注册中心返回的顺序不固定，排序使相同负载下的推荐结果可重现
+		sort.Strings(timelines)
 		
 		loadFactor := tsm.calculateLoadFactor(loadInfo, 0)
 		
+		// 下面推演迁移效果时会修改负载信息，不能改动索引返回的对象
+		loadCopy := *loadInfo
 		storeLoads = append(storeLoads, &storeLoadData{
 			storeInfo:  store,
-			loadInfo:   loadInfo,
+			loadInfo:   &loadCopy,
 			loadFactor: loadFactor,
 			timelines:  timelines,
 		})
@@ -385,9 +434,12 @@ func (tsm *TimelineShardManager) GetRebalanceRecommendations(ctx context.Context
 		return nil, nil // 至少需要2个Store才能重平衡
 	}
 	
-	// 按负载因子排序
+	// 按负载因子排序，负载相同时按Store ID
 	sort.Slice(storeLoads, func(i, j int) bool {
-		return storeLoads[i].loadFactor > storeLoads[j].loadFactor
+		if storeLoads[i].loadFactor != storeLoads[j].loadFactor {
+			return storeLoads[i].loadFactor > storeLoads[j].loadFactor
+		}
+		return storeLoads[i].storeInfo.ID < storeLoads[j].storeInfo.ID
 	})
 	
 	var recommendations []*RebalanceRecommendation
@@ -427,6 +479,7 @@ func (tsm *TimelineShardManager) GetRebalanceRecommendations(ctx context.Context
 					Reason:       fmt.Sprintf("Load balancing: %.2f -> %.2f", highLoadStore.loadFactor, lowLoadStore.loadFactor),
 					Priority:     int(expectedGain * 10),
 					ExpectedGain: expectedGain,
+					Size:         location.TotalSize,
 				})
 				
 				// 更新负载信息用于下次计算
@@ -440,8 +493,8 @@ func (tsm *TimelineShardManager) GetRebalanceRecommendations(ctx context.Context
 		}
 	}
 	
-	// 按优先级排序
-	sort.Slice(recommendations, func(i, j int) bool {
+	// 按优先级排序，优先级相同时保持负载从高到低的顺序
+	sort.SliceStable(recommendations, func(i, j int) bool {
 		return recommendations[i].Priority > recommendations[j].Priority
 	})
 	
@@ -473,6 +526,10 @@ func (tsm *TimelineShardManager) calculateMigrationGain(highLoadStore, lowLoadSt
 
 // UpdateShardPolicy 更新分片策略
 func (tsm *TimelineShardManager) UpdateShardPolicy(policy *ShardPolicy) error {
+	if err := validateRebalanceThrottle(policy); err != nil {
+		return err
+	}
+
 	tsm.mu.Lock()
 	defer tsm.mu.Unlock()
 	
@@ -487,9 +544,18 @@ func (tsm *TimelineShardManager) GetShardPolicy() *ShardPolicy {
 	
 	// 返回副本
 	policyCopy := *tsm.policy
+	policyCopy.RebalanceWindows = append([]MaintenanceWindow(nil), tsm.policy.RebalanceWindows...)
+	policyCopy.BlackoutWindows = append([]MaintenanceWindow(nil), tsm.policy.BlackoutWindows...)
 	return &policyCopy
 }
 
+// SetLeaderElector 设置协调者选举，多个节点开启自动重平衡时只有领导者发起迁移
+func (tsm *TimelineShardManager) SetLeaderElector(elector LeaderElector) {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	tsm.leaderElector = elector
+}
+
 // StartAutoRebalance 启动自动重平衡
 func (tsm *TimelineShardManager) StartAutoRebalance(ctx context.Context) error {
 	tsm.mu.Lock()
@@ -540,35 +606,122 @@ func (tsm *TimelineShardManager) autoRebalanceLoop(ctx context.Context) {
 }
 
 // performAutoRebalance 执行自动重平衡
+// 按优先级依次开始推荐的迁移，受分片策略中的时间段、并发数、冷却期和传输预算限制
+// 设置了协调者选举时只有领导者执行，迁移在领导者任期的ctx中发起，失去领导者身份时中断
 func (tsm *TimelineShardManager) performAutoRebalance(ctx context.Context) {
+	tsm.mu.RLock()
+	elector := tsm.leaderElector
+	tsm.mu.RUnlock()
+	if elector != nil {
+		termCtx, err := elector.LeaderContext()
+		if err != nil {
+			return
+		}
+		ctx = termCtx
+	}
+
+	now := tsm.now()
+	policy := tsm.GetShardPolicy()
+	if open, reason := rebalanceWindowOpen(policy, now); !open {
+		tsm.recordThrottle(reason)
+		return
+	}
+
+	slots := -1
+	if policy.MaxConcurrentMigrations > 0 {
+		active, err := tsm.activeMigrations(ctx)
+		if err != nil {
+			fmt.Printf("Failed to list migrations: %v\n", err)
+			return
+		}
+		if slots = policy.MaxConcurrentMigrations - active; slots <= 0 {
+			tsm.recordThrottle(fmt.Sprintf("%d migrations in progress", active))
+			return
+		}
+	}
+
 	recommendations, err := tsm.GetRebalanceRecommendations(ctx)
 	if err != nil {
 		fmt.Printf("Failed to get rebalance recommendations: %v\n", err)
 		return
 	}
-	
 	if len(recommendations) == 0 {
-		return // 无需重平衡
+		return
 	}
-	
-	// 执行优先级最高的重平衡
-	recommendation := recommendations[0]
-	
-	_, err = tsm.migrationManager.StartMigration(ctx, recommendation.TimelineKey, recommendation.ToStore)
+
+	// 记录迁移前的负载方差，用于评估重平衡效果
+	var varianceBefore float64
+	if stats, err := tsm.GetShardStats(ctx); err == nil {
+		varianceBefore = stats.LoadVariance
+	}
+
+	for _, recommendation := range recommendations {
+		if slots == 0 {
+			tsm.recordThrottle(fmt.Sprintf("%d migrations in progress", policy.MaxConcurrentMigrations))
+			return
+		}
+
+		tsm.mu.Lock()
+		reason := tsm.throttle.reserve(policy, recommendation.TimelineKey, recommendation.Size, now)
+		tsm.mu.Unlock()
+		if reason != "" {
+			tsm.recordThrottle(reason)
+			continue
+		}
+
+		task, err := tsm.migrationManager.StartMigration(ctx, recommendation.TimelineKey, recommendation.ToStore)
+		if err != nil {
+			tsm.mu.Lock()
+			tsm.throttle.release(policy, recommendation.TimelineKey, recommendation.Size)
+			tsm.mu.Unlock()
+			fmt.Printf("Failed to start migration for %s: %v\n", recommendation.TimelineKey, err)
+			continue
+		}
+		slots--
+
+		// 更新统计信息
+		tsm.mu.Lock()
+		tsm.stats.RebalanceCount++
+		startedAt := now
+		tsm.stats.LastRebalance = &startedAt
+		tsm.recordRebalanceEventLocked(&RebalanceEvent{
+			MigrationID:    task.ID,
+			TimelineKey:    recommendation.TimelineKey,
+			FromStore:      recommendation.FromStore,
+			ToStore:        recommendation.ToStore,
+			ExpectedGain:   recommendation.ExpectedGain,
+			StartedAt:      startedAt,
+			VarianceBefore: varianceBefore,
+			Status:         task.Status,
+		})
+		tsm.mu.Unlock()
+
+		fmt.Printf("Started auto rebalance: %s from %s to %s\n",
+			recommendation.TimelineKey, recommendation.FromStore, recommendation.ToStore)
+	}
+}
+
+// activeMigrations 统计等待中和进行中的迁移，包括手动和故障转移发起的迁移
+func (tsm *TimelineShardManager) activeMigrations(ctx context.Context) (int, error) {
+	tasks, err := tsm.migrationManager.ListMigrations(ctx, "")
 	if err != nil {
-		fmt.Printf("Failed to start migration for %s: %v\n", recommendation.TimelineKey, err)
-		return
+		return 0, err
 	}
-	
-	// 更新统计信息
+	active := 0
+	for _, task := range tasks {
+		if task.Status == MigrationPending || task.Status == MigrationRunning {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// recordThrottle 记录一次因节流没有开始的自动迁移
+func (tsm *TimelineShardManager) recordThrottle(reason string) {
 	tsm.mu.Lock()
-	tsm.stats.RebalanceCount++
-	now := time.Now()
-	tsm.stats.LastRebalance = &now
+	tsm.stats.ThrottledRebalances++
+	tsm.stats.LastThrottleReason = reason
 	tsm.mu.Unlock()
-	
-	fmt.Printf("Started auto rebalance: %s from %s to %s\n", 
-		recommendation.TimelineKey, recommendation.FromStore, recommendation.ToStore)
 }
 
 // GetShardStats 获取分片统计信息
@@ -587,6 +740,9 @@ func (tsm *TimelineShardManager) GetShardStats(ctx context.Context) (*ShardStats
 		StoreStats:     make(map[string]*ShardStoreStats),
 		LastRebalance:  tsm.stats.LastRebalance,
 		RebalanceCount: tsm.stats.RebalanceCount,
+
+		ThrottledRebalances: tsm.stats.ThrottledRebalances,
+		LastThrottleReason:  tsm.stats.LastThrottleReason,
 	}
 	
 	var totalTimelines int
@@ -601,7 +757,7 @@ func (tsm *TimelineShardManager) GetShardStats(ctx context.Context) (*ShardStats
 		
 		loadFactor := tsm.calculateLoadFactor(loadInfo, 0)
 		healthScore := 1.0
-		if store.Status != "active" {
+		if !store.Status.Serving() {
 			healthScore = 0.0
 		}
 		
@@ -611,8 +767,14 @@ func (tsm *TimelineShardManager) GetShardStats(ctx context.Context) (*ShardStats
 			TotalSize:     loadInfo.TotalSize,
 			LoadFactor:    loadFactor,
 			HealthScore:   healthScore,
+			Status:        store.Status,
 			LastUpdate:    loadInfo.LastUpdate,
 		}
+		if placed, exists := tsm.stats.StoreStats[store.ID]; exists {
+			stats.StoreStats[store.ID].Placements = placed.Placements
+			stats.StoreStats[store.ID].PlacedSize = placed.PlacedSize
+			stats.StoreStats[store.ID].LastPlacement = placed.LastPlacement
+		}
 		
 		totalTimelines += loadInfo.TimelineCount
 		totalSize += loadInfo.TotalSize
@@ -641,6 +803,22 @@ func (tsm *TimelineShardManager) GetShardStats(ctx context.Context) (*ShardStats
 	return stats, nil
 }
 
+// RecordPlacement 记录新Timeline实际放置的Store，在分片统计中返回
+func (tsm *TimelineShardManager) RecordPlacement(timelineKey string, storeID string, estimatedSize int64) {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+
+	placed, exists := tsm.stats.StoreStats[storeID]
+	if !exists {
+		placed = &ShardStoreStats{StoreID: storeID}
+		tsm.stats.StoreStats[storeID] = placed
+	}
+	now := time.Now()
+	placed.Placements++
+	placed.PlacedSize += estimatedSize
+	placed.LastPlacement = &now
+}
+
 // min 辅助函数
 func min(a, b int) int {
 	if a < b {
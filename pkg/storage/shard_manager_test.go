@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRebalanceShardManager 创建两个高负载Store和两个空Store的分片管理器，
+// 每次检查推荐conv_a1和conv_b1两个迁移
+func newTestRebalanceShardManager(t *testing.T, policy *ShardPolicy) (*TimelineShardManager, *recordingMigrationManager) {
+	t.Helper()
+	ctx := context.Background()
+	registry := NewInMemoryRegistry()
+	t.Cleanup(func() { registry.Close() })
+	for _, id := range []string{"store_a", "store_b", "store_c", "store_d"} {
+		registry.Register(ctx, &StoreInfo{ID: id})
+	}
+
+	globalIndex := NewInMemoryGlobalIndex()
+	sizes := map[string]map[string]int64{
+		"store_a": {"conv_a1": 500, "conv_a2": 40, "conv_a3": 40},
+		"store_b": {"conv_b1": 300, "conv_b2": 30, "conv_b3": 30},
+	}
+	for storeID, timelines := range sizes {
+		for key, size := range timelines {
+			globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: key, StoreID: storeID, BlockID: "block_" + key, Size: size})
+		}
+	}
+
+	policy.AutoRebalance = true
+	policy.MaxTimelinePerStore = 1000
+	policy.MaxSizePerStore = 1000
+	policy.LoadBalanceThreshold = 0.3
+	policy.RebalanceInterval = time.Minute
+	migrations := &recordingMigrationManager{}
+	shards := NewTimelineShardManager(globalIndex, registry, NewRouterManager(), migrations)
+	if err := shards.UpdateShardPolicy(policy); err != nil {
+		t.Fatalf("Failed to update policy: %v", err)
+	}
+	return shards, migrations
+}
+
+// startedBy 执行一次自动重平衡，返回新开始迁移的Timeline
+func startedBy(shards *TimelineShardManager, migrations *recordingMigrationManager) []string {
+	before := len(migrations.tasks)
+	shards.performAutoRebalance(context.Background())
+	var keys []string
+	for _, task := range migrations.tasks[before:] {
+		keys = append(keys, task.TimelineKey)
+	}
+	return keys
+}
+
+func TestMaintenanceWindowContains(t *testing.T) {
+	monday := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	at := func(day int, clock string) time.Time {
+		offset, _ := parseClock(clock)
+		return monday.AddDate(0, 0, day).Add(offset)
+	}
+
+	overnight := MaintenanceWindow{Start: "22:00", End: "06:00", Weekdays: []time.Weekday{time.Sunday}}
+	cases := []struct {
+		window MaintenanceWindow
+		at     time.Time
+		want   bool
+	}{
+		{MaintenanceWindow{Start: "09:00", End: "17:00"}, at(0, "09:00"), true},
+		{MaintenanceWindow{Start: "09:00", End: "17:00"}, at(0, "17:00"), false},
+		{MaintenanceWindow{Start: "00:00", End: "00:00"}, at(3, "13:37"), true},
+		// 跨越午夜的部分属于开始的那一天
+		{overnight, at(-1, "23:00"), true},
+		{overnight, at(0, "05:59"), true},
+		{overnight, at(0, "06:00"), false},
+		{overnight, at(0, "23:00"), false},
+		{overnight, at(-1, "21:59"), false},
+	}
+	for _, c := range cases {
+		if got := c.window.contains(c.at); got != c.want {
+			t.Errorf("%+v contains %s: expected %v, got %v", c.window, c.at.Format(time.RFC1123), c.want, got)
+		}
+	}
+
+	policy := DefaultShardPolicy()
+	policy.BlackoutWindows = []MaintenanceWindow{{Start: "25:00", End: "06:00"}}
+	if err := validateRebalanceThrottle(policy); err == nil {
+		t.Errorf("Expected an invalid window to be rejected")
+	}
+}
+
+func TestAutoRebalanceThrottle(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	policy := DefaultShardPolicy()
+	policy.MaxConcurrentMigrations = 1
+	policy.TimelineCooldown = time.Hour
+	policy.BlackoutWindows = []MaintenanceWindow{{Start: "11:00", End: "13:00"}}
+	shards, migrations := newTestRebalanceShardManager(t, policy)
+	shards.now = func() time.Time { return now }
+
+	// 禁止时间段和允许时间段之外不开始迁移
+	if started := startedBy(shards, migrations); len(started) != 0 {
+		t.Fatalf("Expected no migrations during the blackout, got %v", started)
+	}
+	policy.BlackoutWindows = nil
+	policy.RebalanceWindows = []MaintenanceWindow{{Start: "01:00", End: "05:00"}}
+	shards.UpdateShardPolicy(policy)
+	if started := startedBy(shards, migrations); len(started) != 0 {
+		t.Fatalf("Expected no migrations outside the rebalance windows, got %v", started)
+	}
+	stats, _ := shards.GetShardStats(context.Background())
+	if stats.ThrottledRebalances != 2 || !strings.Contains(stats.LastThrottleReason, "outside") {
+		t.Fatalf("Expected throttled checks in the stats, got %d %q", stats.ThrottledRebalances, stats.LastThrottleReason)
+	}
+
+	// 并发上限为1，进行中的迁移完成后才开始下一个
+	policy.RebalanceWindows = nil
+	shards.UpdateShardPolicy(policy)
+	first := startedBy(shards, migrations)
+	if len(first) != 1 {
+		t.Fatalf("Expected one migration, got %v", first)
+	}
+	if started := startedBy(shards, migrations); len(started) != 0 {
+		t.Fatalf("Expected the concurrency limit to hold, got %v", started)
+	}
+	migrations.tasks[0].Status = MigrationCompleted
+
+	// 刚迁移过的Timeline在冷却期内，开始另一个推荐
+	second := startedBy(shards, migrations)
+	if len(second) != 1 || second[0] == first[0] {
+		t.Fatalf("Expected the other recommendation after %v, got %v", first, second)
+	}
+	migrations.tasks[1].Status = MigrationCompleted
+	if started := startedBy(shards, migrations); len(started) != 0 {
+		t.Fatalf("Expected both timelines to be cooling down, got %v", started)
+	}
+	now = now.Add(time.Hour)
+	if started := startedBy(shards, migrations); len(started) != 1 {
+		t.Fatalf("Expected migrations to resume after the cooldown, got %v", started)
+	}
+}
+
+func TestAutoRebalanceTransferBudget(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	policy := DefaultShardPolicy()
+	policy.MaxConcurrentMigrations = 0
+	policy.TimelineCooldown = 0
+	policy.MaxTransferBytesPerSecond = 10 // 每个间隔600字节
+	shards, migrations := newTestRebalanceShardManager(t, policy)
+	shards.now = func() time.Time { return now }
+
+	// 500+300超过预算，每个间隔只开始一个
+	if started := startedBy(shards, migrations); len(started) != 1 {
+		t.Fatalf("Expected the budget to admit one migration, got %v", started)
+	}
+	now = now.Add(time.Minute)
+	if started := startedBy(shards, migrations); len(started) != 1 {
+		t.Fatalf("Expected the refilled budget to admit one migration, got %v", started)
+	}
+
+	// 大于预算上限的Timeline在预算满时放行，之后等待预算恢复
+	policy.MaxTransferBytesPerSecond = 1
+	shards, migrations = newTestRebalanceShardManager(t, policy)
+	shards.now = func() time.Time { return now }
+	if started := startedBy(shards, migrations); len(started) != 1 {
+		t.Fatalf("Expected an oversized timeline to start with a full budget, got %v", started)
+	}
+	now = now.Add(time.Minute)
+	if started := startedBy(shards, migrations); len(started) != 0 {
+		t.Fatalf("Expected the overdrawn budget to hold further migrations, got %v", started)
+	}
+}
+
+func TestShardStatsHistoryAndRebalanceReport(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	policy := DefaultShardPolicy()
+	policy.MaxConcurrentMigrations = 0
+	shards, migrations := newTestRebalanceShardManager(t, policy)
+	shards.now = func() time.Time { return now }
+	shards.SetStatsHistorySize(3)
+
+	before, err := shards.SampleShardStats(ctx)
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+	if before.StoreLoads["store_a"] != 0.58 || before.LoadVariance == 0 {
+		t.Fatalf("Unexpected sample %+v", before)
+	}
+	started := startedBy(shards, migrations)
+	if len(started) != 2 {
+		t.Fatalf("Expected two migrations, got %v", started)
+	}
+
+	// 一个迁移完成，另一个失败
+	done, failed := migrations.tasks[0], migrations.tasks[1]
+	location, _ := shards.globalIndex.GetTimelineLocation(ctx, done.TimelineKey)
+	from := location.Blocks[0].StoreID
+	if err := shards.globalIndex.MigrateTimeline(ctx, done.TimelineKey, from, done.TargetStore); err != nil {
+		t.Fatalf("Failed to move %s: %v", done.TimelineKey, err)
+	}
+	done.Status = MigrationCompleted
+	failed.Status = MigrationFailed
+	now = now.Add(time.Minute)
+	after, _ := shards.SampleShardStats(ctx)
+
+	report := shards.GetRebalanceReport()
+	if len(report.Events) != 2 || report.Completed != 1 || report.Failed != 1 || report.Improved != 1 {
+		t.Fatalf("Unexpected report %+v", report)
+	}
+	for _, event := range report.Events {
+		if event.MigrationID != done.ID {
+			if event.VarianceAfter != nil {
+				t.Errorf("Expected no variance after a failed migration, got %+v", event)
+			}
+			continue
+		}
+		// 方差的求和顺序不固定，按误差比较
+		if math.Abs(event.VarianceBefore-before.LoadVariance) > 1e-9 || *event.VarianceAfter != after.LoadVariance || event.Improvement <= 0 {
+			t.Errorf("Expected the completed migration to reduce variance, got %+v", event)
+		}
+	}
+	if report.AverageImprovement <= 0 || *report.CurrentVariance != after.LoadVariance {
+		t.Errorf("Unexpected summary %+v", report)
+	}
+
+	// 环形缓冲只保留最近的采样
+	for i := 0; i < 3; i++ {
+		now = now.Add(time.Minute)
+		shards.SampleShardStats(ctx)
+	}
+	history := shards.GetStatsHistory(time.Time{})
+	if len(history) != 3 || !history[0].Time.Equal(now.Add(-2*time.Minute)) || !history[2].Time.Equal(now) {
+		t.Fatalf("Expected the three latest samples in order, got %d", len(history))
+	}
+	if recent := shards.GetStatsHistory(now.Add(-time.Minute)); len(recent) != 2 {
+		t.Errorf("Expected two samples since a minute ago, got %d", len(recent))
+	}
+}
@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// 分片统计历史
+// 周期性地对GetShardStats采样，保存在固定容量的环形缓冲中，容量满后覆盖最早的采样。
+// 自动重平衡开始的每个迁移记为一个重平衡事件，记录开始前的负载方差；迁移结束后的第一次采样
+// 补上结束后的方差，两者之差说明这次迁移是否让负载更均衡
+
+// DefaultShardStatsHistorySize 默认保留的采样数，每分钟采样时约为一天
+const DefaultShardStatsHistorySize = 1440
+
+// maxRebalanceEvents 保留的重平衡事件数
+const maxRebalanceEvents = 256
+
+// ShardStatsSample 某一时刻的分片负载
+type ShardStatsSample struct {
+	Time           time.Time          `json:"time"`
+	TotalTimelines int                `json:"total_timelines"`
+	TotalSize      int64              `json:"total_size"`
+	AverageLoad    float64            `json:"average_load"`
+	LoadVariance   float64            `json:"load_variance"`
+	StoreLoads     map[string]float64 `json:"store_loads"` // 各Store的负载因子
+}
+
+// RebalanceEvent 自动重平衡开始的一次迁移及其效果
+type RebalanceEvent struct {
+	MigrationID    string          `json:"migration_id"`
+	TimelineKey    string          `json:"timeline_key"`
+	FromStore      string          `json:"from_store"`
+	ToStore        string          `json:"to_store"`
+	ExpectedGain   float64         `json:"expected_gain"`
+	StartedAt      time.Time       `json:"started_at"`
+	VarianceBefore float64         `json:"variance_before"`
+	Status         MigrationStatus `json:"status"`
+	FinishedAt     *time.Time      `json:"finished_at,omitempty"`
+	VarianceAfter  *float64        `json:"variance_after,omitempty"` // 只有迁移完成时记录
+	Improvement    float64         `json:"improvement"`              // 方差减少量，负数表示更不均衡
+}
+
+// RebalanceReport 重平衡效果报告
+type RebalanceReport struct {
+	Events             []*RebalanceEvent `json:"events"`
+	InProgress         int               `json:"in_progress"`
+	Completed          int               `json:"completed"`
+	Failed             int               `json:"failed"`   // 失败或取消
+	Improved           int               `json:"improved"` // 完成后方差减少的次数
+	AverageImprovement float64           `json:"average_improvement"`
+	CurrentVariance    *float64          `json:"current_variance,omitempty"` // 最近一次采样的方差
+}
+
+// shardStatsHistory 采样的环形缓冲
+type shardStatsHistory struct {
+	samples []*ShardStatsSample
+	next    int
+	full    bool
+}
+
+func newShardStatsHistory(size int) *shardStatsHistory {
+	if size <= 0 {
+		size = DefaultShardStatsHistorySize
+	}
+	return &shardStatsHistory{samples: make([]*ShardStatsSample, size)}
+}
+
+func (h *shardStatsHistory) add(sample *ShardStatsSample) {
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// list 按时间顺序返回不早于since的采样
+func (h *shardStatsHistory) list(since time.Time) []*ShardStatsSample {
+	ordered := h.samples[:h.next]
+	if h.full {
+		ordered = append(append([]*ShardStatsSample(nil), h.samples[h.next:]...), h.samples[:h.next]...)
+	}
+	result := make([]*ShardStatsSample, 0, len(ordered))
+	for _, sample := range ordered {
+		if !sample.Time.Before(since) {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// latest 返回最近一次采样
+func (h *shardStatsHistory) latest() *ShardStatsSample {
+	if !h.full && h.next == 0 {
+		return nil
+	}
+	return h.samples[(h.next+len(h.samples)-1)%len(h.samples)]
+}
+
+// newShardStatsSample 从分片统计生成采样
+func newShardStatsSample(stats *ShardStats, at time.Time) *ShardStatsSample {
+	sample := &ShardStatsSample{
+		Time:           at,
+		TotalTimelines: stats.TotalTimelines,
+		TotalSize:      stats.TotalSize,
+		AverageLoad:    stats.AverageLoad,
+		LoadVariance:   stats.LoadVariance,
+		StoreLoads:     make(map[string]float64, len(stats.StoreStats)),
+	}
+	for storeID, store := range stats.StoreStats {
+		sample.StoreLoads[storeID] = store.LoadFactor
+	}
+	return sample
+}
+
+// SetStatsHistorySize 设置保留的采样数，已有采样被丢弃
+func (tsm *TimelineShardManager) SetStatsHistorySize(size int) {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	tsm.history = newShardStatsHistory(size)
+}
+
+// SampleShardStats 记录一次分片负载采样，并补全已结束迁移的重平衡事件
+func (tsm *TimelineShardManager) SampleShardStats(ctx context.Context) (*ShardStatsSample, error) {
+	stats, err := tsm.GetShardStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sample := newShardStatsSample(stats, tsm.now())
+
+	tsm.mu.RLock()
+	var pending []*RebalanceEvent
+	for _, event := range tsm.rebalanceEvents {
+		if event.FinishedAt == nil {
+			pending = append(pending, event)
+		}
+	}
+	tsm.mu.RUnlock()
+
+	finished := make(map[*RebalanceEvent]MigrationStatus)
+	for _, event := range pending {
+		task, err := tsm.migrationManager.GetMigrationStatus(ctx, event.MigrationID)
+		if err != nil {
+			// 任务已被清理，无法知道结果
+			finished[event] = MigrationCancelled
+			continue
+		}
+		switch task.Status {
+		case MigrationCompleted, MigrationFailed, MigrationCancelled:
+			finished[event] = task.Status
+		}
+	}
+
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+	for event, status := range finished {
+		finishedAt := sample.Time
+		event.Status = status
+		event.FinishedAt = &finishedAt
+		if status == MigrationCompleted {
+			after := sample.LoadVariance
+			event.VarianceAfter = &after
+			event.Improvement = event.VarianceBefore - after
+		}
+	}
+	tsm.history.add(sample)
+	return sample, nil
+}
+
+// recordRebalanceEventLocked 记录自动重平衡开始的迁移
+func (tsm *TimelineShardManager) recordRebalanceEventLocked(event *RebalanceEvent) {
+	tsm.rebalanceEvents = append(tsm.rebalanceEvents, event)
+	if len(tsm.rebalanceEvents) > maxRebalanceEvents {
+		tsm.rebalanceEvents = tsm.rebalanceEvents[len(tsm.rebalanceEvents)-maxRebalanceEvents:]
+	}
+}
+
+// GetStatsHistory 按时间顺序返回不早于since的采样
+func (tsm *TimelineShardManager) GetStatsHistory(since time.Time) []*ShardStatsSample {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+	return tsm.history.list(since)
+}
+
+// GetRebalanceReport 返回重平衡事件及其效果的汇总
+func (tsm *TimelineShardManager) GetRebalanceReport() *RebalanceReport {
+	tsm.mu.RLock()
+	defer tsm.mu.RUnlock()
+
+	report := &RebalanceReport{Events: make([]*RebalanceEvent, 0, len(tsm.rebalanceEvents))}
+	var totalImprovement float64
+	for _, event := range tsm.rebalanceEvents {
+		eventCopy := *event
+		report.Events = append(report.Events, &eventCopy)
+		switch {
+		case event.FinishedAt == nil:
+			report.InProgress++
+		case event.Status == MigrationCompleted:
+			report.Completed++
+			totalImprovement += event.Improvement
+			if event.Improvement > 0 {
+				report.Improved++
+			}
+		default:
+			report.Failed++
+		}
+	}
+	if report.Completed > 0 {
+		report.AverageImprovement = totalImprovement / float64(report.Completed)
+	}
+	if latest := tsm.history.latest(); latest != nil {
+		variance := latest.LoadVariance
+		report.CurrentVariance = &variance
+	}
+	return report
+}
+
+// StartStatsSampling 按interval周期性采样
+func (tsm *TimelineShardManager) StartStatsSampling(ctx context.Context, interval time.Duration) error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+
+	if tsm.samplingRunning {
+		return fmt.Errorf("stats sampling is already running")
+	}
+	tsm.samplingStop = make(chan struct{})
+	tsm.samplingRunning = true
+
+	go func(stopCh chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if _, err := tsm.SampleShardStats(ctx); err != nil {
+					log.Printf("failed to sample shard stats: %v", err)
+				}
+			}
+		}
+	}(tsm.samplingStop)
+	return nil
+}
+
+// StopStatsSampling 停止周期性采样
+func (tsm *TimelineShardManager) StopStatsSampling() error {
+	tsm.mu.Lock()
+	defer tsm.mu.Unlock()
+
+	if !tsm.samplingRunning {
+		return fmt.Errorf("stats sampling is not running")
+	}
+	close(tsm.samplingStop)
+	tsm.samplingRunning = false
+	return nil
+}
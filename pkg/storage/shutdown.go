@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Store停机流程
+// 收到SIGTERM后按顺序执行：
+//  1. 从注册中心注销，其他节点不再把新请求路由到本Store，之后继续服务DeregisterDelay让其他节点同步路由
+//  2. 停止接收RPC，等待进行中的请求完成
+//  3. 排空迁移：等待进行中的迁移完成，到期仍未完成的迁移中断并交接给下次启动续传
+//  4. 停止后台任务
+//  5. 释放本Store授予的锁，排队中的请求以ErrLockManagerClosed失败
+//  6. 写入未写满的块，保存Timeline元数据与消费检查点并刷盘
+//  7. 关闭Store及其余组件
+// 步骤2、3共用DrainTimeout期限，此后的步骤不受期限限制，保证数据落盘；某一步失败不影响后续步骤。
+
+// DefaultShutdownDrainTimeout 默认排空期限
+const DefaultShutdownDrainTimeout = 15 * time.Second
+
+// ShutdownConfig 停机配置
+type ShutdownConfig struct {
+	DrainTimeout    time.Duration // 停止接收RPC与排空迁移的总期限，为0时使用DefaultShutdownDrainTimeout
+	DeregisterDelay time.Duration // 注销后继续服务的时间，为0时不等待
+}
+
+// ShutdownHook 停机流程中的一个附加步骤
+type ShutdownHook struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// ShutdownDependencies 停机时需要协调的组件，未设置的组件跳过对应步骤
+type ShutdownDependencies struct {
+	Discovery *StoreDiscoveryClient
+	// 对外服务，如RPC与管理接口，按顺序停止，ctx在排空期限到达时结束
+	Servers    []ShutdownHook
+	Migrations *TimelineMigrationManager
+	// 迁移排空后停止的后台任务，如路由同步、复制与事务协调
+	Background  []ShutdownHook
+	LockManager DistributedLockManager
+	Store       *Store
+	// Store关闭后关闭的组件，如事件总线、全局索引与注册中心
+	Closers []ShutdownHook
+}
+
+// ShutdownStep 一个停机步骤的执行结果
+type ShutdownStep struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+// ShutdownReport 停机结果
+type ShutdownReport struct {
+	Steps         []ShutdownStep
+	HandedOff     []string // 被中断并交接给下次启动的迁移任务
+	ReleasedLocks int
+	FlushedBlocks int
+	Duration      time.Duration
+}
+
+// ShutdownManager 按固定顺序协调Store停机，只执行一次
+type ShutdownManager struct {
+	storeID string
+	config  ShutdownConfig
+	deps    ShutdownDependencies
+
+	once   sync.Once
+	report *ShutdownReport
+	err    error
+}
+
+// NewShutdownManager 创建停机管理器
+func NewShutdownManager(storeID string, config ShutdownConfig, deps ShutdownDependencies) *ShutdownManager {
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = DefaultShutdownDrainTimeout
+	}
+	return &ShutdownManager{storeID: storeID, config: config, deps: deps}
+}
+
+// Shutdown 执行停机流程，返回各步骤的结果与失败步骤的错误
+// ctx结束时排空提前结束，落盘与关闭仍会执行；重复调用返回第一次的结果
+func (m *ShutdownManager) Shutdown(ctx context.Context) (*ShutdownReport, error) {
+	m.once.Do(func() {
+		m.report, m.err = m.run(ctx)
+	})
+	return m.report, m.err
+}
+
+func (m *ShutdownManager) run(ctx context.Context) (*ShutdownReport, error) {
+	start := time.Now()
+	report := &ShutdownReport{}
+	var errs []error
+	step := func(name string, fn func() error) {
+		stepStart := time.Now()
+		err := fn()
+		report.Steps = append(report.Steps, ShutdownStep{Name: name, Duration: time.Since(stepStart), Err: err})
+		if err != nil {
+			log.Printf("store %s: shutdown %s: %v", m.storeID, name, err)
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	deps := m.deps
+
+	if deps.Discovery != nil {
+		step("deregister", func() error {
+			registered := deps.Discovery.isRegistered
+			if err := deps.Discovery.Stop(); err != nil {
+				return err
+			}
+			// 启动失败时尚未注册，无需等待
+			if registered && m.config.DeregisterDelay > 0 {
+				timer := time.NewTimer(m.config.DeregisterDelay)
+				defer timer.Stop()
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+				}
+			}
+			return nil
+		})
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, m.config.DrainTimeout)
+	defer cancel()
+	for _, server := range deps.Servers {
+		step(server.Name, func() error {
+			return server.Run(drainCtx)
+		})
+	}
+	if deps.Migrations != nil {
+		step("drain migrations", func() error {
+			handedOff, err := deps.Migrations.Drain(drainCtx)
+			report.HandedOff = handedOff
+			if len(handedOff) > 0 {
+				log.Printf("store %s: handed off %d migrations to the next start: %v", m.storeID, len(handedOff), handedOff)
+			}
+			return err
+		})
+	}
+
+	// 之后的步骤不受排空期限和ctx限制
+	finishCtx := context.WithoutCancel(ctx)
+	for _, hook := range deps.Background {
+		step(hook.Name, func() error {
+			return hook.Run(finishCtx)
+		})
+	}
+	if releaser, ok := deps.LockManager.(interface{ ReleaseAll() int }); ok {
+		step("release locks", func() error {
+			report.ReleasedLocks = releaser.ReleaseAll()
+			return nil
+		})
+	}
+	if deps.Store != nil {
+		step("flush open blocks", func() error {
+			flushed, err := deps.Store.FlushOpenBlocks()
+			report.FlushedBlocks = flushed
+			return err
+		})
+		step("flush store", deps.Store.Flush)
+		step("close store", deps.Store.Close)
+	}
+	for _, hook := range deps.Closers {
+		step(hook.Name, func() error {
+			return hook.Run(finishCtx)
+		})
+	}
+
+	report.Duration = time.Since(start)
+	return report, errors.Join(errs...)
+}
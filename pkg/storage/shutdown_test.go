@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestShutdownHandsOffMigrationsAndReleasesLocks(t *testing.T) {
+	ctx := context.Background()
+	dataDir := t.TempDir()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: dataDir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	timelineKey := "conv_shutdown"
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage(timelineKey, 1, []byte{byte(i)}, nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	globalIndex := NewInMemoryGlobalIndex()
+	globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: timelineKey, StoreID: store.StoreID, BlockID: "b1"})
+
+	// 没有远程访问时迁移步骤一直失败重试，直到被中断
+	locks := NewInMemoryDistributedLockManager(store.StoreID)
+	defer locks.Close()
+	migrations := NewTimelineMigrationManager(store, globalIndex, nil, nil, locks, store.StoreID)
+	migrations.SetStepRetry(100, time.Hour)
+	task, err := migrations.StartMigration(ctx, timelineKey, "store_remote")
+	if err != nil {
+		t.Fatalf("Failed to start migration: %v", err)
+	}
+	if _, err := locks.AcquireLock(ctx, "txn:other", time.Minute); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	var order []string
+	hook := func(name string) ShutdownHook {
+		return ShutdownHook{Name: name, Run: func(ctx context.Context) error {
+			order = append(order, name)
+			return nil
+		}}
+	}
+	manager := NewShutdownManager(store.StoreID, ShutdownConfig{DrainTimeout: 100 * time.Millisecond}, ShutdownDependencies{
+		Servers:     []ShutdownHook{hook("stop rpc server")},
+		Migrations:  migrations,
+		Background:  []ShutdownHook{hook("stop replication")},
+		LockManager: locks,
+		Store:       store,
+		Closers:     []ShutdownHook{hook("close registry")},
+	})
+	report, err := manager.Shutdown(ctx)
+	if err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(order, []string{"stop rpc server", "stop replication", "close registry"}) {
+		t.Errorf("Unexpected hook order: %v", order)
+	}
+	if !reflect.DeepEqual(report.HandedOff, []string{task.ID}) {
+		t.Errorf("Expected migration %s to be handed off, got %v", task.ID, report.HandedOff)
+	}
+	if report.ReleasedLocks != 1 {
+		t.Errorf("Expected 1 released lock, got %d", report.ReleasedLocks)
+	}
+	if report.FlushedBlocks != 1 {
+		t.Errorf("Expected the open block to be flushed, got %d", report.FlushedBlocks)
+	}
+	if _, err := migrations.StartMigration(ctx, "conv_other", "store_remote"); !errors.Is(err, ErrMigrationDraining) {
+		t.Errorf("Expected new migrations to be refused, got %v", err)
+	}
+	if _, err := locks.AcquireLock(ctx, "txn:late", time.Minute); !errors.Is(err, ErrLockManagerClosed) {
+		t.Errorf("Expected acquiring after shutdown to fail, got %v", err)
+	}
+	if again, _ := manager.Shutdown(ctx); again != report {
+		t.Error("Expected a repeated shutdown to return the first report")
+	}
+
+	// 重启后消息仍在，交接的迁移从检查点续传
+	reopened, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: dataDir})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	messages, err := reopened.GetConvMessages(timelineKey, 10, 0)
+	if err != nil || len(messages) != 3 {
+		t.Fatalf("Expected 3 messages after restart, got %d: %v", len(messages), err)
+	}
+
+	restartLocks := NewInMemoryDistributedLockManager(reopened.StoreID)
+	defer restartLocks.Close()
+	restarted := NewTimelineMigrationManager(reopened, globalIndex, nil, nil, restartLocks, reopened.StoreID)
+	restarted.SetStepRetry(100, time.Hour)
+	status, err := restarted.GetMigrationStatus(ctx, task.ID)
+	if err != nil || status.Status != MigrationFailed || status.Error != "migration interrupted by shutdown" {
+		t.Fatalf("Expected the handed off migration to be loaded, got %+v %v", status, err)
+	}
+	resumed := restarted.ResumeHandedOff(ctx)
+	if len(resumed) != 1 || resumed[0].ID != task.ID {
+		t.Fatalf("Expected migration %s to be resumed, got %v", task.ID, resumed)
+	}
+
+	drainCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if handedOff, err := restarted.Drain(drainCtx); err != nil || len(handedOff) != 1 {
+		t.Errorf("Expected the resumed migration to be handed off again, got %v %v", handedOff, err)
+	}
+}
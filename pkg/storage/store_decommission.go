@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Store下线流程
+// DecommissionStore把Store标记为draining并从路由移除，按分片推荐把其上的Timeline迁到其他Store，
+// 同时进行的迁移数不超过分片策略的MaxConcurrentMigrations。全部迁移完成且全局索引中不再有该Store的Timeline后，
+// 把Store标记为decommissioned并从注册中心注销。有迁移失败时Store保持draining，重新调用会只迁移剩下的Timeline
+
+// decommissionPollInterval 查询迁移状态的间隔
+var decommissionPollInterval = time.Second
+
+// DecommissionPhase 下线所处的阶段
+type DecommissionPhase string
+
+const (
+	DecommissionDraining      DecommissionPhase = "draining"
+	DecommissionMigrating     DecommissionPhase = "migrating"
+	DecommissionDeregistering DecommissionPhase = "deregistering"
+	DecommissionCompleted     DecommissionPhase = "completed"
+	DecommissionFailed        DecommissionPhase = "failed"
+)
+
+// DecommissionProgress 下线进度
+type DecommissionProgress struct {
+	StoreID   string            `json:"store_id"`
+	Phase     DecommissionPhase `json:"phase"`
+	Total     int               `json:"total"`            // 需要迁移的Timeline数
+	Completed int               `json:"completed"`        // 已迁移完成的Timeline数
+	Running   int               `json:"running"`          // 进行中的迁移数
+	Failed    map[string]string `json:"failed,omitempty"` // Timeline -> 错误
+	Error     string            `json:"error,omitempty"`
+	StartedAt time.Time         `json:"started_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// SetMigrationManager 设置迁移管理器，下线Store时用它迁移Timeline
+func (dsm *DistributedStorageManager) SetMigrationManager(migrationManager MigrationManager) {
+	dsm.migrationManager = migrationManager
+}
+
+// SetLeaderElector 设置协调者选举，下线编排只在领导者上执行
+func (dsm *DistributedStorageManager) SetLeaderElector(elector LeaderElector) {
+	dsm.leaderElector = elector
+}
+
+// GetDecommissionProgress 获取Store最近一次下线的进度
+func (dsm *DistributedStorageManager) GetDecommissionProgress(storeID string) (*DecommissionProgress, bool) {
+	dsm.decommissionMu.Lock()
+	defer dsm.decommissionMu.Unlock()
+	progress, exists := dsm.decommissions[storeID]
+	if !exists {
+		return nil, false
+	}
+	return progress.snapshot(), true
+}
+
+// snapshot 复制进度，调用方持有decommissionMu
+func (p *DecommissionProgress) snapshot() *DecommissionProgress {
+	progressCopy := *p
+	progressCopy.Failed = make(map[string]string, len(p.Failed))
+	for key, reason := range p.Failed {
+		progressCopy.Failed[key] = reason
+	}
+	return &progressCopy
+}
+
+// updateDecommission 在锁内修改进度
+func (dsm *DistributedStorageManager) updateDecommission(progress *DecommissionProgress, update func(p *DecommissionProgress)) {
+	dsm.decommissionMu.Lock()
+	defer dsm.decommissionMu.Unlock()
+	update(progress)
+	progress.UpdatedAt = time.Now()
+}
+
+// DecommissionStore 排空并下线Store，等待迁移全部结束后返回最终进度
+func (dsm *DistributedStorageManager) DecommissionStore(ctx context.Context, storeID string) (*DecommissionProgress, error) {
+	if dsm.migrationManager == nil {
+		return nil, fmt.Errorf("decommission requires a migration manager")
+	}
+	if dsm.leaderElector != nil {
+		termCtx, err := dsm.leaderElector.LeaderContext()
+		if err != nil {
+			return nil, notLeaderError(ctx, dsm.leaderElector)
+		}
+		// 失去领导者身份时停止编排，由新的领导者重新发起
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		defer context.AfterFunc(termCtx, cancel)()
+	}
+	info, err := dsm.storeRegistry.GetStore(ctx, storeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get store %s: %w", storeID, err)
+	}
+
+	progress, err := dsm.beginDecommission(storeID)
+	if err != nil {
+		return nil, err
+	}
+	progress, err = dsm.runDecommission(ctx, info, progress)
+	if err != nil {
+		dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+			p.Phase = DecommissionFailed
+			p.Error = err.Error()
+		})
+		log.Printf("decommission of store %s failed: %v", storeID, err)
+	}
+	dsm.decommissionMu.Lock()
+	defer dsm.decommissionMu.Unlock()
+	return progress.snapshot(), err
+}
+
+// beginDecommission 登记新的下线进度，同一个Store不能同时下线两次
+func (dsm *DistributedStorageManager) beginDecommission(storeID string) (*DecommissionProgress, error) {
+	dsm.decommissionMu.Lock()
+	defer dsm.decommissionMu.Unlock()
+	if existing, exists := dsm.decommissions[storeID]; exists {
+		switch existing.Phase {
+		case DecommissionCompleted, DecommissionFailed:
+		default:
+			return nil, fmt.Errorf("store %s is already being decommissioned", storeID)
+		}
+	}
+	if dsm.decommissions == nil {
+		dsm.decommissions = make(map[string]*DecommissionProgress)
+	}
+	now := time.Now()
+	progress := &DecommissionProgress{
+		StoreID:   storeID,
+		Phase:     DecommissionDraining,
+		Failed:    make(map[string]string),
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+	dsm.decommissions[storeID] = progress
+	return progress, nil
+}
+
+func (dsm *DistributedStorageManager) runDecommission(ctx context.Context, info *StoreInfo, progress *DecommissionProgress) (*DecommissionProgress, error) {
+	storeID := info.ID
+	if info.Status != StoreStatusDraining {
+		if err := dsm.storeRegistry.UpdateStatus(ctx, storeID, StoreStatusDraining); err != nil {
+			return progress, err
+		}
+	}
+	// 路由器中没有该Store时返回错误，忽略
+	dsm.routerManager.RemoveStore(storeID)
+
+	timelines, err := dsm.globalIndex.ListTimelinesByStore(ctx, storeID)
+	if err != nil {
+		return progress, fmt.Errorf("failed to list timelines: %w", err)
+	}
+	sort.Strings(timelines)
+	dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+		p.Phase = DecommissionMigrating
+		p.Total = len(timelines)
+	})
+	log.Printf("decommissioning store %s, %d timelines to migrate", storeID, len(timelines))
+
+	if err := dsm.migrateOffStore(ctx, storeID, timelines, progress); err != nil {
+		return progress, err
+	}
+	dsm.decommissionMu.Lock()
+	failed := len(progress.Failed)
+	dsm.decommissionMu.Unlock()
+	if failed > 0 {
+		return progress, fmt.Errorf("%d of %d timelines failed to migrate off store %s, it stays draining", failed, len(timelines), storeID)
+	}
+
+	remaining, err := dsm.globalIndex.ListTimelinesByStore(ctx, storeID)
+	if err != nil {
+		return progress, fmt.Errorf("failed to list timelines: %w", err)
+	}
+	if len(remaining) > 0 {
+		return progress, fmt.Errorf("store %s still holds %d timelines, it stays draining", storeID, len(remaining))
+	}
+
+	dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+		p.Phase = DecommissionDeregistering
+	})
+	if err := dsm.storeRegistry.UpdateStatus(ctx, storeID, StoreStatusDecommissioned); err != nil {
+		return progress, err
+	}
+	if err := dsm.storeRegistry.Unregister(ctx, storeID); err != nil {
+		return progress, fmt.Errorf("failed to unregister store %s: %w", storeID, err)
+	}
+	dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+		p.Phase = DecommissionCompleted
+	})
+	log.Printf("store %s decommissioned, %d timelines migrated", storeID, len(timelines))
+	return progress, nil
+}
+
+// migrateOffStore 迁移timelines并等待全部结束，失败的Timeline记录在进度中
+func (dsm *DistributedStorageManager) migrateOffStore(ctx context.Context, storeID string, timelines []string, progress *DecommissionProgress) error {
+	limit := 0
+	if dsm.shardManager != nil {
+		limit = dsm.shardManager.GetShardPolicy().MaxConcurrentMigrations
+	}
+
+	queue := timelines
+	running := make(map[string]string) // 任务ID -> Timeline
+	ticker := time.NewTicker(decommissionPollInterval)
+	defer ticker.Stop()
+
+	for {
+		for len(queue) > 0 && (limit <= 0 || len(running) < limit) {
+			timelineKey := queue[0]
+			queue = queue[1:]
+			task, err := dsm.startDecommissionMigration(ctx, timelineKey, storeID)
+			if err != nil {
+				dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+					p.Failed[timelineKey] = err.Error()
+				})
+				continue
+			}
+			running[task.ID] = timelineKey
+		}
+		dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+			p.Running = len(running)
+		})
+		if len(running) == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		finished := 0
+		for taskID, timelineKey := range running {
+			task, err := dsm.migrationManager.GetMigrationStatus(ctx, taskID)
+			var reason string
+			switch {
+			case err != nil:
+				reason = err.Error()
+			case task.Status == MigrationCompleted:
+			case task.Status == MigrationFailed || task.Status == MigrationCancelled:
+				reason = fmt.Sprintf("migration %s %s: %s", taskID, task.Status, task.Error)
+			default:
+				continue
+			}
+			delete(running, taskID)
+			finished++
+			dsm.updateDecommission(progress, func(p *DecommissionProgress) {
+				if reason != "" {
+					p.Failed[timelineKey] = reason
+				} else {
+					p.Completed++
+				}
+			})
+		}
+		if finished > 0 {
+			dsm.decommissionMu.Lock()
+			log.Printf("decommissioning store %s: %d/%d migrated, %d running, %d failed",
+				storeID, progress.Completed, progress.Total, len(running), len(progress.Failed))
+			dsm.decommissionMu.Unlock()
+		}
+	}
+}
+
+// startDecommissionMigration 按放置推荐选择另一个Store并开始迁移
+func (dsm *DistributedStorageManager) startDecommissionMigration(ctx context.Context, timelineKey, storeID string) (*MigrationTask, error) {
+	var size int64
+	if location, err := dsm.globalIndex.GetTimelineLocation(ctx, timelineKey); err == nil {
+		size = location.TotalSize
+	}
+	candidates, err := dsm.placementCandidates(ctx, timelineKey, size)
+	if err != nil {
+		return nil, err
+	}
+	for _, target := range candidates {
+		if target != storeID {
+			return dsm.migrationManager.StartMigration(ctx, timelineKey, target)
+		}
+	}
+	return nil, fmt.Errorf("no other store available")
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// indexMigrationManager 开始迁移时直接在全局索引中移动Timeline，fail中的Timeline迁移失败
+type indexMigrationManager struct {
+	recordingMigrationManager
+	globalIndex *InMemoryGlobalIndex
+	mu          sync.Mutex
+	fail        map[string]bool
+	maxRunning  int
+}
+
+func (m *indexMigrationManager) StartMigration(ctx context.Context, timelineKey, targetStoreID string) (*MigrationTask, error) {
+	location, err := m.globalIndex.GetTimelineLocation(ctx, timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	task, _ := m.recordingMigrationManager.StartMigration(ctx, timelineKey, targetStoreID)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	running := 0
+	m.recordingMigrationManager.mu.Lock()
+	for _, other := range m.tasks {
+		if other.Status == MigrationPending {
+			running++
+		}
+	}
+	m.recordingMigrationManager.mu.Unlock()
+	if running > m.maxRunning {
+		m.maxRunning = running
+	}
+	if m.fail[timelineKey] {
+		return task, nil
+	}
+	return task, m.globalIndex.MigrateTimeline(ctx, timelineKey, location.Blocks[0].StoreID, targetStoreID)
+}
+
+// finish 结束所有进行中的迁移
+func (m *indexMigrationManager) finish() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.recordingMigrationManager.mu.Lock()
+	defer m.recordingMigrationManager.mu.Unlock()
+	for _, task := range m.tasks {
+		if task.Status != MigrationPending {
+			continue
+		}
+		if m.fail[task.TimelineKey] {
+			task.Status = MigrationFailed
+			task.Error = "target store refused the import"
+		} else {
+			task.Status = MigrationCompleted
+		}
+	}
+}
+
+func (m *indexMigrationManager) GetMigrationStatus(ctx context.Context, taskID string) (*MigrationTask, error) {
+	m.finish()
+	task, err := m.recordingMigrationManager.GetMigrationStatus(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	m.recordingMigrationManager.mu.Lock()
+	defer m.recordingMigrationManager.mu.Unlock()
+	taskCopy := *task
+	return &taskCopy, nil
+}
+
+func TestDecommissionStore(t *testing.T) {
+	ctx := context.Background()
+	defer func(interval time.Duration) { decommissionPollInterval = interval }(decommissionPollInterval)
+	decommissionPollInterval = time.Millisecond
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	routerManager := NewRouterManager()
+	router := NewConsistentHashRouter(1, 10, 0.8)
+	routerManager.RegisterRouter("hash", router)
+	for _, id := range []string{"store_a", "store_b", "store_c"} {
+		registry.Register(ctx, &StoreInfo{ID: id})
+		router.AddStore(&StoreInfo{ID: id, Status: StoreStatusActive})
+	}
+	globalIndex := NewInMemoryGlobalIndex()
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("conv_%d", i)
+		globalIndex.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: key, StoreID: "store_a", BlockID: "block_" + key, Size: 100})
+	}
+	migrations := &indexMigrationManager{globalIndex: globalIndex, fail: map[string]bool{"conv_3": true}}
+	policy := DefaultShardPolicy()
+	policy.MaxConcurrentMigrations = 2
+	shards := NewTimelineShardManager(globalIndex, registry, routerManager, migrations)
+	if err := shards.UpdateShardPolicy(policy); err != nil {
+		t.Fatalf("Failed to update policy: %v", err)
+	}
+
+	pool := NewStoreRPCClientPool(time.Second)
+	defer pool.Close()
+	dsm := NewDistributedStorageManager(nil, globalIndex, routerManager, registry, pool, "store_b")
+	defer dsm.Close()
+	dsm.SetShardManager(shards)
+
+	if _, err := dsm.DecommissionStore(ctx, "store_a"); err == nil {
+		t.Fatalf("Expected decommission without a migration manager to fail")
+	}
+	dsm.SetMigrationManager(migrations)
+
+	// 有Timeline迁移失败时Store保持draining
+	progress, err := dsm.DecommissionStore(ctx, "store_a")
+	if err == nil {
+		t.Fatalf("Expected the failed migration to stop the decommission")
+	}
+	if progress.Phase != DecommissionFailed || progress.Total != 5 || progress.Completed != 4 || progress.Failed["conv_3"] == "" {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	if migrations.maxRunning > policy.MaxConcurrentMigrations {
+		t.Errorf("Expected at most %d concurrent migrations, got %d", policy.MaxConcurrentMigrations, migrations.maxRunning)
+	}
+	for _, task := range migrations.tasks {
+		if task.TargetStore == "store_a" {
+			t.Errorf("Expected no migration to the decommissioned store, got %+v", task)
+		}
+	}
+	info, err := registry.GetStore(ctx, "store_a")
+	if err != nil || info.Status != StoreStatusDraining {
+		t.Fatalf("Expected store_a to stay draining, got %+v %v", info, err)
+	}
+	if target, _ := router.RouteTimeline("conv_new"); target == "store_a" {
+		t.Errorf("Expected store_a to be removed from routing")
+	}
+
+	// 重新调用只迁移剩下的Timeline，完成后注销
+	delete(migrations.fail, "conv_3")
+	progress, err = dsm.DecommissionStore(ctx, "store_a")
+	if err != nil {
+		t.Fatalf("Failed to decommission: %v", err)
+	}
+	if progress.Phase != DecommissionCompleted || progress.Total != 1 || progress.Completed != 1 || len(progress.Failed) != 0 {
+		t.Fatalf("Unexpected progress %+v", progress)
+	}
+	if _, err := registry.GetStore(ctx, "store_a"); err == nil {
+		t.Errorf("Expected store_a to be unregistered")
+	}
+	if timelines, _ := globalIndex.ListTimelinesByStore(ctx, "store_a"); len(timelines) != 0 {
+		t.Errorf("Expected no timelines left on store_a, got %v", timelines)
+	}
+	if latest, ok := dsm.GetDecommissionProgress("store_a"); !ok || latest.Phase != DecommissionCompleted {
+		t.Errorf("Expected the completed progress to be kept, got %+v", latest)
+	}
+
+	// 已注销的Store不能再次下线
+	if _, err := dsm.DecommissionStore(ctx, "store_a"); err == nil {
+		t.Errorf("Expected an unknown store to be rejected, got %v", err)
+	}
+}
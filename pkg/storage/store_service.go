@@ -0,0 +1,506 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"imy/pkg/moderation"
+)
+
+// LocalStoreService 基于本地Store的StoreRPCService实现，HTTP与gRPC服务端共用
+type LocalStoreService struct {
+	store *Store
+
+	mu         sync.RWMutex
+	txnHandler TransactionParticipantHandler
+}
+
+// NewLocalStoreService 创建本地Store RPC服务
+func NewLocalStoreService(store *Store) *LocalStoreService {
+	return &LocalStoreService{store: store}
+}
+
+// Timeline操作
+
+// GetTimeline 获取Timeline，返回的是快照，不存在时不会创建
+func (s *LocalStoreService) GetTimeline(ctx context.Context, req *GetTimelineRequest) (*GetTimelineResponse, error) {
+	timeline, exists := s.store.FindTimeline(req.TimelineKey)
+	if !exists {
+		return &GetTimelineResponse{Exists: false}, nil
+	}
+
+	return &GetTimelineResponse{
+		Timeline: timeline.Snapshot(),
+		Exists:   true,
+	}, nil
+}
+
+// CreateTimeline 创建Timeline
+func (s *LocalStoreService) CreateTimeline(ctx context.Context, req *CreateTimelineRequest) (*CreateTimelineResponse, error) {
+	// 检查Timeline是否已存在
+	if timeline, exists := s.store.FindTimeline(req.TimelineKey); exists {
+		return &CreateTimelineResponse{
+			Timeline: timeline.Snapshot(),
+			Created:  false,
+		}, nil
+	}
+
+	// 创建新Timeline，类型由元数据中的type决定，默认为会话Timeline
+	var timeline *Timeline
+	if timelineType, _ := req.Metadata["type"].(string); timelineType == "user" {
+		timeline = s.store.GetOrCreateUserTimeline(req.TimelineKey)
+	} else {
+		timeline = s.store.GetOrCreateConvTimeline(req.TimelineKey)
+	}
+
+	// TODO: 设置元数据 - Timeline结构体需要添加Metadata字段
+	// if req.Metadata != nil {
+	//     for k, v := range req.Metadata {
+	//         timeline.Metadata[k] = v
+	//     }
+	//     // 保存元数据
+	//     err = s.store.saveTimelineMetadata(timeline)
+	//     if err != nil {
+	//         return nil, fmt.Errorf("failed to save timeline metadata: %w", err)
+	//     }
+	// }
+
+	return &CreateTimelineResponse{
+		Timeline: timeline.Snapshot(),
+		Created:  true,
+	}, nil
+}
+
+// DeleteTimeline 删除Timeline
+func (s *LocalStoreService) DeleteTimeline(ctx context.Context, req *DeleteTimelineRequest) (*DeleteTimelineResponse, error) {
+	// 按Timeline的实际类型删除
+	timeline, exists := s.store.FindTimeline(req.TimelineKey)
+	if !exists {
+		return &DeleteTimelineResponse{Deleted: false}, nil
+	}
+
+	deleted, err := s.store.DeleteTimeline(timeline.Type, req.TimelineKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete timeline: %w", err)
+	}
+
+	return &DeleteTimelineResponse{Deleted: deleted}, nil
+}
+
+// MigrateTimeline 迁移Timeline
+func (s *LocalStoreService) MigrateTimeline(ctx context.Context, req *MigrateTimelineRequest) (*MigrateTimelineResponse, error) {
+	// TODO: 实现Timeline迁移逻辑
+	// 这里需要与目标Store协调，传输Timeline数据
+
+	return &MigrateTimelineResponse{
+		Success:        false,
+		MigratedBlocks: []string{},
+	}, fmt.Errorf("timeline migration not implemented yet")
+}
+
+// 消息操作
+
+// AddMessage 添加消息
+func (s *LocalStoreService) AddMessage(ctx context.Context, req *AddMessageRequest) (*AddMessageResponse, error) {
+	// 获取或创建Timeline
+	timeline := s.store.GetOrCreateConvTimeline(req.TimelineKey)
+
+	if req.Message == nil {
+		return nil, NewRPCError(ErrCodeInvalidMessage, "message is required")
+	}
+
+	// 添加消息，SeqID由会话Timeline分配；携带HLC的是其他Store复制来的消息，保留原HLC。
+	// 带ClientMsgID的重试返回已有消息
+	var msg *Message
+	var duplicate bool
+	var err error
+	if req.Message.HLC > 0 {
+		msg, duplicate, err = s.store.ReplicateMessage(req.TimelineKey, req.Message, req.UserIDs)
+	} else {
+		msg, duplicate, err = s.store.SubmitMessage(req.TimelineKey, req.Message, req.UserIDs)
+	}
+	if errors.Is(err, ErrStorageFull) {
+		return nil, NewRPCError(ErrCodeStorageFull, err.Error())
+	}
+	if errors.Is(err, moderation.ErrRejected) {
+		return nil, NewRPCError(ErrCodeMessageRejected, err.Error())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add message: %w", err)
+	}
+
+	// 返回响应 - 这里简化处理，实际应该返回具体的块ID和偏移量
+	resp := &AddMessageResponse{
+		MessageID: fmt.Sprintf("%d", msg.SeqID),
+		SeqID:     msg.SeqID,
+		HLC:       msg.HLC,
+		Duplicate: duplicate,
+	}
+	if duplicate {
+		return resp, nil
+	}
+	timeline.mu.RLock()
+	if block := timeline.CurrentBlock; block != nil {
+		block.mu.RLock()
+		resp.BlockID = block.BlockID
+		resp.Offset = int64(len(block.Messages))
+		block.mu.RUnlock()
+	}
+	timeline.mu.RUnlock()
+	return resp, nil
+}
+
+// GetMessages 获取消息
+func (s *LocalStoreService) GetMessages(ctx context.Context, req *GetMessagesRequest) (*GetMessagesResponse, error) {
+	if req.Offset < 0 {
+		return nil, NewRPCError(ErrCodeInvalidRequest, "offset must not be negative")
+	}
+
+	// 获取Timeline
+	timeline, exists := s.store.FindTimeline(req.TimelineKey)
+	if !exists {
+		return &GetMessagesResponse{
+			Messages: []*Message{},
+			Total:    0,
+			HasMore:  false,
+		}, nil
+	}
+
+	cursorMode := req.BeforeSeqID > 0 || req.AfterSeqID > 0
+	hlcMode := req.StartHLC > 0 || req.EndHLC > 0
+
+	// 按时间范围过滤消息，EndTime为0表示不限制结束时间；游标模式下同时按SeqID区间过滤，
+	// 设置HLC区间时再按HLC过滤
+	// 在只读视图上过滤，分页基于同一时刻的快照；AfterSeqID之前的未加载块不会读取
+	matched := make([]*Message, 0)
+	for msg, err := range timeline.readView().forward(s.store.readBlockMessages, req.AfterSeqID) {
+		if err != nil {
+			return nil, err
+		}
+		msgTime := msg.CreateTime.Unix()
+		if msgTime < req.StartTime || (req.EndTime > 0 && msgTime > req.EndTime) {
+			continue
+		}
+		if req.BeforeSeqID > 0 && msg.SeqID >= req.BeforeSeqID {
+			continue
+		}
+		if req.AfterSeqID > 0 && msg.SeqID <= req.AfterSeqID {
+			continue
+		}
+		if hlc := messageHLC(msg); hlc < req.StartHLC || (req.EndHLC > 0 && hlc > req.EndHLC) {
+			continue
+		}
+		matched = append(matched, msg)
+	}
+
+	if hlcMode {
+		SortMessagesByHLC(matched)
+	} else if cursorMode {
+		sort.Slice(matched, func(i, j int) bool {
+			return matched[i].SeqID < matched[j].SeqID
+		})
+	}
+
+	total := len(matched)
+	start, end := 0, total
+	switch {
+	case cursorMode && req.AfterSeqID == 0:
+		// 向前翻页：取游标之前最新的Limit条
+		if req.Limit > 0 && total > req.Limit {
+			start = total - req.Limit
+		}
+	case cursorMode:
+		// 向后翻页：取游标之后最早的Limit条
+		if req.Limit > 0 && total > req.Limit {
+			end = req.Limit
+		}
+	default:
+		start = req.Offset
+		if start > total {
+			start = total
+		}
+		if req.Limit > 0 && start+req.Limit < total {
+			end = start + req.Limit
+		}
+	}
+
+	resp := &GetMessagesResponse{
+		Messages: matched[start:end],
+		Total:    total,
+	}
+	if cursorMode && req.AfterSeqID == 0 {
+		resp.HasMore = start > 0
+		if resp.HasMore {
+			resp.NextCursor = matched[start].SeqID
+		}
+	} else {
+		resp.HasMore = end < total
+		if resp.HasMore && end > start {
+			resp.NextCursor = matched[end-1].SeqID
+		}
+	}
+
+	return resp, nil
+}
+
+// EditMessage 编辑消息，追加编辑记录
+func (s *LocalStoreService) EditMessage(ctx context.Context, req *EditMessageRequest) (*EditMessageResponse, error) {
+	msg, err := s.store.EditMessage(req.TimelineKey, req.SenderID, req.SeqID, req.Data, req.UserIDs)
+	if err != nil {
+		return nil, messageMutationError(err)
+	}
+	return &EditMessageResponse{Message: msg}, nil
+}
+
+// DeleteMessage 删除消息，追加删除墓碑
+func (s *LocalStoreService) DeleteMessage(ctx context.Context, req *DeleteMessageRequest) (*DeleteMessageResponse, error) {
+	msg, err := s.store.DeleteMessage(req.TimelineKey, req.SenderID, req.SeqID, req.UserIDs)
+	if err != nil {
+		return nil, messageMutationError(err)
+	}
+	return &DeleteMessageResponse{Message: msg}, nil
+}
+
+// messageMutationError 将消息修改错误转换为RPC错误
+func messageMutationError(err error) error {
+	switch {
+	case errors.Is(err, ErrMessageNotFound):
+		return NewRPCError(ErrCodeMessageNotFound, err.Error())
+	case errors.Is(err, ErrNotMessageAuthor):
+		return NewRPCError(ErrCodePermissionDenied, err.Error())
+	case errors.Is(err, ErrMessageDeleted), errors.Is(err, ErrMessageNotEditable):
+		return NewRPCError(ErrCodeInvalidMessage, err.Error())
+	case errors.Is(err, ErrStorageFull):
+		return NewRPCError(ErrCodeStorageFull, err.Error())
+	case errors.Is(err, moderation.ErrRejected):
+		return NewRPCError(ErrCodeMessageRejected, err.Error())
+	}
+	return fmt.Errorf("failed to mutate message: %w", err)
+}
+
+// 块操作
+
+// GetTimelineBlock 获取Timeline块
+func (s *LocalStoreService) GetTimelineBlock(ctx context.Context, req *GetTimelineBlockRequest) (*GetTimelineBlockResponse, error) {
+	// 从缓存中查找块
+	block, exists := s.store.GetTimelineBlock(req.BlockID)
+	if !exists {
+		return &GetTimelineBlockResponse{
+			Block:  nil,
+			Exists: false,
+		}, nil
+	}
+
+	return &GetTimelineBlockResponse{
+		Block:  block.Snapshot(),
+		Exists: true,
+	}, nil
+}
+
+// Store状态
+
+// GetStoreStats 获取Store统计
+func (s *LocalStoreService) GetStoreStats(ctx context.Context, req *GetStoreStatsRequest) (*GetStoreStatsResponse, error) {
+	timelines := s.store.ListTimelines()
+	capacity := s.store.Capacity()
+
+	response := &GetStoreStatsResponse{
+		StoreID:       s.store.StoreID,
+		TimelineCount: len(timelines),
+		BlockCount:    s.store.BlockCount(),
+		TotalSize:     capacity.UsedBytes,
+		Uptime:        0, // TODO: 添加Store创建时间字段来计算uptime
+		LastUpdate:    time.Now().Unix(),
+		MaxCapacity:   capacity.MaxCapacity,
+		BlockBytes:    capacity.BlockBytes,
+		WALBytes:      capacity.WALBytes,
+		TieredBlocks:  capacity.TieredBlocks,
+		TieredBytes:   capacity.TieredBytes,
+	}
+
+	if req.IncludeTimelines {
+		response.Timelines = make([]string, 0, len(timelines))
+		for _, timeline := range timelines {
+			response.Timelines = append(response.Timelines, timeline.ID)
+		}
+	}
+
+	return response, nil
+}
+
+// HealthCheck 健康检查，存在未恢复的损坏块时状态为degraded
+func (s *LocalStoreService) HealthCheck(ctx context.Context, req *HealthCheckRequest) (*HealthCheckResponse, error) {
+	return &HealthCheckResponse{
+		Pong:      "pong",
+		Status:    s.store.HealthStatus(),
+		Timestamp: time.Now().Unix(),
+	}, nil
+}
+
+// StreamTimelineBlocks 按顺序导出Timeline的块及其消息，fn返回错误时终止
+func (s *LocalStoreService) StreamTimelineBlocks(ctx context.Context, req *StreamTimelineBlocksRequest, fn func(*TimelineBlockData) error) error {
+	timeline, exists := s.store.FindTimeline(req.TimelineKey)
+	if !exists {
+		return NewRPCError(ErrCodeTimelineNotFound, req.TimelineKey)
+	}
+
+	timeline.mu.RLock()
+	blocks := append([]*TimelineBlock(nil), timeline.Blocks...)
+	timeline.mu.RUnlock()
+
+	// 断点续传时跳过AfterBlockID及之前的块
+	if req.AfterBlockID != "" {
+		found := false
+		for i, block := range blocks {
+			if block.BlockID == req.AfterBlockID {
+				blocks = blocks[i+1:]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return NewRPCError(ErrCodeBlockNotFound, req.AfterBlockID)
+		}
+	}
+
+	for _, block := range blocks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		snapshot, messages, err := s.store.blockSnapshot(block)
+		if err != nil {
+			return err
+		}
+		data := &TimelineBlockData{
+			TimelineKey:  timeline.ID,
+			TimelineType: timeline.Type,
+			Block:        snapshot,
+			Messages:     messages,
+		}
+
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// StreamMessages 按SeqID升序逐条读取区间内的消息，只在遍历到的块需要时才从段文件读取
+func (s *LocalStoreService) StreamMessages(ctx context.Context, req *StreamMessagesRequest, fn func(*Message) error) error {
+	timeline, exists := s.store.FindTimeline(req.TimelineKey)
+	if !exists {
+		return NewRPCError(ErrCodeTimelineNotFound, req.TimelineKey)
+	}
+
+	it := s.store.iterateTimeline(timeline, req.AfterSeqID, req.BeforeSeqID)
+	defer it.Close()
+	for it.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(it.Message()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
+
+// ImportTimelineBlocks 接收迁移来的块，fn通过send逐个提交块
+// 每个块收到后立即落盘，已存在且内容一致的块直接跳过，迁移中断后可从任意块续传
+func (s *LocalStoreService) ImportTimelineBlocks(ctx context.Context, fn func(send func(*TimelineBlockData) error) error) (*ImportTimelineBlocksResponse, error) {
+	resp := &ImportTimelineBlocksResponse{}
+	var timelineType string
+	err := fn(func(data *TimelineBlockData) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if resp.TimelineKey == "" {
+			resp.TimelineKey = data.TimelineKey
+			timelineType = data.TimelineType
+		} else if data.TimelineKey != resp.TimelineKey || data.TimelineType != timelineType {
+			return NewRPCError(ErrCodeInvalidRequest, "blocks must belong to the same timeline")
+		}
+
+		imported, err := s.store.ImportTimelineBlock(data.TimelineType, data.TimelineKey, data)
+		if errors.Is(err, ErrStorageFull) {
+			return NewRPCError(ErrCodeStorageFull, err.Error())
+		}
+		if err != nil {
+			return NewRPCError(ErrCodeMigrationFailed, err.Error())
+		}
+		if imported {
+			resp.ImportedBlocks++
+			resp.ImportedMessages += int64(len(data.Messages))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.TimelineKey == "" {
+		return nil, NewRPCError(ErrCodeInvalidRequest, "no blocks to import")
+	}
+	if timeline, exists := s.store.FindTimeline(resp.TimelineKey); exists {
+		timeline.mu.RLock()
+		resp.LastSeqID = timeline.LastSeqID
+		timeline.mu.RUnlock()
+	}
+	return resp, nil
+}
+
+// 事务参与者操作
+
+// SetTransactionHandler 设置执行远程协调者事务请求的参与者处理器，未设置时拒绝事务请求
+func (s *LocalStoreService) SetTransactionHandler(handler TransactionParticipantHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.txnHandler = handler
+}
+
+// PrepareTransaction 准备事务中本Store的操作
+func (s *LocalStoreService) PrepareTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return s.handleTransaction(ctx, req, TransactionParticipantHandler.Prepare)
+}
+
+// CommitTransaction 提交事务中本Store已准备的操作
+func (s *LocalStoreService) CommitTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return s.handleTransaction(ctx, req, TransactionParticipantHandler.Commit)
+}
+
+// AbortTransaction 回滚事务中本Store已准备的操作
+func (s *LocalStoreService) AbortTransaction(ctx context.Context, req *TransactionRequest) (*TransactionResponse, error) {
+	return s.handleTransaction(ctx, req, TransactionParticipantHandler.Abort)
+}
+
+// handleTransaction 调用参与者处理器，处理器返回的错误作为参与者拒绝的响应返回
+func (s *LocalStoreService) handleTransaction(ctx context.Context, req *TransactionRequest, call func(TransactionParticipantHandler, context.Context, string, *TransactionParticipant) error) (*TransactionResponse, error) {
+	s.mu.RLock()
+	handler := s.txnHandler
+	s.mu.RUnlock()
+
+	if handler == nil {
+		return &TransactionResponse{Code: ErrCodeMethodNotFound, Error: "transactions are not enabled on this store"}, nil
+	}
+	if req.TransactionID == "" || req.Participant == nil {
+		return &TransactionResponse{Code: ErrCodeInvalidRequest, Error: "transaction id and participant are required"}, nil
+	}
+
+	if err := call(handler, ctx, req.TransactionID, req.Participant); err != nil {
+		code := ErrCodeInternalError
+		switch {
+		case errors.Is(err, ErrTransactionConflict):
+			code = ErrCodeTransactionConflict
+		case errors.Is(err, ErrTransactionNotPrepared):
+			code = ErrCodeTransactionNotPrepared
+		}
+		return &TransactionResponse{Code: code, Error: err.Error()}, nil
+	}
+	return &TransactionResponse{Success: true}, nil
+}
@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"imy/pkg/errs"
+)
+
+// TestStoreRPCConcurrentAccess 同时进行本地写入与RPC读写，需配合 go test -race 运行
+func TestStoreRPCConcurrentAccess(t *testing.T) {
+	ctx := context.Background()
+	store, ts := newTestRemoteStore(t)
+
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+
+	const writers, rounds = 4, 30
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	report := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	}
+
+	// 本地写入会话与用户Timeline，块写满后落盘
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				convID := fmt.Sprintf("conv_%d", w)
+				if err := store.AddMessage(convID, uint32(w), []byte("local"), []string{fmt.Sprintf("user_%d", w)}); err != nil {
+					report(fmt.Errorf("local write: %w", err))
+					return
+				}
+			}
+		}(w)
+	}
+
+	// RPC写入并删除临时Timeline
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			key := fmt.Sprintf("conv_tmp_%d", i)
+			if _, err := client.AddMessage(ctx, &AddMessageRequest{TimelineKey: key, Message: &Message{SenderID: 9, Data: []byte("rpc")}}); err != nil {
+				report(fmt.Errorf("rpc add: %w", err))
+				return
+			}
+			if _, err := client.CreateTimeline(ctx, &CreateTimelineRequest{TimelineKey: fmt.Sprintf("user_tmp_%d", i), Metadata: map[string]interface{}{"type": "user"}}); err != nil {
+				report(fmt.Errorf("rpc create: %w", err))
+				return
+			}
+			if resp, err := client.DeleteTimeline(ctx, &DeleteTimelineRequest{TimelineKey: key}); err != nil || !resp.Deleted {
+				report(fmt.Errorf("rpc delete %s: %v %v", key, resp, err))
+				return
+			}
+		}
+	}()
+
+	// RPC读取正在写入的Timeline、块与统计
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			key := fmt.Sprintf("conv_%d", i%writers)
+			resp, err := client.GetTimeline(ctx, &GetTimelineRequest{TimelineKey: key})
+			if err != nil {
+				report(fmt.Errorf("rpc get timeline: %w", err))
+				return
+			}
+			if resp.Exists && len(resp.Timeline.Blocks) > 0 {
+				if _, err := client.GetTimelineBlock(ctx, &GetTimelineBlockRequest{BlockID: resp.Timeline.Blocks[0].BlockID}); err != nil {
+					report(fmt.Errorf("rpc get block: %w", err))
+					return
+				}
+			}
+			if _, err := client.GetMessages(ctx, &GetMessagesRequest{TimelineKey: key, Limit: 5}); err != nil {
+				report(fmt.Errorf("rpc get messages: %w", err))
+				return
+			}
+			if _, err := client.GetStoreStats(ctx, &GetStoreStatsRequest{IncludeTimelines: true}); err != nil {
+				report(fmt.Errorf("rpc stats: %w", err))
+				return
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds/3; i++ {
+			if err := store.Flush(); err != nil {
+				report(fmt.Errorf("flush: %w", err))
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for w := 0; w < writers; w++ {
+		timeline, exists := store.GetTimeline("conv", fmt.Sprintf("conv_%d", w))
+		if !exists {
+			t.Fatalf("Expected conv_%d to exist", w)
+		}
+		if messages, _ := store.GetConvMessages(timeline.ID, 2*rounds, 0); len(messages) != rounds {
+			t.Errorf("Expected %d messages in %s, got %d", rounds, timeline.ID, len(messages))
+		}
+	}
+	for _, timeline := range store.ListTimelines() {
+		if timeline.Type == "conv" && len(timeline.ID) > 9 && timeline.ID[:9] == "conv_tmp_" {
+			t.Errorf("Deleted timeline %s is still listed", timeline.ID)
+		}
+	}
+}
+
+func TestStoreTimelineAccessors(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage("conv_a", 1, []byte("a"), []string{"user_a"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	if _, exists := store.GetTimeline("conv", "conv_missing"); exists {
+		t.Error("Lookup should not create missing timelines")
+	}
+	if timelines := store.ListTimelines(); len(timelines) != 2 || timelines[0].ID != "conv_a" || timelines[1].ID != "user_a" {
+		t.Errorf("Unexpected timelines %v", timelines)
+	}
+	timeline, _ := store.GetTimeline("conv", "conv_a")
+	block, exists := store.GetTimelineBlock(timeline.Blocks[0].BlockID)
+	if !exists || block.Size != 2 {
+		t.Errorf("Expected first block with 2 messages, got %v %v", block, exists)
+	}
+	store.Close()
+
+	// 重启后按元数据文件加载未加载的Timeline
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if len(reopened.ListTimelines()) != 0 {
+		t.Error("Timelines should not be loaded before first access")
+	}
+	timeline, exists = reopened.FindTimeline("user_a")
+	if !exists || timeline.Type != "user" || timeline.LastSeqID != 3 {
+		t.Errorf("Expected user_a to be loaded from disk, got %+v", timeline)
+	}
+}
+
+func TestGetMessagesRejectsNegativeOffset(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage("conv_offset", 1, []byte("a"), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	service := NewLocalStoreService(store)
+	ctx := context.Background()
+	_, err = service.GetMessages(ctx, &GetMessagesRequest{TimelineKey: "conv_offset", Offset: -1, Limit: 2})
+	if !errors.Is(err, errs.ErrInvalidArgument) {
+		t.Fatalf("Expected InvalidArgument for a negative offset, got %v", err)
+	}
+	resp, err := service.GetMessages(ctx, &GetMessagesRequest{TimelineKey: "conv_offset", Offset: 1, Limit: 2})
+	if err != nil || len(resp.Messages) != 2 || resp.Messages[0].SeqID != 2 {
+		t.Fatalf("Expected SeqIDs 2 and 3, got %v %v", resp, err)
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Store生命周期
+// active：正常服务，可以放置新Timeline
+// draining：已隔离，不再放置新Timeline，已有的Timeline继续读写并可以迁出
+// unhealthy：心跳超时或探测失败，不参与路由，恢复后回到故障前的状态
+// decommissioned：已下线，不参与路由和探测，需先注销才能以同一ID重新注册
+
+// StoreStatus Store状态
+type StoreStatus string
+
+const (
+	StoreStatusActive         StoreStatus = "active"
+	StoreStatusDraining       StoreStatus = "draining"
+	StoreStatusUnhealthy      StoreStatus = "unhealthy"
+	StoreStatusDecommissioned StoreStatus = "decommissioned"
+)
+
+// 健康检查响应中的状态值
+const (
+	HealthStatusHealthy  = "healthy"  // Store正常
+	HealthStatusDegraded = "degraded" // 仍在服务，但有未恢复的损坏块，见Store.CorruptBlocks
+)
+
+// healthStatusServing 检查健康检查返回的状态是否表示Store仍在服务，旧版本Store可能不返回状态
+func healthStatusServing(status string) bool {
+	return status == "" || status == HealthStatusHealthy || status == HealthStatusDegraded
+}
+
+// ErrInvalidStoreTransition 状态转换不被允许
+var ErrInvalidStoreTransition = errors.New("invalid store status transition")
+
+// ErrStoreDecommissioned Store已下线
+var ErrStoreDecommissioned = errors.New("store is decommissioned")
+
+// errStoreNotFound 注册中心中没有该Store，用于区分读取失败
+var errStoreNotFound = errors.New("not found")
+
+// storeTransitions 各状态允许转换到的状态
+var storeTransitions = map[StoreStatus][]StoreStatus{
+	StoreStatusActive:         {StoreStatusDraining, StoreStatusUnhealthy, StoreStatusDecommissioned},
+	StoreStatusDraining:       {StoreStatusActive, StoreStatusUnhealthy, StoreStatusDecommissioned},
+	StoreStatusUnhealthy:      {StoreStatusActive, StoreStatusDraining, StoreStatusDecommissioned},
+	StoreStatusDecommissioned: {},
+}
+
+// ParseStoreStatus 解析状态名
+func ParseStoreStatus(value string) (StoreStatus, error) {
+	status := StoreStatus(value)
+	if !status.Valid() {
+		return "", fmt.Errorf("unknown store status: %q", value)
+	}
+	return status, nil
+}
+
+// Valid 是否为已定义的状态
+func (s StoreStatus) Valid() bool {
+	_, ok := storeTransitions[s]
+	return ok
+}
+
+// CanTransitionTo 是否允许转换到next，状态不变总是允许
+func (s StoreStatus) CanTransitionTo(next StoreStatus) bool {
+	if s == next {
+		return next.Valid()
+	}
+	for _, allowed := range storeTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// AcceptsPlacements 是否可以放置新Timeline
+func (s StoreStatus) AcceptsPlacements() bool {
+	return s == StoreStatusActive
+}
+
+// Serving 是否继续服务已有Timeline的读写和迁出
+func (s StoreStatus) Serving() bool {
+	return s == StoreStatusActive || s == StoreStatusDraining
+}
+
+// validateStoreTransition 校验Store的状态转换
+func validateStoreTransition(storeID string, from, to StoreStatus) error {
+	if !to.Valid() {
+		return fmt.Errorf("unknown store status: %q", to)
+	}
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: store %s from %s to %s", ErrInvalidStoreTransition, storeID, from, to)
+	}
+	return nil
+}
+
+// registrationStatus 重复注册时的状态：排空中的Store保持排空，已下线的Store不能重新注册，其余为active
+func registrationStatus(existing *StoreInfo) (StoreStatus, error) {
+	if existing == nil {
+		return StoreStatusActive, nil
+	}
+	switch existing.Status {
+	case StoreStatusDraining:
+		return StoreStatusDraining, nil
+	case StoreStatusDecommissioned:
+		return "", fmt.Errorf("%w: %s", ErrStoreDecommissioned, existing.ID)
+	}
+	return StoreStatusActive, nil
+}
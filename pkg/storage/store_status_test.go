@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStoreStatusLifecycle(t *testing.T) {
+	ctx := context.Background()
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	for _, id := range []string{"store_a", "store_b"} {
+		if err := registry.Register(ctx, &StoreInfo{ID: id}); err != nil {
+			t.Fatalf("Failed to register %s: %v", id, err)
+		}
+	}
+
+	// 隔离后不再放置新Timeline，重新注册和心跳都不解除隔离
+	if err := registry.UpdateStatus(ctx, "store_a", StoreStatusDraining); err != nil {
+		t.Fatalf("Failed to drain: %v", err)
+	}
+	registry.Register(ctx, &StoreInfo{ID: "store_a", Address: "http://a2"})
+	registry.UpdateHeartbeat(ctx, "store_a")
+	if info, _ := registry.GetStore(ctx, "store_a"); info.Status != StoreStatusDraining || info.Address != "http://a2" {
+		t.Fatalf("Expected store_a to stay draining at its new address, got %+v", info)
+	}
+	if active, _ := registry.ListActiveStores(ctx); len(active) != 1 || active[0].ID != "store_b" {
+		t.Fatalf("Expected only store_b to be active, got %d stores", len(active))
+	}
+
+	shards := NewTimelineShardManager(NewInMemoryGlobalIndex(), registry, NewRouterManager(), nil)
+	for _, key := range []string{"conv_1", "conv_2", "conv_3"} {
+		rec, err := shards.GetShardRecommendation(ctx, key, 1024)
+		if err != nil || rec.RecommendedStore != "store_b" || len(rec.Alternatives) != 0 {
+			t.Fatalf("Expected placements to skip the draining store, got %+v %v", rec, err)
+		}
+	}
+	if !StoreStatusDraining.Serving() || StoreStatusUnhealthy.Serving() {
+		t.Errorf("Expected draining stores to keep serving reads")
+	}
+
+	// 下线后不能恢复或重新注册，注销后可以
+	if err := registry.UpdateStatus(ctx, "store_a", StoreStatusDecommissioned); err != nil {
+		t.Fatalf("Failed to decommission: %v", err)
+	}
+	if err := registry.UpdateStatus(ctx, "store_a", StoreStatusActive); !errors.Is(err, ErrInvalidStoreTransition) {
+		t.Errorf("Expected reactivating a decommissioned store to fail, got %v", err)
+	}
+	if err := registry.Register(ctx, &StoreInfo{ID: "store_a"}); !errors.Is(err, ErrStoreDecommissioned) {
+		t.Errorf("Expected registering a decommissioned store to fail, got %v", err)
+	}
+	if err := registry.UpdateStatus(ctx, "store_b", StoreStatus("offline")); err == nil {
+		t.Errorf("Expected an unknown status to be rejected")
+	}
+	registry.Unregister(ctx, "store_a")
+	registry.Register(ctx, &StoreInfo{ID: "store_a"})
+	if info, _ := registry.GetStore(ctx, "store_a"); info.Status != StoreStatusActive {
+		t.Errorf("Expected a fresh registration to be active, got %s", info.Status)
+	}
+}
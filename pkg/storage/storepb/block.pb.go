@@ -0,0 +1,358 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.29.3
+// source: block.proto
+
+package storepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// BlockIndex 块内消息的SeqID/时间/HLC范围
+type BlockIndex struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinSeqId      int64                  `protobuf:"varint,1,opt,name=min_seq_id,json=minSeqId,proto3" json:"min_seq_id,omitempty"`
+	MaxSeqId      int64                  `protobuf:"varint,2,opt,name=max_seq_id,json=maxSeqId,proto3" json:"max_seq_id,omitempty"`
+	MinTime       int64                  `protobuf:"varint,3,opt,name=min_time,json=minTime,proto3" json:"min_time,omitempty"` // UnixNano
+	MaxTime       int64                  `protobuf:"varint,4,opt,name=max_time,json=maxTime,proto3" json:"max_time,omitempty"`
+	MinHlc        int64                  `protobuf:"varint,5,opt,name=min_hlc,json=minHlc,proto3" json:"min_hlc,omitempty"`
+	MaxHlc        int64                  `protobuf:"varint,6,opt,name=max_hlc,json=maxHlc,proto3" json:"max_hlc,omitempty"`
+	Count         int64                  `protobuf:"varint,7,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockIndex) Reset() {
+	*x = BlockIndex{}
+	mi := &file_block_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockIndex) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockIndex) ProtoMessage() {}
+
+func (x *BlockIndex) ProtoReflect() protoreflect.Message {
+	mi := &file_block_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockIndex.ProtoReflect.Descriptor instead.
+func (*BlockIndex) Descriptor() ([]byte, []int) {
+	return file_block_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *BlockIndex) GetMinSeqId() int64 {
+	if x != nil {
+		return x.MinSeqId
+	}
+	return 0
+}
+
+func (x *BlockIndex) GetMaxSeqId() int64 {
+	if x != nil {
+		return x.MaxSeqId
+	}
+	return 0
+}
+
+func (x *BlockIndex) GetMinTime() int64 {
+	if x != nil {
+		return x.MinTime
+	}
+	return 0
+}
+
+func (x *BlockIndex) GetMaxTime() int64 {
+	if x != nil {
+		return x.MaxTime
+	}
+	return 0
+}
+
+func (x *BlockIndex) GetMinHlc() int64 {
+	if x != nil {
+		return x.MinHlc
+	}
+	return 0
+}
+
+func (x *BlockIndex) GetMaxHlc() int64 {
+	if x != nil {
+		return x.MaxHlc
+	}
+	return 0
+}
+
+func (x *BlockIndex) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+// BloomFilter 布隆过滤器的位数组与哈希函数个数
+type BloomFilter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bits          []uint64               `protobuf:"varint,1,rep,packed,name=bits,proto3" json:"bits,omitempty"`
+	Hashes        uint32                 `protobuf:"varint,2,opt,name=hashes,proto3" json:"hashes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BloomFilter) Reset() {
+	*x = BloomFilter{}
+	mi := &file_block_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BloomFilter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BloomFilter) ProtoMessage() {}
+
+func (x *BloomFilter) ProtoReflect() protoreflect.Message {
+	mi := &file_block_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BloomFilter.ProtoReflect.Descriptor instead.
+func (*BloomFilter) Descriptor() ([]byte, []int) {
+	return file_block_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BloomFilter) GetBits() []uint64 {
+	if x != nil {
+		return x.Bits
+	}
+	return nil
+}
+
+func (x *BloomFilter) GetHashes() uint32 {
+	if x != nil {
+		return x.Hashes
+	}
+	return 0
+}
+
+// BlockRecord 段文件中的一条块记录，deleted为true时表示块已删除
+type BlockRecord struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	BlockId  string                 `protobuf:"bytes,1,opt,name=block_id,json=blockId,proto3" json:"block_id,omitempty"`
+	Deleted  bool                   `protobuf:"varint,2,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	Messages []*Message             `protobuf:"bytes,3,rep,name=messages,proto3" json:"messages,omitempty"`
+	// 块索引，未设置时读取方按消息重建
+	Index *BlockIndex `protobuf:"bytes,4,opt,name=index,proto3" json:"index,omitempty"`
+	// 发送者、提及用户与回复消息的布隆过滤器，未开启时不设置
+	Senders  *BloomFilter `protobuf:"bytes,5,opt,name=senders,proto3" json:"senders,omitempty"`
+	Mentions *BloomFilter `protobuf:"bytes,6,opt,name=mentions,proto3" json:"mentions,omitempty"`
+	Replies  *BloomFilter `protobuf:"bytes,7,opt,name=replies,proto3" json:"replies,omitempty"`
+	// 块内消息的CRC32C校验和，0表示写入时未计算
+	Checksum      uint32 `protobuf:"fixed32,8,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BlockRecord) Reset() {
+	*x = BlockRecord{}
+	mi := &file_block_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BlockRecord) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BlockRecord) ProtoMessage() {}
+
+func (x *BlockRecord) ProtoReflect() protoreflect.Message {
+	mi := &file_block_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BlockRecord.ProtoReflect.Descriptor instead.
+func (*BlockRecord) Descriptor() ([]byte, []int) {
+	return file_block_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BlockRecord) GetBlockId() string {
+	if x != nil {
+		return x.BlockId
+	}
+	return ""
+}
+
+func (x *BlockRecord) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+func (x *BlockRecord) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *BlockRecord) GetIndex() *BlockIndex {
+	if x != nil {
+		return x.Index
+	}
+	return nil
+}
+
+func (x *BlockRecord) GetSenders() *BloomFilter {
+	if x != nil {
+		return x.Senders
+	}
+	return nil
+}
+
+func (x *BlockRecord) GetMentions() *BloomFilter {
+	if x != nil {
+		return x.Mentions
+	}
+	return nil
+}
+
+func (x *BlockRecord) GetReplies() *BloomFilter {
+	if x != nil {
+		return x.Replies
+	}
+	return nil
+}
+
+func (x *BlockRecord) GetChecksum() uint32 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+var File_block_proto protoreflect.FileDescriptor
+
+const file_block_proto_rawDesc = "" +
+	"\n" +
+	"\vblock.proto\x12\astorepb\x1a\vstore.proto\"\xc6\x01\n" +
+	"\n" +
+	"BlockIndex\x12\x1c\n" +
+	"\n" +
+	"min_seq_id\x18\x01 \x01(\x03R\bminSeqId\x12\x1c\n" +
+	"\n" +
+	"max_seq_id\x18\x02 \x01(\x03R\bmaxSeqId\x12\x19\n" +
+	"\bmin_time\x18\x03 \x01(\x03R\aminTime\x12\x19\n" +
+	"\bmax_time\x18\x04 \x01(\x03R\amaxTime\x12\x17\n" +
+	"\amin_hlc\x18\x05 \x01(\x03R\x06minHlc\x12\x17\n" +
+	"\amax_hlc\x18\x06 \x01(\x03R\x06maxHlc\x12\x14\n" +
+	"\x05count\x18\a \x01(\x03R\x05count\"9\n" +
+	"\vBloomFilter\x12\x12\n" +
+	"\x04bits\x18\x01 \x03(\x04R\x04bits\x12\x16\n" +
+	"\x06hashes\x18\x02 \x01(\rR\x06hashes\"\xc9\x02\n" +
+	"\vBlockRecord\x12\x19\n" +
+	"\bblock_id\x18\x01 \x01(\tR\ablockId\x12\x18\n" +
+	"\adeleted\x18\x02 \x01(\bR\adeleted\x12,\n" +
+	"\bmessages\x18\x03 \x03(\v2\x10.storepb.MessageR\bmessages\x12)\n" +
+	"\x05index\x18\x04 \x01(\v2\x13.storepb.BlockIndexR\x05index\x12.\n" +
+	"\asenders\x18\x05 \x01(\v2\x14.storepb.BloomFilterR\asenders\x120\n" +
+	"\bmentions\x18\x06 \x01(\v2\x14.storepb.BloomFilterR\bmentions\x12.\n" +
+	"\areplies\x18\a \x01(\v2\x14.storepb.BloomFilterR\areplies\x12\x1a\n" +
+	"\bchecksum\x18\b \x01(\aR\bchecksumB\x19Z\x17imy/pkg/storage/storepbb\x06proto3"
+
+var (
+	file_block_proto_rawDescOnce sync.Once
+	file_block_proto_rawDescData []byte
+)
+
+func file_block_proto_rawDescGZIP() []byte {
+	file_block_proto_rawDescOnce.Do(func() {
+		file_block_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_block_proto_rawDesc), len(file_block_proto_rawDesc)))
+	})
+	return file_block_proto_rawDescData
+}
+
+var file_block_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_block_proto_goTypes = []any{
+	(*BlockIndex)(nil),  // 0: storepb.BlockIndex
+	(*BloomFilter)(nil), // 1: storepb.BloomFilter
+	(*BlockRecord)(nil), // 2: storepb.BlockRecord
+	(*Message)(nil),     // 3: storepb.Message
+}
+var file_block_proto_depIdxs = []int32{
+	3, // 0: storepb.BlockRecord.messages:type_name -> storepb.Message
+	0, // 1: storepb.BlockRecord.index:type_name -> storepb.BlockIndex
+	1, // 2: storepb.BlockRecord.senders:type_name -> storepb.BloomFilter
+	1, // 3: storepb.BlockRecord.mentions:type_name -> storepb.BloomFilter
+	1, // 4: storepb.BlockRecord.replies:type_name -> storepb.BloomFilter
+	5, // [5:5] is the sub-list for method output_type
+	5, // [5:5] is the sub-list for method input_type
+	5, // [5:5] is the sub-list for extension type_name
+	5, // [5:5] is the sub-list for extension extendee
+	0, // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_block_proto_init() }
+func file_block_proto_init() {
+	if File_block_proto != nil {
+		return
+	}
+	file_store_proto_init()
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_block_proto_rawDesc), len(file_block_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_block_proto_goTypes,
+		DependencyIndexes: file_block_proto_depIdxs,
+		MessageInfos:      file_block_proto_msgTypes,
+	}.Build()
+	File_block_proto = out.File
+	file_block_proto_goTypes = nil
+	file_block_proto_depIdxs = nil
+}
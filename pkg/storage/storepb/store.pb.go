@@ -0,0 +1,2252 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        v5.29.3
+// source: store.proto
+
+package storepb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Message 消息
+type Message struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	SeqId      int64                  `protobuf:"varint,1,opt,name=seq_id,json=seqId,proto3" json:"seq_id,omitempty"`
+	ConvId     string                 `protobuf:"bytes,2,opt,name=conv_id,json=convId,proto3" json:"conv_id,omitempty"`
+	SenderId   uint32                 `protobuf:"varint,3,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	CreateTime int64                  `protobuf:"varint,4,opt,name=create_time,json=createTime,proto3" json:"create_time,omitempty"` // UnixNano
+	Data       []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	// 记录类型：0普通消息、1编辑记录、2删除墓碑
+	Type int32 `protobuf:"varint,6,opt,name=type,proto3" json:"type,omitempty"`
+	// 编辑/删除记录引用的原消息SeqID
+	RefSeqId int64 `protobuf:"varint,7,opt,name=ref_seq_id,json=refSeqId,proto3" json:"ref_seq_id,omitempty"`
+	// 用户Timeline中的记录在会话Timeline中的SeqID
+	ConvSeqId int64 `protobuf:"varint,8,opt,name=conv_seq_id,json=convSeqId,proto3" json:"conv_seq_id,omitempty"`
+	// 混合逻辑时钟时间戳，用于跨Store比较先后
+	Hlc int64 `protobuf:"varint,9,opt,name=hlc,proto3" json:"hlc,omitempty"`
+	// 客户端生成的幂等ID
+	ClientMsgId string `protobuf:"bytes,10,opt,name=client_msg_id,json=clientMsgId,proto3" json:"client_msg_id,omitempty"`
+	// 消息中提及的用户UUID
+	Mentions []string `protobuf:"bytes,11,rep,name=mentions,proto3" json:"mentions,omitempty"`
+	// 回复的会话消息SeqID，不是回复时为0
+	ReplyToSeqId  int64 `protobuf:"varint,12,opt,name=reply_to_seq_id,json=replyToSeqId,proto3" json:"reply_to_seq_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Message) Reset() {
+	*x = Message{}
+	mi := &file_store_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Message) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Message) ProtoMessage() {}
+
+func (x *Message) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Message.ProtoReflect.Descriptor instead.
+func (*Message) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Message) GetSeqId() int64 {
+	if x != nil {
+		return x.SeqId
+	}
+	return 0
+}
+
+func (x *Message) GetConvId() string {
+	if x != nil {
+		return x.ConvId
+	}
+	return ""
+}
+
+func (x *Message) GetSenderId() uint32 {
+	if x != nil {
+		return x.SenderId
+	}
+	return 0
+}
+
+func (x *Message) GetCreateTime() int64 {
+	if x != nil {
+		return x.CreateTime
+	}
+	return 0
+}
+
+func (x *Message) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Message) GetType() int32 {
+	if x != nil {
+		return x.Type
+	}
+	return 0
+}
+
+func (x *Message) GetRefSeqId() int64 {
+	if x != nil {
+		return x.RefSeqId
+	}
+	return 0
+}
+
+func (x *Message) GetConvSeqId() int64 {
+	if x != nil {
+		return x.ConvSeqId
+	}
+	return 0
+}
+
+func (x *Message) GetHlc() int64 {
+	if x != nil {
+		return x.Hlc
+	}
+	return 0
+}
+
+func (x *Message) GetClientMsgId() string {
+	if x != nil {
+		return x.ClientMsgId
+	}
+	return ""
+}
+
+func (x *Message) GetMentions() []string {
+	if x != nil {
+		return x.Mentions
+	}
+	return nil
+}
+
+func (x *Message) GetReplyToSeqId() int64 {
+	if x != nil {
+		return x.ReplyToSeqId
+	}
+	return 0
+}
+
+// TimelineBlock 块元数据
+type TimelineBlock struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	BlockId string                 `protobuf:"bytes,1,opt,name=block_id,json=blockId,proto3" json:"block_id,omitempty"`
+	StoreId string                 `protobuf:"bytes,2,opt,name=store_id,json=storeId,proto3" json:"store_id,omitempty"`
+	Offset  int64                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Size    int64                  `protobuf:"varint,4,opt,name=size,proto3" json:"size,omitempty"`
+	IsFull  bool                   `protobuf:"varint,5,opt,name=is_full,json=isFull,proto3" json:"is_full,omitempty"`
+	// 块内消息的校验和，块落盘后有效
+	Checksum      uint32 `protobuf:"varint,6,opt,name=checksum,proto3" json:"checksum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimelineBlock) Reset() {
+	*x = TimelineBlock{}
+	mi := &file_store_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimelineBlock) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimelineBlock) ProtoMessage() {}
+
+func (x *TimelineBlock) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimelineBlock.ProtoReflect.Descriptor instead.
+func (*TimelineBlock) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *TimelineBlock) GetBlockId() string {
+	if x != nil {
+		return x.BlockId
+	}
+	return ""
+}
+
+func (x *TimelineBlock) GetStoreId() string {
+	if x != nil {
+		return x.StoreId
+	}
+	return ""
+}
+
+func (x *TimelineBlock) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *TimelineBlock) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *TimelineBlock) GetIsFull() bool {
+	if x != nil {
+		return x.IsFull
+	}
+	return false
+}
+
+func (x *TimelineBlock) GetChecksum() uint32 {
+	if x != nil {
+		return x.Checksum
+	}
+	return 0
+}
+
+// Timeline 时间线元数据
+type Timeline struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type          string                 `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Blocks        []*TimelineBlock       `protobuf:"bytes,3,rep,name=blocks,proto3" json:"blocks,omitempty"`
+	LastSeqId     int64                  `protobuf:"varint,4,opt,name=last_seq_id,json=lastSeqId,proto3" json:"last_seq_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Timeline) Reset() {
+	*x = Timeline{}
+	mi := &file_store_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Timeline) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Timeline) ProtoMessage() {}
+
+func (x *Timeline) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Timeline.ProtoReflect.Descriptor instead.
+func (*Timeline) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Timeline) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Timeline) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Timeline) GetBlocks() []*TimelineBlock {
+	if x != nil {
+		return x.Blocks
+	}
+	return nil
+}
+
+func (x *Timeline) GetLastSeqId() int64 {
+	if x != nil {
+		return x.LastSeqId
+	}
+	return 0
+}
+
+type GetTimelineRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTimelineRequest) Reset() {
+	*x = GetTimelineRequest{}
+	mi := &file_store_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTimelineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTimelineRequest) ProtoMessage() {}
+
+func (x *GetTimelineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTimelineRequest.ProtoReflect.Descriptor instead.
+func (*GetTimelineRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetTimelineRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+type GetTimelineResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timeline      *Timeline              `protobuf:"bytes,1,opt,name=timeline,proto3" json:"timeline,omitempty"`
+	Exists        bool                   `protobuf:"varint,2,opt,name=exists,proto3" json:"exists,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTimelineResponse) Reset() {
+	*x = GetTimelineResponse{}
+	mi := &file_store_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTimelineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTimelineResponse) ProtoMessage() {}
+
+func (x *GetTimelineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTimelineResponse.ProtoReflect.Descriptor instead.
+func (*GetTimelineResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetTimelineResponse) GetTimeline() *Timeline {
+	if x != nil {
+		return x.Timeline
+	}
+	return nil
+}
+
+func (x *GetTimelineResponse) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+type CreateTimelineRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	Metadata      map[string]string      `protobuf:"bytes,2,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateTimelineRequest) Reset() {
+	*x = CreateTimelineRequest{}
+	mi := &file_store_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateTimelineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateTimelineRequest) ProtoMessage() {}
+
+func (x *CreateTimelineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateTimelineRequest.ProtoReflect.Descriptor instead.
+func (*CreateTimelineRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CreateTimelineRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *CreateTimelineRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+type CreateTimelineResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Timeline      *Timeline              `protobuf:"bytes,1,opt,name=timeline,proto3" json:"timeline,omitempty"`
+	Created       bool                   `protobuf:"varint,2,opt,name=created,proto3" json:"created,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateTimelineResponse) Reset() {
+	*x = CreateTimelineResponse{}
+	mi := &file_store_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateTimelineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateTimelineResponse) ProtoMessage() {}
+
+func (x *CreateTimelineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateTimelineResponse.ProtoReflect.Descriptor instead.
+func (*CreateTimelineResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CreateTimelineResponse) GetTimeline() *Timeline {
+	if x != nil {
+		return x.Timeline
+	}
+	return nil
+}
+
+func (x *CreateTimelineResponse) GetCreated() bool {
+	if x != nil {
+		return x.Created
+	}
+	return false
+}
+
+type DeleteTimelineRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	Force         bool                   `protobuf:"varint,2,opt,name=force,proto3" json:"force,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteTimelineRequest) Reset() {
+	*x = DeleteTimelineRequest{}
+	mi := &file_store_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteTimelineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteTimelineRequest) ProtoMessage() {}
+
+func (x *DeleteTimelineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteTimelineRequest.ProtoReflect.Descriptor instead.
+func (*DeleteTimelineRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteTimelineRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *DeleteTimelineRequest) GetForce() bool {
+	if x != nil {
+		return x.Force
+	}
+	return false
+}
+
+type DeleteTimelineResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deleted       bool                   `protobuf:"varint,1,opt,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteTimelineResponse) Reset() {
+	*x = DeleteTimelineResponse{}
+	mi := &file_store_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteTimelineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteTimelineResponse) ProtoMessage() {}
+
+func (x *DeleteTimelineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteTimelineResponse.ProtoReflect.Descriptor instead.
+func (*DeleteTimelineResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *DeleteTimelineResponse) GetDeleted() bool {
+	if x != nil {
+		return x.Deleted
+	}
+	return false
+}
+
+type MigrateTimelineRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	TargetStoreId string                 `protobuf:"bytes,2,opt,name=target_store_id,json=targetStoreId,proto3" json:"target_store_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MigrateTimelineRequest) Reset() {
+	*x = MigrateTimelineRequest{}
+	mi := &file_store_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrateTimelineRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrateTimelineRequest) ProtoMessage() {}
+
+func (x *MigrateTimelineRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrateTimelineRequest.ProtoReflect.Descriptor instead.
+func (*MigrateTimelineRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *MigrateTimelineRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *MigrateTimelineRequest) GetTargetStoreId() string {
+	if x != nil {
+		return x.TargetStoreId
+	}
+	return ""
+}
+
+type MigrateTimelineResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	Success        bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	MigratedBlocks []string               `protobuf:"bytes,2,rep,name=migrated_blocks,json=migratedBlocks,proto3" json:"migrated_blocks,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *MigrateTimelineResponse) Reset() {
+	*x = MigrateTimelineResponse{}
+	mi := &file_store_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MigrateTimelineResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MigrateTimelineResponse) ProtoMessage() {}
+
+func (x *MigrateTimelineResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MigrateTimelineResponse.ProtoReflect.Descriptor instead.
+func (*MigrateTimelineResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *MigrateTimelineResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *MigrateTimelineResponse) GetMigratedBlocks() []string {
+	if x != nil {
+		return x.MigratedBlocks
+	}
+	return nil
+}
+
+type AddMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	Message       *Message               `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	UserIds       []string               `protobuf:"bytes,3,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddMessageRequest) Reset() {
+	*x = AddMessageRequest{}
+	mi := &file_store_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddMessageRequest) ProtoMessage() {}
+
+func (x *AddMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddMessageRequest.ProtoReflect.Descriptor instead.
+func (*AddMessageRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *AddMessageRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *AddMessageRequest) GetMessage() *Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+func (x *AddMessageRequest) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+type AddMessageResponse struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	BlockId   string                 `protobuf:"bytes,1,opt,name=block_id,json=blockId,proto3" json:"block_id,omitempty"`
+	Offset    int64                  `protobuf:"varint,2,opt,name=offset,proto3" json:"offset,omitempty"`
+	MessageId string                 `protobuf:"bytes,3,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	// 消息的HLC，复制到副本时随消息传递
+	Hlc   int64 `protobuf:"varint,4,opt,name=hlc,proto3" json:"hlc,omitempty"`
+	SeqId int64 `protobuf:"varint,5,opt,name=seq_id,json=seqId,proto3" json:"seq_id,omitempty"`
+	// client_msg_id重复，seq_id为已有消息的SeqID，未写入新消息
+	Duplicate     bool `protobuf:"varint,6,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AddMessageResponse) Reset() {
+	*x = AddMessageResponse{}
+	mi := &file_store_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AddMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AddMessageResponse) ProtoMessage() {}
+
+func (x *AddMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AddMessageResponse.ProtoReflect.Descriptor instead.
+func (*AddMessageResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *AddMessageResponse) GetBlockId() string {
+	if x != nil {
+		return x.BlockId
+	}
+	return ""
+}
+
+func (x *AddMessageResponse) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *AddMessageResponse) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *AddMessageResponse) GetHlc() int64 {
+	if x != nil {
+		return x.Hlc
+	}
+	return 0
+}
+
+func (x *AddMessageResponse) GetSeqId() int64 {
+	if x != nil {
+		return x.SeqId
+	}
+	return 0
+}
+
+func (x *AddMessageResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+type GetMessagesRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	StartTime   int64                  `protobuf:"varint,2,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime     int64                  `protobuf:"varint,3,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Limit       int32                  `protobuf:"varint,4,opt,name=limit,proto3" json:"limit,omitempty"`
+	Offset      int32                  `protobuf:"varint,5,opt,name=offset,proto3" json:"offset,omitempty"`
+	// 按SeqID游标分页，设置后忽略offset
+	BeforeSeqId int64 `protobuf:"varint,6,opt,name=before_seq_id,json=beforeSeqId,proto3" json:"before_seq_id,omitempty"`
+	AfterSeqId  int64 `protobuf:"varint,7,opt,name=after_seq_id,json=afterSeqId,proto3" json:"after_seq_id,omitempty"`
+	// 按HLC闭区间过滤，0表示不限制；设置后结果按HLC排序
+	StartHlc      int64 `protobuf:"varint,8,opt,name=start_hlc,json=startHlc,proto3" json:"start_hlc,omitempty"`
+	EndHlc        int64 `protobuf:"varint,9,opt,name=end_hlc,json=endHlc,proto3" json:"end_hlc,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMessagesRequest) Reset() {
+	*x = GetMessagesRequest{}
+	mi := &file_store_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMessagesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMessagesRequest) ProtoMessage() {}
+
+func (x *GetMessagesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMessagesRequest.ProtoReflect.Descriptor instead.
+func (*GetMessagesRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetMessagesRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *GetMessagesRequest) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetEndTime() int64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetBeforeSeqId() int64 {
+	if x != nil {
+		return x.BeforeSeqId
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetAfterSeqId() int64 {
+	if x != nil {
+		return x.AfterSeqId
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetStartHlc() int64 {
+	if x != nil {
+		return x.StartHlc
+	}
+	return 0
+}
+
+func (x *GetMessagesRequest) GetEndHlc() int64 {
+	if x != nil {
+		return x.EndHlc
+	}
+	return 0
+}
+
+type GetMessagesResponse struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Messages []*Message             `protobuf:"bytes,1,rep,name=messages,proto3" json:"messages,omitempty"`
+	Total    int32                  `protobuf:"varint,2,opt,name=total,proto3" json:"total,omitempty"`
+	HasMore  bool                   `protobuf:"varint,3,opt,name=has_more,json=hasMore,proto3" json:"has_more,omitempty"`
+	// 继续翻页时使用的SeqID游标，没有更多消息时为0
+	NextCursor    int64 `protobuf:"varint,4,opt,name=next_cursor,json=nextCursor,proto3" json:"next_cursor,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetMessagesResponse) Reset() {
+	*x = GetMessagesResponse{}
+	mi := &file_store_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetMessagesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetMessagesResponse) ProtoMessage() {}
+
+func (x *GetMessagesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetMessagesResponse.ProtoReflect.Descriptor instead.
+func (*GetMessagesResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *GetMessagesResponse) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+func (x *GetMessagesResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *GetMessagesResponse) GetHasMore() bool {
+	if x != nil {
+		return x.HasMore
+	}
+	return false
+}
+
+func (x *GetMessagesResponse) GetNextCursor() int64 {
+	if x != nil {
+		return x.NextCursor
+	}
+	return 0
+}
+
+type EditMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	SeqId         int64                  `protobuf:"varint,2,opt,name=seq_id,json=seqId,proto3" json:"seq_id,omitempty"`
+	SenderId      uint32                 `protobuf:"varint,3,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	Data          []byte                 `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	UserIds       []string               `protobuf:"bytes,5,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EditMessageRequest) Reset() {
+	*x = EditMessageRequest{}
+	mi := &file_store_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EditMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditMessageRequest) ProtoMessage() {}
+
+func (x *EditMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditMessageRequest.ProtoReflect.Descriptor instead.
+func (*EditMessageRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *EditMessageRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *EditMessageRequest) GetSeqId() int64 {
+	if x != nil {
+		return x.SeqId
+	}
+	return 0
+}
+
+func (x *EditMessageRequest) GetSenderId() uint32 {
+	if x != nil {
+		return x.SenderId
+	}
+	return 0
+}
+
+func (x *EditMessageRequest) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *EditMessageRequest) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+type EditMessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       *Message               `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EditMessageResponse) Reset() {
+	*x = EditMessageResponse{}
+	mi := &file_store_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EditMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditMessageResponse) ProtoMessage() {}
+
+func (x *EditMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditMessageResponse.ProtoReflect.Descriptor instead.
+func (*EditMessageResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *EditMessageResponse) GetMessage() *Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+type DeleteMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	SeqId         int64                  `protobuf:"varint,2,opt,name=seq_id,json=seqId,proto3" json:"seq_id,omitempty"`
+	SenderId      uint32                 `protobuf:"varint,3,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	UserIds       []string               `protobuf:"bytes,4,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMessageRequest) Reset() {
+	*x = DeleteMessageRequest{}
+	mi := &file_store_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMessageRequest) ProtoMessage() {}
+
+func (x *DeleteMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMessageRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMessageRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DeleteMessageRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *DeleteMessageRequest) GetSeqId() int64 {
+	if x != nil {
+		return x.SeqId
+	}
+	return 0
+}
+
+func (x *DeleteMessageRequest) GetSenderId() uint32 {
+	if x != nil {
+		return x.SenderId
+	}
+	return 0
+}
+
+func (x *DeleteMessageRequest) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+type DeleteMessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Message       *Message               `protobuf:"bytes,1,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMessageResponse) Reset() {
+	*x = DeleteMessageResponse{}
+	mi := &file_store_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMessageResponse) ProtoMessage() {}
+
+func (x *DeleteMessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMessageResponse.ProtoReflect.Descriptor instead.
+func (*DeleteMessageResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *DeleteMessageResponse) GetMessage() *Message {
+	if x != nil {
+		return x.Message
+	}
+	return nil
+}
+
+type GetTimelineBlockRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	BlockId       string                 `protobuf:"bytes,1,opt,name=block_id,json=blockId,proto3" json:"block_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTimelineBlockRequest) Reset() {
+	*x = GetTimelineBlockRequest{}
+	mi := &file_store_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTimelineBlockRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTimelineBlockRequest) ProtoMessage() {}
+
+func (x *GetTimelineBlockRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTimelineBlockRequest.ProtoReflect.Descriptor instead.
+func (*GetTimelineBlockRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GetTimelineBlockRequest) GetBlockId() string {
+	if x != nil {
+		return x.BlockId
+	}
+	return ""
+}
+
+type GetTimelineBlockResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Block         *TimelineBlock         `protobuf:"bytes,1,opt,name=block,proto3" json:"block,omitempty"`
+	Exists        bool                   `protobuf:"varint,2,opt,name=exists,proto3" json:"exists,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetTimelineBlockResponse) Reset() {
+	*x = GetTimelineBlockResponse{}
+	mi := &file_store_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetTimelineBlockResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetTimelineBlockResponse) ProtoMessage() {}
+
+func (x *GetTimelineBlockResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetTimelineBlockResponse.ProtoReflect.Descriptor instead.
+func (*GetTimelineBlockResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *GetTimelineBlockResponse) GetBlock() *TimelineBlock {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+func (x *GetTimelineBlockResponse) GetExists() bool {
+	if x != nil {
+		return x.Exists
+	}
+	return false
+}
+
+type StreamTimelineBlocksRequest struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	// 从该块之后开始导出，用于断点续传
+	AfterBlockId  string `protobuf:"bytes,2,opt,name=after_block_id,json=afterBlockId,proto3" json:"after_block_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamTimelineBlocksRequest) Reset() {
+	*x = StreamTimelineBlocksRequest{}
+	mi := &file_store_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamTimelineBlocksRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamTimelineBlocksRequest) ProtoMessage() {}
+
+func (x *StreamTimelineBlocksRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamTimelineBlocksRequest.ProtoReflect.Descriptor instead.
+func (*StreamTimelineBlocksRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *StreamTimelineBlocksRequest) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *StreamTimelineBlocksRequest) GetAfterBlockId() string {
+	if x != nil {
+		return x.AfterBlockId
+	}
+	return ""
+}
+
+// TimelineBlockData 块元数据及其完整消息，每个流消息传输一个块
+type TimelineBlockData struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey   string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	TimelineType  string                 `protobuf:"bytes,2,opt,name=timeline_type,json=timelineType,proto3" json:"timeline_type,omitempty"`
+	Block         *TimelineBlock         `protobuf:"bytes,3,opt,name=block,proto3" json:"block,omitempty"`
+	Messages      []*Message             `protobuf:"bytes,4,rep,name=messages,proto3" json:"messages,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TimelineBlockData) Reset() {
+	*x = TimelineBlockData{}
+	mi := &file_store_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TimelineBlockData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TimelineBlockData) ProtoMessage() {}
+
+func (x *TimelineBlockData) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TimelineBlockData.ProtoReflect.Descriptor instead.
+func (*TimelineBlockData) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *TimelineBlockData) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *TimelineBlockData) GetTimelineType() string {
+	if x != nil {
+		return x.TimelineType
+	}
+	return ""
+}
+
+func (x *TimelineBlockData) GetBlock() *TimelineBlock {
+	if x != nil {
+		return x.Block
+	}
+	return nil
+}
+
+func (x *TimelineBlockData) GetMessages() []*Message {
+	if x != nil {
+		return x.Messages
+	}
+	return nil
+}
+
+// ImportTimelineBlocksResponse 块导入结果，所有块接收完成后一次性写入
+type ImportTimelineBlocksResponse struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	TimelineKey      string                 `protobuf:"bytes,1,opt,name=timeline_key,json=timelineKey,proto3" json:"timeline_key,omitempty"`
+	ImportedBlocks   int32                  `protobuf:"varint,2,opt,name=imported_blocks,json=importedBlocks,proto3" json:"imported_blocks,omitempty"`
+	ImportedMessages int64                  `protobuf:"varint,3,opt,name=imported_messages,json=importedMessages,proto3" json:"imported_messages,omitempty"`
+	LastSeqId        int64                  `protobuf:"varint,4,opt,name=last_seq_id,json=lastSeqId,proto3" json:"last_seq_id,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ImportTimelineBlocksResponse) Reset() {
+	*x = ImportTimelineBlocksResponse{}
+	mi := &file_store_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImportTimelineBlocksResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImportTimelineBlocksResponse) ProtoMessage() {}
+
+func (x *ImportTimelineBlocksResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImportTimelineBlocksResponse.ProtoReflect.Descriptor instead.
+func (*ImportTimelineBlocksResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ImportTimelineBlocksResponse) GetTimelineKey() string {
+	if x != nil {
+		return x.TimelineKey
+	}
+	return ""
+}
+
+func (x *ImportTimelineBlocksResponse) GetImportedBlocks() int32 {
+	if x != nil {
+		return x.ImportedBlocks
+	}
+	return 0
+}
+
+func (x *ImportTimelineBlocksResponse) GetImportedMessages() int64 {
+	if x != nil {
+		return x.ImportedMessages
+	}
+	return 0
+}
+
+func (x *ImportTimelineBlocksResponse) GetLastSeqId() int64 {
+	if x != nil {
+		return x.LastSeqId
+	}
+	return 0
+}
+
+type GetStoreStatsRequest struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	IncludeTimelines bool                   `protobuf:"varint,1,opt,name=include_timelines,json=includeTimelines,proto3" json:"include_timelines,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *GetStoreStatsRequest) Reset() {
+	*x = GetStoreStatsRequest{}
+	mi := &file_store_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStoreStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStoreStatsRequest) ProtoMessage() {}
+
+func (x *GetStoreStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStoreStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetStoreStatsRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *GetStoreStatsRequest) GetIncludeTimelines() bool {
+	if x != nil {
+		return x.IncludeTimelines
+	}
+	return false
+}
+
+type GetStoreStatsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	StoreId       string                 `protobuf:"bytes,1,opt,name=store_id,json=storeId,proto3" json:"store_id,omitempty"`
+	TimelineCount int32                  `protobuf:"varint,2,opt,name=timeline_count,json=timelineCount,proto3" json:"timeline_count,omitempty"`
+	BlockCount    int32                  `protobuf:"varint,3,opt,name=block_count,json=blockCount,proto3" json:"block_count,omitempty"`
+	TotalSize     int64                  `protobuf:"varint,4,opt,name=total_size,json=totalSize,proto3" json:"total_size,omitempty"`
+	Timelines     []string               `protobuf:"bytes,5,rep,name=timelines,proto3" json:"timelines,omitempty"`
+	Uptime        int64                  `protobuf:"varint,6,opt,name=uptime,proto3" json:"uptime,omitempty"`
+	LastUpdate    int64                  `protobuf:"varint,7,opt,name=last_update,json=lastUpdate,proto3" json:"last_update,omitempty"`
+	// 容量统计（字节），total_size为已用容量
+	MaxCapacity   int64 `protobuf:"varint,8,opt,name=max_capacity,json=maxCapacity,proto3" json:"max_capacity,omitempty"`
+	BlockBytes    int64 `protobuf:"varint,9,opt,name=block_bytes,json=blockBytes,proto3" json:"block_bytes,omitempty"`
+	WalBytes      int64 `protobuf:"varint,10,opt,name=wal_bytes,json=walBytes,proto3" json:"wal_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetStoreStatsResponse) Reset() {
+	*x = GetStoreStatsResponse{}
+	mi := &file_store_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetStoreStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetStoreStatsResponse) ProtoMessage() {}
+
+func (x *GetStoreStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetStoreStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetStoreStatsResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *GetStoreStatsResponse) GetStoreId() string {
+	if x != nil {
+		return x.StoreId
+	}
+	return ""
+}
+
+func (x *GetStoreStatsResponse) GetTimelineCount() int32 {
+	if x != nil {
+		return x.TimelineCount
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetBlockCount() int32 {
+	if x != nil {
+		return x.BlockCount
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetTotalSize() int64 {
+	if x != nil {
+		return x.TotalSize
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetTimelines() []string {
+	if x != nil {
+		return x.Timelines
+	}
+	return nil
+}
+
+func (x *GetStoreStatsResponse) GetUptime() int64 {
+	if x != nil {
+		return x.Uptime
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetLastUpdate() int64 {
+	if x != nil {
+		return x.LastUpdate
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetMaxCapacity() int64 {
+	if x != nil {
+		return x.MaxCapacity
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetBlockBytes() int64 {
+	if x != nil {
+		return x.BlockBytes
+	}
+	return 0
+}
+
+func (x *GetStoreStatsResponse) GetWalBytes() int64 {
+	if x != nil {
+		return x.WalBytes
+	}
+	return 0
+}
+
+type HealthCheckRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ping          string                 `protobuf:"bytes,1,opt,name=ping,proto3" json:"ping,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthCheckRequest) Reset() {
+	*x = HealthCheckRequest{}
+	mi := &file_store_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheckRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheckRequest) ProtoMessage() {}
+
+func (x *HealthCheckRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheckRequest.ProtoReflect.Descriptor instead.
+func (*HealthCheckRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *HealthCheckRequest) GetPing() string {
+	if x != nil {
+		return x.Ping
+	}
+	return ""
+}
+
+type HealthCheckResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Pong          string                 `protobuf:"bytes,1,opt,name=pong,proto3" json:"pong,omitempty"`
+	Status        string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,3,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *HealthCheckResponse) Reset() {
+	*x = HealthCheckResponse{}
+	mi := &file_store_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HealthCheckResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HealthCheckResponse) ProtoMessage() {}
+
+func (x *HealthCheckResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HealthCheckResponse.ProtoReflect.Descriptor instead.
+func (*HealthCheckResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *HealthCheckResponse) GetPong() string {
+	if x != nil {
+		return x.Pong
+	}
+	return ""
+}
+
+func (x *HealthCheckResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *HealthCheckResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+// TransactionRequest 两阶段提交中发给参与者Store的请求
+type TransactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	TransactionId string                 `protobuf:"bytes,1,opt,name=transaction_id,json=transactionId,proto3" json:"transaction_id,omitempty"`
+	StoreId       string                 `protobuf:"bytes,2,opt,name=store_id,json=storeId,proto3" json:"store_id,omitempty"`
+	Operation     int32                  `protobuf:"varint,3,opt,name=operation,proto3" json:"operation,omitempty"`
+	// 参与者参数的JSON编码
+	Params        []byte `protobuf:"bytes,4,opt,name=params,proto3" json:"params,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionRequest) Reset() {
+	*x = TransactionRequest{}
+	mi := &file_store_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionRequest) ProtoMessage() {}
+
+func (x *TransactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionRequest.ProtoReflect.Descriptor instead.
+func (*TransactionRequest) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *TransactionRequest) GetTransactionId() string {
+	if x != nil {
+		return x.TransactionId
+	}
+	return ""
+}
+
+func (x *TransactionRequest) GetStoreId() string {
+	if x != nil {
+		return x.StoreId
+	}
+	return ""
+}
+
+func (x *TransactionRequest) GetOperation() int32 {
+	if x != nil {
+		return x.Operation
+	}
+	return 0
+}
+
+func (x *TransactionRequest) GetParams() []byte {
+	if x != nil {
+		return x.Params
+	}
+	return nil
+}
+
+// TransactionResponse 参与者的处理结果，被拒绝时success为false，code为RPC错误码
+type TransactionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
+	Code          int32                  `protobuf:"varint,2,opt,name=code,proto3" json:"code,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionResponse) Reset() {
+	*x = TransactionResponse{}
+	mi := &file_store_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionResponse) ProtoMessage() {}
+
+func (x *TransactionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_store_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionResponse.ProtoReflect.Descriptor instead.
+func (*TransactionResponse) Descriptor() ([]byte, []int) {
+	return file_store_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *TransactionResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *TransactionResponse) GetCode() int32 {
+	if x != nil {
+		return x.Code
+	}
+	return 0
+}
+
+func (x *TransactionResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+var File_store_proto protoreflect.FileDescriptor
+
+const file_store_proto_rawDesc = "" +
+	"\n" +
+	"\vstore.proto\x12\astorepb\"\xd6\x02\n" +
+	"\aMessage\x12\x15\n" +
+	"\x06seq_id\x18\x01 \x01(\x03R\x05seqId\x12\x17\n" +
+	"\aconv_id\x18\x02 \x01(\tR\x06convId\x12\x1b\n" +
+	"\tsender_id\x18\x03 \x01(\rR\bsenderId\x12\x1f\n" +
+	"\vcreate_time\x18\x04 \x01(\x03R\n" +
+	"createTime\x12\x12\n" +
+	"\x04data\x18\x05 \x01(\fR\x04data\x12\x12\n" +
+	"\x04type\x18\x06 \x01(\x05R\x04type\x12\x1c\n" +
+	"\n" +
+	"ref_seq_id\x18\a \x01(\x03R\brefSeqId\x12\x1e\n" +
+	"\vconv_seq_id\x18\b \x01(\x03R\tconvSeqId\x12\x10\n" +
+	"\x03hlc\x18\t \x01(\x03R\x03hlc\x12\"\n" +
+	"\rclient_msg_id\x18\n" +
+	" \x01(\tR\vclientMsgId\x12\x1a\n" +
+	"\bmentions\x18\v \x03(\tR\bmentions\x12%\n" +
+	"\x0freply_to_seq_id\x18\f \x01(\x03R\freplyToSeqId\"\xa6\x01\n" +
+	"\rTimelineBlock\x12\x19\n" +
+	"\bblock_id\x18\x01 \x01(\tR\ablockId\x12\x19\n" +
+	"\bstore_id\x18\x02 \x01(\tR\astoreId\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x03R\x06offset\x12\x12\n" +
+	"\x04size\x18\x04 \x01(\x03R\x04size\x12\x17\n" +
+	"\ais_full\x18\x05 \x01(\bR\x06isFull\x12\x1a\n" +
+	"\bchecksum\x18\x06 \x01(\rR\bchecksum\"~\n" +
+	"\bTimeline\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\x12.\n" +
+	"\x06blocks\x18\x03 \x03(\v2\x16.storepb.TimelineBlockR\x06blocks\x12\x1e\n" +
+	"\vlast_seq_id\x18\x04 \x01(\x03R\tlastSeqId\"7\n" +
+	"\x12GetTimelineRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\"\\\n" +
+	"\x13GetTimelineResponse\x12-\n" +
+	"\btimeline\x18\x01 \x01(\v2\x11.storepb.TimelineR\btimeline\x12\x16\n" +
+	"\x06exists\x18\x02 \x01(\bR\x06exists\"\xc1\x01\n" +
+	"\x15CreateTimelineRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12H\n" +
+	"\bmetadata\x18\x02 \x03(\v2,.storepb.CreateTimelineRequest.MetadataEntryR\bmetadata\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"a\n" +
+	"\x16CreateTimelineResponse\x12-\n" +
+	"\btimeline\x18\x01 \x01(\v2\x11.storepb.TimelineR\btimeline\x12\x18\n" +
+	"\acreated\x18\x02 \x01(\bR\acreated\"P\n" +
+	"\x15DeleteTimelineRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12\x14\n" +
+	"\x05force\x18\x02 \x01(\bR\x05force\"2\n" +
+	"\x16DeleteTimelineResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\bR\adeleted\"c\n" +
+	"\x16MigrateTimelineRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12&\n" +
+	"\x0ftarget_store_id\x18\x02 \x01(\tR\rtargetStoreId\"\\\n" +
+	"\x17MigrateTimelineResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12'\n" +
+	"\x0fmigrated_blocks\x18\x02 \x03(\tR\x0emigratedBlocks\"}\n" +
+	"\x11AddMessageRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12*\n" +
+	"\amessage\x18\x02 \x01(\v2\x10.storepb.MessageR\amessage\x12\x19\n" +
+	"\buser_ids\x18\x03 \x03(\tR\auserIds\"\xad\x01\n" +
+	"\x12AddMessageResponse\x12\x19\n" +
+	"\bblock_id\x18\x01 \x01(\tR\ablockId\x12\x16\n" +
+	"\x06offset\x18\x02 \x01(\x03R\x06offset\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x03 \x01(\tR\tmessageId\x12\x10\n" +
+	"\x03hlc\x18\x04 \x01(\x03R\x03hlc\x12\x15\n" +
+	"\x06seq_id\x18\x05 \x01(\x03R\x05seqId\x12\x1c\n" +
+	"\tduplicate\x18\x06 \x01(\bR\tduplicate\"\x9b\x02\n" +
+	"\x12GetMessagesRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x02 \x01(\x03R\tstartTime\x12\x19\n" +
+	"\bend_time\x18\x03 \x01(\x03R\aendTime\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06offset\x18\x05 \x01(\x05R\x06offset\x12\"\n" +
+	"\rbefore_seq_id\x18\x06 \x01(\x03R\vbeforeSeqId\x12 \n" +
+	"\fafter_seq_id\x18\a \x01(\x03R\n" +
+	"afterSeqId\x12\x1b\n" +
+	"\tstart_hlc\x18\b \x01(\x03R\bstartHlc\x12\x17\n" +
+	"\aend_hlc\x18\t \x01(\x03R\x06endHlc\"\x95\x01\n" +
+	"\x13GetMessagesResponse\x12,\n" +
+	"\bmessages\x18\x01 \x03(\v2\x10.storepb.MessageR\bmessages\x12\x14\n" +
+	"\x05total\x18\x02 \x01(\x05R\x05total\x12\x19\n" +
+	"\bhas_more\x18\x03 \x01(\bR\ahasMore\x12\x1f\n" +
+	"\vnext_cursor\x18\x04 \x01(\x03R\n" +
+	"nextCursor\"\x9a\x01\n" +
+	"\x12EditMessageRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12\x15\n" +
+	"\x06seq_id\x18\x02 \x01(\x03R\x05seqId\x12\x1b\n" +
+	"\tsender_id\x18\x03 \x01(\rR\bsenderId\x12\x12\n" +
+	"\x04data\x18\x04 \x01(\fR\x04data\x12\x19\n" +
+	"\buser_ids\x18\x05 \x03(\tR\auserIds\"A\n" +
+	"\x13EditMessageResponse\x12*\n" +
+	"\amessage\x18\x01 \x01(\v2\x10.storepb.MessageR\amessage\"\x88\x01\n" +
+	"\x14DeleteMessageRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12\x15\n" +
+	"\x06seq_id\x18\x02 \x01(\x03R\x05seqId\x12\x1b\n" +
+	"\tsender_id\x18\x03 \x01(\rR\bsenderId\x12\x19\n" +
+	"\buser_ids\x18\x04 \x03(\tR\auserIds\"C\n" +
+	"\x15DeleteMessageResponse\x12*\n" +
+	"\amessage\x18\x01 \x01(\v2\x10.storepb.MessageR\amessage\"4\n" +
+	"\x17GetTimelineBlockRequest\x12\x19\n" +
+	"\bblock_id\x18\x01 \x01(\tR\ablockId\"`\n" +
+	"\x18GetTimelineBlockResponse\x12,\n" +
+	"\x05block\x18\x01 \x01(\v2\x16.storepb.TimelineBlockR\x05block\x12\x16\n" +
+	"\x06exists\x18\x02 \x01(\bR\x06exists\"f\n" +
+	"\x1bStreamTimelineBlocksRequest\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12$\n" +
+	"\x0eafter_block_id\x18\x02 \x01(\tR\fafterBlockId\"\xb7\x01\n" +
+	"\x11TimelineBlockData\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12#\n" +
+	"\rtimeline_type\x18\x02 \x01(\tR\ftimelineType\x12,\n" +
+	"\x05block\x18\x03 \x01(\v2\x16.storepb.TimelineBlockR\x05block\x12,\n" +
+	"\bmessages\x18\x04 \x03(\v2\x10.storepb.MessageR\bmessages\"\xb7\x01\n" +
+	"\x1cImportTimelineBlocksResponse\x12!\n" +
+	"\ftimeline_key\x18\x01 \x01(\tR\vtimelineKey\x12'\n" +
+	"\x0fimported_blocks\x18\x02 \x01(\x05R\x0eimportedBlocks\x12+\n" +
+	"\x11imported_messages\x18\x03 \x01(\x03R\x10importedMessages\x12\x1e\n" +
+	"\vlast_seq_id\x18\x04 \x01(\x03R\tlastSeqId\"C\n" +
+	"\x14GetStoreStatsRequest\x12+\n" +
+	"\x11include_timelines\x18\x01 \x01(\bR\x10includeTimelines\"\xd1\x02\n" +
+	"\x15GetStoreStatsResponse\x12\x19\n" +
+	"\bstore_id\x18\x01 \x01(\tR\astoreId\x12%\n" +
+	"\x0etimeline_count\x18\x02 \x01(\x05R\rtimelineCount\x12\x1f\n" +
+	"\vblock_count\x18\x03 \x01(\x05R\n" +
+	"blockCount\x12\x1d\n" +
+	"\n" +
+	"total_size\x18\x04 \x01(\x03R\ttotalSize\x12\x1c\n" +
+	"\ttimelines\x18\x05 \x03(\tR\ttimelines\x12\x16\n" +
+	"\x06uptime\x18\x06 \x01(\x03R\x06uptime\x12\x1f\n" +
+	"\vlast_update\x18\a \x01(\x03R\n" +
+	"lastUpdate\x12!\n" +
+	"\fmax_capacity\x18\b \x01(\x03R\vmaxCapacity\x12\x1f\n" +
+	"\vblock_bytes\x18\t \x01(\x03R\n" +
+	"blockBytes\x12\x1b\n" +
+	"\twal_bytes\x18\n" +
+	" \x01(\x03R\bwalBytes\"(\n" +
+	"\x12HealthCheckRequest\x12\x12\n" +
+	"\x04ping\x18\x01 \x01(\tR\x04ping\"_\n" +
+	"\x13HealthCheckResponse\x12\x12\n" +
+	"\x04pong\x18\x01 \x01(\tR\x04pong\x12\x16\n" +
+	"\x06status\x18\x02 \x01(\tR\x06status\x12\x1c\n" +
+	"\ttimestamp\x18\x03 \x01(\x03R\ttimestamp\"\x8c\x01\n" +
+	"\x12TransactionRequest\x12%\n" +
+	"\x0etransaction_id\x18\x01 \x01(\tR\rtransactionId\x12\x19\n" +
+	"\bstore_id\x18\x02 \x01(\tR\astoreId\x12\x1c\n" +
+	"\toperation\x18\x03 \x01(\x05R\toperation\x12\x16\n" +
+	"\x06params\x18\x04 \x01(\fR\x06params\"Y\n" +
+	"\x13TransactionResponse\x12\x18\n" +
+	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x12\n" +
+	"\x04code\x18\x02 \x01(\x05R\x04code\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error2\x97\n" +
+	"\n" +
+	"\bStoreRPC\x12H\n" +
+	"\vGetTimeline\x12\x1b.storepb.GetTimelineRequest\x1a\x1c.storepb.GetTimelineResponse\x12Q\n" +
+	"\x0eCreateTimeline\x12\x1e.storepb.CreateTimelineRequest\x1a\x1f.storepb.CreateTimelineResponse\x12Q\n" +
+	"\x0eDeleteTimeline\x12\x1e.storepb.DeleteTimelineRequest\x1a\x1f.storepb.DeleteTimelineResponse\x12T\n" +
+	"\x0fMigrateTimeline\x12\x1f.storepb.MigrateTimelineRequest\x1a .storepb.MigrateTimelineResponse\x12E\n" +
+	"\n" +
+	"AddMessage\x12\x1a.storepb.AddMessageRequest\x1a\x1b.storepb.AddMessageResponse\x12H\n" +
+	"\vGetMessages\x12\x1b.storepb.GetMessagesRequest\x1a\x1c.storepb.GetMessagesResponse\x12H\n" +
+	"\vEditMessage\x12\x1b.storepb.EditMessageRequest\x1a\x1c.storepb.EditMessageResponse\x12N\n" +
+	"\rDeleteMessage\x12\x1d.storepb.DeleteMessageRequest\x1a\x1e.storepb.DeleteMessageResponse\x12W\n" +
+	"\x10GetTimelineBlock\x12 .storepb.GetTimelineBlockRequest\x1a!.storepb.GetTimelineBlockResponse\x12Z\n" +
+	"\x14StreamTimelineBlocks\x12$.storepb.StreamTimelineBlocksRequest\x1a\x1a.storepb.TimelineBlockData0\x01\x12[\n" +
+	"\x14ImportTimelineBlocks\x12\x1a.storepb.TimelineBlockData\x1a%.storepb.ImportTimelineBlocksResponse(\x01\x12N\n" +
+	"\rGetStoreStats\x12\x1d.storepb.GetStoreStatsRequest\x1a\x1e.storepb.GetStoreStatsResponse\x12H\n" +
+	"\vHealthCheck\x12\x1b.storepb.HealthCheckRequest\x1a\x1c.storepb.HealthCheckResponse\x12O\n" +
+	"\x12PrepareTransaction\x12\x1b.storepb.TransactionRequest\x1a\x1c.storepb.TransactionResponse\x12N\n" +
+	"\x11CommitTransaction\x12\x1b.storepb.TransactionRequest\x1a\x1c.storepb.TransactionResponse\x12M\n" +
+	"\x10AbortTransaction\x12\x1b.storepb.TransactionRequest\x1a\x1c.storepb.TransactionResponseB\x19Z\x17imy/pkg/storage/storepbb\x06proto3"
+
+var (
+	file_store_proto_rawDescOnce sync.Once
+	file_store_proto_rawDescData []byte
+)
+
+func file_store_proto_rawDescGZIP() []byte {
+	file_store_proto_rawDescOnce.Do(func() {
+		file_store_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_store_proto_rawDesc), len(file_store_proto_rawDesc)))
+	})
+	return file_store_proto_rawDescData
+}
+
+var file_store_proto_msgTypes = make([]protoimpl.MessageInfo, 31)
+var file_store_proto_goTypes = []any{
+	(*Message)(nil),                      // 0: storepb.Message
+	(*TimelineBlock)(nil),                // 1: storepb.TimelineBlock
+	(*Timeline)(nil),                     // 2: storepb.Timeline
+	(*GetTimelineRequest)(nil),           // 3: storepb.GetTimelineRequest
+	(*GetTimelineResponse)(nil),          // 4: storepb.GetTimelineResponse
+	(*CreateTimelineRequest)(nil),        // 5: storepb.CreateTimelineRequest
+	(*CreateTimelineResponse)(nil),       // 6: storepb.CreateTimelineResponse
+	(*DeleteTimelineRequest)(nil),        // 7: storepb.DeleteTimelineRequest
+	(*DeleteTimelineResponse)(nil),       // 8: storepb.DeleteTimelineResponse
+	(*MigrateTimelineRequest)(nil),       // 9: storepb.MigrateTimelineRequest
+	(*MigrateTimelineResponse)(nil),      // 10: storepb.MigrateTimelineResponse
+	(*AddMessageRequest)(nil),            // 11: storepb.AddMessageRequest
+	(*AddMessageResponse)(nil),           // 12: storepb.AddMessageResponse
+	(*GetMessagesRequest)(nil),           // 13: storepb.GetMessagesRequest
+	(*GetMessagesResponse)(nil),          // 14: storepb.GetMessagesResponse
+	(*EditMessageRequest)(nil),           // 15: storepb.EditMessageRequest
+	(*EditMessageResponse)(nil),          // 16: storepb.EditMessageResponse
+	(*DeleteMessageRequest)(nil),         // 17: storepb.DeleteMessageRequest
+	(*DeleteMessageResponse)(nil),        // 18: storepb.DeleteMessageResponse
+	(*GetTimelineBlockRequest)(nil),      // 19: storepb.GetTimelineBlockRequest
+	(*GetTimelineBlockResponse)(nil),     // 20: storepb.GetTimelineBlockResponse
+	(*StreamTimelineBlocksRequest)(nil),  // 21: storepb.StreamTimelineBlocksRequest
+	(*TimelineBlockData)(nil),            // 22: storepb.TimelineBlockData
+	(*ImportTimelineBlocksResponse)(nil), // 23: storepb.ImportTimelineBlocksResponse
+	(*GetStoreStatsRequest)(nil),         // 24: storepb.GetStoreStatsRequest
+	(*GetStoreStatsResponse)(nil),        // 25: storepb.GetStoreStatsResponse
+	(*HealthCheckRequest)(nil),           // 26: storepb.HealthCheckRequest
+	(*HealthCheckResponse)(nil),          // 27: storepb.HealthCheckResponse
+	(*TransactionRequest)(nil),           // 28: storepb.TransactionRequest
+	(*TransactionResponse)(nil),          // 29: storepb.TransactionResponse
+	nil,                                  // 30: storepb.CreateTimelineRequest.MetadataEntry
+}
+var file_store_proto_depIdxs = []int32{
+	1,  // 0: storepb.Timeline.blocks:type_name -> storepb.TimelineBlock
+	2,  // 1: storepb.GetTimelineResponse.timeline:type_name -> storepb.Timeline
+	30, // 2: storepb.CreateTimelineRequest.metadata:type_name -> storepb.CreateTimelineRequest.MetadataEntry
+	2,  // 3: storepb.CreateTimelineResponse.timeline:type_name -> storepb.Timeline
+	0,  // 4: storepb.AddMessageRequest.message:type_name -> storepb.Message
+	0,  // 5: storepb.GetMessagesResponse.messages:type_name -> storepb.Message
+	0,  // 6: storepb.EditMessageResponse.message:type_name -> storepb.Message
+	0,  // 7: storepb.DeleteMessageResponse.message:type_name -> storepb.Message
+	1,  // 8: storepb.GetTimelineBlockResponse.block:type_name -> storepb.TimelineBlock
+	1,  // 9: storepb.TimelineBlockData.block:type_name -> storepb.TimelineBlock
+	0,  // 10: storepb.TimelineBlockData.messages:type_name -> storepb.Message
+	3,  // 11: storepb.StoreRPC.GetTimeline:input_type -> storepb.GetTimelineRequest
+	5,  // 12: storepb.StoreRPC.CreateTimeline:input_type -> storepb.CreateTimelineRequest
+	7,  // 13: storepb.StoreRPC.DeleteTimeline:input_type -> storepb.DeleteTimelineRequest
+	9,  // 14: storepb.StoreRPC.MigrateTimeline:input_type -> storepb.MigrateTimelineRequest
+	11, // 15: storepb.StoreRPC.AddMessage:input_type -> storepb.AddMessageRequest
+	13, // 16: storepb.StoreRPC.GetMessages:input_type -> storepb.GetMessagesRequest
+	15, // 17: storepb.StoreRPC.EditMessage:input_type -> storepb.EditMessageRequest
+	17, // 18: storepb.StoreRPC.DeleteMessage:input_type -> storepb.DeleteMessageRequest
+	19, // 19: storepb.StoreRPC.GetTimelineBlock:input_type -> storepb.GetTimelineBlockRequest
+	21, // 20: storepb.StoreRPC.StreamTimelineBlocks:input_type -> storepb.StreamTimelineBlocksRequest
+	22, // 21: storepb.StoreRPC.ImportTimelineBlocks:input_type -> storepb.TimelineBlockData
+	24, // 22: storepb.StoreRPC.GetStoreStats:input_type -> storepb.GetStoreStatsRequest
+	26, // 23: storepb.StoreRPC.HealthCheck:input_type -> storepb.HealthCheckRequest
+	28, // 24: storepb.StoreRPC.PrepareTransaction:input_type -> storepb.TransactionRequest
+	28, // 25: storepb.StoreRPC.CommitTransaction:input_type -> storepb.TransactionRequest
+	28, // 26: storepb.StoreRPC.AbortTransaction:input_type -> storepb.TransactionRequest
+	4,  // 27: storepb.StoreRPC.GetTimeline:output_type -> storepb.GetTimelineResponse
+	6,  // 28: storepb.StoreRPC.CreateTimeline:output_type -> storepb.CreateTimelineResponse
+	8,  // 29: storepb.StoreRPC.DeleteTimeline:output_type -> storepb.DeleteTimelineResponse
+	10, // 30: storepb.StoreRPC.MigrateTimeline:output_type -> storepb.MigrateTimelineResponse
+	12, // 31: storepb.StoreRPC.AddMessage:output_type -> storepb.AddMessageResponse
+	14, // 32: storepb.StoreRPC.GetMessages:output_type -> storepb.GetMessagesResponse
+	16, // 33: storepb.StoreRPC.EditMessage:output_type -> storepb.EditMessageResponse
+	18, // 34: storepb.StoreRPC.DeleteMessage:output_type -> storepb.DeleteMessageResponse
+	20, // 35: storepb.StoreRPC.GetTimelineBlock:output_type -> storepb.GetTimelineBlockResponse
+	22, // 36: storepb.StoreRPC.StreamTimelineBlocks:output_type -> storepb.TimelineBlockData
+	23, // 37: storepb.StoreRPC.ImportTimelineBlocks:output_type -> storepb.ImportTimelineBlocksResponse
+	25, // 38: storepb.StoreRPC.GetStoreStats:output_type -> storepb.GetStoreStatsResponse
+	27, // 39: storepb.StoreRPC.HealthCheck:output_type -> storepb.HealthCheckResponse
+	29, // 40: storepb.StoreRPC.PrepareTransaction:output_type -> storepb.TransactionResponse
+	29, // 41: storepb.StoreRPC.CommitTransaction:output_type -> storepb.TransactionResponse
+	29, // 42: storepb.StoreRPC.AbortTransaction:output_type -> storepb.TransactionResponse
+	27, // [27:43] is the sub-list for method output_type
+	11, // [11:27] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_store_proto_init() }
+func file_store_proto_init() {
+	if File_store_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_store_proto_rawDesc), len(file_store_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   31,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_store_proto_goTypes,
+		DependencyIndexes: file_store_proto_depIdxs,
+		MessageInfos:      file_store_proto_msgTypes,
+	}.Build()
+	File_store_proto = out.File
+	file_store_proto_goTypes = nil
+	file_store_proto_depIdxs = nil
+}
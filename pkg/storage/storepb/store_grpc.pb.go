@@ -0,0 +1,708 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: store.proto
+
+package storepb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	StoreRPC_GetTimeline_FullMethodName          = "/storepb.StoreRPC/GetTimeline"
+	StoreRPC_CreateTimeline_FullMethodName       = "/storepb.StoreRPC/CreateTimeline"
+	StoreRPC_DeleteTimeline_FullMethodName       = "/storepb.StoreRPC/DeleteTimeline"
+	StoreRPC_MigrateTimeline_FullMethodName      = "/storepb.StoreRPC/MigrateTimeline"
+	StoreRPC_AddMessage_FullMethodName           = "/storepb.StoreRPC/AddMessage"
+	StoreRPC_GetMessages_FullMethodName          = "/storepb.StoreRPC/GetMessages"
+	StoreRPC_EditMessage_FullMethodName          = "/storepb.StoreRPC/EditMessage"
+	StoreRPC_DeleteMessage_FullMethodName        = "/storepb.StoreRPC/DeleteMessage"
+	StoreRPC_GetTimelineBlock_FullMethodName     = "/storepb.StoreRPC/GetTimelineBlock"
+	StoreRPC_StreamTimelineBlocks_FullMethodName = "/storepb.StoreRPC/StreamTimelineBlocks"
+	StoreRPC_ImportTimelineBlocks_FullMethodName = "/storepb.StoreRPC/ImportTimelineBlocks"
+	StoreRPC_GetStoreStats_FullMethodName        = "/storepb.StoreRPC/GetStoreStats"
+	StoreRPC_HealthCheck_FullMethodName          = "/storepb.StoreRPC/HealthCheck"
+	StoreRPC_PrepareTransaction_FullMethodName   = "/storepb.StoreRPC/PrepareTransaction"
+	StoreRPC_CommitTransaction_FullMethodName    = "/storepb.StoreRPC/CommitTransaction"
+	StoreRPC_AbortTransaction_FullMethodName     = "/storepb.StoreRPC/AbortTransaction"
+)
+
+// StoreRPCClient is the client API for StoreRPC service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// StoreRPC Store之间通信的gRPC服务，与HTTP JSON RPC方法一一对应
+type StoreRPCClient interface {
+	// Timeline操作
+	GetTimeline(ctx context.Context, in *GetTimelineRequest, opts ...grpc.CallOption) (*GetTimelineResponse, error)
+	CreateTimeline(ctx context.Context, in *CreateTimelineRequest, opts ...grpc.CallOption) (*CreateTimelineResponse, error)
+	DeleteTimeline(ctx context.Context, in *DeleteTimelineRequest, opts ...grpc.CallOption) (*DeleteTimelineResponse, error)
+	MigrateTimeline(ctx context.Context, in *MigrateTimelineRequest, opts ...grpc.CallOption) (*MigrateTimelineResponse, error)
+	// 消息操作
+	AddMessage(ctx context.Context, in *AddMessageRequest, opts ...grpc.CallOption) (*AddMessageResponse, error)
+	GetMessages(ctx context.Context, in *GetMessagesRequest, opts ...grpc.CallOption) (*GetMessagesResponse, error)
+	// EditMessage/DeleteMessage 追加引用原消息的编辑记录或删除墓碑
+	EditMessage(ctx context.Context, in *EditMessageRequest, opts ...grpc.CallOption) (*EditMessageResponse, error)
+	DeleteMessage(ctx context.Context, in *DeleteMessageRequest, opts ...grpc.CallOption) (*DeleteMessageResponse, error)
+	// 块操作
+	GetTimelineBlock(ctx context.Context, in *GetTimelineBlockRequest, opts ...grpc.CallOption) (*GetTimelineBlockResponse, error)
+	// StreamTimelineBlocks 流式导出Timeline的所有块，用于迁移时的块传输
+	StreamTimelineBlocks(ctx context.Context, in *StreamTimelineBlocksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TimelineBlockData], error)
+	// ImportTimelineBlocks 流式接收迁移来的块，保留原有块ID与SeqID
+	ImportTimelineBlocks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TimelineBlockData, ImportTimelineBlocksResponse], error)
+	// Store状态
+	GetStoreStats(ctx context.Context, in *GetStoreStatsRequest, opts ...grpc.CallOption) (*GetStoreStatsResponse, error)
+	HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error)
+	// 事务参与者：两阶段提交的准备、提交与回滚，重复调用是幂等的
+	PrepareTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+	CommitTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+	AbortTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error)
+}
+
+type storeRPCClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStoreRPCClient(cc grpc.ClientConnInterface) StoreRPCClient {
+	return &storeRPCClient{cc}
+}
+
+func (c *storeRPCClient) GetTimeline(ctx context.Context, in *GetTimelineRequest, opts ...grpc.CallOption) (*GetTimelineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetTimelineResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_GetTimeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) CreateTimeline(ctx context.Context, in *CreateTimelineRequest, opts ...grpc.CallOption) (*CreateTimelineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateTimelineResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_CreateTimeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) DeleteTimeline(ctx context.Context, in *DeleteTimelineRequest, opts ...grpc.CallOption) (*DeleteTimelineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteTimelineResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_DeleteTimeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) MigrateTimeline(ctx context.Context, in *MigrateTimelineRequest, opts ...grpc.CallOption) (*MigrateTimelineResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MigrateTimelineResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_MigrateTimeline_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) AddMessage(ctx context.Context, in *AddMessageRequest, opts ...grpc.CallOption) (*AddMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AddMessageResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_AddMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) GetMessages(ctx context.Context, in *GetMessagesRequest, opts ...grpc.CallOption) (*GetMessagesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetMessagesResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_GetMessages_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) EditMessage(ctx context.Context, in *EditMessageRequest, opts ...grpc.CallOption) (*EditMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EditMessageResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_EditMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) DeleteMessage(ctx context.Context, in *DeleteMessageRequest, opts ...grpc.CallOption) (*DeleteMessageResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteMessageResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_DeleteMessage_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) GetTimelineBlock(ctx context.Context, in *GetTimelineBlockRequest, opts ...grpc.CallOption) (*GetTimelineBlockResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetTimelineBlockResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_GetTimelineBlock_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) StreamTimelineBlocks(ctx context.Context, in *StreamTimelineBlocksRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TimelineBlockData], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StoreRPC_ServiceDesc.Streams[0], StoreRPC_StreamTimelineBlocks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StreamTimelineBlocksRequest, TimelineBlockData]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StoreRPC_StreamTimelineBlocksClient = grpc.ServerStreamingClient[TimelineBlockData]
+
+func (c *storeRPCClient) ImportTimelineBlocks(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[TimelineBlockData, ImportTimelineBlocksResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &StoreRPC_ServiceDesc.Streams[1], StoreRPC_ImportTimelineBlocks_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[TimelineBlockData, ImportTimelineBlocksResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StoreRPC_ImportTimelineBlocksClient = grpc.ClientStreamingClient[TimelineBlockData, ImportTimelineBlocksResponse]
+
+func (c *storeRPCClient) GetStoreStats(ctx context.Context, in *GetStoreStatsRequest, opts ...grpc.CallOption) (*GetStoreStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetStoreStatsResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_GetStoreStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) HealthCheck(ctx context.Context, in *HealthCheckRequest, opts ...grpc.CallOption) (*HealthCheckResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(HealthCheckResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_HealthCheck_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) PrepareTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_PrepareTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) CommitTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_CommitTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *storeRPCClient) AbortTransaction(ctx context.Context, in *TransactionRequest, opts ...grpc.CallOption) (*TransactionResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionResponse)
+	err := c.cc.Invoke(ctx, StoreRPC_AbortTransaction_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// StoreRPCServer is the server API for StoreRPC service.
+// All implementations must embed UnimplementedStoreRPCServer
+// for forward compatibility.
+//
+// StoreRPC Store之间通信的gRPC服务，与HTTP JSON RPC方法一一对应
+type StoreRPCServer interface {
+	// Timeline操作
+	GetTimeline(context.Context, *GetTimelineRequest) (*GetTimelineResponse, error)
+	CreateTimeline(context.Context, *CreateTimelineRequest) (*CreateTimelineResponse, error)
+	DeleteTimeline(context.Context, *DeleteTimelineRequest) (*DeleteTimelineResponse, error)
+	MigrateTimeline(context.Context, *MigrateTimelineRequest) (*MigrateTimelineResponse, error)
+	// 消息操作
+	AddMessage(context.Context, *AddMessageRequest) (*AddMessageResponse, error)
+	GetMessages(context.Context, *GetMessagesRequest) (*GetMessagesResponse, error)
+	// EditMessage/DeleteMessage 追加引用原消息的编辑记录或删除墓碑
+	EditMessage(context.Context, *EditMessageRequest) (*EditMessageResponse, error)
+	DeleteMessage(context.Context, *DeleteMessageRequest) (*DeleteMessageResponse, error)
+	// 块操作
+	GetTimelineBlock(context.Context, *GetTimelineBlockRequest) (*GetTimelineBlockResponse, error)
+	// StreamTimelineBlocks 流式导出Timeline的所有块，用于迁移时的块传输
+	StreamTimelineBlocks(*StreamTimelineBlocksRequest, grpc.ServerStreamingServer[TimelineBlockData]) error
+	// ImportTimelineBlocks 流式接收迁移来的块，保留原有块ID与SeqID
+	ImportTimelineBlocks(grpc.ClientStreamingServer[TimelineBlockData, ImportTimelineBlocksResponse]) error
+	// Store状态
+	GetStoreStats(context.Context, *GetStoreStatsRequest) (*GetStoreStatsResponse, error)
+	HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error)
+	// 事务参与者：两阶段提交的准备、提交与回滚，重复调用是幂等的
+	PrepareTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error)
+	CommitTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error)
+	AbortTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error)
+	mustEmbedUnimplementedStoreRPCServer()
+}
+
+// UnimplementedStoreRPCServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStoreRPCServer struct{}
+
+func (UnimplementedStoreRPCServer) GetTimeline(context.Context, *GetTimelineRequest) (*GetTimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTimeline not implemented")
+}
+func (UnimplementedStoreRPCServer) CreateTimeline(context.Context, *CreateTimelineRequest) (*CreateTimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTimeline not implemented")
+}
+func (UnimplementedStoreRPCServer) DeleteTimeline(context.Context, *DeleteTimelineRequest) (*DeleteTimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteTimeline not implemented")
+}
+func (UnimplementedStoreRPCServer) MigrateTimeline(context.Context, *MigrateTimelineRequest) (*MigrateTimelineResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method MigrateTimeline not implemented")
+}
+func (UnimplementedStoreRPCServer) AddMessage(context.Context, *AddMessageRequest) (*AddMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddMessage not implemented")
+}
+func (UnimplementedStoreRPCServer) GetMessages(context.Context, *GetMessagesRequest) (*GetMessagesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMessages not implemented")
+}
+func (UnimplementedStoreRPCServer) EditMessage(context.Context, *EditMessageRequest) (*EditMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EditMessage not implemented")
+}
+func (UnimplementedStoreRPCServer) DeleteMessage(context.Context, *DeleteMessageRequest) (*DeleteMessageResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteMessage not implemented")
+}
+func (UnimplementedStoreRPCServer) GetTimelineBlock(context.Context, *GetTimelineBlockRequest) (*GetTimelineBlockResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetTimelineBlock not implemented")
+}
+func (UnimplementedStoreRPCServer) StreamTimelineBlocks(*StreamTimelineBlocksRequest, grpc.ServerStreamingServer[TimelineBlockData]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamTimelineBlocks not implemented")
+}
+func (UnimplementedStoreRPCServer) ImportTimelineBlocks(grpc.ClientStreamingServer[TimelineBlockData, ImportTimelineBlocksResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method ImportTimelineBlocks not implemented")
+}
+func (UnimplementedStoreRPCServer) GetStoreStats(context.Context, *GetStoreStatsRequest) (*GetStoreStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStoreStats not implemented")
+}
+func (UnimplementedStoreRPCServer) HealthCheck(context.Context, *HealthCheckRequest) (*HealthCheckResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method HealthCheck not implemented")
+}
+func (UnimplementedStoreRPCServer) PrepareTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PrepareTransaction not implemented")
+}
+func (UnimplementedStoreRPCServer) CommitTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CommitTransaction not implemented")
+}
+func (UnimplementedStoreRPCServer) AbortTransaction(context.Context, *TransactionRequest) (*TransactionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AbortTransaction not implemented")
+}
+func (UnimplementedStoreRPCServer) mustEmbedUnimplementedStoreRPCServer() {}
+func (UnimplementedStoreRPCServer) testEmbeddedByValue()                  {}
+
+// UnsafeStoreRPCServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StoreRPCServer will
+// result in compilation errors.
+type UnsafeStoreRPCServer interface {
+	mustEmbedUnimplementedStoreRPCServer()
+}
+
+func RegisterStoreRPCServer(s grpc.ServiceRegistrar, srv StoreRPCServer) {
+	// If the following call pancis, it indicates UnimplementedStoreRPCServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&StoreRPC_ServiceDesc, srv)
+}
+
+func _StoreRPC_GetTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).GetTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_GetTimeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).GetTimeline(ctx, req.(*GetTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_CreateTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).CreateTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_CreateTimeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).CreateTimeline(ctx, req.(*CreateTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_DeleteTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).DeleteTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_DeleteTimeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).DeleteTimeline(ctx, req.(*DeleteTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_MigrateTimeline_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MigrateTimelineRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).MigrateTimeline(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_MigrateTimeline_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).MigrateTimeline(ctx, req.(*MigrateTimelineRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_AddMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).AddMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_AddMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).AddMessage(ctx, req.(*AddMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_GetMessages_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetMessagesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).GetMessages(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_GetMessages_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).GetMessages(ctx, req.(*GetMessagesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_EditMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EditMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).EditMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_EditMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).EditMessage(ctx, req.(*EditMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_DeleteMessage_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteMessageRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).DeleteMessage(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_DeleteMessage_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).DeleteMessage(ctx, req.(*DeleteMessageRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_GetTimelineBlock_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTimelineBlockRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).GetTimelineBlock(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_GetTimelineBlock_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).GetTimelineBlock(ctx, req.(*GetTimelineBlockRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_StreamTimelineBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamTimelineBlocksRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StoreRPCServer).StreamTimelineBlocks(m, &grpc.GenericServerStream[StreamTimelineBlocksRequest, TimelineBlockData]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StoreRPC_StreamTimelineBlocksServer = grpc.ServerStreamingServer[TimelineBlockData]
+
+func _StoreRPC_ImportTimelineBlocks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(StoreRPCServer).ImportTimelineBlocks(&grpc.GenericServerStream[TimelineBlockData, ImportTimelineBlocksResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type StoreRPC_ImportTimelineBlocksServer = grpc.ClientStreamingServer[TimelineBlockData, ImportTimelineBlocksResponse]
+
+func _StoreRPC_GetStoreStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStoreStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).GetStoreStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_GetStoreStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).GetStoreStats(ctx, req.(*GetStoreStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_HealthCheck_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(HealthCheckRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).HealthCheck(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_HealthCheck_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).HealthCheck(ctx, req.(*HealthCheckRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_PrepareTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).PrepareTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_PrepareTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).PrepareTransaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_CommitTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).CommitTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_CommitTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).CommitTransaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _StoreRPC_AbortTransaction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(StoreRPCServer).AbortTransaction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: StoreRPC_AbortTransaction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(StoreRPCServer).AbortTransaction(ctx, req.(*TransactionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// StoreRPC_ServiceDesc is the grpc.ServiceDesc for StoreRPC service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var StoreRPC_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "storepb.StoreRPC",
+	HandlerType: (*StoreRPCServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetTimeline",
+			Handler:    _StoreRPC_GetTimeline_Handler,
+		},
+		{
+			MethodName: "CreateTimeline",
+			Handler:    _StoreRPC_CreateTimeline_Handler,
+		},
+		{
+			MethodName: "DeleteTimeline",
+			Handler:    _StoreRPC_DeleteTimeline_Handler,
+		},
+		{
+			MethodName: "MigrateTimeline",
+			Handler:    _StoreRPC_MigrateTimeline_Handler,
+		},
+		{
+			MethodName: "AddMessage",
+			Handler:    _StoreRPC_AddMessage_Handler,
+		},
+		{
+			MethodName: "GetMessages",
+			Handler:    _StoreRPC_GetMessages_Handler,
+		},
+		{
+			MethodName: "EditMessage",
+			Handler:    _StoreRPC_EditMessage_Handler,
+		},
+		{
+			MethodName: "DeleteMessage",
+			Handler:    _StoreRPC_DeleteMessage_Handler,
+		},
+		{
+			MethodName: "GetTimelineBlock",
+			Handler:    _StoreRPC_GetTimelineBlock_Handler,
+		},
+		{
+			MethodName: "GetStoreStats",
+			Handler:    _StoreRPC_GetStoreStats_Handler,
+		},
+		{
+			MethodName: "HealthCheck",
+			Handler:    _StoreRPC_HealthCheck_Handler,
+		},
+		{
+			MethodName: "PrepareTransaction",
+			Handler:    _StoreRPC_PrepareTransaction_Handler,
+		},
+		{
+			MethodName: "CommitTransaction",
+			Handler:    _StoreRPC_CommitTransaction_Handler,
+		},
+		{
+			MethodName: "AbortTransaction",
+			Handler:    _StoreRPC_AbortTransaction_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamTimelineBlocks",
+			Handler:       _StoreRPC_StreamTimelineBlocks_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportTimelineBlocks",
+			Handler:       _StoreRPC_ImportTimelineBlocks_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "store.proto",
+}
@@ -0,0 +1,519 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 多租户命名空间
+// Timeline键以"租户ID::"为前缀区分租户，不带前缀的键属于默认租户，已有数据无需迁移。
+// Store按租户统计已用容量与写入速率并执行配额，一条消息的会话与用户Timeline必须属于同一租户；
+// TenantGlobalIndex把全局索引的读写限定在单个租户内；路由器与分片推荐按Store元数据中的tenants
+// 把租户的Timeline只放到其专属Store，没有专属Store的租户使用未声明tenants的共享Store
+
+const (
+	// DefaultTenant 默认租户，其Timeline键不带前缀
+	DefaultTenant = ""
+	// TenantSeparator 租户ID与Timeline键之间的分隔符
+	TenantSeparator = "::"
+	// StoreMetadataTenants StoreInfo.Metadata中的专属租户列表，逗号分隔
+	StoreMetadataTenants = "tenants"
+
+	maxTenantIDLength = 64
+)
+
+var (
+	// ErrTenantQuotaExceeded 租户的容量或写入速率超过配额
+	ErrTenantQuotaExceeded = errors.New("tenant quota exceeded")
+	// ErrTenantMismatch 一次操作涉及的Timeline不属于同一租户
+	ErrTenantMismatch = errors.New("timelines belong to different tenants")
+)
+
+// TenantKey 生成租户内的Timeline键，默认租户返回原键
+func TenantKey(tenant, key string) string {
+	if tenant == DefaultTenant {
+		return key
+	}
+	return tenant + TenantSeparator + key
+}
+
+// SplitTenantKey 拆分出Timeline键的租户与租户内的键
+func SplitTenantKey(key string) (tenant, local string) {
+	if i := strings.Index(key, TenantSeparator); i >= 0 {
+		return key[:i], key[i+len(TenantSeparator):]
+	}
+	return DefaultTenant, key
+}
+
+// TenantOf 获取Timeline键所属的租户
+func TenantOf(key string) string {
+	tenant, _ := SplitTenantKey(key)
+	return tenant
+}
+
+// ValidateTenantID 校验租户ID，只允许小写字母、数字、下划线和连字符
+func ValidateTenantID(tenant string) error {
+	if tenant == "" || len(tenant) > maxTenantIDLength {
+		return fmt.Errorf("tenant id must be 1-%d characters", maxTenantIDLength)
+	}
+	for _, c := range tenant {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '_' && c != '-' {
+			return fmt.Errorf("invalid character %q in tenant id %q", c, tenant)
+		}
+	}
+	return nil
+}
+
+// TenantQuota 租户配额，零值表示不限制
+type TenantQuota struct {
+	MaxBytes             int64   `json:"max_bytes"`               // 租户在本Store上最多占用的字节数
+	MaxMessagesPerSecond float64 `json:"max_messages_per_second"` // 每秒最多接受的写入数
+	Burst                int     `json:"burst,omitempty"`         // 允许的突发写入数，默认为一秒的量
+}
+
+// burst 令牌桶容量
+func (q TenantQuota) burst() float64 {
+	if q.Burst > 0 {
+		return float64(q.Burst)
+	}
+	if q.MaxMessagesPerSecond < 1 {
+		return 1
+	}
+	return q.MaxMessagesPerSecond
+}
+
+// TenantMetrics 租户在本Store上的统计
+type TenantMetrics struct {
+	Tenant           string      `json:"tenant"`
+	Quota            TenantQuota `json:"quota"`
+	Timelines        int         `json:"timelines"`         // 已加载的Timeline数
+	UsedBytes        int64       `json:"used_bytes"`        // 已加载Timeline占用的字节数
+	Messages         int64       `json:"messages"`          // 接受的写入数，包括复制写入
+	RejectedCapacity int64       `json:"rejected_capacity"` // 因容量配额拒绝的写入数
+	RejectedRate     int64       `json:"rejected_rate"`     // 因速率配额拒绝的写入数
+}
+
+// tenantState 单个租户的配额与统计
+type tenantState struct {
+	quota      TenantQuota
+	usedBytes  int64
+	usageStale bool // 删除或清理过数据，下次检查容量前重新统计
+	tokens     float64
+	refilledAt time.Time
+	metrics    TenantMetrics
+}
+
+// tenantTable Store内所有租户的状态
+type tenantTable struct {
+	mu     sync.Mutex
+	states map[string]*tenantState
+	now    func() time.Time
+}
+
+func newTenantTable() *tenantTable {
+	return &tenantTable{states: make(map[string]*tenantState), now: time.Now}
+}
+
+// state 获取租户状态，调用方持有mu
+func (t *tenantTable) state(tenant string) *tenantState {
+	st, exists := t.states[tenant]
+	if !exists {
+		st = &tenantState{usageStale: true, metrics: TenantMetrics{Tenant: tenant}}
+		t.states[tenant] = st
+	}
+	return st
+}
+
+// invalidate 租户的数据被删除后标记用量需要重新统计
+func (t *tenantTable) invalidate(tenant string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if st, exists := t.states[tenant]; exists {
+		st.usageStale = true
+	}
+}
+
+// SetTenantQuota 设置租户在本Store上的配额
+func (s *Store) SetTenantQuota(tenant string, quota TenantQuota) error {
+	if tenant != DefaultTenant {
+		if err := ValidateTenantID(tenant); err != nil {
+			return err
+		}
+	}
+	if quota.MaxBytes < 0 || quota.MaxMessagesPerSecond < 0 || quota.Burst < 0 {
+		return fmt.Errorf("tenant quota must not be negative")
+	}
+	s.tenants.mu.Lock()
+	defer s.tenants.mu.Unlock()
+	st := s.tenants.state(tenant)
+	st.quota = quota
+	st.tokens = quota.burst()
+	st.refilledAt = s.tenants.now()
+	return nil
+}
+
+// GetTenantMetrics 重新统计并返回租户在本Store上的用量
+func (s *Store) GetTenantMetrics(tenant string) TenantMetrics {
+	usage := s.tenantUsage()[tenant]
+	s.tenants.mu.Lock()
+	defer s.tenants.mu.Unlock()
+	st := s.tenants.state(tenant)
+	st.usedBytes = usage.bytes
+	st.usageStale = false
+	metrics := st.metrics
+	metrics.Quota = st.quota
+	metrics.Timelines = usage.timelines
+	metrics.UsedBytes = usage.bytes
+	return metrics
+}
+
+// ListTenantMetrics 返回有配额或有已加载Timeline的所有租户的统计，按租户ID排序
+func (s *Store) ListTenantMetrics() []TenantMetrics {
+	usage := s.tenantUsage()
+	s.tenants.mu.Lock()
+	defer s.tenants.mu.Unlock()
+	for tenant := range usage {
+		s.tenants.state(tenant)
+	}
+	list := make([]TenantMetrics, 0, len(s.tenants.states))
+	for tenant, st := range s.tenants.states {
+		st.usedBytes = usage[tenant].bytes
+		st.usageStale = false
+		metrics := st.metrics
+		metrics.Quota = st.quota
+		metrics.Timelines = usage[tenant].timelines
+		metrics.UsedBytes = usage[tenant].bytes
+		list = append(list, metrics)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Tenant < list[j].Tenant })
+	return list
+}
+
+// tenantOfMessage 校验消息写入的会话与用户Timeline属于同一租户，返回该租户
+func tenantOfMessage(convID string, userIDs []string) (string, error) {
+	tenant := TenantOf(convID)
+	for _, userID := range userIDs {
+		if TenantOf(userID) != tenant {
+			return "", fmt.Errorf("%w: conversation %s, user %s", ErrTenantMismatch, convID, userID)
+		}
+	}
+	return tenant, nil
+}
+
+// admitTenantWrite 按租户配额接受一次写入，incoming为预计新增的字节数
+// enforce为false时（复制写入）只计入用量不拒绝，副本必须与主Store保持一致。
+// 接受后预占容量，写入失败时调用返回的函数归还
+func (s *Store) admitTenantWrite(tenant string, incoming int64, enforce bool) (release func(), err error) {
+	s.tenants.mu.Lock()
+	st := s.tenants.state(tenant)
+	stale := st.usageStale && st.quota.MaxBytes > 0
+	s.tenants.mu.Unlock()
+
+	// 统计用量要获取Timeline锁，不能持有tenants.mu
+	var used int64
+	if stale {
+		used = s.tenantUsage()[tenant].bytes
+	}
+
+	s.tenants.mu.Lock()
+	defer s.tenants.mu.Unlock()
+	if stale && st.usageStale {
+		st.usedBytes = used
+		st.usageStale = false
+	}
+
+	if enforce {
+		quota := st.quota
+		if quota.MaxBytes > 0 && st.usedBytes+incoming > quota.MaxBytes {
+			st.metrics.RejectedCapacity++
+			return nil, fmt.Errorf("%w: tenant %q uses %d of %d bytes", ErrTenantQuotaExceeded, tenant, st.usedBytes, quota.MaxBytes)
+		}
+		if quota.MaxMessagesPerSecond > 0 {
+			now := s.tenants.now()
+			st.tokens += now.Sub(st.refilledAt).Seconds() * quota.MaxMessagesPerSecond
+			if limit := quota.burst(); st.tokens > limit {
+				st.tokens = limit
+			}
+			st.refilledAt = now
+			if st.tokens < 1 {
+				st.metrics.RejectedRate++
+				return nil, fmt.Errorf("%w: tenant %q exceeds %.2f messages per second", ErrTenantQuotaExceeded, tenant, quota.MaxMessagesPerSecond)
+			}
+			st.tokens--
+		}
+	}
+
+	st.usedBytes += incoming
+	st.metrics.Messages++
+	return func() {
+		s.tenants.mu.Lock()
+		defer s.tenants.mu.Unlock()
+		st.usedBytes -= incoming
+		st.metrics.Messages--
+	}, nil
+}
+
+// tenantUsageStats 租户已加载Timeline的用量
+type tenantUsageStats struct {
+	timelines int
+	bytes     int64
+}
+
+// tenantUsage 按租户统计已加载的Timeline
+// 已落盘的块按段文件中的记录大小计算，未落盘的块按消息估算
+func (s *Store) tenantUsage() map[string]tenantUsageStats {
+	usage := make(map[string]tenantUsageStats)
+	for _, tl := range s.ListTimelines() {
+		tenant := TenantOf(tl.ID)
+		stats := usage[tenant]
+		stats.timelines++
+
+		tl.mu.RLock()
+		for _, block := range tl.Blocks {
+			if location, persisted := s.segments.Location(block.BlockID); persisted {
+				stats.bytes += location.Length
+				continue
+			}
+			if stub, tiered := s.tieredStub(block.BlockID); tiered {
+				stats.bytes += stub.Length
+				continue
+			}
+			block.mu.RLock()
+			for _, msg := range block.Messages {
+				stats.bytes += estimateMessageBytes(msg, 1)
+			}
+			block.mu.RUnlock()
+		}
+		tl.mu.RUnlock()
+		usage[tenant] = stats
+	}
+	return usage
+}
+
+// TenantGlobalIndex 限定在单个租户内的全局索引
+// 调用方使用租户内的Timeline键，写入底层索引时加上租户前缀，返回结果时去掉；
+// 键中不能包含TenantSeparator，列出Store上的Timeline时只返回本租户的。
+// GetStoreLoad返回Store的整体负载，供放置决策使用
+type TenantGlobalIndex struct {
+	tenant string
+	index  GlobalIndexManager
+}
+
+// NewTenantGlobalIndex 创建租户的全局索引视图
+func NewTenantGlobalIndex(index GlobalIndexManager, tenant string) (*TenantGlobalIndex, error) {
+	if tenant != DefaultTenant {
+		if err := ValidateTenantID(tenant); err != nil {
+			return nil, err
+		}
+	}
+	return &TenantGlobalIndex{tenant: tenant, index: index}, nil
+}
+
+// Tenant 返回索引所属的租户
+func (t *TenantGlobalIndex) Tenant() string {
+	return t.tenant
+}
+
+// scope 把租户内的键转换为底层索引中的键
+func (t *TenantGlobalIndex) scope(timelineKey string) (string, error) {
+	if strings.Contains(timelineKey, TenantSeparator) {
+		return "", fmt.Errorf("%w: key %q must not contain %q", ErrTenantMismatch, timelineKey, TenantSeparator)
+	}
+	return TenantKey(t.tenant, timelineKey), nil
+}
+
+// unscope 去掉底层索引键的租户前缀
+func (t *TenantGlobalIndex) unscope(timelineKey string) string {
+	_, local := SplitTenantKey(timelineKey)
+	return local
+}
+
+// scopedIndex 复制索引条目并替换Timeline键
+func (t *TenantGlobalIndex) scopedIndex(index *GlobalStoreIndex, timelineKey string) *GlobalStoreIndex {
+	if index == nil {
+		return nil
+	}
+	indexCopy := *index
+	indexCopy.TimelineKey = timelineKey
+	return &indexCopy
+}
+
+func (t *TenantGlobalIndex) AddIndex(ctx context.Context, index *GlobalStoreIndex) error {
+	key, err := t.scope(index.TimelineKey)
+	if err != nil {
+		return err
+	}
+	return t.index.AddIndex(ctx, t.scopedIndex(index, key))
+}
+
+func (t *TenantGlobalIndex) RemoveIndex(ctx context.Context, timelineKey, blockID string) error {
+	key, err := t.scope(timelineKey)
+	if err != nil {
+		return err
+	}
+	return t.index.RemoveIndex(ctx, key, blockID)
+}
+
+func (t *TenantGlobalIndex) GetTimelineLocation(ctx context.Context, timelineKey string) (*TimelineLocation, error) {
+	key, err := t.scope(timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	location, err := t.index.GetTimelineLocation(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	locationCopy := *location
+	locationCopy.TimelineKey = timelineKey
+	locationCopy.Blocks = make([]*GlobalStoreIndex, len(location.Blocks))
+	for i, block := range location.Blocks {
+		locationCopy.Blocks[i] = t.scopedIndex(block, timelineKey)
+	}
+	locationCopy.StoreMap = make(map[string][]*GlobalStoreIndex, len(location.StoreMap))
+	for storeID, blocks := range location.StoreMap {
+		scoped := make([]*GlobalStoreIndex, len(blocks))
+		for i, block := range blocks {
+			scoped[i] = t.scopedIndex(block, timelineKey)
+		}
+		locationCopy.StoreMap[storeID] = scoped
+	}
+	return &locationCopy, nil
+}
+
+func (t *TenantGlobalIndex) ListTimelinesByStore(ctx context.Context, storeID string) ([]string, error) {
+	timelines, err := t.index.ListTimelinesByStore(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	scoped := make([]string, 0, len(timelines))
+	for _, key := range timelines {
+		if TenantOf(key) == t.tenant {
+			scoped = append(scoped, t.unscope(key))
+		}
+	}
+	return scoped, nil
+}
+
+func (t *TenantGlobalIndex) UpdateIndex(ctx context.Context, index *GlobalStoreIndex) error {
+	key, err := t.scope(index.TimelineKey)
+	if err != nil {
+		return err
+	}
+	return t.index.UpdateIndex(ctx, t.scopedIndex(index, key))
+}
+
+func (t *TenantGlobalIndex) MigrateTimeline(ctx context.Context, timelineKey, fromStoreID, toStoreID string) error {
+	key, err := t.scope(timelineKey)
+	if err != nil {
+		return err
+	}
+	return t.index.MigrateTimeline(ctx, key, fromStoreID, toStoreID)
+}
+
+func (t *TenantGlobalIndex) GetStoreLoad(ctx context.Context, storeID string) (*StoreLoadInfo, error) {
+	return t.index.GetStoreLoad(ctx, storeID)
+}
+
+func (t *TenantGlobalIndex) Watch(ctx context.Context, timelineKey string) (<-chan IndexEvent, error) {
+	key, err := t.scope(timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	events, err := t.index.Watch(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	scoped := make(chan IndexEvent, cap(events))
+	go func() {
+		defer close(scoped)
+		for event := range events {
+			event.TimelineKey = timelineKey
+			event.Index = t.scopedIndex(event.Index, timelineKey)
+			select {
+			case scoped <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return scoped, nil
+}
+
+// storeTenants 解析Store元数据中的专属租户，未声明时返回nil表示共享Store
+func storeTenants(info *StoreInfo) []string {
+	if info == nil || info.Metadata == nil {
+		return nil
+	}
+	var tenants []string
+	switch value := info.Metadata[StoreMetadataTenants].(type) {
+	case string:
+		for _, tenant := range strings.Split(value, ",") {
+			if tenant = strings.TrimSpace(tenant); tenant != "" {
+				tenants = append(tenants, tenant)
+			}
+		}
+	case []string:
+		tenants = value
+	case []interface{}:
+		// 经JSON编码的注册中心返回[]interface{}
+		for _, item := range value {
+			if tenant, ok := item.(string); ok && tenant != "" {
+				tenants = append(tenants, tenant)
+			}
+		}
+	}
+	return tenants
+}
+
+// storeDedicatedTo Store是否是租户的专属Store
+func storeDedicatedTo(info *StoreInfo, tenant string) bool {
+	for _, dedicated := range storeTenants(info) {
+		if dedicated == tenant {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantHasDedicatedStores 租户是否有专属Store
+func tenantHasDedicatedStores(stores map[string]*StoreInfo, tenant string) bool {
+	for _, info := range stores {
+		if storeDedicatedTo(info, tenant) {
+			return true
+		}
+	}
+	return false
+}
+
+// storeServesTenant Store能否放置租户的Timeline：有专属Store的租户只用专属Store，
+// 其他租户只用共享Store。dedicated为租户是否有专属Store
+func storeServesTenant(info *StoreInfo, tenant string, dedicated bool) bool {
+	if dedicated {
+		return storeDedicatedTo(info, tenant)
+	}
+	return len(storeTenants(info)) == 0
+}
+
+// filterStoresForTenant 过滤出能放置Timeline的Store
+func filterStoresForTenant(stores []*StoreInfo, timelineKey string) []*StoreInfo {
+	tenant := TenantOf(timelineKey)
+	dedicated := false
+	for _, info := range stores {
+		if storeDedicatedTo(info, tenant) {
+			dedicated = true
+			break
+		}
+	}
+	filtered := make([]*StoreInfo, 0, len(stores))
+	for _, info := range stores {
+		if storeServesTenant(info, tenant, dedicated) {
+			filtered = append(filtered, info)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTenantKey(t *testing.T) {
+	key := TenantKey("acme", "conv_1")
+	if key != "acme::conv_1" {
+		t.Fatalf("Unexpected key %q", key)
+	}
+	if tenant, local := SplitTenantKey(key); tenant != "acme" || local != "conv_1" {
+		t.Errorf("Unexpected split %q %q", tenant, local)
+	}
+	if TenantKey(DefaultTenant, "user:1001:profile") != "user:1001:profile" || TenantOf("user:1001:profile") != DefaultTenant {
+		t.Errorf("Expected unprefixed keys to belong to the default tenant")
+	}
+	if TenantOf(TenantKey("acme", "conv_1")+"#split1") != "acme" {
+		t.Errorf("Expected split timelines to keep the tenant")
+	}
+	for _, invalid := range []string{"", "Acme", "a:b", "a b"} {
+		if ValidateTenantID(invalid) == nil {
+			t.Errorf("Expected tenant id %q to be rejected", invalid)
+		}
+	}
+	if err := ValidateTenantID("acme-prod_2"); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestTenantQuotas(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	acmeConv, acmeUser := TenantKey("acme", "conv_1"), TenantKey("acme", "user_1")
+	if _, err := store.AppendMessage(acmeConv, 1, []byte("hi"), []string{"user_1"}); !errors.Is(err, ErrTenantMismatch) {
+		t.Fatalf("Expected ErrTenantMismatch, got %v", err)
+	}
+
+	data := make([]byte, 100)
+	perMessage := estimateMessageBytes(&Message{Data: data}, 2)
+	if err := store.SetTenantQuota("acme", TenantQuota{MaxBytes: 3 * perMessage}); err != nil {
+		t.Fatalf("Failed to set quota: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := store.AppendMessage(acmeConv, 1, data, []string{acmeUser}); err != nil {
+			t.Fatalf("Failed to write message %d: %v", i, err)
+		}
+	}
+	if _, err := store.AppendMessage(acmeConv, 1, data, []string{acmeUser}); !errors.Is(err, ErrTenantQuotaExceeded) {
+		t.Fatalf("Expected ErrTenantQuotaExceeded, got %v", err)
+	}
+	// 其他租户不受影响，复制写入只计入用量
+	if _, err := store.AppendMessage(TenantKey("other", "conv_1"), 1, data, nil); err != nil {
+		t.Errorf("Expected another tenant to write, got %v", err)
+	}
+	if _, _, err := store.ReplicateMessage(acmeConv, &Message{Data: data, HLC: 1}, []string{acmeUser}); err != nil {
+		t.Errorf("Expected replicated writes to bypass the quota, got %v", err)
+	}
+
+	metrics := store.GetTenantMetrics("acme")
+	if metrics.Timelines != 2 || metrics.Messages != 4 || metrics.RejectedCapacity != 1 || metrics.UsedBytes == 0 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+	if list := store.ListTenantMetrics(); len(list) != 2 || list[0].Tenant != "acme" || list[1].Tenant != "other" {
+		t.Errorf("Unexpected tenant list %+v", list)
+	}
+
+	// 删除Timeline后重新统计用量
+	if _, err := store.DeleteTimeline("conv", acmeConv); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if _, err := store.DeleteTimeline("user", acmeUser); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if _, err := store.AppendMessage(acmeConv, 1, data, []string{acmeUser}); err != nil {
+		t.Errorf("Expected the freed quota to accept writes, got %v", err)
+	}
+}
+
+func TestTenantRateQuota(t *testing.T) {
+	store, err := NewStore(&StoreConfig{TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	now := time.Unix(1000, 0)
+	store.tenants.now = func() time.Time { return now }
+
+	if err := store.SetTenantQuota("acme", TenantQuota{MaxMessagesPerSecond: 2, Burst: 2}); err != nil {
+		t.Fatalf("Failed to set quota: %v", err)
+	}
+	conv := TenantKey("acme", "conv_1")
+	for i := 0; i < 2; i++ {
+		if _, err := store.AppendMessage(conv, 1, []byte("hi"), nil); err != nil {
+			t.Fatalf("Failed to write message %d: %v", i, err)
+		}
+	}
+	if _, err := store.AppendMessage(conv, 1, []byte("hi"), nil); !errors.Is(err, ErrTenantQuotaExceeded) {
+		t.Fatalf("Expected the burst to be exhausted, got %v", err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if _, err := store.AppendMessage(conv, 1, []byte("hi"), nil); err != nil {
+		t.Errorf("Expected a refilled token, got %v", err)
+	}
+	if metrics := store.GetTenantMetrics("acme"); metrics.RejectedRate != 1 || metrics.Messages != 3 {
+		t.Errorf("Unexpected metrics %+v", metrics)
+	}
+}
+
+func TestTenantGlobalIndex(t *testing.T) {
+	ctx := context.Background()
+	shared := NewInMemoryGlobalIndex()
+	acme, err := NewTenantGlobalIndex(shared, "acme")
+	if err != nil {
+		t.Fatalf("Failed to create tenant index: %v", err)
+	}
+	other, _ := NewTenantGlobalIndex(shared, "other")
+	if _, err := NewTenantGlobalIndex(shared, "Bad Tenant"); err == nil {
+		t.Errorf("Expected an invalid tenant to be rejected")
+	}
+
+	acme.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_1", StoreID: "store_a", BlockID: "block_a", Size: 10})
+	other.AddIndex(ctx, &GlobalStoreIndex{TimelineKey: "conv_1", StoreID: "store_a", BlockID: "block_b", Size: 20})
+
+	location, err := acme.GetTimelineLocation(ctx, "conv_1")
+	if err != nil {
+		t.Fatalf("Failed to get location: %v", err)
+	}
+	if location.TimelineKey != "conv_1" || location.TotalSize != 10 || location.Blocks[0].TimelineKey != "conv_1" {
+		t.Errorf("Unexpected location %+v", location)
+	}
+	if _, err := shared.GetTimelineLocation(ctx, "acme::conv_1"); err != nil {
+		t.Errorf("Expected the prefixed key in the shared index, got %v", err)
+	}
+	if timelines, _ := other.ListTimelinesByStore(ctx, "store_a"); len(timelines) != 1 || timelines[0] != "conv_1" {
+		t.Errorf("Expected only the tenant's timelines, got %v", timelines)
+	}
+	if _, err := other.GetTimelineLocation(ctx, "acme::conv_1"); !errors.Is(err, ErrTenantMismatch) {
+		t.Errorf("Expected keys of another tenant to be rejected, got %v", err)
+	}
+
+	if err := acme.MigrateTimeline(ctx, "conv_1", "store_a", "store_b"); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if location, _ := other.GetTimelineLocation(ctx, "conv_1"); location.Blocks[0].StoreID != "store_a" {
+		t.Errorf("Expected the other tenant's timeline to stay, got %+v", location.Blocks[0])
+	}
+}
+
+func TestRoutersHonorDedicatedTenantStores(t *testing.T) {
+	stores := []*StoreInfo{
+		{ID: "store_a", Status: StoreStatusActive},
+		{ID: "store_b", Status: StoreStatusActive},
+		{ID: "store_acme", Status: StoreStatusActive, Metadata: map[string]interface{}{StoreMetadataTenants: "acme"}},
+	}
+	routers := map[string]TimelineRouter{
+		"hash":       NewConsistentHashRouter(2, 50, 0.8),
+		"rendezvous": NewRendezvousRouter(2, 0.8),
+		"balance":    NewLoadBalancingRouter(StrategyRoundRobin),
+	}
+	for name, router := range routers {
+		for _, info := range stores {
+			router.AddStore(info)
+		}
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("conv_%d", i)
+			if storeID, _ := router.RouteTimeline(key); storeID == "store_acme" {
+				t.Errorf("%s: expected shared tenants to avoid the dedicated store, got %s for %s", name, storeID, key)
+			}
+			if storeID, _ := router.RouteTimeline(TenantKey("acme", key)); storeID != "store_acme" {
+				t.Errorf("%s: expected acme to use its dedicated store, got %s", name, storeID)
+			}
+			replicas, _ := router.GetTimelineReplicas(key)
+			for _, storeID := range replicas {
+				if storeID == "store_acme" {
+					t.Errorf("%s: expected no replicas on the dedicated store for %s", name, key)
+				}
+			}
+		}
+		if storeID, _ := router.GetBestStore(); storeID == "store_acme" {
+			t.Errorf("%s: expected the best store to be shared", name)
+		}
+	}
+
+	// 租户的专属Store不可用时不回落到共享Store
+	hash := routers["hash"]
+	hash.AddStore(&StoreInfo{ID: "store_acme", Status: StoreStatusUnhealthy, Metadata: stores[2].Metadata})
+	if storeID, err := hash.RouteTimeline(TenantKey("acme", "conv_1")); err == nil {
+		t.Errorf("Expected no store for acme, got %s", storeID)
+	}
+}
+
+func TestShardRecommendationHonorsTenantStores(t *testing.T) {
+	ctx := context.Background()
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: "store_a"})
+	registry.Register(ctx, &StoreInfo{ID: "store_acme", Metadata: map[string]interface{}{StoreMetadataTenants: "acme,beta"}})
+	globalIndex := NewInMemoryGlobalIndex()
+	shards := NewTimelineShardManager(globalIndex, registry, NewRouterManager(), nil)
+
+	recommendation, err := shards.GetShardRecommendation(ctx, TenantKey("beta", "conv_1"), 100)
+	if err != nil {
+		t.Fatalf("Failed to get recommendation: %v", err)
+	}
+	if recommendation.RecommendedStore != "store_acme" || len(recommendation.Alternatives) != 0 {
+		t.Errorf("Expected only the dedicated store, got %+v", recommendation)
+	}
+	recommendation, err = shards.GetShardRecommendation(ctx, "conv_1", 100)
+	if err != nil {
+		t.Fatalf("Failed to get recommendation: %v", err)
+	}
+	if recommendation.RecommendedStore != "store_a" || len(recommendation.Alternatives) != 0 {
+		t.Errorf("Expected only the shared store, got %+v", recommendation)
+	}
+}
@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"time"
+)
+
+// 冷块分层
+// 配置ColdStore（pkg/blob的本地目录或S3兼容存储）后，已写满且最新一条消息早于TierAfter的块被上传，之后从段文件中删除：
+//   - 上传的是块在段文件中的原始记录（含头部与校验和），对象键为 blocks/{StoreID}/{blockID}
+//   - 本地保留存根（对象键、记录长度、校验和与块索引），保存在元数据后端的tiered中，
+//     打开Timeline时据此建立未加载的块，按SeqID/时间跳块不需要访问对象存储
+//   - 读取已分层的块时从对象存储下载并校验，消息放入块缓存（见block_loader.go），之后的读取命中缓存
+//   - 块被重写（编辑、删除、保留策略裁剪等）时重新写入段文件并删除存根与远端对象，删除Timeline时一并删除
+//
+// 上传与从段文件删除之间块被重写时放弃本次分层。存根没有布隆过滤器，按发送者与提及用户查询时会下载已分层的块。
+// 已分层的块不计入容量的UsedBytes，单独统计在TieredBytes中。
+
+const defaultTierAfter = 7 * 24 * time.Hour
+
+// ErrTieringDisabled 未配置ColdStore
+var ErrTieringDisabled = errors.New("cold block tiering is not configured")
+
+// tierStub 已上传块的本地存根
+type tierStub struct {
+	Key      string     `json:"key"`
+	Length   int64      `json:"length"` // 记录字节数
+	Checksum uint32     `json:"checksum"`
+	Index    BlockIndex `json:"index"`
+	TieredAt time.Time  `json:"tiered_at"`
+}
+
+// TierReport 一次分层的结果
+type TierReport struct {
+	Uploaded int   `json:"uploaded"`
+	Bytes    int64 `json:"bytes"`
+	Skipped  int   `json:"skipped"` // 上传期间被重写或删除的块
+	Failed   int   `json:"failed"`
+}
+
+// TierColdBlocks 把符合条件的冷块上传到对象存储并从段文件删除
+// 单个块失败时记录日志并继续，返回第一个错误
+func (s *Store) TierColdBlocks(ctx context.Context) (TierReport, error) {
+	var report TierReport
+	if s.Config.ColdStore == nil {
+		return report, ErrTieringDisabled
+	}
+	after := s.Config.TierAfter
+	if after <= 0 {
+		after = defaultTierAfter
+	}
+	cutoff := time.Now().Add(-after).UnixNano()
+
+	locations := s.segments.Locations()
+	candidates := make([]string, 0)
+	for blockID, location := range locations {
+		if location.Index.Count >= s.Config.TimelineMaxSize && location.Index.MaxTime < cutoff && !s.segments.IsCorrupt(blockID) {
+			candidates = append(candidates, blockID)
+		}
+	}
+	sort.Strings(candidates)
+
+	var firstErr error
+	for _, blockID := range candidates {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		size, tiered, err := s.tierBlock(ctx, blockID)
+		switch {
+		case err != nil:
+			report.Failed++
+			log.Printf("store %s: failed to tier block %s: %v", s.StoreID, blockID, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to tier block %s: %w", blockID, err)
+			}
+		case tiered:
+			report.Uploaded++
+			report.Bytes += size
+		default:
+			report.Skipped++
+		}
+	}
+	return report, firstErr
+}
+
+// tierBlock 上传一个块，保存存根后从段文件删除；块在此期间被重写时撤销存根与远端对象
+func (s *Store) tierBlock(ctx context.Context, blockID string) (int64, bool, error) {
+	raw, location, exists, err := s.segments.RawRecord(blockID)
+	if err != nil || !exists {
+		return 0, false, err
+	}
+	stub := &tierStub{
+		Key:      fmt.Sprintf("blocks/%s/%s", s.StoreID, blockID),
+		Length:   location.Length,
+		Checksum: location.Checksum,
+		Index:    location.Index,
+		TieredAt: time.Now(),
+	}
+	if err := s.Config.ColdStore.Put(ctx, stub.Key, bytes.NewReader(raw), int64(len(raw)), "application/octet-stream"); err != nil {
+		return 0, false, err
+	}
+	if err := s.saveTierStub(blockID, stub); err != nil {
+		s.deleteColdObject(stub.Key)
+		return 0, false, err
+	}
+
+	deleted, err := s.segments.DeleteBlockAt(blockID, location)
+	if err != nil || !deleted {
+		s.dropTieredBlock(blockID)
+		return 0, false, err
+	}
+
+	timelineKey := blockTimelineKey(blockID)
+	s.indexMu.Lock()
+	for _, index := range s.StoreIndex[timelineKey] {
+		if index.BlockID == blockID {
+			index.SegmentID = -1
+			index.Offset = 0
+		}
+	}
+	s.indexMu.Unlock()
+	return location.Length, true, nil
+}
+
+func (s *Store) saveTierStub(blockID string, stub *tierStub) error {
+	data, err := json.Marshal(stub)
+	if err != nil {
+		return err
+	}
+	err = s.metadata.Update(func(tx MetadataTx) error {
+		tx.Put(metaBucketTiered, blockID, data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.tierMu.Lock()
+	s.tiered[blockID] = stub
+	s.tierMu.Unlock()
+	return nil
+}
+
+// tieredStub 块已分层时返回其存根
+func (s *Store) tieredStub(blockID string) (*tierStub, bool) {
+	s.tierMu.RLock()
+	defer s.tierMu.RUnlock()
+	stub, exists := s.tiered[blockID]
+	return stub, exists
+}
+
+// dropTieredBlock 删除块的存根与远端对象，块未分层时不做任何事
+// 块重新写入段文件或被删除后调用；远端对象删除失败只记录日志
+func (s *Store) dropTieredBlock(blockID string) {
+	s.tierMu.Lock()
+	stub, exists := s.tiered[blockID]
+	delete(s.tiered, blockID)
+	s.tierMu.Unlock()
+	if !exists {
+		return
+	}
+	err := s.metadata.Update(func(tx MetadataTx) error {
+		tx.Delete(metaBucketTiered, blockID)
+		return nil
+	})
+	if err != nil {
+		log.Printf("store %s: failed to remove tier stub of %s: %v", s.StoreID, blockID, err)
+	}
+	s.deleteColdObject(stub.Key)
+}
+
+func (s *Store) deleteColdObject(key string) {
+	if s.Config.ColdStore == nil {
+		return
+	}
+	if err := s.Config.ColdStore.Delete(context.Background(), key); err != nil {
+		log.Printf("store %s: failed to delete cold object %s: %v", s.StoreID, key, err)
+	}
+}
+
+func (s *Store) fetchColdObject(key string) ([]byte, error) {
+	body, _, err := s.Config.ColdStore.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// readTieredBlock 从对象存储读取已分层的块，块未分层时返回false
+func (s *Store) readTieredBlock(blockID string) ([]*Message, blockMeta, bool, error) {
+	stub, exists := s.tieredStub(blockID)
+	if !exists {
+		return nil, blockMeta{}, false, nil
+	}
+	if s.Config.ColdStore == nil {
+		return nil, blockMeta{}, false, fmt.Errorf("block %s is tiered but %w", blockID, ErrTieringDisabled)
+	}
+	raw, err := s.fetchColdObject(stub.Key)
+	if err != nil {
+		return nil, blockMeta{}, false, fmt.Errorf("failed to fetch tiered block %s: %w", blockID, err)
+	}
+	if int64(len(raw)) != stub.Length || len(raw) < segmentHeaderSize ||
+		recordChecksum(raw[segmentHeaderSize:]) != binary.BigEndian.Uint32(raw[4:8]) {
+		return nil, blockMeta{}, false, fmt.Errorf("%w: tiered block %s checksum mismatch", ErrBlockCorrupted, blockID)
+	}
+	record, _, err := decodeBlockPayload(raw[segmentHeaderSize:])
+	if err != nil {
+		return nil, blockMeta{}, false, fmt.Errorf("failed to decode tiered block %s: %w", blockID, err)
+	}
+	return record.Messages, blockMeta{Index: record.blockIndex(), Filters: record.Filters}, true, nil
+}
+
+// newTieredBlock 按存根建立未加载的块，块未分层时返回nil
+func (s *Store) newTieredBlock(blockID string) *TimelineBlock {
+	stub, exists := s.tieredStub(blockID)
+	if !exists {
+		return nil
+	}
+	return &TimelineBlock{
+		BlockID:   blockID,
+		StoreID:   s.StoreID,
+		Size:      stub.Index.Count,
+		IsFull:    true,
+		Checksum:  stub.Checksum,
+		SegmentID: -1,
+		Index:     stub.Index,
+		lazy:      true,
+	}
+}
+
+// tieredLocations 已分层块的位置，SegmentID为-1
+func (s *Store) tieredLocations() map[string]BlockLocation {
+	s.tierMu.RLock()
+	defer s.tierMu.RUnlock()
+	locations := make(map[string]BlockLocation, len(s.tiered))
+	for blockID, stub := range s.tiered {
+		locations[blockID] = BlockLocation{SegmentID: -1, Length: stub.Length, Index: stub.Index, Checksum: stub.Checksum}
+	}
+	return locations
+}
+
+// TieredUsage 已分层的块数及其记录字节数
+func (s *Store) TieredUsage() (int, int64) {
+	s.tierMu.RLock()
+	defer s.tierMu.RUnlock()
+	var bytes int64
+	for _, stub := range s.tiered {
+		bytes += stub.Length
+	}
+	return len(s.tiered), bytes
+}
+
+// loadTierStubs 启动时加载全部存根，段文件中仍有记录的块（分层中途崩溃）以段文件为准
+func (s *Store) loadTierStubs() error {
+	var stale []string
+	err := s.metadata.ForEach(metaBucketTiered, func(blockID string, data []byte) error {
+		stub := &tierStub{}
+		if err := json.Unmarshal(data, stub); err != nil {
+			return fmt.Errorf("failed to parse tier stub of %s: %w", blockID, err)
+		}
+		s.tiered[blockID] = stub
+		if s.segments.HasBlock(blockID) {
+			stale = append(stale, blockID)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, blockID := range stale {
+		s.dropTieredBlock(blockID)
+	}
+	return nil
+}
+
+// startTiering 配置了ColdStore与TierInterval时启动后台分层协程
+func (s *Store) startTiering() {
+	interval := s.Config.TierInterval
+	if s.Config.ColdStore == nil || interval <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.tierStop = cancel
+	s.tierDone = make(chan struct{})
+	go func() {
+		defer close(s.tierDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if report, err := s.TierColdBlocks(ctx); err != nil && ctx.Err() == nil {
+					log.Printf("store %s: tiered %d blocks, %d failed: %v", s.StoreID, report.Uploaded, report.Failed, err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// stopTiering 停止后台分层协程，正在上传的块被取消
+func (s *Store) stopTiering() {
+	if s.tierStop == nil {
+		return
+	}
+	s.tierStop()
+	<-s.tierDone
+	s.tierStop = nil
+}
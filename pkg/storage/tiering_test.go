@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"imy/pkg/blob"
+)
+
+func TestTierColdBlocks(t *testing.T) {
+	coldDir := filepath.Join(t.TempDir(), "cold")
+	cold, err := blob.NewLocalStore(coldDir)
+	if err != nil {
+		t.Fatalf("Failed to create object store: %v", err)
+	}
+	config := &StoreConfig{StoreID: "tier", TimelineMaxSize: 4, DataDir: t.TempDir(), ColdStore: cold, TierAfter: time.Millisecond}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 10; i++ {
+		if _, err := store.AppendMessage("cold", 1, []byte(fmt.Sprintf("message-%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	before := store.Capacity()
+	report, err := store.TierColdBlocks(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to tier blocks: %v", err)
+	}
+	// 两个已写满的块，活跃块留在本地
+	if report.Uploaded != 2 || report.Failed != 0 {
+		t.Fatalf("Expected 2 blocks to be tiered, got %+v", report)
+	}
+	capacity := store.Capacity()
+	if capacity.TieredBlocks != 2 || capacity.TieredBytes != report.Bytes {
+		t.Errorf("Expected capacity to report the tiered blocks, got %+v", capacity)
+	}
+	if capacity.BlockBytes >= before.BlockBytes {
+		t.Errorf("Expected tiering to release local block bytes, %d -> %d", before.BlockBytes, capacity.BlockBytes)
+	}
+	if again, _ := store.TierColdBlocks(context.Background()); again.Uploaded != 0 {
+		t.Errorf("Expected no blocks left to tier, got %+v", again)
+	}
+
+	messages, err := store.GetConvMessages("cold", 20, 0)
+	if err != nil || len(messages) != 10 {
+		t.Fatalf("Expected 10 messages read through the tier, got %d: %v", len(messages), err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if blocks, _ := reopened.TieredUsage(); blocks != 2 {
+		t.Errorf("Expected 2 tiered blocks after reopen, got %d", blocks)
+	}
+	messages, err = reopened.GetConvMessages("cold", 20, 0)
+	if err != nil || len(messages) != 10 {
+		t.Fatalf("Expected 10 messages after reopen, got %d: %v", len(messages), err)
+	}
+	if report := reopened.RecoveryReport(); len(report.RepairedBlocks) != 0 || len(report.RebuiltTimelines) != 0 {
+		t.Errorf("Expected tiered blocks not to be repaired on startup, got %+v", report)
+	}
+
+	// 保留策略裁剪已分层的块后块重新写入段文件，存根与远端对象被删除
+	manager := NewRetentionManager(reopened, NewInMemoryGlobalIndex(), &RetentionPolicy{MaxMessages: 7})
+	if result, err := manager.RunOnce(context.Background()); err != nil || result.BlocksCompacted != 1 {
+		t.Fatalf("Expected retention to compact the first block, got %+v: %v", result, err)
+	}
+	if blocks, _ := reopened.TieredUsage(); blocks != 1 {
+		t.Errorf("Expected the rewritten block to leave the tier, %d tiered", blocks)
+	}
+	if objects, _ := filepath.Glob(filepath.Join(coldDir, "blocks", "tier", "*")); len(objects) != 1 {
+		t.Errorf("Expected 1 remote object left, found %v", objects)
+	}
+	if messages, _ = reopened.GetConvMessages("cold", 20, 0); len(messages) != 7 || messages[0].SeqID != 4 {
+		t.Errorf("Expected messages 4-10 after retention, got %d", len(messages))
+	}
+
+	if _, err := reopened.DeleteTimeline("conv", "cold"); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if blocks, _ := reopened.TieredUsage(); blocks != 0 {
+		t.Errorf("Expected no tiered blocks after deleting the timeline, got %d", blocks)
+	}
+	if objects, _ := filepath.Glob(filepath.Join(coldDir, "blocks", "tier", "*")); len(objects) != 0 {
+		t.Errorf("Expected remote objects to be deleted with the timeline, found %v", objects)
+	}
+}
+
+func TestTierColdBlocksCorruptObject(t *testing.T) {
+	coldDir := t.TempDir()
+	cold, err := blob.NewLocalStore(coldDir)
+	if err != nil {
+		t.Fatalf("Failed to create object store: %v", err)
+	}
+	config := &StoreConfig{StoreID: "tier", TimelineMaxSize: 4, DataDir: t.TempDir(), ColdStore: cold, TierAfter: time.Millisecond}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		store.AppendMessage("broken", 1, []byte("hi"), nil)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if report, err := store.TierColdBlocks(context.Background()); err != nil || report.Uploaded != 1 {
+		t.Fatalf("Expected 1 block to be tiered, got %+v: %v", report, err)
+	}
+
+	objects, _ := filepath.Glob(filepath.Join(coldDir, "blocks", "tier", "*"))
+	if len(objects) != 1 {
+		t.Fatalf("Expected 1 remote object, found %v", objects)
+	}
+	data, _ := os.ReadFile(objects[0])
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(objects[0], data, 0644)
+	store.Close()
+
+	// 重新打开后块只有存根，读取时下载并校验
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	if _, err := reopened.GetConvMessages("broken", 10, 0); !errors.Is(err, ErrBlockCorrupted) {
+		t.Errorf("Expected a corrupted tiered block to be reported, got %v", err)
+	}
+}
@@ -1,28 +1,82 @@
 package storage
 
 import (
-	"encoding/gob"
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"imy/pkg/blob"
+	"imy/pkg/events"
+	"imy/pkg/moderation"
 )
 
 // StoreConfig Store配置
 type StoreConfig struct {
-	MaxCapacity     int64  // Store最大容量（字节）
+	StoreID         string // Store ID，为空时自动生成，集群部署时应固定配置
+	MaxCapacity     int64  // Store最大容量（字节），包含段文件中的块与WAL，0表示不限制
 	TimelineMaxSize int64  // Timeline块最大大小（消息数量）
 	DataDir         string // 数据目录
+
+	SegmentMaxSize int64      // 单个段文件的最大字节数，默认64MB
+	BlockCodec     BlockCodec // 新写入块记录的编码，默认protobuf，已有记录按各自的编码读取
+
+	DisableWAL      bool          // 关闭WAL，未写满的块在崩溃时会丢失，除非开启BlockFlushInterval
+	WALSyncPolicy   WALSyncPolicy // WAL刷盘策略，默认interval
+	WALSyncInterval time.Duration // interval策略下的刷盘间隔，默认1秒
+	WALMaxSize      int64         // WAL超过该大小时在块落盘后压缩，默认64MB
+
+	BlockFlushInterval time.Duration // 后台将未写满的活跃块写入段文件的间隔，0表示不写入，见block_flusher.go
+	ScrubInterval      time.Duration // 后台校验所有段记录的间隔，0表示只在打开后校验按段索引文件打开的段，见segment_scrub.go
+	BlockCacheSize     int64         // 按需读取的已写满块的消息缓存上限（字节），默认64MB，见block_loader.go
+
+	Durability         DurabilityPolicy // 段文件、元数据与WAL的fsync策略，见durability.go；WALSyncPolicy为空时同时决定WAL的刷盘策略
+	DurabilityInterval time.Duration    // periodic策略下的刷盘间隔，默认1秒
+
+	CapacityHighWatermark float64 // 已用容量超过MaxCapacity的该比例后拒绝写入，默认0.95
+
+	DedupTTL        time.Duration // clientMsgID去重窗口，默认10分钟
+	DedupMaxEntries int           // 每个会话保留的去重记录上限，默认1024
+
+	FanoutWorkers   int // 异步写入用户时间线的协程数，0表示由写入方同步写入，见fanout.go
+	FanoutQueueSize int // 每个扇出协程的队列长度，队列满时写入方阻塞，默认1024
+	// 会话成员数达到该值时改为拉模式，消息不再复制到成员的用户时间线，0表示不自动切换，见pull_fanout.go
+	PullFanoutThreshold int
+
+	BlockBloomFilters bool // 块落盘时生成发送者、提及用户与回复消息的布隆过滤器，按这些条件查询时跳过不相关的块
+	BloomBitsPerKey   int  // 布隆过滤器每个键占用的位数，默认10
+
+	Clock func() time.Time // 生成HLC的物理时钟，默认time.Now，故障测试中用于模拟时钟偏移
+
+	// Timeline元数据、扇出状态与checkpoint的存储方式，默认files，kv时首次打开导入已有文件，见metadata_backend.go
+	MetadataBackend MetadataBackendType
+
+	// 冷块分层的对象存储，为nil时不分层，见tiering.go
+	ColdStore    blob.Store
+	TierAfter    time.Duration // 已写满的块最新一条消息早于该时长后上传到ColdStore，默认7天
+	TierInterval time.Duration // 后台分层的间隔，0表示只通过TierColdBlocks手动触发
 }
 
 // StoreIndex Store索引信息
 type StoreIndex struct {
 	StoreID   string `json:"store_id"`   // 标识在哪个store
-	Offset    int64  `json:"offset"`     // store中的偏移，精准找到timelineBlock
-	Size      int64  `json:"size"`       // 该timelineBlock的大小
+	BlockID   string `json:"block_id"`   // 对应的timelineBlock
+	SegmentID int    `json:"segment_id"` // 块所在的段文件
+	Offset    int64  `json:"offset"`     // 段文件中的偏移，精准找到timelineBlock
+	Size      int64  `json:"size"`       // 该timelineBlock的大小（字节）
 	CreatedAt int64  `json:"created_at"` // 创建时间戳
 }
 
@@ -30,50 +84,130 @@ type StoreIndex struct {
 type TimelineBlock struct {
 	BlockID   string         `json:"block_id"`
 	StoreID   string         `json:"store_id"`
-	Offset    int64          `json:"offset"`
+	SegmentID int            `json:"segment_id"` // 落盘后所在的段文件
+	Offset    int64          `json:"offset"`     // 落盘后在段文件中的偏移
 	Size      int64          `json:"size"`
 	Messages  []*Message     `json:"-"` // 内存中的消息缓存
 	IsFull    bool           `json:"is_full"`
-	NextBlock *TimelineBlock `json:"-"` // 下一个块的引用
+	Checksum  uint32         `json:"checksum"` // 块内消息的校验和，落盘后有效
+	Index     BlockIndex     `json:"index"`    // 块内消息的SeqID/时间/HLC范围，随块写入段文件
+	Filters   *blockFilters  `json:"-"`        // 发送者、提及用户与回复消息的布隆过滤器，未开启或块未落盘时为nil
+	NextBlock *TimelineBlock `json:"-"`        // 下一个块的引用
 	mu        sync.RWMutex
+	// 块的消息未加载到内存，Messages为nil，读取时按需从段文件读取，见block_loader.go
+	lazy bool
 }
 
 // Store 管理所有的 Timeline
 type Store struct {
-	Config          *StoreConfig // Store配置
-	StoreID         string       // 当前Store ID
-	CurrentCapacity int64        // 当前已使用容量
+	Config  *StoreConfig // Store配置
+	StoreID string       // 当前Store ID
 	// 会话存储库：ConvID -> Timeline
 	ConvTimelines map[string]*Timeline
 	// 用户同步库：UserID -> Timeline
 	UserTimelines map[string]*Timeline
 	// 用户 checkpoint：UserID -> SeqID
 	UserCheckpoints map[string]int64
-	StoreIndex      map[string][]*StoreIndex  // Timeline的Store索引，一个Timeline可能由位于不同store的tblock组成
-	TimelineBlocks  map[string]*TimelineBlock // Timeline块缓存
-	// 全局序列号生成器
-	seqGenerator int64
+	// 会话已读位置：UserID -> ConvID -> SeqID
+	ConvCheckpoints map[string]map[string]int64
+	// 拉模式会话的同步游标：UserID -> ConvID -> 已确认的会话SeqID
+	PullCursors    map[string]map[string]int64
+	StoreIndex     map[string][]*StoreIndex  // Timeline的Store索引，一个Timeline可能由位于不同store的tblock组成
+	TimelineBlocks map[string]*TimelineBlock // Timeline块缓存
+	// 保护StoreIndex与TimelineBlocks，写入块时只持有Timeline锁，因此不能复用mu；
+	// 加锁顺序为 mu -> Timeline.mu -> TimelineBlock.mu -> indexMu，持有indexMu时不再获取其他锁
+	indexMu sync.RWMutex
+	// 混合逻辑时钟，为每条消息生成跨Store可比较的时间戳
+	clock *hybridClock
+	// 查询优化器，按块索引统计与实测成本为Query选择执行计划
+	queryOptimizer *QueryOptimizer
+	// 块数据的段文件存储
+	segments *segmentStore
+	// 未加载块按需读取的消息缓存，见block_loader.go
+	blockCache *MemoryCache
+	// 启动扫描与全局索引核对的结果，见recovery_scan.go
+	recoveryMu sync.Mutex
+	recovery   RecoveryReport
+	// 持久化策略及periodic策略下的后台刷盘协程，见durability.go
+	durability DurabilityPolicy
+	syncStop   chan struct{}
+	syncDone   chan struct{}
+	// 未写满块的后台刷盘协程，以及写满后落盘失败待重试的块，见block_flusher.go
+	flushStop      chan struct{}
+	flushDone      chan struct{}
+	deferredMu     sync.Mutex
+	deferredBlocks map[string]*TimelineBlock
+	// 段记录的后台校验协程，见segment_scrub.go
+	scrubStop chan struct{}
+	scrubDone chan struct{}
+	// 异步写入用户时间线的协程池，未配置FanoutWorkers时为nil，见fanout.go
+	fanout *fanoutPool
+	// 会话的扇出状态及用户所在的拉模式会话，见pull_fanout.go
+	fanoutMu      sync.RWMutex
+	convFanouts   map[string]*convFanout
+	userPullConvs map[string]map[string]struct{}
+	// 最近一次返回给用户的拉模式消息，由mu保护
+	pullReads map[string]*pullRead
+	// checkpoint与会话已读位置的更新日志，元数据后端为kv时为nil，见checkpoint_store.go
+	checkpoints *checkpointLog
+	// Timeline元数据等的存储后端，见metadata_backend.go
+	metadata MetadataBackend
+	// 已上传到对象存储的块的存根：blockID -> 存根，及后台分层协程，见tiering.go
+	tierMu   sync.RWMutex
+	tiered   map[string]*tierStub
+	tierStop context.CancelFunc
+	tierDone chan struct{}
+	// 预写日志及启动时回放得到的未落盘记录：timelineKey -> 记录
+	wal        *writeAheadLog
+	walPending map[string][]*walRecord
+	// 容量不足时最近一次压缩后的WAL大小，WAL未增长时不再重复压缩
+	walCompactedSize int64
+	// 按租户的配额与统计，见tenant.go
+	tenants *tenantTable
+	// 写入消息的审核拦截链，见moderation.go
+	moderation atomic.Pointer[moderation.Pipeline]
+	// 发布消息事件的事件总线，见message_events.go
+	events atomic.Pointer[events.Bus]
 	// 读写锁
 	mu sync.RWMutex
 }
 
 // Timeline 时间线存储
 type Timeline struct {
-	ID           string           `json:"id"`
-	Type         string           `json:"type"`   // "conv" 或 "user"
-	Blocks       []*TimelineBlock `json:"blocks"` // Timeline块列表
-	CurrentBlock *TimelineBlock   `json:"-"`      // 当前活跃块
-	LastSeqID    int64            `json:"last_seq_id"`
+	ID           string                       `json:"id"`
+	Type         string                       `json:"type"`   // "conv" 或 "user"
+	Blocks       []*TimelineBlock             `json:"blocks"` // Timeline块列表
+	CurrentBlock *TimelineBlock               `json:"-"`      // 当前活跃块
+	LastSeqID    int64                        `json:"last_seq_id"`
+	dedup        *dedupIndex                  // clientMsgID去重索引，只用于会话Timeline，首次写入带clientMsgID的消息时创建
+	view         atomic.Pointer[timelineView] // 供读取方无锁遍历的只读视图，在Timeline写锁内发布
+	metaMu       sync.Mutex                   // 串行化元数据文件的替换，替换时共用同一个临时文件
 	mu           sync.RWMutex
 }
 
 // Message 消息结构
+// SeqID在所属Timeline内从1开始连续递增，不同Timeline之间不可比较
 type Message struct {
-	SeqID      int64     `json:"seq_id"`
-	ConvID     string    `json:"conv_id"`
-	SenderID   uint32    `json:"sender_id"`
-	CreateTime time.Time `json:"create_time"`
-	Data       []byte    `json:"data"`
+	SeqID        int64     `json:"seq_id"`
+	ConvID       string    `json:"conv_id"`
+	SenderID     uint32    `json:"sender_id"`
+	CreateTime   time.Time `json:"create_time"`
+	Data         []byte    `json:"data"`
+	Type         MsgType   `json:"type,omitempty"`            // 记录类型，默认为普通消息
+	RefSeqID     int64     `json:"ref_seq_id,omitempty"`      // 编辑/删除记录引用的原消息SeqID
+	ConvSeqID    int64     `json:"conv_seq_id,omitempty"`     // 用户Timeline中的记录在会话Timeline中的SeqID
+	HLC          int64     `json:"hlc,omitempty"`             // 混合逻辑时钟时间戳，复制到其他Store时保持不变
+	ClientMsgID  string    `json:"client_msg_id,omitempty"`   // 客户端生成的幂等ID，去重窗口内重复写入返回已有消息
+	Mentions     []string  `json:"mentions,omitempty"`        // 消息中提及的用户UUID
+	ReplyToSeqID int64     `json:"reply_to_seq_id,omitempty"` // 回复的会话消息SeqID，不是回复时为0
+}
+
+// convSeqID 记录在会话Timeline中的SeqID，兼容未记录ConvSeqID的旧数据
+func (m *Message) convSeqID() int64 {
+	if m.ConvSeqID > 0 {
+		return m.ConvSeqID
+	}
+	return m.SeqID
 }
 
 // NewStore 创建新的存储实例
@@ -84,24 +218,225 @@ func NewStore(config *StoreConfig) (*Store, error) {
 	}
 
 	// 生成Store ID
-	storeID := fmt.Sprintf("store_%d", time.Now().UnixNano())
+	storeID := config.StoreID
+	if storeID == "" {
+		storeID = fmt.Sprintf("store_%d", time.Now().UnixNano())
+	}
 
-	return &Store{
+	store := &Store{
 		Config:          config,
 		StoreID:         storeID,
-		CurrentCapacity: 0,
 		ConvTimelines:   make(map[string]*Timeline),
 		UserTimelines:   make(map[string]*Timeline),
 		UserCheckpoints: make(map[string]int64),
+		ConvCheckpoints: make(map[string]map[string]int64),
+		PullCursors:     make(map[string]map[string]int64),
 		StoreIndex:      make(map[string][]*StoreIndex),
 		TimelineBlocks:  make(map[string]*TimelineBlock),
-		seqGenerator:    0,
-	}, nil
+		clock:           newHybridClock(config.Clock),
+		queryOptimizer:  NewQueryOptimizer(),
+		walPending:      make(map[string][]*walRecord),
+		tenants:         newTenantTable(),
+		convFanouts:     make(map[string]*convFanout),
+		userPullConvs:   make(map[string]map[string]struct{}),
+		pullReads:       make(map[string]*pullRead),
+		tiered:          make(map[string]*tierStub),
+		deferredBlocks:  make(map[string]*TimelineBlock),
+	}
+	blockCacheSize := config.BlockCacheSize
+	if blockCacheSize <= 0 {
+		blockCacheSize = defaultBlockCacheSize
+	}
+	store.blockCache = NewMemoryCache(blockCacheSize)
+
+	durability, err := validDurability(config.Durability)
+	if err != nil {
+		return nil, err
+	}
+	store.durability = durability
+
+	segments, err := openSegmentStore(config.DataDir, config.SegmentMaxSize, config.BlockCodec)
+	if err != nil {
+		return nil, err
+	}
+	segments.durability = durability
+	store.segments = segments
+
+	// 迁移旧版本每块一个gob文件的数据
+	if migrated, err := segments.migrateGobBlocks(config.DataDir); err != nil {
+		segments.Close()
+		return nil, err
+	} else if migrated > 0 {
+		log.Printf("store %s: migrated %d legacy block files into segments", config.DataDir, migrated)
+	}
+
+	if !config.DisableWAL {
+		if err := store.openWAL(); err != nil {
+			segments.Close()
+			return nil, err
+		}
+	}
+
+	if err := store.loadMetadata(); err != nil {
+		if store.metadata != nil {
+			store.metadata.Close()
+		}
+		if store.wal != nil {
+			store.wal.Close()
+		}
+		segments.Close()
+		return nil, err
+	}
+	store.startDurabilityLoop()
+	store.startBlockFlusher()
+	store.startScrubber()
+	store.startTiering()
+	if config.FanoutWorkers > 0 {
+		store.fanout = newFanoutPool(store, config.FanoutWorkers, config.FanoutQueueSize)
+	}
+
+	return store, nil
+}
+
+// Close 关闭Store，刷盘并关闭WAL与段文件
+func (s *Store) Close() error {
+	// 异步审核可能还要写入删除墓碑
+	s.moderation.Load().Wait()
+	s.stopFanout()
+	s.stopTiering()
+	s.stopBlockFlusher()
+	s.stopScrubber()
+	s.stopDurabilityLoop()
+	err := s.closeCheckpoints()
+	if flushErr := s.retryDeferredBlocks(); err == nil {
+		err = flushErr
+	}
+	if s.flushesOpenBlocks() {
+		if _, flushErr := s.FlushOpenBlocks(); err == nil {
+			err = flushErr
+		}
+	}
+	if s.wal != nil {
+		if closeErr := s.wal.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := s.metadata.Close(); err == nil {
+		err = closeErr
+	}
+	if s.durability != DurabilityNone {
+		if syncErr := s.segments.Sync(); err == nil {
+			err = syncErr
+		}
+	}
+	if closeErr := s.segments.Close(); err == nil {
+		err = closeErr
+	}
+	return err
 }
 
-// NextSeqID 生成下一个序列号
-func (s *Store) NextSeqID() int64 {
-	return atomic.AddInt64(&s.seqGenerator, 1)
+// Flush 保存所有已加载Timeline的元数据并将段文件与WAL刷盘，用于停机前的最终落盘
+func (s *Store) Flush() error {
+	s.WaitFanout()
+	err := s.retryDeferredBlocks()
+	for _, tl := range s.ListTimelines() {
+		if saveErr := s.saveTimelineMetadata(tl); saveErr != nil && err == nil {
+			err = fmt.Errorf("failed to flush timeline %s_%s: %w", tl.Type, tl.ID, saveErr)
+		}
+	}
+	if syncErr := s.segments.Sync(); syncErr != nil && err == nil {
+		err = syncErr
+	}
+	if syncErr := s.checkpoints.Sync(); syncErr != nil && err == nil {
+		err = syncErr
+	}
+	if syncErr := s.metadata.Sync(); syncErr != nil && err == nil {
+		err = syncErr
+	}
+	if s.wal != nil {
+		if syncErr := s.wal.Sync(); syncErr != nil && err == nil {
+			err = syncErr
+		}
+	}
+	return err
+}
+
+// openWAL 打开WAL并回放未落盘的记录
+// 记录按Timeline暂存，在Timeline首次加载时重建对应的块
+func (s *Store) openWAL() error {
+	policy, interval := s.Config.walSyncPolicy()
+	wal, records, err := openWAL(s.Config.DataDir, policy, interval)
+	if err != nil {
+		return err
+	}
+	s.wal = wal
+
+	obsolete := 0
+	for _, record := range records {
+		// 已随所在块落盘的记录无需回放
+		if s.walRecordPersisted(record) {
+			obsolete++
+			continue
+		}
+		key := record.timelineKey()
+		s.walPending[key] = append(s.walPending[key], record)
+		s.observeHLC(record.Message.HLC)
+	}
+
+	if obsolete > 0 {
+		return s.compactWAL()
+	}
+	return nil
+}
+
+// compactWAL 移除已随所在块落盘的WAL记录
+// 段文件不是每次写入都fsync时先刷盘，避免WAL中的记录先于对应的块被持久地移除
+func (s *Store) compactWAL() error {
+	if s.durability == DurabilityPeriodic {
+		if err := s.segments.Sync(); err != nil {
+			return err
+		}
+	}
+	return s.wal.Compact(func(record *walRecord) bool {
+		return !s.walRecordPersisted(record)
+	})
+}
+
+// maybeCompactWAL WAL超过阈值时压缩
+func (s *Store) maybeCompactWAL() error {
+	if s.wal == nil {
+		return nil
+	}
+	maxSize := s.Config.WALMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultWALMaxSize
+	}
+	if s.wal.Size() < maxSize {
+		return nil
+	}
+	return s.compactWAL()
+}
+
+// walRecordPersisted 检查WAL记录中的消息是否已随所在块写入段文件
+// 后台刷盘会写入未写满的块，块中已写入的最后一条消息之后的记录仍需保留
+func (s *Store) walRecordPersisted(record *walRecord) bool {
+	seqID, persisted := s.segments.PersistedSeqID(record.BlockID)
+	return persisted && record.Message.SeqID <= seqID
+}
+
+// blockTimelineKey 从块ID中解析所属Timeline键（块ID格式为 {type}_{id}_{纳秒时间戳}）
+func blockTimelineKey(blockID string) string {
+	if i := strings.LastIndex(blockID, "_"); i > 0 {
+		return blockID[:i]
+	}
+	return blockID
+}
+
+// observeHLC 合并其他Store或持久化记录中的HLC
+func (s *Store) observeHLC(hlc int64) {
+	if hlc > 0 {
+		s.clock.Observe(hlc)
+	}
 }
 
 // GetOrCreateConvTimeline 获取或创建会话时间线
@@ -150,44 +485,270 @@ func (s *Store) GetOrCreateUserTimeline(userID string) *Timeline {
 	return tl
 }
 
+// GetTimeline 获取已存在的Timeline，未加载时从元数据文件或WAL加载，不存在时返回false且不会创建
+func (s *Store) GetTimeline(timelineType, timelineID string) (*Timeline, bool) {
+	s.mu.RLock()
+	timelines := s.timelineMap(timelineType)
+	tl, exists := timelines[timelineID]
+	s.mu.RUnlock()
+	if exists || timelines == nil {
+		return tl, exists
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if tl, exists := timelines[timelineID]; exists {
+		return tl, true
+	}
+	tl = &Timeline{
+		ID:     timelineID,
+		Type:   timelineType,
+		Blocks: make([]*TimelineBlock, 0),
+	}
+	timelineKey := fmt.Sprintf("%s_%s", timelineType, timelineID)
+	if _, pending := s.walPending[timelineKey]; !pending && !s.hasTimelineMetadata(timelineKey) {
+		return nil, false
+	}
+	if err := s.loadTimeline(tl); err != nil {
+		log.Printf("store %s: failed to load timeline %s_%s: %v", s.StoreID, timelineType, timelineID, err)
+		return nil, false
+	}
+	timelines[timelineID] = tl
+	return tl, true
+}
+
+// FindTimeline 按ID查找会话或用户Timeline，会话Timeline优先
+func (s *Store) FindTimeline(timelineID string) (*Timeline, bool) {
+	if tl, exists := s.GetTimeline("conv", timelineID); exists {
+		return tl, true
+	}
+	return s.GetTimeline("user", timelineID)
+}
+
+// ListTimelines 列出已加载的Timeline，按类型和ID排序
+func (s *Store) ListTimelines() []*Timeline {
+	s.mu.RLock()
+	timelines := make([]*Timeline, 0, len(s.ConvTimelines)+len(s.UserTimelines))
+	for _, tl := range s.ConvTimelines {
+		timelines = append(timelines, tl)
+	}
+	for _, tl := range s.UserTimelines {
+		timelines = append(timelines, tl)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(timelines, func(i, j int) bool {
+		if timelines[i].Type != timelines[j].Type {
+			return timelines[i].Type < timelines[j].Type
+		}
+		return timelines[i].ID < timelines[j].ID
+	})
+	return timelines
+}
+
+// GetTimelineBlock 获取已加载的块
+func (s *Store) GetTimelineBlock(blockID string) (*TimelineBlock, bool) {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	block, exists := s.TimelineBlocks[blockID]
+	return block, exists
+}
+
+// BlockCount 已加载的块数量
+func (s *Store) BlockCount() int {
+	s.indexMu.RLock()
+	defer s.indexMu.RUnlock()
+	return len(s.TimelineBlocks)
+}
+
+// timelineMap 按类型返回Timeline表，调用方需持有mu
+func (s *Store) timelineMap(timelineType string) map[string]*Timeline {
+	switch timelineType {
+	case "conv":
+		return s.ConvTimelines
+	case "user":
+		return s.UserTimelines
+	}
+	return nil
+}
+
+// Snapshot 复制Timeline及其块的元信息（不含消息），可在锁外安全地序列化
+func (tl *Timeline) Snapshot() *Timeline {
+	tl.mu.RLock()
+	defer tl.mu.RUnlock()
+
+	snapshot := &Timeline{
+		ID:        tl.ID,
+		Type:      tl.Type,
+		Blocks:    make([]*TimelineBlock, 0, len(tl.Blocks)),
+		LastSeqID: tl.LastSeqID,
+	}
+	for _, block := range tl.Blocks {
+		copied := block.Snapshot()
+		if len(snapshot.Blocks) > 0 {
+			snapshot.Blocks[len(snapshot.Blocks)-1].NextBlock = copied
+		}
+		snapshot.Blocks = append(snapshot.Blocks, copied)
+		if block == tl.CurrentBlock {
+			snapshot.CurrentBlock = copied
+		}
+	}
+	return snapshot
+}
+
+// Snapshot 复制块的元信息（不含消息）
+func (b *TimelineBlock) Snapshot() *TimelineBlock {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.snapshotLocked()
+}
+
+// snapshotLocked 调用方需持有块锁
+func (b *TimelineBlock) snapshotLocked() *TimelineBlock {
+	return &TimelineBlock{
+		BlockID:   b.BlockID,
+		StoreID:   b.StoreID,
+		SegmentID: b.SegmentID,
+		Offset:    b.Offset,
+		Size:      b.Size,
+		IsFull:    b.IsFull,
+		Checksum:  b.Checksum,
+		Index:     b.Index,
+	}
+}
+
 // AddMessage 添加消息到会话和相关用户的时间线
 func (s *Store) AddMessage(convID string, senderID uint32, data []byte, userIDs []string) error {
-	seqID := s.NextSeqID()
+	_, err := s.AppendMessage(convID, senderID, data, userIDs)
+	return err
+}
+
+// AppendMessage 添加消息到会话和相关用户的时间线，返回写入会话时间线的消息
+func (s *Store) AppendMessage(convID string, senderID uint32, data []byte, userIDs []string) (*Message, error) {
+	msg, _, err := s.AppendClientMessage(convID, "", senderID, data, userIDs)
+	return msg, err
+}
+
+// AppendClientMessage 按clientMsgID幂等地添加消息，去重窗口内同一会话已有该clientMsgID时
+// 不再写入，返回已有的消息且duplicate为true。clientMsgID为空时不去重
+func (s *Store) AppendClientMessage(convID, clientMsgID string, senderID uint32, data []byte, userIDs []string) (msg *Message, duplicate bool, err error) {
+	return s.SubmitMessage(convID, &Message{SenderID: senderID, Data: data, ClientMsgID: clientMsgID}, userIDs)
+}
+
+// SubmitMessage 写入客户端提交的消息，取用source的发送者、内容、clientMsgID、提及用户与回复的消息，
+// 创建时间与HLC由本地生成。clientMsgID的去重语义同AppendClientMessage
+func (s *Store) SubmitMessage(convID string, source *Message, userIDs []string) (msg *Message, duplicate bool, err error) {
+	return s.appendMessage(&Message{
+		ConvID:       convID,
+		SenderID:     source.SenderID,
+		CreateTime:   time.Now(),
+		Data:         source.Data,
+		ClientMsgID:  source.ClientMsgID,
+		Mentions:     source.Mentions,
+		ReplyToSeqID: source.ReplyToSeqID,
+	}, userIDs)
+}
+
+// ReplicateMessage 写入其他Store已接受的消息，保留其HLC、创建时间与clientMsgID，SeqID仍由本地Timeline分配
+// 同一消息在各副本上的HLC一致，客户端按HLC排序即可得到确定的顺序；重试复制时按clientMsgID去重
+func (s *Store) ReplicateMessage(convID string, source *Message, userIDs []string) (*Message, bool, error) {
 	msg := &Message{
-		SeqID:      seqID,
-		ConvID:     convID,
-		SenderID:   senderID,
-		CreateTime: time.Now(),
-		Data:       data,
+		ConvID:       convID,
+		SenderID:     source.SenderID,
+		CreateTime:   source.CreateTime,
+		Data:         source.Data,
+		Type:         source.Type,
+		RefSeqID:     source.RefSeqID,
+		HLC:          source.HLC,
+		ClientMsgID:  source.ClientMsgID,
+		Mentions:     source.Mentions,
+		ReplyToSeqID: source.ReplyToSeqID,
 	}
+	if msg.CreateTime.IsZero() {
+		msg.CreateTime = time.Now()
+	}
+	return s.appendMessage(msg, userIDs)
+}
 
-	// 添加到会话时间线
-	convTL := s.GetOrCreateConvTimeline(convID)
-	if err := convTL.AddMessage(msg, s); err != nil {
-		return err
+// appendMessage 将记录写入会话和相关用户的时间线，返回会话时间线中的消息
+// 会话与每个用户的时间线各自分配SeqID，写入用户时间线的是带ConvSeqID的副本；
+// msg未携带HLC时由本地时钟生成，否则沿用并推进本地时钟。
+// clientMsgID重复时不写入任何Timeline，返回已有的消息且duplicate为true。
+// 配置了FanoutWorkers时返回前只保证写入会话时间线，用户时间线由后台协程写入，见fanout.go；
+// 拉模式的会话不写入用户时间线，见pull_fanout.go。
+// 会话与用户Timeline必须属于同一租户，本地生成HLC的写入受租户配额限制、经过内容审核并发布消息事件
+func (s *Store) appendMessage(msg *Message, userIDs []string) (result *Message, duplicate bool, err error) {
+	tenant, err := tenantOfMessage(msg.ConvID, userIDs)
+	if err != nil {
+		return nil, false, err
+	}
+	convTL := s.GetOrCreateConvTimeline(msg.ConvID)
+	// 重试的消息无需占用容量，先查一次去重索引
+	if existing := convTL.findDuplicate(msg.ClientMsgID); existing != nil {
+		return existing, true, nil
+	}
+	review, err := s.moderateMessage(msg)
+	if err != nil {
+		return nil, false, err
 	}
 
-	// 添加到所有相关用户的时间线
-	for _, userID := range userIDs {
-		userTL := s.GetOrCreateUserTimeline(userID)
-		if err := userTL.AddMessage(msg, s); err != nil {
-			return err
+	// 拉模式的会话不复制到用户时间线
+	pull := s.pullsFanout(msg.ConvID, len(userIDs))
+	copies := 1
+	if !pull {
+		copies += len(userIDs)
+	}
+
+	// 写入前检查容量，避免一条消息只写入了部分Timeline
+	incoming := estimateMessageBytes(msg, copies)
+	if err := s.checkCapacity(incoming); err != nil {
+		return nil, false, err
+	}
+	release, err := s.admitTenantWrite(tenant, incoming, msg.HLC == 0)
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() {
+		if err != nil || duplicate {
+			release()
 		}
+	}()
+	// 本地生成的HLC在Timeline锁内随SeqID一起分配
+	local := msg.HLC == 0
+	if !local {
+		s.observeHLC(msg.HLC)
 	}
 
-	// 持久化Timeline元数据
-	if err := s.saveTimelineMetadata(convTL); err != nil {
-		return err
+	// 添加到会话时间线，并发的重试在Timeline锁内再次去重
+	if err := convTL.AddMessage(msg, s); err != nil {
+		var dup *duplicateMessageError
+		if errors.As(err, &dup) {
+			return dup.existing, true, nil
+		}
+		return nil, false, err
 	}
 
-	for _, userID := range userIDs {
-		userTL := s.GetOrCreateUserTimeline(userID)
-		if err := s.saveTimelineMetadata(userTL); err != nil {
-			return err
+	// 持久化会话Timeline元数据，之后写入相关用户的时间线
+	if err := s.saveTimelineMetadata(convTL); err != nil {
+		return nil, false, err
+	}
+	if err := s.recordFanout(msg.ConvID, msg.SeqID, userIDs, pull); err != nil {
+		return nil, false, err
+	}
+	if !pull {
+		if err := s.fanOutToUsers(msg, userIDs); err != nil {
+			return nil, false, err
 		}
 	}
 
-	return nil
+	if review != nil {
+		s.reviewAsync(review, msg, userIDs)
+	}
+	if local {
+		s.publishMessageCreated(msg)
+	}
+	return msg, false, nil
 }
 
 // GetUserCheckpoint 获取用户的 checkpoint
@@ -197,71 +758,152 @@ func (s *Store) GetUserCheckpoint(userID string) int64 {
 	return s.UserCheckpoints[userID]
 }
 
-// UpdateUserCheckpoint 更新用户的 checkpoint
+// UpdateUserCheckpoint 更新用户的 checkpoint 并写入checkpoint日志
+// seqID超出用户时间线、属于GetMessagesAfterCheckpoint返回的拉模式消息时推进对应会话的游标
 func (s *Store) UpdateUserCheckpoint(userID string, seqID int64) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.UserCheckpoints[userID] = seqID
+	if err := s.UpdateCheckpoints([]CheckpointUpdate{{Kind: CheckpointUser, UserID: userID, SeqID: seqID}}); err != nil {
+		log.Printf("store %s: failed to update checkpoint of %s: %v", s.StoreID, userID, err)
+	}
+}
+
+// GetConvCheckpoint 获取用户在会话中的已读位置
+func (s *Store) GetConvCheckpoint(userID, convID string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ConvCheckpoints[userID][convID]
+}
+
+// UpdateConvCheckpoint 更新用户在会话中的已读位置，只前进不后退
+func (s *Store) UpdateConvCheckpoint(userID, convID string, seqID int64) {
+	update := CheckpointUpdate{Kind: CheckpointConv, UserID: userID, ConvID: convID, SeqID: seqID}
+	if err := s.UpdateCheckpoints([]CheckpointUpdate{update}); err != nil {
+		log.Printf("store %s: failed to update read position of %s in %s: %v", s.StoreID, userID, convID, err)
+	}
+}
+
+// GetUnreadCounts 统计用户各会话的未读消息数，用户自己发送的消息及编辑/删除记录不计入
+// 会话已读位置为会话Timeline中的SeqID，与用户时间线记录的ConvSeqID比较
+func (s *Store) GetUnreadCounts(userID string) map[string]int64 {
+	s.mu.RLock()
+	checkpoints := make(map[string]int64, len(s.ConvCheckpoints[userID]))
+	for convID, seqID := range s.ConvCheckpoints[userID] {
+		checkpoints[convID] = seqID
+	}
+	s.mu.RUnlock()
+
+	userTL := s.GetOrCreateUserTimeline(userID)
+
+	counts := make(map[string]int64)
+	for msg, err := range userTL.readView().forward(s.readBlockMessages, 0) {
+		if err != nil {
+			log.Printf("store %s: failed to count unread messages of %s: %v", s.StoreID, userID, err)
+			break
+		}
+		if msg.Type != MsgTypeNormal || msg.convSeqID() <= checkpoints[msg.ConvID] || strconv.FormatUint(uint64(msg.SenderID), 10) == userID {
+			continue
+		}
+		counts[msg.ConvID]++
+	}
+	if err := s.countPullUnread(userID, checkpoints, counts); err != nil {
+		log.Printf("store %s: failed to count unread messages of %s: %v", s.StoreID, userID, err)
+	}
+	return counts
 }
 
 // GetMessagesAfterCheckpoint 获取用户 checkpoint 之后的消息
+// checkpoint为用户时间线中的SeqID，返回记录的SeqID同样属于用户时间线，会话内位置见ConvSeqID；
+// 用户所在拉模式会话中游标之后的消息接在最后，SeqID依次编在用户时间线记录之后，见pull_fanout.go
 func (s *Store) GetMessagesAfterCheckpoint(userID string) ([]*Message, error) {
 	checkpoint := s.GetUserCheckpoint(userID)
-	userTL := s.GetOrCreateUserTimeline(userID)
-
-	userTL.mu.RLock()
-	defer userTL.mu.RUnlock()
+	result, _, err := s.userMessagesAfter(userID, checkpoint, 0)
+	if err != nil {
+		return nil, err
+	}
+	pulled, err := s.pullMessagesAfter(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(pulled) == 0 {
+		return result, nil
+	}
+	base := checkpoint
+	if len(result) > 0 {
+		base = result[len(result)-1].SeqID
+	}
+	return s.appendPullMessages(userID, result, pulled, base), nil
+}
 
-	var result []*Message
-	// 遍历所有块获取消息
-	for _, block := range userTL.Blocks {
-		block.mu.RLock()
-		for _, msg := range block.Messages {
-			if msg.SeqID > checkpoint {
-				result = append(result, msg)
-			}
-		}
-		block.mu.RUnlock()
+// GetUserMessagesAfter 按SeqID升序获取用户时间线中afterSeq之后的记录
+// limit<=0时不限制条数，第二个返回值表示是否还有未返回的记录
+func (s *Store) GetUserMessagesAfter(userID string, afterSeq int64, limit int) ([]*Message, bool) {
+	result, more, err := s.userMessagesAfter(userID, afterSeq, limit)
+	if err != nil {
+		log.Printf("store %s: failed to read messages of %s: %v", s.StoreID, userID, err)
 	}
+	return result, more
+}
 
-	return result, nil
+// userMessagesAfter 读取用户时间线中afterSeq之后的记录，只读取SeqID范围内的块
+func (s *Store) userMessagesAfter(userID string, afterSeq int64, limit int) ([]*Message, bool, error) {
+	userTL := s.GetOrCreateUserTimeline(userID)
+	// 在只读视图上按SeqID取前limit条，不阻塞并发写入
+	return userTL.readView().ascending(s.readBlockMessages, afterSeq, limit)
+}
+
+// AppendUserEvent 只向用户时间线追加一条记录，用于持久化推送给该用户的事件
+// SeqID由用户时间线分配，与该用户的消息记录共用同一序列
+func (s *Store) AppendUserEvent(userID string, data []byte) (*Message, error) {
+	userTL := s.GetOrCreateUserTimeline(userID)
+	msg := &Message{
+		CreateTime: time.Now(),
+		Data:       data,
+	}
+	if err := s.checkCapacity(estimateMessageBytes(msg, 1)); err != nil {
+		return nil, err
+	}
+	if err := userTL.AddMessage(msg, s); err != nil {
+		return nil, err
+	}
+	if err := s.saveTimelineMetadata(userTL); err != nil {
+		return nil, err
+	}
+	return msg, nil
 }
 
-// GetConvMessages 获取会话的历史消息（分页）
+// GetConvMessages 获取会话的历史消息（分页），beforeSeqID为会话Timeline中的SeqID，0表示从最新消息开始
 func (s *Store) GetConvMessages(convID string, limit int, beforeSeqID int64) ([]*Message, error) {
 	convTL := s.GetOrCreateConvTimeline(convID)
 
-	convTL.mu.RLock()
-	defer convTL.mu.RUnlock()
-
-	var result []*Message
-	count := 0
-
-	// 收集所有消息
-	var allMessages []*Message
-	for _, block := range convTL.Blocks {
-		block.mu.RLock()
-		allMessages = append(allMessages, block.Messages...)
-		block.mu.RUnlock()
+	// 在同一个只读视图上从最新的消息向前收集，并发写入不会让一页中混入不同时刻的内容；
+	// 收集够一页后，SeqID更小的未加载块不会从段文件读取
+	if limit <= 0 {
+		return nil, nil
 	}
-
-	// 从后往前遍历，获取最新的消息
-	for i := len(allMessages) - 1; i >= 0 && count < limit; i-- {
-		msg := allMessages[i]
-		if beforeSeqID == 0 || msg.SeqID < beforeSeqID {
-			result = append([]*Message{msg}, result...) // 保持时间顺序
-			count++
-		}
+	result, err := convTL.readView().descending(s.readBlockMessages, beforeSeqID, limit)
+	if err != nil {
+		return nil, err
 	}
+	slices.Reverse(result) // 保持时间顺序
 
 	return result, nil
 }
 
-// AddMessage 向时间线添加消息
+// AddMessage 向时间线添加消息，在Timeline锁内分配msg的SeqID，msg未携带HLC时同时由本地时钟生成，
+// 保证同一Timeline中本地生成的HLC与SeqID顺序一致
 func (tl *Timeline) AddMessage(msg *Message, store *Store) error {
 	tl.mu.Lock()
 	defer tl.mu.Unlock()
 
+	if existing := tl.dedup.lookup(msg.ClientMsgID, time.Now()); existing != nil {
+		return &duplicateMessageError{existing: existing}
+	}
+
+	// WAL写入失败时不消耗序列号；写入WAL后消息即已提交，之后写满块落盘失败不影响本次写入
+	msg.SeqID = tl.LastSeqID + 1
+	if msg.HLC == 0 {
+		msg.HLC = store.clock.Now()
+	}
+
 	// 如果没有当前块或当前块已满，创建新块
 	if tl.CurrentBlock == nil || tl.CurrentBlock.IsFull {
 		if err := tl.createNewBlock(store); err != nil {
@@ -269,10 +911,27 @@ func (tl *Timeline) AddMessage(msg *Message, store *Store) error {
 		}
 	}
 
+	// 先写WAL，保证未写满的块在崩溃后可恢复
+	if store.wal != nil {
+		if err := store.wal.Append(&walRecord{
+			TimelineType: tl.Type,
+			TimelineID:   tl.ID,
+			BlockID:      tl.CurrentBlock.BlockID,
+			Message:      msg,
+		}); err != nil {
+			return err
+		}
+	}
+
 	// 添加消息到当前块
 	tl.CurrentBlock.mu.Lock()
 	tl.CurrentBlock.Messages = append(tl.CurrentBlock.Messages, msg)
 	tl.CurrentBlock.Size++
+	tl.CurrentBlock.Index.add(msg)
+	tl.CurrentBlock.Filters.add(msg)
+	// 落盘时会临时释放Timeline锁，需在此之前推进LastSeqID并登记去重索引
+	tl.LastSeqID = msg.SeqID
+	store.rememberClientMessages(tl, []*Message{msg})
 
 	// 检查块是否已满
 	var blockToSave *TimelineBlock
@@ -282,18 +941,21 @@ func (tl *Timeline) AddMessage(msg *Message, store *Store) error {
 	}
 	tl.CurrentBlock.mu.Unlock()
 
-	// 在释放Timeline锁之前保存需要持久化的块
+	// 落盘前发布包含该消息的视图；从WAL恢复后当前块可能不是最后一块，此时重建视图
+	if tl.CurrentBlock == tl.Blocks[len(tl.Blocks)-1] {
+		tl.refreshViewLocked()
+	} else {
+		tl.rebuildViewLocked()
+	}
+
+	// 保存写满的块，失败时记录日志稍后重试，消息已在WAL中且已可见，不向调用方返回错误
 	if blockToSave != nil {
 		// 临时释放Timeline锁来避免死锁
 		tl.mu.Unlock()
-		if err := store.saveTimelineBlock(blockToSave); err != nil {
-			tl.mu.Lock() // 重新获取锁以保持defer的一致性
-			return err
-		}
-		tl.mu.Lock() // 重新获取锁
+		store.writeFullBlock(blockToSave)
+		tl.mu.Lock() // 重新获取锁以保持defer的一致性
 	}
 
-	tl.LastSeqID = msg.SeqID
 	return nil
 }
 
@@ -302,16 +964,10 @@ func (tl *Timeline) createNewBlock(store *Store) error {
 	// 生成块ID
 	blockID := fmt.Sprintf("%s_%s_%d", tl.Type, tl.ID, time.Now().UnixNano())
 
-	// 检查Store容量
-	if store.CurrentCapacity >= store.Config.MaxCapacity {
-		return fmt.Errorf("store capacity exceeded")
-	}
-
 	// 创建新块
 	newBlock := &TimelineBlock{
 		BlockID:  blockID,
 		StoreID:  store.StoreID,
-		Offset:   store.CurrentCapacity,
 		Size:     0,
 		Messages: make([]*Message, 0),
 		IsFull:   false,
@@ -329,18 +985,217 @@ func (tl *Timeline) createNewBlock(store *Store) error {
 	// 更新Store索引
 	storeIndex := &StoreIndex{
 		StoreID:   store.StoreID,
+		BlockID:   blockID,
 		Offset:    newBlock.Offset,
 		Size:      0,
 		CreatedAt: time.Now().Unix(),
 	}
 
 	timelineKey := fmt.Sprintf("%s_%s", tl.Type, tl.ID)
+	store.indexMu.Lock()
 	store.StoreIndex[timelineKey] = append(store.StoreIndex[timelineKey], storeIndex)
 	store.TimelineBlocks[blockID] = newBlock
+	store.indexMu.Unlock()
 
 	return nil
 }
 
+// ImportTimelineBlock 导入一个从其他Store迁移来的块，保留原有块ID与消息SeqID
+// 同一块重复导入时校验和一致即视为成功（返回false），便于迁移中断后续传；
+// 导入的块视为已写满，之后的写入会创建新块。Timeline存在本地活跃块时拒绝导入
+func (s *Store) ImportTimelineBlock(timelineType, timelineID string, data *TimelineBlockData) (bool, error) {
+	if data.Block == nil || data.Block.BlockID == "" {
+		return false, fmt.Errorf("block id is required")
+	}
+
+	var tl *Timeline
+	switch timelineType {
+	case "", "conv":
+		tl = s.GetOrCreateConvTimeline(timelineID)
+	case "user":
+		tl = s.GetOrCreateUserTimeline(timelineID)
+	default:
+		return false, fmt.Errorf("invalid timeline type: %s", timelineType)
+	}
+
+	checksum := blockChecksum(data.Messages)
+	count := int64(len(data.Messages))
+
+	tl.mu.Lock()
+	for _, existing := range tl.Blocks {
+		if existing.BlockID != data.Block.BlockID {
+			continue
+		}
+		tl.mu.Unlock()
+		existing.mu.RLock()
+		matched := existing.Checksum == checksum && existing.Size == count
+		existing.mu.RUnlock()
+		if !matched {
+			return false, fmt.Errorf("block %s already exists with different content", data.Block.BlockID)
+		}
+		return false, nil
+	}
+	if tl.CurrentBlock != nil {
+		tl.mu.Unlock()
+		return false, fmt.Errorf("timeline %s_%s has local writes", tl.Type, tl.ID)
+	}
+	var incoming int64
+	for _, msg := range data.Messages {
+		incoming += estimateMessageBytes(msg, 1)
+	}
+	if err := s.checkCapacity(incoming); err != nil {
+		tl.mu.Unlock()
+		return false, err
+	}
+
+	block := &TimelineBlock{
+		BlockID:  data.Block.BlockID,
+		StoreID:  s.StoreID,
+		Messages: data.Messages,
+		Size:     count,
+		IsFull:   true,
+		Index:    buildBlockIndex(data.Messages),
+	}
+	var lastSeqID int64
+	for _, msg := range data.Messages {
+		if msg.SeqID > lastSeqID {
+			lastSeqID = msg.SeqID
+		}
+	}
+
+	// 与createNewBlock一致，在Timeline锁内登记Store索引
+	timelineKey := fmt.Sprintf("%s_%s", tl.Type, tl.ID)
+	s.indexMu.Lock()
+	s.StoreIndex[timelineKey] = append(s.StoreIndex[timelineKey], &StoreIndex{
+		StoreID:   s.StoreID,
+		BlockID:   block.BlockID,
+		CreatedAt: time.Now().Unix(),
+	})
+	s.indexMu.Unlock()
+
+	if err := s.writeTimelineBlock(block); err != nil {
+		s.indexMu.Lock()
+		s.StoreIndex[timelineKey] = removeStoreIndex(s.StoreIndex[timelineKey], block.BlockID)
+		s.indexMu.Unlock()
+		tl.mu.Unlock()
+		return false, err
+	}
+
+	if len(tl.Blocks) > 0 {
+		tl.Blocks[len(tl.Blocks)-1].NextBlock = block
+	}
+	tl.Blocks = append(tl.Blocks, block)
+	if lastSeqID > tl.LastSeqID {
+		tl.LastSeqID = lastSeqID
+	}
+	// 迁移后客户端的重试仍能被识别
+	s.rememberClientMessages(tl, data.Messages)
+	tl.rebuildViewLocked()
+	tl.mu.Unlock()
+
+	s.indexMu.Lock()
+	s.TimelineBlocks[block.BlockID] = block
+	s.indexMu.Unlock()
+
+	// 保证之后生成的HLC大于导入的消息
+	for _, msg := range data.Messages {
+		s.observeHLC(msg.HLC)
+	}
+	s.tenants.invalidate(TenantOf(timelineID))
+
+	if err := s.saveTimelineMetadata(tl); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeleteTimeline 删除Timeline及其全部块、索引、WAL记录和元数据文件
+// Timeline未加载时按元数据文件或WAL加载后删除，不存在时返回false
+func (s *Store) DeleteTimeline(timelineType, timelineID string) (bool, error) {
+	return s.deleteTimeline(timelineType, timelineID, nil)
+}
+
+// deleteTimeline 删除Timeline，beforeDelete在持有Store锁与Timeline锁、删除任何数据之前调用，返回错误时放弃删除
+func (s *Store) deleteTimeline(timelineType, timelineID string, beforeDelete func(tl *Timeline) error) (bool, error) {
+	tl := &Timeline{ID: timelineID, Type: timelineType}
+	timelineKey := fmt.Sprintf("%s_%s", timelineType, timelineID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	timelines := s.ConvTimelines
+	if timelineType == "user" {
+		timelines = s.UserTimelines
+	}
+	_, pending := s.walPending[timelineKey]
+	if loaded, exists := timelines[timelineID]; exists {
+		tl = loaded
+	} else if pending || s.hasTimelineMetadata(timelineKey) {
+		if err := s.loadTimeline(tl); err != nil {
+			return false, err
+		}
+	} else {
+		return false, nil
+	}
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	if beforeDelete != nil {
+		if err := beforeDelete(tl); err != nil {
+			return false, err
+		}
+	}
+
+	for _, block := range tl.Blocks {
+		if s.segments.HasBlock(block.BlockID) {
+			if err := s.segments.DeleteBlock(block.BlockID); err != nil {
+				return false, err
+			}
+		}
+		s.dropTieredBlock(block.BlockID)
+		s.indexMu.Lock()
+		delete(s.TimelineBlocks, block.BlockID)
+		s.indexMu.Unlock()
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Compact(func(record *walRecord) bool {
+			return record.timelineKey() != timelineKey && !s.walRecordPersisted(record)
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	delete(timelines, timelineID)
+	s.indexMu.Lock()
+	delete(s.StoreIndex, timelineKey)
+	s.indexMu.Unlock()
+	delete(s.walPending, timelineKey)
+	tl.Blocks = nil
+	tl.CurrentBlock = nil
+	tl.rebuildViewLocked()
+	s.tenants.invalidate(TenantOf(timelineID))
+
+	err := s.metadata.Update(func(tx MetadataTx) error {
+		tx.Delete(metaBucketTimelines, timelineKey)
+		if s.metadataKV() {
+			tx.Delete(metaBucketIndex, timelineKey)
+			tx.DeleteBucket(dedupBucket(timelineID))
+		}
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	if timelineType == "conv" {
+		if err := s.dropConvFanout(timelineID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
 // 元数据文件路径生成
 func (s *Store) getTimelineMetaFilePath(tl *Timeline) string {
 	filename := fmt.Sprintf("%s_%s.meta", tl.Type, tl.ID)
@@ -353,80 +1208,75 @@ func (s *Store) getStoreFilePath() string {
 	return filepath.Join(s.Config.DataDir, filename)
 }
 
-// Timeline块文件路径生成
-func (s *Store) getTimelineBlockFilePath(blockID string) string {
-	filename := fmt.Sprintf("block_%s.gob", blockID)
-	return filepath.Join(s.Config.DataDir, filename)
+// writeTimelineBlock 将块中的消息写入段文件（覆盖该块之前的记录），并记录块的实际位置
+func (s *Store) writeTimelineBlock(block *TimelineBlock) error {
+	block.mu.Lock()
+	defer block.mu.Unlock()
+	return s.writeTimelineBlockLocked(block)
 }
 
-// saveTimelineBlock 保存Timeline块到文件
-func (s *Store) saveTimelineBlock(block *TimelineBlock) error {
-	block.mu.RLock()
-	defer block.mu.RUnlock()
-
-	filePath := s.getTimelineBlockFilePath(block.BlockID)
-
-	// 打开文件进行写入
-	file, err := os.Create(filePath)
+// writeTimelineBlockLocked 将块写入段文件，调用方持有块的写锁
+// 同一块的写入在块锁内串行，后写入的记录总是包含之前记录中的全部消息
+func (s *Store) writeTimelineBlockLocked(block *TimelineBlock) error {
+	if block.lazy {
+		return fmt.Errorf("block %s is not loaded", block.BlockID)
+	}
+	// 块内消息可能被保留策略裁剪过，落盘时按实际内容重算索引与过滤器
+	meta := blockMeta{Index: buildBlockIndex(block.Messages)}
+	if s.Config.BlockBloomFilters {
+		meta.Filters = buildBlockFilters(block.Messages, int(s.Config.TimelineMaxSize), s.Config.BloomBitsPerKey)
+	}
+	location, err := s.segments.WriteBlockWithMeta(block.BlockID, block.Messages, meta)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	// 创建gob编码器
-	encoder := gob.NewEncoder(file)
-
-	// 保存所有消息
-	for _, msg := range block.Messages {
-		if err := encoder.Encode(msg); err != nil {
-			return err
+	block.SegmentID = location.SegmentID
+	block.Offset = location.Offset
+	block.Checksum = location.Checksum
+	block.Index = meta.Index
+	block.Filters = meta.Filters
+	// 重新写入段文件的块不再使用对象存储中的副本
+	s.dropTieredBlock(block.BlockID)
+
+	// 更新Store索引中的位置信息
+	timelineKey := blockTimelineKey(block.BlockID)
+	s.indexMu.Lock()
+	for _, index := range s.StoreIndex[timelineKey] {
+		if index.BlockID == block.BlockID {
+			index.SegmentID = location.SegmentID
+			index.Offset = location.Offset
+			index.Size = location.Length
 		}
 	}
-
-	// 更新Store容量
-	s.CurrentCapacity += block.Size
+	s.indexMu.Unlock()
 
 	return nil
 }
 
-// loadTimelineBlock 从文件加载Timeline块
+// loadTimelineBlock 从段文件加载Timeline块，块不存在时返回nil
 func (s *Store) loadTimelineBlock(blockID string) (*TimelineBlock, error) {
-	filePath := s.getTimelineBlockFilePath(blockID)
-
-	file, err := os.Open(filePath)
+	messages, meta, exists, err := s.segments.ReadBlockWithMeta(blockID)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // 文件不存在
-		}
 		return nil, err
 	}
-	defer file.Close()
-
-	// 创建gob解码器
-	decoder := gob.NewDecoder(file)
-
-	var messages []*Message
-	// 逐个解码消息
-	for {
-		var msg Message
-		err := decoder.Decode(&msg)
-		if err != nil {
-			if err.Error() == "EOF" {
-				break // 文件结束
-			}
-			return nil, err
-		}
-
-		messages = append(messages, &msg)
+	if !exists {
+		return nil, nil
 	}
+	location, _ := s.segments.Location(blockID)
 
 	// 创建Timeline块
 	block := &TimelineBlock{
-		BlockID:  blockID,
-		StoreID:  s.StoreID,
-		Messages: messages,
-		Size:     int64(len(messages)),
-		IsFull:   true, // 从文件加载的块默认为已满
+		BlockID:   blockID,
+		StoreID:   s.StoreID,
+		SegmentID: location.SegmentID,
+		Offset:    location.Offset,
+		Messages:  messages,
+		Size:      int64(len(messages)),
+		IsFull:    true, // 从文件加载的块默认为已满
+		Checksum:  blockChecksum(messages),
+		Index:     meta.Index,
+		Filters:   meta.Filters,
 	}
 
 	return block, nil
@@ -438,22 +1288,32 @@ func (s *Store) saveTimeline(tl *Timeline) error {
 	return s.saveTimelineMetadata(tl)
 }
 
+// timelineMetadata Timeline元数据文件 {type}_{id}.meta 的内容
+type timelineMetadata struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	LastSeqID int64    `json:"last_seq_id"`
+	BlockIDs  []string `json:"block_ids"`
+	// 未写满的活跃块，后台刷盘写入后重新加载时继续向其追加消息
+	CurrentBlockID string `json:"current_block_id,omitempty"`
+}
+
 // saveTimelineMetadata 保存时间线元数据
 func (s *Store) saveTimelineMetadata(tl *Timeline) error {
+	tl.metaMu.Lock()
+	defer tl.metaMu.Unlock()
 	tl.mu.RLock()
 	defer tl.mu.RUnlock()
 
-	metadata := struct {
-		ID        string   `json:"id"`
-		Type      string   `json:"type"`
-		LastSeqID int64    `json:"last_seq_id"`
-		BlockIDs  []string `json:"block_ids"`
-	}{
+	metadata := timelineMetadata{
 		ID:        tl.ID,
 		Type:      tl.Type,
 		LastSeqID: tl.LastSeqID,
 		BlockIDs:  make([]string, 0),
 	}
+	if tl.CurrentBlock != nil && !tl.CurrentBlock.IsFull {
+		metadata.CurrentBlockID = tl.CurrentBlock.BlockID
+	}
 
 	// 收集所有块ID
 	for _, block := range tl.Blocks {
@@ -465,8 +1325,18 @@ func (s *Store) saveTimelineMetadata(tl *Timeline) error {
 		return err
 	}
 
-	metaPath := s.getTimelineMetaFilePath(tl)
-	return os.WriteFile(metaPath, data, 0644)
+	key := fmt.Sprintf("%s_%s", tl.Type, tl.ID)
+	err = s.metadata.Update(func(tx MetadataTx) error {
+		tx.Put(metaBucketTimelines, key, data)
+		if s.metadataKV() {
+			return s.putTimelineIndexes(tx, tl, key)
+		}
+		return nil
+	})
+	if err == nil && tl.dedup != nil {
+		tl.dedup.added, tl.dedup.evicted = nil, nil
+	}
+	return err
 }
 
 // loadTimeline 从文件加载时间线
@@ -477,19 +1347,186 @@ func (s *Store) loadTimeline(tl *Timeline) error {
 	}
 
 	// 加载所有块
-	return s.loadTimelineBlocks(tl)
+	if err := s.loadTimelineBlocks(tl); err != nil {
+		return err
+	}
+
+	// 从WAL恢复未落盘的块
+	if err := s.recoverTimelineFromWAL(tl); err != nil {
+		return err
+	}
+
+	if !s.loadDedupIndex(tl) {
+		s.rebuildDedupIndex(tl)
+	}
+	tl.rebuildViewLocked()
+	return nil
 }
 
-// loadTimelineMetadata 加载时间线元数据
-func (s *Store) loadTimelineMetadata(tl *Timeline) error {
-	metaPath := s.getTimelineMetaFilePath(tl)
+// recoverTimelineFromWAL 用WAL中暂存的记录重建Timeline未落盘的块
+func (s *Store) recoverTimelineFromWAL(tl *Timeline) error {
+	key := fmt.Sprintf("%s_%s", tl.Type, tl.ID)
+	records := s.walPending[key]
+	if len(records) == 0 {
+		return nil
+	}
+	delete(s.walPending, key)
 
-	data, err := os.ReadFile(metaPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // 文件不存在，使用默认值
+	loaded := make(map[string]*TimelineBlock, len(tl.Blocks))
+	for _, block := range tl.Blocks {
+		loaded[block.BlockID] = block
+	}
+
+	recovered := make(map[string]*TimelineBlock)
+	order := make([]*TimelineBlock, 0)
+	var extended []*TimelineBlock
+	for _, record := range records {
+		block, exists := loaded[record.BlockID]
+		if exists {
+			// 后台刷盘只写入了块的前一部分消息，之后的消息从WAL补齐
+			if record.Message.SeqID <= block.Index.MaxSeqID {
+				continue
+			}
+			if err := s.loadBlocks([]*TimelineBlock{block}); err != nil {
+				return err
+			}
+			if !slices.Contains(extended, block) {
+				extended = append(extended, block)
+			}
+		} else if block, exists = recovered[record.BlockID]; !exists {
+			block = &TimelineBlock{
+				BlockID:  record.BlockID,
+				StoreID:  s.StoreID,
+				Messages: make([]*Message, 0),
+			}
+			recovered[record.BlockID] = block
+			order = append(order, block)
 		}
-		return err
+		block.Messages = append(block.Messages, record.Message)
+		block.Size++
+		block.Index.add(record.Message)
+		block.Filters.add(record.Message)
+		if record.Message.SeqID > tl.LastSeqID {
+			tl.LastSeqID = record.Message.SeqID
+		}
+	}
+
+	for _, block := range order {
+		tl.Blocks = append(tl.Blocks, block)
+		s.indexMu.Lock()
+		s.TimelineBlocks[block.BlockID] = block
+		s.indexMu.Unlock()
+	}
+
+	// 崩溃前已写满但未来得及落盘的块，补写块文件
+	for _, block := range slices.Concat(order, extended) {
+		block.IsFull = block.Size >= s.Config.TimelineMaxSize
+		if block.IsFull {
+			if err := s.writeTimelineBlock(block); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 段文件中损坏的块，WAL保留了块落盘以来的全部消息时直接重写；
+	// 否则只恢复了部分消息，保持损坏状态等待从副本恢复
+	for _, block := range order {
+		if !s.segments.IsCorrupt(block.BlockID) {
+			continue
+		}
+		gap, err := tl.corruptGapLocked(block.BlockID)
+		if err != nil || gap.validate(block.Messages) != nil {
+			log.Printf("store %s: WAL holds only part of corrupt block %s", s.StoreID, block.BlockID)
+			continue
+		}
+		if err := s.writeTimelineBlock(block); err != nil {
+			return err
+		}
+		log.Printf("store %s: recovered corrupt block %s from WAL", s.StoreID, block.BlockID)
+	}
+
+	tl.sortBlocksLocked()
+	return nil
+}
+
+// sortBlocksLocked 按首条消息的SeqID恢复块顺序并重建链接，调用方持有Timeline写锁
+func (tl *Timeline) sortBlocksLocked() {
+	sort.SliceStable(tl.Blocks, func(i, j int) bool {
+		return firstSeqID(tl.Blocks[i]) < firstSeqID(tl.Blocks[j])
+	})
+	tl.CurrentBlock = nil
+	for i, block := range tl.Blocks {
+		block.NextBlock = nil
+		if i > 0 {
+			tl.Blocks[i-1].NextBlock = block
+		}
+		if !block.IsFull {
+			tl.CurrentBlock = block
+		}
+	}
+}
+
+// firstSeqID 块中首条消息的SeqID，空块排在最后
+func firstSeqID(block *TimelineBlock) int64 {
+	if block.lazy {
+		return block.Index.MinSeqID
+	}
+	if len(block.Messages) == 0 {
+		return int64(^uint64(0) >> 1)
+	}
+	return block.Messages[0].SeqID
+}
+
+// blockChecksum 按顺序计算块内消息的CRC32校验和，用于迁移前后的数据校验
+func blockChecksum(messages []*Message) uint32 {
+	hash := crc32.NewIEEE()
+	hashMessages(hash, messages)
+	return hash.Sum32()
+}
+
+// hashMessages 按顺序将块内消息的各字段写入hash
+func hashMessages(hash hash.Hash32, messages []*Message) {
+	buf := make([]byte, 8)
+	for _, msg := range messages {
+		binary.BigEndian.PutUint64(buf, uint64(msg.SeqID))
+		hash.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(msg.SenderID))
+		hash.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(msg.CreateTime.UnixNano()))
+		hash.Write(buf)
+		hash.Write(msg.Data)
+		// 编辑/删除记录额外校验类型和引用，普通消息的校验和保持不变
+		if msg.Type != MsgTypeNormal {
+			binary.BigEndian.PutUint64(buf, uint64(msg.Type))
+			hash.Write(buf)
+			binary.BigEndian.PutUint64(buf, uint64(msg.RefSeqID))
+			hash.Write(buf)
+		}
+		// 会话位置与HLC只在存在时参与校验，兼容之前写入的块
+		if msg.ConvSeqID != 0 || msg.HLC != 0 {
+			binary.BigEndian.PutUint64(buf, uint64(msg.ConvSeqID))
+			hash.Write(buf)
+			binary.BigEndian.PutUint64(buf, uint64(msg.HLC))
+			hash.Write(buf)
+		}
+		// 没有clientMsgID和提及用户时不写入任何内容
+		hash.Write([]byte(msg.ClientMsgID))
+		for _, mention := range msg.Mentions {
+			hash.Write([]byte(mention))
+		}
+		// 回复关系只在存在时参与校验
+		if msg.ReplyToSeqID != 0 {
+			binary.BigEndian.PutUint64(buf, uint64(msg.ReplyToSeqID))
+			hash.Write(buf)
+		}
+	}
+}
+
+// loadTimelineMetadata 加载时间线元数据
+func (s *Store) loadTimelineMetadata(tl *Timeline) error {
+	data, err := s.readTimelineMetadata(fmt.Sprintf("%s_%s", tl.Type, tl.ID))
+	if err != nil || data == nil {
+		return err // 元数据不存在时使用默认值
 	}
 
 	var metadata struct {
@@ -506,49 +1543,53 @@ func (s *Store) loadTimelineMetadata(tl *Timeline) error {
 	tl.LastSeqID = metadata.LastSeqID
 	// 存储块ID信息，稍后用于加载块
 
-	// 更新全局序列号生成器
-	if metadata.LastSeqID > atomic.LoadInt64(&s.seqGenerator) {
-		atomic.StoreInt64(&s.seqGenerator, metadata.LastSeqID)
-	}
-
 	return nil
 }
 
-// loadTimelineBlocks 加载时间线的所有块
+// loadTimelineBlocks 加载时间线的块列表，已写满的块只建立占位，见block_loader.go
 func (s *Store) loadTimelineBlocks(tl *Timeline) error {
 	// 从元数据中获取块ID列表
-	metaPath := s.getTimelineMetaFilePath(tl)
-
-	data, err := os.ReadFile(metaPath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil // 文件不存在
-		}
+	data, err := s.readTimelineMetadata(fmt.Sprintf("%s_%s", tl.Type, tl.ID))
+	if err != nil || data == nil {
 		return err
 	}
 
 	var metadata struct {
-		BlockIDs []string `json:"block_ids"`
+		BlockIDs       []string `json:"block_ids"`
+		CurrentBlockID string   `json:"current_block_id"`
 	}
 
 	if err := json.Unmarshal(data, &metadata); err != nil {
 		return err
 	}
 
-	// 加载每个块
+	// 已写满的块只建立占位，消息在读取时按需加载；后台刷盘写入的未写满块仍是活跃块，读入内存
 	for _, blockID := range metadata.BlockIDs {
-		block, err := s.loadTimelineBlock(blockID)
-		if err != nil {
-			return err
+		block := s.newLazyBlock(blockID)
+		if block != nil && blockID == metadata.CurrentBlockID && block.Size < s.Config.TimelineMaxSize {
+			if block, err = s.loadTimelineBlock(blockID); err != nil {
+				return err
+			}
+			if block != nil {
+				block.IsFull = false
+			}
 		}
 		if block != nil {
 			tl.Blocks = append(tl.Blocks, block)
+			s.indexMu.Lock()
 			s.TimelineBlocks[blockID] = block
+			s.indexMu.Unlock()
 
 			// 设置当前块（最后一个未满的块）
 			if !block.IsFull {
 				tl.CurrentBlock = block
 			}
+			// 块已落盘但元数据未及保存时，以块索引为准，避免重复分配SeqID
+			if block.Index.MaxSeqID > tl.LastSeqID {
+				tl.LastSeqID = block.Index.MaxSeqID
+			}
+			// 重启后物理时钟可能回拨，推进HLC使新消息排在已落盘的消息之后
+			s.observeHLC(block.Index.MaxHLC)
 		}
 	}
 
@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 热点Timeline拆分
+// 单个超大群聊的写入集中在一个Store上，重平衡只能整体迁移，无法分摊它的负载。HotTimelineDetector按滑动窗口
+// 统计每个Timeline的消息速率和写入字节速率，超过阈值的视为热点；TimelineSplitter把热点会话Timeline按时间拆分：
+// 在另一个Store上创建子Timeline，此后的写入进入最新的子Timeline，已有消息留在原处不移动。
+// 各段的时间范围记录在全局索引的拆分表中，DistributedStoreAccessor读取时按时间范围合并各段的消息，
+// 返回的消息ConvID为原Timeline键；SeqID只在各段内唯一，跨段按创建时间排序。
+
+// timelineSplitGrace 拆分前已确定写入目标的请求可能在拆分后才写入旧段，读取时旧段的结束时间按此放宽
+const timelineSplitGrace = time.Minute
+
+// TimelineSegment 拆分后Timeline的一段
+type TimelineSegment struct {
+	TimelineKey string    `json:"timelineKey"`       // 该段的Timeline键，第一段为原Timeline
+	StoreID     string    `json:"storeId"`           // 该段所在的Store
+	StartTime   time.Time `json:"startTime"`         // 第一段为零值
+	EndTime     time.Time `json:"endTime,omitempty"` // 零值表示仍在写入的最后一段
+}
+
+// TimelineSplitIndex 记录Timeline拆分表的全局索引
+type TimelineSplitIndex interface {
+	// GetTimelineSplits 获取Timeline按时间排列的各段，未拆分时返回nil
+	GetTimelineSplits(ctx context.Context, timelineKey string) ([]*TimelineSegment, error)
+	// SetTimelineSplits 替换Timeline的拆分表，并通知该Timeline的监听者
+	SetTimelineSplits(ctx context.Context, timelineKey string, segments []*TimelineSegment) error
+}
+
+// subTimelineKey 第n段的Timeline键
+func subTimelineKey(timelineKey string, n int) string {
+	return fmt.Sprintf("%s#split%d", timelineKey, n)
+}
+
+// segmentsInRange 返回与时间范围（秒）重叠的段
+func segmentsInRange(segments []*TimelineSegment, startTime, endTime int64) []*TimelineSegment {
+	var result []*TimelineSegment
+	for _, segment := range segments {
+		if !segment.StartTime.IsZero() && segment.StartTime.Unix() > endTime {
+			continue
+		}
+		if !segment.EndTime.IsZero() && segment.EndTime.Add(timelineSplitGrace).Unix() < startTime {
+			continue
+		}
+		result = append(result, segment)
+	}
+	return result
+}
+
+// copySegments 复制拆分表，调用方修改返回值不影响原表
+func copySegments(segments []*TimelineSegment) []*TimelineSegment {
+	if segments == nil {
+		return nil
+	}
+	result := make([]*TimelineSegment, len(segments))
+	for i, segment := range segments {
+		copied := *segment
+		result[i] = &copied
+	}
+	return result
+}
+
+// HotTimelineConfig 热点判定阈值，速率为0的项不参与判定
+type HotTimelineConfig struct {
+	Window               time.Duration `json:"window"`                  // 统计窗口
+	MaxMessagesPerSecond float64       `json:"max_messages_per_second"` // 消息速率上限
+	MaxBytesPerSecond    float64       `json:"max_bytes_per_second"`    // 写入字节速率上限
+}
+
+// DefaultHotTimelineConfig 默认热点判定阈值
+func DefaultHotTimelineConfig() HotTimelineConfig {
+	return HotTimelineConfig{
+		Window:               time.Minute,
+		MaxMessagesPerSecond: 200,
+		MaxBytesPerSecond:    1024 * 1024,
+	}
+}
+
+// HotTimelineStat 热点Timeline的写入统计
+type HotTimelineStat struct {
+	TimelineKey       string  `json:"timeline_key"`
+	MessagesPerSecond float64 `json:"messages_per_second"`
+	BytesPerSecond    float64 `json:"bytes_per_second"`
+	TotalBytes        int64   `json:"total_bytes"` // 开始统计以来写入的字节数
+}
+
+// timelineRate 一个Timeline当前和上一个窗口的写入量
+type timelineRate struct {
+	windowStart time.Time
+	messages    float64
+	bytes       float64
+	prevMessage float64
+	prevBytes   float64
+	totalBytes  int64
+}
+
+// HotTimelineDetector 统计每个Timeline的写入速率
+// 速率按上一个窗口的剩余比例加当前窗口估算，连续两个窗口没有写入的Timeline不再统计
+type HotTimelineDetector struct {
+	mu        sync.Mutex
+	config    HotTimelineConfig
+	timelines map[string]*timelineRate
+	now       func() time.Time
+}
+
+// NewHotTimelineDetector 创建热点检测器
+func NewHotTimelineDetector(config HotTimelineConfig) *HotTimelineDetector {
+	if config.Window <= 0 {
+		config.Window = DefaultHotTimelineConfig().Window
+	}
+	return &HotTimelineDetector{
+		config:    config,
+		timelines: make(map[string]*timelineRate),
+		now:       time.Now,
+	}
+}
+
+// Observe 记录一次写入
+func (h *HotTimelineDetector) Observe(timelineKey string, size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	rate, exists := h.timelines[timelineKey]
+	if !exists {
+		rate = &timelineRate{windowStart: now}
+		h.timelines[timelineKey] = rate
+	}
+	h.advance(rate, now)
+	rate.messages++
+	rate.bytes += float64(size)
+	rate.totalBytes += int64(size)
+}
+
+// Stat 返回Timeline当前的写入统计，没有统计时返回nil
+func (h *HotTimelineDetector) Stat(timelineKey string) *HotTimelineStat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	rate, exists := h.timelines[timelineKey]
+	if !exists {
+		return nil
+	}
+	return h.stat(timelineKey, rate, h.now())
+}
+
+// Hot 返回超过阈值的Timeline，按消息速率从高到低排列
+func (h *HotTimelineDetector) Hot() []*HotTimelineStat {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	var result []*HotTimelineStat
+	for timelineKey, rate := range h.timelines {
+		h.advance(rate, now)
+		if rate.messages == 0 && rate.prevMessage == 0 {
+			delete(h.timelines, timelineKey)
+			continue
+		}
+		stat := h.stat(timelineKey, rate, now)
+		if (h.config.MaxMessagesPerSecond > 0 && stat.MessagesPerSecond > h.config.MaxMessagesPerSecond) ||
+			(h.config.MaxBytesPerSecond > 0 && stat.BytesPerSecond > h.config.MaxBytesPerSecond) {
+			result = append(result, stat)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].MessagesPerSecond > result[j].MessagesPerSecond
+	})
+	return result
+}
+
+// Reset 清除Timeline的统计，拆分后重新开始统计
+func (h *HotTimelineDetector) Reset(timelineKey string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.timelines, timelineKey)
+}
+
+// advance 把窗口推进到now所在的窗口
+func (h *HotTimelineDetector) advance(rate *timelineRate, now time.Time) {
+	elapsed := now.Sub(rate.windowStart)
+	if elapsed < h.config.Window {
+		return
+	}
+	windows := elapsed / h.config.Window
+	if windows == 1 {
+		rate.prevMessage, rate.prevBytes = rate.messages, rate.bytes
+	} else {
+		rate.prevMessage, rate.prevBytes = 0, 0
+	}
+	rate.messages, rate.bytes = 0, 0
+	rate.windowStart = rate.windowStart.Add(windows * h.config.Window)
+}
+
+func (h *HotTimelineDetector) stat(timelineKey string, rate *timelineRate, now time.Time) *HotTimelineStat {
+	h.advance(rate, now)
+	remaining := 1 - float64(now.Sub(rate.windowStart))/float64(h.config.Window)
+	seconds := h.config.Window.Seconds()
+	return &HotTimelineStat{
+		TimelineKey:       timelineKey,
+		MessagesPerSecond: (rate.prevMessage*remaining + rate.messages) / seconds,
+		BytesPerSecond:    (rate.prevBytes*remaining + rate.bytes) / seconds,
+		TotalBytes:        rate.totalBytes,
+	}
+}
+
+// TimelineSplitter 拆分Timeline并维护拆分表
+type TimelineSplitter struct {
+	mu       sync.Mutex
+	accessor *DistributedStoreAccessor
+	splits   TimelineSplitIndex
+	shards   ShardManager
+	detector *HotTimelineDetector
+	stopCh   chan struct{}
+	running  bool
+}
+
+// NewTimelineSplitter 创建拆分器，shards用于为热点Timeline选择目标Store，为nil时只能通过Split指定目标
+func NewTimelineSplitter(accessor *DistributedStoreAccessor, splits TimelineSplitIndex, shards ShardManager, detector *HotTimelineDetector) *TimelineSplitter {
+	return &TimelineSplitter{
+		accessor: accessor,
+		splits:   splits,
+		shards:   shards,
+		detector: detector,
+	}
+}
+
+// Split 在targetStoreID上创建新的一段，此后的写入进入新段
+func (s *TimelineSplitter) Split(ctx context.Context, timelineKey, targetStoreID string) (*TimelineSegment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.segments(ctx, timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	last := segments[len(segments)-1]
+	if last.StoreID == targetStoreID {
+		return nil, fmt.Errorf("timeline %s is already written on store %s", timelineKey, targetStoreID)
+	}
+
+	segment := &TimelineSegment{
+		TimelineKey: subTimelineKey(timelineKey, len(segments)),
+		StoreID:     targetStoreID,
+	}
+	if err := s.accessor.createTimelineOn(ctx, targetStoreID, segment.TimelineKey, "conv"); err != nil {
+		return nil, fmt.Errorf("failed to create segment %s: %w", segment.TimelineKey, err)
+	}
+
+	now := time.Now()
+	last.EndTime = now
+	segment.StartTime = now
+	segments = append(segments, segment)
+	if err := s.splits.SetTimelineSplits(ctx, timelineKey, segments); err != nil {
+		return nil, fmt.Errorf("failed to save splits of %s: %w", timelineKey, err)
+	}
+	log.Printf("split timeline %s: writes move from store %s to %s as %s", timelineKey, last.StoreID, targetStoreID, segment.TimelineKey)
+	return segment, nil
+}
+
+// SplitHotTimelines 拆分检测到的热点Timeline，返回新创建的段
+func (s *TimelineSplitter) SplitHotTimelines(ctx context.Context) ([]*TimelineSegment, error) {
+	if s.detector == nil || s.shards == nil {
+		return nil, fmt.Errorf("hot timeline splitting needs a detector and a shard manager")
+	}
+
+	var created []*TimelineSegment
+	for _, hot := range s.detector.Hot() {
+		segments, err := s.segments(ctx, hot.TimelineKey)
+		if err != nil {
+			log.Printf("skip splitting hot timeline %s: %v", hot.TimelineKey, err)
+			continue
+		}
+		target, err := s.chooseTarget(ctx, hot.TimelineKey, segments[len(segments)-1].StoreID)
+		if err != nil {
+			log.Printf("skip splitting hot timeline %s: %v", hot.TimelineKey, err)
+			continue
+		}
+		segment, err := s.Split(ctx, hot.TimelineKey, target)
+		if err != nil {
+			log.Printf("failed to split hot timeline %s (%.1f msg/s): %v", hot.TimelineKey, hot.MessagesPerSecond, err)
+			continue
+		}
+		s.detector.Reset(hot.TimelineKey)
+		created = append(created, segment)
+	}
+	return created, nil
+}
+
+// Start 每隔interval检查并拆分热点Timeline
+func (s *TimelineSplitter) Start(ctx context.Context, interval time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("timeline splitter is already running")
+	}
+	s.stopCh = make(chan struct{})
+	s.running = true
+
+	go func(stopCh chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				if _, err := s.SplitHotTimelines(ctx); err != nil {
+					log.Printf("failed to split hot timelines: %v", err)
+				}
+			}
+		}
+	}(s.stopCh)
+	return nil
+}
+
+// Stop 停止周期性拆分
+func (s *TimelineSplitter) Stop() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return fmt.Errorf("timeline splitter is not running")
+	}
+	close(s.stopCh)
+	s.running = false
+	return nil
+}
+
+// segments 返回Timeline当前的拆分表，未拆分时以原Timeline作为唯一的一段
+func (s *TimelineSplitter) segments(ctx context.Context, timelineKey string) ([]*TimelineSegment, error) {
+	segments, err := s.splits.GetTimelineSplits(ctx, timelineKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) > 0 {
+		return segments, nil
+	}
+
+	location, err := s.accessor.globalIndex.GetTimelineLocation(ctx, timelineKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get timeline location: %w", err)
+	}
+	if len(location.Blocks) == 0 {
+		return nil, fmt.Errorf("timeline has no blocks")
+	}
+	return []*TimelineSegment{{TimelineKey: timelineKey, StoreID: location.Blocks[0].StoreID}}, nil
+}
+
+// chooseTarget 按分片推荐选择与当前写入Store不同的目标
+func (s *TimelineSplitter) chooseTarget(ctx context.Context, timelineKey, currentStoreID string) (string, error) {
+	rec, err := s.shards.GetShardRecommendation(ctx, timelineKey, EstimatedTimelineSize("conversation"))
+	if err != nil {
+		return "", err
+	}
+	for _, storeID := range append([]string{rec.RecommendedStore}, rec.Alternatives...) {
+		if storeID != "" && storeID != currentStoreID {
+			return storeID, nil
+		}
+	}
+	return "", fmt.Errorf("no store other than %s is available", currentStoreID)
+}
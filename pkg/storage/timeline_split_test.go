@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHotTimelineDetector(t *testing.T) {
+	now := time.Unix(1000, 0)
+	detector := NewHotTimelineDetector(HotTimelineConfig{Window: 10 * time.Second, MaxMessagesPerSecond: 5, MaxBytesPerSecond: 1000})
+	detector.now = func() time.Time { return now }
+
+	for i := 0; i < 60; i++ {
+		detector.Observe("conv_hot", 10)
+	}
+	for i := 0; i < 20; i++ {
+		detector.Observe("conv_quiet", 10)
+	}
+	detector.Observe("conv_big", 20000)
+
+	hot := detector.Hot()
+	if len(hot) != 2 || hot[0].TimelineKey != "conv_hot" || hot[1].TimelineKey != "conv_big" {
+		t.Fatalf("Expected conv_hot by message rate and conv_big by byte rate, got %+v", hot)
+	}
+	if hot[0].MessagesPerSecond != 6 || hot[0].TotalBytes != 600 {
+		t.Errorf("Unexpected stats %+v", hot[0])
+	}
+
+	// 上一个窗口按剩余比例计入
+	now = now.Add(15 * time.Second)
+	if stat := detector.Stat("conv_hot"); stat.MessagesPerSecond != 3 {
+		t.Errorf("Expected half of the previous window to count, got %.1f msg/s", stat.MessagesPerSecond)
+	}
+
+	// 两个窗口没有写入后不再统计
+	now = now.Add(20 * time.Second)
+	if hot := detector.Hot(); len(hot) != 0 {
+		t.Errorf("Expected idle timelines to cool down, got %+v", hot)
+	}
+	if stat := detector.Stat("conv_quiet"); stat != nil {
+		t.Errorf("Expected idle timelines to be dropped, got %+v", stat)
+	}
+}
+
+func TestTimelineSplitStitchesReads(t *testing.T) {
+	ctx := context.Background()
+	local, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+	defer local.Close()
+	remote, ts := newTestRemoteStore(t)
+
+	registry := NewInMemoryRegistry()
+	defer registry.Close()
+	registry.Register(ctx, &StoreInfo{ID: remote.StoreID, Address: ts.URL})
+	pool := NewStoreRPCClientPool(5 * time.Second)
+	defer pool.Close()
+	globalIndex := NewInMemoryGlobalIndex()
+	accessor := NewDistributedStoreAccessor(local, pool, globalIndex, NewConsistentHashRouter(1, 10, 0.8), registry)
+	detector := NewHotTimelineDetector(HotTimelineConfig{Window: time.Minute, MaxMessagesPerSecond: 0.01})
+	accessor.SetHotTimelineDetector(detector)
+
+	if err := accessor.createTimelineOn(ctx, local.StoreID, "conv_hot", "conv"); err != nil {
+		t.Fatalf("Failed to create timeline: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := accessor.AddMessage(ctx, "conv_hot", 1, []byte(fmt.Sprintf("before_%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	// 读取结果被缓存，拆分后应失效
+	if messages, _ := accessor.GetMessages(ctx, "conv_hot", 0, time.Now().Unix()+1, 100); len(messages) != 3 {
+		t.Fatalf("Expected 3 messages before the split, got %d", len(messages))
+	}
+
+	// 写入速率超过阈值，拆分到分片推荐中的另一个Store
+	shards := &fixedShardManager{recommendation: &ShardRecommendation{RecommendedStore: local.StoreID, Alternatives: []string{remote.StoreID}}}
+	splitter := NewTimelineSplitter(accessor, globalIndex, shards, detector)
+	created, err := splitter.SplitHotTimelines(ctx)
+	if err != nil || len(created) != 1 {
+		t.Fatalf("Expected the hot timeline to be split, got %v, %v", created, err)
+	}
+	if created[0].StoreID != remote.StoreID || created[0].TimelineKey != "conv_hot#split1" {
+		t.Fatalf("Unexpected segment %+v", created[0])
+	}
+	if stat := detector.Stat("conv_hot"); stat != nil {
+		t.Errorf("Expected the split timeline's stats to be reset")
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := accessor.AddMessage(ctx, "conv_hot", 1, []byte(fmt.Sprintf("after_%d", i)), nil); err != nil {
+			t.Fatalf("Failed to add message after split: %v", err)
+		}
+	}
+	if messages, _ := remote.GetConvMessages("conv_hot#split1", 10, 0); len(messages) != 2 {
+		t.Fatalf("Expected new writes on the remote segment, got %d", len(messages))
+	}
+	if messages, _ := local.GetConvMessages("conv_hot", 10, 0); len(messages) != 3 {
+		t.Fatalf("Expected earlier messages to stay local, got %d", len(messages))
+	}
+
+	// 读取合并两段，顺序与写入一致
+	messages, err := accessor.GetMessages(ctx, "conv_hot", 0, time.Now().Unix()+1, 100)
+	if err != nil {
+		t.Fatalf("Failed to read split timeline: %v", err)
+	}
+	expected := []string{"before_0", "before_1", "before_2", "after_0", "after_1"}
+	if len(messages) != len(expected) {
+		t.Fatalf("Expected %d stitched messages, got %d", len(expected), len(messages))
+	}
+	for i, msg := range messages {
+		if string(msg.Data) != expected[i] || msg.ConvID != "conv_hot" {
+			t.Errorf("Message %d: expected %s in conv_hot, got %s in %s", i, expected[i], msg.Data, msg.ConvID)
+		}
+	}
+	if messages, _ := accessor.GetMessages(ctx, "conv_hot", 0, time.Now().Unix()+1, 4); len(messages) != 4 {
+		t.Errorf("Expected the limit to apply across segments, got %d", len(messages))
+	}
+
+	// 最后一段已在目标Store上
+	if _, err := splitter.Split(ctx, "conv_hot", remote.StoreID); err == nil {
+		t.Errorf("Expected splitting onto the current store to fail")
+	}
+	segments, _ := globalIndex.GetTimelineSplits(ctx, "conv_hot")
+	if len(segments) != 2 || segments[0].EndTime.IsZero() || !segments[1].EndTime.IsZero() {
+		t.Errorf("Unexpected split table %+v", segments)
+	}
+}
@@ -2,91 +2,93 @@ package storage
 
 import (
 	"fmt"
+	"os"
+	"strings"
 	"testing"
 )
 
 func TestBlockStorageArchitecture(t *testing.T) {
 	// 创建临时目录
 	tempDir := t.TempDir()
-	
+
 	// 创建Store配置
 	config := &StoreConfig{
-		MaxCapacity:     1000,
+		MaxCapacity:     1 << 20,
 		TimelineMaxSize: 3, // 每个块最多3条消息
 		DataDir:         tempDir,
 	}
-	
+
 	// 创建Store
 	store, err := NewStore(config)
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
-	
+
 	// 测试基本的Timeline创建
 	convID := "test_conv_1"
 	convTimeline := store.GetOrCreateConvTimeline(convID)
 	if convTimeline == nil {
 		t.Fatal("Failed to create conv timeline")
 	}
-	
+
 	if convTimeline.ID != convID {
 		t.Errorf("Expected timeline ID %s, got %s", convID, convTimeline.ID)
 	}
-	
+
 	if convTimeline.Type != "conv" {
 		t.Errorf("Expected timeline type 'conv', got %s", convTimeline.Type)
 	}
-	
+
 	// 测试用户Timeline创建
 	userID := "user1"
 	userTimeline := store.GetOrCreateUserTimeline(userID)
 	if userTimeline == nil {
 		t.Fatal("Failed to create user timeline")
 	}
-	
+
 	if userTimeline.ID != userID {
 		t.Errorf("Expected timeline ID %s, got %s", userID, userTimeline.ID)
 	}
-	
+
 	if userTimeline.Type != "user" {
 		t.Errorf("Expected timeline type 'user', got %s", userTimeline.Type)
 	}
-	
+
 	// 测试checkpoint功能
 	checkpoint := store.GetUserCheckpoint("user1")
 	if checkpoint != 0 {
 		t.Errorf("Initial checkpoint should be 0, got %d", checkpoint)
 	}
-	
+
 	store.UpdateUserCheckpoint("user1", 3)
 	checkpoint = store.GetUserCheckpoint("user1")
 	if checkpoint != 3 {
 		t.Errorf("Updated checkpoint should be 3, got %d", checkpoint)
 	}
-	
+
 	t.Logf("Basic block storage architecture test passed successfully!")
 }
 
 func TestBlockPersistence(t *testing.T) {
 	// 创建临时目录
 	tempDir := t.TempDir()
-	
+
 	// 创建Store配置
 	config := &StoreConfig{
-		MaxCapacity:     1000,
+		MaxCapacity:     1 << 20,
 		TimelineMaxSize: 2, // 每个块最多2条消息
 		DataDir:         tempDir,
 	}
-	
+
 	// 创建Store并添加消息
 	store, err := NewStore(config)
 	if err != nil {
 		t.Fatalf("Failed to create store: %v", err)
 	}
-	
+
 	convID := "test_conv_persist"
 	userIDs := []string{"user1"}
-	
+
 	// 添加3条消息，应该创建2个块（2+1）
 	for i := 0; i < 3; i++ {
 		data := []byte(fmt.Sprintf("persist message %d", i+1))
@@ -95,49 +97,261 @@ func TestBlockPersistence(t *testing.T) {
 			t.Fatalf("Failed to add message %d: %v", i+1, err)
 		}
 	}
-	
+
 	// 保存时间线元数据
 	convTimeline := store.GetOrCreateConvTimeline(convID)
 	err = store.saveTimelineMetadata(convTimeline)
 	if err != nil {
 		t.Fatalf("Failed to save timeline metadata: %v", err)
 	}
-	
+
 	// 创建新的Store实例来测试加载
 	newStore, err := NewStore(config)
 	if err != nil {
 		t.Fatalf("Failed to create new store: %v", err)
 	}
-	
+
 	// 创建新的时间线并加载数据
 	newTimeline := &Timeline{
 		ID:     convID,
 		Type:   "conv",
 		Blocks: make([]*TimelineBlock, 0),
 	}
-	
+
 	err = newStore.loadTimeline(newTimeline)
 	if err != nil {
 		t.Fatalf("Failed to load timeline: %v", err)
 	}
-	
+
 	// 验证加载的数据
 	if len(newTimeline.Blocks) != 2 {
 		t.Errorf("Expected 2 blocks after loading, got %d", len(newTimeline.Blocks))
 	}
-	
-	// 验证第一个块的消息
-	if len(newTimeline.Blocks[0].Messages) != 2 {
-		t.Errorf("First block should have 2 messages, got %d", len(newTimeline.Blocks[0].Messages))
+
+	// 验证第一个块的消息，已写满的块按需从段文件读取
+	messages, err := newStore.readBlockMessages(newTimeline.Blocks[0])
+	if err != nil || len(messages) != 2 {
+		t.Errorf("First block should have 2 messages, got %d %v", len(messages), err)
 	}
-	
+
 	// 验证消息内容
-	for i, msg := range newTimeline.Blocks[0].Messages {
+	for i, msg := range messages {
 		expected := fmt.Sprintf("persist message %d", i+1)
 		if string(msg.Data) != expected {
 			t.Errorf("Block 0 Message %d: expected %s, got %s", i, expected, string(msg.Data))
 		}
 	}
-	
+
 	t.Logf("Block persistence test passed successfully!")
-}
\ No newline at end of file
+}
+func TestConvCheckpointsAndUnreadCounts(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	members := []string{"1", "2"}
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage("conv_a", 2, []byte("from 2"), members); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+	if err := store.AddMessage("conv_a", 1, []byte("from 1"), members); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if err := store.AddMessage("conv_b", 2, []byte("from 2"), members); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+
+	counts := store.GetUnreadCounts("1")
+	if counts["conv_a"] != 3 || counts["conv_b"] != 1 {
+		t.Fatalf("Unexpected unread counts: %v", counts)
+	}
+
+	store.UpdateConvCheckpoint("1", "conv_a", 2)
+	store.UpdateConvCheckpoint("1", "conv_a", 1)
+	if got := store.GetConvCheckpoint("1", "conv_a"); got != 2 {
+		t.Errorf("Checkpoint should not move backwards, got %d", got)
+	}
+	counts = store.GetUnreadCounts("1")
+	if counts["conv_a"] != 1 || counts["conv_b"] != 1 {
+		t.Errorf("Unexpected unread counts after reading: %v", counts)
+	}
+	if got := store.GetConvCheckpoint("2", "conv_a"); got != 0 {
+		t.Errorf("Other users should be unaffected, got %d", got)
+	}
+	if counts := store.GetUnreadCounts("2"); counts["conv_a"] != 1 {
+		t.Errorf("Unexpected unread counts for user 2: %v", counts)
+	}
+}
+
+func TestUserEventsResumeAfterReopen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 100000, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := store.AppendUserEvent("1", []byte(fmt.Sprintf("event-%d", i))); err != nil {
+			t.Fatalf("Failed to append event: %v", err)
+		}
+	}
+	store.AppendUserEvent("2", []byte("other"))
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close store: %v", err)
+	}
+
+	reopened, err := NewStore(&StoreConfig{MaxCapacity: 100000, TimelineMaxSize: 2, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+
+	// 重启后新事件的SeqID必须大于该用户已有的记录，否则客户端续传会漏掉
+	next, err := reopened.AppendUserEvent("1", []byte("event-3"))
+	if err != nil {
+		t.Fatalf("Failed to append event: %v", err)
+	}
+	events, more := reopened.GetUserMessagesAfter("1", 0, 0)
+	if more || len(events) != 4 || events[3] != next || events[2].SeqID >= next.SeqID {
+		t.Fatalf("Unexpected events after reopen: %+v", events)
+	}
+
+	page, more := reopened.GetUserMessagesAfter("1", events[0].SeqID, 2)
+	if !more || len(page) != 2 || string(page[0].Data) != "event-1" {
+		t.Errorf("Unexpected page: %+v more=%v", page, more)
+	}
+	if rest, more := reopened.GetUserMessagesAfter("1", next.SeqID, 10); more || len(rest) != 0 {
+		t.Errorf("Nothing should follow the latest event: %+v", rest)
+	}
+}
+
+func TestStoreFlush(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(&StoreConfig{StoreID: "store_1", MaxCapacity: 100000, TimelineMaxSize: 10, DataDir: dir})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+	if store.StoreID != "store_1" {
+		t.Errorf("Expected configured store ID, got %s", store.StoreID)
+	}
+
+	if err := store.AddMessage("conv_1", 1, []byte("hello"), []string{"1"}); err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	tl := store.GetOrCreateConvTimeline("conv_1")
+	metaPath := store.getTimelineMetaFilePath(tl)
+	if err := os.Remove(metaPath); err != nil {
+		t.Fatalf("Failed to remove metadata: %v", err)
+	}
+
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Failed to flush store: %v", err)
+	}
+	if _, err := os.Stat(metaPath); err != nil {
+		t.Errorf("Expected metadata to be written by flush: %v", err)
+	}
+}
+
+func TestPerTimelineSeqIDs(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	// 两个会话交替写入，各自的SeqID都从1连续递增
+	for i := 0; i < 3; i++ {
+		for _, convID := range []string{"conv_a", "conv_b"} {
+			if err := store.AddMessage(convID, 2, []byte(fmt.Sprintf("%s-%d", convID, i)), []string{"1"}); err != nil {
+				t.Fatalf("Failed to add message: %v", err)
+			}
+		}
+	}
+	for _, convID := range []string{"conv_a", "conv_b"} {
+		messages, _ := store.GetConvMessages(convID, 10, 0)
+		for i, msg := range messages {
+			if msg.SeqID != int64(i+1) {
+				t.Errorf("%s message %d: expected SeqID %d, got %d", convID, i, i+1, msg.SeqID)
+			}
+		}
+	}
+
+	// 用户时间线有独立的序列，ConvSeqID指向会话中的位置
+	userMessages, _ := store.GetUserMessagesAfter("1", 0, 0)
+	if len(userMessages) != 6 {
+		t.Fatalf("Expected 6 user messages, got %d", len(userMessages))
+	}
+	for i, msg := range userMessages {
+		if msg.SeqID != int64(i+1) || msg.ConvSeqID != int64(i/2+1) {
+			t.Errorf("User message %d: got SeqID %d ConvSeqID %d", i, msg.SeqID, msg.ConvSeqID)
+		}
+	}
+	store.UpdateConvCheckpoint("1", "conv_a", 2)
+	if counts := store.GetUnreadCounts("1"); counts["conv_a"] != 1 || counts["conv_b"] != 3 {
+		t.Errorf("Unexpected unread counts %v", counts)
+	}
+	store.Close()
+
+	// 重启后按持久化的LastSeqID继续分配
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	msg, err := reopened.AppendMessage("conv_a", 2, []byte("after"), []string{"1"})
+	if err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if msg.SeqID != 4 {
+		t.Errorf("Expected SeqID 4 after reopen, got %d", msg.SeqID)
+	}
+	if latest, _ := reopened.GetUserMessagesAfter("1", 6, 0); len(latest) != 1 || latest[0].SeqID != 7 || latest[0].ConvSeqID != 4 {
+		t.Errorf("Unexpected user message after reopen: %+v", latest)
+	}
+	page, _ := reopened.GetConvMessages("conv_a", 2, 4)
+	if len(page) != 2 || page[0].SeqID != 2 || page[1].SeqID != 3 {
+		t.Errorf("Unexpected page before SeqID 4: %+v", page)
+	}
+}
+
+func TestSeqIDsRecoverFromPersistedBlocks(t *testing.T) {
+	dir := t.TempDir()
+	config := &StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: dir}
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		store.AddMessage("conv_stale", 1, []byte("m"), nil)
+	}
+	timeline, _ := store.GetTimeline("conv", "conv_stale")
+	store.Close()
+
+	// 模拟块落盘后、元数据保存前崩溃
+	metaPath := store.getTimelineMetaFilePath(timeline)
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		t.Fatalf("Failed to read metadata: %v", err)
+	}
+	stale := strings.Replace(string(data), `"last_seq_id":2`, `"last_seq_id":0`, 1)
+	if err := os.WriteFile(metaPath, []byte(stale), 0644); err != nil {
+		t.Fatalf("Failed to write metadata: %v", err)
+	}
+
+	reopened, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer reopened.Close()
+	msg, err := reopened.AppendMessage("conv_stale", 1, []byte("next"), nil)
+	if err != nil {
+		t.Fatalf("Failed to add message: %v", err)
+	}
+	if msg.SeqID != 3 {
+		t.Errorf("Expected SeqID 3, got %d", msg.SeqID)
+	}
+}
@@ -0,0 +1,128 @@
+package storage
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert 签发证书并写入PEM文件，parent为nil时生成自签名CA
+func writeTestCert(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), certPEM, 0600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), keyPEM, 0600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func TestHTTPStoreRPCMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCert(t, dir, "ca", nil, nil)
+	writeTestCert(t, dir, "server", ca, caKey)
+	writeTestCert(t, dir, "client", ca, caKey)
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	serverTLS, err := (&TLSConfig{CertFile: path("server.crt"), KeyFile: path("server.key"), CAFile: path("ca.crt")}).ServerTLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to build server tls config: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewHTTPStoreRPCServer(store)
+	server.SetTLSConfig(serverTLS)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start server: %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	ctx := context.Background()
+	url := "https://" + address
+
+	clientTLS, err := (&TLSConfig{CertFile: path("client.crt"), KeyFile: path("client.key"), CAFile: path("ca.crt")}).ClientTLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to build client tls config: %v", err)
+	}
+	client, err := NewStoreRPCClientWithTLS(TransportHTTP, 2*time.Second, clientTLS)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	client.(*HTTPStoreRPCClient).SetRetryCount(5)
+	if err := client.Connect(ctx, url); err != nil {
+		t.Fatalf("Expected mTLS client to connect: %v", err)
+	}
+
+	// 没有客户端证书的连接应被服务端拒绝
+	anonTLS, err := (&TLSConfig{CAFile: path("ca.crt")}).ClientTLSConfig()
+	if err != nil {
+		t.Fatalf("Failed to build client tls config: %v", err)
+	}
+	anon := NewHTTPStoreRPCClient(2 * time.Second)
+	anon.SetTLSConfig(anonTLS)
+	anon.SetRetryCount(0)
+	if err := anon.Connect(ctx, url); err == nil {
+		t.Error("Expected client without certificate to be rejected")
+	}
+
+	// 明文客户端同样无法连接
+	plain := NewHTTPStoreRPCClient(2 * time.Second)
+	plain.SetRetryCount(0)
+	if err := plain.Connect(ctx, "http://"+address); err == nil {
+		t.Error("Expected clear-text client to fail")
+	}
+}
@@ -0,0 +1,152 @@
+package storage
+
+import (
+	"context"
+	"net/http"
+
+	ztrace "github.com/zeromicro/go-zero/core/trace"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader 网关注入的请求ID头，随RPC调用向下传递
+const RequestIDHeader = "X-Request-Id"
+
+// 追踪属性
+const (
+	attrRPCMethod = attribute.Key("rpc.method")
+	attrRPCSystem = attribute.Key("rpc.system")
+	attrPeer      = attribute.Key("net.peer.name")
+	attrRequestID = attribute.Key("request.id")
+)
+
+type requestIDKey struct{}
+
+// WithRequestID 将请求ID放入上下文，后续Store RPC调用会携带它
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	if requestID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext 获取上下文中的请求ID
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// startRPCSpan 创建Store RPC的span，请求ID作为属性记录
+func startRPCSpan(ctx context.Context, kind trace.SpanKind, system, method, peer string) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attrRPCSystem.String(system), attrRPCMethod.String(method)}
+	if peer != "" {
+		attrs = append(attrs, attrPeer.String(peer))
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		attrs = append(attrs, attrRequestID.String(requestID))
+	}
+	return otel.Tracer(ztrace.TraceName).Start(ctx, "storage."+method, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// endRPCSpan 结束span，失败时记录错误
+func endRPCSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectHTTPTrace 将追踪上下文和请求ID写入HTTP请求头
+func injectHTTPTrace(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		header.Set(RequestIDHeader, requestID)
+	}
+}
+
+// extractHTTPTrace 从HTTP请求头恢复追踪上下文和请求ID
+func extractHTTPTrace(r *http.Request) context.Context {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return WithRequestID(ctx, r.Header.Get(RequestIDHeader))
+}
+
+// requestIDMetadataKey gRPC元数据中的请求ID键，元数据键要求小写
+const requestIDMetadataKey = "x-request-id"
+
+// injectGRPCTrace 将追踪上下文和请求ID写入gRPC出站元数据
+func injectGRPCTrace(ctx context.Context) context.Context {
+	md, ok := metadata.FromOutgoingContext(ctx)
+	if ok {
+		md = md.Copy()
+	} else {
+		md = metadata.MD{}
+	}
+	ztrace.Inject(ctx, otel.GetTextMapPropagator(), &md)
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		md.Set(requestIDMetadataKey, requestID)
+	}
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// extractGRPCTrace 从gRPC入站元数据恢复追踪上下文和请求ID
+func extractGRPCTrace(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	if _, spanCtx := ztrace.Extract(ctx, otel.GetTextMapPropagator(), &md); spanCtx.IsValid() {
+		ctx = trace.ContextWithRemoteSpanContext(ctx, spanCtx)
+	}
+	if values := md.Get(requestIDMetadataKey); len(values) > 0 {
+		ctx = WithRequestID(ctx, values[0])
+	}
+	return ctx
+}
+
+// tracingUnaryClientInterceptor gRPC客户端一元调用追踪
+func tracingUnaryClientInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	ctx, span := startRPCSpan(ctx, trace.SpanKindClient, "grpc", method, cc.Target())
+	err := invoker(injectGRPCTrace(ctx), method, req, reply, cc, opts...)
+	endRPCSpan(span, err)
+	return err
+}
+
+// tracingStreamClientInterceptor gRPC客户端流式调用追踪，span覆盖流的建立
+func tracingStreamClientInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ctx, span := startRPCSpan(ctx, trace.SpanKindClient, "grpc", method, cc.Target())
+	stream, err := streamer(injectGRPCTrace(ctx), desc, cc, method, opts...)
+	endRPCSpan(span, err)
+	return stream, err
+}
+
+// tracingUnaryServerInterceptor gRPC服务端一元调用追踪
+func tracingUnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, span := startRPCSpan(extractGRPCTrace(ctx), trace.SpanKindServer, "grpc", info.FullMethod, "")
+	resp, err := handler(ctx, req)
+	endRPCSpan(span, err)
+	return resp, err
+}
+
+// tracingServerStream 替换流的上下文，使处理器拿到带span的上下文
+type tracingServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracingServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// tracingStreamServerInterceptor gRPC服务端流式调用追踪
+func tracingStreamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startRPCSpan(extractGRPCTrace(ss.Context()), trace.SpanKindServer, "grpc", info.FullMethod, "")
+	err := handler(srv, &tracingServerStream{ServerStream: ss, ctx: ctx})
+	endRPCSpan(span, err)
+	return err
+}
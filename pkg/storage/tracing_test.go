@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// installSpanRecorder 替换全局TracerProvider，记录测试期间结束的span
+func installSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// findServerSpan 查找指定方法的服务端span
+func findServerSpan(recorder *tracetest.SpanRecorder, method string) sdktrace.ReadOnlySpan {
+	for _, span := range recorder.Ended() {
+		if span.SpanKind() != trace.SpanKindServer {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == attrRPCMethod && attr.Value.AsString() == method {
+				return span
+			}
+		}
+	}
+	return nil
+}
+
+func spanRequestID(span sdktrace.ReadOnlySpan) string {
+	for _, attr := range span.Attributes() {
+		if attr.Key == attrRequestID {
+			return attr.Value.AsString()
+		}
+	}
+	return ""
+}
+
+func TestHTTPRPCPropagatesTraceContext(t *testing.T) {
+	recorder := installSpanRecorder(t)
+	_, ts := newTestRemoteStore(t)
+
+	ctx, parent := otel.Tracer("test").Start(WithRequestID(context.Background(), "req-http"), "sendMessage")
+	client := NewHTTPStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, ts.URL); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+	if _, err := client.GetStoreStats(ctx, &GetStoreStatsRequest{}); err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	parent.End()
+
+	span := findServerSpan(recorder, MethodGetStoreStats)
+	if span == nil {
+		t.Fatal("Expected a server span for GetStoreStats")
+	}
+	if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Server span is not in the caller's trace")
+	}
+	if got := spanRequestID(span); got != "req-http" {
+		t.Errorf("Expected request id req-http, got %q", got)
+	}
+}
+
+func TestGRPCPropagatesTraceContext(t *testing.T) {
+	recorder := installSpanRecorder(t)
+
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 10, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to reserve port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	server := NewGRPCStoreRPCServer(store)
+	if err := server.Start(address); err != nil {
+		t.Fatalf("Failed to start gRPC server: %v", err)
+	}
+	defer server.Stop(context.Background())
+
+	ctx, parent := otel.Tracer("test").Start(WithRequestID(context.Background(), "req-grpc"), "sendMessage")
+	client := NewGRPCStoreRPCClient(5 * time.Second)
+	if err := client.Connect(ctx, address); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer client.Disconnect()
+	if _, err := client.GetStoreStats(ctx, &GetStoreStatsRequest{}); err != nil {
+		t.Fatalf("Failed to get stats: %v", err)
+	}
+	parent.End()
+
+	span := findServerSpan(recorder, "/storepb.StoreRPC/GetStoreStats")
+	if span == nil {
+		t.Fatal("Expected a server span for GetStoreStats")
+	}
+	if span.SpanContext().TraceID() != parent.SpanContext().TraceID() {
+		t.Errorf("Server span is not in the caller's trace")
+	}
+	if got := spanRequestID(span); got != "req-grpc" {
+		t.Errorf("Expected request id req-grpc, got %q", got)
+	}
+}
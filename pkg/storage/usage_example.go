@@ -41,7 +41,7 @@ func ExampleUsage() {
 	cacheManager := NewMultiLevelCacheManager(l1Cache, l2Cache, l3Cache)
 	
 	// 创建性能优化器
-	performanceOptimizer := NewPerformanceOptimizer()
+	performanceOptimizer := NewPerformanceOptimizer(storeRegistry)
 	
 	fmt.Println("✓ 系统组件初始化完成")
 	
@@ -69,7 +69,7 @@ func exampleRegisterStores(ctx context.Context, registry StoreRegistry) {
 		{
 			ID:      "store-1",
 			Address: "192.168.1.10:8080",
-			Status:  "active",
+			Status:  StoreStatusActive,
 			Metadata: map[string]interface{}{
 				"capacity": 1024 * 1024 * 1024, // 1GB
 				"region":   "us-west-1",
@@ -80,7 +80,7 @@ func exampleRegisterStores(ctx context.Context, registry StoreRegistry) {
 		{
 			ID:      "store-2",
 			Address: "192.168.1.11:8080",
-			Status:  "active",
+			Status:  StoreStatusActive,
 			Metadata: map[string]interface{}{
 				"capacity": 2048 * 1024 * 1024, // 2GB
 				"region":   "us-west-2",
@@ -91,7 +91,7 @@ func exampleRegisterStores(ctx context.Context, registry StoreRegistry) {
 		{
 			ID:      "store-3",
 			Address: "192.168.1.12:8080",
-			Status:  "active",
+			Status:  StoreStatusActive,
 			Metadata: map[string]interface{}{
 				"capacity": 512 * 1024 * 1024, // 512MB
 				"region":   "us-east-1",
@@ -249,15 +249,21 @@ func exampleStoreStats(ctx context.Context, store *Store) {
 	
 	// 显示Store基本信息
 	fmt.Printf("✓ Store ID: %s\n", store.StoreID)
-	fmt.Printf("✓ 当前容量: %d bytes\n", store.CurrentCapacity)
+	fmt.Printf("✓ 当前容量: %d bytes\n", store.UsedCapacity())
 	fmt.Printf("✓ 最大容量: %d bytes\n", store.Config.MaxCapacity)
 	fmt.Printf("✓ Timeline块大小: %d 条消息\n", store.Config.TimelineMaxSize)
 	fmt.Printf("✓ 数据目录: %s\n", store.Config.DataDir)
 	
 	// 统计Timeline数量
-	convCount := len(store.ConvTimelines)
-	userCount := len(store.UserTimelines)
-	blockCount := len(store.TimelineBlocks)
+	convCount, userCount := 0, 0
+	for _, timeline := range store.ListTimelines() {
+		if timeline.Type == "user" {
+			userCount++
+		} else {
+			convCount++
+		}
+	}
+	blockCount := store.BlockCount()
 	
 	fmt.Printf("✓ 会话Timeline数量: %d\n", convCount)
 	fmt.Printf("✓ 用户Timeline数量: %d\n", userCount)
@@ -338,6 +344,10 @@ func examplePerformanceMonitoring(optimizer *PerformanceOptimizer) {
 		fmt.Printf("  - %s: 次数=%d, 平均耗时=%v, 成功率=%.1f%%\n", 
 			operation, count, avgDuration, successRate*100)
 	}
+	for operation, stats := range metrics.Latencies {
+		fmt.Printf("  - %s: P50=%v, P95=%v, P99=%v, 吞吐量=%.2f次/秒\n",
+			operation, stats.P50, stats.P95, stats.P99, stats.Throughput)
+	}
 }
 
 // 高级使用场景示例
@@ -368,9 +378,9 @@ func exampleCustomRouting(ctx context.Context) {
 	
 	// 添加Store节点
 	stores := []*StoreInfo{
-		{ID: "store-1", Address: "192.168.1.10:8080", Status: "healthy"},
-		{ID: "store-2", Address: "192.168.1.11:8080", Status: "healthy"},
-		{ID: "store-3", Address: "192.168.1.12:8080", Status: "healthy"},
+		{ID: "store-1", Address: "192.168.1.10:8080", Status: StoreStatusActive},
+		{ID: "store-2", Address: "192.168.1.11:8080", Status: StoreStatusActive},
+		{ID: "store-3", Address: "192.168.1.12:8080", Status: StoreStatusActive},
 	}
 	
 	for _, store := range stores {
@@ -435,9 +445,9 @@ func exampleLoadBalancing(ctx context.Context) {
 	
 	// 添加Store节点
 	stores := []*StoreInfo{
-		{ID: "store-1", Address: "192.168.1.10:8080", Status: "healthy"},
-		{ID: "store-2", Address: "192.168.1.11:8080", Status: "healthy"},
-		{ID: "store-3", Address: "192.168.1.12:8080", Status: "healthy"},
+		{ID: "store-1", Address: "192.168.1.10:8080", Status: StoreStatusActive},
+		{ID: "store-2", Address: "192.168.1.11:8080", Status: StoreStatusActive},
+		{ID: "store-3", Address: "192.168.1.12:8080", Status: StoreStatusActive},
 	}
 	
 	for _, store := range stores {
@@ -0,0 +1,209 @@
+package storage
+
+import (
+	"iter"
+	"sort"
+)
+
+// Timeline只读视图
+// 块内消息只追加，已追加的消息不再修改；保留策略裁剪块时换成新的切片，不改动原有的底层数组。
+// 因此记录下各块在某一时刻的切片头，就得到一个之后不会变化的快照。写入在Timeline写锁内
+// 发布新视图，读取方原子地取得当前视图后即可在不持有任何锁的情况下遍历，
+// 不会读到写入一半的分页，也不会阻塞写入。
+// 追加消息只替换最后一个块的切片头，除最后一块外的部分在视图之间共享；
+// 新建、裁剪、删除块等改变块结构的操作重建整个视图。
+// 未加载的块（见block_loader.go）在视图中只记录块及其索引，遍历时先按SeqID范围跳过，
+// 只有范围内的块才通过blockLoader读取
+
+// timelineView Timeline在某一时刻的只读快照，发布后不再修改
+type timelineView struct {
+	sealed    []viewBlock // 除最后一块外的各块
+	current   []*Message  // 最后一块的消息
+	lastBlock *TimelineBlock
+	blocks    int
+}
+
+// viewBlock 视图中的一个块，未加载的块只记录块及其SeqID范围
+type viewBlock struct {
+	messages []*Message
+	lazy     *TimelineBlock // 未加载的块，遍历到时读取
+	index    BlockIndex
+}
+
+// read 块的消息，未加载的块通过load读取
+func (b viewBlock) read(load blockLoader) ([]*Message, error) {
+	if b.lazy == nil {
+		return b.messages, nil
+	}
+	return load(b.lazy)
+}
+
+// overlaps 块中是否可能有SeqID在(afterSeqID, beforeSeqID)内的消息，beforeSeqID为0表示不限制
+// 已加载的块直接逐条过滤，只有未加载的块按索引判断
+func (b viewBlock) overlaps(afterSeqID, beforeSeqID int64) bool {
+	if b.lazy == nil {
+		return true
+	}
+	return b.index.Count > 0 && b.index.MaxSeqID > afterSeqID && (beforeSeqID == 0 || b.index.MinSeqID < beforeSeqID)
+}
+
+// sealedBlock 块在当前时刻的视图，消息切片限制容量使快照不会看到之后追加的消息
+// 调用方持有Timeline写锁，块的消息只在Timeline写锁内修改
+func sealedBlock(block *TimelineBlock) viewBlock {
+	block.mu.RLock()
+	defer block.mu.RUnlock()
+	if block.lazy {
+		return viewBlock{lazy: block, index: block.Index}
+	}
+	return viewBlock{messages: block.Messages[:len(block.Messages):len(block.Messages)]}
+}
+
+// refreshViewLocked 发布Timeline当前内容的视图，只有最后一块有变化时复用之前视图中的其他块
+// 调用方持有Timeline写锁
+func (tl *Timeline) refreshViewLocked() {
+	view := &timelineView{blocks: len(tl.Blocks)}
+	if view.blocks == 0 {
+		tl.view.Store(view)
+		return
+	}
+	view.lastBlock = tl.Blocks[view.blocks-1]
+	sealed := tl.Blocks[:view.blocks-1]
+	if last := sealedBlock(view.lastBlock); last.lazy != nil {
+		// 最后一块已写满且未加载时同样按需读取
+		sealed = tl.Blocks
+	} else {
+		view.current = last.messages
+	}
+
+	if old := tl.view.Load(); old != nil && old.blocks == view.blocks && old.lastBlock == view.lastBlock && len(old.sealed) == len(sealed) {
+		view.sealed = old.sealed
+	} else {
+		view.sealed = make([]viewBlock, 0, len(sealed))
+		for _, block := range sealed {
+			view.sealed = append(view.sealed, sealedBlock(block))
+		}
+	}
+	tl.view.Store(view)
+}
+
+// rebuildViewLocked 块结构变化后重建整个视图，调用方持有Timeline写锁
+func (tl *Timeline) rebuildViewLocked() {
+	tl.view.Store(nil)
+	tl.refreshViewLocked()
+}
+
+// readView 获取Timeline当前的只读视图，调用方不能持有Timeline锁
+func (tl *Timeline) readView() *timelineView {
+	if view := tl.view.Load(); view != nil {
+		return view
+	}
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	if view := tl.view.Load(); view != nil {
+		return view
+	}
+	tl.refreshViewLocked()
+	return tl.view.Load()
+}
+
+// forward 按写入顺序遍历SeqID大于afterSeqID的消息，范围外的未加载块不会读取
+// 读取块失败时产出错误并结束遍历
+func (v *timelineView) forward(load blockLoader, afterSeqID int64) iter.Seq2[*Message, error] {
+	return func(yield func(*Message, error) bool) {
+		for _, block := range v.sealed {
+			if !block.overlaps(afterSeqID, 0) {
+				continue
+			}
+			messages, err := block.read(load)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, msg := range messages {
+				if msg.SeqID > afterSeqID && !yield(msg, nil) {
+					return
+				}
+			}
+		}
+		for _, msg := range v.current {
+			if msg.SeqID > afterSeqID && !yield(msg, nil) {
+				return
+			}
+		}
+	}
+}
+
+// ascending 按SeqID升序返回SeqID大于afterSeqID的前limit条消息及之后是否还有消息，limit为0时返回全部
+// 块在视图中按Timeline中的顺序排列，不保证按SeqID有序（如从WAL恢复后当前块不是最后一块），
+// 因此合并所有块的结果后排序；已取到limit+1条时，只读取首条SeqID更小的未加载块
+func (v *timelineView) ascending(load blockLoader, afterSeqID int64, limit int) ([]*Message, bool, error) {
+	var result []*Message
+	var cutoff int64 // 已取满时第limit+1条的SeqID，之后只收集更小的SeqID
+	bySeqID := func(i, j int) bool { return result[i].SeqID < result[j].SeqID }
+	collect := func(messages []*Message) {
+		for _, msg := range messages {
+			if msg.SeqID > afterSeqID && (cutoff == 0 || msg.SeqID < cutoff) {
+				result = append(result, msg)
+			}
+		}
+		if limit > 0 && len(result) > limit {
+			sort.Slice(result, bySeqID)
+			result = result[:limit+1]
+			cutoff = result[limit].SeqID
+		}
+	}
+
+	for _, block := range v.sealed {
+		if !block.overlaps(afterSeqID, cutoff) {
+			continue
+		}
+		messages, err := block.read(load)
+		if err != nil {
+			return nil, false, err
+		}
+		collect(messages)
+	}
+	collect(v.current)
+
+	sort.Slice(result, bySeqID)
+	if limit > 0 && len(result) > limit {
+		return result[:limit], true, nil
+	}
+	return result, false, nil
+}
+
+// descending 按SeqID降序返回SeqID小于beforeSeqID的前limit条消息，beforeSeqID为0表示从最新消息开始，limit为0时返回全部
+// 与ascending一样不依赖块的排列顺序：合并所有块的结果后排序，已取满limit条时只读取末条SeqID更大的未加载块；
+// 块按SeqID有序时从最后一块向前读取，取满后更早的块不会读取
+func (v *timelineView) descending(load blockLoader, beforeSeqID int64, limit int) ([]*Message, error) {
+	var result []*Message
+	var cutoff int64 // 已取满时第limit条的SeqID，之后只收集更大的SeqID
+	bySeqIDDesc := func(i, j int) bool { return result[i].SeqID > result[j].SeqID }
+	collect := func(messages []*Message) {
+		for _, msg := range messages {
+			if (beforeSeqID == 0 || msg.SeqID < beforeSeqID) && msg.SeqID > cutoff {
+				result = append(result, msg)
+			}
+		}
+		if limit > 0 && len(result) >= limit {
+			sort.Slice(result, bySeqIDDesc)
+			result = result[:limit]
+			cutoff = result[limit-1].SeqID
+		}
+	}
+
+	collect(v.current)
+	for b := len(v.sealed) - 1; b >= 0; b-- {
+		if !v.sealed[b].overlaps(cutoff, beforeSeqID) {
+			continue
+		}
+		messages, err := v.sealed[b].read(load)
+		if err != nil {
+			return nil, err
+		}
+		collect(messages)
+	}
+
+	sort.Slice(result, bySeqIDDesc)
+	return result, nil
+}
@@ -0,0 +1,230 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func collectView(view *timelineView) []int64 {
+	var seqIDs []int64
+	for msg := range view.forward(nil, 0) {
+		seqIDs = append(seqIDs, msg.SeqID)
+	}
+	return seqIDs
+}
+
+func TestReadViewIsStableSnapshot(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 3, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	for i := 0; i < 4; i++ {
+		store.AddMessage("conv_view", 1, []byte("m"), nil)
+	}
+	tl, _ := store.GetTimeline("conv", "conv_view")
+	before := tl.readView()
+
+	// 之后的写入跨越块边界，已取得的视图保持不变
+	for i := 0; i < 4; i++ {
+		store.AddMessage("conv_view", 1, []byte("m"), nil)
+	}
+	if seqIDs := collectView(before); len(seqIDs) != 4 || seqIDs[3] != 4 {
+		t.Errorf("Expected the earlier view to keep SeqIDs 1..4, got %v", seqIDs)
+	}
+	after := tl.readView()
+	if seqIDs := collectView(after); len(seqIDs) != 8 || seqIDs[7] != 8 {
+		t.Errorf("Expected the new view to contain SeqIDs 1..8, got %v", seqIDs)
+	}
+	var backward []int64
+	descending, _ := after.descending(nil, 0, 0)
+	for _, msg := range descending {
+		backward = append(backward, msg.SeqID)
+	}
+	if len(backward) != 8 || backward[0] != 8 || backward[7] != 1 {
+		t.Errorf("Expected backward iteration from 8 to 1, got %v", backward)
+	}
+
+	// 删除Timeline后视图随之清空，之前的视图不受影响
+	if _, err := store.DeleteTimeline("conv", "conv_view"); err != nil {
+		t.Fatalf("Failed to delete timeline: %v", err)
+	}
+	if seqIDs := collectView(after); len(seqIDs) != 8 {
+		t.Errorf("Expected the earlier view to survive deletion, got %v", seqIDs)
+	}
+	if seqIDs := collectView(tl.readView()); len(seqIDs) != 0 {
+		t.Errorf("Expected an empty view after deletion, got %v", seqIDs)
+	}
+}
+
+func TestReadViewAfterRetention(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 20, TimelineMaxSize: 2, DataDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	old := time.Now().Add(-48 * time.Hour)
+	for i := 0; i < 4; i++ {
+		createTime := old
+		if i >= 2 {
+			createTime = time.Now()
+		}
+		store.ReplicateMessage("conv_retention_view", &Message{SenderID: 1, CreateTime: createTime, Data: []byte("m")}, nil)
+	}
+	tl, _ := store.GetTimeline("conv", "conv_retention_view")
+	before := tl.readView()
+
+	manager := NewRetentionManager(store, nil, &RetentionPolicy{MaxAge: 24 * time.Hour})
+	if _, err := manager.RunOnce(context.Background()); err != nil {
+		t.Fatalf("Failed to apply retention: %v", err)
+	}
+	if seqIDs := collectView(tl.readView()); len(seqIDs) != 2 || seqIDs[0] != 3 {
+		t.Errorf("Expected SeqIDs 3 and 4 after retention, got %v", seqIDs)
+	}
+	if seqIDs := collectView(before); len(seqIDs) != 4 {
+		t.Errorf("Expected the earlier view to keep 4 messages, got %v", seqIDs)
+	}
+}
+
+// TestGetConvMessagesConsistentUnderConcurrentWrites 并发写入时每一页都是连续的SeqID
+func TestGetConvMessagesConsistentUnderConcurrentWrites(t *testing.T) {
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 26, TimelineMaxSize: 16, DataDir: t.TempDir(), DisableWAL: true})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	var done atomic.Bool
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer done.Store(true)
+		for i := 0; i < 500; i++ {
+			if err := store.AddMessage("conv_concurrent", 1, []byte(fmt.Sprintf("m%d", i)), nil); err != nil {
+				t.Errorf("Failed to add message: %v", err)
+				return
+			}
+		}
+	}()
+
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !done.Load() {
+				page, err := store.GetConvMessages("conv_concurrent", 20, 0)
+				if err != nil {
+					t.Errorf("GetConvMessages failed: %v", err)
+					return
+				}
+				for i := 1; i < len(page); i++ {
+					if page[i].SeqID != page[i-1].SeqID+1 {
+						t.Errorf("Torn page: SeqID %d follows %d", page[i].SeqID, page[i-1].SeqID)
+						return
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if page, _ := store.GetConvMessages("conv_concurrent", 20, 0); len(page) != 20 || page[19].SeqID != 500 {
+		t.Errorf("Expected the last page to end at SeqID 500, got %d messages", len(page))
+	}
+}
+
+func newViewBenchmarkStore(b *testing.B) *Store {
+	b.Helper()
+	store, err := NewStore(&StoreConfig{MaxCapacity: 1 << 30, TimelineMaxSize: 1000, DataDir: b.TempDir(), DisableWAL: true})
+	if err != nil {
+		b.Fatalf("Failed to create store: %v", err)
+	}
+	b.Cleanup(func() { store.Close() })
+	for i := 0; i < 5000; i++ {
+		store.AddMessage("conv_bench", 1, []byte("payload"), nil)
+	}
+	return store
+}
+
+// startBenchmarkWriter 在后台持续写入，返回停止函数
+func startBenchmarkWriter(store *Store) func() {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				store.AddMessage("conv_bench", 1, []byte("payload"), nil)
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		wg.Wait()
+	}
+}
+
+// BenchmarkGetConvMessages 对比有无后台写入时的并发读取吞吐，读取方不持有Timeline锁
+func BenchmarkGetConvMessages(b *testing.B) {
+	for _, withWriter := range []bool{false, true} {
+		name := "readers"
+		if withWriter {
+			name = "readers+writer"
+		}
+		b.Run(name, func(b *testing.B) {
+			store := newViewBenchmarkStore(b)
+			if withWriter {
+				defer startBenchmarkWriter(store)()
+			}
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					store.GetConvMessages("conv_bench", 50, 0)
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkAddMessageWithReaders 对比有无并发读取时的写入耗时
+func BenchmarkAddMessageWithReaders(b *testing.B) {
+	for _, readers := range []int{0, 4} {
+		b.Run(fmt.Sprintf("readers=%d", readers), func(b *testing.B) {
+			store := newViewBenchmarkStore(b)
+			stop := make(chan struct{})
+			var wg sync.WaitGroup
+			for r := 0; r < readers; r++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						select {
+						case <-stop:
+							return
+						default:
+							store.GetConvMessages("conv_bench", 50, 0)
+						}
+					}
+				}()
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				store.AddMessage("conv_bench", 1, []byte("payload"), nil)
+			}
+			b.StopTimer()
+			close(stop)
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WALSyncPolicy WAL刷盘策略
+type WALSyncPolicy string
+
+const (
+	WALSyncAlways   WALSyncPolicy = "always"   // 每条记录写入后立即fsync
+	WALSyncInterval WALSyncPolicy = "interval" // 后台按固定间隔fsync
+	WALSyncNone     WALSyncPolicy = "none"     // 不主动fsync，由操作系统决定
+)
+
+const (
+	walFileName            = "store.wal"
+	walRecordHeaderSize    = 8 // 4字节长度 + 4字节CRC32
+	defaultWALSyncInterval = time.Second
+	defaultWALMaxSize      = 64 * 1024 * 1024
+)
+
+// walRecord WAL记录，每次向Timeline追加消息写入一条
+type walRecord struct {
+	TimelineType string   `json:"type"`
+	TimelineID   string   `json:"id"`
+	BlockID      string   `json:"block_id"`
+	Message      *Message `json:"message"`
+}
+
+// timelineKey 与Store.StoreIndex使用相同的Timeline键格式
+func (r *walRecord) timelineKey() string {
+	return fmt.Sprintf("%s_%s", r.TimelineType, r.TimelineID)
+}
+
+// writeAheadLog 追加写日志，记录格式为 [长度uint32][CRC32 uint32][JSON负载]
+type writeAheadLog struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	size     int64
+	policy   WALSyncPolicy
+	interval time.Duration
+	dirty    bool
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+// openWAL 打开（或创建）WAL文件并读出其中的有效记录
+// 尾部不完整或校验失败的记录视为崩溃时的残留写入，会被截断
+func openWAL(dir string, policy WALSyncPolicy, interval time.Duration) (*writeAheadLog, []*walRecord, error) {
+	if policy == "" {
+		policy = WALSyncInterval
+	}
+	if interval <= 0 {
+		interval = defaultWALSyncInterval
+	}
+
+	path := filepath.Join(dir, walFileName)
+	records, validSize, err := readWALRecords(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open wal: %w", err)
+	}
+	if err := file.Truncate(validSize); err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to truncate wal: %w", err)
+	}
+
+	w := &writeAheadLog{
+		path:     path,
+		file:     file,
+		size:     validSize,
+		policy:   policy,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+
+	if policy == WALSyncInterval {
+		w.wg.Add(1)
+		go w.syncLoop()
+	}
+
+	return w, records, nil
+}
+
+// readWALRecords 顺序读取WAL记录，返回有效记录及其占用的字节数
+func readWALRecords(path string) ([]*walRecord, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, fmt.Errorf("failed to open wal: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var records []*walRecord
+	var offset int64
+	header := make([]byte, walRecordHeaderSize)
+
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			break
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		checksum := binary.BigEndian.Uint32(header[4:8])
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			break
+		}
+		if crc32.ChecksumIEEE(payload) != checksum {
+			log.Printf("wal %s: checksum mismatch at offset %d, truncating", path, offset)
+			break
+		}
+
+		var record walRecord
+		if err := json.Unmarshal(payload, &record); err != nil || record.Message == nil {
+			log.Printf("wal %s: invalid record at offset %d, truncating", path, offset)
+			break
+		}
+
+		records = append(records, &record)
+		offset += walRecordHeaderSize + int64(length)
+	}
+
+	return records, offset, nil
+}
+
+// encodeWALRecord 编码单条记录
+func encodeWALRecord(record *walRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, walRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(payload))
+	copy(buf[walRecordHeaderSize:], payload)
+	return buf, nil
+}
+
+// Append 追加一条记录
+func (w *writeAheadLog) Append(record *walRecord) error {
+	buf, err := encodeWALRecord(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode wal record: %w", err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("wal is closed")
+	}
+
+	if _, err := w.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write wal: %w", err)
+	}
+	w.size += int64(len(buf))
+
+	if w.policy == WALSyncAlways {
+		return w.file.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// Sync 将已写入的记录刷盘
+func (w *writeAheadLog) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.syncLocked()
+}
+
+func (w *writeAheadLog) syncLocked() error {
+	if w.file == nil || !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+// syncLoop 按间隔刷盘
+func (w *writeAheadLog) syncLoop() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := w.Sync(); err != nil {
+				log.Printf("wal %s: sync failed: %v", w.path, err)
+			}
+		case <-w.stopCh:
+			return
+		}
+	}
+}
+
+// Size 当前WAL文件大小
+func (w *writeAheadLog) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// Compact 重写WAL，只保留keep返回true的记录
+func (w *writeAheadLog) Compact(keep func(*walRecord) bool) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return fmt.Errorf("wal is closed")
+	}
+
+	records, _, err := readWALRecords(w.path)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := w.path + ".tmp"
+	tmp, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create wal: %w", err)
+	}
+
+	writer := bufio.NewWriter(tmp)
+	var size int64
+	for _, record := range records {
+		if !keep(record) {
+			continue
+		}
+		buf, err := encodeWALRecord(record)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to encode wal record: %w", err)
+		}
+		if _, err := writer.Write(buf); err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("failed to write wal: %w", err)
+		}
+		size += int64(len(buf))
+	}
+
+	err = writer.Flush()
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to flush wal: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, w.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace wal: %w", err)
+	}
+	if w.policy == WALSyncAlways {
+		if err := syncDir(filepath.Dir(w.path)); err != nil {
+			return fmt.Errorf("failed to sync wal directory: %w", err)
+		}
+	}
+
+	file, err := os.OpenFile(w.path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen wal: %w", err)
+	}
+	w.file.Close()
+	w.file = file
+	w.size = size
+	w.dirty = false
+
+	return nil
+}
+
+// Close 刷盘并关闭WAL
+func (w *writeAheadLog) Close() error {
+	w.mu.Lock()
+	if w.file == nil {
+		w.mu.Unlock()
+		return nil
+	}
+	close(w.stopCh)
+	w.dirty = true
+	err := w.syncLocked()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	w.file = nil
+	w.mu.Unlock()
+
+	w.wg.Wait()
+	return err
+}
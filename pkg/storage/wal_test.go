@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWALRecoversUnflushedBlocks(t *testing.T) {
+	tempDir := t.TempDir()
+	config := &StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 10,
+		DataDir:         tempDir,
+		WALSyncPolicy:   WALSyncAlways,
+	}
+
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	convID := "conv_wal"
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage(convID, 1001, []byte(fmt.Sprintf("wal message %d", i+1)), []string{"user_wal"}); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	// 模拟崩溃后追加的残缺记录
+	walPath := filepath.Join(tempDir, walFileName)
+	file, err := os.OpenFile(walPath, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("Failed to open wal: %v", err)
+	}
+	file.Write([]byte{0, 0, 1, 0, 1, 2})
+	file.Close()
+
+	// 不关闭原Store，直接重新打开以模拟进程崩溃
+	recovered, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	defer recovered.Close()
+
+	messages, err := recovered.GetConvMessages(convID, 10, 0)
+	if err != nil {
+		t.Fatalf("Failed to get messages: %v", err)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected 3 recovered messages, got %d", len(messages))
+	}
+	for i, msg := range messages {
+		if expected := fmt.Sprintf("wal message %d", i+1); string(msg.Data) != expected {
+			t.Errorf("Message %d: expected %s, got %s", i, expected, string(msg.Data))
+		}
+	}
+
+	if userMessages, _ := recovered.GetMessagesAfterCheckpoint("user_wal"); len(userMessages) != 3 {
+		t.Errorf("Expected 3 recovered user messages, got %d", len(userMessages))
+	}
+
+	// 新消息继续写入恢复出的当前块，序列号不回退
+	if err := recovered.AddMessage(convID, 1001, []byte("after recovery"), nil); err != nil {
+		t.Fatalf("Failed to add message after recovery: %v", err)
+	}
+	messages, _ = recovered.GetConvMessages(convID, 10, 0)
+	if len(messages) != 4 || messages[3].SeqID <= messages[2].SeqID {
+		t.Errorf("Unexpected messages after recovery: %d", len(messages))
+	}
+	if tl := recovered.GetOrCreateConvTimeline(convID); len(tl.Blocks) != 1 {
+		t.Errorf("Expected recovered block to be reused, got %d blocks", len(tl.Blocks))
+	}
+}
+
+func TestWALCompactsFlushedRecords(t *testing.T) {
+	tempDir := t.TempDir()
+	config := &StoreConfig{
+		MaxCapacity:     1 << 20,
+		TimelineMaxSize: 2,
+		DataDir:         tempDir,
+		WALMaxSize:      1,
+	}
+
+	store, err := NewStore(config)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	defer store.Close()
+
+	// 第二条消息写满块并触发压缩，第三条写入新块
+	for i := 0; i < 3; i++ {
+		if err := store.AddMessage("conv_compact", 1, []byte("x"), nil); err != nil {
+			t.Fatalf("Failed to add message: %v", err)
+		}
+	}
+
+	records, _, err := readWALRecords(filepath.Join(tempDir, walFileName))
+	if err != nil {
+		t.Fatalf("Failed to read wal: %v", err)
+	}
+	if len(records) != 1 {
+		t.Errorf("Expected only the unflushed record to remain, got %d", len(records))
+	}
+}
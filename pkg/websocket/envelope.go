@@ -0,0 +1,159 @@
+package websocket
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ProtocolV2 is the subprotocol name clients offer to receive typed envelopes.
+// Connections without it keep receiving the legacy {op, data} payloads.
+const ProtocolV2 = "imy.v2"
+
+// EnvelopeType identifies the kind of event carried in an Envelope.
+type EnvelopeType string
+
+const (
+	EnvelopeMessage      EnvelopeType = "message"      // new, edited, deleted or recalled messages
+	EnvelopeReceipt      EnvelopeType = "receipt"      // acks, read markers and unread counters
+	EnvelopePresence     EnvelopeType = "presence"     // a peer came online or went offline
+	EnvelopeTyping       EnvelopeType = "typing"       // a peer is typing in a conversation
+	EnvelopeError        EnvelopeType = "error"        // a client frame was rejected
+	EnvelopeConversation EnvelopeType = "conversation" // conversation and membership changes
+	EnvelopeResume       EnvelopeType = "resume"       // resume request from the client, replay summary from the server
+	EnvelopeReady        EnvelopeType = "ready"        // first frame after the connection is accepted
+	EnvelopeAuth         EnvelopeType = "auth"         // token renewal from the client, its result from the server
+	EnvelopeExpiring     EnvelopeType = "expiring"     // the connection token is about to expire
+	EnvelopeLogout       EnvelopeType = "logout"       // the login session was revoked, the connection is closed next
+)
+
+// Durable reports whether events of this type are journaled and replayed on resume.
+// Presence, typing and errors only make sense while the connection is live.
+func (t EnvelopeType) Durable() bool {
+	switch t {
+	case EnvelopeMessage, EnvelopeReceipt, EnvelopeConversation:
+		return true
+	}
+	return false
+}
+
+// Envelope is the versioned frame exchanged over a v2 connection.
+// Seq is the per-user journal sequence for durable events and 0 otherwise;
+// clients should remember the highest seq they processed and drop duplicates.
+type Envelope struct {
+	Type    EnvelopeType    `json:"type"`
+	Seq     int64           `json:"seq,omitempty"`
+	ConvID  uint32          `json:"convId,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ResumePayload is sent by the client after connecting to request a replay of
+// durable events after LastSeq. The server answers with the missed envelopes
+// followed by a resume envelope carrying ResumeResult.
+type ResumePayload struct {
+	LastSeq int64 `json:"lastSeq"`
+}
+
+// ResumeResult summarizes a replay. When HasMore is set the client should send
+// another resume with the new LastSeq.
+type ResumeResult struct {
+	Replayed int   `json:"replayed"`
+	LastSeq  int64 `json:"lastSeq"`
+	HasMore  bool  `json:"hasMore"`
+}
+
+// ReceiptPayload is sent by the client to acknowledge every durable event up to Seq.
+type ReceiptPayload struct {
+	Seq int64 `json:"seq"`
+}
+
+// TypingPayload is forwarded to the other members of ConvID.
+type TypingPayload struct {
+	UUID   string `json:"uuid,omitempty"`
+	Typing bool   `json:"typing"`
+}
+
+// PresencePayload announces a user's online state to their conversation peers.
+type PresencePayload struct {
+	UUID   string `json:"uuid"`
+	Online bool   `json:"online"`
+}
+
+// AuthPayload is sent by the client to replace the connection token before it
+// expires. The token must belong to the same user; the server answers with an
+// auth envelope carrying AuthResult, or an error envelope with code auth_failed.
+type AuthPayload struct {
+	Token string `json:"token"`
+}
+
+// AuthResult confirms a renewal and carries the new expiry (unix seconds).
+type AuthResult struct {
+	UUID      string `json:"uuid"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// ExpiringPayload warns that the connection is closed with 1008 (policy
+// violation) at ExpiresAt (unix seconds) unless the client renews its token.
+type ExpiringPayload struct {
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Logout reasons carried in LogoutPayload.
+const (
+	LogoutRevoked       = "revoked"        // revoked from another device
+	LogoutEvicted       = "evicted"        // dropped because the user has too many sessions
+	LogoutRefreshReused = "refresh_reused" // a used refresh token was replayed, it may have leaked
+)
+
+// LogoutPayload tells the client that its login session ended. The server
+// closes the connection with 1008 right after it; the client must sign in
+// again instead of refreshing or reconnecting.
+type LogoutPayload struct {
+	SessionID string `json:"sessionId"`
+	Reason    string `json:"reason"`
+}
+
+// ErrorPayload describes why a client frame was rejected.
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// EnvelopeTypeForOp maps a legacy op name such as "message_new" to an envelope type.
+func EnvelopeTypeForOp(op string) EnvelopeType {
+	switch {
+	case op == "message_ack" || op == "message_read" || op == "unread_count_change":
+		return EnvelopeReceipt
+	case strings.HasPrefix(op, "message_") || op == "mention_new":
+		return EnvelopeMessage
+	case op == "ready":
+		return EnvelopeReady
+	}
+	return EnvelopeConversation
+}
+
+// NewEnvelope marshals payload into an envelope without a seq.
+func NewEnvelope(t EnvelopeType, convID uint32, payload any) (*Envelope, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{Type: t, ConvID: convID, Payload: raw}, nil
+}
+
+// WrapLegacy converts a legacy {op, data} payload into an envelope, taking the
+// conversation id from data.conversationId when present.
+func WrapLegacy(v any) (*Envelope, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var probe struct {
+		Op   string `json:"op"`
+		Data struct {
+			ConversationId uint32 `json:"conversationId"`
+		} `json:"data"`
+	}
+	// data may be an array or scalar for some ops; the conversation id is optional
+	_ = json.Unmarshal(raw, &probe)
+	return &Envelope{Type: EnvelopeTypeForOp(probe.Op), ConvID: probe.Data.ConversationId, Payload: raw}, nil
+}
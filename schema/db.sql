@@ -68,6 +68,26 @@ create table if not exists friend_verify
   collate = utf8mb4_general_ci
   row_format = Dynamic comment ='好友验证表';
 
+# 好友黑名单表，解除拉黑时直接删除记录
+drop table if exists friend_block;
+create table if not exists friend_block
+(
+    id         int unsigned primary key auto_increment comment '主键id',
+    user_uuid varchar(64) not null default '' comment '拉黑方',
+    block_uuid varchar(64) not null default '' comment '被拉黑的用户',
+
+    created_at datetime     not null default now() comment '数据插入时间',
+    updated_at datetime     not null default now() on update now() comment '数据更新时间',
+    deleted_at tinyint(1)   not null default 0 comment '删除标记',
+    unique key uidx_user_block (user_uuid, block_uuid) using btree,
+    index idx_block_uuid (block_uuid) using btree,
+    index idx_deleted_at (deleted_at) using btree
+) engine = InnoDB
+  auto_increment = 1
+  character set = utf8mb4
+  collate = utf8mb4_general_ci
+  row_format = Dynamic comment ='好友黑名单表';
+
 
 
 # 聊天会话表
@@ -83,6 +103,7 @@ create table if not exists chat_conversation
     last_message_id    bigint unsigned not null default 0 comment '最后一条消息的id，用于展示',
     avatar             varchar(512) not null default '' comment '会话头像（群聊）',
     extra              varchar(1024) not null default '' comment '扩展信息（置顶、公告等）',
+    announcement_only  tinyint(1) not null default 0 comment '仅群主和管理员可发言',
 
     created_at datetime     not null default now() comment '数据插入时间',
     updated_at datetime     not null default now() on update now() comment '数据更新时间,最后一次登陆时间',
@@ -108,7 +129,7 @@ create table if not exists chat_conversation_member
     id                   int unsigned primary key auto_increment comment '主键id',
     conversation_id      int unsigned not null default 0 comment '会话id',
     user_uuid            varchar(64) not null default '' comment '成员uuid',
-    role                 tinyint not null default 1 comment '成员角色，1表示普通成员，2表示管理员，群聊使用',
+    role                 tinyint not null default 1 comment '成员角色，1表示普通成员，2表示管理员，3表示群主，群聊使用',
 
     last_read_message_id bigint unsigned not null default 0 comment '最后已读消息ID',
     last_read_at         datetime not null default now() comment '最后已读时间',
@@ -158,10 +179,55 @@ create table if not exists chat_message
     unique key uidx_conv_sender_client (conversation_id, send_uuid, client_msg_id) using btree,
     index idx_conv (conversation_id) using btree,
     index idx_conv_id (conversation_id, id) using btree,
+    index idx_conv_reply (conversation_id, reply_to_message_id, id) using btree,
     index idx_send_uuid (send_uuid) using btree,
     index idx_deleted_at (deleted_at) using btree
 ) engine = InnoDB
   auto_increment = 1
   character set = utf8mb4
   collate = utf8mb4_general_ci
-  row_format = Dynamic comment ='聊天信息表';
\ No newline at end of file
+  row_format = Dynamic comment ='聊天信息表';
+
+# 消息提及记录表，发送消息时为每个被提及的会话成员写入一条
+drop table if exists chat_mention;
+create table if not exists chat_mention
+(
+    id              bigint unsigned primary key auto_increment comment '主键id',
+    conversation_id int unsigned not null default 0 comment '会话id',
+    message_id      bigint unsigned not null default 0 comment '消息id',
+    mention_uuid    varchar(64) not null default '' comment '被提及的用户uuid',
+    send_uuid       varchar(64) not null default '' comment '发送方uuid',
+
+    created_at datetime     not null default now() comment '数据插入时间',
+    updated_at datetime     not null default now() on update now() comment '数据更新时间',
+    deleted_at tinyint(1)   not null default 0 comment '删除标记',
+
+    unique key uidx_mention_message (mention_uuid, message_id) using btree,
+    index idx_mention_conv_message (mention_uuid, conversation_id, message_id) using btree,
+    index idx_deleted_at (deleted_at) using btree
+) engine = InnoDB
+  auto_increment = 1
+  character set = utf8mb4
+  collate = utf8mb4_general_ci
+  row_format = Dynamic comment ='消息提及记录表';
+
+# 会话置顶消息表，取消置顶时直接删除记录
+drop table if exists chat_pinned_message;
+create table if not exists chat_pinned_message
+(
+    id              int unsigned primary key auto_increment comment '主键id',
+    conversation_id int unsigned not null default 0 comment '会话id',
+    message_id      bigint unsigned not null default 0 comment '消息id',
+    pin_uuid        varchar(64) not null default '' comment '置顶操作人',
+
+    created_at datetime     not null default now() comment '数据插入时间',
+    updated_at datetime     not null default now() on update now() comment '数据更新时间',
+    deleted_at tinyint(1)   not null default 0 comment '删除标记',
+
+    unique key uidx_conv_message (conversation_id, message_id) using btree,
+    index idx_deleted_at (deleted_at) using btree
+) engine = InnoDB
+  auto_increment = 1
+  character set = utf8mb4
+  collate = utf8mb4_general_ci
+  row_format = Dynamic comment ='会话置顶消息表';
\ No newline at end of file